@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/agents"
+	"gptcode/internal/modes"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Custom agents defined in YAML",
+	Long: `Define your own agents next to router, query, editor and research.
+
+Each agent is a YAML file in ~/.gptcode/agents with a system prompt, the
+tools it may call (builtin or mcp_<server>_<tool>) and hints for choosing
+its model. In chat, a message starting with @name, or containing one of the
+agent's triggers, is routed to it.
+
+  name: migrator
+  description: Moves raw SQL to the query builder
+  system_prompt: |
+    You migrate raw SQL in internal/db to the query builder. Keep the
+    queries' behavior, and run the package's tests after each file.
+  tools: [read_file, search_code, apply_patch, run_command]
+  triggers: ["query builder"]
+  model:
+    agent: editor        # the model configured for a builtin agent
+    # id: gpt-4o         # or an explicit model or alias
+    # action: edit       # or the model selector's pick for an action
+
+Examples:
+  gptcode agent list
+  gptcode agent run migrator "migrate internal/db/users.go"`,
+}
+
+var agentListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the custom agents",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		specs, err := agents.LoadCustomAgents(agents.CustomAgentsDir())
+		if err != nil {
+			return err
+		}
+		if len(specs) == 0 {
+			fmt.Printf("No agents defined in %s\n", agents.CustomAgentsDir())
+			return nil
+		}
+		for _, s := range specs {
+			fmt.Printf("%-20s %s\n", s.Name, s.Description)
+			if len(s.Tools) > 0 {
+				fmt.Printf("%-20s tools: %s\n", "", strings.Join(s.Tools, ", "))
+			}
+		}
+		return nil
+	},
+}
+
+var agentRunCmd = &cobra.Command{
+	Use:   "run <name> <task>",
+	Short: "Run a custom agent on a task",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return modes.RunAgent(args[0], strings.Join(args[1:], " "))
+	},
+}
+
+func init() {
+	agentCmd.AddCommand(agentListCmd)
+	agentCmd.AddCommand(agentRunCmd)
+	rootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/bugreport"
+	"gptcode/internal/observability"
+)
+
+var bugreportCmd = &cobra.Command{
+	Use:   "bugreport [session]",
+	Short: "Bundle a session into a tarball for a gptcode bug report",
+	Long: `Bundle a session into a tarball you can attach to an issue against gptcode.
+
+The bundle contains the session's trace, the prompts and responses sent to
+the LLM, the files it touched, the working tree diff, setup.yaml and
+environment information (OS, Go, git state). API keys, tokens, private keys
+and header values are redacted; review the bundle before sharing it.
+
+The session is the ID in a trace_<session>_*.json file in the current
+directory; a prefix is enough. Without one, the latest session is used.
+
+Prompts and responses are recorded in ~/.gptcode/transcripts (the last 20
+sessions are kept).
+
+Examples:
+  gptcode bugreport                     # Latest session
+  gptcode bugreport 3f2a9c1e -o bug.tar.gz
+  gptcode bugreport replay bug.tar.gz   # For maintainers`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		session := "latest"
+		if len(args) == 1 {
+			session = args[0]
+		}
+		out, _ := cmd.Flags().GetString("output")
+		dir, _ := os.Getwd()
+
+		path, err := bugreport.Create(session, bugreport.Options{Dir: dir, Version: version, Output: out})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Bug report written to %s\n", path)
+		fmt.Println("Review it for anything private before attaching it to an issue.")
+		return nil
+	},
+}
+
+var bugreportReplayCmd = &cobra.Command{
+	Use:   "replay <bundle>",
+	Short: "Replay a bug report bundle",
+	Long: `Show a bug report bundle as a timeline of trace steps, prompts and responses.
+
+With --extract the bundled files, config, diff, trace and transcript are
+written to a directory to reproduce the session.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		full, _ := cmd.Flags().GetBool("full")
+		extract, _ := cmd.Flags().GetString("extract")
+
+		b, err := bugreport.Load(args[0])
+		if err != nil {
+			return err
+		}
+		if extract != "" {
+			if err := b.Extract(extract); err != nil {
+				return err
+			}
+			fmt.Printf("Extracted to %s\n", extract)
+			return nil
+		}
+		b.Replay(os.Stdout, full)
+		return nil
+	},
+}
+
+var bugreportDiffCmd = &cobra.Command{
+	Use:   "diff <session> [session]",
+	Short: "Show what changed in the environment between two sessions",
+	Long: `Compare the environments recorded in two sessions' traces: OS, toolchain
+versions (go, node, mix, ...), git commit and branch, uncommitted files and the
+models called. The second session defaults to the latest.
+
+Examples:
+  gptcode bugreport diff 3f2a9c1e            # Against the latest session
+  gptcode bugreport diff 3f2a9c1e 9b41d07a`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := os.Getwd()
+		if len(args) == 1 {
+			args = append(args, "latest")
+		}
+		var envs []*observability.Environment
+		for _, session := range args {
+			path, err := bugreport.FindTrace(dir, session)
+			if err != nil {
+				return err
+			}
+			trace, err := bugreport.LoadTrace(path)
+			if err != nil {
+				return err
+			}
+			if trace.Environment == nil {
+				return fmt.Errorf("session %s recorded no environment", trace.SessionID)
+			}
+			envs = append(envs, trace.Environment)
+		}
+
+		changes := envs[0].Diff(envs[1])
+		if len(changes) == 0 {
+			fmt.Println("The environments are the same")
+			return nil
+		}
+		for _, c := range changes {
+			fmt.Println(c)
+		}
+		return nil
+	},
+}
+
+func init() {
+	bugreportCmd.Flags().StringP("output", "o", "", "Output file (default: gptcode-bugreport-<session>.tar.gz)")
+	bugreportReplayCmd.Flags().Bool("full", false, "Show complete prompts and responses")
+	bugreportReplayCmd.Flags().String("extract", "", "Write the bundle's contents to this directory instead")
+	bugreportCmd.AddCommand(bugreportReplayCmd)
+	bugreportCmd.AddCommand(bugreportDiffCmd)
+	rootCmd.AddCommand(bugreportCmd)
+}
+
+// startTranscript records this invocation's LLM prompts and responses for
+// bug reports.
+func startTranscript(cmd *cobra.Command, session string) {
+	if cmd == bugreportCmd || cmd.Parent() == bugreportCmd {
+		return
+	}
+	observability.SetTranscript(observability.NewTranscript(session))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/llm"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the LLM response cache",
+	Long: `Manage the cache of LLM responses in ~/.gptcode/cache/llm.
+
+Research, review and query agents reuse the response to a request they have
+sent before (same model, prompts, messages and tools) instead of calling the
+model again. Responses are kept for cache.ttl_hours (default 24) and the
+oldest are evicted past cache.max_size_mb (default 100).
+
+Disable it for one invocation with --no-cache or GPTCODE_NO_CACHE=1, or
+for good with cache.disabled in setup.yaml.
+
+Examples:
+  gptcode cache stats
+  gptcode cache clear`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how many responses are cached",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		setup, _ := config.LoadEffectiveSetup()
+		c := newResponseCache(setup)
+		stats, err := c.Stats()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Cache:    %s\n", c.Dir)
+		fmt.Printf("Entries:  %d (%d expired)\n", stats.Entries, stats.Expired)
+		fmt.Printf("Size:     %.1f MB of %d MB\n", float64(stats.Size)/(1<<20), c.MaxSize>>20)
+		fmt.Printf("TTL:      %s\n", c.TTL)
+		if setup.Cache.Disabled {
+			fmt.Println("Status:   disabled (cache.disabled)")
+		}
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached response",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		n, err := llm.NewCache(llm.CacheDir(), 0, 0).Clear()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d cached responses\n", n)
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Always call the model instead of reusing cached responses")
+}
+
+// startCache installs the response cache unless --no-cache,
+// GPTCODE_NO_CACHE=1 or cache.disabled turn it off.
+func startCache(cmd *cobra.Command) {
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	setup, _ := config.LoadEffectiveSetup()
+	if noCache || os.Getenv("GPTCODE_NO_CACHE") == "1" || setup.Cache.Disabled {
+		llm.SetCache(nil)
+		return
+	}
+	llm.SetCache(newResponseCache(setup))
+}
+
+func newResponseCache(setup *config.Setup) *llm.Cache {
+	return llm.NewCache(llm.CacheDir(),
+		time.Duration(setup.Cache.TTLHours)*time.Hour,
+		int64(setup.Cache.MaxSizeMB)<<20)
+}
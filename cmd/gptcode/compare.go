@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/compare"
+	"gptcode/internal/config"
+	"gptcode/internal/output"
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <prompt or task>",
+	Short: "Ask several models the same thing and compare their answers",
+	Long: `Send the same prompt to several models in parallel and show their answers
+side by side with latency, tokens and cost.
+
+With --file the models make a small edit instead: each one edits its own
+temporary copy of the file following the task, and the answers are shown as
+diffs. --apply writes the preferred version over the file.
+
+Models are backend/model, or a model of the default backend. Pick the best
+answer when asked (or with --pick) to record it as feedback: the preferred
+model gets good feedback and the others bad, which model selection learns from.
+
+Examples:
+  gptcode compare "Explain Go's context cancellation" --models groq/llama-3.3-70b-versatile,openai/gpt-4o-mini
+  gptcode compare "Add input validation to CreateUser" --file api/users.go --models a,b,c
+  gptcode compare "Simplify this function" --file util.go --models a,b --pick 2 --apply`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCompare,
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+
+	compareCmd.Flags().String("models", "", "Comma-separated models to compare (required)")
+	compareCmd.Flags().String("file", "", "Make the models edit a temporary copy of this file")
+	compareCmd.Flags().Int("pick", 0, "Record the answer of this model (1-based) as preferred without asking")
+	compareCmd.Flags().Bool("apply", false, "Write the preferred edit over --file")
+	compareCmd.Flags().Duration("timeout", 3*time.Minute, "Time limit for the models to answer")
+	_ = compareCmd.MarkFlagRequired("models")
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	spec, _ := cmd.Flags().GetString("models")
+	file, _ := cmd.Flags().GetString("file")
+	pick, _ := cmd.Flags().GetInt("pick")
+	apply, _ := cmd.Flags().GetBool("apply")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	if apply && file == "" {
+		return fmt.Errorf("--apply needs --file")
+	}
+
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	targets, err := compare.ParseTargets(spec, setup)
+	if err != nil {
+		return err
+	}
+	if pick < 0 || pick > len(targets) {
+		return fmt.Errorf("--pick must be between 1 and %d", len(targets))
+	}
+
+	dir, err := os.MkdirTemp("", "gptcode-compare-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	task := compare.Task{Prompt: args[0], File: file}
+	progress := output.StartSpinner(os.Stderr, fmt.Sprintf("Asking %d models", len(targets)))
+	results := compare.Run(ctx, setup, targets, task, dir)
+	progress.Stop()
+
+	printComparison(results)
+
+	if pick == 0 {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return nil
+		}
+		pick = askPreference(len(results))
+		if pick == 0 {
+			return nil
+		}
+	}
+	preferred := results[pick-1]
+	if preferred.Error != nil {
+		return fmt.Errorf("%s failed; it cannot be preferred", preferred.Target)
+	}
+	if err := compare.RecordPreference(task, results, pick-1); err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+	fmt.Println(output.OKf("Recorded %s as preferred", preferred.Target))
+
+	if apply {
+		edited, err := os.ReadFile(preferred.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, edited, 0644); err != nil {
+			return fmt.Errorf("failed to apply edit: %w", err)
+		}
+		fmt.Println(output.OKf("Applied the edit of %s to %s", preferred.Target, file))
+	}
+	return nil
+}
+
+// printComparison shows the answers in columns when they fit the terminal,
+// and one after the other otherwise
+func printComparison(results []compare.Result) {
+	width := output.GetTerminalWidth()
+	columnWidth := width/len(results) - 1
+	sideBySide := !output.Accessible() && len(results) <= 3 && columnWidth >= 40
+
+	var columns []string
+	for i, r := range results {
+		var b strings.Builder
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, r.Target)
+		if r.Error != nil {
+			fmt.Fprintf(&b, "%s\n", output.Failf("%v", r.Error))
+		} else {
+			fmt.Fprintf(&b, "%.1fs, %d in / %d out tokens, $%.4f\n\n", r.Latency.Seconds(), r.TokensIn, r.TokensOut, r.Cost)
+			body := r.Text
+			if r.Path != "" {
+				body = r.Diff
+				if body == "" {
+					body = "(no changes)"
+				}
+			}
+			b.WriteString(strings.TrimSpace(body))
+		}
+
+		if !sideBySide {
+			fmt.Println(output.Separator())
+			fmt.Println(b.String())
+			continue
+		}
+		columns = append(columns, lipgloss.NewStyle().Width(columnWidth).PaddingRight(1).Render(b.String()))
+	}
+	if sideBySide {
+		fmt.Println(lipgloss.JoinHorizontal(lipgloss.Top, columns...))
+	}
+	fmt.Println(output.Separator())
+}
+
+func askPreference(n int) int {
+	fmt.Printf("Which answer is best? [1-%d, Enter to skip] ", n)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > n {
+		return 0
+	}
+	return choice
+}
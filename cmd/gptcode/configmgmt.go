@@ -53,7 +53,7 @@ func init() {
 }
 
 func runConfigList(cmd *cobra.Command, args []string) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -105,7 +105,7 @@ func runConfigUpdate(cmd *cobra.Command, args []string) error {
 	key := args[0]
 	value := args[1]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -181,12 +181,7 @@ func getConfigProvider(setup *config.Setup) (llm.Provider, string, error) {
 		return nil, "", fmt.Errorf("backend %s not configured", backendName)
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	if model == "" {
 		model = backendCfg.GetModelForAgent("editor")
@@ -6,11 +6,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
+	"gptcode/internal/config"
 	"gptcode/internal/live"
+	"gptcode/internal/output"
 )
 
 var contextCmd = &cobra.Command{
@@ -49,7 +52,17 @@ var contextShowCmd = &cobra.Command{
 var contextSyncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync context to integration formats (WARP.md, .cursor/docs, etc)",
-	RunE:  runContextSync,
+	Long: `Write the .gptcode context to every integration enabled in .gptcode/config.yml.
+
+With --watch, sync keeps running and syncs again whenever a context file or
+config.yml changes, waiting --debounce for a burst of edits to settle.
+Use 'gptcode context service' to run the watcher in the background.
+
+Examples:
+  gptcode context sync
+  gptcode context sync --watch
+  gptcode context sync --watch --debounce 2s`,
+	RunE: runContextSync,
 }
 
 var contextExportCmd = &cobra.Command{
@@ -80,6 +93,9 @@ func init() {
 	contextCmd.AddCommand(contextSyncCmd)
 	contextCmd.AddCommand(contextExportCmd)
 	contextCmd.AddCommand(contextLiveCmd)
+
+	contextSyncCmd.Flags().Bool("watch", false, "Keep syncing whenever .gptcode/context or config.yml changes")
+	contextSyncCmd.Flags().Duration("debounce", 500*time.Millisecond, "How long to wait for edits to settle before syncing")
 }
 
 type ContextConfig struct {
@@ -107,19 +123,10 @@ func getGPTCodeDir() (string, error) {
 		return "", err
 	}
 
-	// Search for .gptcode in current dir and parents
-	dir := cwd
-	for {
-		gptcodePath := filepath.Join(dir, ".gptcode")
-		if _, err := os.Stat(gptcodePath); err == nil {
-			return gptcodePath, nil
-		}
-
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			break
-		}
-		dir = parent
+	// Search for .gptcode in current dir and parents, up to the repository
+	// root
+	if dir, ok := config.FindProjectDir(cwd, ".gptcode"); ok {
+		return filepath.Join(dir, ".gptcode"), nil
 	}
 
 	return "", fmt.Errorf(".gptcode directory not found (run 'gptcode context init')")
@@ -131,7 +138,7 @@ func runContextInit(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	gptcodeDir := filepath.Join(cwd, ".gptcode")
+	gptcodeDir := filepath.Join(config.ProjectRoot(cwd), ".gptcode")
 	contextDir := filepath.Join(gptcodeDir, "context")
 
 	if _, err := os.Stat(gptcodeDir); err == nil {
@@ -331,15 +338,59 @@ func runContextSync(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if watch, _ := cmd.Flags().GetBool("watch"); watch {
+		debounce, _ := cmd.Flags().GetDuration("debounce")
+		return watchContext(gptcodeDir, debounce)
+	}
+
+	synced, err := syncContext(gptcodeDir)
+	if err != nil {
+		return err
+	}
+	if synced == 0 {
+		fmt.Println(output.Infof("No integrations enabled. Edit .gptcode/config.yml to enable."))
+	} else {
+		fmt.Println("\n" + output.OKf("Synced to %d integration(s)", synced))
+	}
+
+	return nil
+}
+
+// contextExporter writes the context to one integration's format
+type contextExporter struct {
+	name    string
+	target  string
+	enabled func(ContextConfig) bool
+	sync    func(gptcodeDir, projectRoot string, config ContextConfig) error
+}
+
+var contextExporters = []contextExporter{
+	{
+		name:    "Warp",
+		target:  "WARP.md",
+		enabled: func(c ContextConfig) bool { return c.Integrations.Warp.Enabled },
+		sync:    syncToWarp,
+	},
+	{
+		name:    "Cursor",
+		target:  ".cursor/docs/",
+		enabled: func(c ContextConfig) bool { return c.Integrations.Cursor.Enabled },
+		sync:    syncToCursor,
+	},
+}
+
+// syncContext writes the context to every enabled integration and returns
+// how many were synced. Failures of one integration are reported and skipped.
+func syncContext(gptcodeDir string) (int, error) {
 	configPath := filepath.Join(gptcodeDir, "config.yml")
 	configData, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("failed to read config: %w", err)
+		return 0, fmt.Errorf("failed to read config: %w", err)
 	}
 
 	var config ContextConfig
 	if err := yaml.Unmarshal(configData, &config); err != nil {
-		return fmt.Errorf("failed to parse config: %w", err)
+		return 0, fmt.Errorf("failed to parse config: %w", err)
 	}
 
 	fmt.Println("🔄 Syncing context to integrations...")
@@ -347,31 +398,19 @@ func runContextSync(cmd *cobra.Command, args []string) error {
 	projectRoot := filepath.Dir(gptcodeDir)
 	synced := 0
 
-	if config.Integrations.Warp.Enabled {
-		if err := syncToWarp(gptcodeDir, projectRoot, config); err != nil {
-			fmt.Printf("⚠️  Warp sync failed: %v\n", err)
-		} else {
-			fmt.Println("✅ Synced to WARP.md")
-			synced++
+	for _, exporter := range contextExporters {
+		if !exporter.enabled(config) {
+			continue
 		}
-	}
-
-	if config.Integrations.Cursor.Enabled {
-		if err := syncToCursor(gptcodeDir, projectRoot, config); err != nil {
-			fmt.Printf("⚠️  Cursor sync failed: %v\n", err)
+		if err := exporter.sync(gptcodeDir, projectRoot, config); err != nil {
+			fmt.Println(output.Warnf("%s sync failed: %v", exporter.name, err))
 		} else {
-			fmt.Println("✅ Synced to .cursor/docs/")
+			fmt.Println(output.OKf("Synced to %s", exporter.target))
 			synced++
 		}
 	}
 
-	if synced == 0 {
-		fmt.Println("ℹ️  No integrations enabled. Edit .gptcode/config.yml to enable.")
-	} else {
-		fmt.Printf("\n✅ Synced to %d integration(s)\n", synced)
-	}
-
-	return nil
+	return synced, nil
 }
 
 func syncToWarp(gptcodeDir, projectRoot string, config ContextConfig) error {
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestContextInit(t *testing.T) {
@@ -211,6 +214,58 @@ func TestContextAdd(t *testing.T) {
 	}
 }
 
+func TestWatchFilesDebounces(t *testing.T) {
+	dir := t.TempDir()
+	contextDir := filepath.Join(dir, "context")
+	if err := os.MkdirAll(contextDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	synced := make(chan struct{}, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- watchFiles(ctx, []string{dir, contextDir}, isContextSource, 100*time.Millisecond, func() { synced <- struct{}{} })
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// A burst of edits, including ones that should be ignored, syncs once
+	for i := 0; i < 5; i++ {
+		_ = os.WriteFile(filepath.Join(contextDir, "shared.md"), []byte(fmt.Sprint(i)), 0644)
+		_ = os.WriteFile(filepath.Join(contextDir, ".shared.md.swp"), []byte("x"), 0644)
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-synced:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no sync after editing shared.md")
+	}
+	select {
+	case <-synced:
+		t.Error("a burst of edits should sync once")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	_ = os.WriteFile(filepath.Join(dir, "config.yml"), []byte("x"), 0644)
+	select {
+	case <-synced:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no sync after editing config.yml")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watchFiles returned %v", err)
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	if got := serviceName("context", "/home/me/My App!"); got != "gptcode-context-my-app" {
+		t.Errorf("serviceName = %q", got)
+	}
+}
+
 func contains(s, substr string) bool {
 	return len(s) > 0 && len(substr) > 0 && (s == substr || len(s) >= len(substr) && findSubstring(s, substr))
 }
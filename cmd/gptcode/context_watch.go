@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/output"
+)
+
+var contextServiceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Generate a systemd or launchd service that runs context sync --watch",
+	Long: `Generate a user service that keeps this project's context synced in the
+background by running 'gptcode context sync --watch'.
+
+On Linux a systemd user unit is generated, on macOS a launchd agent. Without
+--install the service is printed; with --install it is written to
+~/.config/systemd/user or ~/Library/LaunchAgents along with the command
+that starts it.
+
+Examples:
+  gptcode context service                  # Print the service for this OS
+  gptcode context service --install        # Write it to the user service directory
+  gptcode context service --format launchd # Print a launchd agent on any OS`,
+	Args: cobra.NoArgs,
+	RunE: runContextService,
+}
+
+func init() {
+	contextCmd.AddCommand(contextServiceCmd)
+	contextServiceCmd.Flags().Bool("install", false, "Write the service to the user service directory")
+	contextServiceCmd.Flags().String("format", "", "Service format: systemd or launchd (default: for this OS)")
+}
+
+// watchContext syncs the context once and then again whenever the context
+// files or config.yml change, until interrupted.
+func watchContext(gptcodeDir string, debounce time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	syncNow := func() {
+		if _, err := syncContext(gptcodeDir); err != nil {
+			fmt.Println(output.Warnf("%v", err))
+		}
+	}
+	syncNow()
+	fmt.Println(output.Infof("Watching %s for changes (Ctrl+C to stop)", gptcodeDir))
+
+	dirs := []string{gptcodeDir, filepath.Join(gptcodeDir, "context")}
+	return watchFiles(ctx, dirs, isContextSource, debounce, syncNow)
+}
+
+// isContextSource reports whether a change to path should trigger a sync:
+// context markdown files and config.yml, but not editor swap or backup files.
+func isContextSource(path string) bool {
+	name := filepath.Base(path)
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return false
+	}
+	if filepath.Base(filepath.Dir(path)) == "context" {
+		return strings.HasSuffix(name, ".md")
+	}
+	return name == "config.yml"
+}
+
+// watchFiles calls onChange once per burst of changes to the files in dirs
+// accepted by match, after debounce has passed without further changes.
+// Directories are watched rather than files so editors that save by
+// renaming a temporary file are still seen.
+func watchFiles(ctx context.Context, dirs []string, match func(string) bool, debounce time.Duration, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod || !match(event.Name) {
+				continue
+			}
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, output.Warnf("Watch error: %v", err))
+		case <-timer.C:
+			onChange()
+		}
+	}
+}
+
+func runContextService(cmd *cobra.Command, args []string) error {
+	gptcodeDir, err := getGPTCodeDir()
+	if err != nil {
+		return err
+	}
+	install, _ := cmd.Flags().GetBool("install")
+	format, _ := cmd.Flags().GetString("format")
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the gptcode binary: %w", err)
+	}
+	svc := userService{
+		Name:        serviceName("context", filepath.Dir(gptcodeDir)),
+		Description: "GPTCode context sync for " + filepath.Dir(gptcodeDir),
+		Executable:  exe,
+		Args:        []string{"context", "sync", "--watch"},
+		ProjectRoot: filepath.Dir(gptcodeDir),
+	}
+	return writeUserService(svc, format, install)
+}
+
+// writeUserService prints svc as a systemd unit or launchd agent (by
+// default, the one for this OS), or with install writes it to the user
+// service directory.
+func writeUserService(svc userService, format string, install bool) error {
+	if format == "" {
+		format = "systemd"
+		if runtime.GOOS == "darwin" {
+			format = "launchd"
+		}
+	}
+	var tmpl *template.Template
+	var path, start string
+	home, _ := os.UserHomeDir()
+	switch format {
+	case "systemd":
+		tmpl = systemdTemplate
+		path = filepath.Join(home, ".config", "systemd", "user", svc.Name+".service")
+		start = "systemctl --user daemon-reload && systemctl --user enable --now " + svc.Name
+	case "launchd":
+		tmpl = launchdTemplate
+		svc.Name = "com.gptcode." + svc.Name
+		svc.LogPath = filepath.Join(home, ".gptcode", "logs", svc.Name+".log")
+		path = filepath.Join(home, "Library", "LaunchAgents", svc.Name+".plist")
+		start = "launchctl load -w " + path
+	default:
+		return fmt.Errorf("invalid format %q. Use: systemd, launchd", format)
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, svc); err != nil {
+		return err
+	}
+	if !install {
+		fmt.Print(b.String())
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if svc.LogPath != "" {
+		if err := os.MkdirAll(filepath.Dir(svc.LogPath), 0755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	fmt.Println(output.OKf("Wrote %s", path))
+	fmt.Printf("Start it with:\n  %s\n", start)
+	return nil
+}
+
+// userService is what the service templates are rendered with
+type userService struct {
+	Name        string
+	Description string
+	Executable  string
+	Args        []string
+	ProjectRoot string
+	LogPath     string
+}
+
+var invalidServiceChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// serviceName names the service after its kind and the project directory,
+// so each project gets its own.
+func serviceName(kind, projectRoot string) string {
+	return "gptcode-" + kind + "-" + projectSlug(projectRoot)
+}
+
+// projectSlug is the project directory's name reduced to [a-z0-9_-]
+func projectSlug(projectRoot string) string {
+	name := strings.Trim(invalidServiceChars.ReplaceAllString(filepath.Base(projectRoot), "-"), "-")
+	if name == "" {
+		name = "project"
+	}
+	return strings.ToLower(name)
+}
+
+var systemdTemplate = template.Must(template.New("systemd").Parse(`[Unit]
+Description={{.Description}}
+
+[Service]
+Type=simple
+WorkingDirectory={{.ProjectRoot}}
+ExecStart="{{.Executable}}"{{range .Args}} {{.}}{{end}}
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=default.target
+`))
+
+var launchdTemplate = template.Must(template.New("launchd").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Name}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{.Executable}}</string>
+{{- range .Args}}
+		<string>{{.}}</string>
+{{- end}}
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{.ProjectRoot}}</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>{{.LogPath}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{.LogPath}}</string>
+</dict>
+</plist>
+`))
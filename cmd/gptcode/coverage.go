@@ -38,7 +38,7 @@ func runCoverage(cmd *cobra.Command, args []string) error {
 		pkgPath = args[0]
 	}
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -87,12 +87,7 @@ func getCoverageProvider(setup *config.Setup) (llm.Provider, string, error) {
 		return nil, "", fmt.Errorf("backend %s not configured", backendName)
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	if model == "" {
 		model = backendCfg.GetModelForAgent("query")
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/daemon"
+	"gptcode/internal/forge"
+	"gptcode/internal/github"
+	"gptcode/internal/observability"
+	"gptcode/internal/output"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run scheduled maintenance jobs for this project",
+	Long: `Run the maintenance jobs defined under daemon.jobs in .gptcode/config.yml
+on their schedules, e.g. a weekly dependency upgrade, a nightly security
+scan or a monthly docs update.
+
+Each job runs a gptcode command. A "report" job writes its output to
+~/.gptcode/daemon/<project>/reports; a "pr" job runs the command on a fresh
+branch in its own worktree and opens a PR with the changes. Jobs have their
+own cost budget (max_cost) and timeout, and notify a webhook or the desktop
+when they fail.
+
+  daemon:
+    notify:
+      webhook: https://hooks.slack.com/services/...
+    jobs:
+      - name: deps
+        schedule: weekly
+        command: deps upgrade --minor
+        output: pr
+        max_cost: 0.50
+      - name: security
+        schedule: "0 1 * * *"
+        command: security scan
+        timeout: 30m
+
+With cleanup_branches set to a schedule, a built-in branch-cleanup job runs
+'git cleanup --remote --yes', deleting agent branches whose PRs were merged
+or closed.
+
+Examples:
+  gptcode daemon start           # Run jobs on schedule until interrupted
+  gptcode daemon list            # Show jobs, next runs and last results
+  gptcode daemon run deps        # Run one job now
+  gptcode daemon service --install`,
+}
+
+var daemonStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Run jobs on their schedules until interrupted",
+	Long: `Run the project's jobs on their schedules until interrupted.
+
+Jobs run one at a time. A job added to the config first runs at its next
+scheduled time; a run missed while the daemon was stopped is made up once
+when it starts again.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sched, root, err := loadScheduler()
+		if err != nil {
+			return err
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Printf("⏰ Running %d job(s) for %s (Ctrl+C to stop)\n", len(sched.Jobs), root)
+		for _, j := range sched.Jobs {
+			fmt.Printf("   %-20s %-16s next %s\n", j.Name, j.Schedule, sched.NextRun(j).Format("Mon Jan 2 15:04"))
+		}
+		return sched.Run(ctx, printDaemonResult)
+	},
+}
+
+var daemonListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show jobs, their next runs and last results",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sched, _, err := loadScheduler()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-20s  %-16s  %-7s  %-16s  %s\n", "JOB", "SCHEDULE", "OUTPUT", "NEXT RUN", "LAST RESULT")
+		for _, j := range sched.Jobs {
+			output := j.Output
+			if output == "" {
+				output = "report"
+			}
+			last := "-"
+			if r := sched.Last(j.Name); r != nil {
+				last = fmt.Sprintf("%s %s", r.Start.Format("Jan 2 15:04"), r.Status)
+			}
+			fmt.Printf("%-20s  %-16s  %-7s  %-16s  %s\n", j.Name, j.Schedule, output, sched.NextRun(j).Format("Mon Jan 2 15:04"), last)
+		}
+		return nil
+	},
+}
+
+var daemonRunCmd = &cobra.Command{
+	Use:   "run <job>",
+	Short: "Run one job now",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sched, _, err := loadScheduler()
+		if err != nil {
+			return err
+		}
+		j := sched.Job(args[0])
+		if j == nil {
+			return fmt.Errorf("no daemon job %q in %s", args[0], config.ProjectConfigFile)
+		}
+		r := sched.RunJob(cmd.Context(), *j)
+		printDaemonResult(r)
+		if r.Status == daemon.StatusFailed {
+			return fmt.Errorf("job %s failed", r.Job)
+		}
+		return nil
+	},
+}
+
+var daemonServiceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Generate a systemd or launchd service that runs daemon start",
+	Long: `Generate a user service that runs 'gptcode daemon start' for this project.
+Without --install the service is printed; with --install it is written to
+the user service directory, as with 'gptcode context service'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, root, err := loadScheduler()
+		if err != nil {
+			return err
+		}
+		install, _ := cmd.Flags().GetBool("install")
+		format, _ := cmd.Flags().GetString("format")
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate the gptcode binary: %w", err)
+		}
+		return writeUserService(userService{
+			Name:        serviceName("daemon", root),
+			Description: "GPTCode maintenance jobs for " + root,
+			Executable:  exe,
+			Args:        []string{"daemon", "start"},
+			ProjectRoot: root,
+		}, format, install)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+	daemonCmd.AddCommand(daemonStartCmd, daemonListCmd, daemonRunCmd, daemonServiceCmd)
+	daemonServiceCmd.Flags().Bool("install", false, "Write the service to the user service directory")
+	daemonServiceCmd.Flags().String("format", "", "Service format: systemd or launchd (default: for this OS)")
+}
+
+// loadScheduler reads the project's daemon jobs and the state of their
+// previous runs.
+func loadScheduler() (*daemon.Scheduler, string, error) {
+	pc, err := config.LoadProjectConfig(".")
+	if err != nil {
+		return nil, "", err
+	}
+	jobs := pc.Daemon.AllJobs()
+	if len(jobs) == 0 {
+		return nil, "", fmt.Errorf("no daemon jobs configured; add them under daemon.jobs in %s", config.ProjectConfigFile)
+	}
+	root := pc.Root
+	home, _ := os.UserHomeDir()
+	dir := filepath.Join(home, ".gptcode", "daemon", projectSlug(root))
+
+	notifier := daemon.NewNotifier(pc.Daemon.Notify)
+	notify := func(job config.DaemonJob, r daemon.Result) {
+		if err := notifier.Notify(filepath.Base(root), r); err != nil {
+			fmt.Fprintln(os.Stderr, output.Warnf("Failed to notify about %s: %v", r.Job, err))
+		}
+	}
+	sched, err := daemon.NewScheduler(jobs, dir, daemonRunner(root), notify)
+	return sched, root, err
+}
+
+func printDaemonResult(r daemon.Result) {
+	mark := output.OKf
+	if r.Status == daemon.StatusFailed {
+		mark = output.Failf
+	}
+	fmt.Println(mark("%s %s in %s ($%.4f)", r.Job, r.Status, r.Duration, r.Cost))
+	if r.Error != "" {
+		fmt.Printf("   %s\n", strings.SplitN(r.Error, "\n", 2)[0])
+	}
+	if r.PR != "" {
+		fmt.Printf("   PR: %s\n", r.PR)
+	}
+	if r.Report != "" {
+		fmt.Printf("   Report: %s\n", r.Report)
+	}
+}
+
+// daemonRunner runs jobs for the project at root, "pr" jobs in a worktree.
+func daemonRunner(root string) daemon.Runner {
+	return func(ctx context.Context, job config.DaemonJob) daemon.Result {
+		if job.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+			defer cancel()
+		}
+		if job.Output == "pr" {
+			return runDaemonPRJob(ctx, root, job)
+		}
+		return execDaemonJob(ctx, root, job)
+	}
+}
+
+// execDaemonJob runs the job's gptcode command in dir under the job's
+// budget.
+func execDaemonJob(ctx context.Context, dir string, job config.DaemonJob) daemon.Result {
+	r := daemon.Result{Status: daemon.StatusFailed}
+	args, err := daemon.CommandArgs(job.Command)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		r.Error = fmt.Sprintf("failed to locate the gptcode binary: %v", err)
+		return r
+	}
+
+	session := uuid.NewString()
+	c := exec.CommandContext(ctx, exe, args...)
+	c.Dir = dir
+	c.Env = append(os.Environ(), "GPTCODE_SESSION="+session, "GPTCODE_NO_NOTIFY=1")
+	if job.MaxCost > 0 {
+		c.Env = append(c.Env, fmt.Sprintf("GPTCODE_MAX_COST=%g", job.MaxCost))
+	}
+	out, err := c.CombinedOutput()
+	r.Output, r.Cost = string(out), sessionCost(session)
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		r.Error = fmt.Sprintf("timed out after %s", job.Timeout)
+	case err != nil:
+		r.Error = fmt.Sprintf("gptcode %s: %v", job.Command, err)
+	default:
+		r.Status = daemon.StatusOK
+	}
+	return r
+}
+
+// sessionCost is what a gptcode run spent, from the usage log
+func sessionCost(session string) float64 {
+	records, _ := observability.LoadUsage(observability.UsagePath())
+	total := 0.0
+	for _, rec := range records {
+		if rec.Session == session {
+			total += rec.Cost
+		}
+	}
+	return total
+}
+
+// runDaemonPRJob runs the job on a fresh branch in its own worktree and
+// opens a PR when the command changed anything. Each run gets a branch and
+// worktree named after its start time, so the worktrees failed runs keep
+// for inspection never block later ones.
+func runDaemonPRJob(ctx context.Context, root string, job config.DaemonJob) daemon.Result {
+	r := daemon.Result{}
+	fail := func(err error) daemon.Result {
+		r.Status, r.Error = daemon.StatusFailed, err.Error()
+		return r
+	}
+
+	host, err := forge.Detect(root, "")
+	if err != nil {
+		return fail(err)
+	}
+	target, err := forge.ResolvePushTarget(root, "")
+	if err != nil {
+		return fail(err)
+	}
+	base := job.Base
+	if base == "" {
+		base = "main"
+	}
+	slug := projectSlug(job.Name)
+	stamp := time.Now().Format("20060102-150405")
+	branch := fmt.Sprintf("gptcode/%s-%s", slug, stamp)
+	worktree := filepath.Join(os.TempDir(), "gptcode-worktrees",
+		strings.ReplaceAll(host.Repo(), "/", "-")+"-daemon-"+slug+"-"+stamp)
+
+	origin := gitClient(host, root)
+	if err := origin.AddWorktree(worktree, branch, base); err != nil {
+		return fail(err)
+	}
+
+	r = execDaemonJob(ctx, worktree, job)
+	if r.Status == daemon.StatusFailed {
+		r.Error += "\nworktree: " + worktree
+		return r
+	}
+
+	client := gitClient(host, worktree)
+	status := exec.Command("git", "status", "--porcelain")
+	status.Dir = worktree
+	if out, err := status.Output(); err != nil {
+		return fail(err)
+	} else if len(strings.TrimSpace(string(out))) == 0 {
+		r.Status = daemon.StatusNoChanges
+		_ = origin.RemoveWorktree(worktree)
+		return r
+	}
+
+	if err := client.CommitChanges(github.CommitOptions{
+		Message:  fmt.Sprintf("%s: gptcode %s", job.Name, job.Command),
+		AllFiles: true,
+		Type:     "chore",
+	}); err != nil {
+		return fail(err)
+	}
+	if err := client.PushBranchTo(target.Remote, branch); err != nil {
+		return fail(err)
+	}
+	pr, err := host.CreatePR(github.PRCreateOptions{
+		Title:      fmt.Sprintf("chore: %s", job.Name),
+		Body:       daemonPRBody(job, r.Output),
+		HeadBranch: branch,
+		HeadOwner:  target.HeadOwner(),
+		HeadRepo:   target.HeadRepo(),
+		BaseBranch: base,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	r.PR = pr.URL
+	_ = origin.RemoveWorktree(worktree)
+	return r
+}
+
+// daemonPRBody describes a scheduled job's PR, ending with the tail of the
+// command's output.
+func daemonPRBody(job config.DaemonJob, output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) > 40 {
+		lines = lines[len(lines)-40:]
+	}
+	return fmt.Sprintf("Scheduled maintenance job `%s` (%s) ran `gptcode %s`.\n\n<details><summary>Output</summary>\n\n```\n%s\n```\n</details>\n",
+		job.Name, job.Schedule, job.Command, strings.Join(lines, "\n"))
+}
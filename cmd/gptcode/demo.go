@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/demo"
+	"gptcode/internal/output"
+)
+
+var demoCmd = &cobra.Command{
+	Use:   "demo",
+	Short: "Demos and recordings",
+}
+
+var demoFeedbackCmd = &cobra.Command{
+	Use:   "feedback",
+	Short: "Feedback capture demos",
+}
+
+var demoFeedbackCreateCmd = &cobra.Command{
+	Use:     "create",
+	Aliases: []string{"feedback:create", "feedback.create"},
+	Short:   "Generate feedback demos (casts + GIFs)",
+	Long: `Record the feedback demos in docs/assets as asciinema casts and GIFs.
+
+Each demo is a built-in script run against this gptcode binary in a fresh
+home directory, so your own feedback history never shows up in a demo.
+A take whose output is missing something the script expects is retried.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repo, _ := cmd.Flags().GetString("repo")
+		tries, _ := cmd.Flags().GetInt("tries")
+		builtin, _ := cmd.Flags().GetBool("builtin-renderer")
+		outDir := filepath.Join(repo, "docs", "assets")
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			return err
+		}
+
+		for _, name := range demo.BuiltinNames() {
+			if !strings.HasPrefix(name, "feedback") {
+				continue
+			}
+			script, err := demo.Builtin(name)
+			if err != nil {
+				return err
+			}
+			if err := recordDemo(cmd.Context(), script, filepath.Join(outDir, name), tries, true, true, builtin); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		fmt.Println(output.OKf("Demos built in %s", outDir))
+		return nil
+	},
+}
+
+var demoRecordCmd = &cobra.Command{
+	Use:   "record <script.yml|name>",
+	Short: "Record a scripted terminal session as a cast and GIF",
+	Long: `Run a demo script in a pseudo terminal and save the session as an
+asciinema cast, and as a GIF with --gif.
+
+A script starts a shell and drives it step by step:
+
+  title: Chat with gptcode
+  cols: 100
+  rows: 30
+  steps:
+    - type: gptcode chat          # types the text and presses Enter
+    - expect: "> "                # waits for output (timeout: 30s by default)
+    - type: explain main.go
+    - expect: package main
+      timeout: 2m
+    - sleep: 2s                   # lets the viewer read
+    - key: ctrl-d                 # enter, tab, esc, arrows, backspace, ctrl-<letter>
+
+The gptcode running this command comes first on the PATH, so scripts
+exercise the same build. The built-in scripts feedback-demo,
+feedback-hook-demo and feedback-story are recorded by name.
+
+GIFs are rendered with agg when it is installed, and with a built-in
+renderer otherwise (or with --builtin-renderer).
+
+Examples:
+  gptcode demo record chat.yml --gif
+  gptcode demo record chat.yml --out docs/assets/chat --tries 3 --gif`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+		tries, _ := cmd.Flags().GetInt("tries")
+		gif, _ := cmd.Flags().GetBool("gif")
+		builtin, _ := cmd.Flags().GetBool("builtin-renderer")
+		freshHome, _ := cmd.Flags().GetBool("fresh-home")
+
+		script, err := demo.LoadScript(args[0])
+		if os.IsNotExist(err) {
+			script, err = demo.Builtin(args[0])
+		}
+		if err != nil {
+			return err
+		}
+		if out == "" {
+			out = strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+		}
+		return recordDemo(cmd.Context(), script, out, tries, gif, freshHome, builtin)
+	},
+}
+
+var demoGIFCmd = &cobra.Command{
+	Use:   "gif <cast> [gif]",
+	Short: "Render an asciinema cast as a GIF",
+	Args:  cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		builtin, _ := cmd.Flags().GetBool("builtin-renderer")
+		cast, err := demo.LoadCast(args[0])
+		if err != nil {
+			return err
+		}
+		out := strings.TrimSuffix(args[0], filepath.Ext(args[0])) + ".gif"
+		if len(args) == 2 {
+			out = args[1]
+		}
+		if err := demo.SaveGIF(cast, out, builtin); err != nil {
+			return err
+		}
+		fmt.Println(output.OKf("Wrote %s", out))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(demoCmd)
+	demoCmd.AddCommand(demoFeedbackCmd)
+	demoCmd.AddCommand(demoRecordCmd)
+	demoCmd.AddCommand(demoGIFCmd)
+	demoFeedbackCmd.AddCommand(demoFeedbackCreateCmd)
+
+	demoFeedbackCreateCmd.Flags().String("repo", ".", "Repository root; demos are written to docs/assets")
+	demoFeedbackCreateCmd.Flags().Int("tries", 3, "Max attempts to capture good demos")
+	demoFeedbackCreateCmd.Flags().Bool("builtin-renderer", false, "Render GIFs without agg")
+
+	demoRecordCmd.Flags().String("out", "", "Output path without extension (default: the script name)")
+	demoRecordCmd.Flags().Int("tries", 1, "Max attempts to capture a take with all expected output")
+	demoRecordCmd.Flags().Bool("gif", false, "Also render a GIF")
+	demoRecordCmd.Flags().Bool("builtin-renderer", false, "Render the GIF without agg")
+	demoRecordCmd.Flags().Bool("fresh-home", false, "Run the script with an empty temporary HOME")
+
+	demoGIFCmd.Flags().Bool("builtin-renderer", false, "Render without agg")
+}
+
+// recordDemo records script into out.cast, and out.gif when gif is set.
+// Scripts run with this binary first on the PATH and, with freshHome, in
+// an empty temporary home directory.
+func recordDemo(ctx context.Context, script *demo.Script, out string, tries int, gif, freshHome, builtin bool) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	env := map[string]string{"GPTCODE_NO_NOTIFY": "1"}
+	if freshHome {
+		home, err := os.MkdirTemp("", "gptcode-demo-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(home)
+		env["HOME"] = home
+	}
+	if exe, err := os.Executable(); err == nil {
+		env["PATH"] = filepath.Dir(exe) + string(os.PathListSeparator) + os.Getenv("PATH")
+	}
+	// The script's own settings win
+	for k, v := range script.Env {
+		env[k] = v
+	}
+	script.Env = env
+
+	fmt.Println(output.Infof("Recording %s", out+".cast"))
+	cast, err := demo.RecordTakes(ctx, script, tries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(out+".cast"), 0o755); err != nil {
+		return err
+	}
+	if err := cast.Save(out + ".cast"); err != nil {
+		return err
+	}
+	fmt.Println(output.OKf("Wrote %s", out+".cast"))
+	if gif {
+		if err := demo.SaveGIF(cast, out+".gif", builtin); err != nil {
+			return err
+		}
+		fmt.Println(output.OKf("Wrote %s", out+".gif"))
+	}
+	return nil
+}
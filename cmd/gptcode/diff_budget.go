@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/config"
+	"gptcode/internal/output"
+	"gptcode/internal/tools"
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{doCmd, issueFixCmd} {
+		cmd.Flags().Int("max-diff-lines", 0, "Stop when the task has changed this many lines (defaults to defaults.max_diff_lines)")
+		cmd.Flags().Int("max-diff-files", 0, "Stop when the task has changed this many files (defaults to defaults.max_diff_files)")
+	}
+}
+
+// startDiffBudget installs the diff budget for commands with --max-diff-lines
+// or --max-diff-files. Once a write goes over it the task stops and reports,
+// asking first whether to raise the budget when on a terminal.
+func startDiffBudget(cmd *cobra.Command) {
+	if cmd.Flags().Lookup("max-diff-lines") == nil {
+		return
+	}
+	setup, _ := config.LoadEffectiveSetup()
+	lines, files := setup.Defaults.MaxDiffLines, setup.Defaults.MaxDiffFiles
+	if cmd.Flags().Changed("max-diff-lines") {
+		lines, _ = cmd.Flags().GetInt("max-diff-lines")
+	}
+	if cmd.Flags().Changed("max-diff-files") {
+		files, _ = cmd.Flags().GetInt("max-diff-files")
+	}
+	if lines <= 0 && files <= 0 {
+		tools.SetDiffBudget(nil)
+		return
+	}
+
+	budget := tools.NewDiffBudget(lines, files)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		budget.OnExceeded = confirmOverDiffBudget
+	}
+	tools.SetDiffBudget(budget)
+}
+
+func confirmOverDiffBudget(usage tools.DiffUsage, maxLines, maxFiles int) bool {
+	budget := []string{}
+	if maxLines > 0 {
+		budget = append(budget, fmt.Sprintf("%d lines", maxLines))
+	}
+	if maxFiles > 0 {
+		budget = append(budget, fmt.Sprintf("%d files", maxFiles))
+	}
+	defer output.LockTerminal()()
+	fmt.Fprintf(os.Stderr, "\n%s Raise the budget and continue? [y/N] ",
+		output.Warnf("This task has changed %d lines in %d files, over its budget of %s.", usage.Lines, usage.Files, strings.Join(budget, " and ")))
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
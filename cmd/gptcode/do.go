@@ -1,18 +1,28 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 
+	"gptcode/internal/agents"
 	"gptcode/internal/config"
 	"gptcode/internal/intelligence"
 	"gptcode/internal/llm"
+	"gptcode/internal/memory"
 	"gptcode/internal/modes"
+	"gptcode/internal/observability"
+	"gptcode/internal/output"
+	"gptcode/internal/tools"
 )
 
 var doCmd = &cobra.Command{
@@ -23,7 +33,11 @@ var doCmd = &cobra.Command{
 Examples:
   gptcode do "add error handling to main.go"
   gptcode do "read docs/README.md and create a getting-started guide"
-  gptcode do "unify all feature files in /guides"`,
+  gptcode do "unify all feature files in /guides"
+  gptcode do "fix the login redirect" --max-diff-lines 80 --max-diff-files 3
+  gptcode do "migrate the config loader to viper" --sandbox
+  gptcode do "bump the API client to v2" --plan-only-patch=api-v2.patch
+  gptcode do "split the payment service into its own package" --discuss`,
 	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		task := strings.Join(args, " ")
@@ -33,6 +47,13 @@ Examples:
 		maxAttempts, _ := cmd.Flags().GetInt("max-attempts")
 		supervised, _ := cmd.Flags().GetBool("supervised")
 		interactive, _ := cmd.Flags().GetBool("interactive")
+		discuss, _ := cmd.Flags().GetBool("discuss")
+		doTimeout, _ = cmd.Flags().GetDuration("timeout")
+		if !cmd.Flags().Changed("max-attempts") {
+			if setup, _ := config.LoadEffectiveSetup(); setup.Defaults.MaxAttempts > 0 {
+				maxAttempts = setup.Defaults.MaxAttempts
+			}
+		}
 
 		if verbose {
 			fmt.Fprintf(os.Stderr, "Task: %s\n", task)
@@ -44,7 +65,22 @@ Examples:
 			return runDoAnalysis(task, verbose)
 		}
 
-		return runDoExecutionWithRetry(task, verbose, maxAttempts, supervised, interactive)
+		var plan string
+		if discuss {
+			var err error
+			plan, err = discussDoPlan(task)
+			if errors.Is(err, modes.ErrPlanRejected) {
+				fmt.Fprintln(os.Stderr, "Plan discussion cancelled; nothing was changed")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return runSandboxed(cmd, func() error {
+			return runDoExecutionWithRetry(task, verbose, maxAttempts, supervised, interactive, plan)
+		})
 	},
 }
 
@@ -53,13 +89,91 @@ func init() {
 
 	doCmd.Flags().Bool("dry-run", false, "Show analysis and plan without executing")
 	doCmd.Flags().BoolP("verbose", "v", false, "Show detailed progress")
-	doCmd.Flags().Int("max-attempts", 3, "Maximum retry attempts with different models")
+	doCmd.Flags().Int("max-attempts", 3, "Maximum retry attempts with different models (default: defaults.max_attempts, or 3)")
 	doCmd.Flags().Bool("supervised", false, "Require manual approval before implementation")
 	doCmd.Flags().BoolP("interactive", "i", false, "Prompt for model selection when multiple options are similar")
+	doCmd.Flags().Bool("discuss", false, "Review and revise the plan with the planner before execution starts")
+	doCmd.Flags().Duration("timeout", 0, "Stop the task after this long, e.g. 30m (defaults to defaults.task_timeout, or no limit)")
+}
+
+// errInterrupted is the cause of a task stopped with Ctrl+C
+var errInterrupted = errors.New("interrupted")
+
+// doTimeout is --timeout, which overrides defaults.task_timeout
+var doTimeout time.Duration
+
+// taskContext returns the context of an autonomous task: Ctrl+C cancels it,
+// letting the agents stop their requests and save the trace and checkpoint,
+// and the task timeout ends it. A second Ctrl+C quits at once.
+func taskContext(setup *config.Setup) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupts; ok {
+			signal.Stop(interrupts)
+			fmt.Fprintln(os.Stderr, "\n"+output.Warnf("Interrupted; stopping the task (Ctrl+C again to quit now)"))
+			cancel(errInterrupted)
+		}
+	}()
+
+	timeout := doTimeout
+	if timeout == 0 {
+		timeout = time.Duration(setup.Defaults.TaskTimeout) * time.Second
+	}
+	stopTimer := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, stopTimer = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("the task took longer than %s", timeout))
+	}
+	return ctx, func() {
+		signal.Stop(interrupts)
+		close(interrupts)
+		stopTimer()
+		cancel(nil)
+	}
+}
+
+// discussDoPlan agrees on a plan for task with the user: the planner drafts
+// one and revises it with each round of comments until it is accepted.
+func discussDoPlan(task string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("--discuss needs an interactive terminal")
+	}
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return "", fmt.Errorf("failed to load setup: %w", err)
+	}
+
+	backendName := setup.Defaults.Backend
+	backendCfg := setup.Backend[backendName]
+	provider := llm.NewForBackend(backendName, backendCfg)
+	queryModel := backendCfg.GetModelForAgentWithProfile("query", setup.Defaults.Profile)
+	if queryModel == "" {
+		queryModel = backendCfg.DefaultModel
+	}
+	researchModel := backendCfg.GetModelForAgentWithProfile("research", setup.Defaults.Profile)
+	if researchModel == "" {
+		researchModel = backendCfg.DefaultModel
+	}
+	orchestrator := llm.NewOrchestrator(backendCfg.BaseURL, backendName, provider, researchModel)
+
+	cwd, _ := os.Getwd()
+	guided := modes.NewGuidedMode(orchestrator, cwd, queryModel)
+	reader := bufio.NewReader(os.Stdin)
+	return guided.DiscussPlan(context.Background(), task, func(plan string) (string, error) {
+		fmt.Fprintf(os.Stderr, "\n%s\n\n", strings.TrimSpace(plan))
+		fmt.Fprint(os.Stderr, "Comments on the plan (Enter to accept, q to cancel): ")
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if (err != nil && line == "") || line == "q" || line == "quit" {
+			return "", modes.ErrPlanRejected
+		}
+		return line, nil
+	})
 }
 
 func runDoAnalysis(task string, verbose bool) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load setup: %w", err)
 	}
@@ -67,12 +181,7 @@ func runDoAnalysis(task string, verbose bool) error {
 	backendName := setup.Defaults.Backend
 	backendCfg := setup.Backend[backendName]
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	queryModel := backendCfg.GetModelForAgent("query")
 
@@ -107,8 +216,11 @@ Provide a brief analysis.`, task)
 	return nil
 }
 
-func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervised bool, interactive bool) error {
-	setup, err := config.LoadSetup()
+// runDoExecutionWithRetry runs task, retrying with other models on tool and
+// API errors. A non-empty plan, agreed with --discuss, is implemented as is,
+// by the autonomous executor unless supervised.
+func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervised bool, interactive bool, plan string) error {
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load setup: %w", err)
 	}
@@ -153,6 +265,25 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 
 	currentBackend := editorBackend
 	currentEditorModel := editorModel
+	cwd, _ := os.Getwd()
+	project := map[string]interface{}{"project": intelligence.ProjectKey(cwd)}
+
+	// Supervised and interactive runs can answer the agent's questions;
+	// autonomous runs stop on questions too ambiguous to guess at.
+	tools.SetAmbiguityThreshold(setup.Defaults.AmbiguityThreshold)
+	if supervised || interactive {
+		tools.SetUserPrompter(tools.TerminalPrompter(os.Stdin, os.Stderr))
+		defer tools.SetUserPrompter(nil)
+	}
+	// Supervised runs also ask before each command and write outside the
+	// project the project policy does not allow
+	if supervised {
+		tools.SetPermissions(projectPermissions(cwd))
+		defer tools.SetPermissions(nil)
+	}
+
+	ctx, stop := taskContext(setup)
+	defer stop()
 
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		if attempt > 1 && verbose {
@@ -160,9 +291,17 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 		}
 
 		startTime := time.Now()
-		err := runDoExecution(task, verbose, supervised, setup, currentBackend, currentEditorModel)
+		err := runDoExecution(ctx, task, verbose, supervised, plan, setup, currentBackend, currentEditorModel)
 		elapsed := time.Since(startTime).Milliseconds()
 
+		var clarification *tools.ClarificationError
+		if errors.As(err, &clarification) || errors.Is(err, observability.ErrBudgetExceeded) || tools.IsDiffBudgetError(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
 		if err == nil {
 			_ = intelligence.RecordExecution(intelligence.TaskExecution{
 				Task:      task,
@@ -170,20 +309,25 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 				Model:     currentEditorModel,
 				Success:   true,
 				LatencyMs: elapsed,
+				Features:  project,
 			})
 
 			if verbose {
-				fmt.Fprintf(os.Stderr, "\n[OK] Task completed successfully\n")
+				fmt.Fprintln(os.Stderr, "\n"+output.OKf("Task completed successfully"))
+			}
+			if setup.Defaults.LearnMemory {
+				rememberTask(setup, currentBackend, cwd, task, verbose)
 			}
 			return nil
 		}
 
 		_ = intelligence.RecordExecution(intelligence.TaskExecution{
-			Task:    task,
-			Backend: currentBackend,
-			Model:   currentEditorModel,
-			Success: false,
-			Error:   err.Error(),
+			Task:     task,
+			Backend:  currentBackend,
+			Model:    currentEditorModel,
+			Success:  false,
+			Error:    err.Error(),
+			Features: project,
 		})
 
 		errMsg := err.Error()
@@ -193,7 +337,8 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 		looksLikeAPIError := strings.Contains(errMsg, "API error") || strings.Contains(errMsg, "Provider returned error") ||
 			strings.Contains(errMsg, "rate limit") || strings.Contains(errMsg, "timeout") ||
 			strings.Contains(errMsg, "connection") || strings.Contains(errMsg, "429") ||
-			strings.Contains(errMsg, "empty movements array") || strings.Contains(errMsg, "decomposition failed")
+			strings.Contains(errMsg, "empty movements array") || strings.Contains(errMsg, "decomposition failed") ||
+			errors.Is(err, agents.ErrAgentTimeout)
 
 		if !looksLikeToolError && !looksLikeAPIError {
 			return fmt.Errorf("task failed: %w", err)
@@ -303,17 +448,12 @@ func runDoExecutionWithRetry(task string, verbose bool, maxAttempts int, supervi
 	return fmt.Errorf("task failed after %d attempts", maxAttempts)
 }
 
-func runDoExecution(task string, verbose bool, supervised bool, setup *config.Setup, backendName string, editorModel string) error {
+func runDoExecution(ctx context.Context, task string, verbose bool, supervised bool, plan string, setup *config.Setup, backendName string, editorModel string) error {
 	backendCfg := setup.Backend[backendName]
 
 	cwd, _ := os.Getwd()
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	// Use same backend for all agents (query, research, editor) for consistency
 	// This ensures retry switches all models together
@@ -350,20 +490,27 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 		}
 		// Use queryProvider for analyzer/classifier with selected backend
 		executor := modes.NewAutonomousExecutorWithBackend(queryProvider, cwd, queryModel, language, backendName)
-		return executor.Execute(context.Background(), task)
+		if plan != "" {
+			return executor.ExecutePlan(ctx, task, plan)
+		}
+		return executor.Execute(ctx, task)
 	}
 
 	// Supervised mode: use guided workflow
 	if supervised {
-		guided := modes.NewGuidedMode(orchestrator, cwd, queryModel)
+		planContent := plan
+		if planContent == "" {
+			guided := modes.NewGuidedMode(orchestrator, cwd, queryModel)
 
-		if verbose {
-			fmt.Fprintf(os.Stderr, "Creating plan...\n")
-		}
+			if verbose {
+				fmt.Fprintf(os.Stderr, "Creating plan...\n")
+			}
 
-		planContent, err := guided.ExecuteAndReturnPlan(context.Background(), task)
-		if err != nil {
-			return fmt.Errorf("plan creation failed: %w", err)
+			var err error
+			planContent, err = guided.ExecuteAndReturnPlan(ctx, task)
+			if err != nil {
+				return fmt.Errorf("plan creation failed: %w", err)
+			}
 		}
 
 		if verbose {
@@ -372,7 +519,7 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 
 		guidedWithCustomEditor := modes.NewGuidedModeWithCustomModel(orchestrator, provider, cwd, queryModel, editorModel)
 
-		if err := guidedWithCustomEditor.Implement(context.Background(), planContent); err != nil {
+		if err := guidedWithCustomEditor.Implement(ctx, planContent); err != nil {
 			return fmt.Errorf("implementation failed: %w", err)
 		}
 	} else {
@@ -382,10 +529,34 @@ func runDoExecution(task string, verbose bool, supervised bool, setup *config.Se
 			fmt.Fprintf(os.Stderr, "Using orchestrated mode with decomposed agents...\n")
 		}
 
-		if err := orchestrated.Execute(context.Background(), task); err != nil {
+		if err := orchestrated.Execute(ctx, task); err != nil {
 			return fmt.Errorf("orchestrated execution failed: %w", err)
 		}
 	}
 
 	return nil
 }
+
+// rememberTask adds what a completed task taught about the project, read
+// from the task and the change it made, to the project's memory
+func rememberTask(setup *config.Setup, backendName, dir, task string, verbose bool) {
+	diff, _ := exec.Command("git", "-C", dir, "diff", "HEAD").Output()
+	transcript := "Task:\n" + task
+	if len(diff) > 0 {
+		transcript += "\n\nChange made:\n" + string(diff)
+	}
+
+	provider, model := llm.NewQueryProvider(setup, backendName)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	facts, err := memory.Summarize(ctx, provider, model, memory.NewProjectStore(dir), "do", transcript)
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "Failed to update project memory: %v\n", err)
+		}
+		return
+	}
+	if len(facts) > 0 && verbose {
+		fmt.Fprintf(os.Stderr, "Remembered %d new facts about this project\n", len(facts))
+	}
+}
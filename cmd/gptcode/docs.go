@@ -8,9 +8,11 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"gptcode/internal/apigen"
 	"gptcode/internal/config"
 	"gptcode/internal/docs"
 	"gptcode/internal/llm"
+	"gptcode/internal/output"
 )
 
 var docsCmd = &cobra.Command{
@@ -35,21 +37,42 @@ var docsAPICmd = &cobra.Command{
 	Short: "Generate API documentation from code",
 	Long: `Discover API endpoints and generate comprehensive documentation.
 
+Routes are extracted statically from the code: net/http ServeMux patterns,
+gin, echo, chi, fiber and gorilla/mux in Go, Express in JavaScript and
+TypeScript, FastAPI and Flask in Python, Phoenix and Rails. Their request
+and response schemas are inferred from the handlers by the model.
+
 Supported formats:
-  markdown (default) - Markdown documentation
-  openapi           - OpenAPI 3.0 YAML spec
-  postman           - Postman Collection JSON
+  markdown (default) - Markdown documentation (API.md)
+  openapi           - OpenAPI 3.1 spec plus a Postman collection
+  postman           - Postman Collection v2.1 JSON only
+
+The spec is written to the existing openapi.yaml, openapi.json or
+api-spec.yaml, else to api-spec.yaml (--output to choose). Operations
+already in it are kept as they are, edits included, and only new routes
+are inferred; --refresh infers them all again. Before writing, the
+operations added, removed and changed against the existing spec (or the
+one given with --against) are listed. --check only lists them and fails
+when there are any, e.g. to catch an outdated spec in CI.
 
 Examples:
-  gptcode docs api              # Generate API.md
-  gptcode docs api openapi      # Generate api-spec.yaml
-  gptcode docs api postman      # Generate api-collection.json`,
+  gptcode docs api                        # Generate API.md
+  gptcode docs api openapi                # Generate api-spec.yaml and api-collection.json
+  gptcode docs api postman                # Generate api-collection.json
+  gptcode docs api openapi --check        # Fail when the spec misses routes
+  gptcode docs api openapi --against main-api.yaml`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runDocsAPI,
 }
 
 var docsApply bool
 var docsModel string
+var (
+	docsAPIOutput  string
+	docsAPIAgainst string
+	docsAPIRefresh bool
+	docsAPICheck   bool
+)
 
 func init() {
 	rootCmd.AddCommand(docsCmd)
@@ -58,10 +81,14 @@ func init() {
 
 	docsUpdateCmd.Flags().BoolVar(&docsApply, "apply", false, "Apply changes automatically")
 	docsCmd.PersistentFlags().StringVar(&docsModel, "model", "", "LLM model to use (default: from config)")
+	docsAPICmd.Flags().StringVarP(&docsAPIOutput, "output", "o", "", "OpenAPI spec to write (default: the existing spec, else api-spec.yaml)")
+	docsAPICmd.Flags().StringVar(&docsAPIAgainst, "against", "", "Spec to diff against and keep operations from (default: the output spec)")
+	docsAPICmd.Flags().BoolVar(&docsAPIRefresh, "refresh", false, "Infer every operation again instead of keeping the existing spec's")
+	docsAPICmd.Flags().BoolVar(&docsAPICheck, "check", false, "Only diff against the existing spec; fail when they differ")
 }
 
 func runDocsUpdate(cmd *cobra.Command, args []string) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -81,7 +108,7 @@ func runDocsUpdate(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 
-	fmt.Println("📚 Analyzing recent changes...")
+	fmt.Println(output.Infof("Analyzing recent changes..."))
 
 	result, err := updater.UpdateReadme(ctx)
 	if err != nil {
@@ -89,11 +116,11 @@ func runDocsUpdate(cmd *cobra.Command, args []string) error {
 	}
 
 	if !result.Updated {
-		fmt.Println("✅ README is up to date")
+		fmt.Println(output.OKf("README is up to date"))
 		return nil
 	}
 
-	fmt.Printf("\n📝 Detected %d change(s):\n", len(result.Changes))
+	fmt.Println("\n" + output.Infof("Detected %d change(s):", len(result.Changes)))
 	for _, change := range result.Changes {
 		fmt.Printf("  - %s\n", change)
 	}
@@ -103,9 +130,9 @@ func runDocsUpdate(cmd *cobra.Command, args []string) error {
 		if err := updater.ApplyUpdate(readmePath, result.NewText); err != nil {
 			return fmt.Errorf("failed to apply update: %w", err)
 		}
-		fmt.Println("\n✅ README updated successfully")
+		fmt.Println("\n" + output.OKf("README updated successfully"))
 	} else {
-		fmt.Println("\n📋 Preview changes:")
+		fmt.Println("\n" + output.Infof("Preview changes:"))
 		fmt.Println("Run with --apply to update README.md")
 
 		previewPath := filepath.Join(workDir, "README.new.md")
@@ -131,12 +158,7 @@ func getDocsProvider(setup *config.Setup) (llm.Provider, string, error) {
 		return nil, "", fmt.Errorf("backend %s not configured", backendName)
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	if model == "" {
 		model = backendCfg.GetModelForAgent("query")
@@ -157,8 +179,11 @@ func runDocsAPI(cmd *cobra.Command, args []string) error {
 	if len(args) > 0 {
 		format = args[0]
 	}
+	if format != "markdown" && format != "openapi" && format != "postman" {
+		return fmt.Errorf("unknown format %q (markdown, openapi or postman)", format)
+	}
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -173,20 +198,24 @@ func runDocsAPI(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to get working directory: %w", err)
 	}
 
-	generator := docs.NewAPIDocGenerator(provider, model, workDir)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	fmt.Printf("📚 Discovering API endpoints...\n")
+	if format != "markdown" {
+		return runDocsOpenAPI(ctx, provider, model, workDir, format)
+	}
+
+	generator := docs.NewAPIDocGenerator(provider, model, workDir)
+
+	fmt.Println(output.Infof("Discovering API endpoints..."))
 
-	filename, err := generator.Generate(ctx, format)
+	filename, err := generator.Generate(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to generate API docs: %w", err)
 	}
 
-	fmt.Printf("✅ Generated: %s\n", filename)
-	fmt.Println("\n📚 Documentation includes:")
+	fmt.Println(output.OKf("Generated: %s", filename))
+	fmt.Println("\n" + output.Infof("Documentation includes:"))
 	fmt.Println("  - Endpoint descriptions")
 	fmt.Println("  - Request/response examples")
 	fmt.Println("  - Authentication info")
@@ -194,3 +223,100 @@ func runDocsAPI(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// defaultSpecFiles are where a project's OpenAPI spec usually is
+var defaultSpecFiles = []string{"openapi.yaml", "openapi.yml", "openapi.json", "api-spec.yaml", "api-spec.json"}
+
+func runDocsOpenAPI(ctx context.Context, provider llm.Provider, model, workDir, format string) error {
+	specPath := docsAPIOutput
+	if specPath == "" {
+		specPath = filepath.Join(workDir, "api-spec.yaml")
+		for _, name := range defaultSpecFiles {
+			if _, err := os.Stat(filepath.Join(workDir, name)); err == nil {
+				specPath = filepath.Join(workDir, name)
+				break
+			}
+		}
+	}
+	againstPath := docsAPIAgainst
+	if againstPath == "" {
+		againstPath = specPath
+	}
+	var previous *apigen.Spec
+	if _, err := os.Stat(againstPath); err == nil {
+		previous, err = apigen.ParseSpec(againstPath)
+		if err != nil {
+			if docsAPIAgainst != "" {
+				return fmt.Errorf("failed to read %s: %w", againstPath, err)
+			}
+			fmt.Println(output.Warnf("Ignoring %s: %v", againstPath, err))
+		}
+	} else if docsAPIAgainst != "" {
+		return fmt.Errorf("failed to read %s: %w", againstPath, err)
+	}
+
+	fmt.Println(output.Infof("Discovering API endpoints..."))
+	routes, err := docs.DiscoverRoutes(workDir)
+	if err != nil {
+		return fmt.Errorf("failed to discover endpoints: %w", err)
+	}
+	if len(routes) == 0 {
+		return fmt.Errorf("no API endpoints found")
+	}
+	fmt.Printf("   %d route(s) found\n", len(routes))
+
+	generator := docs.NewOpenAPIGenerator(provider, model, workDir)
+	result, err := generator.Generate(ctx, routes, previous, docsAPIRefresh)
+	if err != nil {
+		return fmt.Errorf("failed to generate the OpenAPI spec: %w", err)
+	}
+	fmt.Printf("   %d operation(s) inferred, %d kept from the existing spec, %d without schemas\n",
+		result.Inferred, result.Kept, result.Bare)
+
+	spec, err := result.Doc.Marshal(specPath)
+	if err != nil {
+		return fmt.Errorf("failed to encode the spec: %w", err)
+	}
+
+	if previous != nil {
+		current, err := apigen.ParseSpecData(spec)
+		if err != nil {
+			return fmt.Errorf("generated an invalid spec: %w", err)
+		}
+		diff := docs.DiffSpecs(previous, current)
+		if diff.Empty() {
+			fmt.Println("\n" + output.OKf("No changes against %s", againstPath))
+		} else {
+			fmt.Print("\n" + output.Infof("Changes against %s:\n%s", againstPath, diff))
+		}
+		if docsAPICheck {
+			if !diff.Empty() {
+				return fmt.Errorf("%s is out of date", againstPath)
+			}
+			return nil
+		}
+	} else if docsAPICheck {
+		return fmt.Errorf("no spec to check at %s", againstPath)
+	}
+
+	if format == "openapi" {
+		if err := os.WriteFile(specPath, spec, 0644); err != nil {
+			return fmt.Errorf("failed to write the spec: %w", err)
+		}
+		fmt.Println("\n" + output.OKf("Generated: %s", specPath))
+	}
+
+	collection, err := docs.PostmanCollection(result.Doc)
+	if err != nil {
+		return fmt.Errorf("failed to build the Postman collection: %w", err)
+	}
+	collectionPath := filepath.Join(filepath.Dir(specPath), "api-collection.json")
+	if err := os.WriteFile(collectionPath, append(collection, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write the Postman collection: %w", err)
+	}
+	if format == "postman" {
+		fmt.Println()
+	}
+	fmt.Println(output.OKf("Generated: %s", collectionPath))
+	return nil
+}
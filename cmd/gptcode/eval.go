@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/feedback"
+	"gptcode/internal/prompt"
+)
+
+var evalCmd = &cobra.Command{
+	Use:   "eval",
+	Short: "Evaluate experiments from recorded feedback",
+}
+
+var evalPromptsCmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "Compare success rates of prompt variants",
+	Long: `Compare task success rates of the prompt variants defined under
+prompt_experiments in ~/.gptcode/setup.yaml.
+
+Every task records which variant each agent was assigned. Variants are
+assigned randomly per session (weighted), by the experiment's assign
+setting, or pinned with GPTCODE_PROMPT_VARIANTS.
+
+Example setup.yaml:
+  prompt_experiments:
+    editor:
+      variants:
+        concise:
+          append: "Keep changes minimal; do not refactor unrelated code."
+          weight: 1
+        strict:
+          file: prompts/editor-strict.md
+
+Examples:
+  gptcode eval prompts
+  gptcode eval prompts --agent editor
+  GPTCODE_PROMPT_VARIANTS=editor=concise gptcode do "..."`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		agent, _ := cmd.Flags().GetString("agent")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		events, err := feedback.LoadAll()
+		if err != nil {
+			return fmt.Errorf("failed to load feedback: %w", err)
+		}
+
+		var stats []prompt.VariantStats
+		for _, s := range prompt.CompareVariants(events) {
+			if agent == "" || s.Agent == agent {
+				stats = append(stats, s)
+			}
+		}
+
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(stats)
+		}
+
+		if len(stats) == 0 {
+			fmt.Println("No prompt experiment results recorded yet")
+			fmt.Println("Define variants under prompt_experiments in ~/.gptcode/setup.yaml and run some tasks.")
+			return nil
+		}
+
+		current := ""
+		for _, s := range stats {
+			if s.Agent != current {
+				if current != "" {
+					fmt.Println()
+				}
+				current = s.Agent
+				fmt.Printf("%s\n", s.Agent)
+				fmt.Printf("  %-20s %8s %9s %8s\n", "VARIANT", "SESSIONS", "SUCCESSES", "RATE")
+				fmt.Printf("  %s\n", strings.Repeat("-", 48))
+			}
+			fmt.Printf("  %-20s %8d %9d %7.1f%%\n", s.Variant, s.Sessions, s.Successes, s.Rate*100)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(evalCmd)
+	evalCmd.AddCommand(evalPromptsCmd)
+
+	evalPromptsCmd.Flags().String("agent", "", "Only show variants for this agent")
+	evalPromptsCmd.Flags().Bool("json", false, "Output results as JSON")
+}
@@ -43,7 +43,7 @@ func init() {
 func runEvolveGenerate(cmd *cobra.Command, args []string) error {
 	description := args[0]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -95,12 +95,7 @@ func getEvolveProvider(setup *config.Setup) (llm.Provider, string, error) {
 		return nil, "", fmt.Errorf("backend %s not configured", backendName)
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	if model == "" {
 		model = backendCfg.GetModelForAgent("editor")
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"gptcode/internal/output"
+)
+
+// feedbackHooks are the feedback capture hooks, one text/template per
+// shell, rendered with hookData
+//
+//go:embed hooks/*
+var feedbackHooks embed.FS
+
+// hookData are the fields of the hook templates
+type hookData struct {
+	// WithDiff also captures the git diff with each correction
+	WithDiff bool
+}
+
+// hookShell is a shell feedback hook install supports: where its hook goes
+// and how the shell loads it
+type hookShell struct {
+	// template is the hook's file in hooks/
+	template string
+	// path is where the hook is written, relative to the home directory
+	path string
+	// rc is the startup file that loads the hook with line, or nil when
+	// the shell loads path by itself
+	rc   func(home string) string
+	line string
+	// reload is what --and-source runs
+	reload []string
+	hint   string
+}
+
+var hookShells = map[string]hookShell{
+	"zsh": {
+		template: "feedback.zsh",
+		path:     filepath.Join(".gptcode", "feedback_hook.zsh"),
+		rc:       homeFile(".zshrc"),
+		line:     "source $HOME/.gptcode/feedback_hook.zsh",
+		reload:   []string{"zsh", "-ic", "source ~/.zshrc"},
+		hint:     "Restart your shell or run: source ~/.zshrc",
+	},
+	"bash": {
+		template: "feedback.bash",
+		path:     filepath.Join(".gptcode", "feedback_hook.bash"),
+		rc:       homeFile(".bashrc"),
+		line:     `. "$HOME/.gptcode/feedback_hook.bash"`,
+		reload:   []string{"bash", "-ic", "source ~/.bashrc"},
+		hint:     "Restart your shell or run: source ~/.bashrc",
+	},
+	"fish": {
+		template: "feedback.fish",
+		path:     filepath.Join(".config", "fish", "conf.d", "chu_feedback.fish"),
+		reload:   []string{"fish", "-ic", "source ~/.config/fish/conf.d/chu_feedback.fish"},
+		hint:     "Restart fish or open a new session",
+	},
+	"powershell": {
+		template: "feedback.ps1",
+		path:     filepath.Join(".gptcode", "feedback_hook.ps1"),
+		rc:       powershellProfile,
+		line:     `. "$HOME/.gptcode/feedback_hook.ps1"`,
+		reload:   []string{"pwsh", "-Command", ". $PROFILE"},
+		hint:     "Restart PowerShell or run: . $PROFILE",
+	},
+	"nu": {
+		template: "feedback.nu",
+		path:     filepath.Join(".gptcode", "feedback_hook.nu"),
+		rc:       nushellConfig,
+		line:     "source ~/.gptcode/feedback_hook.nu",
+		hint:     "Restart nu to load it",
+	},
+}
+
+// hookShellAliases are other names accepted by --shell
+var hookShellAliases = map[string]string{"pwsh": "powershell", "nushell": "nu"}
+
+// defaultHookShell is the shell feedback hook install targets without
+// --shell on goos: PowerShell on Windows, zsh elsewhere
+func defaultHookShell(goos string) string {
+	if goos == "windows" {
+		return "powershell"
+	}
+	return "zsh"
+}
+
+// installFeedbackHook writes shell's hook and makes the shell load it
+func installFeedbackHook(shell string, withDiff, andSource bool) error {
+	if name, ok := hookShellAliases[shell]; ok {
+		shell = name
+	}
+	sh, ok := hookShells[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell: %s (zsh, bash, fish, powershell or nu)", shell)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return err
+	}
+	hook, err := renderFeedbackHook(sh.template, withDiff)
+	if err != nil {
+		return err
+	}
+	hookPath := filepath.Join(home, sh.path)
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(hookPath, []byte(hook), 0644); err != nil {
+		return err
+	}
+
+	if sh.rc != nil {
+		rcPath := sh.rc(home)
+		if err := os.MkdirAll(filepath.Dir(rcPath), 0755); err != nil {
+			return err
+		}
+		var rc string
+		if data, err := os.ReadFile(rcPath); err == nil {
+			rc = string(data)
+		}
+		if !strings.Contains(rc, sh.line) {
+			rc += "\n" + sh.line + "\n"
+			if err := os.WriteFile(rcPath, []byte(rc), 0644); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Println(output.OKf("Installed %s hook. %s", shell, sh.hint))
+	if andSource && len(sh.reload) > 0 {
+		_ = exec.Command(sh.reload[0], sh.reload[1:]...).Run()
+	}
+	return nil
+}
+
+// renderFeedbackHook renders the hook template name
+func renderFeedbackHook(name string, withDiff bool) (string, error) {
+	tmpl, err := template.ParseFS(feedbackHooks, "hooks/"+name)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, hookData{WithDiff: withDiff}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func homeFile(name string) func(home string) string {
+	return func(home string) string {
+		return filepath.Join(home, name)
+	}
+}
+
+// powershellProfile is the current user's PowerShell profile, as
+// PowerShell reports it; without PowerShell on the PATH, the usual
+// location of PowerShell 7's profile.
+func powershellProfile(home string) string {
+	if p := askShell("$PROFILE", "pwsh", "powershell"); p != "" {
+		return p
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	}
+	return filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
+// nushellConfig is Nushell's config.nu, as nu reports it, or its usual
+// location in the user's configuration directory.
+func nushellConfig(home string) string {
+	if p := askShell("$nu.config-path", "nu"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "nushell", "config.nu")
+}
+
+// askShell prints expr with the first of shells that runs, without
+// loading the user's configuration
+func askShell(expr string, shells ...string) string {
+	for _, sh := range shells {
+		args := []string{"-NoProfile", "-NonInteractive", "-Command", expr}
+		if sh == "nu" {
+			args = []string{"--no-config-file", "-c", expr}
+		}
+		out, err := exec.Command(sh, args...).Output()
+		if p := strings.TrimSpace(string(out)); err == nil && p != "" {
+			return p
+		}
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestRenderFeedbackHooks(t *testing.T) {
+	for name, sh := range hookShells {
+		for _, withDiff := range []bool{false, true} {
+			hook, err := renderFeedbackHook(sh.template, withDiff)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if strings.Contains(hook, "{{") || strings.Contains(hook, "%WITH_DIFF%") {
+				t.Errorf("%s: unrendered placeholder in the hook", name)
+			}
+			if got := strings.Contains(hook, "--capture-diff"); got != withDiff {
+				t.Errorf("%s: --capture-diff in the hook = %v, want %v", name, got, withDiff)
+			}
+		}
+	}
+
+	if _, err := exec.LookPath("bash"); err == nil {
+		hook, _ := renderFeedbackHook("feedback.bash", true)
+		if out, err := exec.Command("bash", "-n", "-c", hook).CombinedOutput(); err != nil {
+			t.Errorf("invalid bash hook: %v\n%s", err, out)
+		}
+	}
+}
+
+func TestInstallFeedbackHook(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for i := 0; i < 2; i++ {
+		if err := installFeedbackHook("bash", false, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, ".gptcode", "feedback_hook.bash")); err != nil {
+		t.Fatal("the hook was not written")
+	}
+	rc, _ := os.ReadFile(filepath.Join(home, ".bashrc"))
+	if n := strings.Count(string(rc), ".gptcode/feedback_hook.bash"); n != 1 {
+		t.Errorf(".bashrc sources the hook %d times, want once", n)
+	}
+
+	if err := installFeedbackHook("tcsh", false, false); err == nil {
+		t.Error("expected an error for an unsupported shell")
+	}
+}
+
+func TestDefaultHookShell(t *testing.T) {
+	for goos, want := range map[string]string{"windows": "powershell", "darwin": "zsh", "linux": "zsh"} {
+		if got := defaultHookShell(goos); got != want {
+			t.Errorf("defaultHookShell(%q) = %q, want %q", goos, got, want)
+		}
+	}
+}
+
+func TestInstallPowerShellHook(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	// Without pwsh on the PATH the profile is found at its usual location
+	t.Setenv("PATH", t.TempDir())
+
+	for _, withDiff := range []bool{true, false} {
+		if err := installFeedbackHook("pwsh", withDiff, false); err != nil {
+			t.Fatal(err)
+		}
+		hook, err := os.ReadFile(filepath.Join(home, ".gptcode", "feedback_hook.ps1"))
+		if err != nil {
+			t.Fatal("the hook was not written")
+		}
+		if strings.Contains(string(hook), "%WITH_DIFF%") {
+			t.Error("%WITH_DIFF% left in the hook")
+		}
+		if got := strings.Contains(string(hook), "--capture-diff"); got != withDiff {
+			t.Errorf("--capture-diff in the hook = %v, want %v", got, withDiff)
+		}
+	}
+
+	profile := powershellProfile(home)
+	if runtime.GOOS != "windows" && profile != filepath.Join(home, ".config", "powershell", "Microsoft.PowerShell_profile.ps1") {
+		t.Errorf("unexpected profile %s", profile)
+	}
+	data, err := os.ReadFile(profile)
+	if err != nil {
+		t.Fatal("the profile was not written")
+	}
+	if n := strings.Count(string(data), `. "$HOME/.gptcode/feedback_hook.ps1"`); n != 1 {
+		t.Errorf("the profile loads the hook %d times, want once", n)
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/fleet"
+	"gptcode/internal/forge"
+	"gptcode/internal/github"
+	"gptcode/internal/output"
+)
+
+var fleetCmd = &cobra.Command{
+	Use:   "fleet",
+	Short: "Run the same task across many repositories",
+}
+
+var fleetRunCmd = &cobra.Command{
+	Use:   "run [task]",
+	Short: "Run a task in every repository of a list and open a PR in each",
+	Long: `Run a task in every repository listed in --repos, each in its own git
+worktree, commit the result on one branch name, push it and open a pull
+request per repository, then print a consolidated report.
+
+The list has one repository per line, # starting a comment:
+  ~/src/billing                          # local checkout
+  acme/payments                          # GitHub owner/repo
+  gitlab.example.com/platform/gateway    # host/group/repo
+  git@github.com:acme/search.git         # clone URL
+
+Repositories that are not local are cloned into ~/.gptcode/fleet, or
+fetched when an earlier run cloned them. Repositories run through a bounded
+queue: at most --concurrency at a time (default from batch.max_concurrency
+in .gptcode/config.yml, else 1). Ctrl+C stops starting new ones.
+
+Examples:
+  gptcode fleet run --repos repos.txt "upgrade Go to 1.22 and fix deprecations"
+  gptcode fleet run --repos repos.txt --concurrency 4 --draft --report fleet.md "replace ioutil with os and io"
+  gptcode fleet run --repos repos.txt --no-pr --branch chore/lint "fix golangci-lint warnings"`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reposPath, _ := cmd.Flags().GetString("repos")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		reportPath, _ := cmd.Flags().GetString("report")
+
+		run := &fleetRun{task: strings.Join(args, " ")}
+		run.branch, _ = cmd.Flags().GetString("branch")
+		run.base, _ = cmd.Flags().GetString("base")
+		run.title, _ = cmd.Flags().GetString("title")
+		run.draft, _ = cmd.Flags().GetBool("draft")
+		run.noPR, _ = cmd.Flags().GetBool("no-pr")
+		run.timeout, _ = cmd.Flags().GetDuration("timeout")
+		if run.branch == "" {
+			run.branch = "gptcode/fleet-" + github.Slug(run.task)
+		}
+		if run.title == "" {
+			run.title = fleetTitle(run.task, 72)
+		}
+
+		f, err := os.Open(reposPath)
+		if err != nil {
+			return fmt.Errorf("failed to read the repository list: %w", err)
+		}
+		specs, err := fleet.ParseRepoList(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if len(specs) == 0 {
+			return fmt.Errorf("no repositories in %s", reposPath)
+		}
+
+		if !cmd.Flags().Changed("concurrency") {
+			cwd, _ := os.Getwd()
+			if pc, _ := config.LoadProjectConfig(cwd); pc.Batch.MaxConcurrency > 0 {
+				concurrency = pc.Batch.MaxConcurrency
+			}
+		}
+		concurrency = max(concurrency, 1)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Println(output.Infof("%d repositories queued, %d at a time, on branch %s", len(specs), concurrency, run.branch))
+		report := &fleet.Report{Task: run.task, Started: time.Now()}
+		report.Results = run.all(ctx, specs, concurrency)
+		report.Duration = time.Since(report.Started).Round(time.Second).String()
+
+		printFleetReport(report)
+		if reportPath != "" {
+			if err := report.Write(reportPath); err != nil {
+				return fmt.Errorf("failed to write report: %w", err)
+			}
+			fmt.Printf("\nReport written to %s\n", reportPath)
+		}
+		if failed := report.Count(fleet.StatusFailed); failed > 0 {
+			return fmt.Errorf("%d of %d repositories failed", failed, len(specs))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fleetCmd)
+	fleetCmd.AddCommand(fleetRunCmd)
+
+	fleetRunCmd.Flags().String("repos", "", "File listing the repositories, one per line")
+	_ = fleetRunCmd.MarkFlagRequired("repos")
+	fleetRunCmd.Flags().Int("concurrency", 1, "Repositories worked on in parallel (default from batch.max_concurrency)")
+	fleetRunCmd.Flags().String("branch", "", "Branch to commit to in every repository (default gptcode/fleet-<task>)")
+	fleetRunCmd.Flags().String("base", "", "Branch to start from and open PRs against (default: each repository's default branch)")
+	fleetRunCmd.Flags().String("title", "", "Commit and PR title (default: the task)")
+	fleetRunCmd.Flags().Bool("draft", false, "Create draft pull requests")
+	fleetRunCmd.Flags().Bool("no-pr", false, "Only commit on the branch; don't push or open pull requests")
+	fleetRunCmd.Flags().Duration("timeout", 0, "Stop a repository's task after this long, e.g. 20m")
+	fleetRunCmd.Flags().String("report", "", "Write the report to this file: Markdown for .md, JSON otherwise")
+}
+
+// fleetRun is one task applied to every repository of a fleet
+type fleetRun struct {
+	task    string
+	title   string
+	branch  string
+	base    string
+	draft   bool
+	noPR    bool
+	timeout time.Duration
+}
+
+// all works through specs with at most concurrency workers, keeping the
+// results in list order. Once ctx is done the remaining repositories are
+// skipped.
+func (f *fleetRun) all(ctx context.Context, specs []string, concurrency int) []fleet.Result {
+	results := make([]fleet.Result, len(specs))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if ctx.Err() != nil {
+					results[i] = fleet.Result{Repo: specs[i], Status: fleet.StatusSkipped, Error: "interrupted"}
+					continue
+				}
+				fmt.Printf("\n▶ %s\n", specs[i])
+				results[i] = f.apply(ctx, specs[i])
+				fmt.Printf("■ %s %s\n", results[i].Repo, results[i].Status)
+			}
+		}()
+	}
+	for i := range specs {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+// apply runs the task in a worktree of one repository, commits the result
+// and, unless --no-pr, pushes it and opens a PR. Failed worktrees are kept
+// for inspection.
+func (f *fleetRun) apply(ctx context.Context, spec string) fleet.Result {
+	start := time.Now()
+	result := fleet.Result{Repo: spec, Branch: f.branch}
+	fail := func(err error) fleet.Result {
+		result.Status = fleet.StatusFailed
+		result.Error = err.Error()
+		result.Duration = time.Since(start).Round(time.Second).String()
+		return result
+	}
+
+	repo, err := fleet.Checkout(spec, fleet.CacheDir())
+	if err != nil {
+		return fail(err)
+	}
+	result.Repo = repo.Name
+	var host forge.Forge
+	if !f.noPR {
+		if host, err = forge.Detect(repo.Dir, ""); err != nil {
+			return fail(err)
+		}
+	}
+
+	base := f.base
+	if base == "" {
+		base = fleet.DefaultBranch(repo.Dir)
+	}
+	startPoint := base
+	if repo.Cloned {
+		// The clone's local branch is as old as the clone; origin was just fetched
+		startPoint = "origin/" + base
+	}
+
+	origin := github.NewClient(repo.Name)
+	origin.SetWorkDir(repo.Dir)
+	worktree := filepath.Join(os.TempDir(), "gptcode-worktrees", "fleet-"+strings.ReplaceAll(repo.Name, "/", "-"))
+	_ = origin.RemoveWorktree(worktree)
+	if err := origin.AddWorktree(worktree, f.branch, startPoint); err != nil {
+		return fail(err)
+	}
+	result.Worktree = worktree
+	cleanup := func() {
+		if err := origin.RemoveWorktree(worktree); err == nil {
+			result.Worktree = ""
+		}
+	}
+
+	executor, err := worktreeExecutor(worktree)
+	if err != nil {
+		return fail(err)
+	}
+	taskCtx, cancel := ctx, context.CancelFunc(func() {})
+	if f.timeout > 0 {
+		taskCtx, cancel = context.WithTimeoutCause(ctx, f.timeout, fmt.Errorf("the task took longer than %s", f.timeout))
+	}
+	err = executor.Execute(taskCtx, f.task)
+	if taskCtx.Err() != nil && err != nil {
+		err = context.Cause(taskCtx)
+	}
+	cancel()
+	if err != nil {
+		return fail(fmt.Errorf("task failed: %w", err))
+	}
+
+	changed, err := fleet.HasChanges(worktree)
+	if err != nil {
+		return fail(err)
+	}
+	if !changed {
+		result.Status = fleet.StatusUnchanged
+		result.Branch = ""
+		result.Duration = time.Since(start).Round(time.Second).String()
+		cleanup()
+		return result
+	}
+
+	client := github.NewClient(repo.Name)
+	client.SetWorkDir(worktree)
+	message := f.title
+	if f.title != f.task {
+		message += "\n\n" + f.task
+	}
+	if err := client.CommitChanges(github.CommitOptions{Message: message, AllFiles: true}); err != nil {
+		return fail(err)
+	}
+	result.Files = client.ChangedFiles(startPoint)
+
+	if !f.noPR {
+		target, err := forge.ResolvePushTarget(worktree, "")
+		if err != nil {
+			return fail(err)
+		}
+		target.UpstreamRepo = host.Repo()
+		if err := client.PushBranchTo(target.Remote, f.branch); err != nil {
+			return fail(err)
+		}
+		pr, err := host.CreatePR(github.PRCreateOptions{
+			Title:      f.title,
+			Body:       fleetPRBody(f.task, result.Files),
+			HeadBranch: f.branch,
+			HeadOwner:  target.HeadOwner(),
+			HeadRepo:   target.HeadRepo(),
+			BaseBranch: base,
+			IsDraft:    f.draft,
+		})
+		if err != nil {
+			return fail(err)
+		}
+		result.PR = pr.URL
+	}
+
+	result.Status = fleet.StatusDone
+	result.Duration = time.Since(start).Round(time.Second).String()
+	cleanup()
+	return result
+}
+
+func fleetPRBody(task string, files []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Summary\n\n%s\n\nThis change was made by `gptcode fleet run`, which applied the same task to several repositories.\n", task)
+	if len(files) > 0 {
+		b.WriteString("\n## Files changed\n\n")
+		for _, f := range files {
+			fmt.Fprintf(&b, "- `%s`\n", f)
+		}
+	}
+	return b.String()
+}
+
+// fleetTitle is the task's first line, cut to at most n characters
+func fleetTitle(task string, n int) string {
+	title := firstLine(task)
+	if len(title) > n {
+		title = strings.TrimSpace(title[:n-3]) + "..."
+	}
+	return title
+}
+
+func printFleetReport(report *fleet.Report) {
+	fmt.Println("\n" + output.Infof("Fleet report"))
+	fmt.Printf("   %s\n\n", report.Summary())
+	for _, r := range report.Results {
+		line := fmt.Sprintf("   %-9s %s", r.Status, r.Repo)
+		switch {
+		case r.PR != "":
+			line += "\n             " + r.PR
+		case r.Error != "":
+			line += "\n             " + strings.SplitN(r.Error, "\n", 2)[0]
+		case r.Status == fleet.StatusDone:
+			line += "\n             committed on " + r.Branch
+		}
+		if r.Worktree != "" && r.Status == fleet.StatusFailed {
+			line += "\n             worktree: " + r.Worktree
+		}
+		fmt.Println(line)
+	}
+	if n := report.Count(fleet.StatusDone); n > 0 {
+		fmt.Println(output.OKf("%d repositories changed", n))
+	}
+}
@@ -4,14 +4,23 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"gptcode/internal/agents"
+	"gptcode/internal/apigen"
 	"gptcode/internal/changelog"
 	"gptcode/internal/config"
+	"gptcode/internal/contractgen"
+	"gptcode/internal/coverage"
+	"gptcode/internal/factorygen"
+	"gptcode/internal/langdetect"
 	"gptcode/internal/llm"
 	"gptcode/internal/migration"
 	"gptcode/internal/mockgen"
+	"gptcode/internal/output"
 	"gptcode/internal/testgen"
 )
 
@@ -22,10 +31,29 @@ var genCmd = &cobra.Command{
 }
 
 var genTestCmd = &cobra.Command{
-	Use:   "test <file>",
+	Use:   "test <file> | --from-coverage [package]",
 	Short: "Generate unit tests for a source file",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runGenTest,
+	Long: `Generate unit tests for a source file.
+
+With --from-coverage, the argument is a Go package pattern (default ./...)
+whose tests are run with coverage instead. The functions they leave
+statements of uncovered are ranked by PageRank over the dependency graph,
+and the editor agent writes tests for the most important ones, a few at a
+time, aimed at their uncovered lines. Tests that do not pass are discarded.
+Coverage is measured again after each round until it reaches --threshold
+or --max-rounds is used up.
+
+Examples:
+  gptcode gen test pkg/calculator/calculator.go
+  gptcode gen test --from-coverage ./internal/...
+  gptcode gen test --from-coverage --threshold 85 --per-round 3`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if fromCoverage, _ := cmd.Flags().GetBool("from-coverage"); fromCoverage {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: runGenTest,
 }
 
 var genChangelogCmd = &cobra.Command{
@@ -91,6 +119,108 @@ Examples:
 	RunE: runGenSnapshot,
 }
 
+var genFactoryCmd = &cobra.Command{
+	Use:   "factory <model-file>",
+	Short: "Generate test data factories for the models in a file",
+	Long: `Generate test data factories for the structs, schemas or types of a file,
+in the idiom of its language:
+
+  Go          functional options builders (NewUser(WithEmail(...)))
+  Elixir      ExMachina factories for Ecto schemas
+  TypeScript  fishery factories for interfaces, types and classes
+
+Factories go to <name>_factory_test.go next to Go models,
+test/support/factories/<name>_factory.ex in Mix projects and
+<name>.factory.ts next to TypeScript types, unless --output says otherwise.
+Running it again updates them to the models, keeping their customizations.
+
+The factory file records the fields it was generated from; --check compares
+them with the models and fails when they drifted, without calling a model,
+so CI can catch factories left behind by a model change.
+
+Examples:
+  gptcode gen factory internal/models/user.go
+  gptcode gen factory lib/app/accounts/user.ex
+  gptcode gen factory src/types/order.ts --output test/factories/order.ts
+  gptcode gen factory internal/models/user.go --check`,
+	Args: cobra.ExactArgs(1),
+	RunE: runGenFactory,
+}
+
+var genContractCmd = &cobra.Command{
+	Use:   "contract",
+	Short: "Generate contract tests between HTTP services",
+	Long: `Find the HTTP endpoints a project serves (net/http, gin, echo, chi, Express,
+FastAPI, Flask, Phoenix, Rails) and the calls made to them (net/http, fetch,
+axios, requests, httpx, Req, HTTPoison, Faraday...), match them, and generate
+contract tests from the matches:
+
+  golden  Provider tests replaying each consumer's request against the
+          handler and comparing the response with a golden file (default)
+  pact    Pact consumer tests, whose pact files the provider verifies
+
+The current directory is the consumer; --provider points at the service it
+calls when that lives in another repository, otherwise the project is both.
+Calls to other hosts than localhost are left out as third-party APIs.
+
+--check lists the matches and fails when a call matches no endpoint, the
+breaking change contract tests guard against, without calling a model.
+
+Examples:
+  gptcode gen contract --check
+  gptcode gen contract --provider ../billing-api
+  gptcode gen contract --provider ../billing-api --format pact`,
+	Args: cobra.NoArgs,
+	RunE: runGenContract,
+}
+
+var genAPIClientCmd = &cobra.Command{
+	Use:   "api-client <openapi.yaml>",
+	Short: "Generate a typed API client and its tests from an OpenAPI spec",
+	Long: `Generate a typed HTTP client for an OpenAPI 3 spec (YAML or JSON), in the
+project's language: Go, TypeScript or Python.
+
+The spec is parsed into a plan: a first step writes the shared code (types
+for the schemas, the client, request helpers and errors), then one step per
+endpoint writes its method and tests, in one file per tag. The editor agent
+runs each step. The code is then compiled with go vet, tsc or py_compile,
+and the compiler's errors go back to the editor until it compiles or
+--fix-rounds is used up.
+
+--dry-run prints the plan without calling a model.
+
+Examples:
+  gptcode gen api-client openapi.yaml
+  gptcode gen api-client specs/billing.json --output internal/billing
+  gptcode gen api-client openapi.yaml --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGenAPI(cmd, args[0], apigen.KindClient)
+	},
+}
+
+var genAPIServerCmd = &cobra.Command{
+	Use:   "api-server <openapi.yaml>",
+	Short: "Generate typed API handlers and their tests from an OpenAPI spec",
+	Long: `Generate the server side of an OpenAPI 3 spec (YAML or JSON), in the project's
+language: Go, TypeScript or Python.
+
+The first step writes the shared code: types for the schemas, a service
+interface with one method per operation and the router. Then one step per
+endpoint writes its handler, which decodes and validates the request, calls
+the service and writes the documented responses, with tests against a fake
+service. Implementing the service is left to you. The code is compiled and
+fixed as for api-client.
+
+Examples:
+  gptcode gen api-server openapi.yaml
+  gptcode gen api-server openapi.yaml --output internal/httpapi --fix-rounds 3`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGenAPI(cmd, args[0], apigen.KindServer)
+	},
+}
+
 var genModel string
 
 func init() {
@@ -101,14 +231,41 @@ func init() {
 	genCmd.AddCommand(genIntegrationCmd)
 	genCmd.AddCommand(genMigrationCmd)
 	genCmd.AddCommand(genSnapshotCmd)
+	genCmd.AddCommand(genFactoryCmd)
+	genCmd.AddCommand(genContractCmd)
+	genCmd.AddCommand(genAPIClientCmd)
+	genCmd.AddCommand(genAPIServerCmd)
+
+	genTestCmd.Flags().Bool("from-coverage", false, "Write tests for the uncovered functions of a package, most important first")
+	genTestCmd.Flags().Float64("threshold", 80, "Coverage to reach, in percent (with --from-coverage)")
+	genTestCmd.Flags().Int("max-rounds", 3, "Maximum rounds of measuring coverage and writing tests (with --from-coverage)")
+	genTestCmd.Flags().Int("per-round", 5, "Functions to write tests for each round (with --from-coverage)")
+
+	genFactoryCmd.Flags().Bool("check", false, "Report drift between the models and their factories, failing when there is any")
+	genFactoryCmd.Flags().String("output", "", "Factory file (default: by language, next to the models)")
+
+	genContractCmd.Flags().String("provider", "", "Directory of the service the project calls (default: the project itself)")
+	genContractCmd.Flags().String("format", contractgen.FormatGolden, "Test format: golden or pact")
+	genContractCmd.Flags().Bool("check", false, "List the contracts and fail on calls matching no endpoint")
+	genContractCmd.Flags().String("output", "", "Test file (default: by language)")
+
+	for _, c := range []*cobra.Command{genAPIClientCmd, genAPIServerCmd} {
+		c.Flags().String("output", "", "Directory of the generated code (default: apiclient or apiserver, under src/ in TypeScript)")
+		c.Flags().String("lang", "", "Language: go, typescript or python (default: detected)")
+		c.Flags().Int("fix-rounds", 2, "Times the compiler's errors are handed back to the editor")
+		c.Flags().Bool("dry-run", false, "Print the plan without generating code")
+	}
 
 	genCmd.PersistentFlags().StringVar(&genModel, "model", "", "LLM model to use (default: from config)")
 }
 
 func runGenTest(cmd *cobra.Command, args []string) error {
+	if fromCoverage, _ := cmd.Flags().GetBool("from-coverage"); fromCoverage {
+		return runGenTestFromCoverage(cmd, args)
+	}
 	sourceFile := args[0]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -131,7 +288,7 @@ func runGenTest(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 
-	fmt.Printf("🧪 Generating unit tests for: %s\n", sourceFile)
+	fmt.Println(output.Infof("Generating unit tests for: %s", sourceFile))
 
 	result, err := generator.GenerateUnitTests(ctx, sourceFile)
 	if err != nil && result == nil {
@@ -139,9 +296,9 @@ func runGenTest(cmd *cobra.Command, args []string) error {
 	}
 
 	if result.Valid {
-		fmt.Printf("✅ Generated %s (valid)\n", result.TestFile)
+		fmt.Println(output.OKf("Generated %s (valid)", result.TestFile))
 	} else {
-		fmt.Printf("⚠️  Generated %s (may have compilation issues)\n", result.TestFile)
+		fmt.Println(output.Warnf("Generated %s (may have compilation issues)", result.TestFile))
 		if result.Error != nil {
 			fmt.Printf("   Error: %v\n", result.Error)
 		}
@@ -150,10 +307,208 @@ func runGenTest(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runGenTestFromCoverage(cmd *cobra.Command, args []string) error {
+	pkg := "./..."
+	if len(args) > 0 {
+		pkg = args[0]
+	}
+	threshold, _ := cmd.Flags().GetFloat64("threshold")
+	maxRounds, _ := cmd.Flags().GetInt("max-rounds")
+	perRound, _ := cmd.Flags().GetInt("per-round")
+	if perRound < 1 {
+		return fmt.Errorf("--per-round must be at least 1")
+	}
+
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	provider, model, err := getGenProvider(setup)
+	if err != nil {
+		return err
+	}
+	if backendCfg, ok := setup.Backend[setup.Defaults.Backend]; ok && genModel == "" {
+		if editor := backendCfg.GetModelForAgent("editor"); editor != "" {
+			model = editor
+		}
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	root, ok := config.FindProjectDir(workDir, "go.mod")
+	if !ok {
+		return fmt.Errorf("--from-coverage needs a Go module: no go.mod in %s or its parents", workDir)
+	}
+	if strings.HasPrefix(pkg, ".") {
+		// Tests run from the module root
+		rel, err := filepath.Rel(root, filepath.Join(workDir, pkg))
+		if err != nil {
+			return err
+		}
+		pkg = "./" + filepath.ToSlash(rel)
+	}
+
+	gg := &coverage.GuidedGenerator{
+		Root:      root,
+		Package:   pkg,
+		Threshold: threshold,
+		MaxRounds: maxRounds,
+		PerRound:  perRound,
+		Author: func(ctx context.Context, t coverage.Target) error {
+			editor := agents.NewEditorWithFileValidation(provider, root, model, []string{t.TestFile()})
+			_, _, err := editor.Execute(ctx, []llm.ChatMessage{{Role: "user", Content: coverageTestPrompt(t)}}, nil)
+			return err
+		},
+		Progress: func(round int, percent float64, targets []coverage.Target) {
+			fmt.Println("\n" + output.Infof("Round %d: coverage %.1f%%, target %.1f%%", round, percent, threshold))
+			for _, t := range targets {
+				fmt.Printf("   %s:%d %s (%.0f%% covered, rank %.4f)\n", t.File, t.Line, t.Function, t.Percent(), t.Rank)
+			}
+		},
+		Outcome: func(t coverage.Target, err error) {
+			if err != nil {
+				fmt.Println(output.Warnf("%s: %v", t.Function, err))
+				return
+			}
+			fmt.Println(output.OKf("%s: tests added to %s", t.Function, t.TestFile()))
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	fmt.Println(output.Infof("Generating tests from coverage for: %s", pkg))
+	result, err := gg.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("coverage-guided generation failed: %w", err)
+	}
+
+	fmt.Println("\n" + output.Infof("Coverage: %.1f%% → %.1f%% (%d function(s) tested, %d failed)",
+		result.Start, result.End, len(result.Written), len(result.Failed)))
+	if !result.Reached {
+		return fmt.Errorf("coverage %.1f%% is below the %.1f%% threshold", result.End, threshold)
+	}
+	return nil
+}
+
+// coverageTestPrompt asks for tests exercising the uncovered lines of t
+func coverageTestPrompt(t coverage.Target) string {
+	lines := make([]string, len(t.Uncovered))
+	for i, l := range t.Uncovered {
+		lines[i] = fmt.Sprint(l)
+	}
+	return fmt.Sprintf(`Write Go tests for %s in %s (line %d).
+
+The existing tests cover %d of its %d statements. The blocks starting at
+these lines never run: %s.
+
+Read %s and its existing tests, then add tests to %s that take the
+branches leading to those lines: error paths, edge cases and boundary
+values. Follow the style of the existing tests and keep them passing.
+Only change %s; do not change the code under test.`,
+		t.Function, t.File, t.Line, t.Covered, t.Statements, strings.Join(lines, ", "),
+		t.File, t.TestFile(), t.TestFile())
+}
+
+func runGenAPI(cmd *cobra.Command, specFile, kind string) error {
+	outPath, _ := cmd.Flags().GetString("output")
+	lang, _ := cmd.Flags().GetString("lang")
+	fixRounds, _ := cmd.Flags().GetInt("fix-rounds")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	spec, err := apigen.ParseSpec(specFile)
+	if err != nil {
+		return err
+	}
+	language := langdetect.Language(lang)
+	if lang == "" {
+		language = langdetect.DetectLanguage(workDir)
+	}
+	plan, err := apigen.NewPlan(spec, kind, language, outPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(output.Infof("%s %s: %d operation(s), %s %s in %s", spec.Title, spec.Version, len(spec.Operations), plan.Language, kind, plan.Dir))
+	for i, step := range plan.Steps {
+		fmt.Printf("  %d. %-24s → %s\n", i+1, step.Name, strings.Join(step.Files, ", "))
+	}
+	if dryRun {
+		return nil
+	}
+
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	provider, model, err := getGenProvider(setup)
+	if err != nil {
+		return err
+	}
+	if backendCfg, ok := setup.Backend[setup.Defaults.Backend]; ok && genModel == "" {
+		if editor := backendCfg.GetModelForAgent("editor"); editor != "" {
+			model = editor
+		}
+	}
+
+	total := len(plan.Steps)
+	g := &apigen.Generator{
+		Root:      workDir,
+		Plan:      plan,
+		FixRounds: fixRounds,
+		Author: func(ctx context.Context, step apigen.Step) error {
+			editor := agents.NewEditorWithFileValidation(provider, workDir, model, step.Files)
+			_, _, err := editor.Execute(ctx, []llm.ChatMessage{{Role: "user", Content: step.Prompt}}, nil)
+			return err
+		},
+		Progress: func(i int, step apigen.Step) {
+			if step.Operation == nil && step.Name != "foundation" {
+				fmt.Println("\n" + output.Infof("%s: fixing compile errors", step.Name))
+				return
+			}
+			fmt.Printf("\n[%d/%d] %s\n", i+1, total, step.Name)
+		},
+		Outcome: func(step apigen.Step, err error) {
+			if err != nil {
+				fmt.Println(output.Warnf("%s: %v", step.Name, err))
+			}
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Minute)
+	defer cancel()
+	result, err := g.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("API %s generation failed: %w", kind, err)
+	}
+
+	fmt.Println("\n" + output.OKf("%d of %d step(s) written in %s", len(result.Written), total, plan.Dir))
+	c := result.Compilation
+	switch {
+	case c.Command == "":
+		fmt.Println(output.Warnf("No %s compiler found; the code was not checked", plan.Language))
+	case c.OK:
+		fmt.Println(output.OKf("Compiles (%s)", c.Command))
+	default:
+		fmt.Println(output.Failf("Does not compile after %d fix round(s) (%s):\n%s", result.Fixes, c.Command, strings.TrimSpace(c.Output)))
+	}
+	if len(result.Failed) > 0 || (c.Command != "" && !c.OK) {
+		return fmt.Errorf("generated API %s is incomplete or does not compile", kind)
+	}
+	return nil
+}
+
 func runGenIntegration(cmd *cobra.Command, args []string) error {
 	packagePath := args[0]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -173,7 +528,7 @@ func runGenIntegration(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	fmt.Printf("🔗 Generating integration tests for: %s\n", packagePath)
+	fmt.Println(output.Infof("Generating integration tests for: %s", packagePath))
 
 	result, err := generator.GenerateIntegrationTests(ctx, packagePath)
 	if err != nil && result == nil {
@@ -181,9 +536,9 @@ func runGenIntegration(cmd *cobra.Command, args []string) error {
 	}
 
 	if result.Valid {
-		fmt.Printf("✅ Generated %s (valid)\n", result.TestFile)
+		fmt.Println(output.OKf("Generated %s (valid)", result.TestFile))
 	} else {
-		fmt.Printf("⚠️  Generated %s (may have issues)\n", result.TestFile)
+		fmt.Println(output.Warnf("Generated %s (may have issues)", result.TestFile))
 		if result.Error != nil {
 			fmt.Printf("   Error: %v\n", result.Error)
 		}
@@ -196,7 +551,7 @@ func runGenIntegration(cmd *cobra.Command, args []string) error {
 func runGenMigration(cmd *cobra.Command, args []string) error {
 	migrationName := args[0]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -216,7 +571,7 @@ func runGenMigration(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 
-	fmt.Printf("💾 Analyzing model changes for: %s\n", migrationName)
+	fmt.Println(output.Infof("Analyzing model changes for: %s", migrationName))
 
 	result, err := generator.GenerateMigration(ctx, migrationName)
 	if err != nil && result == nil {
@@ -224,11 +579,11 @@ func runGenMigration(cmd *cobra.Command, args []string) error {
 	}
 
 	if len(result.Changes) == 0 {
-		fmt.Println("ℹ️  No model changes detected")
+		fmt.Println(output.Infof("No model changes detected"))
 		return nil
 	}
 
-	fmt.Printf("\n🔍 Detected %d change(s):\n", len(result.Changes))
+	fmt.Println("\n" + output.Infof("Detected %d change(s):", len(result.Changes)))
 	for _, change := range result.Changes {
 		switch change.Type {
 		case "added":
@@ -249,9 +604,9 @@ func runGenMigration(cmd *cobra.Command, args []string) error {
 	}
 
 	if result.Valid {
-		fmt.Printf("\n✅ Generated migration: %s\n", result.MigrationFile)
+		fmt.Println("\n" + output.OKf("Generated migration: %s", result.MigrationFile))
 	} else {
-		fmt.Printf("\n⚠️  Generated migration with issues: %s\n", result.MigrationFile)
+		fmt.Println("\n" + output.Warnf("Generated migration with issues: %s", result.MigrationFile))
 		if result.Error != nil {
 			fmt.Printf("   Error: %v\n", result.Error)
 		}
@@ -272,12 +627,7 @@ func getGenProvider(setup *config.Setup) (llm.Provider, string, error) {
 		return nil, "", fmt.Errorf("backend %s not configured", backendName)
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	if model == "" {
 		model = backendCfg.GetModelForAgent("query")
@@ -307,7 +657,7 @@ func runGenChangelog(cmd *cobra.Command, args []string) error {
 		toTag = args[1]
 	}
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -327,7 +677,7 @@ func runGenChangelog(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	fmt.Printf("📝 Generating CHANGELOG from %s to %s...\n", fromTag, toTag)
+	fmt.Println(output.Infof("Generating CHANGELOG from %s to %s...", fromTag, toTag))
 
 	entry, err := generator.Generate(ctx, fromTag, toTag)
 	if err != nil {
@@ -341,7 +691,7 @@ func runGenChangelog(cmd *cobra.Command, args []string) error {
 func runGenMock(cmd *cobra.Command, args []string) error {
 	sourceFile := args[0]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -361,7 +711,7 @@ func runGenMock(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 
-	fmt.Printf("🎭 Generating mocks for: %s\n", sourceFile)
+	fmt.Println(output.Infof("Generating mocks for: %s", sourceFile))
 
 	result, err := generator.GenerateMock(ctx, sourceFile)
 	if err != nil && result == nil {
@@ -369,9 +719,9 @@ func runGenMock(cmd *cobra.Command, args []string) error {
 	}
 
 	if result.Valid {
-		fmt.Printf("✅ Generated %s (valid)\n", result.MockFile)
+		fmt.Println(output.OKf("Generated %s (valid)", result.MockFile))
 	} else {
-		fmt.Printf("⚠️  Generated %s (may have issues)\n", result.MockFile)
+		fmt.Println(output.Warnf("Generated %s (may have issues)", result.MockFile))
 		if result.Error != nil {
 			fmt.Printf("   Error: %v\n", result.Error)
 		}
@@ -383,7 +733,7 @@ func runGenMock(cmd *cobra.Command, args []string) error {
 func runGenSnapshot(cmd *cobra.Command, args []string) error {
 	sourceFile := args[0]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -398,18 +748,161 @@ func runGenSnapshot(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
 	defer cancel()
 
-	fmt.Printf("📸 Generating snapshot tests for: %s\n", sourceFile)
+	fmt.Println(output.Infof("Generating snapshot tests for: %s", sourceFile))
 
 	testFile, err := generator.Generate(ctx, sourceFile)
 	if err != nil {
 		return fmt.Errorf("failed to generate snapshot tests: %w", err)
 	}
 
-	fmt.Printf("✅ Generated: %s\n", testFile)
-	fmt.Println("\n💡 Next steps:")
+	fmt.Println(output.OKf("Generated: %s", testFile))
+	fmt.Println("\n" + output.Infof("Next steps:"))
 	fmt.Println("  1. Run the tests to generate initial snapshots")
 	fmt.Println("  2. Review the snapshots in __snapshots__/")
 	fmt.Println("  3. Commit both test files and snapshots")
 
 	return nil
 }
+
+func runGenFactory(cmd *cobra.Command, args []string) error {
+	modelFile := args[0]
+	check, _ := cmd.Flags().GetBool("check")
+	outPath, _ := cmd.Flags().GetString("output")
+
+	if check {
+		drifts, err := factorygen.Check(modelFile, outPath)
+		if err != nil {
+			return err
+		}
+		if len(drifts) == 0 {
+			fmt.Println(output.OKf("Factories are in sync with the models"))
+			return nil
+		}
+		fmt.Println(output.Warnf("%d change(s) since the factories were generated:", len(drifts)))
+		for _, d := range drifts {
+			fmt.Printf("  %s\n", d)
+		}
+		fmt.Printf("\nUpdate them with: gptcode gen factory %s\n", modelFile)
+		return fmt.Errorf("factories drifted from %s", modelFile)
+	}
+
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	provider, model, err := getGenProvider(setup)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+
+	generator := factorygen.NewFactoryGenerator(provider, model, workDir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	fmt.Println(output.Infof("Generating factories for: %s", modelFile))
+
+	result, err := generator.Generate(ctx, modelFile, outPath)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range result.Models {
+		fmt.Printf("  %s (%d fields)\n", m.Name, len(m.Fields))
+	}
+	verb := "Generated"
+	if result.Updated {
+		verb = "Updated"
+	}
+	if result.Valid {
+		fmt.Println(output.OKf("%s %s", verb, result.FactoryFile))
+	} else {
+		fmt.Println(output.Warnf("%s %s (may have issues)", verb, result.FactoryFile))
+		if result.Error != nil {
+			fmt.Printf("   Error: %v\n", result.Error)
+		}
+	}
+
+	return nil
+}
+
+func runGenContract(cmd *cobra.Command, args []string) error {
+	providerDir, _ := cmd.Flags().GetString("provider")
+	format, _ := cmd.Flags().GetString("format")
+	check, _ := cmd.Flags().GetBool("check")
+	outPath, _ := cmd.Flags().GetString("output")
+
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	if providerDir == "" {
+		providerDir = workDir
+	}
+	if providerDir, err = filepath.Abs(providerDir); err != nil {
+		return err
+	}
+
+	fmt.Println(output.Infof("Discovering endpoints and calls..."))
+	contracts, unmatched, err := contractgen.Check(workDir, providerDir)
+	if err != nil {
+		return fmt.Errorf("failed to discover endpoints: %w", err)
+	}
+	for _, c := range contracts {
+		e := c.Endpoint
+		fmt.Printf("  %-6s %s → %s (%s:%d), %d call(s)\n", e.Method, e.Path, e.Handler, e.File, e.Line, len(c.Calls))
+	}
+	if len(unmatched) > 0 {
+		fmt.Println("\n" + output.Warnf("%d call(s) match no endpoint:", len(unmatched)))
+		for _, c := range unmatched {
+			fmt.Printf("  %-6s %s (%s:%d)\n", c.Method, c.Path, c.File, c.Line)
+		}
+	}
+
+	if check {
+		if len(unmatched) > 0 {
+			return fmt.Errorf("%d call(s) match no endpoint", len(unmatched))
+		}
+		fmt.Println(output.OKf("%d endpoint(s) called, every call matches one", len(contracts)))
+		return nil
+	}
+
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	provider, model, err := getGenProvider(setup)
+	if err != nil {
+		return err
+	}
+
+	generator := contractgen.NewContractGenerator(provider, model)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	fmt.Println("\n" + output.Infof("Generating %s contract tests for %d endpoint(s)...", format, len(contracts)))
+
+	result, err := generator.Generate(ctx, workDir, providerDir, contracts, format, outPath)
+	if err != nil {
+		return err
+	}
+
+	if result.Valid {
+		fmt.Println(output.OKf("Generated %s", result.TestFile))
+	} else {
+		fmt.Println(output.Warnf("Generated %s (may have issues)", result.TestFile))
+		if result.Error != nil {
+			fmt.Printf("   Error: %v\n", result.Error)
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
 	"os"
@@ -9,8 +10,11 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 	"gptcode/internal/config"
 	"gptcode/internal/llm"
+	"gptcode/internal/merge"
+	"gptcode/internal/output"
 )
 
 var gitCmd = &cobra.Command{
@@ -78,6 +82,30 @@ Examples:
 	RunE: runGitReword,
 }
 
+var gitResolveCmd = &cobra.Command{
+	Use:   "resolve [file...]",
+	Short: "Resolve conflict markers hunk by hunk, with a confidence per hunk",
+	Long: `Resolve the conflict markers of the given files, or of every conflicted
+file, one hunk at a time. Hunks where both sides made the same change, or
+only one side changed the common ancestor, are settled without the model;
+the others get a proposed resolution with a confidence from 0 to 100%.
+
+Each hunk is shown for you to accept, skip, or replace with ours or theirs.
+With --yes, or without a terminal, hunks at or above --min-confidence are
+accepted and the rest skipped. Files left without conflicts are staged;
+skipped hunks keep their markers.
+
+To use it as git mergetool --tool=gptcode, add the output of
+--print-config to your git config.
+
+Examples:
+  gptcode git resolve
+  gptcode git resolve internal/api/handler.go --yes --min-confidence 0.9
+  gptcode git resolve --print-config >> ~/.gitconfig
+  git mergetool --tool=gptcode`,
+	RunE: runGitResolve,
+}
+
 var gitModel string
 var gitInteractive bool
 
@@ -88,16 +116,21 @@ func init() {
 	gitCmd.AddCommand(gitRebaseCmd)
 	gitCmd.AddCommand(gitSquashCmd)
 	gitCmd.AddCommand(gitRewordCmd)
+	gitCmd.AddCommand(gitResolveCmd)
 
 	gitCmd.PersistentFlags().StringVar(&gitModel, "model", "", "LLM model to use")
 	gitRebaseCmd.Flags().BoolVar(&gitInteractive, "interactive", false, "Interactive rebase")
+	gitResolveCmd.Flags().BoolP("yes", "y", false, "Accept hunks at or above --min-confidence without asking")
+	gitResolveCmd.Flags().Float64("min-confidence", 0.8, "Confidence (0-1) a hunk needs to be accepted without asking")
+	gitResolveCmd.Flags().Bool("mergetool", false, "Run as git mergetool: resolve the one file given, don't stage it, fail if hunks remain")
+	gitResolveCmd.Flags().Bool("print-config", false, "Print the git config that registers gptcode as a mergetool")
 }
 
 func runGitBisect(cmd *cobra.Command, args []string) error {
 	goodCommit := args[0]
 	badCommit := args[1]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -165,7 +198,7 @@ func runGitBisect(cmd *cobra.Command, args []string) error {
 func runGitCherryPick(cmd *cobra.Command, args []string) error {
 	commits := args
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -219,7 +252,7 @@ func runGitRebase(cmd *cobra.Command, args []string) error {
 		target = args[0]
 	}
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -365,10 +398,179 @@ Return ONLY the resolved file content with conflicts removed.`, file, reference,
 	return nil
 }
 
+func runGitResolve(cmd *cobra.Command, args []string) error {
+	yes, _ := cmd.Flags().GetBool("yes")
+	minConfidence, _ := cmd.Flags().GetFloat64("min-confidence")
+	mergetool, _ := cmd.Flags().GetBool("mergetool")
+	printConfig, _ := cmd.Flags().GetBool("print-config")
+
+	if printConfig {
+		fmt.Print(merge.MergetoolConfig("gptcode"))
+		return nil
+	}
+	if mergetool && len(args) != 1 {
+		return fmt.Errorf("--mergetool takes the one file git passes as $MERGED")
+	}
+
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	provider, model, err := getGitProvider(setup)
+	if err != nil {
+		return err
+	}
+	resolver := merge.NewResolver(provider, model)
+
+	files := args
+	if len(files) == 0 {
+		if files, err = resolver.DetectConflicts(); err != nil {
+			return fmt.Errorf("failed to detect conflicts: %w", err)
+		}
+	}
+	if len(files) == 0 {
+		fmt.Println(output.OKf("No merge conflicts detected"))
+		return nil
+	}
+
+	r := &hunkReview{
+		ask:           !yes && term.IsTerminal(int(os.Stdin.Fd())),
+		minConfidence: minConfidence,
+		in:            bufio.NewReader(os.Stdin),
+	}
+	remaining := 0
+	for _, file := range files {
+		left, err := r.resolveFile(resolver, file, !mergetool)
+		if err != nil {
+			fmt.Println(output.Failf("%s: %v", file, err))
+			remaining++
+			continue
+		}
+		remaining += left
+		if r.quit {
+			break
+		}
+	}
+
+	fmt.Println("\n" + output.OKf("%d hunk(s) resolved, %d left with markers", r.accepted, remaining))
+	if remaining > 0 {
+		if mergetool {
+			return fmt.Errorf("%d conflict(s) left in %s", remaining, args[0])
+		}
+		fmt.Println(output.Infof("Finish the remaining conflicts, then git add the files"))
+	} else if !mergetool {
+		fmt.Println(output.Infof("Review changes with: git diff --cached"))
+	}
+	return nil
+}
+
+// hunkReview walks the hunks of conflicted files, asking about each one
+// unless ask is false, in which case the confidence decides
+type hunkReview struct {
+	ask           bool
+	minConfidence float64
+	in            *bufio.Reader
+	accepted      int
+	quit          bool
+}
+
+// resolveFile resolves the hunks of one file, writes back the accepted
+// ones and, once none are left and stage is set, stages the file. It
+// returns how many hunks are left.
+func (r *hunkReview) resolveFile(resolver *merge.Resolver, path string, stage bool) (int, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	doc, err := merge.ParseHunks(string(content))
+	if err != nil {
+		return 0, err
+	}
+	hunks := doc.Hunks()
+	if len(hunks) == 0 {
+		fmt.Printf("⏭️  %s has no conflict markers\n", path)
+		return 0, nil
+	}
+
+	fmt.Println("\n" + output.Infof("%s: %d conflict(s)", path, len(hunks)))
+	for i, h := range hunks {
+		if r.quit {
+			break
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+		err := resolver.ResolveHunk(ctx, path, doc, h)
+		cancel()
+		if err != nil {
+			fmt.Printf("   %s\n", output.Warnf("line %d: %v", h.Line, err))
+			continue
+		}
+		r.review(h, fmt.Sprintf("%s:%d (%d/%d)", path, h.Line, i+1, len(hunks)))
+		if h.Accepted {
+			r.accepted++
+		}
+	}
+
+	left := doc.Unresolved()
+	if left == len(hunks) {
+		return left, nil
+	}
+	if err := os.WriteFile(path, []byte(doc.Render()), info.Mode().Perm()); err != nil {
+		return left, err
+	}
+	if left == 0 && stage {
+		if out, err := exec.Command("git", "add", "--", path).CombinedOutput(); err != nil {
+			return left, fmt.Errorf("failed to stage: %v: %s", err, out)
+		}
+		fmt.Printf("   %s\n", output.OKf("Resolved and staged"))
+	}
+	return left, nil
+}
+
+// review shows a proposed resolution and records the decision on h
+func (r *hunkReview) review(h *merge.Hunk, where string) {
+	confident := h.Confidence >= r.minConfidence
+	fmt.Printf("\n── %s  confidence %.0f%%: %s\n", where, h.Confidence*100, h.Reason)
+	if !r.ask {
+		h.Accepted = confident && h.Resolution != ""
+		if h.Accepted {
+			fmt.Println("   accepted")
+		} else {
+			fmt.Println("   skipped, below --min-confidence")
+		}
+		return
+	}
+
+	fmt.Printf("<<<<<<< %s\n%s=======\n%s>>>>>>> %s\n", h.OursLabel, h.Ours, h.Theirs, h.TheirsLabel)
+	fmt.Println("Proposed:")
+	fmt.Println(indent(h.Resolution))
+	choices := "[a]ccept  [S]kip  [o]urs  [t]heirs  [q]uit: "
+	if confident {
+		choices = "[A]ccept  [s]kip  [o]urs  [t]heirs  [q]uit: "
+	}
+	fmt.Print(choices)
+	line, _ := r.in.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "":
+		h.Accepted = confident
+	case "a", "accept":
+		h.Accepted = true
+	case "o", "ours":
+		h.Resolution, h.Accepted = h.Ours, true
+	case "t", "theirs":
+		h.Resolution, h.Accepted = h.Theirs, true
+	case "q", "quit":
+		r.quit = true
+	}
+}
+
 func runGitSquash(cmd *cobra.Command, args []string) error {
 	baseCommit := args[0]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -398,9 +600,6 @@ func runGitSquash(cmd *cobra.Command, args []string) error {
 	diffCmd := exec.Command("git", "diff", baseCommit+"..HEAD")
 	diffOutput, _ := diffCmd.Output()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
-
 	prompt := fmt.Sprintf(`Generate a concise commit message for squashing these commits:
 
 %s
@@ -410,17 +609,11 @@ Diff summary:
 
 Provide only the commit message (first line is subject, then blank line, then optional body).`, string(commitsOutput), truncate(string(diffOutput), 2000))
 
-	resp, err := provider.Chat(ctx, llm.ChatRequest{
-		SystemPrompt: "You are a helpful assistant that generates concise, well-formatted git commit messages.",
-		UserPrompt:   prompt,
-		Model:        model,
-	})
-
+	message, err := generateCommitMessage(provider, model,
+		"You are a helpful assistant that generates concise, well-formatted git commit messages.", prompt)
 	if err != nil {
-		return fmt.Errorf("failed to generate message: %w", err)
+		return err
 	}
-
-	message := strings.TrimSpace(resp.Text)
 	fmt.Println("\n📝 Generated commit message:")
 	fmt.Println(message)
 
@@ -441,7 +634,7 @@ Provide only the commit message (first line is subject, then blank line, then op
 func runGitReword(cmd *cobra.Command, args []string) error {
 	commit := args[0]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -460,9 +653,6 @@ func runGitReword(cmd *cobra.Command, args []string) error {
 	diffCmd := exec.Command("git", "show", commit)
 	diffOutput, _ := diffCmd.Output()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-	defer cancel()
-
 	prompt := fmt.Sprintf(`Improve this commit message following best practices:
 
 Current message:
@@ -473,17 +663,11 @@ Commit diff:
 
 Provide an improved message (subject line + optional body). Be concise.`, string(currentMsg), truncate(string(diffOutput), 2000))
 
-	resp, err := provider.Chat(ctx, llm.ChatRequest{
-		SystemPrompt: "You are a helpful assistant that improves git commit messages following best practices.",
-		UserPrompt:   prompt,
-		Model:        model,
-	})
-
+	message, err := generateCommitMessage(provider, model,
+		"You are a helpful assistant that improves git commit messages following best practices.", prompt)
 	if err != nil {
-		return fmt.Errorf("failed to generate message: %w", err)
+		return err
 	}
-
-	message := strings.TrimSpace(resp.Text)
 	fmt.Println("📝 Suggested commit message:")
 	fmt.Println(message)
 	fmt.Println("\n💡 To apply: git commit --amend -m \"<message>\"")
@@ -491,6 +675,23 @@ Provide an improved message (subject line + optional body). Be concise.`, string
 	return nil
 }
 
+// generateCommitMessage asks the git model for a commit message, shared by
+// squash, reword and commit
+func generateCommitMessage(provider llm.Provider, model, systemPrompt, prompt string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: systemPrompt,
+		UserPrompt:   prompt,
+		Model:        model,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate message: %w", err)
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
+
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -510,12 +711,7 @@ func getGitProvider(setup *config.Setup) (llm.Provider, string, error) {
 		return nil, "", fmt.Errorf("backend %s not configured", backendName)
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	if model == "" {
 		model = backendCfg.GetModelForAgent("editor")
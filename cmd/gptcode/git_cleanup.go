@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/forge"
+	"gptcode/internal/github"
+	"gptcode/internal/output"
+)
+
+var gitCleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Delete agent branches whose PRs were merged or closed",
+	Long: `Delete the branches agent workflows leave behind (issue-123-slug, the
+git.branch_template names such as feat/123-slug, gptcode/<job>-<date>) once
+their pull requests are merged or closed. Branches with an open PR or no PR
+at all are kept, as are the current branch and main/master, and branches
+with commits their PR does not have.
+
+Local branches are force-deleted, since squash merges leave them unmerged as
+far as git can tell. With --remote the branches are also deleted from the
+remote agents push to.
+
+Set daemon.cleanup_branches in .gptcode/config.yml to a schedule (e.g.
+weekly) to run 'git cleanup --remote --yes' from gptcode daemon.
+
+Examples:
+  gptcode git cleanup --dry-run
+  gptcode git cleanup --merged-only
+  gptcode git cleanup --remote --yes`,
+	Args: cobra.NoArgs,
+	RunE: runGitCleanup,
+}
+
+func init() {
+	gitCmd.AddCommand(gitCleanupCmd)
+	gitCleanupCmd.Flags().Bool("merged-only", false, "Keep branches whose PRs were closed without merging")
+	gitCleanupCmd.Flags().Bool("remote", false, "Also delete the branches from the remote")
+	gitCleanupCmd.Flags().Bool("all", false, "Consider every branch, not only the ones agents create")
+	gitCleanupCmd.Flags().Bool("dry-run", false, "List the branches without deleting them")
+	gitCleanupCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+}
+
+func runGitCleanup(cmd *cobra.Command, args []string) error {
+	mergedOnly, _ := cmd.Flags().GetBool("merged-only")
+	remote, _ := cmd.Flags().GetBool("remote")
+	all, _ := cmd.Flags().GetBool("all")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	host, err := forge.Detect(cwd, "")
+	if err != nil {
+		return err
+	}
+	opts := github.CleanupOptions{MergedOnly: mergedOnly, All: all, Keep: []string{"main", "master"}}
+	if remote {
+		target, err := forge.ResolvePushTarget(cwd, "")
+		if err != nil {
+			return err
+		}
+		opts.Remote = target.Remote
+	}
+
+	client := gitClient(host, cwd)
+	stale, err := client.StaleBranches(opts, host.BranchPRs)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		fmt.Println(output.OKf("No merged or closed branches to delete"))
+		return nil
+	}
+
+	fmt.Println(output.Infof("%d branches with merged or closed PRs:", len(stale)))
+	for _, b := range stale {
+		fmt.Printf("   %-40s #%d %s\n", b, b.PR.Number, strings.ToLower(b.PR.State))
+	}
+	if dryRun {
+		return nil
+	}
+	if !yes && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Printf("Delete these %d branches? [y/N] ", len(stale))
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer := strings.ToLower(strings.TrimSpace(line)); answer != "y" && answer != "yes" {
+			return nil
+		}
+	}
+
+	failed := 0
+	for _, b := range stale {
+		if err := client.DeleteBranch(b); err != nil {
+			fmt.Fprintln(os.Stderr, output.Warnf("Could not delete %s: %s", b, strings.SplitN(err.Error(), "\n", 2)[0]))
+			failed++
+		}
+	}
+	fmt.Println(output.OKf("Deleted %d branches", len(stale)-failed))
+	if failed > 0 {
+		return fmt.Errorf("%d branches could not be deleted", failed)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/config"
+	"gptcode/internal/github"
+	"gptcode/internal/output"
+	"gptcode/internal/validation"
+)
+
+var gitCommitCmd = &cobra.Command{
+	Use:   "commit [files...]",
+	Short: "Commit with a generated Conventional Commits message",
+	Long: `Stage the given files (or, with --all, every tracked change), have the
+editor model read the staged diff and write a Conventional Commits message
+(type, scope, body and a BREAKING CHANGE footer when the change breaks
+callers), show it, and commit once you accept or edit it.
+
+Without files or --all, what is already staged is committed.
+
+The staged changes are checked for likely secrets (API keys, private keys,
+tokens) first, and the commit is refused when any turn up, unless
+--allow-secrets or defaults.secret_scan is warn or off. With
+--redact-secrets, the editor replaces them with environment lookups and the
+files are staged again.
+
+Examples:
+  gptcode git commit
+  gptcode git commit internal/auth/token.go internal/auth/token_test.go
+  gptcode git commit --all --type fix
+  gptcode git commit --all --yes`,
+	RunE: runGitCommit,
+}
+
+func init() {
+	gitCmd.AddCommand(gitCommitCmd)
+
+	gitCommitCmd.Flags().BoolP("all", "a", false, "Stage every change to tracked files first")
+	gitCommitCmd.Flags().String("type", "", "Commit type to use instead of the one the model picks (feat, fix, ...)")
+	gitCommitCmd.Flags().String("scope", "", "Commit scope (default: inferred from the staged files)")
+	gitCommitCmd.Flags().BoolP("yes", "y", false, "Commit without asking")
+	gitCommitCmd.Flags().BoolP("edit", "e", false, "Open the message in the git editor before committing")
+	gitCommitCmd.Flags().Bool("allow-secrets", false, "Commit even if the staged changes contain likely secrets")
+	gitCommitCmd.Flags().Bool("redact-secrets", false, "Have the editor replace likely secrets with environment lookups, then stage them again")
+}
+
+// maxCommitDiff caps how much of the staged diff the model reads; the stat
+// always covers every file
+const maxCommitDiff = 12000
+
+const commitMessagePrompt = `You write git commit messages in the Conventional Commits format:
+
+<type>(<scope>): <subject>
+
+<body>
+
+<footer>
+
+Rules:
+- type is one of: %s
+- scope is a short noun for the area changed; use %q unless another fits better, and omit it when nothing fits.
+- subject: imperative mood, lowercase, no trailing period, at most 72 characters including the prefix.
+- body: what changed and why, wrapped at 72 columns; omit it for trivial changes.
+- When the change breaks callers (removed or renamed exported API, changed config or CLI behavior), add ! after the scope and a footer "BREAKING CHANGE: <what breaks and how to migrate>".
+- Reply with the message only, no code fences or commentary.`
+
+func runGitCommit(cmd *cobra.Command, args []string) error {
+	all, _ := cmd.Flags().GetBool("all")
+	typeName, _ := cmd.Flags().GetString("type")
+	scope, _ := cmd.Flags().GetString("scope")
+	yes, _ := cmd.Flags().GetBool("yes")
+	edit, _ := cmd.Flags().GetBool("edit")
+	allowSecrets, _ := cmd.Flags().GetBool("allow-secrets")
+	redact, _ := cmd.Flags().GetBool("redact-secrets")
+
+	if all {
+		if out, err := exec.Command("git", "add", "--update").CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to stage changes: %w: %s", err, out)
+		}
+	}
+	if len(args) > 0 {
+		if out, err := exec.Command("git", append([]string{"add", "--"}, args...)...).CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to stage files: %w: %s", err, out)
+		}
+	}
+
+	filesOut, err := exec.Command("git", "diff", "--cached", "--name-only").Output()
+	if err != nil {
+		return fmt.Errorf("failed to read staged files: %w", err)
+	}
+	files := strings.Fields(string(filesOut))
+	if len(files) == 0 {
+		return fmt.Errorf("nothing staged; pass the files to commit or --all")
+	}
+
+	workDir, _ := os.Getwd()
+	var redactFn func([]validation.SecretFinding) error
+	if redact {
+		redactFn = func(findings []validation.SecretFinding) error {
+			changed, err := redactSecrets(workDir, findings)
+			if len(changed) > 0 {
+				if out, addErr := exec.Command("git", append([]string{"add", "--"}, changed...)...).CombinedOutput(); addErr != nil {
+					return fmt.Errorf("failed to stage redacted files: %w: %s", addErr, out)
+				}
+			}
+			return err
+		}
+	}
+	if err := guardSecrets(validation.NewSecretScanner(workDir).Staged, allowSecrets, redactFn); err != nil {
+		return err
+	}
+	if scope == "" {
+		scope = github.InferScope(files)
+	}
+	stat, _ := exec.Command("git", "diff", "--cached", "--stat").Output()
+	diff, _ := exec.Command("git", "diff", "--cached").Output()
+	recent, _ := exec.Command("git", "log", "-10", "--format=%s").Output()
+
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	provider, model, err := getGitProvider(setup)
+	if err != nil {
+		return err
+	}
+
+	prompt := fmt.Sprintf("Staged changes:\n%s\nDiff:\n%s", stat, truncate(string(diff), maxCommitDiff))
+	if len(strings.TrimSpace(string(recent))) > 0 {
+		prompt += "\n\nRecent commit subjects, for the project's wording:\n" + string(recent)
+	}
+	if typeName != "" {
+		prompt += fmt.Sprintf("\n\nUse the type %q.", typeName)
+	}
+
+	progress := output.StartStatus(os.Stderr, "Writing commit message")
+	message, err := generateCommitMessage(provider, model,
+		fmt.Sprintf(commitMessagePrompt, strings.Join(github.ConventionalTypes, ", "), scope), prompt)
+	progress.Stop()
+	if err != nil {
+		return err
+	}
+	message = github.NormalizeCommitMessage(message, scope)
+	if typeName != "" {
+		message = withCommitType(message, typeName)
+	}
+
+	fmt.Println(output.Infof("Commit message:"))
+	fmt.Println(indent(message))
+	fmt.Println()
+
+	if !yes && !edit {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("not committing without a terminal to confirm on; use --yes")
+		}
+		fmt.Print("Commit? [Y]es  [e]dit  [n]o: ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "", "y", "yes":
+		case "e", "edit":
+			edit = true
+		default:
+			fmt.Println("Not committed; the files stay staged")
+			return nil
+		}
+	}
+	return commitWithMessage(message, edit)
+}
+
+// withCommitType replaces the type of a conventional subject
+func withCommitType(message, typeName string) string {
+	subject, rest, _ := strings.Cut(message, "\n")
+	if i := strings.IndexAny(subject, "(!:"); i > 0 {
+		subject = typeName + subject[i:]
+	}
+	if rest != "" {
+		return subject + "\n" + rest
+	}
+	return subject
+}
+
+// commitWithMessage commits the staged changes; with edit, git opens its
+// editor on the message first and an emptied message aborts the commit.
+func commitWithMessage(message string, edit bool) error {
+	file, err := os.CreateTemp("", "gptcode-commit-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(message + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	file.Close()
+
+	args := []string{"commit", "--file", file.Name()}
+	if edit {
+		args = append(args, "--edit")
+	}
+	commit := exec.Command("git", args...)
+	commit.Stdin, commit.Stdout, commit.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := commit.Run(); err != nil {
+		return fmt.Errorf("git commit failed: %w", err)
+	}
+	fmt.Println(output.OKf("Committed"))
+	return nil
+}
@@ -6,7 +6,10 @@ import (
 	"strings"
 	"time"
 
+	"gptcode/internal/config"
 	"gptcode/internal/graph"
+	"gptcode/internal/index"
+	"gptcode/internal/output"
 
 	"github.com/spf13/cobra"
 )
@@ -19,32 +22,57 @@ var graphCmd = &cobra.Command{
 var graphBuildCmd = &cobra.Command{
 	Use:   "build",
 	Short: "Build and index the dependency graph",
+	Long: `Build the dependency graph, parsing only the files that changed since the
+last build. The imports of the others come from the cache in
+.gptcode/graph.cache.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		cwd, err := os.Getwd()
-		if err != nil {
-			return err
-		}
+		return buildGraph(false)
+	},
+}
 
-		fmt.Println(" Building dependency graph...")
-		start := time.Now()
+var graphRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Rebuild the dependency graph from scratch",
+	Long: `Parse every file again and replace .gptcode/graph.cache, for when the cache
+seems stale.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return buildGraph(true)
+	},
+}
 
-		builder := graph.NewBuilder(cwd)
-		g, err := builder.Build()
-		if err != nil {
-			return fmt.Errorf("failed to build graph: %w", err)
-		}
+func buildGraph(rebuild bool) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
 
-		fmt.Printf("   Nodes: %d\n", len(g.Nodes))
-		fmt.Printf("   Edges: %d\n", countEdges(g))
+	fmt.Println(" Building dependency graph...")
+	start := time.Now()
 
-		fmt.Println(" Calculating PageRank...")
-		g.PageRank(0.85, 20)
+	builder := graph.NewBuilder(cwd)
+	var g *graph.Graph
+	if rebuild {
+		g, err = builder.Rebuild()
+	} else {
+		g, err = builder.Build()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build graph: %w", err)
+	}
 
-		duration := time.Since(start)
-		fmt.Printf("[OK] Done in %v\n", duration)
+	stats := builder.Stats()
+	fmt.Printf("   Files: %d (%d parsed, %d cached)\n", stats.Files, stats.Parsed, stats.Reused)
+	fmt.Printf("   Nodes: %d\n", len(g.Nodes))
+	fmt.Printf("   Symbols: %d\n", len(g.Nodes)-len(g.Files()))
+	fmt.Printf("   Edges: %d\n", countEdges(g))
 
-		return nil
-	},
+	fmt.Println(" Calculating PageRank...")
+	g.PageRank(0.85, 20)
+
+	duration := time.Since(start)
+	fmt.Println(output.OKf("Done in %v", duration))
+
+	return nil
 }
 
 var graphQueryCmd = &cobra.Command{
@@ -65,15 +93,35 @@ var graphQueryCmd = &cobra.Command{
 		g.PageRank(0.85, 20)
 
 		query := strings.Join(args, " ")
-		optimizer := graph.NewOptimizer(g)
-		results := optimizer.OptimizeContext(query, 10)
+		limit, _ := cmd.Flags().GetInt("limit")
+		explain, _ := cmd.Flags().GetBool("explain")
+
+		ranker := graph.NewRanker(g, cwd)
+		setup, _ := config.LoadEffectiveSetup()
+		if ranker.Weights, err = ranker.Weights.With(setup.Context.Weights); err != nil {
+			return err
+		}
+		if ix, embedder, err := index.Open(cwd, setup); err == nil {
+			ranker.Similarity = ix.Similarity(embedder)
+		}
+		sel := ranker.Select(query, limit)
 
 		fmt.Printf("\n Query: %q\n", query)
+		if explain {
+			fmt.Print(sel.Explain(limit + 10))
+			fmt.Println()
+			return nil
+		}
 		fmt.Println("📂 Relevant Context:")
-		for _, path := range results {
-			nodeID := g.Paths[path]
-			score := g.Nodes[nodeID].Score
-			fmt.Printf("   - %s (PR: %.4f)\n", path, score)
+		for _, f := range sel.Files {
+			if !f.Included {
+				break
+			}
+			if node, ok := g.Nodes[g.Paths[f.Path]]; ok {
+				fmt.Printf("   - %s (score: %.3f, PR: %.4f)\n", f.Path, f.Score, node.Score)
+			} else {
+				fmt.Printf("   - %s (score: %.3f)\n", f.Path, f.Score)
+			}
 		}
 		fmt.Println()
 
@@ -92,5 +140,9 @@ func countEdges(g *graph.Graph) int {
 func init() {
 	rootCmd.AddCommand(graphCmd)
 	graphCmd.AddCommand(graphBuildCmd)
+	graphCmd.AddCommand(graphRebuildCmd)
 	graphCmd.AddCommand(graphQueryCmd)
+
+	graphQueryCmd.Flags().Int("limit", 10, "Number of files to select")
+	graphQueryCmd.Flags().Bool("explain", false, "Show how each signal scored every candidate, including files left out")
 }
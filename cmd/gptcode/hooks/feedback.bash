@@ -0,0 +1,44 @@
+chu_mark_suggestion_bash() {
+	local f="$HOME/.gptcode/last_suggestion_cmd"
+	printf "%s" "$READLINE_LINE" > "$f"
+}
+
+bind -x '"\C-g":"chu_mark_suggestion_bash"'
+
+chu_preexec() {
+	local cmd="$1"
+	local sfile="$HOME/.gptcode/last_suggestion_cmd"
+	if [[ -f "$sfile" ]]; then
+		cat "$sfile" > "$HOME/.gptcode/.pending_wrong"
+		printf "%s" "$cmd" > "$HOME/.gptcode/.pending_correct"
+	fi
+}
+trap 'chu_preexec "$BASH_COMMAND"' DEBUG
+
+chu_precmd() {
+	local wrongf="$HOME/.gptcode/.pending_wrong"
+	local correctf="$HOME/.gptcode/.pending_correct"
+	if [[ -f "$wrongf" && -f "$correctf" ]]; then
+		local wrong
+		wrong="$(cat "$wrongf")"
+		local correct
+		correct="$(cat "$correctf")"
+		local files=""
+		if command -v git >/dev/null 2>&1; then
+			if git rev-parse --is-inside-work-tree >/dev/null 2>&1; then
+				files="$(git diff --name-only)"
+			fi
+		fi
+		local -a args=(feedback submit --sentiment=bad --kind=command --source=shell --agent=editor --wrong="$wrong" --correct="$correct")
+{{- if .WithDiff}}
+		args+=(--capture-diff)
+{{- end}}
+		if [[ -n "$files" ]]; then
+			while IFS= read -r f; do args+=(--files "$f"); done <<< "$files"
+		fi
+		gptcode "${args[@]}" >/dev/null 2>&1
+		rm -f "$wrongf" "$correctf" "$HOME/.gptcode/last_suggestion_cmd"
+	fi
+}
+
+PROMPT_COMMAND="chu_precmd; $PROMPT_COMMAND"
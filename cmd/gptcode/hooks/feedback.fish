@@ -0,0 +1,38 @@
+function chufb_mark_suggestion
+	set -l f "$HOME/.gptcode/last_suggestion_cmd"
+	commandline -b > $f
+end
+bind \cg chufb_mark_suggestion
+
+function chufb_preexec --on-event fish_preexec
+	set -l cmd $argv
+	set -l sfile "$HOME/.gptcode/last_suggestion_cmd"
+	if test -f $sfile
+		cat $sfile > "$HOME/.gptcode/.pending_wrong"
+		printf "%s" "$cmd" > "$HOME/.gptcode/.pending_correct"
+	end
+end
+
+function chufb_postexec --on-event fish_postexec
+	set -l wrongf "$HOME/.gptcode/.pending_wrong"
+	set -l correctf "$HOME/.gptcode/.pending_correct"
+	if test -f $wrongf; and test -f $correctf
+		set -l wrong (cat $wrongf)
+		set -l correct (cat $correctf)
+		set -l files
+		if type -q git
+			if git rev-parse --is-inside-work-tree >/dev/null 2>&1
+				set files (git diff --name-only)
+			end
+		end
+		set -l args feedback submit --sentiment=bad --kind=command --source=shell --agent=editor --wrong="$wrong" --correct="$correct"
+{{- if .WithDiff}}
+		set args $args --capture-diff
+{{- end}}
+		for f in $files
+			set args $args --files $f
+		end
+		gptcode $args >/dev/null 2>&1
+		rm -f $wrongf $correctf "$HOME/.gptcode/last_suggestion_cmd"
+	end
+end
@@ -0,0 +1,47 @@
+# gptcode feedback capture
+if (-not (Get-Module PSReadLine)) { return }
+
+$global:GptcodeFeedbackDir = Join-Path $HOME '.gptcode'
+Set-PSReadLineKeyHandler -Chord 'Ctrl+g' -BriefDescription 'GptcodeMarkSuggestion' -Description 'Mark the command line as the suggested command' -ScriptBlock {
+	$line = $null
+	$cursor = $null
+	[Microsoft.PowerShell.PSConsoleReadLine]::GetBufferState([ref]$line, [ref]$cursor)
+	Set-Content -LiteralPath (Join-Path $global:GptcodeFeedbackDir 'last_suggestion_cmd') -Value $line -NoNewline
+}
+
+Set-PSReadLineKeyHandler -Chord 'Enter' -BriefDescription 'GptcodeAcceptLine' -Description 'Run the command line, noting it when a suggestion was marked' -ScriptBlock {
+	$line = $null
+	$cursor = $null
+	[Microsoft.PowerShell.PSConsoleReadLine]::GetBufferState([ref]$line, [ref]$cursor)
+	$sfile = Join-Path $global:GptcodeFeedbackDir 'last_suggestion_cmd'
+	if (Test-Path -LiteralPath $sfile) {
+		Copy-Item -LiteralPath $sfile -Destination (Join-Path $global:GptcodeFeedbackDir '.pending_wrong') -Force
+		Set-Content -LiteralPath (Join-Path $global:GptcodeFeedbackDir '.pending_correct') -Value $line -NoNewline
+	}
+	[Microsoft.PowerShell.PSConsoleReadLine]::AcceptLine()
+}
+
+$global:GptcodeOriginalPrompt = $function:prompt
+function global:prompt {
+	$exitCode = $global:LASTEXITCODE
+	$wrongf = Join-Path $global:GptcodeFeedbackDir '.pending_wrong'
+	$correctf = Join-Path $global:GptcodeFeedbackDir '.pending_correct'
+	if ((Test-Path -LiteralPath $wrongf) -and (Test-Path -LiteralPath $correctf)) {
+		$wrong = Get-Content -LiteralPath $wrongf -Raw
+		$correct = Get-Content -LiteralPath $correctf -Raw
+		$fbArgs = @('feedback', 'submit', '--sentiment=bad', '--kind=command', '--source=shell', '--agent=editor', "--wrong=$wrong", "--correct=$correct")
+		if (Get-Command git -ErrorAction SilentlyContinue) {
+			git rev-parse --is-inside-work-tree *> $null
+			if ($LASTEXITCODE -eq 0) {
+				foreach ($f in (git diff --name-only)) { $fbArgs += @('--files', $f) }
+			}
+		}
+{{- if .WithDiff}}
+		$fbArgs += '--capture-diff'
+{{- end}}
+		gptcode @fbArgs *> $null
+		Remove-Item -LiteralPath $wrongf, $correctf, (Join-Path $global:GptcodeFeedbackDir 'last_suggestion_cmd') -ErrorAction SilentlyContinue
+	}
+	$global:LASTEXITCODE = $exitCode
+	& $global:GptcodeOriginalPrompt
+}
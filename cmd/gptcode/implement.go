@@ -10,6 +10,9 @@ import (
 	"gptcode/internal/config"
 	"gptcode/internal/llm"
 	"gptcode/internal/maestro"
+	"gptcode/internal/modes"
+	"gptcode/internal/output"
+	"gptcode/internal/validation"
 
 	"github.com/spf13/cobra"
 )
@@ -22,10 +25,23 @@ var implementCmd = &cobra.Command{
 By default, prompts for confirmation before each step.
 Use --auto for autonomous execution with automatic verification and retry.
 
+Plans with phases in their front matter, as gptcode plan writes them, are
+implemented one phase at a time. Each phase's verify commands must pass,
+and its status is saved to the plan file as it goes, so running implement
+again resumes at the first phase that is not done or skipped. Plans without
+front matter get their phases from their "## Phase N" sections.
+
+With --commit, or git.commit_phases in .gptcode/config.yml, each phase is
+committed once its verify commands pass, so a long plan leaves one commit
+per phase. A failing phase is left uncommitted in the working tree for
+review, and implement stops there. Committing needs a clean working tree,
+apart from the plan file and a failed phase being resumed.
+
 Examples:
   gptcode implement plan.md
   gptcode implement plan.md --auto
   gptcode implement plan.md --auto --lint
+  gptcode implement plan.md --auto --commit
   gptcode implement plan.md --auto --max-retries 5`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -36,7 +52,7 @@ Examples:
 			return runAutonomousImplement(cmd, planPath)
 		}
 
-		return runInteractiveImplement(planPath)
+		return runInteractiveImplement(planPath, commitPhases(cmd))
 	},
 }
 
@@ -44,7 +60,20 @@ func init() {
 	implementCmd.Flags().Bool("auto", false, "Autonomous execution with verification and retry")
 	implementCmd.Flags().Int("max-retries", 3, "Maximum retry attempts per step (only with --auto)")
 	implementCmd.Flags().Bool("lint", false, "Enable lint verification (only with --auto)")
-	implementCmd.Flags().Bool("resume", false, "Resume from last checkpoint (only with --auto)")
+	implementCmd.Flags().Bool("resume", false, "Resume from last checkpoint (only with --auto, for plans without phases)")
+	implementCmd.Flags().Bool("commit", false, "Commit after each completed phase (default from git.commit_phases in .gptcode/config.yml)")
+}
+
+// commitPhases reports whether implement commits after each phase: the
+// --commit flag when given, else the project's git.commit_phases
+func commitPhases(cmd *cobra.Command) bool {
+	if cmd.Flags().Changed("commit") {
+		commit, _ := cmd.Flags().GetBool("commit")
+		return commit
+	}
+	cwd, _ := os.Getwd()
+	pc, _ := config.LoadProjectConfig(cwd)
+	return pc.Git.CommitPhases
 }
 
 func runAutonomousImplement(cmd *cobra.Command, planPath string) error {
@@ -56,8 +85,12 @@ func runAutonomousImplement(cmd *cobra.Command, planPath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read plan: %w", err)
 	}
+	plan, err := modes.ParsePlanFile(string(planContent))
+	if err != nil {
+		return fmt.Errorf("%s: %w", planPath, err)
+	}
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load setup: %w", err)
 	}
@@ -66,12 +99,7 @@ func runAutonomousImplement(cmd *cobra.Command, planPath string) error {
 	backendCfg := setup.Backend[backendName]
 	cwd, _ := os.Getwd()
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	model := backendCfg.GetModelForAgent("editor")
 
@@ -84,6 +112,10 @@ func runAutonomousImplement(cmd *cobra.Command, planPath string) error {
 		m.Verifiers = append(m.Verifiers, maestro.NewLintVerifier(cwd))
 	}
 
+	if len(plan.Phases) > 0 {
+		return runAutonomousPhases(m, plan, planPath, commitPhases(cmd))
+	}
+
 	if resume {
 		fmt.Fprintln(os.Stderr, "⚙  Attempting to resume from checkpoint...")
 		if err := m.ResumeExecution(context.Background(), string(planContent)); err != nil {
@@ -99,17 +131,140 @@ func runAutonomousImplement(cmd *cobra.Command, planPath string) error {
 		}
 	}
 
-	fmt.Fprintln(os.Stderr, "✓ Execution completed successfully!")
+	fmt.Fprintln(os.Stderr, output.OKf("Execution completed successfully!"))
+	return nil
+}
+
+// runAutonomousPhases implements the plan's phases from the first one not
+// done or skipped, saving each phase's status to the plan file and, with
+// commit, committing each phase once it passes
+func runAutonomousPhases(m *maestro.Maestro, plan *modes.Plan, planPath string, commit bool) error {
+	start := plan.Next()
+	if start < 0 {
+		fmt.Fprintln(os.Stderr, output.OKf("All phases are done or skipped; see gptcode plan status"))
+		return nil
+	}
+	if start > 0 || plan.Phases[start].Status != modes.PhasePending {
+		fmt.Fprintln(os.Stderr, output.Infof("Resuming at phase %d/%d: %s", start+1, len(plan.Phases), plan.Phases[start].Name))
+	}
+
+	if commit {
+		if err := checkCommitPhases(m.CWD, plan, planPath); err != nil {
+			return err
+		}
+	}
+
+	base := m.Verifiers
+	for i := start; i < len(plan.Phases); i++ {
+		phase := &plan.Phases[i]
+		if phase.Status.Finished() {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, " Phase %d/%d: %s\n", i+1, len(plan.Phases), phase.Name)
+		if err := setPhaseStatus(plan, planPath, i, modes.PhaseInProgress); err != nil {
+			return err
+		}
+
+		// The phase's commands are checked with the build and tests, so
+		// their failures are retried like any other
+		m.Verifiers = append([]maestro.Verifier(nil), base...)
+		for _, command := range phase.Verify {
+			m.Verifiers = append(m.Verifiers, maestro.NewCommandVerifier(m.CWD, "verify", &config.ValidationCommand{Command: command}))
+		}
+		if err := m.ExecutePlan(context.Background(), plan.PhaseTask(i)); err != nil {
+			if saveErr := setPhaseStatus(plan, planPath, i, modes.PhaseFailed); saveErr != nil {
+				fmt.Fprintln(os.Stderr, output.Warnf("%v", saveErr))
+			}
+			return phaseFailed(plan, i, commit, err)
+		}
+		if err := finishPhase(m.CWD, plan, planPath, i, commit); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, output.OKf("Execution completed successfully!"))
+	return nil
+}
+
+// checkCommitPhases refuses to commit phases over changes made before
+// implement started, which the first phase's commit would sweep in. The
+// changes left by a phase that failed belong to it when it is resumed.
+func checkCommitPhases(dir string, plan *modes.Plan, planPath string) error {
+	if start := plan.Next(); start < 0 || plan.Phases[start].Status != modes.PhasePending {
+		return nil
+	}
+	changed, err := modes.UncommittedChanges(dir, planPath)
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("the working tree has uncommitted changes (%s); commit or stash them before implementing with --commit", strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// setPhaseStatus records the status of phase i in the plan file
+func setPhaseStatus(plan *modes.Plan, planPath string, i int, status modes.PhaseStatus) error {
+	plan.Phases[i].Status = status
+	if err := plan.Save(planPath); err != nil {
+		return fmt.Errorf("could not save the plan's progress: %w", err)
+	}
 	return nil
 }
 
-func runInteractiveImplement(planPath string) error {
+// finishPhase marks phase i done and, with commit, commits its changes
+// together with the plan file
+func finishPhase(dir string, plan *modes.Plan, planPath string, i int, commit bool) error {
+	if err := setPhaseStatus(plan, planPath, i, modes.PhaseDone); err != nil {
+		return err
+	}
+	if !commit {
+		return nil
+	}
+	if err := modes.CommitPhase(dir, plan, i); err != nil {
+		return fmt.Errorf("%w\nThe phase is marked done; commit its changes yourself before running implement again", err)
+	}
+	fmt.Fprintln(os.Stderr, output.OKf("Committed phase %d: %s", i+1, plan.Phases[i].Name))
+	return nil
+}
+
+// phaseFailed is the error implement stops with when phase i fails,
+// telling where it will resume
+func phaseFailed(plan *modes.Plan, i int, commit bool, err error) error {
+	resume := "Fix it or skip it (gptcode plan skip-phase), then run implement again to resume"
+	if commit {
+		resume = "Earlier phases are committed and its changes are left uncommitted for review.\n" + resume
+	}
+	return fmt.Errorf("phase %d (%s) failed: %w\n%s at phase %d", i+1, plan.Phases[i].Name, err, resume, i+1)
+}
+
+// runPhaseVerify runs the verify commands of a phase, returning the first
+// failure
+func runPhaseVerify(dir string, phase modes.PlanPhase) error {
+	for _, command := range phase.Verify {
+		fmt.Fprintf(os.Stderr, "  $ %s\n", command)
+		res, err := validation.RunCommand(context.Background(), dir, "verify", &config.ValidationCommand{Command: command})
+		if err != nil {
+			return err
+		}
+		if !res.Success {
+			return fmt.Errorf("%q failed (%s):\n%s", command, res.Reason, strings.TrimSpace(res.Output))
+		}
+	}
+	return nil
+}
+
+func runInteractiveImplement(planPath string, commit bool) error {
 	planContent, err := os.ReadFile(planPath)
 	if err != nil {
 		return fmt.Errorf("failed to read plan: %w", err)
 	}
+	plan, err := modes.ParsePlanFile(string(planContent))
+	if err != nil {
+		return fmt.Errorf("%s: %w", planPath, err)
+	}
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load setup: %w", err)
 	}
@@ -118,16 +273,14 @@ func runInteractiveImplement(planPath string) error {
 	backendCfg := setup.Backend[backendName]
 	cwd, _ := os.Getwd()
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	model := backendCfg.GetModelForAgent("editor")
 
 	m := maestro.NewMaestro(provider, cwd, model)
+	if len(plan.Phases) > 0 {
+		return runInteractivePhases(m, plan, planPath, commit)
+	}
 	steps := m.ParsePlan(string(planContent))
 
 	fmt.Fprintf(os.Stderr, "Plan loaded: %d steps\n\n", len(steps))
@@ -173,3 +326,93 @@ func runInteractiveImplement(planPath string) error {
 
 	return nil
 }
+
+// runInteractivePhases asks before each phase not done or skipped, runs
+// its steps and verify commands, and saves its status to the plan file,
+// committing each phase that passes when commit is set
+func runInteractivePhases(m *maestro.Maestro, plan *modes.Plan, planPath string, commit bool) error {
+	start := plan.Next()
+	if start < 0 {
+		fmt.Fprintln(os.Stderr, output.OKf("All phases are done or skipped; see gptcode plan status"))
+		return nil
+	}
+	if commit {
+		if err := checkCommitPhases(m.CWD, plan, planPath); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Plan loaded: %d phases, starting at phase %d\n\n", len(plan.Phases), start+1)
+
+	reader := bufio.NewReader(os.Stdin)
+	for i := start; i < len(plan.Phases); i++ {
+		phase := &plan.Phases[i]
+		if phase.Status.Finished() {
+			continue
+		}
+		task := plan.PhaseTask(i)
+		fmt.Fprintf(os.Stderr, "\033[34m─── Phase %d/%d: %s ───\033[0m\n", i+1, len(plan.Phases), phase.Name)
+		fmt.Fprintf(os.Stderr, "\n%s\n\n", strings.TrimSpace(task))
+
+		fmt.Fprint(os.Stderr, "Execute this phase? [Y/n/q]: ")
+		response, _ := reader.ReadString('\n')
+		response = strings.ToLower(strings.TrimSpace(response))
+
+		if response == "q" || response == "quit" {
+			fmt.Fprintln(os.Stderr, "\n"+output.Warnf("Implementation paused; run implement again to resume"))
+			return nil
+		}
+
+		if response == "n" || response == "no" {
+			if err := setPhaseStatus(plan, planPath, i, modes.PhaseSkipped); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "⊘ Skipped")
+			continue
+		}
+
+		if err := setPhaseStatus(plan, planPath, i, modes.PhaseInProgress); err != nil {
+			return err
+		}
+		var phaseErr error
+		for _, step := range m.ParsePlan(task) {
+			if _, _, err := m.ExecuteStep(context.Background(), step); err != nil {
+				phaseErr = fmt.Errorf("step %s: %w", step.Title, err)
+				break
+			}
+		}
+		if phaseErr == nil {
+			phaseErr = runPhaseVerify(m.CWD, *phase)
+		}
+
+		if phaseErr != nil {
+			if err := setPhaseStatus(plan, planPath, i, modes.PhaseFailed); err != nil {
+				return err
+			}
+			if commit {
+				// Continuing would fold this phase's changes into the next
+				// phase's commit
+				return phaseFailed(plan, i, commit, phaseErr)
+			}
+			fmt.Fprintf(os.Stderr, "\n\033[31m✗ Phase failed: %v\033[0m\n", phaseErr)
+			fmt.Fprint(os.Stderr, "Continue anyway? [y/N]: ")
+			continueResp, _ := reader.ReadString('\n')
+			continueResp = strings.ToLower(strings.TrimSpace(continueResp))
+			if continueResp != "y" && continueResp != "yes" {
+				return fmt.Errorf("implementation stopped at phase %d; run implement again to retry it", i+1)
+			}
+			continue
+		}
+		if err := finishPhase(m.CWD, plan, planPath, i, commit); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "\033[32m✓ Phase completed\033[0m")
+	}
+
+	fmt.Fprintln(os.Stderr, "\n Implementation completed!")
+	fmt.Fprintln(os.Stderr, "\nNext steps:")
+	fmt.Fprintln(os.Stderr, "  • Review changes: git diff")
+	fmt.Fprintln(os.Stderr, "  • Run tests")
+	fmt.Fprintln(os.Stderr, "  • Commit changes")
+
+	return nil
+}
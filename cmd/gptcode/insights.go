@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/feedback"
+	"gptcode/internal/intelligence"
+)
+
+var insightsCmd = &cobra.Command{
+	Use:   "insights",
+	Short: "Report what gptcode has learned about this project",
+	Long: `Analyze the task history, feedback and traces recorded for the current
+project and report patterns across sessions:
+
+  - which kinds of tasks fail most
+  - which files cause the most retries
+  - which models perform best on this project
+  - configuration changes worth making (profile and policy recommendations)
+
+Task history and feedback live in ~/.gptcode; traces are the trace_*.json
+files written in the project directory.
+
+Examples:
+  gptcode insights
+  gptcode insights --json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		project := intelligence.ProjectKey(cwd)
+
+		history, err := intelligence.LoadHistory()
+		if err != nil {
+			return fmt.Errorf("failed to load task history: %w", err)
+		}
+		events, err := feedback.LoadAll()
+		if err != nil {
+			return fmt.Errorf("failed to load feedback: %w", err)
+		}
+		traces, err := intelligence.LoadProjectTraces(project)
+		if err != nil {
+			return fmt.Errorf("failed to load traces: %w", err)
+		}
+
+		setup, _ := config.LoadEffectiveSetup()
+		backendCfg := setup.Backend[setup.Defaults.Backend]
+		opts := intelligence.InsightsOptions{
+			Backend: setup.Defaults.Backend,
+			Profile: setup.Defaults.Profile,
+			Model:   backendCfg.GetModelForAgentWithProfile("editor", setup.Defaults.Profile),
+		}
+
+		insights := intelligence.BuildInsights(project, history, events, traces, opts)
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(insights)
+		}
+		fmt.Print(insights.Markdown())
+		return nil
+	},
+}
+
+func init() {
+	insightsCmd.Flags().Bool("json", false, "Output as JSON")
+	rootCmd.AddCommand(insightsCmd)
+}
@@ -11,36 +11,52 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"gptcode/internal/agents"
 	"gptcode/internal/ci"
 	"gptcode/internal/codebase"
 	"gptcode/internal/config"
+	"gptcode/internal/forge"
 	"gptcode/internal/github"
+	"gptcode/internal/intelligence"
 	"gptcode/internal/langdetect"
 	"gptcode/internal/llm"
+	"gptcode/internal/ml"
 	"gptcode/internal/modes"
+	"gptcode/internal/output"
 	"gptcode/internal/recovery"
 	"gptcode/internal/validation"
 )
 
 var issueCmd = &cobra.Command{
 	Use:   "issue",
-	Short: "GitHub issue management and automation",
-	Long: `Manage GitHub issues and automate issue resolution.
+	Short: "Issue management and automation for GitHub and GitLab",
+	Long: `Manage issues and automate issue resolution.
+
+The forge is detected from the upstream (or origin) remote: GitHub through
+the gh CLI, GitLab (gitlab.com or self-hosted) through the glab CLI. For a
+self-hosted GitLab whose hostname does not mention gitlab, set GITLAB_HOST
+or git.forge: gitlab in .gptcode/config.yml. On GitLab, PR numbers are
+merge request IIDs.
 
 Examples:
   gptcode issue fix 123              Fix issue #123 autonomously
   gptcode issue fix 123 --repo owner/repo  Fix issue from specific repo
-  gptcode issue show 123             Show issue details`,
+  gptcode issue show 123             Show issue details
+  gptcode issue fix-all --label good-first-issue --limit 5
+                                     Fix a batch of issues in worktrees
+  gptcode issue estimate 123 --post  Estimate effort and post it on the issue
+  gptcode issue ready 456 --watch    Mark draft PR #456 ready when CI passes
+  gptcode issue stack create 123     Split the fix into stacked PRs, one per phase`,
 }
 
 var issueFixCmd = &cobra.Command{
 	Use:   "fix <issue-number>",
-	Short: "Autonomously fix a GitHub issue",
-	Long: `Fetch a GitHub issue, create a branch, implement the fix, run tests, 
+	Short: "Autonomously fix an issue",
+	Long: `Fetch an issue, create a branch, implement the fix, run tests, 
 and create a pull request.
 
 This command will:
-1. Fetch issue details from GitHub
+1. Fetch issue details from GitHub or GitLab
 2. Extract requirements
 3. Create a branch (issue-N-description)
 4. Analyze codebase and implement changes
@@ -52,7 +68,8 @@ This command will:
 Examples:
   gptcode issue fix 123                    Fix issue #123
   gptcode issue fix 123 --repo owner/repo Fix from specific repo
-  gptcode issue fix 123 --draft           Create draft PR`,
+  gptcode issue fix 123 --draft           Create draft PR
+  gptcode issue fix 123 --sandbox         Implement in a worktree, merge once validated`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		issueNum, err := strconv.Atoi(args[0])
@@ -60,29 +77,24 @@ Examples:
 			return fmt.Errorf("invalid issue number: %s", args[0])
 		}
 
-		repo, _ := cmd.Flags().GetString("repo")
 		autonomous, _ := cmd.Flags().GetBool("autonomous")
 		findFiles, _ := cmd.Flags().GetBool("find-files")
 
-		if repo == "" {
-			repo = detectGitHubRepo()
-			if repo == "" {
-				return fmt.Errorf("could not detect GitHub repository. Use --repo flag")
-			}
+		workDir, _ := os.Getwd()
+		host, err := detectForge(cmd, workDir)
+		if err != nil {
+			return err
 		}
 
-		fmt.Printf("🔍 Fetching issue #%d from %s...\n\n", issueNum, repo)
-
-		client := github.NewClient(repo)
-		workDir, _ := os.Getwd()
-		client.SetWorkDir(workDir)
+		fmt.Printf("%s\n\n", output.Infof("Fetching issue #%d from %s...", issueNum, host.Repo()))
 
-		issue, err := client.FetchIssue(issueNum)
+		client := gitClient(host, workDir)
+		issue, err := host.FetchIssue(issueNum)
 		if err != nil {
 			return fmt.Errorf("failed to fetch issue: %w", err)
 		}
 
-		fmt.Printf("📋 Issue #%d: %s\n", issue.Number, issue.Title)
+		fmt.Println(output.Infof("Issue #%d: %s", issue.Number, issue.Title))
 		fmt.Printf("   State: %s\n", issue.State)
 		fmt.Printf("   Author: %s\n", issue.Author)
 		if len(issue.Labels) > 0 {
@@ -99,7 +111,7 @@ Examples:
 			fmt.Println()
 		}
 
-		branchName := issue.CreateBranchName()
+		branchName := client.BranchNameFor(issue)
 		fmt.Printf("🌿 Creating branch: %s\n", branchName)
 
 		if err := client.CreateBranch(branchName, ""); err != nil {
@@ -109,22 +121,7 @@ Examples:
 		var relevantFiles []codebase.RelevantFile
 		if findFiles {
 			fmt.Println("\n🔍 Finding relevant files...")
-			setup, _ := config.LoadSetup()
-			backendName := setup.Defaults.Backend
-			if backendName == "" {
-				backendName = "anthropic"
-			}
-			backendCfg := setup.Backend[backendName]
-			var provider llm.Provider
-			if backendCfg.Type == "ollama" {
-				provider = llm.NewOllama(backendCfg.BaseURL)
-			} else {
-				provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-			}
-			queryModel := backendCfg.GetModelForAgent("query")
-			if queryModel == "" {
-				queryModel = backendCfg.DefaultModel
-			}
+			provider, queryModel := llm.NewQueryProvider(nil, "")
 
 			finder, err := codebase.NewFileFinder(provider, workDir, queryModel)
 			if err != nil {
@@ -133,7 +130,7 @@ Examples:
 				issueDesc := fmt.Sprintf("%s\n\n%s", issue.Title, issue.Body)
 				relevantFiles, err = finder.FindRelevantFiles(context.Background(), issueDesc)
 				if err != nil {
-					fmt.Printf("⚠️  Failed to find relevant files: %v\n", err)
+					fmt.Println(output.Warnf("Failed to find relevant files: %v", err))
 				} else if len(relevantFiles) > 0 {
 					fmt.Println("\nRelevant files identified:")
 					for i, file := range relevantFiles {
@@ -153,31 +150,16 @@ Examples:
 			}
 		}
 
-		task := fmt.Sprintf("Fix issue #%d: %s", issue.Number, issue.Title)
-		if len(reqs) > 0 {
-			task += ", Requirements: " + strings.Join(reqs, "; ")
-		}
-		if len(relevantFiles) > 0 {
-			var filePaths []string
-			for _, f := range relevantFiles {
-				filePaths = append(filePaths, f.Path)
-			}
-			task += ". Focus on files: " + strings.Join(filePaths, ", ")
-		}
+		task := issueTask(issue, relevantFiles)
 
 		if autonomous {
-			setup, err := config.LoadSetup()
+			setup, err := config.LoadEffectiveSetup()
 			if err != nil {
 				return fmt.Errorf("failed to load setup: %w", err)
 			}
 			backendName := setup.Defaults.Backend
 			backendCfg := setup.Backend[backendName]
-			var provider llm.Provider
-			if backendCfg.Type == "ollama" {
-				provider = llm.NewOllama(backendCfg.BaseURL)
-			} else {
-				provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-			}
+			provider := llm.NewForBackend(backendName, backendCfg)
 			queryModel := backendCfg.GetModelForAgent("query")
 			if queryModel == "" {
 				queryModel = backendCfg.DefaultModel
@@ -189,11 +171,15 @@ Examples:
 					language = "go"
 				}
 			}
-			exec := modes.NewAutonomousExecutorWithBackend(provider, workDir, queryModel, language, backendName)
-			if err := exec.Execute(context.Background(), task); err != nil {
+			err = runSandboxed(cmd, func() error {
+				dir, _ := os.Getwd()
+				exec := modes.NewAutonomousExecutorWithBackend(provider, dir, queryModel, language, backendName)
+				return exec.Execute(context.Background(), task)
+			})
+			if err != nil {
 				return fmt.Errorf("autonomous implementation failed: %w", err)
 			}
-			fmt.Println("\n[OK] Implementation complete")
+			fmt.Println("\n" + output.OKf("Implementation complete"))
 		} else {
 			fmt.Println("\nImplementation not executed (use --autonomous to enable)")
 		}
@@ -206,6 +192,22 @@ Examples:
 	},
 }
 
+// issueTask builds the autonomous task description for an issue
+func issueTask(issue *github.Issue, relevantFiles []codebase.RelevantFile) string {
+	task := fmt.Sprintf("Fix issue #%d: %s", issue.Number, issue.Title)
+	if reqs := issue.ExtractRequirements(); len(reqs) > 0 {
+		task += ", Requirements: " + strings.Join(reqs, "; ")
+	}
+	if len(relevantFiles) > 0 {
+		var filePaths []string
+		for _, f := range relevantFiles {
+			filePaths = append(filePaths, f.Path)
+		}
+		task += ". Focus on files: " + strings.Join(filePaths, ", ")
+	}
+	return task
+}
+
 var issueShowCmd = &cobra.Command{
 	Use:   "show <issue-number>",
 	Short: "Show GitHub issue details",
@@ -216,16 +218,13 @@ var issueShowCmd = &cobra.Command{
 			return fmt.Errorf("invalid issue number: %s", args[0])
 		}
 
-		repo, _ := cmd.Flags().GetString("repo")
-		if repo == "" {
-			repo = detectGitHubRepo()
-			if repo == "" {
-				return fmt.Errorf("could not detect GitHub repository. Use --repo flag")
-			}
+		workDir, _ := os.Getwd()
+		host, err := detectForge(cmd, workDir)
+		if err != nil {
+			return err
 		}
 
-		client := github.NewClient(repo)
-		issue, err := client.FetchIssue(issueNum)
+		issue, err := host.FetchIssue(issueNum)
 		if err != nil {
 			return fmt.Errorf("failed to fetch issue: %w", err)
 		}
@@ -267,10 +266,14 @@ var issueCommitCmd = &cobra.Command{
 	Long: `Commit staged changes with proper issue reference and run validation.
 
 This will:
-1. Commit changes with "Closes #N" reference
-2. Run tests (unless --skip-tests)
-3. Run linters (unless --skip-lint)
-4. Report validation results`,
+1. Refuse to commit if tests were deleted, skipped or lost assertions
+   (unless --allow-test-changes)
+2. Refuse to commit likely secrets (API keys, private keys, tokens) unless
+   --allow-secrets; --redact-secrets has the editor move them out first
+3. Commit changes with "Closes #N" reference
+4. Run tests (unless --skip-tests)
+5. Run linters (unless --skip-lint)
+6. Report validation results`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		issueNum, err := strconv.Atoi(args[0])
@@ -286,12 +289,11 @@ This will:
 		minCoverage, _ := cmd.Flags().GetFloat64("min-coverage")
 		securityScan, _ := cmd.Flags().GetBool("security-scan")
 		autoFix, _ := cmd.Flags().GetBool("auto-fix")
+		allowTestChanges, _ := cmd.Flags().GetBool("allow-test-changes")
+		allowSecrets, _ := cmd.Flags().GetBool("allow-secrets")
+		redact, _ := cmd.Flags().GetBool("redact-secrets")
 		repo, _ := cmd.Flags().GetString("repo")
 
-		if repo == "" {
-			repo = detectGitHubRepo()
-		}
-
 		if message == "" {
 			message = fmt.Sprintf("Fix issue #%d", issueNum)
 		}
@@ -300,6 +302,25 @@ This will:
 		client := github.NewClient(repo)
 		client.SetWorkDir(workDir)
 
+		if !allowTestChanges && !validation.AllowsTestChanges(message) {
+			if err := guardTests(workDir, checkCoverage); err != nil {
+				return err
+			}
+		}
+
+		// Everything is committed, untracked files included
+		scanner := validation.NewSecretScanner(workDir)
+		var redactFn func([]validation.SecretFinding) error
+		if redact {
+			redactFn = func(findings []validation.SecretFinding) error {
+				_, err := redactSecrets(workDir, findings)
+				return err
+			}
+		}
+		if err := guardSecrets(scanner.WorkingTree, allowSecrets, redactFn); err != nil {
+			return err
+		}
+
 		fmt.Printf("💾 Committing changes for issue #%d...\n", issueNum)
 
 		err = client.CommitChanges(github.CommitOptions{
@@ -436,6 +457,110 @@ This will:
 	},
 }
 
+var issueEstimateCmd = &cobra.Command{
+	Use:   "estimate <issue-number>",
+	Short: "Estimate effort and risk for an issue",
+	Long: `Estimate the effort to fix an issue by combining the complexity
+classifier, similar past tasks from the execution history (attempts and
+durations), and the files likely to be impacted.
+
+With --post, the estimate is posted as an issue comment.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		issueNum, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid issue number: %s", args[0])
+		}
+
+		post, _ := cmd.Flags().GetBool("post")
+		findFiles, _ := cmd.Flags().GetBool("find-files")
+
+		workDir, _ := os.Getwd()
+		host, err := detectForge(cmd, workDir)
+		if err != nil {
+			return err
+		}
+
+		issue, err := host.FetchIssue(issueNum)
+		if err != nil {
+			return fmt.Errorf("failed to fetch issue: %w", err)
+		}
+		fmt.Println(output.Infof("Issue #%d: %s", issue.Number, issue.Title))
+
+		issueDesc := fmt.Sprintf("%s\n\n%s", issue.Title, issue.Body)
+
+		complexity, confidence := "complex", 0.0
+		if p, err := ml.LoadEmbedded("complexity_detection"); err == nil {
+			var probs map[string]float64
+			complexity, probs = p.Predict(issueDesc)
+			confidence = probs[complexity]
+		}
+
+		history, err := intelligence.LoadHistory()
+		if err != nil {
+			fmt.Println(output.Warnf("Could not read execution history: %v", err))
+		}
+		similar := intelligence.FindSimilarTasks(history, issue.Title, 0.2, 10)
+
+		var impactFiles []string
+		if findFiles {
+			fmt.Println(output.Infof("Analyzing impacted files..."))
+			provider, queryModel := llm.NewQueryProvider(nil, "")
+			if finder, err := codebase.NewFileFinder(provider, workDir, queryModel); err == nil {
+				if files, err := finder.FindRelevantFiles(context.Background(), issueDesc); err == nil {
+					for _, f := range files {
+						impactFiles = append(impactFiles, f.Path)
+					}
+				} else {
+					fmt.Println(output.Warnf("Failed to find relevant files: %v", err))
+				}
+			}
+		}
+
+		estimate := intelligence.BuildEstimate(complexity, confidence, similar, impactFiles)
+		body := estimate.Markdown()
+		fmt.Println()
+		fmt.Println(body)
+
+		if post {
+			if err := host.CommentOnIssue(issueNum, body); err != nil {
+				return err
+			}
+			fmt.Println(output.OKf("Estimate posted to issue #%d", issueNum))
+		}
+		return nil
+	},
+}
+
+// issueQueryProvider returns the default backend's provider and query model
+// prBaseBranch is the branch PRs pushed to remote target: the default
+// branch of upstream when it exists, else of remote, as last fetched; main
+// when neither is known.
+func prBaseBranch(workDir, remote string) string {
+	remotes := []string{remote}
+	if _, ok := forge.Remotes(workDir)["upstream"]; ok {
+		remotes = []string{"upstream", remote}
+	}
+	for _, r := range remotes {
+		cmd := exec.Command("git", "symbolic-ref", "--short", "refs/remotes/"+r+"/HEAD")
+		cmd.Dir = workDir
+		if out, err := cmd.Output(); err == nil {
+			return strings.TrimPrefix(strings.TrimSpace(string(out)), r+"/")
+		}
+	}
+	return "main"
+}
+
+// prBody writes the PR body for issue from the branch's commits and diff,
+// in the repository's PR template when it has one.
+func prBody(client *github.Client, workDir, base string, issue *github.Issue) string {
+	body, err := describePR(client, workDir, base, issue)
+	if err != nil {
+		fmt.Println(output.Warnf("The PR description was not written by the model: %v", err))
+	}
+	return body
+}
+
 var issuePushCmd = &cobra.Command{
 	Use:   "push <issue-number>",
 	Short: "Push branch and create pull request",
@@ -446,29 +571,46 @@ var issuePushCmd = &cobra.Command{
 			return fmt.Errorf("invalid issue number: %s", args[0])
 		}
 
-		repo, _ := cmd.Flags().GetString("repo")
 		draft, _ := cmd.Flags().GetBool("draft")
-
-		if repo == "" {
-			repo = detectGitHubRepo()
-			if repo == "" {
-				return fmt.Errorf("could not detect GitHub repository")
-			}
-		}
+		remote, _ := cmd.Flags().GetString("remote")
+		autoReady, _ := cmd.Flags().GetBool("auto-ready")
+		assign, _ := cmd.Flags().GetBool("assign")
 
 		workDir, _ := os.Getwd()
-		client := github.NewClient(repo)
-		client.SetWorkDir(workDir)
+		if pc, _ := config.LoadProjectConfig(workDir); pc.Git.DraftFirst && !cmd.Flags().Changed("auto-ready") {
+			autoReady = true
+		}
+		if autoReady {
+			draft = true
+		}
+		target, err := forge.ResolvePushTarget(workDir, remote)
+		if err != nil {
+			return err
+		}
+		host, err := detectForge(cmd, workDir)
+		if err != nil {
+			return err
+		}
+		target.UpstreamRepo = host.Repo()
 
-		issue, err := client.FetchIssue(issueNum)
+		client := gitClient(host, workDir)
+		issue, err := host.FetchIssue(issueNum)
 		if err != nil {
 			return fmt.Errorf("failed to fetch issue: %w", err)
 		}
 
-		branchName := issue.CreateBranchName()
+		branchName := client.BranchNameFor(issue)
+		base := prBaseBranch(workDir, target.Remote)
 
-		fmt.Printf("🚀 Pushing branch %s...\n", branchName)
-		if err := client.PushBranch(branchName); err != nil {
+		allowSecrets, _ := cmd.Flags().GetBool("allow-secrets")
+		if err := guardSecrets(func() ([]validation.SecretFinding, error) {
+			return validation.NewSecretScanner(workDir).Since(base)
+		}, allowSecrets, nil); err != nil {
+			return err
+		}
+
+		fmt.Println(output.Infof("Pushing branch %s to %s (%s)...", branchName, target.Remote, target.Repo))
+		if err := client.PushBranchTo(target.Remote, branchName); err != nil {
 			return fmt.Errorf("failed to push branch: %w", err)
 		}
 
@@ -476,16 +618,33 @@ var issuePushCmd = &cobra.Command{
 
 		fmt.Println("\n📝 Creating pull request...")
 
-		changes := []string{"Implemented fix for issue"}
-		prBody := github.GeneratePRBody(issue, changes)
+		body := prBody(client, workDir, base, issue)
+
+		var reviewers []string
+		suggestions := client.SuggestReviewers(client.ChangedFiles(base), []string{host.CurrentUser()}, 3)
+		if len(suggestions) > 0 {
+			fmt.Println(output.Infof("Suggested reviewers:"))
+			for _, s := range suggestions {
+				fmt.Printf("   @%s (%s)\n", s.Login, s.Reason)
+				if assign {
+					reviewers = append(reviewers, s.Login)
+				}
+			}
+			if !assign {
+				fmt.Println("   Use --assign to request their review")
+			}
+		}
 
-		pr, err := client.CreatePR(github.PRCreateOptions{
+		pr, err := host.CreatePR(github.PRCreateOptions{
 			Title:      fmt.Sprintf("Fix: %s", issue.Title),
-			Body:       prBody,
+			Body:       body,
 			HeadBranch: branchName,
-			BaseBranch: "main",
+			HeadOwner:  target.HeadOwner(),
+			HeadRepo:   target.HeadRepo(),
+			BaseBranch: base,
 			IsDraft:    draft,
 			Labels:     issue.Labels,
+			Reviewers:  reviewers,
 		})
 
 		if err != nil {
@@ -495,48 +654,230 @@ var issuePushCmd = &cobra.Command{
 		fmt.Printf("✅ Pull request created: %s\n", pr.URL)
 		fmt.Printf("   PR #%d: %s\n", pr.Number, pr.Title)
 
+		if autoReady {
+			fmt.Println("\n" + output.Infof("Draft PR will be marked ready once CI passes"))
+			return markPRReady(host, ciHandler(host, workDir, nil, ""), pr.Number, true, 30)
+		}
+		if draft {
+			fmt.Printf("\nWhen CI is green: gptcode issue ready %d\n", pr.Number)
+		}
+
 		return nil
 	},
 }
 
-func detectGitHubRepo() string {
-	cmd := exec.Command("git", "remote", "get-url", "origin")
-	output, err := cmd.Output()
+var issueReadyCmd = &cobra.Command{
+	Use:   "ready <pr-number>",
+	Short: "Mark a draft PR ready for review once CI passes",
+	Long: `Check CI on a draft PR and, once every check passes, post a diff
+summary comment and mark the PR ready for review.
+
+With --watch, waits for pending checks instead of failing. Set
+git.draft_first in .gptcode/config.yml to have issue push open draft PRs
+and run this automatically.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		prNumber, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid PR number: %s", args[0])
+		}
+
+		watch, _ := cmd.Flags().GetBool("watch")
+		timeout, _ := cmd.Flags().GetInt("timeout")
+
+		workDir, _ := os.Getwd()
+		host, err := detectForge(cmd, workDir)
+		if err != nil {
+			return err
+		}
+		return markPRReady(host, ciHandler(host, workDir, nil, ""), prNumber, watch, timeout)
+	},
+}
+
+// markPRReady waits for (or checks) CI, posts the diff summary and takes the
+// PR out of draft. A failing or pending check leaves the PR in draft.
+func markPRReady(host forge.Forge, handler *ci.Handler, prNumber int, watch bool, timeoutMinutes int) error {
+	if watch {
+		if err := handler.WaitForCI(prNumber, timeoutMinutes); err != nil {
+			return fmt.Errorf("PR #%d left in draft: %w", prNumber, err)
+		}
+	} else {
+		statuses, err := handler.CheckPRStatus(prNumber)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			if status.Conclusion != "success" {
+				return fmt.Errorf("PR #%d left in draft: check %q is %s (use --watch to wait)", prNumber, status.Name, status.Conclusion)
+			}
+		}
+		fmt.Println(output.OKf("All CI checks passed"))
+	}
+
+	summary, err := host.FetchPRDiffSummary(prNumber)
 	if err != nil {
-		return ""
+		return err
+	}
+	if err := host.CommentOnPR(prNumber, summary.Markdown()); err != nil {
+		return err
+	}
+	fmt.Println(output.Infof("Posted diff summary (%d files, +%d/-%d)", summary.ChangedFiles, summary.Additions, summary.Deletions))
+
+	if err := host.MarkPRReady(prNumber); err != nil {
+		return err
 	}
+	fmt.Println(output.OKf("PR #%d is ready for review", prNumber))
+	return nil
+}
+
+// detectForge returns the forge issues and PRs live on: --repo when set,
+// otherwise the "upstream" remote in a fork checkout, or "origin".
+func detectForge(cmd *cobra.Command, workDir string) (forge.Forge, error) {
+	repo, _ := cmd.Flags().GetString("repo")
+	return forge.Detect(workDir, repo)
+}
+
+// gitClient runs the branch, commit and push steps in workDir, which are
+// the same whatever the forge.
+func gitClient(host forge.Forge, workDir string) *github.Client {
+	client := github.NewClient(host.Repo())
+	client.SetWorkDir(workDir)
+	return client
+}
+
+// ciHandler reads CI status and logs from host.
+func ciHandler(host forge.Forge, workDir string, provider llm.Provider, model string) *ci.Handler {
+	handler := ci.NewHandler(host.Repo(), workDir, provider, model)
+	handler.SetChecks(host)
+	return handler
+}
 
-	url := strings.TrimSpace(string(output))
+// guardTests fails when the uncommitted changes weaken the tests, unless
+// defaults.test_guard is warn or off.
+func guardTests(workDir string, coverage bool) error {
+	setup, _ := config.LoadEffectiveSetup()
+	mode := setup.Defaults.TestGuard
+	if mode == "off" {
+		return nil
+	}
+	guard := validation.NewTestGuard(workDir)
+	guard.Coverage = coverage
+	res, err := guard.Check()
+	if err != nil {
+		fmt.Println(output.Warnf("Test guard skipped: %v", err))
+		return nil
+	}
+	if !res.Weakened {
+		return nil
+	}
+	fmt.Println(output.Failf("%s", res.Summary()))
+	if mode == "warn" {
+		return nil
+	}
+	return fmt.Errorf("tests were weakened; restore them or pass --allow-test-changes")
+}
 
-	if strings.Contains(url, "github.com") {
-		parts := strings.Split(url, "github.com")
-		if len(parts) < 2 {
-			return ""
+// guardSecrets stops a commit or a push when scan finds likely secrets in
+// what it would publish, unless allow or defaults.secret_scan says
+// otherwise. A scan that fails stops it too. With redact, the secrets are
+// first handed to it to remove and scan runs again.
+func guardSecrets(scan func() ([]validation.SecretFinding, error), allow bool, redact func([]validation.SecretFinding) error) error {
+	setup, _ := config.LoadEffectiveSetup()
+	mode := setup.Defaults.SecretScan
+	if mode == "off" || allow {
+		return nil
+	}
+	findings, err := scan()
+	if err != nil {
+		if mode == "warn" {
+			fmt.Println(output.Warnf("Secret scan skipped: %v", err))
+			return nil
 		}
+		return fmt.Errorf("could not scan for secrets: %w; fix that or pass --allow-secrets", err)
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	fmt.Println(output.Infof("Likely secrets:\n%s", validation.SecretsSummary(findings)))
+
+	if redact != nil {
+		fmt.Println("\n" + output.Infof("Redacting secrets..."))
+		if err := redact(findings); err != nil {
+			fmt.Println(output.Warnf("Redaction failed: %v", err))
+		} else if findings, err = scan(); err == nil && len(findings) == 0 {
+			fmt.Println(output.OKf("Secrets redacted"))
+			return nil
+		} else if len(findings) > 0 {
+			fmt.Println(output.Failf("Still found:\n%s", validation.SecretsSummary(findings)))
+		}
+	}
 
-		repo := strings.Trim(parts[1], ":/")
-		repo = strings.TrimSuffix(repo, ".git")
+	if mode == "warn" {
+		return nil
+	}
+	return fmt.Errorf("likely secrets found; remove them, mark false positives with a %q comment, or pass --allow-secrets", validation.AllowSecretMarker)
+}
+
+// redactSecrets has the editor agent replace each file's secrets with
+// environment lookups and returns the files it changed
+func redactSecrets(workDir string, findings []validation.SecretFinding) ([]string, error) {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return nil, err
+	}
+	backendName := setup.Defaults.Backend
+	backendCfg := setup.Backend[backendName]
+	provider := llm.NewForBackend(backendName, backendCfg)
+	model := backendCfg.GetModelForAgent("editor")
+	if model == "" {
+		model = backendCfg.DefaultModel
+	}
 
-		return repo
+	byFile := map[string][]validation.SecretFinding{}
+	var files []string
+	for _, f := range findings {
+		if _, ok := byFile[f.File]; !ok {
+			files = append(files, f.File)
+		}
+		byFile[f.File] = append(byFile[f.File], f)
 	}
 
-	return ""
+	var changed []string
+	for _, file := range files {
+		var lines []string
+		for _, f := range byFile[file] {
+			lines = append(lines, fmt.Sprintf("- line %d: %s (starts with %s)", f.Line, f.Rule, f.Redacted()))
+		}
+		prompt := fmt.Sprintf(`%s contains hard-coded secrets that must not be committed:
+
+%s
+
+Replace each one with a lookup of an environment variable named after what
+it is (e.g. STRIPE_API_KEY), or with the project's existing configuration
+mechanism when there is one. Remove private key material from the file
+entirely. Do not write the secrets anywhere else, and edit only this file.`, file, strings.Join(lines, "\n"))
+
+		editor := agents.NewEditorWithFileValidation(provider, workDir, model, []string{file})
+		_, modified, err := editor.Execute(context.Background(), []llm.ChatMessage{{Role: "user", Content: prompt}}, nil)
+		if err != nil {
+			return changed, fmt.Errorf("%s: %w", file, err)
+		}
+		if len(modified) > 0 {
+			changed = append(changed, file)
+		}
+	}
+	return changed, nil
 }
 
 func attemptTestFix(workDir string, testResult *validation.TestResult) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return err
 	}
 
 	backendName := setup.Defaults.Backend
 	backendCfg := setup.Backend[backendName]
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	model := backendCfg.GetModelForAgent("editor")
 	if model == "" {
@@ -557,19 +898,14 @@ func attemptTestFix(workDir string, testResult *validation.TestResult) error {
 }
 
 func attemptLintFix(workDir string, lintResults []*validation.LintResult) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return err
 	}
 
 	backendName := setup.Defaults.Backend
 	backendCfg := setup.Backend[backendName]
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	model := backendCfg.GetModelForAgent("editor")
 	if model == "" {
@@ -606,21 +942,16 @@ This will:
 			return fmt.Errorf("invalid PR number: %s", args[0])
 		}
 
-		repo, _ := cmd.Flags().GetString("repo")
-		if repo == "" {
-			repo = detectGitHubRepo()
-			if repo == "" {
-				return fmt.Errorf("could not detect GitHub repository. Use --repo flag")
-			}
+		workDir, _ := os.Getwd()
+		host, err := detectForge(cmd, workDir)
+		if err != nil {
+			return err
 		}
 
 		fmt.Printf("🔍 Fetching review comments for PR #%d...\n", prNumber)
 
-		client := github.NewClient(repo)
-		workDir, _ := os.Getwd()
-		client.SetWorkDir(workDir)
-
-		comments, err := client.GetUnresolvedComments(prNumber)
+		client := gitClient(host, workDir)
+		comments, err := host.GetUnresolvedComments(prNumber)
 		if err != nil {
 			return fmt.Errorf("failed to fetch comments: %w", err)
 		}
@@ -636,19 +967,14 @@ This will:
 			fmt.Printf("   %s\n\n", comment.Body)
 		}
 
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
 
 		backendName := setup.Defaults.Backend
 		backendCfg := setup.Backend[backendName]
-		var provider llm.Provider
-		if backendCfg.Type == "ollama" {
-			provider = llm.NewOllama(backendCfg.BaseURL)
-		} else {
-			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-		}
+		provider := llm.NewForBackend(backendName, backendCfg)
 		queryModel := backendCfg.GetModelForAgent("query")
 		if queryModel == "" {
 			queryModel = backendCfg.DefaultModel
@@ -709,7 +1035,7 @@ Please read the file, understand the context, and implement the requested change
 		}
 
 		fmt.Printf("\n✨ Successfully addressed %d review comment(s)\n", len(comments))
-		fmt.Printf("   View PR: https://github.com/%s/pull/%d\n", repo, prNumber)
+		fmt.Printf("   View PR: %s\n", host.PRURL(prNumber))
 
 		return nil
 	},
@@ -732,36 +1058,27 @@ This will:
 			return fmt.Errorf("invalid PR number: %s", args[0])
 		}
 
-		repo, _ := cmd.Flags().GetString("repo")
-		if repo == "" {
-			repo = detectGitHubRepo()
-			if repo == "" {
-				return fmt.Errorf("could not detect GitHub repository. Use --repo flag")
-			}
-		}
-
 		workDir, _ := os.Getwd()
+		host, err := detectForge(cmd, workDir)
+		if err != nil {
+			return err
+		}
 
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
 
 		backendName := setup.Defaults.Backend
 		backendCfg := setup.Backend[backendName]
-		var provider llm.Provider
-		if backendCfg.Type == "ollama" {
-			provider = llm.NewOllama(backendCfg.BaseURL)
-		} else {
-			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-		}
+		provider := llm.NewForBackend(backendName, backendCfg)
 
 		model := backendCfg.GetModelForAgent("editor")
 		if model == "" {
 			model = backendCfg.DefaultModel
 		}
 
-		handler := ci.NewHandler(repo, workDir, provider, model)
+		handler := ciHandler(host, workDir, provider, model)
 
 		fmt.Printf("🔍 Checking CI status for PR #%d...\n", prNumber)
 
@@ -815,9 +1132,7 @@ This will:
 
 		fmt.Println("\n📦 Committing fix...")
 
-		client := github.NewClient(repo)
-		client.SetWorkDir(workDir)
-
+		client := gitClient(host, workDir)
 		err = client.CommitChanges(github.CommitOptions{
 			Message:  fmt.Sprintf("Fix CI failure on PR #%d", prNumber),
 			AllFiles: true,
@@ -843,7 +1158,7 @@ This will:
 		}
 
 		fmt.Println("\n✅ CI fix pushed")
-		fmt.Printf("   View PR: https://github.com/%s/pull/%d\n", repo, prNumber)
+		fmt.Printf("   View PR: %s\n", host.PRURL(prNumber))
 		fmt.Println("\n⏳ CI checks will run again automatically")
 
 		return nil
@@ -857,15 +1172,21 @@ func init() {
 	issueCmd.AddCommand(issuePushCmd)
 	issueCmd.AddCommand(issueReviewCmd)
 	issueCmd.AddCommand(issueCICmd)
+	issueCmd.AddCommand(issueReadyCmd)
+	issueCmd.AddCommand(issueEstimateCmd)
 
-	issueFixCmd.Flags().String("repo", "", "GitHub repository (owner/repo)")
+	issueFixCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
 	issueFixCmd.Flags().Bool("draft", false, "Create draft pull request")
 	issueFixCmd.Flags().Bool("skip-tests", false, "Skip running tests")
 	issueFixCmd.Flags().Bool("skip-lint", false, "Skip running linters")
 	issueFixCmd.Flags().Bool("autonomous", true, "Execute implementation autonomously")
 	issueFixCmd.Flags().Bool("find-files", true, "Find relevant files before implementation")
 
-	issueShowCmd.Flags().String("repo", "", "GitHub repository (owner/repo)")
+	issueShowCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
+
+	issueEstimateCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
+	issueEstimateCmd.Flags().Bool("post", false, "Post the estimate as an issue comment")
+	issueEstimateCmd.Flags().Bool("find-files", true, "Use the file finder to analyze impacted files")
 
 	issueCommitCmd.Flags().String("message", "", "Commit message")
 	issueCommitCmd.Flags().Bool("skip-tests", false, "Skip running tests")
@@ -875,12 +1196,23 @@ func init() {
 	issueCommitCmd.Flags().Float64("min-coverage", 0.0, "Minimum coverage threshold (0-100)")
 	issueCommitCmd.Flags().Bool("security-scan", false, "Run security vulnerability scan")
 	issueCommitCmd.Flags().Bool("auto-fix", true, "Automatically fix test/lint failures")
-	issueCommitCmd.Flags().String("repo", "", "GitHub repository (owner/repo)")
+	issueCommitCmd.Flags().Bool("allow-test-changes", false, "Commit even if tests were removed, skipped or lost assertions")
+	issueCommitCmd.Flags().Bool("allow-secrets", false, "Commit even if the changes contain likely secrets")
+	issueCommitCmd.Flags().Bool("redact-secrets", false, "Have the editor replace likely secrets with environment lookups before committing")
+	issueCommitCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
 
-	issuePushCmd.Flags().String("repo", "", "GitHub repository (owner/repo)")
+	issuePushCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
 	issuePushCmd.Flags().Bool("draft", false, "Create draft pull request")
+	issuePushCmd.Flags().Bool("assign", false, "Request review from suggested reviewers (CODEOWNERS and file history)")
+	issuePushCmd.Flags().Bool("auto-ready", false, "Open a draft PR and mark it ready once CI passes (default from git.draft_first)")
+	issuePushCmd.Flags().String("remote", "", "Remote to push the branch to (default: origin; PRs target upstream when it exists)")
+	issuePushCmd.Flags().Bool("allow-secrets", false, "Push even if the branch's commits contain likely secrets")
+
+	issueReviewCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
 
-	issueReviewCmd.Flags().String("repo", "", "GitHub repository (owner/repo)")
+	issueCICmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
 
-	issueCICmd.Flags().String("repo", "", "GitHub repository (owner/repo)")
+	issueReadyCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
+	issueReadyCmd.Flags().Bool("watch", false, "Wait for pending CI checks to finish")
+	issueReadyCmd.Flags().Int("timeout", 30, "Maximum minutes to wait with --watch")
 }
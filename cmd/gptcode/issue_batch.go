@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/forge"
+	"gptcode/internal/github"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+	"gptcode/internal/modes"
+	"gptcode/internal/output"
+)
+
+var issueFixAllCmd = &cobra.Command{
+	Use:   "fix-all",
+	Short: "Fix every issue matching a label or milestone",
+	Long: `Fix a batch of issues, each in its own git worktree, opening one PR per
+issue and printing a batch report at the end.
+
+Issues run through a bounded queue: at most --concurrency at a time
+(default from batch.max_concurrency in .gptcode/config.yml, else 1). No new
+issue is started once --time-budget is spent.
+
+Examples:
+  gptcode issue fix-all --label good-first-issue --limit 5
+  gptcode issue fix-all --milestone v1.2 --concurrency 2 --draft
+  gptcode issue fix-all --label bug --time-budget 1h --report report.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		labels, _ := cmd.Flags().GetStringSlice("label")
+		milestone, _ := cmd.Flags().GetString("milestone")
+		limit, _ := cmd.Flags().GetInt("limit")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		draft, _ := cmd.Flags().GetBool("draft")
+		base, _ := cmd.Flags().GetString("base")
+		timeBudget, _ := cmd.Flags().GetDuration("time-budget")
+		reportPath, _ := cmd.Flags().GetString("report")
+
+		if len(labels) == 0 && milestone == "" {
+			return fmt.Errorf("specify --label or --milestone")
+		}
+		workDir, _ := os.Getwd()
+		host, err := detectForge(cmd, workDir)
+		if err != nil {
+			return err
+		}
+		if !cmd.Flags().Changed("concurrency") {
+			if pc, _ := config.LoadProjectConfig(workDir); pc.Batch.MaxConcurrency > 0 {
+				concurrency = pc.Batch.MaxConcurrency
+			}
+		}
+		if concurrency < 1 {
+			concurrency = 1
+		}
+
+		target, err := forge.ResolvePushTarget(workDir, "")
+		if err != nil {
+			return err
+		}
+		target.UpstreamRepo = host.Repo()
+
+		issues, err := host.ListIssues(github.IssueFilter{Labels: labels, Milestone: milestone, Limit: limit})
+		if err != nil {
+			return err
+		}
+		if len(issues) == 0 {
+			fmt.Println("No matching issues")
+			return nil
+		}
+
+		fmt.Println(output.Infof("%d issue(s) queued, %d at a time", len(issues), concurrency))
+
+		batch := &issueBatch{
+			host:     host,
+			workDir:  workDir,
+			base:     base,
+			draft:    draft,
+			target:   target,
+			deadline: deadlineFrom(timeBudget),
+		}
+		results := batch.run(issues, concurrency)
+
+		printBatchReport(results)
+		if reportPath != "" {
+			data, err := json.MarshalIndent(results, "", "  ")
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(reportPath, data, 0644); err != nil {
+				return fmt.Errorf("failed to write report: %w", err)
+			}
+			fmt.Printf("\nReport written to %s\n", reportPath)
+		}
+
+		for _, r := range results {
+			if r.Status == batchFailed {
+				return fmt.Errorf("%d of %d issues failed", countStatus(results, batchFailed), len(results))
+			}
+		}
+		return nil
+	},
+}
+
+const (
+	batchFixed   = "fixed"
+	batchFailed  = "failed"
+	batchSkipped = "skipped"
+)
+
+// batchResult is one issue's outcome in a fix-all run
+type batchResult struct {
+	Issue    int    `json:"issue"`
+	Title    string `json:"title"`
+	Status   string `json:"status"`
+	Branch   string `json:"branch,omitempty"`
+	PR       string `json:"pr,omitempty"`
+	Worktree string `json:"worktree,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type issueBatch struct {
+	host     forge.Forge
+	workDir  string
+	base     string
+	draft    bool
+	target   github.PushTarget
+	deadline time.Time
+}
+
+func deadlineFrom(budget time.Duration) time.Time {
+	if budget <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(budget)
+}
+
+// run works through issues with at most concurrency workers, keeping the
+// results in issue order.
+func (b *issueBatch) run(issues []*github.Issue, concurrency int) []batchResult {
+	results := make([]batchResult, len(issues))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				issue := issues[i]
+				if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+					results[i] = batchResult{Issue: issue.Number, Title: issue.Title, Status: batchSkipped, Error: "time budget exhausted"}
+					continue
+				}
+				fmt.Printf("\n▶ #%d %s\n", issue.Number, issue.Title)
+				results[i] = b.fix(issue)
+				fmt.Printf("■ #%d %s\n", issue.Number, results[i].Status)
+			}
+		}()
+	}
+	for i := range issues {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+// fix runs the issue pipeline in a dedicated worktree: implement, commit,
+// push and open a PR. Failed worktrees are kept for inspection.
+func (b *issueBatch) fix(issue *github.Issue) batchResult {
+	start := time.Now()
+	result := batchResult{Issue: issue.Number, Title: issue.Title}
+	fail := func(err error) batchResult {
+		result.Status = batchFailed
+		result.Error = err.Error()
+		result.Duration = time.Since(start).Round(time.Second).String()
+		return result
+	}
+
+	origin := gitClient(b.host, b.workDir)
+	result.Branch = origin.BranchNameFor(issue)
+	result.Worktree = filepath.Join(os.TempDir(), "gptcode-worktrees",
+		strings.ReplaceAll(b.host.Repo(), "/", "-")+fmt.Sprintf("-issue-%d", issue.Number))
+
+	// A failed earlier run keeps its worktree, with the branch checked out
+	_ = origin.RemoveWorktree(result.Worktree)
+	if err := origin.AddWorktree(result.Worktree, result.Branch, b.base); err != nil {
+		return fail(err)
+	}
+
+	client := gitClient(b.host, result.Worktree)
+
+	executor, err := worktreeExecutor(result.Worktree)
+	if err != nil {
+		return fail(err)
+	}
+	if err := executor.Execute(context.Background(), issueTask(issue, nil)); err != nil {
+		return fail(fmt.Errorf("implementation failed: %w", err))
+	}
+
+	if err := client.CommitChanges(github.CommitOptions{
+		Message:     fmt.Sprintf("Fix issue #%d: %s", issue.Number, issue.Title),
+		IssueNumber: issue.Number,
+		AllFiles:    true,
+	}); err != nil {
+		return fail(err)
+	}
+	if err := client.PushBranchTo(b.target.Remote, result.Branch); err != nil {
+		return fail(err)
+	}
+
+	pr, err := b.host.CreatePR(github.PRCreateOptions{
+		Title:      fmt.Sprintf("Fix: %s", issue.Title),
+		Body:       prBody(client, result.Worktree, b.base, issue),
+		HeadBranch: result.Branch,
+		HeadOwner:  b.target.HeadOwner(),
+		HeadRepo:   b.target.HeadRepo(),
+		BaseBranch: b.base,
+		IsDraft:    b.draft,
+		Labels:     issue.Labels,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	result.PR = pr.URL
+	result.Status = batchFixed
+	result.Duration = time.Since(start).Round(time.Second).String()
+	if err := origin.RemoveWorktree(result.Worktree); err == nil {
+		result.Worktree = ""
+	}
+	return result
+}
+
+// worktreeExecutor returns the autonomous executor for a task run in dir,
+// a worktree of another checkout, with the default backend and the
+// language detected there.
+func worktreeExecutor(dir string) (*modes.AutonomousExecutor, error) {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load setup: %w", err)
+	}
+	provider, queryModel := llm.NewQueryProvider(setup, "")
+	language := string(langdetect.DetectLanguage(dir))
+	if language == "" || language == "unknown" {
+		language = setup.Defaults.Lang
+		if language == "" {
+			language = "go"
+		}
+	}
+	return modes.NewAutonomousExecutorWithBackend(provider, dir, queryModel, language, setup.Defaults.Backend), nil
+}
+
+func countStatus(results []batchResult, status string) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func printBatchReport(results []batchResult) {
+	fmt.Println("\n" + output.Infof("Batch report"))
+	fmt.Printf("   fixed: %d  failed: %d  skipped: %d\n\n",
+		countStatus(results, batchFixed), countStatus(results, batchFailed), countStatus(results, batchSkipped))
+	for _, r := range results {
+		line := fmt.Sprintf("   #%-5d %-8s %s", r.Issue, r.Status, r.Title)
+		switch {
+		case r.PR != "":
+			line += "\n          " + r.PR
+		case r.Error != "":
+			line += "\n          " + strings.SplitN(r.Error, "\n", 2)[0]
+		}
+		if r.Worktree != "" && r.Status == batchFailed {
+			line += "\n          worktree: " + r.Worktree
+		}
+		fmt.Println(line)
+	}
+}
+
+func init() {
+	issueCmd.AddCommand(issueFixAllCmd)
+
+	issueFixAllCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
+	issueFixAllCmd.Flags().StringSlice("label", nil, "Only issues with this label (repeatable)")
+	issueFixAllCmd.Flags().String("milestone", "", "Only issues in this milestone")
+	issueFixAllCmd.Flags().Int("limit", 5, "Maximum number of issues to fix")
+	issueFixAllCmd.Flags().Int("concurrency", 1, "Issues worked on in parallel (default from batch.max_concurrency)")
+	issueFixAllCmd.Flags().Bool("draft", false, "Create draft pull requests")
+	issueFixAllCmd.Flags().String("base", "main", "Base branch for worktrees and PRs")
+	issueFixAllCmd.Flags().Duration("time-budget", 0, "Stop starting new issues after this long (e.g. 1h)")
+	issueFixAllCmd.Flags().String("report", "", "Write the batch report as JSON to this file")
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/forge"
+	"gptcode/internal/github"
+)
+
+// captureStdout returns what f prints to stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	done := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		done <- string(out)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+}
+
+func batchIssues(n int) []*github.Issue {
+	var issues []*github.Issue
+	for i := 1; i <= n; i++ {
+		issues = append(issues, &github.Issue{Number: i, Title: "Issue " + strings.Repeat("x", i)})
+	}
+	return issues
+}
+
+func TestDeadlineFrom(t *testing.T) {
+	if !deadlineFrom(0).IsZero() || !deadlineFrom(-time.Minute).IsZero() {
+		t.Error("no budget should mean no deadline")
+	}
+	if d := deadlineFrom(time.Hour); d.Before(time.Now().Add(59*time.Minute)) || d.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expected a deadline an hour from now, got %v", d)
+	}
+}
+
+func TestIssueBatchRun(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TMPDIR", t.TempDir())
+	batch := &issueBatch{host: forge.NewGitLab("gitlab.com", "o/r"), workDir: t.TempDir(), base: "main"}
+
+	// Past the deadline nothing is started
+	batch.deadline = time.Now().Add(-time.Second)
+	var results []batchResult
+	captureStdout(t, func() { results = batch.run(batchIssues(4), 2) })
+	for i, r := range results {
+		if r.Issue != i+1 || r.Status != batchSkipped || r.Error != "time budget exhausted" {
+			t.Errorf("result %d: expected issue %d skipped, got %+v", i, i+1, r)
+		}
+	}
+
+	// Outside a git checkout every worktree fails, and the results keep the
+	// issues' order whatever order the workers finish in
+	batch.deadline = time.Time{}
+	captureStdout(t, func() { results = batch.run(batchIssues(5), 3) })
+	if len(results) != 5 {
+		t.Fatalf("expected 5 results, got %d", len(results))
+	}
+	for i, r := range results {
+		if r.Issue != i+1 || r.Status != batchFailed || !strings.Contains(r.Error, "failed to create worktree") {
+			t.Errorf("result %d: expected issue %d failed creating its worktree, got %+v", i, i+1, r)
+		}
+		if r.Branch == "" || !strings.HasSuffix(r.Worktree, fmt.Sprintf("o-r-issue-%d", r.Issue)) || r.Duration == "" {
+			t.Errorf("result %d: expected branch, worktree and duration, got %+v", i, r)
+		}
+	}
+}
+
+func TestIssueBatchFixWithoutSetup(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TMPDIR", t.TempDir())
+	dir := t.TempDir()
+	gitRun(t, dir, "init", "-q", "-b", "main")
+	gitRun(t, dir, "-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init")
+
+	batch := &issueBatch{host: forge.NewGitLab("gitlab.com", "o/r"), workDir: dir, base: "main"}
+	issue := &github.Issue{Number: 7, Title: "Crash on start"}
+	batch.fix(issue)
+	// A rerun replaces the worktree the failed run kept
+	r := batch.fix(issue)
+	if r.Status != batchFailed || r.Branch != "issue-7-crash-on-start" {
+		t.Fatalf("expected a failed issue-7-crash-on-start, got %+v", r)
+	}
+	if !strings.Contains(r.Error, "failed to load setup") {
+		t.Errorf("expected the missing setup to fail the fix, got %q", r.Error)
+	}
+	// The worktree was created before the failure and is kept for inspection
+	if _, err := os.Stat(r.Worktree); err != nil {
+		t.Errorf("expected the worktree to be kept: %v", err)
+	}
+}
+
+func TestPrintBatchReport(t *testing.T) {
+	out := captureStdout(t, func() {
+		printBatchReport([]batchResult{
+			{Issue: 1, Title: "Add login", Status: batchFixed, PR: "https://github.com/o/r/pull/9", Worktree: "/tmp/w1"},
+			{Issue: 2, Title: "Crash", Status: batchFailed, Error: "implementation failed: boom\nstack trace", Worktree: "/tmp/w2"},
+			{Issue: 3, Title: "Docs", Status: batchSkipped, Error: "time budget exhausted"},
+		})
+	})
+
+	for _, want := range []string{
+		"fixed: 1  failed: 1  skipped: 1",
+		"#1     fixed    Add login\n          https://github.com/o/r/pull/9\n",
+		"#2     failed   Crash\n          implementation failed: boom\n          worktree: /tmp/w2\n",
+		"#3     skipped  Docs\n          time budget exhausted\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "stack trace") || strings.Contains(out, "/tmp/w1") {
+		t.Errorf("report should show only the first error line and failed worktrees:\n%s", out)
+	}
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/forge"
+	"gptcode/internal/github"
+	"gptcode/internal/httpclient"
+	"gptcode/internal/llm"
+	"gptcode/internal/output"
+	"gptcode/internal/stack"
+)
+
+var issueStackCmd = &cobra.Command{
+	Use:   "stack",
+	Short: "Split a large fix into stacked branches and PRs, one per phase",
+	Long: `Split a large fix into phases, each on a branch made from the one before
+it and opened as its own PR against that branch, so reviewers see one phase
+at a time. The stack is tracked in .gptcode/stack.json.
+
+Examples:
+  gptcode issue stack create 123              Plan phases and open a PR per phase
+  gptcode issue stack create 123 --plan plan.md
+  gptcode issue stack status                  Show the phases and their PRs
+  gptcode issue stack sync                    Rebase phases onto updated ones below
+  gptcode issue stack sync --watch 5m         Keep the stack rebased while it is reviewed`,
+}
+
+var issueStackCreateCmd = &cobra.Command{
+	Use:   "create <issue-number>",
+	Short: "Implement an issue as a stack of branches and PRs",
+	Long: `Split an issue into phases, from a plan given with --plan (one "## "
+heading per phase, as written by gptcode plan) or planned by the model.
+Each phase gets a branch made from the previous one, is implemented and
+committed there, pushed, and opened as a PR whose base is the previous
+phase's branch. The last phase closes the issue.
+
+The stack is saved after every phase, so a failed phase leaves the ones
+below it in place.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runIssueStackCreate,
+}
+
+var issueStackStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the stack's phases, branches and PRs",
+	Args:  cobra.NoArgs,
+	RunE:  runIssueStackStatus,
+}
+
+var issueStackSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Rebase the stack's branches onto the phases below them",
+	Long: `Fetch the remote, fast-forward phase branches others pushed to, and rebase
+every phase whose parent moved onto the parent's new tip. Phases whose PR
+merged drop out of the stack and the phase above them moves onto the base
+branch. Rebased branches are pushed with --force-with-lease against the
+commit fetched from the remote; a branch whose remote copy has commits the
+local one lacks is not pushed.
+
+With --watch, sync runs again every interval until interrupted, so the
+stack follows the base PR as it is updated during review.`,
+	Args: cobra.NoArgs,
+	RunE: runIssueStackSync,
+}
+
+func init() {
+	issueCmd.AddCommand(issueStackCmd)
+	issueStackCmd.AddCommand(issueStackCreateCmd)
+	issueStackCmd.AddCommand(issueStackStatusCmd)
+	issueStackCmd.AddCommand(issueStackSyncCmd)
+
+	issueStackCmd.PersistentFlags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
+	issueStackCmd.PersistentFlags().String("remote", "origin", "Remote the stack's branches are pushed to")
+
+	issueStackCreateCmd.Flags().String("plan", "", "Plan file with one \"## \" section per phase (default: plan with the model)")
+	issueStackCreateCmd.Flags().String("base", "main", "Branch the first phase starts from")
+	issueStackCreateCmd.Flags().Bool("draft", false, "Create draft pull requests")
+	issueStackCreateCmd.Flags().Bool("no-pr", false, "Only create and commit the branches; don't push or open PRs")
+	issueStackCreateCmd.Flags().Bool("autonomous", true, "Implement each phase autonomously")
+	issueStackCreateCmd.Flags().Bool("force", false, "Replace an existing stack")
+
+	issueStackSyncCmd.Flags().Bool("no-push", false, "Rebase locally without pushing")
+	issueStackSyncCmd.Flags().Duration("watch", 0, "Sync again at this interval until interrupted, e.g. 5m")
+}
+
+func runIssueStackCreate(cmd *cobra.Command, args []string) error {
+	issueNum, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid issue number: %s", args[0])
+	}
+	planPath, _ := cmd.Flags().GetString("plan")
+	base, _ := cmd.Flags().GetString("base")
+	draft, _ := cmd.Flags().GetBool("draft")
+	noPR, _ := cmd.Flags().GetBool("no-pr")
+	autonomous, _ := cmd.Flags().GetBool("autonomous")
+	force, _ := cmd.Flags().GetBool("force")
+	remote, _ := cmd.Flags().GetString("remote")
+
+	workDir, _ := os.Getwd()
+	if existing, err := stack.Load(workDir); err == nil && len(existing.Open()) > 0 && !force {
+		return fmt.Errorf("a stack for #%d is in progress; finish it or pass --force", existing.Issue)
+	}
+
+	host, err := detectForge(cmd, workDir)
+	if err != nil {
+		return err
+	}
+	var target github.PushTarget
+	if !noPR {
+		if target, err = forge.ResolvePushTarget(workDir, remote); err != nil {
+			return err
+		}
+		target.UpstreamRepo = host.Repo()
+		if target.IsFork() {
+			return fmt.Errorf("stacked PRs need their branches in %s, but %s is a fork; push to the upstream with --remote or use --no-pr", host.Repo(), target.Remote)
+		}
+	}
+
+	issue, err := host.FetchIssue(issueNum)
+	if err != nil {
+		return fmt.Errorf("failed to fetch issue: %w", err)
+	}
+	fmt.Println(output.Infof("Issue #%d: %s", issue.Number, issue.Title))
+
+	var plan string
+	if planPath != "" {
+		data, err := os.ReadFile(planPath)
+		if err != nil {
+			return fmt.Errorf("failed to read plan: %w", err)
+		}
+		plan = string(data)
+	} else {
+		progress := output.StartStatus(os.Stderr, "Planning phases")
+		plan, err = planStackPhases(issue)
+		progress.Stop()
+		if err != nil {
+			return err
+		}
+	}
+	specs := stack.ParsePhases(plan)
+	if len(specs) == 0 {
+		return fmt.Errorf("the plan has no \"## \" phases")
+	}
+
+	client := gitClient(host, workDir)
+	prefix := client.BranchNameFor(issue)
+	s := &stack.Stack{Issue: issue.Number, Title: issue.Title, Base: base, Created: time.Now()}
+	for i, spec := range specs {
+		s.Phases = append(s.Phases, stack.Phase{Title: spec.Title, Task: spec.Task, Branch: stack.BranchName(prefix, i+1)})
+	}
+	if err := s.Save(workDir); err != nil {
+		return err
+	}
+
+	fmt.Println("\n" + output.Infof("%d phases:", len(s.Phases)))
+	for i, p := range s.Phases {
+		fmt.Printf("   %d. %s (%s)\n", i+1, p.Title, p.Branch)
+	}
+
+	parent := base
+	for i := range s.Phases {
+		p := &s.Phases[i]
+		fmt.Printf("\n▶ Part %d/%d: %s\n", i+1, len(s.Phases), p.Title)
+		p.Parent = parent
+		if p.ParentSHA, err = stack.Head(workDir, parent); err != nil {
+			return err
+		}
+		if err := client.CreateBranch(p.Branch, parent); err != nil {
+			return fmt.Errorf("failed to create branch: %w", err)
+		}
+
+		if autonomous {
+			executor, err := worktreeExecutor(workDir)
+			if err != nil {
+				return err
+			}
+			if err := executor.Execute(context.Background(), stackPhaseTask(issue, s, i)); err != nil {
+				_ = s.Save(workDir)
+				return fmt.Errorf("part %d failed: %w", i+1, err)
+			}
+		}
+
+		opts := github.CommitOptions{Message: p.Title, AllFiles: true}
+		if i == len(s.Phases)-1 {
+			opts.IssueNumber = issue.Number
+		} else {
+			opts.Message += fmt.Sprintf("\n\nPart %d of %d for #%d", i+1, len(s.Phases), issue.Number)
+		}
+		if err := client.CommitChanges(opts); err != nil {
+			return err
+		}
+		fmt.Println(output.OKf("Committed on %s", p.Branch))
+
+		if !noPR {
+			if err := client.PushBranchTo(target.Remote, p.Branch); err != nil {
+				return err
+			}
+			pr, err := host.CreatePR(github.PRCreateOptions{
+				Title:      fmt.Sprintf("[%d/%d] %s", i+1, len(s.Phases), p.Title),
+				Body:       stackPRBody(issue, s, i),
+				HeadBranch: p.Branch,
+				BaseBranch: parent,
+				IsDraft:    draft,
+				Labels:     issue.Labels,
+			})
+			if err != nil {
+				_ = s.Save(workDir)
+				return fmt.Errorf("failed to create PR: %w", err)
+			}
+			p.PR, p.PRURL = pr.Number, pr.URL
+			fmt.Println(output.OKf("PR #%d: %s", pr.Number, pr.URL))
+		}
+		if err := s.Save(workDir); err != nil {
+			return err
+		}
+		parent = p.Branch
+	}
+
+	fmt.Println("\nNext steps:")
+	fmt.Println("   gptcode issue stack status")
+	fmt.Println("   gptcode issue stack sync    after updating a phase")
+	return nil
+}
+
+// planStackPhases asks the model to split issue into phases that can be
+// reviewed one after another
+func planStackPhases(issue *github.Issue) (string, error) {
+	provider, model := llm.NewQueryProvider(nil, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You split large changes into small pull requests that are reviewed and merged one after another.",
+		UserPrompt: fmt.Sprintf(`Split the fix for this issue into 2 to 5 phases. Each phase is one pull
+request built on the previous one: it must compile and pass tests on its
+own, and should be reviewable in a few minutes. Put refactoring and new
+building blocks first and the behavior change last.
+
+Issue #%d: %s
+
+%s
+
+Reply in Markdown with one "## <phase title>" heading per phase, in order,
+each followed by what to change and which files are involved. Nothing else.`, issue.Number, issue.Title, issue.Body),
+		Model: model,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to plan phases: %w", err)
+	}
+	return resp.Text, nil
+}
+
+func stackPhaseTask(issue *github.Issue, s *stack.Stack, i int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\nThis fix is split into %d parts. Implement only part %d, %q:\n\n%s\n",
+		issueTask(issue, nil), len(s.Phases), i+1, s.Phases[i].Title, s.Phases[i].Task)
+	if i > 0 {
+		b.WriteString("\nEarlier parts, already done:\n")
+		for _, p := range s.Phases[:i] {
+			fmt.Fprintf(&b, "- %s\n", p.Title)
+		}
+	}
+	if i < len(s.Phases)-1 {
+		b.WriteString("\nLater parts, not to be done now:\n")
+		for _, p := range s.Phases[i+1:] {
+			fmt.Fprintf(&b, "- %s\n", p.Title)
+		}
+	}
+	return b.String()
+}
+
+func stackPRBody(issue *github.Issue, s *stack.Stack, i int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Summary\n\n%s\n\n", s.Phases[i].Task)
+	fmt.Fprintf(&b, "## Stack\n\nPart %d of %d for #%d (%s). Review and merge in order:\n\n", i+1, len(s.Phases), issue.Number, issue.Title)
+	for j, p := range s.Phases {
+		line := fmt.Sprintf("%d. %s", j+1, p.Title)
+		if p.PR > 0 {
+			line += fmt.Sprintf(" (#%d)", p.PR)
+		}
+		if j == i {
+			line = "**" + line + "** 👈 this PR"
+		}
+		b.WriteString(line + "\n")
+	}
+	if i < len(s.Phases)-1 {
+		fmt.Fprintf(&b, "\nPart of #%d\n", issue.Number)
+	} else {
+		fmt.Fprintf(&b, "\nCloses #%d\n", issue.Number)
+	}
+	return b.String()
+}
+
+func runIssueStackStatus(cmd *cobra.Command, args []string) error {
+	workDir, _ := os.Getwd()
+	s, err := stack.Load(workDir)
+	if err != nil {
+		return err
+	}
+	if host, err := detectForge(cmd, workDir); err == nil {
+		refreshStackPRs(host, s)
+		_ = s.Save(workDir)
+	}
+
+	fmt.Printf("%s\n\n", output.Infof("#%d: %s (base %s)", s.Issue, s.Title, s.Base))
+	current := stack.CurrentBranch(workDir)
+	for i, p := range s.Phases {
+		marker := " "
+		if p.Branch == current {
+			marker = "▸"
+		}
+		state := "open"
+		if p.Merged {
+			state = "merged"
+		}
+		fmt.Printf(" %s %d. %-40s %-7s %s\n", marker, i+1, p.Title, state, p.Branch)
+		if p.PRURL != "" {
+			fmt.Printf("      %s\n", p.PRURL)
+		}
+	}
+	return nil
+}
+
+// refreshStackPRs marks the phases whose PRs merged
+func refreshStackPRs(host forge.Forge, s *stack.Stack) {
+	for _, p := range s.Open() {
+		prs, err := host.BranchPRs(p.Branch)
+		if err != nil {
+			continue
+		}
+		for _, pr := range prs {
+			if pr.State == github.PRStateMerged {
+				p.Merged = true
+			}
+			if p.PR == 0 && pr.Number > 0 {
+				p.PR, p.PRURL = pr.Number, pr.URL
+			}
+		}
+	}
+}
+
+func runIssueStackSync(cmd *cobra.Command, args []string) error {
+	remote, _ := cmd.Flags().GetString("remote")
+	noPush, _ := cmd.Flags().GetBool("no-push")
+	watch, _ := cmd.Flags().GetDuration("watch")
+
+	workDir, _ := os.Getwd()
+	if watch <= 0 {
+		return syncStack(cmd, workDir, remote, !noPush)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Println(output.Infof("Syncing the stack every %s (Ctrl+C to stop)", watch))
+	for {
+		if err := syncStack(cmd, workDir, remote, !noPush); err != nil {
+			if errors.Is(err, stack.ErrNoStack) {
+				return err
+			}
+			fmt.Println(output.Warnf("%v", err))
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watch):
+		}
+	}
+}
+
+// syncStack brings the stack up to date once: fetch, pull phases others
+// pushed to, drop merged phases, rebase, push
+func syncStack(cmd *cobra.Command, workDir, remote string, push bool) error {
+	s, err := stack.Load(workDir)
+	if err != nil {
+		return err
+	}
+	if dirty, _ := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output(); len(dirty) > 0 {
+		return fmt.Errorf("commit or stash your changes before syncing the stack")
+	}
+
+	fetch := exec.Command("git", "fetch", "--quiet", remote)
+	fetch.Env = httpclient.CommandEnv()
+	if out, err := fetch.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to fetch %s: %w: %s", remote, err, out)
+	}
+	if host, err := detectForge(cmd, workDir); err == nil {
+		refreshStackPRs(host, s)
+	} else {
+		fmt.Println(output.Warnf("Not checking for merged PRs: %v", err))
+	}
+
+	pulled, diverged, err := s.Pull(workDir, remote)
+	for _, b := range pulled {
+		fmt.Println(output.Infof("%s: took the commits pushed to %s", b, remote))
+	}
+	if err != nil {
+		return err
+	}
+	skip := map[string]bool{}
+	for _, b := range diverged {
+		skip[b] = true
+		fmt.Println(output.Warnf("%s and %s/%s both have new commits; it will not be pushed until you merge them", b, remote, b))
+	}
+	// What each branch is expected to be on remote, so the push fails if
+	// anyone pushed to it since
+	leases := map[string]string{}
+	for _, p := range s.Open() {
+		leases[p.Branch], _ = stack.Head(workDir, remote+"/"+p.Branch)
+	}
+
+	baseRef := remote + "/" + s.Base
+	if _, err := stack.Head(workDir, baseRef); err != nil {
+		baseRef = s.Base
+	}
+	current := stack.CurrentBranch(workDir)
+	rebases, syncErr := s.Sync(workDir, baseRef)
+	if current != "" && current != "HEAD" {
+		_ = exec.Command("git", "checkout", "--quiet", current).Run()
+	}
+	if err := s.Save(workDir); err != nil {
+		return err
+	}
+
+	moved := 0
+	for _, r := range rebases {
+		if r.Parent != "" {
+			fmt.Println(output.Infof("%s now builds on %s; retarget its PR there if the host did not", r.Branch, r.Parent))
+		}
+		if !r.Moved() {
+			continue
+		}
+		moved++
+		fmt.Println(output.Infof("%s rebased onto %s", r.Branch, r.Onto[:min(7, len(r.Onto))]))
+		if push && !skip[r.Branch] {
+			lease := "--force-with-lease=refs/heads/" + r.Branch + ":" + leases[r.Branch]
+			pushCmd := exec.Command("git", "push", lease, remote, r.Branch)
+			pushCmd.Env = httpclient.CommandEnv()
+			if out, err := pushCmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to push %s: %w: %s", r.Branch, err, out)
+			}
+		}
+	}
+	if syncErr != nil {
+		return fmt.Errorf("%w; resolve it with git rebase and run sync again", syncErr)
+	}
+	if moved == 0 && len(pulled) == 0 {
+		fmt.Println(output.OKf("Stack is up to date"))
+	} else {
+		fmt.Println(output.OKf("Stack synced: %d branch(es) rebased", moved))
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/feedback"
+	"gptcode/internal/output"
+	"gptcode/internal/tutorial"
+)
+
+var learnCmd = &cobra.Command{
+	Use:   "learn",
+	Short: "Interactive tutorial: setup, a sample task, supervised mode, feedback and profiles",
+	Long: `Walk through gptcode with a guided tutorial.
+
+Each step explains a feature and then runs it for real: checking your backend
+and API key, fixing a bug autonomously and then in supervised mode inside a
+sandbox repository, recording feedback, and choosing a profile. The sandbox is
+a small Go project in a temporary directory; your own repositories are not
+touched.
+
+Examples:
+  gptcode learn            # Start from the beginning
+  gptcode learn --step 4   # Resume at step 4
+  gptcode learn --keep     # Keep the sandbox afterwards`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		step, _ := cmd.Flags().GetInt("step")
+		keep, _ := cmd.Flags().GetBool("keep")
+		steps := learnSteps()
+		if step < 1 || step > len(steps) {
+			return fmt.Errorf("--step must be between 1 and %d", len(steps))
+		}
+
+		sandbox, err := os.MkdirTemp("", "gptcode-learn-")
+		if err != nil {
+			return err
+		}
+		if err := tutorial.CreateSandbox(sandbox); err != nil {
+			os.RemoveAll(sandbox)
+			return fmt.Errorf("failed to create sandbox: %w", err)
+		}
+
+		s := tutorial.NewSession(os.Stdin, os.Stdout, sandbox)
+		err = s.Run(steps, step-1)
+		if keep {
+			fmt.Printf("\nSandbox kept at %s\n", sandbox)
+		} else {
+			os.RemoveAll(sandbox)
+		}
+		if errors.Is(err, tutorial.ErrQuit) {
+			return nil
+		}
+		return err
+	},
+}
+
+func init() {
+	learnCmd.Flags().Int("step", 1, "Start at this step")
+	learnCmd.Flags().Bool("keep", false, "Keep the sandbox repository when done")
+	rootCmd.AddCommand(learnCmd)
+}
+
+const learnBugTask = "Fix the bug in calc.Sum in calc/calc.go so that the tests in calc/calc_test.go pass. Do not change the tests."
+
+const learnSupervisedTask = "Add a Max function to calc/calc.go that returns the largest number (0 for an empty slice), with a test in calc/calc_test.go."
+
+func learnSteps() []tutorial.Step {
+	return []tutorial.Step{
+		{
+			Title: "Welcome",
+			Explain: `gptcode is an AI coding assistant that plans, edits and validates changes
+in your repository. This tutorial runs real commands in a sandbox: a small Go
+project in a temporary directory with a bug for you to fix.
+
+Steps: setup, an autonomous task, supervised mode, feedback, profiles.`,
+		},
+		{
+			Title: "Setup",
+			Explain: `gptcode talks to an LLM backend (OpenRouter, Groq, OpenAI, Anthropic,
+Ollama, ...) configured in ~/.gptcode/setup.yaml. API keys are read from
+<BACKEND>_API_KEY or ~/.gptcode/keys.yaml.
+
+Equivalent commands: gptcode setup, gptcode key <backend>, gptcode backend`,
+			Run: learnSetup,
+		},
+		{
+			Title: "Your first task",
+			Explain: `"gptcode do" runs a task end to end: a planner writes a plan, an editor
+changes files and runs commands, and a reviewer validates the result,
+retrying with the errors until it passes.
+
+The sandbox's calc.Sum skips the first number. This step runs:
+  gptcode do "` + learnBugTask + `"`,
+			Run: learnAutonomous,
+		},
+		{
+			Title: "Supervised mode",
+			Explain: `With --supervised, gptcode shows the plan and asks before changing
+anything, so you stay in control of larger or riskier changes. The agent can
+also ask you questions when the task is ambiguous.
+
+This step runs:
+  gptcode do --supervised "` + learnSupervisedTask + `"`,
+			Run: learnSupervised,
+		},
+		{
+			Title: "Feedback",
+			Explain: `Feedback teaches gptcode which models work for which tasks: bad results
+lower a model's score for that kind of work. Record it any time with
+"gptcode feedback good" or "gptcode feedback bad", or install the shell hook
+with "gptcode feedback hook install" to capture it as you work.`,
+			Run: learnFeedback,
+		},
+		{
+			Title: "Profiles",
+			Explain: `A profile picks a model per agent (router, query, editor, research) for a
+backend, e.g. a fast free profile and a stronger paid one. Switch with
+"gptcode profile use <backend>.<profile>"; override one run with
+--backend, --profile or --model.`,
+			Run: learnProfiles,
+		},
+		{
+			Title: "Next steps",
+			Explain: `You're set. Try these in your own repository:
+  gptcode chat                 Ask questions about the code
+  gptcode do "<task>"          Make a change
+  gptcode issue fix <number>   Fix a GitHub issue and open a PR
+  gptcode insights             See what works in this project
+  gptcode usage                See what it costs`,
+		},
+	}
+}
+
+func learnSetup(s *tutorial.Session) error {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil || setup.Defaults.Backend == "" || len(setup.Backend) == 0 {
+		fmt.Fprintln(s.Out, "No backend is configured yet.")
+		if !s.Confirm("Run gptcode setup now?", true) {
+			return fmt.Errorf("no backend configured; run gptcode setup")
+		}
+		config.RunSetup()
+		if setup, err = config.LoadEffectiveSetup(); err != nil || setup.Defaults.Backend == "" {
+			return fmt.Errorf("setup did not configure a backend")
+		}
+	}
+
+	backend := setup.Defaults.Backend
+	backendCfg := setup.Backend[backend]
+	fmt.Fprintf(s.Out, "Backend: %s (%s, %s)\n", backend, backendCfg.Type, backendCfg.BaseURL)
+	fmt.Fprintf(s.Out, "Profile: %s\n", valueOr(setup.Defaults.Profile, "default"))
+
+	if backendCfg.Type != "ollama" && config.GetAPIKey(backend) == "" {
+		fmt.Fprintf(s.Out, "\nNo API key found for %s.\n", backend)
+		if !s.Confirm("Add it now?", true) {
+			return fmt.Errorf("no API key for %s; run gptcode key %s", backend, backend)
+		}
+		if err := config.UpdateAPIKey(backend); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(s.Out, "\n"+output.OKf("Ready to run tasks with %s", backend))
+	return nil
+}
+
+func learnAutonomous(s *tutorial.Session) error {
+	fmt.Fprintf(s.Out, "Sandbox: %s\n\n", s.Sandbox)
+	fmt.Fprintln(s.Out, "Before the fix:")
+	learnRunTests(s)
+
+	err := inDir(s.Sandbox, func() error {
+		return runDoExecutionWithRetry(learnBugTask, false, 3, false, false, "")
+	})
+	s.Values["task"] = learnBugTask
+	learnShowResult(s)
+	return err
+}
+
+func learnSupervised(s *tutorial.Session) error {
+	err := inDir(s.Sandbox, func() error {
+		return runDoExecutionWithRetry(learnSupervisedTask, false, 3, true, false, "")
+	})
+	s.Values["task"] = learnSupervisedTask
+	learnShowResult(s)
+	return err
+}
+
+func learnShowResult(s *tutorial.Session) {
+	if diff := s.Diff(); diff != "" {
+		fmt.Fprintf(s.Out, "\nChanges in the sandbox:\n%s\n", diff)
+	} else {
+		fmt.Fprintln(s.Out, "\nNo changes were made in the sandbox.")
+	}
+	fmt.Fprintln(s.Out, "After the task:")
+	learnRunTests(s)
+}
+
+func learnRunTests(s *tutorial.Session) {
+	if _, err := exec.LookPath("go"); err != nil {
+		fmt.Fprintln(s.Out, "  (Go is not installed; skipping go test)")
+		return
+	}
+	cmd := exec.Command("go", "test", "./...")
+	cmd.Dir = s.Sandbox
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		fmt.Fprintf(s.Out, "%s\n", indent(string(out)))
+		return
+	}
+	fmt.Fprintln(s.Out, "  "+output.OKf("go test ./... passes"))
+}
+
+func learnFeedback(s *tutorial.Session) error {
+	task := s.Values["task"]
+	if task == "" {
+		task = learnBugTask
+	}
+	good := s.Confirm("Was the result of the last task good?", true)
+
+	setup, _ := config.LoadEffectiveSetup()
+	event := feedback.Event{
+		Sentiment: feedback.SentimentBad,
+		Backend:   setup.Defaults.Backend,
+		Model:     setup.Defaults.Model,
+		Agent:     "editor",
+		Task:      task,
+		Source:    "learn",
+		Files:     []string{filepath.Join("calc", "calc.go")},
+	}
+	if good {
+		event.Sentiment = feedback.SentimentGood
+	} else {
+		event.Context = s.Ask("What went wrong? (optional)", "")
+	}
+	if err := feedback.Record(event); err != nil {
+		return fmt.Errorf("failed to record feedback: %w", err)
+	}
+
+	command := "gptcode feedback bad"
+	if good {
+		command = "gptcode feedback good"
+	}
+	fmt.Fprintln(s.Out, output.OKf("Recorded, just like %s --backend %s --agent editor", command, event.Backend))
+	if events, err := feedback.LoadAll(); err == nil {
+		fmt.Fprintf(s.Out, "You have %d feedback events; see them with gptcode feedback stats\n", len(events))
+	}
+	return nil
+}
+
+func learnProfiles(s *tutorial.Session) error {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return err
+	}
+	backend := setup.Defaults.Backend
+	profiles, err := config.ListBackendProfiles(backend)
+	if err != nil || len(profiles) == 0 {
+		fmt.Fprintf(s.Out, "%s has no profiles yet. Create one with: gptcode profiles create %s <name>\n", backend, backend)
+		return nil
+	}
+
+	current := valueOr(setup.Defaults.Profile, "default")
+	fmt.Fprintf(s.Out, "Profiles for %s:\n", backend)
+	for _, p := range profiles {
+		marker := "  "
+		if p == current {
+			marker = "* "
+		}
+		fmt.Fprintf(s.Out, "  %s%s\n", marker, p)
+	}
+
+	choice := s.Ask("\nProfile to use (Enter keeps the current one):", current)
+	if choice == current {
+		return nil
+	}
+	found := false
+	for _, p := range profiles {
+		found = found || p == choice
+	}
+	if !found {
+		return fmt.Errorf("unknown profile %q", choice)
+	}
+	if err := config.SetConfig("defaults.profile", choice); err != nil {
+		return err
+	}
+	fmt.Fprintln(s.Out, output.OKf("Switched to %s/%s (gptcode profile use %s.%s)", backend, choice, backend, choice))
+	return nil
+}
+
+// inDir runs fn with the working directory set to dir.
+func inDir(dir string, fn func() error) error {
+	prev, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	defer func() { _ = os.Chdir(prev) }()
+	return fn()
+}
+
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return "  " + strings.Join(lines, "\n  ")
+}
+
+func valueOr(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
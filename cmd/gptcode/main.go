@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
@@ -24,13 +25,20 @@ import (
 	"gptcode/internal/ml"
 	"gptcode/internal/modes"
 	"gptcode/internal/ollama"
+	"gptcode/internal/output"
 	"gptcode/internal/prompt"
+	"gptcode/internal/tools"
 )
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	registerPlugins()
+	updateNotice := startUpdateCheck()
+	err := rootCmd.Execute()
+	saveCostMeter()
+	if err != nil {
 		os.Exit(1)
 	}
+	updateNotice()
 }
 
 var rootCmd = &cobra.Command{
@@ -43,10 +51,12 @@ $0-5/month vs $20-30/month subscriptions.
 
 ## COPILOT (Autonomous)
   gptcode do "task" [--supervised] [--interactive]  - Autonomous execution with agent orchestration
+  gptcode fleet run --repos repos.txt "task"        - Run a task across many repositories, one PR each
 
 ## INTERACTIVE (Conversational)
   gptcode chat                - Code-focused conversation (CLI or Neovim)
   gptcode run "task"          - Execute tasks with follow-up
+  gptcode agent run <name> "task" - Run a custom agent from ~/.gptcode/agents
 
 ## WORKFLOW (Manual Control)
   gptcode research "question" - Document codebase and architecture
@@ -66,6 +76,7 @@ $0-5/month vs $20-30/month subscriptions.
   gptcode tdd                    - Test-driven development mode
   gptcode feature "desc"      - Generate tests + implementation
   gptcode review [target]     - Code review for bugs, security, improvements
+  gptcode pr describe [pr]    - Write a PR description from the branch diff
 
 ## MODEL MANAGEMENT
   gptcode model list [--recommended]   - List models from catalog
@@ -79,6 +90,7 @@ $0-5/month vs $20-30/month subscriptions.
   gptcode backend              - Show current backend
   gptcode backend list         - List all backends
   gptcode backend use <name>   - Switch backend
+  gptcode backend status       - Show backend health (retries/circuit breaker)
   gptcode profile              - Show current profile
   gptcode profile list         - List all profiles
   gptcode profile use <backend>.<profile> - Switch profile
@@ -107,7 +119,9 @@ $0-5/month vs $20-30/month subscriptions.
   gptcode ml list|train|test|eval|predict - Machine learning features
   gptcode graph build|query    - Dependency graph analysis
   gptcode feedback good|bad    - User feedback tracking
-  gptcode detect-language      - Detect project language`,
+  gptcode detect-language      - Detect project language
+  gptcode plugins              - List gptcode-<name> plugins on PATH
+  gptcode self-update          - Update to the latest release`,
 }
 
 func init() {
@@ -132,7 +146,7 @@ func init() {
 }
 
 func newBuilderAndLLM(lang, mode, hint string) (*prompt.Builder, llm.Provider, string, error) {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return nil, nil, "", fmt.Errorf("failed to load setup: %w", err)
 	}
@@ -156,19 +170,11 @@ func newBuilderAndLLM(lang, mode, hint string) (*prompt.Builder, llm.Provider, s
 		var customExec llm.Provider
 		customModel := backendCfg.DefaultModel
 
-		if backendCfg.Type == "ollama" {
-			customExec = llm.NewOllama(backendCfg.BaseURL)
-		} else {
-			customExec = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-		}
+		customExec = llm.NewForBackend(backendName, backendCfg)
 
 		provider = llm.NewOrchestrator(backendCfg.BaseURL, backendName, customExec, customModel)
 	} else {
-		if backendCfg.Type == "ollama" {
-			provider = llm.NewOllama(backendCfg.BaseURL)
-		} else {
-			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-		}
+		provider = llm.NewForBackend(backendName, backendCfg)
 	}
 
 	return builder, provider, model, nil
@@ -196,7 +202,7 @@ var backendCmd = &cobra.Command{
 	Use:   "backend",
 	Short: "Show and manage backends",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -226,7 +232,7 @@ var backendListCmd = &cobra.Command{
 			return fmt.Errorf("failed to list backends: %w", err)
 		}
 
-		setup, _ := config.LoadSetup()
+		setup, _ := config.LoadEffectiveSetup()
 		defaultBackend := setup.Defaults.Backend
 
 		for _, name := range backends {
@@ -245,28 +251,30 @@ var backendCreateCmd = &cobra.Command{
 	Short: "Create new backend",
 	Long: `Create a new backend configuration.
 
-Type must be: openai, ollama
+Type must be: openai, ollama, anthropic, cohere (embeddings only)
 
 Examples:
   gptcode backend create mygroq openai https://api.groq.com/openai/v1
-  gptcode backend create local ollama http://localhost:11434`,
+  gptcode backend create local ollama http://localhost:11434
+  gptcode backend create claude anthropic https://api.anthropic.com
+  gptcode backend create cohere cohere https://api.cohere.com/v2`,
 	Args: cobra.ExactArgs(3),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
 		backendType := args[1]
 		baseURL := args[2]
 
-		if backendType != "openai" && backendType != "ollama" {
-			return fmt.Errorf("type must be 'openai' or 'ollama'")
+		if backendType != "openai" && backendType != "ollama" && backendType != "anthropic" && backendType != "cohere" {
+			return fmt.Errorf("type must be 'openai', 'ollama', 'anthropic' or 'cohere'")
 		}
 
 		if err := config.CreateBackend(name, backendType, baseURL); err != nil {
 			return err
 		}
 
-		fmt.Printf("[OK] Created backend: %s\n", name)
+		fmt.Println(output.OKf("Created backend: %s", name))
 		fmt.Println("\nNext steps:")
-		if backendType == "openai" {
+		if backendType != "ollama" {
 			fmt.Printf("  gptcode key %s                    # Set API key\n", name)
 		}
 		fmt.Printf("  gptcode config set backend.%s.default_model <model>\n", name)
@@ -286,7 +294,7 @@ var backendDeleteCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Printf("[OK] Deleted backend: %s\n", name)
+		fmt.Println(output.OKf("Deleted backend: %s", name))
 		return nil
 	},
 }
@@ -296,7 +304,7 @@ var backendShowCmd = &cobra.Command{
 	Short: "Show backend configuration (current if not specified)",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -323,6 +331,57 @@ var backendShowCmd = &cobra.Command{
 				fmt.Printf("    %s: %s\n", alias, model)
 			}
 		}
+		if len(backendCfg.Headers) > 0 {
+			fmt.Println("  Custom headers:")
+			for name := range backendCfg.Headers {
+				fmt.Printf("    %s\n", name)
+			}
+		}
+		if backendCfg.SigV4 != nil {
+			fmt.Printf("  SigV4 signing: %s/%s\n", backendCfg.SigV4.Region, backendCfg.SigV4.Service)
+		}
+		return nil
+	},
+}
+
+var backendStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show circuit breaker health of backends",
+	Long: `Show the last recorded request health of each backend.
+
+Requests that fail with connection errors, 429 or 5xx responses are retried
+with jittered exponential backoff. After repeated failures a backend's circuit
+opens and requests fail fast until the cooldown elapses.
+
+Tune per backend in setup.yaml:
+  backend:
+    openrouter:
+      retry:
+        max_attempts: 5
+        base_delay_ms: 500
+        max_delay_ms: 10000
+        breaker_threshold: 5
+        breaker_cooldown_sec: 30`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		health, err := llm.LoadBackendHealth()
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Println("No backend health recorded yet")
+				return nil
+			}
+			return fmt.Errorf("failed to load backend health: %w", err)
+		}
+
+		for _, h := range health {
+			fmt.Printf("%s: %s\n", h.Backend, h.State)
+			if h.ConsecutiveFailures > 0 {
+				fmt.Printf("  Consecutive failures: %d\n", h.ConsecutiveFailures)
+			}
+			if h.LastError != "" {
+				fmt.Printf("  Last error: %s\n", h.LastError)
+			}
+			fmt.Printf("  Updated: %s\n", h.UpdatedAt.Format(time.RFC3339))
+		}
 		return nil
 	},
 }
@@ -344,7 +403,7 @@ Examples:
 			return fmt.Errorf("failed to set backend: %w", err)
 		}
 
-		fmt.Printf("[OK] Switched to %s\n", backendName)
+		fmt.Println(output.OKf("Switched to %s", backendName))
 		return nil
 	},
 }
@@ -391,7 +450,49 @@ Examples:
 		if err := config.SetConfig(key, value); err != nil {
 			return err
 		}
-		fmt.Printf("[OK] Set %s = %s\n", key, value)
+		fmt.Println(output.OKf("Set %s = %s", key, value))
+		return nil
+	},
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade setup.yaml to the current schema version",
+	Long: `Upgrade ~/.gptcode/setup.yaml to the current schema version.
+
+Outdated files are migrated automatically when loaded; this command lets
+you preview the changes first. The original is kept as setup.yaml.v<N>.bak.
+
+Examples:
+  gptcode config migrate --dry-run
+  gptcode config migrate`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(home, ".gptcode", "setup.yaml")
+
+		result, err := config.MigrateSetupFile(path, dryRun)
+		if err != nil {
+			return err
+		}
+		if !result.Migrated() {
+			fmt.Printf("setup.yaml is up to date (version %d)\n", result.ToVersion)
+			return nil
+		}
+
+		fmt.Printf("setup.yaml: version %d -> %d\n", result.FromVersion, result.ToVersion)
+		for _, change := range result.Changes {
+			fmt.Printf("  - %s\n", change)
+		}
+		if dryRun {
+			fmt.Println("\nResult (dry run, nothing written):")
+			fmt.Println(string(result.After))
+			return nil
+		}
+		fmt.Println(output.OKf("Migrated (backup: %s)", result.BackupPath))
 		return nil
 	},
 }
@@ -449,7 +550,7 @@ var modelsUpdateCmd = &cobra.Command{
 		if err := catalog.FetchAndSave(catalogPath, apiKeys); err != nil {
 			return fmt.Errorf("failed to update catalog: %w", err)
 		}
-		fmt.Printf("[OK] Model catalog updated: %s\n", catalogPath)
+		fmt.Println(output.OKf("Model catalog updated: %s", catalogPath))
 		return nil
 	},
 }
@@ -458,7 +559,9 @@ var modelsSearchCmd = &cobra.Command{
 	Use:   "search [term1] [term2] ...",
 	Short: "Search models in catalog with filtering and sorting",
 	Long: `Search models from a backend with multi-term filtering.
-Models are automatically sorted by price (lowest first), then by context window (largest first).
+Models are shown as a table sorted by feedback score, then price (lowest first),
+then context window (largest first). The PROFILES column lists the profiles of
+your setup that use each model.
 
 Single term: filters across all backends
   gptcode models search gemini
@@ -468,10 +571,30 @@ Multiple terms: ANDs all terms (must match all)
   gptcode models search free coding     # free models tagged as coding
 
 Flags override positional backend:
-  gptcode models search gemini --backend openrouter`,
+  gptcode models search gemini --backend openrouter
+
+Sort and filter:
+  gptcode models search --sort context --min-context 128k
+  gptcode models search coder --max-price 2 --tools-only
+  gptcode models search --free --json   # JSON for scripts`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		backendFlag, _ := cmd.Flags().GetString("backend")
 		agentFlag, _ := cmd.Flags().GetString("agent")
+		sortBy, _ := cmd.Flags().GetString("sort")
+		maxPrice, _ := cmd.Flags().GetFloat64("max-price")
+		minContext, _ := cmd.Flags().GetString("min-context")
+		toolsOnly, _ := cmd.Flags().GetBool("tools-only")
+		free, _ := cmd.Flags().GetBool("free")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		filter := catalog.Filter{MaxPrice: maxPrice, ToolsOnly: toolsOnly, Free: free}
+		if minContext != "" {
+			size, err := catalog.ParseContextSize(minContext)
+			if err != nil {
+				return err
+			}
+			filter.MinContext = size
+		}
 
 		var queryTerms []string
 		if len(args) > 0 {
@@ -482,6 +605,15 @@ Flags override positional backend:
 		if err != nil {
 			return fmt.Errorf("search failed: %w", err)
 		}
+		models = filter.Apply(models)
+		if err := catalog.SortModels(models, sortBy); err != nil {
+			return err
+		}
+
+		refs := map[string][]string{}
+		if setup, err := config.LoadEffectiveSetup(); err == nil {
+			refs = setup.ModelProfiles(backendFlag)
+		}
 
 		type ModelJSON struct {
 			ID            string   `json:"id"`
@@ -493,6 +625,8 @@ Flags override positional backend:
 			PriceComp     float64  `json:"pricing_completion_per_m_tokens"`
 			Installed     bool     `json:"installed"`
 			FeedbackScore float64  `json:"feedback_score"`
+			SupportsTools bool     `json:"supports_tools"`
+			Profiles      []string `json:"profiles,omitempty"`
 		}
 
 		result := make([]ModelJSON, 0, len(models))
@@ -507,6 +641,13 @@ Flags override positional backend:
 				}
 			}
 
+			// Catalog ids may carry the provider ahead of the backend's own
+			// model name, as in openai/gpt-4o-mini
+			profiles := refs[m.ID]
+			if _, name, ok := strings.Cut(m.ID, "/"); ok && len(profiles) == 0 {
+				profiles = refs[name]
+			}
+
 			result = append(result, ModelJSON{
 				ID:            m.ID,
 				Name:          m.Name,
@@ -517,19 +658,72 @@ Flags override positional backend:
 				PriceComp:     m.PricingComp,
 				Installed:     m.Installed,
 				FeedbackScore: m.FeedbackScore,
+				SupportsTools: m.SupportsTools(),
+				Profiles:      profiles,
 			})
 		}
 
-		encoder := json.NewEncoder(os.Stdout)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(result); err != nil {
-			return fmt.Errorf("failed to encode JSON: %w", err)
+		if asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(result); err != nil {
+				return fmt.Errorf("failed to encode JSON: %w", err)
+			}
+			return nil
 		}
 
+		if len(result) == 0 {
+			fmt.Println("No models match")
+			return nil
+		}
+		width := len("MODEL")
+		for _, m := range result {
+			width = max(width, len(m.ID))
+		}
+		fmt.Printf("%-*s  %8s  %8s  %8s  %5s  %5s  %s\n", width, "MODEL", "CONTEXT", "$IN/1M", "$OUT/1M", "SCORE", "TOOLS", "PROFILES")
+		for _, m := range result {
+			score, tools := "-", ""
+			if m.FeedbackScore > 0 {
+				score = fmt.Sprintf("%.0f%%", m.FeedbackScore*100)
+			}
+			if m.SupportsTools {
+				tools = "yes"
+			}
+			if m.Recommended {
+				m.ID += "*"
+			}
+			line := fmt.Sprintf("%-*s  %8s  %8s  %8s  %5s  %5s  %s", width, m.ID, contextSize(m.ContextWindow),
+				pricePerM(m.PricePrompt), pricePerM(m.PriceComp), score, tools, strings.Join(m.Profiles, ", "))
+			fmt.Println(strings.TrimRight(line, " "))
+		}
+		if agentFlag != "" {
+			fmt.Printf("\n* recommended for %s\n", agentFlag)
+		}
 		return nil
 	},
 }
 
+// pricePerM formats a catalog price, which is negative when it varies
+func pricePerM(price float64) string {
+	if price < 0 {
+		return "varies"
+	}
+	return fmt.Sprintf("%.2f", price)
+}
+
+// contextSize formats a context window as 8k, 128k or 1m, or - when unknown
+func contextSize(tokens int) string {
+	switch {
+	case tokens <= 0:
+		return "-"
+	case tokens >= 1000000:
+		return fmt.Sprintf("%gm", float64(tokens/100000)/10)
+	case tokens >= 1000:
+		return fmt.Sprintf("%dk", tokens/1000)
+	}
+	return fmt.Sprintf("%d", tokens)
+}
+
 var modelsInstallCmd = &cobra.Command{
 	Use:   "install <model>",
 	Short: "Install Ollama model if not present",
@@ -543,7 +737,7 @@ var modelsInstallCmd = &cobra.Command{
 		}
 
 		if installed {
-			fmt.Printf("[OK] Model %s already installed\n", modelName)
+			fmt.Println(output.OKf("Model %s already installed", modelName))
 			return nil
 		}
 
@@ -556,7 +750,7 @@ var modelsInstallCmd = &cobra.Command{
 			return fmt.Errorf("failed to install model: %w", err)
 		}
 
-		fmt.Printf("[OK] Model %s installed successfully\n", modelName)
+		fmt.Println(output.OKf("Model %s installed successfully", modelName))
 		return nil
 	},
 }
@@ -570,7 +764,7 @@ var profileCmd = &cobra.Command{
 	Use:   "profile",
 	Short: "Show and manage current profile",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -601,7 +795,7 @@ var profileListCmd = &cobra.Command{
 	Short: "List all profiles (or for specific backend)",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -638,7 +832,7 @@ var profileShowCmd = &cobra.Command{
 	Short: "Show profile configuration (current if not specified)",
 	Args:  cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -700,7 +894,7 @@ Examples:
 			return fmt.Errorf("failed to set profile: %w", err)
 		}
 
-		fmt.Printf("[OK] Switched to %s/%s\n", backend, profile)
+		fmt.Println(output.OKf("Switched to %s/%s", backend, profile))
 		return nil
 	},
 }
@@ -761,7 +955,7 @@ var profilesCreateCmd = &cobra.Command{
 			return fmt.Errorf("failed to create profile: %w", err)
 		}
 
-		fmt.Printf("[OK] Created profile: %s/%s\n", backend, name)
+		fmt.Println(output.OKf("Created profile: %s/%s", backend, name))
 		fmt.Println("\nConfigure agent models using:")
 		fmt.Printf("  gptcode profiles set-agent %s %s router <model>\n", backend, name)
 		fmt.Printf("  gptcode profiles set-agent %s %s query <model>\n", backend, name)
@@ -791,7 +985,7 @@ Example:
 			return fmt.Errorf("failed to set agent model: %w", err)
 		}
 
-		fmt.Printf("[OK] Set %s/%s %s = %s\n", backend, profile, agent, model)
+		fmt.Println(output.OKf("Set %s/%s %s = %s", backend, profile, agent, model))
 		return nil
 	},
 }
@@ -808,7 +1002,7 @@ var profilesDeleteCmd = &cobra.Command{
 			return fmt.Errorf("failed to delete profile: %w", err)
 		}
 
-		fmt.Printf("[OK] Deleted profile: %s/%s\n", backend, profile)
+		fmt.Println(output.OKf("Deleted profile: %s/%s", backend, profile))
 		return nil
 	},
 }
@@ -840,7 +1034,7 @@ Examples:
 			return fmt.Errorf("failed to set profile: %w", err)
 		}
 
-		fmt.Printf("[OK] Switched to %s/%s\n", backend, profile)
+		fmt.Println(output.OKf("Switched to %s/%s", backend, profile))
 		return nil
 	},
 }
@@ -883,7 +1077,7 @@ var feedbackGoodCmd = &cobra.Command{
 			return fmt.Errorf("failed to record feedback: %w", err)
 		}
 
-		fmt.Println("[OK] Positive feedback recorded")
+		fmt.Println(output.OKf("Positive feedback recorded"))
 		return nil
 	},
 }
@@ -921,7 +1115,7 @@ var feedbackBadCmd = &cobra.Command{
 			return fmt.Errorf("failed to record feedback: %w", err)
 		}
 
-		fmt.Println("[OK] Negative feedback recorded")
+		fmt.Println(output.OKf("Negative feedback recorded"))
 		return nil
 	},
 }
@@ -1008,7 +1202,7 @@ Example:
 			return fmt.Errorf("failed to export feedback: %w", err)
 		}
 
-		fmt.Printf("[OK] Exported %d anonymized feedback events to %s\n", len(events), outputPath)
+		fmt.Println(output.OKf("Exported %d anonymized feedback events to %s", len(events), outputPath))
 		return nil
 	},
 }
@@ -1033,7 +1227,7 @@ var feedbackSubmitCmd = &cobra.Command{
 			if err := feedback.Record(e); err != nil {
 				return fmt.Errorf("failed to record feedback: %w", err)
 			}
-			fmt.Println("[OK] Feedback submitted")
+			fmt.Println(output.OKf("Feedback submitted"))
 			return nil
 		}
 
@@ -1072,7 +1266,8 @@ var feedbackSubmitCmd = &cobra.Command{
 					diffCmd := exec.Command("git", "diff")
 					diffBytes, _ := diffCmd.Output()
 					if len(diffBytes) > 0 {
-						dir := filepath.Join(os.Getenv("HOME"), ".gptcode", "diffs")
+						home, _ := os.UserHomeDir()
+						dir := filepath.Join(home, ".gptcode", "diffs")
 						_ = os.MkdirAll(dir, 0755)
 						name := time.Now().Format("20060102-150405") + ".patch"
 						path := filepath.Join(dir, name)
@@ -1085,40 +1280,7 @@ var feedbackSubmitCmd = &cobra.Command{
 		if err := feedback.Record(e); err != nil {
 			return fmt.Errorf("failed to record feedback: %w", err)
 		}
-		fmt.Println("[OK] Feedback submitted")
-		return nil
-	},
-}
-
-var demoCmd = &cobra.Command{
-	Use:   "demo",
-	Short: "Demos and recordings",
-}
-
-var demoFeedbackCmd = &cobra.Command{
-	Use:   "feedback",
-	Short: "Feedback capture demos",
-}
-
-var demoFeedbackCreateCmd = &cobra.Command{
-	Use:     "create",
-	Aliases: []string{"feedback:create", "feedback.create"},
-	Short:   "Generate feedback demos (casts + GIFs)",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		repo, _ := cmd.Flags().GetString("repo")
-		tries, _ := cmd.Flags().GetInt("tries")
-		if repo == "" {
-			repo = "."
-		}
-		sh := exec.Command("bash", "docs/scripts/build_demos.sh")
-		sh.Dir = repo
-		sh.Env = append(os.Environ(), fmt.Sprintf("TRIES=%d", tries))
-		sh.Stdout = os.Stdout
-		sh.Stderr = os.Stderr
-		if err := sh.Run(); err != nil {
-			return fmt.Errorf("failed to build demos: %w", err)
-		}
-		fmt.Println("[OK] Demos built in docs/assets")
+		fmt.Println(output.OKf("Feedback submitted"))
 		return nil
 	},
 }
@@ -1134,9 +1296,12 @@ func init() {
 	backendCmd.AddCommand(backendUseCmd)
 	backendCmd.AddCommand(backendCreateCmd)
 	backendCmd.AddCommand(backendDeleteCmd)
+	backendCmd.AddCommand(backendStatusCmd)
 
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	configMigrateCmd.Flags().Bool("dry-run", false, "Show the changes without writing setup.yaml")
 
 	rootCmd.AddCommand(profilesCmd)
 	profilesCmd.AddCommand(profilesListCmd)
@@ -1159,12 +1324,6 @@ func init() {
 	feedbackCmd.AddCommand(feedbackSubmitCmd)
 	feedbackCmd.AddCommand(feedbackHookCmd)
 
-	rootCmd.AddCommand(demoCmd)
-	demoCmd.AddCommand(demoFeedbackCmd)
-	demoFeedbackCmd.AddCommand(demoFeedbackCreateCmd)
-	demoFeedbackCreateCmd.Flags().String("repo", ".", "Repository root containing docs/scripts/build_demos.sh")
-	demoFeedbackCreateCmd.Flags().Int("tries", 3, "Max attempts to capture good demos")
-
 	feedbackGoodCmd.Flags().String("backend", "", "Backend used")
 	feedbackGoodCmd.Flags().String("model", "", "Model used")
 	feedbackGoodCmd.Flags().String("agent", "", "Agent type (router, query, editor, research)")
@@ -1203,12 +1362,19 @@ func init() {
 
 	feedbackExportCmd.Flags().Bool("dry-run", false, "Preview anonymized data without exporting")
 
+	rootCmd.AddCommand(modelsCmd)
 	modelsCmd.AddCommand(modelsUpdateCmd)
 	modelsCmd.AddCommand(modelsSearchCmd)
 	modelsCmd.AddCommand(modelsInstallCmd)
 
 	modelsSearchCmd.Flags().StringP("backend", "b", "openrouter", "Backend to search (openrouter, groq, ollama, etc)")
 	modelsSearchCmd.Flags().StringP("agent", "a", "", "Agent type (router, query, editor, research)")
+	modelsSearchCmd.Flags().String("sort", catalog.SortScore, "Sort by score, price or context")
+	modelsSearchCmd.Flags().Float64("max-price", 0, "Highest prompt plus completion price in USD per 1M tokens")
+	modelsSearchCmd.Flags().String("min-context", "", "Smallest context window, such as 32k or 1m")
+	modelsSearchCmd.Flags().Bool("tools-only", false, "Only models known to support tool calling")
+	modelsSearchCmd.Flags().Bool("free", false, "Only free models")
+	modelsSearchCmd.Flags().Bool("json", false, "Output results as JSON")
 }
 
 var feedbackHookInstallCmd = &cobra.Command{
@@ -1218,218 +1384,12 @@ var feedbackHookInstallCmd = &cobra.Command{
 		shell, _ := cmd.Flags().GetString("shell")
 		withDiff, _ := cmd.Flags().GetBool("with-diff")
 		andSource, _ := cmd.Flags().GetBool("and-source")
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return err
-		}
-		hookDir := filepath.Join(home, ".gptcode")
-		if err := os.MkdirAll(hookDir, 0755); err != nil {
-			return err
-		}
-		switch shell {
-		case "zsh":
-			hookPath := filepath.Join(hookDir, "feedback_hook.zsh")
-			hook := `chu_mark_suggestion_widget() {
-	local f="$HOME/.gptcode/last_suggestion_cmd"
-	print -r -- "$BUFFER" > "$f"
-zle -M "Suggestion captured"
-}
-
-zle -N chu_mark_suggestion_widget
-bindkey -M emacs "^G" chu_mark_suggestion_widget
-bindkey -M viins "^G" chu_mark_suggestion_widget
-
-preexec_chu_feedback() {
-	local cmd="$1"
-	local sfile="$HOME/.gptcode/last_suggestion_cmd"
-	if [[ -f "$sfile" ]]; then
-		print -r -- "$(<"$sfile")" > "$HOME/.gptcode/.pending_wrong"
-		print -r -- "$cmd" > "$HOME/.gptcode/.pending_correct"
-	fi
-}
-
-precmd_chu_feedback() {
-	local wrongf="$HOME/.gptcode/.pending_wrong"
-	local correctf="$HOME/.gptcode/.pending_correct"
-	if [[ -f "$wrongf" && -f "$correctf" ]]; then
-		local wrong="$(<"$wrongf")"
-		local correct="$(<"$correctf")"
-		local files=""
-		if command -v git >/dev/null 2>&1; then
-			if git rev-parse --is-inside-work-tree >/dev/null 2>&1; then
-				files=$(git diff --name-only)
-			fi
-		fi
-		local -a args
-		args=(feedback submit --sentiment=bad --kind=command --source=shell --agent=editor --wrong="$wrong" --correct="$correct")
-		
-		if [[ -n "$files" ]]; then
-			local f
-			for f in ${(f)files}; do
-				args+=(--files "$f")
-			done
-		fi
-		if [[ %WITH_DIFF% == 1 ]]; then args+=(--capture-diff); fi
-		gptcode $args >/dev/null 2>&1
-		rm -f "$wrongf" "$correctf" "$HOME/.gptcode/last_suggestion_cmd"
-	fi
-}
-
-autoload -Uz add-zsh-hook
-add-zsh-hook preexec preexec_chu_feedback
-add-zsh-hook precmd precmd_chu_feedback
-`
-			if withDiff {
-				hook = strings.ReplaceAll(hook, "%WITH_DIFF%", "1")
-				hook = strings.ReplaceAll(hook, "%FISH_DIFF%", "set args $args --capture-diff")
-			} else {
-				hook = strings.ReplaceAll(hook, "%WITH_DIFF%", "0")
-				hook = strings.ReplaceAll(hook, "%FISH_DIFF%", "")
-			}
-			if err := os.WriteFile(hookPath, []byte(hook), 0644); err != nil {
-				return err
-			}
-			rcPath := filepath.Join(home, ".zshrc")
-			var rc string
-			if data, err := os.ReadFile(rcPath); err == nil {
-				rc = string(data)
-			}
-			line := "source $HOME/.gptcode/feedback_hook.zsh"
-			if !strings.Contains(rc, line) {
-				rc += "\n" + line + "\n"
-				if err := os.WriteFile(rcPath, []byte(rc), 0644); err != nil {
-					return err
-				}
-			}
-			fmt.Println("[OK] Installed zsh hook. Restart your shell or run: source ~/.zshrc")
-			if andSource {
-				_ = exec.Command("zsh", "-ic", "source ~/.zshrc").Run()
-			}
-			return nil
-		case "bash":
-			hookPath := filepath.Join(hookDir, "feedback_hook.bash")
-			hook := `chu_mark_suggestion_bash() {
-	local f="$HOME/.gptcode/last_suggestion_cmd"
-	printf "%s" "$READLINE_LINE" > "$f"
-}
-
-bind -x '"\C-g":"chu_mark_suggestion_bash"'
-
-chu_preexec() {
-	local cmd="$1"
-	local sfile="$HOME/.gptcode/last_suggestion_cmd"
-	if [[ -f "$sfile" ]]; then
-		cat "$sfile" > "$HOME/.gptcode/.pending_wrong"
-		printf "%s" "$cmd" > "$HOME/.gptcode/.pending_correct"
-	fi
-}
-trap 'chu_preexec "$BASH_COMMAND"' DEBUG
-
-chu_precmd() {
-	local wrongf="$HOME/.gptcode/.pending_wrong"
-	local correctf="$HOME/.gptcode/.pending_correct"
-	if [[ -f "$wrongf" && -f "$correctf" ]]; then
-		local wrong
-		wrong="$(cat "$wrongf")"
-		local correct
-		correct="$(cat "$correctf")"
-		local files=""
-		if command -v git >/dev/null 2>&1; then
-			if git rev-parse --is-inside-work-tree >/dev/null 2>&1; then
-				files="$(git diff --name-only)"
-			fi
-		fi
-		local -a args=(feedback submit --sentiment=bad --kind=command --source=shell --agent=editor --wrong="$wrong" --correct="$correct")
-		if [[ %WITH_DIFF% == 1 ]]; then args+=(--capture-diff); fi
-		if [[ -n "$files" ]]; then
-			while IFS= read -r f; do args+=(--files "$f"); done <<< "$files"
-		fi
-		gptcode "${args[@]}" >/dev/null 2>&1
-		rm -f "$wrongf" "$correctf" "$HOME/.gptcode/last_suggestion_cmd"
-	fi
-}
-
-PROMPT_COMMAND="chu_precmd; $PROMPT_COMMAND"
-`
-			if err := os.WriteFile(hookPath, []byte(hook), 0644); err != nil {
-				return err
-			}
-			rcPath := filepath.Join(home, ".bashrc")
-			var rc string
-			if data, err := os.ReadFile(rcPath); err == nil {
-				rc = string(data)
-			}
-			line := ". \"$HOME/.gptcode/feedback_hook.bash\""
-			if !strings.Contains(rc, line) {
-				rc += "\n" + line + "\n"
-				if err := os.WriteFile(rcPath, []byte(rc), 0644); err != nil {
-					return err
-				}
-			}
-			fmt.Println("[OK] Installed bash hook. Restart your shell or run: source ~/.bashrc")
-			if andSource {
-				_ = exec.Command("bash", "-ic", "source ~/.bashrc").Run()
-			}
-			return nil
-		case "fish":
-			confDir := filepath.Join(home, ".config", "fish", "conf.d")
-			if err := os.MkdirAll(confDir, 0755); err != nil {
-				return err
-			}
-			hookPath := filepath.Join(confDir, "chu_feedback.fish")
-			hook := `function chufb_mark_suggestion
-	set -l f "$HOME/.gptcode/last_suggestion_cmd"
-	commandline -b > $f
-end
-bind \cg chufb_mark_suggestion
-
-function chufb_preexec --on-event fish_preexec
-	set -l cmd $argv
-	set -l sfile "$HOME/.gptcode/last_suggestion_cmd"
-	if test -f $sfile
-		cat $sfile > "$HOME/.gptcode/.pending_wrong"
-		printf "%s" "$cmd" > "$HOME/.gptcode/.pending_correct"
-	end
-end
-
-function chufb_postexec --on-event fish_postexec
-	set -l wrongf "$HOME/.gptcode/.pending_wrong"
-	set -l correctf "$HOME/.gptcode/.pending_correct"
-	if test -f $wrongf; and test -f $correctf
-		set -l wrong (cat $wrongf)
-		set -l correct (cat $correctf)
-		set -l files
-		if type -q git
-			if git rev-parse --is-inside-work-tree >/dev/null 2>&1
-				set files (git diff --name-only)
-			end
-		end
-		set -l args feedback submit --sentiment=bad --kind=command --source=shell --agent=editor --wrong="$wrong" --correct="$correct"
-		%FISH_DIFF%
-		for f in $files
-			set args $args --files $f
-		end
-		gptcode $args >/dev/null 2>&1
-		rm -f $wrongf $correctf "$HOME/.gptcode/last_suggestion_cmd"
-	end
-end
-`
-			if err := os.WriteFile(hookPath, []byte(hook), 0644); err != nil {
-				return err
-			}
-			fmt.Println("[OK] Installed fish hook. Restart fish or open a new session")
-			if andSource {
-				_ = exec.Command("fish", "-ic", "source ~/.config/fish/conf.d/chu_feedback.fish").Run()
-			}
-			return nil
-		default:
-			return fmt.Errorf("unsupported shell: %s", shell)
-		}
+		return installFeedbackHook(shell, withDiff, andSource)
 	},
 }
 
 func init() {
-	feedbackHookInstallCmd.Flags().String("shell", "zsh", "Shell to install hook for")
+	feedbackHookInstallCmd.Flags().String("shell", defaultHookShell(runtime.GOOS), "Shell to install hook for (zsh, bash, fish, powershell or nu)")
 	feedbackHookInstallCmd.Flags().Bool("with-diff", false, "Also capture git diff patch to file")
 	feedbackHookInstallCmd.Flags().Bool("and-source", false, "Attempt to source shell rc after install")
 	feedbackHookCmd.AddCommand(feedbackHookInstallCmd)
@@ -1456,6 +1416,7 @@ REPL Commands:
   /context       - Show context stats
   /files         - List files in context
   /history       - Show history
+  /do [note]     - Turn the conversation into a task and run it, as gptcode do
   /help          - Show help`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if we have a message argument or stdin input
@@ -1473,6 +1434,9 @@ REPL Commands:
 		if err != nil {
 			return fmt.Errorf("failed to initialize chat REPL: %w", err)
 		}
+		replInstance.SetTaskRunner(func(task string) error {
+			return runDoExecutionWithRetry(task, false, 3, false, false, "")
+		})
 		return replInstance.RunWithInitialMessage(initialMessage)
 	},
 }
@@ -1540,7 +1504,7 @@ Two modes available:
    gptcode run "curl https://api.github.com" --raw
 
 AI-assisted mode provides:
-- Command suggestions and execution
+- Command suggestions, explained and selectively approved before execution
 - Follow-up support with context preservation
 - Command history and output reference ($1, $2, $last)
 - Directory and environment variable management
@@ -1552,24 +1516,49 @@ REPL Commands:
   /output <id>   - Show output of previous command
   /cd <dir>      - Change directory
   /env           - Show/set environment variables
+  /diff          - Show output diff of the last repeated command
+  /delta [q]     - Ask the AI what changed since the previous run
+
+Re-running a command shows what changed in its output since the last run.
+
+With kubectl and a kubeconfig, AI mode also gets read-only k8s_get,
+k8s_describe, k8s_logs and k8s_events tools and a troubleshooting playbook
+for crashlooping or pending workloads.
+
+--target ssh://[user@]host[:port][/dir] runs the commands on a remote
+machine over SSH, streaming their output, in both modes. The host must be
+allowed in ~/.gptcode/setup.yaml first:
+  gptcode config set run.ssh_hosts "web-1.example.com,*.staging.example.com"
 
 Examples:
   gptcode run                # Start AI-assisted mode
   gptcode run "deploy to staging" --once  # Single AI execution
-  gptcode run "docker ps"    --raw     # Direct command REPL`,
+  gptcode run "restart staging" --yes     # Skip command confirmation (scripts)
+  gptcode run "docker ps"    --raw     # Direct command REPL
+  gptcode run "why is nginx returning 502?" --target ssh://deploy@web-1
+  gptcode run "why is deployment checkout crashlooping?"`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		raw, _ := cmd.Flags().GetBool("raw")
 		once, _ := cmd.Flags().GetBool("once")
+		yes, _ := cmd.Flags().GetBool("yes")
+		targetURL, _ := cmd.Flags().GetString("target")
+		target, err := runTarget(targetURL)
+		if err != nil {
+			return err
+		}
 
 		// Raw mode with command references
 		if raw {
 			// If we have a task, we want to run it and exit
 			if len(args) > 0 && args[0] != "" {
 				task := strings.Join(args, " ")
-				return repl.RunSingleShotCommand(task)
+				return repl.RunSingleShotCommand(task, target)
 			}
 			// Start raw REPL mode (no AI, just command execution)
 			repl := repl.NewRunREPL(20) // Track last 20 commands
+			if target != nil {
+				repl.SetTarget(target)
+			}
 			return repl.Run()
 		}
 
@@ -1589,18 +1578,47 @@ Examples:
 			if err != nil {
 				return err
 			}
-			return modes.RunExecute(builder, provider, model, strings.Fields(input))
+			return modes.RunExecuteWithOptions(builder, provider, model, strings.Fields(input), modes.RunOptions{AutoApprove: yes, Target: target})
 		}
 
 		// Start AI-assisted REPL mode - combine run REPL with AI processing
 		repl := repl.NewRunREPL(20)
+		if target != nil {
+			repl.SetTarget(target)
+		}
 		return repl.Run()
 	},
 }
 
+// runTarget resolves --target to an SSH executor streaming to the
+// terminal, refusing hosts not in run.ssh_hosts; nil runs locally
+func runTarget(targetURL string) (*tools.SSHExecutor, error) {
+	if targetURL == "" {
+		return nil, nil
+	}
+	target, err := tools.ParseSSHTarget(targetURL)
+	if err != nil {
+		return nil, err
+	}
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return nil, err
+	}
+	if !target.Allowed(setup.Run.SSHHosts) {
+		return nil, fmt.Errorf("host %s is not allowed as a run target; allow it with: gptcode config set run.ssh_hosts %q",
+			target.Host, strings.Join(append(setup.Run.SSHHosts, target.Host), ","))
+	}
+	if _, err := exec.LookPath("ssh"); err != nil {
+		return nil, fmt.Errorf("--target needs the ssh client: %w", err)
+	}
+	return &tools.SSHExecutor{Target: target, Stream: os.Stdout}, nil
+}
+
 func init() {
 	runCmd.Flags().Bool("raw", false, "Run direct command REPL mode (no AI)")
 	runCmd.Flags().Bool("once", false, "Run single-shot mode")
+	runCmd.Flags().BoolP("yes", "y", false, "Run proposed commands without asking for confirmation")
+	runCmd.Flags().String("target", "", "Run commands on a remote machine: ssh://[user@]host[:port][/dir]")
 }
 
 var featureCmd = &cobra.Command{
@@ -1798,7 +1816,18 @@ Review a directory:
 Focus on specific aspects:
   gptcode review main.go --focus security
   gptcode review . --focus performance
-  gptcode review src/ --focus "error handling"`,
+  gptcode review src/ --focus "error handling"
+
+Review a pull request, and post the findings as inline comments in one
+review that requests changes on critical or major findings and approves
+otherwise:
+  gptcode review --pr 42
+  gptcode review --pr 42 --post
+
+Findings have a level: error (critical and major), warn (minor) or info
+(nits). Gate CI on them, or upload SARIF to GitHub code scanning:
+  gptcode review . --fail-on error
+  gptcode review . --format sarif > review.sarif`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		target := "."
 		if len(args) > 0 {
@@ -1806,36 +1835,48 @@ Focus on specific aspects:
 		}
 
 		focus, _ := cmd.Flags().GetString("focus")
-
-		return modes.RunReview(modes.ReviewOptions{
-			Target: target,
-			Focus:  focus,
-		})
+		pr, _ := cmd.Flags().GetInt("pr")
+		post, _ := cmd.Flags().GetBool("post")
+		format, _ := cmd.Flags().GetString("format")
+		failOn, _ := cmd.Flags().GetString("fail-on")
+		if post && pr == 0 {
+			return fmt.Errorf("--post needs --pr")
+		}
+
+		opts := modes.ReviewOptions{
+			Target:  target,
+			Focus:   focus,
+			PR:      pr,
+			Post:    post,
+			Format:  format,
+			FailOn:  failOn,
+			Version: version,
+		}
+		if pr > 0 {
+			workDir, _ := os.Getwd()
+			host, err := detectForge(cmd, workDir)
+			if err != nil {
+				return err
+			}
+			opts.Host = host
+		}
+		// A failing gate is an outcome, not a usage error
+		cmd.SilenceUsage = true
+		return modes.RunReview(opts)
 	},
 }
 
 func init() {
 	reviewCmd.Flags().StringP("focus", "f", "", "Focus area for review (e.g., security, performance, error handling)")
+	reviewCmd.Flags().Int("pr", 0, "Review a pull request's diff instead of local files")
+	reviewCmd.Flags().Bool("post", false, "Post the findings as an inline review on the PR (with --pr)")
+	reviewCmd.Flags().String("repo", "", "Repository of the PR (owner/repo, or host/group/repo for self-hosted GitLab)")
+	reviewCmd.Flags().String("format", "text", "Output format: text, json or sarif")
+	reviewCmd.Flags().String("fail-on", "", "Exit non-zero when a finding is at this level or above: error, warn or info")
 }
 
+// detectLanguage returns the language of the project the working
+// directory is in, which in a monorepo is the subproject's
 func detectLanguage() string {
-	if _, err := os.Stat("mix.exs"); err == nil {
-		return "elixir"
-	}
-	if _, err := os.Stat("Gemfile"); err == nil {
-		return "ruby"
-	}
-	if _, err := os.Stat("go.mod"); err == nil {
-		return "go"
-	}
-	if _, err := os.Stat("package.json"); err == nil {
-		return "typescript"
-	}
-	if _, err := os.Stat("requirements.txt"); err == nil {
-		return "python"
-	}
-	if _, err := os.Stat("Cargo.toml"); err == nil {
-		return "rust"
-	}
-	return "unknown"
+	return string(langdetect.DetectLanguage("."))
 }
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/lsp"
+	"gptcode/internal/mcp"
+	"gptcode/internal/output"
+	"gptcode/internal/tools"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Model Context Protocol servers available to the agents",
+	Long: `Use tools from Model Context Protocol (MCP) servers alongside the builtin ones.
+
+Servers are declared in ~/.gptcode/mcp.yaml. A server with a command is
+started and spoken to over stdio; a server with a url is reached over SSE.
+Their tools are offered to the editor as mcp_<server>_<tool> when running
+do, chat, run, implement, feature, tdd and issue fix.
+
+  servers:
+    filesystem:
+      command: npx
+      args: ["-y", "@modelcontextprotocol/server-filesystem", "."]
+    postgres:
+      command: npx
+      args: ["-y", "@modelcontextprotocol/server-postgres", "${DATABASE_URL}"]
+      tools: [query]
+    browser:
+      url: http://localhost:8931/sse
+      timeout: 120
+
+Examples:
+  gptcode mcp list   # Connect to every server and list its tools`,
+}
+
+var mcpListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Connect to the configured MCP servers and list their tools",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := mcp.LoadConfig(mcp.ConfigPath())
+		if err != nil {
+			return err
+		}
+		if len(cfg.Names()) == 0 {
+			fmt.Printf("No MCP servers configured in %s\n", mcp.ConfigPath())
+			return nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		manager, err := mcp.ConnectAll(ctx, cfg, version)
+		defer manager.Close()
+
+		for _, s := range manager.Servers {
+			fmt.Println(output.OKf("%s (%s %s): %d tools", s.Name, s.Client.Server.Name, s.Client.Server.Version, len(s.Tools)))
+			for _, t := range s.Tools {
+				fmt.Printf("  %-40s %s\n", mcp.ToolName(s.Name, t.Name), firstLine(t.Description))
+			}
+		}
+		if err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintln(os.Stderr, output.Warnf("%s", line))
+			}
+			return fmt.Errorf("some MCP servers could not be reached")
+		}
+		return nil
+	},
+}
+
+func init() {
+	mcpCmd.AddCommand(mcpListCmd)
+	rootCmd.AddCommand(mcpCmd)
+}
+
+// startMCP connects to the configured MCP servers for commands that run the
+// editor and offers their tools to it. Servers that fail are reported and
+// skipped; the connections are closed when the command finishes.
+func startMCP(cmd *cobra.Command) {
+	if !runsEditor(cmd) {
+		return
+	}
+	cfg, err := mcp.LoadConfig(mcp.ConfigPath())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, output.Warnf("%v", err))
+		return
+	}
+	if len(cfg.Names()) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	manager, err := mcp.ConnectAll(ctx, cfg, version)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, output.Warnf("%v", err))
+	}
+	if n := manager.Register(); n > 0 && os.Getenv("GPTCODE_DEBUG") == "1" {
+		fmt.Fprintf(os.Stderr, "[MCP] Registered %d tools: %s\n", n, strings.Join(manager.ToolNames(), ", "))
+	}
+	cobra.OnFinalize(manager.Close)
+}
+
+// runsEditor reports whether cmd runs agents that take extra tools
+func runsEditor(cmd *cobra.Command) bool {
+	switch cmd {
+	case doCmd, chatCmd, runCmd, implementCmd, featureCmd, tddCmd, issueFixCmd, issueFixAllCmd, agentRunCmd, serveAPICmd:
+		return true
+	}
+	return false
+}
+
+// startShellTools offers the shell tools declared in the project's
+// .gptcode/config.yml to the agents of commands that run the editor. They
+// run in the project root.
+func startShellTools(cmd *cobra.Command) {
+	if !runsEditor(cmd) {
+		return
+	}
+	cwd, _ := os.Getwd()
+	pc, err := config.LoadProjectConfig(cwd)
+	if err != nil || len(pc.Tools) == 0 {
+		return
+	}
+	shellTools := make([]tools.ShellTool, 0, len(pc.Tools))
+	for _, t := range pc.Tools {
+		shellTools = append(shellTools, tools.ShellTool{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.Parameters,
+			Command:     t.Command,
+			Timeout:     t.Timeout,
+		})
+	}
+	if err := tools.RegisterShellTools(shellTools, pc.Root); err != nil {
+		fmt.Fprintln(os.Stderr, output.Warnf("%s: %v", config.ProjectConfigFile, err))
+	}
+}
+
+// startLSP offers the agents of commands that run the editor tools backed
+// by the project's language server. The server is started the first time
+// one of them is called.
+func startLSP(cmd *cobra.Command) {
+	if !runsEditor(cmd) {
+		return
+	}
+	cwd, _ := os.Getwd()
+	var command []string
+	if pc, err := config.LoadProjectConfig(cwd); err == nil {
+		if pc.LSP.Disabled {
+			return
+		}
+		command = pc.LSP.Command
+	}
+	project := langdetect.Detect(cwd)
+	if len(command) == 0 {
+		command = lsp.DefaultCommand(project.Language)
+	}
+	if len(command) == 0 {
+		return
+	}
+	manager := lsp.NewManager(command, project.Root, cwd)
+	for _, t := range manager.Tools() {
+		tools.RegisterExternalTool(t)
+	}
+	cobra.OnFinalize(manager.Close)
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/memory"
+	"gptcode/internal/output"
+)
+
+var memoryCmd = &cobra.Command{
+	Use:   "memory",
+	Short: "Manage what gptcode remembers about this project",
+	Long: `Manage the project's long-term memory.
+
+Facts are kept in .gptcode/memory.jsonl at the project root and included in
+later prompts. Commit the file to share the memory with your team.
+
+With defaults.learn_memory on, gptcode also asks the model at the end of
+chat sessions and completed gptcode do tasks for the durable facts the
+session taught about the project (architecture decisions, conventions and
+gotchas) and keeps the new ones. That costs one more model call per
+session, so it is off by default:
+
+  gptcode config set defaults.learn_memory true
+
+Examples:
+  gptcode memory list
+  gptcode memory add "Migrations run with make migrate, never with mix ecto.migrate"
+  gptcode memory add --kind gotcha "The CI runner has no network access"
+  gptcode memory forget 3f2a9c1e`,
+}
+
+var memoryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the facts remembered about this project",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kind, _ := cmd.Flags().GetString("kind")
+		store, err := projectMemory()
+		if err != nil {
+			return err
+		}
+		facts, err := store.List()
+		if err != nil {
+			return err
+		}
+		shown := 0
+		for _, f := range facts {
+			if kind != "" && f.Kind != kind {
+				continue
+			}
+			fmt.Printf("%s  %-10s %s  (%s, %s)\n", f.ID, f.Kind, f.Text, f.Source, f.Timestamp.Format("2006-01-02"))
+			shown++
+		}
+		if shown == 0 {
+			fmt.Println("Nothing remembered yet")
+		}
+		return nil
+	},
+}
+
+var memoryAddCmd = &cobra.Command{
+	Use:   "add <fact>",
+	Short: "Remember a fact about this project",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kind, _ := cmd.Flags().GetString("kind")
+		switch kind {
+		case memory.KindDecision, memory.KindConvention, memory.KindGotcha, memory.KindNote:
+		default:
+			return fmt.Errorf("unknown kind %q (decision, convention, gotcha or note)", kind)
+		}
+		store, err := projectMemory()
+		if err != nil {
+			return err
+		}
+		added, err := store.Add(memory.Fact{Kind: kind, Text: strings.Join(args, " "), Source: "user"})
+		if err != nil {
+			return err
+		}
+		if len(added) == 0 {
+			fmt.Println(output.Warnf("Already remembered"))
+			return nil
+		}
+		fmt.Println(output.OKf("Remembered %s", added[0].ID))
+		return nil
+	},
+}
+
+var memoryForgetCmd = &cobra.Command{
+	Use:   "forget <id>",
+	Short: "Forget a fact; a prefix of its id is enough",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := projectMemory()
+		if err != nil {
+			return err
+		}
+		fact, err := store.Forget(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(output.OKf("Forgot: %s", fact.Text))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(memoryCmd)
+	memoryCmd.AddCommand(memoryListCmd)
+	memoryCmd.AddCommand(memoryAddCmd)
+	memoryCmd.AddCommand(memoryForgetCmd)
+
+	memoryListCmd.Flags().String("kind", "", "Only list facts of this kind")
+	memoryAddCmd.Flags().String("kind", memory.KindNote, "Kind of fact: decision, convention, gotcha or note")
+}
+
+func projectMemory() (*memory.ProjectStore, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewProjectStore(cwd), nil
+}
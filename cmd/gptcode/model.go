@@ -14,6 +14,7 @@ import (
 	"gptcode/internal/catalog"
 	"gptcode/internal/config"
 	"gptcode/internal/intelligence"
+	"gptcode/internal/output"
 )
 
 var modelCmd = &cobra.Command{
@@ -60,7 +61,7 @@ Examples:
 			return fmt.Errorf("invalid agent type '%s'. Must be one of: editor, query, research, router", agentType)
 		}
 
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -80,7 +81,7 @@ Examples:
 }
 
 func showAllRecommendations() error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load setup: %w", err)
 	}
@@ -133,7 +134,7 @@ Use --recommended flag to show only recommended models for current setup.`,
 			return fmt.Errorf("failed to load catalog: %w\nRun 'gptcode model update --all' to create catalog", err)
 		}
 
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -280,7 +281,7 @@ func setDefaultModel(modelName string) error {
 	// Verify model exists in catalog
 	catalogData, err := catalog.Load()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[WARN] Could not load catalog: %v\n", err)
+		fmt.Fprintln(os.Stderr, output.Warnf("Could not load catalog: %v", err))
 		fmt.Println("Proceeding anyway - model may not be validated")
 	} else {
 		found := false
@@ -299,7 +300,7 @@ func setDefaultModel(modelName string) error {
 		}
 
 		if !found {
-			fmt.Fprintf(os.Stderr, "[WARN] Model '%s' not found in catalog\n", modelName)
+			fmt.Fprintln(os.Stderr, output.Warnf("Model '%s' not found in catalog", modelName))
 			fmt.Println("Run 'gptcode model list' to see available models")
 			fmt.Println("Proceeding anyway - model may work if backend supports it")
 		}
@@ -330,7 +331,8 @@ func setDefaultModel(modelName string) error {
 func updateSingleModel(modelName string) error {
 	fmt.Printf("Updating model: %s\n", modelName)
 
-	catalogPath := filepath.Join(os.Getenv("HOME"), ".gptcode", "models_catalog.json")
+	home, _ := os.UserHomeDir()
+	catalogPath := filepath.Join(home, ".gptcode", "models_catalog.json")
 
 	if _, err := os.Stat(catalogPath); os.IsNotExist(err) {
 		fmt.Println("No catalog found. Running full update first...")
@@ -419,7 +421,7 @@ func installOllamaModel(modelName string) error {
 func updateCatalogFromAllProviders() error {
 	fmt.Println("Fetching models from all providers...")
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load setup: %w", err)
 	}
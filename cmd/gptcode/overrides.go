@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/output"
+)
+
+func init() {
+	addModelOverrideFlags(chatCmd.Flags(), doCmd.Flags(), runCmd.Flags(), reviewCmd.Flags(),
+		researchCmd.Flags(), planCmd.Flags(), implementCmd.Flags(), featureCmd.Flags(),
+		issueCmd.PersistentFlags(), agentRunCmd.Flags())
+	rootCmd.PersistentFlags().String("style", "", "Output style: emoji, ascii or plain (overrides output.style)")
+	rootCmd.PersistentFlags().String("theme", "", "Color theme: tokyonight, solarized or gruvbox (overrides output.theme)")
+	rootCmd.PersistentFlags().Bool("accessible", false, "Screen-reader friendly output: plain progress lines, no spinners (overrides output.accessible)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		// Overrides come first so the hooks below load the setup with them
+		if err := applyModelOverrides(cmd, args); err != nil {
+			return err
+		}
+		if err := configureOutput(cmd); err != nil {
+			return err
+		}
+		session := os.Getenv("GPTCODE_SESSION")
+		if session == "" {
+			session = uuid.NewString()
+		}
+		startCostMeter(cmd, session)
+		startTranscript(cmd, session)
+		startJournal(cmd, session)
+		startDiffBudget(cmd)
+		startCommandPolicy(cmd)
+		startSandbox(cmd)
+		startMCP(cmd)
+		startShellTools(cmd)
+		startLSP(cmd)
+		startSemanticSearch(cmd)
+		startFeedbackEmbeddings(cmd)
+		startCache(cmd)
+		return nil
+	}
+}
+
+// configureOutput selects the output style from --style/--theme/--accessible,
+// falling back to the output section of setup.yaml.
+func configureOutput(cmd *cobra.Command) error {
+	style, _ := cmd.Flags().GetString("style")
+	theme, _ := cmd.Flags().GetString("theme")
+	accessible, _ := cmd.Flags().GetBool("accessible")
+	if setup, err := config.LoadEffectiveSetup(); err == nil {
+		if !cmd.Flags().Changed("accessible") {
+			accessible = setup.Output.Accessible
+		}
+		if style == "" && os.Getenv("GPTCODE_OUTPUT_STYLE") == "" {
+			style = setup.Output.Style
+		}
+		if theme == "" {
+			theme = setup.Output.Theme
+		}
+	}
+	if err := output.Configure(style, theme); err != nil {
+		return err
+	}
+	if accessible {
+		output.SetAccessible(true)
+	}
+	return nil
+}
+
+type flagSet interface {
+	String(name, value, usage string) *string
+}
+
+// addModelOverrideFlags registers --backend, --profile and --model, which
+// override setup.yaml defaults for a single invocation.
+func addModelOverrideFlags(sets ...flagSet) {
+	for _, fs := range sets {
+		fs.String("backend", "", "Backend to use for this invocation (overrides defaults.backend)")
+		fs.String("profile", "", "Profile to use for this invocation (overrides defaults.profile)")
+		fs.String("model", "", "Model to use for every agent in this invocation")
+	}
+}
+
+// applyModelOverrides installs the override flags of the running command so
+// every later config.LoadEffectiveSetup sees them.
+func applyModelOverrides(cmd *cobra.Command, args []string) error {
+	var o config.Overrides
+	for name, dst := range map[string]*string{"backend": &o.Backend, "profile": &o.Profile, "model": &o.Model} {
+		if f := cmd.Flags().Lookup(name); f != nil {
+			*dst = f.Value.String()
+		}
+	}
+	if o.IsZero() {
+		return nil
+	}
+
+	config.SetOverrides(o)
+	if _, err := config.LoadEffectiveSetup(); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("invalid override: %w", err)
+	}
+	if os.Getenv("GPTCODE_DEBUG") == "1" {
+		fmt.Fprintf(os.Stderr, "[CONFIG] Overrides: %s\n", o)
+	}
+	return nil
+}
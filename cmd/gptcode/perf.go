@@ -1,12 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"gptcode/internal/config"
+	"gptcode/internal/graph"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+	"gptcode/internal/output"
+	"gptcode/internal/perf"
+	"gptcode/internal/tools"
 )
 
 var perfCmd = &cobra.Command{
@@ -16,24 +27,48 @@ var perfCmd = &cobra.Command{
 }
 
 var perfProfileCmd = &cobra.Command{
-	Use:   "profile [target]",
+	Use:   "profile [package]",
 	Short: "Run performance profiling",
-	Long: `Profile application performance (CPU, memory, etc).
+	Long: `Profile a package's benchmarks and suggest optimizations for its hotspots.
+
+The benchmarks of the package (default .) run with CPU and heap profiling,
+writing cpu.prof and mem.prof. The project functions that cost the most
+time and allocated memory are listed with their hot lines and the files
+next to them in the dependency graph, and the editor model proposes
+optimizations as a unified diff, saved to perf-suggestions.patch.
+--apply applies it; all files change or none does.
+
+Profiles collected elsewhere, e.g. from a server through net/http/pprof,
+are analyzed with --cpu and --mem instead, with --binary to symbolize
+them.
 
 Examples:
-  gptcode perf profile          # Profile with go test
-  gptcode perf profile ./cmd    # Profile specific package`,
+  gptcode perf profile                    # Profile the current package
+  gptcode perf profile ./internal/graph --bench BenchmarkBuild
+  gptcode perf profile --cpu cpu.prof --binary ./bin/server
+  gptcode perf profile --apply`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runPerfProfile,
 }
 
 var perfBenchCmd = &cobra.Command{
 	Use:   "bench [pattern]",
 	Short: "Run benchmarks and analyze results",
-	Long: `Run benchmarks and provide optimization suggestions.
+	Long: `Run benchmarks and compare them with a baseline to catch regressions.
+
+Each benchmark runs --count times and the results are saved per commit in
+.gptcode/bench/<commit>.json. They are then compared with the results of
+the nearest earlier commit that has some, or of --baseline, the way
+benchstat does: a change counts only when the Mann-Whitney U test finds it
+significant (p < 0.05). A significant slowdown, or growth in B/op or
+allocs/op, beyond --threshold percent fails the command with a report and
+an explanation of the diff since the baseline that most likely caused it.
 
 Examples:
-  gptcode perf bench          # Run all benchmarks
-  gptcode perf bench BenchmarkFoo  # Run specific benchmark`,
+  gptcode perf bench                      # Run all benchmarks
+  gptcode perf bench BenchmarkFoo         # Run specific benchmark
+  gptcode perf bench --baseline main      # Compare with main's results
+  gptcode perf bench --threshold 10 --count 10`,
 	RunE: runPerfBench,
 }
 
@@ -41,65 +76,284 @@ func init() {
 	rootCmd.AddCommand(perfCmd)
 	perfCmd.AddCommand(perfProfileCmd)
 	perfCmd.AddCommand(perfBenchCmd)
+
+	perfProfileCmd.Flags().String("bench", ".", "Benchmarks to profile")
+	perfProfileCmd.Flags().String("cpu", "", "Analyze this CPU profile instead of running benchmarks")
+	perfProfileCmd.Flags().String("mem", "", "Analyze this heap profile instead of running benchmarks")
+	perfProfileCmd.Flags().String("binary", "", "Binary the profiles came from, to symbolize them")
+	perfProfileCmd.Flags().Int("top", 5, "Hotspots to report and optimize per profile")
+	perfProfileCmd.Flags().Bool("no-suggest", false, "Only report the hotspots")
+	perfProfileCmd.Flags().Bool("apply", false, "Apply the suggested patches")
+	perfProfileCmd.Flags().String("model", "", "LLM model to use for suggestions (default: the editor model)")
+
+	perfBenchCmd.Flags().String("package", "./...", "Packages to benchmark")
+	perfBenchCmd.Flags().Int("count", 5, "Runs of each benchmark, for the significance test")
+	perfBenchCmd.Flags().String("baseline", "", "Commit to compare with (default: the nearest earlier commit with results)")
+	perfBenchCmd.Flags().Float64("threshold", 5, "Regression threshold, in percent")
+	perfBenchCmd.Flags().Bool("no-explain", false, "Don't ask the model to explain regressions")
+	perfBenchCmd.Flags().String("model", "", "LLM model to use for explanations (default: from config)")
 }
 
 func runPerfProfile(cmd *cobra.Command, args []string) error {
-	target := "./..."
+	target := "."
 	if len(args) > 0 {
 		target = args[0]
 	}
+	benchPattern, _ := cmd.Flags().GetString("bench")
+	cpuProfile, _ := cmd.Flags().GetString("cpu")
+	memProfile, _ := cmd.Flags().GetString("mem")
+	binary, _ := cmd.Flags().GetString("binary")
+	top, _ := cmd.Flags().GetInt("top")
+	noSuggest, _ := cmd.Flags().GetBool("no-suggest")
+	apply, _ := cmd.Flags().GetBool("apply")
 
-	fmt.Println("🔥 Starting performance profiling...")
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
 
-	cpuCmd := exec.Command("go", "test", "-cpuprofile=cpu.prof", "-memprofile=mem.prof", target)
-	cpuCmd.Stdout = os.Stdout
-	cpuCmd.Stderr = os.Stderr
+	if cpuProfile == "" && memProfile == "" {
+		fmt.Println(output.Infof("Starting performance profiling..."))
+		tmp, err := os.MkdirTemp("", "gptcode-perf-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		// go test keeps the test binary when profiling; it goes to tmp
+		binary = filepath.Join(tmp, "bench.test")
+		cpuProfile, memProfile = "cpu.prof", "mem.prof"
+
+		profCmd := exec.Command("go", "test", "-run", "^$", "-bench", benchPattern,
+			"-cpuprofile="+cpuProfile, "-memprofile="+memProfile, "-o", binary, target)
+		profCmd.Stdout = os.Stdout
+		profCmd.Stderr = os.Stderr
+		if err := profCmd.Run(); err != nil {
+			return fmt.Errorf("profiling failed: %w", err)
+		}
+
+		fmt.Println("\n" + output.OKf("Profiling complete!"))
+		fmt.Println("\n" + output.Infof("Generated profiles:"))
+		fmt.Println("  - cpu.prof (CPU profile)")
+		fmt.Println("  - mem.prof (Memory profile)")
+	}
+
+	root := config.ProjectRoot(cwd)
+	g, err := graph.NewBuilder(root).Build()
+	if err != nil {
+		g = nil
+	} else {
+		g.PageRank(0.85, 20)
+	}
 
-	if err := cpuCmd.Run(); err != nil {
-		return fmt.Errorf("profiling failed: %w", err)
+	type profile struct {
+		kind, path, sampleIndex string
 	}
+	var suggestions []string
+	for _, p := range []profile{{"CPU", cpuProfile, ""}, {"heap allocations", memProfile, "alloc_space"}} {
+		if p.path == "" {
+			continue
+		}
+		frames, err := perf.Top(p.path, binary, p.sampleIndex)
+		if err != nil {
+			return err
+		}
+		hotspots := perf.Hotspots(frames, root, g, top)
+		fmt.Println("\n" + output.Infof("%s hotspots in %s:", strings.ToUpper(p.kind[:1])+p.kind[1:], p.path))
+		if len(hotspots) == 0 {
+			fmt.Println("  (none in the project's code)")
+			continue
+		}
+		for _, h := range hotspots {
+			fmt.Printf("  %5.1f%% cum %5.1f%% flat  %s  %s:%d\n", h.CumPercent, h.FlatPercent, h.Function, h.File, h.Lines[0])
+			if len(h.Related) > 0 {
+				fmt.Printf("                         related: %s\n", strings.Join(h.Related, ", "))
+			}
+		}
+		if noSuggest {
+			continue
+		}
 
-	fmt.Println("\n✅ Profiling complete!")
-	fmt.Println("\n📊 Generated profiles:")
-	fmt.Println("  - cpu.prof (CPU profile)")
-	fmt.Println("  - mem.prof (Memory profile)")
-	fmt.Println("\n💡 Analyze with:")
-	fmt.Println("  go tool pprof cpu.prof")
-	fmt.Println("  go tool pprof mem.prof")
+		s, err := suggestOptimizations(cmd, root, p.kind, hotspots)
+		if err != nil {
+			fmt.Println("\n" + output.Warnf("Could not get suggestions: %v", err))
+			continue
+		}
+		fmt.Println("\n" + output.Infof("Suggestions for %s:\n\n%s", p.kind, s.Text))
+		if s.Diff != "" {
+			suggestions = append(suggestions, s.Diff)
+		}
+	}
 
+	if len(suggestions) == 0 {
+		if noSuggest {
+			fmt.Println("\n" + output.Infof("Analyze further with: go tool pprof %s", cpuProfile))
+		}
+		return nil
+	}
+	// The CPU and heap patches may touch the same file, so they are
+	// applied one after the other
+	const patchFile = "perf-suggestions.patch"
+	if err := os.WriteFile(patchFile, []byte(strings.Join(suggestions, "\n")), 0644); err != nil {
+		return err
+	}
+	fmt.Println("\n" + output.Infof("Patches saved to %s", patchFile))
+	if !apply {
+		fmt.Println("   Apply them with --apply or: git apply " + patchFile)
+		return nil
+	}
+	for _, diff := range suggestions {
+		res := tools.ApplyDiff(tools.ToolCall{Name: "apply_diff", Arguments: map[string]interface{}{"diff": diff}}, root)
+		if res.Error != "" {
+			return fmt.Errorf("could not apply the suggested patch: %s", res.Error)
+		}
+		fmt.Println(res.Result)
+	}
+	fmt.Println(output.OKf("Applied; run the benchmarks again to check the gain: gptcode perf bench"))
 	return nil
 }
 
+// suggestOptimizations asks the editor model for patches to the hotspots
+func suggestOptimizations(cmd *cobra.Command, root, kind string, hotspots []perf.Hotspot) (*perf.Suggestion, error) {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	model, _ := cmd.Flags().GetString("model")
+	backendName := setup.Defaults.Backend
+	backendCfg, ok := setup.Backend[backendName]
+	if !ok {
+		return nil, fmt.Errorf("backend %s not configured", backendName)
+	}
+	if model == "" {
+		model = backendCfg.GetModelForAgent("editor")
+		if model == "" {
+			model = backendCfg.DefaultModel
+		}
+	}
+	provider := llm.NewForBackend(backendName, backendCfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	return perf.Suggest(ctx, provider, model, root, kind, hotspots)
+}
+
 func runPerfBench(cmd *cobra.Command, args []string) error {
 	pattern := ""
 	if len(args) > 0 {
 		pattern = args[0]
 	}
+	pkg, _ := cmd.Flags().GetString("package")
+	count, _ := cmd.Flags().GetInt("count")
+	baselineRef, _ := cmd.Flags().GetString("baseline")
+	threshold, _ := cmd.Flags().GetFloat64("threshold")
+	noExplain, _ := cmd.Flags().GetBool("no-explain")
+	if count < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(output.Infof("Running benchmarks..."))
+	benchOut, err := perf.RunBench(cwd, pkg, pattern, count)
+	fmt.Print(benchOut)
+	if err != nil {
+		return err
+	}
+	benchmarks := perf.ParseBench(benchOut)
+	if len(benchmarks) == 0 {
+		fmt.Println("\n" + output.Infof("No benchmarks ran"))
+		return nil
+	}
+
+	root := langdetect.RepoRoot(cwd)
+	if root == "" {
+		fmt.Println("\n" + output.Infof("Not a git repository: results are not saved or compared"))
+		printBenchTips(benchOut)
+		return nil
+	}
+	commit, dirty, err := perf.Head(root)
+	if err != nil {
+		return err
+	}
+	run := &perf.Run{Commit: commit, Dirty: dirty, Time: time.Now(), Benchmarks: benchmarks}
+	if err := run.Save(root); err != nil {
+		return fmt.Errorf("failed to save benchmark results: %w", err)
+	}
+	fmt.Println("\n" + output.Infof("Saved results for %s", commit[:12]))
+
+	var base *perf.Run
+	if baselineRef != "" {
+		base, err = perf.Load(root, baselineRef)
+	} else {
+		base, err = perf.Baseline(root)
+	}
+	if errors.Is(err, perf.ErrNoBaseline) {
+		fmt.Println(output.Infof("No baseline yet: later runs will be compared with these results"))
+		printBenchTips(benchOut)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-	fmt.Println("⚡ Running benchmarks...")
+	comparison := perf.Compare(base, run, threshold)
+	fmt.Println()
+	fmt.Print(comparison.Report())
 
-	benchArgs := []string{"test", "-bench", "."}
-	if pattern != "" {
-		benchArgs = []string{"test", "-bench", pattern}
+	regressions := comparison.Regressions()
+	if len(regressions) == 0 {
+		fmt.Println("\n" + output.OKf("No regressions beyond the threshold"))
+		return nil
 	}
-	benchArgs = append(benchArgs, "-benchmem", "./...")
 
-	benchCmd := exec.Command("go", benchArgs...)
-	output, err := benchCmd.CombinedOutput()
+	if !noExplain {
+		if explanation, err := explainRegressions(cmd, root, comparison); err != nil {
+			fmt.Println("\n" + output.Warnf("Could not explain the regressions: %v", err))
+		} else if explanation != "" {
+			fmt.Println("\n" + output.Infof("Likely causes:\n\n%s", explanation))
+		}
+	}
+	return fmt.Errorf("%d benchmark regression(s) beyond %.1f%% since %s", len(regressions), threshold, base.Commit[:12])
+}
 
-	fmt.Print(string(output))
+// explainRegressions asks the configured model which changes since the
+// baseline caused the comparison's regressions
+func explainRegressions(cmd *cobra.Command, root string, comparison *perf.Comparison) (string, error) {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	model, _ := cmd.Flags().GetString("model")
+	backendName := setup.Defaults.Backend
+	backendCfg, ok := setup.Backend[backendName]
+	if !ok {
+		return "", fmt.Errorf("backend %s not configured", backendName)
+	}
+	if model == "" {
+		model = backendCfg.GetModelForAgent("query")
+		if model == "" {
+			model = backendCfg.DefaultModel
+		}
+	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
-	if err != nil && !strings.Contains(string(output), "PASS") {
-		return fmt.Errorf("benchmarks failed: %w", err)
+	diff, err := perf.Diff(root, comparison.Base)
+	if err != nil {
+		return "", err
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	return perf.Explain(ctx, provider, model, comparison, diff)
+}
 
-	fmt.Println("\n💡 Optimization tips:")
-	if strings.Contains(string(output), "allocs/op") {
+func printBenchTips(benchOut string) {
+	fmt.Println("\n" + output.Infof("Optimization tips:"))
+	if strings.Contains(benchOut, "allocs/op") {
 		fmt.Println("  - Review allocations for hot paths")
 		fmt.Println("  - Consider object pooling for frequent allocations")
 	}
 	fmt.Println("  - Profile with: gptcode perf profile")
-	fmt.Println("  - Compare with: benchstat old.txt new.txt")
-
-	return nil
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/config"
+	"gptcode/internal/output"
+	"gptcode/internal/tools"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Bool("yolo", false, "Run every command the agents ask for, without the command policy's denials and confirmations")
+}
+
+// startSandbox selects where run_command, shell tools and validation
+// commands run: in a disposable container with tools.sandbox docker, on the
+// host otherwise. Without docker on the
+// PATH the sandboxed commands fail rather than run on the host.
+func startSandbox(cmd *cobra.Command) {
+	setup, _ := config.LoadEffectiveSetup()
+	switch sandbox := setup.Tools.Sandbox; sandbox {
+	case "", "none":
+		tools.SetExecutor(nil)
+		return
+	case "docker":
+	default:
+		fmt.Fprintln(os.Stderr, output.Warnf("unknown sandbox %q; commands run in docker", sandbox))
+	}
+
+	docker := setup.Tools.Docker
+	tools.SetExecutor(&tools.DockerExecutor{
+		Images:  docker.Images,
+		Network: docker.Network,
+		Memory:  docker.Memory,
+		CPUs:    docker.CPUs,
+		Env:     docker.Env,
+	})
+	if runsEditor(cmd) {
+		if _, err := exec.LookPath("docker"); err != nil {
+			fmt.Fprintln(os.Stderr, output.Warnf("tools.sandbox is docker but docker is not installed; run_command will fail"))
+		}
+	}
+}
+
+// startCommandPolicy installs the policy run_command checks: the built-in
+// rules plus the project's policy.deny and policy.confirm patterns. Commands
+// to confirm are asked about on the terminal, and refused without one.
+func startCommandPolicy(cmd *cobra.Command) {
+	if yolo, _ := cmd.Flags().GetBool("yolo"); yolo {
+		tools.SetCommandPolicy(nil)
+		fmt.Fprintln(os.Stderr, output.Warnf("--yolo: the command policy is off, agents run any command"))
+		return
+	}
+	cwd, _ := os.Getwd()
+	pc, _ := config.LoadProjectConfig(cwd)
+	policy := tools.NewCommandPolicy(pc.Policy.Deny.Commands, pc.Policy.Confirm.Commands)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		policy.Confirm = projectPermissions(cwd)
+	}
+	tools.SetCommandPolicy(policy)
+}
+
+// projectPermissions returns the permissions of a supervised run in dir:
+// commands and writes outside the project are asked about on the terminal,
+// except those the project policy in .gptcode/config.yml allows. Answering
+// "always for this project" adds the operation to that policy.
+func projectPermissions(dir string) *tools.Permissions {
+	pc, _ := config.LoadProjectConfig(dir)
+	root := pc.Root
+
+	perms := tools.NewPermissions(root, pc.Policy.Allow.Commands, pc.Policy.Allow.Paths,
+		tools.TerminalPermissionPrompter(os.Stdin, os.Stderr))
+	perms.Remember = func(kind tools.PermissionKind, pattern string) error {
+		return config.AllowInProjectPolicy(root, string(kind), pattern)
+	}
+	return perms
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/modes"
+	"gptcode/internal/output"
+)
+
+var planStatusCmd = &cobra.Command{
+	Use:   "status <plan_file>",
+	Short: "Show a plan's phases and how far implement got",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPlanStatus,
+}
+
+var planSkipPhaseCmd = &cobra.Command{
+	Use:   "skip-phase <plan_file> <phase>",
+	Short: "Mark a phase skipped so implement moves past it",
+	Long: `Mark a phase, given by number (from 1) or name, as skipped. implement
+resumes at the first phase that is neither done nor skipped.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runPlanSkipPhase,
+}
+
+var planEditCmd = &cobra.Command{
+	Use:   "edit <plan_file>",
+	Short: "Edit a plan's phases",
+	Long: `Edit a plan's phases with flags, or open the plan in $EDITOR when none is
+given. An edited plan must still have valid front matter, or it is not
+saved. A plan without front matter is upgraded to the current format.
+
+Examples:
+  gptcode plan edit plan.md                                  Open in $EDITOR
+  gptcode plan edit plan.md --phase 2 --add-verify "go test ./internal/auth/..."
+  gptcode plan edit plan.md --phase "Login handler" --status pending
+  gptcode plan edit plan.md --add-phase "Docs" --add-file docs/auth.md`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPlanEdit,
+}
+
+func init() {
+	planCmd.AddCommand(planStatusCmd)
+	planCmd.AddCommand(planSkipPhaseCmd)
+	planCmd.AddCommand(planEditCmd)
+
+	planEditCmd.Flags().String("phase", "", "Phase to change, by number (from 1) or name")
+	planEditCmd.Flags().String("add-phase", "", "Append a phase with this name and change it")
+	planEditCmd.Flags().String("name", "", "Rename the phase")
+	planEditCmd.Flags().String("status", "", "Set the phase's status: "+modes.PhaseStatusNames())
+	planEditCmd.Flags().StringSlice("add-file", nil, "Add a file the phase changes")
+	planEditCmd.Flags().StringSlice("remove-file", nil, "Remove a file from the phase")
+	planEditCmd.Flags().StringSlice("add-verify", nil, "Add a command verifying the phase")
+	planEditCmd.Flags().Bool("clear-verify", false, "Remove the phase's verify commands")
+	planEditCmd.Flags().String("task", "", "Set the plan's task")
+}
+
+func runPlanStatus(cmd *cobra.Command, args []string) error {
+	plan, err := modes.LoadPlan(args[0])
+	if err != nil {
+		return err
+	}
+	title := plan.Task
+	if title == "" {
+		title = filepath.Base(args[0])
+	}
+	finished := 0
+	for _, phase := range plan.Phases {
+		if phase.Status.Finished() {
+			finished++
+		}
+	}
+	fmt.Printf("%s\n\n", output.Infof("%s (%d/%d phases done or skipped)", title, finished, len(plan.Phases)))
+	if len(plan.Phases) == 0 {
+		fmt.Println("The plan has no phases; add them with gptcode plan edit.")
+		return nil
+	}
+
+	next := plan.Next()
+	for i, phase := range plan.Phases {
+		marker := " "
+		if i == next {
+			marker = "▸"
+		}
+		fmt.Printf(" %s %d. %-40s %s\n", marker, i+1, phase.Name, phase.Status)
+		if len(phase.Files) > 0 {
+			fmt.Printf("      files:  %s\n", strings.Join(phase.Files, ", "))
+		}
+		for _, command := range phase.Verify {
+			fmt.Printf("      verify: %s\n", command)
+		}
+	}
+	if next < 0 {
+		fmt.Println("\nAll phases are done or skipped.")
+	}
+	return nil
+}
+
+func runPlanSkipPhase(cmd *cobra.Command, args []string) error {
+	plan, err := modes.LoadPlan(args[0])
+	if err != nil {
+		return err
+	}
+	i, err := plan.Find(args[1])
+	if err != nil {
+		return err
+	}
+	plan.Phases[i].Status = modes.PhaseSkipped
+	if err := plan.Save(args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "⊘ Skipped phase %d: %s\n", i+1, plan.Phases[i].Name)
+	return nil
+}
+
+func runPlanEdit(cmd *cobra.Command, args []string) error {
+	planPath := args[0]
+	if cmd.Flags().NFlag() == 0 {
+		return editPlanInEditor(planPath)
+	}
+
+	plan, err := modes.LoadPlan(planPath)
+	if err != nil {
+		return err
+	}
+	if task, _ := cmd.Flags().GetString("task"); task != "" {
+		plan.Task = task
+	}
+
+	ref, _ := cmd.Flags().GetString("phase")
+	added, _ := cmd.Flags().GetString("add-phase")
+	i := -1
+	switch {
+	case ref != "" && added != "":
+		return fmt.Errorf("use --phase or --add-phase, not both")
+	case added != "":
+		plan.Phases = append(plan.Phases, modes.PlanPhase{Name: added, Status: modes.PhasePending})
+		i = len(plan.Phases) - 1
+	case ref != "":
+		if i, err = plan.Find(ref); err != nil {
+			return err
+		}
+	}
+
+	changesPhase := false
+	for _, name := range []string{"name", "status", "add-file", "remove-file", "add-verify", "clear-verify"} {
+		changesPhase = changesPhase || cmd.Flags().Changed(name)
+	}
+	if changesPhase && i < 0 {
+		return fmt.Errorf("--phase or --add-phase is required to change a phase")
+	}
+	if i >= 0 {
+		phase := &plan.Phases[i]
+		if name, _ := cmd.Flags().GetString("name"); name != "" {
+			phase.Name = name
+		}
+		if status, _ := cmd.Flags().GetString("status"); status != "" {
+			if !modes.PhaseStatus(status).Valid() {
+				return fmt.Errorf("invalid status %q, expected one of %s", status, modes.PhaseStatusNames())
+			}
+			phase.Status = modes.PhaseStatus(status)
+		}
+		addFiles, _ := cmd.Flags().GetStringSlice("add-file")
+		for _, f := range addFiles {
+			if !slices.Contains(phase.Files, f) {
+				phase.Files = append(phase.Files, f)
+			}
+		}
+		removeFiles, _ := cmd.Flags().GetStringSlice("remove-file")
+		for _, f := range removeFiles {
+			j := slices.Index(phase.Files, f)
+			if j < 0 {
+				return fmt.Errorf("phase %d does not list %s", i+1, f)
+			}
+			phase.Files = slices.Delete(phase.Files, j, j+1)
+		}
+		if clearVerify, _ := cmd.Flags().GetBool("clear-verify"); clearVerify {
+			phase.Verify = nil
+		}
+		addVerify, _ := cmd.Flags().GetStringSlice("add-verify")
+		phase.Verify = append(phase.Verify, addVerify...)
+	}
+
+	if err := plan.Save(planPath); err != nil {
+		return err
+	}
+	if i >= 0 {
+		fmt.Fprintln(os.Stderr, output.OKf("Phase %d updated: %s (%s)", i+1, plan.Phases[i].Name, plan.Phases[i].Status))
+	} else {
+		fmt.Fprintln(os.Stderr, output.OKf("Plan updated: %s", planPath))
+	}
+	return nil
+}
+
+// editPlanInEditor opens a copy of the plan in $EDITOR and saves it back
+// when its front matter is still valid
+func editPlanInEditor(planPath string) error {
+	original, err := os.ReadFile(planPath)
+	if err != nil {
+		return fmt.Errorf("could not read plan file: %w", err)
+	}
+	tmp, err := os.CreateTemp("", "gptcode-plan-*.md")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(original); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vim"
+	}
+	edit := exec.Command("sh", "-c", editor+` "$1"`, "sh", tmp.Name())
+	edit.Stdin = os.Stdin
+	edit.Stdout = os.Stdout
+	edit.Stderr = os.Stderr
+	if err := edit.Run(); err != nil {
+		return fmt.Errorf("editor failed: %w", err)
+	}
+
+	edited, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return err
+	}
+	if string(edited) == string(original) {
+		fmt.Fprintln(os.Stderr, "Plan unchanged")
+		return nil
+	}
+	plan, err := modes.ParsePlanFile(string(edited))
+	if err != nil {
+		return fmt.Errorf("the plan was not saved: %w", err)
+	}
+	if err := os.WriteFile(planPath, edited, 0644); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, output.OKf("Plan saved: %d phases", len(plan.Phases)))
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/plugin"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List external plugins found on PATH",
+	Long: `List external plugins found on PATH.
+
+Any executable named gptcode-<name> on PATH runs as "gptcode <name>".
+Arguments are passed through unchanged, and the plugin inherits
+stdin/stdout/stderr. Context is provided through the environment:
+
+  GPTCODE_PLUGIN      plugin name
+  GPTCODE_WORKDIR     directory gptcode was run from
+  GPTCODE_CONFIG_DIR  ~/.gptcode
+  GPTCODE_BACKEND     active backend
+  GPTCODE_PROFILE     active profile
+  GPTCODE_MODEL       default model of the active backend
+  GPTCODE_LANGUAGE    detected project language
+  GPTCODE_CONTEXT     all of the above (plus args) as a JSON object
+
+Built-in commands always take precedence over plugins.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		plugins := plugin.Discover()
+		if len(plugins) == 0 {
+			fmt.Println("No plugins found (executables named gptcode-<name> on PATH)")
+			return nil
+		}
+		for _, p := range plugins {
+			note := ""
+			if isBuiltinCommand(p.Name) {
+				note = "  (shadowed by built-in command)"
+			}
+			fmt.Printf("  %-20s %s%s\n", p.Name, p.Path, note)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+}
+
+// registerPlugins exposes every plugin on PATH as a subcommand, unless a
+// built-in command already uses the name.
+func registerPlugins() {
+	for _, p := range plugin.Discover() {
+		if isBuiltinCommand(p.Name) {
+			continue
+		}
+		rootCmd.AddCommand(pluginCommand(p))
+	}
+}
+
+func isBuiltinCommand(name string) bool {
+	for _, c := range rootCmd.Commands() {
+		if _, ok := c.Annotations[pluginAnnotation]; ok {
+			continue
+		}
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return name == "help" || name == "completion"
+}
+
+// pluginAnnotation marks commands registered from plugins
+const pluginAnnotation = "gptcode.plugin"
+
+func pluginCommand(p plugin.Plugin) *cobra.Command {
+	return &cobra.Command{
+		Use:                p.Name,
+		Short:              fmt.Sprintf("Plugin (%s)", filepath.Base(p.Path)),
+		Annotations:        map[string]string{pluginAnnotation: p.Path},
+		DisableFlagParsing: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := p.Run(pluginContext(p.Name, args), args, os.Stdin, os.Stdout, os.Stderr)
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			return err
+		},
+	}
+}
+
+func pluginContext(name string, args []string) plugin.Context {
+	workDir, _ := os.Getwd()
+	home, _ := os.UserHomeDir()
+	ctx := plugin.Context{
+		Name:      name,
+		Args:      args,
+		WorkDir:   workDir,
+		ConfigDir: filepath.Join(home, ".gptcode"),
+		Language:  detectLanguage(),
+	}
+	if setup, err := config.LoadEffectiveSetup(); err == nil {
+		ctx.Backend = setup.Defaults.Backend
+		ctx.Profile = setup.Defaults.Profile
+		ctx.Model = setup.Backend[ctx.Backend].DefaultModel
+	}
+	return ctx
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/config"
+	"gptcode/internal/github"
+	"gptcode/internal/llm"
+	"gptcode/internal/output"
+)
+
+var prCmd = &cobra.Command{
+	Use:   "pr",
+	Short: "Pull request helpers for GitHub and GitLab",
+}
+
+var prDescribeCmd = &cobra.Command{
+	Use:   "describe [pr-number]",
+	Short: "Write a PR description from the branch diff and commits",
+	Long: `Read the branch's diff and commit history since its base and write a
+structured PR description: summary, changes, testing notes and a place for
+screenshots, or the repository's PR template filled in when it has one.
+The description replaces the PR's body; without a number, the open PR of
+the current branch is described.
+
+The PR's branch must be checked out, since the diff is read locally.
+
+Examples:
+  gptcode pr describe
+  gptcode pr describe 42 --dry-run
+  gptcode pr describe --yes`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runPRDescribe,
+}
+
+func init() {
+	rootCmd.AddCommand(prCmd)
+	prCmd.AddCommand(prDescribeCmd)
+
+	prDescribeCmd.Flags().String("repo", "", "Repository (owner/repo, or host/group/repo for self-hosted GitLab)")
+	prDescribeCmd.Flags().String("base", "", "Branch to diff against (default: the PR's base)")
+	prDescribeCmd.Flags().Bool("dry-run", false, "Print the description without updating the PR")
+	prDescribeCmd.Flags().BoolP("yes", "y", false, "Replace an existing description without asking")
+}
+
+// maxPRDiff caps how much of the branch diff the model reads for a PR
+// description
+const maxPRDiff = 15000
+
+func runPRDescribe(cmd *cobra.Command, args []string) error {
+	base, _ := cmd.Flags().GetString("base")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	yes, _ := cmd.Flags().GetBool("yes")
+
+	workDir, _ := os.Getwd()
+	host, err := detectForge(cmd, workDir)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		return fmt.Errorf("not in a git repository: %w", err)
+	}
+	branch := strings.TrimSpace(string(out))
+
+	number := 0
+	if len(args) > 0 {
+		if number, err = strconv.Atoi(strings.TrimPrefix(args[0], "#")); err != nil {
+			return fmt.Errorf("invalid PR number: %s", args[0])
+		}
+	} else {
+		prs, err := host.BranchPRs(branch)
+		if err != nil {
+			return err
+		}
+		for _, pr := range prs {
+			if pr.State == github.PRStateOpen {
+				number = pr.Number
+				break
+			}
+		}
+		if number == 0 {
+			return fmt.Errorf("no open PR from %s; pass its number, or open one with gptcode issue push", branch)
+		}
+	}
+
+	pr, err := host.FetchPR(number)
+	if err != nil {
+		return err
+	}
+	if pr.HeadBranch != branch {
+		return fmt.Errorf("PR #%d is on %s; check it out to describe it (gh pr checkout %d)", number, pr.HeadBranch, number)
+	}
+	if base == "" {
+		base = pr.BaseBranch
+	}
+
+	var issue *github.Issue
+	pc, _ := config.LoadProjectConfig(workDir)
+	if n := github.IssueFromBranch(branch, pc.Git.BranchTemplate); n > 0 {
+		issue, _ = host.FetchIssue(n)
+	}
+
+	client := gitClient(host, workDir)
+	progress := output.StartStatus(os.Stderr, fmt.Sprintf("Describing PR #%d", number))
+	body, err := describePR(client, workDir, base, issue)
+	progress.Stop()
+	if err != nil {
+		return fmt.Errorf("failed to write the description: %w", err)
+	}
+
+	fmt.Println("\n" + output.Infof("PR #%d: %s\n\n%s", pr.Number, pr.Title, body))
+	if dryRun {
+		return nil
+	}
+	if strings.TrimSpace(pr.Body) != "" && !yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("PR #%d already has a description; use --yes to replace it", number)
+		}
+		fmt.Print("Replace the current description? [y/N]: ")
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer := strings.ToLower(strings.TrimSpace(line)); answer != "y" && answer != "yes" {
+			fmt.Println("Description left unchanged")
+			return nil
+		}
+	}
+	if err := host.UpdatePRBody(number, body); err != nil {
+		return err
+	}
+	fmt.Println(output.OKf("Updated %s", host.PRURL(number)))
+	return nil
+}
+
+// describePR writes a PR body from the branch's commits, files and diff
+// since base, in the repository's PR template or the default layout. When
+// the model fails, the error comes with a body written without it.
+func describePR(client *github.Client, workDir, base string, issue *github.Issue) (string, error) {
+	pc := github.PRContext{
+		Issue:   issue,
+		Changes: client.CommitSubjects(base),
+		Files:   client.ChangedFiles(base),
+		Diff:    client.BranchDiff(base, maxPRDiff),
+	}
+	tmpl, path := github.LoadPRTemplate(workDir)
+	if tmpl != "" {
+		fmt.Println(output.Infof("Filling in the PR template %s", path))
+	} else {
+		tmpl = github.DefaultPRTemplate
+	}
+
+	provider, model := llm.NewQueryProvider(nil, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+	body, err := github.WritePRBody(ctx, provider, model, tmpl, pc)
+	if err != nil && tmpl == github.DefaultPRTemplate && issue != nil {
+		body = github.GeneratePRBody(issue, pc.Changes)
+	}
+	return body, err
+}
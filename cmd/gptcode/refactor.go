@@ -96,7 +96,7 @@ func init() {
 }
 
 func runRefactorAPI(cmd *cobra.Command, args []string) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -168,12 +168,7 @@ func getRefactorProvider(setup *config.Setup) (llm.Provider, string, error) {
 		return nil, "", fmt.Errorf("backend %s not configured", backendName)
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	if model == "" {
 		model = backendCfg.GetModelForAgent("editor")
@@ -193,7 +188,7 @@ func runRefactorSignature(cmd *cobra.Command, args []string) error {
 	funcName := args[0]
 	newSig := args[1]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -244,7 +239,7 @@ func runRefactorSignature(cmd *cobra.Command, args []string) error {
 }
 
 func runRefactorBreaking(cmd *cobra.Command, args []string) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -324,7 +319,7 @@ func runRefactorType(cmd *cobra.Command, args []string) error {
 		newDef = args[1]
 	}
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -374,7 +369,7 @@ func runRefactorCompat(cmd *cobra.Command, args []string) error {
 	newAPI := args[1]
 	version := args[2]
 
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
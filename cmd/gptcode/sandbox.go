@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/autonomous"
+	"gptcode/internal/output"
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{doCmd, issueFixCmd} {
+		cmd.Flags().Bool("sandbox", false, "Run in a temporary git worktree and merge the changes back only once the task passes validation")
+	}
+	doCmd.Flags().String("plan-only-patch", "", "Run the whole task in a sandbox and write its changes to this patch file instead of the working tree")
+	doCmd.Flags().Lookup("plan-only-patch").NoOptDefVal = "gptcode.patch"
+}
+
+// runSandboxed runs fn in a sandbox worktree of the current checkout when
+// the command has --sandbox, and merges the result back if fn succeeds. On
+// failure the checkout is left untouched and the partial changes are saved
+// as a patch. With --plan-only-patch the result is written to the patch
+// file instead of being merged.
+func runSandboxed(cmd *cobra.Command, fn func() error) error {
+	sandbox, _ := cmd.Flags().GetBool("sandbox")
+	patchFile, _ := cmd.Flags().GetString("plan-only-patch")
+	if !sandbox && patchFile == "" {
+		return fn()
+	}
+	cwd, _ := os.Getwd()
+	if patchFile != "" && !filepath.IsAbs(patchFile) {
+		patchFile = filepath.Join(cwd, patchFile)
+	}
+	sb, err := autonomous.NewSandbox(cwd)
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	defer sb.Remove()
+	fmt.Fprintf(os.Stderr, "Running in sandbox %s\n", sb.Dir())
+
+	if err := inDir(sb.Dir(), fn); err != nil {
+		if path, perr := sb.SavePatch(); perr == nil && path != "" {
+			fmt.Fprintf(os.Stderr, "Checkout left untouched; the sandbox's partial changes are saved in %s\n", path)
+		}
+		return err
+	}
+
+	if patchFile != "" {
+		return writeSandboxPatch(sb, patchFile)
+	}
+	files, err := sb.Merge()
+	if err != nil {
+		if path, perr := sb.SavePatch(); perr == nil && path != "" {
+			return fmt.Errorf("%w (patch saved to %s)", err, path)
+		}
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Sandbox finished without changes")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, output.OKf("Merged %d files from the sandbox", len(files)))
+	return nil
+}
+
+// writeSandboxPatch writes what the sandbox changed to path, leaving the
+// checkout untouched, and says how to apply it.
+func writeSandboxPatch(sb *autonomous.Sandbox, path string) error {
+	files, err := sb.WritePatch(path)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Sandbox finished without changes; no patch written")
+		return nil
+	}
+	fmt.Fprintln(os.Stderr, output.OKf("Saved the changes to %d files as %s; the working tree is untouched", len(files), path))
+	for _, f := range files {
+		fmt.Fprintf(os.Stderr, "  %s\n", f)
+	}
+	fmt.Fprintf(os.Stderr, "Apply them with: git -C %s apply %s\n", sb.Root(), path)
+	return nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/feedback"
+	"gptcode/internal/index"
+	"gptcode/internal/llm"
+	"gptcode/internal/output"
+	"gptcode/internal/tools"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the code by meaning",
+	Long: `Find the code that matches a question in plain words, using the project's
+semantic index: files split into chunks of lines, each embedded with the
+model configured under context.embeddings in setup.yaml (by default the
+default backend's, or nomic-embed-text through a local Ollama).
+
+The index is brought up to date before searching, embedding only files that
+changed since the last search; the first search of a project builds it.
+Once a project is indexed, the agents get a semantic_search tool and the
+embedding signal of context selection.
+
+Examples:
+  gptcode search "where is token refresh handled"
+  gptcode search "retry with backoff" --limit 5 --json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+		asJSON, _ := cmd.Flags().GetBool("json")
+		noUpdate, _ := cmd.Flags().GetBool("no-update")
+		query := strings.Join(args, " ")
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ix, embedder, err := openIndex()
+		if err != nil {
+			return err
+		}
+		if !noUpdate || len(ix.Files) == 0 {
+			if err := updateIndex(ctx, ix, embedder); err != nil {
+				return err
+			}
+		}
+
+		results, err := ix.Search(ctx, embedder, query, limit)
+		if err != nil {
+			return err
+		}
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(results)
+		}
+		fmt.Print(index.FormatResults(results, 8))
+		return nil
+	},
+}
+
+var indexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Build or update the semantic code index",
+	Long: `Build the semantic index of the current project, or update it by embedding
+only the files that changed. The index lives in ~/.gptcode/cache; changing the
+embedding model or its dimensions rebuilds it, and vectors embedded before
+are reused from ~/.gptcode/cache/embeddings.
+
+Examples:
+  gptcode index             # Build or update
+  gptcode index --status    # Files, chunks and model
+  gptcode index --rebuild   # Embed everything again
+  gptcode index --clear     # Delete the index`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rebuild, _ := cmd.Flags().GetBool("rebuild")
+		clearIndex, _ := cmd.Flags().GetBool("clear")
+		status, _ := cmd.Flags().GetBool("status")
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case clearIndex:
+			if err := index.Clear(cwd); err != nil {
+				return err
+			}
+			fmt.Println(output.OKf("Semantic index deleted"))
+			return nil
+		case status:
+			ix, err := index.Load(cwd)
+			if errors.Is(err, index.ErrNoIndex) {
+				fmt.Println("No semantic index yet; run: gptcode index")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			model := ix.Model
+			if ix.Dimensions > 0 {
+				model += fmt.Sprintf(" (%d dimensions)", ix.Dimensions)
+			}
+			fmt.Println(output.Infof("%d files, %d chunks, embedded with %s", len(ix.Files), ix.Chunks(), model))
+			fmt.Printf("   Updated %s\n   %s\n", ix.Updated.Format("2006-01-02 15:04"), index.Path(cwd))
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ix, embedder, err := openIndex()
+		if err != nil {
+			return err
+		}
+		if rebuild {
+			ix = index.New(cwd)
+		}
+		return updateIndex(ctx, ix, embedder)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(indexCmd)
+	searchCmd.Flags().Int("limit", 10, "Maximum results")
+	searchCmd.Flags().Bool("json", false, "Print results as JSON")
+	searchCmd.Flags().Bool("no-update", false, "Search the index as it is, without embedding changed files")
+	indexCmd.Flags().Bool("rebuild", false, "Embed every file again")
+	indexCmd.Flags().Bool("clear", false, "Delete the index")
+	indexCmd.Flags().Bool("status", false, "Show what is indexed")
+}
+
+// openIndex loads the current project's index, or a new one, and the
+// configured embedder.
+func openIndex() (*index.Index, llm.Embedder, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+	setup, _ := config.LoadEffectiveSetup()
+	embedder, err := llm.NewEmbedder(setup)
+	if err != nil {
+		return nil, nil, err
+	}
+	ix, err := index.Load(cwd)
+	if errors.Is(err, index.ErrNoIndex) {
+		ix = index.New(cwd)
+	} else if err != nil {
+		return nil, nil, err
+	}
+	return ix, embedder, nil
+}
+
+// updateIndex embeds the files that changed, reporting progress on stderr
+func updateIndex(ctx context.Context, ix *index.Index, embedder llm.Embedder) error {
+	progress := func(done, total int) {
+		fmt.Fprint(os.Stderr, "\r"+output.Infof("Embedding %d/%d chunks with %s", done, total, embedder.Model()))
+	}
+	stats, err := ix.Update(ctx, embedder, progress)
+	if stats.EmbeddedChunks > 0 {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update the semantic index: %w", err)
+	}
+	if stats.Embedded > 0 || stats.Removed > 0 {
+		fmt.Fprintln(os.Stderr, output.OKf("Indexed %d files (%d chunks): %d embedded, %d removed",
+			stats.Files, stats.Chunks, stats.Embedded, stats.Removed))
+	}
+	return nil
+}
+
+// startSemanticSearch offers the agents of commands that run them a
+// semantic_search tool when the project has been indexed.
+func startSemanticSearch(cmd *cobra.Command) {
+	if !runsEditor(cmd) {
+		return
+	}
+	cwd, _ := os.Getwd()
+	setup, _ := config.LoadEffectiveSetup()
+	ix, embedder, err := index.Open(cwd, setup)
+	if err != nil {
+		return
+	}
+	tools.RegisterExternalTool(index.Tool(ix, embedder))
+}
+
+// startFeedbackEmbeddings lets recorded feedback be merged with its
+// near-duplicates, and the planner and editor find past corrections by
+// meaning, for the commands that run them or record feedback.
+func startFeedbackEmbeddings(cmd *cobra.Command) {
+	if !runsEditor(cmd) && cmd.Parent() != feedbackCmd {
+		return
+	}
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return
+	}
+	if embedder, err := llm.NewEmbedder(setup); err == nil {
+		feedback.SetEmbedder(embedder)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"gptcode/internal/config"
 	"gptcode/internal/llm"
+	"gptcode/internal/output"
 	"gptcode/internal/security"
 )
 
@@ -21,33 +23,42 @@ var securityCmd = &cobra.Command{
 var securityScanCmd = &cobra.Command{
 	Use:   "scan",
 	Short: "Scan for security vulnerabilities",
-	Long: `Scan the codebase for security vulnerabilities using language-specific tools.
+	Long: `Scan the codebase for security vulnerabilities with the tools that apply to it,
+and merge their findings into one report.
 
 Supported tools:
-- Go: govulncheck
+- Go: govulncheck, gosec
 - Node.js: npm audit
-- Python: safety
+- Python: bandit, pip-audit
 - Ruby: bundle audit
+- Containers: trivy (Dockerfile and compose files, or an image with --image)
+
+Tools that are not installed are skipped. With --fix, vulnerable dependencies
+are upgraded with the package manager and code findings are handed to the
+editor agent with their file and line.
 
 Examples:
-  gptcode security scan           # Scan only
-  gptcode security scan --fix     # Scan and auto-fix`,
+  gptcode security scan                   # Scan only
+  gptcode security scan --fix             # Scan and auto-fix
+  gptcode security scan --image app:dev   # Also scan a container image`,
 	RunE: runSecurityScan,
 }
 
 var securityFix bool
 var securityModel string
+var securityImage string
 
 func init() {
 	rootCmd.AddCommand(securityCmd)
 	securityCmd.AddCommand(securityScanCmd)
 
 	securityScanCmd.Flags().BoolVar(&securityFix, "fix", false, "Automatically fix vulnerabilities")
+	securityScanCmd.Flags().StringVar(&securityImage, "image", "", "Container image to scan with trivy")
 	securityCmd.PersistentFlags().StringVar(&securityModel, "model", "", "LLM model to use (default: from config)")
 }
 
 func runSecurityScan(cmd *cobra.Command, args []string) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -63,23 +74,43 @@ func runSecurityScan(cmd *cobra.Command, args []string) error {
 	}
 
 	scanner := security.NewScanner(provider, model, workDir)
+	if securityImage != "" {
+		scanner.AddImage(securityImage)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	fmt.Println("🔒 Scanning for vulnerabilities...")
+	fmt.Println(output.Infof("Scanning for vulnerabilities..."))
 
 	report, err := scanner.ScanAndFix(ctx, securityFix)
 	if err != nil {
 		return fmt.Errorf("scan failed: %w", err)
 	}
 
+	if len(report.Tools) > 0 {
+		fmt.Printf("   Tools: %s\n", strings.Join(report.Tools, ", "))
+	}
+	if len(report.Skipped) > 0 {
+		fmt.Printf("   Skipped (not installed): %s\n", strings.Join(report.Skipped, ", "))
+	}
+	if len(report.Tools) == 0 {
+		if len(report.Skipped) == 0 {
+			fmt.Printf("No security tools apply to this %s project\n", report.Language)
+		}
+		return nil
+	}
 	if len(report.Vulnerabilities) == 0 {
-		fmt.Println("✅ No vulnerabilities detected")
+		for _, err := range report.Errors {
+			fmt.Println(output.Warnf("%v", err))
+		}
+		if len(report.Errors) == 0 {
+			fmt.Println(output.OKf("No vulnerabilities detected"))
+		}
 		return nil
 	}
 
-	fmt.Printf("\n⚠️  Found %d vulnerabilit(y/ies):\n", len(report.Vulnerabilities))
+	fmt.Println("\n" + output.Warnf("Found %d vulnerabilit(y/ies):", len(report.Vulnerabilities)))
 
 	criticalCount := 0
 	highCount := 0
@@ -103,8 +134,15 @@ func runSecurityScan(cmd *cobra.Command, args []string) error {
 
 		if vuln.Package != "" {
 			fmt.Printf(" in %s", vuln.Package)
+			if vuln.Version != "" {
+				fmt.Printf(" %s", vuln.Version)
+			}
+		}
+		fmt.Printf(" (%s)\n", vuln.Tool)
+
+		if loc := vuln.Location(); loc != "" {
+			fmt.Printf("   at %s\n", loc)
 		}
-		fmt.Println()
 
 		if vuln.Description != "" {
 			desc := vuln.Description
@@ -113,9 +151,12 @@ func runSecurityScan(cmd *cobra.Command, args []string) error {
 			}
 			fmt.Printf("   %s\n", desc)
 		}
+		if vuln.Fix != "" {
+			fmt.Printf("   Fix: %s\n", vuln.Fix)
+		}
 	}
 
-	fmt.Printf("\n📊 Summary:\n")
+	fmt.Println("\n" + output.Infof("Summary:"))
 	fmt.Printf("   Language: %s\n", report.Language)
 	fmt.Printf("   Total: %d\n", len(report.Vulnerabilities))
 	if criticalCount > 0 {
@@ -125,8 +166,15 @@ func runSecurityScan(cmd *cobra.Command, args []string) error {
 		fmt.Printf("   High: %d\n", highCount)
 	}
 
+	if !securityFix && len(report.Errors) > 0 {
+		fmt.Println("\n" + output.Warnf("%d tool error(s):", len(report.Errors)))
+		for _, err := range report.Errors {
+			fmt.Printf("   - %v\n", err)
+		}
+	}
+
 	if securityFix {
-		fmt.Printf("\n🔧 Fix Results:\n")
+		fmt.Println("\n" + output.Infof("Fix Results:"))
 		fmt.Printf("   Fixed: %d\n", report.FixedCount)
 
 		if len(report.UpdatedFiles) > 0 {
@@ -137,18 +185,18 @@ func runSecurityScan(cmd *cobra.Command, args []string) error {
 		}
 
 		if len(report.Errors) > 0 {
-			fmt.Printf("\n⚠️  %d error(s) during fixing:\n", len(report.Errors))
+			fmt.Println("\n" + output.Warnf("%d error(s):", len(report.Errors)))
 			for _, err := range report.Errors {
 				fmt.Printf("   - %v\n", err)
 			}
 		}
 
 		if report.FixedCount > 0 {
-			fmt.Println("\n✅ Vulnerabilities fixed")
-			fmt.Println("⚠️  Run tests to verify fixes before committing")
+			fmt.Println("\n" + output.OKf("Vulnerabilities fixed"))
+			fmt.Println(output.Warnf("Run tests to verify fixes before committing"))
 		}
 	} else {
-		fmt.Println("\n💡 Run with --fix to automatically fix vulnerabilities")
+		fmt.Println("\n" + output.Infof("Run with --fix to automatically fix vulnerabilities"))
 	}
 
 	return nil
@@ -166,12 +214,7 @@ func getSecurityProvider(setup *config.Setup) (llm.Provider, string, error) {
 		return nil, "", fmt.Errorf("backend %s not configured", backendName)
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	if model == "" {
 		model = backendCfg.GetModelForAgent("editor")
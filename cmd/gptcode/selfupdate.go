@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/config"
+	"gptcode/internal/httpclient"
+	"gptcode/internal/output"
+	"gptcode/internal/selfupdate"
+)
+
+// Set at build time by goreleaser (-X main.version=...)
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update gptcode to the latest release",
+	Long: `Update gptcode to the latest release.
+
+Downloads the build for this platform from GitHub releases, verifies it
+against the release checksums (and signature, for signed builds), shows
+what changed since the installed version and replaces the binary in place.
+
+The channel defaults to update.channel in setup.yaml (stable). A daily
+"new version available" notice can be turned off with update.check: false
+or GPTCODE_NO_UPDATE_CHECK=1.
+
+Examples:
+  gptcode self-update
+  gptcode self-update --check
+  gptcode self-update --channel beta`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		channel, _ := cmd.Flags().GetString("channel")
+		checkOnly, _ := cmd.Flags().GetBool("check")
+		force, _ := cmd.Flags().GetBool("force")
+
+		setup, _ := config.LoadEffectiveSetup()
+		if channel == "" {
+			channel = setup.Update.ChannelOrDefault()
+		}
+		if channel != selfupdate.ChannelStable && channel != selfupdate.ChannelBeta {
+			return fmt.Errorf("unknown channel %q (use stable or beta)", channel)
+		}
+
+		updater, err := newUpdater(setup)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		releases, err := updater.Releases(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list releases: %w", err)
+		}
+		latest, ok := selfupdate.Latest(releases, channel)
+		if !ok {
+			return fmt.Errorf("no releases found on the %s channel", channel)
+		}
+
+		fmt.Printf("Installed: %s\n", version)
+		fmt.Printf("Latest (%s): %s\n", channel, latest.Tag)
+
+		if version == "dev" && !force {
+			fmt.Println("\nThis is a development build; use --force to replace it with a release.")
+			return nil
+		}
+		if version != "dev" && selfupdate.CompareVersions(latest.Tag, version) <= 0 && !force {
+			fmt.Println(output.OKf("gptcode is up to date"))
+			return nil
+		}
+
+		if version != "dev" {
+			fmt.Println("\nChanges:")
+			for _, r := range selfupdate.Between(releases, version, latest.Tag) {
+				fmt.Printf("\n## %s\n", r.Tag)
+				if body := strings.TrimSpace(r.Body); body != "" {
+					fmt.Println(body)
+				}
+			}
+		}
+		if checkOnly {
+			return nil
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("\nDownloading %s...\n", selfupdate.ArchiveName(latest.Tag, runtime.GOOS, runtime.GOARCH))
+		binary, err := updater.Download(ctx, latest)
+		if err != nil {
+			return err
+		}
+		if selfupdate.PublicKey == "" {
+			fmt.Println("Checksum verified (this build has no signing key; signature not checked)")
+		} else {
+			fmt.Println("Checksum and signature verified")
+		}
+		if err := selfupdate.Install(exe, binary); err != nil {
+			return fmt.Errorf("failed to install update: %w", err)
+		}
+		fmt.Println(output.OKf("Updated to %s", latest.Tag))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.Version = fmt.Sprintf("%s (commit %s, built %s)", version, commit, date)
+	rootCmd.AddCommand(selfUpdateCmd)
+
+	selfUpdateCmd.Flags().String("channel", "", "Release channel: stable or beta (default from update.channel)")
+	selfUpdateCmd.Flags().Bool("check", false, "Only show whether an update is available")
+	selfUpdateCmd.Flags().Bool("force", false, "Reinstall even when up to date or running a dev build")
+}
+
+func newUpdater(setup *config.Setup) (*selfupdate.Updater, error) {
+	client, err := httpclient.New(setup.Network, 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return selfupdate.New(client), nil
+}
+
+// startUpdateCheck refreshes the cached latest version in the background
+// and returns a function that prints the new-version notice, if any. The
+// notice only appears on interactive terminals for release builds.
+func startUpdateCheck() func() {
+	if version == "dev" || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return func() {}
+	}
+	if len(os.Args) > 1 && os.Args[1] == selfUpdateCmd.Name() {
+		return func() {}
+	}
+	setup, _ := config.LoadEffectiveSetup()
+	if !setup.Update.CheckEnabled() {
+		return func() {}
+	}
+	channel := setup.Update.ChannelOrDefault()
+
+	if updater, err := newUpdater(setup); err == nil {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			updater.Refresh(ctx, channel)
+		}()
+	}
+	return func() {
+		if notice := selfupdate.Notice(version, channel); notice != "" {
+			fmt.Fprintln(os.Stderr, "\n"+output.Infof("%s", notice))
+		}
+	}
+}
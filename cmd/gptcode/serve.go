@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/config"
+	"gptcode/internal/forge"
+	"gptcode/internal/output"
+	"gptcode/internal/webhook"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run issue fix, review and ci from GitHub webhooks",
+	Long: `Listen for GitHub webhooks and run the issue automation flows in the
+background, so gptcode can work as a hands-off bot:
+
+  issues labeled gptcode-fix            issue fix, commit and push
+  PR review with changes or comments    issue review
+  PR review comment                     issue review
+  failed check suite or workflow run    issue ci
+
+Each job runs in its own git worktree of this checkout, so jobs never touch
+the working tree you are in. Deliveries for work already queued are merged,
+and each issue or PR gets at most --max-attempts jobs. Events from the
+account gptcode is authenticated as, and PRs from forks, are ignored.
+
+Only PRs from the branches gptcode creates (issue-123-slug, the project's
+git.branch_template, gptcode/...) are worked on, and only reviews by the
+repository's owners, members and collaborators, or by --allow-user, start
+review jobs: anyone else's comments never drive the agent.
+
+Configure the webhook on GitHub with content type application/json, the URL
+http://<host>:<port>/webhook and a secret, given to serve through
+GPTCODE_WEBHOOK_SECRET (or --secret). Subscribe to Issues, Pull request
+reviews, Pull request review comments, Check suites and Workflow runs.
+
+Job status is served as JSON to requests bearing the secret:
+  curl -H "Authorization: Bearer $GPTCODE_WEBHOOK_SECRET" http://localhost:8787/jobs
+  curl -H "Authorization: Bearer $GPTCODE_WEBHOOK_SECRET" http://localhost:8787/jobs/3
+
+Examples:
+  GPTCODE_WEBHOOK_SECRET=... gptcode serve
+  gptcode serve --port 9000 --label autofix --concurrency 2`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().Int("port", 8787, "Port to listen on")
+	serveCmd.Flags().String("secret", "", "Webhook secret (default $GPTCODE_WEBHOOK_SECRET)")
+	serveCmd.Flags().String("label", webhook.DefaultLabel, "Issue label that triggers a fix")
+	serveCmd.Flags().String("repo", "", "Repository (owner/repo)")
+	serveCmd.Flags().String("base", "main", "Base branch for fixes")
+	serveCmd.Flags().Int("concurrency", 1, "Jobs run in parallel")
+	serveCmd.Flags().Int("max-attempts", 3, "Jobs per issue or PR before further events are ignored")
+	serveCmd.Flags().StringSlice("allow-user", nil, "Users whose reviews start jobs besides owners, members and collaborators")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	secret, _ := cmd.Flags().GetString("secret")
+	if secret == "" {
+		secret = os.Getenv("GPTCODE_WEBHOOK_SECRET")
+	}
+	if secret == "" {
+		return fmt.Errorf("a webhook secret is required: set GPTCODE_WEBHOOK_SECRET or --secret")
+	}
+	port, _ := cmd.Flags().GetInt("port")
+	label, _ := cmd.Flags().GetString("label")
+	base, _ := cmd.Flags().GetString("base")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	maxAttempts, _ := cmd.Flags().GetInt("max-attempts")
+	allowUsers, _ := cmd.Flags().GetStringSlice("allow-user")
+
+	workDir, _ := os.Getwd()
+	host, err := detectForge(cmd, workDir)
+	if err != nil {
+		return err
+	}
+	if host.Name() != "GitHub" {
+		return fmt.Errorf("serve handles GitHub webhooks; %s is not supported", host.Name())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	queue := webhook.NewQueue(ctx, serveRunner(host, workDir, base), concurrency)
+	queue.MaxAttempts = maxAttempts
+	filter := webhook.Filter{Repo: host.Repo(), Label: label, IgnoreUsers: []string{host.CurrentUser()}, AllowUsers: allowUsers}
+	if pc, err := config.LoadProjectConfig(workDir); err == nil {
+		filter.BranchTemplate = pc.Git.BranchTemplate
+	}
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           webhook.NewServer(secret, filter, queue),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	fmt.Println(output.Infof("Listening for %s webhooks on :%d/webhook (Ctrl+C to stop)", host.Repo(), port))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveRunner runs a webhook job's issue commands in a worktree of workDir,
+// which is removed when the job succeeds and kept for inspection otherwise.
+func serveRunner(host forge.Forge, workDir, base string) webhook.Runner {
+	return func(ctx context.Context, req webhook.Request) (string, error) {
+		log.Printf("▶ %s", req.Key())
+		origin := gitClient(host, workDir)
+		worktree := filepath.Join(os.TempDir(), "gptcode-worktrees",
+			strings.ReplaceAll(req.Repo, "/", "-")+fmt.Sprintf("-serve-%s-%d", req.Kind, req.Number))
+		_ = origin.RemoveWorktree(worktree)
+
+		var steps [][]string
+		number := strconv.Itoa(req.Number)
+		start := base
+		switch req.Kind {
+		case webhook.KindFix:
+			steps = [][]string{{"fix", number}, {"commit", number}, {"push", number}}
+		case webhook.KindReview, webhook.KindCI:
+			steps = [][]string{{req.Kind, number}}
+			start = req.Ref
+		default:
+			return "", fmt.Errorf("unknown job kind %q", req.Kind)
+		}
+
+		fetch := exec.CommandContext(ctx, "git", "fetch", "origin", start)
+		fetch.Dir = workDir
+		if out, err := fetch.CombinedOutput(); err != nil {
+			return string(out), fmt.Errorf("failed to fetch %s: %w", start, err)
+		}
+		branch := fmt.Sprintf("gptcode/serve-%d", req.Number)
+		if req.Kind != webhook.KindFix {
+			branch = req.Ref
+		}
+		if err := origin.AddWorktree(worktree, branch, "origin/"+start); err != nil {
+			return "", err
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			return "", fmt.Errorf("failed to locate the gptcode binary: %w", err)
+		}
+		var output strings.Builder
+		for _, step := range steps {
+			args := append([]string{"issue"}, step...)
+			args = append(args, "--repo", req.Repo)
+			c := exec.CommandContext(ctx, exe, args...)
+			c.Dir = worktree
+			c.Env = append(os.Environ(), "GPTCODE_NO_NOTIFY=1")
+			fmt.Fprintf(&output, "$ gptcode %s\n", strings.Join(args, " "))
+			out, err := c.CombinedOutput()
+			output.Write(out)
+			if err != nil {
+				log.Printf("■ %s failed (worktree kept: %s)", req.Key(), worktree)
+				return output.String(), fmt.Errorf("gptcode %s: %w", strings.Join(args, " "), err)
+			}
+		}
+		_ = origin.RemoveWorktree(worktree)
+		log.Printf("■ %s succeeded", req.Key())
+		return output.String(), nil
+	}
+}
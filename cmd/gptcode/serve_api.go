@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/api"
+	"gptcode/internal/config"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+	"gptcode/internal/modes"
+	"gptcode/internal/observability"
+	"gptcode/internal/output"
+)
+
+var serveAPICmd = &cobra.Command{
+	Use:   "api",
+	Short: "Serve chat and do over a local HTTP API",
+	Long: `Serve the agents over a local HTTP API, so editors, scripts and the Neovim
+plugin can talk to one long-lived process instead of running gptcode for
+each request. Requests run in the current directory, with the same setup,
+MCP servers and project tools as the CLI.
+
+  POST   /chat                  {"message": "..."} or {"messages": [...]};
+                                add "stream": true for server-sent events
+  POST   /do                    {"task": "..."}: starts a task, returns its session
+  GET    /sessions              the sessions, newest last
+  GET    /sessions/{id}         a session's status and result
+  GET    /sessions/{id}/events  a session's events as server-sent events
+  DELETE /sessions/{id}         cancel a session
+
+Events are the agents' tool calls, file changes and LLM requests, status
+changes, chat chunks, and a final "done". Each has an id, so a client that
+reconnects with Last-Event-ID gets only what it missed. Tasks run one at a
+time, since they change the same tree.
+
+The server listens on localhost. Requests must send JSON, and requests from
+browsers (with an Origin header) are refused. Set GPTCODE_API_TOKEN (or
+--token) to also require "Authorization: Bearer <token>"; without a token,
+only requests addressed to localhost or a loopback IP are served.
+
+Examples:
+  gptcode serve api
+  curl -s localhost:8711/chat -H 'Content-Type: application/json' -d '{"message": "what does main.go do?"}'
+  curl -s localhost:8711/do -H 'Content-Type: application/json' -d '{"task": "add a --json flag to stats"}'
+  curl -N localhost:8711/sessions/do-1/events`,
+	Args: cobra.NoArgs,
+	RunE: runServeAPI,
+}
+
+func init() {
+	serveCmd.AddCommand(serveAPICmd)
+	serveAPICmd.Flags().Int("port", 8711, "Port to listen on")
+	serveAPICmd.Flags().String("host", "127.0.0.1", "Address to listen on")
+	serveAPICmd.Flags().String("token", "", "Bearer token clients must send (default $GPTCODE_API_TOKEN)")
+}
+
+func runServeAPI(cmd *cobra.Command, args []string) error {
+	port, _ := cmd.Flags().GetInt("port")
+	host, _ := cmd.Flags().GetString("host")
+	token, _ := cmd.Flags().GetString("token")
+	if token == "" {
+		token = os.Getenv("GPTCODE_API_TOKEN")
+	}
+	if token == "" && !isLoopback(host) {
+		return fmt.Errorf("listening on %s needs a token: set GPTCODE_API_TOKEN or --token", host)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           api.NewServer(api.Handlers{Chat: apiChat, Do: apiDo}, token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	fmt.Println(output.Infof("Serving the API on http://%s (Ctrl+C to stop)", addr))
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// apiChat answers a conversation as gptcode chat does. The chat cannot be
+// interrupted once started; a cancelled request only stops streaming it.
+func apiChat(ctx context.Context, messages []llm.ChatMessage, onChunk func(string)) (string, error) {
+	history, err := json.Marshal(modes.ChatHistory{Messages: messages})
+	if err != nil {
+		return "", err
+	}
+	return modes.ChatWithStream(string(history), nil, func(chunk string) {
+		if ctx.Err() == nil {
+			onChunk(chunk)
+		}
+	})
+}
+
+// apiDo runs a task as gptcode do does without --supervised, in one
+// attempt with the default backend
+func apiDo(ctx context.Context, task string, observer *observability.AgentObserver) error {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load setup: %w", err)
+	}
+	if setup.Defaults.TaskTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(setup.Defaults.TaskTimeout)*time.Second)
+		defer cancel()
+	}
+	provider, queryModel := llm.NewQueryProvider(setup, "")
+	cwd, _ := os.Getwd()
+	language := string(langdetect.DetectLanguage(cwd))
+	if language == "" || language == "unknown" {
+		language = setup.Defaults.Lang
+		if language == "" {
+			language = "go"
+		}
+	}
+	executor := modes.NewAutonomousExecutorWithBackend(provider, cwd, queryModel, language, setup.Defaults.Backend)
+	executor.SetObserver(observer)
+	return executor.Execute(ctx, task)
+}
@@ -12,6 +12,10 @@ import (
 	"github.com/spf13/cobra"
 
 	"gptcode/internal/config"
+	"gptcode/internal/feedback"
+	"gptcode/internal/intelligence"
+	"gptcode/internal/observability"
+	"gptcode/internal/tools"
 )
 
 var modeCmd = &cobra.Command{
@@ -29,7 +33,7 @@ Examples:
   gptcode mode local      # Switch to local mode`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -59,186 +63,428 @@ Examples:
 
 var statsCmd = &cobra.Command{
 	Use:   "stats [--today|--week|--all]",
-	Short: "Display usage statistics with elegant dashboard",
-	Long: `Display usage statistics in a beautiful dashboard format.
+	Short: "Display a usage dashboard across tasks, cost, edits and feedback",
+	Long: `Display usage statistics aggregated across gptcode's records:
+
+  - tasks run and their success rate and average duration (task history)
+  - LLM requests, tokens and cost by backend (usage.json and usage.jsonl)
+  - the files agents edited most (undo journals of the last runs)
+  - the weekly trend of good and bad feedback
 
 Flags:
   --today  Show today's stats only
   --week   Show last 7 days
   --all    Show all time stats (default)
+  --json   Output the dashboard as JSON
 
 Examples:
   gptcode stats
   gptcode stats --today
-  gptcode stats --week`,
+  gptcode stats --week --json`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		today, _ := cmd.Flags().GetBool("today")
 		week, _ := cmd.Flags().GetBool("week")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		now := time.Now()
+		period, from := "All Time", time.Time{}
+		if today {
+			period = "Today"
+			from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		} else if week {
+			period, from = "Last 7 Days", now.AddDate(0, 0, -7)
+		}
 
-		home, err := os.UserHomeDir()
+		requests, err := loadRequestUsage()
 		if err != nil {
 			return err
 		}
-
-		usagePath := filepath.Join(home, ".gptcode", "usage.json")
-		data, err := os.ReadFile(usagePath)
+		history, err := intelligence.LoadHistory()
 		if err != nil {
-			if os.IsNotExist(err) {
-				fmt.Println("No usage data yet. Start using gptcode to see stats!")
-				return nil
-			}
-			return err
+			return fmt.Errorf("failed to load task history: %w", err)
 		}
-
-		var usage map[string]map[string]struct {
-			Requests     int    `json:"requests"`
-			InputTokens  int    `json:"input_tokens"`
-			OutputTokens int    `json:"output_tokens"`
-			CachedTokens int    `json:"cached_tokens"`
-			LastError    string `json:"last_error,omitempty"`
+		spend, err := observability.LoadUsage(observability.UsagePath())
+		if err != nil {
+			return err
 		}
-
-		if err := json.Unmarshal(data, &usage); err != nil {
+		journals, err := tools.ListJournals()
+		if err != nil {
 			return err
 		}
+		events, err := feedback.LoadAll()
+		if err != nil {
+			return fmt.Errorf("failed to load feedback: %w", err)
+		}
 
-		return displayStatsBox(usage, today, week)
+		d := buildDashboard(statsSources{
+			Requests: requests,
+			History:  history,
+			Spend:    spend,
+			Journals: journals,
+			Feedback: events,
+		}, period, from, now)
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(d)
+		}
+		if d.empty() {
+			fmt.Println("No usage data yet. Start using gptcode to see stats!")
+			return nil
+		}
+		d.print()
+		return nil
 	},
 }
 
-func displayStatsBox(usage map[string]map[string]struct {
+// requestUsage is one model's requests on one day, as kept in
+// ~/.gptcode/usage.json by date and model
+type requestUsage struct {
 	Requests     int    `json:"requests"`
 	InputTokens  int    `json:"input_tokens"`
 	OutputTokens int    `json:"output_tokens"`
 	CachedTokens int    `json:"cached_tokens"`
 	LastError    string `json:"last_error,omitempty"`
-}, todayOnly, weekOnly bool) error {
-	now := time.Now()
-	todayStr := now.Format("2006-01-02")
+}
+
+func loadRequestUsage() (map[string]map[string]requestUsage, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".gptcode", "usage.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var usage map[string]map[string]requestUsage
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return nil, fmt.Errorf("failed to parse usage.json: %w", err)
+	}
+	return usage, nil
+}
+
+// statsSources are the records the dashboard aggregates
+type statsSources struct {
+	Requests map[string]map[string]requestUsage
+	History  []intelligence.TaskExecution
+	Spend    []observability.UsageRecord
+	Journals []*tools.Journal
+	Feedback []feedback.Event
+}
 
-	var dates []string
-	for date := range usage {
-		if todayOnly && date != todayStr {
+// statsDashboard is what gptcode stats reports, also as --json
+type statsDashboard struct {
+	Period   string            `json:"period"`
+	Since    *time.Time        `json:"since,omitempty"`
+	Tasks    taskSummary       `json:"tasks"`
+	Requests requestSummary    `json:"requests"`
+	Backends []backendCost     `json:"cost_by_backend"`
+	Cost     float64           `json:"total_cost"`
+	Files    []fileEdits       `json:"most_edited_files"`
+	Feedback []sentimentPeriod `json:"feedback_trend"`
+}
+
+type taskSummary struct {
+	Total         int     `json:"total"`
+	ThisWeek      int     `json:"this_week"`
+	Succeeded     int     `json:"succeeded"`
+	SuccessRate   float64 `json:"success_rate"`
+	AvgDurationMs int64   `json:"avg_duration_ms"`
+}
+
+type requestSummary struct {
+	Total        int            `json:"total"`
+	Errors       int            `json:"errors"`
+	InputTokens  int            `json:"input_tokens"`
+	OutputTokens int            `json:"output_tokens"`
+	CachedTokens int            `json:"cached_tokens"`
+	Models       []modelRequest `json:"models"`
+}
+
+type modelRequest struct {
+	Model    string `json:"model"`
+	Requests int    `json:"requests"`
+	HasError bool   `json:"has_error"`
+}
+
+type backendCost struct {
+	Backend   string  `json:"backend"`
+	Calls     int     `json:"calls"`
+	TokensIn  int     `json:"tokens_in"`
+	TokensOut int     `json:"tokens_out"`
+	Cost      float64 `json:"cost"`
+	Unpriced  bool    `json:"unpriced,omitempty"`
+}
+
+type fileEdits struct {
+	Path string `json:"path"`
+	Runs int    `json:"runs"`
+}
+
+// sentimentPeriod counts the feedback given in the week starting at Week
+type sentimentPeriod struct {
+	Week string `json:"week"`
+	Good int    `json:"good"`
+	Bad  int    `json:"bad"`
+}
+
+// maxStatsFiles and maxStatsWeeks bound the edited files and feedback weeks shown
+const (
+	maxStatsFiles = 10
+	maxStatsWeeks = 8
+)
+
+func buildDashboard(src statsSources, period string, from, now time.Time) *statsDashboard {
+	d := &statsDashboard{Period: period}
+	if !from.IsZero() {
+		d.Since = &from
+	}
+
+	var durationTotal, durationCount int64
+	weekAgo := now.AddDate(0, 0, -7)
+	for _, exec := range src.History {
+		if exec.Timestamp.After(weekAgo) {
+			d.Tasks.ThisWeek++
+		}
+		if exec.Timestamp.Before(from) {
 			continue
 		}
-		if weekOnly {
-			d, _ := time.Parse("2006-01-02", date)
-			if now.Sub(d) > 7*24*time.Hour {
-				continue
-			}
+		d.Tasks.Total++
+		if exec.Success {
+			d.Tasks.Succeeded++
+		}
+		if exec.LatencyMs > 0 {
+			durationTotal += exec.LatencyMs
+			durationCount++
 		}
-		dates = append(dates, date)
 	}
-	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
-
-	totalRequests := 0
-	totalErrors := 0
-	totalInputTokens := 0
-	totalOutputTokens := 0
-	totalCachedTokens := 0
-	modelStats := make(map[string]int)
-
-	for _, date := range dates {
-		models := usage[date]
-		for model, stats := range models {
-			totalRequests += stats.Requests
-			totalInputTokens += stats.InputTokens
-			totalOutputTokens += stats.OutputTokens
-			totalCachedTokens += stats.CachedTokens
-			if stats.LastError != "" {
-				totalErrors++
+	if d.Tasks.Total > 0 {
+		d.Tasks.SuccessRate = float64(d.Tasks.Succeeded) / float64(d.Tasks.Total) * 100
+	}
+	if durationCount > 0 {
+		d.Tasks.AvgDurationMs = durationTotal / durationCount
+	}
+
+	models := map[string]*modelRequest{}
+	for date, byModel := range src.Requests {
+		day, err := time.ParseInLocation("2006-01-02", date, now.Location())
+		if err != nil || day.AddDate(0, 0, 1).Before(from) {
+			continue
+		}
+		for model, u := range byModel {
+			r := &d.Requests
+			r.Total += u.Requests
+			r.InputTokens += u.InputTokens
+			r.OutputTokens += u.OutputTokens
+			r.CachedTokens += u.CachedTokens
+			m, ok := models[model]
+			if !ok {
+				m = &modelRequest{Model: model}
+				models[model] = m
+			}
+			m.Requests += u.Requests
+			if u.LastError != "" {
+				r.Errors++
+				m.HasError = true
 			}
-			modelStats[model] += stats.Requests
 		}
 	}
+	for _, m := range models {
+		d.Requests.Models = append(d.Requests.Models, *m)
+	}
+	sort.Slice(d.Requests.Models, func(i, j int) bool {
+		if d.Requests.Models[i].Requests != d.Requests.Models[j].Requests {
+			return d.Requests.Models[i].Requests > d.Requests.Models[j].Requests
+		}
+		return d.Requests.Models[i].Model < d.Requests.Models[j].Model
+	})
+
+	byBackend := func(r observability.UsageRecord) string { return r.Backend }
+	for _, row := range groupUsage(src.Spend, from, byBackend) {
+		d.Backends = append(d.Backends, backendCost{
+			Backend:   row.Key,
+			Calls:     row.Calls,
+			TokensIn:  row.TokensIn,
+			TokensOut: row.TokensOut,
+			Cost:      row.Cost,
+			Unpriced:  row.Unpriced,
+		})
+		d.Cost += row.Cost
+	}
+	sort.SliceStable(d.Backends, func(i, j int) bool { return d.Backends[i].Cost > d.Backends[j].Cost })
 
-	successRate := 100.0
-	if totalRequests > 0 {
-		successRate = float64(totalRequests-totalErrors) / float64(totalRequests) * 100
+	runs := map[string]int{}
+	for _, j := range src.Journals {
+		if j.Started.Before(from) {
+			continue
+		}
+		for _, e := range j.Entries {
+			path := e.Path
+			if rel, err := filepath.Rel(j.Workdir, path); err == nil && !strings.HasPrefix(rel, "..") {
+				path = rel
+			}
+			runs[path]++
+		}
+	}
+	for path, n := range runs {
+		d.Files = append(d.Files, fileEdits{Path: path, Runs: n})
+	}
+	sort.Slice(d.Files, func(i, j int) bool {
+		if d.Files[i].Runs != d.Files[j].Runs {
+			return d.Files[i].Runs > d.Files[j].Runs
+		}
+		return d.Files[i].Path < d.Files[j].Path
+	})
+	if len(d.Files) > maxStatsFiles {
+		d.Files = d.Files[:maxStatsFiles]
 	}
 
-	cacheHitRate := 0.0
-	if totalInputTokens > 0 {
-		cacheHitRate = float64(totalCachedTokens) / float64(totalInputTokens) * 100
+	weeks := map[string]*sentimentPeriod{}
+	for _, e := range src.Feedback {
+		if e.Timestamp.Before(from) {
+			continue
+		}
+		week := weekStart(e.Timestamp.In(now.Location())).Format("2006-01-02")
+		p, ok := weeks[week]
+		if !ok {
+			p = &sentimentPeriod{Week: week}
+			weeks[week] = p
+		}
+		switch e.Sentiment {
+		case feedback.SentimentGood:
+			p.Good++
+		case feedback.SentimentBad:
+			p.Bad++
+		}
+	}
+	for _, p := range weeks {
+		d.Feedback = append(d.Feedback, *p)
 	}
+	sort.Slice(d.Feedback, func(i, j int) bool { return d.Feedback[i].Week < d.Feedback[j].Week })
+	if len(d.Feedback) > maxStatsWeeks {
+		d.Feedback = d.Feedback[len(d.Feedback)-maxStatsWeeks:]
+	}
+
+	return d
+}
 
+// weekStart is the Monday starting t's week
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+func (d *statsDashboard) empty() bool {
+	return d.Tasks.Total == 0 && d.Requests.Total == 0 && len(d.Backends) == 0 && len(d.Files) == 0 && len(d.Feedback) == 0
+}
+
+func (d *statsDashboard) print() {
 	width := 88
 	fmt.Println(strings.Repeat("─", width))
 	fmt.Println()
 	fmt.Println("  Usage Statistics")
 	fmt.Println()
+	fmt.Printf("  Period:              %s\n", d.Period)
+	fmt.Println()
 
-	period := "All Time"
-	if todayOnly {
-		period = "Today"
-	} else if weekOnly {
-		period = "Last 7 Days"
+	fmt.Println("  Tasks")
+	fmt.Printf("  Tasks Run:           %d (%d this week)\n", d.Tasks.Total, d.Tasks.ThisWeek)
+	if d.Tasks.Total > 0 {
+		fmt.Printf("  Success Rate:        %.1f%%\n", d.Tasks.SuccessRate)
+	}
+	if d.Tasks.AvgDurationMs > 0 {
+		fmt.Printf("  Avg Duration:        %s\n", (time.Duration(d.Tasks.AvgDurationMs) * time.Millisecond).Round(time.Second))
 	}
-	fmt.Printf("  Period:              %s\n", period)
-	fmt.Printf("  Total Requests:      %d\n", totalRequests)
-	fmt.Printf("  Success Rate:        %.1f%%\n", successRate)
 	fmt.Println()
 
-	if totalInputTokens > 0 || totalOutputTokens > 0 {
-		fmt.Println("  Token Usage")
-		fmt.Printf("  Input Tokens:        %s\n", formatNumber(totalInputTokens))
-		fmt.Printf("  Output Tokens:       %s\n", formatNumber(totalOutputTokens))
-		if totalCachedTokens > 0 {
-			fmt.Printf("  Cached Tokens:       %s (%.1f%% cache hit)\n", formatNumber(totalCachedTokens), cacheHitRate)
-			fmt.Println()
-			fmt.Printf("   Cache savings: %s tokens, reducing costs\n", formatNumber(totalCachedTokens))
+	if r := d.Requests; r.Total > 0 {
+		fmt.Println("  Requests")
+		fmt.Printf("  Total Requests:      %d\n", r.Total)
+		fmt.Printf("  Success Rate:        %.1f%%\n", float64(r.Total-r.Errors)/float64(r.Total)*100)
+		if r.InputTokens > 0 || r.OutputTokens > 0 {
+			fmt.Printf("  Input Tokens:        %s\n", formatNumber(r.InputTokens))
+			fmt.Printf("  Output Tokens:       %s\n", formatNumber(r.OutputTokens))
+			if r.CachedTokens > 0 && r.InputTokens > 0 {
+				fmt.Printf("  Cached Tokens:       %s (%.1f%% cache hit)\n", formatNumber(r.CachedTokens), float64(r.CachedTokens)/float64(r.InputTokens)*100)
+			}
 		}
 		fmt.Println()
-	}
-
-	fmt.Println("  Model Usage          Requests  Status")
-	fmt.Println("  " + strings.Repeat("─", width-4))
 
-	type modelStat struct {
-		name     string
-		requests int
-		hasError bool
-	}
-	var stats []modelStat
-	for model, reqs := range modelStats {
-		hasErr := false
-		for _, date := range dates {
-			if models, ok := usage[date]; ok {
-				if m, ok := models[model]; ok && m.LastError != "" {
-					hasErr = true
-					break
-				}
+		fmt.Println("  Model Usage          Requests  Status")
+		fmt.Println("  " + strings.Repeat("─", width-4))
+		for _, m := range r.Models {
+			status := "✓"
+			if m.HasError {
+				status = "⚠"
 			}
+			modelName := m.Model
+			if parts := strings.Split(modelName, "/"); len(parts) > 1 {
+				modelName = parts[len(parts)-1]
+			}
+			if len(modelName) > 30 {
+				modelName = modelName[:27] + "..."
+			}
+			fmt.Printf("  %-32s %8d  %s\n", modelName, m.Requests, status)
 		}
-		stats = append(stats, modelStat{model, reqs, hasErr})
+		fmt.Println()
 	}
 
-	sort.Slice(stats, func(i, j int) bool {
-		return stats[i].requests > stats[j].requests
-	})
-
-	for _, s := range stats {
-		status := "✓"
-		if s.hasError {
-			status = "⚠"
+	if len(d.Backends) > 0 {
+		fmt.Println("  Cost by Backend         Calls    Tokens in   Tokens out        Cost")
+		fmt.Println("  " + strings.Repeat("─", width-4))
+		unpriced := false
+		for _, b := range d.Backends {
+			mark := ""
+			if b.Unpriced {
+				mark, unpriced = "*", true
+			}
+			fmt.Printf("  %-20s %8d  %11s  %11s  %10s\n", b.Backend, b.Calls, formatNumber(b.TokensIn), formatNumber(b.TokensOut), fmt.Sprintf("$%.4f%s", b.Cost, mark))
 		}
-		parts := strings.Split(s.name, "/")
-		modelName := s.name
-		if len(parts) > 1 {
-			modelName = parts[len(parts)-1]
+		fmt.Printf("  %-20s %8s  %11s  %11s  %10s\n", "Total", "", "", "", fmt.Sprintf("$%.4f", d.Cost))
+		if unpriced {
+			fmt.Println("  * includes models without catalog prices, counted as $0")
 		}
-		if len(modelName) > 30 {
-			modelName = modelName[:27] + "..."
+		fmt.Println()
+	}
+
+	if len(d.Files) > 0 {
+		fmt.Println("  Most Edited Files                                                      Runs")
+		fmt.Println("  " + strings.Repeat("─", width-4))
+		for _, f := range d.Files {
+			path := f.Path
+			if len(path) > 66 {
+				path = "..." + path[len(path)-63:]
+			}
+			fmt.Printf("  %-66s %8d\n", path, f.Runs)
 		}
-		fmt.Printf("  %-32s %8d  %s\n", modelName, s.requests, status)
+		fmt.Println()
 	}
 
-	fmt.Println()
-	fmt.Println("  » Tip: Use 'gptcode stats --today' for today's activity")
+	if len(d.Feedback) > 0 {
+		fmt.Println("  Feedback (week of)   Good   Bad  Trend")
+		fmt.Println("  " + strings.Repeat("─", width-4))
+		for _, p := range d.Feedback {
+			fmt.Printf("  %-18s %6d %5d  %s\n", p.Week, p.Good, p.Bad, sentimentBar(p.Good, p.Bad))
+		}
+		fmt.Println()
+	}
+
+	fmt.Println("  » Tip: Use 'gptcode stats --week' for the last 7 days, --json for dashboards")
 	fmt.Println()
 	fmt.Println(strings.Repeat("─", width))
+}
 
-	return nil
+// sentimentBar draws the share of good feedback as a 20-character bar
+func sentimentBar(good, bad int) string {
+	if good+bad == 0 {
+		return ""
+	}
+	n := good * 20 / (good + bad)
+	return strings.Repeat("█", n) + strings.Repeat("░", 20-n) + fmt.Sprintf(" %d%%", good*100/(good+bad))
 }
 
 func formatNumber(n int) string {
@@ -356,4 +602,5 @@ func init() {
 	statsCmd.Flags().Bool("today", false, "Show today's stats only")
 	statsCmd.Flags().Bool("week", false, "Show last 7 days")
 	statsCmd.Flags().Bool("all", false, "Show all time stats")
+	statsCmd.Flags().Bool("json", false, "Output as JSON")
 }
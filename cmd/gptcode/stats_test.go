@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"gptcode/internal/feedback"
+	"gptcode/internal/intelligence"
+	"gptcode/internal/observability"
+	"gptcode/internal/tools"
+)
+
+func TestBuildDashboard(t *testing.T) {
+	now := time.Date(2025, 3, 12, 12, 0, 0, 0, time.UTC) // a Wednesday
+	src := statsSources{
+		Requests: map[string]map[string]requestUsage{
+			"2025-03-12": {"groq/llama": {Requests: 3, InputTokens: 100}, "openai/gpt": {Requests: 1, LastError: "429"}},
+			"2025-01-01": {"groq/llama": {Requests: 50}},
+		},
+		History: []intelligence.TaskExecution{
+			{Timestamp: now.Add(-time.Hour), Success: true, LatencyMs: 4000},
+			{Timestamp: now.Add(-2 * time.Hour), Success: false},
+			{Timestamp: now.AddDate(0, 0, -3), Success: true, LatencyMs: 2000},
+			{Timestamp: now.AddDate(0, 0, -20), Success: true, LatencyMs: 60000},
+		},
+		Spend: []observability.UsageRecord{
+			{Time: now, Backend: "groq", Calls: 2, Cost: 0.01},
+			{Time: now, Backend: "openai", Calls: 1, Cost: 0.05},
+			{Time: now, Backend: "groq", Calls: 1, Cost: 0.02},
+		},
+		Journals: []*tools.Journal{
+			{Workdir: "/p", Started: now, Entries: []*tools.JournalEntry{{Path: "/p/a.go"}, {Path: "/p/b.go"}}},
+			{Workdir: "/p", Started: now, Entries: []*tools.JournalEntry{{Path: "/p/b.go"}}},
+		},
+		Feedback: []feedback.Event{
+			{Timestamp: now, Sentiment: feedback.SentimentGood},
+			{Timestamp: now.AddDate(0, 0, -1), Sentiment: feedback.SentimentBad},
+			{Timestamp: now.AddDate(0, 0, -7), Sentiment: feedback.SentimentGood},
+		},
+	}
+
+	d := buildDashboard(src, "Last 7 Days", now.AddDate(0, 0, -7), now)
+	if d.Tasks.Total != 3 || d.Tasks.ThisWeek != 3 || d.Tasks.Succeeded != 2 {
+		t.Errorf("unexpected tasks %+v", d.Tasks)
+	}
+	if d.Tasks.AvgDurationMs != 3000 {
+		t.Errorf("average duration should skip tasks without one, got %d", d.Tasks.AvgDurationMs)
+	}
+	if d.Requests.Total != 4 || d.Requests.Errors != 1 || d.Requests.Models[0].Model != "groq/llama" {
+		t.Errorf("unexpected requests %+v", d.Requests)
+	}
+	if len(d.Backends) != 2 || d.Backends[0].Backend != "openai" || d.Backends[1].Calls != 3 {
+		t.Errorf("cost should be grouped by backend, most expensive first: %+v", d.Backends)
+	}
+	if d.Cost < 0.0799 || d.Cost > 0.0801 {
+		t.Errorf("total cost = %f", d.Cost)
+	}
+	if len(d.Files) != 2 || d.Files[0] != (fileEdits{Path: "b.go", Runs: 2}) {
+		t.Errorf("unexpected files %+v", d.Files)
+	}
+	want := []sentimentPeriod{{Week: "2025-03-03", Good: 1}, {Week: "2025-03-10", Good: 1, Bad: 1}}
+	if len(d.Feedback) != 2 || d.Feedback[0] != want[0] || d.Feedback[1] != want[1] {
+		t.Errorf("feedback trend = %+v, want %+v", d.Feedback, want)
+	}
+
+	all := buildDashboard(src, "All Time", time.Time{}, now)
+	if all.Tasks.Total != 4 || all.Tasks.ThisWeek != 3 || all.Requests.Total != 54 {
+		t.Errorf("all-time dashboard should include older records: %+v %+v", all.Tasks, all.Requests)
+	}
+}
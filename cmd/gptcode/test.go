@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"gptcode/internal/config"
+	"gptcode/internal/output"
 	"gptcode/internal/testrunner"
 
 	"github.com/spf13/cobra"
@@ -53,7 +54,7 @@ func init() {
 }
 
 func runE2ETests(cmd *cobra.Command, args []string) error {
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -84,7 +85,7 @@ func runE2ETests(cmd *cobra.Command, args []string) error {
 			if err := saveDefaultE2EProfile(profile); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: Could not save default profile: %v\n", err)
 			} else {
-				fmt.Printf("[OK] Saved '%s' as default E2E profile\n", profile)
+				fmt.Println(output.OKf("Saved '%s' as default E2E profile", profile))
 			}
 		}
 	}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"gptcode/internal/auth"
+	"gptcode/internal/webui"
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse past runs in a local web dashboard",
+	Long: `Serve a read-only dashboard of past runs on http://127.0.0.1:<port>.
+
+It shows each session's command, cost and LLM exchanges, the diff of every
+file it changed against the file's current content, the current project's
+traces, daily spend per model and recorded feedback. Everything comes from
+the files gptcode already keeps under ~/.gptcode; nothing can be changed
+from the browser.
+
+The server only listens on localhost and rate-limits requests.
+
+Examples:
+  gptcode ui
+  gptcode ui --port 8080 --open`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		port, _ := cmd.Flags().GetInt("port")
+		open, _ := cmd.Flags().GetBool("open")
+
+		cwd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		server := webui.NewServer(webui.DefaultSources(cwd), 0, 0)
+
+		url := fmt.Sprintf("http://127.0.0.1:%d/", port)
+		fmt.Printf("Serving the dashboard on %s (Ctrl+C to stop)\n", url)
+		if open {
+			if err := auth.OpenBrowser(url); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to open browser: %v\n", err)
+			}
+		}
+		return server.ListenAndServe(port)
+	},
+}
+
+func init() {
+	uiCmd.Flags().Int("port", 7420, "Port to serve the dashboard on")
+	uiCmd.Flags().Bool("open", false, "Open the dashboard in the browser")
+	rootCmd.AddCommand(uiCmd)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/output"
+	"gptcode/internal/tools"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [session-id]",
+	Short: "Revert the file changes of an agent run",
+	Long: `Revert every file an agent run changed, without relying on git.
+
+Before an agent writes a file, its previous content is saved in a journal
+under ~/.gptcode/journal/<session> (the last 20 runs are kept). undo restores
+those files and deletes the files the run created. Without a session ID the
+most recent run that has not been undone is reverted; a prefix of the ID is
+enough.
+
+Files changed after the run by someone else are left alone and reported;
+use --force to revert them anyway.
+
+Examples:
+  gptcode undo --list
+  gptcode undo
+  gptcode undo 3f2a9c1e --force`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, _ := cmd.Flags().GetBool("list")
+		force, _ := cmd.Flags().GetBool("force")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if list {
+			return listJournals()
+		}
+
+		var journal *tools.Journal
+		var err error
+		if len(args) == 1 {
+			journal, err = tools.LoadJournal(args[0])
+		} else {
+			journal, err = latestJournal()
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Session %s: %s (%s)\n", journal.Session, journal.Command, journal.Started.Format("2006-01-02 15:04"))
+		for _, e := range journal.Entries {
+			fmt.Printf("  %s\n", relativeTo(journal.Workdir, e.Path))
+		}
+		if !yes && term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Printf("Revert these %d files? [y/N] ", len(journal.Entries))
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if answer := strings.ToLower(strings.TrimSpace(line)); answer != "y" && answer != "yes" {
+				return nil
+			}
+		}
+
+		res, err := journal.Undo(force)
+		if res != nil {
+			printUndo(journal.Workdir, res)
+		}
+		if err != nil {
+			return err
+		}
+		if len(res.Conflicts) > 0 {
+			return fmt.Errorf("%d files changed after the run were left alone; rerun with --force to revert them", len(res.Conflicts))
+		}
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().Bool("list", false, "List recorded runs")
+	undoCmd.Flags().Bool("force", false, "Also revert files changed after the run")
+	undoCmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	rootCmd.AddCommand(undoCmd)
+}
+
+// startJournal records the files this invocation's agents change so it can
+// be undone.
+func startJournal(cmd *cobra.Command, session string) {
+	if cmd == undoCmd {
+		return
+	}
+	cwd, _ := os.Getwd()
+	tools.SetJournal(tools.NewJournal(session, cmd.CommandPath(), cwd))
+}
+
+func latestJournal() (*tools.Journal, error) {
+	journals, err := tools.ListJournals()
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range journals {
+		if j.Undone == nil && len(j.Entries) > 0 {
+			return j, nil
+		}
+	}
+	return nil, fmt.Errorf("no agent runs to undo")
+}
+
+func listJournals() error {
+	journals, err := tools.ListJournals()
+	if err != nil {
+		return err
+	}
+	if len(journals) == 0 {
+		fmt.Println("No agent runs recorded")
+		return nil
+	}
+	for _, j := range journals {
+		if len(j.Entries) == 0 {
+			continue
+		}
+		status := ""
+		if j.Undone != nil {
+			status = " (undone)"
+		}
+		fmt.Printf("%s  %s  %-20s %3d files  %s%s\n", shortSession(j.Session), j.Started.Format("2006-01-02 15:04"),
+			j.Command, len(j.Entries), j.Workdir, status)
+	}
+	return nil
+}
+
+func printUndo(workdir string, res *tools.UndoResult) {
+	for _, p := range res.Restored {
+		fmt.Println(output.OKf("restored %s", relativeTo(workdir, p)))
+	}
+	for _, p := range res.Removed {
+		fmt.Println(output.OKf("removed %s", relativeTo(workdir, p)))
+	}
+	for _, p := range res.Conflicts {
+		fmt.Println(output.Warnf("changed after the run, left alone: %s", relativeTo(workdir, p)))
+	}
+}
+
+func relativeTo(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func shortSession(session string) string {
+	if len(session) > 8 {
+		return session[:8]
+	}
+	return session
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"gptcode/internal/config"
+	"gptcode/internal/observability"
+	"gptcode/internal/output"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report LLM spend by backend, model or day",
+	Long: `Report LLM spend recorded in ~/.gptcode/usage.jsonl.
+
+Every run records its calls, tokens and cost per backend and model, priced
+from the model catalog. Models without catalog prices are counted with a
+cost of 0 and marked with *.
+
+Examples:
+  gptcode usage                 # Spend per day, last 30 days
+  gptcode usage --by model      # Spend per model
+  gptcode usage --by backend --days 7
+  gptcode usage --since 2025-01-01`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		by, _ := cmd.Flags().GetString("by")
+		days, _ := cmd.Flags().GetInt("days")
+		since, _ := cmd.Flags().GetString("since")
+
+		var from time.Time
+		if since != "" {
+			t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+			if err != nil {
+				return fmt.Errorf("invalid --since %q (use YYYY-MM-DD)", since)
+			}
+			from = t
+		} else if days > 0 {
+			from = time.Now().AddDate(0, 0, -days)
+		}
+
+		keyOf, err := usageGrouping(by)
+		if err != nil {
+			return err
+		}
+
+		records, err := observability.LoadUsage(observability.UsagePath())
+		if err != nil {
+			return err
+		}
+		rows := groupUsage(records, from, keyOf)
+		if len(rows) == 0 {
+			fmt.Println("No usage recorded yet.")
+			return nil
+		}
+		printUsage(strings.ToUpper(by[:1])+by[1:], rows)
+		return nil
+	},
+}
+
+func init() {
+	usageCmd.Flags().String("by", "day", "Group by backend, model or day")
+	usageCmd.Flags().Int("days", 30, "Only include the last N days (0 for all)")
+	usageCmd.Flags().String("since", "", "Only include usage since a date (YYYY-MM-DD)")
+	rootCmd.AddCommand(usageCmd)
+
+	doCmd.Flags().Float64("max-cost", 0, "Stop when the run has cost this much in USD (defaults to defaults.max_cost_per_task)")
+	runCmd.Flags().Float64("max-cost", 0, "Stop when the session has cost this much in USD (defaults to defaults.max_cost_per_task)")
+	issueFixCmd.Flags().Float64("max-cost", 0, "Stop when the fix has cost this much in USD (defaults to defaults.max_cost_per_task)")
+	issueFixAllCmd.Flags().Float64("max-cost", 0, "Stop when the batch has cost this much in USD (defaults to defaults.max_cost_per_task)")
+}
+
+// usageRow is the spend of one backend, model or day.
+type usageRow struct {
+	Key       string
+	Calls     int
+	TokensIn  int
+	TokensOut int
+	Cost      float64
+	Unpriced  bool
+}
+
+func usageGrouping(by string) (func(observability.UsageRecord) string, error) {
+	switch by {
+	case "backend":
+		return func(r observability.UsageRecord) string { return r.Backend }, nil
+	case "model":
+		return func(r observability.UsageRecord) string { return r.Backend + "/" + r.Model }, nil
+	case "day":
+		return func(r observability.UsageRecord) string { return r.Time.Local().Format("2006-01-02") }, nil
+	}
+	return nil, fmt.Errorf("unknown grouping %q (use backend, model or day)", by)
+}
+
+func groupUsage(records []observability.UsageRecord, from time.Time, keyOf func(observability.UsageRecord) string) []usageRow {
+	byKey := map[string]*usageRow{}
+	for _, rec := range records {
+		if rec.Time.Before(from) {
+			continue
+		}
+		key := keyOf(rec)
+		row, ok := byKey[key]
+		if !ok {
+			row = &usageRow{Key: key}
+			byKey[key] = row
+		}
+		row.Calls += rec.Calls
+		row.TokensIn += rec.TokensIn
+		row.TokensOut += rec.TokensOut
+		row.Cost += rec.Cost
+		row.Unpriced = row.Unpriced || rec.Unpriced
+	}
+
+	rows := make([]usageRow, 0, len(byKey))
+	for _, row := range byKey {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Key < rows[j].Key })
+	return rows
+}
+
+func printUsage(header string, rows []usageRow) {
+	width := len(header)
+	for _, row := range rows {
+		if len(row.Key) > width {
+			width = len(row.Key)
+		}
+	}
+
+	fmt.Printf("%-*s  %7s  %12s  %12s  %10s\n", width, header, "Calls", "Tokens in", "Tokens out", "Cost")
+	var total usageRow
+	unpriced := false
+	for _, row := range rows {
+		mark := ""
+		if row.Unpriced {
+			mark, unpriced = "*", true
+		}
+		fmt.Printf("%-*s  %7d  %12d  %12d  %10s\n", width, row.Key, row.Calls, row.TokensIn, row.TokensOut, fmt.Sprintf("$%.4f%s", row.Cost, mark))
+		total.Calls += row.Calls
+		total.TokensIn += row.TokensIn
+		total.TokensOut += row.TokensOut
+		total.Cost += row.Cost
+	}
+	fmt.Printf("%-*s  %7d  %12d  %12d  %10s\n", width, "Total", total.Calls, total.TokensIn, total.TokensOut, fmt.Sprintf("$%.4f", total.Cost))
+	if unpriced {
+		fmt.Println("\n* includes models without catalog prices, counted as $0")
+	}
+}
+
+// startCostMeter installs the cost meter for this invocation. Commands with
+// --max-cost stop once the limit is spent, asking first when on a terminal.
+// GPTCODE_MAX_COST sets a limit for any command; the daemon budgets its
+// jobs with it.
+func startCostMeter(cmd *cobra.Command, session string) {
+	limit := 0.0
+	f := cmd.Flags().Lookup("max-cost")
+	switch env := os.Getenv("GPTCODE_MAX_COST"); {
+	case f != nil && f.Changed:
+		limit, _ = cmd.Flags().GetFloat64("max-cost")
+	case env != "":
+		limit, _ = strconv.ParseFloat(env, 64)
+	case f != nil:
+		setup, _ := config.LoadEffectiveSetup()
+		limit = setup.Defaults.MaxCostPerTask
+	}
+
+	meter := observability.NewCostMeter(session, cmd.CommandPath(), limit)
+	if limit > 0 && term.IsTerminal(int(os.Stdin.Fd())) {
+		meter.OnExceeded = confirmOverBudget
+	}
+	observability.SetCostMeter(meter)
+}
+
+func confirmOverBudget(spent, limit float64) bool {
+	defer output.LockTerminal()()
+	fmt.Fprintf(os.Stderr, "\n%s Continue? [y/N] ", output.Warnf("This run has cost $%.4f, over its $%.4f budget.", spent, limit))
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// saveCostMeter appends the session's spend to the usage log.
+func saveCostMeter() {
+	meter := observability.ActiveCostMeter()
+	if meter == nil {
+		return
+	}
+	if err := meter.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record usage: %v\n", err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 
+	"gptcode/internal/index"
 	"gptcode/internal/llm"
 	"gptcode/internal/tools"
 )
@@ -108,6 +109,10 @@ func (a *AnalyzerAgent) Analyze(ctx context.Context, task string, statusCallback
 		},
 	}
 
+	if def, ok := tools.ExternalToolDefinition(index.ToolName); ok {
+		toolDefs = append(toolDefs, def)
+	}
+
 	analyzePrompt := fmt.Sprintf(`Analyze the codebase for this task:
 
 Task: %s
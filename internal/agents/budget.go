@@ -0,0 +1,111 @@
+package agents
+
+import (
+	"fmt"
+	"strings"
+
+	"gptcode/internal/catalog"
+	"gptcode/internal/observability"
+)
+
+// tightBudgetShare is the share of the limit below which the remaining
+// budget is tight and plans should stay narrow
+const tightBudgetShare = 0.25
+
+// ModelPrice is the model an agent of the task runs on and its catalog
+// prices in USD per million tokens
+type ModelPrice struct {
+	Agent          string
+	Backend        string
+	Model          string
+	PromptPerM     float64
+	CompletionPerM float64
+	Priced         bool
+}
+
+// Budget is what a task may still spend and what its models cost, for the
+// planner to pick a strategy that fits
+type Budget struct {
+	Spent float64
+	// Limit is the run's spending limit in USD; 0 means none
+	Limit  float64
+	Models []ModelPrice
+}
+
+// NewBudget reads the spend and limit from meter, which may be nil, and
+// prices models with its price function, or the catalog without one.
+func NewBudget(meter *observability.CostMeter, models ...ModelPrice) Budget {
+	price := observability.PriceFunc(catalog.Price)
+	var b Budget
+	if meter != nil {
+		b.Spent, b.Limit = meter.Spent(), meter.Limit()
+		if meter.Price != nil {
+			price = meter.Price
+		}
+	}
+	for _, m := range models {
+		m.PromptPerM, m.CompletionPerM, m.Priced = price(m.Backend, m.Model)
+		b.Models = append(b.Models, m)
+	}
+	return b
+}
+
+// Remaining returns what is left of the limit, and false without one
+func (b Budget) Remaining() (float64, bool) {
+	if b.Limit <= 0 {
+		return 0, false
+	}
+	return max(b.Limit-b.Spent, 0), true
+}
+
+// Tight reports whether less than a quarter of the limit is left
+func (b Budget) Tight() bool {
+	left, limited := b.Remaining()
+	return limited && left < b.Limit*tightBudgetShare
+}
+
+// String describes the budget and prices for the planner's prompt, with
+// guidance on how much of the codebase the plan can afford to touch
+func (b Budget) String() string {
+	var sb strings.Builder
+	if left, limited := b.Remaining(); limited {
+		fmt.Fprintf(&sb, "Spent $%.4f of $%.4f; $%.4f left for planning, editing, review and retries.\n", b.Spent, b.Limit, left)
+	} else {
+		fmt.Fprintf(&sb, "No spending limit; spent $%.4f so far.\n", b.Spent)
+	}
+	if len(b.Models) > 0 {
+		sb.WriteString("Model prices (USD per 1M tokens, input / output):\n")
+		for _, m := range b.Models {
+			price := "unknown"
+			switch {
+			case m.Priced && m.PromptPerM == 0 && m.CompletionPerM == 0:
+				price = "free"
+			case m.Priced:
+				price = fmt.Sprintf("$%.2f / $%.2f", m.PromptPerM, m.CompletionPerM)
+			}
+			fmt.Fprintf(&sb, "- %s: %s/%s %s\n", m.Agent, m.Backend, m.Model, price)
+		}
+	}
+	switch {
+	case b.Tight():
+		sb.WriteString("The budget is TIGHT: skip whole-repository analysis, target the fewest files that satisfy the task, name them explicitly and plan a single edit pass.")
+	case b.Limit > 0:
+		sb.WriteString("Fit the plan to the budget: prefer naming the specific files to change over exploring the repository.")
+	default:
+		sb.WriteString("Prefer plans that read and change few files; every file read is paid for on each editor step.")
+	}
+	return sb.String()
+}
+
+// BudgetStrategy returns the "## Budget Strategy" section of a plan, the
+// planner's account of how it fit the budget, or "" without one
+func BudgetStrategy(plan string) string {
+	_, section, found := strings.Cut(plan, "## Budget Strategy")
+	if !found {
+		return ""
+	}
+	if next := strings.Index(section, "\n#"); next >= 0 {
+		section = section[:next]
+	}
+	return strings.TrimSpace(section)
+}
@@ -0,0 +1,78 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/observability"
+)
+
+type promptProvider struct {
+	prompt string
+}
+
+func (p *promptProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.prompt = req.UserPrompt
+	return &llm.ChatResponse{Text: "# Plan\n## Files to modify\n- a.go\n## Budget Strategy\nTarget a.go only.\n"}, nil
+}
+
+func TestBudget(t *testing.T) {
+	meter := observability.NewCostMeter("s", "gptcode do", 1)
+	meter.Price = func(backend, model string) (float64, float64, bool) {
+		switch model {
+		case "big":
+			return 3, 15, true
+		case "local":
+			return 0, 0, true
+		}
+		return 0, 0, false
+	}
+	meter.Record("openai", "big", 100000, 30000)
+
+	b := NewBudget(meter,
+		ModelPrice{Agent: "planner", Backend: "openai", Model: "big"},
+		ModelPrice{Agent: "editor", Backend: "ollama", Model: "local"},
+		ModelPrice{Agent: "reviewer", Backend: "x", Model: "new"})
+	if left, limited := b.Remaining(); !limited || left < 0.249 || left > 0.251 || b.Tight() {
+		t.Fatalf("Remaining = %v, %v, tight %v; want 0.25 left, not tight", left, limited, b.Tight())
+	}
+	text := b.String()
+	for _, want := range []string{"$0.2500 left", "planner: openai/big $3.00 / $15.00", "editor: ollama/local free", "reviewer: x/new unknown", "Fit the plan"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("budget text lacks %q:\n%s", want, text)
+		}
+	}
+
+	meter.Record("openai", "big", 10000, 0)
+	if b = NewBudget(meter); !b.Tight() || !strings.Contains(b.String(), "TIGHT") {
+		t.Errorf("under a quarter left should be tight:\n%s", b)
+	}
+	if NewBudget(nil).Tight() {
+		t.Error("a run without a limit is never tight")
+	}
+}
+
+func TestPlannerBudget(t *testing.T) {
+	p := &promptProvider{}
+	planner := NewPlanner(p, "m")
+	if _, err := planner.CreatePlan(context.Background(), "fix a.go", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(p.prompt, "Budget:") {
+		t.Error("no budget section without a budget")
+	}
+
+	planner.SetBudget(Budget{Spent: 0.1, Limit: 0.2})
+	plan, err := planner.CreatePlan(context.Background(), "fix a.go", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(p.prompt, "Budget:\n---\nSpent $0.1000 of $0.2000") || !strings.Contains(p.prompt, "## Budget Strategy") {
+		t.Errorf("the budget is not in the prompt:\n%s", p.prompt)
+	}
+	if got := BudgetStrategy(plan); got != "Target a.go only." {
+		t.Errorf("BudgetStrategy = %q", got)
+	}
+}
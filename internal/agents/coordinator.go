@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"gptcode/internal/llm"
 )
@@ -14,6 +16,8 @@ type Coordinator struct {
 	query      *QueryAgent
 	research   *ResearchAgent
 	review     *ReviewAgent
+	custom     []*CustomAgent
+	timeout    TimeoutFunc
 }
 
 func NewCoordinator(
@@ -34,6 +38,49 @@ func NewCoordinator(
 	}
 }
 
+// SetStream streams the answering agent's response to fn as it is
+// generated. Providers without streaming deliver the response in one chunk.
+func (c *Coordinator) SetStream(fn StreamCallback) {
+	c.editor.SetStream(fn)
+	c.query.SetStream(fn)
+	c.research.SetStream(fn)
+	c.review.SetStream(fn)
+	for _, a := range c.custom {
+		a.SetStream(fn)
+	}
+}
+
+// Register adds a custom agent. Messages that mention it as @name or
+// contain one of its triggers go to it instead of the classified agent.
+func (c *Coordinator) Register(agent *CustomAgent) {
+	c.custom = append(c.custom, agent)
+}
+
+// customAgentFor returns the first registered agent message is meant for
+func (c *Coordinator) customAgentFor(message string) *CustomAgent {
+	message = strings.TrimSpace(message)
+	for _, a := range c.custom {
+		if a.Matches(message) {
+			return a
+		}
+	}
+	return nil
+}
+
+// SetTimeouts limits each agent's run to timeout(agent), cancelling the
+// request in flight when it runs over
+func (c *Coordinator) SetTimeouts(timeout TimeoutFunc) {
+	c.timeout = timeout
+}
+
+// agentTimeout returns how long one run of agent may take
+func (c *Coordinator) agentTimeout(agent string) time.Duration {
+	if c.timeout == nil {
+		return 0
+	}
+	return c.timeout(agent)
+}
+
 func (c *Coordinator) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
 	// Use the last user message for intent classification
 	lastMessage := ""
@@ -44,6 +91,19 @@ func (c *Coordinator) Execute(ctx context.Context, history []llm.ChatMessage, st
 		}
 	}
 
+	if custom := c.customAgentFor(lastMessage); custom != nil {
+		if statusCallback != nil {
+			statusCallback(fmt.Sprintf("Coordinator: Routing to %s agent...", custom.Spec.Name))
+		}
+		ctx, cancel := WithTimeout(ctx, custom.Spec.Name, c.agentTimeout(custom.Spec.Name))
+		defer cancel()
+		res, err := custom.Execute(ctx, history, statusCallback)
+		if cause := stopped(ctx); err != nil && cause != nil {
+			err = cause
+		}
+		return res, err
+	}
+
 	if statusCallback != nil {
 		statusCallback("Classifier: Analyzing intent...")
 	}
@@ -64,20 +124,30 @@ func (c *Coordinator) Execute(ctx context.Context, history []llm.ChatMessage, st
 		statusCallback(fmt.Sprintf("Coordinator: Routing to %s agent...", intent))
 	}
 
+	agent := string(intent)
 	switch intent {
-	case IntentEdit:
-		res, _, err := c.editor.Execute(ctx, history, statusCallback)
-		return res, err
-	case IntentQuery:
-		return c.query.Execute(ctx, history, statusCallback)
+	case IntentEdit, IntentTest:
+		agent = "editor"
+	case IntentReview:
+		agent = "reviewer"
+	}
+	ctx, cancel := WithTimeout(ctx, agent, c.agentTimeout(agent))
+	defer cancel()
+
+	var res string
+	switch intent {
+	case IntentEdit, IntentTest:
+		res, _, err = c.editor.Execute(ctx, history, statusCallback)
 	case IntentResearch:
-		return c.research.Execute(ctx, history, statusCallback)
-	case IntentTest:
-		res, _, err := c.editor.Execute(ctx, history, statusCallback)
-		return res, err
+		res, err = c.research.Execute(ctx, history, statusCallback)
 	case IntentReview:
-		return c.review.Execute(ctx, history, statusCallback)
+		res, err = c.review.Execute(ctx, history, statusCallback)
 	default:
-		return c.query.Execute(ctx, history, statusCallback)
+		res, err = c.query.Execute(ctx, history, statusCallback)
+	}
+	// Report the timeout or cancellation rather than the failed request
+	if cause := stopped(ctx); err != nil && cause != nil {
+		err = cause
 	}
+	return res, err
 }
@@ -0,0 +1,298 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/tools"
+)
+
+// CustomAgentSpec defines an agent in a YAML file under
+// ~/.gptcode/agents:
+//
+//	name: migrator
+//	description: Moves database code to the new query builder
+//	system_prompt: |
+//	  You migrate raw SQL to the query builder in internal/db...
+//	tools: [read_file, search_code, apply_patch, run_command]
+//	triggers: ["migrate", "query builder"]
+//	model:
+//	  agent: editor
+type CustomAgentSpec struct {
+	Name         string `yaml:"name"`
+	Description  string `yaml:"description,omitempty"`
+	SystemPrompt string `yaml:"system_prompt"`
+	// Tools the agent may call, builtin, MCP or the project's shell tools;
+	// none makes a plain chat
+	Tools []string `yaml:"tools,omitempty"`
+	// Triggers route chat messages containing one of the phrases to the
+	// agent; "@name" at the start of a message always does
+	Triggers      []string   `yaml:"triggers,omitempty"`
+	Model         ModelHints `yaml:"model,omitempty"`
+	MaxIterations int        `yaml:"max_iterations,omitempty"`
+
+	// Path is the file the agent was loaded from
+	Path string `yaml:"-"`
+}
+
+// ModelHints choose the agent's model, the first one set winning: an
+// explicit model or alias, the model configured for a builtin agent, or the
+// model selector's pick for an action.
+type ModelHints struct {
+	ID      string `yaml:"id,omitempty"`
+	Backend string `yaml:"backend,omitempty"`
+	// Agent is router, query, editor or research
+	Agent string `yaml:"agent,omitempty"`
+	// Action is edit, review, plan, research or route
+	Action     string `yaml:"action,omitempty"`
+	Complexity string `yaml:"complexity,omitempty"`
+}
+
+// builtinAgents are the names custom agents cannot take
+var builtinAgents = map[string]bool{
+	"router": true, "query": true, "editor": true, "research": true, "review": true, "reviewer": true,
+}
+
+var agentNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// defaultCustomIterations is how many tool rounds a custom agent gets
+// before it must answer
+const defaultCustomIterations = 8
+
+// CustomAgentsDir is ~/.gptcode/agents
+func CustomAgentsDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "agents")
+}
+
+// LoadCustomAgents reads every *.yaml and *.yml file in dir, sorted by
+// name. A missing directory has no agents.
+func LoadCustomAgents(dir string) ([]CustomAgentSpec, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var specs []CustomAgentSpec
+	seen := map[string]string{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		spec, err := LoadCustomAgent(path)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[spec.Name]; ok {
+			return nil, fmt.Errorf("agent %s is defined in both %s and %s", spec.Name, other, path)
+		}
+		seen[spec.Name] = path
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name < specs[j].Name })
+	return specs, nil
+}
+
+// LoadCustomAgent reads and validates one agent definition. The name
+// defaults to the file's name.
+func LoadCustomAgent(path string) (CustomAgentSpec, error) {
+	var spec CustomAgentSpec
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return spec, err
+	}
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if spec.Name == "" {
+		spec.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	spec.Path = path
+	if err := spec.Validate(); err != nil {
+		return spec, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// Validate checks the name, prompt and model hints. Tools are checked when
+// the agent runs, once MCP servers have registered theirs.
+func (s CustomAgentSpec) Validate() error {
+	if !agentNamePattern.MatchString(s.Name) {
+		return fmt.Errorf("invalid agent name %q: use lowercase letters, digits, - and _", s.Name)
+	}
+	if builtinAgents[s.Name] {
+		return fmt.Errorf("agent name %q is taken by a builtin agent", s.Name)
+	}
+	if strings.TrimSpace(s.SystemPrompt) == "" {
+		return fmt.Errorf("agent %s has no system_prompt", s.Name)
+	}
+	switch s.Model.Agent {
+	case "", "router", "query", "editor", "research":
+	default:
+		return fmt.Errorf("agent %s: model.agent must be router, query, editor or research", s.Name)
+	}
+	switch s.Model.Action {
+	case "", "edit", "review", "plan", "research", "route":
+	default:
+		return fmt.Errorf("agent %s: model.action must be edit, review, plan, research or route", s.Name)
+	}
+	return nil
+}
+
+// toolDefinitions returns the definitions of the named tools, failing on
+// names no builtin or registered external tool has
+func toolDefinitions(names []string) ([]interface{}, error) {
+	available := map[string]map[string]interface{}{}
+	for _, def := range tools.GetAvailableTools() {
+		available[def["function"].(map[string]interface{})["name"].(string)] = def
+	}
+	available["ask_user"] = tools.AskUserTool()
+
+	var defs []interface{}
+	for _, name := range names {
+		def, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tool %q", name)
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// CustomAgent runs a user-defined agent: its system prompt, limited to its
+// tools, on its model
+type CustomAgent struct {
+	Spec     CustomAgentSpec
+	provider llm.Provider
+	cwd      string
+	model    string
+	stream   StreamCallback
+}
+
+func NewCustomAgent(spec CustomAgentSpec, provider llm.Provider, cwd string, model string) *CustomAgent {
+	return &CustomAgent{
+		Spec:     spec,
+		provider: provider,
+		cwd:      cwd,
+		model:    model,
+	}
+}
+
+// SetStream streams the agent's answers to fn as they are generated.
+func (a *CustomAgent) SetStream(fn StreamCallback) {
+	a.stream = fn
+}
+
+// Matches reports whether a chat message is meant for the agent: it starts
+// with @name or contains one of the agent's triggers
+func (a *CustomAgent) Matches(message string) bool {
+	if mention := "@" + a.Spec.Name; message == mention || strings.HasPrefix(message, mention+" ") {
+		return true
+	}
+	lower := strings.ToLower(message)
+	for _, t := range a.Spec.Triggers {
+		if t != "" && strings.Contains(lower, strings.ToLower(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *CustomAgent) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
+	toolDefs, err := toolDefinitions(a.Spec.Tools)
+	if err != nil {
+		return "", err
+	}
+	allowed := map[string]bool{}
+	for _, name := range a.Spec.Tools {
+		allowed[name] = true
+	}
+	systemPrompt := a.Spec.SystemPrompt
+	if len(toolDefs) > 0 {
+		systemPrompt += "\n\n" + tools.UntrustedDataNotice
+	}
+
+	messages := make([]llm.ChatMessage, len(history))
+	copy(messages, history)
+
+	maxIterations := a.Spec.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultCustomIterations
+	}
+	for i := 0; i < maxIterations; i++ {
+		if err := stopped(ctx); err != nil {
+			return "", err
+		}
+		if statusCallback != nil {
+			statusCallback(fmt.Sprintf("%s: Thinking (Iteration %d/%d)...", a.Spec.Name, i+1, maxIterations))
+		}
+
+		resp, err := llm.StreamChat(ctx, a.provider, llm.ChatRequest{
+			SystemPrompt: systemPrompt,
+			Messages:     messages,
+			Tools:        toolDefs,
+			Model:        a.model,
+		}, a.stream)
+		if err != nil {
+			return "", err
+		}
+		if len(resp.ToolCalls) == 0 {
+			return resp.Text, nil
+		}
+
+		messages = append(messages, llm.ChatMessage{
+			Role:      "assistant",
+			Content:   resp.Text,
+			ToolCalls: resp.ToolCalls,
+		})
+		for _, tc := range resp.ToolCalls {
+			if statusCallback != nil {
+				statusCallback(fmt.Sprintf("%s: Executing %s...", a.Spec.Name, tc.Name))
+			}
+			content := fmt.Sprintf("Error: tool %s is not available to this agent", tc.Name)
+			if allowed[tc.Name] {
+				result := tools.ExecuteToolFromLLM(tools.LLMToolCall{ID: tc.ID, Name: tc.Name, Arguments: tc.Arguments}, a.cwd)
+				content = result.Result
+				if result.Error != "" {
+					content = "Error: " + result.Error
+				} else if content == "" {
+					content = "Success"
+				}
+			}
+			messages = append(messages, llm.ChatMessage{
+				Role:       "tool",
+				Content:    content,
+				Name:       tc.Name,
+				ToolCallID: tc.ID,
+			})
+		}
+		if err := tools.PendingClarification(); err != nil {
+			return "", err
+		}
+	}
+
+	if statusCallback != nil {
+		statusCallback(fmt.Sprintf("%s: Finalizing...", a.Spec.Name))
+	}
+	finalResp, err := llm.StreamChat(ctx, a.provider, llm.ChatRequest{
+		SystemPrompt: a.Spec.SystemPrompt + "\n\nYou are out of tool calls. Answer with what you have done and found.",
+		Messages:     messages,
+		Model:        a.model,
+	}, a.stream)
+	if err != nil {
+		return "", err
+	}
+	return finalResp.Text, nil
+}
@@ -0,0 +1,89 @@
+package agents
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+func TestLoadCustomAgents(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("migrator.yaml", "system_prompt: Migrate SQL.\ntools: [read_file]\nmodel:\n  agent: editor\n")
+	write("docs.yml", "name: docs-writer\nsystem_prompt: Write docs.\ntriggers: [document]\n")
+	write("notes.txt", "not an agent")
+
+	specs, err := LoadCustomAgents(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 2 || specs[0].Name != "docs-writer" || specs[1].Name != "migrator" {
+		t.Fatalf("unexpected agents %+v", specs)
+	}
+	if specs[1].Model.Agent != "editor" || specs[1].Tools[0] != "read_file" {
+		t.Errorf("unexpected migrator %+v", specs[1])
+	}
+
+	for content, want := range map[string]string{
+		"name: editor\nsystem_prompt: x\n":                         "builtin",
+		"name: Bad Name\nsystem_prompt: x\n":                       "invalid agent name",
+		"name: quiet\n":                                            "no system_prompt",
+		"name: picky\nsystem_prompt: x\nmodel:\n  action: guess\n": "model.action",
+	} {
+		write("bad.yaml", content)
+		if _, err := LoadCustomAgents(dir); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want an error about %s", content, err, want)
+		}
+	}
+
+	if specs, err := LoadCustomAgents(filepath.Join(dir, "missing")); err != nil || len(specs) != 0 {
+		t.Errorf("a missing directory should have no agents, got %v, %v", specs, err)
+	}
+}
+
+func TestCustomAgentMatches(t *testing.T) {
+	a := NewCustomAgent(CustomAgentSpec{Name: "docs", Triggers: []string{"Write the README"}}, nil, ".", "m")
+	for msg, want := range map[string]bool{
+		"@docs explain the api":   true,
+		"@docs":                   true,
+		"@docsy explain":          false,
+		"please write the readme": true,
+		"explain the api":         false,
+	} {
+		if got := a.Matches(msg); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+func TestCustomAgentRefusesUnlistedTools(t *testing.T) {
+	dir := t.TempDir()
+	mock := &mockProvider{
+		responses: []llm.ChatResponse{
+			{ToolCalls: []llm.ChatToolCall{{ID: "1", Name: "write_file", Arguments: `{"path": "x.txt", "content": "x"}`}}},
+			{Text: "done"},
+		},
+	}
+	a := NewCustomAgent(CustomAgentSpec{Name: "reader", SystemPrompt: "Read only.", Tools: []string{"read_file"}}, mock, dir, "m")
+	result, err := a.Execute(context.Background(), []llm.ChatMessage{{Role: "user", Content: "write x"}}, nil)
+	if err != nil || result != "done" {
+		t.Fatalf("Execute = %q, %v", result, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x.txt")); !os.IsNotExist(err) {
+		t.Error("the agent wrote a file with a tool it does not have")
+	}
+
+	a.Spec.Tools = []string{"no_such_tool"}
+	if _, err := a.Execute(context.Background(), nil, nil); err == nil {
+		t.Error("expected an error for an unknown tool")
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"gptcode/internal/llm"
 	"gptcode/internal/observability"
+	"gptcode/internal/prompt"
 	"gptcode/internal/tools"
 )
 
@@ -19,6 +20,10 @@ type EditorAgent struct {
 	model        string
 	allowedFiles []string
 	observer     observability.Observer
+	versions     *tools.FileVersions
+	stream       StreamCallback
+	timeout      time.Duration
+	corrections  string
 }
 
 func NewEditor(provider llm.Provider, cwd string, model string) *EditorAgent {
@@ -28,6 +33,7 @@ func NewEditor(provider llm.Provider, cwd string, model string) *EditorAgent {
 		model:        model,
 		allowedFiles: nil,
 		observer:     nil,
+		versions:     tools.NewFileVersions(),
 	}
 }
 
@@ -39,6 +45,7 @@ func NewEditorWithObserver(provider llm.Provider, cwd string, model string, obse
 		model:        model,
 		allowedFiles: nil,
 		observer:     observer,
+		versions:     tools.NewFileVersions(),
 	}
 }
 
@@ -48,15 +55,27 @@ func NewEditorWithFileValidation(provider llm.Provider, cwd string, model string
 		cwd:          cwd,
 		model:        model,
 		allowedFiles: allowedFiles,
+		versions:     tools.NewFileVersions(),
 	}
 }
 
+// SetStream streams the editor's messages to fn as they are generated.
+func (e *EditorAgent) SetStream(fn StreamCallback) {
+	e.stream = fn
+}
+
+// SetFileVersions shares a file version tracker with the editor so that
+// several editors working on the same tree detect each other's writes.
+func (e *EditorAgent) SetFileVersions(versions *tools.FileVersions) {
+	e.versions = versions
+}
+
 const editorPrompt = `You are a code editor and executor. Your job is to modify files AND execute shell commands.
 
 WORKFLOW:
 1. For file reading: Call read_file to get current content
 2. For shell commands: Call run_command (e.g., "gh pr list", "go test", "npm run lint")
-3. For file modification: Call apply_patch for small changes, or write_file for new files/large rewrites
+3. For file modification: Call apply_patch for small changes, apply_diff for changes spanning several places or files, or write_file for new files/large rewrites
 4. **WHEN DONE**: Stop immediately. Do NOT call tools again. Return success message.
 
 CRITICAL RULES:
@@ -128,8 +147,25 @@ After executing ALL required changes:
 
 Be direct. No explanations unless there's an error.`
 
+// SetCorrections adds past feedback corrections on similar tasks, as
+// rendered by feedback.FormatCorrections, to the editor's system prompt
+func (e *EditorAgent) SetCorrections(section string) {
+	e.corrections = section
+}
+
+// SetTimeout limits each Execute to d, cancelling the request in flight
+// when it runs over; 0 means no limit
+func (e *EditorAgent) SetTimeout(d time.Duration) {
+	e.timeout = d
+}
+
 func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, []string, error) {
+	ctx, cancel := WithTimeout(ctx, "editor", e.timeout)
+	defer cancel()
+
 	var modifiedFiles []string
+	// Imports are fixed once, over the finished edit
+	defer func() { tools.FixGoImports(e.cwd, modifiedFiles) }()
 	toolDefs := []interface{}{
 		map[string]interface{}{
 			"type": "function",
@@ -227,6 +263,11 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 				},
 			},
 		},
+		tools.ApplyDiffTool(),
+		tools.AskUserTool(),
+	}
+	for _, def := range tools.ExternalToolDefinitions() {
+		toolDefs = append(toolDefs, def)
 	}
 
 	// Copy history to avoid mutating the original slice in the loop
@@ -255,27 +296,49 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 	// Set to 10 to allow complex tasks: 3-4 discovery calls + 2-3 reads + 2-3 writes
 	maxToolChainDepth := 10
 	for iteration := 0; iteration < maxToolChainDepth; iteration++ {
+		if err := stopped(ctx); err != nil {
+			return "", modifiedFiles, err
+		}
+		// A question too ambiguous to guess at stops the task
+		if iteration > 0 {
+			if err := tools.PendingClarification(); err != nil {
+				return "", modifiedFiles, err
+			}
+		}
+
 		llmStart := time.Now()
-		resp, err := e.provider.Chat(ctx, llm.ChatRequest{
-			SystemPrompt: editorPrompt,
+		resp, err := llm.StreamChat(ctx, e.provider, llm.ChatRequest{
+			SystemPrompt: e.systemPrompt(),
 			Messages:     messages,
 			Tools:        toolDefs,
 			Model:        e.model,
-		})
+		}, e.stream)
 		llmDuration := time.Since(llmStart)
-		if err != nil {
-			return "", nil, err
-		}
 
 		// Emit LLM request event to observer
-		if e.observer != nil && resp.TokenUsage != nil {
-			e.observer.Emit(&observability.LLMRequestEvent{
+		if e.observer != nil {
+			event := &observability.LLMRequestEvent{
 				BaseEvent: observability.BaseEvent{Time: time.Now()},
 				Model:     e.model,
-				TokensIn:  resp.TokenUsage.PromptTokens,
-				TokensOut: resp.TokenUsage.CompletionTokens,
 				Duration:  llmDuration,
-			})
+			}
+			if err != nil {
+				event.Error = err.Error()
+			} else {
+				event.Backend = resp.Backend
+				event.Cost = resp.Cost
+				if resp.TokenUsage != nil {
+					event.TokensIn = resp.TokenUsage.PromptTokens
+					event.TokensOut = resp.TokenUsage.CompletionTokens
+				}
+			}
+			e.observer.Emit(event)
+		}
+		if cause := stopped(ctx); cause != nil {
+			return "", modifiedFiles, cause
+		}
+		if err != nil {
+			return "", nil, err
 		}
 
 		if os.Getenv("GPTCODE_DEBUG") == "1" {
@@ -309,10 +372,10 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 						statusCallback(fmt.Sprintf("Editor: Executing %s...", tc.Name))
 					}
 
-					if tc.Name == "write_file" || tc.Name == "apply_patch" {
+					if tc.Name == "write_file" || tc.Name == "apply_patch" || tc.Name == "apply_diff" {
 						var argsMap map[string]interface{}
 						if err := json.Unmarshal([]byte(tc.Arguments), &argsMap); err == nil {
-							if err := e.validateFileWrite(argsMap); err != nil {
+							if err := e.validateFileWrite(tc.Name, argsMap); err != nil {
 								messages = append(messages, llm.ChatMessage{
 									Role:       "tool",
 									Content:    fmt.Sprintf("Error: %s. Only modify files mentioned in the plan.", err.Error()),
@@ -324,10 +387,13 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 						}
 					}
 
-					result := tools.ExecuteToolWithObserver(llmCall, e.cwd, e.observer)
+					result := e.executeTool(llmCall)
 					if len(result.ModifiedFiles) > 0 {
 						modifiedFiles = append(modifiedFiles, result.ModifiedFiles...)
 					}
+					if err := diffBudgetExceeded(); err != nil {
+						return "", modifiedFiles, err
+					}
 
 					content := result.Result
 					if result.Error != "" {
@@ -345,27 +411,13 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 								if os.Getenv("GPTCODE_DEBUG") == "1" {
 									fmt.Fprintf(os.Stderr, "[EDITOR] Early return for query task, result length=%d\n", len(result.Result))
 								}
-								return result.Result, modifiedFiles, nil
+								return tools.UnwrapUntrusted(result.Result), modifiedFiles, nil
 							}
 						}
 					}
 
-					// Truncate very long content to prevent API errors
-					// Max ~10k chars (~2500 tokens) per tool result
-					maxContentLength := 10000
-					if len(content) > maxContentLength {
-						lines := strings.Split(content, "\n")
-						if len(lines) > 200 {
-							// Show first 100 and last 100 lines for large files
-							firstLines := strings.Join(lines[:100], "\n")
-							lastLines := strings.Join(lines[len(lines)-100:], "\n")
-							content = fmt.Sprintf("%s\n\n... [%d lines omitted] ...\n\n%s",
-								firstLines, len(lines)-200, lastLines)
-						} else {
-							// Just truncate by character count
-							content = content[:maxContentLength] + "\n\n... [truncated]"
-						}
-					}
+					// Keep each tool result to a share of the model's context window
+					content = llm.TruncateToTokens(content, llm.ToolResultTokens(e.model), llm.TokenizerFor(e.model))
 
 					messages = append(messages, llm.ChatMessage{
 						Role:       "tool",
@@ -399,10 +451,10 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 				statusCallback(fmt.Sprintf("Editor: Executing %s...", tc.Name))
 			}
 
-			if tc.Name == "write_file" || tc.Name == "apply_patch" {
+			if tc.Name == "write_file" || tc.Name == "apply_patch" || tc.Name == "apply_diff" {
 				var argsMap map[string]interface{}
 				if err := json.Unmarshal([]byte(tc.Arguments), &argsMap); err == nil {
-					if err := e.validateFileWrite(argsMap); err != nil {
+					if err := e.validateFileWrite(tc.Name, argsMap); err != nil {
 						messages = append(messages, llm.ChatMessage{
 							Role:       "tool",
 							Content:    fmt.Sprintf("Error: %s. Only modify files mentioned in the plan.", err.Error()),
@@ -414,10 +466,13 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 				}
 			}
 
-			result := tools.ExecuteToolWithObserver(llmCall, e.cwd, e.observer)
+			result := e.executeTool(llmCall)
 			if len(result.ModifiedFiles) > 0 {
 				modifiedFiles = append(modifiedFiles, result.ModifiedFiles...)
 			}
+			if err := diffBudgetExceeded(); err != nil {
+				return "", modifiedFiles, err
+			}
 
 			content := result.Result
 			if result.Error != "" {
@@ -435,27 +490,13 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 						if os.Getenv("GPTCODE_DEBUG") == "1" {
 							fmt.Fprintf(os.Stderr, "[EDITOR] Early return for query task (path 2), result length=%d\n", len(result.Result))
 						}
-						return result.Result, modifiedFiles, nil
+						return tools.UnwrapUntrusted(result.Result), modifiedFiles, nil
 					}
 				}
 			}
 
-			// Truncate very long content to prevent API errors
-			// Max ~10k chars (~2500 tokens) per tool result
-			maxContentLength := 10000
-			if len(content) > maxContentLength {
-				lines := strings.Split(content, "\n")
-				if len(lines) > 200 {
-					// Show first 100 and last 100 lines for large files
-					firstLines := strings.Join(lines[:100], "\n")
-					lastLines := strings.Join(lines[len(lines)-100:], "\n")
-					content = fmt.Sprintf("%s\n\n... [%d lines omitted] ...\n\n%s",
-						firstLines, len(lines)-200, lastLines)
-				} else {
-					// Just truncate by character count
-					content = content[:maxContentLength] + "\n\n... [truncated]"
-				}
-			}
+			// Keep each tool result to a share of the model's context window
+			content = llm.TruncateToTokens(content, llm.ToolResultTokens(e.model), llm.TokenizerFor(e.model))
 
 			messages = append(messages, llm.ChatMessage{
 				Role:       "tool",
@@ -473,6 +514,39 @@ func (e *EditorAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 	return "Editor reached max iterations", modifiedFiles, nil
 }
 
+// executeTool runs a tool call, rejecting writes to files that changed since
+// this editor last read them and writes over the task's diff budget.
+func (e *EditorAgent) executeTool(call tools.LLMToolCall) tools.ToolResult {
+	if e.versions != nil {
+		if err := e.versions.Check(call, e.cwd); err != nil {
+			return tools.ToolResult{Tool: call.Name, Error: err.Error()}
+		}
+	}
+	budget := tools.ActiveDiffBudget()
+	if budget != nil {
+		budget.Before(call, e.cwd)
+	}
+	result := tools.ExecuteToolWithObserver(call, e.cwd, e.observer)
+	if budget != nil {
+		if err := budget.After(call, result, e.cwd); err != nil {
+			return tools.ToolResult{Tool: call.Name, Error: err.Error()}
+		}
+	}
+	if e.versions != nil {
+		e.versions.Record(call, result, e.cwd)
+	}
+	return result
+}
+
+// diffBudgetExceeded returns the error of a task that went over its diff
+// budget; the editor stops instead of continuing to sprawl.
+func diffBudgetExceeded() error {
+	if budget := tools.ActiveDiffBudget(); budget != nil {
+		return budget.Exceeded()
+	}
+	return nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -504,7 +578,7 @@ func containsEditKeywords(text string) bool {
 
 	// Check for file operations (but exclude "change" which appears in plan headings)
 	editKeywords := []string{
-		"write_file", "apply_patch", // Tool calls
+		"write_file", "apply_patch", "apply_diff", // Tool calls
 		"modify file", "create file", "update file", "patch file",
 		"add to", "append to", "insert into",
 		"delete from", "remove from",
@@ -519,24 +593,35 @@ func containsEditKeywords(text string) bool {
 	return false
 }
 
-func (e *EditorAgent) validateFileWrite(args map[string]interface{}) error {
+func (e *EditorAgent) validateFileWrite(name string, args map[string]interface{}) error {
 	if len(e.allowedFiles) == 0 {
 		return nil
 	}
 
-	path, ok := args["path"].(string)
-	if !ok {
-		return nil
+	for _, path := range tools.WritePaths(name, args) {
+		if !e.allowedFile(path) {
+			return &FileValidationError{
+				Path:    path,
+				Message: fmt.Sprintf("File '%s' is not in the allowed list. Plan mentions: %v", path, e.allowedFiles),
+			}
+		}
 	}
+	return nil
+}
 
+func (e *EditorAgent) allowedFile(path string) bool {
 	for _, allowed := range e.allowedFiles {
 		if path == allowed || strings.HasSuffix(allowed, path) || strings.Contains(allowed, path) {
-			return nil
+			return true
 		}
 	}
+	return false
+}
 
-	return &FileValidationError{
-		Path:    path,
-		Message: fmt.Sprintf("File '%s' is not in the allowed list. Plan mentions: %v", path, e.allowedFiles),
+func (e *EditorAgent) systemPrompt() string {
+	system := prompt.AgentPrompt("editor", editorPrompt) + "\n\n" + tools.UntrustedDataNotice
+	if e.corrections != "" {
+		system += "\n\n" + e.corrections
 	}
+	return system
 }
@@ -2,10 +2,12 @@ package agents
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gptcode/internal/llm"
 )
@@ -485,3 +487,32 @@ func TestEditor_ShowVsGrep_BothReturnImmediately(t *testing.T) {
 		}
 	})
 }
+
+// hungProvider never answers until its request is cancelled
+type hungProvider struct{}
+
+func (hungProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// Test: a hung provider is cancelled by the editor's timeout, and a
+// cancelled task reports its own cause
+func TestEditor_Timeout(t *testing.T) {
+	editor := NewEditor(hungProvider{}, t.TempDir(), "test-model")
+	editor.SetTimeout(20 * time.Millisecond)
+	history := []llm.ChatMessage{{Role: "user", Content: "Fix it"}}
+
+	_, _, err := editor.Execute(context.Background(), history, nil)
+	if !errors.Is(err, ErrAgentTimeout) || !strings.Contains(err.Error(), "editor took longer than 20ms") {
+		t.Errorf("expected an editor timeout, got %v", err)
+	}
+
+	interrupted := errors.New("interrupted")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(10*time.Millisecond, func() { cancel(interrupted) })
+	editor.SetTimeout(time.Minute)
+	if _, _, err := editor.Execute(ctx, history, nil); !errors.Is(err, interrupted) {
+		t.Errorf("expected the task's cancellation, got %v", err)
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"fmt"
 
 	"gptcode/internal/llm"
+	"gptcode/internal/prompt"
 )
 
 type PlannerAgent struct {
 	provider llm.Provider
 	model    string
+	budget   *Budget
+	// corrections is the prompt section with past corrections to similar tasks
+	corrections string
 }
 
 func NewPlanner(provider llm.Provider, model string) *PlannerAgent {
@@ -148,6 +152,18 @@ Use run_command to display explanation about slot entries:
 
 Create minimal, direct plans.`
 
+// SetBudget shows the planner the task's remaining budget and model prices
+// so it can choose a strategy that fits them
+func (p *PlannerAgent) SetBudget(b Budget) {
+	p.budget = &b
+}
+
+// SetCorrections shows the planner past feedback corrections on tasks
+// similar to the one it plans, as rendered by feedback.FormatCorrections
+func (p *PlannerAgent) SetCorrections(section string) {
+	p.corrections = section
+}
+
 func (p *PlannerAgent) CreatePlan(ctx context.Context, task string, analysis string, statusCallback StatusCallback) (string, error) {
 	if statusCallback != nil {
 		statusCallback("Planner: Creating minimal plan...")
@@ -161,7 +177,7 @@ Codebase Analysis:
 ---
 %s
 ---
-
+%s
 Create a brief plan:
 # Plan
 
@@ -212,10 +228,10 @@ REMEMBER:
 - NO automation unless explicitly requested
 - NO files for explanations - use command output instead
 - Solve the task DIRECTLY in the simplest way
-- Keep it MINIMAL. NO extra features.`, task, analysis)
+- Keep it MINIMAL. NO extra features.`, task, analysis, p.budgetSection()+p.correctionsSection())
 
 	resp, err := p.provider.Chat(ctx, llm.ChatRequest{
-		SystemPrompt: plannerPrompt,
+		SystemPrompt: prompt.AgentPrompt("planner", plannerPrompt),
 		UserPrompt:   planPrompt,
 		Model:        p.model,
 	})
@@ -225,3 +241,27 @@ REMEMBER:
 
 	return resp.Text, nil
 }
+
+// budgetSection is the prompt section with the budget, asking the plan to
+// state how it fits it, or an empty line without a budget
+func (p *PlannerAgent) budgetSection() string {
+	if p.budget == nil {
+		return "\n"
+	}
+	return fmt.Sprintf(`
+Budget:
+---
+%s
+---
+End the plan with a "## Budget Strategy" section: one line on how the plan fits the budget (e.g. "skip full-repo analysis, target these two files").
+
+`, p.budget.String())
+}
+
+// correctionsSection is the prompt section with past corrections, or empty
+func (p *PlannerAgent) correctionsSection() string {
+	if p.corrections == "" {
+		return ""
+	}
+	return p.corrections + "\n"
+}
@@ -13,6 +13,7 @@ type QueryAgent struct {
 	provider llm.Provider
 	cwd      string
 	model    string
+	stream   StreamCallback
 }
 
 func NewQuery(provider llm.Provider, cwd string, model string) *QueryAgent {
@@ -23,6 +24,11 @@ func NewQuery(provider llm.Provider, cwd string, model string) *QueryAgent {
 	}
 }
 
+// SetStream streams the agent's answers to fn as they are generated.
+func (q *QueryAgent) SetStream(fn StreamCallback) {
+	q.stream = fn
+}
+
 const queryPrompt = `You are a code reader and explainer. Your job is to READ and UNDERSTAND code.
 
 You can:
@@ -34,6 +40,9 @@ You can:
 You CANNOT modify files. Be concise and direct in your explanations.`
 
 func (q *QueryAgent) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
+	// Questions asked again about unchanged code are answered from the cache
+	ctx = llm.WithCache(ctx)
+
 	toolDefs := []interface{}{
 		map[string]interface{}{
 			"type": "function",
@@ -124,12 +133,12 @@ func (q *QueryAgent) Execute(ctx context.Context, history []llm.ChatMessage, sta
 			fmt.Fprintf(os.Stderr, "[QUERY] Iteration %d/%d\n", i+1, maxIterations)
 		}
 
-		resp, err := q.provider.Chat(ctx, llm.ChatRequest{
-			SystemPrompt: queryPrompt,
+		resp, err := llm.StreamChat(ctx, q.provider, llm.ChatRequest{
+			SystemPrompt: queryPrompt + "\n\n" + tools.UntrustedDataNotice,
 			Messages:     messages,
 			Tools:        toolDefs,
 			Model:        q.model,
-		})
+		}, q.stream)
 		if err != nil {
 			return "", err
 		}
@@ -185,11 +194,11 @@ func (q *QueryAgent) Execute(ctx context.Context, history []llm.ChatMessage, sta
 		}
 	}
 
-	finalResp, err := q.provider.Chat(ctx, llm.ChatRequest{
+	finalResp, err := llm.StreamChat(ctx, q.provider, llm.ChatRequest{
 		SystemPrompt: "Based on the tool execution results above, provide a clear and concise answer to the user's question. Answer directly without suggesting additional actions.",
 		Messages:     finalMessages,
 		Model:        q.model,
-	})
+	}, q.stream)
 	if err != nil {
 		return "", err
 	}
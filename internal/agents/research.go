@@ -8,6 +8,7 @@ import (
 
 type ResearchAgent struct {
 	orchestrator *llm.OrchestratorProvider
+	stream       StreamCallback
 }
 
 func NewResearch(orchestrator *llm.OrchestratorProvider) *ResearchAgent {
@@ -16,6 +17,11 @@ func NewResearch(orchestrator *llm.OrchestratorProvider) *ResearchAgent {
 	}
 }
 
+// SetStream streams the agent's answers to fn as they are generated.
+func (r *ResearchAgent) SetStream(fn StreamCallback) {
+	r.stream = fn
+}
+
 const researchPrompt = `You are a research assistant with access to external information sources.
 
 You can use web_search to find current information, documentation, and answers to questions.
@@ -23,13 +29,16 @@ You can use web_search to find current information, documentation, and answers t
 Be concise and cite sources when possible.`
 
 func (r *ResearchAgent) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
+	// Research questions asked again are answered from the response cache
+	ctx = llm.WithCache(ctx)
+
 	if statusCallback != nil {
 		statusCallback("Research: Searching/Summarizing...")
 	}
-	resp, err := r.orchestrator.Chat(ctx, llm.ChatRequest{
+	resp, err := llm.StreamChat(ctx, r.orchestrator, llm.ChatRequest{
 		SystemPrompt: researchPrompt,
 		Messages:     history,
-	})
+	}, r.stream)
 	if err != nil {
 		return "", err
 	}
@@ -13,6 +13,8 @@ type ReviewAgent struct {
 	provider llm.Provider
 	cwd      string
 	model    string
+	stream   StreamCallback
+	format   string
 }
 
 func NewReview(provider llm.Provider, cwd string, model string) *ReviewAgent {
@@ -23,6 +25,25 @@ func NewReview(provider llm.Provider, cwd string, model string) *ReviewAgent {
 	}
 }
 
+// SetStream streams the review to fn as it is generated.
+func (r *ReviewAgent) SetStream(fn StreamCallback) {
+	r.stream = fn
+}
+
+// SetOutputFormat replaces the review's default sectioned output with
+// format, for callers that parse the review.
+func (r *ReviewAgent) SetOutputFormat(format string) {
+	r.format = format
+}
+
+// reviewOutputFormat is the review's default, human-readable layout
+const reviewOutputFormat = `Output Format:
+Provide a structured review with:
+1. **Summary**: High-level assessment.
+2. **Critical Issues**: Must-fix bugs or security risks.
+3. **Suggestions**: Improvements for quality/performance.
+4. **Nitpicks**: Style/naming preferences.`
+
 func getCodeStandards() string {
 	return `
 ## Code Standards Summary
@@ -52,7 +73,11 @@ func getCodeStandards() string {
 `
 }
 
-func buildReviewPrompt() string {
+func buildReviewPrompt(format string) string {
+	if format == "" {
+		format = reviewOutputFormat
+	}
+
 	prompt := `You are a senior code reviewer. Analyze code and provide constructive critique.
 
 Focus on:
@@ -67,12 +92,7 @@ You can:
 - Read specific files to analyze details
 - Use project_map to get a high-level view
 
-Output Format:
-Provide a structured review with:
-1. **Summary**: High-level assessment.
-2. **Critical Issues**: Must-fix bugs or security risks.
-3. **Suggestions**: Improvements for quality/performance.
-4. **Nitpicks**: Style/naming preferences.
+` + format + `
 
 Be concise but thorough. If the code is good, say so.`
 
@@ -82,7 +102,10 @@ Be concise but thorough. If the code is good, say so.`
 }
 
 func (r *ReviewAgent) Execute(ctx context.Context, history []llm.ChatMessage, statusCallback StatusCallback) (string, error) {
-	reviewPrompt := buildReviewPrompt()
+	// Reviews of unchanged code are answered from the response cache
+	ctx = llm.WithCache(ctx)
+
+	reviewPrompt := buildReviewPrompt(r.format)
 
 	toolDefs := []interface{}{
 		map[string]interface{}{
@@ -156,12 +179,12 @@ func (r *ReviewAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 			fmt.Fprintf(os.Stderr, "[REVIEW] Iteration %d/%d\n", i+1, maxIterations)
 		}
 
-		resp, err := r.provider.Chat(ctx, llm.ChatRequest{
-			SystemPrompt: reviewPrompt,
+		resp, err := llm.StreamChat(ctx, r.provider, llm.ChatRequest{
+			SystemPrompt: reviewPrompt + "\n\n" + tools.UntrustedDataNotice,
 			Messages:     messages,
 			Tools:        toolDefs,
 			Model:        r.model,
-		})
+		}, r.stream)
 		if err != nil {
 			return "", err
 		}
@@ -206,11 +229,11 @@ func (r *ReviewAgent) Execute(ctx context.Context, history []llm.ChatMessage, st
 	if statusCallback != nil {
 		statusCallback("Review: Finalizing...")
 	}
-	finalResp, err := r.provider.Chat(ctx, llm.ChatRequest{
+	finalResp, err := llm.StreamChat(ctx, r.provider, llm.ChatRequest{
 		SystemPrompt: reviewPrompt + "\n\nProvide your final review based on all the information gathered. Summarize findings.",
 		Messages:     messages,
 		Model:        r.model,
-	})
+	}, r.stream)
 	if err != nil {
 		return "Review completed but failed to generate final summary", nil
 	}
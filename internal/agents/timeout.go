@@ -0,0 +1,33 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrAgentTimeout is the cause of an agent run cancelled for taking longer
+// than its timeout. Callers retry such runs; a cancelled parent context
+// stops the task instead.
+var ErrAgentTimeout = errors.New("agent timed out")
+
+// TimeoutFunc returns how long one run of an agent may take; 0 means no limit
+type TimeoutFunc func(agent string) time.Duration
+
+// WithTimeout bounds one run of agent, when timeout is set
+func WithTimeout(ctx context.Context, agent string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%s took longer than %s: %w", agent, timeout, ErrAgentTimeout))
+}
+
+// stopped returns why ctx ended an agent run: its own timeout, the task's
+// deadline or a cancellation such as Ctrl+C. It is nil while ctx is live.
+func stopped(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(ctx)
+}
@@ -2,6 +2,9 @@ package agents
 
 type StatusCallback func(status string)
 
+// StreamCallback receives response text as the model generates it
+type StreamCallback func(chunk string)
+
 type FileValidationError struct {
 	Path    string
 	Message string
@@ -0,0 +1,303 @@
+// Package api serves gptcode's agents over a local HTTP API, so editors
+// and scripts can talk to one long-lived process instead of running the
+// CLI for each request.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/observability"
+)
+
+// maxRequest bounds a request body; chat histories are the largest
+const maxRequest = 5 << 20
+
+// Handlers do the work behind the endpoints.
+type Handlers struct {
+	// Chat answers the last user message of messages, passing the
+	// response to onChunk as it is generated
+	Chat func(ctx context.Context, messages []llm.ChatMessage, onChunk func(string)) (string, error)
+	// Do runs an autonomous task, reporting the agents' activity to
+	// observer
+	Do func(ctx context.Context, task string, observer *observability.AgentObserver) error
+}
+
+// Server is the HTTP API:
+//
+//	POST   /chat                 answer a message, as JSON or as SSE with "stream": true
+//	POST   /do                   start an autonomous task
+//	GET    /sessions             list the sessions
+//	GET    /sessions/{id}        a session's status and result
+//	GET    /sessions/{id}/events a session's events as SSE
+//	DELETE /sessions/{id}        cancel a session
+//
+// Requests must send JSON, and browser requests (with an Origin header)
+// are refused, so web pages cannot drive the agents. With a token, every
+// request must bear it; without one, only requests to a localhost or
+// loopback Host are served.
+type Server struct {
+	handlers Handlers
+	token    string
+	sessions *sessions
+	// tasks runs one do session at a time, since they change the same tree
+	tasks sync.Mutex
+	mux   *http.ServeMux
+}
+
+// NewServer returns a server running requests with handlers; an empty
+// token lets any local client in.
+func NewServer(handlers Handlers, token string) *Server {
+	s := &Server{
+		handlers: handlers,
+		token:    token,
+		sessions: &sessions{byID: map[string]*Session{}, keep: 200},
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("POST /chat", s.handleChat)
+	s.mux.HandleFunc("POST /do", s.handleDo)
+	s.mux.HandleFunc("GET /sessions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.sessions.list())
+	})
+	s.mux.HandleFunc("GET /sessions/{id}", s.handleSession)
+	s.mux.HandleFunc("DELETE /sessions/{id}", s.handleCancel)
+	s.mux.HandleFunc("GET /sessions/{id}/events", s.handleEvents)
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Origin") != "" {
+		http.Error(w, "browser requests are not allowed", http.StatusForbidden)
+		return
+	}
+	// Without a token, a page on another site could otherwise reach the
+	// API through a DNS name rebound to 127.0.0.1
+	if s.token == "" && !isLocalHost(r.Host) {
+		http.Error(w, "forbidden host", http.StatusForbidden)
+		return
+	}
+	if s.token != "" {
+		auth := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+s.token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func isLocalHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Session returns the session id, or nil
+func (s *Server) Session(id string) *Session {
+	return s.sessions.get(id)
+}
+
+type chatRequest struct {
+	// Message is a single user message; Messages a whole conversation
+	// ending with one
+	Message  string            `json:"message"`
+	Messages []llm.ChatMessage `json:"messages"`
+	Stream   bool              `json:"stream"`
+}
+
+type doRequest struct {
+	Task string `json:"task"`
+}
+
+// decode reads a JSON request body into v
+func decode(r *http.Request, v any) error {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return errors.New("the request body must be JSON (Content-Type: application/json)")
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequest))
+	if err != nil {
+		return fmt.Errorf("failed to read the request: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := decode(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	messages := req.Messages
+	if req.Message != "" {
+		messages = append(messages, llm.ChatMessage{Role: "user", Content: req.Message})
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		http.Error(w, "message, or messages ending with a user message, is required", http.StatusBadRequest)
+		return
+	}
+
+	session := s.sessions.add(KindChat, messages[len(messages)-1].Content)
+	// The chat lives as long as the request: a client that goes away
+	// cancels it
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	session.mu.Lock()
+	session.cancel = cancel
+	session.mu.Unlock()
+
+	run := func() {
+		session.setStatus(StatusRunning)
+		result, err := s.handlers.Chat(ctx, messages, func(chunk string) {
+			session.emit(EventChunk, map[string]string{"text": chunk})
+		})
+		session.finish(result, err, ctx.Err() != nil)
+	}
+	if !req.Stream {
+		run()
+		info := session.Info()
+		status := http.StatusOK
+		if info.Status != StatusSucceeded {
+			status = http.StatusInternalServerError
+		}
+		writeJSON(w, status, info)
+		return
+	}
+	go run()
+	s.streamEvents(w, r, session, 0)
+}
+
+func (s *Server) handleDo(w http.ResponseWriter, r *http.Request) {
+	var req doRequest
+	if err := decode(r, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Task) == "" {
+		http.Error(w, "task is required", http.StatusBadRequest)
+		return
+	}
+
+	session := s.sessions.add(KindDo, req.Task)
+	ctx, cancel := context.WithCancel(context.Background())
+	session.mu.Lock()
+	session.cancel = cancel
+	session.mu.Unlock()
+	go func() {
+		defer cancel()
+		s.tasks.Lock()
+		defer s.tasks.Unlock()
+		if ctx.Err() != nil {
+			session.finish("", nil, true)
+			return
+		}
+		session.setStatus(StatusRunning)
+		observer := observability.NewObserver()
+		stop := session.observe(observer)
+		err := s.handlers.Do(ctx, req.Task, observer)
+		stop()
+		var result string
+		if summary, err := json.Marshal(observer.Summary()); err == nil {
+			result = string(summary)
+		}
+		session.finish(result, err, ctx.Err() != nil)
+	}()
+
+	info := session.Info()
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"session": info,
+		"events":  "/sessions/" + info.ID + "/events",
+	})
+}
+
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	session := s.sessions.get(r.PathValue("id"))
+	if session == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, session.Info())
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	session := s.sessions.get(r.PathValue("id"))
+	if session == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	session.Cancel()
+	writeJSON(w, http.StatusAccepted, session.Info())
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	session := s.sessions.get(r.PathValue("id"))
+	if session == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	after, _ := strconv.Atoi(r.Header.Get("Last-Event-ID"))
+	s.streamEvents(w, r, session, after)
+}
+
+// streamEvents writes the session's events after seq as server-sent
+// events, following it until it finishes or the client goes away
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, session *Session, after int) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		events, changed, finished := session.Events(after)
+		for _, e := range events {
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data)
+			after = e.Seq
+		}
+		flusher.Flush()
+		if finished {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
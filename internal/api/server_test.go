@@ -0,0 +1,239 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/observability"
+)
+
+func testServer(t *testing.T, token string) (*httptest.Server, chan struct{}) {
+	t.Helper()
+	release := make(chan struct{})
+	handlers := Handlers{
+		Chat: func(ctx context.Context, messages []llm.ChatMessage, onChunk func(string)) (string, error) {
+			onChunk("hello ")
+			onChunk("there")
+			return "hello there", nil
+		},
+		Do: func(ctx context.Context, task string, observer *observability.AgentObserver) error {
+			observer.Emit(&observability.ToolCallEvent{Name: "read_file"})
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	}
+	srv := httptest.NewServer(NewServer(handlers, token))
+	t.Cleanup(srv.Close)
+	return srv, release
+}
+
+func post(t *testing.T, url, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestChat(t *testing.T) {
+	srv, _ := testServer(t, "")
+
+	resp := post(t, srv.URL+"/chat", `{"message": "hi"}`, nil)
+	var info SessionInfo
+	_ = json.NewDecoder(resp.Body).Decode(&info)
+	if resp.StatusCode != http.StatusOK || info.Status != StatusSucceeded || info.Result != "hello there" {
+		t.Fatalf("unexpected chat response %d %+v", resp.StatusCode, info)
+	}
+
+	resp = post(t, srv.URL+"/chat", `{"message": "hi", "stream": true}`, nil)
+	var chunks []string
+	for _, e := range readEvents(t, resp) {
+		if e.Type == EventChunk {
+			chunks = append(chunks, e.Data.(map[string]any)["text"].(string))
+		}
+	}
+	if strings.Join(chunks, "") != "hello there" {
+		t.Errorf("expected the streamed chunks, got %q", chunks)
+	}
+
+	if resp := post(t, srv.URL+"/chat", `{"messages": [{"role": "assistant", "content": "hi"}]}`, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("a conversation not ending with the user should be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestDoEvents(t *testing.T) {
+	srv, release := testServer(t, "")
+
+	resp := post(t, srv.URL+"/do", `{"task": "add a flag"}`, nil)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", resp.StatusCode)
+	}
+	var accepted struct {
+		Session SessionInfo
+		Events  string
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&accepted)
+	if accepted.Events != "/sessions/do-1/events" {
+		t.Fatalf("unexpected response %+v", accepted)
+	}
+
+	close(release)
+	events, err := http.Get(srv.URL + accepted.Events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Body.Close()
+	var types []string
+	for _, e := range readEvents(t, events) {
+		types = append(types, e.Type)
+	}
+	if got := strings.Join(types, ","); got != "status,tool_call,done" {
+		t.Errorf("unexpected events %s", got)
+	}
+
+	// Resuming after the first event skips it
+	req, _ := http.NewRequest("GET", srv.URL+accepted.Events, nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resumed, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Body.Close()
+	if rest := readEvents(t, resumed); len(rest) != 2 || rest[0].Seq != 2 {
+		t.Errorf("expected the events after 1, got %+v", rest)
+	}
+
+	got, err := http.Get(srv.URL + "/sessions/do-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Body.Close()
+	var info SessionInfo
+	_ = json.NewDecoder(got.Body).Decode(&info)
+	if info.Status != StatusSucceeded || !strings.Contains(info.Result, "read_file") {
+		t.Errorf("unexpected session %+v", info)
+	}
+}
+
+func TestDoCancel(t *testing.T) {
+	srv, _ := testServer(t, "")
+	post(t, srv.URL+"/do", `{"task": "add a flag"}`, nil)
+
+	req, _ := http.NewRequest("DELETE", srv.URL+"/sessions/do-1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := http.Get(srv.URL + "/sessions/do-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var info SessionInfo
+		_ = json.NewDecoder(got.Body).Decode(&info)
+		got.Body.Close()
+		if info.Status == StatusCancelled {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the session to be cancelled, got %+v", info)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServerRefuses(t *testing.T) {
+	srv, _ := testServer(t, "s3cret")
+	auth := map[string]string{"Authorization": "Bearer s3cret"}
+
+	if resp := post(t, srv.URL+"/chat", `{"message": "hi"}`, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("a request without the token should be rejected, got %d", resp.StatusCode)
+	}
+	if resp := post(t, srv.URL+"/chat", `{"message": "hi"}`, map[string]string{"Authorization": "Bearer s3cret", "Origin": "https://example.com"}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("a browser request should be rejected, got %d", resp.StatusCode)
+	}
+	if resp := post(t, srv.URL+"/do", `task=x`, map[string]string{"Authorization": "Bearer s3cret", "Content-Type": "application/x-www-form-urlencoded"}); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("a form should be rejected, got %d", resp.StatusCode)
+	}
+	if resp := post(t, srv.URL+"/chat", `{"message": "hi"}`, auth); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the chat with the token, got %d", resp.StatusCode)
+	}
+}
+
+func TestServerRefusesForeignHost(t *testing.T) {
+	srv, _ := testServer(t, "")
+	for host, want := range map[string]int{
+		"evil.example.com:8711": http.StatusForbidden,
+		"10.0.0.5":              http.StatusForbidden,
+		"localhost:8711":        http.StatusOK,
+		"127.0.0.1:8711":        http.StatusOK,
+		"[::1]:8711":            http.StatusOK,
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+"/healthz", nil)
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Host %s: got %d, want %d", host, resp.StatusCode, want)
+		}
+	}
+
+	// A token is enough for any host, so the API can listen beyond localhost
+	srv, _ = testServer(t, "s3cret")
+	req, _ := http.NewRequest("GET", srv.URL+"/healthz", nil)
+	req.Host = "gptcode.internal:8711"
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("a request with the token should be served on any host, got %d", resp.StatusCode)
+	}
+}
+
+// readEvents reads server-sent events until the stream ends
+func readEvents(t *testing.T, resp *http.Response) []Event {
+	t.Helper()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", ct)
+	}
+	var events []Event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", data, err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
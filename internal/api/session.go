@@ -0,0 +1,227 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"gptcode/internal/observability"
+)
+
+// Session kinds
+const (
+	KindChat = "chat"
+	KindDo   = "do"
+)
+
+// Session statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Event types besides the observability events (tool_call, file_modified,
+// llm_request, agent, movement, validation)
+const (
+	EventStatus = "status"
+	EventChunk  = "chunk"
+	EventDone   = "done"
+)
+
+// Event is one server-sent event of a session. Seq numbers the session's
+// events from 1 and is sent as the SSE id, so a client can resume with
+// Last-Event-ID.
+type Event struct {
+	Seq  int       `json:"seq"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+// SessionInfo is a chat or do request and what it produced.
+type SessionInfo struct {
+	ID       string    `json:"id"`
+	Kind     string    `json:"kind"`
+	Input    string    `json:"input"`
+	Status   string    `json:"status"`
+	Created  time.Time `json:"created"`
+	Finished time.Time `json:"finished,omitzero"`
+	Result   string    `json:"result,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Session is a running or finished request with its events
+type Session struct {
+	mu      sync.Mutex
+	info    SessionInfo
+	events  []Event
+	changed chan struct{}
+	done    chan struct{}
+	cancel  context.CancelFunc
+}
+
+func newSession(id, kind, input string) *Session {
+	return &Session{
+		info:    SessionInfo{ID: id, Kind: kind, Input: input, Status: StatusQueued, Created: time.Now()},
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// emit appends an event and wakes the clients following the session
+func (s *Session) emit(typ string, data any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emitLocked(typ, data)
+}
+
+func (s *Session) emitLocked(typ string, data any) {
+	s.events = append(s.events, Event{Seq: len(s.events) + 1, Type: typ, Time: time.Now(), Data: data})
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Session) setStatus(status string) {
+	s.mu.Lock()
+	s.info.Status = status
+	s.mu.Unlock()
+	s.emit(EventStatus, map[string]string{"status": status})
+}
+
+// finish records the outcome and ends the event stream with a done event
+func (s *Session) finish(result string, err error, cancelled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info.Finished = time.Now()
+	s.info.Result = result
+	switch {
+	case cancelled:
+		s.info.Status = StatusCancelled
+	case err != nil:
+		s.info.Status = StatusFailed
+	default:
+		s.info.Status = StatusSucceeded
+	}
+	if err != nil {
+		s.info.Error = err.Error()
+	}
+	s.emitLocked(EventDone, map[string]string{"status": s.info.Status, "result": s.info.Result, "error": s.info.Error})
+	close(s.done)
+}
+
+// Events returns the events after seq, a channel closed when more arrive,
+// and whether the session has finished.
+func (s *Session) Events(after int) ([]Event, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if after < 0 {
+		after = 0
+	}
+	var events []Event
+	if after < len(s.events) {
+		events = append(events, s.events[after:]...)
+	}
+	select {
+	case <-s.done:
+		return events, s.changed, true
+	default:
+		return events, s.changed, false
+	}
+}
+
+// Cancel stops the session's work
+func (s *Session) Cancel() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// Info returns the session's request and outcome so far
+func (s *Session) Info() SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info
+}
+
+// observe forwards the events of an agent observer to the session until
+// the returned stop is called
+func (s *Session) observe(observer observability.Observer) (stop func()) {
+	ch := make(chan observability.Event, 256)
+	observer.Subscribe(ch)
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case e := <-ch:
+				s.emit(e.EventType(), e)
+			case <-quit:
+				for {
+					select {
+					case e := <-ch:
+						s.emit(e.EventType(), e)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		observer.Unsubscribe(ch)
+		close(quit)
+		wg.Wait()
+	}
+}
+
+// sessions holds the sessions of a server, forgetting the oldest finished
+// ones past keep
+type sessions struct {
+	mu     sync.Mutex
+	byID   map[string]*Session
+	order  []string
+	nextID int
+	keep   int
+}
+
+func (ss *sessions) add(kind, input string) *Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.nextID++
+	s := newSession(fmt.Sprintf("%s-%d", kind, ss.nextID), kind, input)
+	ss.byID[s.info.ID] = s
+	ss.order = append(ss.order, s.info.ID)
+	for len(ss.order) > ss.keep {
+		if ss.byID[ss.order[0]].Info().Finished.IsZero() {
+			break
+		}
+		delete(ss.byID, ss.order[0])
+		ss.order = ss.order[1:]
+	}
+	return s
+}
+
+func (ss *sessions) get(id string) *Session {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.byID[id]
+}
+
+func (ss *sessions) list() []SessionInfo {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	list := make([]SessionInfo, 0, len(ss.order))
+	for _, id := range ss.order {
+		list = append(list, ss.byID[id].Info())
+	}
+	return list
+}
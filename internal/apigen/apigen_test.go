@@ -0,0 +1,237 @@
+package apigen
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gptcode/internal/langdetect"
+)
+
+const petstore = `openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.2.0
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      tags: [pets]
+      parameters:
+        - $ref: '#/components/parameters/Limit'
+      responses:
+        200:
+          description: The pets
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/Pet'
+    post:
+      tags: [pets]
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/NewPet'
+      responses:
+        '201':
+          description: Created
+        default:
+          $ref: '#/components/responses/Error'
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        schema: {type: string}
+    get:
+      operationId: getPet
+      responses:
+        '200':
+          description: A pet
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+  /store/Inventory:
+    get:
+      tags: [StoreAdmin]
+      responses:
+        '200':
+          description: Counts
+components:
+  parameters:
+    Limit:
+      name: limit
+      in: query
+      schema: {type: integer}
+  responses:
+    Error:
+      description: Error
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/Error'
+  schemas:
+    Pet:
+      type: object
+      properties:
+        id: {type: string}
+        owner:
+          $ref: '#/components/schemas/Owner'
+    Owner:
+      type: object
+      properties:
+        name: {type: string}
+    NewPet:
+      type: object
+      properties:
+        name: {type: string}
+    Error:
+      type: object
+      properties:
+        message: {type: string}
+`
+
+func TestParseSpec(t *testing.T) {
+	spec, err := ParseSpecData([]byte(petstore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Title != "Petstore" || spec.Version != "1.2.0" || spec.Servers[0] != "https://api.example.com/v1" {
+		t.Errorf("unexpected info %+v", spec)
+	}
+	var ids []string
+	for _, op := range spec.Operations {
+		ids = append(ids, op.ID)
+	}
+	if want := []string{"listPets", "postPets", "getPet", "getStoreInventory"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("expected operations %v, got %v", want, ids)
+	}
+
+	list, create, get, inventory := spec.Operations[0], spec.Operations[1], spec.Operations[2], spec.Operations[3]
+	if !reflect.DeepEqual(list.Parameters, []Parameter{{Name: "limit", In: "query"}}) {
+		t.Errorf("expected the referenced parameter resolved, got %+v", list.Parameters)
+	}
+	if !reflect.DeepEqual(list.Responses, []string{"200"}) || !reflect.DeepEqual(list.Schemas, []string{"Owner", "Pet"}) {
+		t.Errorf("expected unquoted status codes and nested schemas, got %v and %v", list.Responses, list.Schemas)
+	}
+	if !reflect.DeepEqual(create.Schemas, []string{"Error", "NewPet"}) {
+		t.Errorf("expected the schemas of referenced responses, got %v", create.Schemas)
+	}
+	if !reflect.DeepEqual(get.Parameters, []Parameter{{Name: "petId", In: "path", Required: true}}) || get.Group != "pets" {
+		t.Errorf("expected the path-level parameter and the path's group, got %+v in %s", get.Parameters, get.Group)
+	}
+	if inventory.Group != "store_admin" {
+		t.Errorf("expected the tag as a file name, got %s", inventory.Group)
+	}
+	if excerpt := spec.Excerpt(list); !strings.Contains(excerpt, "GET /pets:") || !strings.Contains(excerpt, "Owner:") || strings.Contains(excerpt, "NewPet") {
+		t.Errorf("unexpected excerpt:\n%s", excerpt)
+	}
+
+	for doc, want := range map[string]string{
+		"swagger: '2.0'\n":            "swagger 2.0",
+		"openapi: 3.1.0\npaths: {}\n": "no operations",
+		"openapi: 2.0\n":              "not an OpenAPI 3",
+		"openapi: 3.0.0\npaths:\n  /a:\n    get: {operationId: x}\n  /b:\n    get: {operationId: x}\n": "used twice",
+	} {
+		if _, err := ParseSpecData([]byte(doc)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected an error about %s, got %v", doc, want, err)
+		}
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	spec, err := ParseSpecData([]byte(petstore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := NewPlan(spec, KindClient, langdetect.Go, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 5 || plan.Steps[0].Name != "foundation" || plan.Steps[0].Files[0] != "apiclient/client.go" {
+		t.Fatalf("expected the foundation then one step per operation, got %+v", plan.Steps)
+	}
+	if got := plan.Steps[3].Files; !reflect.DeepEqual(got, []string{"apiclient/pets.go", "apiclient/pets_test.go", "apiclient/client.go"}) {
+		t.Errorf("unexpected files %v", got)
+	}
+	if p := plan.Steps[1].Prompt; !strings.Contains(p, "listPets") || !strings.Contains(p, "apiclient/pets_test.go") || !strings.Contains(p, "httptest") {
+		t.Errorf("unexpected prompt:\n%s", p)
+	}
+	if len(plan.Files()) != 5 {
+		t.Errorf("expected each file once, got %v", plan.Files())
+	}
+
+	py, err := NewPlan(spec, KindServer, langdetect.Python, "app/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(py.Steps[0].Files, []string{"app/api/server.py", "app/api/__init__.py"}) || py.Steps[4].Files[1] != "app/api/test_store_admin.py" {
+		t.Errorf("unexpected python layout %v", py.Files())
+	}
+	if _, err := NewPlan(spec, KindClient, langdetect.Elixir, ""); err == nil {
+		t.Error("expected unsupported languages refused")
+	}
+}
+
+func TestGeneratorFixesCompileErrors(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed")
+	}
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/shop\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := ParseSpecData([]byte(petstore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := NewPlan(spec, KindClient, langdetect.Go, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes a file per step, the foundation with a compile error that
+	// the fix round corrects
+	var steps []string
+	write := func(name, code string) error {
+		return os.WriteFile(filepath.Join(root, "apiclient", name), []byte("package apiclient\n\n"+code), 0644)
+	}
+	g := &Generator{
+		Root:      root,
+		Plan:      plan,
+		FixRounds: 2,
+		Author: func(ctx context.Context, step Step) error {
+			steps = append(steps, step.Name)
+			switch {
+			case step.Name == "foundation":
+				return write("client.go", "type Client struct{ BaseURL string }\n\nvar broken int = \"x\"\n")
+			case step.Operation != nil:
+				return write(step.Operation.Group+".go", "func (c *Client) "+strings.ToUpper(step.Name[:1])+step.Name[1:]+"() {}\n")
+			default:
+				if !strings.Contains(step.Prompt, "client.go:5") {
+					t.Errorf("expected the compile errors in the fix prompt:\n%s", step.Prompt)
+				}
+				return write("client.go", "type Client struct{ BaseURL string }\n")
+			}
+		},
+	}
+	result, err := g.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid() || result.Fixes != 1 || len(result.Written) != 5 {
+		t.Errorf("expected the code fixed in one round, got %+v", result)
+	}
+	if len(steps) != 6 || steps[5] != "fix 1" {
+		t.Errorf("unexpected steps %v", steps)
+	}
+}
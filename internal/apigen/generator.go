@@ -0,0 +1,183 @@
+package apigen
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/langdetect"
+)
+
+// Author writes the files of one step, e.g. with the editor agent
+type Author func(ctx context.Context, step Step) error
+
+// Compilation is how compiling the generated code went
+type Compilation struct {
+	// Command is what was run; empty when no compiler was found and the
+	// code was not checked
+	Command string
+	OK      bool
+	Output  string
+}
+
+// Generator runs a plan and makes the generated code compile
+type Generator struct {
+	// Root is the project the plan's paths are relative to
+	Root   string
+	Plan   *Plan
+	Author Author
+	// FixRounds is how many times the author is given the compiler's
+	// errors to fix
+	FixRounds int
+	// Compile checks the generated code (default: Compile)
+	Compile func(ctx context.Context, root string, plan *Plan) Compilation
+	// Progress, when set, is told which step is about to run
+	Progress func(i int, step Step)
+	// Outcome, when set, is told how a step went
+	Outcome func(step Step, err error)
+}
+
+// Result is how generating went
+type Result struct {
+	// Written are the steps the author completed, Failed the others
+	Written []Step
+	Failed  []Step
+	// Compilation is the last check of the code
+	Compilation Compilation
+	// Fixes is how many fix rounds ran
+	Fixes int
+}
+
+// Valid reports whether every step was written and the code compiles
+func (r *Result) Valid() bool {
+	return len(r.Failed) == 0 && r.Compilation.OK
+}
+
+// Run runs the plan's steps in order, then compiles the code and hands
+// the errors back to the author until it compiles or FixRounds is used up.
+// A failed step does not stop the next ones.
+func (g *Generator) Run(ctx context.Context) (*Result, error) {
+	if g.Author == nil {
+		return nil, fmt.Errorf("no author")
+	}
+	compile := g.Compile
+	if compile == nil {
+		compile = Compile
+	}
+	if err := os.MkdirAll(filepath.Join(g.Root, g.Plan.Dir), 0755); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for i, step := range g.Plan.Steps {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if g.Progress != nil {
+			g.Progress(i, step)
+		}
+		err := g.Author(ctx, step)
+		if err == nil {
+			result.Written = append(result.Written, step)
+		} else {
+			result.Failed = append(result.Failed, step)
+		}
+		if g.Outcome != nil {
+			g.Outcome(step, err)
+		}
+	}
+
+	result.Compilation = compile(ctx, g.Root, g.Plan)
+	for !result.Compilation.OK && result.Compilation.Command != "" && result.Fixes < g.FixRounds {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.Fixes++
+		fix := Step{
+			Name:   fmt.Sprintf("fix %d", result.Fixes),
+			Files:  g.Plan.Files(),
+			Prompt: fixPrompt(g.Plan, result.Compilation),
+		}
+		if g.Progress != nil {
+			g.Progress(len(g.Plan.Steps)+result.Fixes-1, fix)
+		}
+		err := g.Author(ctx, fix)
+		if g.Outcome != nil {
+			g.Outcome(fix, err)
+		}
+		if err != nil {
+			break
+		}
+		result.Compilation = compile(ctx, g.Root, g.Plan)
+	}
+	return result, nil
+}
+
+// maxErrorOutput is how much compiler output a fix prompt carries
+const maxErrorOutput = 8000
+
+func fixPrompt(plan *Plan, c Compilation) string {
+	out := c.Output
+	if len(out) > maxErrorOutput {
+		out = out[:maxErrorOutput] + "\n[... truncated]"
+	}
+	return fmt.Sprintf(`The generated %s %s in %s does not compile. `+"`%s`"+` reports:
+
+%s
+
+Fix these errors in %s. Keep the API and the tests' intent; do not delete
+operations or tests to make the errors go away.`,
+		plan.Language, plan.Kind, plan.Dir, c.Command, strings.TrimSpace(out), strings.Join(plan.Files(), ", "))
+}
+
+// Compile compiles the plan's code and its tests with the language's
+// tools: go vet, tsc or py_compile. Without them the code is not checked
+// and Command is empty.
+func Compile(ctx context.Context, root string, plan *Plan) Compilation {
+	var name string
+	var args []string
+	switch plan.Language {
+	case langdetect.Go:
+		name, args = "go", []string{"vet", "./" + plan.Dir + "/..."}
+	case langdetect.TypeScript:
+		name = filepath.Join(root, "node_modules", ".bin", "tsc")
+		if _, err := os.Stat(name); err != nil {
+			name = "tsc"
+		}
+		if _, err := os.Stat(filepath.Join(root, "tsconfig.json")); err == nil {
+			args = []string{"--noEmit", "-p", "."}
+		} else {
+			args = append([]string{"--noEmit", "--strict", "--skipLibCheck", "--esModuleInterop", "--target", "es2020", "--moduleResolution", "node"}, existing(root, plan.Files())...)
+		}
+	case langdetect.Python:
+		name, args = "python3", append([]string{"-m", "py_compile"}, existing(root, plan.Files())...)
+	default:
+		return Compilation{}
+	}
+	if _, err := exec.LookPath(name); err != nil {
+		return Compilation{}
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = root
+	out, err := cmd.CombinedOutput()
+	return Compilation{
+		Command: filepath.Base(name) + " " + strings.Join(args, " "),
+		OK:      err == nil,
+		Output:  string(out),
+	}
+}
+
+// existing keeps the files that were written
+func existing(root string, files []string) []string {
+	var kept []string
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(root, f)); err == nil {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
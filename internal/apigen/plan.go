@@ -0,0 +1,200 @@
+package apigen
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/langdetect"
+)
+
+// What to generate from a spec
+const (
+	KindClient = "client"
+	KindServer = "server"
+)
+
+// Step is one editor run of a plan
+type Step struct {
+	// Name is "foundation" for the shared code, or the operation's ID
+	Name string
+	// Operation is the endpoint the step writes, nil for the foundation
+	Operation *Operation
+	// Files are the files the step may write, relative to the project
+	Files []string
+	// Prompt is the editor's task
+	Prompt string
+}
+
+// Plan is the steps generating a client or a server, the shared code
+// first and then one step per endpoint
+type Plan struct {
+	Kind     string
+	Language langdetect.Language
+	// Dir is where the code goes, relative to the project
+	Dir   string
+	Steps []Step
+}
+
+// layout is where a language's code goes in Dir
+type layout struct {
+	core  func(kind string) string
+	group func(group string) string
+	test  func(group string) string
+	extra []string
+}
+
+var layouts = map[langdetect.Language]layout{
+	langdetect.Go: {
+		core:  func(kind string) string { return kind + ".go" },
+		group: func(g string) string { return g + ".go" },
+		test:  func(g string) string { return g + "_test.go" },
+	},
+	langdetect.TypeScript: {
+		core:  func(kind string) string { return kind + ".ts" },
+		group: func(g string) string { return g + ".ts" },
+		test:  func(g string) string { return g + ".test.ts" },
+	},
+	langdetect.Python: {
+		core:  func(kind string) string { return kind + ".py" },
+		group: func(g string) string { return g + ".py" },
+		test:  func(g string) string { return "test_" + g + ".py" },
+		extra: []string{"__init__.py"},
+	},
+}
+
+// idioms says how each language's code is written
+var idioms = map[langdetect.Language]map[string]string{
+	langdetect.Go: {
+		KindClient: "net/http and encoding/json only. A Client struct with a BaseURL and an *http.Client, one method per operation taking a context.Context first. Tests serve canned responses with net/http/httptest.",
+		KindServer: "net/http only: a Service interface with one method per operation taking a context.Context first, and a NewHandler(Service) http.Handler routing with ServeMux method patterns (GET /users/{id}). Tests call the handler through net/http/httptest with a fake Service.",
+	},
+	langdetect.TypeScript: {
+		KindClient: "fetch, with an injectable fetch implementation. Typed interfaces for the schemas, one async function or method per operation. Tests use the project's test runner (vitest or jest) with a stubbed fetch.",
+		KindServer: "The project's HTTP framework if it has one (check package.json), else Express. A Service interface with one async method per operation and a function building the router from a Service. Tests use supertest with a fake Service.",
+	},
+	langdetect.Python: {
+		KindClient: "httpx if the project uses it, else requests. Dataclasses or TypedDicts for the schemas and type hints throughout, one method per operation on an ApiClient class. pytest tests with the HTTP layer mocked.",
+		KindServer: "The project's framework if it has one (check its requirements), else FastAPI. Pydantic models for the schemas, a Service protocol with one method per operation and a function building the router from a Service. pytest tests with the framework's test client and a fake Service.",
+	},
+}
+
+// DefaultDir is where a kind's code goes when no directory is given
+func DefaultDir(lang langdetect.Language, kind string) string {
+	if lang == langdetect.TypeScript {
+		return path.Join("src", "api"+kind)
+	}
+	return "api" + kind
+}
+
+// NewPlan plans generating kind code for spec, in lang, under dir
+func NewPlan(spec *Spec, kind string, lang langdetect.Language, dir string) (*Plan, error) {
+	if kind != KindClient && kind != KindServer {
+		return nil, fmt.Errorf("unknown kind %q (client or server)", kind)
+	}
+	lay, ok := layouts[lang]
+	if !ok {
+		return nil, fmt.Errorf("%s is not supported; API code is generated in go, typescript or python", lang)
+	}
+	if dir == "" {
+		dir = DefaultDir(lang, kind)
+	}
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	in := func(name string) string { return path.Join(dir, name) }
+
+	plan := &Plan{Kind: kind, Language: lang, Dir: dir}
+	core := in(lay.core(kind))
+	foundation := Step{Name: "foundation", Files: []string{core}}
+	for _, f := range lay.extra {
+		foundation.Files = append(foundation.Files, in(f))
+	}
+	foundation.Prompt = foundationPrompt(spec, plan, foundation.Files)
+	plan.Steps = append(plan.Steps, foundation)
+
+	for i := range spec.Operations {
+		op := &spec.Operations[i]
+		group := op.Group
+		if in(lay.group(group)) == core {
+			group += "_api"
+		}
+		step := Step{
+			Name:      op.ID,
+			Operation: op,
+			Files:     []string{in(lay.group(group)), in(lay.test(group)), core},
+		}
+		step.Prompt = operationPrompt(spec, plan, step)
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan, nil
+}
+
+// Files returns every file the plan writes
+func (p *Plan) Files() []string {
+	var files []string
+	seen := map[string]bool{}
+	for _, s := range p.Steps {
+		for _, f := range s.Files {
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
+func foundationPrompt(spec *Spec, plan *Plan, files []string) string {
+	core := files[0]
+	var ops strings.Builder
+	for _, op := range spec.Operations {
+		fmt.Fprintf(&ops, "- %s\n", op)
+	}
+	server := "(none given)"
+	if len(spec.Servers) > 0 {
+		server = spec.Servers[0]
+	}
+	var what string
+	if plan.Kind == KindClient {
+		what = "the client's shared code: a type for every schema, the client with its base URL (default " + server + ") and HTTP client, a helper sending a request and decoding the JSON response, and an error type carrying the status code and body of non-2xx responses. Do not write the operations' methods"
+	} else {
+		what = "the server's shared code: a type for every schema, the service interface with one method per operation listed below, the router function later steps register each operation's handler in, helpers to decode requests and write JSON responses and errors. The handlers themselves are written in later steps, one per operation, in per-tag files next to it"
+	}
+	return fmt.Sprintf(`Generate a typed %s %s for the %s API (version %s) from its OpenAPI spec.
+
+This step: write %s in %s.
+Later steps add each operation, one at a time, in per-tag files of %s.
+
+Idioms: %s
+
+Operations:
+%s
+Schemas:
+%s
+Only write %s. Clean, idiomatic code that compiles as is.`,
+		plan.Language, plan.Kind, spec.Title, spec.Version, what, core, plan.Dir,
+		idioms[plan.Language][plan.Kind], ops.String(), spec.SchemasYAML(), strings.Join(files, " and "))
+}
+
+func operationPrompt(spec *Spec, plan *Plan, step Step) string {
+	op := step.Operation
+	code, test, core := step.Files[0], step.Files[1], step.Files[2]
+	var what string
+	if plan.Kind == KindClient {
+		what = fmt.Sprintf("the client method for %s in %s: typed parameters for its path, query and header parameters and request body, a typed result for its success response, and an error for the others", op.ID, code)
+	} else {
+		what = fmt.Sprintf("the HTTP handler for %s in %s: decode and validate its parameters and body (400 on invalid input), call the service's %s method, and write the documented status codes and response bodies; register it in the router in %s if it is not yet", op.ID, code, op.ID, core)
+	}
+	return fmt.Sprintf(`Continue the typed %s %s for the %s API in %s. The shared code is in %s; read it first and reuse its types and helpers.
+
+This step: write %s.
+Add tests for it to %s: the success response and at least one error response, with the request the endpoint expects.
+Keep what other steps wrote in these files. Only change %s and, for shared types or helpers that are missing, %s.
+
+Idioms: %s
+
+Endpoint:
+%s`,
+		plan.Language, plan.Kind, spec.Title, plan.Dir, core, what, test, strings.Join(step.Files[:2], " and "), core,
+		idioms[plan.Language][plan.Kind], spec.Excerpt(*op))
+}
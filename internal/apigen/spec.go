@@ -0,0 +1,361 @@
+package apigen
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Spec is what code generation needs of an OpenAPI 3 document
+type Spec struct {
+	Title   string
+	Version string
+	// Servers are the base URLs, the first being the default
+	Servers    []string
+	Operations []Operation
+	// Schemas are components.schemas, by name
+	Schemas map[string]interface{}
+}
+
+// Operation is one method on one path
+type Operation struct {
+	// ID is the operationId, or one made from the method and path
+	ID      string
+	Method  string
+	Path    string
+	Summary string
+	// Group is the first tag, or the first segment of the path; each
+	// group's code goes in its own file
+	Group      string
+	Parameters []Parameter
+	// Responses are the documented status codes, sorted
+	Responses []string
+	// Schemas are the component schemas the operation uses, directly or
+	// not, sorted
+	Schemas []string
+	// Raw is the operation object, with path-level parameters and
+	// references to other components than schemas resolved
+	Raw map[string]interface{}
+}
+
+// Parameter is a path, query, header or cookie parameter
+type Parameter struct {
+	Name     string
+	In       string
+	Required bool
+}
+
+func (o Operation) String() string {
+	return fmt.Sprintf("%s %s (%s)", o.Method, o.Path, o.ID)
+}
+
+var methodOrder = []string{"get", "post", "put", "patch", "delete", "head", "options", "trace"}
+
+// ParseSpec reads an OpenAPI 3 document, in YAML or JSON
+func ParseSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSpecData(data)
+}
+
+// ParseSpecData parses an OpenAPI 3 document, in YAML or JSON
+func ParseSpecData(data []byte) (*Spec, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	doc := mapOf(normalize(raw))
+	if doc == nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: not a mapping")
+	}
+	if _, ok := doc["swagger"]; ok {
+		return nil, fmt.Errorf("swagger 2.0 documents are not supported; convert it to OpenAPI 3 first")
+	}
+	if v := fmt.Sprint(doc["openapi"]); !strings.HasPrefix(v, "3.") {
+		return nil, fmt.Errorf("not an OpenAPI 3 document (openapi: %s)", v)
+	}
+
+	spec := &Spec{Schemas: map[string]interface{}{}}
+	info, _ := doc["info"].(map[string]interface{})
+	spec.Title, _ = info["title"].(string)
+	spec.Version = fmt.Sprint(info["version"])
+	for _, s := range list(doc["servers"]) {
+		if url, ok := mapOf(s)["url"].(string); ok {
+			spec.Servers = append(spec.Servers, url)
+		}
+	}
+	components := mapOf(doc["components"])
+	for name, schema := range mapOf(components["schemas"]) {
+		spec.Schemas[name] = schema
+	}
+
+	paths := mapOf(doc["paths"])
+	pathNames := make([]string, 0, len(paths))
+	for p := range paths {
+		pathNames = append(pathNames, p)
+	}
+	sort.Strings(pathNames)
+	ids := map[string]bool{}
+	for _, p := range pathNames {
+		item := mapOf(paths[p])
+		for _, method := range methodOrder {
+			raw, ok := item[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			raw = resolve(raw, components, 0).(map[string]interface{})
+			// Path-level parameters apply to every operation, unless one
+			// redefines them
+			if shared := list(resolve(item["parameters"], components, 0)); len(shared) > 0 {
+				own := map[string]bool{}
+				for _, param := range list(raw["parameters"]) {
+					m := mapOf(param)
+					own[fmt.Sprint(m["in"], ":", m["name"])] = true
+				}
+				params := list(raw["parameters"])
+				for _, param := range shared {
+					m := mapOf(param)
+					if !own[fmt.Sprint(m["in"], ":", m["name"])] {
+						params = append(params, param)
+					}
+				}
+				raw["parameters"] = params
+			}
+			op := newOperation(strings.ToUpper(method), p, raw)
+			if ids[op.ID] {
+				return nil, fmt.Errorf("operationId %s is used twice", op.ID)
+			}
+			ids[op.ID] = true
+			op.Schemas = spec.schemaClosure(raw)
+			spec.Operations = append(spec.Operations, op)
+		}
+	}
+	if len(spec.Operations) == 0 {
+		return nil, fmt.Errorf("the document has no operations")
+	}
+	return spec, nil
+}
+
+func newOperation(method, path string, raw map[string]interface{}) Operation {
+	op := Operation{Method: method, Path: path, Raw: raw}
+	op.ID, _ = raw["operationId"].(string)
+	if op.ID == "" {
+		op.ID = operationID(method, path)
+	}
+	op.Summary, _ = raw["summary"].(string)
+	if tags := list(raw["tags"]); len(tags) > 0 {
+		op.Group = identifier(fmt.Sprint(tags[0]))
+	}
+	if op.Group == "" {
+		for _, seg := range strings.Split(path, "/") {
+			if seg != "" && !strings.HasPrefix(seg, "{") {
+				op.Group = identifier(seg)
+				break
+			}
+		}
+	}
+	if op.Group == "" {
+		op.Group = "default"
+	}
+	for _, param := range list(raw["parameters"]) {
+		m := mapOf(param)
+		name, _ := m["name"].(string)
+		in, _ := m["in"].(string)
+		required, _ := m["required"].(bool)
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: in, Required: required || in == "path"})
+	}
+	for status := range mapOf(raw["responses"]) {
+		op.Responses = append(op.Responses, status)
+	}
+	sort.Strings(op.Responses)
+	return op
+}
+
+var nonWord = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// operationID makes an id such as getUsersById from GET /users/{id}
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		if seg == "" {
+			continue
+		}
+		if strings.HasPrefix(seg, "{") {
+			b.WriteString("By")
+		}
+		for _, word := range nonWord.Split(seg, -1) {
+			if word != "" {
+				b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+			}
+		}
+	}
+	return b.String()
+}
+
+// identifier turns a tag or path segment into a file name: user_accounts
+func identifier(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	id := strings.Trim(nonWord.ReplaceAllString(b.String(), "_"), "_")
+	if id != "" && unicode.IsDigit(rune(id[0])) {
+		id = "api_" + id
+	}
+	return id
+}
+
+// resolve inlines references to components other than schemas, which
+// stay references so the generated code can share their types. depth
+// counts the references followed, against cycles.
+func resolve(v interface{}, components map[string]interface{}, depth int) interface{} {
+	if depth > 10 {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok && !strings.HasPrefix(ref, "#/components/schemas/") {
+			parts := strings.Split(strings.TrimPrefix(ref, "#/components/"), "/")
+			if len(parts) == 2 {
+				if target, ok := mapOf(components[parts[0]])[parts[1]]; ok {
+					return resolve(target, components, depth+1)
+				}
+			}
+			return v
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = resolve(val, components, depth)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = resolve(val, components, depth)
+		}
+		return out
+	}
+	return v
+}
+
+// schemaClosure returns the schemas v references, and theirs
+func (s *Spec) schemaClosure(v interface{}) []string {
+	seen := map[string]bool{}
+	var walk func(interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok && strings.HasPrefix(ref, "#/components/schemas/") {
+				name := strings.TrimPrefix(ref, "#/components/schemas/")
+				if !seen[name] {
+					seen[name] = true
+					walk(s.Schemas[name])
+				}
+			}
+			for _, val := range v {
+				walk(val)
+			}
+		case []interface{}:
+			for _, val := range v {
+				walk(val)
+			}
+		}
+	}
+	walk(v)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Groups returns the operations' groups, in order of first appearance
+func (s *Spec) Groups() []string {
+	var groups []string
+	seen := map[string]bool{}
+	for _, op := range s.Operations {
+		if !seen[op.Group] {
+			seen[op.Group] = true
+			groups = append(groups, op.Group)
+		}
+	}
+	return groups
+}
+
+// Excerpt is the part of the document an operation's code is written
+// from: the operation and the schemas it uses, as YAML
+func (s *Spec) Excerpt(op Operation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s:\n%s", op.Method, op.Path, toYAML(op.Raw))
+	if len(op.Schemas) > 0 {
+		b.WriteString("\nSchemas:\n")
+		schemas := map[string]interface{}{}
+		for _, name := range op.Schemas {
+			schemas[name] = s.Schemas[name]
+		}
+		b.WriteString(toYAML(schemas))
+	}
+	return b.String()
+}
+
+// SchemasYAML renders every component schema
+func (s *Spec) SchemasYAML() string {
+	if len(s.Schemas) == 0 {
+		return ""
+	}
+	return toYAML(s.Schemas)
+}
+
+func toYAML(v interface{}) string {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
+
+// normalize turns the maps YAML decodes with non-string keys, such as
+// unquoted status codes, into string-keyed ones
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			v[k] = normalize(val)
+		}
+		return v
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[fmt.Sprint(k)] = normalize(val)
+		}
+		return out
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalize(val)
+		}
+		return v
+	}
+	return v
+}
+
+func mapOf(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func list(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
@@ -47,7 +47,7 @@ func Login() error {
 	fmt.Printf("Opening browser to log in...\n")
 	fmt.Printf("If browser doesn't open, visit:\n%s\n", loginURL)
 
-	if err := openBrowser(loginURL); err != nil {
+	if err := OpenBrowser(loginURL); err != nil {
 		fmt.Printf("Failed to open browser: %v\n", err)
 	}
 
@@ -150,7 +150,8 @@ func LoadCredentials() (*Credentials, error) {
 	return &creds, nil
 }
 
-func openBrowser(url string) error {
+// OpenBrowser opens url in the default browser.
+func OpenBrowser(url string) error {
 	var cmd string
 	var args []string
 
@@ -172,7 +172,8 @@ Rules:
 - Each movement should be independently executable
 - Define clear dependencies (Movement B depends on Movement A)
 - Each movement should have 1-3 success criteria
-- Movements should be sequential (not parallel)
+- Movements that touch different files and do not need each other's output must not depend on each other; they run in parallel
+- Never create dependency cycles
 - Be specific about files to read/create
 - AVOID creating intermediate/temporary files - process data in memory when possible
 - Only create files that are part of the final task goal
@@ -0,0 +1,70 @@
+package autonomous
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ScheduleMovements orders movements by their dependencies. It returns waves
+// of movement indices: every movement in a wave depends only on movements in
+// earlier waves, so the movements of one wave can run in parallel.
+// Dependencies on unknown IDs are ignored, since collapsing movements can
+// drop the ones they named. A dependency cycle is an error.
+func ScheduleMovements(movements []Movement) ([][]int, error) {
+	index := make(map[string]int, len(movements))
+	for i, m := range movements {
+		index[m.ID] = i
+	}
+
+	pending := make([]int, len(movements))
+	dependents := make([][]int, len(movements))
+	for i, m := range movements {
+		seen := map[int]bool{}
+		for _, dep := range m.Dependencies {
+			j, ok := index[dep]
+			if !ok || j == i || seen[j] {
+				continue
+			}
+			seen[j] = true
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	var wave []int
+	for i := range movements {
+		if pending[i] == 0 {
+			wave = append(wave, i)
+		}
+	}
+
+	var waves [][]int
+	scheduled := 0
+	for len(wave) > 0 {
+		waves = append(waves, wave)
+		scheduled += len(wave)
+		var next []int
+		for _, i := range wave {
+			for _, d := range dependents[i] {
+				pending[d]--
+				if pending[d] == 0 {
+					next = append(next, d)
+				}
+			}
+		}
+		sort.Ints(next)
+		wave = next
+	}
+
+	if scheduled < len(movements) {
+		var cycle []string
+		for i, n := range pending {
+			if n > 0 {
+				cycle = append(cycle, movements[i].ID)
+			}
+		}
+		return nil, fmt.Errorf("dependency cycle between movements: %s", strings.Join(cycle, ", "))
+	}
+	return waves, nil
+}
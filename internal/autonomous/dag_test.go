@@ -0,0 +1,38 @@
+package autonomous
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScheduleMovements(t *testing.T) {
+	movements := []Movement{
+		{ID: "api"},
+		{ID: "db"},
+		{ID: "handler", Dependencies: []string{"api", "db"}},
+		{ID: "docs", Dependencies: []string{"api", "collapsed"}},
+		{ID: "e2e", Dependencies: []string{"handler"}},
+	}
+
+	waves, err := ScheduleMovements(movements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]int{{0, 1}, {2, 3}, {4}}
+	if !reflect.DeepEqual(waves, want) {
+		t.Errorf("waves = %v, want %v", waves, want)
+	}
+}
+
+func TestScheduleMovementsCycle(t *testing.T) {
+	movements := []Movement{
+		{ID: "a"},
+		{ID: "b", Dependencies: []string{"c"}},
+		{ID: "c", Dependencies: []string{"b"}},
+	}
+	_, err := ScheduleMovements(movements)
+	if err == nil || !strings.Contains(err.Error(), "b, c") {
+		t.Errorf("expected cycle error naming b and c, got %v", err)
+	}
+}
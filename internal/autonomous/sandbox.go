@@ -0,0 +1,111 @@
+package autonomous
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Sandbox is a worktree of a checkout, including its uncommitted changes, in
+// which a whole task runs. Its changes reach the checkout only through Merge,
+// so a task that fails halfway leaves the checkout untouched.
+type Sandbox struct {
+	wt   *movementWorktree
+	temp string
+	rel  string
+}
+
+// NewSandbox creates a sandbox of the git checkout containing cwd.
+func NewSandbox(cwd string) (*Sandbox, error) {
+	root, err := repoRoot(cwd)
+	if err != nil {
+		return nil, fmt.Errorf("a sandbox needs a git repository with at least one commit: %w", err)
+	}
+	rel, err := filepath.Rel(root, cwd)
+	if err != nil {
+		return nil, err
+	}
+	temp, err := os.MkdirTemp("", "gptcode-sandbox-")
+	if err != nil {
+		return nil, err
+	}
+	wt, err := newMovementWorktree(root, filepath.Join(temp, filepath.Base(root)))
+	if err != nil {
+		os.RemoveAll(temp)
+		return nil, err
+	}
+	return &Sandbox{wt: wt, temp: temp, rel: rel}, nil
+}
+
+// Dir is the sandbox's counterpart of the directory it was created from.
+func (s *Sandbox) Dir() string {
+	return filepath.Join(s.wt.dir, s.rel)
+}
+
+// Merge applies the sandbox's changes to the checkout and returns the files
+// they touch. Nothing is changed when the patch no longer applies.
+func (s *Sandbox) Merge() ([]string, error) {
+	patch, err := s.wt.Patch()
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(patch))) == 0 {
+		return nil, nil
+	}
+	files, err := patchPaths(s.wt.repo, patch)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyPatch(s.wt.repo, patch); err != nil {
+		return nil, fmt.Errorf("sandbox changes conflict with the checkout: %w", err)
+	}
+	return files, nil
+}
+
+// WritePatch writes the sandbox's changes to path in git apply format,
+// relative to the repository root, and returns the files they touch
+// relative to it. Nothing is written when there are no changes.
+func (s *Sandbox) WritePatch(path string) ([]string, error) {
+	patch, err := s.wt.Patch()
+	if err != nil || len(strings.TrimSpace(string(patch))) == 0 {
+		return nil, err
+	}
+	paths, err := patchPaths(s.wt.repo, patch)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(paths))
+	for _, p := range paths {
+		rel, _ := filepath.Rel(s.wt.repo, p)
+		files = append(files, rel)
+	}
+	return files, os.WriteFile(path, patch, 0644)
+}
+
+// Root is the repository root of the checkout the sandbox was created from,
+// where its patches apply.
+func (s *Sandbox) Root() string {
+	return s.wt.repo
+}
+
+// SavePatch writes the sandbox's changes next to the symphony checkpoints and
+// returns the path, or "" when there are none.
+func (s *Sandbox) SavePatch() (string, error) {
+	patch, err := s.wt.Patch()
+	if err != nil || len(strings.TrimSpace(string(patch))) == 0 {
+		return "", err
+	}
+	if err := os.MkdirAll(checkpointsDir(), 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(checkpointsDir(), fmt.Sprintf("sandbox-%s.patch", time.Now().Format("20060102-150405")))
+	return path, os.WriteFile(path, patch, 0644)
+}
+
+// Remove deletes the sandbox.
+func (s *Sandbox) Remove() {
+	s.wt.Remove()
+	os.RemoveAll(s.temp)
+}
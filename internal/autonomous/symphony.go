@@ -5,13 +5,16 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"gptcode/internal/maestro"
+	"gptcode/internal/output"
 )
 
 // Symphony represents a multi-movement task execution
@@ -20,16 +23,17 @@ type Symphony struct {
 	Task            string     `json:"task"`
 	Movements       []Movement `json:"movements"`
 	CurrentMovement int        `json:"current_movement"`
-	Status          string     `json:"status"` // "pending", "executing", "completed", "failed"
+	Status          string     `json:"status"` // "pending", "executing", "completed", "failed", "cancelled"
 	StartTime       time.Time  `json:"start_time"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 }
 
 // Executor executes tasks using the Symphony pattern
 type Executor struct {
-	analyzer *TaskAnalyzer
-	maestro  *maestro.Conductor
-	cwd      string
+	analyzer     *TaskAnalyzer
+	maestro      *maestro.Conductor
+	cwd          string
+	conductorFor func(cwd string) *maestro.Conductor
 }
 
 // NewExecutor creates a new symphony executor
@@ -45,6 +49,12 @@ func NewExecutor(
 	}
 }
 
+// SetWorktreeConductors enables running independent movements in parallel.
+// newConductor builds a conductor working in the given worktree directory.
+func (e *Executor) SetWorktreeConductors(newConductor func(cwd string) *maestro.Conductor) {
+	e.conductorFor = newConductor
+}
+
 // Execute executes a task autonomously
 func (e *Executor) Execute(ctx context.Context, task string) error {
 	// 1. Analyze task
@@ -108,21 +118,31 @@ func (e *Executor) Execute(ctx context.Context, task string) error {
 	symphony.Movements = collapseDisplayMovements(symphony.Movements)
 	fmt.Printf("Optimized to %d movements\n\n", len(symphony.Movements))
 
-	// 5. Execute each movement
-	for i, movement := range symphony.Movements {
-		symphony.CurrentMovement = i
-
-		fmt.Printf("Movement %d/%d: %s\n", i+1, len(symphony.Movements), movement.Name)
-		fmt.Printf("   Goal: %s\n", movement.Goal)
+	// 5. Order movements by dependencies; independent ones run side by side
+	waves, err := ScheduleMovements(symphony.Movements)
+	if err != nil {
+		fmt.Printf("[WARNING] %v; running movements in listed order\n\n", err)
+		waves = nil
+		for i := range symphony.Movements {
+			waves = append(waves, []int{i})
+		}
+	}
 
-		err := e.executeMovement(ctx, &symphony.Movements[i])
-		if err != nil {
+	// 6. Execute each wave of movements
+	for _, wave := range waves {
+		if err := e.executeWave(ctx, symphony, wave); err != nil {
 			symphony.Status = "failed"
-			return fmt.Errorf("movement %d failed: %w", i+1, err)
+			if ctx.Err() != nil {
+				symphony.Status = "cancelled"
+			}
+			if err := e.saveCheckpoint(symphony); err != nil {
+				fmt.Printf("   [WARNING] Failed to save checkpoint: %v\n", err)
+			} else if symphony.Status == "cancelled" {
+				fmt.Printf("   Checkpoint saved to %s\n", filepath.Join(checkpointsDir(), symphony.ID+".json"))
+			}
+			return err
 		}
 
-		fmt.Printf("   [OK] Movement %d complete\n\n", i+1)
-
 		// Save checkpoint (enable resume)
 		if err := e.saveCheckpoint(symphony); err != nil {
 			fmt.Printf("   [WARNING] Failed to save checkpoint: %v\n", err)
@@ -133,10 +153,25 @@ func (e *Executor) Execute(ctx context.Context, task string) error {
 	symphony.Status = "completed"
 	symphony.CompletedAt = &now
 
-	fmt.Println("[OK] Symphony complete!")
+	fmt.Println(output.OKf("Symphony complete!"))
 	return nil
 }
 
+// ExecutePlan carries out a plan already agreed on for task. The plan is
+// not decomposed again: Maestro implements it as a whole, on models chosen
+// for the task's analysed complexity, with validation and the test guard.
+func (e *Executor) ExecutePlan(ctx context.Context, task string, plan string) error {
+	fmt.Println("Analyzing task...")
+	analysis, err := e.analyzer.Analyze(ctx, task)
+	if err != nil {
+		return fmt.Errorf("failed to analyze task: %w", err)
+	}
+	fmt.Printf("   Complexity: %d/10\n", analysis.Complexity)
+
+	fmt.Println("\nExecuting the agreed plan...")
+	return e.maestro.ExecutePlan(ctx, task, plan, complexityLevel(analysis.Complexity))
+}
+
 // executeDirect executes a simple task without decomposition
 func (e *Executor) executeDirect(ctx context.Context, task string, analysis *TaskAnalysis) error {
 	if os.Getenv("GPTCODE_DEBUG") == "1" {
@@ -144,12 +179,7 @@ func (e *Executor) executeDirect(ctx context.Context, task string, analysis *Tas
 	}
 
 	// Delegate to Maestro with complexity
-	complexityStr := "simple"
-	if analysis.Complexity >= 7 {
-		complexityStr = "complex"
-	} else if analysis.Complexity >= 5 {
-		complexityStr = "medium"
-	}
+	complexityStr := complexityLevel(analysis.Complexity)
 
 	if os.Getenv("GPTCODE_DEBUG") == "1" {
 		fmt.Fprintf(os.Stderr, "[SYMPHONY] Calling maestro.ExecuteTask with complexityStr=%s\n", complexityStr)
@@ -158,12 +188,127 @@ func (e *Executor) executeDirect(ctx context.Context, task string, analysis *Tas
 	return e.maestro.ExecuteTask(ctx, task, complexityStr)
 }
 
-// executeMovement executes a single movement with retry on review failure
-func (e *Executor) executeMovement(ctx context.Context, movement *Movement) error {
+// complexityLevel maps an analysed 1-10 complexity onto the level the
+// model selector is configured by.
+func complexityLevel(complexity int) string {
+	switch {
+	case complexity >= 7:
+		return "complex"
+	case complexity >= 5:
+		return "medium"
+	}
+	return "simple"
+}
+
+// executeWave runs a wave of independent movements. With a worktree
+// conductor factory and a git checkout, several movements run in parallel,
+// each in its own worktree, and their changes are merged back in order;
+// otherwise they run one after another in the checkout.
+func (e *Executor) executeWave(ctx context.Context, symphony *Symphony, wave []int) error {
+	if len(wave) > 1 && e.conductorFor != nil {
+		root, err := repoRoot(e.cwd)
+		if err == nil {
+			return e.executeParallel(ctx, symphony, wave, root)
+		}
+		if os.Getenv("GPTCODE_DEBUG") == "1" {
+			fmt.Fprintf(os.Stderr, "[SYMPHONY] Not a git checkout, running wave sequentially: %v\n", err)
+		}
+	}
+
+	for _, i := range wave {
+		movement := &symphony.Movements[i]
+		symphony.CurrentMovement = i
+		fmt.Printf("Movement %d/%d: %s\n", i+1, len(symphony.Movements), movement.Name)
+		fmt.Printf("   Goal: %s\n", movement.Goal)
+
+		if err := e.executeMovement(ctx, e.maestro, movement); err != nil {
+			return fmt.Errorf("movement %d failed: %w", i+1, err)
+		}
+		fmt.Printf("   %s\n\n", output.OKf("Movement %d complete", i+1))
+	}
+	return nil
+}
+
+// executeParallel runs each movement of the wave in a worktree of root, then
+// applies the changes of those that succeeded to the checkout in movement
+// order, even when a sibling failed. A patch that no longer applies is saved
+// next to the symphony checkpoint. Prompts and progress lines of the
+// movements take turns on the terminal; the cost meter, diff budget and
+// journal they share are safe for concurrent use.
+func (e *Executor) executeParallel(ctx context.Context, symphony *Symphony, wave []int, root string) error {
+	rel, err := filepath.Rel(root, e.cwd)
+	if err != nil {
+		return err
+	}
+
+	worktrees := make([]*movementWorktree, len(wave))
+	defer func() {
+		for _, wt := range worktrees {
+			if wt != nil {
+				wt.Remove()
+			}
+		}
+	}()
+
+	names := make([]string, len(wave))
+	for k, i := range wave {
+		dir := filepath.Join(os.TempDir(), fmt.Sprintf("gptcode-%s-%d", symphony.ID, i+1))
+		wt, err := newMovementWorktree(root, dir)
+		if err != nil {
+			return fmt.Errorf("failed to create worktree for movement %d: %w", i+1, err)
+		}
+		worktrees[k] = wt
+		names[k] = fmt.Sprintf("%d (%s)", i+1, symphony.Movements[i].Name)
+	}
+	fmt.Printf("Running movements %s in parallel\n\n", strings.Join(names, ", "))
+
+	errs := make([]error, len(wave))
+	var wg sync.WaitGroup
+	for k, i := range wave {
+		wg.Add(1)
+		go func(k, i int) {
+			defer wg.Done()
+			conductor := e.conductorFor(filepath.Join(worktrees[k].dir, rel))
+			errs[k] = e.executeMovement(ctx, conductor, &symphony.Movements[i])
+		}(k, i)
+	}
+	wg.Wait()
+
+	var failed []error
+	for k, i := range wave {
+		if errs[k] != nil {
+			failed = append(failed, fmt.Errorf("movement %d failed: %w", i+1, errs[k]))
+			continue
+		}
+		patch, err := worktrees[k].Patch()
+		if err != nil {
+			failed = append(failed, fmt.Errorf("failed to collect changes of movement %d: %w", i+1, err))
+			continue
+		}
+		if err := applyPatch(root, patch); err != nil {
+			symphony.Movements[i].Status = "failed"
+			path := filepath.Join(checkpointsDir(), fmt.Sprintf("%s-movement-%d.patch", symphony.ID, i+1))
+			if werr := os.WriteFile(path, patch, 0644); werr != nil {
+				failed = append(failed, fmt.Errorf("movement %d conflicts with earlier changes: %w", i+1, err))
+			} else {
+				failed = append(failed, fmt.Errorf("movement %d conflicts with earlier changes (patch saved to %s): %w", i+1, path, err))
+			}
+			continue
+		}
+		symphony.CurrentMovement = i
+		fmt.Printf("   %s\n", output.OKf("Movement %d merged", i+1))
+	}
+	fmt.Println()
+	return errors.Join(failed...)
+}
+
+// executeMovement executes a single movement with conductor, which runs,
+// reviews and validates the change.
+func (e *Executor) executeMovement(ctx context.Context, conductor *maestro.Conductor, movement *Movement) error {
 	movement.Status = "executing"
 
 	// Delegate to Maestro (movements are complex by definition)
-	err := e.maestro.ExecuteTask(ctx, movement.Goal, "complex")
+	err := conductor.ExecuteTask(ctx, movement.Goal, "complex")
 	if err != nil {
 		movement.Status = "failed"
 		return err
@@ -175,12 +320,12 @@ func (e *Executor) executeMovement(ctx context.Context, movement *Movement) erro
 
 // saveCheckpoint saves symphony state for resume capability
 func (e *Executor) saveCheckpoint(symphony *Symphony) error {
-	checkpointsDir := filepath.Join(os.Getenv("HOME"), ".gptcode", "symphonies")
-	if err := os.MkdirAll(checkpointsDir, 0755); err != nil {
+	dir := checkpointsDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	checkpointPath := filepath.Join(checkpointsDir, symphony.ID+".json")
+	checkpointPath := filepath.Join(dir, symphony.ID+".json")
 
 	data, err := json.MarshalIndent(symphony, "", "  ")
 	if err != nil {
@@ -190,6 +335,11 @@ func (e *Executor) saveCheckpoint(symphony *Symphony) error {
 	return os.WriteFile(checkpointPath, data, 0644)
 }
 
+func checkpointsDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "symphonies")
+}
+
 // generateID generates a random symphony ID
 func generateID() string {
 	b := make([]byte, 8)
@@ -0,0 +1,190 @@
+package autonomous
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/tools"
+)
+
+// movementWorktree is a detached git worktree holding a copy of the main
+// checkout, including its uncommitted changes, in which one movement runs
+// in isolation.
+type movementWorktree struct {
+	repo string
+	dir  string
+}
+
+// repoRoot returns the top level of the git repository containing dir, or
+// an error when dir is not in a repository with at least one commit.
+func repoRoot(dir string) (string, error) {
+	if _, err := git(dir, nil, "rev-parse", "--verify", "HEAD"); err != nil {
+		return "", err
+	}
+	out, err := git(dir, nil, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// newMovementWorktree creates a worktree of the repository rooted at repo at
+// dir and copies the checkout's uncommitted and untracked changes into it,
+// recording them as a baseline commit so the movement's own changes can be
+// diffed alone.
+func newMovementWorktree(repo, dir string) (*movementWorktree, error) {
+	if _, err := git(repo, nil, "worktree", "add", "--detach", dir, "HEAD"); err != nil {
+		return nil, err
+	}
+	wt := &movementWorktree{repo: repo, dir: dir}
+
+	if err := wt.copyPending(); err != nil {
+		wt.Remove()
+		return nil, err
+	}
+	if _, err := git(dir, nil, "add", "-A"); err != nil {
+		wt.Remove()
+		return nil, err
+	}
+	if _, err := git(dir, nil, "-c", "user.name=gptcode", "-c", "user.email=gptcode@localhost",
+		"commit", "-q", "--allow-empty", "--no-verify", "-m", "movement baseline"); err != nil {
+		wt.Remove()
+		return nil, err
+	}
+	return wt, nil
+}
+
+func (wt *movementWorktree) copyPending() error {
+	diff, err := git(wt.repo, nil, "diff", "HEAD", "--binary")
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(diff)) > 0 {
+		if _, err := git(wt.dir, diff, "apply", "--whitespace=nowarn", "-"); err != nil {
+			return err
+		}
+	}
+
+	untracked, err := git(wt.repo, nil, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return err
+	}
+	for _, path := range strings.Split(string(untracked), "\x00") {
+		if path == "" {
+			continue
+		}
+		src := filepath.Join(wt.repo, path)
+		info, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(wt.dir, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Patch returns the changes the movement made in the worktree.
+func (wt *movementWorktree) Patch() ([]byte, error) {
+	if _, err := git(wt.dir, nil, "add", "-A"); err != nil {
+		return nil, err
+	}
+	return git(wt.dir, nil, "diff", "--cached", "--binary", "HEAD")
+}
+
+// Remove deletes the worktree.
+func (wt *movementWorktree) Remove() {
+	_, _ = git(wt.repo, nil, "worktree", "remove", "--force", wt.dir)
+	// Edits in the worktree reach the checkout through applyPatch, which
+	// journals them there
+	if journal := tools.ActiveJournal(); journal != nil {
+		journal.Forget(wt.dir)
+	}
+}
+
+// applyPatch applies a movement's patch to the main checkout without
+// touching the index. It checks first so a conflicting patch changes nothing.
+func applyPatch(repo string, patch []byte) error {
+	if len(bytes.TrimSpace(patch)) == 0 {
+		return nil
+	}
+	if _, err := git(repo, patch, "apply", "--check", "--whitespace=nowarn", "-"); err != nil {
+		return err
+	}
+	paths, err := patchPaths(repo, patch)
+	if err != nil {
+		return err
+	}
+	journal := tools.ActiveJournal()
+	if journal != nil {
+		for _, path := range paths {
+			if err := journal.Snapshot(path); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := git(repo, patch, "apply", "--whitespace=nowarn", "-"); err != nil {
+		return err
+	}
+	if journal != nil {
+		for _, path := range paths {
+			journal.Written(path)
+		}
+	}
+	return nil
+}
+
+// patchPaths returns the absolute paths of the files a patch touches,
+// including both sides of renames.
+func patchPaths(repo string, patch []byte) ([]string, error) {
+	out, err := git(repo, patch, "apply", "--numstat", "-z", "-")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		parts := strings.SplitN(fields[i], "\t", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		if parts[2] != "" {
+			paths = append(paths, filepath.Join(repo, parts[2]))
+			continue
+		}
+		// Renames list the old and new paths as the next two fields
+		for _, name := range fields[i+1 : min(i+3, len(fields))] {
+			paths = append(paths, filepath.Join(repo, name))
+		}
+		i += 2
+	}
+	return paths, nil
+}
+
+func git(dir string, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package autonomous
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func initRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if _, err := git(dir, nil, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestMovementWorktreeMergesChanges(t *testing.T) {
+	repo := initRepo(t)
+	if err := os.WriteFile(filepath.Join(repo, "pending.txt"), []byte("from checkout\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var patches [][]byte
+	for _, name := range []string{"a.txt", "b.txt"} {
+		wt, err := newMovementWorktree(repo, filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(wt.dir, "pending.txt")); err != nil {
+			t.Errorf("worktree should contain the checkout's pending changes: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(wt.dir, name), []byte(name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		patch, err := wt.Patch()
+		if err != nil {
+			t.Fatal(err)
+		}
+		patches = append(patches, patch)
+		wt.Remove()
+	}
+
+	for _, patch := range patches {
+		if err := applyPatch(repo, patch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if data, err := os.ReadFile(filepath.Join(repo, name)); err != nil || string(data) != name+"\n" {
+			t.Errorf("%s not merged: %q %v", name, data, err)
+		}
+	}
+
+	if err := applyPatch(repo, patches[0]); err == nil {
+		t.Error("reapplying a patch should conflict")
+	}
+}
+
+func TestSandboxMergesOnlyOnMerge(t *testing.T) {
+	repo := initRepo(t)
+	sub := filepath.Join(repo, "pkg")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	sb, err := NewSandbox(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Remove()
+	if err := os.MkdirAll(sb.Dir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sb.Dir(), "fix.go"), []byte("package pkg\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(sub, "fix.go")); !os.IsNotExist(err) {
+		t.Fatal("sandbox changes should not reach the checkout before Merge")
+	}
+
+	files, err := sb.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _ := filepath.EvalSymlinks(repo)
+	if len(files) != 1 || files[0] != filepath.Join(root, "pkg", "fix.go") {
+		t.Errorf("unexpected merged files %v", files)
+	}
+	if data, err := os.ReadFile(filepath.Join(sub, "fix.go")); err != nil || string(data) != "package pkg\n" {
+		t.Errorf("fix.go not merged: %q %v", data, err)
+	}
+}
+
+func TestSandboxWritePatch(t *testing.T) {
+	repo := initRepo(t)
+	sb, err := NewSandbox(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Remove()
+
+	path := filepath.Join(t.TempDir(), "task.patch")
+	if files, err := sb.WritePatch(path); err != nil || files != nil {
+		t.Fatalf("no changes, no patch: %v %v", files, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("a patch without changes should not be written")
+	}
+
+	if err := os.WriteFile(filepath.Join(sb.Dir(), "fix.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := sb.WritePatch(path)
+	if err != nil || len(files) != 1 || files[0] != "fix.go" {
+		t.Fatalf("WritePatch = %v, %v", files, err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "fix.go")); !os.IsNotExist(err) {
+		t.Fatal("the checkout should be untouched")
+	}
+	if out, err := exec.Command("git", "-C", repo, "apply", path).CombinedOutput(); err != nil {
+		t.Fatalf("the patch should apply with git apply: %v %s", err, out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(repo, "fix.go")); string(data) != "package main\n" {
+		t.Errorf("fix.go = %q", data)
+	}
+}
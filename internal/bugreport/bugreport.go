@@ -0,0 +1,524 @@
+// Package bugreport bundles a gptcode session into a tarball that can be
+// attached to an issue against gptcode, and loads such bundles back so
+// maintainers can replay the session offline.
+package bugreport
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"gptcode/internal/config"
+	"gptcode/internal/observability"
+)
+
+// FormatVersion is the bundle layout version written to the manifest
+const FormatVersion = 1
+
+const (
+	maxFiles    = 50
+	maxFileSize = 256 * 1024
+)
+
+// Manifest describes a bundle
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	Session       string    `json:"session"`
+	Command       string    `json:"command"`
+	Created       time.Time `json:"created"`
+	Version       string    `json:"gptcode_version"`
+	Files         []string  `json:"files,omitempty"`
+	// Skipped lists relevant files left out for size or count
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Environment is the machine and repository the session ran in
+type Environment struct {
+	OS        string   `json:"os"`
+	Arch      string   `json:"arch"`
+	GoVersion string   `json:"go_version"`
+	Shell     string   `json:"shell,omitempty"`
+	GitHead   string   `json:"git_head,omitempty"`
+	GitBranch string   `json:"git_branch,omitempty"`
+	GitDirty  bool     `json:"git_dirty"`
+	EnvVars   []string `json:"env_vars,omitempty"` // names only
+}
+
+// Bundle is the content of a bug report
+type Bundle struct {
+	Manifest    Manifest
+	Trace       observability.SessionTrace
+	Exchanges   []observability.Exchange
+	Config      string
+	Environment Environment
+	Diff        string
+	Files       map[string]string
+}
+
+// Options configures Create
+type Options struct {
+	// Dir is the repository the session ran in; traces are read from it
+	Dir string
+	// Version is the running gptcode version
+	Version string
+	// Output is the tarball to write; defaults to
+	// gptcode-bugreport-<session>.tar.gz in Dir
+	Output string
+}
+
+// FindTrace returns the trace file for session in dir. A session ID prefix
+// is enough; "latest" or an empty session selects the newest trace.
+func FindTrace(dir, session string) (string, error) {
+	pattern := "trace_*.json"
+	if session != "" && session != "latest" {
+		pattern = "trace_" + session + "*.json"
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+	if len(matches) == 0 {
+		if session == "" || session == "latest" {
+			return "", fmt.Errorf("no session traces in %s", dir)
+		}
+		return "", fmt.Errorf("no trace for session %s in %s", session, dir)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		a, _ := os.Stat(matches[i])
+		b, _ := os.Stat(matches[j])
+		return a.ModTime().After(b.ModTime())
+	})
+	if session != "" && session != "latest" && len(matches) > 1 {
+		first := sessionOf(matches[0])
+		for _, m := range matches[1:] {
+			if sessionOf(m) != first {
+				return "", fmt.Errorf("session %s is ambiguous; use more of the ID", session)
+			}
+		}
+	}
+	return matches[0], nil
+}
+
+// sessionOf extracts the session ID from trace_<session>_<date>_<time>.json
+func sessionOf(path string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "trace_"), ".json")
+	parts := strings.Split(name, "_")
+	if len(parts) > 2 {
+		return strings.Join(parts[:len(parts)-2], "_")
+	}
+	return name
+}
+
+// Collect gathers a session's bundle: its trace, the LLM exchanges made
+// while it ran, the files it touched, the config and the environment.
+// Everything is passed through redactor.
+func Collect(session string, opts Options, redactor *Redactor) (*Bundle, error) {
+	tracePath, err := FindTrace(opts.Dir, session)
+	if err != nil {
+		return nil, err
+	}
+	trace, err := LoadTrace(tracePath)
+	if err != nil {
+		return nil, err
+	}
+	trace.Command = redactor.Redact(trace.Command)
+
+	b := &Bundle{
+		Manifest: Manifest{
+			FormatVersion: FormatVersion,
+			Session:       trace.SessionID,
+			Command:       trace.Command,
+			Created:       time.Now(),
+			Version:       opts.Version,
+		},
+		Trace:       *trace,
+		Environment: environment(opts.Dir),
+		Files:       map[string]string{},
+	}
+
+	if trace.Transcript != "" {
+		exchanges, err := observability.LoadTranscript(trace.Transcript)
+		if err != nil {
+			return nil, err
+		}
+		for _, ex := range exchanges {
+			if inWindow(ex.Time, *trace) {
+				b.Exchanges = append(b.Exchanges, redactExchange(ex, redactor))
+			}
+		}
+	}
+
+	if cfg, err := strippedConfig(); err == nil {
+		b.Config = redactor.Redact(cfg)
+	}
+	if diff, err := gitOutput(opts.Dir, "diff", "HEAD"); err == nil {
+		b.Diff = redactor.Redact(diff)
+	}
+
+	for _, path := range relevantFiles(opts.Dir, b) {
+		content, err := os.ReadFile(filepath.Join(opts.Dir, path))
+		switch {
+		case err != nil:
+			continue
+		case len(content) > maxFileSize || len(b.Files) >= maxFiles:
+			b.Manifest.Skipped = append(b.Manifest.Skipped, path)
+		default:
+			b.Files[path] = redactor.Redact(string(content))
+			b.Manifest.Files = append(b.Manifest.Files, path)
+		}
+	}
+	return b, nil
+}
+
+// LoadTrace reads a session trace file
+func LoadTrace(path string) (*observability.SessionTrace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var trace observability.SessionTrace
+	if err := json.Unmarshal(data, &trace); err != nil {
+		return nil, fmt.Errorf("invalid trace %s: %w", path, err)
+	}
+	return &trace, nil
+}
+
+// Create collects the session and writes it as a tarball, returning its path.
+func Create(session string, opts Options) (string, error) {
+	b, err := Collect(session, opts, DefaultRedactor())
+	if err != nil {
+		return "", err
+	}
+	out := opts.Output
+	if out == "" {
+		out = filepath.Join(opts.Dir, fmt.Sprintf("gptcode-bugreport-%s.tar.gz", shortID(b.Manifest.Session)))
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return "", err
+	}
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return "", err
+	}
+	return out, f.Close()
+}
+
+// DefaultRedactor redacts the API keys of every configured backend along
+// with anything that looks like a secret.
+func DefaultRedactor() *Redactor {
+	var known []string
+	setup, _ := config.LoadEffectiveSetup()
+	for name := range setup.Backend {
+		if key := config.GetAPIKey(name); key != "" {
+			known = append(known, key)
+		}
+	}
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if secretEnvName(name) {
+			known = append(known, value)
+		}
+	}
+	return NewRedactor(known...)
+}
+
+func secretEnvName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, s := range []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "CREDENTIAL"} {
+		if strings.Contains(upper, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Write writes the bundle as a gzipped tarball.
+func (b *Bundle) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: b.Manifest.Created}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	addJSON := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return add(name, data)
+	}
+
+	if err := addJSON("manifest.json", b.Manifest); err != nil {
+		return err
+	}
+	if err := addJSON("trace.json", b.Trace); err != nil {
+		return err
+	}
+	if err := addJSON("environment.json", b.Environment); err != nil {
+		return err
+	}
+	var transcript bytes.Buffer
+	enc := json.NewEncoder(&transcript)
+	for _, ex := range b.Exchanges {
+		if err := enc.Encode(ex); err != nil {
+			return err
+		}
+	}
+	if err := add("transcript.jsonl", transcript.Bytes()); err != nil {
+		return err
+	}
+	if err := add("config.yaml", []byte(b.Config)); err != nil {
+		return err
+	}
+	if err := add("diff.patch", []byte(b.Diff)); err != nil {
+		return err
+	}
+	paths := make([]string, 0, len(b.Files))
+	for path := range b.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := add("files/"+filepath.ToSlash(path), []byte(b.Files[path])); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Load reads a bundle written by Create.
+func Load(path string) (*Bundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("not a bug report bundle: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	b := &Bundle{Files: map[string]string{}}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		switch name := hdr.Name; {
+		case name == "manifest.json":
+			err = json.Unmarshal(data, &b.Manifest)
+		case name == "trace.json":
+			err = json.Unmarshal(data, &b.Trace)
+		case name == "environment.json":
+			err = json.Unmarshal(data, &b.Environment)
+		case name == "transcript.jsonl":
+			for _, line := range bytes.Split(data, []byte("\n")) {
+				var ex observability.Exchange
+				if json.Unmarshal(line, &ex) == nil {
+					b.Exchanges = append(b.Exchanges, ex)
+				}
+			}
+		case name == "config.yaml":
+			b.Config = string(data)
+		case name == "diff.patch":
+			b.Diff = string(data)
+		case strings.HasPrefix(name, "files/"):
+			b.Files[filepath.FromSlash(strings.TrimPrefix(name, "files/"))] = string(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in bundle: %w", hdr.Name, err)
+		}
+	}
+	if b.Manifest.FormatVersion == 0 {
+		return nil, fmt.Errorf("%s has no manifest; not a bug report bundle", path)
+	}
+	if b.Manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("bundle format %d is newer than this gptcode supports (%d)", b.Manifest.FormatVersion, FormatVersion)
+	}
+	return b, nil
+}
+
+func inWindow(t time.Time, trace observability.SessionTrace) bool {
+	if t.Before(trace.StartTime) {
+		return false
+	}
+	return trace.EndTime == nil || !t.After(*trace.EndTime)
+}
+
+func redactExchange(ex observability.Exchange, r *Redactor) observability.Exchange {
+	ex.System = r.Redact(ex.System)
+	ex.Response = r.Redact(ex.Response)
+	ex.Messages = redactJSON(ex.Messages, r)
+	ex.ToolCalls = redactJSON(ex.ToolCalls, r)
+	return ex
+}
+
+// redactJSON redacts the strings inside a JSON document so it stays valid.
+func redactJSON(raw json.RawMessage, r *Redactor) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return json.RawMessage(fmt.Sprintf("%q", r.Redact(string(raw))))
+	}
+	out, err := json.Marshal(redactValue(v, r))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func redactValue(v interface{}, r *Redactor) interface{} {
+	switch t := v.(type) {
+	case string:
+		return r.Redact(t)
+	case []interface{}:
+		for i := range t {
+			t[i] = redactValue(t[i], r)
+		}
+	case map[string]interface{}:
+		for k := range t {
+			t[k] = redactValue(t[k], r)
+		}
+	}
+	return v
+}
+
+// strippedConfig is setup.yaml without header values, which may carry
+// credentials.
+func strippedConfig() (string, error) {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return "", err
+	}
+	for name, backend := range setup.Backend {
+		for h := range backend.Headers {
+			backend.Headers[h] = redacted
+		}
+		setup.Backend[name] = backend
+	}
+	data, err := yaml.Marshal(setup)
+	return string(data), err
+}
+
+// relevantFiles are the files the session touched according to its trace and
+// tool calls, plus the files changed in the working tree.
+func relevantFiles(dir string, b *Bundle) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(path string) {
+		if path == "" {
+			return
+		}
+		if filepath.IsAbs(path) {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				return
+			}
+			path = rel
+		}
+		path = filepath.Clean(path)
+		if strings.HasPrefix(path, "..") || seen[path] {
+			return
+		}
+		seen[path] = true
+		out = append(out, path)
+	}
+
+	for _, step := range b.Trace.Steps {
+		if files, ok := step.Inputs["files"].([]interface{}); ok {
+			for _, f := range files {
+				s, _ := f.(string)
+				add(s)
+			}
+		}
+	}
+	for _, ex := range b.Exchanges {
+		var calls []struct{ Arguments string }
+		if json.Unmarshal(ex.ToolCalls, &calls) != nil {
+			continue
+		}
+		for _, call := range calls {
+			var args struct {
+				Path string `json:"path"`
+			}
+			if json.Unmarshal([]byte(call.Arguments), &args) == nil {
+				add(args.Path)
+			}
+		}
+	}
+	if changed, err := gitOutput(dir, "diff", "--name-only", "HEAD"); err == nil {
+		for _, path := range strings.Split(strings.TrimSpace(changed), "\n") {
+			add(path)
+		}
+	}
+	return out
+}
+
+func environment(dir string) Environment {
+	env := Environment{
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		Shell:     filepath.Base(os.Getenv("SHELL")),
+	}
+	if head, err := gitOutput(dir, "rev-parse", "HEAD"); err == nil {
+		env.GitHead = strings.TrimSpace(head)
+	}
+	if branch, err := gitOutput(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		env.GitBranch = strings.TrimSpace(branch)
+	}
+	if status, err := gitOutput(dir, "status", "--porcelain"); err == nil {
+		env.GitDirty = strings.TrimSpace(status) != ""
+	}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(name, "GPTCODE_") || strings.HasSuffix(name, "_API_KEY") {
+			env.EnvVars = append(env.EnvVars, name)
+		}
+	}
+	sort.Strings(env.EnvVars)
+	return env
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func shortID(session string) string {
+	if len(session) > 8 {
+		return session[:8]
+	}
+	return session
+}
@@ -0,0 +1,45 @@
+package bugreport
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"gptcode/internal/secrets"
+)
+
+const redacted = "[REDACTED]"
+
+// assignment keeps the key and redacts the value of secret-looking settings
+// such as API_KEY=..., "password": "...", token: ...
+var assignment = regexp.MustCompile(`(?i)((?:` + secrets.KeyWords + `)s?["']?\s*[:=]\s*["']?)([^\s"',}]{6,})`)
+
+// Redactor removes secrets from text: known values such as configured API
+// keys, and anything that looks like a key, token or private key.
+type Redactor struct {
+	known []string
+}
+
+// NewRedactor returns a redactor that also removes the given values.
+func NewRedactor(known ...string) *Redactor {
+	r := &Redactor{}
+	for _, v := range known {
+		if len(v) >= 8 {
+			r.known = append(r.known, v)
+		}
+	}
+	// Longest first so a key containing another is removed whole
+	sort.Slice(r.known, func(i, j int) bool { return len(r.known[i]) > len(r.known[j]) })
+	return r
+}
+
+// Redact returns s with secrets replaced by [REDACTED].
+func (r *Redactor) Redact(s string) string {
+	for _, v := range r.known {
+		s = strings.ReplaceAll(s, v, redacted)
+	}
+	for _, p := range secrets.Patterns {
+		s = p.Regexp.ReplaceAllString(s, redacted)
+	}
+	return assignment.ReplaceAllString(s, "${1}"+redacted)
+}
@@ -0,0 +1,171 @@
+package bugreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gptcode/internal/observability"
+)
+
+// previewLen is how much of each prompt and response Replay shows unless
+// the full text is requested
+const previewLen = 300
+
+// event is a trace step or an LLM exchange on the session timeline
+type event struct {
+	time     time.Time
+	step     *observability.StepTrace
+	exchange *observability.Exchange
+}
+
+// Replay writes the session as a timeline: trace steps and decisions
+// interleaved with the prompts sent and responses received. With full the
+// prompts and responses are not shortened.
+func (b *Bundle) Replay(w io.Writer, full bool) {
+	m := b.Manifest
+	fmt.Fprintf(w, "Session %s (gptcode %s)\n", m.Session, m.Version)
+	fmt.Fprintf(w, "Command: %s\n", m.Command)
+	status := "failed"
+	if b.Trace.Success {
+		status = "succeeded"
+	}
+	fmt.Fprintf(w, "Result:  %s in %s, $%.4f\n", status, time.Duration(b.Trace.TotalTimeMs)*time.Millisecond, b.Trace.TotalCost)
+	env := b.Environment
+	fmt.Fprintf(w, "Env:     %s/%s, %s, git %s on %s (dirty: %v)\n", env.OS, env.Arch, env.GoVersion, shortID(env.GitHead), env.GitBranch, env.GitDirty)
+	// The environment the session itself ran with, which traces record
+	if run := b.Trace.Environment; run != nil {
+		tools := make([]string, 0, len(run.Tools))
+		for _, version := range run.Tools {
+			tools = append(tools, version)
+		}
+		sort.Strings(tools)
+		fmt.Fprintf(w, "Ran with: git %s, %d dirty files, %s\n", shortID(run.GitCommit), len(run.Dirty), strings.Join(tools, "; "))
+		if len(run.Models) > 0 {
+			fmt.Fprintf(w, "Models:  %s\n", strings.Join(run.Models, ", "))
+		}
+	}
+	fmt.Fprintln(w)
+
+	var events []event
+	for i := range b.Trace.Steps {
+		events = append(events, event{time: b.Trace.Steps[i].Timestamp, step: &b.Trace.Steps[i]})
+	}
+	for i := range b.Exchanges {
+		events = append(events, event{time: b.Exchanges[i].Time, exchange: &b.Exchanges[i]})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].time.Before(events[j].time) })
+
+	start := b.Trace.StartTime
+	for _, e := range events {
+		offset := e.time.Sub(start).Round(time.Millisecond)
+		if e.step != nil {
+			writeStep(w, offset, e.step)
+		} else {
+			writeExchange(w, offset, e.exchange, full)
+		}
+	}
+
+	if len(m.Files) > 0 {
+		fmt.Fprintf(w, "\nFiles: %s\n", strings.Join(m.Files, ", "))
+	}
+	if len(m.Skipped) > 0 {
+		fmt.Fprintf(w, "Not included: %s\n", strings.Join(m.Skipped, ", "))
+	}
+}
+
+func writeStep(w io.Writer, offset time.Duration, step *observability.StepTrace) {
+	fmt.Fprintf(w, "[%8s] %s", offset, step.Node)
+	if d := step.Decision; d != nil {
+		fmt.Fprintf(w, ": %s -> %s", d.Type, d.Chosen)
+		if d.Reasoning != "" {
+			fmt.Fprintf(w, " (%s)", d.Reasoning)
+		}
+	}
+	if step.Metrics.ErrorMessage != "" {
+		fmt.Fprintf(w, " error: %s", step.Metrics.ErrorMessage)
+	} else if step.Metrics.DurationMs > 0 {
+		fmt.Fprintf(w, " %dms", step.Metrics.DurationMs)
+	}
+	fmt.Fprintln(w)
+}
+
+func writeExchange(w io.Writer, offset time.Duration, ex *observability.Exchange, full bool) {
+	fmt.Fprintf(w, "[%8s] LLM %s/%s\n", offset, ex.Backend, ex.Model)
+	var messages []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	_ = json.Unmarshal(ex.Messages, &messages)
+	if len(messages) > 0 {
+		last := messages[len(messages)-1]
+		fmt.Fprintf(w, "           > %s (%d messages): %s\n", last.Role, len(messages), clip(last.Content, full))
+	}
+	if ex.Response != "" {
+		fmt.Fprintf(w, "           < %s\n", clip(ex.Response, full))
+	}
+	var calls []struct{ Name, Arguments string }
+	_ = json.Unmarshal(ex.ToolCalls, &calls)
+	for _, call := range calls {
+		fmt.Fprintf(w, "           < %s %s\n", call.Name, clip(call.Arguments, full))
+	}
+}
+
+func clip(s string, full bool) string {
+	if full {
+		return s
+	}
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > previewLen {
+		return s[:previewLen] + "..."
+	}
+	return s
+}
+
+// Extract writes the bundle's files under dir/files, with the config, diff,
+// trace and transcript next to them, so the session can be reproduced.
+func (b *Bundle) Extract(dir string) error {
+	for path, content := range b.Files {
+		target := filepath.Join(dir, "files", path)
+		rel, err := filepath.Rel(filepath.Join(dir, "files"), target)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("unsafe path in bundle: %s", path)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	trace, err := json.MarshalIndent(b.Trace, "", "  ")
+	if err != nil {
+		return err
+	}
+	var transcript strings.Builder
+	for _, ex := range b.Exchanges {
+		line, err := json.Marshal(ex)
+		if err != nil {
+			return err
+		}
+		transcript.Write(line)
+		transcript.WriteByte('\n')
+	}
+	for name, content := range map[string]string{
+		"config.yaml":      b.Config,
+		"diff.patch":       b.Diff,
+		"trace.json":       string(trace),
+		"transcript.jsonl": transcript.String(),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -158,3 +158,55 @@ func searchModels(models []ModelOutput, query string) []ModelOutput {
 
 	return results
 }
+
+func TestFilterAndSortModels(t *testing.T) {
+	models := []ModelOutput{
+		{ID: "big", ContextWindow: 1000000, PricingPrompt: 2, PricingComp: 8},
+		{ID: "free", ContextWindow: 32768},
+		{ID: "auto", PricingPrompt: -1, PricingComp: -1},
+		{ID: "tools", ContextWindow: 131072, PricingPrompt: 0.5, PricingComp: 1, FeedbackScore: 0.9, Capabilities: &Capabilities{SupportsTools: true}},
+	}
+	ids := func(models []ModelOutput) string {
+		var ids []string
+		for _, m := range models {
+			ids = append(ids, m.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+
+	size, err := ParseContextSize("32k")
+	if err != nil || size != 32000 {
+		t.Fatalf("ParseContextSize(32k) = %d, %v", size, err)
+	}
+	cases := []struct {
+		filter Filter
+		want   string
+	}{
+		{Filter{}, "big,free,auto,tools"},
+		{Filter{MaxPrice: 5}, "free,tools"},
+		{Filter{Free: true}, "free"},
+		{Filter{MinContext: size}, "big,free,tools"},
+		{Filter{ToolsOnly: true}, "tools"},
+	}
+	for _, c := range cases {
+		if got := ids(c.filter.Apply(models)); got != c.want {
+			t.Errorf("%+v kept %s, want %s", c.filter, got, c.want)
+		}
+	}
+
+	for by, want := range map[string]string{
+		SortScore:   "tools,free,big,auto",
+		SortPrice:   "free,tools,big,auto",
+		SortContext: "big,tools,free,auto",
+	} {
+		if err := SortModels(models, by); err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(models); got != want {
+			t.Errorf("sorted by %s: %s, want %s", by, got, want)
+		}
+	}
+	if err := SortModels(models, "name"); err == nil {
+		t.Error("expected an error for an unknown sort")
+	}
+}
@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/chromedp/chromedp"
+
+	"gptcode/internal/httpclient"
 )
 
 const (
@@ -109,6 +111,19 @@ type ModelOutput struct {
 	PricingComp    float64  `json:"pricing_completion_per_m_tokens"`
 	Installed      bool     `json:"installed"`
 	FeedbackScore  float64  `json:"feedback_score"`
+	// Capabilities are known for some models only
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+}
+
+type Capabilities struct {
+	SupportsTools          bool   `json:"supports_tools"`
+	SupportsFileOperations bool   `json:"supports_file_operations"`
+	Notes                  string `json:"notes,omitempty"`
+}
+
+// SupportsTools tells whether the model is known to call tools
+func (m ModelOutput) SupportsTools() bool {
+	return m.Capabilities != nil && m.Capabilities.SupportsTools
 }
 
 type ProviderOutput struct {
@@ -176,7 +191,7 @@ func FetchAndSave(outputPath string, apiKeys map[string]string) error {
 }
 
 func fetchOllamaInstalledModels() ([]ModelAPI, error) {
-	resp, err := http.Get(OllamaAPI)
+	resp, err := httpclient.Default().Get(OllamaAPI)
 	if err != nil {
 		return nil, fmt.Errorf("ollama not available: %w", err)
 	}
@@ -292,7 +307,7 @@ func mergeOllamaModels(installed, available []ModelAPI) []ModelAPI {
 }
 
 func fetchOpenRouterModels() ([]ModelAPI, error) {
-	resp, err := http.Get(OpenRouterAPI)
+	resp, err := httpclient.Default().Get(OpenRouterAPI)
 	if err != nil {
 		return nil, fmt.Errorf("falha na requisição HTTP: %w", err)
 	}
@@ -319,7 +334,7 @@ func fetchGroqModels(apiKey string) ([]ModelAPI, error) {
 	req, _ := http.NewRequest("GET", GroqAPI, nil)
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Default().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -438,7 +453,7 @@ func fetchOpenAIModels(apiKey string) ([]ModelAPI, error) {
 	req, _ := http.NewRequest("GET", OpenAIAPI, nil)
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Default().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -462,7 +477,7 @@ func fetchAnthropicModels(apiKey string) ([]ModelAPI, error) {
 	req.Header.Set("x-api-key", apiKey)
 	req.Header.Set("anthropic-version", "2023-06-01")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Default().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -485,7 +500,7 @@ func fetchCohereModels(apiKey string) ([]ModelAPI, error) {
 	req, _ := http.NewRequest("GET", CohereAPI, nil)
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := httpclient.Default().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -535,6 +550,9 @@ func categorizeAndTagModels(sources []ModelSource) OutputJSON {
 				RecommendedFor: inferRecommendedFor(m),
 				Installed:      m.Installed,
 			}
+			if m.SupportsTools {
+				modelOutput.Capabilities = &Capabilities{SupportsTools: true}
+			}
 
 			switch source.Provider {
 			case "ollama":
@@ -0,0 +1,105 @@
+package catalog
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Sort orders for SortModels
+const (
+	SortScore   = "score"
+	SortPrice   = "price"
+	SortContext = "context"
+)
+
+// Filter narrows down search results
+type Filter struct {
+	// MaxPrice is the highest prompt plus completion price per million
+	// tokens; 0 means no limit
+	MaxPrice   float64
+	MinContext int
+	ToolsOnly  bool
+	Free       bool
+}
+
+// price is a model's prompt plus completion price, +Inf when it varies as
+// for routers, which the catalog records as negative
+func price(m ModelOutput) float64 {
+	if m.PricingPrompt < 0 || m.PricingComp < 0 {
+		return math.Inf(1)
+	}
+	return m.PricingPrompt + m.PricingComp
+}
+
+// Apply returns the models passing the filter
+func (f Filter) Apply(models []ModelOutput) []ModelOutput {
+	var kept []ModelOutput
+	for _, m := range models {
+		switch {
+		case f.MaxPrice > 0 && price(m) > f.MaxPrice:
+		case f.Free && price(m) != 0:
+		case m.ContextWindow < f.MinContext:
+		case f.ToolsOnly && !m.SupportsTools():
+		default:
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// SortModels orders models by feedback score (best first, the search
+// order), price (cheapest first) or context window (largest first), each
+// breaking ties with the others.
+func SortModels(models []ModelOutput, by string) error {
+	score := func(i, j int) int {
+		return cmp.Compare(models[j].FeedbackScore, models[i].FeedbackScore)
+	}
+	cheaper := func(i, j int) int {
+		return cmp.Compare(price(models[i]), price(models[j]))
+	}
+	context := func(i, j int) int {
+		return models[j].ContextWindow - models[i].ContextWindow
+	}
+
+	var keys []func(i, j int) int
+	switch by {
+	case SortScore, "":
+		keys = []func(i, j int) int{score, cheaper, context}
+	case SortPrice:
+		keys = []func(i, j int) int{cheaper, context, score}
+	case SortContext:
+		keys = []func(i, j int) int{context, cheaper, score}
+	default:
+		return fmt.Errorf("unknown sort %q (score, price or context)", by)
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		for _, key := range keys {
+			if c := key(i, j); c != 0 {
+				return c < 0
+			}
+		}
+		return models[i].Name < models[j].Name
+	})
+	return nil
+}
+
+// ParseContextSize reads a context window size such as 32k, 1m or 131072
+func ParseContextSize(s string) (int, error) {
+	num := strings.ToLower(strings.TrimSpace(s))
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(num, "k"):
+		multiplier, num = 1000, strings.TrimSuffix(num, "k")
+	case strings.HasSuffix(num, "m"):
+		multiplier, num = 1000000, strings.TrimSuffix(num, "m")
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid context size %q", s)
+	}
+	return int(n * multiplier), nil
+}
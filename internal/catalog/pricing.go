@@ -0,0 +1,78 @@
+package catalog
+
+import (
+	"strings"
+	"sync"
+)
+
+var (
+	priceOnce sync.Once
+	priceData *OutputJSON
+)
+
+// Price returns a model's prompt and completion prices in USD per million
+// tokens. The backend's own listing is preferred; models of backends the
+// catalog does not list (custom OpenAI-compatible endpoints) are matched by
+// ID across all providers. Local Ollama models are free.
+func Price(backend, model string) (prompt, completion float64, ok bool) {
+	if strings.EqualFold(backend, "ollama") {
+		return 0, 0, true
+	}
+	m, found := lookup(backend, model)
+	if !found {
+		return 0, 0, false
+	}
+	return m.PricingPrompt, m.PricingComp, true
+}
+
+// ContextWindow returns a model's context window in tokens, or 0 when the
+// catalog does not list it. Models are matched as in Price.
+func ContextWindow(backend, model string) int {
+	m, found := lookup(backend, model)
+	if !found {
+		return 0
+	}
+	return m.ContextWindow
+}
+
+// lookup finds model in backend's listing, then in every provider's
+func lookup(backend, model string) (ModelOutput, bool) {
+	priceOnce.Do(func() {
+		priceData, _ = Load()
+	})
+	if priceData == nil || model == "" {
+		return ModelOutput{}, false
+	}
+
+	providers := map[string][]ModelOutput{
+		"openrouter": priceData.OpenRouter.Models,
+		"groq":       priceData.Groq.Models,
+		"openai":     priceData.OpenAI.Models,
+		"deepseek":   priceData.DeepSeek.Models,
+		"ollama":     priceData.Ollama.Models,
+	}
+	if m, found := findModel(providers[strings.ToLower(backend)], model); found {
+		return m, true
+	}
+	for _, name := range []string{"openrouter", "openai", "groq", "deepseek"} {
+		if m, found := findModel(providers[name], model); found {
+			return m, true
+		}
+	}
+	return ModelOutput{}, false
+}
+
+func findModel(models []ModelOutput, id string) (ModelOutput, bool) {
+	for _, m := range models {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	// OpenRouter IDs carry a vendor prefix ("openai/gpt-4o")
+	for _, m := range models {
+		if _, name, found := strings.Cut(m.ID, "/"); found && name == id {
+			return m, true
+		}
+	}
+	return ModelOutput{}, false
+}
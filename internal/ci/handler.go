@@ -2,11 +2,14 @@ package ci
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
+	"gptcode/internal/httpclient"
 	"gptcode/internal/llm"
 	"gptcode/internal/recovery"
 )
@@ -27,29 +30,62 @@ type CIFailure struct {
 	FullLog    string
 }
 
+// Checks reports the CI checks of a pull request and fetches their logs.
+// Without one a Handler asks GitHub through the gh CLI; forges such as
+// GitLab supply their own.
+type Checks interface {
+	CheckPRStatus(prNumber int) ([]CIStatus, error)
+	FetchCILogs(prNumber int, checkName string) (string, error)
+}
+
 type Handler struct {
 	repo     string
 	workDir  string
 	provider llm.Provider
 	model    string
+	checks   Checks
+
+	// now and sleep are the clock WaitForCI polls with
+	now   func() time.Time
+	sleep func(time.Duration)
 }
 
+// pollInterval is how often WaitForCI checks the PR's status
+const pollInterval = 30 * time.Second
+
 func NewHandler(repo, workDir string, provider llm.Provider, model string) *Handler {
 	return &Handler{
 		repo:     repo,
 		workDir:  workDir,
 		provider: provider,
 		model:    model,
+		now:      time.Now,
+		sleep:    time.Sleep,
 	}
 }
 
+// SetChecks makes the handler read CI status and logs from checks instead of
+// GitHub.
+func (h *Handler) SetChecks(checks Checks) {
+	h.checks = checks
+}
+
+func ghCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("gh", args...)
+	cmd.Env = httpclient.CommandEnv()
+	return cmd
+}
+
 func (h *Handler) CheckPRStatus(prNumber int) ([]CIStatus, error) {
-	cmd := exec.Command("gh", "pr", "checks", strconv.Itoa(prNumber),
+	if h.checks != nil {
+		return h.checks.CheckPRStatus(prNumber)
+	}
+	cmd := ghCommand("pr", "checks", strconv.Itoa(prNumber),
 		"--repo", h.repo)
 	cmd.Dir = h.workDir
 
 	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if err != nil && !checksReported(err, output) {
 		return nil, fmt.Errorf("failed to check PR status: %w\nOutput: %s", err, string(output))
 	}
 
@@ -61,32 +97,59 @@ func (h *Handler) CheckPRStatus(prNumber int) ([]CIStatus, error) {
 			continue
 		}
 
-		parts := strings.Fields(line)
-		if len(parts) < 2 {
-			continue
-		}
-
-		status := CIStatus{
-			State: parts[0],
-			Name:  strings.Join(parts[1:], " "),
-		}
-
-		if strings.Contains(strings.ToLower(status.State), "fail") ||
-			strings.Contains(strings.ToLower(status.State), "error") {
-			status.Conclusion = "failure"
-		} else if strings.Contains(strings.ToLower(status.State), "success") ||
-			strings.Contains(strings.ToLower(status.State), "pass") {
-			status.Conclusion = "success"
+		var status CIStatus
+		if cols := strings.Split(line, "\t"); len(cols) >= 2 {
+			// Non-TTY output: name, state, elapsed, link
+			status = CIStatus{Name: cols[0], State: cols[1]}
+			if len(cols) >= 4 {
+				status.URL = cols[3]
+			}
 		} else {
-			status.Conclusion = "pending"
+			parts := strings.Fields(line)
+			if len(parts) < 2 {
+				continue
+			}
+			status = CIStatus{
+				State: parts[0],
+				Name:  strings.Join(parts[1:], " "),
+			}
 		}
 
+		status.Conclusion = conclusionOf(status.State)
 		statuses = append(statuses, status)
 	}
 
 	return statuses, nil
 }
 
+// conclusionOf maps a check's state to success, failure or pending. Skipped
+// and neutral checks do not block a PR, so they count as success; cancelled
+// and timed out ones never finish, so they count as failure.
+func conclusionOf(state string) string {
+	state = strings.ToLower(state)
+	for _, s := range []string{"fail", "error", "cancel", "timed_out", "timed out", "action_required"} {
+		if strings.Contains(state, s) {
+			return "failure"
+		}
+	}
+	for _, s := range []string{"success", "pass", "skip", "neutral"} {
+		if strings.Contains(state, s) {
+			return "success"
+		}
+	}
+	return "pending"
+}
+
+// checksReported reports whether gh pr checks exited non-zero only because
+// checks are failing (1) or still pending (8), with the table still printed.
+func checksReported(err error, output []byte) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || len(strings.TrimSpace(string(output))) == 0 {
+		return false
+	}
+	return exitErr.ExitCode() == 1 || exitErr.ExitCode() == 8
+}
+
 func (h *Handler) GetFailedChecks(prNumber int) ([]CIStatus, error) {
 	statuses, err := h.CheckPRStatus(prNumber)
 	if err != nil {
@@ -104,7 +167,10 @@ func (h *Handler) GetFailedChecks(prNumber int) ([]CIStatus, error) {
 }
 
 func (h *Handler) FetchCILogs(prNumber int, checkName string) (string, error) {
-	cmd := exec.Command("gh", "run", "view",
+	if h.checks != nil {
+		return h.checks.FetchCILogs(prNumber, checkName)
+	}
+	cmd := ghCommand("run", "view",
 		"--repo", h.repo,
 		"--log")
 	cmd.Dir = h.workDir
@@ -199,10 +265,13 @@ func (h *Handler) ParseCIFailure(log string) *CIFailure {
 	return failure
 }
 
+// WaitForCI polls the PR's checks every pollInterval until they all
+// complete or maxWaitMinutes pass.
 func (h *Handler) WaitForCI(prNumber int, maxWaitMinutes int) error {
 	fmt.Printf("⏳ Waiting for CI checks (max %d minutes)...\n", maxWaitMinutes)
 
-	for i := 0; i < maxWaitMinutes; i++ {
+	deadline := h.now().Add(time.Duration(maxWaitMinutes) * time.Minute)
+	for {
 		statuses, err := h.CheckPRStatus(prNumber)
 		if err != nil {
 			return err
@@ -228,9 +297,12 @@ func (h *Handler) WaitForCI(prNumber int, maxWaitMinutes int) error {
 			return nil
 		}
 
-		if i < maxWaitMinutes-1 {
-			fmt.Print(".")
+		left := deadline.Sub(h.now())
+		if left <= 0 {
+			break
 		}
+		fmt.Print(".")
+		h.sleep(min(pollInterval, left))
 	}
 
 	return fmt.Errorf("CI checks timed out after %d minutes", maxWaitMinutes)
@@ -0,0 +1,97 @@
+package ci
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// pendingChecks reports pending checks until polled done times
+type pendingChecks struct {
+	polls, done int
+	conclusion  string
+}
+
+func (c *pendingChecks) CheckPRStatus(prNumber int) ([]CIStatus, error) {
+	c.polls++
+	if c.polls < c.done {
+		return []CIStatus{{Name: "test", Conclusion: "pending"}}, nil
+	}
+	return []CIStatus{{Name: "test", Conclusion: c.conclusion}}, nil
+}
+
+func (c *pendingChecks) FetchCILogs(prNumber int, checkName string) (string, error) {
+	return "", nil
+}
+
+// fakeClock advances only when slept on
+type fakeClock struct {
+	t     time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.slept = append(c.slept, d)
+	c.t = c.t.Add(d)
+}
+
+func newTestHandler(checks Checks) (*Handler, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	h := NewHandler("o/r", "", nil, "")
+	h.SetChecks(checks)
+	h.now, h.sleep = clock.now, clock.sleep
+	return h, clock
+}
+
+func TestWaitForCI(t *testing.T) {
+	checks := &pendingChecks{done: 4, conclusion: "success"}
+	h, clock := newTestHandler(checks)
+	if err := h.WaitForCI(7, 10); err != nil {
+		t.Fatal(err)
+	}
+	if checks.polls != 4 || len(clock.slept) != 3 || clock.slept[0] != pollInterval {
+		t.Errorf("expected 4 polls %v apart, got %d polls after sleeping %v", pollInterval, checks.polls, clock.slept)
+	}
+
+	checks = &pendingChecks{done: 2, conclusion: "failure"}
+	h, _ = newTestHandler(checks)
+	if err := h.WaitForCI(7, 10); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("expected the failure reported, got %v", err)
+	}
+}
+
+func TestWaitForCITimesOut(t *testing.T) {
+	checks := &pendingChecks{done: 1000}
+	h, clock := newTestHandler(checks)
+	start := clock.t
+	err := h.WaitForCI(7, 2)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 2 minutes") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if waited := clock.t.Sub(start); waited != 2*time.Minute {
+		t.Errorf("expected to wait the full 2 minutes, waited %v", waited)
+	}
+	if checks.polls != 5 {
+		t.Errorf("expected a poll every %v and one at the deadline, got %d", pollInterval, checks.polls)
+	}
+}
+
+func TestConclusionOf(t *testing.T) {
+	for state, want := range map[string]string{
+		"pass":      "success",
+		"skipping":  "success",
+		"NEUTRAL":   "success",
+		"fail":      "failure",
+		"cancel":    "failure",
+		"cancelled": "failure",
+		"timed_out": "failure",
+		"pending":   "pending",
+		"queued":    "pending",
+	} {
+		if got := conclusionOf(state); got != want {
+			t.Errorf("conclusionOf(%q) = %q, want %q", state, got, want)
+		}
+	}
+}
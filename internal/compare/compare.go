@@ -0,0 +1,202 @@
+// Package compare sends the same prompt, or the same small edit of a file,
+// to several models at once and collects their answers with latency, tokens
+// and cost, so they can be judged side by side.
+package compare
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"gptcode/internal/config"
+	"gptcode/internal/feedback"
+	"gptcode/internal/llm"
+)
+
+// Target is a model on a configured backend
+type Target struct {
+	Backend string
+	Model   string
+}
+
+func (t Target) String() string {
+	return t.Backend + "/" + t.Model
+}
+
+// Task is what every model is asked. With File set the models edit a
+// temporary copy of it following Prompt, and their answers are diffs.
+type Task struct {
+	Prompt string
+	File   string
+}
+
+// Result is one model's answer
+type Result struct {
+	Target Target
+	Text   string
+	// Diff is the change made to the task's file, for edit tasks
+	Diff string
+	// Path is the model's edited copy of the file, for edit tasks
+	Path      string
+	Latency   time.Duration
+	TokensIn  int
+	TokensOut int
+	Cost      float64
+	Error     error
+}
+
+// ParseTargets reads a comma-separated list of models. An entry is
+// backend/model when it starts with the name of a configured backend, and
+// otherwise a model of the default backend, so OpenRouter ids such as
+// anthropic/claude-sonnet-4 still work.
+func ParseTargets(spec string, setup *config.Setup) ([]Target, error) {
+	var targets []Target
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		t := Target{Backend: setup.Defaults.Backend, Model: entry}
+		if backend, model, ok := strings.Cut(entry, "/"); ok {
+			if _, configured := setup.Backend[backend]; configured {
+				t = Target{Backend: backend, Model: model}
+			}
+		}
+		if _, ok := setup.Backend[t.Backend]; !ok {
+			return nil, fmt.Errorf("backend %q for %s is not configured", t.Backend, entry)
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) < 2 {
+		return nil, fmt.Errorf("at least two models are needed to compare, got %q", spec)
+	}
+	return targets, nil
+}
+
+// Run asks every target in parallel and returns the results in the order
+// of targets. Edit tasks work on copies under dir, one per target.
+func Run(ctx context.Context, setup *config.Setup, targets []Target, task Task, dir string) []Result {
+	var original []byte
+	if task.File != "" {
+		var err error
+		if original, err = os.ReadFile(task.File); err != nil {
+			results := make([]Result, len(targets))
+			for i, t := range targets {
+				results[i] = Result{Target: t, Error: err}
+			}
+			return results
+		}
+	}
+
+	results := make([]Result, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			provider := llm.NewForBackend(t.Backend, setup.Backend[t.Backend])
+			results[i] = ask(ctx, provider, t, task, original, filepath.Join(dir, fmt.Sprintf("%d", i+1)))
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func ask(ctx context.Context, provider llm.Provider, t Target, task Task, original []byte, dir string) Result {
+	result := Result{Target: t}
+	req := llm.ChatRequest{
+		SystemPrompt: "You are a helpful coding assistant.",
+		UserPrompt:   task.Prompt,
+		Model:        t.Model,
+	}
+	if task.File != "" {
+		req.SystemPrompt = "You are a coding assistant that edits files. Return ONLY the complete updated file in one code block, no explanations."
+		req.UserPrompt = fmt.Sprintf("%s\n\nFile %s:\n```\n%s\n```", task.Prompt, filepath.Base(task.File), original)
+	}
+
+	start := time.Now()
+	resp, err := provider.Chat(ctx, req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	result.Text = resp.Text
+	result.Cost = resp.Cost
+	if resp.TokenUsage != nil {
+		result.TokensIn = resp.TokenUsage.PromptTokens
+		result.TokensOut = resp.TokenUsage.CompletionTokens
+	}
+	if task.File == "" {
+		return result
+	}
+
+	edited := llm.ExtractCode(resp.Text) + "\n"
+	result.Path = filepath.Join(dir, filepath.Base(task.File))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		result.Error = err
+		return result
+	}
+	if err := os.WriteFile(result.Path, []byte(edited), 0644); err != nil {
+		result.Error = err
+		return result
+	}
+	result.Diff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(original)),
+		B:        difflib.SplitLines(edited),
+		FromFile: "a/" + filepath.Base(task.File),
+		ToFile:   "b/" + filepath.Base(task.File),
+		Context:  3,
+	})
+	return result
+}
+
+// RecordPreference records the preferred result as good feedback and the
+// others as bad, so model selection learns from the comparison.
+func RecordPreference(task Task, results []Result, preferred int) error {
+	agent := "query"
+	if task.File != "" {
+		agent = "editor"
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Target.String())
+	}
+	for i, r := range results {
+		if r.Error != nil {
+			continue
+		}
+		event := feedback.Event{
+			Sentiment: feedback.SentimentBad,
+			Backend:   r.Target.Backend,
+			Model:     r.Target.Model,
+			Agent:     agent,
+			Task:      task.Prompt,
+			Source:    "compare",
+			Kind:      "preference",
+			Metadata: map[string]string{
+				"compared":   strings.Join(names, ","),
+				"preferred":  results[preferred].Target.String(),
+				"latency_ms": fmt.Sprintf("%d", r.Latency.Milliseconds()),
+				"cost":       fmt.Sprintf("%.6f", r.Cost),
+			},
+		}
+		if i == preferred {
+			event.Sentiment = feedback.SentimentGood
+			event.CorrectResponse = r.Text
+		}
+		if task.File != "" {
+			event.Files = []string{task.File}
+		}
+		if err := feedback.Record(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/config"
+	"gptcode/internal/feedback"
+)
+
+func TestParseTargets(t *testing.T) {
+	setup := &config.Setup{Backend: map[string]config.BackendConfig{"groq": {}, "openrouter": {}}}
+	setup.Defaults.Backend = "openrouter"
+
+	targets, err := ParseTargets("groq/llama-3.3-70b, anthropic/claude-sonnet-4,gpt-4o", setup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{{"groq", "llama-3.3-70b"}, {"openrouter", "anthropic/claude-sonnet-4"}, {"openrouter", "gpt-4o"}}
+	if fmt.Sprint(targets) != fmt.Sprint(want) {
+		t.Errorf("targets = %v, want %v", targets, want)
+	}
+	if _, err := ParseTargets("gpt-4o", setup); err == nil {
+		t.Error("expected an error for a single model")
+	}
+}
+
+func TestRunEditTask(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Model string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Model == "broken" {
+			http.Error(w, "no such model", http.StatusNotFound)
+			return
+		}
+		answer := "```go\npackage calc\n\nfunc Add(a, b int) int { return a + b }\n```"
+		if req.Model == "lazy" {
+			answer = "package calc\n\nfunc Add(a, b int) int { return a - b }"
+		}
+		fmt.Fprintf(w, `{"message":{"content":%q},"done":true,"prompt_eval_count":20,"eval_count":10}`, answer)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "calc.go")
+	if err := os.WriteFile(file, []byte("package calc\n\nfunc Add(a, b int) int { return a - b }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setup := &config.Setup{Backend: map[string]config.BackendConfig{"local": {Type: "ollama", BaseURL: server.URL}}}
+	targets := []Target{{"local", "good"}, {"local", "lazy"}, {"local", "broken"}}
+	task := Task{Prompt: "Fix Add", File: file}
+
+	results := Run(context.Background(), setup, targets, task, t.TempDir())
+	if results[0].Error != nil || !strings.Contains(results[0].Diff, "+func Add(a, b int) int { return a + b }") {
+		t.Errorf("unexpected first result: %+v", results[0])
+	}
+	if results[0].TokensIn != 20 || results[0].TokensOut != 10 {
+		t.Errorf("unexpected tokens: %d in, %d out", results[0].TokensIn, results[0].TokensOut)
+	}
+	if results[1].Error != nil || results[1].Diff != "" {
+		t.Errorf("expected no changes from the second model, got %q (%v)", results[1].Diff, results[1].Error)
+	}
+	if results[2].Error == nil {
+		t.Error("expected the third model to fail")
+	}
+	if edited, _ := os.ReadFile(results[0].Path); !strings.Contains(string(edited), "a + b") {
+		t.Errorf("edited copy is %q", edited)
+	}
+	if original, _ := os.ReadFile(file); strings.Contains(string(original), "a + b") {
+		t.Error("the original file was modified")
+	}
+
+	if err := RecordPreference(task, results, 0); err != nil {
+		t.Fatal(err)
+	}
+	events, err := feedback.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Sentiment != feedback.SentimentGood || events[0].Model != "good" ||
+		events[1].Sentiment != feedback.SentimentBad || events[1].Agent != "editor" {
+		t.Errorf("unexpected feedback: %+v", events)
+	}
+}
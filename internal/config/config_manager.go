@@ -68,6 +68,16 @@ func getNestedValue(setup *Setup, key string) (interface{}, error) {
 			return setup.Defaults.MaxCostPerTask, nil
 		case "monthly_budget":
 			return setup.Defaults.MonthlyBudget, nil
+		case "max_diff_lines":
+			return setup.Defaults.MaxDiffLines, nil
+		case "max_diff_files":
+			return setup.Defaults.MaxDiffFiles, nil
+		case "test_guard":
+			return setup.Defaults.TestGuard, nil
+		case "secret_scan":
+			return setup.Defaults.SecretScan, nil
+		case "learn_memory":
+			return setup.Defaults.LearnMemory, nil
 		default:
 			return nil, fmt.Errorf("unknown defaults field: %s", parts[1])
 		}
@@ -101,6 +111,48 @@ func getNestedValue(setup *Setup, key string) (interface{}, error) {
 			return nil, fmt.Errorf("unknown backend field: %s", parts[2])
 		}
 
+	case "context":
+		if len(parts) == 3 && parts[1] == "embeddings" {
+			switch parts[2] {
+			case "backend":
+				return setup.Context.Embeddings.Backend, nil
+			case "model":
+				return setup.Context.Embeddings.Model, nil
+			case "dimensions":
+				return setup.Context.Embeddings.Dimensions, nil
+			case "batch_size":
+				return setup.Context.Embeddings.BatchSize, nil
+			}
+		}
+		if len(parts) != 3 || parts[1] != "weights" {
+			return nil, fmt.Errorf("context key requires: context.weights.<signal> or context.embeddings.<backend|model|dimensions|batch_size>")
+		}
+		return setup.Context.Weights[parts[2]], nil
+
+	case "tools":
+		switch {
+		case len(parts) == 2 && parts[1] == "sandbox":
+			return setup.Tools.Sandbox, nil
+		case len(parts) == 4 && parts[1] == "docker" && parts[2] == "images":
+			return setup.Tools.Docker.Images[parts[3]], nil
+		case len(parts) == 3 && parts[1] == "docker":
+			switch parts[2] {
+			case "network":
+				return setup.Tools.Docker.Network, nil
+			case "memory":
+				return setup.Tools.Docker.Memory, nil
+			case "cpus":
+				return setup.Tools.Docker.CPUs, nil
+			}
+		}
+		return nil, fmt.Errorf("tools key requires: tools.sandbox, tools.docker.<network|memory|cpus> or tools.docker.images.<language>")
+
+	case "run":
+		if len(parts) != 2 || parts[1] != "ssh_hosts" {
+			return nil, fmt.Errorf("run key requires: run.ssh_hosts")
+		}
+		return strings.Join(setup.Run.SSHHosts, ","), nil
+
 	default:
 		return nil, fmt.Errorf("unknown config section: %s", parts[0])
 	}
@@ -183,6 +235,42 @@ func setNestedValue(setup *Setup, key, value string) error {
 				return fmt.Errorf("monthly_budget must be non-negative")
 			}
 			setup.Defaults.MonthlyBudget = f
+		case "max_diff_lines", "max_diff_files":
+			var i int
+			if _, err := fmt.Sscan(value, &i); err != nil {
+				return fmt.Errorf("invalid int value for %s: %s", parts[1], value)
+			}
+			if i < 0 {
+				return fmt.Errorf("%s must be non-negative", parts[1])
+			}
+			if parts[1] == "max_diff_lines" {
+				setup.Defaults.MaxDiffLines = i
+			} else {
+				setup.Defaults.MaxDiffFiles = i
+			}
+		case "test_guard":
+			switch value {
+			case "fail", "warn", "off":
+				setup.Defaults.TestGuard = value
+			default:
+				return fmt.Errorf("test_guard must be fail, warn or off")
+			}
+		case "secret_scan":
+			switch value {
+			case "fail", "warn", "off":
+				setup.Defaults.SecretScan = value
+			default:
+				return fmt.Errorf("secret_scan must be fail, warn or off")
+			}
+		case "learn_memory":
+			switch value {
+			case "true", "1", "yes":
+				setup.Defaults.LearnMemory = true
+			case "false", "0", "no":
+				setup.Defaults.LearnMemory = false
+			default:
+				return fmt.Errorf("invalid boolean value for learn_memory: %s", value)
+			}
 		default:
 			return fmt.Errorf("unknown defaults field: %s", parts[1])
 		}
@@ -210,6 +298,86 @@ func setNestedValue(setup *Setup, key, value string) error {
 
 		setup.Backend[backendName] = backend
 
+	case "context":
+		if len(parts) == 3 && parts[1] == "embeddings" {
+			switch parts[2] {
+			case "backend":
+				setup.Context.Embeddings.Backend = value
+				return nil
+			case "model":
+				setup.Context.Embeddings.Model = value
+				return nil
+			case "dimensions", "batch_size":
+				var n int
+				if _, err := fmt.Sscan(value, &n); err != nil || n < 0 {
+					return fmt.Errorf("invalid %s: %s", parts[2], value)
+				}
+				if parts[2] == "dimensions" {
+					setup.Context.Embeddings.Dimensions = n
+				} else {
+					setup.Context.Embeddings.BatchSize = n
+				}
+				return nil
+			}
+		}
+		if len(parts) != 3 || parts[1] != "weights" {
+			return fmt.Errorf("context key requires: context.weights.<signal> or context.embeddings.<backend|model|dimensions|batch_size>")
+		}
+		known := false
+		for _, signal := range ContextSignals {
+			known = known || signal == parts[2]
+		}
+		if !known {
+			return fmt.Errorf("unknown context signal %s (use %s)", parts[2], strings.Join(ContextSignals, ", "))
+		}
+		var w float64
+		if _, err := fmt.Sscan(value, &w); err != nil || w < 0 {
+			return fmt.Errorf("invalid weight for %s: %s", parts[2], value)
+		}
+		if setup.Context.Weights == nil {
+			setup.Context.Weights = map[string]float64{}
+		}
+		setup.Context.Weights[parts[2]] = w
+
+	case "tools":
+		switch {
+		case len(parts) == 2 && parts[1] == "sandbox":
+			switch value {
+			case "docker", "none", "":
+				if value == "none" {
+					value = ""
+				}
+				setup.Tools.Sandbox = value
+			default:
+				return fmt.Errorf("tools.sandbox must be docker or none")
+			}
+		case len(parts) == 4 && parts[1] == "docker" && parts[2] == "images":
+			if setup.Tools.Docker.Images == nil {
+				setup.Tools.Docker.Images = map[string]string{}
+			}
+			setup.Tools.Docker.Images[parts[3]] = value
+		case len(parts) == 3 && parts[1] == "docker" && parts[2] == "network":
+			setup.Tools.Docker.Network = value
+		case len(parts) == 3 && parts[1] == "docker" && parts[2] == "memory":
+			setup.Tools.Docker.Memory = value
+		case len(parts) == 3 && parts[1] == "docker" && parts[2] == "cpus":
+			setup.Tools.Docker.CPUs = value
+		default:
+			return fmt.Errorf("tools key requires: tools.sandbox, tools.docker.<network|memory|cpus> or tools.docker.images.<language>")
+		}
+
+	case "run":
+		if len(parts) != 2 || parts[1] != "ssh_hosts" {
+			return fmt.Errorf("run key requires: run.ssh_hosts")
+		}
+		// A comma-separated list; empty allows no host
+		setup.Run.SSHHosts = nil
+		for _, host := range strings.Split(value, ",") {
+			if host = strings.TrimSpace(host); host != "" {
+				setup.Run.SSHHosts = append(setup.Run.SSHHosts, host)
+			}
+		}
+
 	default:
 		return fmt.Errorf("unknown config section: %s", parts[0])
 	}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SetupMigration upgrades setup.yaml from version To-1 to version To. Apply
+// edits the document in place and describes each change it made.
+type SetupMigration struct {
+	To          int
+	Description string
+	Apply       func(doc *yaml.Node) []string
+}
+
+// setupMigrations are applied in order to configs older than their To
+// version. Files without a version key are version 1.
+var setupMigrations = []SetupMigration{
+	{
+		To:          2,
+		Description: "fold the implicit \"default\" profile into backend agent_models and split <backend>.<profile> defaults",
+		Apply:       migrateDefaultProfiles,
+	},
+}
+
+// CurrentSetupVersion is the schema version written by this build.
+var CurrentSetupVersion = setupMigrations[len(setupMigrations)-1].To
+
+// MigrationResult describes a migration of setup.yaml
+type MigrationResult struct {
+	FromVersion int
+	ToVersion   int
+	Changes     []string
+	Before      []byte
+	After       []byte
+	BackupPath  string
+}
+
+// Migrated reports whether the file needed upgrading.
+func (r *MigrationResult) Migrated() bool {
+	return r.FromVersion < r.ToVersion
+}
+
+// MigrateSetupData upgrades setup.yaml content to CurrentSetupVersion,
+// keeping comments and key order.
+func MigrateSetupData(data []byte) (*MigrationResult, error) {
+	result := &MigrationResult{Before: data, After: data}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		result.FromVersion, result.ToVersion = CurrentSetupVersion, CurrentSetupVersion
+		return result, nil
+	}
+	root := doc.Content[0]
+
+	version := 1
+	if v := mappingValue(root, "version"); v != nil {
+		n, err := strconv.Atoi(v.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid setup.yaml version %q", v.Value)
+		}
+		version = n
+	}
+	result.FromVersion, result.ToVersion = version, version
+	if version > CurrentSetupVersion {
+		return nil, fmt.Errorf("setup.yaml version %d is newer than this gptcode supports (%d); upgrade gptcode", version, CurrentSetupVersion)
+	}
+	if version == CurrentSetupVersion {
+		return result, nil
+	}
+
+	for _, m := range setupMigrations {
+		if m.To <= version {
+			continue
+		}
+		for _, change := range m.Apply(root) {
+			result.Changes = append(result.Changes, fmt.Sprintf("v%d: %s", m.To, change))
+		}
+	}
+	setMappingValue(root, "version", strconv.Itoa(CurrentSetupVersion), true)
+	result.ToVersion = CurrentSetupVersion
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	result.After = buf.Bytes()
+	return result, nil
+}
+
+// MigrateSetupFile upgrades the setup.yaml at path. Unless dryRun is set,
+// the original is copied to <path>.v<version>.bak before the new version is
+// written.
+func MigrateSetupFile(path string, dryRun bool) (*MigrationResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result, err := MigrateSetupData(data)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun || !result.Migrated() {
+		return result, nil
+	}
+
+	result.BackupPath = fmt.Sprintf("%s.v%d.bak", path, result.FromVersion)
+	if err := os.WriteFile(result.BackupPath, data, 0644); err != nil {
+		return nil, fmt.Errorf("failed to back up setup.yaml: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, result.After, 0644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// migrateSetupOnLoad upgrades an outdated setup.yaml before it is read.
+// Failures are reported but never block loading.
+func migrateSetupOnLoad(path string) {
+	result, err := MigrateSetupFile(path, false)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "[WARN] setup.yaml migration skipped: %v\n", err)
+		}
+		return
+	}
+	if result.Migrated() {
+		fmt.Fprintf(os.Stderr, "Migrated setup.yaml from v%d to v%d (backup: %s)\n",
+			result.FromVersion, result.ToVersion, result.BackupPath)
+	}
+}
+
+// migrateDefaultProfiles handles two v1 patterns that later versions read
+// differently: a profile literally named "default" (the backend agent_models
+// are the default profile), and defaults.profile written as
+// <backend>.<profile>.
+func migrateDefaultProfiles(root *yaml.Node) []string {
+	var changes []string
+
+	if backends := mappingValue(root, "backend"); backends != nil && backends.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(backends.Content); i += 2 {
+			name, backend := backends.Content[i].Value, backends.Content[i+1]
+			profiles := mappingValue(backend, "profiles")
+			if profiles == nil || profiles.Kind != yaml.MappingNode {
+				continue
+			}
+			def := mappingValue(profiles, "default")
+			if def == nil {
+				continue
+			}
+			if models := mappingValue(def, "agent_models"); models != nil && models.Kind == yaml.MappingNode {
+				target := mappingValue(backend, "agent_models")
+				if target == nil || target.Kind != yaml.MappingNode {
+					target = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+					setMappingNode(backend, "agent_models", target)
+				}
+				// Keys already set at the backend level are the ones in effect today
+				for j := 0; j+1 < len(models.Content); j += 2 {
+					if mappingValue(target, models.Content[j].Value) == nil {
+						setMappingValue(target, models.Content[j].Value, models.Content[j+1].Value, false)
+					}
+				}
+			}
+			deleteMappingKey(profiles, "default")
+			if len(profiles.Content) == 0 {
+				deleteMappingKey(backend, "profiles")
+			}
+			changes = append(changes, fmt.Sprintf("backend.%s.profiles.default moved to backend.%s.agent_models", name, name))
+		}
+	}
+
+	if defaults := mappingValue(root, "defaults"); defaults != nil && defaults.Kind == yaml.MappingNode {
+		if profile := mappingValue(defaults, "profile"); profile != nil {
+			if backend, name, ok := strings.Cut(profile.Value, "."); ok && backend != "" && name != "" {
+				setMappingValue(defaults, "backend", backend, false)
+				profile.Value = name
+				changes = append(changes, fmt.Sprintf("defaults.profile %s.%s split into defaults.backend and defaults.profile", backend, name))
+			}
+		}
+	}
+	return changes
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingNode(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// setMappingValue sets a scalar; first puts a new key at the top of the
+// mapping instead of the end.
+func setMappingValue(node *yaml.Node, key, value string, first bool) {
+	if existing := mappingValue(node, key); existing != nil {
+		existing.Kind, existing.Value, existing.Tag, existing.Content = yaml.ScalarNode, value, "", nil
+		return
+	}
+	pair := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+		{Kind: yaml.ScalarNode, Value: value},
+	}
+	if first {
+		// Keep a leading file comment at the top of the file
+		if len(node.Content) > 0 {
+			pair[0].HeadComment, node.Content[0].HeadComment = node.Content[0].HeadComment, ""
+		}
+		node.Content = append(pair, node.Content...)
+		return
+	}
+	node.Content = append(node.Content, pair...)
+}
+
+func deleteMappingKey(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
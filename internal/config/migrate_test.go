@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const v1Setup = `# my setup
+defaults:
+  backend: groq
+  profile: openrouter.free
+backend:
+  groq:
+    type: openai
+    agent_models:
+      editor: kimi
+    profiles:
+      default:
+        agent_models:
+          editor: llama
+          query: qwen
+      speed:
+        agent_models:
+          query: llama-8b
+`
+
+func TestMigrateSetupData(t *testing.T) {
+	result, err := MigrateSetupData([]byte(v1Setup))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Migrated() || result.FromVersion != 1 || result.ToVersion != CurrentSetupVersion {
+		t.Fatalf("unexpected versions %d -> %d", result.FromVersion, result.ToVersion)
+	}
+	if len(result.Changes) != 2 {
+		t.Errorf("expected 2 changes, got %v", result.Changes)
+	}
+	if !strings.Contains(string(result.After), "# my setup") {
+		t.Error("comments should be preserved")
+	}
+
+	var s Setup
+	if err := yaml.Unmarshal(result.After, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != CurrentSetupVersion {
+		t.Errorf("version = %d", s.Version)
+	}
+	if s.Defaults.Backend != "openrouter" || s.Defaults.Profile != "free" {
+		t.Errorf("defaults = %s/%s", s.Defaults.Backend, s.Defaults.Profile)
+	}
+	groq := s.Backend["groq"]
+	// The backend-level value was the one in effect and must win
+	if groq.AgentModels.Editor != "kimi" || groq.AgentModels.Query != "qwen" {
+		t.Errorf("agent_models = %+v", groq.AgentModels)
+	}
+	if _, ok := groq.Profiles["default"]; ok {
+		t.Error("default profile should be removed")
+	}
+	if groq.Profiles["speed"].AgentModels.Query != "llama-8b" {
+		t.Error("other profiles should be kept")
+	}
+
+	again, err := MigrateSetupData(result.After)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Migrated() {
+		t.Error("migrating a current file should be a no-op")
+	}
+}
+
+func TestMigrateSetupDataRejectsNewerVersion(t *testing.T) {
+	if _, err := MigrateSetupData([]byte("version: 99\n")); err == nil {
+		t.Error("expected error for a newer schema version")
+	}
+}
+
+func TestMigrateSetupFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "setup.yaml")
+	if err := os.WriteFile(path, []byte(v1Setup), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateSetupFile(path, true); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != v1Setup {
+		t.Fatal("dry run must not modify the file")
+	}
+
+	result, err := MigrateSetupFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup, _ := os.ReadFile(result.BackupPath); string(backup) != v1Setup {
+		t.Error("backup should contain the original file")
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "version: 2") {
+		t.Errorf("migrated file missing version:\n%s", data)
+	}
+}
@@ -41,6 +41,7 @@ type ModelInfo struct {
 
 type ModelFeedback struct {
 	ModelID    string     `json:"model_id"`
+	Backend    string     `json:"backend,omitempty"`
 	Action     ActionType `json:"action"`
 	Language   string     `json:"language"`
 	Success    bool       `json:"success"`
@@ -61,6 +62,7 @@ type ModelSelector struct {
 	usage       map[string]map[string]ModelUsage
 	setup       *Setup
 	recommender *RecommenderModel
+	onDemotion  func(Demotion)
 }
 
 func NewModelSelector(setup *Setup) (*ModelSelector, error) {
@@ -215,7 +217,12 @@ func (ms *ModelSelector) loadFeedback() error {
 
 		for _, event := range rawEvents {
 			fb := ms.convertFeedbackEvent(event)
-			if fb.ModelID != "" && fb.Action != "" {
+			if fb.ModelID == "" || fb.Action == "" {
+				continue
+			}
+			// Merged duplicates stand for several events
+			count, _ := event["count"].(float64)
+			for i := 0; i < max(int(count), 1); i++ {
 				ms.feedback = append(ms.feedback, fb)
 			}
 		}
@@ -230,6 +237,9 @@ func (ms *ModelSelector) convertFeedbackEvent(event map[string]interface{}) Mode
 	if val, ok := event["model"].(string); ok {
 		fb.ModelID = val
 	}
+	if val, ok := event["backend"].(string); ok {
+		fb.Backend = val
+	}
 
 	if agent, ok := event["agent"].(string); ok {
 		switch strings.ToLower(agent) {
@@ -354,11 +364,12 @@ func (ms *ModelSelector) SelectModel(action ActionType, language string, complex
 	mode := ms.setup.Defaults.Mode
 	defaultBackend := ms.setup.Defaults.Backend
 
-	type scoredModel struct {
-		backend string
-		model   string
-		score   float64
+	// Explicit --backend/--model flags bypass scoring entirely.
+	pinned := CurrentOverrides()
+	if pinned.Model != "" {
+		return defaultBackend, pinned.Model, nil
 	}
+
 	var scored []scoredModel
 
 	if os.Getenv("GPTCODE_DEBUG") == "1" {
@@ -368,10 +379,13 @@ func (ms *ModelSelector) SelectModel(action ActionType, language string, complex
 	}
 
 	for backend, models := range ms.catalog {
-		if mode == "local" && backend != "ollama" {
+		if pinned.Backend != "" {
+			if backend != pinned.Backend {
+				continue
+			}
+		} else if mode == "local" && backend != "ollama" {
 			continue
-		}
-		if mode == "cloud" && backend == "ollama" {
+		} else if mode == "cloud" && backend == "ollama" {
 			continue
 		}
 
@@ -411,6 +425,15 @@ func (ms *ModelSelector) SelectModel(action ActionType, language string, complex
 		}
 	}
 
+	if d := ms.demote(scored, action); d != nil {
+		if os.Getenv("GPTCODE_DEBUG") == "1" {
+			fmt.Fprintf(os.Stderr, "[MODEL_SELECTOR] DEMOTION: %s\n", d)
+		}
+		if ms.onDemotion != nil {
+			ms.onDemotion(*d)
+		}
+	}
+
 	// EXPLORATION: 10% chance to pick from top 5 models to try new ones
 	best := scored[0]
 	if len(scored) > 1 && rand.Float64() < 0.10 {
@@ -434,6 +457,91 @@ func (ms *ModelSelector) SelectModel(action ActionType, language string, complex
 	return best.backend, best.model, nil
 }
 
+type scoredModel struct {
+	backend string
+	model   string
+	score   float64
+}
+
+// Demotion is a model passed over for an action because of its recent bad
+// feedback, and the model promoted in its place
+type Demotion struct {
+	Action  ActionType
+	Backend string
+	Model   string
+	// Bad of the Recent latest feedback events for the model were bad
+	Bad    int
+	Recent int
+	// PromotedBackend and PromotedModel were selected instead
+	PromotedBackend string
+	PromotedModel   string
+}
+
+func (d Demotion) String() string {
+	return fmt.Sprintf("%s/%s demoted for %s (%d of its last %d feedback events were bad), promoted %s/%s",
+		d.Backend, d.Model, d.Action, d.Bad, d.Recent, d.PromotedBackend, d.PromotedModel)
+}
+
+// OnDemotion calls fn whenever SelectModel passes over the best scored
+// model because of repeated bad feedback
+func (ms *ModelSelector) OnDemotion(fn func(Demotion)) {
+	ms.onDemotion = fn
+}
+
+// badFeedback counts the bad events among the latest feedback for a model
+// and action, up to window events. Feedback without a backend counts for
+// the model on every backend.
+func (ms *ModelSelector) badFeedback(backend, model string, action ActionType, window int) (bad, recent int) {
+	for i := len(ms.feedback) - 1; i >= 0 && recent < window; i-- {
+		fb := ms.feedback[i]
+		if fb.ModelID != model || fb.Action != action || (fb.Backend != "" && fb.Backend != backend) {
+			continue
+		}
+		recent++
+		if !fb.Success {
+			bad++
+		}
+	}
+	return bad, recent
+}
+
+// demote moves the models whose recent feedback for action is mostly bad,
+// per setup.yaml's selection.demotion, after the others in the ranked
+// scored, promoting the best of the rest. It returns the demotion of the
+// model that was ranked first, if it lost its place.
+func (ms *ModelSelector) demote(scored []scoredModel, action ActionType) *Demotion {
+	var cfg DemotionConfig
+	if ms.setup != nil {
+		cfg = ms.setup.Selection.Demotion
+	}
+	if cfg.Disabled || len(scored) < 2 {
+		return nil
+	}
+	cfg = cfg.WithDefaults()
+
+	var kept, demoted []scoredModel
+	var first *Demotion
+	for i, s := range scored {
+		bad, recent := ms.badFeedback(s.backend, s.model, action, cfg.Window)
+		if bad >= cfg.MinBad && float64(bad) >= cfg.BadRatio*float64(recent) {
+			demoted = append(demoted, s)
+			if i == 0 {
+				first = &Demotion{Action: action, Backend: s.backend, Model: s.model, Bad: bad, Recent: recent}
+			}
+			continue
+		}
+		kept = append(kept, s)
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	copy(scored, append(kept, demoted...))
+	if first != nil {
+		first.PromotedBackend, first.PromotedModel = scored[0].backend, scored[0].model
+	}
+	return first
+}
+
 func (ms *ModelSelector) scoreModel(model ModelInfo, action ActionType, language string, complexity string) float64 {
 	// CRITICAL: For edit/review actions, model MUST support tools
 	if action == ActionEdit || action == ActionReview {
@@ -7,18 +7,27 @@ import (
 func TestModelSelectorScoring(t *testing.T) {
 	setup := &Setup{
 		Defaults: struct {
-			Mode               string  `yaml:"mode,omitempty"`
-			Backend            string  `yaml:"backend"`
-			Profile            string  `yaml:"profile,omitempty"`
-			Model              string  `yaml:"model,omitempty"`
-			Lang               string  `yaml:"lang"`
-			SystemPromptFile   string  `yaml:"system_prompt_file,omitempty"`
-			MLComplexThreshold float64 `yaml:"ml_complex_threshold,omitempty"`
-			MLIntentThreshold  float64 `yaml:"ml_intent_threshold,omitempty"`
-			GraphMaxFiles      int     `yaml:"graph_max_files,omitempty"`
-			BudgetMode         bool    `yaml:"budget_mode,omitempty"`
-			MaxCostPerTask     float64 `yaml:"max_cost_per_task,omitempty"`
-			MonthlyBudget      float64 `yaml:"monthly_budget,omitempty"`
+			Mode               string         `yaml:"mode,omitempty"`
+			Backend            string         `yaml:"backend"`
+			Profile            string         `yaml:"profile,omitempty"`
+			Model              string         `yaml:"model,omitempty"`
+			Lang               string         `yaml:"lang"`
+			SystemPromptFile   string         `yaml:"system_prompt_file,omitempty"`
+			MLComplexThreshold float64        `yaml:"ml_complex_threshold,omitempty"`
+			MLIntentThreshold  float64        `yaml:"ml_intent_threshold,omitempty"`
+			GraphMaxFiles      int            `yaml:"graph_max_files,omitempty"`
+			BudgetMode         bool           `yaml:"budget_mode,omitempty"`
+			MaxCostPerTask     float64        `yaml:"max_cost_per_task,omitempty"`
+			MonthlyBudget      float64        `yaml:"monthly_budget,omitempty"`
+			AmbiguityThreshold float64        `yaml:"ambiguity_threshold,omitempty"`
+			MaxDiffLines       int            `yaml:"max_diff_lines,omitempty"`
+			MaxDiffFiles       int            `yaml:"max_diff_files,omitempty"`
+			TestGuard          string         `yaml:"test_guard,omitempty"`
+			SecretScan         string         `yaml:"secret_scan,omitempty"`
+			MaxAttempts        int            `yaml:"max_attempts,omitempty"`
+			TaskTimeout        int            `yaml:"task_timeout,omitempty"`
+			AgentTimeouts      map[string]int `yaml:"agent_timeouts,omitempty"`
+			LearnMemory        bool           `yaml:"learn_memory,omitempty"`
 		}{
 			Mode:    "cloud",
 			Backend: "openrouter",
@@ -88,3 +97,49 @@ func TestModelSelectorScoring(t *testing.T) {
 
 	t.Logf("Selected: %s/%s", backend, model)
 }
+
+func TestModelSelectorDemotion(t *testing.T) {
+	bad := ModelFeedback{ModelID: "fast", Backend: "groq", Action: ActionEdit}
+	good := bad
+	good.Success = true
+	selector := &ModelSelector{
+		feedback: []ModelFeedback{good, bad, bad, bad},
+		setup:    &Setup{},
+	}
+	ranked := func() []scoredModel {
+		return []scoredModel{{"groq", "fast", 300}, {"groq", "slow", 200}, {"openrouter", "other", 100}}
+	}
+
+	scored := ranked()
+	d := selector.demote(scored, ActionEdit)
+	if d == nil || d.Model != "fast" || d.Bad != 3 || d.Recent != 4 || d.PromotedModel != "slow" {
+		t.Fatalf("unexpected demotion %+v", d)
+	}
+	if scored[0].model != "slow" || scored[2].model != "fast" {
+		t.Errorf("the demoted model should rank last, got %+v", scored)
+	}
+
+	// Feedback for another action or backend does not count
+	if d := selector.demote(ranked(), ActionReview); d != nil {
+		t.Errorf("unexpected demotion for review: %+v", d)
+	}
+	scored = []scoredModel{{"openrouter", "fast", 300}, {"groq", "slow", 200}}
+	if d := selector.demote(scored, ActionEdit); d != nil {
+		t.Errorf("unexpected demotion on another backend: %+v", d)
+	}
+
+	// Good feedback brings the model back
+	selector.feedback = append(selector.feedback, good, good)
+	if d := selector.demote(ranked(), ActionEdit); d != nil {
+		t.Errorf("3 bad of 6 is under the default ratio, got %+v", d)
+	}
+
+	selector.setup.Selection.Demotion = DemotionConfig{MinBad: 2, BadRatio: 0.5}
+	if d := selector.demote(ranked(), ActionEdit); d == nil {
+		t.Error("expected a demotion with the configured thresholds")
+	}
+	selector.setup.Selection.Demotion.Disabled = true
+	if d := selector.demote(ranked(), ActionEdit); d != nil {
+		t.Errorf("demotion is disabled, got %+v", d)
+	}
+}
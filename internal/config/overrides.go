@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Overrides pins the backend, profile or model for a single invocation. They
+// come from the --backend, --profile and --model command flags and are
+// applied on top of setup.yaml by LoadEffectiveSetup; nothing is written back to disk.
+type Overrides struct {
+	Backend string `json:"backend,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	Model   string `json:"model,omitempty"`
+}
+
+var (
+	overridesMu sync.RWMutex
+	overrides   Overrides
+)
+
+// SetOverrides installs invocation overrides for every later LoadEffectiveSetup call.
+func SetOverrides(o Overrides) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	overrides = o
+}
+
+// CurrentOverrides returns the overrides installed with SetOverrides.
+func CurrentOverrides() Overrides {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+	return overrides
+}
+
+// IsZero reports whether no override is set.
+func (o Overrides) IsZero() bool {
+	return o.Backend == "" && o.Profile == "" && o.Model == ""
+}
+
+// String renders the overrides as "backend=x profile=y model=z", skipping
+// empty fields.
+func (o Overrides) String() string {
+	var parts []string
+	if o.Backend != "" {
+		parts = append(parts, "backend="+o.Backend)
+	}
+	if o.Profile != "" {
+		parts = append(parts, "profile="+o.Profile)
+	}
+	if o.Model != "" {
+		parts = append(parts, "model="+o.Model)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Apply rewrites the setup defaults so that every lookup resolves to the
+// overridden backend, profile and model. A model override replaces the
+// per-agent models of the selected backend and profile, so planner, editor
+// and reviewer all use it.
+func (o Overrides) Apply(s *Setup) error {
+	if o.Backend != "" {
+		if _, ok := s.Backend[o.Backend]; !ok {
+			return fmt.Errorf("backend %q not found in setup.yaml", o.Backend)
+		}
+		s.Defaults.Backend = o.Backend
+	}
+
+	backendName := s.Defaults.Backend
+	bc, ok := s.Backend[backendName]
+
+	if o.Profile != "" {
+		if o.Profile != "default" {
+			if !ok {
+				return fmt.Errorf("backend %q not found in setup.yaml", backendName)
+			}
+			if _, exists := bc.Profiles[o.Profile]; !exists {
+				return fmt.Errorf("profile %q not found for backend %q", o.Profile, backendName)
+			}
+		}
+		s.Defaults.Profile = o.Profile
+	}
+
+	if o.Model != "" {
+		s.Defaults.Model = o.Model
+		if ok {
+			bc.DefaultModel = o.Model
+			bc.AgentModels = AgentModels{}
+			if p, exists := bc.Profiles[s.Defaults.Profile]; exists {
+				p.AgentModels = AgentModels{}
+				profiles := make(map[string]ProfileConfig, len(bc.Profiles))
+				for name, cfg := range bc.Profiles {
+					profiles[name] = cfg
+				}
+				profiles[s.Defaults.Profile] = p
+				bc.Profiles = profiles
+			}
+			s.Backend[backendName] = bc
+		}
+	}
+	return nil
+}
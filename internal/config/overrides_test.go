@@ -0,0 +1,65 @@
+package config
+
+import "testing"
+
+func overrideSetup() *Setup {
+	s := &Setup{Backend: map[string]BackendConfig{
+		"groq": {
+			DefaultModel: "llama",
+			AgentModels:  AgentModels{Editor: "qwen"},
+			Profiles: map[string]ProfileConfig{
+				"speed": {AgentModels: AgentModels{Editor: "gemma"}},
+			},
+		},
+		"openrouter": {DefaultModel: "kimi"},
+	}}
+	s.Defaults.Backend = "groq"
+	return s
+}
+
+func TestOverridesApply(t *testing.T) {
+	t.Run("backend", func(t *testing.T) {
+		s := overrideSetup()
+		if err := (Overrides{Backend: "openrouter"}).Apply(s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Defaults.Backend != "openrouter" {
+			t.Errorf("backend = %q", s.Defaults.Backend)
+		}
+	})
+
+	t.Run("model replaces agent models", func(t *testing.T) {
+		s := overrideSetup()
+		if err := (Overrides{Profile: "speed", Model: "gpt-4o"}).Apply(s); err != nil {
+			t.Fatal(err)
+		}
+		bc := s.Backend["groq"]
+		for _, agent := range []string{"editor", "query", "router"} {
+			if got := bc.GetModelForAgentWithProfile(agent, s.Defaults.Profile); got != "gpt-4o" {
+				t.Errorf("%s model = %q, want gpt-4o", agent, got)
+			}
+		}
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		if err := (Overrides{Backend: "nope"}).Apply(overrideSetup()); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		if err := (Overrides{Profile: "nope"}).Apply(overrideSetup()); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestOverridesString(t *testing.T) {
+	o := Overrides{Backend: "groq", Model: "llama"}
+	if got := o.String(); got != "backend=groq model=llama" {
+		t.Errorf("String() = %q", got)
+	}
+	if !(Overrides{}).IsZero() {
+		t.Error("empty overrides should be zero")
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -15,6 +16,7 @@ type Profile struct {
 }
 
 type Setup struct {
+	Version  int `yaml:"version,omitempty"` // schema version, see CurrentSetupVersion
 	Defaults struct {
 		Mode               string  `yaml:"mode,omitempty"` // local or cloud
 		Backend            string  `yaml:"backend"`
@@ -28,6 +30,33 @@ type Setup struct {
 		BudgetMode         bool    `yaml:"budget_mode,omitempty"`
 		MaxCostPerTask     float64 `yaml:"max_cost_per_task,omitempty"`
 		MonthlyBudget      float64 `yaml:"monthly_budget,omitempty"`
+		// AmbiguityThreshold (0-1) is how ambiguous an agent's question must be
+		// to stop an autonomous task instead of letting the agent guess
+		AmbiguityThreshold float64 `yaml:"ambiguity_threshold,omitempty"`
+		// MaxDiffLines and MaxDiffFiles limit how much an autonomous task may
+		// change; 0 means unlimited
+		MaxDiffLines int `yaml:"max_diff_lines,omitempty"`
+		MaxDiffFiles int `yaml:"max_diff_files,omitempty"`
+		// TestGuard is what happens when an autonomous change weakens the
+		// tests: fail (default), warn or off
+		TestGuard string `yaml:"test_guard,omitempty"`
+		// SecretScan is what happens when a commit or a push would publish
+		// a likely secret: fail (default), warn or off
+		SecretScan string `yaml:"secret_scan,omitempty"`
+		// MaxAttempts is how many models gptcode do tries before giving up;
+		// 0 means the --max-attempts default
+		MaxAttempts int `yaml:"max_attempts,omitempty"`
+		// TaskTimeout is how many seconds an autonomous task may run;
+		// 0 means unlimited
+		TaskTimeout int `yaml:"task_timeout,omitempty"`
+		// AgentTimeouts are how many seconds one run of an agent (planner,
+		// editor, reviewer, query, research) may take before it is cancelled
+		// and retried; "default" applies to the agents not listed, and
+		// agents without either have no limit
+		AgentTimeouts map[string]int `yaml:"agent_timeouts,omitempty"`
+		// LearnMemory has chat sessions and completed gptcode do tasks
+		// summarized into the project memory, one more model call each
+		LearnMemory bool `yaml:"learn_memory,omitempty"`
 	} `yaml:"defaults"`
 	E2E struct {
 		DefaultProfile string `yaml:"default_profile,omitempty"`
@@ -35,7 +64,171 @@ type Setup struct {
 		Notify         bool   `yaml:"notify,omitempty"`
 		Parallel       int    `yaml:"parallel,omitempty"`
 	} `yaml:"e2e,omitempty"`
-	Backend map[string]BackendConfig `yaml:"backend"`
+	Network NetworkConfig `yaml:"network,omitempty"`
+	Output  OutputConfig  `yaml:"output,omitempty"`
+	Context ContextConfig `yaml:"context,omitempty"`
+	Cache   CacheConfig   `yaml:"cache,omitempty"`
+	Update  UpdateConfig  `yaml:"update,omitempty"`
+	// Selection tunes how the model selector reacts to feedback
+	Selection SelectionConfig `yaml:"selection,omitempty"`
+	// Tools selects where the agents' commands run
+	Tools ToolsConfig `yaml:"tools,omitempty"`
+	// Run configures gptcode run
+	Run RunConfig `yaml:"run,omitempty"`
+	// Prompts holds prompt A/B experiments keyed by agent (planner, editor, reviewer)
+	Prompts map[string]PromptExperiment `yaml:"prompt_experiments,omitempty"`
+	Backend map[string]BackendConfig    `yaml:"backend"`
+}
+
+// OutputConfig selects how terminal output looks
+type OutputConfig struct {
+	Style string `yaml:"style,omitempty"` // emoji, ascii or plain
+	Theme string `yaml:"theme,omitempty"` // tokyonight, solarized or gruvbox
+	// Accessible prints plain progress lines instead of spinners
+	Accessible bool `yaml:"accessible,omitempty"`
+	// Stream renders chat responses token by token; on by default
+	Stream *bool `yaml:"stream,omitempty"`
+}
+
+// ToolsConfig selects where run_command runs: on the host (default) or,
+// with Sandbox docker, in a disposable container
+type ToolsConfig struct {
+	Sandbox string              `yaml:"sandbox,omitempty"`
+	Docker  DockerSandboxConfig `yaml:"docker,omitempty"`
+}
+
+// RunConfig configures gptcode run
+type RunConfig struct {
+	// SSHHosts are the hosts --target may run commands on, as shell globs
+	// such as *.staging.example.com; none are allowed by default
+	SSHHosts []string `yaml:"ssh_hosts,omitempty"`
+}
+
+// DockerSandboxConfig tunes the docker sandbox's containers
+type DockerSandboxConfig struct {
+	// Images override the image by language (go, typescript, python,
+	// ruby, elixir, rust) or for every other project (default)
+	Images map[string]string `yaml:"images,omitempty"`
+	// Network is the containers' network, e.g. none to cut them off
+	Network string `yaml:"network,omitempty"`
+	Memory  string `yaml:"memory,omitempty"` // e.g. 2g
+	CPUs    string `yaml:"cpus,omitempty"`   // e.g. 2
+	// Env are extra KEY=value variables
+	Env []string `yaml:"env,omitempty"`
+}
+
+// ContextConfig tunes how files are selected as context for the agents
+type ContextConfig struct {
+	// Weights override the default weight of each ranking signal: graph,
+	// embedding, recency, ownership and open_files. 0 turns a signal off.
+	Weights map[string]float64 `yaml:"weights,omitempty"`
+	// Embeddings selects the model behind semantic search and the
+	// embedding signal
+	Embeddings EmbeddingsConfig `yaml:"embeddings,omitempty"`
+}
+
+// EmbeddingsConfig selects the embedding model: a configured backend
+// (default: the default backend, or a local Ollama when it has no
+// embeddings) and a model on it.
+type EmbeddingsConfig struct {
+	Backend string `yaml:"backend,omitempty"`
+	Model   string `yaml:"model,omitempty"`
+	// Dimensions shortens the vectors of models that support it
+	// (text-embedding-3, embed-v4.0)
+	Dimensions int `yaml:"dimensions,omitempty"`
+	// BatchSize is how many texts go in one request (default: the
+	// provider's)
+	BatchSize int `yaml:"batch_size,omitempty"`
+}
+
+// CacheConfig tunes the LLM response cache in ~/.gptcode/cache
+type CacheConfig struct {
+	Disabled bool `yaml:"disabled,omitempty"`
+	// TTLHours is how long a response is reused (default 24)
+	TTLHours int `yaml:"ttl_hours,omitempty"`
+	// MaxSizeMB bounds the cache on disk (default 100)
+	MaxSizeMB int `yaml:"max_size_mb,omitempty"`
+}
+
+// SelectionConfig tunes how the model selector reacts to feedback
+type SelectionConfig struct {
+	Demotion DemotionConfig `yaml:"demotion,omitempty"`
+}
+
+// DemotionConfig sets when repeated bad feedback demotes a model for an
+// agent: among its Window most recent feedback events for that agent, at
+// least MinBad are bad and they are at least BadRatio of them. A demoted
+// model is only selected when no other model fits; good feedback brings it
+// back.
+type DemotionConfig struct {
+	Disabled bool    `yaml:"disabled,omitempty"`
+	Window   int     `yaml:"window,omitempty"`    // default 10
+	MinBad   int     `yaml:"min_bad,omitempty"`   // default 3
+	BadRatio float64 `yaml:"bad_ratio,omitempty"` // default 0.6
+}
+
+// WithDefaults fills the thresholds that are not set
+func (d DemotionConfig) WithDefaults() DemotionConfig {
+	if d.Window <= 0 {
+		d.Window = 10
+	}
+	if d.MinBad <= 0 {
+		d.MinBad = 3
+	}
+	if d.BadRatio <= 0 {
+		d.BadRatio = 0.6
+	}
+	return d
+}
+
+// ContextSignals are the ranking signals ContextConfig.Weights accepts
+var ContextSignals = []string{"graph", "embedding", "recency", "ownership", "open_files"}
+
+// StreamEnabled reports whether chat responses are streamed.
+// GPTCODE_NO_STREAM=1 turns streaming off regardless of config.
+func (o OutputConfig) StreamEnabled() bool {
+	if os.Getenv("GPTCODE_NO_STREAM") == "1" {
+		return false
+	}
+	return o.Stream == nil || *o.Stream
+}
+
+// PromptExperiment lists alternative system prompts for one agent. Each
+// session is assigned one variant; "control" is the built-in prompt.
+type PromptExperiment struct {
+	Assign   string                   `yaml:"assign,omitempty"` // "random" (default) or a variant name
+	Variants map[string]PromptVariant `yaml:"variants"`
+}
+
+// PromptVariant is one arm of a prompt experiment
+type PromptVariant struct {
+	Prompt string  `yaml:"prompt,omitempty"` // replaces the built-in prompt
+	File   string  `yaml:"file,omitempty"`   // prompt file, relative to ~/.gptcode
+	Append string  `yaml:"append,omitempty"` // added after the built-in prompt
+	Weight float64 `yaml:"weight,omitempty"` // relative share of sessions, default 1
+}
+
+// UpdateConfig controls self-update
+type UpdateConfig struct {
+	Channel string `yaml:"channel,omitempty"` // stable (default) or beta
+	Check   *bool  `yaml:"check,omitempty"`   // daily "new version" notice, on by default
+}
+
+// CheckEnabled reports whether the new-version notice is on.
+// GPTCODE_NO_UPDATE_CHECK=1 turns it off regardless of config.
+func (u UpdateConfig) CheckEnabled() bool {
+	if os.Getenv("GPTCODE_NO_UPDATE_CHECK") == "1" {
+		return false
+	}
+	return u.Check == nil || *u.Check
+}
+
+// ChannelOrDefault returns the configured channel, defaulting to stable.
+func (u UpdateConfig) ChannelOrDefault() string {
+	if u.Channel == "" {
+		return "stable"
+	}
+	return u.Channel
 }
 
 type BackendConfig struct {
@@ -45,10 +238,135 @@ type BackendConfig struct {
 	Models       map[string]string        `yaml:"models"`
 	AgentModels  AgentModels              `yaml:"agent_models,omitempty"`
 	Profiles     map[string]ProfileConfig `yaml:"profiles,omitempty"`
+	Retry        *RetryConfig             `yaml:"retry,omitempty"`
+	Network      *NetworkConfig           `yaml:"network,omitempty"`
+	Headers      map[string]string        `yaml:"headers,omitempty"`
+	SigV4        *SigV4Config             `yaml:"sigv4,omitempty"`
+	Routing      map[string]RoutingConfig `yaml:"routing,omitempty"`
+}
+
+// RoutingConfig holds router hints (OpenRouter, LiteLLM) sent in the request
+// body for a model. Routing maps are keyed by model ID, with "*" applying to
+// every model of the backend.
+type RoutingConfig struct {
+	Provider   *ProviderPreferences   `yaml:"provider,omitempty" json:"provider,omitempty"`
+	Transforms []string               `yaml:"transforms,omitempty" json:"transforms,omitempty"`
+	Extra      map[string]interface{} `yaml:"extra,omitempty" json:"-"`
+}
+
+// ProviderPreferences mirrors OpenRouter's provider routing object.
+type ProviderPreferences struct {
+	Order             []string `yaml:"order,omitempty" json:"order,omitempty"`
+	AllowFallbacks    *bool    `yaml:"allow_fallbacks,omitempty" json:"allow_fallbacks,omitempty"`
+	RequireParameters *bool    `yaml:"require_parameters,omitempty" json:"require_parameters,omitempty"`
+	DataCollection    string   `yaml:"data_collection,omitempty" json:"data_collection,omitempty"`
+	Quantizations     []string `yaml:"quantizations,omitempty" json:"quantizations,omitempty"`
+	Ignore            []string `yaml:"ignore,omitempty" json:"ignore,omitempty"`
+	Only              []string `yaml:"only,omitempty" json:"only,omitempty"`
+	Sort              string   `yaml:"sort,omitempty" json:"sort,omitempty"`
+}
+
+// Merge returns r with fields set in override replacing its own.
+func (r RoutingConfig) Merge(override RoutingConfig) RoutingConfig {
+	if override.Provider != nil {
+		r.Provider = override.Provider
+	}
+	if len(override.Transforms) > 0 {
+		r.Transforms = override.Transforms
+	}
+	if len(override.Extra) > 0 {
+		extra := make(map[string]interface{}, len(r.Extra)+len(override.Extra))
+		for k, v := range r.Extra {
+			extra[k] = v
+		}
+		for k, v := range override.Extra {
+			extra[k] = v
+		}
+		r.Extra = extra
+	}
+	return r
+}
+
+// RoutingFor resolves the routing hints for a model on a backend. Profile
+// settings override backend settings, and model-specific entries override
+// the "*" wildcard.
+func (b BackendConfig) RoutingFor(profile, model string) (RoutingConfig, bool) {
+	var result RoutingConfig
+	found := false
+	apply := func(routing map[string]RoutingConfig) {
+		for _, key := range []string{"*", model} {
+			if r, ok := routing[key]; ok {
+				result = result.Merge(r)
+				found = true
+			}
+		}
+	}
+
+	apply(b.Routing)
+	if p, ok := b.Profiles[profile]; ok {
+		apply(p.Routing)
+	}
+	return result, found
+}
+
+// SigV4Config enables AWS Signature Version 4 signing of backend requests,
+// for gateways such as Bedrock or API Gateway. Credentials are read from the
+// named environment variables (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN by default).
+type SigV4Config struct {
+	Region          string `yaml:"region"`
+	Service         string `yaml:"service"`
+	AccessKeyEnv    string `yaml:"access_key_env,omitempty"`
+	SecretKeyEnv    string `yaml:"secret_key_env,omitempty"`
+	SessionTokenEnv string `yaml:"session_token_env,omitempty"`
+}
+
+// NetworkConfig describes how to reach a service from restricted networks.
+// Empty fields fall back to the global network section and then to the
+// standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment variables.
+type NetworkConfig struct {
+	Proxy              string `yaml:"proxy,omitempty"`
+	NoProxy            string `yaml:"no_proxy,omitempty"`
+	CACert             string `yaml:"ca_cert,omitempty"`
+	ClientCert         string `yaml:"client_cert,omitempty"`
+	ClientKey          string `yaml:"client_key,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Merge returns n with empty fields filled from base.
+func (n NetworkConfig) Merge(base NetworkConfig) NetworkConfig {
+	if n.Proxy == "" {
+		n.Proxy = base.Proxy
+	}
+	if n.NoProxy == "" {
+		n.NoProxy = base.NoProxy
+	}
+	if n.CACert == "" {
+		n.CACert = base.CACert
+	}
+	if n.ClientCert == "" && n.ClientKey == "" {
+		n.ClientCert = base.ClientCert
+		n.ClientKey = base.ClientKey
+	}
+	if !n.InsecureSkipVerify {
+		n.InsecureSkipVerify = base.InsecureSkipVerify
+	}
+	return n
+}
+
+// RetryConfig overrides the default retry and circuit breaker behaviour for
+// requests to a backend.
+type RetryConfig struct {
+	MaxAttempts        int `yaml:"max_attempts,omitempty"`
+	BaseDelayMs        int `yaml:"base_delay_ms,omitempty"`
+	MaxDelayMs         int `yaml:"max_delay_ms,omitempty"`
+	BreakerThreshold   int `yaml:"breaker_threshold,omitempty"`
+	BreakerCooldownSec int `yaml:"breaker_cooldown_sec,omitempty"`
 }
 
 type ProfileConfig struct {
-	AgentModels AgentModels `yaml:"agent_models"`
+	AgentModels AgentModels              `yaml:"agent_models"`
+	Routing     map[string]RoutingConfig `yaml:"routing,omitempty"`
 }
 
 type AgentModels struct {
@@ -58,6 +376,23 @@ type AgentModels struct {
 	Research string `yaml:"research,omitempty"`
 }
 
+// merge returns the models with the ones set in o replacing them
+func (a AgentModels) merge(o AgentModels) AgentModels {
+	if o.Router != "" {
+		a.Router = o.Router
+	}
+	if o.Query != "" {
+		a.Query = o.Query
+	}
+	if o.Editor != "" {
+		a.Editor = o.Editor
+	}
+	if o.Research != "" {
+		a.Research = o.Research
+	}
+	return a
+}
+
 type ProfileDefaults struct {
 	Backend string `yaml:"backend"`
 	Model   string `yaml:"model"`
@@ -133,3 +468,18 @@ func (s *Setup) ResolveBackendAndModel(modelStr string, defaultBackend string) (
 	// The model string itself is the slug that the API expects
 	return defaultBackend, modelStr
 }
+
+// AgentTimeout returns how long one run of agent may take, or 0 for no
+// limit when agent_timeouts sets nothing for it
+func (s *Setup) AgentTimeout(agent string) time.Duration {
+	if s == nil {
+		return 0
+	}
+	if secs, ok := s.Defaults.AgentTimeouts[agent]; ok && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if secs, ok := s.Defaults.AgentTimeouts["default"]; ok && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return 0
+}
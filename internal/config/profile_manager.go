@@ -28,6 +28,7 @@ func loadSetupForProfiles() (*Setup, error) {
 		return nil, err
 	}
 
+	migrateSetupOnLoad(setupPath)
 	data, err := os.ReadFile(setupPath)
 	if err != nil {
 		return nil, err
@@ -86,6 +87,39 @@ func ListBackendProfiles(backendName string) ([]string, error) {
 	return profiles, nil
 }
 
+// ModelProfiles maps the models the profiles of a backend use, for any of
+// their agents, to the profiles as backend.profile. The backend's own agent
+// models are its default profile. An empty backend covers all of them.
+func (s *Setup) ModelProfiles(backend string) map[string][]string {
+	refs := map[string][]string{}
+	for name, bc := range s.Backend {
+		if backend != "" && name != backend {
+			continue
+		}
+		profiles := []string{"default"}
+		for p := range bc.Profiles {
+			if p != "default" {
+				profiles = append(profiles, p)
+			}
+		}
+		for _, p := range profiles {
+			seen := map[string]bool{}
+			for _, agent := range []string{"router", "query", "editor", "research"} {
+				model := bc.GetModelForAgentWithProfile(agent, p)
+				if model == "" || seen[model] {
+					continue
+				}
+				seen[model] = true
+				refs[model] = append(refs[model], name+"."+p)
+			}
+		}
+	}
+	for _, profiles := range refs {
+		sort.Strings(profiles)
+	}
+	return refs
+}
+
 func GetBackendProfile(backendName, profileName string) (*BackendProfile, error) {
 	setup, err := loadSetupForProfiles()
 	if err != nil {
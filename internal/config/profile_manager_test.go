@@ -0,0 +1,40 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestModelProfiles(t *testing.T) {
+	setupYAML := `
+backend:
+    groq:
+        default_model: llama-3.3-70b-versatile
+        agent_models:
+            router: llama-3.1-8b-instant
+        profiles:
+            speed:
+                agent_models:
+                    editor: qwen/qwen3-32b
+    openrouter:
+        default_model: qwen/qwen3-32b
+`
+	var setup Setup
+	if err := yaml.Unmarshal([]byte(setupYAML), &setup); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"llama-3.3-70b-versatile": {"groq.default", "groq.speed"},
+		"llama-3.1-8b-instant":    {"groq.default"},
+		"qwen/qwen3-32b":          {"groq.speed", "openrouter.default"},
+	}
+	if got := setup.ModelProfiles(""); !reflect.DeepEqual(got, want) {
+		t.Errorf("ModelProfiles() = %v, want %v", got, want)
+	}
+	if got := setup.ModelProfiles("openrouter"); !reflect.DeepEqual(got, map[string][]string{"qwen/qwen3-32b": {"openrouter.default"}}) {
+		t.Errorf("ModelProfiles(openrouter) = %v", got)
+	}
+}
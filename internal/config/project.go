@@ -0,0 +1,460 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"gptcode/internal/langdetect"
+)
+
+// ProjectConfigFile is the repo-level configuration file, relative to the
+// repository root.
+const ProjectConfigFile = ".gptcode/config.yml"
+
+// ProjectConfig is the per-repository configuration kept in version control
+// at .gptcode/config.yml.
+type ProjectConfig struct {
+	Git    ProjectGitConfig    `yaml:"git,omitempty"`
+	Batch  ProjectBatchConfig  `yaml:"batch,omitempty"`
+	Daemon ProjectDaemonConfig `yaml:"daemon,omitempty"`
+	Policy ProjectPolicyConfig `yaml:"policy,omitempty"`
+	Models ProjectModelsConfig `yaml:"models,omitempty"`
+
+	Validation ProjectValidationConfig `yaml:"validation,omitempty"`
+
+	// Tools are shell tools offered to the agents next to the builtin ones
+	Tools []ProjectTool `yaml:"tools,omitempty"`
+
+	// LSP picks the language server behind the lsp_* tools
+	LSP ProjectLSPConfig `yaml:"lsp,omitempty"`
+
+	// Root is the project root: the directory containing
+	// .gptcode/config.yml or, without one, the repository root.
+	Root string `yaml:"-"`
+}
+
+// ProjectGitConfig controls how autonomous runs create branches and commits.
+type ProjectGitConfig struct {
+	// IssueTrailer is the trailer appended to agent commits made on an issue
+	// branch: "refs" (default), "closes", "fixes" or "none".
+	IssueTrailer string `yaml:"issue_trailer,omitempty"`
+
+	// BranchTemplate names issue branches, e.g. "feat/{issue}-{slug}".
+	// Placeholders: {issue}, {slug}, {type}.
+	BranchTemplate string `yaml:"branch_template,omitempty"`
+
+	// CommitTemplate formats agent commit subjects, e.g.
+	// "{type}({scope}): {summary}". Placeholders: {type}, {scope},
+	// {summary}, {issue}. Type and scope are inferred when not given.
+	CommitTemplate string `yaml:"commit_template,omitempty"`
+
+	// DraftFirst opens agent PRs as drafts and marks them ready for review
+	// once CI passes and the diff summary is posted.
+	DraftFirst bool `yaml:"draft_first,omitempty"`
+
+	// CommitPhases makes implement commit after each plan phase it
+	// completes, as with --commit.
+	CommitPhases bool `yaml:"commit_phases,omitempty"`
+
+	// Forge is the code host issues and PRs live on: "github" or "gitlab".
+	// It is detected from the remote's host; set it for a self-hosted
+	// GitLab whose hostname does not mention gitlab.
+	Forge string `yaml:"forge,omitempty"`
+}
+
+// ProjectBatchConfig limits batch runs such as issue fix-all.
+type ProjectBatchConfig struct {
+	// MaxConcurrency caps how many issues are worked on at once (default 1).
+	MaxConcurrency int `yaml:"max_concurrency,omitempty"`
+}
+
+// ProjectDaemonConfig holds the maintenance jobs gptcode daemon runs on a
+// schedule.
+type ProjectDaemonConfig struct {
+	Jobs   []DaemonJob  `yaml:"jobs,omitempty"`
+	Notify DaemonNotify `yaml:"notify,omitempty"`
+
+	// CleanupBranches is the schedule of a built-in job that deletes agent
+	// branches whose PRs were merged or closed, locally and on the remote.
+	// Empty disables it.
+	CleanupBranches string `yaml:"cleanup_branches,omitempty"`
+}
+
+// CleanupJobName is the name of the built-in branch cleanup job
+const CleanupJobName = "branch-cleanup"
+
+// AllJobs returns the configured jobs and the enabled built-in ones.
+func (d ProjectDaemonConfig) AllJobs() []DaemonJob {
+	jobs := append([]DaemonJob(nil), d.Jobs...)
+	if d.CleanupBranches == "" {
+		return jobs
+	}
+	for _, j := range jobs {
+		if j.Name == CleanupJobName {
+			return jobs
+		}
+	}
+	return append(jobs, DaemonJob{
+		Name:     CleanupJobName,
+		Schedule: d.CleanupBranches,
+		Command:  "git cleanup --remote --yes",
+	})
+}
+
+// DaemonJob is one scheduled gptcode command, e.g. a weekly
+// "deps upgrade --minor".
+type DaemonJob struct {
+	Name string `yaml:"name"`
+
+	// Schedule is a 5-field cron expression or one of hourly, daily,
+	// nightly, weekly and monthly.
+	Schedule string `yaml:"schedule"`
+
+	// Command is the gptcode command line to run, without "gptcode".
+	Command string `yaml:"command"`
+
+	// Output is what the job produces: "report" (default) or "pr", which
+	// runs the command on a fresh branch and opens a PR with its changes.
+	Output string `yaml:"output,omitempty"`
+
+	// Base is the branch "pr" jobs start from (default main).
+	Base string `yaml:"base,omitempty"`
+
+	// MaxCost stops the job once it has cost this much in USD; Timeout
+	// stops it after this long. 0 means defaults.max_cost_per_task and no
+	// timeout.
+	MaxCost float64       `yaml:"max_cost,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Notify is when to send a notification: "failure" (default),
+	// "always" or "never".
+	Notify string `yaml:"notify,omitempty"`
+}
+
+// DaemonNotify is where job notifications go.
+type DaemonNotify struct {
+	// Webhook receives a JSON {"text": ...} POST, as Slack and Mattermost
+	// incoming webhooks expect.
+	Webhook string `yaml:"webhook,omitempty"`
+	// Desktop shows a desktop notification.
+	Desktop bool `yaml:"desktop,omitempty"`
+}
+
+// ProjectModelsConfig pins the model configuration a team shares, on top
+// of each member's ~/.gptcode/setup.yaml. Empty fields keep the setup.yaml
+// values; command-line flags still override both.
+type ProjectModelsConfig struct {
+	// Backend and Profile must exist in setup.yaml
+	Backend string `yaml:"backend,omitempty"`
+	Profile string `yaml:"profile,omitempty"`
+
+	// Model replaces the backend's default and per-agent models, as
+	// --model does; AgentModels then sets the models of single agents.
+	Model       string      `yaml:"model,omitempty"`
+	AgentModels AgentModels `yaml:"agent_models,omitempty"`
+
+	// MaxAttempts is how many models gptcode do tries before giving up.
+	MaxAttempts int `yaml:"max_attempts,omitempty"`
+}
+
+// IsZero reports whether the project pins nothing.
+func (m ProjectModelsConfig) IsZero() bool {
+	return m.Backend == "" && m.Profile == "" && m.Model == "" && m.AgentModels == (AgentModels{}) &&
+		m.MaxAttempts == 0
+}
+
+// Apply overlays the project's model configuration on the setup. Agent
+// models go to the selected profile, or to the backend without one.
+func (m ProjectModelsConfig) Apply(s *Setup) error {
+	o := Overrides{Backend: m.Backend, Profile: m.Profile, Model: m.Model}
+	if err := o.Apply(s); err != nil {
+		return fmt.Errorf("%s: %w", ProjectConfigFile, err)
+	}
+	if m.MaxAttempts > 0 {
+		s.Defaults.MaxAttempts = m.MaxAttempts
+	}
+
+	bc, ok := s.Backend[s.Defaults.Backend]
+	if m.AgentModels == (AgentModels{}) || !ok {
+		return nil
+	}
+	profile := s.Defaults.Profile
+	if p, exists := bc.Profiles[profile]; exists && profile != "default" {
+		p.AgentModels = p.AgentModels.merge(m.AgentModels)
+		profiles := make(map[string]ProfileConfig, len(bc.Profiles))
+		for name, cfg := range bc.Profiles {
+			profiles[name] = cfg
+		}
+		profiles[profile] = p
+		bc.Profiles = profiles
+	} else {
+		bc.AgentModels = bc.AgentModels.merge(m.AgentModels)
+	}
+	s.Backend[s.Defaults.Backend] = bc
+	return nil
+}
+
+// ProjectValidationConfig replaces the build, test, lint and coverage
+// commands detected from the project's language, for projects built with
+// make, Bazel or scripts. Commands not configured are still detected.
+type ProjectValidationConfig struct {
+	ValidationCommands `yaml:",inline"`
+
+	// Languages configures the commands of the subprojects in one language
+	// (go, typescript, python, elixir, ruby, rust), taking precedence over
+	// the commands above.
+	Languages map[string]ValidationCommands `yaml:"languages,omitempty"`
+}
+
+// ValidationCommands are the commands checking a change, by kind.
+type ValidationCommands struct {
+	Build    *ValidationCommand `yaml:"build,omitempty"`
+	Test     *ValidationCommand `yaml:"test,omitempty"`
+	Lint     *ValidationCommand `yaml:"lint,omitempty"`
+	Coverage *ValidationCommand `yaml:"coverage,omitempty"`
+}
+
+// ValidationCommand is a shell command and what its success looks like. A
+// plain string is a command that succeeds with exit code 0.
+type ValidationCommand struct {
+	Command string `yaml:"command"`
+
+	// ExitCodes are the exit codes meaning success (default 0).
+	ExitCodes []int `yaml:"exit_codes,omitempty"`
+
+	// SuccessPattern is a regular expression the output must match, and
+	// FailurePattern one it must not.
+	SuccessPattern string `yaml:"success_pattern,omitempty"`
+	FailurePattern string `yaml:"failure_pattern,omitempty"`
+
+	// CoveragePattern captures the coverage percentage in its first group;
+	// by default the last percentage in the output is used.
+	CoveragePattern string `yaml:"coverage_pattern,omitempty"`
+}
+
+// UnmarshalYAML accepts a command line in place of the mapping.
+func (c *ValidationCommand) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		c.Command = node.Value
+		return nil
+	}
+	type plain ValidationCommand
+	return node.Decode((*plain)(c))
+}
+
+// For returns the commands configured for a language.
+func (v ProjectValidationConfig) For(language string) ValidationCommands {
+	cmds := v.ValidationCommands
+	lang, ok := v.Languages[language]
+	if !ok {
+		return cmds
+	}
+	if lang.Build != nil {
+		cmds.Build = lang.Build
+	}
+	if lang.Test != nil {
+		cmds.Test = lang.Test
+	}
+	if lang.Lint != nil {
+		cmds.Lint = lang.Lint
+	}
+	if lang.Coverage != nil {
+		cmds.Coverage = lang.Coverage
+	}
+	return cmds
+}
+
+// ProjectTool declares a tool wrapping a project command, e.g.
+//
+//	tools:
+//	  - name: migrate_db
+//	    description: Apply or roll back database migrations
+//	    parameters:
+//	      type: object
+//	      properties:
+//	        direction: {type: string, enum: [up, down]}
+//	      required: [direction]
+//	    command: ./scripts/migrate.sh {{.direction}}
+type ProjectTool struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+
+	// Parameters is the JSON schema of the arguments
+	Parameters map[string]interface{} `yaml:"parameters,omitempty"`
+
+	// Command is a text/template run by sh in the project root; its fields
+	// are the arguments, shell-quoted.
+	Command string `yaml:"command"`
+
+	// Timeout limits one run (default 5m)
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// ProjectLSPConfig picks the language server the agents query for
+// diagnostics, references and renames.
+type ProjectLSPConfig struct {
+	// Command starts a server speaking LSP on stdio, e.g.
+	// ["typescript-language-server", "--stdio"]. By default it is the
+	// usual server of the project language, when installed.
+	Command []string `yaml:"command,omitempty"`
+
+	// Disabled offers no lsp_* tools
+	Disabled bool `yaml:"disabled,omitempty"`
+}
+
+// ProjectPolicyConfig holds what supervised runs may do without asking, and
+// the commands run_command refuses or asks about in every run.
+type ProjectPolicyConfig struct {
+	Allow   PolicyAllow    `yaml:"allow,omitempty"`
+	Deny    PolicyCommands `yaml:"deny,omitempty"`
+	Confirm PolicyCommands `yaml:"confirm,omitempty"`
+}
+
+// PolicyCommands lists command patterns, regular expressions matched
+// anywhere in the command line, added to the built-in deny or confirm rules.
+type PolicyCommands struct {
+	Commands []string `yaml:"commands,omitempty"`
+}
+
+// PolicyAllow lists the operations approved for the project, usually by
+// answering "always for this project" at a prompt.
+type PolicyAllow struct {
+	// Commands run_command may run: a command line, or a prefix ending in
+	// "*" such as "go test *".
+	Commands []string `yaml:"commands,omitempty"`
+
+	// Paths outside the project the agents may write to, with everything
+	// under them. Relative paths are relative to the project root; "~" is
+	// the home directory.
+	Paths []string `yaml:"paths,omitempty"`
+}
+
+// IssueTrailerKeyword returns the trailer keyword ("Refs", "Closes" or
+// "Fixes") or "" when issue trailers are disabled.
+func (g ProjectGitConfig) IssueTrailerKeyword() string {
+	switch g.IssueTrailer {
+	case "", "refs":
+		return "Refs"
+	case "closes":
+		return "Closes"
+	case "fixes":
+		return "Fixes"
+	default:
+		return ""
+	}
+}
+
+// LoadProjectConfig finds .gptcode/config.yml in dir or its parents, stopping
+// at the repository root. A missing file yields an empty config rooted at
+// the repository root.
+func LoadProjectConfig(dir string) (*ProjectConfig, error) {
+	root, ok := FindProjectDir(dir, ProjectConfigFile)
+	if !ok {
+		return &ProjectConfig{Root: root}, nil
+	}
+	b, err := os.ReadFile(filepath.Join(root, ProjectConfigFile))
+	if err != nil {
+		return &ProjectConfig{Root: root}, err
+	}
+	var pc ProjectConfig
+	if err := yaml.Unmarshal(b, &pc); err != nil {
+		return &ProjectConfig{Root: root}, err
+	}
+	pc.Root = root
+	return &pc, nil
+}
+
+// ProjectRoot returns the root of the project dir is in: the directory
+// containing .gptcode/config.yml, else the repository (or worktree) root,
+// else dir itself.
+func ProjectRoot(dir string) string {
+	root, _ := FindProjectDir(dir, ProjectConfigFile)
+	return root
+}
+
+// FindProjectDir returns the nearest of dir and its parents containing
+// name, looking no further than the repository root; outside a repository
+// every parent is looked at. When none contains it, ok is false and the
+// repository root, or dir, is returned.
+func FindProjectDir(dir, name string) (found string, ok bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return dir, false
+	}
+	repo := langdetect.RepoRoot(dir)
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, name)); err == nil {
+			return d, true
+		}
+		parent := filepath.Dir(d)
+		if d == repo || parent == d {
+			break
+		}
+		d = parent
+	}
+	if repo != "" {
+		return repo, false
+	}
+	return dir, false
+}
+
+// AllowInProjectPolicy adds value to policy.allow.<kind> ("commands" or
+// "paths") in root's .gptcode/config.yml, creating the file if needed. The
+// rest of the file, comments included, is kept as it is.
+func AllowInProjectPolicy(root, kind, value string) error {
+	if kind != "commands" && kind != "paths" {
+		return fmt.Errorf("unknown policy list %q", kind)
+	}
+	path := filepath.Join(root, ProjectConfigFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	node := doc.Content[0]
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a mapping", path)
+	}
+	for _, key := range []string{"policy", "allow"} {
+		child := mappingValue(node, key)
+		if child == nil || child.Kind != yaml.MappingNode {
+			child = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingNode(node, key, child)
+		}
+		node = child
+	}
+	list := mappingValue(node, kind)
+	if list == nil || list.Kind != yaml.SequenceNode {
+		list = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		setMappingNode(node, kind, list)
+	}
+	for _, item := range list.Content {
+		if item.Value == value {
+			return nil
+		}
+	}
+	list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value})
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
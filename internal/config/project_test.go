@@ -0,0 +1,236 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(root, "pkg", "api")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := LoadProjectConfig(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc.Git.IssueTrailerKeyword() != "Refs" {
+		t.Errorf("default trailer = %q, want Refs", pc.Git.IssueTrailerKeyword())
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, ".gptcode"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ProjectConfigFile), []byte("git:\n  issue_trailer: none\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pc, err = LoadProjectConfig(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc.Root != root {
+		t.Errorf("Root = %q, want %q", pc.Root, root)
+	}
+	if kw := pc.Git.IssueTrailerKeyword(); kw != "" {
+		t.Errorf("trailer keyword = %q, want disabled", kw)
+	}
+
+	tools := "tools:\n  - name: migrate_db\n    parameters:\n      type: object\n      properties:\n        direction: {type: string}\n    command: ./migrate.sh {{.direction}}\n    timeout: 2m\n"
+	if err := os.WriteFile(filepath.Join(root, ProjectConfigFile), []byte(tools), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pc, err = LoadProjectConfig(sub)
+	if err != nil || len(pc.Tools) != 1 {
+		t.Fatalf("tools = %+v, %v", pc.Tools, err)
+	}
+	tool := pc.Tools[0]
+	if tool.Command != "./migrate.sh {{.direction}}" || tool.Timeout != 2*time.Minute {
+		t.Errorf("unexpected tool %+v", tool)
+	}
+	if props, ok := tool.Parameters["properties"].(map[string]interface{}); !ok || props["direction"] == nil {
+		t.Errorf("parameters should decode as a JSON schema, got %#v", tool.Parameters)
+	}
+}
+
+func TestProjectRoot(t *testing.T) {
+	repo := t.TempDir()
+	sub := filepath.Join(repo, "apps", "web")
+	for _, dir := range []string{filepath.Join(repo, ".git"), filepath.Join(sub, "src")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without a config, the repository root
+	if got := ProjectRoot(filepath.Join(sub, "src")); got != repo {
+		t.Errorf("ProjectRoot = %q, want the repository root %q", got, repo)
+	}
+	pc, err := LoadProjectConfig(filepath.Join(sub, "src"))
+	if err != nil || pc.Root != repo {
+		t.Errorf("LoadProjectConfig root = %q (%v), want %q", pc.Root, err, repo)
+	}
+
+	// A subproject's own config makes it the root
+	if err := os.MkdirAll(filepath.Join(sub, ".gptcode"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sub, ProjectConfigFile), []byte("batch:\n  max_concurrency: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := ProjectRoot(filepath.Join(sub, "src")); got != sub {
+		t.Errorf("ProjectRoot = %q, want the subproject %q", got, sub)
+	}
+
+	// The search stops at the repository root
+	outer := filepath.Dir(repo)
+	if _, ok := FindProjectDir(filepath.Join(sub, "src"), filepath.Base(repo)); ok {
+		t.Errorf("FindProjectDir looked above the repository root into %s", outer)
+	}
+}
+
+func TestAllowInProjectPolicy(t *testing.T) {
+	root := t.TempDir()
+	if err := AllowInProjectPolicy(root, "commands", "go test ./..."); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, ProjectConfigFile)
+	existing := "# Team settings\ngit:\n  issue_trailer: closes # close issues on merge\n"
+	if err := os.WriteFile(path, []byte(existing), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"go test ./...", "make lint", "go test ./..."} {
+		if err := AllowInProjectPolicy(root, "commands", v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AllowInProjectPolicy(root, "paths", "../shared"); err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := LoadProjectConfig(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pc.Policy.Allow.Commands; len(got) != 2 || got[0] != "go test ./..." || got[1] != "make lint" {
+		t.Errorf("commands = %v", got)
+	}
+	if got := pc.Policy.Allow.Paths; len(got) != 1 || got[0] != "../shared" {
+		t.Errorf("paths = %v", got)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# close issues on merge") || pc.Git.IssueTrailer != "closes" {
+		t.Errorf("existing settings and comments should be kept:\n%s", data)
+	}
+}
+
+func TestDaemonAllJobs(t *testing.T) {
+	d := ProjectDaemonConfig{Jobs: []DaemonJob{{Name: "deps", Schedule: "weekly", Command: "deps upgrade"}}}
+	if jobs := d.AllJobs(); len(jobs) != 1 {
+		t.Errorf("expected only the configured job, got %+v", jobs)
+	}
+	d.CleanupBranches = "daily"
+	jobs := d.AllJobs()
+	if len(jobs) != 2 || jobs[1].Name != CleanupJobName || jobs[1].Schedule != "daily" || jobs[1].Command != "git cleanup --remote --yes" {
+		t.Errorf("expected the branch cleanup job, got %+v", jobs)
+	}
+	if len(d.Jobs) != 1 {
+		t.Error("AllJobs should not change the configured jobs")
+	}
+}
+
+func TestProjectModelsOverlay(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.MkdirAll(filepath.Join(home, ".gptcode"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	setup := `defaults:
+  backend: groq
+  max_attempts: 2
+backend:
+  groq:
+    default_model: llama
+    agent_models:
+      editor: qwen
+    profiles:
+      speed:
+        agent_models:
+          editor: gemma
+  openrouter:
+    default_model: kimi
+`
+	if err := os.WriteFile(filepath.Join(home, ".gptcode", "setup.yaml"), []byte(setup), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	for _, dir := range []string{".git", ".gptcode", "web"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	project := `models:
+  profile: speed
+  agent_models:
+    query: mixtral
+  max_attempts: 5
+`
+	if err := os.WriteFile(filepath.Join(root, ProjectConfigFile), []byte(project), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(root, "web"))
+
+	s, err := LoadEffectiveSetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc := s.Backend["groq"]
+	if s.Defaults.Backend != "groq" || s.Defaults.Profile != "speed" {
+		t.Errorf("backend/profile = %s/%s, want groq/speed", s.Defaults.Backend, s.Defaults.Profile)
+	}
+	if got := bc.GetModelForAgentWithProfile("query", "speed"); got != "mixtral" {
+		t.Errorf("query model = %q, want the project's mixtral", got)
+	}
+	if got := bc.GetModelForAgentWithProfile("editor", "speed"); got != "gemma" {
+		t.Errorf("editor model = %q, want setup.yaml's gemma", got)
+	}
+	if s.Defaults.MaxAttempts != 5 {
+		t.Errorf("max attempts = %d, want 5", s.Defaults.MaxAttempts)
+	}
+
+	// Saving the setup keeps the project's pins out of setup.yaml
+	if err := SetConfig("defaults.lang", "go"); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := LoadSetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Defaults.Lang != "go" || raw.Defaults.Profile != "" || raw.Defaults.MaxAttempts != 2 {
+		t.Errorf("setup.yaml = %+v, want only the lang changed", raw.Defaults)
+	}
+
+	// Flags win over the project
+	SetOverrides(Overrides{Backend: "openrouter"})
+	defer SetOverrides(Overrides{})
+	if s, err = LoadEffectiveSetup(); err != nil || s.Defaults.Backend != "openrouter" {
+		t.Errorf("backend = %q (%v), want the flag's openrouter", s.Defaults.Backend, err)
+	}
+
+	// Backends must exist in setup.yaml
+	if err := os.WriteFile(filepath.Join(root, ProjectConfigFile), []byte("models:\n  backend: nope\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	SetOverrides(Overrides{})
+	if _, err := LoadEffectiveSetup(); err == nil || !strings.Contains(err.Error(), ProjectConfigFile) {
+		t.Errorf("unknown project backend should fail, got %v", err)
+	}
+}
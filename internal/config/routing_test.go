@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRoutingFor(t *testing.T) {
+	backendYAML := `
+type: openai
+base_url: https://openrouter.ai/api/v1
+routing:
+    "*":
+        transforms: [middle-out]
+    qwen/qwen3-coder:
+        provider:
+            order: [deepinfra, together]
+            allow_fallbacks: false
+            quantizations: [fp8]
+profiles:
+    stable:
+        agent_models:
+            editor: qwen/qwen3-coder
+        routing:
+            qwen/qwen3-coder:
+                provider:
+                    order: [fireworks]
+`
+	var backend BackendConfig
+	if err := yaml.Unmarshal([]byte(backendYAML), &backend); err != nil {
+		t.Fatalf("failed to parse backend: %v", err)
+	}
+
+	t.Run("backend model entry merges wildcard", func(t *testing.T) {
+		r, ok := backend.RoutingFor("default", "qwen/qwen3-coder")
+		if !ok {
+			t.Fatal("expected routing")
+		}
+		if len(r.Transforms) != 1 || r.Transforms[0] != "middle-out" {
+			t.Errorf("expected wildcard transforms, got %v", r.Transforms)
+		}
+		if r.Provider == nil || r.Provider.Order[0] != "deepinfra" {
+			t.Errorf("expected backend provider order, got %+v", r.Provider)
+		}
+		if r.Provider.AllowFallbacks == nil || *r.Provider.AllowFallbacks {
+			t.Error("expected allow_fallbacks=false")
+		}
+	})
+
+	t.Run("profile overrides backend", func(t *testing.T) {
+		r, _ := backend.RoutingFor("stable", "qwen/qwen3-coder")
+		if r.Provider == nil || len(r.Provider.Order) != 1 || r.Provider.Order[0] != "fireworks" {
+			t.Errorf("expected profile provider order, got %+v", r.Provider)
+		}
+	})
+
+	t.Run("wildcard only", func(t *testing.T) {
+		r, ok := backend.RoutingFor("default", "other/model")
+		if !ok || r.Provider != nil || len(r.Transforms) != 1 {
+			t.Errorf("expected only wildcard routing, got %+v", r)
+		}
+	})
+}
@@ -55,8 +55,11 @@ func detectTemplateDir() string {
 	return "templates"
 }
 
+// LoadSetup reads setup.yaml as it is on disk. Anything saving the setup
+// back loads it with this; commands run with LoadEffectiveSetup.
 func LoadSetup() (*Setup, error) {
 	path := filepath.Join(configDir(), "setup.yaml")
+	migrateSetupOnLoad(path)
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return &Setup{}, err
@@ -68,6 +71,33 @@ func LoadSetup() (*Setup, error) {
 	return &s, nil
 }
 
+// LoadEffectiveSetup is the setup commands run with: setup.yaml, the
+// project's pinned models on top and flags on top of both. It must not be
+// saved, or the project's pins would end up in the global setup.yaml.
+func LoadEffectiveSetup() (*Setup, error) {
+	s, err := LoadSetup()
+	if err != nil {
+		return s, err
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		pc, err := LoadProjectConfig(cwd)
+		if err != nil {
+			return s, fmt.Errorf("%s: %w", ProjectConfigFile, err)
+		}
+		if !pc.Models.IsZero() {
+			if err := pc.Models.Apply(s); err != nil {
+				return s, err
+			}
+		}
+	}
+	if o := CurrentOverrides(); !o.IsZero() {
+		if err := o.Apply(s); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
 func SaveSetup(setup *Setup) error {
 	path := filepath.Join(configDir(), "setup.yaml")
 	return saveSetup(path, setup)
@@ -265,6 +295,9 @@ func interactiveSetup() *Setup {
 }
 
 func saveSetup(path string, setup *Setup) error {
+	if setup.Version == 0 {
+		setup.Version = CurrentSetupVersion
+	}
 	data, err := yaml.Marshal(setup)
 	if err != nil {
 		return err
@@ -0,0 +1,115 @@
+package contractgen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gptcode/internal/graph"
+)
+
+func TestURLPath(t *testing.T) {
+	cases := map[string]string{
+		`"/users"`:                           "/users",
+		`baseURL + "/users/" + id`:           "/users/{}",
+		"`${API}/orders/${id}/items?page=2`": "/orders/${id}/items",
+		`fmt.Sprintf("%s/users/%d"`:          "/users/%d",
+		`f"{self.base}/users/{user_id}"`:     "/users/{user_id}",
+		`"http://localhost:4000/api/health"`: "/api/health",
+		`"#{@base}/teams/#{id}"`:             "/teams/#{id}",
+		`"https://api.github.com/repos"`:     "",
+		`url`:                                "",
+	}
+	for expr, want := range cases {
+		got, ok := urlPath(expr)
+		if got != want || ok != (want != "") {
+			t.Errorf("urlPath(%s) = %q, %v; want %q", expr, got, ok, want)
+		}
+	}
+}
+
+func TestDiscoverAndMatch(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shop\n",
+		"server/routes.go": `package server
+
+import "net/http"
+
+func Routes(mux *http.ServeMux, h *Handler) {
+	mux.HandleFunc("GET /users/{id}", h.GetUser)
+	mux.HandleFunc("POST /orders", createOrder)
+	mux.HandleFunc("/health", health)
+}
+`,
+		"server/users.go": `package server
+
+func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.store.User(r.PathValue("id")))
+}
+`,
+		"web/api.ts": `export async function user(id: string) {
+  return fetch(` + "`${BASE}/api/users/${id}`" + `).then(r => r.json());
+}
+export const order = (o) => fetch("/orders", { method: "POST", body: JSON.stringify(o) });
+export const stale = () => axios.delete("/orders/" + id);
+export const gh = () => fetch("https://api.github.com/user");
+`,
+		"web/api.test.ts": `fetch("/not/a/real/call")`,
+		"scripts/sync.py": `import requests
+
+def sync(base):
+    return requests.get(f"{base}/users/{uid}")
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contracts, unmatched, err := Check(root, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, c := range contracts {
+		key := c.Endpoint.Method + " " + c.Endpoint.Path
+		for _, call := range c.Calls {
+			got[key] = append(got[key], call.Method+" "+call.File)
+		}
+	}
+	want := map[string][]string{
+		"GET /users/{id}": {"GET scripts/sync.py", "GET web/api.ts"},
+		"POST /orders":    {"POST web/api.ts"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("contracts = %v, want %v", got, want)
+	}
+	if len(unmatched) != 1 || unmatched[0].Method != "DELETE" || unmatched[0].Path != "/orders/{}" {
+		t.Errorf("unmatched = %+v, want the DELETE /orders/{}", unmatched)
+	}
+
+	// The handler is found through its declaration, away from the route
+	gr, err := graph.NewBuilder(root).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range contracts {
+		if c.Endpoint.Handler != "h.GetUser" {
+			continue
+		}
+		file, snippet, ok := HandlerSource(gr, root, c.Endpoint)
+		if !ok || file != filepath.Join("server", "users.go") || snippet == "" {
+			t.Errorf("HandlerSource = %s, %q, %v", file, snippet, ok)
+		}
+	}
+	if TestPath(root, FormatGolden, contracts) != filepath.Join(root, "server", "contract_test.go") {
+		t.Errorf("unexpected test path %s", TestPath(root, FormatGolden, contracts))
+	}
+}
@@ -0,0 +1,354 @@
+// Package contractgen finds the HTTP endpoints a project serves and the
+// calls a project makes to HTTP APIs, matches them, and generates contract
+// tests from the matches: golden-request tests on the provider side or
+// Pact consumer tests. Calls matching no endpoint are breaking changes
+// waiting to happen, which Check reports without a model.
+package contractgen
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gptcode/internal/graph"
+)
+
+// Endpoint is a route a project serves
+type Endpoint struct {
+	Method string `json:"method"` // upper case, "*" for any
+	Path   string `json:"path"`   // as written in the route
+	// Handler is the function, controller action or method serving it,
+	// when the route names one
+	Handler string `json:"handler,omitempty"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+}
+
+// Call is a request a project makes to an HTTP API
+type Call struct {
+	Method string `json:"method"`
+	// Path is the URL path, with the parts computed at runtime as {}
+	Path string `json:"path"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// Contract is an endpoint and the calls to it
+type Contract struct {
+	Endpoint Endpoint `json:"endpoint"`
+	Calls    []Call   `json:"calls"`
+}
+
+// Discovery is what a scan of a project found
+type Discovery struct {
+	Root      string
+	Endpoints []Endpoint
+	Calls     []Call
+}
+
+const methods = `get|post|put|patch|delete|head|options`
+
+// endpointPattern finds routes: the method, path and handler submatch
+// indexes, 0 when the route has none
+type endpointPattern struct {
+	re                    *regexp.Regexp
+	method, path, handler int
+}
+
+var endpointPatterns = map[string][]endpointPattern{
+	"go": {
+		// net/http: mux.HandleFunc("GET /users/{id}", getUser)
+		{regexp.MustCompile(`\.Handle(?:Func)?\(\s*"(?:([A-Z]+)\s+)?(/[^"]*)"\s*,\s*([\w.]+)`), 1, 2, 3},
+		// gin, echo, chi, fiber: r.GET("/users/:id", getUser)
+		{regexp.MustCompile(`(?i)\.(` + methods + `)\(\s*"(/[^"]*)"\s*,\s*(?:[\w.]+\(\)\s*,\s*)*([\w.]+)`), 1, 2, 3},
+	},
+	"js": {
+		// Express, Koa, Fastify: app.get('/users/:id', auth, getUser)
+		{regexp.MustCompile(`\b(?:app|router|server|routes)\.(` + methods + `)\(\s*['"` + "`" + `](/[^'"` + "`" + `]*)['"` + "`" + `]\s*(?:,\s*([\w.]+)\s*)*`), 1, 2, 3},
+	},
+	"python": {
+		// FastAPI, Flask: @app.get("/users/{id}"), @bp.route("/users", methods=["POST"])
+		{regexp.MustCompile(`@\w+\.(` + methods + `|route)\(\s*['"](/[^'"]*)['"]`), 1, 2, 0},
+	},
+	"elixir": {
+		// Phoenix: get "/users/:id", UserController, :show
+		{regexp.MustCompile(`^\s*(` + methods + `)\s+"(/[^"]*)"\s*,\s*([\w.]+\s*,\s*:\w+)`), 1, 2, 3},
+	},
+	"ruby": {
+		// Rails, Sinatra: get '/users/:id', to: 'users#show'
+		{regexp.MustCompile(`^\s*(` + methods + `)\s+['"](/?[^'"]*)['"](?:\s*,\s*to:\s*['"]([\w#/]+)['"])?`), 1, 2, 3},
+	},
+}
+
+// callPattern finds HTTP client calls: the method submatch (0 when the
+// method comes from elsewhere), and the URL expression
+type callPattern struct {
+	re          *regexp.Regexp
+	method, url int
+	// defaultMethod is the method when the pattern has none
+	defaultMethod string
+}
+
+var callPatterns = map[string][]callPattern{
+	"go": {
+		{regexp.MustCompile(`\bhttp\.(Get|Post|Head)\(\s*([^,)]+)`), 1, 2, ""},
+		{regexp.MustCompile(`\bhttp\.NewRequest(?:WithContext)?\(\s*(?:\w+\s*,\s*)?(?:"(\w+)"|http\.Method(\w+))\s*,\s*([^,)]+)`), 0, 3, ""},
+	},
+	"js": {
+		{regexp.MustCompile(`\baxios\.(` + methods + `)\(\s*([^,)]+)`), 1, 2, ""},
+		{regexp.MustCompile(`\bfetch\(\s*([^,)]+)`), 0, 1, "GET"},
+	},
+	"python": {
+		{regexp.MustCompile(`\b(?:requests|httpx|client|session)\.(` + methods + `)\(\s*([^,)]+)`), 1, 2, ""},
+	},
+	"elixir": {
+		{regexp.MustCompile(`\b(?:Req|HTTPoison|Tesla|Finch)\.(` + methods + `)!?\(\s*(?:client\s*,\s*)?([^,)]+)`), 1, 2, ""},
+	},
+	"ruby": {
+		{regexp.MustCompile(`\b(?:HTTParty|Faraday|RestClient|conn|connection)\.(` + methods + `)\(?\s*([^,)]+)`), 1, 2, ""},
+	},
+}
+
+var (
+	fetchMethodRegex   = regexp.MustCompile(`method:\s*['"](\w+)['"]`)
+	flaskMethodsRegex  = regexp.MustCompile(`methods\s*=\s*\[\s*['"](\w+)['"]`)
+	muxMethodsRegex    = regexp.MustCompile(`\.Methods\(\s*"(\w+)"`)
+	pythonDefRegex     = regexp.MustCompile(`^\s*(?:async\s+)?def\s+(\w+)`)
+	stringLiteralRegex = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'|` + "`([^`]*)`")
+	urlHostRegex       = regexp.MustCompile(`^[a-z]+://([^/]*)`)
+	baseURLRegex       = regexp.MustCompile(`^(?:\{\}|%[sv]|\$\{[^}]*\}|#\{[^}]*\}|\{[^}/]*\})+`)
+	interpolationRegex = regexp.MustCompile(`\$\{[^}]*\}|#\{[^}]*\}|\{[^}/]*\}|%[sdvq]|<[^>/]*>|:\w+`)
+)
+
+// scriptLanguage groups extensions sharing endpoint and call patterns
+func scriptLanguage(path string) string {
+	switch filepath.Ext(path) {
+	case ".go":
+		return "go"
+	case ".js", ".jsx", ".ts", ".tsx", ".mjs":
+		return "js"
+	case ".py":
+		return "python"
+	case ".ex", ".exs":
+		return "elixir"
+	case ".rb":
+		return "ruby"
+	}
+	return ""
+}
+
+// Discover scans the source files of the dependency graph of root for
+// endpoints and client calls. Test files are left out, as their requests
+// are not the project's.
+func Discover(root string) (*Discovery, error) {
+	g, err := graph.NewBuilder(root).Build()
+	if err != nil {
+		return nil, err
+	}
+	d := &Discovery{Root: root}
+	for _, file := range g.Files() {
+		lang := scriptLanguage(file)
+		if lang == "" || isTestFile(file) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(root, file))
+		if err != nil {
+			continue
+		}
+		d.scan(file, lang, strings.Split(string(content), "\n"))
+	}
+	return d, nil
+}
+
+func isTestFile(path string) bool {
+	base := filepath.Base(path)
+	for _, s := range []string{"_test.go", "_test.exs", "_spec.rb", "_test.rb", ".test.ts", ".test.js", ".spec.ts", ".spec.js", ".test.tsx"} {
+		if strings.HasSuffix(base, s) {
+			return true
+		}
+	}
+	return strings.HasPrefix(base, "test_")
+}
+
+func (d *Discovery) scan(file, lang string, lines []string) {
+	for i, line := range lines {
+		found := false
+		for _, p := range endpointPatterns[lang] {
+			m := p.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			e := Endpoint{Method: strings.ToUpper(m[p.method]), Path: m[p.path], File: file, Line: i + 1}
+			if p.handler > 0 {
+				e.Handler = strings.Join(strings.Fields(m[p.handler]), " ")
+			}
+			switch {
+			case e.Method == "":
+				e.Method = "*"
+				// gorilla/mux: r.HandleFunc("/users", h).Methods("POST")
+				if mm := muxMethodsRegex.FindStringSubmatch(line); mm != nil {
+					e.Method = strings.ToUpper(mm[1])
+				}
+			case e.Method == "ROUTE":
+				e.Method = "GET"
+				if mm := flaskMethodsRegex.FindStringSubmatch(line); mm != nil {
+					e.Method = strings.ToUpper(mm[1])
+				}
+			}
+			if lang == "python" {
+				// The decorated function is the handler
+				for _, next := range lines[i+1 : min(i+4, len(lines))] {
+					if mm := pythonDefRegex.FindStringSubmatch(next); mm != nil {
+						e.Handler = mm[1]
+						break
+					}
+				}
+			}
+			d.Endpoints = append(d.Endpoints, e)
+			found = true
+			break
+		}
+		if found {
+			continue
+		}
+		for _, p := range callPatterns[lang] {
+			m := p.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			path, ok := urlPath(m[p.url])
+			if !ok {
+				continue
+			}
+			c := Call{Method: p.defaultMethod, Path: path, File: file, Line: i + 1}
+			if p.method > 0 {
+				c.Method = m[p.method]
+			} else if lang == "go" {
+				// NewRequest: "POST" or http.MethodPost
+				c.Method = m[1] + m[2]
+			}
+			if mm := fetchMethodRegex.FindStringSubmatch(line); mm != nil && lang == "js" {
+				c.Method = mm[1]
+			}
+			c.Method = strings.ToUpper(c.Method)
+			d.Calls = append(d.Calls, c)
+			break
+		}
+	}
+}
+
+// urlPath turns the URL expression of a call into its path: string
+// literals are kept and what is computed between them becomes {}. It is
+// false when the expression has no literal path, such as a plain variable,
+// and for URLs of other hosts than localhost, which are third-party APIs.
+func urlPath(expr string) (string, bool) {
+	expr = strings.TrimSpace(expr)
+	var b strings.Builder
+	last := 0
+	for _, loc := range stringLiteralRegex.FindAllStringSubmatchIndex(expr, -1) {
+		if strings.Trim(expr[last:loc[0]], " +.<>,(") != "" {
+			b.WriteString("{}")
+		}
+		for i := 2; i < len(loc); i += 2 {
+			if loc[i] >= 0 {
+				b.WriteString(expr[loc[i]:loc[i+1]])
+			}
+		}
+		last = loc[1]
+	}
+	// A concatenation after the last literal: "/users/" + id
+	if rest := strings.TrimSpace(expr[last:]); b.Len() > 0 && (strings.HasPrefix(rest, "+") || strings.HasPrefix(rest, "<>")) {
+		b.WriteString("{}")
+	}
+	url := b.String()
+	if m := urlHostRegex.FindStringSubmatch(url); m != nil {
+		host, _, _ := strings.Cut(m[1], ":")
+		if host != "localhost" && host != "127.0.0.1" && host != "0.0.0.0" && !strings.Contains(host, "{") && !strings.Contains(host, "%") {
+			return "", false
+		}
+		url = url[len(m[0]):]
+	}
+	url, _, _ = strings.Cut(url, "?")
+	// A base URL computed at runtime
+	url = baseURLRegex.ReplaceAllString(url, "")
+	if !strings.HasPrefix(url, "/") {
+		return "", false
+	}
+	return url, true
+}
+
+// segments splits a path into segments, with parameters as {}
+func segments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if p == "*" || strings.HasPrefix(p, "*") || interpolationRegex.MatchString(p) {
+			parts[i] = "{}"
+		}
+	}
+	return parts
+}
+
+// matches tells whether a call's path is served by an endpoint's, which
+// may be mounted under a prefix the call includes. A parameter of the
+// endpoint matches any segment, a computed segment of the call only a
+// parameter.
+func matches(e Endpoint, c Call) bool {
+	if e.Method != "*" && c.Method != "" && e.Method != c.Method {
+		return false
+	}
+	es, cs := segments(e.Path), segments(c.Path)
+	if len(cs) < len(es) || (len(es) == 0 && len(cs) > 0) {
+		return false
+	}
+	cs = cs[len(cs)-len(es):]
+	for i := range es {
+		if es[i] != cs[i] && es[i] != "{}" {
+			return false
+		}
+	}
+	return true
+}
+
+// Match pairs the calls with the endpoints serving them, returning the
+// contracts of the endpoints called and the calls no endpoint serves.
+// Exact matches win over ones through a mount prefix.
+func Match(endpoints []Endpoint, calls []Call) ([]Contract, []Call) {
+	contracts := make([]Contract, len(endpoints))
+	for i, e := range endpoints {
+		contracts[i].Endpoint = e
+	}
+	var unmatched []Call
+	for _, c := range calls {
+		best := -1
+		for i, e := range endpoints {
+			if !matches(e, c) {
+				continue
+			}
+			if best < 0 || len(segments(e.Path)) > len(segments(endpoints[best].Path)) {
+				best = i
+			}
+		}
+		if best < 0 {
+			unmatched = append(unmatched, c)
+			continue
+		}
+		contracts[best].Calls = append(contracts[best].Calls, c)
+	}
+
+	var called []Contract
+	for _, c := range contracts {
+		if len(c.Calls) > 0 {
+			called = append(called, c)
+		}
+	}
+	sort.SliceStable(called, func(i, j int) bool {
+		return called[i].Endpoint.Path < called[j].Endpoint.Path
+	})
+	return called, unmatched
+}
@@ -0,0 +1,284 @@
+package contractgen
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gptcode/internal/graph"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+)
+
+// Test formats
+const (
+	// FormatGolden replays the consumers' requests against the provider's
+	// handlers and compares the responses with golden files
+	FormatGolden = "golden"
+	// FormatPact writes Pact consumer tests, whose pact files the
+	// provider verifies
+	FormatPact = "pact"
+)
+
+// snippetLines is how much of a handler or call site goes in the prompt
+const snippetLines = 60
+
+type ContractGenerator struct {
+	provider llm.Provider
+	model    string
+}
+
+type GenerateResult struct {
+	TestFile  string
+	Contracts []Contract
+	Valid     bool
+	Error     error
+}
+
+func NewContractGenerator(provider llm.Provider, model string) *ContractGenerator {
+	return &ContractGenerator{
+		provider: provider,
+		model:    model,
+	}
+}
+
+// Check discovers the endpoints of providerRoot and the calls of
+// consumerRoot, which may be the same project, and matches them.
+func Check(consumerRoot, providerRoot string) (contracts []Contract, unmatched []Call, err error) {
+	provider, err := Discover(providerRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	consumer := provider
+	if consumerRoot != providerRoot {
+		if consumer, err = Discover(consumerRoot); err != nil {
+			return nil, nil, err
+		}
+	}
+	contracts, unmatched = Match(provider.Endpoints, consumer.Calls)
+	return contracts, unmatched, nil
+}
+
+// TestPath is where the contract tests of a format go by default: next to
+// the first handler's package for Go golden tests, next to the first call
+// for Go Pact tests, and in the test directory of other languages.
+func TestPath(root, format string, contracts []Contract) string {
+	lang := langdetect.DetectLanguage(root)
+	first := ""
+	if len(contracts) > 0 {
+		first = contracts[0].Endpoint.File
+		if format == FormatPact {
+			first = contracts[0].Calls[0].File
+		}
+	}
+	name := "contract"
+	if format == FormatPact {
+		name = "pact"
+	}
+	switch lang {
+	case langdetect.Go:
+		return filepath.Join(root, filepath.Dir(first), name+"_test.go")
+	case langdetect.TypeScript:
+		return filepath.Join(root, "test", name+".test.ts")
+	case langdetect.Python:
+		return filepath.Join(root, "tests", "test_"+name+".py")
+	case langdetect.Elixir:
+		return filepath.Join(root, "test", name+"_test.exs")
+	case langdetect.Ruby:
+		return filepath.Join(root, "spec", name+"_spec.rb")
+	}
+	return filepath.Join(root, "test", name+".test.js")
+}
+
+// Generate writes contract tests for contracts, in the provider's project
+// for golden tests and in the consumer's for Pact tests. testFile
+// defaults to TestPath.
+func (g *ContractGenerator) Generate(ctx context.Context, consumerRoot, providerRoot string, contracts []Contract, format, testFile string) (*GenerateResult, error) {
+	if len(contracts) == 0 {
+		return nil, fmt.Errorf("no endpoint is called; nothing to test")
+	}
+	root := providerRoot
+	if format == FormatPact {
+		root = consumerRoot
+		if langdetect.DetectLanguage(root) == langdetect.Elixir {
+			return nil, fmt.Errorf("pact has no Elixir library; use --format golden")
+		}
+	} else if format != FormatGolden {
+		return nil, fmt.Errorf("unknown format %q (golden or pact)", format)
+	}
+	if testFile == "" {
+		testFile = TestPath(root, format, contracts)
+	} else if !filepath.IsAbs(testFile) {
+		testFile = filepath.Join(root, testFile)
+	}
+
+	// The graph finds the handlers, which are often defined away from
+	// their routes
+	gr, err := graph.NewBuilder(providerRoot).Build()
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	for _, c := range contracts {
+		e := c.Endpoint
+		fmt.Fprintf(&b, "### %s %s (route at %s:%d)\n", e.Method, e.Path, e.File, e.Line)
+		if file, snippet, ok := HandlerSource(gr, providerRoot, e); ok {
+			fmt.Fprintf(&b, "Handler %s in %s:\n```\n%s\n```\n", e.Handler, file, snippet)
+		}
+		for _, call := range c.Calls {
+			fmt.Fprintf(&b, "Called as %s %s at %s:%d:\n```\n%s\n```\n", call.Method, call.Path, call.File, call.Line,
+				fileSnippet(filepath.Join(consumerRoot, call.File), call.Line-5, 15))
+		}
+		b.WriteString("\n")
+	}
+
+	rel, _ := filepath.Rel(root, testFile)
+	code, err := g.generateTestCode(ctx, langdetect.DetectLanguage(root), format, rel, b.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate contract tests: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(testFile), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(testFile, []byte(code+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write contract tests: %w", err)
+	}
+	result := &GenerateResult{TestFile: testFile, Contracts: contracts, Valid: true}
+	if filepath.Ext(testFile) == ".go" {
+		if _, err := parser.ParseFile(token.NewFileSet(), testFile, code, 0); err != nil {
+			result.Valid = false
+			result.Error = err
+		}
+	}
+	return result, nil
+}
+
+var formatRules = map[string]string{
+	FormatGolden: `- Provider-side golden request tests: for each endpoint, build the request each
+  consumer sends (method, path with realistic parameters, body and headers
+  the call site shows) and serve it with the handler in process (net/http/httptest
+  in Go, supertest in JS/TS, the test client in Python, Phoenix.ConnTest in Elixir,
+  request specs in Ruby)
+- Compare the status and the JSON body with a golden file per request under
+  testdata/contracts/ (test/fixtures/contracts/ outside Go), written when it is
+  missing or when the tests run in update mode (an -update flag in Go, an
+  UPDATE_GOLDEN=1 environment variable elsewhere)
+- Compare the shape of the body (keys and value types), not volatile values
+  such as ids and timestamps`,
+	FormatPact: `- Pact consumer tests (pact-go v2, @pact-foundation/pact, pact-python or
+  pact-ruby): one interaction per endpoint and method the consumer calls, with
+  the request the call site sends and the response fields the consumer reads
+  (use matchers such as like/eachLike rather than exact values)
+- Exercise the consumer's own client code against the mock server where it
+  can take a base URL; otherwise send the request directly
+- Write the pact files to the pacts/ directory for the provider to verify`,
+}
+
+func (g *ContractGenerator) generateTestCode(ctx context.Context, lang langdetect.Language, format, testFile, contracts string) (string, error) {
+	prompt := fmt.Sprintf(`Generate contract tests in %s, to be saved as %s, for these HTTP
+endpoints and the calls made to them:
+
+%s
+Rules:
+%s
+- One test per endpoint and method, named after them
+- Only cover what the calls use: a breaking change to any of it must fail a test
+- Clean, idiomatic code that compiles as is
+
+Return ONLY the complete file, no explanations.`, lang, testFile, contracts, formatRules[format])
+
+	resp, err := g.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are a helpful assistant that writes contract tests between HTTP services.",
+		UserPrompt:   prompt,
+		Model:        g.model,
+	})
+	if err != nil {
+		return "", err
+	}
+	return llm.ExtractCode(resp.Text), nil
+}
+
+var identRegex = regexp.MustCompile(`\w+`)
+
+// HandlerSource finds the definition of an endpoint's handler: a symbol of
+// the graph named after it, preferring the route's file and files named
+// after the handler's controller or module, or for Go, whose symbols the
+// graph does not keep, a func declaration
+func HandlerSource(gr *graph.Graph, root string, e Endpoint) (string, string, bool) {
+	idents := identRegex.FindAllString(e.Handler, -1)
+	if len(idents) == 0 {
+		return "", "", false
+	}
+	name := idents[len(idents)-1]
+	hint := strings.ToLower(idents[0])
+
+	var candidates []string
+	for _, n := range gr.Nodes {
+		if n.Type == "symbol" && n.Symbol() == name {
+			candidates = append(candidates, n.File())
+		}
+	}
+	if filepath.Ext(e.File) == ".go" {
+		for _, f := range gr.Files() {
+			if filepath.Ext(f) == ".go" && findDefinition(filepath.Join(root, f), name) > 0 {
+				candidates = append(candidates, f)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", "", false
+	}
+	best := candidates[0]
+	for _, f := range candidates {
+		base := strings.ReplaceAll(strings.ToLower(filepath.Base(f)), "_", "")
+		if f == e.File {
+			best = f
+			break
+		}
+		if len(idents) > 1 && strings.Contains(base, strings.TrimSuffix(hint, "controller")) {
+			best = f
+		}
+	}
+	path := filepath.Join(root, best)
+	line := findDefinition(path, name)
+	if line == 0 {
+		line = 1
+	}
+	return best, fileSnippet(path, line, snippetLines), true
+}
+
+// findDefinition returns the line where name is defined in a file, 0 when
+// it is not
+func findDefinition(path, name string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	def := regexp.MustCompile(`^\s*(?:export\s+)?(?:async\s+)?(?:func|def|defp|function|const|let|var)\s+(?:\([^)]*\)\s*)?` + regexp.QuoteMeta(name) + `\b`)
+	for i, line := range strings.Split(string(content), "\n") {
+		if def.MatchString(line) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// fileSnippet returns n lines of a file from line start, 1-based
+func fileSnippet(path string, start, n int) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(content), "\n")
+	start = max(start, 1)
+	end := min(start-1+n, len(lines))
+	if start > end {
+		return ""
+	}
+	return strings.Join(lines[start-1:end], "\n")
+}
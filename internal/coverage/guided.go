@@ -0,0 +1,143 @@
+package coverage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/graph"
+)
+
+// TestAuthor writes tests for target into its test file
+type TestAuthor func(ctx context.Context, target Target) error
+
+// GuidedGenerator writes tests for the functions coverage leaves
+// uncovered, the most important first by PageRank, until the package
+// reaches a coverage threshold.
+type GuidedGenerator struct {
+	// Root is the root of the Go module
+	Root string
+	// Package is the package pattern tests are run for, e.g. ./internal/...
+	Package string
+	// Threshold is the coverage, in percent, to reach
+	Threshold float64
+	// MaxRounds caps how many times coverage is measured and the targets
+	// written tests for
+	MaxRounds int
+	// PerRound is how many targets get tests each round
+	PerRound int
+	Author   TestAuthor
+	// Progress, when set, is told what each round is about to do
+	Progress func(round int, percent float64, targets []Target)
+	// Outcome, when set, is told how writing tests for a target went
+	Outcome func(target Target, err error)
+}
+
+// GuidedResult is how a guided run went
+type GuidedResult struct {
+	Start, End float64
+	Rounds     int
+	Reached    bool
+	// Written are the targets whose tests were kept, Failed those whose
+	// tests could not be written or did not pass
+	Written []Target
+	Failed  []Target
+}
+
+// Run measures coverage, writes tests for the highest ranked targets not
+// tried yet, and repeats until the threshold is met, no target is left or
+// MaxRounds is reached. A test file whose tests fail after the author
+// wrote them is restored, so each round starts from passing tests.
+func (gg *GuidedGenerator) Run(ctx context.Context) (*GuidedResult, error) {
+	g, err := graph.NewBuilder(gg.Root).Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the dependency graph: %w", err)
+	}
+	g.PageRank(0.85, 20)
+
+	res := &GuidedResult{}
+	tried := map[string]bool{}
+	for round := 1; ; round++ {
+		profile, err := RunProfile(gg.Root, gg.Package)
+		if err != nil {
+			return res, err
+		}
+		res.End = profile.Percent()
+		if round == 1 {
+			res.Start = res.End
+		}
+		if res.End >= gg.Threshold {
+			res.Reached = true
+			return res, nil
+		}
+		if round > gg.MaxRounds {
+			return res, nil
+		}
+
+		targets, err := Targets(profile, gg.Root)
+		if err != nil {
+			return res, err
+		}
+		Rank(targets, g)
+		var batch []Target
+		for _, t := range targets {
+			if !tried[t.key()] && len(batch) < gg.PerRound {
+				tried[t.key()] = true
+				batch = append(batch, t)
+			}
+		}
+		if len(batch) == 0 {
+			return res, nil
+		}
+
+		res.Rounds = round
+		if gg.Progress != nil {
+			gg.Progress(round, res.End, batch)
+		}
+		for _, t := range batch {
+			if err := ctx.Err(); err != nil {
+				return res, err
+			}
+			err := gg.write(ctx, t)
+			if gg.Outcome != nil {
+				gg.Outcome(t, err)
+			}
+			if err != nil {
+				res.Failed = append(res.Failed, t)
+			} else {
+				res.Written = append(res.Written, t)
+			}
+		}
+	}
+}
+
+// write has the author write tests for t and keeps them only when the
+// package's tests pass
+func (gg *GuidedGenerator) write(ctx context.Context, t Target) error {
+	testPath := filepath.Join(gg.Root, filepath.FromSlash(t.TestFile()))
+	before, readErr := os.ReadFile(testPath)
+	restore := func() {
+		if readErr == nil {
+			_ = os.WriteFile(testPath, before, 0644)
+		} else if errors.Is(readErr, os.ErrNotExist) {
+			_ = os.Remove(testPath)
+		}
+	}
+
+	if err := gg.Author(ctx, t); err != nil {
+		restore()
+		return err
+	}
+	cmd := exec.CommandContext(ctx, "go", "test", "./"+path.Dir(t.File))
+	cmd.Dir = gg.Root
+	if output, err := cmd.CombinedOutput(); err != nil {
+		restore()
+		return fmt.Errorf("the tests written do not pass, so they were discarded:\n%s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
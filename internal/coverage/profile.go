@@ -0,0 +1,299 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gptcode/internal/graph"
+)
+
+// Block is a run of statements in a coverage profile, and how many times
+// the tests ran it
+type Block struct {
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	NumStmt   int
+	Count     int
+}
+
+// Profile is a Go coverage profile, as go test -coverprofile writes it,
+// with its blocks by file
+type Profile struct {
+	Mode   string
+	Blocks map[string][]Block
+}
+
+// ParseProfile parses a coverage profile. A block listed more than once,
+// as when several test binaries cover the same package, is counted once
+// with the counts added up.
+func ParseProfile(r io.Reader) (*Profile, error) {
+	p := &Profile{Blocks: map[string][]Block{}}
+	index := map[string]map[[4]int]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if mode, ok := strings.CutPrefix(line, "mode:"); ok {
+			p.Mode = strings.TrimSpace(mode)
+			continue
+		}
+		file, b, err := parseBlock(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		if index[file] == nil {
+			index[file] = map[[4]int]int{}
+		}
+		pos := [4]int{b.StartLine, b.StartCol, b.EndLine, b.EndCol}
+		if i, ok := index[file][pos]; ok {
+			p.Blocks[file][i].Count += b.Count
+			continue
+		}
+		index[file][pos] = len(p.Blocks[file])
+		p.Blocks[file] = append(p.Blocks[file], b)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.Mode == "" {
+		return nil, fmt.Errorf("not a coverage profile: no mode line")
+	}
+	return p, nil
+}
+
+// parseBlock parses "file:startLine.startCol,endLine.endCol numStmt count"
+func parseBlock(line string) (string, Block, error) {
+	colon := strings.LastIndex(line, ":")
+	if colon < 0 {
+		return "", Block{}, fmt.Errorf("malformed block %q", line)
+	}
+	var b Block
+	if _, err := fmt.Sscanf(line[colon+1:], "%d.%d,%d.%d %d %d",
+		&b.StartLine, &b.StartCol, &b.EndLine, &b.EndCol, &b.NumStmt, &b.Count); err != nil {
+		return "", Block{}, fmt.Errorf("malformed block %q", line)
+	}
+	return line[:colon], b, nil
+}
+
+// Percent is the share of statements the tests ran
+func (p *Profile) Percent() float64 {
+	covered, total := 0, 0
+	for _, blocks := range p.Blocks {
+		for _, b := range blocks {
+			total += b.NumStmt
+			if b.Count > 0 {
+				covered += b.NumStmt
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(covered) / float64(total)
+}
+
+// RunProfile runs the tests of pkg in the Go module at root with coverage
+// and returns their profile. Failing tests are an error.
+func RunProfile(root, pkg string) (*Profile, error) {
+	out, err := os.CreateTemp("", "gptcode-cover-*.out")
+	if err != nil {
+		return nil, err
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	cmd := exec.Command("go", "test", "-coverprofile="+out.Name(), pkg)
+	cmd.Dir = root
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("go test failed: %w\nOutput: %s", err, string(output))
+	}
+	f, err := os.Open(out.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseProfile(f)
+}
+
+// Target is a function the tests leave statements of uncovered
+type Target struct {
+	// File is relative to the module root
+	File     string
+	Function string // Name, or Recv.Name for methods
+	Line     int
+	// Statements and Covered count the function's statements, and those
+	// the tests ran
+	Statements int
+	Covered    int
+	// Uncovered are the first lines of the blocks the tests never ran
+	Uncovered []int
+	// Rank is the PageRank of the function, or of its file
+	Rank float64
+}
+
+// Percent is the share of the target's statements the tests ran
+func (t Target) Percent() float64 {
+	if t.Statements == 0 {
+		return 100
+	}
+	return 100 * float64(t.Covered) / float64(t.Statements)
+}
+
+// TestFile is the _test.go file next to the target's file
+func (t Target) TestFile() string {
+	return strings.TrimSuffix(t.File, ".go") + "_test.go"
+}
+
+func (t Target) key() string {
+	return t.File + "#" + t.Function
+}
+
+// Targets returns the functions of the module at root that p shows
+// statements of uncovered, matching the profile's import paths to files
+// through the module path.
+func Targets(p *Profile, root string) ([]Target, error) {
+	module, err := modulePath(root)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for file := range p.Blocks {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	var targets []Target
+	for _, file := range files {
+		rel, ok := strings.CutPrefix(file, module+"/")
+		if !ok {
+			continue
+		}
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, filepath.Join(root, rel), nil, 0)
+		if err != nil {
+			// Files generated or deleted since the profile was written
+			continue
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			t := Target{
+				File:     filepath.ToSlash(rel),
+				Function: funcName(fn),
+				Line:     fset.Position(fn.Pos()).Line,
+			}
+			start, end := fset.Position(fn.Body.Lbrace), fset.Position(fn.Body.Rbrace)
+			for _, b := range p.Blocks[file] {
+				if !within(b, start, end) {
+					continue
+				}
+				t.Statements += b.NumStmt
+				if b.Count > 0 {
+					t.Covered += b.NumStmt
+				} else if b.NumStmt > 0 {
+					t.Uncovered = append(t.Uncovered, b.StartLine)
+				}
+			}
+			if t.Covered < t.Statements {
+				sort.Ints(t.Uncovered)
+				targets = append(targets, t)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// within reports whether block b lies between the positions of a
+// function's braces
+func within(b Block, start, end token.Position) bool {
+	after := b.StartLine > start.Line || (b.StartLine == start.Line && b.StartCol >= start.Column)
+	before := b.EndLine < end.Line || (b.EndLine == end.Line && b.EndCol <= end.Column+1)
+	return after && before
+}
+
+// funcName is the name of fn, prefixed by its receiver's type for methods
+func funcName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	typ := fn.Recv.List[0].Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+	if id, ok := typ.(*ast.Ident); ok {
+		return id.Name + "." + fn.Name.Name
+	}
+	return fn.Name.Name
+}
+
+// modulePath reads the module path from root's go.mod
+func modulePath(root string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return "", fmt.Errorf("not a Go module: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module line in %s", filepath.Join(root, "go.mod"))
+}
+
+// Rank sets the rank of each target from g, whose PageRank must have been
+// computed: the score of the function's symbol node, or else of its file.
+// The targets are sorted with the most important first, then by how many
+// statements they leave uncovered.
+func Rank(targets []Target, g *graph.Graph) {
+	for i := range targets {
+		t := &targets[i]
+		name := t.Function
+		if _, method, ok := strings.Cut(name, "."); ok {
+			name = method
+		}
+		t.Rank = 0
+		for _, path := range []string{t.File + "#" + name, t.File} {
+			if id, ok := g.Paths[path]; ok {
+				t.Rank = g.Nodes[id].Score
+				break
+			}
+		}
+	}
+	sort.SliceStable(targets, func(i, j int) bool {
+		a, b := targets[i], targets[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if missA, missB := a.Statements-a.Covered, b.Statements-b.Covered; missA != missB {
+			return missA > missB
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
@@ -0,0 +1,153 @@
+package coverage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gptcode/internal/graph"
+)
+
+const mathSource = `package mathx
+
+func Abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+type Clamp struct{ Max int }
+
+func (c *Clamp) Apply(n int) int {
+	if n > c.Max {
+		return c.Max
+	}
+	return n
+}
+`
+
+// writeModule writes a module with mathx and a test covering the positive
+// path of Abs only
+func writeModule(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":            "module example.com/demo\n\ngo 1.21\n",
+		"mathx/mathx.go":    mathSource,
+		"mathx/abs_test.go": "package mathx\n\nimport \"testing\"\n\nfunc TestAbs(t *testing.T) {\n\tif Abs(2) != 2 {\n\t\tt.Fatal()\n\t}\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestParseProfile(t *testing.T) {
+	p, err := ParseProfile(strings.NewReader(`mode: set
+example.com/demo/mathx/mathx.go:3.21,4.11 1 1
+example.com/demo/mathx/mathx.go:4.11,6.3 1 0
+example.com/demo/mathx/mathx.go:7.2,7.10 1 1
+example.com/demo/mathx/mathx.go:4.11,6.3 1 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Mode != "set" {
+		t.Errorf("expected mode set, got %q", p.Mode)
+	}
+	blocks := p.Blocks["example.com/demo/mathx/mathx.go"]
+	if len(blocks) != 3 {
+		t.Fatalf("expected the repeated block to be merged, got %+v", blocks)
+	}
+	if blocks[1].Count != 1 || p.Percent() != 100 {
+		t.Errorf("expected the repeated block's counts added, got %+v (%.1f%%)", blocks[1], p.Percent())
+	}
+
+	if _, err := ParseProfile(strings.NewReader("mode: set\nmathx.go:3.21 1\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a malformed block error, got %v", err)
+	}
+	if _, err := ParseProfile(strings.NewReader("")); err == nil {
+		t.Error("expected an error without a mode line")
+	}
+}
+
+func TestTargetsAndRank(t *testing.T) {
+	root := writeModule(t)
+	p, err := RunProfile(root, "./...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets, err := Targets(p, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("expected Abs and Clamp.Apply, got %+v", targets)
+	}
+	abs, apply := targets[0], targets[1]
+	if abs.Function != "Abs" || abs.File != "mathx/mathx.go" || abs.Line != 3 || !reflect.DeepEqual(abs.Uncovered, []int{5}) {
+		t.Errorf("unexpected Abs target: %+v", abs)
+	}
+	if apply.Function != "Clamp.Apply" || apply.Covered != 0 || apply.Statements != 3 {
+		t.Errorf("unexpected Clamp.Apply target: %+v", apply)
+	}
+	if abs.TestFile() != "mathx/mathx_test.go" {
+		t.Errorf("unexpected test file %q", abs.TestFile())
+	}
+
+	// Apply is ranked first once its symbol outranks Abs's file
+	g := graph.NewGraph()
+	g.Nodes[g.AddNode("mathx/mathx.go", "file")].Score = 0.1
+	g.Nodes[g.AddNode("mathx/mathx.go#Apply", "symbol")].Score = 0.5
+	Rank(targets, g)
+	if targets[0].Function != "Clamp.Apply" || targets[0].Rank != 0.5 || targets[1].Rank != 0.1 {
+		t.Errorf("unexpected ranking: %+v", targets)
+	}
+}
+
+func TestGuidedGeneratorRun(t *testing.T) {
+	root := writeModule(t)
+	var authored []string
+	gg := &GuidedGenerator{
+		Root:      root,
+		Package:   "./...",
+		Threshold: 100,
+		MaxRounds: 2,
+		PerRound:  1,
+		Author: func(ctx context.Context, target Target) error {
+			authored = append(authored, target.Function)
+			path := filepath.Join(root, target.TestFile())
+			if target.Function == "Abs" {
+				// A failing test is discarded
+				return os.WriteFile(path, []byte("package mathx\n\nimport \"testing\"\n\nfunc TestNegative(t *testing.T) { t.Fatal(Abs(-1)) }\n"), 0644)
+			}
+			return os.WriteFile(path, []byte("package mathx\n\nimport \"testing\"\n\nfunc TestApply(t *testing.T) {\n\tc := &Clamp{Max: 1}\n\tif c.Apply(5) != 1 || c.Apply(0) != 0 {\n\t\tt.Fatal()\n\t}\n}\n"), 0644)
+		},
+	}
+	res, err := gg.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(authored) != 2 || len(res.Written) != 1 || len(res.Failed) != 1 {
+		t.Fatalf("expected one kept and one discarded target, got %v %+v", authored, res)
+	}
+	if res.Written[0].Function != "Clamp.Apply" || res.Failed[0].Function != "Abs" {
+		t.Errorf("unexpected outcome: %+v", res)
+	}
+	if res.Reached || res.End <= res.Start || res.Rounds != 2 {
+		t.Errorf("expected coverage to rise short of 100%% in 2 rounds, got %+v", res)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "mathx/mathx_test.go")); err != nil || !strings.Contains(string(data), "TestApply") {
+		t.Errorf("expected the passing tests kept, got %q %v", data, err)
+	}
+}
@@ -0,0 +1,313 @@
+// Package daemon runs a project's scheduled maintenance jobs, such as a
+// weekly dependency upgrade or a nightly security scan, each producing a
+// report or a PR.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gptcode/internal/config"
+)
+
+// Job statuses
+const (
+	StatusOK        = "ok"
+	StatusFailed    = "failed"
+	StatusNoChanges = "no changes"
+)
+
+// Result is the outcome of one run of a job.
+type Result struct {
+	Job      string        `json:"job"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Status   string        `json:"status"`
+	Cost     float64       `json:"cost,omitempty"`
+	PR       string        `json:"pr,omitempty"`
+	Report   string        `json:"report,omitempty"`
+	Error    string        `json:"error,omitempty"`
+
+	// Output is the command's output, kept in the report only.
+	Output string `json:"-"`
+}
+
+// Runner runs a job and reports its outcome. It is given the job's own
+// budget and timeout to enforce.
+type Runner func(ctx context.Context, job config.DaemonJob) Result
+
+// jobState is what the scheduler remembers about a job between runs
+type jobState struct {
+	LastRun time.Time `json:"last_run"`
+	Last    *Result   `json:"last,omitempty"`
+}
+
+// Job is a configured job with its parsed schedule.
+type Job struct {
+	config.DaemonJob
+	Schedule *Schedule
+}
+
+// Scheduler decides which jobs are due and runs them one at a time, so two
+// jobs never change the same checkout at once. State and reports are kept
+// in dir.
+type Scheduler struct {
+	Jobs []Job
+
+	dir    string
+	run    Runner
+	notify func(config.DaemonJob, Result)
+	now    func() time.Time
+	state  map[string]*jobState
+}
+
+// NewScheduler validates jobs and loads the state kept in dir. notify may
+// be nil.
+func NewScheduler(jobs []config.DaemonJob, dir string, run Runner, notify func(config.DaemonJob, Result)) (*Scheduler, error) {
+	s := &Scheduler{dir: dir, run: run, notify: notify, now: time.Now, state: map[string]*jobState{}}
+	seen := map[string]bool{}
+	for _, j := range jobs {
+		if j.Name == "" || j.Command == "" {
+			return nil, fmt.Errorf("daemon job %q needs a name and a command", j.Name)
+		}
+		if seen[j.Name] {
+			return nil, fmt.Errorf("daemon job %q is defined twice", j.Name)
+		}
+		seen[j.Name] = true
+		if j.Output != "" && j.Output != "report" && j.Output != "pr" {
+			return nil, fmt.Errorf("daemon job %q: invalid output %q. Use: report, pr", j.Name, j.Output)
+		}
+		switch j.Notify {
+		case "", "failure", "always", "never":
+		default:
+			return nil, fmt.Errorf("daemon job %q: invalid notify %q. Use: failure, always, never", j.Name, j.Notify)
+		}
+		sched, err := ParseSchedule(j.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("daemon job %q: %w", j.Name, err)
+		}
+		s.Jobs = append(s.Jobs, Job{DaemonJob: j, Schedule: sched})
+	}
+
+	data, err := os.ReadFile(s.statePath())
+	if err == nil {
+		if err := json.Unmarshal(data, &s.state); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", s.statePath(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Scheduler) statePath() string { return filepath.Join(s.dir, "state.json") }
+
+// Job returns the job called name, or nil.
+func (s *Scheduler) Job(name string) *Job {
+	for i := range s.Jobs {
+		if s.Jobs[i].Name == name {
+			return &s.Jobs[i]
+		}
+	}
+	return nil
+}
+
+// Last returns the job's most recent result, or nil if it never ran.
+func (s *Scheduler) Last(name string) *Result {
+	if st := s.state[name]; st != nil {
+		return st.Last
+	}
+	return nil
+}
+
+// NextRun returns when the job runs next. A job the scheduler has not seen
+// before counts from now, so adding a job does not run it straight away.
+func (s *Scheduler) NextRun(j Job) time.Time {
+	st := s.state[j.Name]
+	if st == nil {
+		return j.Schedule.Next(s.now())
+	}
+	return j.Schedule.Next(st.LastRun)
+}
+
+// Due returns the jobs whose next run is not in the future. A run missed
+// while the daemon was down is made up once, not once per missed slot.
+func (s *Scheduler) Due() []Job {
+	now := s.now()
+	var due []Job
+	for _, j := range s.Jobs {
+		if s.state[j.Name] == nil {
+			continue
+		}
+		if next := s.NextRun(j); !next.IsZero() && !next.After(now) {
+			due = append(due, j)
+		}
+	}
+	return due
+}
+
+// Tick runs the due jobs and records new ones, returning the results.
+func (s *Scheduler) Tick(ctx context.Context) ([]Result, error) {
+	var results []Result
+	for _, j := range s.Due() {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, s.RunJob(ctx, j))
+	}
+	for _, j := range s.Jobs {
+		if s.state[j.Name] == nil {
+			s.state[j.Name] = &jobState{LastRun: s.now()}
+		}
+	}
+	return results, s.save()
+}
+
+// RunJob runs j now, writes its report and notifies as the job asks.
+func (s *Scheduler) RunJob(ctx context.Context, j Job) Result {
+	start := s.now()
+	r := s.run(ctx, j.DaemonJob)
+	r.Job, r.Start = j.Name, start
+	if r.Duration == 0 {
+		r.Duration = s.now().Sub(start).Round(time.Second)
+	}
+	if path, err := s.writeReport(r); err == nil {
+		r.Report = path
+	} else if r.Error == "" {
+		r.Error = fmt.Sprintf("failed to write the report: %v", err)
+	}
+
+	s.state[j.Name] = &jobState{LastRun: start, Last: &r}
+	if err := s.save(); err != nil && r.Error == "" {
+		r.Error = err.Error()
+	}
+	if s.notify != nil && ShouldNotify(j.Notify, r) {
+		s.notify(j.DaemonJob, r)
+	}
+	return r
+}
+
+// Run ticks every minute until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context, onResult func(Result)) error {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		results, err := s.Tick(ctx)
+		for _, r := range results {
+			if onResult != nil {
+				onResult(r)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) save() error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.statePath(), data, 0644)
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// writeReport writes the result as markdown to reports/<job>-<time>.md
+func (s *Scheduler) writeReport(r Result) (string, error) {
+	dir := filepath.Join(s.dir, "reports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := unsafeNameChars.ReplaceAllString(r.Job, "-") + "-" + r.Start.Format("20060102-1504") + ".md"
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", r.Job)
+	fmt.Fprintf(&b, "- Started: %s\n", r.Start.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Duration: %s\n", r.Duration)
+	fmt.Fprintf(&b, "- Status: %s\n", r.Status)
+	if r.Cost > 0 {
+		fmt.Fprintf(&b, "- Cost: $%.4f\n", r.Cost)
+	}
+	if r.PR != "" {
+		fmt.Fprintf(&b, "- Pull request: %s\n", r.PR)
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, "- Error: %s\n", r.Error)
+	}
+	if out := strings.TrimSpace(r.Output); out != "" {
+		fmt.Fprintf(&b, "\n## Output\n\n```\n%s\n```\n", out)
+	}
+
+	path := filepath.Join(dir, name)
+	return path, os.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// ShouldNotify applies a job's notify policy: "failure" (default),
+// "always" or "never".
+func ShouldNotify(policy string, r Result) bool {
+	switch policy {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return r.Status == StatusFailed
+	}
+}
+
+// CommandArgs splits a job's command line into gptcode arguments, honouring
+// single and double quotes. A leading "gptcode" is dropped.
+func CommandArgs(command string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", command)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	if len(args) > 0 && args[0] == "gptcode" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
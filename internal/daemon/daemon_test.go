@@ -0,0 +1,145 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/config"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// Wednesday 2026-10-14 10:30
+	from := time.Date(2026, 10, 14, 10, 30, 0, 0, time.UTC)
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"hourly", time.Date(2026, 10, 14, 11, 0, 0, 0, time.UTC)},
+		{"nightly", time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)},
+		{"monthly", time.Date(2026, 11, 1, 4, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 14, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * 1-5", time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)},
+		{"30 10 * * 7", time.Date(2026, 10, 18, 10, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 12 1 * 5", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		s, err := ParseSchedule(c.spec)
+		if err != nil {
+			t.Fatalf("ParseSchedule(%q): %v", c.spec, err)
+		}
+		if got := s.Next(from); !got.Equal(c.want) {
+			t.Errorf("%q: Next = %s, want %s", c.spec, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"", "every day", "60 * * * *", "* * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseSchedule(bad); err == nil {
+			t.Errorf("ParseSchedule(%q) should fail", bad)
+		}
+	}
+	if s, _ := ParseSchedule("0 0 30 2 *"); !s.Next(from).IsZero() {
+		t.Error("a schedule that never fires should return the zero time")
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 14, 1, 0, 0, 0, time.UTC)
+	var ran []string
+	run := func(ctx context.Context, job config.DaemonJob) Result {
+		ran = append(ran, job.Name)
+		if job.Name == "scan" {
+			return Result{Status: StatusFailed, Error: "boom", Output: "scanning"}
+		}
+		return Result{Status: StatusOK}
+	}
+	var notified []string
+	notify := func(job config.DaemonJob, r Result) { notified = append(notified, r.Job) }
+	jobs := []config.DaemonJob{
+		{Name: "scan", Schedule: "nightly", Command: "security scan"},
+		{Name: "docs", Schedule: "monthly", Command: "docs update", Notify: "never"},
+	}
+	newScheduler := func() *Scheduler {
+		s, err := NewScheduler(jobs, dir, run, notify)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.now = func() time.Time { return now }
+		return s
+	}
+
+	s := newScheduler()
+	// New jobs are only recorded
+	if results, err := s.Tick(context.Background()); err != nil || len(results) != 0 {
+		t.Fatalf("first tick ran %v, %v", results, err)
+	}
+
+	// The next day, after a restart, the nightly job is due once
+	now = now.Add(26 * time.Hour)
+	s = newScheduler()
+	results, err := s.Tick(context.Background())
+	if err != nil || len(results) != 1 || results[0].Job != "scan" || results[0].Status != StatusFailed {
+		t.Fatalf("expected the nightly scan to run, got %+v, %v", results, err)
+	}
+	if len(notified) != 1 {
+		t.Errorf("a failure should notify, got %v", notified)
+	}
+	report, err := os.ReadFile(results[0].Report)
+	if err != nil || !strings.Contains(string(report), "- Error: boom") || !strings.Contains(string(report), "scanning") {
+		t.Errorf("unexpected report %q, %v", report, err)
+	}
+	if results, _ := s.Tick(context.Background()); len(results) != 0 {
+		t.Errorf("the scan already ran today, got %+v", results)
+	}
+
+	s = newScheduler()
+	if last := s.Last("scan"); last == nil || last.Error != "boom" {
+		t.Errorf("last result not persisted: %+v", last)
+	}
+	if _, err := NewScheduler(append(jobs, jobs[0]), dir, run, nil); err == nil {
+		t.Error("duplicate job names should be rejected")
+	}
+	if len(ran) != 1 {
+		t.Errorf("ran %v", ran)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	n := NewNotifier(config.DaemonNotify{Webhook: srv.URL})
+	r := Result{Job: "deps", Status: StatusFailed, Duration: time.Minute, Error: "budget exceeded\ndetails"}
+	if err := n.Notify("app", r); err != nil {
+		t.Fatal(err)
+	}
+	if got["text"] != `gptcode daemon: app job "deps" failed after 1m0s: budget exceeded` {
+		t.Errorf("unexpected message %q", got["text"])
+	}
+
+	if ShouldNotify("", Result{Status: StatusOK}) || !ShouldNotify("always", Result{Status: StatusOK}) || ShouldNotify("never", r) {
+		t.Error("notify policy not applied")
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	args, err := CommandArgs(`gptcode do "update the changelog" --max-cost 1`)
+	if err != nil || strings.Join(args, "|") != "do|update the changelog|--max-cost|1" {
+		t.Errorf("CommandArgs = %q, %v", args, err)
+	}
+	if _, err := CommandArgs(`do "unterminated`); err == nil {
+		t.Error("an unterminated quote should fail")
+	}
+}
@@ -0,0 +1,91 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"gptcode/internal/config"
+	"gptcode/internal/httpclient"
+)
+
+// Notifier sends job notifications to a webhook and the desktop.
+type Notifier struct {
+	cfg    config.DaemonNotify
+	client *http.Client
+	// desktop shows a notification; replaced in tests
+	desktop func(title, message string) error
+}
+
+// NewNotifier returns a notifier for cfg.
+func NewNotifier(cfg config.DaemonNotify) *Notifier {
+	return &Notifier{cfg: cfg, client: httpclient.Default(), desktop: desktopNotify}
+}
+
+// Message is the one-paragraph summary a notification carries.
+func Message(project string, r Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "gptcode daemon: %s job %q %s after %s", project, r.Job, r.Status, r.Duration)
+	if r.Error != "" {
+		fmt.Fprintf(&b, ": %s", strings.SplitN(r.Error, "\n", 2)[0])
+	}
+	if r.PR != "" {
+		fmt.Fprintf(&b, "\nPR: %s", r.PR)
+	}
+	if r.Report != "" {
+		fmt.Fprintf(&b, "\nReport: %s", r.Report)
+	}
+	return b.String()
+}
+
+// Notify sends r to every configured destination, returning the first
+// error.
+func (n *Notifier) Notify(project string, r Result) error {
+	msg := Message(project, r)
+	var firstErr error
+	if n.cfg.Webhook != "" {
+		if err := n.post(msg); err != nil {
+			firstErr = err
+		}
+	}
+	if n.cfg.Desktop {
+		if err := n.desktop("gptcode daemon", strings.SplitN(msg, "\n", 2)[0]); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (n *Notifier) post(msg string) error {
+	body, err := json.Marshal(map[string]string{"text": msg})
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.cfg.Webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+func desktopNotify(title, message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e",
+			fmt.Sprintf("display notification %q with title %q", message, title))
+	case "linux":
+		cmd = exec.Command("notify-send", title, message)
+	default:
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	return cmd.Run()
+}
@@ -0,0 +1,146 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scheduleAliases are the named schedules a job may use instead of a cron
+// expression. Nightly and weekly jobs run at night, when nobody is waiting
+// on the machine.
+var scheduleAliases = map[string]string{
+	"hourly":  "0 * * * *",
+	"daily":   "0 2 * * *",
+	"nightly": "0 2 * * *",
+	"weekly":  "0 3 * * 1",
+	"monthly": "0 4 1 * *",
+}
+
+// Schedule is a parsed cron expression: minute, hour, day of month, month
+// and day of week. Each field is a set of allowed values.
+type Schedule struct {
+	spec                     string
+	minute, hour, dom, month uint64
+	dow                      uint64
+	domAny, dowAny           bool
+}
+
+// ParseSchedule parses a 5-field cron expression ("30 2 * * 1-5") or one of
+// hourly, daily, nightly, weekly and monthly, with or without a leading @.
+// Fields accept *, lists (1,15), ranges (1-5) and steps (*/15, 0-30/10);
+// day of week runs from 0 (Sunday) to 7 (Sunday again).
+func ParseSchedule(spec string) (*Schedule, error) {
+	expr := strings.TrimSpace(spec)
+	if alias, ok := scheduleAliases[strings.ToLower(strings.TrimPrefix(expr, "@"))]; ok {
+		expr = alias
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 cron fields or hourly, daily, nightly, weekly, monthly", spec)
+	}
+
+	s := &Schedule{spec: spec, domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	bounds := []struct {
+		set      *uint64
+		min, max int
+		name     string
+	}{
+		{&s.minute, 0, 59, "minute"},
+		{&s.hour, 0, 23, "hour"},
+		{&s.dom, 1, 31, "day of month"},
+		{&s.month, 1, 12, "month"},
+		{&s.dow, 0, 7, "day of week"},
+	}
+	for i, b := range bounds {
+		set, err := parseField(fields[i], b.min, b.max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %s: %w", spec, b.name, err)
+		}
+		*b.set = set
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+func parseField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("bad value %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (s *Schedule) String() string { return s.spec }
+
+// dayMatches applies cron's rule that when both day fields are restricted,
+// a day matching either of them is a match.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time after t the schedule fires, in t's location,
+// or the zero time when it never does (e.g. "0 0 30 2 *").
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
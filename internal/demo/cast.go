@@ -0,0 +1,143 @@
+package demo
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Cast is an asciinema recording: the terminal size and the output
+// written to it over time.
+type Cast struct {
+	Cols, Rows int
+	Title      string
+	Timestamp  int64
+	Events     []Event
+}
+
+// Event is output written At seconds after the recording started.
+type Event struct {
+	At   float64
+	Data string
+}
+
+// castHeader is the first line of an asciinema v2 cast
+type castHeader struct {
+	Version       int               `json:"version"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Timestamp     int64             `json:"timestamp,omitempty"`
+	Title         string            `json:"title,omitempty"`
+	IdleTimeLimit float64           `json:"idle_time_limit,omitempty"`
+	Env           map[string]string `json:"env,omitempty"`
+	// Term holds the size in v3 casts
+	Term *struct {
+		Cols int `json:"cols"`
+		Rows int `json:"rows"`
+	} `json:"term,omitempty"`
+}
+
+// Text returns everything written to the terminal.
+func (c *Cast) Text() string {
+	var b strings.Builder
+	for _, e := range c.Events {
+		b.WriteString(e.Data)
+	}
+	return b.String()
+}
+
+// Write writes the cast in asciinema v2 format, which asciinema play, the
+// asciinema web player and agg all read.
+func (c *Cast) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	header := castHeader{Version: 2, Width: c.Cols, Height: c.Rows, Timestamp: c.Timestamp, Title: c.Title,
+		IdleTimeLimit: 2, Env: map[string]string{"TERM": "xterm-256color"}}
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	for _, e := range c.Events {
+		if err := enc.Encode([]any{roundTime(e.At), "o", e.Data}); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func roundTime(t float64) float64 {
+	return float64(int64(t*1e6+0.5)) / 1e6
+}
+
+// Save writes the cast to path.
+func (c *Cast) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := c.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadCast reads an asciinema v2 or v3 cast. Input events are skipped.
+func ReadCast(r io.Reader) (*Cast, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	if !sc.Scan() {
+		return nil, fmt.Errorf("empty cast")
+	}
+	var h castHeader
+	if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("invalid cast header: %w", err)
+	}
+	c := &Cast{Cols: h.Width, Rows: h.Height, Title: h.Title, Timestamp: h.Timestamp}
+	switch h.Version {
+	case 2:
+	case 3:
+		if h.Term != nil {
+			c.Cols, c.Rows = h.Term.Cols, h.Term.Rows
+		}
+	default:
+		return nil, fmt.Errorf("unsupported cast version %d", h.Version)
+	}
+
+	at := 0.0
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var ev []any
+		if err := json.Unmarshal([]byte(line), &ev); err != nil || len(ev) < 3 {
+			return nil, fmt.Errorf("invalid cast event %q", line)
+		}
+		t, _ := ev[0].(float64)
+		// v3 stores the interval since the previous event
+		if h.Version == 3 {
+			at += t
+		} else {
+			at = t
+		}
+		kind, _ := ev[1].(string)
+		data, _ := ev[2].(string)
+		if kind == "o" {
+			c.Events = append(c.Events, Event{At: at, Data: data})
+		}
+	}
+	return c, sc.Err()
+}
+
+// LoadCast reads the cast at path.
+func LoadCast(path string) (*Cast, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCast(f)
+}
@@ -0,0 +1,139 @@
+package demo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/gif"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseScript(t *testing.T) {
+	s, err := ParseScript([]byte("steps:\n  - type: echo hi\n  - expect: hi\n    timeout: 2s\n  - key: ctrl-c\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Cols != 100 || s.Rows != 30 || s.Shell != "/bin/sh" || s.Steps[1].Timeout != 2*time.Second {
+		t.Errorf("unexpected script %+v", s)
+	}
+	for _, bad := range []string{
+		"steps: []",
+		"steps:\n  - type: a\n    expect: b\n",
+		"steps:\n  - key: hyper-x\n",
+	} {
+		if _, err := ParseScript([]byte(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+	for _, name := range BuiltinNames() {
+		if _, err := Builtin(name); err != nil {
+			t.Errorf("built-in %s: %v", name, err)
+		}
+	}
+}
+
+func TestCastRoundTrip(t *testing.T) {
+	c := &Cast{Cols: 80, Rows: 24, Title: "demo", Events: []Event{{0.5, "$ "}, {1.25, "ls\r\n✅ done"}}}
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadCast(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cols != 80 || got.Title != "demo" || len(got.Events) != 2 || got.Events[1] != c.Events[1] {
+		t.Errorf("round trip changed the cast: %+v", got)
+	}
+
+	// v3 casts store intervals and may carry input events
+	v3 := `{"version":3,"term":{"cols":90,"rows":20}}
+[0.5,"o","a"]
+[0.1,"i","x"]
+[0.25,"o","b"]
+`
+	got, err = ReadCast(strings.NewReader(v3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cols != 90 || got.Text() != "ab" || got.Events[1].At != 0.85 {
+		t.Errorf("unexpected v3 cast %+v", got)
+	}
+}
+
+func TestScreen(t *testing.T) {
+	s := newScreen(10, 3)
+	s.write("hello\r\n\x1b[31mred\x1b[0m\x1b[1;8HX\x1b[2;1H\x1b[Kok")
+	line := func(y int) string {
+		var b strings.Builder
+		for _, c := range s.cells[y*s.cols : (y+1)*s.cols] {
+			b.WriteRune(c.r)
+		}
+		return strings.TrimRight(b.String(), " ")
+	}
+	if line(0) != "hello  X" || line(1) != "ok" {
+		t.Errorf("unexpected screen %q %q", line(0), line(1))
+	}
+
+	s.write("\x1b[3;1Hone\r\ntwo")
+	if line(0) != "ok" || line(1) != "one" || line(2) != "two" {
+		t.Errorf("expected a scroll, got %q %q %q", line(0), line(1), line(2))
+	}
+	s.write("\x1b[2J\x1b[H\x1b[38;5;196mx\x1b[48;2;0;0;0my")
+	if c := s.cells[0]; c.fg != colorANSI+9 && c.fg != colorANSI+1 {
+		t.Errorf("a 256 color red should map to ANSI red, got %d", c.fg)
+	}
+	if c := s.cells[1]; c.bg != colorANSI {
+		t.Errorf("true color black should map to ANSI black, got %d", c.bg)
+	}
+}
+
+func TestWriteGIF(t *testing.T) {
+	c := &Cast{Cols: 20, Rows: 4, Events: []Event{{0, "$ "}, {0.1, "l"}, {0.2, "s"}, {10, "\r\nfile.go\r\n$ "}}}
+	var buf bytes.Buffer
+	if err := WriteGIF(c, &buf); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 4 || g.Config.Width != 20*7+2*padding {
+		t.Fatalf("unexpected GIF: %d frames, %dpx wide", len(g.Image), g.Config.Width)
+	}
+	// The long pause before the last frame is capped
+	if g.Delay[2] != int(IdleTimeLimit*100) {
+		t.Errorf("expected the pause capped at %v, got %d", IdleTimeLimit, g.Delay[2])
+	}
+}
+
+func TestRecord(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	s, err := ParseScript([]byte(`
+typing_delay: 1ms
+steps:
+  - type: echo "$((6 * 7))"
+  - expect: "42"
+    timeout: 10s
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cast, err := Record(context.Background(), s)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, cast.Text())
+	}
+	if !strings.Contains(cast.Text(), "42") || cast.Cols != 100 {
+		t.Errorf("unexpected recording %q", cast.Text())
+	}
+
+	s.Steps[1].Expect, s.Steps[1].Timeout = "43", 200*time.Millisecond
+	if _, err := RecordTakes(context.Background(), s, 2); !errors.Is(err, ErrExpectTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package demo
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// ansiColors is the 16 color ANSI palette (Dracula, as the docs demos use)
+var ansiColors = []color.RGBA{
+	{0x21, 0x22, 0x2c, 0xff}, {0xff, 0x55, 0x55, 0xff}, {0x50, 0xfa, 0x7b, 0xff}, {0xf1, 0xfa, 0x8c, 0xff},
+	{0xbd, 0x93, 0xf9, 0xff}, {0xff, 0x79, 0xc6, 0xff}, {0x8b, 0xe9, 0xfd, 0xff}, {0xf8, 0xf8, 0xf2, 0xff},
+	{0x62, 0x72, 0xa4, 0xff}, {0xff, 0x6e, 0x6e, 0xff}, {0x69, 0xff, 0x94, 0xff}, {0xff, 0xff, 0xa5, 0xff},
+	{0xd6, 0xac, 0xff, 0xff}, {0xff, 0x92, 0xdf, 0xff}, {0xa4, 0xff, 0xff, 0xff}, {0xff, 0xff, 0xff, 0xff},
+}
+
+var palette = func() color.Palette {
+	p := color.Palette{color.RGBA{0x28, 0x2a, 0x36, 0xff}, color.RGBA{0xf8, 0xf8, 0xf2, 0xff}}
+	for _, c := range ansiColors {
+		p = append(p, c)
+	}
+	return p
+}()
+
+const (
+	// IdleTimeLimit caps pauses in the GIF, in seconds
+	IdleTimeLimit = 2.0
+	// frames closer than this are merged, in seconds
+	minFrameGap = 0.02
+	// how long the last frame stays before the GIF loops, in 1/100s
+	lastFrameDelay = 300
+	padding        = 10
+)
+
+// SaveGIF renders the cast as a GIF at path. It uses agg when it is on the
+// PATH, for full Unicode and font support, and the built-in renderer
+// otherwise; builtin forces the built-in renderer.
+func SaveGIF(c *Cast, path string, builtin bool) error {
+	if agg, err := exec.LookPath("agg"); err == nil && !builtin {
+		tmp, err := os.CreateTemp(filepath.Dir(path), ".demo-*.cast")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if err := c.Write(tmp); err != nil {
+			tmp.Close()
+			return err
+		}
+		tmp.Close()
+		out, err := exec.Command(agg, "--theme", "dracula", "--idle-time-limit", fmt.Sprint(IdleTimeLimit), tmp.Name(), path).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("agg failed: %w: %s", err, out)
+		}
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteGIF(c, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteGIF renders the cast as an animated GIF with the built-in terminal
+// emulator and a 7x13 bitmap font. Characters the font lacks are drawn as
+// blocks.
+func WriteGIF(c *Cast, w io.Writer) error {
+	if c.Cols <= 0 || c.Rows <= 0 {
+		return fmt.Errorf("invalid terminal size %dx%d", c.Cols, c.Rows)
+	}
+	r := &gifRenderer{face: basicfont.Face7x13, cols: c.Cols, rows: c.Rows}
+	r.bounds = image.Rect(0, 0, c.Cols*r.face.Advance+2*padding, c.Rows*r.face.Height+2*padding)
+	scr := newScreen(c.Cols, c.Rows)
+	anim := &gif.GIF{Config: image.Config{ColorModel: palette, Width: r.bounds.Dx(), Height: r.bounds.Dy()}}
+
+	// Pauses are capped at IdleTimeLimit, like asciinema play does
+	var at, last float64
+	var startCs []int
+	emit := func() {
+		if img := r.frame(scr.snapshot()); img != nil {
+			anim.Image = append(anim.Image, img)
+			anim.Disposal = append(anim.Disposal, gif.DisposalNone)
+			startCs = append(startCs, int(at*100+0.5))
+		}
+	}
+	for i, e := range c.Events {
+		at += min(max(e.At-last, 0), IdleTimeLimit)
+		last = e.At
+		scr.write(e.Data)
+		if i+1 < len(c.Events) && c.Events[i+1].At-e.At < minFrameGap {
+			continue
+		}
+		emit()
+	}
+	if len(anim.Image) == 0 {
+		emit()
+	}
+	for i := range anim.Image {
+		delay := lastFrameDelay
+		if i+1 < len(startCs) {
+			delay = max(startCs[i+1]-startCs[i], 2)
+		}
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// boxDrawing maps the box drawing characters tables and spinners use to
+// ASCII, which the bitmap font covers.
+var boxDrawing = map[rune]rune{
+	'─': '-', '━': '-', '│': '|', '┃': '|', '┌': '+', '┐': '+', '└': '+', '┘': '+',
+	'├': '+', '┤': '+', '┬': '+', '┴': '+', '┼': '+', '╭': '+', '╮': '+', '╰': '+', '╯': '+',
+	'•': '*', '…': '.', '→': '>', '←': '<', '✓': 'v', '✗': 'x',
+}
+
+type gifRenderer struct {
+	face       *basicfont.Face
+	cols, rows int
+	bounds     image.Rectangle
+	prev       []cell
+}
+
+// frame draws the cells that changed since the previous frame, returning
+// nil when nothing changed.
+func (r *gifRenderer) frame(cells []cell) *image.Paletted {
+	minX, minY, maxX, maxY := r.cols, r.rows, -1, -1
+	for i, c := range cells {
+		if r.prev != nil && r.prev[i] == c {
+			continue
+		}
+		x, y := i%r.cols, i/r.cols
+		minX, minY, maxX, maxY = min(minX, x), min(minY, y), max(maxX, x), max(maxY, y)
+	}
+	if maxX < 0 {
+		return nil
+	}
+
+	var rect image.Rectangle
+	if r.prev == nil {
+		rect = r.bounds
+	} else {
+		rect = image.Rect(padding+minX*r.face.Advance, padding+minY*r.face.Height,
+			padding+(maxX+1)*r.face.Advance, padding+(maxY+1)*r.face.Height)
+	}
+	img := image.NewPaletted(rect, palette)
+	for i := range img.Pix {
+		img.Pix[i] = colorBackground
+	}
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			r.drawCell(img, x, y, cells[y*r.cols+x])
+		}
+	}
+	r.prev = cells
+	return img
+}
+
+func (r *gifRenderer) drawCell(img *image.Paletted, x, y int, c cell) {
+	cellRect := image.Rect(padding+x*r.face.Advance, padding+y*r.face.Height,
+		padding+(x+1)*r.face.Advance, padding+(y+1)*r.face.Height)
+	for py := cellRect.Min.Y; py < cellRect.Max.Y; py++ {
+		for px := cellRect.Min.X; px < cellRect.Max.X; px++ {
+			img.SetColorIndex(px, py, c.bg)
+		}
+	}
+	if c.r == ' ' {
+		return
+	}
+	ch := c.r
+	if ascii, ok := boxDrawing[ch]; ok {
+		ch = ascii
+	}
+	dot := fixed.P(cellRect.Min.X, cellRect.Min.Y+r.face.Ascent)
+	dr, mask, mp, _, ok := r.face.Glyph(dot, ch)
+	if !ok {
+		// Outside the font: a block the size of a lowercase letter
+		for py := cellRect.Min.Y + 4; py < cellRect.Max.Y-3; py++ {
+			for px := cellRect.Min.X + 1; px < cellRect.Max.X-1; px++ {
+				img.SetColorIndex(px, py, c.fg)
+			}
+		}
+		return
+	}
+	for py := dr.Min.Y; py < dr.Max.Y; py++ {
+		for px := dr.Min.X; px < dr.Max.X; px++ {
+			if _, _, _, a := mask.At(mp.X+px-dr.Min.X, mp.Y+py-dr.Min.Y).RGBA(); a > 0x7fff {
+				img.SetColorIndex(px, py, c.fg)
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package demo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/creack/pty"
+)
+
+// ErrExpectTimeout fails a take whose output never showed an expected text
+var ErrExpectTimeout = errors.New("expected output did not appear")
+
+const defaultExpectTimeout = 30 * time.Second
+
+// session is a shell running in a pseudo terminal, recorded as it runs
+type session struct {
+	script *Script
+	tty    *os.File
+	cmd    *exec.Cmd
+	start  time.Time
+
+	mu      sync.Mutex
+	events  []Event
+	text    strings.Builder // output with escape sequences removed
+	matched int             // end of the last expect match in text
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// Record runs the script once and returns the recording. A take whose
+// expected output does not appear returns the partial recording with an
+// error wrapping ErrExpectTimeout.
+func Record(ctx context.Context, s *Script) (*Cast, error) {
+	cmd := exec.Command(s.Shell)
+	// A bare prompt and no rc files, so every take looks the same
+	cmd.Env = append(os.Environ(), "PS1=$ ", "ENV=", "HISTFILE=", "TERM=xterm-256color")
+	for k, v := range s.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	tty, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(s.Cols), Rows: uint16(s.Rows)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s in a terminal: %w", s.Shell, err)
+	}
+	sess := &session{script: s, tty: tty, cmd: cmd, start: time.Now(),
+		changed: make(chan struct{}, 1), done: make(chan struct{})}
+	go sess.read()
+
+	runErr := sess.run(ctx)
+	sess.stop()
+
+	cast := &Cast{Cols: s.Cols, Rows: s.Rows, Title: s.Title, Timestamp: sess.start.Unix()}
+	sess.mu.Lock()
+	cast.Events = sess.events
+	sess.mu.Unlock()
+	return cast, runErr
+}
+
+// RecordTakes records the script up to tries times, until a take shows
+// all of its expected output.
+func RecordTakes(ctx context.Context, s *Script, tries int) (*Cast, error) {
+	if tries < 1 {
+		tries = 1
+	}
+	var err error
+	for i := 0; i < tries; i++ {
+		var cast *Cast
+		cast, err = Record(ctx, s)
+		if err == nil {
+			return cast, nil
+		}
+		if !errors.Is(err, ErrExpectTimeout) || ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("no good take in %d tries: %w", tries, err)
+}
+
+var escapeSequence = regexp.MustCompile(`\x1b(\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|[@-Z\\-_])`)
+
+func (s *session) read() {
+	defer close(s.done)
+	buf := make([]byte, 4096)
+	var pending []byte
+	for {
+		n, err := s.tty.Read(buf)
+		if n > 0 {
+			pending = append(pending, buf[:n]...)
+			// Keep a UTF-8 sequence split across reads for the next event
+			cut := len(pending)
+			for i := 1; i <= 3 && i <= len(pending); i++ {
+				if b := pending[len(pending)-i]; b >= 0xc0 {
+					if !utf8.FullRune(pending[len(pending)-i:]) {
+						cut = len(pending) - i
+					}
+					break
+				}
+			}
+			data := string(pending[:cut])
+			pending = append([]byte(nil), pending[cut:]...)
+			if data != "" {
+				s.mu.Lock()
+				s.events = append(s.events, Event{At: time.Since(s.start).Seconds(), Data: data})
+				s.text.WriteString(strings.ReplaceAll(escapeSequence.ReplaceAllString(data, ""), "\r", ""))
+				s.mu.Unlock()
+				select {
+				case s.changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *session) run(ctx context.Context) error {
+	for i, step := range s.script.Steps {
+		var err error
+		switch {
+		case step.Type != "":
+			err = s.typeText(ctx, step.Type+"\r")
+		case step.Send != "":
+			err = s.typeText(ctx, step.Send)
+		case step.Key != "":
+			b, _ := keyBytes(step.Key)
+			_, err = s.tty.Write(b)
+		case step.Expect != "":
+			err = s.expect(ctx, step.Expect, step.Timeout)
+		case step.Sleep != 0:
+			err = sleep(ctx, step.Sleep)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	// Let the last screen settle before the shell exits
+	return sleep(ctx, 500*time.Millisecond)
+}
+
+func (s *session) typeText(ctx context.Context, text string) error {
+	for _, r := range text {
+		if _, err := s.tty.Write([]byte(string(r))); err != nil {
+			return err
+		}
+		if err := sleep(ctx, s.script.TypingDelay); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expect waits for text to appear after the previous match
+func (s *session) expect(ctx context.Context, text string, timeout time.Duration) error {
+	if timeout == 0 {
+		timeout = defaultExpectTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		s.mu.Lock()
+		seen := s.text.String()[s.matched:]
+		if i := strings.Index(seen, text); i >= 0 {
+			s.matched += i + len(text)
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.changed:
+		case <-s.done:
+			return fmt.Errorf("%w: %q (the shell exited)", ErrExpectTimeout, text)
+		case <-deadline.C:
+			return fmt.Errorf("%w: %q within %s", ErrExpectTimeout, text, timeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// stop ends the shell with Ctrl+D, killing it if it does not exit
+func (s *session) stop() {
+	_, _ = s.tty.Write([]byte{4})
+	exited := make(chan struct{})
+	go func() {
+		_ = s.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(3 * time.Second):
+		_ = s.cmd.Process.Kill()
+		<-exited
+	}
+	_ = s.tty.Close()
+	<-s.done
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
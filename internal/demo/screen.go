@@ -0,0 +1,433 @@
+package demo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Colors are indexes into the GIF palette: the default background and
+// foreground, then the 16 ANSI colors.
+const (
+	colorBackground uint8 = iota
+	colorForeground
+	colorANSI
+)
+
+type cell struct {
+	r      rune
+	fg, bg uint8
+}
+
+// screen is a small VT100/xterm emulator, enough to replay the output of
+// shells and command line tools: text, cursor movement, erasing, scrolling
+// and SGR colors. Other sequences are parsed and ignored.
+type screen struct {
+	cols, rows int
+	cells      []cell
+	x, y       int
+	savedX     int
+	savedY     int
+	wrapNext   bool
+	hidden     bool // cursor hidden
+
+	fg, bg         uint8
+	bold, reverse  bool
+	state          int
+	params         strings.Builder
+	top, bottom    int // scroll region rows
+	pendingPrivate bool
+}
+
+const (
+	stateGround = iota
+	stateEscape
+	stateCSI
+	stateOSC
+	stateOSCEscape
+	stateCharset
+)
+
+func newScreen(cols, rows int) *screen {
+	s := &screen{cols: cols, rows: rows, cells: make([]cell, cols*rows), bottom: rows - 1}
+	s.resetAttrs()
+	s.erase(0, len(s.cells))
+	return s
+}
+
+func (s *screen) resetAttrs() {
+	s.fg, s.bg, s.bold, s.reverse = colorForeground, colorBackground, false, false
+}
+
+// snapshot returns the visible cells, with the cursor drawn in reverse
+func (s *screen) snapshot() []cell {
+	out := append([]cell(nil), s.cells...)
+	if !s.hidden && s.x < s.cols {
+		c := &out[s.y*s.cols+s.x]
+		c.fg, c.bg = c.bg, c.fg
+	}
+	return out
+}
+
+func (s *screen) write(data string) {
+	for _, r := range data {
+		s.put(r)
+	}
+}
+
+func (s *screen) put(r rune) {
+	switch s.state {
+	case stateEscape:
+		s.escape(r)
+		return
+	case stateCSI:
+		switch {
+		case r >= 0x40 && r <= 0x7e:
+			s.csi(r)
+			s.state = stateGround
+		case r == '?' || r == '>' || r == '=':
+			s.pendingPrivate = true
+		case r >= 0x20 && r <= 0x3f:
+			s.params.WriteRune(r)
+		default:
+			s.state = stateGround
+		}
+		return
+	case stateOSC:
+		switch r {
+		case 0x07:
+			s.state = stateGround
+		case 0x1b:
+			s.state = stateOSCEscape
+		}
+		return
+	case stateOSCEscape, stateCharset:
+		s.state = stateGround
+		return
+	}
+
+	switch r {
+	case 0x1b:
+		s.state = stateEscape
+	case '\r':
+		s.x, s.wrapNext = 0, false
+	case '\n', 0x0b, 0x0c:
+		s.lineFeed()
+	case '\b':
+		if s.x > 0 {
+			s.x--
+		}
+		s.wrapNext = false
+	case '\t':
+		s.x = min((s.x/8+1)*8, s.cols-1)
+	default:
+		if r < 0x20 || r == 0x7f || isZeroWidth(r) {
+			return
+		}
+		s.print(r)
+	}
+}
+
+func (s *screen) print(r rune) {
+	width := 1
+	if isWide(r) {
+		width = 2
+	}
+	if s.wrapNext || s.x+width > s.cols {
+		s.x, s.wrapNext = 0, false
+		s.lineFeed()
+	}
+	fg, bg := s.fg, s.bg
+	if s.bold && fg >= colorANSI && fg < colorANSI+8 {
+		fg += 8
+	}
+	if s.reverse {
+		fg, bg = bg, fg
+	}
+	i := s.y*s.cols + s.x
+	s.cells[i] = cell{r: r, fg: fg, bg: bg}
+	if width == 2 {
+		s.cells[i+1] = cell{r: ' ', fg: fg, bg: bg}
+	}
+	s.x += width
+	if s.x >= s.cols {
+		s.x, s.wrapNext = s.cols-1, true
+	}
+}
+
+func (s *screen) lineFeed() {
+	s.wrapNext = false
+	if s.y == s.bottom {
+		s.scrollUp(1)
+	} else if s.y < s.rows-1 {
+		s.y++
+	}
+}
+
+func (s *screen) escape(r rune) {
+	s.state = stateGround
+	switch r {
+	case '[':
+		s.state = stateCSI
+		s.params.Reset()
+		s.pendingPrivate = false
+	case ']':
+		s.state = stateOSC
+	case '(', ')', '*', '+':
+		s.state = stateCharset
+	case '7':
+		s.savedX, s.savedY = s.x, s.y
+	case '8':
+		s.x, s.y = s.savedX, s.savedY
+	case 'D':
+		s.lineFeed()
+	case 'E':
+		s.x = 0
+		s.lineFeed()
+	case 'M':
+		if s.y == s.top {
+			s.scrollDown(1)
+		} else if s.y > 0 {
+			s.y--
+		}
+	case 'c':
+		*s = *newScreen(s.cols, s.rows)
+	}
+}
+
+func (s *screen) csi(final rune) {
+	var args []int
+	if p := s.params.String(); p != "" {
+		for _, f := range strings.FieldsFunc(p, func(r rune) bool { return r == ';' || r == ':' }) {
+			n, _ := strconv.Atoi(f)
+			args = append(args, n)
+		}
+	}
+	arg := func(i, def int) int {
+		if i < len(args) && args[i] > 0 {
+			return args[i]
+		}
+		return def
+	}
+	if s.pendingPrivate {
+		// Only cursor visibility matters for a replay
+		if len(args) > 0 && args[0] == 25 && (final == 'h' || final == 'l') {
+			s.hidden = final == 'l'
+		}
+		return
+	}
+
+	s.wrapNext = false
+	switch final {
+	case 'm':
+		s.sgr(args)
+	case 'H', 'f':
+		s.moveTo(arg(1, 1)-1, arg(0, 1)-1)
+	case 'A':
+		s.moveTo(s.x, s.y-arg(0, 1))
+	case 'B', 'e':
+		s.moveTo(s.x, s.y+arg(0, 1))
+	case 'C', 'a':
+		s.moveTo(s.x+arg(0, 1), s.y)
+	case 'D':
+		s.moveTo(s.x-arg(0, 1), s.y)
+	case 'E':
+		s.moveTo(0, s.y+arg(0, 1))
+	case 'F':
+		s.moveTo(0, s.y-arg(0, 1))
+	case 'G', '`':
+		s.moveTo(arg(0, 1)-1, s.y)
+	case 'd':
+		s.moveTo(s.x, arg(0, 1)-1)
+	case 'J':
+		pos := s.y*s.cols + s.x
+		switch arg(0, 0) {
+		case 0:
+			s.erase(pos, len(s.cells))
+		case 1:
+			s.erase(0, pos+1)
+		case 2, 3:
+			s.erase(0, len(s.cells))
+		}
+	case 'K':
+		line := s.y * s.cols
+		switch arg(0, 0) {
+		case 0:
+			s.erase(line+s.x, line+s.cols)
+		case 1:
+			s.erase(line, line+s.x+1)
+		case 2:
+			s.erase(line, line+s.cols)
+		}
+	case 'X':
+		line := s.y * s.cols
+		s.erase(line+s.x, line+min(s.x+arg(0, 1), s.cols))
+	case 'P':
+		line := s.cells[s.y*s.cols : (s.y+1)*s.cols]
+		n := min(arg(0, 1), s.cols-s.x)
+		copy(line[s.x:], line[s.x+n:])
+		s.erase(s.y*s.cols+s.cols-n, (s.y+1)*s.cols)
+	case '@':
+		line := s.cells[s.y*s.cols : (s.y+1)*s.cols]
+		n := min(arg(0, 1), s.cols-s.x)
+		copy(line[s.x+n:], line[s.x:])
+		s.erase(s.y*s.cols+s.x, s.y*s.cols+s.x+n)
+	case 'L', 'M':
+		if s.y < s.top || s.y > s.bottom {
+			return
+		}
+		top := s.top
+		s.top = s.y
+		if final == 'L' {
+			s.scrollDown(arg(0, 1))
+		} else {
+			s.scrollUp(arg(0, 1))
+		}
+		s.top = top
+	case 'S':
+		s.scrollUp(arg(0, 1))
+	case 'T':
+		s.scrollDown(arg(0, 1))
+	case 'r':
+		top, bottom := arg(0, 1)-1, arg(1, s.rows)-1
+		if top < bottom && bottom < s.rows {
+			s.top, s.bottom = top, bottom
+			s.moveTo(0, 0)
+		}
+	case 's':
+		s.savedX, s.savedY = s.x, s.y
+	case 'u':
+		s.x, s.y = s.savedX, s.savedY
+	}
+}
+
+func (s *screen) moveTo(x, y int) {
+	s.x = max(0, min(x, s.cols-1))
+	s.y = max(0, min(y, s.rows-1))
+}
+
+// erase blanks cells [from, to) with the current background
+func (s *screen) erase(from, to int) {
+	bg := s.bg
+	if s.reverse {
+		bg = s.fg
+	}
+	for i := from; i < to; i++ {
+		s.cells[i] = cell{r: ' ', fg: colorForeground, bg: bg}
+	}
+}
+
+func (s *screen) scrollUp(n int) {
+	n = min(n, s.bottom-s.top+1)
+	region := s.cells[s.top*s.cols : (s.bottom+1)*s.cols]
+	copy(region, region[n*s.cols:])
+	s.erase((s.bottom+1-n)*s.cols, (s.bottom+1)*s.cols)
+}
+
+func (s *screen) scrollDown(n int) {
+	n = min(n, s.bottom-s.top+1)
+	region := s.cells[s.top*s.cols : (s.bottom+1)*s.cols]
+	copy(region[n*s.cols:], region)
+	s.erase(s.top*s.cols, (s.top+n)*s.cols)
+}
+
+func (s *screen) sgr(args []int) {
+	if len(args) == 0 {
+		args = []int{0}
+	}
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; {
+		case a == 0:
+			s.resetAttrs()
+		case a == 1:
+			s.bold = true
+		case a == 22:
+			s.bold = false
+		case a == 7:
+			s.reverse = true
+		case a == 27:
+			s.reverse = false
+		case a >= 30 && a <= 37:
+			s.fg = colorANSI + uint8(a-30)
+		case a >= 90 && a <= 97:
+			s.fg = colorANSI + 8 + uint8(a-90)
+		case a == 39:
+			s.fg = colorForeground
+		case a >= 40 && a <= 47:
+			s.bg = colorANSI + uint8(a-40)
+		case a >= 100 && a <= 107:
+			s.bg = colorANSI + 8 + uint8(a-100)
+		case a == 49:
+			s.bg = colorBackground
+		case a == 38 || a == 48:
+			c, used := extendedColor(args[i+1:])
+			i += used
+			if a == 38 {
+				s.fg = c
+			} else {
+				s.bg = c
+			}
+		}
+	}
+}
+
+// extendedColor maps a 256 color (5;n) or true color (2;r;g;b) to the
+// nearest ANSI color, returning how many arguments it used.
+func extendedColor(args []int) (uint8, int) {
+	if len(args) >= 2 && args[0] == 5 {
+		n := args[1]
+		switch {
+		case n < 16:
+			return colorANSI + uint8(n), 2
+		case n < 232:
+			n -= 16
+			level := func(v int) int {
+				if v == 0 {
+					return 0
+				}
+				return 55 + v*40
+			}
+			return nearestANSI(level(n/36), level(n/6%6), level(n%6)), 2
+		default:
+			g := 8 + (n-232)*10
+			return nearestANSI(g, g, g), 2
+		}
+	}
+	if len(args) >= 4 && args[0] == 2 {
+		return nearestANSI(args[1], args[2], args[3]), 4
+	}
+	return colorForeground, len(args)
+}
+
+func nearestANSI(r, g, b int) uint8 {
+	best, bestDist := colorANSI, -1
+	for i, c := range ansiColors {
+		dr, dg, db := r-int(c.R), g-int(c.G), b-int(c.B)
+		if d := dr*dr + dg*dg + db*db; bestDist < 0 || d < bestDist {
+			best, bestDist = colorANSI+uint8(i), d
+		}
+	}
+	return best
+}
+
+// isWide reports runes that terminals draw two cells wide: CJK and emoji
+func isWide(r rune) bool {
+	switch {
+	case r >= 0x1100 && r <= 0x115f,
+		r >= 0x2e80 && r <= 0xa4cf,
+		r >= 0xac00 && r <= 0xd7a3,
+		r >= 0xf900 && r <= 0xfaff,
+		r >= 0xfe30 && r <= 0xfe4f,
+		r >= 0xff00 && r <= 0xff60,
+		r >= 0xffe0 && r <= 0xffe6,
+		r >= 0x1f300 && r <= 0x1f64f,
+		r >= 0x1f680 && r <= 0x1f6ff,
+		r >= 0x1f900 && r <= 0x1f9ff,
+		r == 0x2705, r == 0x274c, r == 0x2728, r == 0x23f3, r == 0x231b:
+		return true
+	}
+	return false
+}
+
+func isZeroWidth(r rune) bool {
+	return r == 0x200d || (r >= 0xfe00 && r <= 0xfe0f) || (r >= 0x300 && r <= 0x36f)
+}
@@ -0,0 +1,143 @@
+// Package demo records scripted terminal sessions as asciinema casts and
+// renders them as GIFs, for the demos in the docs.
+package demo
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in scripts for the demos in docs/assets
+//
+//go:embed scripts/*.yml
+var builtinScripts embed.FS
+
+// Script is a scripted terminal session: a shell started in a pseudo
+// terminal of Cols x Rows, driven by Steps.
+type Script struct {
+	Title string `yaml:"title,omitempty"`
+	Cols  int    `yaml:"cols,omitempty"`
+	Rows  int    `yaml:"rows,omitempty"`
+	// Shell is the program started in the terminal (default /bin/sh)
+	Shell string            `yaml:"shell,omitempty"`
+	Env   map[string]string `yaml:"env,omitempty"`
+	// TypingDelay is the pause between typed characters (default 35ms)
+	TypingDelay time.Duration `yaml:"typing_delay,omitempty"`
+	Steps       []Step        `yaml:"steps"`
+}
+
+// Step is one action of a script. Exactly one of Type, Send, Key, Expect
+// and Sleep is set.
+type Step struct {
+	// Type types the text and presses Enter
+	Type string `yaml:"type,omitempty"`
+	// Send types the text without pressing Enter
+	Send string `yaml:"send,omitempty"`
+	// Key presses a named key: enter, tab, esc, up, down, left, right,
+	// backspace or ctrl-<letter>
+	Key string `yaml:"key,omitempty"`
+	// Expect waits until the text appears in the output since the
+	// previous expect, failing the take after Timeout (default 30s)
+	Expect  string        `yaml:"expect,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Sleep pauses, letting the viewer read the screen
+	Sleep time.Duration `yaml:"sleep,omitempty"`
+}
+
+// LoadScript reads a YAML script from path.
+func LoadScript(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseScript(data)
+}
+
+// ParseScript parses a YAML script and fills in its defaults.
+func ParseScript(data []byte) (*Script, error) {
+	var s Script
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid demo script: %w", err)
+	}
+	if s.Cols == 0 {
+		s.Cols = 100
+	}
+	if s.Rows == 0 {
+		s.Rows = 30
+	}
+	if s.Shell == "" {
+		s.Shell = "/bin/sh"
+	}
+	if s.TypingDelay == 0 {
+		s.TypingDelay = 35 * time.Millisecond
+	}
+	if len(s.Steps) == 0 {
+		return nil, fmt.Errorf("invalid demo script: no steps")
+	}
+	for i, step := range s.Steps {
+		set := 0
+		for _, v := range []bool{step.Type != "", step.Send != "", step.Key != "", step.Expect != "", step.Sleep != 0} {
+			if v {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("invalid demo script: step %d needs exactly one of type, send, key, expect and sleep", i+1)
+		}
+		if step.Key != "" {
+			if _, err := keyBytes(step.Key); err != nil {
+				return nil, fmt.Errorf("invalid demo script: step %d: %w", i+1, err)
+			}
+		}
+	}
+	return &s, nil
+}
+
+// Builtin returns the built-in script with the given name.
+func Builtin(name string) (*Script, error) {
+	data, err := builtinScripts.ReadFile(path.Join("scripts", name+".yml"))
+	if err != nil {
+		return nil, fmt.Errorf("no built-in demo %q", name)
+	}
+	return ParseScript(data)
+}
+
+// BuiltinNames lists the built-in scripts.
+func BuiltinNames() []string {
+	entries, _ := fs.ReadDir(builtinScripts, "scripts")
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yml"))
+	}
+	return names
+}
+
+var namedKeys = map[string]string{
+	"enter":     "\r",
+	"tab":       "\t",
+	"esc":       "\x1b",
+	"backspace": "\x7f",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+}
+
+// keyBytes returns what the terminal sends for a named key
+func keyBytes(name string) ([]byte, error) {
+	name = strings.ToLower(name)
+	if s, ok := namedKeys[name]; ok {
+		return []byte(s), nil
+	}
+	if letter, ok := strings.CutPrefix(name, "ctrl-"); ok && len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' {
+		return []byte{letter[0] - 'a' + 1}, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", name)
+}
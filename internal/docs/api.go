@@ -3,17 +3,10 @@ package docs
 import (
 	"context"
 	"fmt"
-	"go/ast"
-	"go/parser"
-	"go/token"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
-
-	"gptcode/internal/langdetect"
 	"gptcode/internal/llm"
 )
 
@@ -42,10 +35,9 @@ func NewAPIDocGenerator(provider llm.Provider, model, workDir string) *APIDocGen
 	}
 }
 
-func (g *APIDocGenerator) Generate(ctx context.Context, format string) (string, error) {
-	lang := langdetect.DetectLanguage(g.workDir)
-
-	endpoints, err := g.discoverEndpoints(lang)
+// Generate writes Markdown documentation of the routes the code serves
+func (g *APIDocGenerator) Generate(ctx context.Context) (string, error) {
+	endpoints, err := g.discoverEndpoints()
 	if err != nil {
 		return "", fmt.Errorf("failed to discover endpoints: %w", err)
 	}
@@ -54,12 +46,12 @@ func (g *APIDocGenerator) Generate(ctx context.Context, format string) (string,
 		return "", fmt.Errorf("no API endpoints found")
 	}
 
-	doc, err := g.generateDocumentation(ctx, endpoints, format)
+	doc, err := g.generateDocumentation(ctx, endpoints)
 	if err != nil {
 		return "", err
 	}
 
-	filename := g.getOutputFilename(format)
+	filename := filepath.Join(g.workDir, "API.md")
 	if err := os.WriteFile(filename, []byte(doc), 0644); err != nil {
 		return "", fmt.Errorf("failed to write documentation: %w", err)
 	}
@@ -67,163 +59,33 @@ func (g *APIDocGenerator) Generate(ctx context.Context, format string) (string,
 	return filename, nil
 }
 
-func (g *APIDocGenerator) discoverEndpoints(lang langdetect.Language) ([]APIEndpoint, error) {
-	var endpoints []APIEndpoint
-
-	err := filepath.Walk(g.workDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
-		}
-
-		if strings.Contains(path, "vendor/") || strings.Contains(path, "node_modules/") {
-			return nil
-		}
-
-		switch lang {
-		case langdetect.Go:
-			if strings.HasSuffix(path, ".go") && !strings.HasSuffix(path, "_test.go") {
-				eps, _ := g.parseGoFile(path)
-				endpoints = append(endpoints, eps...)
-			}
-		case langdetect.TypeScript:
-			if strings.HasSuffix(path, ".ts") || strings.HasSuffix(path, ".js") {
-				eps, _ := g.parseTypeScriptFile(path)
-				endpoints = append(endpoints, eps...)
-			}
-		}
-
-		return nil
-	})
-
-	return endpoints, err
-}
-
-func (g *APIDocGenerator) parseGoFile(path string) ([]APIEndpoint, error) {
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, path, content, parser.ParseComments)
+func (g *APIDocGenerator) discoverEndpoints() ([]APIEndpoint, error) {
+	routes, err := DiscoverRoutes(g.workDir)
 	if err != nil {
 		return nil, err
 	}
 
 	var endpoints []APIEndpoint
-	httpMethods := []string{"GET", "POST", "PUT", "DELETE", "PATCH"}
-
-	ast.Inspect(node, func(n ast.Node) bool {
-		call, ok := n.(*ast.CallExpr)
-		if !ok {
-			return true
-		}
-
-		sel, ok := call.Fun.(*ast.SelectorExpr)
-		if !ok {
-			return true
-		}
-
-		method := strings.ToUpper(sel.Sel.Name)
-		if !contains(httpMethods, method) {
-			return true
-		}
-
-		if len(call.Args) < 2 {
-			return true
-		}
-
-		pathLit, ok := call.Args[0].(*ast.BasicLit)
-		if !ok {
-			return true
-		}
-
-		handlerIdent, ok := call.Args[1].(*ast.Ident)
-		if !ok {
-			return true
-		}
-
-		endpoint := APIEndpoint{
-			Method:  method,
-			Path:    strings.Trim(pathLit.Value, "\""),
-			Handler: handlerIdent.Name,
-			File:    path,
-			Line:    fset.Position(call.Pos()).Line,
-		}
-
-		endpoints = append(endpoints, endpoint)
-		return true
-	})
-
-	return endpoints, nil
-}
-
-func (g *APIDocGenerator) parseTypeScriptFile(path string) ([]APIEndpoint, error) {
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	var endpoints []APIEndpoint
-	lines := strings.Split(string(content), "\n")
-
-	httpMethods := []string{"get", "post", "put", "delete", "patch"}
-	c := cases.Title(language.English)
-
-	for i, line := range lines {
-		for _, method := range httpMethods {
-			if strings.Contains(line, fmt.Sprintf(".%s(", method)) ||
-				strings.Contains(line, fmt.Sprintf("@%s(", c.String(method))) {
-
-				pathStart := strings.Index(line, "\"")
-				if pathStart == -1 {
-					pathStart = strings.Index(line, "'")
-				}
-				if pathStart == -1 {
-					continue
-				}
-
-				remaining := line[pathStart+1:]
-				pathEnd := strings.IndexAny(remaining, "\"'")
-				if pathEnd == -1 {
-					continue
-				}
-
-				endpoint := APIEndpoint{
-					Method: strings.ToUpper(method),
-					Path:   remaining[:pathEnd],
-					File:   path,
-					Line:   i + 1,
-				}
-				endpoints = append(endpoints, endpoint)
-				break
-			}
-		}
+	for _, r := range routes {
+		endpoints = append(endpoints, APIEndpoint{
+			Method:  r.Method,
+			Path:    r.Path,
+			Handler: r.Handler,
+			File:    r.File,
+			Line:    r.Line,
+		})
 	}
-
 	return endpoints, nil
 }
 
-func (g *APIDocGenerator) generateDocumentation(ctx context.Context, endpoints []APIEndpoint, format string) (string, error) {
+func (g *APIDocGenerator) generateDocumentation(ctx context.Context, endpoints []APIEndpoint) (string, error) {
 	endpointList := g.formatEndpointList(endpoints)
 
-	var formatInstruction string
-	switch format {
-	case "openapi":
-		formatInstruction = "Generate OpenAPI 3.0 specification in YAML format"
-	case "markdown":
-		formatInstruction = "Generate Markdown documentation with clear sections"
-	case "postman":
-		formatInstruction = "Generate Postman Collection v2.1 in JSON format"
-	default:
-		formatInstruction = "Generate Markdown documentation"
-	}
-
 	prompt := fmt.Sprintf(`Generate API documentation for these endpoints:
 
 %s
 
-%s.
+Generate Markdown documentation with clear sections.
 
 Include:
 - Full endpoint details
@@ -231,7 +93,7 @@ Include:
 - Authentication requirements (if detected)
 - Error responses
 
-Return ONLY the documentation, no explanations.`, endpointList, formatInstruction)
+Return ONLY the documentation, no explanations.`, endpointList)
 
 	resp, err := g.provider.Chat(ctx, llm.ChatRequest{
 		SystemPrompt: "You are an API documentation expert that generates comprehensive, professional documentation.",
@@ -243,7 +105,7 @@ Return ONLY the documentation, no explanations.`, endpointList, formatInstructio
 		return "", err
 	}
 
-	return g.extractDoc(resp.Text, format), nil
+	return g.extractDoc(resp.Text), nil
 }
 
 func (g *APIDocGenerator) formatEndpointList(endpoints []APIEndpoint) string {
@@ -261,45 +123,17 @@ func (g *APIDocGenerator) formatEndpointList(endpoints []APIEndpoint) string {
 	return builder.String()
 }
 
-func (g *APIDocGenerator) getOutputFilename(format string) string {
-	switch format {
-	case "openapi":
-		return filepath.Join(g.workDir, "api-spec.yaml")
-	case "postman":
-		return filepath.Join(g.workDir, "api-collection.json")
-	default:
-		return filepath.Join(g.workDir, "API.md")
-	}
-}
-
-func (g *APIDocGenerator) extractDoc(text, format string) string {
+func (g *APIDocGenerator) extractDoc(text string) string {
 	text = strings.TrimSpace(text)
 
-	markers := map[string][]string{
-		"openapi":  {"```yaml", "```yml", "```"},
-		"postman":  {"```json", "```"},
-		"markdown": {"```markdown", "```md", "```"},
-	}
-
-	if prefixes, ok := markers[format]; ok {
-		for _, marker := range prefixes {
-			if strings.HasPrefix(text, marker) {
-				text = strings.TrimPrefix(text, marker)
-				text = strings.TrimPrefix(text, "\n")
-				text = strings.TrimSuffix(text, "```")
-				break
-			}
+	for _, marker := range []string{"```markdown", "```md", "```"} {
+		if strings.HasPrefix(text, marker) {
+			text = strings.TrimPrefix(text, marker)
+			text = strings.TrimPrefix(text, "\n")
+			text = strings.TrimSuffix(text, "```")
+			break
 		}
 	}
 
 	return strings.TrimSpace(text)
 }
-
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if strings.EqualFold(s, item) {
-			return true
-		}
-	}
-	return false
-}
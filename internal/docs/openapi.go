@@ -0,0 +1,522 @@
+package docs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"gptcode/internal/apigen"
+	"gptcode/internal/contractgen"
+	"gptcode/internal/graph"
+	"gptcode/internal/llm"
+)
+
+// OpenAPIVersion is the version of the documents OpenAPIGenerator writes
+const OpenAPIVersion = "3.1.0"
+
+// openAPIBatch is how many routes one inference request covers
+const openAPIBatch = 8
+
+// Route is an endpoint served by the code, with its path in OpenAPI form
+type Route struct {
+	// Method is upper case, "*" for routes accepting any method
+	Method  string
+	Path    string
+	Handler string
+	File    string
+	Line    int
+	// Source is the handler's definition, when it was found
+	Source string
+}
+
+func (r Route) String() string {
+	return fmt.Sprintf("%s %s", r.Method, r.Path)
+}
+
+var (
+	bracePathParam = regexp.MustCompile(`\{(\w+)(?::[^}]*)?(?:\.\.\.)?\}`)
+	colonPathParam = regexp.MustCompile(`:(\w+)`)
+	anglePathParam = regexp.MustCompile(`<(?:\w+:)?(\w+)>`)
+	starPathParam  = regexp.MustCompile(`\*(\w*)`)
+)
+
+// OpenAPIPath writes a route's path the OpenAPI way: /users/:id (gin,
+// echo, Express), /users/<int:id> (Flask) and /users/{id:[0-9]+} (chi) all
+// become /users/{id}
+func OpenAPIPath(p string) string {
+	p = strings.ReplaceAll(p, "{$}", "")
+	p = bracePathParam.ReplaceAllString(p, "{$1}")
+	p = anglePathParam.ReplaceAllString(p, "{$1}")
+	p = colonPathParam.ReplaceAllString(p, "{$1}")
+	p = starPathParam.ReplaceAllStringFunc(p, func(m string) string {
+		if m == "*" {
+			return "{path}"
+		}
+		return "{" + m[1:] + "}"
+	})
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
+
+func pathParams(p string) []string {
+	var names []string
+	for _, m := range bracePathParam.FindAllStringSubmatch(p, -1) {
+		names = append(names, m[1])
+	}
+	return names
+}
+
+// operationKey identifies an operation across specs whatever its path
+// parameters are called
+func operationKey(method, path string) string {
+	return strings.ToUpper(method) + " " + bracePathParam.ReplaceAllString(path, "{}")
+}
+
+// DiscoverRoutes finds the routes root serves (net/http, gin, echo, chi,
+// Express, FastAPI, Flask, Phoenix, Rails) and their handlers' source
+func DiscoverRoutes(root string) ([]Route, error) {
+	d, err := contractgen.Discover(root)
+	if err != nil {
+		return nil, err
+	}
+	gr, err := graph.NewBuilder(root).Build()
+	if err != nil {
+		return nil, err
+	}
+	var routes []Route
+	seen := map[string]bool{}
+	for _, e := range d.Endpoints {
+		r := Route{Method: e.Method, Path: OpenAPIPath(e.Path), Handler: e.Handler, File: e.File, Line: e.Line}
+		if key := operationKey(r.Method, r.Path); !seen[key] {
+			seen[key] = true
+			if _, snippet, ok := contractgen.HandlerSource(gr, root, e); ok {
+				r.Source = snippet
+			}
+			routes = append(routes, r)
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes, nil
+}
+
+// OpenAPIDocument is an OpenAPI 3.1 document
+type OpenAPIDocument struct {
+	OpenAPI    string                            `yaml:"openapi" json:"openapi"`
+	Info       OpenAPIInfo                       `yaml:"info" json:"info"`
+	Servers    []OpenAPIServer                   `yaml:"servers,omitempty" json:"servers,omitempty"`
+	Paths      map[string]map[string]interface{} `yaml:"paths" json:"paths"`
+	Components OpenAPIComponents                 `yaml:"components,omitempty" json:"components,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `yaml:"title" json:"title"`
+	Version string `yaml:"version" json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `yaml:"url" json:"url"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]interface{} `yaml:"schemas,omitempty" json:"schemas,omitempty"`
+}
+
+// Marshal encodes the document as JSON for .json files, YAML otherwise
+func (d *OpenAPIDocument) Marshal(path string) ([]byte, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return json.MarshalIndent(d, "", "  ")
+	}
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(d); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), enc.Close()
+}
+
+// OpenAPIGenerator documents routes, inferring their parameters, bodies
+// and responses from the handlers with a model
+type OpenAPIGenerator struct {
+	provider llm.Provider
+	model    string
+	workDir  string
+}
+
+// OpenAPIResult is a generated document and how its operations were made
+type OpenAPIResult struct {
+	Doc *OpenAPIDocument
+	// Inferred operations were described by the model, Kept ones copied
+	// from the previous spec and Bare ones made from the route alone
+	Inferred, Kept, Bare int
+}
+
+func NewOpenAPIGenerator(provider llm.Provider, model, workDir string) *OpenAPIGenerator {
+	return &OpenAPIGenerator{provider: provider, model: model, workDir: workDir}
+}
+
+// Generate documents routes. The operations of previous, which may be nil,
+// that are still served are kept as they are, edits included, unless
+// refresh is set; the others are inferred from their handlers.
+func (g *OpenAPIGenerator) Generate(ctx context.Context, routes []Route, previous *apigen.Spec, refresh bool) (*OpenAPIResult, error) {
+	doc := &OpenAPIDocument{
+		OpenAPI:    OpenAPIVersion,
+		Info:       OpenAPIInfo{Title: filepath.Base(g.workDir) + " API", Version: "1.0.0"},
+		Paths:      map[string]map[string]interface{}{},
+		Components: OpenAPIComponents{Schemas: map[string]interface{}{}},
+	}
+	prev := map[string]apigen.Operation{}
+	prevPaths := map[string][]apigen.Operation{}
+	if previous != nil {
+		if previous.Title != "" {
+			doc.Info.Title = previous.Title
+		}
+		if previous.Version != "" && previous.Version != "<nil>" {
+			doc.Info.Version = previous.Version
+		}
+		for _, url := range previous.Servers {
+			doc.Servers = append(doc.Servers, OpenAPIServer{URL: url})
+		}
+		for _, op := range previous.Operations {
+			prev[operationKey(op.Method, op.Path)] = op
+			pathKey := operationKey("", op.Path)
+			prevPaths[pathKey] = append(prevPaths[pathKey], op)
+		}
+	}
+	result := &OpenAPIResult{Doc: doc}
+
+	// Kept operations stay under their path, whose parameters they declare
+	// and may be named differently in the code
+	keep := func(op apigen.Operation) {
+		doc.add(op.Method, op.Path, op.Raw)
+		for _, name := range op.Schemas {
+			doc.Components.Schemas[name] = previous.Schemas[name]
+		}
+		result.Kept++
+	}
+	var infer []Route
+	for _, r := range routes {
+		switch {
+		case refresh:
+			infer = append(infer, r)
+		case r.Method == "*" && len(prevPaths[operationKey("", r.Path)]) > 0:
+			for _, op := range prevPaths[operationKey("", r.Path)] {
+				keep(op)
+			}
+		case r.Method != "*" && prev[operationKey(r.Method, r.Path)].Raw != nil:
+			keep(prev[operationKey(r.Method, r.Path)])
+		default:
+			infer = append(infer, r)
+		}
+	}
+
+	for start := 0; start < len(infer); start += openAPIBatch {
+		batch := infer[start:min(start+openAPIBatch, len(infer))]
+		ops, schemas, err := g.infer(ctx, batch)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			ops = nil
+		}
+		for name, schema := range schemas {
+			doc.Components.Schemas[name] = schema
+		}
+		for _, r := range batch {
+			found := false
+			for _, op := range ops {
+				method, _ := op["method"].(string)
+				path, _ := op["path"].(string)
+				if operationKey("", OpenAPIPath(path)) != operationKey("", r.Path) ||
+					(r.Method != "*" && !strings.EqualFold(method, r.Method)) || method == "" {
+					continue
+				}
+				delete(op, "method")
+				delete(op, "path")
+				doc.add(method, r.Path, op)
+				found = true
+			}
+			if found {
+				result.Inferred++
+				continue
+			}
+			method := r.Method
+			if method == "*" {
+				method = "GET"
+			}
+			doc.add(method, r.Path, bareOperation(r))
+			result.Bare++
+		}
+	}
+	doc.uniqueOperationIDs()
+	if len(doc.Components.Schemas) == 0 {
+		doc.Components.Schemas = nil
+	}
+	return result, nil
+}
+
+// add sets an operation, declaring the path parameters it leaves out
+func (d *OpenAPIDocument) add(method, path string, op map[string]interface{}) {
+	declared := map[string]bool{}
+	params, _ := op["parameters"].([]interface{})
+	for _, p := range params {
+		if m, ok := p.(map[string]interface{}); ok && m["in"] == "path" {
+			declared[fmt.Sprint(m["name"])] = true
+		}
+	}
+	for _, name := range pathParams(path) {
+		if !declared[name] {
+			params = append(params, pathParameter(name))
+		}
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if _, ok := op["responses"]; !ok {
+		op["responses"] = map[string]interface{}{"200": map[string]interface{}{"description": "OK"}}
+	}
+	if d.Paths[path] == nil {
+		d.Paths[path] = map[string]interface{}{}
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+func pathParameter(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":     name,
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+}
+
+// bareOperation documents a route whose handler could not be described
+func bareOperation(r Route) map[string]interface{} {
+	op := map[string]interface{}{}
+	if idents := identPattern.FindAllString(r.Handler, -1); len(idents) > 0 {
+		op["operationId"] = idents[len(idents)-1]
+	}
+	op["description"] = fmt.Sprintf("Served at %s:%d.", r.File, r.Line)
+	return op
+}
+
+var identPattern = regexp.MustCompile(`[A-Za-z_]\w*`)
+
+// uniqueOperationIDs renames operationIds used more than once, as when
+// one handler serves several routes, in path order
+func (d *OpenAPIDocument) uniqueOperationIDs() {
+	paths := make([]string, 0, len(d.Paths))
+	for p := range d.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	used := map[string]int{}
+	for _, p := range paths {
+		methods := make([]string, 0, len(d.Paths[p]))
+		for m := range d.Paths[p] {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		for _, m := range methods {
+			op, _ := d.Paths[p][m].(map[string]interface{})
+			id, _ := op["operationId"].(string)
+			if id == "" {
+				continue
+			}
+			if used[id]++; used[id] > 1 {
+				op["operationId"] = fmt.Sprintf("%s%d", id, used[id])
+			}
+		}
+	}
+}
+
+func (g *OpenAPIGenerator) infer(ctx context.Context, routes []Route) ([]map[string]interface{}, map[string]interface{}, error) {
+	var b strings.Builder
+	for _, r := range routes {
+		fmt.Fprintf(&b, "### %s %s (%s:%d)\n", r.Method, r.Path, r.File, r.Line)
+		if r.Source != "" {
+			fmt.Fprintf(&b, "Handler %s:\n```\n%s\n```\n", r.Handler, strings.TrimSpace(r.Source))
+		} else if r.Handler != "" {
+			fmt.Fprintf(&b, "Handler %s (source not found)\n", r.Handler)
+		}
+		b.WriteString("\n")
+	}
+
+	prompt := fmt.Sprintf(`Describe these HTTP routes as OpenAPI %s operations, from what their
+handlers read and write:
+
+%s
+Return ONLY a JSON object:
+{"operations": [{"method": "GET", "path": "/users/{id}", "operationId": "getUser",
+  "summary": "...", "tags": ["users"], "parameters": [...], "requestBody": {...},
+  "responses": {"200": {...}, "404": {...}}}],
+ "schemas": {"User": {...}}}
+
+Rules:
+- Use each route's path as given; a route with method * gets one operation per
+  method its handler accepts
+- Parameters, request bodies and responses as OpenAPI 3.1 objects, with JSON
+  Schema for their content; only what the handler actually reads or writes
+- Name the shared body types in "schemas" and reference them as
+  {"$ref": "#/components/schemas/Name"}
+- Document the error responses the handler returns, not generic ones`, OpenAPIVersion, b.String())
+
+	resp, err := g.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are an API documentation expert who writes precise OpenAPI specifications from source code.",
+		UserPrompt:   prompt,
+		Model:        g.model,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	text := resp.Text
+	start, end := strings.Index(text, "{"), strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return nil, nil, fmt.Errorf("no JSON in the response")
+	}
+	var out struct {
+		Operations []map[string]interface{} `json:"operations"`
+		Schemas    map[string]interface{}   `json:"schemas"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &out); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON in the response: %w", err)
+	}
+	return out.Operations, out.Schemas, nil
+}
+
+// SpecDiff is how the operations of two specs differ
+type SpecDiff struct {
+	Added   []string
+	Removed []string
+	// Changed describes the operations whose parameters, body or
+	// responses differ
+	Changed []string
+}
+
+func (d SpecDiff) Empty() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+func (d SpecDiff) String() string {
+	var b strings.Builder
+	for _, s := range d.Added {
+		fmt.Fprintf(&b, "+ %s\n", s)
+	}
+	for _, s := range d.Removed {
+		fmt.Fprintf(&b, "- %s\n", s)
+	}
+	for _, s := range d.Changed {
+		fmt.Fprintf(&b, "~ %s\n", s)
+	}
+	return b.String()
+}
+
+// DiffSpecs compares the operations of two specs, matching them by method
+// and path whatever their path parameters are called
+func DiffSpecs(old, new *apigen.Spec) SpecDiff {
+	index := func(s *apigen.Spec) (map[string]apigen.Operation, []string) {
+		ops := map[string]apigen.Operation{}
+		var keys []string
+		for _, op := range s.Operations {
+			key := operationKey(op.Method, op.Path)
+			ops[key] = op
+			keys = append(keys, key)
+		}
+		return ops, keys
+	}
+	oldOps, oldKeys := index(old)
+	newOps, newKeys := index(new)
+
+	var d SpecDiff
+	for _, key := range newKeys {
+		op := newOps[key]
+		prev, ok := oldOps[key]
+		if !ok {
+			d.Added = append(d.Added, op.Method+" "+op.Path)
+			continue
+		}
+		if changes := operationChanges(prev, op); len(changes) > 0 {
+			d.Changed = append(d.Changed, fmt.Sprintf("%s %s: %s", op.Method, op.Path, strings.Join(changes, "; ")))
+		}
+	}
+	for _, key := range oldKeys {
+		if _, ok := newOps[key]; !ok {
+			d.Removed = append(d.Removed, oldOps[key].Method+" "+oldOps[key].Path)
+		}
+	}
+	return d
+}
+
+func operationChanges(old, new apigen.Operation) []string {
+	var changes []string
+	params := func(op apigen.Operation) map[string]bool {
+		m := map[string]bool{}
+		for _, p := range op.Parameters {
+			if p.In != "path" {
+				m[p.In+" "+p.Name] = p.Required
+			}
+		}
+		return m
+	}
+	oldParams, newParams := params(old), params(new)
+	for _, name := range sortedKeys(newParams) {
+		required, ok := oldParams[name]
+		switch {
+		case !ok:
+			changes = append(changes, "parameter "+name+" added")
+		case required != newParams[name]:
+			changes = append(changes, fmt.Sprintf("parameter %s required: %v → %v", name, required, newParams[name]))
+		}
+	}
+	for _, name := range sortedKeys(oldParams) {
+		if _, ok := newParams[name]; !ok {
+			changes = append(changes, "parameter "+name+" removed")
+		}
+	}
+
+	oldBody, newBody := old.Raw["requestBody"] != nil, new.Raw["requestBody"] != nil
+	if oldBody != newBody {
+		changes = append(changes, map[bool]string{true: "request body added", false: "request body removed"}[newBody])
+	}
+
+	oldResp := map[string]bool{}
+	for _, s := range old.Responses {
+		oldResp[s] = true
+	}
+	newResp := map[string]bool{}
+	for _, s := range new.Responses {
+		newResp[s] = true
+		if !oldResp[s] {
+			changes = append(changes, "response "+s+" added")
+		}
+	}
+	for _, s := range old.Responses {
+		if !newResp[s] {
+			changes = append(changes, "response "+s+" removed")
+		}
+	}
+	return changes
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,237 @@
+package docs
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gptcode/internal/apigen"
+	"gptcode/internal/llm"
+)
+
+func TestOpenAPIPath(t *testing.T) {
+	for in, want := range map[string]string{
+		"/users/:id":              "/users/{id}",
+		"users/<int:user_id>":     "/users/{user_id}",
+		"/orders/{id:[0-9]+}":     "/orders/{id}",
+		"/files/{path...}":        "/files/{path}",
+		"/static/*filepath":       "/static/{filepath}",
+		"/assets/*":               "/assets/{path}",
+		"/{$}":                    "/",
+		"/teams/:team/members/:m": "/teams/{team}/members/{m}",
+	} {
+		if got := OpenAPIPath(in); got != want {
+			t.Errorf("OpenAPIPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// routeProvider describes the routes of the prompts it is given
+type routeProvider struct {
+	prompts []string
+}
+
+func (p *routeProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.prompts = append(p.prompts, req.UserPrompt)
+	return &llm.ChatResponse{Text: "```json\n" + `{
+  "operations": [{
+    "method": "POST", "path": "/orders", "operationId": "createOrder", "tags": ["orders"],
+    "requestBody": {"content": {"application/json": {"schema": {"$ref": "#/components/schemas/NewOrder"}}}},
+    "responses": {"201": {"description": "Created"}, "400": {"description": "Invalid order"}}
+  }],
+  "schemas": {"NewOrder": {"type": "object", "properties": {"sku": {"type": "string"}, "qty": {"type": "integer"}}}}
+}` + "\n```"}, nil
+}
+
+const previousSpec = `openapi: 3.1.0
+info:
+  title: Shop
+  version: 2.0.0
+servers:
+  - url: https://shop.example.com
+paths:
+  /users/{userId}:
+    get:
+      operationId: getUser
+      summary: Hand-written summary
+      parameters:
+        - name: userId
+          in: path
+          required: true
+      responses:
+        '200':
+          description: The user
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+  /legacy:
+    get:
+      responses:
+        '200':
+          description: OK
+components:
+  schemas:
+    User:
+      type: object
+      properties:
+        name: {type: string}
+`
+
+func TestGenerateOpenAPI(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/shop\n",
+		"server/routes.go": `package server
+
+func Routes(r *gin.Engine, h *Handler) {
+	r.GET("/users/:id", h.GetUser)
+	r.POST("/orders", createOrder)
+	r.GET("/health", health)
+}
+`,
+		"server/orders.go": `package server
+
+func createOrder(c *gin.Context) {
+	var o NewOrder
+	if err := c.BindJSON(&o); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+	}
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	routes, err := DiscoverRoutes(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range routes {
+		got = append(got, r.String())
+	}
+	if want := []string{"GET /health", "POST /orders", "GET /users/{id}"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("routes = %v, want %v", got, want)
+	}
+	if !strings.Contains(routes[1].Source, "BindJSON") {
+		t.Errorf("expected the handler's source, got %q", routes[1].Source)
+	}
+
+	previous, err := apigen.ParseSpecData([]byte(previousSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &routeProvider{}
+	result, err := NewOpenAPIGenerator(provider, "model", root).Generate(context.Background(), routes, previous, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inferred != 1 || result.Kept != 1 || result.Bare != 1 {
+		t.Errorf("expected one operation of each kind, got %+v", result)
+	}
+	if len(provider.prompts) != 1 || strings.Contains(provider.prompts[0], "### GET /users") || !strings.Contains(provider.prompts[0], "BindJSON") {
+		t.Errorf("expected only the new routes inferred, from their handlers:\n%v", provider.prompts)
+	}
+
+	doc := result.Doc
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "Shop" || doc.Servers[0].URL != "https://shop.example.com" {
+		t.Errorf("unexpected document info %+v", doc)
+	}
+	user, _ := doc.Paths["/users/{userId}"]["get"].(map[string]interface{})
+	if user["summary"] != "Hand-written summary" || len(user["parameters"].([]interface{})) != 1 || doc.Components.Schemas["User"] == nil {
+		t.Errorf("expected the existing operation and its schemas kept, got %v", user)
+	}
+	health, _ := doc.Paths["/health"]["get"].(map[string]interface{})
+	if health["operationId"] != "health" || health["responses"] == nil {
+		t.Errorf("expected an operation made from the route, got %v", health)
+	}
+
+	data, err := doc.Marshal("api-spec.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, err := apigen.ParseSpecData(data)
+	if err != nil {
+		t.Fatalf("generated an invalid spec: %v\n%s", err, data)
+	}
+	diff := DiffSpecs(previous, current)
+	if !reflect.DeepEqual(diff.Added, []string{"GET /health", "POST /orders"}) || !reflect.DeepEqual(diff.Removed, []string{"GET /legacy"}) || diff.Changed != nil {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	collection, err := PostmanCollection(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c struct {
+		Item []struct {
+			Name string `json:"name"`
+			Item []struct {
+				Request struct {
+					Method string `json:"method"`
+					URL    struct {
+						Raw string `json:"raw"`
+					} `json:"url"`
+					Body struct {
+						Raw string `json:"raw"`
+					} `json:"body"`
+				} `json:"request"`
+			} `json:"item"`
+		} `json:"item"`
+		Variable []postmanKV `json:"variable"`
+	}
+	if err := json.Unmarshal(collection, &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Item) != 2 || c.Item[0].Name != "default" || c.Item[1].Name != "orders" || c.Variable[0].Value != "https://shop.example.com" {
+		t.Fatalf("expected a folder per tag, got %s", collection)
+	}
+	order := c.Item[1].Item[0].Request
+	if order.Method != "POST" || order.URL.Raw != "{{baseUrl}}/orders" || !strings.Contains(order.Body.Raw, `"qty": 0`) {
+		t.Errorf("unexpected request %+v", order)
+	}
+	if raw := c.Item[0].Item[1].Request.URL.Raw; raw != "{{baseUrl}}/users/:userId" {
+		t.Errorf("expected the path parameter as a variable, got %s", raw)
+	}
+}
+
+func TestDiffSpecsChanges(t *testing.T) {
+	old, err := apigen.ParseSpecData([]byte(previousSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := strings.Replace(previousSpec, "      responses:\n        '200':\n          description: The user", `        - name: fields
+          in: query
+      requestBody:
+        content:
+          application/json: {}
+      responses:
+        '404':
+          description: Missing
+        '200':
+          description: The user`, 1)
+	new, err := apigen.ParseSpecData([]byte(changed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff := DiffSpecs(old, new)
+	want := []string{"GET /users/{userId}: parameter query fields added; request body added; response 404 added"}
+	if !reflect.DeepEqual(diff.Changed, want) || len(diff.Added)+len(diff.Removed) != 0 {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+	if !DiffSpecs(old, old).Empty() {
+		t.Error("expected no changes between a spec and itself")
+	}
+}
@@ -0,0 +1,262 @@
+package docs
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+type postmanCollection struct {
+	Info     postmanInfo   `json:"info"`
+	Item     []postmanItem `json:"item"`
+	Variable []postmanKV   `json:"variable"`
+}
+
+type postmanInfo struct {
+	Name   string `json:"name"`
+	Schema string `json:"schema"`
+}
+
+type postmanItem struct {
+	Name    string          `json:"name"`
+	Item    []postmanItem   `json:"item,omitempty"`
+	Request *postmanRequest `json:"request,omitempty"`
+}
+
+type postmanRequest struct {
+	Method      string       `json:"method"`
+	Header      []postmanKV  `json:"header"`
+	URL         postmanURL   `json:"url"`
+	Body        *postmanBody `json:"body,omitempty"`
+	Description string       `json:"description,omitempty"`
+}
+
+type postmanURL struct {
+	Raw      string      `json:"raw"`
+	Host     []string    `json:"host"`
+	Path     []string    `json:"path"`
+	Query    []postmanKV `json:"query,omitempty"`
+	Variable []postmanKV `json:"variable,omitempty"`
+}
+
+type postmanKV struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Description string `json:"description,omitempty"`
+}
+
+type postmanBody struct {
+	Mode    string                 `json:"mode"`
+	Raw     string                 `json:"raw"`
+	Options map[string]interface{} `json:"options"`
+}
+
+// PostmanCollection converts doc into a Postman v2.1 collection: a folder
+// per tag, the server as {{baseUrl}} and example bodies made from the
+// request schemas
+func PostmanCollection(doc *OpenAPIDocument) ([]byte, error) {
+	baseURL := "http://localhost:8080"
+	if len(doc.Servers) > 0 {
+		baseURL = doc.Servers[0].URL
+	}
+	c := postmanCollection{
+		Info:     postmanInfo{Name: doc.Info.Title, Schema: postmanSchema},
+		Variable: []postmanKV{{Key: "baseUrl", Value: baseURL}},
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	folders := map[string]*postmanItem{}
+	var order []string
+	for _, p := range paths {
+		for _, method := range []string{"get", "post", "put", "patch", "delete", "head", "options"} {
+			op, ok := doc.Paths[p][method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			item := postmanItem{Name: strings.ToUpper(method) + " " + p, Request: postmanRequestFor(doc, method, p, op)}
+			if summary, _ := op["summary"].(string); summary != "" {
+				item.Name = summary
+			}
+			tag := "default"
+			if tags, _ := op["tags"].([]interface{}); len(tags) > 0 {
+				if s, ok := tags[0].(string); ok && s != "" {
+					tag = s
+				}
+			}
+			if folders[tag] == nil {
+				folders[tag] = &postmanItem{Name: tag}
+				order = append(order, tag)
+			}
+			folders[tag].Item = append(folders[tag].Item, item)
+		}
+	}
+	for _, tag := range order {
+		c.Item = append(c.Item, *folders[tag])
+	}
+	if len(order) == 1 {
+		c.Item = folders[order[0]].Item
+	}
+	if c.Item == nil {
+		c.Item = []postmanItem{}
+	}
+	return json.MarshalIndent(c, "", "  ")
+}
+
+func postmanRequestFor(doc *OpenAPIDocument, method, path string, op map[string]interface{}) *postmanRequest {
+	req := &postmanRequest{Method: strings.ToUpper(method), Header: []postmanKV{}}
+	req.Description, _ = op["description"].(string)
+
+	var segments []string
+	for _, seg := range strings.Split(strings.Trim(path, "/"), "/") {
+		if seg == "" {
+			continue
+		}
+		if m := bracePathParam.FindStringSubmatch(seg); m != nil && m[0] == seg {
+			seg = ":" + m[1]
+			req.URL.Variable = append(req.URL.Variable, postmanKV{Key: m[1]})
+		}
+		segments = append(segments, seg)
+	}
+	req.URL.Host = []string{"{{baseUrl}}"}
+	req.URL.Path = segments
+	if req.URL.Path == nil {
+		req.URL.Path = []string{}
+	}
+	raw := "{{baseUrl}}/" + strings.Join(segments, "/")
+
+	params, _ := op["parameters"].([]interface{})
+	var query []string
+	for _, p := range params {
+		m, _ := p.(map[string]interface{})
+		name, _ := m["name"].(string)
+		description, _ := m["description"].(string)
+		switch m["in"] {
+		case "query":
+			value := exampleString(example(doc, m["schema"], 0))
+			req.URL.Query = append(req.URL.Query, postmanKV{Key: name, Value: value, Description: description})
+			query = append(query, name+"="+value)
+		case "header":
+			req.Header = append(req.Header, postmanKV{Key: name, Value: exampleString(example(doc, m["schema"], 0)), Description: description})
+		case "path":
+			for i := range req.URL.Variable {
+				if req.URL.Variable[i].Key == name {
+					req.URL.Variable[i].Description = description
+				}
+			}
+		}
+	}
+	if len(query) > 0 {
+		raw += "?" + strings.Join(query, "&")
+	}
+	req.URL.Raw = raw
+
+	body, _ := resolveRef(doc, op["requestBody"]).(map[string]interface{})
+	content, _ := body["content"].(map[string]interface{})
+	if media, ok := content["application/json"].(map[string]interface{}); ok {
+		value, ok := media["example"]
+		if !ok {
+			value = example(doc, media["schema"], 0)
+		}
+		out, _ := json.MarshalIndent(value, "", "  ")
+		req.Header = append(req.Header, postmanKV{Key: "Content-Type", Value: "application/json"})
+		req.Body = &postmanBody{
+			Mode:    "raw",
+			Raw:     string(out),
+			Options: map[string]interface{}{"raw": map[string]interface{}{"language": "json"}},
+		}
+	}
+	return req
+}
+
+// resolveRef follows a reference to the document's component schemas
+func resolveRef(doc *OpenAPIDocument, v interface{}) interface{} {
+	m, _ := v.(map[string]interface{})
+	if ref, ok := m["$ref"].(string); ok && strings.HasPrefix(ref, "#/components/schemas/") {
+		return doc.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+	}
+	return v
+}
+
+// example makes a value matching a JSON Schema
+func example(doc *OpenAPIDocument, v interface{}, depth int) interface{} {
+	if depth > 6 {
+		return nil
+	}
+	schema, _ := resolveRef(doc, v).(map[string]interface{})
+	if schema == nil {
+		return nil
+	}
+	if value, ok := schema["example"]; ok {
+		return value
+	}
+	if values, _ := schema["examples"].([]interface{}); len(values) > 0 {
+		return values[0]
+	}
+	if values, _ := schema["enum"].([]interface{}); len(values) > 0 {
+		return values[0]
+	}
+	for _, key := range []string{"oneOf", "anyOf", "allOf"} {
+		if options, _ := schema[key].([]interface{}); len(options) > 0 {
+			return example(doc, options[0], depth+1)
+		}
+	}
+
+	// OpenAPI 3.1 types may be a list such as [string, "null"]
+	typ, _ := schema["type"].(string)
+	if types, ok := schema["type"].([]interface{}); ok {
+		for _, t := range types {
+			if s, _ := t.(string); s != "null" {
+				typ = s
+				break
+			}
+		}
+	}
+	if typ == "" && schema["properties"] != nil {
+		typ = "object"
+	}
+	switch typ {
+	case "object":
+		out := map[string]interface{}{}
+		props, _ := schema["properties"].(map[string]interface{})
+		for name, prop := range props {
+			out[name] = example(doc, prop, depth+1)
+		}
+		return out
+	case "array":
+		return []interface{}{example(doc, schema["items"], depth+1)}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "string":
+		switch schema["format"] {
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "date":
+			return "2024-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		}
+		return "string"
+	}
+	return nil
+}
+
+func exampleString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
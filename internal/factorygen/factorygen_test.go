@@ -0,0 +1,127 @@
+package factorygen
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseModels(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"user.go": `package models
+
+type User struct {
+	Base
+	ID          int64
+	Name, Email string
+	Tags        []string
+	Manager     *User
+}
+
+type Role int
+`,
+		"user.ex": `defmodule App.Accounts.User do
+  use Ecto.Schema
+
+  schema "users" do
+    field :name, :string
+    field :age, :integer, default: 0
+    field :nickname
+    belongs_to :org, App.Accounts.Org
+    timestamps()
+  end
+end
+`,
+		"order.ts": `export interface Order {
+  id: number;
+  note?: string;
+  items: Array<{ sku: string; qty: number }>;
+  total(): number;
+  onPaid: (at: Date) => void;
+}
+
+export type Item = { sku: string, price: number }
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string][]Model{
+		"user.go": {{Name: "User", Fields: []Field{
+			{"Base", "Base"}, {"ID", "int64"}, {"Name", "string"}, {"Email", "string"}, {"Tags", "[]string"}, {"Manager", "*User"},
+		}}},
+		"user.ex": {{Name: "App.Accounts.User", Fields: []Field{
+			{"name", "string"}, {"age", "integer"}, {"nickname", "string"}, {"org", "belongs_to App.Accounts.Org"},
+		}}},
+		"order.ts": {
+			{Name: "Order", Fields: []Field{{"id", "number"}, {"note", "string | undefined"}, {"items", "Array<{ sku: string; qty: number }>"}}},
+			{Name: "Item", Fields: []Field{{"sku", "string"}, {"price", "number"}}},
+		},
+	}
+	for name, want := range cases {
+		got, err := ParseModels(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", name, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	modelFile := filepath.Join(dir, "user.go")
+	factoryFile := FactoryPath(modelFile)
+	if filepath.Base(factoryFile) != "user_factory_test.go" {
+		t.Errorf("unexpected factory path %s", factoryFile)
+	}
+
+	generated := []Model{
+		{Name: "User", Fields: []Field{{"ID", "int64"}, {"Name", "string"}, {"Age", "int"}}},
+		{Name: "Team", Fields: []Field{{"ID", "int64"}}},
+	}
+	code := header(Go, "user.go", generated) + stripHeader(header(Go, "user.go", generated)+"package models\n")
+	if err := os.WriteFile(factoryFile, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if models, err := ReadHeader(factoryFile); err != nil || !reflect.DeepEqual(models, generated) {
+		t.Fatalf("ReadHeader = %+v (%v), want the generated models once", models, err)
+	}
+
+	if err := os.WriteFile(modelFile, []byte(`package models
+
+type User struct {
+	ID    int64
+	Name  string
+	Email string
+	Age   int64
+}
+
+type Account struct{ ID int64 }
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	drifts, err := Check(modelFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"+ User.Email (string)",
+		"~ User.Age: int -> int64",
+		"+ Account: model without a factory",
+		"- Team",
+	}
+	var got []string
+	for _, d := range drifts {
+		got = append(got, d.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("drift:\n got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,323 @@
+package factorygen
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+)
+
+// headerMarker starts the comment lines recording the models a factory was
+// generated from
+const headerMarker = "gptcode:factory "
+
+type FactoryGenerator struct {
+	provider llm.Provider
+	model    string
+	workDir  string
+}
+
+type GenerateResult struct {
+	FactoryFile string
+	Models      []Model
+	// Updated is set when an existing factory file was brought up to date
+	Updated bool
+	Valid   bool
+	Error   error
+}
+
+// Drift is a difference between a model and the factory generated from it
+type Drift struct {
+	Model string
+	// Field is empty when the whole model was added or removed
+	Field string
+	// Change is added, removed or retyped
+	Change   string
+	Old, New string
+}
+
+func (d Drift) String() string {
+	name := d.Model
+	if d.Field != "" {
+		name += "." + d.Field
+	}
+	switch d.Change {
+	case "added":
+		if d.Field == "" {
+			return fmt.Sprintf("+ %s: model without a factory", name)
+		}
+		return fmt.Sprintf("+ %s (%s)", name, d.New)
+	case "removed":
+		return fmt.Sprintf("- %s", name)
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", name, d.Old, d.New)
+}
+
+func NewFactoryGenerator(provider llm.Provider, model, workDir string) *FactoryGenerator {
+	return &FactoryGenerator{
+		provider: provider,
+		model:    model,
+		workDir:  workDir,
+	}
+}
+
+// FactoryPath is where the factories of a model file go: a _factory_test.go
+// file next to Go models, test/support/factories in the Mix project of
+// Elixir schemas, and a .factory.ts file next to TypeScript types.
+func FactoryPath(modelFile string) string {
+	dir, base := filepath.Split(modelFile)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	switch LanguageOf(modelFile) {
+	case Go:
+		return filepath.Join(dir, name+"_factory_test.go")
+	case Elixir:
+		root := langdetect.Detect(dir).Root
+		if root == "" {
+			root = dir
+		}
+		return filepath.Join(root, "test", "support", "factories", name+"_factory.ex")
+	}
+	return filepath.Join(dir, name+".factory.ts")
+}
+
+// Generate writes the factories of the models in modelFile to
+// factoryFile, or FactoryPath when it is empty. An existing factory file is
+// updated rather than replaced, keeping its customizations.
+func (g *FactoryGenerator) Generate(ctx context.Context, modelFile, factoryFile string) (*GenerateResult, error) {
+	modelPath := g.abs(modelFile)
+	models, err := ParseModels(modelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse models: %w", err)
+	}
+	if len(models) == 0 {
+		return nil, fmt.Errorf("no models found in %s", modelFile)
+	}
+	if factoryFile == "" {
+		factoryFile = FactoryPath(modelPath)
+	}
+	factoryFile = g.abs(factoryFile)
+	result := &GenerateResult{FactoryFile: factoryFile, Models: models}
+
+	source, err := os.ReadFile(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := os.ReadFile(factoryFile)
+	if err == nil {
+		result.Updated = true
+	}
+
+	code, err := g.generateFactoryCode(ctx, modelFile, string(source), string(existing), models)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate factories: %w", err)
+	}
+	code = header(LanguageOf(modelPath), filepath.Base(modelFile), models) + stripHeader(code)
+
+	if err := os.MkdirAll(filepath.Dir(factoryFile), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(factoryFile, []byte(code), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write factory file: %w", err)
+	}
+
+	result.Valid = true
+	if LanguageOf(modelPath) == Go {
+		if _, err := parser.ParseFile(token.NewFileSet(), factoryFile, code, 0); err != nil {
+			result.Valid = false
+			result.Error = err
+		}
+	}
+	return result, nil
+}
+
+// Check compares the models in modelFile with the ones its factory file
+// was generated from, returning what changed since.
+func Check(modelFile, factoryFile string) ([]Drift, error) {
+	models, err := ParseModels(modelFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse models: %w", err)
+	}
+	if factoryFile == "" {
+		factoryFile = FactoryPath(modelFile)
+	}
+	recorded, err := ReadHeader(factoryFile)
+	if err != nil {
+		return nil, err
+	}
+
+	old := map[string]Model{}
+	for _, m := range recorded {
+		old[m.Name] = m
+	}
+	var drifts []Drift
+	for _, m := range models {
+		prev, ok := old[m.Name]
+		if !ok {
+			drifts = append(drifts, Drift{Model: m.Name, Change: "added"})
+			continue
+		}
+		delete(old, m.Name)
+		prevFields := map[string]string{}
+		for _, f := range prev.Fields {
+			prevFields[f.Name] = f.Type
+		}
+		for _, f := range m.Fields {
+			typ, ok := prevFields[f.Name]
+			switch {
+			case !ok:
+				drifts = append(drifts, Drift{Model: m.Name, Field: f.Name, Change: "added", New: f.Type})
+			case typ != f.Type:
+				drifts = append(drifts, Drift{Model: m.Name, Field: f.Name, Change: "retyped", Old: typ, New: f.Type})
+			}
+			delete(prevFields, f.Name)
+		}
+		for _, f := range prev.Fields {
+			if _, ok := prevFields[f.Name]; ok {
+				drifts = append(drifts, Drift{Model: m.Name, Field: f.Name, Change: "removed", Old: f.Type})
+			}
+		}
+	}
+	for _, m := range recorded {
+		if _, ok := old[m.Name]; ok {
+			drifts = append(drifts, Drift{Model: m.Name, Change: "removed"})
+		}
+	}
+	return drifts, nil
+}
+
+// ReadHeader returns the models a factory file was generated from.
+func ReadHeader(factoryFile string) ([]Model, error) {
+	f, err := os.Open(factoryFile)
+	if err != nil {
+		return nil, fmt.Errorf("no factory file: %w", err)
+	}
+	defer f.Close()
+
+	var models []Model
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimLeft(strings.TrimSpace(scanner.Text()), "/#")
+		_, data, ok := strings.Cut(line, headerMarker)
+		if !ok {
+			continue
+		}
+		var m Model
+		if err := json.Unmarshal([]byte(data), &m); err != nil {
+			return nil, fmt.Errorf("invalid factory header in %s: %w", factoryFile, err)
+		}
+		models = append(models, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if models == nil {
+		return nil, fmt.Errorf("%s was not generated by gptcode gen factory", factoryFile)
+	}
+	return models, nil
+}
+
+// header records the models in comments at the top of a factory file
+func header(language, modelFile string, models []Model) string {
+	comment := "//"
+	if language == Elixir {
+		comment = "#"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s Factories for %s, generated by gptcode gen factory. Edit them freely;\n", comment, modelFile)
+	fmt.Fprintf(&b, "%s the lines below let gptcode gen factory --check tell when the models change.\n", comment)
+	for _, m := range models {
+		data, _ := json.Marshal(m)
+		fmt.Fprintf(&b, "%s %s%s\n", comment, headerMarker, data)
+	}
+	return b.String() + "\n"
+}
+
+// stripHeader removes a previous header the model may have copied over
+// from the existing factory file
+func stripHeader(code string) string {
+	lines := strings.Split(code, "\n")
+	i := 0
+	for i < len(lines) {
+		line := strings.TrimSpace(lines[i])
+		if !strings.Contains(line, headerMarker) && !strings.Contains(line, "gptcode gen factory") && (i > 0 || line != "") {
+			break
+		}
+		i++
+	}
+	return strings.TrimLeft(strings.Join(lines[i:], "\n"), "\n") + "\n"
+}
+
+func (g *FactoryGenerator) abs(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(g.workDir, path)
+}
+
+// idioms describe the factory style of each language
+var idioms = map[string]string{
+	Go: `- Write a builder per struct in idiomatic Go: func New<Model>(opts ...<Model>Option) *<Model>
+  returning a valid value with realistic defaults, and a With<Field>(v) <Model>Option per field
+- Type <Model>Option func(*<Model>)
+- Same package as the models; only the standard library
+- Related structs are built with their own builders`,
+	Elixir: `- Write ExMachina factories: a module using ExMachina.Ecto, with repo: <App>.Repo
+  (infer the app from the schema module), and a <name>_factory/0 function per schema
+- Unique fields use sequence/2; associations use build/1 of their factory
+- Realistic defaults, valid for the schema's changeset`,
+	TypeScript: `- Write fishery factories: export const <model>Factory = Factory.define<Model>(({ sequence }) => ({ ... }))
+  importing Factory from 'fishery' and the types from the model file
+- Ids and unique fields use sequence; nested types use their factories' build()
+- Optional fields are left out unless they matter`,
+}
+
+func (g *FactoryGenerator) generateFactoryCode(ctx context.Context, modelFile, source, existing string, models []Model) (string, error) {
+	language := LanguageOf(modelFile)
+	var names []string
+	for _, m := range models {
+		names = append(names, m.Name)
+	}
+
+	prompt := fmt.Sprintf(`Generate test data factories for the models %s defined in %s:
+
+`+"```"+`%s
+%s
+`+"```"+`
+
+Rules:
+%s
+- Every field of the models gets a default
+- Clean, idiomatic code that compiles as is
+`, strings.Join(names, ", "), modelFile, language, source, idioms[language])
+	if existing != "" {
+		prompt += fmt.Sprintf(`
+The factories exist already; update them to the models as they are now,
+adding, removing and changing fields as needed and keeping everything else,
+including custom defaults and helpers:
+
+`+"```"+`%s
+%s
+`+"```"+`
+`, language, existing)
+	}
+	prompt += "\nReturn ONLY the complete file, no explanations."
+
+	resp, err := g.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are a helpful assistant that generates test data factories in the idiom of each language.",
+		UserPrompt:   prompt,
+		Model:        g.model,
+	})
+	if err != nil {
+		return "", err
+	}
+	return llm.ExtractCode(resp.Text), nil
+}
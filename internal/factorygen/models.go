@@ -0,0 +1,234 @@
+// Package factorygen generates test data factories for the models of a
+// file, in the idiom of its language: functional options builders in Go,
+// ExMachina factories in Elixir and fishery factories in TypeScript. Each
+// factory records the fields it was generated from, so drift between the
+// models and their factories can be checked without a model.
+package factorygen
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Model is a struct, schema or interface and its fields
+type Model struct {
+	Name   string  `json:"name"`
+	Fields []Field `json:"fields"`
+}
+
+// Field is a field of a model; Type is as written in the source
+type Field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Language of a model file
+const (
+	Go         = "go"
+	Elixir     = "elixir"
+	TypeScript = "typescript"
+)
+
+// LanguageOf returns the language of a model file by its extension, or ""
+func LanguageOf(path string) string {
+	switch filepath.Ext(path) {
+	case ".go":
+		return Go
+	case ".ex", ".exs":
+		return Elixir
+	case ".ts", ".tsx":
+		return TypeScript
+	}
+	return ""
+}
+
+// ParseModels reads the models defined in a Go, Elixir or TypeScript file.
+func ParseModels(path string) ([]Model, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	switch LanguageOf(path) {
+	case Go:
+		return parseGo(path, content)
+	case Elixir:
+		return parseElixir(string(content)), nil
+	case TypeScript:
+		return parseTypeScript(string(content)), nil
+	}
+	return nil, fmt.Errorf("unsupported model file %s (Go, Elixir or TypeScript)", path)
+}
+
+func parseGo(path string, content []byte) ([]Model, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, content, 0)
+	if err != nil {
+		return nil, err
+	}
+	var models []Model
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			m := Model{Name: ts.Name.Name}
+			for _, f := range st.Fields.List {
+				typ := types.ExprString(f.Type)
+				if len(f.Names) == 0 {
+					// Embedded: named after its type
+					name := strings.TrimPrefix(typ, "*")
+					if i := strings.LastIndex(name, "."); i >= 0 {
+						name = name[i+1:]
+					}
+					m.Fields = append(m.Fields, Field{Name: name, Type: typ})
+				}
+				for _, n := range f.Names {
+					m.Fields = append(m.Fields, Field{Name: n.Name, Type: typ})
+				}
+			}
+			models = append(models, m)
+		}
+	}
+	return models, nil
+}
+
+var (
+	elixirModuleRegex = regexp.MustCompile(`^\s*defmodule\s+([\w.]+)\s+do`)
+	elixirSchemaRegex = regexp.MustCompile(`^\s*(?:embedded_)?schema\b`)
+	elixirFieldRegex  = regexp.MustCompile(`^\s*(field|belongs_to|embeds_one|embeds_many|has_one|has_many|many_to_many)\s+:(\w+)(?:\s*,\s*([^,\s]+(?:\.\w+)*))?`)
+	elixirEndRegex    = regexp.MustCompile(`^\s*end\b`)
+)
+
+// parseElixir reads the Ecto schemas of a file: their fields and
+// associations, the association kind standing for the type
+func parseElixir(content string) []Model {
+	var models []Model
+	var module string
+	var current *Model
+	for _, line := range strings.Split(content, "\n") {
+		if m := elixirModuleRegex.FindStringSubmatch(line); m != nil {
+			module = m[1]
+			continue
+		}
+		if current == nil {
+			if elixirSchemaRegex.MatchString(line) && module != "" {
+				current = &Model{Name: module}
+			}
+			continue
+		}
+		if elixirEndRegex.MatchString(line) {
+			models = append(models, *current)
+			current = nil
+			continue
+		}
+		m := elixirFieldRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		typ := strings.TrimPrefix(m[3], ":")
+		switch {
+		case m[1] != "field":
+			typ = m[1] + " " + typ
+		case typ == "":
+			typ = "string"
+		}
+		current.Fields = append(current.Fields, Field{Name: m[2], Type: typ})
+	}
+	return models
+}
+
+var (
+	tsDeclRegex  = regexp.MustCompile(`(?m)^\s*(?:export\s+)?(?:default\s+)?(?:interface|class|type)\s+(\w+)[^{=]*(=\s*)?\{`)
+	tsFieldRegex = regexp.MustCompile(`^(?:readonly\s+|public\s+|private\s+|protected\s+)*(\w+)(\??)\s*:\s*(.+)$`)
+)
+
+// parseTypeScript reads the interfaces, object types and class properties
+// of a file
+func parseTypeScript(content string) []Model {
+	var models []Model
+	for _, loc := range tsDeclRegex.FindAllStringSubmatchIndex(content, -1) {
+		name := content[loc[2]:loc[3]]
+		body, ok := braced(content[loc[1]-1:])
+		if !ok {
+			continue
+		}
+		m := Model{Name: name}
+		for _, member := range splitMembers(body) {
+			f := tsFieldRegex.FindStringSubmatch(member)
+			if f == nil || (strings.HasPrefix(f[3], "(") && strings.Contains(f[3], "=>")) {
+				// Methods and function-typed members are no data
+				continue
+			}
+			typ := strings.TrimSpace(f[3])
+			if i := strings.Index(typ, " = "); i >= 0 {
+				typ = typ[:i]
+			}
+			if f[2] == "?" {
+				typ += " | undefined"
+			}
+			m.Fields = append(m.Fields, Field{Name: f[1], Type: typ})
+		}
+		models = append(models, m)
+	}
+	return models
+}
+
+// braced returns what is between the opening brace s starts with and its
+// matching brace
+func braced(s string) (string, bool) {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[1:i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// splitMembers splits a body at the semicolons, commas and newlines
+// outside nested braces, brackets and parentheses
+func splitMembers(body string) []string {
+	var members []string
+	depth, start := 0, 0
+	add := func(end int) {
+		if m := strings.TrimSpace(body[start:end]); m != "" && !strings.HasPrefix(m, "//") {
+			members = append(members, m)
+		}
+		start = end + 1
+	}
+	for i, r := range body {
+		switch r {
+		case '{', '[', '(', '<':
+			depth++
+		case '}', ']', ')', '>':
+			if r == '>' && i > 0 && body[i-1] == '=' {
+				continue
+			}
+			depth--
+		case ';', ',', '\n':
+			if depth == 0 {
+				add(i)
+			}
+		}
+	}
+	add(len(body))
+	return members
+}
@@ -34,6 +34,18 @@ type Event struct {
 	Files           []string          `json:"files,omitempty"`
 	DiffPath        string            `json:"diff_path,omitempty"`
 	Metadata        map[string]string `json:"metadata,omitempty"`
+	// Count is how many near-identical events were merged into this one
+	// (0 for a single event); LastSeen is when the latest of them happened
+	Count    int       `json:"count,omitempty"`
+	LastSeen time.Time `json:"last_seen,omitempty"`
+}
+
+// Occurrences is how many times the event was recorded
+func (e Event) Occurrences() int {
+	if e.Count < 1 {
+		return 1
+	}
+	return e.Count
 }
 
 func GetFeedbackDir() string {
@@ -55,18 +67,35 @@ func Record(event Event) error {
 		event.Timestamp = time.Now()
 	}
 
+	// Near-duplicates of an earlier event only bump its count
+	if merged, err := mergeDuplicate(event); err != nil || merged {
+		return err
+	}
+
 	filename := fmt.Sprintf("%s.json", event.Timestamp.Format("2006-01-02"))
 	path := filepath.Join(GetFeedbackDir(), filename)
 
+	events, err := readEvents(path)
+	if err != nil {
+		return err
+	}
+
+	events = append(events, event)
+
+	return writeEvents(path, events)
+}
+
+func readEvents(path string) ([]Event, error) {
 	var events []Event
 	if data, err := os.ReadFile(path); err == nil {
 		if err := json.Unmarshal(data, &events); err != nil {
-			return fmt.Errorf("failed to parse existing feedback: %w", err)
+			return nil, fmt.Errorf("failed to parse existing feedback: %w", err)
 		}
 	}
+	return events, nil
+}
 
-	events = append(events, event)
-
+func writeEvents(path string, events []Event) error {
 	data, err := json.MarshalIndent(events, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal feedback: %w", err)
@@ -194,7 +223,9 @@ func ConvertToModelFeedback(events []Event, language string) []map[string]interf
 			"timestamp":  e.Timestamp,
 		}
 
-		result = append(result, fb)
+		for i := 0; i < e.Occurrences(); i++ {
+			result = append(result, fb)
+		}
 	}
 
 	return result
@@ -202,28 +233,30 @@ func ConvertToModelFeedback(events []Event, language string) []map[string]interf
 
 func Analyze(events []Event) Stats {
 	stats := Stats{
-		TotalEvents: len(events),
-		ByBackend:   make(map[string]BackendStats),
-		ByModel:     make(map[string]ModelStats),
-		ByAgent:     make(map[string]AgentStats),
+		ByBackend: make(map[string]BackendStats),
+		ByModel:   make(map[string]ModelStats),
+		ByAgent:   make(map[string]AgentStats),
 	}
 
+	// Merged duplicates count once per occurrence
 	for _, e := range events {
+		n := e.Occurrences()
+		stats.TotalEvents += n
 		switch e.Sentiment {
 		case SentimentGood:
-			stats.GoodCount++
+			stats.GoodCount += n
 		case SentimentBad:
-			stats.BadCount++
+			stats.BadCount += n
 		}
 
 		if e.Backend != "" {
 			bs := stats.ByBackend[e.Backend]
-			bs.Total++
+			bs.Total += n
 			switch e.Sentiment {
 			case SentimentGood:
-				bs.Good++
+				bs.Good += n
 			case SentimentBad:
-				bs.Bad++
+				bs.Bad += n
 			}
 			if bs.Total > 0 {
 				bs.Ratio = float64(bs.Good) / float64(bs.Total)
@@ -233,13 +266,13 @@ func Analyze(events []Event) Stats {
 
 		if e.Model != "" {
 			ms := stats.ByModel[e.Model]
-			ms.Total++
+			ms.Total += n
 			ms.Backend = e.Backend
 			switch e.Sentiment {
 			case SentimentGood:
-				ms.Good++
+				ms.Good += n
 			case SentimentBad:
-				ms.Bad++
+				ms.Bad += n
 			}
 			if ms.Total > 0 {
 				ms.Ratio = float64(ms.Good) / float64(ms.Total)
@@ -249,12 +282,12 @@ func Analyze(events []Event) Stats {
 
 		if e.Agent != "" {
 			as := stats.ByAgent[e.Agent]
-			as.Total++
+			as.Total += n
 			switch e.Sentiment {
 			case SentimentGood:
-				as.Good++
+				as.Good += n
 			case SentimentBad:
-				as.Bad++
+				as.Bad += n
 			}
 			if as.Total > 0 {
 				as.Ratio = float64(as.Good) / float64(as.Total)
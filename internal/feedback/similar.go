@@ -0,0 +1,319 @@
+package feedback
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Embedder turns texts into embedding vectors, one per text. The
+// embedders of the llm package satisfy it.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+var embedder Embedder
+
+// SetEmbedder makes Record merge near-duplicate events and QueryRelevant
+// rank corrections by meaning; nil turns both back to plain matching.
+func SetEmbedder(e Embedder) {
+	embedder = e
+}
+
+const (
+	// duplicateSimilarity is the cosine similarity above which an event
+	// repeats an earlier one
+	duplicateSimilarity = 0.92
+	// relevantSimilarity is the cosine similarity above which a past
+	// correction applies to a task
+	relevantSimilarity = 0.75
+	// relevantOverlap is the share of common words above which a past
+	// correction applies to a task when there are no embeddings
+	relevantOverlap = 0.3
+	// maxRelevant is how many corrections QueryRelevant returns
+	maxRelevant = 3
+	// maxCompared bounds how many recent events an event is compared with
+	maxCompared  = 200
+	embedTimeout = 15 * time.Second
+)
+
+// text is what is embedded for an event
+func (e Event) text() string {
+	var parts []string
+	for _, s := range []string{e.Task, e.Context, e.WrongResponse, e.CorrectResponse} {
+		if s = strings.TrimSpace(s); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// sameKind reports whether a and b describe the same outcome of the same
+// model and agent, so that one can stand for both
+func sameKind(a, b Event) bool {
+	return a.Sentiment == b.Sentiment && a.Backend == b.Backend && a.Model == b.Model &&
+		a.Agent == b.Agent && a.Kind == b.Kind && a.Source == b.Source &&
+		maps.Equal(a.Metadata, b.Metadata)
+}
+
+// storedEvent is an event with the day file it is stored in
+type storedEvent struct {
+	Event
+	path  string
+	index int
+}
+
+// loadStored reads the events of every day file, oldest first
+func loadStored() ([]storedEvent, error) {
+	dir := GetFeedbackDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read feedback dir: %w", err)
+	}
+	var stored []storedEvent
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		events, err := readEvents(path)
+		if err != nil {
+			continue
+		}
+		for i, e := range events {
+			stored = append(stored, storedEvent{Event: e, path: path, index: i})
+		}
+	}
+	return stored, nil
+}
+
+// mergeDuplicate folds event into the most similar earlier event of the
+// same kind when they are near-duplicates, reporting whether it did.
+// Without an embedder, or when embedding fails, nothing is merged.
+func mergeDuplicate(event Event) (bool, error) {
+	text := event.text()
+	if embedder == nil || text == "" {
+		return false, nil
+	}
+	stored, err := loadStored()
+	if err != nil {
+		return false, nil
+	}
+	var candidates []storedEvent
+	for i := len(stored) - 1; i >= 0 && len(candidates) < maxCompared; i-- {
+		if sameKind(stored[i].Event, event) && stored[i].text() != "" {
+			candidates = append(candidates, stored[i])
+		}
+	}
+	if len(candidates) == 0 {
+		return false, nil
+	}
+
+	texts := []string{text}
+	for _, c := range candidates {
+		texts = append(texts, c.text())
+	}
+	scores, ok := similarities(texts)
+	if !ok {
+		return false, nil
+	}
+	best := -1
+	for i, score := range scores {
+		if score >= duplicateSimilarity && (best < 0 || score > scores[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return false, nil
+	}
+
+	match := candidates[best]
+	events, err := readEvents(match.path)
+	if err != nil || match.index >= len(events) {
+		return false, err
+	}
+	merged := &events[match.index]
+	merged.Count = merged.Occurrences() + 1
+	merged.LastSeen = event.Timestamp
+	if event.CorrectResponse != "" {
+		merged.CorrectResponse = event.CorrectResponse
+	}
+	if event.WrongResponse != "" {
+		merged.WrongResponse = event.WrongResponse
+	}
+	for _, f := range event.Files {
+		if !slices.Contains(merged.Files, f) {
+			merged.Files = append(merged.Files, f)
+		}
+	}
+	if event.DiffPath != "" {
+		merged.DiffPath = event.DiffPath
+	}
+	return true, writeEvents(match.path, events)
+}
+
+// similarities embeds texts and returns the cosine similarity of each text
+// after the first to the first one
+func similarities(texts []string) ([]float64, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), embedTimeout)
+	defer cancel()
+	vectors, err := embedder.Embed(ctx, texts)
+	if err != nil || len(vectors) != len(texts) {
+		return nil, false
+	}
+	scores := make([]float64, len(texts)-1)
+	for i := range scores {
+		scores[i] = cosine(vectors[0], vectors[i+1])
+	}
+	return scores, true
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// IsCorrection reports whether the event tells what a response got wrong
+// or what it should have been
+func (e Event) IsCorrection() bool {
+	return e.CorrectResponse != "" || (e.Sentiment == SentimentBad && e.WrongResponse != "")
+}
+
+// QueryRelevant returns up to three past corrections similar to task, the
+// most similar first. Corrections are compared by meaning when an embedder
+// is set, and by the words they share with task otherwise.
+func QueryRelevant(task string) ([]Event, error) {
+	if strings.TrimSpace(task) == "" {
+		return nil, nil
+	}
+	events, err := LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	var corrections []Event
+	for i := len(events) - 1; i >= 0 && len(corrections) < maxCompared; i-- {
+		if events[i].IsCorrection() {
+			corrections = append(corrections, events[i])
+		}
+	}
+	if len(corrections) == 0 {
+		return nil, nil
+	}
+
+	var scores []float64
+	threshold := relevantSimilarity
+	if embedder != nil {
+		texts := []string{task}
+		for _, e := range corrections {
+			texts = append(texts, e.text())
+		}
+		scores, _ = similarities(texts)
+	}
+	if scores == nil {
+		threshold = relevantOverlap
+		words := wordSet(task)
+		for _, e := range corrections {
+			scores = append(scores, overlap(words, wordSet(e.Task+" "+e.Context)))
+		}
+	}
+
+	type scored struct {
+		event Event
+		score float64
+	}
+	var relevant []scored
+	for i, e := range corrections {
+		if scores[i] >= threshold {
+			relevant = append(relevant, scored{e, scores[i]})
+		}
+	}
+	sort.SliceStable(relevant, func(i, j int) bool { return relevant[i].score > relevant[j].score })
+	var result []Event
+	for i := 0; i < len(relevant) && i < maxRelevant; i++ {
+		result = append(result, relevant[i].event)
+	}
+	return result, nil
+}
+
+// wordSet is the set of lowercase words of three letters or more in s
+func wordSet(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_')
+	}) {
+		if len(w) >= 3 {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+// overlap is the Jaccard index of two word sets
+func overlap(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// FormatCorrections renders corrections as a prompt section, or "" when
+// there are none
+func FormatCorrections(corrections []Event) string {
+	if len(corrections) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Past corrections on similar tasks (avoid repeating these mistakes):\n")
+	for _, e := range corrections {
+		task := e.Task
+		if task == "" {
+			task = e.Context
+		}
+		fmt.Fprintf(&b, "- Task: %s\n", truncate(task, 300))
+		if e.WrongResponse != "" {
+			fmt.Fprintf(&b, "  Wrong: %s\n", truncate(e.WrongResponse, 500))
+		}
+		if e.CorrectResponse != "" {
+			fmt.Fprintf(&b, "  Correct: %s\n", truncate(e.CorrectResponse, 500))
+		}
+		if e.Occurrences() > 1 {
+			fmt.Fprintf(&b, "  (reported %d times)\n", e.Occurrences())
+		}
+	}
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
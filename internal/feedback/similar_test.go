@@ -0,0 +1,103 @@
+package feedback
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wordEmbedder embeds a text as counts of a few words, so that texts about
+// the same things get similar vectors
+type wordEmbedder struct{}
+
+func (wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vocabulary := []string{"migration", "timestamp", "column", "readme", "typo", "handler"}
+	var vectors [][]float32
+	for _, t := range texts {
+		v := make([]float32, len(vocabulary))
+		for i, w := range vocabulary {
+			v[i] = float32(strings.Count(strings.ToLower(t), w))
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors, nil
+}
+
+func TestRecordMergesDuplicates(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	SetEmbedder(wordEmbedder{})
+	defer SetEmbedder(nil)
+
+	day := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	bad := Event{Sentiment: SentimentBad, Backend: "groq", Model: "m", Agent: "editor"}
+	for i, task := range []string{
+		"add a migration for the timestamp column",
+		"Add a migration adding a timestamp column",
+		"fix the typo in the readme",
+	} {
+		e := bad
+		e.Task = task
+		e.Timestamp = day.Add(time.Duration(i) * time.Hour)
+		if err := Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	good := bad
+	good.Sentiment = SentimentGood
+	good.Task = "add a migration for the timestamp column"
+	if err := Record(good); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected the migration events to be merged, got %d events", len(events))
+	}
+	if events[0].Count != 2 || !events[0].LastSeen.Equal(day.Add(time.Hour)) {
+		t.Errorf("unexpected merged event %+v", events[0])
+	}
+	stats := Analyze(events)
+	if stats.TotalEvents != 4 || stats.BadCount != 3 {
+		t.Errorf("merged events should count once per occurrence, got %+v", stats)
+	}
+}
+
+func TestQueryRelevant(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	for _, e := range []Event{
+		{Sentiment: SentimentBad, Agent: "editor", Task: "add a migration for the created_at timestamp column",
+			WrongResponse: "edited schema.sql by hand", CorrectResponse: "create a file under db/migrations"},
+		{Sentiment: SentimentBad, Agent: "editor", Task: "fix the typo in the readme", CorrectResponse: "only touch README.md"},
+		{Sentiment: SentimentGood, Agent: "editor", Task: "add a migration for the users table"},
+	} {
+		if err := Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, e := range []Embedder{nil, wordEmbedder{}} {
+		SetEmbedder(e)
+		got, err := QueryRelevant("add a migration for the updated_at timestamp column")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || !strings.Contains(got[0].Task, "created_at") {
+			t.Errorf("embedder %v: unexpected corrections %+v", e, got)
+		}
+	}
+	SetEmbedder(nil)
+
+	section := FormatCorrections([]Event{{Task: "t", WrongResponse: "w", CorrectResponse: "c", Count: 3}})
+	for _, want := range []string{"- Task: t", "Wrong: w", "Correct: c", "reported 3 times"} {
+		if !strings.Contains(section, want) {
+			t.Errorf("section missing %q:\n%s", want, section)
+		}
+	}
+	if FormatCorrections(nil) != "" {
+		t.Error("no corrections should render nothing")
+	}
+}
@@ -0,0 +1,153 @@
+// Package fleet runs one task across many repositories: it reads the list
+// of repositories, prepares a checkout of each and reports the outcome of
+// every run.
+package fleet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gptcode/internal/httpclient"
+)
+
+// Repo is a repository of the fleet and the checkout its task runs from
+type Repo struct {
+	// Spec is the line of the repository list: a local path, a clone URL,
+	// owner/repo on GitHub or host/group/repo
+	Spec string
+	// Name identifies the repository in branches, worktrees and the report
+	Name string
+	// Dir is the checkout: the local path, or a clone in the fleet cache
+	Dir string
+	// Cloned is set when Dir is a clone made or updated by the fleet
+	Cloned bool
+}
+
+// ParseRepoList reads one repository per line, skipping blank lines and
+// # comments.
+func ParseRepoList(r io.Reader) ([]string, error) {
+	var specs []string
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+		specs = append(specs, line)
+	}
+	return specs, scanner.Err()
+}
+
+// CacheDir is where repositories given by URL or name are cloned,
+// ~/.gptcode/fleet
+func CacheDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "fleet")
+}
+
+// Checkout returns the repository of spec with a checkout to work from. A
+// local path is used as it is; anything else is cloned into cacheDir, or
+// fetched when an earlier run cloned it already.
+func Checkout(spec, cacheDir string) (Repo, error) {
+	if info, err := os.Stat(expandHome(spec)); err == nil && info.IsDir() {
+		dir, _ := filepath.Abs(expandHome(spec))
+		if _, err := git(dir, "rev-parse", "--git-dir"); err != nil {
+			return Repo{}, fmt.Errorf("%s is not a git repository", spec)
+		}
+		return Repo{Spec: spec, Name: filepath.Base(dir), Dir: dir}, nil
+	}
+
+	url := cloneURL(spec)
+	name := repoName(spec)
+	dir := filepath.Join(cacheDir, strings.ReplaceAll(name, "/", "-"))
+	repo := Repo{Spec: spec, Name: name, Dir: dir, Cloned: true}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		if _, err := git(dir, "fetch", "--prune", "origin"); err != nil {
+			return repo, err
+		}
+		return repo, nil
+	}
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return repo, err
+	}
+	if _, err := git(cacheDir, "clone", url, dir); err != nil {
+		return repo, err
+	}
+	return repo, nil
+}
+
+// cloneURL turns owner/repo and host/group/repo into HTTPS clone URLs;
+// URLs and scp-like addresses are kept
+func cloneURL(spec string) string {
+	if strings.Contains(spec, "://") || strings.Contains(spec, "@") {
+		return spec
+	}
+	if host, _, _ := strings.Cut(spec, "/"); strings.Contains(host, ".") {
+		return "https://" + strings.TrimSuffix(spec, ".git") + ".git"
+	}
+	return "https://github.com/" + strings.TrimSuffix(spec, ".git") + ".git"
+}
+
+// repoName is the repository path of spec without host and .git, e.g.
+// owner/repo
+func repoName(spec string) string {
+	s := strings.TrimSuffix(strings.TrimSuffix(spec, "/"), ".git")
+	if _, rest, found := strings.Cut(s, "://"); found {
+		s = rest
+		if _, path, ok := strings.Cut(s, "/"); ok {
+			s = path
+		}
+	} else if _, path, ok := strings.Cut(s, ":"); ok && strings.Contains(s, "@") {
+		s = path
+	} else if host, path, ok := strings.Cut(s, "/"); ok && strings.Contains(host, ".") {
+		s = path
+	}
+	return s
+}
+
+// DefaultBranch is the branch work starts from in dir: origin's default
+// branch, else the checked-out one, else main.
+func DefaultBranch(dir string) string {
+	if out, err := git(dir, "symbolic-ref", "--short", "refs/remotes/origin/HEAD"); err == nil {
+		return strings.TrimPrefix(strings.TrimSpace(out), "origin/")
+	}
+	if out, err := git(dir, "symbolic-ref", "--short", "HEAD"); err == nil {
+		return strings.TrimSpace(out)
+	}
+	return "main"
+}
+
+// HasChanges reports whether dir has uncommitted changes
+func HasChanges(dir string) (bool, error) {
+	out, err := git(dir, "status", "--porcelain")
+	return strings.TrimSpace(out) != "", err
+}
+
+func expandHome(path string) string {
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(path, "~/") {
+		return filepath.Join(home, path[2:])
+	}
+	return path
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = httpclient.CommandEnv()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,114 @@
+package fleet
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func initRepo(t *testing.T, branch string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", branch},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestParseRepoList(t *testing.T) {
+	specs, err := ParseRepoList(strings.NewReader("# services\nacme/payments\n\n  ~/src/billing  # local\nacme/payments\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 2 || specs[0] != "acme/payments" || specs[1] != "~/src/billing" {
+		t.Errorf("ParseRepoList = %q", specs)
+	}
+}
+
+func TestCloneURLAndName(t *testing.T) {
+	cases := []struct{ spec, url, name string }{
+		{"acme/payments", "https://github.com/acme/payments.git", "acme/payments"},
+		{"gitlab.example.com/platform/gateway", "https://gitlab.example.com/platform/gateway.git", "platform/gateway"},
+		{"git@github.com:acme/search.git", "git@github.com:acme/search.git", "acme/search"},
+		{"https://github.com/acme/web", "https://github.com/acme/web", "acme/web"},
+	}
+	for _, c := range cases {
+		if got := cloneURL(c.spec); got != c.url {
+			t.Errorf("cloneURL(%q) = %q, want %q", c.spec, got, c.url)
+		}
+		if got := repoName(c.spec); got != c.name {
+			t.Errorf("repoName(%q) = %q, want %q", c.spec, got, c.name)
+		}
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	upstream := initRepo(t, "trunk")
+	cache := t.TempDir()
+
+	local, err := Checkout(upstream, cache)
+	if err != nil || local.Cloned || local.Dir != upstream {
+		t.Fatalf("a local checkout is used as is: %+v %v", local, err)
+	}
+	if _, err := Checkout(t.TempDir(), cache); err == nil {
+		t.Error("a directory that is not a repository should fail")
+	}
+
+	url := "file://" + upstream
+	clone, err := Checkout(url, cache)
+	if err != nil || !clone.Cloned {
+		t.Fatalf("Checkout(%s) = %+v, %v", url, clone, err)
+	}
+	if got := DefaultBranch(clone.Dir); got != "trunk" {
+		t.Errorf("DefaultBranch = %q, want trunk", got)
+	}
+	if again, err := Checkout(url, cache); err != nil || again.Dir != clone.Dir {
+		t.Errorf("a second run should fetch the same clone: %+v %v", again, err)
+	}
+
+	if changed, _ := HasChanges(clone.Dir); changed {
+		t.Error("a fresh clone has no changes")
+	}
+	_ = os.WriteFile(filepath.Join(clone.Dir, "new.txt"), []byte("x"), 0644)
+	if changed, _ := HasChanges(clone.Dir); !changed {
+		t.Error("an untracked file is a change")
+	}
+}
+
+func TestReport(t *testing.T) {
+	report := &Report{Task: "upgrade Go", Duration: "1m", Results: []Result{
+		{Repo: "acme/a", Status: StatusDone, PR: "https://github.com/acme/a/pull/1", Files: []string{"go.mod"}},
+		{Repo: "acme/b", Status: StatusFailed, Error: "task failed: tests | build\nmore", Worktree: "/tmp/wt"},
+		{Repo: "acme/c", Status: StatusUnchanged},
+	}}
+	if got := report.Summary(); got != "done: 1  unchanged: 1  failed: 1  skipped: 0" {
+		t.Errorf("Summary = %q", got)
+	}
+	md := report.Markdown()
+	for _, want := range []string{"| acme/a | done | https://github.com/acme/a/pull/1 | 1 files changed |", `tests \| build (worktree: /tmp/wt) |`} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown lacks %q:\n%s", want, md)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "fleet.json")
+	if err := report.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	var back Report
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &back); err != nil || len(back.Results) != 3 {
+		t.Errorf("JSON report = %s, %v", data, err)
+	}
+}
@@ -0,0 +1,90 @@
+package fleet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Outcomes of a repository's run
+const (
+	StatusDone      = "done"
+	StatusUnchanged = "unchanged"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+// Result is one repository's outcome in a fleet run
+type Result struct {
+	Repo     string   `json:"repo"`
+	Status   string   `json:"status"`
+	Branch   string   `json:"branch,omitempty"`
+	PR       string   `json:"pr,omitempty"`
+	Files    []string `json:"files,omitempty"`
+	Worktree string   `json:"worktree,omitempty"`
+	Error    string   `json:"error,omitempty"`
+	Duration string   `json:"duration"`
+}
+
+// Report is a fleet run: the task and every repository's result, in the
+// order of the repository list
+type Report struct {
+	Task     string    `json:"task"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+	Results  []Result  `json:"results"`
+}
+
+// Count returns how many repositories ended with status
+func (r *Report) Count(status string) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary is the one-line tally of the run
+func (r *Report) Summary() string {
+	return fmt.Sprintf("done: %d  unchanged: %d  failed: %d  skipped: %d",
+		r.Count(StatusDone), r.Count(StatusUnchanged), r.Count(StatusFailed), r.Count(StatusSkipped))
+}
+
+// Markdown renders the report as a table, for a tracking issue or a wiki
+func (r *Report) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Fleet run\n\n**Task:** %s\n\n%s, %s in %s\n\n", r.Task,
+		r.Started.Format("2006-01-02 15:04"), r.Summary(), r.Duration)
+	b.WriteString("| Repository | Status | Pull request | Notes |\n|---|---|---|---|\n")
+	for _, res := range r.Results {
+		notes := ""
+		switch {
+		case res.Error != "":
+			notes = strings.SplitN(res.Error, "\n", 2)[0]
+		case len(res.Files) > 0:
+			notes = fmt.Sprintf("%d files changed", len(res.Files))
+		}
+		if res.Worktree != "" && res.Status == StatusFailed {
+			notes += " (worktree: " + res.Worktree + ")"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", res.Repo, res.Status, res.PR, strings.ReplaceAll(notes, "|", "\\|"))
+	}
+	return b.String()
+}
+
+// Write saves the report to path: Markdown for .md files, JSON otherwise
+func (r *Report) Write(path string) error {
+	data := []byte(r.Markdown())
+	if !strings.EqualFold(filepath.Ext(path), ".md") {
+		var err error
+		if data, err = json.MarshalIndent(r, "", "  "); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
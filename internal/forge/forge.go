@@ -0,0 +1,195 @@
+// Package forge abstracts the code host a repository lives on, so the issue
+// workflow (fetching issues, opening pull or merge requests, reading review
+// comments and CI) works the same on GitHub and GitLab.
+package forge
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gptcode/internal/ci"
+	"gptcode/internal/config"
+	"gptcode/internal/github"
+)
+
+// Forge is a code host's issues, pull requests (merge requests on GitLab),
+// review comments and CI. Issues and pull requests use the github package's
+// types whichever host they come from.
+type Forge interface {
+	// Name is the host's name for messages, e.g. "GitLab"
+	Name() string
+	// Repo is the repository path: owner/repo, or group/subgroup/repo
+	Repo() string
+
+	FetchIssue(number int) (*github.Issue, error)
+	ListIssues(filter github.IssueFilter) ([]*github.Issue, error)
+	CommentOnIssue(number int, body string) error
+
+	CreatePR(opts github.PRCreateOptions) (*github.PullRequest, error)
+	// FetchPR returns a pull request with its body, head and base branches
+	FetchPR(number int) (*github.PullRequest, error)
+	UpdatePRBody(number int, body string) error
+	PRURL(number int) string
+	GetUnresolvedComments(number int) ([]github.ReviewComment, error)
+	FetchPRDiffSummary(number int) (*github.DiffSummary, error)
+	// FetchPRDiff returns the unified diff of a pull request
+	FetchPRDiff(number int) (string, error)
+	// PostReview submits a review with inline comments and a verdict
+	PostReview(number int, review github.ReviewSubmission) error
+	CommentOnPR(number int, body string) error
+	MarkPRReady(number int) error
+	// BranchPRs are the pull requests opened from a branch, in any state,
+	// with the github package's PRState values
+	BranchPRs(branch string) ([]*github.PullRequest, error)
+
+	// CurrentUser is the login the host's CLI is authenticated as, or ""
+	CurrentUser() string
+
+	ci.Checks
+}
+
+// Supported forges
+const (
+	KindGitHub = "github"
+	KindGitLab = "gitlab"
+)
+
+// Remote is the host and repository path of a git remote
+type Remote struct {
+	Host string
+	Path string
+}
+
+// ParseRemote splits a remote URL (HTTPS, ssh:// or scp-like
+// git@host:path) into its host and repository path.
+func ParseRemote(rawURL string) (Remote, bool) {
+	rawURL = strings.TrimSpace(rawURL)
+	var r Remote
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return Remote{}, false
+		}
+		r.Host = u.Host
+		if u.Scheme == "ssh" || u.Scheme == "git" {
+			// The SSH port is not the web port
+			r.Host = u.Hostname()
+		}
+		r.Path = u.Path
+	} else {
+		hostPart, path, found := strings.Cut(rawURL, ":")
+		if !found {
+			return Remote{}, false
+		}
+		if _, host, ok := strings.Cut(hostPart, "@"); ok {
+			hostPart = host
+		}
+		r.Host, r.Path = hostPart, path
+	}
+	r.Path = strings.TrimSuffix(strings.Trim(r.Path, "/"), ".git")
+	if r.Host == "" || !strings.Contains(r.Path, "/") {
+		return Remote{}, false
+	}
+	return r, true
+}
+
+// Remotes returns the remotes of the repository in dir, keyed by name.
+func Remotes(dir string) map[string]Remote {
+	cmd := exec.Command("git", "remote", "-v")
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	remotes := make(map[string]Remote)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if r, ok := ParseRemote(fields[1]); ok {
+			remotes[fields[0]] = r
+		}
+	}
+	return remotes
+}
+
+// Kind tells which forge serves host: git.forge in the project config
+// wins, then GITLAB_HOST, then a hostname mentioning gitlab. Anything else
+// is GitHub, including GitHub Enterprise hosts.
+func Kind(dir, host string) string {
+	if pc, _ := config.LoadProjectConfig(dir); pc.Git.Forge != "" {
+		return strings.ToLower(pc.Git.Forge)
+	}
+	if env := os.Getenv("GITLAB_HOST"); env != "" && hostOf(env) == host {
+		return KindGitLab
+	}
+	if strings.Contains(strings.ToLower(host), "gitlab") {
+		return KindGitLab
+	}
+	return KindGitHub
+}
+
+func hostOf(s string) string {
+	if u, err := url.Parse(s); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return strings.TrimSuffix(s, "/")
+}
+
+// Detect returns the forge of the repository in dir: the "upstream" remote
+// in a fork checkout, otherwise "origin". repo, when set, overrides the
+// repository and may name the host too (gitlab.example.com/group/repo).
+func Detect(dir, repo string) (Forge, error) {
+	remotes := Remotes(dir)
+	r, ok := remotes["upstream"]
+	if !ok {
+		r = remotes["origin"]
+	}
+	if repo != "" {
+		if host, path, found := strings.Cut(repo, "/"); found && strings.Contains(host, ".") && strings.Contains(path, "/") {
+			r = Remote{Host: host, Path: path}
+		} else {
+			r.Path = repo
+		}
+	}
+	if r.Path == "" {
+		return nil, fmt.Errorf("could not detect the repository; use --repo")
+	}
+	if r.Host == "" {
+		r.Host = "github.com"
+	}
+
+	switch kind := Kind(dir, r.Host); kind {
+	case KindGitLab:
+		return NewGitLab(r.Host, r.Path), nil
+	case KindGitHub:
+		return NewGitHub(r.Path, dir), nil
+	default:
+		return nil, fmt.Errorf("unknown forge %q in git.forge (use github or gitlab)", kind)
+	}
+}
+
+// ResolvePushTarget picks the remote to push to, on any host. An explicit
+// remote wins; otherwise origin is used, and PRs target "upstream" when
+// that remote exists and points elsewhere.
+func ResolvePushTarget(dir, remote string) (github.PushTarget, error) {
+	remotes := Remotes(dir)
+	if remote == "" {
+		remote = "origin"
+	}
+	r, ok := remotes[remote]
+	if !ok {
+		return github.PushTarget{}, fmt.Errorf("remote %q not found", remote)
+	}
+	upstream := r.Path
+	if u, ok := remotes["upstream"]; ok {
+		upstream = u.Path
+	}
+	return github.PushTarget{Remote: remote, Repo: r.Path, UpstreamRepo: upstream}, nil
+}
@@ -0,0 +1,32 @@
+package forge
+
+import (
+	"gptcode/internal/ci"
+	"gptcode/internal/github"
+)
+
+// GitHub is the gh CLI backed forge
+type GitHub struct {
+	*github.Client
+	checks *ci.Handler
+}
+
+// NewGitHub returns the forge for the GitHub repository owner/repo checked
+// out in dir.
+func NewGitHub(repo, dir string) *GitHub {
+	client := github.NewClient(repo)
+	client.SetWorkDir(dir)
+	return &GitHub{Client: client, checks: ci.NewHandler(repo, dir, nil, "")}
+}
+
+func (g *GitHub) Name() string { return "GitHub" }
+
+func (g *GitHub) CurrentUser() string { return github.CurrentUser() }
+
+func (g *GitHub) CheckPRStatus(prNumber int) ([]ci.CIStatus, error) {
+	return g.checks.CheckPRStatus(prNumber)
+}
+
+func (g *GitHub) FetchCILogs(prNumber int, checkName string) (string, error) {
+	return g.checks.FetchCILogs(prNumber, checkName)
+}
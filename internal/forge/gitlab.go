@@ -0,0 +1,561 @@
+package forge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gptcode/internal/ci"
+	"gptcode/internal/github"
+	"gptcode/internal/httpclient"
+)
+
+// GitLab is the forge for gitlab.com and self-hosted GitLab, driven through
+// the glab CLI so authentication works as it does for `glab` itself.
+type GitLab struct {
+	host    string
+	project string // group/subgroup/repo
+
+	// run executes glab; tests replace it
+	run func(args ...string) ([]byte, error)
+}
+
+// NewGitLab returns the forge for project on host.
+func NewGitLab(host, project string) *GitLab {
+	return &GitLab{host: host, project: project, run: glab}
+}
+
+func glab(args ...string) ([]byte, error) {
+	cmd := exec.Command("glab", args...)
+	cmd.Env = httpclient.CommandEnv()
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%w\nOutput: %s", err, strings.TrimSpace(string(exitErr.Stderr)+string(output)))
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+func (g *GitLab) Name() string { return "GitLab" }
+
+func (g *GitLab) Repo() string { return g.project }
+
+// api calls the REST API under the project, decoding the response into v
+// when it is not nil.
+func (g *GitLab) api(method, path string, fields map[string]string, v interface{}) ([]byte, error) {
+	args := []string{"api", "--hostname", g.host, "-X", method,
+		"projects/" + url.PathEscape(g.project) + path}
+	for k, val := range fields {
+		args = append(args, "-f", k+"="+val)
+	}
+	output, err := g.run(args...)
+	if err != nil {
+		return nil, err
+	}
+	if v != nil {
+		if err := json.Unmarshal(output, v); err != nil {
+			return nil, fmt.Errorf("failed to parse GitLab response: %w", err)
+		}
+	}
+	return output, nil
+}
+
+// gitlabIssue is the API shape of an issue
+type gitlabIssue struct {
+	IID         int      `json:"iid"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	State       string   `json:"state"`
+	Labels      []string `json:"labels"`
+	Author      struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	WebURL    string `json:"web_url"`
+	Assignees []struct {
+		Username string `json:"username"`
+	} `json:"assignees"`
+	Milestone *struct {
+		Title string `json:"title"`
+	} `json:"milestone"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+	UserNotes  int    `json:"user_notes_count"`
+	References struct {
+		Full string `json:"full"`
+	} `json:"references"`
+}
+
+func (r gitlabIssue) toIssue(project string) *github.Issue {
+	issue := &github.Issue{
+		Number:     r.IID,
+		Title:      r.Title,
+		Body:       r.Description,
+		State:      r.State,
+		Labels:     r.Labels,
+		Author:     r.Author.Username,
+		URL:        r.WebURL,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		Comments:   r.UserNotes,
+		Repository: project,
+	}
+	for _, a := range r.Assignees {
+		issue.Assignees = append(issue.Assignees, a.Username)
+	}
+	if r.Milestone != nil {
+		issue.Milestone = r.Milestone.Title
+	}
+	return issue
+}
+
+func (g *GitLab) FetchIssue(number int) (*github.Issue, error) {
+	var raw gitlabIssue
+	if _, err := g.api("GET", fmt.Sprintf("/issues/%d", number), nil, &raw); err != nil {
+		return nil, fmt.Errorf("failed to fetch issue #%d: %w", number, err)
+	}
+	return raw.toIssue(g.project), nil
+}
+
+func (g *GitLab) ListIssues(filter github.IssueFilter) ([]*github.Issue, error) {
+	query := url.Values{}
+	switch filter.State {
+	case "", "open":
+		query.Set("state", "opened")
+	case "all":
+	default:
+		query.Set("state", filter.State)
+	}
+	if len(filter.Labels) > 0 {
+		query.Set("labels", strings.Join(filter.Labels, ","))
+	}
+	if filter.Milestone != "" {
+		query.Set("milestone", filter.Milestone)
+	}
+	perPage := 100
+	if filter.Limit > 0 && filter.Limit < perPage {
+		perPage = filter.Limit
+	}
+	query.Set("per_page", strconv.Itoa(perPage))
+
+	var raws []gitlabIssue
+	if _, err := g.api("GET", "/issues?"+query.Encode(), nil, &raws); err != nil {
+		return nil, fmt.Errorf("failed to list issues: %w", err)
+	}
+	issues := make([]*github.Issue, 0, len(raws))
+	for _, r := range raws {
+		issues = append(issues, r.toIssue(g.project))
+	}
+	return issues, nil
+}
+
+func (g *GitLab) CommentOnIssue(number int, body string) error {
+	if _, err := g.api("POST", fmt.Sprintf("/issues/%d/notes", number), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to comment on issue #%d: %w", number, err)
+	}
+	return nil
+}
+
+// projectURL is the web address of the project, which glab accepts as --repo
+func (g *GitLab) projectURL() string {
+	return "https://" + g.host + "/" + g.project
+}
+
+var mergeRequestURL = regexp.MustCompile(`https?://\S+/-/merge_requests/(\d+)`)
+
+// CreatePR opens a merge request with glab, which resolves reviewer and
+// assignee usernames.
+func (g *GitLab) CreatePR(opts github.PRCreateOptions) (*github.PullRequest, error) {
+	args := []string{"mr", "create", "--yes", "--repo", g.projectURL(),
+		"--title", opts.Title, "--description", opts.Body,
+		"--source-branch", opts.HeadBranch}
+	if opts.BaseBranch != "" {
+		args = append(args, "--target-branch", opts.BaseBranch)
+	}
+	if opts.HeadRepo != "" {
+		args = append(args, "--head", opts.HeadRepo)
+	}
+	if opts.IsDraft {
+		args = append(args, "--draft")
+	}
+	if len(opts.Labels) > 0 {
+		args = append(args, "--label", strings.Join(opts.Labels, ","))
+	}
+	if len(opts.Assignees) > 0 {
+		args = append(args, "--assignee", strings.Join(opts.Assignees, ","))
+	}
+	if len(opts.Reviewers) > 0 {
+		args = append(args, "--reviewer", strings.Join(opts.Reviewers, ","))
+	}
+
+	output, err := g.run(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create merge request: %w", err)
+	}
+	m := mergeRequestURL.FindStringSubmatch(string(output))
+	if m == nil {
+		return nil, fmt.Errorf("could not find the merge request URL in: %s", strings.TrimSpace(string(output)))
+	}
+	number, _ := strconv.Atoi(m[1])
+	return &github.PullRequest{
+		Number:     number,
+		Title:      opts.Title,
+		Body:       opts.Body,
+		URL:        m[0],
+		HeadBranch: opts.HeadBranch,
+		BaseBranch: opts.BaseBranch,
+		IsDraft:    opts.IsDraft,
+		Labels:     opts.Labels,
+		Assignees:  opts.Assignees,
+		Reviewers:  opts.Reviewers,
+		Repository: g.project,
+	}, nil
+}
+
+// FetchPR returns a merge request, with GitLab's state mapped to GitHub's
+func (g *GitLab) FetchPR(number int) (*github.PullRequest, error) {
+	var raw struct {
+		IID          int    `json:"iid"`
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+		State        string `json:"state"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		WebURL       string `json:"web_url"`
+		Draft        bool   `json:"draft"`
+	}
+	if _, err := g.api("GET", fmt.Sprintf("/merge_requests/%d", number), nil, &raw); err != nil {
+		return nil, fmt.Errorf("failed to fetch merge request !%d: %w", number, err)
+	}
+	state := github.PRStateClosed
+	switch raw.State {
+	case "opened":
+		state = github.PRStateOpen
+	case "merged":
+		state = github.PRStateMerged
+	}
+	return &github.PullRequest{
+		Number:     raw.IID,
+		Title:      raw.Title,
+		Body:       raw.Description,
+		State:      state,
+		HeadBranch: raw.SourceBranch,
+		BaseBranch: raw.TargetBranch,
+		URL:        raw.WebURL,
+		IsDraft:    raw.Draft,
+		Repository: g.project,
+	}, nil
+}
+
+func (g *GitLab) UpdatePRBody(number int, body string) error {
+	if _, err := g.api("PUT", fmt.Sprintf("/merge_requests/%d", number), map[string]string{"description": body}, nil); err != nil {
+		return fmt.Errorf("failed to update merge request !%d: %w", number, err)
+	}
+	return nil
+}
+
+func (g *GitLab) PRURL(number int) string {
+	return fmt.Sprintf("%s/-/merge_requests/%d", g.projectURL(), number)
+}
+
+// BranchPRs returns the merge requests from branch, with GitLab's states
+// mapped to GitHub's.
+func (g *GitLab) BranchPRs(branch string) ([]*github.PullRequest, error) {
+	query := url.Values{"source_branch": {branch}, "state": {"all"}, "per_page": {"100"}}
+	var raws []struct {
+		IID          int    `json:"iid"`
+		Title        string `json:"title"`
+		State        string `json:"state"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		WebURL       string `json:"web_url"`
+		SHA          string `json:"sha"`
+	}
+	if _, err := g.api("GET", "/merge_requests?"+query.Encode(), nil, &raws); err != nil {
+		return nil, fmt.Errorf("failed to list merge requests of %s: %w", branch, err)
+	}
+	prs := make([]*github.PullRequest, 0, len(raws))
+	for _, r := range raws {
+		state := github.PRStateClosed
+		switch r.State {
+		case "opened":
+			state = github.PRStateOpen
+		case "merged":
+			state = github.PRStateMerged
+		}
+		prs = append(prs, &github.PullRequest{
+			Number:     r.IID,
+			Title:      r.Title,
+			State:      state,
+			HeadBranch: r.SourceBranch,
+			BaseBranch: r.TargetBranch,
+			URL:        r.WebURL,
+			HeadSHA:    r.SHA,
+			Repository: g.project,
+		})
+	}
+	return prs, nil
+}
+
+// GetUnresolvedComments returns the notes of unresolved merge request
+// threads.
+func (g *GitLab) GetUnresolvedComments(number int) ([]github.ReviewComment, error) {
+	var discussions []struct {
+		Notes []struct {
+			ID     int64 `json:"id"`
+			Author struct {
+				Username string `json:"username"`
+			} `json:"author"`
+			Body       string `json:"body"`
+			System     bool   `json:"system"`
+			Resolvable bool   `json:"resolvable"`
+			Resolved   bool   `json:"resolved"`
+			CreatedAt  string `json:"created_at"`
+			Position   *struct {
+				NewPath string `json:"new_path"`
+				NewLine int    `json:"new_line"`
+			} `json:"position"`
+		} `json:"notes"`
+	}
+	if _, err := g.api("GET", fmt.Sprintf("/merge_requests/%d/discussions?per_page=100", number), nil, &discussions); err != nil {
+		return nil, fmt.Errorf("failed to fetch merge request comments: %w", err)
+	}
+
+	var comments []github.ReviewComment
+	for _, d := range discussions {
+		for _, n := range d.Notes {
+			if n.System || !n.Resolvable || n.Resolved || n.Body == "" {
+				continue
+			}
+			c := github.ReviewComment{
+				ID:        strconv.FormatInt(n.ID, 10),
+				Author:    n.Author.Username,
+				Body:      n.Body,
+				State:     "UNRESOLVED",
+				CreatedAt: n.CreatedAt,
+			}
+			if n.Position != nil {
+				c.Path, c.Line = n.Position.NewPath, n.Position.NewLine
+			}
+			comments = append(comments, c)
+		}
+	}
+	return comments, nil
+}
+
+func (g *GitLab) FetchPRDiffSummary(number int) (*github.DiffSummary, error) {
+	var diffs []struct {
+		NewPath string `json:"new_path"`
+		Diff    string `json:"diff"`
+	}
+	if _, err := g.api("GET", fmt.Sprintf("/merge_requests/%d/diffs?per_page=100", number), nil, &diffs); err != nil {
+		return nil, fmt.Errorf("failed to fetch merge request diff: %w", err)
+	}
+
+	summary := &github.DiffSummary{ChangedFiles: len(diffs)}
+	for _, d := range diffs {
+		fc := github.FileChange{Path: d.NewPath}
+		for _, line := range strings.Split(d.Diff, "\n") {
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			case strings.HasPrefix(line, "+"):
+				fc.Additions++
+			case strings.HasPrefix(line, "-"):
+				fc.Deletions++
+			}
+		}
+		summary.Additions += fc.Additions
+		summary.Deletions += fc.Deletions
+		summary.Files = append(summary.Files, fc)
+	}
+	return summary, nil
+}
+
+// FetchPRDiff rebuilds the merge request's unified diff from its
+// per-file diffs
+func (g *GitLab) FetchPRDiff(number int) (string, error) {
+	var diffs []struct {
+		OldPath string `json:"old_path"`
+		NewPath string `json:"new_path"`
+		Diff    string `json:"diff"`
+	}
+	if _, err := g.api("GET", fmt.Sprintf("/merge_requests/%d/diffs?per_page=100", number), nil, &diffs); err != nil {
+		return "", fmt.Errorf("failed to fetch merge request diff: %w", err)
+	}
+	var sb strings.Builder
+	for _, d := range diffs {
+		fmt.Fprintf(&sb, "diff --git a/%s b/%s\n--- a/%s\n+++ b/%s\n%s", d.OldPath, d.NewPath, d.OldPath, d.NewPath, d.Diff)
+		if !strings.HasSuffix(d.Diff, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String(), nil
+}
+
+// PostReview posts each inline comment as a diff discussion and the summary
+// as a note, and approves the merge request when the verdict is approve.
+// GitLab has no "request changes" state, so that verdict is only stated in
+// the summary. Comments GitLab cannot place on the diff go into the
+// summary.
+func (g *GitLab) PostReview(number int, review github.ReviewSubmission) error {
+	var mr struct {
+		DiffRefs struct {
+			BaseSHA  string `json:"base_sha"`
+			StartSHA string `json:"start_sha"`
+			HeadSHA  string `json:"head_sha"`
+		} `json:"diff_refs"`
+	}
+	if _, err := g.api("GET", fmt.Sprintf("/merge_requests/%d", number), nil, &mr); err != nil {
+		return fmt.Errorf("failed to fetch merge request !%d: %w", number, err)
+	}
+
+	body := review.Body
+	for _, c := range review.Comments {
+		_, err := g.api("POST", fmt.Sprintf("/merge_requests/%d/discussions", number), map[string]string{
+			"body":                    c.Body,
+			"position[position_type]": "text",
+			"position[base_sha]":      mr.DiffRefs.BaseSHA,
+			"position[start_sha]":     mr.DiffRefs.StartSHA,
+			"position[head_sha]":      mr.DiffRefs.HeadSHA,
+			"position[old_path]":      c.Path,
+			"position[new_path]":      c.Path,
+			"position[new_line]":      strconv.Itoa(c.Line),
+		}, nil)
+		if err != nil {
+			body += fmt.Sprintf("\n\n**%s:%d**\n\n%s", c.Path, c.Line, c.Body)
+		}
+	}
+	if review.Event == github.ReviewRequestChanges {
+		body = "**Changes requested**\n\n" + body
+	}
+	if err := g.CommentOnPR(number, body); err != nil {
+		return err
+	}
+	if review.Event == github.ReviewApprove {
+		if _, err := g.api("POST", fmt.Sprintf("/merge_requests/%d/approve", number), nil, nil); err != nil {
+			return fmt.Errorf("failed to approve merge request !%d: %w", number, err)
+		}
+	}
+	return nil
+}
+
+func (g *GitLab) CommentOnPR(number int, body string) error {
+	if _, err := g.api("POST", fmt.Sprintf("/merge_requests/%d/notes", number), map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to comment on merge request !%d: %w", number, err)
+	}
+	return nil
+}
+
+func (g *GitLab) MarkPRReady(number int) error {
+	if _, err := g.run("mr", "update", strconv.Itoa(number), "--ready", "--repo", g.projectURL()); err != nil {
+		return fmt.Errorf("failed to mark merge request !%d ready: %w", number, err)
+	}
+	return nil
+}
+
+func (g *GitLab) CurrentUser() string {
+	output, err := g.run("api", "--hostname", g.host, "user")
+	if err != nil {
+		return ""
+	}
+	var user struct {
+		Username string `json:"username"`
+	}
+	_ = json.Unmarshal(output, &user)
+	return user.Username
+}
+
+// gitlabJob is a job of a pipeline
+type gitlabJob struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	Stage        string `json:"stage"`
+	Status       string `json:"status"`
+	AllowFailure bool   `json:"allow_failure"`
+	WebURL       string `json:"web_url"`
+}
+
+// latestJobs returns the jobs of the merge request's most recent pipeline.
+func (g *GitLab) latestJobs(number int) ([]gitlabJob, error) {
+	var pipelines []struct {
+		ID int64 `json:"id"`
+	}
+	if _, err := g.api("GET", fmt.Sprintf("/merge_requests/%d/pipelines", number), nil, &pipelines); err != nil {
+		return nil, fmt.Errorf("failed to fetch pipelines: %w", err)
+	}
+	if len(pipelines) == 0 {
+		return nil, nil
+	}
+	var jobs []gitlabJob
+	if _, err := g.api("GET", fmt.Sprintf("/pipelines/%d/jobs?per_page=100", pipelines[0].ID), nil, &jobs); err != nil {
+		return nil, fmt.Errorf("failed to fetch pipeline jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// jobConclusion maps a job status to the success, failure or pending of
+// ci.CIStatus. Manual and skipped jobs do not hold a merge request back,
+// nor do failures of jobs allowed to fail.
+func jobConclusion(job gitlabJob) string {
+	switch job.Status {
+	case "success", "skipped", "manual":
+		return "success"
+	case "failed", "canceled":
+		if job.AllowFailure {
+			return "success"
+		}
+		return "failure"
+	default:
+		return "pending"
+	}
+}
+
+// CheckPRStatus reports the jobs of the merge request's latest pipeline.
+func (g *GitLab) CheckPRStatus(number int) ([]ci.CIStatus, error) {
+	jobs, err := g.latestJobs(number)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]ci.CIStatus, 0, len(jobs))
+	for _, job := range jobs {
+		statuses = append(statuses, ci.CIStatus{
+			Name:       job.Name,
+			State:      job.Status,
+			Conclusion: jobConclusion(job),
+			URL:        job.WebURL,
+			LogURL:     job.WebURL,
+		})
+	}
+	return statuses, nil
+}
+
+// FetchCILogs returns the logs of the failed jobs of the latest pipeline,
+// or of the job named checkName, each headed by a "Job:" line.
+func (g *GitLab) FetchCILogs(number int, checkName string) (string, error) {
+	jobs, err := g.latestJobs(number)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, job := range jobs {
+		if checkName != "" && job.Name != checkName || checkName == "" && jobConclusion(job) != "failure" {
+			continue
+		}
+		trace, err := g.api("GET", fmt.Sprintf("/jobs/%d/trace", job.ID), nil, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch the log of job %s: %w", job.Name, err)
+		}
+		fmt.Fprintf(&sb, "Job: %s\n%s\n", job.Name, trace)
+	}
+	if sb.Len() == 0 {
+		return "", fmt.Errorf("no failed jobs in the latest pipeline of merge request !%d", number)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,230 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Pull request states, as gh reports them. Forges map theirs to these.
+const (
+	PRStateOpen   = "OPEN"
+	PRStateMerged = "MERGED"
+	PRStateClosed = "CLOSED"
+)
+
+// BranchPRs returns the pull requests opened from branch, in any state.
+func (c *Client) BranchPRs(branch string) ([]*PullRequest, error) {
+	cmd := ghCommand("pr", "list", "--head", branch, "--state", "all",
+		"--json", "number,title,state,headRefName,headRefOid,baseRefName,url",
+		"--repo", c.repo)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PRs of %s: %w", branch, err)
+	}
+	var prs []*PullRequest
+	if err := json.Unmarshal(output, &prs); err != nil {
+		return nil, fmt.Errorf("failed to parse PRs of %s: %w", branch, err)
+	}
+	return prs, nil
+}
+
+// CleanupOptions selects the branches StaleBranches returns
+type CleanupOptions struct {
+	// MergedOnly keeps branches whose PRs were closed without merging
+	MergedOnly bool
+	// Remote also considers the branches on this remote; "" only looks at
+	// local branches
+	Remote string
+	// All considers every branch, not only the ones agents create
+	All bool
+	// Keep are branches never deleted, besides the current one
+	Keep []string
+}
+
+// StaleBranch is a branch whose pull request was merged or closed
+type StaleBranch struct {
+	Name string
+	// Remote is the remote the branch is on, or "" for a local branch
+	Remote string
+	PR     *PullRequest
+	// Tip is the commit the branch points at
+	Tip string
+}
+
+func (b StaleBranch) String() string {
+	if b.Remote != "" {
+		return b.Remote + "/" + b.Name
+	}
+	return b.Name
+}
+
+// agentTypes are the {type}s of branch templates
+const agentTypes = `(?:feat|fix|docs|test|refactor|perf|ci|chore)`
+
+// IsAgentBranch reports whether gptcode creates branches named like this:
+// daemon job branches (gptcode/deps-20250101) and issue branches, named
+// issue-123-slug or after template (git.branch_template), with their
+// stacked parts (-part-2).
+func IsAgentBranch(name, template string) bool {
+	if strings.HasPrefix(name, "gptcode/") {
+		return true
+	}
+	for _, re := range issueBranchPatterns(template) {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// issueBranchPatterns match the issue branches gptcode names, with the
+// default template and with template (git.branch_template) when set, and
+// their stacked parts. The issue number is the first group.
+func issueBranchPatterns(template string) []*regexp.Regexp {
+	templates := []string{"issue-{issue}-{slug}"}
+	if strings.TrimSpace(template) != "" {
+		templates = append(templates, template)
+	}
+	var patterns []*regexp.Regexp
+	for _, tmpl := range templates {
+		pattern := strings.NewReplacer(
+			`\{issue\}`, `(\d+)`,
+			`\{slug\}`, `[a-z0-9-]*`,
+			`\{type\}`, agentTypes,
+		).Replace(regexp.QuoteMeta(tmpl))
+		patterns = append(patterns, regexp.MustCompile(`^`+pattern+`(?:-part-\d+)?$`))
+	}
+	return patterns
+}
+
+// StaleBranches finds the branches whose pull requests, as returned by prs,
+// are all merged or closed. Branches with an open PR or none are kept, as
+// are the current branch and opts.Keep, and branches with commits the PR
+// does not have: only a tip equal to the PR's head is safe to delete.
+func (c *Client) StaleBranches(opts CleanupOptions, prs func(branch string) ([]*PullRequest, error)) ([]StaleBranch, error) {
+	keep := map[string]bool{}
+	for _, k := range opts.Keep {
+		keep[k] = true
+	}
+	if current, err := c.git("branch", "--show-current"); err == nil && current != "" {
+		keep[current] = true
+	}
+
+	candidates, err := c.branches("refs/heads", "")
+	if err != nil {
+		return nil, err
+	}
+	if opts.Remote != "" {
+		if _, err := c.git("fetch", "--prune", opts.Remote); err != nil {
+			return nil, err
+		}
+		remote, err := c.branches("refs/remotes/"+opts.Remote, opts.Remote)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, remote...)
+	}
+
+	template := c.projectConfig().Git.BranchTemplate
+	looked := map[string]*PullRequest{}
+	var stale []StaleBranch
+	for _, b := range candidates {
+		if keep[b.Name] || b.Name == "HEAD" || (!opts.All && !IsAgentBranch(b.Name, template)) {
+			continue
+		}
+		pr, ok := looked[b.Name]
+		if !ok {
+			list, err := prs(b.Name)
+			if err != nil {
+				return nil, err
+			}
+			pr = finishedPR(list, opts.MergedOnly)
+			looked[b.Name] = pr
+		}
+		if pr != nil && pr.HeadSHA != "" && pr.HeadSHA == b.Tip {
+			b.PR = pr
+			stale = append(stale, b)
+		}
+	}
+	return stale, nil
+}
+
+// finishedPR returns the merged PR of a branch, or its closed one unless
+// mergedOnly, or nil while any PR is open
+func finishedPR(prs []*PullRequest, mergedOnly bool) *PullRequest {
+	var merged, closed *PullRequest
+	for _, pr := range prs {
+		switch strings.ToUpper(pr.State) {
+		case PRStateOpen:
+			return nil
+		case PRStateMerged:
+			if merged == nil || pr.Number > merged.Number {
+				merged = pr
+			}
+		case PRStateClosed:
+			if closed == nil || pr.Number > closed.Number {
+				closed = pr
+			}
+		}
+	}
+	if merged != nil {
+		return merged
+	}
+	if mergedOnly {
+		return nil
+	}
+	return closed
+}
+
+// DeleteBranch deletes a stale branch, locally or on its remote. Local
+// branches are force-deleted, since squash and rebase merges leave them
+// unmerged as far as git can tell; either is only deleted while it still
+// points at b.Tip, so commits made since it was found are not lost.
+func (c *Client) DeleteBranch(b StaleBranch) error {
+	if b.Remote != "" {
+		_, err := c.git("push", "--force-with-lease=refs/heads/"+b.Name+":"+b.Tip, b.Remote, "--delete", b.Name)
+		return err
+	}
+	tip, err := c.git("rev-parse", "--verify", "refs/heads/"+b.Name)
+	if err != nil {
+		return err
+	}
+	if tip != b.Tip {
+		return fmt.Errorf("branch %s has new commits; not deleting it", b.Name)
+	}
+	_, err = c.git("branch", "-D", b.Name)
+	return err
+}
+
+// branches lists the branches under a refs/ prefix, named without it
+func (c *Client) branches(prefix, remote string) ([]StaleBranch, error) {
+	out, err := c.git("for-each-ref", "--format=%(refname) %(objectname)", prefix)
+	if err != nil {
+		return nil, err
+	}
+	var branches []StaleBranch
+	for _, line := range strings.Split(out, "\n") {
+		ref, tip, _ := strings.Cut(line, " ")
+		if name := strings.TrimPrefix(ref, prefix+"/"); ref != "" && name != ref {
+			branches = append(branches, StaleBranch{Name: name, Remote: remote, Tip: tip})
+		}
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
+	return branches, nil
+}
+
+func (c *Client) git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	if c.workDir != "" {
+		cmd.Dir = c.workDir
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w\nOutput: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
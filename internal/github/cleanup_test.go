@@ -0,0 +1,114 @@
+package github
+
+import (
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestStaleBranches(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"branch", "issue-1-merged"},
+		{"branch", "issue-2-closed"},
+		{"branch", "issue-3-open"},
+		{"branch", "feat/4-no-pr"},
+		{"branch", "gptcode/deps-20250101"},
+		{"branch", "experiment"},
+		{"branch", "release-2024"},
+		{"branch", "sprint-12-ui"},
+		{"branch", "fix/9-login-part-2"},
+		{"checkout", "-q", "-b", "issue-6-moved"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "after the PR"},
+		{"checkout", "-q", "-b", "issue-5-current", "main"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	states := map[string][]string{
+		"issue-1-merged":        {PRStateClosed, PRStateMerged},
+		"issue-2-closed":        {PRStateClosed},
+		"issue-3-open":          {PRStateMerged, PRStateOpen},
+		"gptcode/deps-20250101": {"merged"},
+		"issue-5-current":       {PRStateMerged},
+		"experiment":            {PRStateMerged},
+		"release-2024":          {PRStateMerged},
+		"sprint-12-ui":          {PRStateMerged},
+		"fix/9-login-part-2":    {PRStateMerged},
+		"issue-6-moved":         {PRStateMerged},
+	}
+
+	c := NewClient("o/r")
+	c.SetWorkDir(dir)
+	// Every PR's head is main's commit, which issue-6-moved has moved past
+	head, err := c.git("rev-parse", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prs := func(branch string) ([]*PullRequest, error) {
+		var out []*PullRequest
+		for i, s := range states[branch] {
+			out = append(out, &PullRequest{Number: i + 1, State: s, HeadBranch: branch, HeadSHA: head})
+		}
+		return out, nil
+	}
+	names := func(opts CleanupOptions) []string {
+		t.Helper()
+		stale, err := c.StaleBranches(opts, prs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, b := range stale {
+			out = append(out, b.String())
+		}
+		return out
+	}
+
+	if got, want := names(CleanupOptions{}), []string{"gptcode/deps-20250101", "issue-1-merged", "issue-2-closed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stale branches = %v, want %v", got, want)
+	}
+	if got, want := names(CleanupOptions{MergedOnly: true}), []string{"gptcode/deps-20250101", "issue-1-merged"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("merged branches = %v, want %v", got, want)
+	}
+	got := names(CleanupOptions{All: true, MergedOnly: true, Keep: []string{"gptcode/deps-20250101"}})
+	if want := []string{"experiment", "fix/9-login-part-2", "issue-1-merged", "release-2024", "sprint-12-ui"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("all merged branches = %v, want %v", got, want)
+	}
+
+	if err := c.DeleteBranch(StaleBranch{Name: "issue-6-moved", Tip: head}); err == nil {
+		t.Error("expected a branch with new commits kept")
+	}
+	if err := c.DeleteBranch(StaleBranch{Name: "issue-1-merged", Tip: head}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(CleanupOptions{MergedOnly: true}), []string{"gptcode/deps-20250101"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after deleting, merged branches = %v, want %v", got, want)
+	}
+}
+
+func TestIsAgentBranch(t *testing.T) {
+	for _, tc := range []struct {
+		name, template string
+		want           bool
+	}{
+		{"issue-12-fix-login", "", true},
+		{"issue-12-fix-login-part-3", "", true},
+		{"gptcode/deps-20250101", "", true},
+		{"feat/12-add-cache", "{type}/{issue}-{slug}", true},
+		{"feat/12-add-cache", "", false},
+		{"release-2024", "", false},
+		{"sprint-12-ui", "{type}/{issue}-{slug}", false},
+		{"hotfix/12-x", "{type}/{issue}-{slug}", false},
+	} {
+		if got := IsAgentBranch(tc.name, tc.template); got != tc.want {
+			t.Errorf("IsAgentBranch(%q, %q) = %v, want %v", tc.name, tc.template, got, tc.want)
+		}
+	}
+}
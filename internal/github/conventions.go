@@ -0,0 +1,219 @@
+package github
+
+import (
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var conventionalPattern = regexp.MustCompile(`^[a-z]+(\([^)]*\))?!?: `)
+
+// typeKeywords maps words found in labels, titles or messages to
+// conventional commit types, checked in order.
+var typeKeywords = []struct {
+	words    []string
+	typeName string
+}{
+	{[]string{"bug", "fix", "fixes", "fixed", "hotfix", "regression", "crash"}, "fix"},
+	{[]string{"docs", "doc", "documentation", "readme"}, "docs"},
+	{[]string{"test", "tests", "testing", "coverage"}, "test"},
+	{[]string{"refactor", "refactoring", "cleanup"}, "refactor"},
+	{[]string{"perf", "performance", "optimize", "speed"}, "perf"},
+	{[]string{"ci", "build", "pipeline"}, "ci"},
+	{[]string{"chore", "deps", "dependencies", "bump"}, "chore"},
+	{[]string{"feature", "enhancement", "feat", "add", "implement", "support", "new"}, "feat"},
+}
+
+// InferType guesses a conventional commit type from free text such as issue
+// labels, a title or a commit message. It defaults to "chore".
+func InferType(texts ...string) string {
+	words := map[string]bool{}
+	for _, text := range texts {
+		for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !(r >= 'a' && r <= 'z')
+		}) {
+			words[w] = true
+		}
+	}
+	for _, kw := range typeKeywords {
+		for _, w := range kw.words {
+			if words[w] {
+				return kw.typeName
+			}
+		}
+	}
+	return "chore"
+}
+
+// InferType guesses the conventional commit type of the issue from its labels
+// first, then its title.
+func (i *Issue) InferType() string {
+	if t := InferType(i.Labels...); t != "chore" {
+		return t
+	}
+	return InferType(i.Title)
+}
+
+// Slug turns a title into a lowercase, hyphen-separated branch component of
+// at most 50 characters.
+func Slug(title string) string {
+	title = strings.ToLower(title)
+
+	// Replace spaces and special chars with hyphens
+	title = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '-'
+	}, title)
+
+	// Remove consecutive hyphens
+	for strings.Contains(title, "--") {
+		title = strings.ReplaceAll(title, "--", "-")
+	}
+
+	// Trim hyphens from ends
+	title = strings.Trim(title, "-")
+
+	// Limit length
+	if len(title) > 50 {
+		title = title[:50]
+		title = strings.Trim(title, "-")
+	}
+	return title
+}
+
+// BranchNameFromTemplate renders a branch template such as
+// "feat/{issue}-{slug}". Supported placeholders are {issue}, {slug} and
+// {type}. An empty template yields CreateBranchName.
+func (i *Issue) BranchNameFromTemplate(tmpl string) string {
+	if strings.TrimSpace(tmpl) == "" {
+		return i.CreateBranchName()
+	}
+	return strings.NewReplacer(
+		"{issue}", strconv.Itoa(i.Number),
+		"{slug}", Slug(i.Title),
+		"{type}", i.InferType(),
+	).Replace(tmpl)
+}
+
+// BranchNameFor returns the branch name for an issue using the repo's
+// git.branch_template, if any.
+func (c *Client) BranchNameFor(issue *Issue) string {
+	return issue.BranchNameFromTemplate(c.projectConfig().Git.BranchTemplate)
+}
+
+// FormatCommitMessage renders a commit template such as
+// "{type}({scope}): {summary}". Type is inferred from the summary's words
+// when not given, "fixes" or "bug" making it fix; an empty scope drops the
+// parentheses around {scope}. Messages that already follow the
+// conventional format, and empty templates, are returned unchanged.
+func FormatCommitMessage(tmpl, message, typeName, scope string, issue int) string {
+	if strings.TrimSpace(tmpl) == "" || conventionalPattern.MatchString(message) {
+		return message
+	}
+
+	summary, body, _ := strings.Cut(message, "\n")
+	if typeName == "" {
+		typeName = InferType(summary)
+	}
+
+	issueRef := ""
+	if issue > 0 {
+		issueRef = "#" + strconv.Itoa(issue)
+	}
+	if scope == "" {
+		tmpl = strings.ReplaceAll(tmpl, "({scope})", "")
+	}
+	out := strings.NewReplacer(
+		"{type}", typeName,
+		"{scope}", scope,
+		"{summary}", lowerFirst(summary),
+		"{issue}", issueRef,
+	).Replace(tmpl)
+	out = strings.TrimSpace(out)
+
+	if strings.TrimSpace(body) != "" {
+		out += "\n" + body
+	}
+	return out
+}
+
+// ConventionalTypes are the Conventional Commits types commit messages are
+// generated with
+var ConventionalTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// NormalizeCommitMessage tidies a generated commit message: code fences and
+// quotes around it are dropped, and a subject that does not start with a
+// conventional type gets one inferred from it, with scope when given.
+func NormalizeCommitMessage(message, scope string) string {
+	message = strings.TrimSpace(message)
+	if strings.HasPrefix(message, "```") {
+		message = strings.TrimPrefix(message, "```")
+		if nl := strings.IndexByte(message, '\n'); nl >= 0 && !strings.Contains(message[:nl], " ") {
+			message = message[nl+1:]
+		}
+		message = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(message), "```"))
+	}
+	if len(message) > 1 && message[0] == message[len(message)-1] && strings.ContainsRune("\"'", rune(message[0])) {
+		message = strings.TrimSpace(message[1 : len(message)-1])
+	}
+
+	subject, body, _ := strings.Cut(message, "\n")
+	subject = strings.TrimRight(strings.TrimSpace(subject), ".")
+	if !conventionalPattern.MatchString(subject) {
+		prefix := InferType(subject)
+		if scope != "" {
+			prefix += "(" + scope + ")"
+		}
+		subject = prefix + ": " + lowerFirst(subject)
+	}
+	if body = strings.Trim(body, "\n"); body != "" {
+		return subject + "\n\n" + body
+	}
+	return subject
+}
+
+// lowerFirst lowercases the first letter unless the first word looks like an
+// acronym or identifier.
+func lowerFirst(s string) string {
+	if len(s) < 2 || s[1] < 'a' || s[1] > 'z' {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// InferScope returns the last element of the directory shared by all paths
+// (e.g. "auth" for internal/auth/a.go and internal/auth/b.go), or "" when the
+// paths have nothing in common.
+func InferScope(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	common := path.Dir(paths[0])
+	for _, p := range paths[1:] {
+		dir := path.Dir(p)
+		for common != "." && dir != common && !strings.HasPrefix(dir, common+"/") {
+			common = path.Dir(common)
+		}
+	}
+	if common == "." || common == "/" {
+		return ""
+	}
+	return path.Base(common)
+}
+
+// stagedFiles lists the files staged for commit
+func (c *Client) stagedFiles() []string {
+	cmd := exec.Command("git", "diff", "--cached", "--name-only")
+	if c.workDir != "" {
+		cmd.Dir = c.workDir
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(output))
+}
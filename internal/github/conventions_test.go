@@ -0,0 +1,70 @@
+package github
+
+import "testing"
+
+func TestBranchNameFromTemplate(t *testing.T) {
+	issue := &Issue{Number: 42, Title: "Login fails on Safari!", Labels: []string{"bug"}}
+
+	if got := issue.BranchNameFromTemplate(""); got != "issue-42-login-fails-on-safari" {
+		t.Errorf("default branch = %q", got)
+	}
+	if got := issue.BranchNameFromTemplate("{type}/{issue}-{slug}"); got != "fix/42-login-fails-on-safari" {
+		t.Errorf("templated branch = %q", got)
+	}
+	if IssueFromBranch(issue.BranchNameFromTemplate("{type}/{issue}-{slug}"), "{type}/{issue}-{slug}") != 42 {
+		t.Error("templated branch should still resolve to its issue")
+	}
+}
+
+func TestFormatCommitMessage(t *testing.T) {
+	tmpl := "{type}({scope}): {summary}"
+	tests := []struct {
+		name, message, typeName, scope, want string
+	}{
+		{"inferred type", "Fix nil pointer in parser", "", "parser", "fix(parser): fix nil pointer in parser"},
+		{"empty scope", "Add retry support", "", "", "feat: add retry support"},
+		{"explicit type", "Update README", "docs", "", "docs: update README"},
+		{"already conventional", "feat(api): add endpoint", "", "api", "feat(api): add endpoint"},
+		{"parentheses in summary", "Call init() before serving", "fix", "", "fix: call init() before serving"},
+		{"keeps body", "Add cache\n\nDetails here", "", "graph", "feat(graph): add cache\n\nDetails here"},
+	}
+	for _, tt := range tests {
+		if got := FormatCommitMessage(tmpl, tt.message, tt.typeName, tt.scope, 0); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := FormatCommitMessage("", "Add cache", "", "", 0); got != "Add cache" {
+		t.Errorf("empty template should not change message, got %q", got)
+	}
+}
+
+func TestNormalizeCommitMessage(t *testing.T) {
+	tests := []struct{ message, scope, want string }{
+		{"feat(api)!: drop v1 routes\n\nBREAKING CHANGE: v1 is gone", "api", "feat(api)!: drop v1 routes\n\nBREAKING CHANGE: v1 is gone"},
+		{"```text\nfix(auth): refresh expired tokens\n```", "", "fix(auth): refresh expired tokens"},
+		{`"docs: explain setup."`, "", "docs: explain setup"},
+		{"Add retry support\n\n\nRetries twice.\n", "llm", "feat(llm): add retry support\n\nRetries twice."},
+	}
+	for _, tt := range tests {
+		if got := NormalizeCommitMessage(tt.message, tt.scope); got != tt.want {
+			t.Errorf("NormalizeCommitMessage(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestInferScope(t *testing.T) {
+	tests := []struct {
+		paths []string
+		want  string
+	}{
+		{[]string{"internal/auth/a.go", "internal/auth/b.go"}, "auth"},
+		{[]string{"internal/auth/a.go", "internal/llm/b.go"}, "internal"},
+		{[]string{"README.md", "internal/llm/b.go"}, ""},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := InferScope(tt.paths); got != tt.want {
+			t.Errorf("InferScope(%v) = %q, want %q", tt.paths, got, tt.want)
+		}
+	}
+}
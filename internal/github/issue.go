@@ -6,6 +6,8 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+
+	"gptcode/internal/httpclient"
 )
 
 // Issue represents a GitHub issue
@@ -41,11 +43,24 @@ func (c *Client) SetWorkDir(dir string) {
 	c.workDir = dir
 }
 
+// Repo is the owner/repo the client works on
+func (c *Client) Repo() string {
+	return c.repo
+}
+
+// ghCommand builds a gh CLI command that honors the configured proxy and CA
+// bundle.
+func ghCommand(args ...string) *exec.Cmd {
+	cmd := exec.Command("gh", args...)
+	cmd.Env = httpclient.CommandEnv()
+	return cmd
+}
+
 // FetchIssue fetches a GitHub issue by number
 func (c *Client) FetchIssue(issueNumber int) (*Issue, error) {
 	// Use gh CLI to fetch issue details in JSON format
-	cmd := exec.Command("gh", "issue", "view", strconv.Itoa(issueNumber),
-		"--json", "number,title,body,state,labels,author,url,assignees,milestone,createdAt,updatedAt",
+	cmd := ghCommand("issue", "view", strconv.Itoa(issueNumber),
+		"--json", issueJSONFields,
 		"--repo", c.repo)
 
 	output, err := cmd.CombinedOutput()
@@ -53,60 +68,108 @@ func (c *Client) FetchIssue(issueNumber int) (*Issue, error) {
 		return nil, fmt.Errorf("failed to fetch issue #%d: %w\nOutput: %s", issueNumber, err, string(output))
 	}
 
-	var rawIssue struct {
-		Number int    `json:"number"`
-		Title  string `json:"title"`
-		Body   string `json:"body"`
-		State  string `json:"state"`
-		Labels []struct {
-			Name string `json:"name"`
-		} `json:"labels"`
-		Author struct {
-			Login string `json:"login"`
-		} `json:"author"`
-		URL       string `json:"url"`
-		Assignees []struct {
-			Login string `json:"login"`
-		} `json:"assignees"`
-		Milestone struct {
-			Title string `json:"title"`
-		} `json:"milestone"`
-		CreatedAt string `json:"createdAt"`
-		UpdatedAt string `json:"updatedAt"`
-	}
-
-	if err := json.Unmarshal(output, &rawIssue); err != nil {
+	var raw rawIssue
+	if err := json.Unmarshal(output, &raw); err != nil {
 		return nil, fmt.Errorf("failed to parse issue JSON: %w", err)
 	}
 
-	// Convert to our Issue struct
+	return raw.toIssue(c.repo), nil
+}
+
+// issueJSONFields are the gh --json fields decoded into rawIssue
+const issueJSONFields = "number,title,body,state,labels,author,url,assignees,milestone,createdAt,updatedAt"
+
+// rawIssue is the gh CLI JSON shape of an issue
+type rawIssue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	State  string `json:"state"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	URL       string `json:"url"`
+	Assignees []struct {
+		Login string `json:"login"`
+	} `json:"assignees"`
+	Milestone struct {
+		Title string `json:"title"`
+	} `json:"milestone"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func (r rawIssue) toIssue(repo string) *Issue {
 	issue := &Issue{
-		Number:     rawIssue.Number,
-		Title:      rawIssue.Title,
-		Body:       rawIssue.Body,
-		State:      rawIssue.State,
-		Author:     rawIssue.Author.Login,
-		URL:        rawIssue.URL,
-		CreatedAt:  rawIssue.CreatedAt,
-		UpdatedAt:  rawIssue.UpdatedAt,
+		Number:     r.Number,
+		Title:      r.Title,
+		Body:       r.Body,
+		State:      r.State,
+		Author:     r.Author.Login,
+		URL:        r.URL,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
 		Comments:   0,
-		Repository: c.repo,
+		Repository: repo,
 	}
 
 	// Extract label names
-	for _, label := range rawIssue.Labels {
+	for _, label := range r.Labels {
 		issue.Labels = append(issue.Labels, label.Name)
 	}
 
 	// Extract assignee logins
-	for _, assignee := range rawIssue.Assignees {
+	for _, assignee := range r.Assignees {
 		issue.Assignees = append(issue.Assignees, assignee.Login)
 	}
 
 	// Set milestone
-	issue.Milestone = rawIssue.Milestone.Title
+	issue.Milestone = r.Milestone.Title
+
+	return issue
+}
 
-	return issue, nil
+// IssueFilter selects issues for ListIssues
+type IssueFilter struct {
+	Labels    []string
+	Milestone string
+	State     string // open (default), closed or all
+	Limit     int
+}
+
+// ListIssues returns the issues matching filter
+func (c *Client) ListIssues(filter IssueFilter) ([]*Issue, error) {
+	args := []string{"issue", "list", "--json", issueJSONFields, "--repo", c.repo}
+	for _, label := range filter.Labels {
+		args = append(args, "--label", label)
+	}
+	if filter.Milestone != "" {
+		args = append(args, "--milestone", filter.Milestone)
+	}
+	if filter.State != "" {
+		args = append(args, "--state", filter.State)
+	}
+	if filter.Limit > 0 {
+		args = append(args, "--limit", strconv.Itoa(filter.Limit))
+	}
+
+	output, err := ghCommand(args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list issues: %w\nOutput: %s", err, string(output))
+	}
+
+	var raws []rawIssue
+	if err := json.Unmarshal(output, &raws); err != nil {
+		return nil, fmt.Errorf("failed to parse issue list JSON: %w", err)
+	}
+	issues := make([]*Issue, 0, len(raws))
+	for _, r := range raws {
+		issues = append(issues, r.toIssue(c.repo))
+	}
+	return issues, nil
 }
 
 // ExtractRequirements extracts actionable requirements from issue
@@ -180,32 +243,17 @@ func ParseReferences(text string) []string {
 	return references
 }
 
+// CommentOnIssue posts a comment on an issue
+func (c *Client) CommentOnIssue(issueNumber int, body string) error {
+	cmd := ghCommand("issue", "comment", strconv.Itoa(issueNumber), "--body", body, "--repo", c.repo)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to comment on issue #%d: %w\nOutput: %s", issueNumber, err, string(output))
+	}
+	return nil
+}
+
 // CreateBranchName generates a branch name from issue
 func (i *Issue) CreateBranchName() string {
 	// Format: issue-123-short-description
-	title := strings.ToLower(i.Title)
-
-	// Replace spaces and special chars with hyphens
-	title = strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
-			return r
-		}
-		return '-'
-	}, title)
-
-	// Remove consecutive hyphens
-	for strings.Contains(title, "--") {
-		title = strings.ReplaceAll(title, "--", "-")
-	}
-
-	// Trim hyphens from ends
-	title = strings.Trim(title, "-")
-
-	// Limit length
-	if len(title) > 50 {
-		title = title[:50]
-		title = strings.Trim(title, "-")
-	}
-
-	return fmt.Sprintf("issue-%d-%s", i.Number, title)
+	return fmt.Sprintf("issue-%d-%s", i.Number, Slug(i.Title))
 }
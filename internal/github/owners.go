@@ -0,0 +1,212 @@
+package github
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OwnerRule is one CODEOWNERS line: a path pattern and its owners
+type OwnerRule struct {
+	Pattern string
+	Owners  []string
+}
+
+// ReviewerSuggestion is a proposed PR reviewer and why
+type ReviewerSuggestion struct {
+	Login  string  // GitHub login or org/team
+	Score  float64 // higher is more relevant
+	Reason string
+}
+
+var codeOwnersLocations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// LoadCodeOwners reads the repository's CODEOWNERS file, if any.
+func LoadCodeOwners(root string) []OwnerRule {
+	for _, loc := range codeOwnersLocations {
+		data, err := os.ReadFile(filepath.Join(root, loc))
+		if err == nil {
+			return ParseCodeOwners(string(data))
+		}
+	}
+	return nil
+}
+
+// ParseCodeOwners parses CODEOWNERS content, skipping comments and lines
+// without owners.
+func ParseCodeOwners(content string) []OwnerRule {
+	var rules []OwnerRule
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		var owners []string
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "@") {
+				owners = append(owners, strings.TrimPrefix(owner, "@"))
+			}
+		}
+		if len(owners) > 0 {
+			rules = append(rules, OwnerRule{Pattern: fields[0], Owners: owners})
+		}
+	}
+	return rules
+}
+
+// OwnersFor returns the owners of file. As in GitHub, the last matching rule
+// wins.
+func OwnersFor(rules []OwnerRule, file string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if matchOwnerPattern(rules[i].Pattern, file) {
+			return rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// matchOwnerPattern implements the gitignore-style subset used by CODEOWNERS:
+// anchored paths, directory prefixes, and globs on the base name.
+func matchOwnerPattern(pattern, file string) bool {
+	if pattern == "*" {
+		return true
+	}
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	if strings.HasSuffix(pattern, "/") {
+		dir := strings.TrimSuffix(pattern, "/")
+		if anchored || strings.Contains(dir, "/") {
+			return strings.HasPrefix(file, dir+"/")
+		}
+		return file == dir || strings.HasPrefix(file, dir+"/") || strings.Contains(file, "/"+dir+"/")
+	}
+	if strings.HasSuffix(pattern, "/**") {
+		return strings.HasPrefix(file, strings.TrimSuffix(pattern, "**"))
+	}
+
+	if !anchored && !strings.Contains(pattern, "/") {
+		if ok, _ := path.Match(pattern, path.Base(file)); ok {
+			return true
+		}
+		// A bare name also matches a directory anywhere in the tree
+		for _, part := range strings.Split(path.Dir(file), "/") {
+			if part == pattern {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ok, _ := path.Match(pattern, file); ok {
+		return true
+	}
+	// "docs/*" style patterns and directories without a trailing slash
+	return strings.HasPrefix(file, pattern+"/")
+}
+
+var noreplyPattern = regexp.MustCompile(`^(?:\d+\+)?([A-Za-z0-9-]+)@users\.noreply\.github\.com$`)
+
+// loginFromEmail maps a GitHub noreply address to its login.
+func loginFromEmail(email string) string {
+	if m := noreplyPattern.FindStringSubmatch(strings.ToLower(email)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// SuggestReviewers ranks reviewers for the changed files: CODEOWNERS owners
+// first, then recent authors of those files whose GitHub login is known.
+// Logins in exclude (such as the PR author) are skipped.
+func (c *Client) SuggestReviewers(files []string, exclude []string, limit int) []ReviewerSuggestion {
+	root := c.workDir
+	if root == "" {
+		root = "."
+	}
+
+	skip := make(map[string]bool)
+	for _, login := range exclude {
+		skip[strings.ToLower(login)] = true
+	}
+
+	scores := make(map[string]*ReviewerSuggestion)
+	add := func(login string, score float64, reason string) {
+		if login == "" || skip[strings.ToLower(login)] {
+			return
+		}
+		s, ok := scores[login]
+		if !ok {
+			s = &ReviewerSuggestion{Login: login, Reason: reason}
+			scores[login] = s
+		}
+		s.Score += score
+	}
+
+	rules := LoadCodeOwners(root)
+	for _, file := range files {
+		for _, owner := range OwnersFor(rules, file) {
+			add(owner, 2, "CODEOWNERS")
+		}
+	}
+
+	for _, file := range files {
+		cmd := exec.Command("git", "log", "-n", "20", "--format=%aE", "--", file)
+		cmd.Dir = root
+		output, err := cmd.Output()
+		if err != nil {
+			continue
+		}
+		for _, email := range strings.Fields(string(output)) {
+			add(loginFromEmail(email), 0.25, "recent commits")
+		}
+	}
+
+	suggestions := make([]ReviewerSuggestion, 0, len(scores))
+	for _, s := range scores {
+		suggestions = append(suggestions, *s)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Login < suggestions[j].Login
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// ChangedFiles lists files changed on the current branch relative to base,
+// falling back to origin/<base> when there is no local base branch.
+func (c *Client) ChangedFiles(base string) []string {
+	for _, ref := range []string{base, "origin/" + base} {
+		cmd := exec.Command("git", "diff", "--name-only", ref+"...HEAD")
+		if c.workDir != "" {
+			cmd.Dir = c.workDir
+		}
+		if output, err := cmd.Output(); err == nil {
+			return strings.Fields(string(output))
+		}
+	}
+	return nil
+}
+
+// CurrentUser returns the login gh is authenticated as, or "".
+func CurrentUser() string {
+	output, err := ghCommand("api", "user", "--jq", ".login").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
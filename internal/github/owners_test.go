@@ -0,0 +1,43 @@
+package github
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnersFor(t *testing.T) {
+	rules := ParseCodeOwners(`
+# Default owners
+*                 @alice
+*.md              @docs-team   # docs
+/internal/llm/    @bob @org/llm
+cmd/              @carol
+no-owner-line
+`)
+	if len(rules) != 4 {
+		t.Fatalf("parsed %d rules, want 4", len(rules))
+	}
+
+	tests := map[string][]string{
+		"main.go":                     {"alice"},
+		"docs/guide.md":               {"docs-team"},
+		"internal/llm/retry.go":       {"bob", "org/llm"},
+		"cmd/gptcode/main.go":         {"carol"},
+		"internal/llm/README.md":      {"bob", "org/llm"}, // later rule wins
+		"tools/internal/llm/other.go": {"alice"},
+	}
+	for file, want := range tests {
+		if got := OwnersFor(rules, file); !reflect.DeepEqual(got, want) {
+			t.Errorf("OwnersFor(%q) = %v, want %v", file, got, want)
+		}
+	}
+}
+
+func TestLoginFromEmail(t *testing.T) {
+	if got := loginFromEmail("12345+octocat@users.noreply.github.com"); got != "octocat" {
+		t.Errorf("got %q, want octocat", got)
+	}
+	if got := loginFromEmail("dev@example.com"); got != "" {
+		t.Errorf("got %q, want empty", got)
+	}
+}
@@ -3,17 +3,23 @@ package github
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+
+	"gptcode/internal/config"
+	"gptcode/internal/httpclient"
 )
 
 type PullRequest struct {
-	Number     int      `json:"number"`
-	Title      string   `json:"title"`
-	Body       string   `json:"body"`
-	State      string   `json:"state"`
-	HeadBranch string   `json:"headRefName"`
+	Number     int    `json:"number"`
+	Title      string `json:"title"`
+	Body       string `json:"body"`
+	State      string `json:"state"`
+	HeadBranch string `json:"headRefName"`
+	// HeadSHA is the head branch's last commit, when the forge reports it
+	HeadSHA    string   `json:"headRefOid"`
 	BaseBranch string   `json:"baseRefName"`
 	URL        string   `json:"url"`
 	Author     string   `json:"author"`
@@ -48,6 +54,11 @@ type CommitOptions struct {
 	IssueNumber int
 	FilePaths   []string
 	AllFiles    bool
+
+	// Type and Scope fill the project's commit template; they are inferred
+	// from the message and staged files when empty.
+	Type  string
+	Scope string
 }
 
 func (c *Client) CreateBranch(branchName string, fromBranch string) error {
@@ -99,8 +110,18 @@ func (c *Client) CommitChanges(opts CommitOptions) error {
 	}
 
 	commitMsg := opts.Message
+	gitCfg := c.projectConfig().Git
+	if gitCfg.CommitTemplate != "" {
+		scope := opts.Scope
+		if scope == "" {
+			scope = InferScope(c.stagedFiles())
+		}
+		commitMsg = FormatCommitMessage(gitCfg.CommitTemplate, commitMsg, opts.Type, scope, opts.IssueNumber)
+	}
 	if opts.IssueNumber > 0 {
 		commitMsg = fmt.Sprintf("%s\n\nCloses #%d", commitMsg, opts.IssueNumber)
+	} else if issue := c.activeIssue(); issue > 0 {
+		commitMsg = addIssueTrailer(commitMsg, issue, gitCfg.IssueTrailerKeyword())
 	}
 
 	commitCmd := exec.Command("git", "commit", "-m", commitMsg)
@@ -118,14 +139,80 @@ func (c *Client) CommitChanges(opts CommitOptions) error {
 	return nil
 }
 
+// activeIssue returns the issue the working tree is on, from GPTCODE_ISSUE or
+// the current branch name, or 0 when there is none.
+func (c *Client) activeIssue() int {
+	if n, err := strconv.Atoi(os.Getenv("GPTCODE_ISSUE")); err == nil && n > 0 {
+		return n
+	}
+	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
+	if c.workDir != "" {
+		cmd.Dir = c.workDir
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	return IssueFromBranch(strings.TrimSpace(string(output)), c.projectConfig().Git.BranchTemplate)
+}
+
+// projectConfig loads .gptcode/config.yml for the client's working directory
+func (c *Client) projectConfig() *config.ProjectConfig {
+	dir := c.workDir
+	if dir == "" {
+		dir = "."
+	}
+	pc, _ := config.LoadProjectConfig(dir)
+	return pc
+}
+
+// IssueFromBranch extracts the issue number from a branch BranchNameFor
+// named: issue-123-slug, or after template (git.branch_template) when set.
+// Other branches, such as release-2024 or sprint-12-ui, return 0.
+func IssueFromBranch(branch, template string) int {
+	if branch == "" || branch == "HEAD" {
+		return 0
+	}
+	for _, re := range issueBranchPatterns(template) {
+		if m := re.FindStringSubmatch(branch); m != nil && len(m) > 1 {
+			n, _ := strconv.Atoi(m[1])
+			return n
+		}
+	}
+	return 0
+}
+
+// addIssueTrailer appends "<keyword> #N" unless the message already mentions
+// the issue or keyword is empty.
+func addIssueTrailer(message string, issue int, keyword string) string {
+	if keyword == "" {
+		return message
+	}
+	ref := fmt.Sprintf("#%d", issue)
+	for _, field := range strings.FieldsFunc(message, func(r rune) bool {
+		return r == ' ' || r == '\n' || r == '(' || r == ')' || r == ',' || r == '.'
+	}) {
+		if field == ref {
+			return message
+		}
+	}
+	return fmt.Sprintf("%s\n\n%s %s", strings.TrimRight(message, "\n"), keyword, ref)
+}
+
 func (c *Client) PushBranch(branchName string) error {
-	pushCmd := exec.Command("git", "push", "-u", "origin", branchName)
+	return c.PushBranchTo("origin", branchName)
+}
+
+// PushBranchTo pushes branchName to the given remote and sets it as upstream
+func (c *Client) PushBranchTo(remote, branchName string) error {
+	pushCmd := exec.Command("git", "push", "-u", remote, branchName)
+	pushCmd.Env = httpclient.CommandEnv()
 	if c.workDir != "" {
 		pushCmd.Dir = c.workDir
 	}
 	output, err := pushCmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("failed to push branch %s: %w\nOutput: %s", branchName, err, string(output))
+		return fmt.Errorf("failed to push branch %s to %s: %w\nOutput: %s", branchName, remote, err, string(output))
 	}
 
 	return nil
@@ -146,6 +233,14 @@ func (c *Client) CreatePR(opts PRCreateOptions) (*PullRequest, error) {
 		args = append(args, "--base", opts.BaseBranch)
 	}
 
+	if opts.HeadBranch != "" {
+		head := opts.HeadBranch
+		if opts.HeadOwner != "" {
+			head = opts.HeadOwner + ":" + head
+		}
+		args = append(args, "--head", head)
+	}
+
 	if opts.IsDraft {
 		args = append(args, "--draft")
 	}
@@ -164,7 +259,7 @@ func (c *Client) CreatePR(opts PRCreateOptions) (*PullRequest, error) {
 
 	args = append(args, "--repo", c.repo)
 
-	cmd := exec.Command("gh", args...)
+	cmd := ghCommand(args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create PR: %w\nOutput: %s", err, string(output))
@@ -201,6 +296,8 @@ type PRCreateOptions struct {
 	Title      string
 	Body       string
 	HeadBranch string
+	HeadOwner  string // fork owner when the branch lives in a fork
+	HeadRepo   string // fork repository, for forges that need its full path
 	BaseBranch string
 	IsDraft    bool
 	Labels     []string
@@ -208,9 +305,14 @@ type PRCreateOptions struct {
 	Reviewers  []string
 }
 
+// PRURL is the web address of pull request prNumber
+func (c *Client) PRURL(prNumber int) string {
+	return fmt.Sprintf("https://github.com/%s/pull/%d", c.repo, prNumber)
+}
+
 func (c *Client) AddLabelsToPR(prNumber int, labels []string) error {
 	for _, label := range labels {
-		cmd := exec.Command("gh", "pr", "edit", strconv.Itoa(prNumber), "--add-label", label, "--repo", c.repo)
+		cmd := ghCommand("pr", "edit", strconv.Itoa(prNumber), "--add-label", label, "--repo", c.repo)
 		if output, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("failed to add label %s to PR #%d: %w\nOutput: %s", label, prNumber, err, string(output))
 		}
@@ -220,7 +322,7 @@ func (c *Client) AddLabelsToPR(prNumber int, labels []string) error {
 
 func (c *Client) AddReviewersToPR(prNumber int, reviewers []string) error {
 	for _, reviewer := range reviewers {
-		cmd := exec.Command("gh", "pr", "edit", strconv.Itoa(prNumber), "--add-reviewer", reviewer, "--repo", c.repo)
+		cmd := ghCommand("pr", "edit", strconv.Itoa(prNumber), "--add-reviewer", reviewer, "--repo", c.repo)
 		if output, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("failed to add reviewer %s to PR #%d: %w\nOutput: %s", reviewer, prNumber, err, string(output))
 		}
@@ -229,7 +331,7 @@ func (c *Client) AddReviewersToPR(prNumber int, reviewers []string) error {
 }
 
 func (c *Client) FetchPRReviews(prNumber int) ([]Review, error) {
-	cmd := exec.Command("gh", "pr", "view", strconv.Itoa(prNumber),
+	cmd := ghCommand("pr", "view", strconv.Itoa(prNumber),
 		"--json", "reviews",
 		"--repo", c.repo)
 
@@ -269,7 +371,7 @@ func (c *Client) FetchPRReviews(prNumber int) ([]Review, error) {
 }
 
 func (c *Client) FetchPRComments(prNumber int) ([]ReviewComment, error) {
-	cmd := exec.Command("gh", "api",
+	cmd := ghCommand("api",
 		fmt.Sprintf("/repos/%s/pulls/%d/comments", c.repo, prNumber))
 
 	output, err := cmd.CombinedOutput()
@@ -325,6 +427,72 @@ func (c *Client) GetUnresolvedComments(prNumber int) ([]ReviewComment, error) {
 	return unresolved, nil
 }
 
+// CommitSubjects lists the subjects of the commits on the current branch
+// since base, oldest first, falling back to origin/<base>.
+func (c *Client) CommitSubjects(base string) []string {
+	for _, ref := range []string{base, "origin/" + base} {
+		cmd := exec.Command("git", "log", "--reverse", "--format=%s", ref+"..HEAD")
+		if c.workDir != "" {
+			cmd.Dir = c.workDir
+		}
+		if output, err := cmd.Output(); err == nil {
+			var subjects []string
+			for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+				if line != "" {
+					subjects = append(subjects, line)
+				}
+			}
+			return subjects
+		}
+	}
+	return nil
+}
+
+// BranchDiff is the diff of the current branch since it left base, cut
+// to maxLen bytes, falling back to origin/<base>.
+func (c *Client) BranchDiff(base string, maxLen int) string {
+	for _, ref := range []string{base, "origin/" + base} {
+		cmd := exec.Command("git", "diff", ref+"...HEAD")
+		if c.workDir != "" {
+			cmd.Dir = c.workDir
+		}
+		if output, err := cmd.Output(); err == nil {
+			if len(output) > maxLen {
+				return string(output[:maxLen]) + "\n... (truncated)"
+			}
+			return string(output)
+		}
+	}
+	return ""
+}
+
+// FetchPR returns a pull request with its body
+func (c *Client) FetchPR(prNumber int) (*PullRequest, error) {
+	cmd := ghCommand("pr", "view", strconv.Itoa(prNumber),
+		"--json", "number,title,body,state,headRefName,baseRefName,url,isDraft",
+		"--repo", c.repo)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PR #%d: %w", prNumber, err)
+	}
+	var pr PullRequest
+	if err := json.Unmarshal(output, &pr); err != nil {
+		return nil, fmt.Errorf("failed to parse PR #%d: %w", prNumber, err)
+	}
+	pr.Repository = c.repo
+	return &pr, nil
+}
+
+// UpdatePRBody replaces a pull request's description
+func (c *Client) UpdatePRBody(prNumber int, body string) error {
+	cmd := ghCommand("pr", "edit", strconv.Itoa(prNumber), "--body-file", "-", "--repo", c.repo)
+	cmd.Stdin = strings.NewReader(body)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to update PR #%d: %w\nOutput: %s", prNumber, err, string(output))
+	}
+	return nil
+}
+
 func GeneratePRBody(issue *Issue, changes []string) string {
 	body := fmt.Sprintf("Closes #%d\n\n", issue.Number)
 	body += "## Changes\n\n"
@@ -0,0 +1,81 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIssueFromBranch(t *testing.T) {
+	tests := []struct {
+		branch, template string
+		want             int
+	}{
+		{"issue-123-fix-login", "", 123},
+		{"issue-5-login-part-2", "", 5},
+		{"main", "", 0},
+		{"HEAD", "", 0},
+		{"release-2024", "", 0},
+		{"sprint-12-ui", "", 0},
+		{"feat/42-add-cache", "", 0},
+		{"feat/42-add-cache", "{type}/{issue}-{slug}", 42},
+		{"issue-7-login", "{type}/{issue}-{slug}", 7},
+		{"feat/v2-api", "{type}/{issue}-{slug}", 0},
+		{"hotfix/2024-01-02", "{type}/{issue}-{slug}", 0},
+		{"user/7-wip", "{type}/{issue}-{slug}", 0},
+		{"gh-7", "gh-{issue}", 7},
+		{"release-2024", "release-{slug}", 0},
+	}
+	for _, tt := range tests {
+		if got := IssueFromBranch(tt.branch, tt.template); got != tt.want {
+			t.Errorf("IssueFromBranch(%q, %q) = %d, want %d", tt.branch, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestAddIssueTrailer(t *testing.T) {
+	if got := addIssueTrailer("Fix login\n", 12, "Refs"); got != "Fix login\n\nRefs #12" {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := addIssueTrailer("Fix login (#12)", 12, "Refs"); got != "Fix login (#12)" {
+		t.Errorf("existing reference should be kept as-is, got %q", got)
+	}
+	if got := addIssueTrailer("Fix login", 12, ""); got != "Fix login" {
+		t.Errorf("disabled trailer should not change message, got %q", got)
+	}
+}
+
+func TestPushTarget(t *testing.T) {
+	if got := ParseRepoURL("git@github.com:alice/chuchu.git"); got != "alice/chuchu" {
+		t.Errorf("ParseRepoURL(ssh) = %q", got)
+	}
+	if got := ParseRepoURL("https://github.com/jadercorrea/chuchu"); got != "jadercorrea/chuchu" {
+		t.Errorf("ParseRepoURL(https) = %q", got)
+	}
+	if got := ParseRepoURL("https://gitlab.com/a/b.git"); got != "" {
+		t.Errorf("ParseRepoURL(gitlab) = %q", got)
+	}
+
+	fork := PushTarget{Remote: "origin", Repo: "alice/chuchu", UpstreamRepo: "jadercorrea/chuchu"}
+	if !fork.IsFork() || fork.HeadOwner() != "alice" {
+		t.Errorf("fork target: IsFork=%v HeadOwner=%q", fork.IsFork(), fork.HeadOwner())
+	}
+	same := PushTarget{Remote: "origin", Repo: "jadercorrea/chuchu", UpstreamRepo: "jadercorrea/chuchu"}
+	if same.IsFork() || same.HeadOwner() != "" {
+		t.Error("same-repo target should not be a fork")
+	}
+}
+
+func TestDiffSummaryMarkdown(t *testing.T) {
+	summary := DiffSummary{
+		Additions:    12,
+		Deletions:    3,
+		ChangedFiles: 1,
+		Files:        []FileChange{{Path: "internal/auth/login.go", Additions: 12, Deletions: 3}},
+	}
+	md := summary.Markdown()
+	for _, want := range []string{"1 file(s) changed, +12 / -3", "| `internal/auth/login.go` | 12 | 3 |"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
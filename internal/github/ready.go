@@ -0,0 +1,73 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FileChange is the per-file line count of a PR diff
+type FileChange struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+}
+
+// DiffSummary is the size of a PR diff
+type DiffSummary struct {
+	Additions    int          `json:"additions"`
+	Deletions    int          `json:"deletions"`
+	ChangedFiles int          `json:"changedFiles"`
+	Files        []FileChange `json:"files"`
+}
+
+// Markdown renders the summary as a PR comment
+func (d DiffSummary) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString("### Diff summary\n\n")
+	sb.WriteString(fmt.Sprintf("%d file(s) changed, +%d / -%d lines. CI is green.\n\n", d.ChangedFiles, d.Additions, d.Deletions))
+	if len(d.Files) > 0 {
+		sb.WriteString("| File | + | - |\n|---|---:|---:|\n")
+		for _, f := range d.Files {
+			sb.WriteString(fmt.Sprintf("| `%s` | %d | %d |\n", f.Path, f.Additions, f.Deletions))
+		}
+	}
+	return sb.String()
+}
+
+// FetchPRDiffSummary returns the files and line counts changed by a PR
+func (c *Client) FetchPRDiffSummary(prNumber int) (*DiffSummary, error) {
+	cmd := ghCommand("pr", "view", strconv.Itoa(prNumber),
+		"--json", "additions,deletions,changedFiles,files",
+		"--repo", c.repo)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PR diff: %w\nOutput: %s", err, string(output))
+	}
+
+	var summary DiffSummary
+	if err := json.Unmarshal(output, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse PR diff: %w", err)
+	}
+	return &summary, nil
+}
+
+// CommentOnPR posts a comment on a pull request
+func (c *Client) CommentOnPR(prNumber int, body string) error {
+	cmd := ghCommand("pr", "comment", strconv.Itoa(prNumber), "--body", body, "--repo", c.repo)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to comment on PR #%d: %w\nOutput: %s", prNumber, err, string(output))
+	}
+	return nil
+}
+
+// MarkPRReady converts a draft pull request into one ready for review
+func (c *Client) MarkPRReady(prNumber int) error {
+	cmd := ghCommand("pr", "ready", strconv.Itoa(prNumber), "--repo", c.repo)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to mark PR #%d ready: %w\nOutput: %s", prNumber, err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package github
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// PushTarget describes where a branch is pushed and where its PR is opened.
+// In a fork workflow the branch goes to the fork (origin) and the PR targets
+// the upstream repository.
+type PushTarget struct {
+	Remote       string // remote the branch is pushed to
+	Repo         string // owner/repo of Remote
+	UpstreamRepo string // owner/repo the PR is opened against
+}
+
+// IsFork reports whether the push repository differs from the PR repository.
+func (t PushTarget) IsFork() bool {
+	return t.Repo != "" && t.UpstreamRepo != "" && t.Repo != t.UpstreamRepo
+}
+
+// HeadOwner returns the fork owner to qualify the PR head with
+// (owner:branch), or "" when the branch lives in the upstream repository.
+func (t PushTarget) HeadOwner() string {
+	if !t.IsFork() {
+		return ""
+	}
+	owner, _, _ := strings.Cut(t.Repo, "/")
+	return owner
+}
+
+// HeadRepo returns the fork repository the PR head lives in, or "" when
+// the branch lives in the upstream repository.
+func (t PushTarget) HeadRepo() string {
+	if !t.IsFork() {
+		return ""
+	}
+	return t.Repo
+}
+
+// ParseRepoURL extracts owner/repo from a GitHub remote URL (HTTPS or SSH),
+// or returns "" for non-GitHub URLs.
+func ParseRepoURL(url string) string {
+	url = strings.TrimSpace(url)
+	if !strings.Contains(url, "github.com") {
+		return ""
+	}
+	parts := strings.Split(url, "github.com")
+	if len(parts) < 2 {
+		return ""
+	}
+	repo := strings.Trim(parts[1], ":/")
+	return strings.TrimSuffix(repo, ".git")
+}
+
+// Remotes returns the GitHub remotes of the repository in dir, keyed by
+// remote name.
+func Remotes(dir string) map[string]string {
+	cmd := exec.Command("git", "remote", "-v")
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	remotes := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if repo := ParseRepoURL(fields[1]); repo != "" {
+			remotes[fields[0]] = repo
+		}
+	}
+	return remotes
+}
+
+// UpstreamRepo returns the repository issues and PRs belong to: the
+// "upstream" remote when present, otherwise "origin".
+func UpstreamRepo(dir string) string {
+	remotes := Remotes(dir)
+	if repo, ok := remotes["upstream"]; ok {
+		return repo
+	}
+	return remotes["origin"]
+}
+
+// ResolvePushTarget picks the remote to push to. An explicit remote wins;
+// otherwise origin is used, and the PR targets "upstream" when that remote
+// exists and points elsewhere.
+func ResolvePushTarget(dir, remote string) (PushTarget, error) {
+	remotes := Remotes(dir)
+	if remote == "" {
+		remote = "origin"
+	}
+	repo, ok := remotes[remote]
+	if !ok {
+		return PushTarget{}, fmt.Errorf("remote %q not found or not a GitHub remote", remote)
+	}
+
+	upstream := repo
+	if u, ok := remotes["upstream"]; ok {
+		upstream = u
+	}
+	return PushTarget{Remote: remote, Repo: repo, UpstreamRepo: upstream}, nil
+}
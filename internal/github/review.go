@@ -0,0 +1,72 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Review events: the verdict a submitted review carries
+const (
+	ReviewApprove        = "APPROVE"
+	ReviewRequestChanges = "REQUEST_CHANGES"
+	ReviewCommentOnly    = "COMMENT"
+)
+
+// InlineComment is a review comment on a line of the PR's new version
+type InlineComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+	Side string `json:"side,omitempty"`
+	Body string `json:"body"`
+}
+
+// ReviewSubmission is a review posted in one go: a summary, a verdict and
+// the inline comments
+type ReviewSubmission struct {
+	Body     string          `json:"body"`
+	Event    string          `json:"event"`
+	Comments []InlineComment `json:"comments,omitempty"`
+}
+
+// FetchPRDiff returns the unified diff of a pull request
+func (c *Client) FetchPRDiff(prNumber int) (string, error) {
+	cmd := ghCommand("pr", "diff", strconv.Itoa(prNumber), "--repo", c.repo)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch the diff of PR #%d: %w", prNumber, err)
+	}
+	return string(output), nil
+}
+
+// PostReview submits review on a pull request. GitHub refuses approving or
+// requesting changes on one's own PR; the review is then posted as a
+// comment.
+func (c *Client) PostReview(prNumber int, review ReviewSubmission) error {
+	review.Comments = append([]InlineComment(nil), review.Comments...)
+	for i := range review.Comments {
+		if review.Comments[i].Side == "" {
+			review.Comments[i].Side = "RIGHT"
+		}
+	}
+	output, err := c.postReview(prNumber, review)
+	if err != nil && review.Event != ReviewCommentOnly && strings.Contains(string(output), "your own pull request") {
+		review.Event = ReviewCommentOnly
+		output, err = c.postReview(prNumber, review)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to post the review on PR #%d: %w\nOutput: %s", prNumber, err, string(output))
+	}
+	return nil
+}
+
+func (c *Client) postReview(prNumber int, review ReviewSubmission) ([]byte, error) {
+	payload, err := json.Marshal(review)
+	if err != nil {
+		return nil, err
+	}
+	cmd := ghCommand("api", "--method", "POST", fmt.Sprintf("repos/%s/pulls/%d/reviews", c.repo, prNumber), "--input", "-")
+	cmd.Stdin = strings.NewReader(string(payload))
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,193 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gptcode/internal/llm"
+)
+
+// prTemplatePaths are searched in order for the PR body template. The
+// gptcode-specific template wins over the ones GitHub and GitLab show to
+// human contributors.
+var prTemplatePaths = []string{
+	".gptcode/pr_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	".github/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	".gitlab/merge_request_templates/Default.md",
+}
+
+// LoadPRTemplate returns the repository's PR template and its path
+// relative to root, or "" when it has none.
+func LoadPRTemplate(root string) (content, path string) {
+	for _, p := range prTemplatePaths {
+		data, err := os.ReadFile(filepath.Join(root, p))
+		if err == nil && strings.TrimSpace(string(data)) != "" {
+			return string(data), p
+		}
+	}
+	return "", ""
+}
+
+// DefaultPRTemplate is the body layout for repositories without a PR
+// template of their own
+const DefaultPRTemplate = `## Summary
+
+{summary}
+
+## Changes
+
+{changes}
+
+## Testing
+
+{test_plan}
+
+## Screenshots
+
+<!-- Screenshots or recordings of UI changes; remove this section if there are none. -->
+`
+
+// PRContext is what a PR body is written from
+type PRContext struct {
+	Issue    *Issue
+	Changes  []string // one line per change
+	Files    []string // files changed on the branch
+	TestPlan string   // how the change was verified, if known
+	Diff     string   // the branch diff, possibly truncated
+}
+
+// issueLink is the line that closes the issue when the PR merges
+func (pc PRContext) issueLink() string {
+	if pc.Issue == nil {
+		return ""
+	}
+	return fmt.Sprintf("Closes #%d", pc.Issue.Number)
+}
+
+// FillPRTemplate replaces the placeholders gptcode knows in tmpl: {title},
+// {issue} (the issue number), {issue_link} ("Closes #N"), {changes} (a
+// bullet list), {summary} and {test_plan}. {summary} falls back to the
+// issue title and {test_plan} to PRContext.TestPlan.
+func FillPRTemplate(tmpl string, pc PRContext) string {
+	var changes strings.Builder
+	for _, c := range pc.Changes {
+		changes.WriteString("- " + c + "\n")
+	}
+	title, number := "", ""
+	if pc.Issue != nil {
+		title, number = pc.Issue.Title, fmt.Sprint(pc.Issue.Number)
+	}
+	return strings.NewReplacer(
+		"{title}", title,
+		"{issue}", number,
+		"{issue_link}", pc.issueLink(),
+		"{changes}", strings.TrimRight(changes.String(), "\n"),
+		"{summary}", title,
+		"{test_plan}", pc.TestPlan,
+	).Replace(tmpl)
+}
+
+var headingPattern = regexp.MustCompile(`(?m)^#{1,6}\s+(.+?)\s*#*\s*$`)
+
+// Sections returns the headings of a markdown template, in order.
+func Sections(tmpl string) []string {
+	var sections []string
+	for _, m := range headingPattern.FindAllStringSubmatch(tmpl, -1) {
+		sections = append(sections, m[1])
+	}
+	return sections
+}
+
+// MissingSections returns the template's headings that body lacks.
+func MissingSections(tmpl, body string) []string {
+	present := map[string]bool{}
+	for _, s := range Sections(body) {
+		present[strings.ToLower(s)] = true
+	}
+	var missing []string
+	for _, s := range Sections(tmpl) {
+		if !present[strings.ToLower(s)] {
+			missing = append(missing, s)
+		}
+	}
+	return missing
+}
+
+const prTemplatePrompt = `You write pull request descriptions by filling in the repository's template.
+
+Rules:
+- Keep every heading of the template, unchanged and in the same order. Reviewers rely on them.
+- Replace placeholder text, HTML comments and empty sections with content about this change.
+- Keep checklists; tick ([x]) only the items the information below shows to be true.
+- Write the summary and test plan from the issue, the changes, the files and the diff. Do not claim tests were run unless a test plan is given; say instead how a reviewer can verify the change.
+- Reply with the filled template only, as markdown, without code fences.`
+
+// WritePRBody fills tmpl for a PR. Known placeholders are replaced first;
+// the model then writes the summary, test plan and any other sections. If
+// the model fails or drops a section of the template, the template with
+// only its placeholders filled is returned, with the error. The body always
+// links the issue.
+func WritePRBody(ctx context.Context, provider llm.Provider, model, tmpl string, pc PRContext) (string, error) {
+	filled := FillPRTemplate(tmpl, pc)
+	body, err := completePRBody(ctx, provider, model, filled, pc)
+	if err == nil {
+		if missing := MissingSections(tmpl, body); len(missing) > 0 {
+			err = fmt.Errorf("the generated body dropped sections %s", strings.Join(missing, ", "))
+		}
+	}
+	if err != nil {
+		body = filled
+	}
+	if pc.Issue != nil && !strings.Contains(body, fmt.Sprintf("#%d", pc.Issue.Number)) {
+		body = strings.TrimRight(body, "\n") + "\n\n" + pc.issueLink() + "\n"
+	}
+	return body, err
+}
+
+func completePRBody(ctx context.Context, provider llm.Provider, model, filled string, pc PRContext) (string, error) {
+	if provider == nil {
+		return "", fmt.Errorf("no model configured")
+	}
+	var sb strings.Builder
+	if pc.Issue != nil {
+		fmt.Fprintf(&sb, "Issue #%d: %s\n\n%s\n\n", pc.Issue.Number, pc.Issue.Title, pc.Issue.Body)
+	}
+	if len(pc.Changes) > 0 {
+		sb.WriteString("Changes:\n- " + strings.Join(pc.Changes, "\n- ") + "\n\n")
+	}
+	if len(pc.Files) > 0 {
+		sb.WriteString("Files changed:\n" + strings.Join(pc.Files, "\n") + "\n\n")
+	}
+	if pc.TestPlan != "" {
+		sb.WriteString("Test plan:\n" + pc.TestPlan + "\n\n")
+	}
+	if pc.Diff != "" {
+		sb.WriteString("Diff:\n" + pc.Diff + "\n\n")
+	}
+	sb.WriteString("Template:\n" + filled)
+
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: prTemplatePrompt,
+		UserPrompt:   sb.String(),
+		Model:        model,
+	})
+	if err != nil {
+		return "", err
+	}
+	body := strings.TrimSpace(resp.Text)
+	body = strings.TrimPrefix(strings.TrimPrefix(body, "```markdown"), "```")
+	body = strings.TrimSpace(strings.TrimSuffix(body, "```"))
+	if body == "" {
+		return "", fmt.Errorf("empty response")
+	}
+	return body + "\n", nil
+}
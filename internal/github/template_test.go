@@ -0,0 +1,94 @@
+package github
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+type replyProvider struct {
+	reply  string
+	prompt string
+}
+
+func (p *replyProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.prompt = req.UserPrompt
+	return &llm.ChatResponse{Text: p.reply}, nil
+}
+
+func TestLoadPRTemplate(t *testing.T) {
+	root := t.TempDir()
+	if tmpl, _ := LoadPRTemplate(root); tmpl != "" {
+		t.Fatal("no template expected")
+	}
+	write := func(path, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".github/PULL_REQUEST_TEMPLATE.md", "## Description\n")
+	if _, path := LoadPRTemplate(root); path != ".github/PULL_REQUEST_TEMPLATE.md" {
+		t.Errorf("expected the GitHub template, got %q", path)
+	}
+	write(".gptcode/pr_template.md", "## Summary\n{summary}\n")
+	if _, path := LoadPRTemplate(root); path != ".gptcode/pr_template.md" {
+		t.Errorf("the gptcode template should win, got %q", path)
+	}
+}
+
+func TestWritePRBody(t *testing.T) {
+	tmpl := "## Summary\n{summary}\n\n## Changes\n{changes}\n\n## Test plan\n<!-- How was this tested? -->\n\n{issue_link}\n"
+	pc := PRContext{Issue: &Issue{Number: 42, Title: "Fix crash"}, Changes: []string{"Guard nil config"}}
+
+	filled := FillPRTemplate(tmpl, pc)
+	if !strings.Contains(filled, "- Guard nil config") || !strings.Contains(filled, "Closes #42") || strings.Contains(filled, "{") {
+		t.Errorf("placeholders not filled: %q", filled)
+	}
+
+	p := &replyProvider{reply: "```markdown\n## Summary\nStops the crash.\n\n## Changes\n- Guard nil config\n\n## Test plan\nRun gptcode with no config.\n\nCloses #42\n```"}
+	body, err := WritePRBody(context.Background(), p, "m", tmpl, pc)
+	if err != nil || !strings.HasPrefix(body, "## Summary\nStops the crash.") || strings.Contains(body, "```") {
+		t.Errorf("expected the model's body, got %q, %v", body, err)
+	}
+	if !strings.Contains(p.prompt, "Issue #42: Fix crash") || !strings.Contains(p.prompt, "- Guard nil config") {
+		t.Errorf("prompt lacks the issue and changes: %q", p.prompt)
+	}
+
+	// A body that drops a required section is not used
+	p.reply = "## Summary\nStops the crash.\n"
+	body, err = WritePRBody(context.Background(), p, "m", tmpl, pc)
+	if err == nil || body != filled {
+		t.Errorf("expected the filled template and an error, got %q, %v", body, err)
+	}
+	if missing := MissingSections(tmpl, p.reply); len(missing) != 2 || missing[0] != "Changes" {
+		t.Errorf("MissingSections = %v", missing)
+	}
+
+	// The issue is always linked
+	body, _ = WritePRBody(context.Background(), nil, "", "## Summary\n{summary}\n", pc)
+	if !strings.HasSuffix(body, "Closes #42\n") {
+		t.Errorf("issue link missing: %q", body)
+	}
+}
+
+func TestWritePRBodyDefaultTemplate(t *testing.T) {
+	pc := PRContext{Changes: []string{"Add retry"}, Diff: "+\treturn retry(fn)\n"}
+	p := &replyProvider{reply: "## Summary\nRetries flaky calls.\n\n## Changes\n- Add retry\n\n## Testing\nRun the client tests.\n\n## Screenshots\nNone.\n"}
+	body, err := WritePRBody(context.Background(), p, "m", DefaultPRTemplate, pc)
+	if err != nil || !strings.HasPrefix(body, "## Summary\nRetries flaky calls.") {
+		t.Errorf("expected the model's body, got %q, %v", body, err)
+	}
+	if !strings.Contains(p.prompt, "Diff:\n+\treturn retry(fn)") {
+		t.Errorf("prompt lacks the diff: %q", p.prompt)
+	}
+	if sections := Sections(DefaultPRTemplate); strings.Join(sections, ",") != "Summary,Changes,Testing,Screenshots" {
+		t.Errorf("default sections = %v", sections)
+	}
+}
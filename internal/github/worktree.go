@@ -0,0 +1,49 @@
+package github
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// AddWorktree checks out branch into dir as a separate git worktree, so
+// several issues can be worked on without touching the main checkout. A new
+// branch starts from base; an existing one is reused with its commits, and
+// only fast-forwarded to base when it has none of its own.
+func (c *Client) AddWorktree(dir, branch, base string) error {
+	if base == "" {
+		base = "main"
+	}
+	args := []string{"worktree", "add", "-b", branch, dir, base}
+	_, err := c.git("rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	exists := err == nil
+	if exists {
+		args = []string{"worktree", "add", dir, branch}
+	}
+	if _, err := c.git(args...); err != nil {
+		return fmt.Errorf("failed to create worktree %s: %w", dir, err)
+	}
+	if !exists {
+		return nil
+	}
+	if _, err := c.git("merge-base", "--is-ancestor", branch, base); err == nil {
+		cmd := exec.Command("git", "merge", "--ff-only", "--quiet", base)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to fast-forward %s to %s: %w\nOutput: %s", branch, base, err, string(output))
+		}
+	}
+	return nil
+}
+
+// RemoveWorktree deletes a worktree created with AddWorktree. The branch is
+// kept.
+func (c *Client) RemoveWorktree(dir string) error {
+	cmd := exec.Command("git", "worktree", "remove", "--force", dir)
+	if c.workDir != "" {
+		cmd.Dir = c.workDir
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to remove worktree %s: %w\nOutput: %s", dir, err, string(output))
+	}
+	return nil
+}
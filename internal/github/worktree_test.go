@@ -0,0 +1,68 @@
+package github
+
+import (
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddWorktree(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "init"},
+		{"branch", "issue-1-behind"},
+		{"checkout", "-q", "-b", "issue-2-work"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "work in progress"},
+		{"checkout", "-q", "main"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "--allow-empty", "-m", "later on main"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	c := NewClient("o/r")
+	c.SetWorkDir(dir)
+	rev := func(ref string) string {
+		t.Helper()
+		sha, err := c.git("rev-parse", ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sha
+	}
+	main := rev("main")
+	work := rev("issue-2-work")
+	trees := t.TempDir()
+
+	if err := c.AddWorktree(filepath.Join(trees, "new"), "issue-3-new", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if got := rev("issue-3-new"); got != main {
+		t.Errorf("a new branch should start at main, got %s", got)
+	}
+
+	if err := c.AddWorktree(filepath.Join(trees, "behind"), "issue-1-behind", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if got := rev("issue-1-behind"); got != main {
+		t.Errorf("a branch without commits of its own should be fast-forwarded to main, got %s", got)
+	}
+
+	if err := c.AddWorktree(filepath.Join(trees, "work"), "issue-2-work", "main"); err != nil {
+		t.Fatal(err)
+	}
+	if got := rev("issue-2-work"); got != work {
+		t.Errorf("an existing branch's commits must be kept, got %s want %s", got, work)
+	}
+
+	if err := c.RemoveWorktree(filepath.Join(trees, "work")); err != nil {
+		t.Fatal(err)
+	}
+	if got := rev("issue-2-work"); got != work {
+		t.Errorf("removing the worktree should keep the branch, got %s", got)
+	}
+}
@@ -2,40 +2,54 @@ package graph
 
 import (
 	"bufio"
-	"go/parser"
-	"go/token"
 	"os"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
 )
 
 // Builder handles graph construction
 type Builder struct {
-	graph      *Graph
 	root       string
-	cache      *Cache
 	moduleName string
+	stats      BuildStats
+}
+
+// BuildStats tells how much of the last build came from the cache
+type BuildStats struct {
+	Files  int // source files in the graph
+	Parsed int // files parsed because they were new or changed
+	Reused int // files whose imports came from the cache
 }
 
 // NewBuilder creates a new graph builder
 func NewBuilder(root string) *Builder {
-	return &Builder{
-		graph: NewGraph(),
-		root:  root,
-		cache: NewCache(),
-	}
+	return &Builder{root: root}
 }
 
-// Build scans the directory and builds the dependency graph
+// Build scans the directory and builds the dependency graph. Only files
+// changed since the last build are parsed again; the imports of the others
+// come from the cache in .gptcode/graph.cache.
 func (b *Builder) Build() (*Graph, error) {
+	return b.build(loadCache(b.root))
+}
+
+// Rebuild builds the graph parsing every file, replacing the cache.
+func (b *Builder) Rebuild() (*Graph, error) {
+	return b.build(newCache())
+}
+
+// Stats returns how the last build used the cache.
+func (b *Builder) Stats() BuildStats {
+	return b.stats
+}
+
+func (b *Builder) build(c *cache) (*Graph, error) {
 	// Try to parse go.mod to get module name
 	b.parseGoMod()
+	b.stats = BuildStats{}
 
-	if cached, err := b.cache.Get(b.root); err == nil {
-		return cached, nil
-	}
-
+	seen := map[string]bool{}
 	err := filepath.Walk(b.root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -47,264 +61,214 @@ func (b *Builder) Build() (*Graph, error) {
 			}
 			return nil
 		}
-
-		// Process files based on extension
-		ext := filepath.Ext(path)
-		switch ext {
-		case ".go":
-			b.processGoFile(path)
-		case ".py":
-			b.processPythonFile(path)
-		case ".js", ".ts", ".jsx", ".tsx":
-			b.processJSFile(path)
-		case ".rb":
-			b.processRubyFile(path)
-		case ".rs":
-			b.processRustFile(path)
+		if parserFor(path) == nil {
+			return nil
 		}
 
+		relPath, _ := filepath.Rel(b.root, path)
+		seen[relPath] = true
+		if c.refresh(relPath, path, info) {
+			b.stats.Parsed++
+		} else {
+			b.stats.Reused++
+		}
 		return nil
 	})
+	if err != nil {
+		return NewGraph(), err
+	}
 
-	if err == nil {
-		_ = b.cache.Set(b.root, b.graph)
+	for path := range c.Files {
+		if !seen[path] {
+			delete(c.Files, path)
+			c.dirty = true
+		}
+	}
+	if c.dirty {
+		_ = c.save(b.root)
 	}
 
-	return b.graph, err
+	b.stats.Files = len(seen)
+	return b.assemble(c), nil
 }
 
-func (b *Builder) processGoFile(path string) {
-	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
-	if err != nil {
-		return
+// assemble builds the graph from the files' imports, definitions and
+// calls, resolving them against the files that exist now. Each symbol a
+// file defines is a node linked to the file; a call to a symbol defined in
+// another file links the caller to the symbol and its file.
+func (b *Builder) assemble(c *cache) *Graph {
+	g := NewGraph()
+	paths := make([]string, 0, len(c.Files))
+	for path := range c.Files {
+		paths = append(paths, path)
 	}
-
-	relPath, _ := filepath.Rel(b.root, path)
-	b.graph.AddNode(relPath, "file")
-
-	for _, imp := range f.Imports {
-		// Clean import path (remove quotes)
-		impPath := strings.Trim(imp.Path.Value, "\"")
-
-		// Check if it's an internal import
-		isInternal := false
-		var targetDir string
-
-		if b.moduleName != "" && strings.HasPrefix(impPath, b.moduleName+"/") {
-			isInternal = true
-			targetDir = strings.TrimPrefix(impPath, b.moduleName+"/")
-		} else if !strings.Contains(impPath, ".") {
-			// Fallback for subdirectories without go.mod or relative imports
-			isInternal = true
-			targetDir = impPath
+	sort.Strings(paths)
+
+	c.goDirs = map[string][]string{}
+	c.defs = map[string][]string{}
+	c.modules = map[string][]string{}
+	for _, path := range paths {
+		f := c.Files[path]
+		if filepath.Ext(path) == ".go" {
+			c.goDirs[filepath.Dir(path)] = append(c.goDirs[filepath.Dir(path)], path)
 		}
-
-		if isInternal {
-			fullTargetDir := filepath.Join(b.root, targetDir)
-
-			if _, err := os.Stat(fullTargetDir); err == nil {
-				// Find all .go files in that directory
-				files, _ := filepath.Glob(filepath.Join(fullTargetDir, "*.go"))
-				for _, targetFile := range files {
-					targetRel, _ := filepath.Rel(b.root, targetFile)
-					b.graph.AddEdge(relPath, targetRel)
+		if !f.Parsed {
+			continue
+		}
+		g.AddNode(path, "file")
+		for _, name := range f.Symbols {
+			c.defs[name] = append(c.defs[name], path)
+			if language(path) == "elixir" && isModuleName(name) {
+				for suffix := name; ; {
+					c.modules[suffix] = append(c.modules[suffix], path)
+					_, rest, ok := strings.Cut(suffix, ".")
+					if !ok {
+						break
+					}
+					suffix = rest
 				}
 			}
 		}
 	}
-}
-
-func (b *Builder) parseGoMod() {
-	goModPath := filepath.Join(b.root, "go.mod")
-	file, err := os.Open(goModPath)
-	if err != nil {
-		return
-	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, "module ") {
-			b.moduleName = strings.TrimSpace(strings.TrimPrefix(line, "module "))
-			return
+	for _, path := range paths {
+		f := c.Files[path]
+		if !f.Parsed {
+			continue
 		}
-	}
-}
-
-var (
-	pyImportRegex   = regexp.MustCompile(`^(?:from\s+([\w\.]+)|import\s+([\w\.]+))`)
-	jsImportRegex   = regexp.MustCompile(`(?:import|require)\s*.*?['"]([^'"]+)['"]`)
-	rubyImportRegex = regexp.MustCompile(`^(?:require|require_relative)\s+['"]([^'"]+)['"]`)
-	rustUseRegex    = regexp.MustCompile(`^use\s+([\w:]+)`)
-)
-
-func (b *Builder) processPythonFile(path string) {
-	file, err := os.Open(path)
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	relPath, _ := filepath.Rel(b.root, path)
-	b.graph.AddNode(relPath, "file")
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		matches := pyImportRegex.FindStringSubmatch(line)
-		if len(matches) > 0 {
-			// matches[1] is for 'from X', matches[2] is for 'import X'
-			imp := matches[1]
-			if imp == "" {
-				imp = matches[2]
-			}
-			if imp == "" {
-				continue
-			}
-
-			// Convert python dot notation to path
-			impPath := strings.ReplaceAll(imp, ".", "/")
-
-			// Try multiple resolution strategies
-			// 1. Relative to current file's directory
-			targetPath := filepath.Join(filepath.Dir(path), impPath+".py")
-			if _, err := os.Stat(targetPath); err == nil {
-				targetRel, _ := filepath.Rel(b.root, targetPath)
-				b.graph.AddEdge(relPath, targetRel)
-				continue
-			}
-
-			// 2. Absolute from root
-			targetPath = filepath.Join(b.root, impPath+".py")
-			if _, err := os.Stat(targetPath); err == nil {
-				targetRel, _ := filepath.Rel(b.root, targetPath)
-				b.graph.AddEdge(relPath, targetRel)
-				continue
+		for _, imp := range f.Imports {
+			for _, target := range b.resolve(c, path, imp) {
+				g.AddEdge(path, target)
 			}
+		}
+		for _, name := range f.Symbols {
+			g.AddNode(symbolPath(path, name), "symbol")
+			g.AddEdge(symbolPath(path, name), path)
+		}
+	}
 
-			// 3. Try __init__.py in directory
-			targetPath = filepath.Join(b.root, impPath, "__init__.py")
-			if _, err := os.Stat(targetPath); err == nil {
-				targetRel, _ := filepath.Rel(b.root, targetPath)
-				b.graph.AddEdge(relPath, targetRel)
+	for _, path := range paths {
+		f := c.Files[path]
+		if !f.Parsed {
+			continue
+		}
+		for _, name := range f.Calls {
+			for _, target := range callTargets(c, g, path, name) {
+				g.AddEdge(path, target)
+				g.AddEdge(path, symbolPath(target, name))
 			}
 		}
 	}
+	return g
 }
 
-func (b *Builder) processJSFile(path string) {
-	file, err := os.Open(path)
-	if err != nil {
-		return
+// callTargets returns the files a call from file to name most likely
+// reaches: the files defining name that file imports or, failing that, the
+// only other file of the same language defining it. Names defined in file
+// itself are local calls.
+func callTargets(c *cache, g *Graph, file, name string) []string {
+	var defs []string
+	for _, def := range c.defs[name] {
+		if def == file {
+			return nil
+		}
+		if language(def) == language(file) {
+			defs = append(defs, def)
+		}
+	}
+	if len(defs) == 0 {
+		return nil
 	}
-	defer file.Close()
-
-	relPath, _ := filepath.Rel(b.root, path)
-	b.graph.AddNode(relPath, "file")
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		matches := jsImportRegex.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			imp := matches[1]
-
-			// Handle relative imports
-			if strings.HasPrefix(imp, ".") {
-				dir := filepath.Dir(path)
-				targetPath := filepath.Join(dir, imp)
-
-				// Try extensions
-				exts := []string{".js", ".ts", ".jsx", ".tsx", "/index.js", "/index.ts"}
-				for _, ext := range exts {
-					check := targetPath
-					if !strings.HasSuffix(targetPath, ext) && !strings.HasSuffix(targetPath, "/") {
-						check = targetPath + ext
-					}
 
-					if _, err := os.Stat(check); err == nil {
-						targetRel, _ := filepath.Rel(b.root, check)
-						b.graph.AddEdge(relPath, targetRel)
-						break
-					}
-				}
-			}
+	imported := map[string]bool{}
+	for _, id := range g.OutEdges[g.Paths[file]] {
+		imported[g.Nodes[id].Path] = true
+	}
+	var targets []string
+	for _, def := range defs {
+		if imported[def] {
+			targets = append(targets, def)
 		}
 	}
-}
-
-func (b *Builder) processRubyFile(path string) {
-	file, err := os.Open(path)
-	if err != nil {
-		return
+	if len(targets) == 0 && len(defs) == 1 {
+		targets = defs
 	}
-	defer file.Close()
-
-	relPath, _ := filepath.Rel(b.root, path)
-	b.graph.AddNode(relPath, "file")
+	return targets
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		matches := rubyImportRegex.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			imp := matches[1]
+// symbolPath is the path of a symbol's node: file#name
+func symbolPath(file, name string) string {
+	return file + "#" + name
+}
 
-			// Convert to path
-			var targetPath string
-			if strings.Contains(line, "require_relative") {
-				// Relative import
-				dir := filepath.Dir(path)
-				targetPath = filepath.Join(dir, imp+".rb")
+// resolve maps an import of file to the project files it refers to
+func (b *Builder) resolve(c *cache, file, imp string) []string {
+	dir := filepath.Dir(file)
+	switch filepath.Ext(file) {
+	case ".go":
+		var targetDir string
+		if b.moduleName != "" && strings.HasPrefix(imp, b.moduleName+"/") {
+			targetDir = strings.TrimPrefix(imp, b.moduleName+"/")
+		} else if !strings.Contains(imp, ".") {
+			// Fallback for subdirectories without go.mod or relative imports
+			targetDir = imp
+		} else {
+			return nil
+		}
+		// All .go files in that directory
+		return c.goDirs[filepath.Clean(filepath.FromSlash(targetDir))]
+
+	case ".py":
+		// Relative to the file's directory, then from the root, then a
+		// package's __init__.py
+		impPath := filepath.FromSlash(imp)
+		return c.first(filepath.Join(dir, impPath+".py"), impPath+".py", filepath.Join(impPath, "__init__.py"))
+
+	case ".js", ".ts", ".jsx", ".tsx":
+		targetPath := filepath.Join(dir, filepath.FromSlash(imp))
+		var candidates []string
+		for _, ext := range []string{".js", ".ts", ".jsx", ".tsx", "/index.js", "/index.ts"} {
+			ext = filepath.FromSlash(ext)
+			if strings.HasSuffix(targetPath, ext) {
+				candidates = append(candidates, targetPath)
 			} else {
-				// Absolute from root
-				targetPath = filepath.Join(b.root, "lib", imp+".rb")
+				candidates = append(candidates, targetPath+ext)
 			}
+		}
+		return c.first(candidates...)
 
-			if _, err := os.Stat(targetPath); err == nil {
-				targetRel, _ := filepath.Rel(b.root, targetPath)
-				b.graph.AddEdge(relPath, targetRel)
-			}
+	case ".rb":
+		if rel, ok := strings.CutPrefix(imp, "./"); ok {
+			return c.first(filepath.Join(dir, filepath.FromSlash(rel)+".rb"))
 		}
+		return c.first(filepath.Join("lib", filepath.FromSlash(imp)+".rb"))
+
+	case ".rs":
+		modPath := filepath.FromSlash(imp)
+		return c.first(filepath.Join("src", modPath+".rs"), filepath.Join("src", modPath, "mod.rs"))
+
+	case ".ex", ".exs":
+		// A module, by its full name or, as an alias leaves it, its last
+		// segments
+		return c.modules[imp]
 	}
+	return nil
 }
 
-func (b *Builder) processRustFile(path string) {
-	file, err := os.Open(path)
+func (b *Builder) parseGoMod() {
+	goModPath := filepath.Join(b.root, "go.mod")
+	file, err := os.Open(goModPath)
 	if err != nil {
 		return
 	}
 	defer file.Close()
 
-	relPath, _ := filepath.Rel(b.root, path)
-	b.graph.AddNode(relPath, "file")
-
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		matches := rustUseRegex.FindStringSubmatch(line)
-		if len(matches) > 1 {
-			imp := matches[1]
-
-			// Simple heuristic: if starts with "crate::" it's local
-			if strings.HasPrefix(imp, "crate::") || strings.HasPrefix(imp, "super::") {
-				// Convert module path to file path
-				modPath := strings.TrimPrefix(imp, "crate::")
-				modPath = strings.TrimPrefix(modPath, "super::")
-				modPath = strings.ReplaceAll(modPath, "::", "/")
-
-				// Try .rs file or mod.rs
-				for _, suffix := range []string{".rs", "/mod.rs"} {
-					targetPath := filepath.Join(b.root, "src", modPath+suffix)
-					if _, err := os.Stat(targetPath); err == nil {
-						targetRel, _ := filepath.Rel(b.root, targetPath)
-						b.graph.AddEdge(relPath, targetRel)
-						break
-					}
-				}
-			}
+		if strings.HasPrefix(line, "module ") {
+			b.moduleName = strings.TrimSpace(strings.TrimPrefix(line, "module "))
+			return
 		}
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestGoModuleImportResolution(t *testing.T) {
@@ -81,6 +82,13 @@ api.call();`,
 		"src/main.rs": `use crate::utils::helper;
 fn main() {}`,
 		"src/utils/helper.rs": `pub fn help() {}`,
+
+		// Elixir - modules resolve by name, wherever they are defined
+		"lib/web.ex": `defmodule MyApp.Web do
+  alias MyApp.Repo
+end`,
+		"lib/my_app/repo.ex": `defmodule MyApp.Repo do
+end`,
 	}
 
 	for path, content := range files {
@@ -130,6 +138,11 @@ fn main() {}`,
 			}
 		}
 	}
+
+	// Verify Elixir edge
+	if !hasEdge(g, g.Paths["lib/web.ex"], g.Paths["lib/my_app/repo.ex"]) {
+		t.Error("Missing Elixir edge lib/web.ex -> lib/my_app/repo.ex")
+	}
 }
 
 func TestPageRankConvergence(t *testing.T) {
@@ -330,6 +343,79 @@ func Login() { utils.Help() }`,
 	}
 }
 
+func TestIncrementalBuild(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		full := filepath.Join(root, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Files written in the same instant as an earlier version are told
+	// apart by their hash, but move mtimes on to exercise the usual path
+	later := time.Now()
+	touch := func(path string) {
+		later = later.Add(time.Second)
+		os.Chtimes(filepath.Join(root, path), later, later)
+	}
+	write("go.mod", "module example.com/app\n")
+	write("main.go", "package main\nimport \"example.com/app/util\"\n")
+	write("util/a.go", "package util\n")
+	write("app.py", "import lib\n")
+
+	build := func(want BuildStats) *Graph {
+		t.Helper()
+		b := NewBuilder(root)
+		g, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Stats() != want {
+			t.Errorf("stats = %+v, want %+v", b.Stats(), want)
+		}
+		return g
+	}
+
+	build(BuildStats{Files: 3, Parsed: 3})
+	if _, err := os.Stat(filepath.Join(root, CacheFile)); err != nil {
+		t.Fatalf("cache not written: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, ".gptcode", ".gitignore")); string(data) != "graph.cache\n" {
+		t.Errorf("cache should be ignored by git, .gitignore is %q", data)
+	}
+	build(BuildStats{Files: 3, Reused: 3})
+
+	// New files resolve imports of files that are not parsed again
+	write("util/b.go", "package util\n")
+	write("lib.py", "x = 1\n")
+	g := build(BuildStats{Files: 5, Parsed: 2, Reused: 3})
+	if !hasEdge(g, g.Paths["main.go"], g.Paths[filepath.Join("util", "b.go")]) || !hasEdge(g, g.Paths["app.py"], g.Paths["lib.py"]) {
+		t.Error("imports should resolve to the new files")
+	}
+
+	// A touched file is hashed but not parsed again; a changed one is
+	touch("main.go")
+	build(BuildStats{Files: 5, Reused: 5})
+	write("app.py", "x = 2\n")
+	touch("app.py")
+	g = build(BuildStats{Files: 5, Parsed: 1, Reused: 4})
+	if len(g.OutEdges[g.Paths["app.py"]]) != 0 {
+		t.Error("app.py no longer imports lib")
+	}
+
+	os.Remove(filepath.Join(root, "util", "a.go"))
+	g = build(BuildStats{Files: 4, Reused: 4})
+	if _, ok := g.Paths[filepath.Join("util", "a.go")]; ok {
+		t.Error("deleted file should leave the graph")
+	}
+
+	b := NewBuilder(root)
+	if _, err := b.Rebuild(); err != nil || b.Stats().Parsed != 4 {
+		t.Errorf("rebuild should parse every file, got %+v (%v)", b.Stats(), err)
+	}
+}
+
 func hasEdge(g *Graph, from, to int64) bool {
 	for _, id := range g.OutEdges[from] {
 		if id == to {
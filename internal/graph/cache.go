@@ -1,122 +1,152 @@
 package graph
 
 import (
-	"crypto/md5"
-	"encoding/json"
-	"fmt"
+	"encoding/gob"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 )
 
-type CacheEntry struct {
-	Hash      string    `json:"hash"`
-	Timestamp time.Time `json:"timestamp"`
-	Graph     *Graph    `json:"graph"`
+// CacheFile is where the graph cache is kept, relative to the project root
+const CacheFile = ".gptcode/graph.cache"
+
+// cacheVersion is bumped when parsing changes, so older caches are rebuilt
+const cacheVersion = 2
+
+// cache holds what each source file imports, defines and calls, along with
+// what identifies the version of the file they were parsed from
+type cache struct {
+	Version int
+	// Parser is the kind of parsers the files were parsed with, which
+	// depends on how gptcode was built
+	Parser string
+	Files  map[string]*cachedFile
+
+	dirty bool
+	// goDirs lists the .go files of each directory, defs the files
+	// defining each symbol and modules the files defining each Elixir
+	// module, by every suffix of its name, for resolving imports and calls
+	goDirs  map[string][]string
+	defs    map[string][]string
+	modules map[string][]string
 }
 
-type Cache struct {
-	cacheDir string
+type cachedFile struct {
+	ModTime int64
+	Size    int64
+	Hash    string
+	Imports []string
+	Symbols []string
+	Calls   []string
+	// Parsed is false for files that failed to parse, which are left out
+	// of the graph
+	Parsed bool
 }
 
-func NewCache() *Cache {
-	home, _ := os.UserHomeDir()
-	cacheDir := filepath.Join(home, ".gptcode", "cache")
-	_ = os.MkdirAll(cacheDir, 0755)
-	return &Cache{cacheDir: cacheDir}
+func newCache() *cache {
+	return &cache{Version: cacheVersion, Parser: parserKind, Files: map[string]*cachedFile{}, dirty: true}
 }
 
-func (c *Cache) cacheKey(root string) string {
-	return fmt.Sprintf("%x", md5.Sum([]byte(root)))
-}
-
-func (c *Cache) cachePath(root string) string {
-	return filepath.Join(c.cacheDir, fmt.Sprintf("graph_%s.json", c.cacheKey(root)))
-}
-
-func (c *Cache) computeHash(root string) (string, error) {
-	h := md5.New()
-
-	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			if info.Name() == ".git" || info.Name() == "vendor" || info.Name() == "node_modules" {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		ext := filepath.Ext(path)
-		if ext == ".go" || ext == ".py" || ext == ".js" || ext == ".ts" || ext == ".jsx" || ext == ".tsx" {
-			relPath, _ := filepath.Rel(root, path)
-			h.Write([]byte(relPath))
-			fmt.Fprintf(h, "%d", info.ModTime().Unix())
-		}
-
-		return nil
-	})
-
+// loadCache reads root's cache, or returns an empty one when there is none
+// or it is unusable.
+func loadCache(root string) *cache {
+	f, err := os.Open(filepath.Join(root, CacheFile))
 	if err != nil {
-		return "", err
+		return newCache()
 	}
-
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
+	defer f.Close()
+	var c cache
+	if err := gob.NewDecoder(f).Decode(&c); err != nil || c.Version != cacheVersion || c.Parser != parserKind || c.Files == nil {
+		return newCache()
+	}
+	return &c
 }
 
-func (c *Cache) Get(root string) (*Graph, error) {
-	cachePath := c.cachePath(root)
-
-	file, err := os.Open(cachePath)
+// refresh brings the entry of the file at path up to date, and reports
+// whether it had to be parsed. An unchanged size and modification time
+// skip reading the file; an unchanged content hash skips parsing it.
+func (c *cache) refresh(relPath, path string, info os.FileInfo) bool {
+	entry, ok := c.Files[relPath]
+	if ok && entry.ModTime == info.ModTime().UnixNano() && entry.Size == info.Size() {
+		return false
+	}
+	content, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		if ok {
+			delete(c.Files, relPath)
+			c.dirty = true
+		}
+		return false
 	}
-	defer file.Close()
-
-	var entry CacheEntry
-	if err := json.NewDecoder(file).Decode(&entry); err != nil {
-		return nil, err
+	c.dirty = true
+	hash := hashContent(content)
+	if ok && entry.Hash == hash {
+		entry.ModTime, entry.Size = info.ModTime().UnixNano(), info.Size()
+		return false
 	}
-
-	currentHash, err := c.computeHash(root)
-	if err != nil {
-		return nil, err
+	parsed, ok := parserFor(path)(content)
+	c.Files[relPath] = &cachedFile{
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+		Hash:    hash,
+		Imports: parsed.Imports,
+		Symbols: parsed.Symbols,
+		Calls:   parsed.Calls,
+		Parsed:  ok,
 	}
+	return true
+}
 
-	if entry.Hash != currentHash {
-		return nil, fmt.Errorf("cache stale")
+// first returns the first of the paths that is a source file of the project
+func (c *cache) first(paths ...string) []string {
+	for _, p := range paths {
+		if _, ok := c.Files[p]; ok {
+			return []string{p}
+		}
 	}
+	return nil
+}
 
-	if time.Since(entry.Timestamp) > 24*time.Hour {
-		return nil, fmt.Errorf("cache expired")
+// save writes the cache, and keeps it out of version control with a
+// .gptcode/.gitignore entry.
+func (c *cache) save(root string) error {
+	path := filepath.Join(root, CacheFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
+	ignoreCache(filepath.Dir(path))
 
-	return entry.Graph, nil
-}
-
-func (c *Cache) Set(root string, g *Graph) error {
-	hash, err := c.computeHash(root)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
 	if err != nil {
 		return err
 	}
-
-	entry := CacheEntry{
-		Hash:      hash,
-		Timestamp: time.Now(),
-		Graph:     g,
+	if err := gob.NewEncoder(f).Encode(c); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
 	}
-
-	cachePath := c.cachePath(root)
-	file, err := os.Create(cachePath)
-	if err != nil {
+	if err := f.Close(); err != nil {
 		return err
 	}
-	defer file.Close()
-
-	return json.NewEncoder(file).Encode(entry)
+	c.dirty = false
+	return os.Rename(tmp, path)
 }
 
-func (c *Cache) Clear(root string) error {
-	return os.Remove(c.cachePath(root))
+func ignoreCache(dir string) {
+	ignore := filepath.Join(dir, ".gitignore")
+	name := filepath.Base(CacheFile)
+	data, err := os.ReadFile(ignore)
+	if err != nil && !os.IsNotExist(err) {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == name {
+			return
+		}
+	}
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		data = append(data, '\n')
+	}
+	_ = os.WriteFile(ignore, append(data, name+"\n"...), 0644)
 }
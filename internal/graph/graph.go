@@ -2,16 +2,30 @@ package graph
 
 import (
 	"math"
+	"sort"
+	"strings"
 )
 
-// Node represents a file in the dependency graph
+// Node represents a file in the dependency graph, or a symbol one defines
 type Node struct {
 	ID    int64
-	Path  string
-	Type  string // "file", "package"
+	Path  string // file path, or file#symbol
+	Type  string // "file", "package", "symbol"
 	Score float64
 }
 
+// File returns the path of the file the node is, or defines it
+func (n *Node) File() string {
+	file, _, _ := strings.Cut(n.Path, "#")
+	return file
+}
+
+// Symbol returns the name of a symbol node, or "" for files
+func (n *Node) Symbol() string {
+	_, name, _ := strings.Cut(n.Path, "#")
+	return name
+}
+
 // Graph represents the dependency graph
 type Graph struct {
 	Nodes    map[int64]*Node
@@ -49,6 +63,18 @@ func (g *Graph) AddNode(path string, nodeType string) int64 {
 	return id
 }
 
+// Files returns the paths of the graph's files, sorted, leaving out symbols
+func (g *Graph) Files() []string {
+	var files []string
+	for path, id := range g.Paths {
+		if g.Nodes[id].Type != "symbol" {
+			files = append(files, path)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
 // AddEdge adds a directed edge from source to target
 func (g *Graph) AddEdge(fromPath, toPath string) {
 	fromID := g.AddNode(fromPath, "file")
@@ -17,6 +17,26 @@ func NewOptimizer(g *Graph) *Optimizer {
 
 // OptimizeContext returns the most relevant files for a query
 func (o *Optimizer) OptimizeContext(query string, limit int) []string {
+	scores := o.relevance(query)
+
+	// Sort and limit
+	paths := make([]string, 0, len(scores))
+	for path := range scores {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return scores[paths[i]] > scores[paths[j]]
+	})
+	if len(paths) > limit {
+		paths = paths[:limit]
+	}
+	return paths
+}
+
+// relevance scores the files matching the query terms and their direct
+// neighbors, weighted by PageRank. Symbols match by name, and their scores
+// go to the files defining them.
+func (o *Optimizer) relevance(query string) map[string]float64 {
 	// 1. Identify entry points (nodes matching query terms)
 	queryTerms := strings.Fields(strings.ToLower(query))
 	entryPoints := make(map[int64]float64)
@@ -24,6 +44,9 @@ func (o *Optimizer) OptimizeContext(query string, limit int) []string {
 	for id, node := range o.graph.Nodes {
 		score := 0.0
 		pathLower := strings.ToLower(node.Path)
+		if node.Type == "symbol" {
+			pathLower = strings.ToLower(node.Symbol())
+		}
 
 		for _, term := range queryTerms {
 			if strings.Contains(pathLower, term) {
@@ -53,36 +76,16 @@ func (o *Optimizer) OptimizeContext(query string, limit int) []string {
 	}
 
 	// 3. Combine with PageRank
-	type Result struct {
-		Path  string
-		Score float64
-	}
-	var results []Result
-
+	scores := make(map[string]float64, len(candidates))
 	for id, relevance := range candidates {
 		node := o.graph.Nodes[id]
+		file := node
+		if node.Type == "symbol" {
+			file = o.graph.Nodes[o.graph.Paths[node.File()]]
+		}
 		// Final score = Relevance * PageRank
 		// PageRank helps prioritize "central" files among the relevant ones
-		finalScore := relevance * (1.0 + node.Score*10.0)
-
-		results = append(results, Result{
-			Path:  node.Path,
-			Score: finalScore,
-		})
+		scores[file.Path] += relevance * (1.0 + file.Score*10.0)
 	}
-
-	// 4. Sort and limit
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
-
-	var paths []string
-	for i, res := range results {
-		if i >= limit {
-			break
-		}
-		paths = append(paths, res.Path)
-	}
-
-	return paths
+	return scores
 }
@@ -0,0 +1,220 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// parsedFile is what a parser finds in a source file: the imports, still
+// to be resolved to files, the names of the functions, classes and modules
+// it defines, and the names of the functions it calls
+type parsedFile struct {
+	Imports []string
+	Symbols []string
+	Calls   []string
+}
+
+// fileParser parses a source file; ok is false when it could not be parsed
+type fileParser func(content []byte) (f parsedFile, ok bool)
+
+// parserFor returns the parser for a source file, or nil for files that
+// are not part of the graph. Builds with cgo parse syntax trees, finding
+// symbols and calls; the regex parsers are the fallback, and mostly find
+// imports.
+func parserFor(path string) fileParser {
+	ext := filepath.Ext(path)
+	if p := syntaxParser(ext); p != nil {
+		return p
+	}
+	switch ext {
+	case ".go":
+		return parseGoImports
+	case ".py":
+		return scanImports(pyImportRegex, pythonImport)
+	case ".js", ".ts", ".jsx", ".tsx":
+		return scanImports(jsImportRegex, jsImport)
+	case ".rb":
+		return scanImports(rubyImportRegex, rubyImport)
+	case ".rs":
+		return scanImports(rustUseRegex, rustImport)
+	case ".ex", ".exs":
+		return parseElixir
+	}
+	return nil
+}
+
+// language groups the extensions whose files can call each other
+func language(path string) string {
+	switch filepath.Ext(path) {
+	case ".go":
+		return "go"
+	case ".py":
+		return "python"
+	case ".js", ".ts", ".jsx", ".tsx":
+		return "js"
+	case ".rb":
+		return "ruby"
+	case ".rs":
+		return "rust"
+	case ".ex", ".exs":
+		return "elixir"
+	}
+	return ""
+}
+
+func parseGoImports(content []byte) (parsedFile, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", content, parser.ImportsOnly)
+	if err != nil {
+		return parsedFile{}, false
+	}
+	var imports []string
+	for _, imp := range f.Imports {
+		// Clean import path (remove quotes)
+		imports = append(imports, strings.Trim(imp.Path.Value, "\""))
+	}
+	return parsedFile{Imports: imports}, true
+}
+
+var (
+	pyImportRegex   = regexp.MustCompile(`^(?:from\s+([\w\.]+)|import\s+([\w\.]+))`)
+	jsImportRegex   = regexp.MustCompile(`(?:import|require)\s*.*?['"]([^'"]+)['"]`)
+	rubyImportRegex = regexp.MustCompile(`^(?:require|require_relative)\s+['"]([^'"]+)['"]`)
+	rustUseRegex    = regexp.MustCompile(`^use\s+([\w:]+)`)
+
+	elixirModuleRegex = regexp.MustCompile(`^defmodule\s+([\w\.]+)`)
+	elixirImportRegex = regexp.MustCompile(`^(?:alias|import|use|require)\s+([A-Z][\w\.]*)`)
+)
+
+// scanImports returns a parser matching re on each trimmed line and
+// turning the match into an import with extract, which returns "" to skip
+// it
+func scanImports(re *regexp.Regexp, extract func(line string, matches []string) string) fileParser {
+	return func(content []byte) (parsedFile, bool) {
+		var imports []string
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if matches := re.FindStringSubmatch(line); len(matches) > 1 {
+				if imp := extract(line, matches); imp != "" {
+					imports = append(imports, imp)
+				}
+			}
+		}
+		return parsedFile{Imports: imports}, true
+	}
+}
+
+// parseElixir finds the modules a file defines and the ones it aliases,
+// imports, uses or requires
+func parseElixir(content []byte) (parsedFile, bool) {
+	var f parsedFile
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := elixirModuleRegex.FindStringSubmatch(line); m != nil {
+			f.Symbols = append(f.Symbols, m[1])
+		} else if m := elixirImportRegex.FindStringSubmatch(line); m != nil {
+			f.Imports = append(f.Imports, m[1])
+		}
+	}
+	return f.normalize(), true
+}
+
+func pythonImport(line string, matches []string) string {
+	// matches[1] is for 'from X', matches[2] is for 'import X'
+	imp := matches[1]
+	if imp == "" {
+		imp = matches[2]
+	}
+	return pythonModulePath(imp)
+}
+
+// pythonModulePath converts a module's dot notation to a path. Relative
+// imports (.utils) resolve from the importing file's directory first
+// anyway, so their leading dots are dropped.
+func pythonModulePath(imp string) string {
+	return strings.ReplaceAll(strings.TrimLeft(imp, "."), ".", "/")
+}
+
+func jsImport(line string, matches []string) string {
+	return jsRelativeImport(matches[1])
+}
+
+// jsRelativeImport keeps relative imports, the only ones that are project
+// files
+func jsRelativeImport(imp string) string {
+	if strings.HasPrefix(imp, ".") {
+		return imp
+	}
+	return ""
+}
+
+func rubyImport(line string, matches []string) string {
+	// Relative imports are kept as ./path, others resolve from lib/
+	if strings.Contains(line, "require_relative") {
+		return "./" + matches[1]
+	}
+	return matches[1]
+}
+
+func rustImport(line string, matches []string) string {
+	return rustModulePath(matches[1])
+}
+
+// rustModulePath converts a local use path (crate:: or super::) to a file
+// path, and returns "" for other crates
+func rustModulePath(imp string) string {
+	imp = strings.TrimSuffix(imp, "::")
+	if !strings.HasPrefix(imp, "crate::") && !strings.HasPrefix(imp, "super::") {
+		return ""
+	}
+	modPath := strings.TrimPrefix(imp, "crate::")
+	modPath = strings.TrimPrefix(modPath, "super::")
+	return strings.ReplaceAll(modPath, "::", "/")
+}
+
+// normalize sorts the names found and drops duplicates and blanks
+func (f parsedFile) normalize() parsedFile {
+	return parsedFile{Imports: dedupe(f.Imports), Symbols: dedupe(f.Symbols), Calls: dedupe(f.Calls)}
+}
+
+func dedupe(names []string) []string {
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	out := names[:0]
+	for i, name := range names {
+		if name != "" && (i == 0 || name != names[i-1]) {
+			out = append(out, name)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// isModuleName reports whether an Elixir symbol names a module rather than
+// a function
+func isModuleName(name string) bool {
+	for _, r := range name {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:12])
+}
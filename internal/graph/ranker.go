@@ -0,0 +1,276 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Signals combined by the Ranker, as named in Weights and Ranked.Signals
+const (
+	SignalGraph     = "graph"      // PageRank-weighted dependency relevance
+	SignalEmbedding = "embedding"  // similarity of the file to the query
+	SignalRecency   = "recency"    // recently committed or uncommitted changes
+	SignalOwnership = "ownership"  // share of the file's commits by the user
+	SignalOpenFiles = "open_files" // open in the user's editor
+)
+
+// Signals lists every signal in display order
+var Signals = []string{SignalGraph, SignalEmbedding, SignalRecency, SignalOwnership, SignalOpenFiles}
+
+// Weights is how much each signal counts towards a file's score
+type Weights map[string]float64
+
+// DefaultWeights favors the dependency graph, with the other signals
+// breaking ties and surfacing files the graph cannot connect to the query.
+func DefaultWeights() Weights {
+	return Weights{
+		SignalGraph:     0.45,
+		SignalEmbedding: 0.25,
+		SignalRecency:   0.15,
+		SignalOwnership: 0.05,
+		SignalOpenFiles: 0.10,
+	}
+}
+
+// With returns the weights with overrides applied, e.g. from the context
+// section of setup.yaml. A weight of 0 turns a signal off.
+func (w Weights) With(overrides map[string]float64) (Weights, error) {
+	out := Weights{}
+	for k, v := range w {
+		out[k] = v
+	}
+	for k, v := range overrides {
+		if _, ok := w[k]; !ok {
+			return nil, fmt.Errorf("unknown context signal %q (use %s)", k, strings.Join(Signals, ", "))
+		}
+		if v < 0 {
+			return nil, fmt.Errorf("context weight %s must not be negative", k)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+// Similarity scores files by embedding similarity to the query, from 0 to 1
+type Similarity func(query string, paths []string) (map[string]float64, error)
+
+// Ranker selects the files to give an agent as context by combining the
+// dependency graph with embedding similarity, git history and the files
+// open in the user's editor.
+type Ranker struct {
+	Weights Weights
+	// Similarity is optional; without it the embedding signal is skipped
+	Similarity Similarity
+	// OpenFiles are the files open in the user's editor, most recent first
+	OpenFiles []string
+
+	graph *Graph
+	root  string
+	// git returns the recency and ownership signals; replaced in tests
+	git func(root string) (recency, ownership map[string]float64)
+}
+
+// NewRanker returns a ranker over g, the graph of the project at root, with
+// the default weights and the open-file hints of editor integrations.
+func NewRanker(g *Graph, root string) *Ranker {
+	return &Ranker{
+		Weights:   DefaultWeights(),
+		OpenFiles: OpenFiles(root),
+		graph:     g,
+		root:      root,
+		git:       gitSignals,
+	}
+}
+
+// Ranked is a candidate file and how each signal scored it
+type Ranked struct {
+	Path     string             `json:"path"`
+	Score    float64            `json:"score"`
+	Signals  map[string]float64 `json:"signals"`
+	Included bool               `json:"included"`
+}
+
+// Selection records one context selection, so it can be explained later
+type Selection struct {
+	Time  time.Time `json:"time"`
+	Query string    `json:"query"`
+	Root  string    `json:"root"`
+	Limit int       `json:"limit"`
+	// Weights are the weights used, without signals that had no data
+	Weights Weights  `json:"weights"`
+	Files   []Ranked `json:"files"`
+}
+
+// Paths returns the selected files in order.
+func (s *Selection) Paths() []string {
+	var paths []string
+	for _, f := range s.Files {
+		if f.Included {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+// Select ranks the candidate files for query and marks the best limit as
+// included. Candidates are files the graph or embeddings relate to the query
+// and open files; recency and ownership only reorder them. Each signal is
+// normalized to 0-1 and signals without data are left out of the weighting.
+func (r *Ranker) Select(query string, limit int) *Selection {
+	signals := map[string]map[string]float64{
+		SignalGraph: normalize((&Optimizer{graph: r.graph}).relevance(query)),
+	}
+	if r.Similarity != nil && r.Weights[SignalEmbedding] > 0 {
+		if scores, err := r.Similarity(query, r.graph.Files()); err == nil {
+			signals[SignalEmbedding] = scores
+		}
+	}
+	if len(r.OpenFiles) > 0 {
+		open := map[string]float64{}
+		for i, path := range r.OpenFiles {
+			// The most recently used buffer counts most
+			open[path] = 1 / (1 + 0.25*float64(i))
+		}
+		signals[SignalOpenFiles] = open
+	}
+	if r.git != nil && (r.Weights[SignalRecency] > 0 || r.Weights[SignalOwnership] > 0) {
+		recency, ownership := r.git(r.root)
+		signals[SignalRecency] = recency
+		signals[SignalOwnership] = ownership
+	}
+
+	used := Weights{}
+	total := 0.0
+	for name, scores := range signals {
+		if w := r.Weights[name]; w > 0 && len(scores) > 0 {
+			used[name] = w
+			total += w
+		}
+	}
+
+	candidates := map[string]bool{}
+	for _, name := range []string{SignalGraph, SignalEmbedding, SignalOpenFiles} {
+		for path, score := range signals[name] {
+			if score > 0 && used[name] > 0 {
+				candidates[path] = true
+			}
+		}
+	}
+
+	sel := &Selection{Time: time.Now(), Query: query, Root: r.root, Limit: limit, Weights: used}
+	for path := range candidates {
+		ranked := Ranked{Path: path, Signals: map[string]float64{}}
+		for name, w := range used {
+			if v := signals[name][path]; v > 0 {
+				ranked.Signals[name] = v
+				ranked.Score += w * v / total
+			}
+		}
+		sel.Files = append(sel.Files, ranked)
+	}
+	sort.Slice(sel.Files, func(i, j int) bool {
+		if sel.Files[i].Score != sel.Files[j].Score {
+			return sel.Files[i].Score > sel.Files[j].Score
+		}
+		return sel.Files[i].Path < sel.Files[j].Path
+	})
+	for i := range sel.Files {
+		sel.Files[i].Included = i < limit
+	}
+	return sel
+}
+
+// Explain renders the selection as a table of each candidate's signals,
+// with the files that were left out below the cutoff.
+func (s *Selection) Explain(max int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Weights:")
+	for _, name := range Signals {
+		if w, ok := s.Weights[name]; ok {
+			fmt.Fprintf(&b, " %s=%.2f", name, w)
+		}
+	}
+	b.WriteString("\n")
+	for i, f := range s.Files {
+		if i >= max {
+			fmt.Fprintf(&b, "  ... %d more candidates\n", len(s.Files)-max)
+			break
+		}
+		if i == s.Limit {
+			fmt.Fprintf(&b, "  --- not included (limit %d) ---\n", s.Limit)
+		}
+		var parts []string
+		for _, name := range Signals {
+			if v, ok := f.Signals[name]; ok {
+				parts = append(parts, fmt.Sprintf("%s=%.2f", name, v))
+			}
+		}
+		fmt.Fprintf(&b, "  %.3f  %s  [%s]\n", f.Score, f.Path, strings.Join(parts, " "))
+	}
+	return b.String()
+}
+
+// maxLoggedSelections is how many selections the log keeps
+const maxLoggedSelections = 200
+
+// maxLoggedCandidates is how many candidates of a selection are logged
+const maxLoggedCandidates = 30
+
+// SelectionLogPath is ~/.gptcode/context_selections.jsonl
+func SelectionLogPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "context_selections.jsonl")
+}
+
+// LogSelection appends the selection to the selection log, keeping the most
+// recent ones, so "why was this file (not) included" can be answered after
+// the fact.
+func LogSelection(sel *Selection) error {
+	logged := *sel
+	if len(logged.Files) > maxLoggedCandidates {
+		logged.Files = logged.Files[:maxLoggedCandidates]
+	}
+	line, err := json.Marshal(logged)
+	if err != nil {
+		return err
+	}
+
+	path := SelectionLogPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var lines []string
+	if data, err := os.ReadFile(path); err == nil {
+		lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) == 1 && lines[0] == "" {
+			lines = nil
+		}
+	}
+	lines = append(lines, string(line))
+	if len(lines) > maxLoggedSelections {
+		lines = lines[len(lines)-maxLoggedSelections:]
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+func normalize(scores map[string]float64) map[string]float64 {
+	max := 0.0
+	for _, v := range scores {
+		if v > max {
+			max = v
+		}
+	}
+	if max == 0 {
+		return scores
+	}
+	out := make(map[string]float64, len(scores))
+	for k, v := range scores {
+		out[k] = v / max
+	}
+	return out
+}
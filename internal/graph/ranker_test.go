@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rankerGraph() *Graph {
+	g := NewGraph()
+	g.AddEdge("auth/login.go", "auth/session.go")
+	g.AddEdge("api/handler.go", "auth/login.go")
+	g.AddNode("billing/invoice.go", "file")
+	g.AddNode("docs/notes.go", "file")
+	g.PageRank(0.85, 20)
+	return g
+}
+
+func TestRankerCombinesSignals(t *testing.T) {
+	r := &Ranker{
+		Weights:   DefaultWeights(),
+		OpenFiles: []string{"billing/invoice.go"},
+		graph:     rankerGraph(),
+		root:      "/project",
+		git: func(string) (map[string]float64, map[string]float64) {
+			// docs/notes.go is recent but unrelated, so it must not be nominated
+			return map[string]float64{"auth/session.go": 1, "docs/notes.go": 1}, map[string]float64{}
+		},
+	}
+
+	sel := r.Select("login", 3)
+	paths := map[string]bool{}
+	for _, f := range sel.Files {
+		paths[f.Path] = true
+	}
+	if !paths["billing/invoice.go"] {
+		t.Error("open files should be candidates")
+	}
+	if paths["docs/notes.go"] {
+		t.Error("recency alone should not nominate a candidate")
+	}
+	if sel.Files[0].Path != "auth/login.go" {
+		t.Errorf("expected the query match first, got %s", sel.Files[0].Path)
+	}
+	if len(sel.Paths()) != 3 {
+		t.Errorf("expected 3 included files, got %v", sel.Paths())
+	}
+
+	// Signals without data are left out and the rest renormalized
+	if _, ok := sel.Weights[SignalEmbedding]; ok {
+		t.Error("embedding has no data and should not be weighted")
+	}
+	if _, ok := sel.Weights[SignalOwnership]; ok {
+		t.Error("ownership has no data and should not be weighted")
+	}
+	for _, f := range sel.Files {
+		if f.Score > 1 {
+			t.Errorf("%s scored %f, want at most 1", f.Path, f.Score)
+		}
+	}
+
+	explain := sel.Explain(10)
+	if !strings.Contains(explain, "not included (limit 3)") || !strings.Contains(explain, "open_files=1.00") {
+		t.Errorf("unexpected explanation:\n%s", explain)
+	}
+}
+
+func TestRankerRecencyReorders(t *testing.T) {
+	g := NewGraph()
+	g.AddNode("pkg/user_a.go", "file")
+	g.AddNode("pkg/user_b.go", "file")
+	r := &Ranker{
+		Weights: DefaultWeights(),
+		graph:   g,
+		git: func(string) (map[string]float64, map[string]float64) {
+			return map[string]float64{"pkg/user_b.go": 1}, nil
+		},
+	}
+	if top := r.Select("user", 1).Paths(); len(top) != 1 || top[0] != "pkg/user_b.go" {
+		t.Errorf("recently changed file should win the tie, got %v", top)
+	}
+
+	weights, err := DefaultWeights().With(map[string]float64{SignalRecency: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Weights = weights
+	if top := r.Select("user", 1).Paths(); top[0] != "pkg/user_a.go" {
+		t.Errorf("with recency off ties break by path, got %v", top)
+	}
+}
+
+func TestWeightsWith(t *testing.T) {
+	if _, err := DefaultWeights().With(map[string]float64{"stars": 1}); err == nil {
+		t.Error("expected an error for an unknown signal")
+	}
+	if _, err := DefaultWeights().With(map[string]float64{SignalGraph: -1}); err == nil {
+		t.Error("expected an error for a negative weight")
+	}
+}
+
+func TestScoreHistory(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	month := int64(recencyHalfLife.Seconds())
+	log := "\x00" + strconv.FormatInt(now.Unix(), 10) + " me@example.com\n\na.go\nb.go\n" +
+		"\x00" + strconv.FormatInt(now.Unix()-month, 10) + " other@example.com\n\nb.go\nc.go\n"
+
+	recency, ownership := scoreHistory([]byte(log), "me@example.com", now, []string{"c.go"})
+	if recency["a.go"] != 1 || recency["b.go"] != 1 {
+		t.Errorf("files in the latest commit should score 1, got %v", recency)
+	}
+	if recency["c.go"] != 1 {
+		t.Errorf("uncommitted files should score 1, got %v", recency["c.go"])
+	}
+	if ownership["a.go"] != 1 || ownership["b.go"] != 0.5 || ownership["c.go"] != 0 {
+		t.Errorf("unexpected ownership %v", ownership)
+	}
+
+	recency, _ = scoreHistory([]byte(log), "", now, nil)
+	if recency["c.go"] != 0.5 {
+		t.Errorf("a commit one half-life old should score 0.5, got %v", recency["c.go"])
+	}
+}
+
+func TestOpenFiles(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+
+	t.Setenv("GPTCODE_OPEN_FILES", strings.Join([]string{filepath.Join(root, "a.go"), "b.go", "/elsewhere/c.go"}, string(filepath.ListSeparator)))
+	if got := OpenFiles(root); strings.Join(got, ",") != "a.go,b.go" {
+		t.Errorf("OpenFiles from the environment = %v", got)
+	}
+
+	t.Setenv("GPTCODE_OPEN_FILES", "")
+	data, _ := json.Marshal(map[string][]string{
+		root:         {filepath.Join(root, "sub", "x.go")},
+		"/elsewhere": {"/elsewhere/y.go"},
+	})
+	_ = os.MkdirAll(filepath.Dir(OpenFilesPath()), 0755)
+	_ = os.WriteFile(OpenFilesPath(), data, 0644)
+	if got := OpenFiles(filepath.Join(root, "sub")); len(got) != 1 || got[0] != "x.go" {
+		t.Errorf("OpenFiles from the editor record = %v", got)
+	}
+}
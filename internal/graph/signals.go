@@ -0,0 +1,151 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recencyHalfLife is how long it takes a commit's recency score to halve
+const recencyHalfLife = 30 * 24 * time.Hour
+
+// gitSignals scores the files under root by how recently they changed
+// (uncommitted changes score 1) and by the share of their recent commits
+// made by the current git user. Outside a repository both are empty.
+func gitSignals(root string) (recency, ownership map[string]float64) {
+	recency, ownership = map[string]float64{}, map[string]float64{}
+	out, err := gitOutput(root, "log", "--since=180.days", "-n", "2000", "--relative", "--name-only", "--format=%x00%ct %ae")
+	if err != nil {
+		return recency, ownership
+	}
+	return scoreHistory(out, gitUser(root), time.Now(), uncommitted(root))
+}
+
+// scoreHistory scores the output of git log --name-only --format=%x00%ct %ae.
+func scoreHistory(log []byte, user string, now time.Time, dirty []string) (recency, ownership map[string]float64) {
+	recency, ownership = map[string]float64{}, map[string]float64{}
+	commits, mine := map[string]int{}, map[string]int{}
+
+	var when time.Time
+	var author string
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "\x00") {
+			fields := strings.Fields(strings.TrimPrefix(line, "\x00"))
+			if len(fields) == 2 {
+				sec, _ := strconv.ParseInt(fields[0], 10, 64)
+				when, author = time.Unix(sec, 0), fields[1]
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		// Commits are newest first, so the first one seen sets recency
+		if _, seen := recency[line]; !seen {
+			recency[line] = math.Pow(0.5, now.Sub(when).Hours()/recencyHalfLife.Hours())
+		}
+		commits[line]++
+		if user != "" && strings.EqualFold(author, user) {
+			mine[line]++
+		}
+	}
+	for path, n := range commits {
+		if mine[path] > 0 {
+			ownership[path] = float64(mine[path]) / float64(n)
+		}
+	}
+	for _, path := range dirty {
+		recency[path] = 1
+	}
+	return recency, ownership
+}
+
+func gitUser(root string) string {
+	out, err := gitOutput(root, "config", "user.email")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// uncommitted lists the modified and untracked files under root.
+func uncommitted(root string) []string {
+	var paths []string
+	for _, args := range [][]string{
+		{"diff", "--name-only", "--relative", "HEAD"},
+		{"ls-files", "--others", "--exclude-standard"},
+	} {
+		out, err := gitOutput(root, args...)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				paths = append(paths, line)
+			}
+		}
+	}
+	return paths
+}
+
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
+
+// OpenFilesPath is ~/.gptcode/open_files.json, where editor integrations
+// record the files open in each project: {"<project root>": ["<file>", ...]},
+// most recently used first.
+func OpenFilesPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "open_files.json")
+}
+
+// OpenFiles returns the files open in the user's editor under root, relative
+// to it, from GPTCODE_OPEN_FILES (a path list) or else the open files
+// recorded by editor integrations for root or a project containing it.
+func OpenFiles(root string) []string {
+	var files []string
+	if env := os.Getenv("GPTCODE_OPEN_FILES"); env != "" {
+		files = filepath.SplitList(env)
+	} else if data, err := os.ReadFile(OpenFilesPath()); err == nil {
+		var projects map[string][]string
+		if json.Unmarshal(data, &projects) == nil {
+			for project, open := range projects {
+				if project == root || strings.HasPrefix(root, project+string(filepath.Separator)) {
+					for _, f := range open {
+						if !filepath.IsAbs(f) {
+							f = filepath.Join(project, f)
+						}
+						files = append(files, f)
+					}
+				}
+			}
+		}
+	}
+
+	var rel []string
+	seen := map[string]bool{}
+	for _, f := range files {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(root, f)
+		}
+		r, err := filepath.Rel(root, f)
+		if err != nil || strings.HasPrefix(r, "..") || seen[r] {
+			continue
+		}
+		seen[r] = true
+		rel = append(rel, r)
+	}
+	return rel
+}
@@ -0,0 +1,264 @@
+//go:build cgo
+
+package graph
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/elixir"
+	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/ruby"
+	"github.com/smacker/go-tree-sitter/rust"
+	"github.com/smacker/go-tree-sitter/typescript/tsx"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
+)
+
+// parserKind tells the cache which parsers built it
+const parserKind = "tree-sitter"
+
+// The queries capture @import (turned into a path by the language's
+// normalizer), @import.relative (Ruby's require_relative), @def for the
+// symbols a file defines and @call for the names it calls. Captures
+// starting with _ only feed predicates.
+const jsQuery = `
+(import_statement source: (string) @import)
+(export_statement source: (string) @import)
+(call_expression function: (identifier) @_fn arguments: (arguments . (string) @import) (#eq? @_fn "require"))
+(call_expression function: (import) arguments: (arguments . (string) @import))
+(function_declaration name: (_) @def)
+(class_declaration name: (_) @def)
+(method_definition name: (_) @def)
+(variable_declarator name: (identifier) @def value: [(arrow_function) (function_expression)])
+(call_expression function: (identifier) @call)
+(call_expression function: (member_expression property: (property_identifier) @call))
+(new_expression constructor: (identifier) @call)
+`
+
+const tsQuery = jsQuery + `
+(interface_declaration name: (_) @def)
+(type_alias_declaration name: (_) @def)
+(abstract_class_declaration name: (_) @def)
+`
+
+const pythonQuery = `
+(import_statement name: (dotted_name) @import)
+(import_statement name: (aliased_import name: (dotted_name) @import))
+(import_from_statement module_name: (_) @import)
+(function_definition name: (identifier) @def)
+(class_definition name: (identifier) @def)
+(call function: (identifier) @call)
+(call function: (attribute attribute: (identifier) @call))
+`
+
+const rubyQuery = `
+(call method: (identifier) @_fn arguments: (argument_list . (string (string_content) @import)) (#eq? @_fn "require"))
+(call method: (identifier) @_fn arguments: (argument_list . (string (string_content) @import.relative)) (#eq? @_fn "require_relative"))
+(method name: (_) @def)
+(singleton_method name: (_) @def)
+(class name: (constant) @def)
+(module name: (constant) @def)
+(call method: (identifier) @call)
+(call receiver: (constant) @call)
+`
+
+const rustQuery = `
+(use_declaration argument: (_) @import)
+(function_item name: (identifier) @def)
+(struct_item name: (type_identifier) @def)
+(enum_item name: (type_identifier) @def)
+(trait_item name: (type_identifier) @def)
+(call_expression function: (identifier) @call)
+(call_expression function: (scoped_identifier name: (identifier) @call))
+(call_expression function: (field_expression field: (field_identifier) @call))
+(struct_expression name: (type_identifier) @call)
+`
+
+// syntaxLanguage is a grammar and its query, compiled on first use
+type syntaxLanguage struct {
+	lang  *sitter.Language
+	query string
+	// imports turns a captured import into the path resolve expects, ""
+	// skipping it
+	imports func(imp string) string
+
+	once sync.Once
+	q    *sitter.Query
+	err  error
+}
+
+var (
+	jsLanguage  = &syntaxLanguage{lang: javascript.GetLanguage(), query: jsQuery, imports: jsRelativeImport}
+	tsLanguage  = &syntaxLanguage{lang: typescript.GetLanguage(), query: tsQuery, imports: jsRelativeImport}
+	tsxLanguage = &syntaxLanguage{lang: tsx.GetLanguage(), query: tsQuery, imports: jsRelativeImport}
+
+	syntaxLanguages = map[string]*syntaxLanguage{
+		".py":  {lang: python.GetLanguage(), query: pythonQuery, imports: pythonModulePath},
+		".js":  jsLanguage,
+		".jsx": jsLanguage,
+		".ts":  tsLanguage,
+		".tsx": tsxLanguage,
+		".rb":  {lang: ruby.GetLanguage(), query: rubyQuery, imports: func(imp string) string { return imp }},
+		".rs":  {lang: rust.GetLanguage(), query: rustQuery, imports: rustUsePath},
+	}
+
+	elixirLanguage = elixir.GetLanguage()
+)
+
+// syntaxParser returns the tree-sitter parser for an extension, or nil
+// when there is no grammar for it
+func syntaxParser(ext string) fileParser {
+	if ext == ".ex" || ext == ".exs" {
+		return parseElixirSyntax
+	}
+	l := syntaxLanguages[ext]
+	if l == nil {
+		return nil
+	}
+	return l.parse
+}
+
+func (l *syntaxLanguage) compile() (*sitter.Query, error) {
+	l.once.Do(func() {
+		l.q, l.err = sitter.NewQuery([]byte(l.query), l.lang)
+	})
+	return l.q, l.err
+}
+
+func (l *syntaxLanguage) parse(content []byte) (parsedFile, bool) {
+	q, err := l.compile()
+	if err != nil {
+		return parsedFile{}, false
+	}
+	tree, err := parseSyntax(l.lang, content)
+	if err != nil {
+		return parsedFile{}, false
+	}
+	defer tree.Close()
+
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(q, tree.RootNode())
+
+	var f parsedFile
+	for {
+		m, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+		m = cursor.FilterPredicates(m, content)
+		for _, c := range m.Captures {
+			text := c.Node.Content(content)
+			switch q.CaptureNameForId(c.Index) {
+			case "import":
+				f.Imports = append(f.Imports, l.imports(strings.Trim(text, "'\"`")))
+			case "import.relative":
+				f.Imports = append(f.Imports, "./"+text)
+			case "def":
+				f.Symbols = append(f.Symbols, text)
+			case "call":
+				f.Calls = append(f.Calls, text)
+			}
+		}
+	}
+	return f.normalize(), true
+}
+
+func parseSyntax(lang *sitter.Language, content []byte) (*sitter.Tree, error) {
+	parser := sitter.NewParser()
+	defer parser.Close()
+	parser.SetLanguage(lang)
+	return parser.ParseCtx(context.Background(), nil, content)
+}
+
+var rustPathRegex = regexp.MustCompile(`^[\w:]+`)
+
+// rustUsePath turns a use tree (crate::a::b::{c, d}) into its module path
+func rustUsePath(imp string) string {
+	return rustModulePath(rustPathRegex.FindString(imp))
+}
+
+// parseElixirSyntax walks an Elixir syntax tree, where definitions,
+// imports and calls are all call nodes
+func parseElixirSyntax(content []byte) (parsedFile, bool) {
+	tree, err := parseSyntax(elixirLanguage, content)
+	if err != nil {
+		return parsedFile{}, false
+	}
+	defer tree.Close()
+
+	var f parsedFile
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		if n.Type() == "call" {
+			elixirCall(n, content, &f)
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	walk(tree.RootNode())
+	return f.normalize(), true
+}
+
+func elixirCall(n *sitter.Node, content []byte, f *parsedFile) {
+	target := n.ChildByFieldName("target")
+	if target == nil {
+		return
+	}
+	if target.Type() == "dot" {
+		// Module.function(...)
+		left, right := target.ChildByFieldName("left"), target.ChildByFieldName("right")
+		if left != nil && left.Type() == "alias" {
+			f.Imports = append(f.Imports, left.Content(content))
+		}
+		if right != nil && right.Type() == "identifier" {
+			f.Calls = append(f.Calls, right.Content(content))
+		}
+		return
+	}
+	if target.Type() != "identifier" {
+		return
+	}
+
+	var first *sitter.Node
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		if c := n.NamedChild(i); c.Type() == "arguments" && c.NamedChildCount() > 0 {
+			first = c.NamedChild(0)
+			break
+		}
+	}
+	name := target.Content(content)
+	switch name {
+	case "defmodule":
+		if first != nil && first.Type() == "alias" {
+			f.Symbols = append(f.Symbols, first.Content(content))
+		}
+	case "def", "defp", "defmacro", "defmacrop":
+		if first == nil {
+			return
+		}
+		// def name, def name(args) or def name(args) when guard
+		if first.Type() == "binary_operator" {
+			if left := first.ChildByFieldName("left"); left != nil {
+				first = left
+			}
+		}
+		if first.Type() == "call" {
+			first = first.ChildByFieldName("target")
+		}
+		if first != nil && first.Type() == "identifier" {
+			f.Symbols = append(f.Symbols, first.Content(content))
+		}
+	case "alias", "import", "use", "require":
+		if first != nil && first.Type() == "alias" {
+			f.Imports = append(f.Imports, first.Content(content))
+		}
+	default:
+		f.Calls = append(f.Calls, name)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !cgo
+
+package graph
+
+// parserKind tells the cache which parsers built it
+const parserKind = "regex"
+
+// syntaxParser returns nil: the tree-sitter grammars need cgo, so builds
+// without it fall back to the regex parsers
+func syntaxParser(ext string) fileParser {
+	return nil
+}
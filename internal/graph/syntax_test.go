@@ -0,0 +1,128 @@
+//go:build cgo
+
+package graph
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSymbolsAndCalls(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"app/main.py": `from .billing import charge
+import app.models
+
+def checkout(order):
+    return charge(order.total)
+`,
+		"app/billing.py": `def charge(amount):
+    return Invoice(amount)
+
+class Invoice:
+    pass
+`,
+		"web/cart.ts": `import { total } from './pricing';
+export function render(items: Item[]) { return total(items); }
+interface Item { price: number }
+`,
+		"web/pricing.ts": `export const total = (items) => items.reduce(sum, 0);
+function sum(a, b) { return a + b.price; }
+`,
+		"lib/shop.rb": `require_relative 'cart'
+class Shop
+  def buy(item)
+    Cart.new.add(item)
+  end
+end
+`,
+		"lib/cart.rb": `class Cart
+  def add(item); end
+end
+`,
+		"src/main.rs": `use crate::store::{Store, open};
+fn main() { let s = open(); s.save(); }
+`,
+		"src/store.rs": `pub struct Store;
+pub fn open() -> Store { Store }
+impl Store { pub fn save(&self) {} }
+`,
+		"lib/accounts.ex": `defmodule MyApp.Accounts do
+  alias MyApp.Repo
+  def get_user(id) when is_integer(id), do: Repo.get(id)
+  defp log(msg), do: msg
+end
+`,
+		"lib/repo.ex": `defmodule MyApp.Repo do
+  def get(id), do: id
+end
+`,
+	}
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		os.MkdirAll(filepath.Dir(full), 0755)
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g, err := NewBuilder(root).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sym := range []string{
+		"app/billing.py#charge", "app/billing.py#Invoice", "app/main.py#checkout",
+		"web/cart.ts#render", "web/cart.ts#Item", "web/pricing.ts#total", "web/pricing.ts#sum",
+		"lib/shop.rb#Shop", "lib/shop.rb#buy", "lib/cart.rb#add",
+		"src/store.rs#open", "src/store.rs#Store",
+		"lib/accounts.ex#MyApp.Accounts", "lib/accounts.ex#get_user", "lib/accounts.ex#log", "lib/repo.ex#get",
+	} {
+		id, ok := g.Paths[filepath.FromSlash(sym)]
+		if !ok || g.Nodes[id].Type != "symbol" {
+			t.Errorf("missing symbol %s", sym)
+			continue
+		}
+		if file := g.Nodes[id].File(); !hasEdge(g, id, g.Paths[file]) {
+			t.Errorf("symbol %s should link to %s", sym, file)
+		}
+	}
+
+	for _, e := range [][2]string{
+		// Imports
+		{"app/main.py", "app/billing.py"},
+		{"web/cart.ts", "web/pricing.ts"},
+		{"lib/shop.rb", "lib/cart.rb"},
+		{"src/main.rs", "src/store.rs"},
+		{"lib/accounts.ex", "lib/repo.ex"},
+		// Calls
+		{"app/main.py", "app/billing.py#charge"},
+		{"web/cart.ts", "web/pricing.ts#total"},
+		{"lib/shop.rb", "lib/cart.rb#Cart"},
+		{"lib/shop.rb", "lib/cart.rb#add"},
+		{"src/main.rs", "src/store.rs#open"},
+		{"src/main.rs", "src/store.rs#save"},
+		{"lib/accounts.ex", "lib/repo.ex#get"},
+	} {
+		from, to := filepath.FromSlash(e[0]), filepath.FromSlash(e[1])
+		if !hasEdge(g, g.Paths[from], g.Paths[to]) {
+			t.Errorf("missing edge %s -> %s", from, to)
+		}
+	}
+
+	// Calls to a file's own symbols stay inside it
+	if hasEdge(g, g.Paths["app/billing.py"], g.Paths["app/billing.py#Invoice"]) {
+		t.Error("local calls should not add edges")
+	}
+	if got, want := g.Files(), []string{"app/billing.py", "app/main.py", "lib/accounts.ex", "lib/cart.rb", "lib/repo.ex", "lib/shop.rb", "src/main.rs", "src/store.rs", "web/cart.ts", "web/pricing.ts"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Files() = %v, want %v", got, want)
+	}
+
+	// A symbol's name brings up the file defining it
+	g.PageRank(0.85, 20)
+	if results := NewOptimizer(g).OptimizeContext("invoice", 1); len(results) != 1 || results[0] != "app/billing.py" {
+		t.Errorf("OptimizeContext(invoice) = %v, want app/billing.py", results)
+	}
+}
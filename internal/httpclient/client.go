@@ -0,0 +1,183 @@
+// Package httpclient builds HTTP clients that honor the proxy, CA bundle and
+// client certificate settings from setup.yaml.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gptcode/internal/config"
+)
+
+// New builds an HTTP client for the given network settings.
+func New(cfg config.NetworkConfig, timeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxyURL, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", cfg.Proxy, err)
+		}
+		noProxy := splitNoProxy(cfg.NoProxy)
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			if bypassProxy(req.URL.Hostname(), noProxy) {
+				return nil, nil
+			}
+			return proxyURL, nil
+		}
+	}
+
+	tlsConfig, err := tlsConfigFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+func tlsConfigFor(cfg config.NetworkConfig) (*tls.Config, error) {
+	if cfg.CACert == "" && cfg.ClientCert == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(expandHome(cfg.CACert))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.ClientCert != "" || cfg.ClientKey != "" {
+		if cfg.ClientCert == "" || cfg.ClientKey == "" {
+			return nil, fmt.Errorf("client_cert and client_key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(expandHome(cfg.ClientCert), expandHome(cfg.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Default returns a client using the global network section of setup.yaml.
+// Invalid settings are reported on stderr and the standard client is used.
+func Default() *http.Client {
+	setup, _ := config.LoadEffectiveSetup()
+	return mustNew(setup.Network, 0)
+}
+
+// ForBackend returns a client for a backend, with the backend's network
+// section layered over the global one.
+func ForBackend(backendName string) *http.Client {
+	return mustNew(NetworkFor(backendName), 0)
+}
+
+// NetworkFor resolves the effective network settings for a backend.
+func NetworkFor(backendName string) config.NetworkConfig {
+	setup, _ := config.LoadEffectiveSetup()
+	cfg := setup.Network
+	if backend, ok := setup.Backend[backendName]; ok && backend.Network != nil {
+		cfg = backend.Network.Merge(setup.Network)
+	}
+	return cfg
+}
+
+func mustNew(cfg config.NetworkConfig, timeout time.Duration) *http.Client {
+	client, err := New(cfg, timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[WARNING] Ignoring network settings: %v\n", err)
+		return &http.Client{Timeout: timeout}
+	}
+	return client
+}
+
+// Env returns environment variables that make subprocesses such as gh and git
+// use the global proxy and CA bundle.
+func Env() []string {
+	setup, _ := config.LoadEffectiveSetup()
+	return EnvFor(setup.Network)
+}
+
+// EnvFor returns environment variables for the given network settings.
+func EnvFor(cfg config.NetworkConfig) []string {
+	var env []string
+	if cfg.Proxy != "" {
+		env = append(env,
+			"HTTP_PROXY="+cfg.Proxy, "HTTPS_PROXY="+cfg.Proxy,
+			"http_proxy="+cfg.Proxy, "https_proxy="+cfg.Proxy)
+	}
+	if cfg.NoProxy != "" {
+		env = append(env, "NO_PROXY="+cfg.NoProxy, "no_proxy="+cfg.NoProxy)
+	}
+	if cfg.CACert != "" {
+		path := expandHome(cfg.CACert)
+		env = append(env, "SSL_CERT_FILE="+path, "GIT_SSL_CAINFO="+path)
+	}
+	return env
+}
+
+func splitNoProxy(value string) []string {
+	var hosts []string
+	for _, h := range strings.Split(value, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, strings.ToLower(h))
+		}
+	}
+	return hosts
+}
+
+func bypassProxy(host string, noProxy []string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+		return true
+	}
+	for _, entry := range noProxy {
+		if entry == "*" {
+			return true
+		}
+		entry = strings.TrimPrefix(entry, "*")
+		if host == strings.TrimPrefix(entry, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
+
+// CommandEnv returns the current environment extended with the global
+// network settings, for use as exec.Cmd.Env.
+func CommandEnv() []string {
+	return append(os.Environ(), Env()...)
+}
@@ -0,0 +1,77 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gptcode/internal/config"
+)
+
+func TestNewWithCACert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caPath, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	plain, err := New(config.NetworkConfig{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.Get(srv.URL); err == nil {
+		t.Fatal("expected TLS failure without custom CA")
+	}
+
+	client, err := New(config.NetworkConfig{CACert: caPath}, 0)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request with custom CA failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewRejectsPartialClientCert(t *testing.T) {
+	if _, err := New(config.NetworkConfig{ClientCert: "cert.pem"}, 0); err == nil {
+		t.Error("expected error when client_key is missing")
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	noProxy := splitNoProxy("internal.example.com, .corp")
+	tests := map[string]bool{
+		"localhost":            true,
+		"internal.example.com": true,
+		"api.corp":             true,
+		"api.openai.com":       false,
+	}
+	for host, want := range tests {
+		if got := bypassProxy(host, noProxy); got != want {
+			t.Errorf("bypassProxy(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestNetworkMerge(t *testing.T) {
+	global := config.NetworkConfig{Proxy: "http://proxy:3128", CACert: "/etc/ca.pem"}
+	backend := config.NetworkConfig{ClientCert: "c.pem", ClientKey: "k.pem"}
+
+	merged := backend.Merge(global)
+	if merged.Proxy != global.Proxy || merged.CACert != global.CACert {
+		t.Errorf("expected global proxy/CA to be inherited, got %+v", merged)
+	}
+	if merged.ClientCert != "c.pem" {
+		t.Errorf("expected backend client cert to win, got %+v", merged)
+	}
+}
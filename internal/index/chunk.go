@@ -0,0 +1,120 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// chunkLines is the size of a chunk, overlapping the previous one by
+	// chunkOverlap lines so code that straddles a boundary is found whole
+	chunkLines   = 60
+	chunkOverlap = 10
+	// maxChunkBytes keeps a chunk within embedding models' input limits
+	maxChunkBytes = 6000
+	// maxFileBytes skips generated and data files too large to be useful
+	maxFileBytes = 256 * 1024
+)
+
+// skipDirs are never indexed when the project is not a git repository
+var skipDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true,
+	"target": true, "_build": true, "deps": true, "__pycache__": true, ".venv": true,
+}
+
+// skipFiles are lock files and other generated text with nothing to find
+var skipFiles = map[string]bool{
+	"go.sum": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"Cargo.lock": true, "mix.lock": true, "Gemfile.lock": true, "poetry.lock": true,
+}
+
+// chunk is a span of lines of a file, 1-based and inclusive
+type chunk struct {
+	Start, End int
+	Text       string
+}
+
+// listFiles returns the files to index under root, relative to it: the
+// files git tracks or would track, or every file outside skipDirs when
+// root is not in a git repository.
+func listFiles(root string) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+	cmd.Dir = root
+	var files []string
+	if out, err := cmd.Output(); err == nil {
+		for _, f := range strings.Split(string(out), "\x00") {
+			if f != "" && indexable(f) {
+				files = append(files, filepath.FromSlash(f))
+			}
+		}
+		return files, nil
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && (skipDirs[d.Name()] || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if d.Type().IsRegular() && indexable(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func indexable(path string) bool {
+	base := filepath.Base(path)
+	return !skipFiles[base] && !strings.Contains(base, ".min.")
+}
+
+// readText returns the file's content, or false for binary and oversized
+// files.
+func readText(path string) ([]byte, bool) {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxFileBytes || info.Size() == 0 {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	return data, !bytes.Contains(head, []byte{0})
+}
+
+// chunkFile splits content into overlapping chunks of lines. Each chunk's
+// text starts with the path, so the file name counts towards a match.
+func chunkFile(path string, content []byte) []chunk {
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	var chunks []chunk
+	for start := 0; start < len(lines); start += chunkLines - chunkOverlap {
+		end := min(start+chunkLines, len(lines))
+		body := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(body) != "" {
+			text := fmt.Sprintf("%s:%d-%d\n%s", filepath.ToSlash(path), start+1, end, body)
+			if len(text) > maxChunkBytes {
+				text = strings.ToValidUTF8(text[:maxChunkBytes], "")
+			}
+			chunks = append(chunks, chunk{Start: start + 1, End: end, Text: text})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
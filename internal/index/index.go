@@ -0,0 +1,410 @@
+// Package index keeps a semantic index of a project: its files split into
+// chunks of lines, each with an embedding vector, searched by similarity
+// to a natural language query.
+package index
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gptcode/internal/config"
+	"gptcode/internal/llm"
+)
+
+// ErrNoIndex is returned by Load when the project has not been indexed
+var ErrNoIndex = errors.New("no semantic index")
+
+// batchSize is how many chunks are embedded per request
+const batchSize = 32
+
+// Index is the semantic index of the project at Root. Vectors are stored
+// normalized, so similarity is a dot product.
+type Index struct {
+	Root  string
+	Model string
+	// Dimensions is the length of the vectors, 0 in indexes built before
+	// it was recorded
+	Dimensions int
+	Updated    time.Time
+	Files      map[string]*File
+}
+
+// File is an indexed file and the content hash its chunks were made from
+type File struct {
+	Hash   string
+	Chunks []Chunk
+}
+
+// Chunk is an indexed span of lines, 1-based and inclusive
+type Chunk struct {
+	Start, End int
+	Vector     []float32
+}
+
+// Result is a chunk matching a query
+type Result struct {
+	Path  string  `json:"path"`
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+	Score float64 `json:"score"`
+	Text  string  `json:"text,omitempty"`
+}
+
+// Stats summarizes an update
+type Stats struct {
+	Files  int // files in the index
+	Chunks int // chunks in the index
+	// Embedded files were new or changed, Fresh files unchanged and
+	// Removed files deleted since the last update
+	Embedded, Fresh, Removed int
+	EmbeddedChunks           int
+}
+
+// Path is where the index of root is stored: ~/.gptcode/cache/index_<hash>.gob
+func Path(root string) string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "cache", fmt.Sprintf("index_%x.gob", md5.Sum([]byte(root))))
+}
+
+// New returns an empty index of root.
+func New(root string) *Index {
+	return &Index{Root: root, Files: map[string]*File{}}
+}
+
+// Load reads the index of root, returning ErrNoIndex when there is none.
+func Load(root string) (*Index, error) {
+	f, err := os.Open(Path(root))
+	if os.IsNotExist(err) {
+		return nil, ErrNoIndex
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ix Index
+	if err := gob.NewDecoder(f).Decode(&ix); err != nil {
+		return nil, fmt.Errorf("corrupt semantic index %s: %w", Path(root), err)
+	}
+	if ix.Files == nil {
+		ix.Files = map[string]*File{}
+	}
+	return &ix, nil
+}
+
+// Open loads the index of root with the embedder configured in setup, for
+// searching an existing index. It fails with ErrNoIndex when root has not
+// been indexed, and when the index was built with another model or
+// dimensions.
+func Open(root string, setup *config.Setup) (*Index, llm.Embedder, error) {
+	ix, err := Load(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	e, err := llm.NewEmbedder(setup)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ix.compatible(e); err != nil {
+		return nil, nil, err
+	}
+	return ix, e, nil
+}
+
+// compatible fails when the index's vectors cannot be compared with e's
+func (ix *Index) compatible(e llm.Embedder) error {
+	if e.Model() != ix.Model {
+		return fmt.Errorf("the index was built with %s, not %s; rebuild it with: gptcode index --rebuild", ix.Model, e.Model())
+	}
+	if d := e.Dimensions(); d > 0 && ix.Dimensions > 0 && d != ix.Dimensions {
+		return fmt.Errorf("the index has %d dimensions, not %d; rebuild it with: gptcode index --rebuild", ix.Dimensions, d)
+	}
+	return nil
+}
+
+// Save writes the index.
+func (ix *Index) Save() error {
+	path := Path(ix.Root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(ix); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Clear deletes the index of root.
+func Clear(root string) error {
+	err := os.Remove(Path(root))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Chunks returns the number of indexed chunks.
+func (ix *Index) Chunks() int {
+	n := 0
+	for _, f := range ix.Files {
+		n += len(f.Chunks)
+	}
+	return n
+}
+
+// Update brings the index up to date with the files under Root, embedding
+// only new and changed files. A different embedding model or dimensions
+// rebuilds the whole index. progress, if set, is called after each batch with the
+// chunks embedded so far and the total to embed. The index is saved as it
+// goes, so an interrupted update resumes where it stopped.
+func (ix *Index) Update(ctx context.Context, e llm.Embedder, progress func(done, total int)) (Stats, error) {
+	var stats Stats
+	if ix.compatible(e) != nil {
+		ix.Model = e.Model()
+		ix.Dimensions = 0
+		ix.Files = map[string]*File{}
+	}
+	paths, err := listFiles(ix.Root)
+	if err != nil {
+		return stats, err
+	}
+
+	type pending struct {
+		path   string
+		hash   string
+		chunks []chunk
+	}
+	var todo []pending
+	seen := map[string]bool{}
+	total := 0
+	for _, path := range paths {
+		content, ok := readText(filepath.Join(ix.Root, path))
+		if !ok {
+			continue
+		}
+		seen[path] = true
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:12])
+		if f, ok := ix.Files[path]; ok && f.Hash == hash {
+			stats.Fresh++
+			continue
+		}
+		chunks := chunkFile(path, content)
+		todo = append(todo, pending{path, hash, chunks})
+		total += len(chunks)
+	}
+	for path := range ix.Files {
+		if !seen[path] {
+			delete(ix.Files, path)
+			stats.Removed++
+		}
+	}
+
+	// Files are embedded in batches of chunks and committed to the index
+	// once all of their chunks are in
+	var batch []string
+	var vectors [][]float32
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		out, err := e.Embed(ctx, batch)
+		if err != nil {
+			return err
+		}
+		for _, v := range out {
+			vectors = append(vectors, normalize(v))
+		}
+		if len(out) > 0 {
+			ix.Dimensions = len(out[0])
+		}
+		stats.EmbeddedChunks += len(batch)
+		batch = batch[:0]
+		if progress != nil {
+			progress(stats.EmbeddedChunks, total)
+		}
+		return nil
+	}
+	commit := func(p pending) {
+		f := &File{Hash: p.hash}
+		for i, c := range p.chunks {
+			f.Chunks = append(f.Chunks, Chunk{Start: c.Start, End: c.End, Vector: vectors[i]})
+		}
+		vectors = vectors[len(p.chunks):]
+		ix.Files[p.path] = f
+		stats.Embedded++
+	}
+
+	var queued []pending
+	sinceSave := 0
+	for _, p := range todo {
+		for _, c := range p.chunks {
+			batch = append(batch, c.Text)
+			if len(batch) == batchSize {
+				if err := flush(); err != nil {
+					return ix.finish(stats, err)
+				}
+				sinceSave++
+			}
+		}
+		queued = append(queued, p)
+		for len(queued) > 0 && len(vectors) >= len(queued[0].chunks) {
+			commit(queued[0])
+			queued = queued[1:]
+		}
+		if sinceSave >= 20 {
+			if err := ix.Save(); err != nil {
+				return stats, err
+			}
+			sinceSave = 0
+		}
+	}
+	if err := flush(); err != nil {
+		return ix.finish(stats, err)
+	}
+	for _, p := range queued {
+		commit(p)
+	}
+	return ix.finish(stats, nil)
+}
+
+// finish saves the index after an update, keeping the update's error
+func (ix *Index) finish(stats Stats, err error) (Stats, error) {
+	ix.Updated = time.Now()
+	stats.Files = len(ix.Files)
+	stats.Chunks = ix.Chunks()
+	if saveErr := ix.Save(); err == nil {
+		err = saveErr
+	}
+	return stats, err
+}
+
+// Search returns the limit chunks most similar to query, best first, with
+// their current text.
+func (ix *Index) Search(ctx context.Context, e llm.Embedder, query string, limit int) ([]Result, error) {
+	results, err := ix.search(ctx, e, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].Text = ix.snippet(results[i])
+	}
+	return results, nil
+}
+
+func (ix *Index) search(ctx context.Context, e llm.Embedder, query string, limit int) ([]Result, error) {
+	if err := ix.compatible(e); err != nil {
+		return nil, err
+	}
+	out, err := e.Embed(llm.WithQueryEmbedding(ctx), []string{query})
+	if err != nil {
+		return nil, err
+	}
+	q := normalize(out[0])
+
+	var results []Result
+	for path, f := range ix.Files {
+		for _, c := range f.Chunks {
+			if len(c.Vector) != len(q) {
+				continue
+			}
+			results = append(results, Result{Path: path, Start: c.Start, End: c.End, Score: dot(q, c.Vector)})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Path < results[j].Path
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// snippet reads the result's lines from the file as it is now
+func (ix *Index) snippet(r Result) string {
+	data, err := os.ReadFile(filepath.Join(ix.Root, r.Path))
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(data), "\n")
+	start, end := min(r.Start-1, len(lines)), min(r.End, len(lines))
+	return strings.Join(lines[start:end], "\n")
+}
+
+// similarityChunks is how many of the best chunks the embedding signal
+// draws files from
+const similarityChunks = 40
+
+// Similarity scores files by their best chunk's similarity to the query,
+// scaled so the best file scores 1, for the context ranker's embedding
+// signal. Only files among the closest chunks are scored.
+func (ix *Index) Similarity(e llm.Embedder) func(query string, paths []string) (map[string]float64, error) {
+	return func(query string, paths []string) (map[string]float64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		results, err := ix.search(ctx, e, query, similarityChunks)
+		if err != nil || len(results) == 0 {
+			return nil, err
+		}
+		known := make(map[string]bool, len(paths))
+		for _, p := range paths {
+			known[p] = true
+		}
+		best := results[0].Score
+		scores := map[string]float64{}
+		for _, r := range results {
+			if !known[r.Path] || r.Score <= 0 || best <= 0 {
+				continue
+			}
+			scores[r.Path] = math.Max(scores[r.Path], r.Score/best)
+		}
+		return scores, nil
+	}
+}
+
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	n := float32(1 / math.Sqrt(sum))
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = x * n
+	}
+	return out
+}
+
+func dot(a, b []float32) float64 {
+	var s float64
+	for i := range a {
+		s += float64(a[i]) * float64(b[i])
+	}
+	return s
+}
@@ -0,0 +1,141 @@
+package index
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// wordEmbedder embeds texts as bags of hashed words, so texts sharing
+// words are similar
+type wordEmbedder struct {
+	model string
+	calls int
+}
+
+func (w *wordEmbedder) Model() string { return w.model }
+
+func (w *wordEmbedder) Dimensions() int { return 64 }
+
+func (w *wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	w.calls += len(texts)
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v := make([]float32, 64)
+		for _, word := range strings.FieldsFunc(strings.ToLower(t), func(r rune) bool {
+			return !('a' <= r && r <= 'z')
+		}) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			v[h.Sum32()%64]++
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChunkFile(t *testing.T) {
+	var lines []string
+	for i := 0; i < 130; i++ {
+		lines = append(lines, "line")
+	}
+	chunks := chunkFile("a.go", []byte(strings.Join(lines, "\n")+"\n"))
+	if len(chunks) != 3 || chunks[0].Start != 1 || chunks[0].End != 60 || chunks[1].Start != 51 || chunks[2].End != 130 {
+		t.Errorf("unexpected chunks %+v", chunks)
+	}
+	if !strings.HasPrefix(chunks[1].Text, "a.go:51-110\n") {
+		t.Errorf("chunk text should start with its location, got %q", chunks[1].Text[:20])
+	}
+}
+
+func TestUpdateAndSearch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"auth/token.go":     "package auth\n\n// RefreshToken renews an expired access token\nfunc RefreshToken() {}\n",
+		"billing/tax.go":    "package billing\n\n// Tax computes the invoice tax\nfunc Tax() {}\n",
+		"node_modules/x.js": "refresh token",
+		"logo.png":          "\x89PNG\x00\x00",
+	})
+	ctx := context.Background()
+	e := &wordEmbedder{model: "words"}
+
+	ix := New(root)
+	stats, err := ix.Update(ctx, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 2 || stats.Embedded != 2 {
+		t.Fatalf("expected two indexed files, got %+v", stats)
+	}
+
+	results, err := ix.Search(ctx, e, "where is the access token refreshed", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Path != filepath.Join("auth", "token.go") || !strings.Contains(results[0].Text, "RefreshToken") {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	// Only changed files are embedded again, and deleted files dropped
+	ix, err = Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, root, map[string]string{"billing/tax.go": "package billing\n\nfunc Tax(rate float64) {}\n"})
+	os.Remove(filepath.Join(root, "auth", "token.go"))
+	e.calls = 0
+	stats, err = ix.Update(ctx, e, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Embedded != 1 || stats.Removed != 1 || e.calls != 1 {
+		t.Errorf("expected one file embedded and one removed, got %+v after %d embeddings", stats, e.calls)
+	}
+
+	// Another model rebuilds the index
+	other := &wordEmbedder{model: "other"}
+	if _, err := ix.Search(ctx, other, "tax", 1); err == nil {
+		t.Error("searching with another model should fail")
+	}
+	if stats, _ := ix.Update(ctx, other, nil); stats.Fresh != 0 || ix.Model != "other" {
+		t.Errorf("expected a rebuild, got %+v", stats)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"a.go": "parse the config file",
+		"b.go": "send the email",
+	})
+	e := &wordEmbedder{model: "words"}
+	ix := New(root)
+	if _, err := ix.Update(context.Background(), e, nil); err != nil {
+		t.Fatal(err)
+	}
+	scores, err := ix.Similarity(e)("parse the config", []string{"a.go", "b.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores["a.go"] != 1 || scores["b.go"] >= 1 {
+		t.Errorf("expected a.go to score best, got %v", scores)
+	}
+}
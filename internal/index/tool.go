@@ -0,0 +1,82 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/tools"
+)
+
+// ToolName is the agents' semantic search tool
+const ToolName = "semantic_search"
+
+// snippetLines is how much of each match the tool shows
+const snippetLines = 15
+
+// Tool returns the semantic search tool over ix, for registering with
+// tools.RegisterExternalTool. Results are file spans with the start of
+// their code, so the agent reads only the files that matter.
+func Tool(ix *Index, e llm.Embedder) tools.ExternalTool {
+	return tools.ExternalTool{
+		Name: ToolName,
+		Description: "Find code by meaning rather than keywords, e.g. 'where are auth tokens refreshed'. " +
+			"Returns the best matching file spans with a preview. Use it to locate code before reading files.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "What the code does, in plain words",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum results (default: 5)",
+				},
+			},
+			"required": []string{"query"},
+		},
+		Call: func(args map[string]interface{}) (string, error) {
+			query, _ := args["query"].(string)
+			if strings.TrimSpace(query) == "" {
+				return "", fmt.Errorf("query parameter required")
+			}
+			limit := 5
+			if l, ok := args["limit"].(float64); ok && l > 0 {
+				limit = int(l)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+			results, err := ix.Search(ctx, e, query, limit)
+			if err != nil {
+				return "", err
+			}
+			return FormatResults(results, snippetLines), nil
+		},
+	}
+}
+
+// FormatResults renders results as path:start-end headers followed by up
+// to lines lines of each match.
+func FormatResults(results []Result, lines int) string {
+	if len(results) == 0 {
+		return "No matches."
+	}
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%s:%d-%d (score %.2f)\n", r.Path, r.Start, r.End, r.Score)
+		text := strings.Split(r.Text, "\n")
+		if len(text) > lines {
+			text = append(text[:lines], "...")
+		}
+		for _, line := range text {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	return b.String()
+}
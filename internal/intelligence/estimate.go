@@ -0,0 +1,261 @@
+package intelligence
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SimilarTask aggregates past executions of one task that resemble the one
+// being estimated.
+type SimilarTask struct {
+	Task       string
+	Similarity float64
+	Attempts   int
+	Succeeded  bool
+	DurationMs int64 // total latency of successful attempts
+}
+
+// Estimate is an effort estimate for a task, combining the complexity
+// classifier, similar past tasks and the files likely to change.
+type Estimate struct {
+	Complexity       string
+	Confidence       float64
+	Size             string // S, M, L or XL
+	ExpectedAttempts float64
+	ExpectedMinutes  float64
+	SuccessRate      float64
+	Similar          []SimilarTask
+	ImpactFiles      []string
+	Risks            []string
+}
+
+// LoadHistory reads all recorded task executions.
+func LoadHistory() ([]TaskExecution, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	historyPath := filepath.Join(home, ".gptcode", "task_execution_history.jsonl")
+	data, err := os.ReadFile(historyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []TaskExecution{}, nil
+		}
+		return nil, err
+	}
+
+	var history []TaskExecution
+	for _, line := range splitLines(data) {
+		var exec TaskExecution
+		if err := json.Unmarshal(line, &exec); err != nil {
+			continue
+		}
+		history = append(history, exec)
+	}
+	return history, nil
+}
+
+// FindSimilarTasks groups history by task and returns the tasks whose word
+// overlap with task is at least minSimilarity, most similar first.
+func FindSimilarTasks(history []TaskExecution, task string, minSimilarity float64, limit int) []SimilarTask {
+	query := taskWords(task)
+	if len(query) == 0 {
+		return nil
+	}
+
+	byTask := make(map[string]*SimilarTask)
+	var order []string
+	for _, exec := range history {
+		s, ok := byTask[exec.Task]
+		if !ok {
+			sim := jaccard(query, taskWords(exec.Task))
+			if sim < minSimilarity {
+				continue
+			}
+			s = &SimilarTask{Task: exec.Task, Similarity: sim}
+			byTask[exec.Task] = s
+			order = append(order, exec.Task)
+		}
+		s.Attempts++
+		if exec.Success {
+			s.Succeeded = true
+			s.DurationMs += exec.LatencyMs
+		}
+	}
+
+	similar := make([]SimilarTask, 0, len(order))
+	for _, t := range order {
+		similar = append(similar, *byTask[t])
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].Similarity > similar[j].Similarity
+	})
+	if limit > 0 && len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar
+}
+
+// baseline attempts and minutes per complexity class, used when there is no
+// comparable history.
+var complexityBaseline = map[string]struct {
+	attempts float64
+	minutes  float64
+}{
+	"simple":    {1.0, 5},
+	"complex":   {1.5, 20},
+	"multistep": {2.0, 45},
+}
+
+// BuildEstimate combines the classifier output, similar tasks and impacted
+// files into an estimate with risk notes.
+func BuildEstimate(complexity string, confidence float64, similar []SimilarTask, impactFiles []string) *Estimate {
+	est := &Estimate{
+		Complexity:  complexity,
+		Confidence:  confidence,
+		Similar:     similar,
+		ImpactFiles: impactFiles,
+	}
+
+	base, ok := complexityBaseline[complexity]
+	if !ok {
+		base = complexityBaseline["complex"]
+	}
+	est.ExpectedAttempts = base.attempts
+	est.ExpectedMinutes = base.minutes
+
+	if len(similar) > 0 {
+		var attempts, successes, minutes float64
+		var timed int
+		for _, s := range similar {
+			attempts += float64(s.Attempts)
+			if s.Succeeded {
+				successes++
+				if s.DurationMs > 0 {
+					minutes += float64(s.DurationMs) / float64(time.Minute/time.Millisecond)
+					timed++
+				}
+			}
+		}
+		n := float64(len(similar))
+		est.SuccessRate = successes / n
+		// Weight history against the baseline by how much of it there is
+		w := math.Min(n/5, 0.8)
+		est.ExpectedAttempts = w*(attempts/n) + (1-w)*base.attempts
+		if timed > 0 {
+			est.ExpectedMinutes = w*(minutes/float64(timed)) + (1-w)*base.minutes
+		}
+	}
+
+	// More files touched means more to review and more room for breakage
+	est.ExpectedMinutes *= 1 + 0.1*math.Max(0, float64(len(impactFiles)-2))
+
+	switch {
+	case est.ExpectedMinutes < 10:
+		est.Size = "S"
+	case est.ExpectedMinutes < 30:
+		est.Size = "M"
+	case est.ExpectedMinutes < 90:
+		est.Size = "L"
+	default:
+		est.Size = "XL"
+	}
+
+	est.Risks = estimateRisks(est)
+	return est
+}
+
+func estimateRisks(est *Estimate) []string {
+	var risks []string
+	if len(est.Similar) == 0 {
+		risks = append(risks, "No comparable past tasks; estimate is based on the complexity class only.")
+	} else if est.SuccessRate < 0.5 {
+		risks = append(risks, fmt.Sprintf("Similar tasks succeeded only %.0f%% of the time.", est.SuccessRate*100))
+	}
+	if est.Confidence > 0 && est.Confidence < 0.5 {
+		risks = append(risks, fmt.Sprintf("Complexity classifier is unsure (%.0f%% confidence).", est.Confidence*100))
+	}
+	if est.Complexity == "multistep" {
+		risks = append(risks, "Multi-step task; consider splitting it into smaller issues.")
+	}
+	if len(est.ImpactFiles) > 5 {
+		risks = append(risks, fmt.Sprintf("Touches %d files; expect a broad diff.", len(est.ImpactFiles)))
+	}
+	if len(est.ImpactFiles) > 0 && !hasTestFile(est.ImpactFiles) {
+		risks = append(risks, "No test files among the impacted files; changes may be unverified.")
+	}
+	return risks
+}
+
+// Markdown renders the estimate as an issue comment.
+func (e *Estimate) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### Effort estimate: %s\n\n", e.Size))
+	sb.WriteString(fmt.Sprintf("- Complexity: **%s**", e.Complexity))
+	if e.Confidence > 0 {
+		sb.WriteString(fmt.Sprintf(" (%.0f%% confidence)", e.Confidence*100))
+	}
+	sb.WriteString("\n")
+	sb.WriteString(fmt.Sprintf("- Expected agent attempts: %.1f\n", e.ExpectedAttempts))
+	sb.WriteString(fmt.Sprintf("- Expected agent time: ~%.0f min\n", e.ExpectedMinutes))
+	if len(e.Similar) > 0 {
+		sb.WriteString(fmt.Sprintf("- Similar past tasks: %d (%.0f%% succeeded)\n", len(e.Similar), e.SuccessRate*100))
+	}
+
+	if len(e.ImpactFiles) > 0 {
+		sb.WriteString("\n**Likely impacted files**\n\n")
+		for _, f := range e.ImpactFiles {
+			sb.WriteString(fmt.Sprintf("- `%s`\n", f))
+		}
+	}
+	if len(e.Risks) > 0 {
+		sb.WriteString("\n**Risks**\n\n")
+		for _, r := range e.Risks {
+			sb.WriteString("- " + r + "\n")
+		}
+	}
+	return sb.String()
+}
+
+func hasTestFile(files []string) bool {
+	for _, f := range files {
+		base := strings.ToLower(filepath.Base(f))
+		if strings.Contains(base, "_test.") || strings.Contains(base, ".test.") ||
+			strings.Contains(base, ".spec.") || strings.HasPrefix(base, "test_") {
+			return true
+		}
+	}
+	return false
+}
+
+func taskWords(task string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(task), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}) {
+		if len(w) > 2 {
+			words[w] = true
+		}
+	}
+	return words
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range a {
+		if b[w] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
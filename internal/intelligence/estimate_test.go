@@ -0,0 +1,60 @@
+package intelligence
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindSimilarTasks(t *testing.T) {
+	history := []TaskExecution{
+		{Task: "add retry to http client", Success: false},
+		{Task: "add retry to http client", Success: true, LatencyMs: 120000},
+		{Task: "fix login redirect", Success: true, LatencyMs: 60000},
+		{Task: "add retry with backoff to http client", Success: true, LatencyMs: 300000},
+	}
+
+	similar := FindSimilarTasks(history, "Add retry to the HTTP client", 0.3, 5)
+	if len(similar) != 2 {
+		t.Fatalf("found %d similar tasks, want 2: %+v", len(similar), similar)
+	}
+	if similar[0].Task != "add retry to http client" || similar[0].Attempts != 2 || !similar[0].Succeeded {
+		t.Errorf("unexpected top match: %+v", similar[0])
+	}
+}
+
+func TestBuildEstimate(t *testing.T) {
+	est := BuildEstimate("simple", 0.9, nil, []string{"auth/login.go"})
+	if est.Size != "S" {
+		t.Errorf("size = %s, want S", est.Size)
+	}
+	if !containsRisk(est.Risks, "No comparable past tasks") || !containsRisk(est.Risks, "No test files") {
+		t.Errorf("missing expected risks: %v", est.Risks)
+	}
+
+	similar := []SimilarTask{
+		{Task: "a", Attempts: 3, Succeeded: false},
+		{Task: "b", Attempts: 3, Succeeded: false},
+		{Task: "c", Attempts: 2, Succeeded: true, DurationMs: 60 * 60 * 1000},
+	}
+	est = BuildEstimate("multistep", 0.4, similar, []string{"a.go", "a_test.go"})
+	if est.ExpectedAttempts <= complexityBaseline["multistep"].attempts {
+		t.Errorf("history with many attempts should raise expected attempts, got %.2f", est.ExpectedAttempts)
+	}
+	for _, want := range []string{"succeeded only 33%", "unsure", "Multi-step"} {
+		if !containsRisk(est.Risks, want) {
+			t.Errorf("missing risk %q in %v", want, est.Risks)
+		}
+	}
+	if !strings.Contains(est.Markdown(), "Effort estimate: "+est.Size) {
+		t.Errorf("markdown missing size:\n%s", est.Markdown())
+	}
+}
+
+func containsRisk(risks []string, substr string) bool {
+	for _, r := range risks {
+		if strings.Contains(r, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -51,29 +51,11 @@ type ModelSuccess struct {
 }
 
 func GetRecentModelPerformance(taskType string, limit int) ([]ModelSuccess, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return nil, err
-	}
-
-	historyPath := filepath.Join(home, ".gptcode", "task_execution_history.jsonl")
-	data, err := os.ReadFile(historyPath)
+	lines, err := LoadHistory()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []ModelSuccess{}, nil
-		}
 		return nil, err
 	}
 
-	lines := make([]TaskExecution, 0)
-	for _, line := range splitLines(data) {
-		var exec TaskExecution
-		if err := json.Unmarshal(line, &exec); err != nil {
-			continue
-		}
-		lines = append(lines, exec)
-	}
-
 	if len(lines) > limit {
 		lines = lines[len(lines)-limit:]
 	}
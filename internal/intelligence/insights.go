@@ -0,0 +1,417 @@
+package intelligence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gptcode/internal/feedback"
+	"gptcode/internal/observability"
+)
+
+// ProjectKey identifies the project a run happened in: the git top level of
+// dir, or dir itself outside a repository. Task history and feedback are
+// tagged with it so insights can be scoped to one project. Linked worktrees,
+// such as --sandbox runs, count as their main checkout.
+func ProjectKey(dir string) string {
+	if out, err := exec.Command("git", "-C", dir, "rev-parse", "--git-common-dir").Output(); err == nil {
+		common := strings.TrimSpace(string(out))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(dir, common)
+		}
+		if filepath.Base(common) == ".git" {
+			if abs, err := filepath.Abs(filepath.Dir(common)); err == nil {
+				if root, err := filepath.EvalSymlinks(abs); err == nil {
+					return root
+				}
+			}
+		}
+	}
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err == nil {
+		if root := strings.TrimSpace(string(out)); root != "" {
+			return root
+		}
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// KindStats is the outcome of one kind of task (feature, fix, refactor...).
+type KindStats struct {
+	Kind   string
+	Total  int
+	Failed int
+}
+
+// FileStats counts the retries a file was involved in.
+type FileStats struct {
+	Path    string
+	Retries int
+}
+
+// ModelStats is a model's record on this project's tasks.
+type ModelStats struct {
+	Backend      string
+	Model        string
+	Total        int
+	Succeeded    int
+	AvgLatencyMs int64
+}
+
+// SuccessRate is the fraction of tasks the model completed.
+func (m ModelStats) SuccessRate() float64 {
+	if m.Total == 0 {
+		return 0
+	}
+	return float64(m.Succeeded) / float64(m.Total)
+}
+
+// ReasonStats counts a recorded failure reason.
+type ReasonStats struct {
+	Reason string
+	Count  int
+}
+
+// Insights are patterns learned from a project's task history, feedback and
+// traces, with configuration recommendations.
+type Insights struct {
+	Project string
+	// Unscoped is set when nothing was tagged with the project yet and the
+	// report covers every recorded run instead.
+	Unscoped        bool
+	Tasks           int
+	Failed          int
+	Kinds           []KindStats
+	RetryFiles      []FileStats
+	Models          []ModelStats
+	FailureReasons  []ReasonStats
+	RateLimited     int // failed tasks that hit provider rate limits
+	Recommendations []string
+}
+
+// InsightsOptions describes the current configuration, so recommendations
+// can say what to change.
+type InsightsOptions struct {
+	Backend string
+	Profile string
+	Model   string // current editor model
+	// MinSamples is how many tasks a model or kind needs before it is
+	// compared; 3 when zero.
+	MinSamples int
+}
+
+// LoadProjectTraces reads the trace_*.json session traces written in dir.
+func LoadProjectTraces(dir string) ([]observability.SessionTrace, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "trace_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var traces []observability.SessionTrace
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var trace observability.SessionTrace
+		if err := json.Unmarshal(data, &trace); err == nil {
+			traces = append(traces, trace)
+		}
+	}
+	return traces, nil
+}
+
+// BuildInsights analyzes the runs recorded for project.
+func BuildInsights(project string, history []TaskExecution, events []feedback.Event, traces []observability.SessionTrace, opts InsightsOptions) *Insights {
+	if opts.MinSamples <= 0 {
+		opts.MinSamples = 3
+	}
+	ins := &Insights{Project: project}
+
+	history, events, ins.Unscoped = scopeToProject(project, history, events)
+
+	kinds := map[string]*KindStats{}
+	models := map[string]*ModelStats{}
+	latency := map[string]int64{}
+	for _, exec := range history {
+		ins.Tasks++
+		kind := taskKind(exec.Task)
+		ks, ok := kinds[kind]
+		if !ok {
+			ks = &KindStats{Kind: kind}
+			kinds[kind] = ks
+		}
+		ks.Total++
+
+		key := exec.Backend + "/" + exec.Model
+		ms, ok := models[key]
+		if !ok {
+			ms = &ModelStats{Backend: exec.Backend, Model: exec.Model}
+			models[key] = ms
+		}
+		ms.Total++
+		if exec.Success {
+			ms.Succeeded++
+			latency[key] += exec.LatencyMs
+		} else {
+			ins.Failed++
+			ks.Failed++
+			lower := strings.ToLower(exec.Error)
+			if strings.Contains(lower, "rate limit") || strings.Contains(lower, "429") {
+				ins.RateLimited++
+			}
+		}
+	}
+	for _, ks := range kinds {
+		ins.Kinds = append(ins.Kinds, *ks)
+	}
+	sort.Slice(ins.Kinds, func(i, j int) bool {
+		a, b := ins.Kinds[i], ins.Kinds[j]
+		if a.Failed != b.Failed {
+			return a.Failed > b.Failed
+		}
+		return a.Kind < b.Kind
+	})
+	for key, ms := range models {
+		if ms.Succeeded > 0 {
+			ms.AvgLatencyMs = latency[key] / int64(ms.Succeeded)
+		}
+		ins.Models = append(ins.Models, *ms)
+	}
+	sort.Slice(ins.Models, func(i, j int) bool {
+		a, b := ins.Models[i], ins.Models[j]
+		if a.SuccessRate() != b.SuccessRate() {
+			return a.SuccessRate() > b.SuccessRate()
+		}
+		return a.Total > b.Total
+	})
+
+	// Each failed task records its reason for the editor and the reviewer;
+	// count it once. Details in parentheses vary per run.
+	reasons := map[string]int{}
+	for _, e := range events {
+		reason := e.Metadata["failure_reason"]
+		if e.Sentiment != feedback.SentimentBad || e.Agent != "editor" || reason == "" {
+			continue
+		}
+		if i := strings.Index(reason, " ("); i > 0 {
+			reason = reason[:i]
+		}
+		reasons[reason]++
+	}
+	for reason, n := range reasons {
+		ins.FailureReasons = append(ins.FailureReasons, ReasonStats{Reason: reason, Count: n})
+	}
+	sort.Slice(ins.FailureReasons, func(i, j int) bool {
+		a, b := ins.FailureReasons[i], ins.FailureReasons[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Reason < b.Reason
+	})
+
+	retries := map[string]int{}
+	for _, trace := range traces {
+		for _, step := range trace.Steps {
+			if step.Node != "RecoverySystem" {
+				continue
+			}
+			for _, f := range stepFiles(step) {
+				retries[f]++
+			}
+		}
+	}
+	for path, n := range retries {
+		ins.RetryFiles = append(ins.RetryFiles, FileStats{Path: path, Retries: n})
+	}
+	sort.Slice(ins.RetryFiles, func(i, j int) bool {
+		a, b := ins.RetryFiles[i], ins.RetryFiles[j]
+		if a.Retries != b.Retries {
+			return a.Retries > b.Retries
+		}
+		return a.Path < b.Path
+	})
+
+	ins.Recommendations = recommend(ins, opts)
+	return ins
+}
+
+// scopeToProject keeps the records tagged with project. Older records carry
+// no tag; when none are tagged with project, every record is kept.
+func scopeToProject(project string, history []TaskExecution, events []feedback.Event) ([]TaskExecution, []feedback.Event, bool) {
+	var scopedHistory []TaskExecution
+	for _, exec := range history {
+		if p, _ := exec.Features["project"].(string); p == project {
+			scopedHistory = append(scopedHistory, exec)
+		}
+	}
+	var scopedEvents []feedback.Event
+	for _, e := range events {
+		if e.Metadata["project"] == project {
+			scopedEvents = append(scopedEvents, e)
+		}
+	}
+	if len(scopedHistory) == 0 && len(scopedEvents) == 0 {
+		return history, events, true
+	}
+	return scopedHistory, scopedEvents, false
+}
+
+func stepFiles(step observability.StepTrace) []string {
+	raw, ok := step.Inputs["files"].([]interface{})
+	if !ok {
+		if files, ok := step.Inputs["files"].([]string); ok {
+			return files
+		}
+		return nil
+	}
+	var files []string
+	for _, f := range raw {
+		if s, ok := f.(string); ok && s != "" {
+			files = append(files, s)
+		}
+	}
+	return files
+}
+
+// taskKind buckets a task by its leading intent.
+func taskKind(task string) string {
+	words := taskWords(task)
+	kinds := []struct {
+		kind  string
+		words []string
+	}{
+		{"fix", []string{"fix", "bug", "broken", "crash", "error", "failing"}},
+		{"test", []string{"test", "tests", "coverage"}},
+		{"refactor", []string{"refactor", "rename", "move", "extract", "cleanup", "simplify"}},
+		{"docs", []string{"doc", "docs", "readme", "document", "comment", "comments"}},
+		{"feature", []string{"add", "create", "implement", "support", "new", "build"}},
+	}
+	for _, k := range kinds {
+		for _, w := range k.words {
+			if words[w] {
+				return k.kind
+			}
+		}
+	}
+	return "other"
+}
+
+func recommend(ins *Insights, opts InsightsOptions) []string {
+	var recs []string
+
+	var best, current *ModelStats
+	for i := range ins.Models {
+		m := &ins.Models[i]
+		if m.Total < opts.MinSamples {
+			continue
+		}
+		if best == nil {
+			best = m
+		}
+		if m.Model == opts.Model && (opts.Backend == "" || m.Backend == opts.Backend) {
+			current = m
+		}
+	}
+	if best != nil && best.Model != opts.Model {
+		if current == nil || best.SuccessRate()-current.SuccessRate() >= 0.2 {
+			profile := opts.Profile
+			if profile == "" {
+				profile = "default"
+			}
+			recs = append(recs, fmt.Sprintf("%s/%s succeeds on %.0f%% of this project's tasks; make it the editor: gptcode profile set-agent %s %s editor %s",
+				best.Backend, best.Model, best.SuccessRate()*100, best.Backend, profile, best.Model))
+		}
+	}
+
+	for _, k := range ins.Kinds {
+		if k.Total >= opts.MinSamples && float64(k.Failed)/float64(k.Total) >= 0.5 {
+			recs = append(recs, fmt.Sprintf("%d of %d %s tasks failed; split them into smaller steps or run them with gptcode do --supervised",
+				k.Failed, k.Total, k.kindLabel()))
+		}
+	}
+
+	for _, f := range ins.RetryFiles {
+		if f.Retries < opts.MinSamples {
+			break
+		}
+		recs = append(recs, fmt.Sprintf("%s was involved in %d retries; record its constraints in the project context: gptcode context add shared \"%s: ...\"",
+			f.Path, f.Retries, f.Path))
+	}
+
+	for _, r := range ins.FailureReasons {
+		if r.Count >= opts.MinSamples && r.Reason == "max_iterations_reached" {
+			recs = append(recs, fmt.Sprintf("%d tasks ran out of iterations; cap spend per task so runaway loops stop early: gptcode config set defaults.max_cost_per_task 0.50", r.Count))
+		}
+	}
+	if ins.RateLimited >= opts.MinSamples {
+		recs = append(recs, fmt.Sprintf("%d tasks failed on rate limits; enable budget mode to spread work across other models: gptcode budget enable", ins.RateLimited))
+	}
+	return recs
+}
+
+func (k KindStats) kindLabel() string {
+	if k.Kind == "other" {
+		return "uncategorized"
+	}
+	return k.Kind
+}
+
+// Markdown renders the insights report.
+func (ins *Insights) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Insights: %s\n\n", filepath.Base(ins.Project)))
+	if ins.Unscoped {
+		sb.WriteString("_No runs are tagged with this project yet; the report covers all recorded runs._\n\n")
+	}
+	if ins.Tasks > 0 {
+		sb.WriteString(fmt.Sprintf("%d tasks, %d failed (%.0f%%)\n", ins.Tasks, ins.Failed, float64(ins.Failed)/float64(ins.Tasks)*100))
+	} else {
+		sb.WriteString("No task history yet.\n")
+	}
+
+	if len(ins.Kinds) > 0 {
+		sb.WriteString("\n## Tasks that fail most\n\n")
+		for _, k := range ins.Kinds {
+			sb.WriteString(fmt.Sprintf("- %s: %d/%d failed\n", k.Kind, k.Failed, k.Total))
+		}
+	}
+	if len(ins.RetryFiles) > 0 {
+		sb.WriteString("\n## Files causing most retries\n\n")
+		for i, f := range ins.RetryFiles {
+			if i == 10 {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("- `%s`: %d retries\n", f.Path, f.Retries))
+		}
+	}
+	if len(ins.Models) > 0 {
+		sb.WriteString("\n## Models on this project\n\n")
+		for _, m := range ins.Models {
+			sb.WriteString(fmt.Sprintf("- %s/%s: %.0f%% of %d tasks", m.Backend, m.Model, m.SuccessRate()*100, m.Total))
+			if m.AvgLatencyMs > 0 {
+				sb.WriteString(fmt.Sprintf(", %.1fs avg", float64(m.AvgLatencyMs)/1000))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	if len(ins.FailureReasons) > 0 {
+		sb.WriteString("\n## Failure reasons\n\n")
+		for _, r := range ins.FailureReasons {
+			sb.WriteString(fmt.Sprintf("- %s (%d)\n", r.Reason, r.Count))
+		}
+	}
+	if len(ins.Recommendations) > 0 {
+		sb.WriteString("\n## Recommendations\n\n")
+		for _, r := range ins.Recommendations {
+			sb.WriteString("- " + r + "\n")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,69 @@
+package intelligence
+
+import (
+	"strings"
+	"testing"
+
+	"gptcode/internal/feedback"
+	"gptcode/internal/observability"
+)
+
+func TestBuildInsights(t *testing.T) {
+	here := map[string]interface{}{"project": "/src/app"}
+	other := map[string]interface{}{"project": "/src/other"}
+	history := []TaskExecution{
+		{Task: "fix login bug", Backend: "groq", Model: "small", Success: false, Features: here},
+		{Task: "fix crash on start", Backend: "groq", Model: "small", Success: false, Error: "429 rate limit", Features: here},
+		{Task: "fix typo error", Backend: "groq", Model: "small", Success: true, LatencyMs: 1000, Features: here},
+		{Task: "add export command", Backend: "openrouter", Model: "big", Success: true, LatencyMs: 3000, Features: here},
+		{Task: "implement search", Backend: "openrouter", Model: "big", Success: true, LatencyMs: 5000, Features: here},
+		{Task: "create config file", Backend: "openrouter", Model: "big", Success: true, LatencyMs: 4000, Features: here},
+		{Task: "fix everything", Backend: "groq", Model: "small", Success: false, Features: other},
+	}
+	events := []feedback.Event{
+		{Sentiment: feedback.SentimentBad, Agent: "editor", Metadata: map[string]string{"project": "/src/app", "failure_reason": "max_iterations_reached (10 iterations)"}},
+		{Sentiment: feedback.SentimentBad, Agent: "reviewer", Metadata: map[string]string{"project": "/src/app", "failure_reason": "max_iterations_reached (10 iterations)"}},
+	}
+	retry := observability.StepTrace{Node: "RecoverySystem", Inputs: map[string]interface{}{"files": []interface{}{"auth/login.go"}}}
+	traces := []observability.SessionTrace{{Steps: []observability.StepTrace{retry, retry, retry, {Node: "EditorAgent"}}}}
+
+	ins := BuildInsights("/src/app", history, events, traces, InsightsOptions{Backend: "groq", Model: "small"})
+
+	if ins.Unscoped || ins.Tasks != 6 || ins.Failed != 2 {
+		t.Fatalf("expected 6 project tasks with 2 failures, got %+v", ins)
+	}
+	if ins.Kinds[0].Kind != "fix" || ins.Kinds[0].Failed != 2 || ins.Kinds[0].Total != 3 {
+		t.Errorf("fix tasks should fail most, got %+v", ins.Kinds)
+	}
+	if ins.Models[0].Model != "big" || ins.Models[0].AvgLatencyMs != 4000 {
+		t.Errorf("big should rank first, got %+v", ins.Models)
+	}
+	if len(ins.RetryFiles) != 1 || ins.RetryFiles[0].Retries != 3 {
+		t.Errorf("unexpected retry files: %+v", ins.RetryFiles)
+	}
+	if len(ins.FailureReasons) != 1 || ins.FailureReasons[0].Reason != "max_iterations_reached" || ins.FailureReasons[0].Count != 1 {
+		t.Errorf("reasons should be counted once per task, got %+v", ins.FailureReasons)
+	}
+
+	recs := strings.Join(ins.Recommendations, "\n")
+	for _, want := range []string{
+		"gptcode profile set-agent openrouter default editor big",
+		"2 of 3 fix tasks failed",
+		"auth/login.go was involved in 3 retries",
+	} {
+		if !strings.Contains(recs, want) {
+			t.Errorf("recommendations missing %q:\n%s", want, recs)
+		}
+	}
+}
+
+func TestBuildInsightsUnscoped(t *testing.T) {
+	history := []TaskExecution{{Task: "add thing", Backend: "groq", Model: "m", Success: true}}
+	ins := BuildInsights("/src/app", history, nil, nil, InsightsOptions{})
+	if !ins.Unscoped || ins.Tasks != 1 {
+		t.Errorf("untagged history should be reported unscoped, got %+v", ins)
+	}
+	if !strings.Contains(ins.Markdown(), "covers all recorded runs") {
+		t.Errorf("markdown should note the report is unscoped:\n%s", ins.Markdown())
+	}
+}
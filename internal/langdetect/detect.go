@@ -14,43 +14,117 @@ const (
 	Go         Language = "go"
 	TypeScript Language = "typescript"
 	Python     Language = "python"
+	Rust       Language = "rust"
 	Unknown    Language = "unknown"
 )
 
+// Project is the code a directory belongs to
+type Project struct {
+	// Root is the nearest directory, up to the repository root, with a
+	// build manifest (go.mod, package.json, mix.exs...), so each
+	// subproject of a monorepo is detected on its own. Without one it is
+	// the repository root.
+	Root string
+	// RepoRoot is the root of the repository or worktree, or "" outside
+	// one
+	RepoRoot string
+	Language Language
+}
+
+// DetectLanguage returns the language of the project path is in.
 func DetectLanguage(path string) Language {
+	return Detect(path).Language
+}
+
+// Detect finds the project path is in, looking for a build manifest in
+// path and its parents up to the repository root. Without a manifest the
+// language is the most common one among the files under path.
+func Detect(path string) Project {
 	if path == "" {
 		path = "."
 	}
 
 	absPath, err := filepath.Abs(path)
 	if err != nil {
-		return Unknown
+		return Project{Language: Unknown}
+	}
+
+	repo := RepoRoot(absPath)
+	for dir := absPath; ; {
+		if lang := manifestLanguage(dir); lang != Unknown {
+			return Project{Root: dir, RepoRoot: repo, Language: lang}
+		}
+		// Outside a repository only path itself is looked at, so a stray
+		// manifest in the home directory does not count
+		parent := filepath.Dir(dir)
+		if repo == "" || dir == repo || parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	root := repo
+	if root == "" {
+		root = absPath
 	}
+	return Project{Root: root, RepoRoot: repo, Language: countLanguages(absPath)}
+}
 
-	if fileExists(filepath.Join(absPath, "mix.exs")) {
+// RepoRoot returns the nearest directory at or above path with a .git
+// entry, or "" when there is none. Linked worktrees and submodules have a
+// .git file rather than a directory, so each resolves to its own checkout.
+func RepoRoot(path string) string {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for {
+		if fileExists(filepath.Join(dir, ".git")) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// manifestLanguage returns the language of the build manifest in dir
+func manifestLanguage(dir string) Language {
+	if fileExists(filepath.Join(dir, "mix.exs")) {
 		return Elixir
 	}
 
-	if fileExists(filepath.Join(absPath, "Gemfile")) ||
-		fileExists(filepath.Join(absPath, "config", "application.rb")) {
+	if fileExists(filepath.Join(dir, "Gemfile")) ||
+		fileExists(filepath.Join(dir, "config", "application.rb")) {
 		return Ruby
 	}
 
-	if fileExists(filepath.Join(absPath, "go.mod")) {
+	if fileExists(filepath.Join(dir, "go.mod")) {
 		return Go
 	}
 
-	if fileExists(filepath.Join(absPath, "tsconfig.json")) ||
-		fileExists(filepath.Join(absPath, "package.json")) {
+	if fileExists(filepath.Join(dir, "tsconfig.json")) ||
+		fileExists(filepath.Join(dir, "package.json")) {
 		return TypeScript
 	}
 
-	if fileExists(filepath.Join(absPath, "requirements.txt")) ||
-		fileExists(filepath.Join(absPath, "setup.py")) ||
-		fileExists(filepath.Join(absPath, "pyproject.toml")) {
+	if fileExists(filepath.Join(dir, "requirements.txt")) ||
+		fileExists(filepath.Join(dir, "setup.py")) ||
+		fileExists(filepath.Join(dir, "pyproject.toml")) {
 		return Python
 	}
 
+	if fileExists(filepath.Join(dir, "Cargo.toml")) {
+		return Rust
+	}
+	return Unknown
+}
+
+// countLanguages returns the most common language among the files under
+// absPath
+func countLanguages(absPath string) Language {
 	langCounts := make(map[Language]int)
 
 	walkErr := filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
@@ -74,6 +148,8 @@ func DetectLanguage(path string) Language {
 			langCounts[TypeScript]++
 		case ".py":
 			langCounts[Python]++
+		case ".rs":
+			langCounts[Rust]++
 		}
 
 		return nil
@@ -109,6 +185,8 @@ func DetectFromFilename(filename string) Language {
 		return TypeScript
 	case ".py":
 		return Python
+	case ".rs":
+		return Rust
 	default:
 		return Unknown
 	}
@@ -93,6 +93,48 @@ func TestDetectLanguage(t *testing.T) {
 	}
 }
 
+func TestDetectMonorepo(t *testing.T) {
+	repo := t.TempDir()
+	for path, content := range map[string]string{
+		".git/HEAD":                     "ref: refs/heads/main\n",
+		"go.mod":                        "module example.com/tools",
+		"services/web/package.json":     "{}",
+		"services/web/src/app/index.ts": "export {}",
+		"services/api/mix.exs":          "defmodule Api.MixProject do\nend",
+		"docs/guide/intro.md":           "# Intro",
+		// A linked worktree has a .git file pointing at the main repository
+		"worktrees/feature/.git":       "gitdir: ../../.git/worktrees/feature\n",
+		"worktrees/feature/Cargo.toml": "[package]",
+		"worktrees/feature/src/lib.rs": "",
+	} {
+		full := filepath.Join(repo, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir            string
+		root, repoRoot string
+		lang           Language
+	}{
+		{"services/web/src/app", "services/web", ".", TypeScript},
+		{"services/api", "services/api", ".", Elixir},
+		{"docs/guide", ".", ".", Go},
+		{"worktrees/feature/src", "worktrees/feature", "worktrees/feature", Rust},
+	}
+	for _, tt := range tests {
+		p := Detect(filepath.Join(repo, tt.dir))
+		want := Project{Root: filepath.Join(repo, tt.root), RepoRoot: filepath.Join(repo, tt.repoRoot), Language: tt.lang}
+		if p != want {
+			t.Errorf("Detect(%s) = %+v, want %+v", tt.dir, p, want)
+		}
+	}
+}
+
 func TestDetectFromFilename(t *testing.T) {
 	tests := []struct {
 		filename string
@@ -105,6 +147,7 @@ func TestDetectFromFilename(t *testing.T) {
 		{"component.tsx", TypeScript},
 		{"script.js", TypeScript},
 		{"app.py", Python},
+		{"lib.rs", Rust},
 		{"readme.md", Unknown},
 		{"Makefile", Unknown},
 	}
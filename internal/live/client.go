@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"gptcode/internal/config"
 	"gptcode/internal/crypto"
 
 	"github.com/gorilla/websocket"
@@ -333,18 +334,8 @@ func findGPTCodeDir() (string, error) {
 		return "", err
 	}
 
-	dir := cwd
-	for {
-		gptcodePath := filepath.Join(dir, ".gptcode")
-		if _, err := os.Stat(gptcodePath); err == nil {
-			return gptcodePath, nil
-		}
-
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			break
-		}
-		dir = parent
+	if dir, ok := config.FindProjectDir(cwd, ".gptcode"); ok {
+		return filepath.Join(dir, ".gptcode"), nil
 	}
 
 	return "", fmt.Errorf(".gptcode directory not found")
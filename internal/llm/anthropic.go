@@ -0,0 +1,400 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"gptcode/internal/config"
+	"gptcode/internal/httpclient"
+)
+
+// anthropicVersion is the Messages API version requests are made against
+const anthropicVersion = "2023-06-01"
+
+// anthropicMaxTokens caps responses; the Messages API requires a limit
+const anthropicMaxTokens = 8192
+
+// AnthropicProvider speaks the Anthropic Messages API natively, including
+// tool use and streaming, so Claude models don't need an OpenAI-compatible
+// proxy.
+type AnthropicProvider struct {
+	APIKey     string
+	BaseURL    string
+	Backend    string
+	MaxTokens  int
+	Retry      RetryPolicy
+	HTTPClient *http.Client
+	Headers    map[string]string
+}
+
+// NewAnthropic returns a provider for the backend named backendName. baseURL
+// defaults to https://api.anthropic.com; the API key is read like any other
+// backend's (ANTHROPIC_API_KEY for a backend named anthropic).
+func NewAnthropic(baseURL, backendName string) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = "https://api.anthropic.com"
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if !strings.HasSuffix(baseURL, "/messages") {
+		if !strings.HasSuffix(baseURL, "/v1") {
+			baseURL += "/v1"
+		}
+		baseURL += "/messages"
+	}
+
+	provider := &AnthropicProvider{
+		APIKey:     config.GetAPIKey(backendName),
+		BaseURL:    baseURL,
+		Backend:    backendName,
+		MaxTokens:  anthropicMaxTokens,
+		Retry:      RetryPolicyFor(backendName),
+		HTTPClient: httpclient.ForBackend(backendName),
+	}
+	if setup, err := config.LoadEffectiveSetup(); err == nil {
+		if backendCfg, ok := setup.Backend[backendName]; ok {
+			provider.Headers = backendCfg.Headers
+		}
+	}
+	return provider
+}
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+	Stream    bool               `json:"stream,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema"`
+}
+
+type anthropicResponse struct {
+	Content []anthropicBlock `json:"content"`
+	Usage   *struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// requestBody converts req to a Messages API request. System messages are
+// folded into the system prompt, tool results become tool_result blocks in
+// a user turn, and consecutive turns of the same role are merged as the API
+// requires alternating roles.
+func (a *AnthropicProvider) requestBody(req ChatRequest, stream bool) []byte {
+	req = FitRequest(a.Backend, req)
+	body := anthropicRequest{
+		Model:     req.Model,
+		MaxTokens: a.MaxTokens,
+		Stream:    stream,
+	}
+	if body.MaxTokens <= 0 {
+		body.MaxTokens = anthropicMaxTokens
+	}
+
+	system := []string{}
+	if req.SystemPrompt != "" {
+		system = append(system, req.SystemPrompt)
+	}
+
+	add := func(role string, blocks ...anthropicBlock) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(body.Messages); n > 0 && body.Messages[n-1].Role == role {
+			body.Messages[n-1].Content = append(body.Messages[n-1].Content, blocks...)
+			return
+		}
+		body.Messages = append(body.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+		case "tool":
+			add("user", anthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		case "assistant":
+			var blocks []anthropicBlock
+			if msg.Content != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: msg.Content})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Arguments)
+				if !json.Valid(input) || len(bytes.TrimSpace(input)) == 0 {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Name, Input: input})
+			}
+			add("assistant", blocks...)
+		default:
+			if msg.Content != "" {
+				add("user", anthropicBlock{Type: "text", Text: msg.Content})
+			}
+		}
+	}
+	if req.UserPrompt != "" {
+		add("user", anthropicBlock{Type: "text", Text: req.UserPrompt})
+	}
+	body.System = strings.Join(system, "\n\n")
+	body.Tools = anthropicTools(req.Tools)
+
+	b, _ := json.Marshal(body)
+	return b
+}
+
+// anthropicTools converts OpenAI-style function definitions to Messages API
+// tool definitions.
+func anthropicTools(tools []interface{}) []anthropicTool {
+	var out []anthropicTool
+	for _, tool := range tools {
+		toolMap, ok := tool.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fn, ok := toolMap["function"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := fn["name"].(string)
+		if name == "" {
+			continue
+		}
+		description, _ := fn["description"].(string)
+		schema := fn["parameters"]
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		out = append(out, anthropicTool{Name: name, Description: description, InputSchema: schema})
+	}
+	return out
+}
+
+func (a *AnthropicProvider) newRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", a.BaseURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("x-api-key", a.APIKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+	httpReq.Header.Set("Content-Type", "application/json")
+	for name, value := range a.Headers {
+		httpReq.Header.Set(name, os.ExpandEnv(value))
+	}
+	return httpReq, nil
+}
+
+func (a *AnthropicProvider) client() *http.Client {
+	if a.HTTPClient != nil {
+		return a.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// retryKey identifies the circuit breaker for this provider.
+func (a *AnthropicProvider) retryKey() string {
+	if a.Backend != "" {
+		return a.Backend
+	}
+	return a.BaseURL
+}
+
+func (a *AnthropicProvider) send(ctx context.Context, body []byte) (*http.Response, error) {
+	if a.APIKey == "" {
+		return nil, errors.New("API key not defined")
+	}
+	if err := meterCheck(); err != nil {
+		return nil, err
+	}
+	if os.Getenv("GPTCODE_DEBUG") == "1" {
+		fmt.Fprintf(os.Stderr, "\n=== REQUEST TO %s ===\n%s\n\n", a.BaseURL, string(body))
+	}
+	return doWithRetry(ctx, a.client(), a.retryKey(), a.Retry, func() (*http.Request, error) {
+		return a.newRequest(ctx, body)
+	})
+}
+
+func (a *AnthropicProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	resp, err := a.send(ctx, a.requestBody(req, false))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	responseBody, _ := io.ReadAll(resp.Body)
+	if os.Getenv("GPTCODE_DEBUG") == "1" {
+		fmt.Fprintf(os.Stderr, "=== RESPONSE ===\n%s\n\n", string(responseBody))
+	}
+
+	var apiResp anthropicResponse
+	if err := json.Unmarshal(responseBody, &apiResp); err != nil {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(responseBody))
+	}
+	if apiResp.Error != nil {
+		return nil, fmt.Errorf("API error: %s", apiResp.Error.Message)
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(responseBody))
+	}
+
+	response := &ChatResponse{}
+	var text strings.Builder
+	for _, block := range apiResp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			response.ToolCalls = append(response.ToolCalls, ChatToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: string(block.Input),
+			})
+		}
+	}
+	response.Text = text.String()
+
+	if apiResp.Usage != nil {
+		response.TokenUsage = &TokenUsage{
+			PromptTokens:     apiResp.Usage.InputTokens,
+			CompletionTokens: apiResp.Usage.OutputTokens,
+			TotalTokens:      apiResp.Usage.InputTokens + apiResp.Usage.OutputTokens,
+		}
+	}
+	meterRecord(a.retryKey(), req.Model, req, response)
+	return response, nil
+}
+
+// ChatStream streams the response text to callback.
+func (a *AnthropicProvider) ChatStream(ctx context.Context, req ChatRequest, callback func(chunk string)) error {
+	_, err := a.StreamChat(ctx, req, callback)
+	return err
+}
+
+// StreamChat sends req with streaming enabled, passing text to onChunk as it
+// arrives, and returns the assembled response including any tool calls.
+func (a *AnthropicProvider) StreamChat(ctx context.Context, req ChatRequest, onChunk func(chunk string)) (*ChatResponse, error) {
+	resp, err := a.send(ctx, a.requestBody(req, true))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var text strings.Builder
+	var calls []ChatToolCall
+	// Content blocks are streamed by index; tool_use blocks become calls
+	toolIndex := map[int]int{}
+	usage := &TokenUsage{}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		var event struct {
+			Type    string `json:"type"`
+			Index   int    `json:"index"`
+			Message struct {
+				Usage struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+			ContentBlock anthropicBlock `json:"content_block"`
+			Delta        struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				PartialJSON string `json:"partial_json"`
+			} `json:"delta"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			continue
+		}
+
+		switch event.Type {
+		case "message_start":
+			usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				toolIndex[event.Index] = len(calls)
+				calls = append(calls, ChatToolCall{ID: event.ContentBlock.ID, Name: event.ContentBlock.Name})
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				text.WriteString(event.Delta.Text)
+				if onChunk != nil && event.Delta.Text != "" {
+					onChunk(event.Delta.Text)
+				}
+			case "input_json_delta":
+				if i, ok := toolIndex[event.Index]; ok {
+					calls[i].Arguments += event.Delta.PartialJSON
+				}
+			}
+		case "message_delta":
+			usage.CompletionTokens = event.Usage.OutputTokens
+		case "error":
+			if event.Error != nil {
+				return nil, fmt.Errorf("API error: %s", event.Error.Message)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range calls {
+		if calls[i].Arguments == "" {
+			calls[i].Arguments = "{}"
+		}
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	response := &ChatResponse{Text: text.String(), ToolCalls: calls, TokenUsage: usage}
+	meterRecord(a.retryKey(), req.Model, req, response)
+	return response, nil
+}
@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicRequestBody(t *testing.T) {
+	a := &AnthropicProvider{MaxTokens: 100}
+	body := a.requestBody(ChatRequest{
+		Model:        "claude",
+		SystemPrompt: "be brief",
+		Messages: []ChatMessage{
+			{Role: "user", Content: "read a.go"},
+			{Role: "assistant", ToolCalls: []ChatToolCall{
+				{ID: "t1", Name: "read_file", Arguments: `{"path":"a.go"}`},
+				{ID: "t2", Name: "read_file", Arguments: `{"path":"b.go"}`},
+			}},
+			{Role: "tool", ToolCallID: "t1", Content: "package a"},
+			{Role: "tool", ToolCallID: "t2", Content: "package b"},
+		},
+		Tools: []interface{}{map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        "read_file",
+				"description": "Read a file",
+				"parameters":  map[string]interface{}{"type": "object"},
+			},
+		}},
+	}, false)
+
+	var got anthropicRequest
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.System != "be brief" || got.MaxTokens != 100 {
+		t.Errorf("unexpected system/max_tokens: %q %d", got.System, got.MaxTokens)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("expected user, assistant, user turns, got %+v", got.Messages)
+	}
+	if blocks := got.Messages[1].Content; len(blocks) != 2 || blocks[0].Type != "tool_use" || string(blocks[0].Input) != `{"path":"a.go"}` {
+		t.Errorf("unexpected assistant blocks: %+v", blocks)
+	}
+	if blocks := got.Messages[2].Content; len(blocks) != 2 || blocks[1].Type != "tool_result" || blocks[1].ToolUseID != "t2" {
+		t.Errorf("tool results should be merged into one user turn: %+v", blocks)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Name != "read_file" || got.Tools[0].InputSchema == nil {
+		t.Errorf("unexpected tools: %+v", got.Tools)
+	}
+}
+
+func TestAnthropicChat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing auth headers: %v", r.Header)
+		}
+		io.Copy(io.Discard, r.Body)
+		fmt.Fprint(w, `{"content":[{"type":"text","text":"Reading."},{"type":"tool_use","id":"t1","name":"read_file","input":{"path":"a.go"}}],"usage":{"input_tokens":10,"output_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	a := &AnthropicProvider{APIKey: "key", BaseURL: server.URL, HTTPClient: server.Client()}
+	resp, err := a.Chat(context.Background(), ChatRequest{Model: "claude", UserPrompt: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Text != "Reading." || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Arguments != `{"path":"a.go"}` {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.TokenUsage == nil || resp.TokenUsage.TotalTokens != 15 {
+		t.Errorf("unexpected usage: %+v", resp.TokenUsage)
+	}
+}
+
+func TestAnthropicStreamChat(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"usage":{"input_tokens":7}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"check."}}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"t1","name":"read_file"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"pa"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"th\":\"a.go\"}"}}`,
+		`{"type":"message_delta","usage":{"output_tokens":12}}`,
+		`{"type":"message_stop"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", e)
+		}
+	}))
+	defer server.Close()
+
+	a := &AnthropicProvider{APIKey: "key", BaseURL: server.URL, HTTPClient: server.Client()}
+	var chunks []string
+	resp, err := StreamChat(context.Background(), a, ChatRequest{Model: "claude", UserPrompt: "hi"}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || resp.Text != "Let me check." {
+		t.Errorf("unexpected text: %q %q", chunks, resp.Text)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "t1" || resp.ToolCalls[0].Arguments != `{"path":"a.go"}` {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.TokenUsage.PromptTokens != 7 || resp.TokenUsage.CompletionTokens != 12 {
+		t.Errorf("unexpected usage: %+v", resp.TokenUsage)
+	}
+}
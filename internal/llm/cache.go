@@ -0,0 +1,230 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache defaults, overridable in the cache section of setup.yaml
+const (
+	DefaultCacheTTL     = 24 * time.Hour
+	DefaultCacheMaxSize = 100 << 20
+)
+
+// Cache stores LLM responses on disk keyed by a hash of the model, prompts,
+// messages and tools of the request, so identical questions asked again
+// (typically by research and review agents across runs) are answered
+// without a call. Entries expire after a TTL and the oldest are evicted
+// once the cache grows past its size limit.
+type Cache struct {
+	Dir     string
+	TTL     time.Duration
+	MaxSize int64
+
+	mu sync.Mutex
+}
+
+// NewCache returns a cache in dir. A zero ttl or maxSize uses the default.
+func NewCache(dir string, ttl time.Duration, maxSize int64) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultCacheMaxSize
+	}
+	return &Cache{Dir: dir, TTL: ttl, MaxSize: maxSize}
+}
+
+// CacheDir is ~/.gptcode/cache/llm
+func CacheDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "cache", "llm")
+}
+
+var (
+	activeCacheMu sync.RWMutex
+	activeCache   *Cache
+)
+
+// SetCache installs the response cache for this process; nil disables it.
+func SetCache(c *Cache) {
+	activeCacheMu.Lock()
+	defer activeCacheMu.Unlock()
+	activeCache = c
+}
+
+// ActiveCache returns the installed response cache, or nil.
+func ActiveCache() *Cache {
+	activeCacheMu.RLock()
+	defer activeCacheMu.RUnlock()
+	return activeCache
+}
+
+type cacheableKey struct{}
+
+// WithCache marks calls made with ctx as answerable from the response
+// cache. Only agents whose answers depend on nothing but the request opt in;
+// the editor does not, so retrying a task asks the model again.
+func WithCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheableKey{}, true)
+}
+
+func cacheFor(ctx context.Context) *Cache {
+	if ctx == nil || ctx.Value(cacheableKey{}) == nil {
+		return nil
+	}
+	return ActiveCache()
+}
+
+type cacheEntry struct {
+	Created  time.Time     `json:"created"`
+	Model    string        `json:"model"`
+	Response *ChatResponse `json:"response"`
+}
+
+// Key hashes everything about req that determines the response.
+func (c *Cache) Key(req ChatRequest) string {
+	h := sha256.New()
+	_ = json.NewEncoder(h).Encode(struct {
+		Model    string
+		System   string
+		User     string
+		Messages []ChatMessage
+		Tools    []interface{}
+	}{req.Model, req.SystemPrompt, req.UserPrompt, req.Messages, req.Tools})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, key[:2], key+".json")
+}
+
+// Get returns the cached response for key if it has not expired.
+func (c *Cache) Get(key string) (*ChatResponse, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var e cacheEntry
+	if json.Unmarshal(data, &e) != nil || e.Response == nil || time.Since(e.Created) > c.TTL {
+		return nil, false
+	}
+	resp := *e.Response
+	resp.Cached = true
+	return &resp, true
+}
+
+// Put stores resp under key and evicts the oldest entries if the cache has
+// grown past its size limit.
+func (c *Cache) Put(key, model string, resp *ChatResponse) error {
+	data, err := json.Marshal(cacheEntry{Created: time.Now(), Model: model, Response: resp})
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return c.prune()
+}
+
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (c *Cache) files() ([]cacheFile, error) {
+	var files []cacheFile
+	err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".json") {
+			files = append(files, cacheFile{path, info.Size(), info.ModTime()})
+		}
+		return nil
+	})
+	return files, err
+}
+
+// prune removes expired entries, then the oldest ones until the cache fits
+// in MaxSize.
+func (c *Cache) prune() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	for _, f := range files {
+		if total <= c.MaxSize && time.Since(f.modTime) <= c.TTL {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+	return nil
+}
+
+// CacheStats describes what the cache holds
+type CacheStats struct {
+	Entries int
+	Size    int64
+	Expired int
+}
+
+// Stats counts the cache's entries and their size.
+func (c *Cache) Stats() (CacheStats, error) {
+	files, err := c.files()
+	if err != nil {
+		return CacheStats{}, err
+	}
+	var s CacheStats
+	for _, f := range files {
+		s.Entries++
+		s.Size += f.size
+		if time.Since(f.modTime) > c.TTL {
+			s.Expired++
+		}
+	}
+	return s, nil
+}
+
+// Clear removes every cached response and returns how many there were.
+func (c *Cache) Clear() (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(c.Dir); err != nil {
+		return 0, fmt.Errorf("failed to clear cache: %w", err)
+	}
+	return len(files), nil
+}
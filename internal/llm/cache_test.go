@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingProvider struct{ calls int }
+
+func (p *countingProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	p.calls++
+	return &ChatResponse{Text: "answer to " + req.Messages[len(req.Messages)-1].Content}, nil
+}
+
+func TestStreamChatUsesCacheWhenAllowed(t *testing.T) {
+	SetCache(NewCache(t.TempDir(), time.Hour, 1<<20))
+	defer SetCache(nil)
+
+	p := &countingProvider{}
+	req := ChatRequest{Model: "m", SystemPrompt: "s", Messages: []ChatMessage{{Role: "user", Content: "q1"}}}
+	ctx := WithCache(context.Background())
+
+	first, _ := StreamChat(ctx, p, req, nil)
+	var streamed string
+	second, err := StreamChat(ctx, p, req, func(chunk string) { streamed += chunk })
+	if err != nil || p.calls != 1 {
+		t.Fatalf("identical request should be cached, calls=%d err=%v", p.calls, err)
+	}
+	if first.Cached || !second.Cached || second.Text != first.Text || streamed != first.Text {
+		t.Errorf("unexpected responses %+v %+v, streamed %q", first, second, streamed)
+	}
+
+	req.Messages = []ChatMessage{{Role: "user", Content: "q2"}}
+	if resp, _ := StreamChat(ctx, p, req, nil); p.calls != 2 || resp.Text != "answer to q2" {
+		t.Errorf("a different request must call the model, calls=%d", p.calls)
+	}
+	_, _ = StreamChat(context.Background(), p, req, nil)
+	if p.calls != 3 {
+		t.Error("calls not marked WithCache must not use the cache")
+	}
+}
+
+func TestCacheExpiresAndEvicts(t *testing.T) {
+	c := NewCache(t.TempDir(), time.Hour, 1<<20)
+	key := c.Key(ChatRequest{Model: "m"})
+	if err := c.Put(key, "m", &ChatResponse{Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(key); !ok {
+		t.Fatal("fresh entry should be returned")
+	}
+	c.TTL = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, ok := c.Get(key); ok {
+		t.Error("expired entry should not be returned")
+	}
+
+	c = NewCache(t.TempDir(), time.Hour, 600)
+	for i := 0; i < 10; i++ {
+		key := c.Key(ChatRequest{Model: "m", UserPrompt: strings.Repeat("x", i)})
+		if err := c.Put(key, "m", &ChatResponse{Text: strings.Repeat("y", 100)}); err != nil {
+			t.Fatal(err)
+		}
+		// Distinct modification times so eviction order is deterministic
+		old := time.Now().Add(time.Duration(i-10) * time.Second)
+		_ = os.Chtimes(c.path(key), old, old)
+	}
+	stats, _ := c.Stats()
+	if stats.Size > 600 || stats.Entries == 0 {
+		t.Errorf("cache should be pruned to its size limit, got %+v", stats)
+	}
+	last := c.Key(ChatRequest{Model: "m", UserPrompt: strings.Repeat("x", 9)})
+	if _, ok := c.Get(last); !ok {
+		t.Error("the newest entry should survive eviction")
+	}
+
+	n, err := c.Clear()
+	if err != nil || n != stats.Entries {
+		t.Errorf("Clear() = %d, %v", n, err)
+	}
+	if stats, _ := c.Stats(); stats.Entries != 0 {
+		t.Errorf("cache not empty after Clear: %+v", stats)
+	}
+}
@@ -8,15 +8,24 @@ import (
 	"errors"
 	"fmt"
 	"gptcode/internal/config"
+	"gptcode/internal/httpclient"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 )
 
 type ChatCompletionProvider struct {
-	APIKey  string
-	BaseURL string
+	APIKey     string
+	BaseURL    string
+	Backend    string
+	Retry      RetryPolicy
+	HTTPClient *http.Client
+	Headers    map[string]string
+	SigV4      *config.SigV4Config
+	backendCfg *config.BackendConfig
+	profile    string
 }
 
 func NewChatCompletion(baseURL, backendName string) *ChatCompletionProvider {
@@ -29,19 +38,37 @@ func NewChatCompletion(baseURL, backendName string) *ChatCompletionProvider {
 
 	apiKey := config.GetAPIKey(backendName)
 
-	return &ChatCompletionProvider{
-		APIKey:  apiKey,
-		BaseURL: baseURL,
+	provider := &ChatCompletionProvider{
+		APIKey:     apiKey,
+		BaseURL:    baseURL,
+		Backend:    backendName,
+		Retry:      RetryPolicyFor(backendName),
+		HTTPClient: httpclient.ForBackend(backendName),
 	}
+	if setup, err := config.LoadEffectiveSetup(); err == nil {
+		if backendCfg, ok := setup.Backend[backendName]; ok {
+			provider.Headers = backendCfg.Headers
+			provider.SigV4 = backendCfg.SigV4
+			provider.backendCfg = &backendCfg
+			provider.profile = setup.Defaults.Profile
+		}
+	}
+	return provider
 }
 
 type chatCompletionRequest struct {
-	Model       string              `json:"model"`
-	Messages    []chatCompletionMsg `json:"messages"`
-	Tools       []interface{}       `json:"tools,omitempty"`
-	ToolChoice  *string             `json:"tool_choice,omitempty"`
-	Stream      bool                `json:"stream,omitempty"`
-	Temperature float64             `json:"temperature"`
+	Model      string              `json:"model"`
+	Messages   []chatCompletionMsg `json:"messages"`
+	Tools      []interface{}       `json:"tools,omitempty"`
+	ToolChoice *string             `json:"tool_choice,omitempty"`
+	Stream     bool                `json:"stream,omitempty"`
+	// StreamOptions asks for the usage in a final chunk of streams
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+	Temperature   float64        `json:"temperature"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type compoundChatRequest struct {
@@ -113,90 +140,154 @@ type chatCompletionResponse struct {
 			ToolCalls []ToolCall `json:"tool_calls"`
 		} `json:"message"`
 	} `json:"choices"`
-	Usage *struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Usage *chatCompletionUsage `json:"usage"`
 	Error *struct {
 		Message string `json:"message"`
 	} `json:"error"`
 }
 
-func (c *ChatCompletionProvider) ChatStream(ctx context.Context, req ChatRequest, callback func(chunk string)) error {
-	if c.APIKey == "" {
-		return errors.New("API key not defined")
+type chatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+func (u *chatCompletionUsage) tokenUsage() *TokenUsage {
+	if u == nil {
+		return nil
+	}
+	total := u.TotalTokens
+	if total == 0 {
+		total = u.PromptTokens + u.CompletionTokens
 	}
+	return &TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: total}
+}
 
-	messages := []chatCompletionMsg{
-		{Role: "system", Content: req.SystemPrompt},
+// newRequest builds the HTTP request for body, applying the backend's custom
+// headers and signing it when SigV4 is configured. Header values may
+// reference environment variables as ${VAR}.
+func (c *ChatCompletionProvider) newRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for name, value := range c.Headers {
+		httpReq.Header.Set(name, os.ExpandEnv(value))
 	}
 
-	for _, msg := range req.Messages {
-		messages = append(messages, chatCompletionMsg{
-			Role:       msg.Role,
-			Content:    msg.Content,
-			Name:       msg.Name,
-			ToolCallID: msg.ToolCallID,
-		})
+	if c.SigV4 != nil {
+		creds, err := sigV4CredentialsFor(c.SigV4)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Del("Authorization")
+		signSigV4(httpReq, body, creds, c.SigV4.Region, c.SigV4.Service, time.Now())
 	}
+	return httpReq, nil
+}
 
-	if req.UserPrompt != "" {
-		messages = append(messages, chatCompletionMsg{
-			Role:    "user",
-			Content: req.UserPrompt,
-		})
+// applyRouting adds the configured router hints (OpenRouter provider
+// preferences, transforms, LiteLLM extras) for model to the request body.
+func (c *ChatCompletionProvider) applyRouting(body []byte, model string) []byte {
+	if c.backendCfg == nil {
+		return body
+	}
+	routing, ok := c.backendCfg.RoutingFor(c.profile, model)
+	if !ok {
+		return body
 	}
+	return mergeRouting(body, routing)
+}
 
-	isCompound := strings.Contains(req.Model, "compound")
+func mergeRouting(body []byte, routing config.RoutingConfig) []byte {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+	for k, v := range routing.Extra {
+		fields[k] = v
+	}
+	if routing.Provider != nil {
+		fields["provider"] = routing.Provider
+	}
+	if len(routing.Transforms) > 0 {
+		fields["transforms"] = routing.Transforms
+	}
+	merged, err := json.Marshal(fields)
+	if err != nil {
+		return body
+	}
+	return merged
+}
 
-	var b []byte
-	if isCompound {
-		toolNames := extractToolNames(req.Tools)
-		compoundBody := compoundChatRequest{
-			Model:    req.Model,
-			Messages: messages,
-			CompoundCustom: &compoundCustom{
-				Tools: &compoundTools{
-					EnabledTools: toolNames,
-				},
-			},
-			Stream:      true,
-			Temperature: 0.0,
-		}
-		b, _ = json.Marshal(compoundBody)
-	} else {
-		body := chatCompletionRequest{
-			Model:       req.Model,
-			Messages:    messages,
-			Stream:      true,
-			Temperature: 0.0,
-		}
-		if len(req.Tools) > 0 {
-			body.Tools = req.Tools
-			auto := "auto"
-			body.ToolChoice = &auto
+// hasCredentials reports whether requests carry some form of authentication:
+// an API key, SigV4 signing, or an Authorization header from config.
+func (c *ChatCompletionProvider) hasCredentials() bool {
+	if c.APIKey != "" || c.SigV4 != nil {
+		return true
+	}
+	for name := range c.Headers {
+		if strings.EqualFold(name, "Authorization") {
+			return true
 		}
-		b, _ = json.Marshal(body)
 	}
+	return false
+}
 
-	httpReq, _ := http.NewRequestWithContext(ctx, "POST", c.BaseURL, bytes.NewReader(b))
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
+func (c *ChatCompletionProvider) client() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// retryKey identifies the circuit breaker for this provider.
+func (c *ChatCompletionProvider) retryKey() string {
+	if c.Backend != "" {
+		return c.Backend
+	}
+	return c.BaseURL
+}
+
+// ChatStream streams the response text to callback.
+func (c *ChatCompletionProvider) ChatStream(ctx context.Context, req ChatRequest, callback func(chunk string)) error {
+	_, err := c.StreamChat(ctx, req, callback)
+	return err
+}
 
-	resp, err := http.DefaultClient.Do(httpReq)
+// StreamChat sends req with streaming enabled, passing text to onChunk as it
+// arrives, and returns the assembled response including any tool calls.
+func (c *ChatCompletionProvider) StreamChat(ctx context.Context, req ChatRequest, onChunk func(chunk string)) (*ChatResponse, error) {
+	if !c.hasCredentials() {
+		return nil, errors.New("API key not defined")
+	}
+	if err := meterCheck(); err != nil {
+		return nil, err
+	}
+	b := c.requestBody(req, true)
+
+	resp, err := doWithRetry(ctx, c.client(), c.retryKey(), c.Retry, func() (*http.Request, error) {
+		return c.newRequest(ctx, b)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
-		fmt.Fprintf(os.Stderr, "\n[HTTP %d] %s\n", resp.StatusCode, string(body))
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
 	}
 
+	var text strings.Builder
+	var calls []ChatToolCall
+	var usage *TokenUsage
 	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
 		line := scanner.Text()
 		if !strings.HasPrefix(line, "data: ") {
@@ -211,28 +302,67 @@ func (c *ChatCompletionProvider) ChatStream(ctx context.Context, req ChatRequest
 		var chunk struct {
 			Choices []struct {
 				Delta struct {
-					Content string `json:"content"`
+					Content   string `json:"content"`
+					ToolCalls []struct {
+						Index    int    `json:"index"`
+						ID       string `json:"id"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
 				} `json:"delta"`
 			} `json:"choices"`
+			// The last chunk carries the usage, in x_groq on Groq
+			Usage *chatCompletionUsage `json:"usage"`
+			XGroq *struct {
+				Usage *chatCompletionUsage `json:"usage"`
+			} `json:"x_groq"`
 		}
 
 		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
 			continue
 		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage.tokenUsage()
+		} else if chunk.XGroq != nil && chunk.XGroq.Usage != nil {
+			usage = chunk.XGroq.Usage.tokenUsage()
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
 
-		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-			callback(chunk.Choices[0].Delta.Content)
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			text.WriteString(delta.Content)
+			if onChunk != nil {
+				onChunk(delta.Content)
+			}
+		}
+		// Tool calls arrive in fragments keyed by index
+		for _, tc := range delta.ToolCalls {
+			for len(calls) <= tc.Index {
+				calls = append(calls, ChatToolCall{})
+			}
+			if tc.ID != "" {
+				calls[tc.Index].ID = tc.ID
+			}
+			calls[tc.Index].Name += tc.Function.Name
+			calls[tc.Index].Arguments += tc.Function.Arguments
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
 
-	return scanner.Err()
+	response := &ChatResponse{Text: text.String(), ToolCalls: calls, TokenUsage: usage}
+	meterRecord(c.retryKey(), req.Model, req, response)
+	return response, nil
 }
 
-func (c *ChatCompletionProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
-	if c.APIKey == "" {
-		return nil, errors.New("API key not defined")
-	}
-
+// requestBody encodes req as a chat completions request body.
+func (c *ChatCompletionProvider) requestBody(req ChatRequest, stream bool) []byte {
+	req = FitRequest(c.Backend, req)
 	messages := []chatCompletionMsg{
 		{Role: "system", Content: req.SystemPrompt},
 	}
@@ -280,6 +410,7 @@ func (c *ChatCompletionProvider) Chat(ctx context.Context, req ChatRequest) (*Ch
 					EnabledTools: toolNames,
 				},
 			},
+			Stream:      stream,
 			Temperature: 0.0,
 		}
 		b, _ = json.Marshal(compoundBody)
@@ -287,8 +418,12 @@ func (c *ChatCompletionProvider) Chat(ctx context.Context, req ChatRequest) (*Ch
 		body := chatCompletionRequest{
 			Model:       req.Model,
 			Messages:    messages,
+			Stream:      stream,
 			Temperature: 0.0,
 		}
+		if stream {
+			body.StreamOptions = &streamOptions{IncludeUsage: true}
+		}
 		if len(req.Tools) > 0 {
 			body.Tools = req.Tools
 			auto := "auto"
@@ -296,20 +431,31 @@ func (c *ChatCompletionProvider) Chat(ctx context.Context, req ChatRequest) (*Ch
 		}
 		b, _ = json.Marshal(body)
 	}
+	b = c.applyRouting(b, req.Model)
+	return b
+}
+
+func (c *ChatCompletionProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if !c.hasCredentials() {
+		return nil, errors.New("API key not defined")
+	}
+	if err := meterCheck(); err != nil {
+		return nil, err
+	}
+
+	b := c.requestBody(req, false)
 
 	if os.Getenv("GPTCODE_DEBUG") == "1" {
 		fmt.Fprintf(os.Stderr, "\n=== REQUEST TO %s ===\n%s\n\n", c.BaseURL, string(b))
 	}
 
-	httpReq, _ := http.NewRequestWithContext(ctx, "POST", c.BaseURL, bytes.NewReader(b))
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-
 	if os.Getenv("GPTCODE_DEBUG") == "1" {
 		fmt.Fprintf(os.Stderr, "[HTTP] Making request to %s\n", c.BaseURL)
 	}
 
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := doWithRetry(ctx, c.client(), c.retryKey(), c.Retry, func() (*http.Request, error) {
+		return c.newRequest(ctx, b)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -368,14 +514,8 @@ func (c *ChatCompletionProvider) Chat(ctx context.Context, req ChatRequest) (*Ch
 		}
 	}
 
-	// Populate token usage if available
-	if apiResp.Usage != nil {
-		response.TokenUsage = &TokenUsage{
-			PromptTokens:     apiResp.Usage.PromptTokens,
-			CompletionTokens: apiResp.Usage.CompletionTokens,
-			TotalTokens:      apiResp.Usage.TotalTokens,
-		}
-	}
+	response.TokenUsage = apiResp.Usage.tokenUsage()
+	meterRecord(c.retryKey(), req.Model, req, response)
 
 	return response, nil
 }
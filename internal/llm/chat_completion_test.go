@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gptcode/internal/config"
+)
+
+func TestMergeRouting(t *testing.T) {
+	fallbacks := false
+	routing := config.RoutingConfig{
+		Provider:   &config.ProviderPreferences{Order: []string{"deepinfra"}, AllowFallbacks: &fallbacks},
+		Transforms: []string{"middle-out"},
+		Extra:      map[string]interface{}{"metadata": map[string]interface{}{"team": "platform"}},
+	}
+
+	body := mergeRouting([]byte(`{"model":"m","temperature":0}`), routing)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	provider, ok := got["provider"].(map[string]interface{})
+	if !ok || provider["allow_fallbacks"] != false {
+		t.Errorf("expected provider preferences in body, got %s", body)
+	}
+	if _, ok := got["transforms"]; !ok {
+		t.Errorf("expected transforms in body, got %s", body)
+	}
+	if _, ok := got["metadata"]; !ok {
+		t.Errorf("expected extra fields in body, got %s", body)
+	}
+	if got["model"] != "m" {
+		t.Errorf("original fields lost: %s", body)
+	}
+}
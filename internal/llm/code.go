@@ -0,0 +1,21 @@
+package llm
+
+import "strings"
+
+// ExtractCode returns the first fenced code block of a model's response,
+// or the whole response when it has none
+func ExtractCode(text string) string {
+	text = strings.TrimSpace(text)
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return text
+	}
+	rest := text[start+3:]
+	if nl := strings.Index(rest, "\n"); nl >= 0 {
+		rest = rest[nl+1:]
+	}
+	if end := strings.Index(rest, "```"); end >= 0 {
+		rest = rest[:end]
+	}
+	return strings.TrimSpace(rest)
+}
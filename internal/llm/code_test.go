@@ -0,0 +1,18 @@
+package llm
+
+import "testing"
+
+func TestExtractCode(t *testing.T) {
+	cases := map[string]string{
+		"  package main  ": "package main",
+		"Here you go:\n```go\npackage main\n```\nDone.":            "package main",
+		"```\nfirst\n```\n```\nsecond\n```":                        "first",
+		"```python\nunterminated = True":                           "unterminated = True",
+		"Two:\n```ts\nexport const a = 1\nexport const b = 2\n```": "export const a = 1\nexport const b = 2",
+	}
+	for in, want := range cases {
+		if got := ExtractCode(in); got != want {
+			t.Errorf("ExtractCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
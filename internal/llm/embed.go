@@ -0,0 +1,337 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gptcode/internal/config"
+)
+
+// Embedder turns texts into embedding vectors, one per text. Embed splits
+// long lists into batches the provider accepts.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	// Model names the embedding model; vectors of different models are
+	// not comparable
+	Model() string
+	// Dimensions is the length of the vectors: the configured one, the
+	// model's known one, or the one seen in the last response; 0 until
+	// then. Vectors of different dimensions are not comparable either.
+	Dimensions() int
+}
+
+// Default embedding models when context.embeddings.model is not set
+const (
+	DefaultOpenAIEmbeddingModel = "text-embedding-3-small"
+	DefaultOllamaEmbeddingModel = "nomic-embed-text"
+	DefaultCohereEmbeddingModel = "embed-english-v3.0"
+)
+
+// embeddingDimensions are the default dimensions of known models
+var embeddingDimensions = map[string]int{
+	"text-embedding-3-small":        1536,
+	"text-embedding-3-large":        3072,
+	"text-embedding-ada-002":        1536,
+	"nomic-embed-text":              768,
+	"mxbai-embed-large":             1024,
+	"all-minilm":                    384,
+	"embed-english-v3.0":            1024,
+	"embed-multilingual-v3.0":       1024,
+	"embed-english-light-v3.0":      384,
+	"embed-multilingual-light-v3.0": 384,
+	"embed-v4.0":                    1536,
+}
+
+// Texts per request of each provider, unless context.embeddings.batch_size
+// says otherwise. Cohere accepts at most 96; local Ollama models are kept
+// to small batches so one request does not hit the timeout.
+const (
+	openAIEmbeddingBatch = 256
+	cohereEmbeddingBatch = 96
+	ollamaEmbeddingBatch = 32
+)
+
+// NewEmbedder returns the embedder configured under context.embeddings in
+// setup.yaml. Without a backend there, the default backend is used when it
+// serves embeddings (Ollama, Cohere, OpenAI, or any backend with an
+// explicit model); otherwise embeddings come from a local Ollama. Vectors
+// are cached in ~/.gptcode/cache/embeddings unless the cache is disabled.
+func NewEmbedder(setup *config.Setup) (Embedder, error) {
+	e, err := newEmbedder(setup)
+	if err != nil || setup.Cache.Disabled {
+		return e, err
+	}
+	return NewCachedEmbedder(e, EmbeddingCacheDir()), nil
+}
+
+func newEmbedder(setup *config.Setup) (Embedder, error) {
+	cfg := setup.Context.Embeddings
+	name := cfg.Backend
+	if name == "" {
+		name = setup.Defaults.Backend
+	}
+	backend, ok := setup.Backend[name]
+	if cfg.Backend != "" && !ok {
+		return nil, fmt.Errorf("embedding backend %q is not configured", cfg.Backend)
+	}
+
+	switch {
+	case ok && backend.Type == "ollama":
+		return newOllamaEmbedder(backend.BaseURL, name, cfg), nil
+	case ok && backend.Type == "cohere":
+		return newCohereEmbedder(backend.BaseURL, name, cfg), nil
+	case ok && backend.Type == "anthropic":
+		if cfg.Backend != "" {
+			return nil, fmt.Errorf("backend %q does not serve embeddings", name)
+		}
+	case ok && (cfg.Model != "" || backend.BaseURL == "" || strings.Contains(backend.BaseURL, "api.openai.com")):
+		p := NewChatCompletion(backend.BaseURL, name)
+		p.BaseURL = strings.TrimSuffix(p.BaseURL, "/chat/completions") + "/embeddings"
+		return &openAIEmbedder{
+			provider:      p,
+			embeddingInfo: newEmbeddingInfo(cfg, DefaultOpenAIEmbeddingModel, openAIEmbeddingBatch),
+			dimensions:    cfg.Dimensions,
+		}, nil
+	}
+	return newOllamaEmbedder("", "", cfg), nil
+}
+
+// embeddingTimeout bounds one embedding request
+const embeddingTimeout = 2 * time.Minute
+
+type embeddingInputKey struct{}
+
+// WithQueryEmbedding marks the texts embedded with ctx as search queries
+// rather than documents, for providers that embed them differently
+func WithQueryEmbedding(ctx context.Context) context.Context {
+	return context.WithValue(ctx, embeddingInputKey{}, "query")
+}
+
+// embeddingInput is "query" or "document"
+func embeddingInput(ctx context.Context) string {
+	if v, ok := ctx.Value(embeddingInputKey{}).(string); ok {
+		return v
+	}
+	return "document"
+}
+
+// embeddingInfo holds what the embedders share: the model, the dimensions
+// and the batch size
+type embeddingInfo struct {
+	model string
+	batch int
+	dims  atomic.Int64
+}
+
+func newEmbeddingInfo(cfg config.EmbeddingsConfig, defaultModel string, batch int) *embeddingInfo {
+	info := &embeddingInfo{model: cfg.Model, batch: batch}
+	if info.model == "" {
+		info.model = defaultModel
+	}
+	if cfg.BatchSize > 0 {
+		info.batch = cfg.BatchSize
+	}
+	dims := cfg.Dimensions
+	if dims == 0 {
+		dims = embeddingDimensions[info.model]
+	}
+	info.dims.Store(int64(dims))
+	return info
+}
+
+func (i *embeddingInfo) Model() string { return i.model }
+
+func (i *embeddingInfo) Dimensions() int { return int(i.dims.Load()) }
+
+// embedBatches embeds texts a batch at a time with embed, recording the
+// dimensions of the vectors returned
+func (i *embeddingInfo) embedBatches(ctx context.Context, texts []string, embed func(context.Context, []string) ([][]float32, error)) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += i.batch {
+		vectors, err := embed(ctx, texts[start:min(start+i.batch, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		if vectors, err = checkEmbeddings(vectors); err != nil {
+			return nil, err
+		}
+		out = append(out, vectors...)
+	}
+	if len(out) > 0 {
+		i.dims.Store(int64(len(out[0])))
+	}
+	return out, nil
+}
+
+// openAIEmbedder calls the /embeddings endpoint of OpenAI-compatible APIs
+type openAIEmbedder struct {
+	provider *ChatCompletionProvider
+	*embeddingInfo
+	// dimensions is sent only when configured, as only some models
+	// accept it
+	dimensions int
+}
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embedBatches(ctx, texts, e.embed)
+}
+
+func (e *openAIEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	params := map[string]any{"model": e.model, "input": texts}
+	if e.dimensions > 0 {
+		params["dimensions"] = e.dimensions
+	}
+	body, _ := json.Marshal(params)
+	req, err := e.provider.newRequest(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	client := http.Client{}
+	if e.provider.HTTPClient != nil {
+		client = *e.provider.HTTPClient
+	}
+	client.Timeout = embeddingTimeout
+	if err := doEmbedding(&client, req, &resp); err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index >= 0 && d.Index < len(out) {
+			out[d.Index] = d.Embedding
+		}
+	}
+	return out, nil
+}
+
+// cohereEmbedder calls Cohere's v2 /embed endpoint, which embeds search
+// queries and documents differently
+type cohereEmbedder struct {
+	provider *ChatCompletionProvider
+	*embeddingInfo
+	dimensions int
+}
+
+func newCohereEmbedder(baseURL, backendName string, cfg config.EmbeddingsConfig) *cohereEmbedder {
+	if baseURL == "" {
+		baseURL = "https://api.cohere.com/v2"
+	}
+	p := NewChatCompletion(baseURL, backendName)
+	p.BaseURL = strings.TrimSuffix(p.BaseURL, "/chat/completions") + "/embed"
+	return &cohereEmbedder{
+		provider:      p,
+		embeddingInfo: newEmbeddingInfo(cfg, DefaultCohereEmbeddingModel, cohereEmbeddingBatch),
+		dimensions:    cfg.Dimensions,
+	}
+}
+
+func (e *cohereEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embedBatches(ctx, texts, e.embed)
+}
+
+func (e *cohereEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	params := map[string]any{
+		"model":           e.model,
+		"texts":           texts,
+		"input_type":      "search_" + embeddingInput(ctx),
+		"embedding_types": []string{"float"},
+	}
+	if e.dimensions > 0 {
+		params["output_dimension"] = e.dimensions
+	}
+	body, _ := json.Marshal(params)
+	req, err := e.provider.newRequest(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Embeddings struct {
+			Float [][]float32 `json:"float"`
+		} `json:"embeddings"`
+	}
+	client := http.Client{}
+	if e.provider.HTTPClient != nil {
+		client = *e.provider.HTTPClient
+	}
+	client.Timeout = embeddingTimeout
+	if err := doEmbedding(&client, req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings.Float) != len(texts) {
+		return nil, fmt.Errorf("cohere returned %d embeddings for %d texts", len(resp.Embeddings.Float), len(texts))
+	}
+	return resp.Embeddings.Float, nil
+}
+
+// ollamaEmbedder calls Ollama's /api/embed endpoint
+type ollamaEmbedder struct {
+	provider *OllamaProvider
+	*embeddingInfo
+}
+
+func newOllamaEmbedder(baseURL, backendName string, cfg config.EmbeddingsConfig) *ollamaEmbedder {
+	p := NewOllama(strings.TrimSuffix(baseURL, "/api/chat"), backendName)
+	p.BaseURL = strings.TrimSuffix(p.BaseURL, "/api/chat") + "/api/embed"
+	return &ollamaEmbedder{provider: p, embeddingInfo: newEmbeddingInfo(cfg, DefaultOllamaEmbeddingModel, ollamaEmbeddingBatch)}
+}
+
+func (e *ollamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.embedBatches(ctx, texts, e.embed)
+}
+
+func (e *ollamaEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, _ := json.Marshal(map[string]any{"model": e.model, "input": texts})
+	req, err := e.provider.newRequest(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := doEmbedding(e.provider.client(embeddingTimeout), req, &resp); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, fmt.Errorf("%w (run: ollama pull %s)", err, e.model)
+		}
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d texts", len(resp.Embeddings), len(texts))
+	}
+	return resp.Embeddings, nil
+}
+
+func doEmbedding(client *http.Client, req *http.Request, out any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("embedding request failed: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
+
+func checkEmbeddings(vectors [][]float32) ([][]float32, error) {
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return vectors, nil
+}
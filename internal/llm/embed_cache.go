@@ -0,0 +1,143 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// maxEmbeddingCacheSize bounds the cache file of one model; a larger one is
+// started over
+const maxEmbeddingCacheSize = 512 << 20
+
+// EmbeddingCacheDir is ~/.gptcode/cache/embeddings
+func EmbeddingCacheDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "cache", "embeddings")
+}
+
+// CachedEmbedder keeps the vectors of an embedder on disk, keyed by a hash
+// of each text, so rebuilding an index, switching back to a model used
+// before or indexing another checkout of the code embeds only what was
+// never seen. Each model has an append-only file in Dir; a cached vector
+// whose length differs from the embedder's dimensions is embedded again.
+// The cache is best effort: failing to read or write it never fails Embed.
+type CachedEmbedder struct {
+	Embedder
+	Dir string
+
+	mu      sync.Mutex
+	vectors map[[32]byte][]float32
+}
+
+// NewCachedEmbedder wraps e with the cache in dir.
+func NewCachedEmbedder(e Embedder, dir string) *CachedEmbedder {
+	return &CachedEmbedder{Embedder: e, Dir: dir}
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Path is the cache file of the wrapped embedder's model.
+func (c *CachedEmbedder) Path() string {
+	return filepath.Join(c.Dir, unsafeFileChars.ReplaceAllString(c.Model(), "_")+".vec")
+}
+
+func (c *CachedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	input := embeddingInput(ctx)
+	keys := make([][32]byte, len(texts))
+	out := make([][]float32, len(texts))
+	var missing []int
+
+	c.mu.Lock()
+	c.load()
+	dims := c.Dimensions()
+	for i, text := range texts {
+		keys[i] = sha256.Sum256([]byte(input + "\x00" + text))
+		if v, ok := c.vectors[keys[i]]; ok && (dims == 0 || len(v) == dims) {
+			out[i] = v
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	c.mu.Unlock()
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	todo := make([]string, len(missing))
+	for j, i := range missing {
+		todo[j] = texts[i]
+	}
+	vectors, err := c.Embedder.Embed(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+
+	var records bytes.Buffer
+	c.mu.Lock()
+	for j, i := range missing {
+		out[i] = vectors[j]
+		c.vectors[keys[i]] = vectors[j]
+		writeVectorRecord(&records, keys[i], vectors[j])
+	}
+	c.mu.Unlock()
+	c.append(records.Bytes())
+	return out, nil
+}
+
+// load reads the cache file once. Records are a 32-byte key, the vector's
+// length as a uint32 and its float32s, little-endian; a record cut short
+// by an interrupted write ends the file.
+func (c *CachedEmbedder) load() {
+	if c.vectors != nil {
+		return
+	}
+	c.vectors = map[[32]byte][]float32{}
+	data, err := os.ReadFile(c.Path())
+	if err != nil {
+		return
+	}
+	if len(data) > maxEmbeddingCacheSize {
+		os.Remove(c.Path())
+		return
+	}
+	for len(data) >= 36 {
+		var key [32]byte
+		copy(key[:], data)
+		n := int(binary.LittleEndian.Uint32(data[32:]))
+		data = data[36:]
+		if len(data) < 4*n {
+			break
+		}
+		v := make([]float32, n)
+		for i := range v {
+			v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+		}
+		data = data[4*n:]
+		c.vectors[key] = v
+	}
+}
+
+func (c *CachedEmbedder) append(records []byte) {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return
+	}
+	f, err := os.OpenFile(c.Path(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.Write(records)
+}
+
+func writeVectorRecord(buf *bytes.Buffer, key [32]byte, v []float32) {
+	buf.Write(key[:])
+	binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+	binary.Write(buf, binary.LittleEndian, v)
+}
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"gptcode/internal/config"
+)
+
+func TestNewEmbedder(t *testing.T) {
+	setup := &config.Setup{Backend: map[string]config.BackendConfig{
+		"openai":    {Type: "openai", BaseURL: "https://api.openai.com/v1"},
+		"groq":      {Type: "openai", BaseURL: "https://api.groq.com/openai/v1"},
+		"local":     {Type: "ollama", BaseURL: "http://gpu:11434"},
+		"anthropic": {Type: "anthropic"},
+		"cohere":    {Type: "cohere"},
+	}}
+	cases := []struct {
+		backend, embedBackend, model string
+		want                         string
+	}{
+		{"openai", "", "", DefaultOpenAIEmbeddingModel},
+		{"local", "", "", DefaultOllamaEmbeddingModel},
+		// Backends without known embeddings fall back to a local Ollama
+		{"groq", "", "", DefaultOllamaEmbeddingModel},
+		{"anthropic", "", "", DefaultOllamaEmbeddingModel},
+		{"anthropic", "openai", "text-embedding-3-large", "text-embedding-3-large"},
+		{"openai", "cohere", "", DefaultCohereEmbeddingModel},
+	}
+	for _, c := range cases {
+		setup.Defaults.Backend = c.backend
+		setup.Context.Embeddings = config.EmbeddingsConfig{Backend: c.embedBackend, Model: c.model}
+		e, err := NewEmbedder(setup)
+		if err != nil {
+			t.Fatalf("%s: %v", c.backend, err)
+		}
+		if e.Model() != c.want {
+			t.Errorf("%s/%s: got %s, want %s", c.backend, c.embedBackend, e.Model(), c.want)
+		}
+	}
+
+	setup.Context.Embeddings = config.EmbeddingsConfig{Backend: "anthropic"}
+	if _, err := NewEmbedder(setup); err == nil {
+		t.Error("anthropic has no embeddings and should be rejected")
+	}
+}
+
+func TestEmbedders(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input     []string
+			Texts     []string
+			InputType string `json:"input_type"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		requests++
+		switch r.URL.Path {
+		case "/v1/embeddings":
+			// Out of order, as the index field allows
+			_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1,0]},{"index":0,"embedding":[1,0,0]}]}`))
+		case "/api/embed":
+			_, _ = w.Write([]byte(`{"embeddings":[[1,0,0],[0,1,0]]}`))
+		case "/v2/embed":
+			if req.InputType != "search_document" {
+				http.Error(w, "input_type "+req.InputType, http.StatusBadRequest)
+				return
+			}
+			// One text per request, as batch_size says
+			if len(req.Texts) != 1 {
+				http.Error(w, "batch too large", http.StatusBadRequest)
+				return
+			}
+			v := `[1,0,0]`
+			if req.Texts[0] == "b" {
+				v = `[0,1,0]`
+			}
+			_, _ = w.Write([]byte(`{"embeddings":{"float":[` + v + `]}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	setup := &config.Setup{Backend: map[string]config.BackendConfig{
+		"custom": {Type: "openai", BaseURL: srv.URL + "/v1"},
+		"local":  {Type: "ollama", BaseURL: srv.URL},
+		"cohere": {Type: "cohere", BaseURL: srv.URL + "/v2"},
+	}}
+	for _, c := range []struct {
+		backend  string
+		requests int
+	}{{"custom", 1}, {"local", 1}, {"cohere", 2}} {
+		setup.Context.Embeddings = config.EmbeddingsConfig{Backend: c.backend, Model: "m-" + c.backend, BatchSize: 1}
+		if c.backend != "cohere" {
+			setup.Context.Embeddings.BatchSize = 0
+		}
+		e, err := NewEmbedder(setup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Dimensions() != 0 {
+			t.Errorf("%s: unknown model has %d dimensions before the first call", c.backend, e.Dimensions())
+		}
+		for range 2 {
+			requests = 0
+			vectors, err := e.Embed(context.Background(), []string{"a", "b"})
+			if err != nil {
+				t.Fatalf("%s: %v", c.backend, err)
+			}
+			if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][1] != 1 {
+				t.Errorf("%s: unexpected vectors %v", c.backend, vectors)
+			}
+			if requests != c.requests {
+				t.Errorf("%s: %d requests, want %d", c.backend, requests, c.requests)
+			}
+			// The second time, the vectors come from the cache
+			c.requests = 0
+		}
+		if e.Dimensions() != 3 {
+			t.Errorf("%s: %d dimensions, want 3", c.backend, e.Dimensions())
+		}
+	}
+}
+
+func TestEmbeddingDimensions(t *testing.T) {
+	setup := &config.Setup{
+		Backend: map[string]config.BackendConfig{"openai": {Type: "openai"}},
+		Cache:   config.CacheConfig{Disabled: true},
+	}
+	setup.Context.Embeddings = config.EmbeddingsConfig{Backend: "openai"}
+	if e, _ := NewEmbedder(setup); e.Dimensions() != 1536 {
+		t.Errorf("text-embedding-3-small: %d dimensions, want 1536", e.Dimensions())
+	}
+	setup.Context.Embeddings.Dimensions = 512
+	if e, _ := NewEmbedder(setup); e.Dimensions() != 512 {
+		t.Errorf("configured: %d dimensions, want 512", e.Dimensions())
+	}
+}
+
+// fixedEmbedder returns a vector of its dimensions per text, counting them
+type fixedEmbedder struct {
+	dims  int
+	calls int
+}
+
+func (f *fixedEmbedder) Model() string { return "org/fixed:v1" }
+
+func (f *fixedEmbedder) Dimensions() int { return f.dims }
+
+func (f *fixedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	f.calls += len(texts)
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = make([]float32, f.dims)
+		out[i][0] = float32(len(text))
+	}
+	return out, nil
+}
+
+func TestCachedEmbedder(t *testing.T) {
+	dir := t.TempDir()
+	inner := &fixedEmbedder{dims: 4}
+	e := NewCachedEmbedder(inner, dir)
+	ctx := context.Background()
+	if _, err := e.Embed(ctx, []string{"a", "bb"}); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(e.Path()) != "org_fixed_v1.vec" {
+		t.Errorf("unexpected cache file %s", e.Path())
+	}
+
+	// A new process reads the vectors back and embeds only new texts;
+	// queries are cached apart from documents
+	e = NewCachedEmbedder(inner, dir)
+	vectors, err := e.Embed(ctx, []string{"bb", "ccc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 3 || vectors[0][0] != 2 || vectors[1][0] != 3 {
+		t.Errorf("calls = %d, vectors = %v; want 3 calls", inner.calls, vectors)
+	}
+	if _, err := e.Embed(WithQueryEmbedding(ctx), []string{"a"}); err != nil || inner.calls != 4 {
+		t.Errorf("a query should not reuse the document's vector (%d calls)", inner.calls)
+	}
+
+	// Vectors of other dimensions are embedded again
+	inner.dims = 8
+	vectors, _ = NewCachedEmbedder(inner, dir).Embed(ctx, []string{"a"})
+	if inner.calls != 5 || len(vectors[0]) != 8 {
+		t.Errorf("calls = %d, dimensions = %d; want 5 calls and 8 dimensions", inner.calls, len(vectors[0]))
+	}
+}
@@ -11,20 +11,30 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"gptcode/internal/httpclient"
 )
 
 type OllamaProvider struct {
-	BaseURL string
+	BaseURL    string
+	Retry      RetryPolicy
+	HTTPClient *http.Client
 }
 
-func NewOllama(baseURL string) *OllamaProvider {
+// NewOllama returns a provider for the Ollama server at baseURL, with the
+// retry policy and network settings configured for backendName.
+func NewOllama(baseURL, backendName string) *OllamaProvider {
 	if baseURL == "" {
 		baseURL = "http://localhost:11434"
 	}
 	if !strings.HasSuffix(baseURL, "/api/chat") {
 		baseURL = baseURL + "/api/chat"
 	}
-	return &OllamaProvider{BaseURL: baseURL}
+	return &OllamaProvider{
+		BaseURL:    baseURL,
+		Retry:      RetryPolicyFor(backendName),
+		HTTPClient: httpclient.ForBackend(backendName),
+	}
 }
 
 type ollamaReq struct {
@@ -45,6 +55,22 @@ type ollamaResp struct {
 		Content   string           `json:"content"`
 		ToolCalls []ollamaToolCall `json:"tool_calls"`
 	} `json:"message"`
+	// The token counts come with the final message of a stream
+	Done            bool `json:"done"`
+	PromptEvalCount int  `json:"prompt_eval_count"`
+	EvalCount       int  `json:"eval_count"`
+}
+
+// tokenUsage returns the counts Ollama reported, or nil
+func (r *ollamaResp) tokenUsage() *TokenUsage {
+	if r.PromptEvalCount == 0 && r.EvalCount == 0 {
+		return nil
+	}
+	return &TokenUsage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
 }
 
 type ollamaToolCall struct {
@@ -56,7 +82,34 @@ type ollamaToolCall struct {
 	} `json:"function"`
 }
 
+func (o *OllamaProvider) newRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.BaseURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
+
+// client returns the configured HTTP client with the given timeout.
+func (o *OllamaProvider) client(timeout time.Duration) *http.Client {
+	client := http.Client{}
+	if o.HTTPClient != nil {
+		client = *o.HTTPClient
+	}
+	client.Timeout = timeout
+	return &client
+}
+
 func (o *OllamaProvider) ChatStream(ctx context.Context, req ChatRequest, callback func(chunk string)) error {
+	_, err := o.stream(ctx, req, callback)
+	return err
+}
+
+// stream streams the response text to callback, returning the usage of
+// the final message
+func (o *OllamaProvider) stream(ctx context.Context, req ChatRequest, callback func(chunk string)) (*TokenUsage, error) {
+	req = FitRequest("ollama", req)
 	messages := []ollamaMessage{
 		{Role: "system", Content: req.SystemPrompt},
 	}
@@ -84,15 +137,15 @@ func (o *OllamaProvider) ChatStream(ctx context.Context, req ChatRequest, callba
 	}
 	b, _ := json.Marshal(body)
 
-	httpReq, _ := http.NewRequestWithContext(ctx, "POST", o.BaseURL, bytes.NewReader(b))
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, err := doWithRetry(ctx, o.client(0), o.BaseURL, o.Retry, func() (*http.Request, error) {
+		return o.newRequest(ctx, b)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	var usage *TokenUsage
 	scanner := bufio.NewScanner(resp.Body)
 	for scanner.Scan() {
 		var chunk ollamaResp
@@ -103,12 +156,44 @@ func (o *OllamaProvider) ChatStream(ctx context.Context, req ChatRequest, callba
 		if chunk.Message.Content != "" {
 			callback(chunk.Message.Content)
 		}
+		if chunk.Done {
+			usage = chunk.tokenUsage()
+		}
 	}
 
-	return scanner.Err()
+	return usage, scanner.Err()
+}
+
+// StreamChat streams plain conversations. Tool calling needs the complete
+// message, so requests with tools fall back to Chat and deliver the text in
+// one chunk.
+func (o *OllamaProvider) StreamChat(ctx context.Context, req ChatRequest, onChunk func(chunk string)) (*ChatResponse, error) {
+	if len(req.Tools) > 0 {
+		return chatAsStream(ctx, o, req, onChunk)
+	}
+	if err := meterCheck(); err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	usage, err := o.stream(ctx, req, func(chunk string) {
+		text.WriteString(chunk)
+		if onChunk != nil {
+			onChunk(chunk)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	response := &ChatResponse{Text: text.String(), TokenUsage: usage}
+	meterRecord("ollama", req.Model, req, response)
+	return response, nil
 }
 
 func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	if err := meterCheck(); err != nil {
+		return nil, err
+	}
+	req = FitRequest("ollama", req)
 	messages := []ollamaMessage{
 		{Role: "system", Content: req.SystemPrompt},
 	}
@@ -147,11 +232,9 @@ func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	}
 	b, _ := json.Marshal(body)
 
-	httpReq, _ := http.NewRequestWithContext(ctx, "POST", o.BaseURL, bytes.NewReader(b))
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Do(httpReq)
+	resp, err := doWithRetry(ctx, o.client(120*time.Second), o.BaseURL, o.Retry, func() (*http.Request, error) {
+		return o.newRequest(ctx, b)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -163,7 +246,8 @@ func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 	}
 
 	response := &ChatResponse{
-		Text: or.Message.Content,
+		Text:       or.Message.Content,
+		TokenUsage: or.tokenUsage(),
 	}
 
 	if len(or.Message.ToolCalls) > 0 {
@@ -189,6 +273,7 @@ func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatRespon
 			response.Text = strings.Split(or.Message.Content, "<function=")[0]
 		}
 	}
+	meterRecord("ollama", req.Model, req, response)
 
 	return response, nil
 }
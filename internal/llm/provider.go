@@ -1,6 +1,10 @@
 package llm
 
-import "context"
+import (
+	"context"
+
+	"gptcode/internal/config"
+)
 
 type Provider interface {
 	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
@@ -24,9 +28,17 @@ type ChatMessage struct {
 }
 
 type ChatResponse struct {
-	Text       string
-	ToolCalls  []ChatToolCall
+	Text      string
+	ToolCalls []ChatToolCall
+	// TokenUsage is the usage the provider reported, nil when it reported
+	// none
 	TokenUsage *TokenUsage
+	// Backend answered the request; Cost is its price in USD when a cost
+	// meter is running and the model has a catalog price
+	Backend string  `json:"-"`
+	Cost    float64 `json:"-"`
+	// Cached is set when the response came from the response cache
+	Cached bool `json:"-"`
 }
 
 type TokenUsage struct {
@@ -40,3 +52,29 @@ type ChatToolCall struct {
 	Name      string
 	Arguments string
 }
+
+// NewForBackend returns the provider for a configured backend: Ollama,
+// Anthropic's Messages API, or an OpenAI-compatible chat completions API.
+func NewForBackend(backendName string, cfg config.BackendConfig) Provider {
+	switch cfg.Type {
+	case "ollama":
+		return NewOllama(cfg.BaseURL, backendName)
+	case "anthropic":
+		return NewAnthropic(cfg.BaseURL, backendName)
+	}
+	return NewChatCompletion(cfg.BaseURL, backendName)
+}
+
+// NewQueryProvider returns the provider of backendName, or of the default
+// backend when it is "", with the model of its query agent in the selected
+// profile. A nil setup stands for the effective one.
+func NewQueryProvider(setup *config.Setup, backendName string) (Provider, string) {
+	if setup == nil {
+		setup, _ = config.LoadEffectiveSetup()
+	}
+	if backendName == "" {
+		backendName = setup.Defaults.Backend
+	}
+	backendCfg := setup.Backend[backendName]
+	return NewForBackend(backendName, backendCfg), backendCfg.GetModelForAgentWithProfile("query", setup.Defaults.Profile)
+}
@@ -0,0 +1,381 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gptcode/internal/config"
+)
+
+// RetryPolicy controls how provider requests are retried on transient errors.
+type RetryPolicy struct {
+	MaxAttempts      int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:      3,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         10 * time.Second,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	}
+}
+
+// RetryPolicyFor returns the retry policy for a backend, applying any
+// overrides from the backend's retry section in setup.yaml.
+func RetryPolicyFor(backendName string) RetryPolicy {
+	policy := DefaultRetryPolicy()
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return policy
+	}
+	cfg, ok := setup.Backend[backendName]
+	if !ok || cfg.Retry == nil {
+		return policy
+	}
+	if cfg.Retry.MaxAttempts > 0 {
+		policy.MaxAttempts = cfg.Retry.MaxAttempts
+	}
+	if cfg.Retry.BaseDelayMs > 0 {
+		policy.BaseDelay = time.Duration(cfg.Retry.BaseDelayMs) * time.Millisecond
+	}
+	if cfg.Retry.MaxDelayMs > 0 {
+		policy.MaxDelay = time.Duration(cfg.Retry.MaxDelayMs) * time.Millisecond
+	}
+	if cfg.Retry.BreakerThreshold > 0 {
+		policy.BreakerThreshold = cfg.Retry.BreakerThreshold
+	}
+	if cfg.Retry.BreakerCooldownSec > 0 {
+		policy.BreakerCooldown = time.Duration(cfg.Retry.BreakerCooldownSec) * time.Second
+	}
+	return policy
+}
+
+// backoff returns the delay before the given retry (1-based) using full
+// jitter over an exponentially growing window.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	window := p.BaseDelay << uint(retry-1)
+	if window <= 0 || window > p.MaxDelay {
+		window = p.MaxDelay
+	}
+	if window <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(window)) + 1)
+}
+
+// ErrCircuitOpen is returned when a backend has failed repeatedly and is
+// temporarily not accepting requests.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// isRetryableError reports whether a transport error is likely transient:
+// a timeout, or a connection refused, reset or closed by the server. Other
+// errors, such as an unknown host, a bad URL or a TLS failure, fail the
+// same way on every attempt.
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return strings.Contains(err.Error(), "connection reset")
+}
+
+// isRetryableStatus reports whether an HTTP status indicates a transient
+// server-side condition. 501 Not Implemented is answered the same way
+// every time.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || (status >= 500 && status != http.StatusNotImplemented)
+}
+
+// retryAfterHeader returns the wait a response asks for in its Retry-After
+// header, in seconds or as a date, or 0 when it asks for none.
+func retryAfterHeader(resp *http.Response, now time.Time) time.Duration {
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// doWithRetry sends the request produced by newReq, retrying transient
+// failures with jittered exponential backoff, or after the wait a response's
+// Retry-After asks for. The request as a whole, retries included, counts as
+// one success or failure in the circuit breaker for key. newReq is called
+// once per attempt so request bodies can be replayed.
+func doWithRetry(ctx context.Context, client *http.Client, key string, policy RetryPolicy, newReq func() (*http.Request, error)) (*http.Response, error) {
+	breaker := breakerFor(key, policy)
+	if !breaker.allow() {
+		return nil, fmt.Errorf("%w for %s (retry after %s)", ErrCircuitOpen, key, breaker.retryAfter().Round(time.Second))
+	}
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			breaker.release()
+			return nil, err
+		}
+
+		var wait time.Duration
+		resp, err := client.Do(req)
+		switch {
+		case err != nil && isRetryableError(err):
+			lastErr = err
+		case err != nil:
+			breaker.release()
+			return nil, err
+		case isRetryableStatus(resp.StatusCode):
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+			wait = retryAfterHeader(resp, time.Now())
+		default:
+			breaker.recordSuccess()
+			return resp, nil
+		}
+
+		if attempt == attempts {
+			break
+		}
+
+		delay := wait
+		if delay == 0 {
+			delay = policy.backoff(attempt)
+		}
+		if os.Getenv("GPTCODE_DEBUG") == "1" {
+			fmt.Fprintf(os.Stderr, "[RETRY] %s attempt %d/%d failed: %v (waiting %s)\n", key, attempt, attempts, lastErr, delay)
+		}
+		select {
+		case <-ctx.Done():
+			breaker.release()
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	breaker.recordFailure(lastErr)
+	return nil, fmt.Errorf("request to %s failed after %d attempts: %w", key, attempts, lastErr)
+}
+
+type breakerState string
+
+const (
+	breakerClosed   breakerState = "closed"
+	breakerOpen     breakerState = "open"
+	breakerHalfOpen breakerState = "half-open"
+)
+
+// CircuitBreaker stops sending requests to a backend after consecutive
+// failed requests. Once the cooldown elapses it lets a single probe request
+// through, refusing others while it runs; the probe's outcome closes the
+// breaker or opens it for another cooldown.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	key       string
+	state     breakerState
+	probing   bool
+	failures  int
+	threshold int
+	cooldown  time.Duration
+	openedAt  time.Time
+	lastError string
+}
+
+var (
+	breakersMu sync.Mutex
+	breakers   = map[string]*CircuitBreaker{}
+)
+
+func breakerFor(key string, policy RetryPolicy) *CircuitBreaker {
+	breakersMu.Lock()
+	defer breakersMu.Unlock()
+	if b, ok := breakers[key]; ok {
+		return b
+	}
+	b := &CircuitBreaker{
+		key:       key,
+		state:     breakerClosed,
+		threshold: policy.BreakerThreshold,
+		cooldown:  policy.BreakerCooldown,
+	}
+	breakers[key] = b
+	return b
+}
+
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.state == breakerClosed:
+		return true
+	case b.probing:
+		return false
+	case b.state == breakerHalfOpen || time.Since(b.openedAt) >= b.cooldown:
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	}
+	return false
+}
+
+// release ends a request that neither succeeded nor failed for the backend,
+// such as a cancelled one, so another probe may be sent.
+func (b *CircuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) retryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cooldown - time.Since(b.openedAt)
+}
+
+func (b *CircuitBreaker) recordSuccess() {
+	b.mu.Lock()
+	changed := b.state != breakerClosed || b.failures > 0
+	b.state = breakerClosed
+	b.probing = false
+	b.failures = 0
+	b.lastError = ""
+	b.mu.Unlock()
+	if changed {
+		saveHealth()
+	}
+}
+
+func (b *CircuitBreaker) recordFailure(err error) {
+	b.mu.Lock()
+	b.probing = false
+	b.failures++
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == breakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+	b.mu.Unlock()
+	saveHealth()
+}
+
+// BackendHealth is a snapshot of a backend's circuit breaker.
+type BackendHealth struct {
+	Backend             string    `json:"backend"`
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	OpenedAt            time.Time `json:"opened_at,omitempty"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+func healthPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gptcode", "backend_health.json")
+}
+
+// saveHealth merges the in-process breaker states into the health file so
+// `gptcode backend status` can report them after the run.
+func saveHealth() {
+	path := healthPath()
+	if path == "" {
+		return
+	}
+
+	existing, _ := LoadBackendHealth()
+	merged := make(map[string]BackendHealth, len(existing))
+	for _, h := range existing {
+		merged[h.Backend] = h
+	}
+
+	breakersMu.Lock()
+	for key, b := range breakers {
+		b.mu.Lock()
+		merged[key] = BackendHealth{
+			Backend:             key,
+			State:               string(b.state),
+			ConsecutiveFailures: b.failures,
+			LastError:           b.lastError,
+			OpenedAt:            b.openedAt,
+			UpdatedAt:           time.Now(),
+		}
+		b.mu.Unlock()
+	}
+	breakersMu.Unlock()
+
+	list := make([]BackendHealth, 0, len(merged))
+	for _, h := range merged {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Backend < list[j].Backend })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	_ = os.WriteFile(path, data, 0o644)
+}
+
+// LoadBackendHealth reads the last recorded breaker state of each backend.
+func LoadBackendHealth() ([]BackendHealth, error) {
+	path := healthPath()
+	if path == "" {
+		return nil, fmt.Errorf("could not determine home directory")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []BackendHealth
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,243 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func testPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:      3,
+		BaseDelay:        time.Millisecond,
+		MaxDelay:         5 * time.Millisecond,
+		BreakerThreshold: 2,
+		BreakerCooldown:  time.Hour,
+	}
+}
+
+func TestDoWithRetry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	t.Run("retries transient 5xx then succeeds", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		policy := testPolicy()
+		policy.BreakerThreshold = 10
+		resp, err := doWithRetry(context.Background(), srv.Client(), "retry-ok", policy, func() (*http.Request, error) {
+			return http.NewRequest("GET", srv.URL, nil)
+		})
+		if err != nil {
+			t.Fatalf("expected success, got %v", err)
+		}
+		resp.Body.Close()
+		if calls != 3 {
+			t.Errorf("expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		resp, err := doWithRetry(context.Background(), srv.Client(), "retry-401", testPolicy(), func() (*http.Request, error) {
+			return http.NewRequest("GET", srv.URL, nil)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("does not retry 501", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusNotImplemented)
+		}))
+		defer srv.Close()
+
+		resp, err := doWithRetry(context.Background(), srv.Client(), "retry-501", testPolicy(), func() (*http.Request, error) {
+			return http.NewRequest("GET", srv.URL, nil)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("waits as long as Retry-After asks", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+
+		start := time.Now()
+		resp, err := doWithRetry(context.Background(), srv.Client(), "retry-after", testPolicy(), func() (*http.Request, error) {
+			return http.NewRequest("GET", srv.URL, nil)
+		})
+		if err != nil {
+			t.Fatalf("expected success, got %v", err)
+		}
+		resp.Body.Close()
+		if elapsed := time.Since(start); elapsed < time.Second {
+			t.Errorf("retried after %s, before the second Retry-After asked for", elapsed)
+		}
+	})
+
+	t.Run("opens circuit after repeated failures", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		newReq := func() (*http.Request, error) { return http.NewRequest("GET", srv.URL, nil) }
+
+		// Each request fails once, whatever its number of attempts
+		for i := 0; i < 2; i++ {
+			if _, err := doWithRetry(context.Background(), srv.Client(), "retry-breaker", testPolicy(), newReq); err == nil || errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("request %d: expected the request to fail, got %v", i+1, err)
+			}
+		}
+		_, err := doWithRetry(context.Background(), srv.Client(), "retry-breaker", testPolicy(), newReq)
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected circuit to open, got %v", err)
+		}
+		if calls != 6 {
+			t.Errorf("expected breaker to stop after 2 requests of 3 attempts, got %d calls", calls)
+		}
+
+		health, err := LoadBackendHealth()
+		if err != nil {
+			t.Fatalf("failed to load health: %v", err)
+		}
+		found := false
+		for _, h := range health {
+			if h.Backend == "retry-breaker" && h.State == string(breakerOpen) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected open breaker in health file, got %+v", health)
+		}
+	})
+}
+
+func TestBackoffBounds(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for retry := 1; retry <= 10; retry++ {
+		d := p.backoff(retry)
+		if d <= 0 || d > time.Second {
+			t.Errorf("backoff(%d) = %s out of bounds", retry, d)
+		}
+	}
+}
+
+// timeoutError is a net.Error reporting whether it is a timeout
+type timeoutError bool
+
+func (e timeoutError) Error() string   { return "i/o timeout" }
+func (e timeoutError) Timeout() bool   { return bool(e) }
+func (e timeoutError) Temporary() bool { return false }
+
+func TestIsRetryableError(t *testing.T) {
+	urlErr := func(err error) error { return &url.Error{Op: "Post", URL: "https://api.example.com", Err: err} }
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{urlErr(timeoutError(true)), true},
+		{urlErr(&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), true},
+		{urlErr(&net.OpError{Op: "read", Err: syscall.ECONNRESET}), true},
+		{urlErr(io.ErrUnexpectedEOF), true},
+		// Errors that would fail the same way again
+		{urlErr(&net.DNSError{Err: "no such host", Name: "api.example.com", IsNotFound: true}), false},
+		{urlErr(errors.New("unsupported protocol scheme \"\"")), false},
+		{urlErr(timeoutError(false)), false},
+		{urlErr(context.Canceled), false},
+		{errors.New("tls: failed to verify certificate"), false},
+	}
+	for _, c := range cases {
+		if got := isRetryableError(c.err); got != c.want {
+			t.Errorf("isRetryableError(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestBreakerLetsOneProbeThrough(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	b := &CircuitBreaker{key: "probe", state: breakerClosed, threshold: 1, cooldown: time.Millisecond}
+	b.recordFailure(errors.New("down"))
+	if b.allow() {
+		t.Fatal("an open breaker should refuse requests during the cooldown")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("the probe should go through once the cooldown elapses")
+	}
+	if b.allow() {
+		t.Error("a second request should wait for the probe's outcome")
+	}
+	b.recordSuccess()
+	if !b.allow() || !b.allow() {
+		t.Error("a successful probe should close the breaker")
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"-1", 0},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+	}
+	for _, c := range cases {
+		resp := &http.Response{Header: http.Header{}}
+		if c.value != "" {
+			resp.Header.Set("Retry-After", c.value)
+		}
+		if got := retryAfterHeader(resp, now); got != c.want {
+			t.Errorf("retryAfterHeader(%q) = %s, want %s", c.value, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Example request from the AWS Signature Version 4 documentation.
+func TestSignSigV4KnownVector(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds := sigV4Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	signSigV4(req, nil, creds, "us-east-1", "iam", now)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization mismatch\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestChatCompletionCustomHeaders(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("TEST_ORG_ID", "org-42")
+
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer srv.Close()
+
+	provider := &ChatCompletionProvider{
+		BaseURL: srv.URL,
+		Headers: map[string]string{
+			"X-Org-Id":      "${TEST_ORG_ID}",
+			"Authorization": "Custom token-123",
+		},
+	}
+
+	resp, err := provider.Chat(context.Background(), ChatRequest{Model: "m", UserPrompt: "hi"})
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if resp.Text != "ok" {
+		t.Errorf("unexpected response: %q", resp.Text)
+	}
+	if got.Get("X-Org-Id") != "org-42" {
+		t.Errorf("expected expanded X-Org-Id, got %q", got.Get("X-Org-Id"))
+	}
+	if !strings.HasPrefix(got.Get("Authorization"), "Custom ") {
+		t.Errorf("expected custom Authorization header, got %q", got.Get("Authorization"))
+	}
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Streamer is implemented by providers that can deliver a response as it is
+// generated. StreamChat passes text to onChunk as it arrives and returns the
+// complete response, including tool calls.
+type Streamer interface {
+	StreamChat(ctx context.Context, req ChatRequest, onChunk func(chunk string)) (*ChatResponse, error)
+}
+
+// StreamChat streams req through p when p supports it. Other providers fall
+// back to Chat, with the full text delivered as a single chunk. A nil
+// onChunk makes this a plain Chat call. Calls whose ctx is marked WithCache
+// are answered from the response cache when possible.
+func StreamChat(ctx context.Context, p Provider, req ChatRequest, onChunk func(chunk string)) (*ChatResponse, error) {
+	cache := cacheFor(ctx)
+	if cache == nil {
+		return streamChat(ctx, p, req, onChunk)
+	}
+
+	key := cache.Key(req)
+	if resp, ok := cache.Get(key); ok {
+		if os.Getenv("GPTCODE_DEBUG") == "1" {
+			fmt.Fprintf(os.Stderr, "[CACHE] Hit for %s (%s)\n", req.Model, key[:12])
+		}
+		if onChunk != nil && resp.Text != "" {
+			onChunk(resp.Text)
+		}
+		return resp, nil
+	}
+	resp, err := streamChat(ctx, p, req, onChunk)
+	if err == nil {
+		_ = cache.Put(key, req.Model, resp)
+	}
+	return resp, err
+}
+
+func streamChat(ctx context.Context, p Provider, req ChatRequest, onChunk func(chunk string)) (*ChatResponse, error) {
+	if s, ok := p.(Streamer); ok && onChunk != nil {
+		return s.StreamChat(ctx, req, onChunk)
+	}
+	return chatAsStream(ctx, p, req, onChunk)
+}
+
+func chatAsStream(ctx context.Context, p Provider, req ChatRequest, onChunk func(chunk string)) (*ChatResponse, error) {
+	resp, err := p.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if onChunk != nil && resp.Text != "" {
+		onChunk(resp.Text)
+	}
+	return resp, nil
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChatCompletionStreamChat(t *testing.T) {
+	events := []string{
+		`{"choices":[{"delta":{"content":"Let me "}}]}`,
+		`{"choices":[{"delta":{"content":"check."}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"read_file","arguments":"{\"pa"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"th\":\"a.go\"}"}}]}}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}}`,
+		`[DONE]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+	}))
+	defer server.Close()
+
+	provider := &ChatCompletionProvider{APIKey: "test", BaseURL: server.URL, HTTPClient: server.Client()}
+
+	var chunks []string
+	resp, err := StreamChat(context.Background(), provider, ChatRequest{Model: "m", UserPrompt: "hi"}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(chunks, "|") != "Let me |check." {
+		t.Errorf("unexpected chunks: %q", chunks)
+	}
+	if resp.Text != "Let me check." {
+		t.Errorf("unexpected text: %q", resp.Text)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "call_1" || resp.ToolCalls[0].Name != "read_file" || resp.ToolCalls[0].Arguments != `{"path":"a.go"}` {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.TokenUsage == nil || resp.TokenUsage.PromptTokens != 12 || resp.TokenUsage.CompletionTokens != 7 || resp.TokenUsage.TotalTokens != 19 {
+		t.Errorf("unexpected usage: %+v", resp.TokenUsage)
+	}
+}
+
+func TestStreamUsage(t *testing.T) {
+	cases := map[string]struct {
+		provider func(url string) Provider
+		lines    []string
+	}{
+		"groq": {
+			provider: func(url string) Provider { return &ChatCompletionProvider{APIKey: "test", BaseURL: url} },
+			lines: []string{
+				`data: {"choices":[{"delta":{"content":"ok"}}]}`,
+				`data: {"choices":[{"delta":{},"finish_reason":"stop"}],"x_groq":{"usage":{"prompt_tokens":30,"completion_tokens":5}}}`,
+				`data: [DONE]`,
+			},
+		},
+		"ollama": {
+			provider: func(url string) Provider { return &OllamaProvider{BaseURL: url} },
+			lines: []string{
+				`{"message":{"content":"ok"},"done":false}`,
+				`{"message":{"content":""},"done":true,"prompt_eval_count":30,"eval_count":5}`,
+			},
+		},
+	}
+	for name, tc := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, line := range tc.lines {
+				fmt.Fprintf(w, "%s\n\n", line)
+			}
+		}))
+		resp, err := StreamChat(context.Background(), tc.provider(server.URL), ChatRequest{Model: "m", UserPrompt: "hi"}, func(string) {})
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if resp.Text != "ok" || resp.TokenUsage == nil || *resp.TokenUsage != (TokenUsage{PromptTokens: 30, CompletionTokens: 5, TotalTokens: 35}) {
+			t.Errorf("%s: unexpected response %q, usage %+v", name, resp.Text, resp.TokenUsage)
+		}
+	}
+}
+
+type staticProvider struct{ text string }
+
+func (p staticProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	return &ChatResponse{Text: p.text}, nil
+}
+
+func TestStreamChatFallback(t *testing.T) {
+	var chunks []string
+	resp, err := StreamChat(context.Background(), staticProvider{text: "whole answer"}, ChatRequest{}, func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || chunks[0] != "whole answer" || resp.Text != "whole answer" {
+		t.Errorf("expected one chunk with the full text, got %q", chunks)
+	}
+}
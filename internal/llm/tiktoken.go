@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// bpeTokenizer counts tokens exactly with one of OpenAI's BPE encodings. The
+// vocabularies ship with the binary; one is loaded the first time a model
+// using it is counted.
+type bpeTokenizer struct {
+	encoding string
+
+	once sync.Once
+	enc  *tiktoken.Tiktoken
+}
+
+func (t *bpeTokenizer) Count(text string) int {
+	t.once.Do(func() {
+		t.enc, _ = tiktoken.GetEncoding(t.encoding)
+	})
+	if t.enc == nil {
+		return estimateTokenizer{}.Count(text)
+	}
+	return len(t.enc.EncodeOrdinary(text))
+}
+
+func init() {
+	tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+
+	o200k := &bpeTokenizer{encoding: tiktoken.MODEL_O200K_BASE}
+	cl100k := &bpeTokenizer{encoding: tiktoken.MODEL_CL100K_BASE}
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-4.5", "gpt-5", "o1", "o3", "o4", "chatgpt-4o"} {
+		RegisterTokenizer(prefix, o200k)
+	}
+	for _, prefix := range []string{"gpt-4", "gpt-3.5", "text-embedding-3", "text-embedding-ada-002"} {
+		RegisterTokenizer(prefix, cl100k)
+	}
+}
@@ -0,0 +1,336 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"gptcode/internal/catalog"
+)
+
+// Tokenizer counts the tokens a model sees for a piece of text
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// estimateTokenizer approximates the BPE tokenizers of current models
+// (cl100k/o200k, Llama 3, Claude) without shipping their vocabularies. Text
+// is split the way those tokenizers pre-tokenize it: words with their
+// leading space, digit groups, punctuation runs and whitespace. Each piece
+// is then priced by length, since common words are a single token and long
+// identifiers break into several.
+type estimateTokenizer struct{}
+
+func (estimateTokenizer) Count(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		start := i
+		i += size
+		switch {
+		case r == ' ' && i < len(text) && isWordByte(text[i]):
+			// A word with its leading space, as BPE merges them
+			fallthrough
+		case unicode.IsLetter(r) && r < utf8.RuneSelf:
+			for i < len(text) && isWordByte(text[i]) {
+				i++
+			}
+			tokens += 1 + (i-start-1)/6
+		case unicode.IsDigit(r):
+			for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+				i++
+			}
+			// Digits are grouped in threes
+			tokens += (i - start + 2) / 3
+		case unicode.IsSpace(r):
+			for i < len(text) && (text[i] == ' ' || text[i] == '\t' || text[i] == '\n' || text[i] == '\r') {
+				i++
+			}
+			tokens++
+		case r >= utf8.RuneSelf:
+			// CJK and other non-Latin text is about one token per character
+			tokens++
+		default:
+			for i < len(text) && isPunct(text[i]) {
+				i++
+			}
+			// Common operators and brackets pair up ("()", ":=", "//")
+			tokens += (i - start + 1) / 2
+		}
+	}
+	return tokens
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && c > ' ' && !isWordByte(c) && !(c >= '0' && c <= '9')
+}
+
+var (
+	tokenizersMu sync.RWMutex
+	tokenizers   = map[string]Tokenizer{}
+)
+
+// RegisterTokenizer uses t to count tokens for models whose name starts
+// with prefix, e.g. an exact BPE tokenizer for "gpt-4o".
+func RegisterTokenizer(prefix string, t Tokenizer) {
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+	tokenizers[prefix] = t
+}
+
+// TokenizerFor returns the tokenizer registered for the longest prefix of
+// model (ignoring a vendor prefix such as "openai/"), or the estimating
+// tokenizer.
+func TokenizerFor(model string) Tokenizer {
+	if _, name, found := strings.Cut(model, "/"); found {
+		model = name
+	}
+	tokenizersMu.RLock()
+	defer tokenizersMu.RUnlock()
+	var best Tokenizer
+	bestLen := -1
+	for prefix, t := range tokenizers {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = t, len(prefix)
+		}
+	}
+	if best == nil {
+		return estimateTokenizer{}
+	}
+	return best
+}
+
+// CountTokens counts text's tokens for model.
+func CountTokens(model, text string) int {
+	return TokenizerFor(model).Count(text)
+}
+
+// defaultContextWindow is assumed for models the catalog does not list
+const defaultContextWindow = 32768
+
+// familyContextWindows covers model families the catalog may not list under
+// the name a backend uses, such as Anthropic's dated model IDs
+var familyContextWindows = []struct {
+	prefix string
+	window int
+}{
+	{"claude", 200000},
+	{"gemini", 1000000},
+	{"gpt-4.1", 1000000},
+	{"gpt-4o", 128000},
+	{"gpt-5", 400000},
+	{"o1", 200000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"llama-3", 128000},
+	{"llama3", 128000},
+	{"deepseek", 64000},
+}
+
+// ContextWindow returns the model's context window in tokens from the model
+// catalog. GPTCODE_CONTEXT_WINDOW overrides it, e.g. for an Ollama model run
+// with a smaller num_ctx.
+func ContextWindow(backend, model string) int {
+	if env := os.Getenv("GPTCODE_CONTEXT_WINDOW"); env != "" {
+		if n, err := strconv.Atoi(env); err == nil && n > 0 {
+			return n
+		}
+	}
+	if n := catalog.ContextWindow(backend, model); n > 0 {
+		return n
+	}
+	name := strings.ToLower(model)
+	if _, after, found := strings.Cut(name, "/"); found {
+		name = after
+	}
+	for _, f := range familyContextWindows {
+		if strings.HasPrefix(name, f.prefix) {
+			return f.window
+		}
+	}
+	return defaultContextWindow
+}
+
+// outputReserve is the part of the window left for the response
+func outputReserve(window int) int {
+	return min(window/4, 8192)
+}
+
+// ToolResultTokens is how many tokens a single tool result may take for
+// model: a tenth of its context window, within sensible bounds.
+func ToolResultTokens(model string) int {
+	return clampTokens(ContextWindow("", model)/10, 1500, 8000)
+}
+
+// SnippetTokens is how many tokens each of n files added as context may take.
+func SnippetTokens(backend, model string, n int) int {
+	return clampTokens(ContextWindow(backend, model)/(10*max(n, 1)), 400, 4000)
+}
+
+func clampTokens(n, lo, hi int) int {
+	return max(lo, min(n, hi))
+}
+
+// TruncateToTokens shortens text to about maxTokens, keeping its first and
+// last lines since those usually hold the declarations and the latest
+// output. Text that already fits is returned unchanged.
+func TruncateToTokens(text string, maxTokens int, tok Tokenizer) string {
+	total := tok.Count(text)
+	if total <= maxTokens {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	headBudget, tailBudget := maxTokens*3/5, maxTokens*2/5
+	head, used := 0, 0
+	for head < len(lines) {
+		n := tok.Count(lines[head]) + 1
+		if used+n > headBudget {
+			break
+		}
+		used += n
+		head++
+	}
+	tail, used := len(lines), 0
+	for tail > head {
+		n := tok.Count(lines[tail-1]) + 1
+		if used+n > tailBudget {
+			break
+		}
+		used += n
+		tail--
+	}
+	if tail > head && (head > 0 || tail < len(lines)) {
+		return fmt.Sprintf("%s\n\n... [%d lines omitted] ...\n\n%s",
+			strings.Join(lines[:head], "\n"), tail-head, strings.Join(lines[tail:], "\n"))
+	}
+
+	// A few very long lines: cut by the share of the text that fits
+	cut := int(math.Floor(float64(len(text)) * float64(maxTokens) / float64(total)))
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "\n\n... [truncated]"
+}
+
+// messageTokens estimates a message's tokens, including its tool calls and
+// the few tokens of framing every message costs.
+func messageTokens(msg ChatMessage, tok Tokenizer) int {
+	n := 4 + tok.Count(msg.Content)
+	for _, tc := range msg.ToolCalls {
+		n += 4 + tok.Count(tc.Name) + tok.Count(tc.Arguments)
+	}
+	return n
+}
+
+// FitMessages drops the oldest messages until the rest fit in budget
+// tokens. A leading user message (the task) is always kept, as is the most
+// recent exchange, whose content is truncated if it alone is too large. An
+// assistant message and the tool results answering its calls are kept or
+// dropped together, so no tool result is left without its call.
+func FitMessages(messages []ChatMessage, budget int, tok Tokenizer) []ChatMessage {
+	total := 0
+	for _, msg := range messages {
+		total += messageTokens(msg, tok)
+	}
+	if total <= budget || len(messages) == 0 {
+		return messages
+	}
+
+	var pinned []ChatMessage
+	rest := messages
+	if messages[0].Role == "user" && len(messages) > 1 {
+		pinned, rest = messages[:1], messages[1:]
+		budget -= messageTokens(messages[0], tok)
+	}
+
+	// Split into units that must stay together, newest last
+	var units [][]ChatMessage
+	for i := 0; i < len(rest); {
+		j := i + 1
+		if len(rest[i].ToolCalls) > 0 {
+			for j < len(rest) && rest[j].Role == "tool" {
+				j++
+			}
+		}
+		units = append(units, rest[i:j])
+		i = j
+	}
+
+	keep := len(units)
+	used := 0
+	for keep > 0 {
+		n := 0
+		for _, msg := range units[keep-1] {
+			n += messageTokens(msg, tok)
+		}
+		if used+n > budget && keep < len(units) {
+			break
+		}
+		used += n
+		keep--
+	}
+
+	out := append([]ChatMessage{}, pinned...)
+	for _, unit := range units[keep:] {
+		out = append(out, unit...)
+	}
+	if used > budget {
+		out = shrinkMessages(out, used-budget, tok)
+	}
+	if os.Getenv("GPTCODE_DEBUG") == "1" && keep > 0 {
+		dropped := 0
+		for _, unit := range units[:keep] {
+			dropped += len(unit)
+		}
+		fmt.Fprintf(os.Stderr, "[TOKENS] Dropped %d older messages to fit %d tokens\n", dropped, budget)
+	}
+	return out
+}
+
+// shrinkMessages truncates the largest messages until excess tokens are
+// saved.
+func shrinkMessages(messages []ChatMessage, excess int, tok Tokenizer) []ChatMessage {
+	out := append([]ChatMessage{}, messages...)
+	for excess > 0 {
+		largest, size := -1, 0
+		for i := range out {
+			if n := tok.Count(out[i].Content); n > size && n > 100 {
+				largest, size = i, n
+			}
+		}
+		if largest < 0 {
+			break
+		}
+		target := max(size-excess, size/2)
+		out[largest].Content = TruncateToTokens(out[largest].Content, target, tok)
+		excess -= size - tok.Count(out[largest].Content)
+	}
+	return out
+}
+
+// FitRequest trims req's messages so the request and a response fit in the
+// model's context window. The system prompt, tools and user prompt are
+// always sent in full.
+func FitRequest(backend string, req ChatRequest) ChatRequest {
+	tok := TokenizerFor(req.Model)
+	window := ContextWindow(backend, req.Model)
+	budget := window - outputReserve(window) - tok.Count(req.SystemPrompt) - tok.Count(req.UserPrompt)
+	if len(req.Tools) > 0 {
+		if data, err := json.Marshal(req.Tools); err == nil {
+			budget -= tok.Count(string(data))
+		}
+	}
+	req.Messages = FitMessages(req.Messages, max(budget, 0), tok)
+	return req
+}
@@ -0,0 +1,137 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokenizer(t *testing.T) {
+	tok := estimateTokenizer{}
+	cases := []struct {
+		text     string
+		min, max int
+	}{
+		{"", 0, 0},
+		{"hello world", 2, 2},
+		{"The quick brown fox jumps over the lazy dog.", 9, 11},
+		{"func (c *Client) Close() error {\n\treturn nil\n}", 12, 20},
+		{"1234567890", 4, 4},
+		{"こんにちは", 5, 5},
+	}
+	for _, c := range cases {
+		if n := tok.Count(c.text); n < c.min || n > c.max {
+			t.Errorf("Count(%q) = %d, want %d-%d", c.text, n, c.min, c.max)
+		}
+	}
+}
+
+type wordTokenizer struct{}
+
+func (wordTokenizer) Count(text string) int { return len(strings.Fields(text)) }
+
+func TestTokenizerFor(t *testing.T) {
+	RegisterTokenizer("words-", wordTokenizer{})
+	defer func() {
+		tokenizersMu.Lock()
+		delete(tokenizers, "words-")
+		tokenizersMu.Unlock()
+	}()
+	if _, ok := TokenizerFor("vendor/words-1").(wordTokenizer); !ok {
+		t.Error("registered tokenizer should be used, ignoring the vendor prefix")
+	}
+	if _, ok := TokenizerFor("other").(estimateTokenizer); !ok {
+		t.Error("unknown models should use the estimate")
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	t.Setenv("GPTCODE_CONTEXT_WINDOW", "4096")
+	if n := ContextWindow("ollama", "anything"); n != 4096 {
+		t.Errorf("override ignored, got %d", n)
+	}
+	t.Setenv("GPTCODE_CONTEXT_WINDOW", "")
+	if n := ContextWindow("anthropic", "claude-unlisted-20990101"); n != 200000 {
+		t.Errorf("claude family window = %d", n)
+	}
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	tok := wordTokenizer{}
+	var lines []string
+	for i := 0; i < 100; i++ {
+		lines = append(lines, "line with four words")
+	}
+	text := strings.Join(lines, "\n")
+
+	if got := TruncateToTokens("short", 10, tok); got != "short" {
+		t.Errorf("text that fits should be unchanged, got %q", got)
+	}
+	got := TruncateToTokens(text, 100, tok)
+	if !strings.Contains(got, "lines omitted") || !strings.HasPrefix(got, "line with") || !strings.HasSuffix(got, "four words") {
+		t.Errorf("expected head and tail with a marker, got %q", got)
+	}
+	if n := tok.Count(got); n > 110 {
+		t.Errorf("truncated text has %d tokens, want about 100", n)
+	}
+
+	long := strings.Repeat("word ", 1000)
+	if got := TruncateToTokens(long, 100, tok); tok.Count(got) > 105 || !strings.HasSuffix(got, "[truncated]") {
+		t.Errorf("a single long line should be cut, got %d tokens", tok.Count(got))
+	}
+}
+
+func TestFitMessages(t *testing.T) {
+	tok := wordTokenizer{}
+	words := func(n int) string { return strings.TrimSpace(strings.Repeat("w ", n)) }
+	messages := []ChatMessage{
+		{Role: "user", Content: "the task"},
+		{Role: "assistant", Content: words(50), ToolCalls: []ChatToolCall{{ID: "1", Name: "read_file"}}},
+		{Role: "tool", Content: words(100), ToolCallID: "1"},
+		{Role: "assistant", Content: words(20)},
+		{Role: "user", Content: words(20)},
+	}
+
+	if got := FitMessages(messages, 1000, tok); len(got) != len(messages) {
+		t.Errorf("messages that fit should be kept, got %d", len(got))
+	}
+
+	got := FitMessages(messages, 100, tok)
+	if len(got) != 3 || got[0].Content != "the task" || got[1].Role != "assistant" || got[2].Role != "user" {
+		t.Errorf("expected the task and the latest messages, got %+v", got)
+	}
+	for _, msg := range got {
+		if msg.Role == "tool" {
+			t.Error("a tool result must not be kept without its call")
+		}
+	}
+
+	// The latest exchange alone is too large: its content is truncated
+	big := []ChatMessage{{Role: "user", Content: "task"}, {Role: "user", Content: words(500)}}
+	got = FitMessages(big, 200, tok)
+	if len(got) != 2 || tok.Count(got[1].Content) > 200 {
+		t.Errorf("the latest message should be truncated to fit, got %d tokens", tok.Count(got[1].Content))
+	}
+	if tok.Count(big[1].Content) != 500 {
+		t.Error("FitMessages must not modify its input")
+	}
+}
+
+func TestBPETokenizer(t *testing.T) {
+	cases := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4o-mini", "hello world", 2},
+		{"openai/gpt-4.1", "tiktoken is great!", 6},
+		{"gpt-4-turbo", "tiktoken is great!", 6},
+	}
+	for _, c := range cases {
+		if _, ok := TokenizerFor(c.model).(*bpeTokenizer); !ok {
+			t.Fatalf("%s should use a BPE tokenizer", c.model)
+		}
+		if n := CountTokens(c.model, c.text); n != c.want {
+			t.Errorf("CountTokens(%s, %q) = %d, want %d", c.model, c.text, n, c.want)
+		}
+	}
+}
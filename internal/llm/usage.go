@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"encoding/json"
+	"time"
+
+	"gptcode/internal/observability"
+)
+
+// meterCheck returns an error once the run's cost budget is spent.
+func meterCheck() error {
+	if m := observability.ActiveCostMeter(); m != nil {
+		return m.Check()
+	}
+	return nil
+}
+
+// meterRecord reports a call's token usage to the run's cost meter, noting
+// the backend and cost on the response. When the provider reported no usage
+// the tokens are counted from the text sent and received with the model's
+// tokenizer.
+func meterRecord(backend, model string, req ChatRequest, resp *ChatResponse) {
+	recordExchange(backend, model, req, resp)
+	if resp == nil {
+		return
+	}
+	resp.Backend = backend
+
+	m := observability.ActiveCostMeter()
+	if m == nil {
+		return
+	}
+	if resp.TokenUsage != nil {
+		resp.Cost = m.Record(backend, model, resp.TokenUsage.PromptTokens, resp.TokenUsage.CompletionTokens)
+		return
+	}
+
+	tok := TokenizerFor(model)
+	in := tok.Count(req.SystemPrompt) + tok.Count(req.UserPrompt)
+	for _, msg := range req.Messages {
+		in += messageTokens(msg, tok)
+	}
+	out := tok.Count(resp.Text)
+	for _, tc := range resp.ToolCalls {
+		out += tok.Count(tc.Arguments)
+	}
+	resp.Cost = m.Record(backend, model, in, out)
+}
+
+// recordExchange appends the call's prompt and response to the session
+// transcript.
+func recordExchange(backend, model string, req ChatRequest, resp *ChatResponse) {
+	t := observability.ActiveTranscript()
+	if t == nil || resp == nil {
+		return
+	}
+	messages := req.Messages
+	if req.UserPrompt != "" {
+		messages = append([]ChatMessage{{Role: "user", Content: req.UserPrompt}}, messages...)
+	}
+	ex := observability.Exchange{
+		Time:     time.Now(),
+		Backend:  backend,
+		Model:    model,
+		System:   req.SystemPrompt,
+		Response: resp.Text,
+	}
+	ex.Messages, _ = json.Marshal(messages)
+	if len(resp.ToolCalls) > 0 {
+		ex.ToolCalls, _ = json.Marshal(resp.ToolCalls)
+	}
+	_ = t.Record(ex)
+}
@@ -0,0 +1,507 @@
+// Package lsp is a client of language servers (gopls, tsserver, pyright)
+// that gives the agents precise diagnostics, references and renames.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned for calls on a server that has exited.
+var ErrClosed = errors.New("language server exited")
+
+const (
+	// diagnosticsWait bounds how long Diagnostics waits for the server to
+	// publish a file's diagnostics
+	diagnosticsWait = 10 * time.Second
+	// diagnosticsSettle is how long Diagnostics waits for further updates
+	// once a file's diagnostics arrive, as servers publish them in passes
+	diagnosticsSettle = 300 * time.Millisecond
+)
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// document is a file the server has been told about
+type document struct {
+	version int
+	text    string
+}
+
+// published are the latest diagnostics of a document, and how many times
+// they have been published
+type published struct {
+	diagnostics []Diagnostic
+	count       int
+}
+
+// Client is a running language server for one project root
+type Client struct {
+	Root string
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stderr  *tailBuffer
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	nextID      int64
+	pending     map[string]chan rpcMessage
+	docs        map[string]*document
+	diagnostics map[string]*published
+	// diagnosed is closed and replaced whenever diagnostics are published
+	diagnosed chan struct{}
+	done      chan struct{}
+}
+
+// Start runs command as a language server for root and initializes it.
+func Start(ctx context.Context, command []string, root string) (*Client, error) {
+	if len(command) == 0 {
+		return nil, errors.New("no language server command")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Dir = root
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		Root:        root,
+		cmd:         cmd,
+		stdin:       stdin,
+		stderr:      &tailBuffer{max: 2048},
+		pending:     map[string]chan rpcMessage{},
+		docs:        map[string]*document{},
+		diagnostics: map[string]*published{},
+		diagnosed:   make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	cmd.Stderr = c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+	go c.readLoop(bufio.NewReader(stdout))
+
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		if tail := c.stderr.String(); tail != "" {
+			return nil, fmt.Errorf("%s: %w\n%s", command[0], err, tail)
+		}
+		return nil, fmt.Errorf("%s: %w", command[0], err)
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	rootURI := FileURI(c.Root)
+	err := c.call(ctx, "initialize", map[string]interface{}{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"workspaceFolders": []map[string]string{
+			{"uri": rootURI, "name": "root"},
+		},
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"synchronization":    map[string]interface{}{},
+				"publishDiagnostics": map[string]interface{}{},
+				"references":         map[string]interface{}{},
+				"rename":             map[string]interface{}{},
+			},
+			"workspace": map[string]interface{}{
+				"workspaceEdit":    map[string]interface{}{"documentChanges": true},
+				"workspaceFolders": true,
+				"configuration":    true,
+			},
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	return c.notify("initialized", map[string]interface{}{})
+}
+
+// readLoop reads the server's messages, framed by Content-Length headers
+func (c *Client) readLoop(r *bufio.Reader) {
+	defer close(c.done)
+	headers := textproto.NewReader(r)
+	for {
+		header, err := headers.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil || length < 0 {
+			return
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return
+		}
+		var msg rpcMessage
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		switch {
+		case msg.Method != "" && len(msg.ID) > 0:
+			c.answer(msg)
+		case msg.Method == "textDocument/publishDiagnostics":
+			c.publish(msg.Params)
+		case msg.Method != "":
+			// Other notifications (logs, progress) need no reply
+		default:
+			c.mu.Lock()
+			ch := c.pending[string(msg.ID)]
+			delete(c.pending, string(msg.ID))
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// answer replies to a request from the server. Configuration requests get
+// no settings, so servers use their defaults.
+func (c *Client) answer(req rpcMessage) {
+	reply := rpcMessage{JSONRPC: "2.0", ID: req.ID}
+	switch req.Method {
+	case "workspace/configuration":
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		settings := make([]interface{}, len(params.Items))
+		reply.Result, _ = json.Marshal(settings)
+	case "window/workDoneProgress/create", "client/registerCapability", "client/unregisterCapability":
+		reply.Result = json.RawMessage("null")
+	case "workspace/workspaceFolders":
+		reply.Result, _ = json.Marshal([]map[string]string{{"uri": FileURI(c.Root), "name": "root"}})
+	default:
+		reply.Error = &rpcError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	_ = c.send(reply)
+}
+
+func (c *Client) publish(params json.RawMessage) {
+	var p struct {
+		URI         string       `json:"uri"`
+		Diagnostics []Diagnostic `json:"diagnostics"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := c.diagnostics[p.URI]
+	if d == nil {
+		d = &published{}
+		c.diagnostics[p.URI] = d
+	}
+	d.diagnostics = p.Diagnostics
+	d.count++
+	close(c.diagnosed)
+	c.diagnosed = make(chan struct{})
+}
+
+func (c *Client) send(msg rpcMessage) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.stdin, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.stdin.Write(data)
+	return err
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := json.RawMessage(strconv.FormatInt(c.nextID, 10))
+	ch := make(chan rpcMessage, 1)
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(rpcMessage{ID: id, Method: method, Params: raw}); err != nil {
+		return err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(method string, params interface{}) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	return c.send(rpcMessage{Method: method, Params: raw})
+}
+
+// marshalParams encodes params, leaving them out when nil
+func marshalParams(params interface{}) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	return json.Marshal(params)
+}
+
+// sync tells the server about path's current content, opening it the
+// first time. It returns the file's URI and whether the server was told
+// anything new.
+func (c *Client) sync(path string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	uri, text := FileURI(path), string(data)
+
+	c.mu.Lock()
+	doc := c.docs[uri]
+	switch {
+	case doc == nil:
+		c.docs[uri] = &document{version: 1, text: text}
+	case doc.text == text:
+		c.mu.Unlock()
+		return uri, false, nil
+	default:
+		doc.version++
+		doc.text = text
+	}
+	version := c.docs[uri].version
+	c.mu.Unlock()
+
+	if version == 1 {
+		err = c.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"uri":        uri,
+				"languageId": languageID(path),
+				"version":    version,
+				"text":       text,
+			},
+		})
+	} else {
+		err = c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": version},
+			"contentChanges": []map[string]string{{"text": text}},
+		})
+	}
+	return uri, true, err
+}
+
+// Diagnostics returns the server's diagnostics for path, waiting for it to
+// check the file's current content.
+func (c *Client) Diagnostics(ctx context.Context, path string) ([]Diagnostic, error) {
+	c.mu.Lock()
+	before := 0
+	if d := c.diagnostics[FileURI(path)]; d != nil {
+		before = d.count
+	}
+	c.mu.Unlock()
+	uri, changed, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+	if !changed && before > 0 {
+		return c.latest(uri), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsWait)
+	defer cancel()
+	var settle <-chan time.Time
+	for {
+		c.mu.Lock()
+		d := c.diagnostics[uri]
+		changed := c.diagnosed
+		c.mu.Unlock()
+		if d != nil && d.count > before && settle == nil {
+			settle = time.After(diagnosticsSettle)
+		}
+		select {
+		case <-changed:
+			if settle != nil {
+				settle = time.After(diagnosticsSettle)
+			}
+		case <-settle:
+			return c.latest(uri), nil
+		case <-c.done:
+			return nil, ErrClosed
+		case <-ctx.Done():
+			// An unchanged file may not be checked again
+			return c.latest(uri), nil
+		}
+	}
+}
+
+func (c *Client) latest(uri string) []Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d := c.diagnostics[uri]; d != nil {
+		return append([]Diagnostic(nil), d.diagnostics...)
+	}
+	return nil
+}
+
+// References returns the locations referring to the symbol at pos in path,
+// including its declaration.
+func (c *Client) References(ctx context.Context, path string, pos Position) ([]Location, error) {
+	uri, _, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+	var locations []Location
+	err = c.call(ctx, "textDocument/references", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     pos,
+		"context":      map[string]bool{"includeDeclaration": true},
+	}, &locations)
+	return locations, err
+}
+
+// Rename returns the edits renaming the symbol at pos in path to newName
+// across the project. Nothing is changed on disk.
+func (c *Client) Rename(ctx context.Context, path string, pos Position, newName string) (*WorkspaceEdit, error) {
+	uri, _, err := c.sync(path)
+	if err != nil {
+		return nil, err
+	}
+	edit := &WorkspaceEdit{}
+	err = c.call(ctx, "textDocument/rename", map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     pos,
+		"newName":      newName,
+	}, edit)
+	return edit, err
+}
+
+// Close shuts the server down, killing it if it does not exit within a
+// few seconds.
+func (c *Client) Close() error {
+	select {
+	case <-c.done:
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if c.call(ctx, "shutdown", nil, nil) == nil {
+			_ = c.notify("exit", nil)
+		}
+		cancel()
+	}
+	_ = c.stdin.Close()
+	exited := make(chan struct{})
+	go func() {
+		_ = c.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(3 * time.Second):
+		_ = c.cmd.Process.Kill()
+		<-exited
+	}
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it, for reporting why a
+// server failed to start
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.buf))
+}
+
+// languageID is the LSP language identifier of a file
+func languageID(path string) string {
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")) {
+	case "go":
+		return "go"
+	case "ts":
+		return "typescript"
+	case "tsx":
+		return "typescriptreact"
+	case "js", "mjs", "cjs":
+		return "javascript"
+	case "jsx":
+		return "javascriptreact"
+	case "py":
+		return "python"
+	case "rs":
+		return "rust"
+	case "rb":
+		return "ruby"
+	case "ex", "exs":
+		return "elixir"
+	default:
+		return "plaintext"
+	}
+}
@@ -0,0 +1,189 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gptcode/internal/tools"
+)
+
+// TestMain turns the test binary into a fake language server when asked to.
+func TestMain(m *testing.M) {
+	if os.Getenv("GPTCODE_FAKE_LSP") == "1" {
+		fakeServer(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServer reports a warning on each line mentioning TODO, and finds and
+// renames the occurrences of a word in its document.
+func fakeServer(in io.Reader, out io.Writer) {
+	r := bufio.NewReader(in)
+	headers := textproto.NewReader(r)
+	docs := map[string]string{}
+	write := func(msg map[string]interface{}) {
+		msg["jsonrpc"] = "2.0"
+		data, _ := json.Marshal(msg)
+		fmt.Fprintf(out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+	for {
+		header, err := headers.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		length, _ := strconv.Atoi(header.Get("Content-Length"))
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return
+		}
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				TextDocument struct {
+					URI  string `json:"uri"`
+					Text string `json:"text"`
+				} `json:"textDocument"`
+				ContentChanges []struct {
+					Text string `json:"text"`
+				} `json:"contentChanges"`
+				Position Position `json:"position"`
+				NewName  string   `json:"newName"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(data, &req) != nil {
+			continue
+		}
+		uri := req.Params.TextDocument.URI
+		occurrences := func() []Range {
+			text := docs[uri]
+			line := strings.Split(text, "\n")[req.Params.Position.Line]
+			start := Offset(line, Position{Character: req.Params.Position.Character})
+			end := start
+			for end < len(line) && isIdentByte(line[end]) {
+				end++
+			}
+			var ranges []Range
+			for n, l := range strings.Split(text, "\n") {
+				pos, err := FindSymbol(l, 1, line[start:end])
+				if err == nil {
+					ranges = append(ranges, Range{Position{n, pos.Character}, Position{n, pos.Character + end - start}})
+				}
+			}
+			return ranges
+		}
+
+		switch req.Method {
+		case "initialize":
+			write(map[string]interface{}{"id": req.ID, "result": map[string]interface{}{"capabilities": map[string]interface{}{}}})
+		case "textDocument/didOpen", "textDocument/didChange":
+			docs[uri] = req.Params.TextDocument.Text
+			if len(req.Params.ContentChanges) > 0 {
+				docs[uri] = req.Params.ContentChanges[0].Text
+			}
+			diagnostics := []Diagnostic{}
+			for n, l := range strings.Split(docs[uri], "\n") {
+				if i := strings.Index(l, "TODO"); i >= 0 {
+					diagnostics = append(diagnostics, Diagnostic{Range: Range{Start: Position{n, i}}, Severity: SeverityWarning, Source: "fake", Message: "TODO left"})
+				}
+			}
+			write(map[string]interface{}{"method": "textDocument/publishDiagnostics", "params": map[string]interface{}{"uri": uri, "diagnostics": diagnostics}})
+		case "textDocument/references":
+			var locations []Location
+			for _, r := range occurrences() {
+				locations = append(locations, Location{URI: uri, Range: r})
+			}
+			write(map[string]interface{}{"id": req.ID, "result": locations})
+		case "textDocument/rename":
+			var edits []TextEdit
+			for _, r := range occurrences() {
+				edits = append(edits, TextEdit{Range: r, NewText: req.Params.NewName})
+			}
+			write(map[string]interface{}{"id": req.ID, "result": WorkspaceEdit{Changes: map[string][]TextEdit{uri: edits}}})
+		case "shutdown":
+			write(map[string]interface{}{"id": req.ID, "result": nil})
+		case "exit":
+			return
+		}
+	}
+}
+
+func TestFindSymbol(t *testing.T) {
+	text := "package main\n\nvar café, cafe = 1, 2 // cafe\n"
+	pos, err := FindSymbol(text, 3, "cafe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// é is two bytes but one UTF-16 unit
+	if pos != (Position{Line: 2, Character: 10}) {
+		t.Errorf("unexpected position %+v", pos)
+	}
+	if _, err := FindSymbol(text, 1, "mai"); err == nil {
+		t.Error("a partial word should not match")
+	}
+	if _, err := FindSymbol(text, 9, "main"); err == nil {
+		t.Error("a line past the end should fail")
+	}
+}
+
+func TestApplyEdits(t *testing.T) {
+	text := "a 😀 b\nb b\n"
+	edits := []TextEdit{
+		// The emoji is two UTF-16 units
+		{Range: Range{Position{0, 5}, Position{0, 6}}, NewText: "bee"},
+		{Range: Range{Position{1, 0}, Position{1, 1}}, NewText: "bee"},
+		{Range: Range{Position{1, 2}, Position{1, 3}}, NewText: "bee"},
+	}
+	if got := ApplyEdits(text, edits); got != "a 😀 bee\nbee bee\n" {
+		t.Errorf("unexpected result %q", got)
+	}
+}
+
+func TestTools(t *testing.T) {
+	t.Setenv("GPTCODE_FAKE_LSP", "1")
+	dir := t.TempDir()
+	file := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n\n// TODO: more\nfunc run() {}\n\nfunc main() { run() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manager := NewManager([]string{os.Args[0]}, dir, dir)
+	defer manager.Close()
+	byName := map[string]tools.ExternalTool{}
+	for _, tool := range manager.Tools() {
+		byName[tool.Name] = tool
+	}
+
+	out, err := byName[DiagnosticsTool].Call(map[string]interface{}{"path": "main.go"})
+	if err != nil || out != "main.go:3:4: warning: TODO left (fake)\n" {
+		t.Errorf("unexpected diagnostics %q %v", out, err)
+	}
+
+	out, err = byName[ReferencesTool].Call(map[string]interface{}{"path": "main.go", "line": 4.0, "symbol": "run"})
+	if err != nil || !strings.Contains(out, "2 references") || !strings.Contains(out, "main.go:6: func main() { run() }") {
+		t.Errorf("unexpected references %q %v", out, err)
+	}
+
+	out, files, err := byName[RenameTool].Edit(map[string]interface{}{"path": "main.go", "line": 4.0, "symbol": "run", "new_name": "start"})
+	if err != nil || len(files) != 1 || files[0] != "main.go" {
+		t.Fatalf("unexpected rename %q %v %v", out, files, err)
+	}
+	data, _ := os.ReadFile(file)
+	if !strings.Contains(string(data), "func start() {}\n\nfunc main() { start() }") {
+		t.Errorf("the file was not renamed:\n%s", data)
+	}
+
+	// The server sees the renamed file
+	out, err = byName[ReferencesTool].Call(map[string]interface{}{"path": "main.go", "line": 4.0, "symbol": "start"})
+	if err != nil || !strings.Contains(out, "2 references") {
+		t.Errorf("unexpected references after the rename %q %v", out, err)
+	}
+}
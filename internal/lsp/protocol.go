@@ -0,0 +1,167 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Position is a zero-based line and character offset in UTF-16 code units,
+// as the protocol counts them
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span of a document, end exclusive
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range of a file
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// Diagnostic severities
+const (
+	SeverityError       = 1
+	SeverityWarning     = 2
+	SeverityInformation = 3
+	SeverityHint        = 4
+)
+
+// Diagnostic is an error or warning the server reports for a document
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+}
+
+// SeverityName is the lowercase name of the diagnostic's severity
+func (d Diagnostic) SeverityName() string {
+	switch d.Severity {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "info"
+	case SeverityHint:
+		return "hint"
+	default:
+		return "error"
+	}
+}
+
+// TextEdit replaces a range of a document
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit is a set of changes to documents, as a rename returns it.
+// Servers send either Changes or DocumentChanges.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []DocumentChange      `json:"documentChanges,omitempty"`
+}
+
+// DocumentChange is an edit of one document or, when Kind is set, a file
+// creation, rename or deletion
+type DocumentChange struct {
+	Kind         string `json:"kind,omitempty"`
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Edits []TextEdit `json:"edits"`
+}
+
+// FileEdits returns the text edits by file URI. A change that creates,
+// renames or deletes a file is reported by its kind, since it cannot be
+// expressed as text edits.
+func (e *WorkspaceEdit) FileEdits() (map[string][]TextEdit, string) {
+	edits := map[string][]TextEdit{}
+	for uri, changes := range e.Changes {
+		edits[uri] = append(edits[uri], changes...)
+	}
+	for _, c := range e.DocumentChanges {
+		if c.Kind != "" {
+			return nil, c.Kind
+		}
+		edits[c.TextDocument.URI] = append(edits[c.TextDocument.URI], c.Edits...)
+	}
+	return edits, ""
+}
+
+// FileURI is the file:// URI of an absolute path
+func FileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// URIPath is the path of a file:// URI, or "" for other URIs
+func URIPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	path := u.Path
+	// file:///C:/dir on Windows
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+// Offset is the byte offset in text of pos. Positions past the end of a
+// line or of the text are clamped to it.
+func Offset(text string, pos Position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		i := strings.IndexByte(text[offset:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		offset += i + 1
+	}
+	for units := 0; units < pos.Character && offset < len(text); {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		if r == '\n' {
+			break
+		}
+		units += utf16.RuneLen(r)
+		offset += size
+	}
+	return offset
+}
+
+// Character is the UTF-16 offset of byte column col in line
+func Character(line string, col int) int {
+	units := 0
+	for _, r := range line[:min(col, len(line))] {
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
+
+// ApplyEdits applies non-overlapping edits to text
+func ApplyEdits(text string, edits []TextEdit) string {
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, 0, len(edits))
+	for _, e := range edits {
+		spans = append(spans, span{Offset(text, e.Range.Start), Offset(text, e.Range.End), e.NewText})
+	}
+	// Last first, so earlier offsets stay valid
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start > spans[j].start })
+	for _, s := range spans {
+		text = text[:s.start] + s.text + text[s.end:]
+	}
+	return text
+}
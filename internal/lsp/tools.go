@@ -0,0 +1,392 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gptcode/internal/langdetect"
+	"gptcode/internal/tools"
+)
+
+// Tool names
+const (
+	DiagnosticsTool = "lsp_diagnostics"
+	ReferencesTool  = "lsp_references"
+	RenameTool      = "lsp_rename"
+)
+
+const (
+	// startTimeout bounds starting and initializing a server
+	startTimeout = time.Minute
+	// callTimeout bounds one tool call
+	callTimeout = time.Minute
+	// maxReferences bounds the references a call lists
+	maxReferences = 100
+)
+
+// servers are the usual language servers of each language, the preferred
+// first
+var servers = map[langdetect.Language][][]string{
+	langdetect.Go:         {{"gopls"}},
+	langdetect.TypeScript: {{"typescript-language-server", "--stdio"}},
+	langdetect.Python:     {{"pyright-langserver", "--stdio"}, {"pylsp"}},
+	langdetect.Rust:       {{"rust-analyzer"}},
+}
+
+// DefaultCommand returns the command of the first installed server for
+// language, or nil when none is.
+func DefaultCommand(language langdetect.Language) []string {
+	for _, command := range servers[language] {
+		if _, err := exec.LookPath(command[0]); err == nil {
+			return command
+		}
+	}
+	return nil
+}
+
+// Manager starts a project's language server the first time a tool needs
+// it, and restarts it if it exits.
+type Manager struct {
+	command []string
+	root    string
+	workdir string
+
+	mu     sync.Mutex
+	client *Client
+	err    error
+}
+
+// NewManager returns a manager running command for the project at root.
+// Tool paths are relative to workdir.
+func NewManager(command []string, root, workdir string) *Manager {
+	return &Manager{command: command, root: root, workdir: workdir}
+}
+
+// Client returns the running server, starting it if needed. A server that
+// failed to start is not tried again.
+func (m *Manager) Client() (*Client, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.client != nil {
+		select {
+		case <-m.client.done:
+			m.client.Close()
+			m.client = nil
+		default:
+			return m.client, nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+	m.client, m.err = Start(ctx, m.command, m.root)
+	return m.client, m.err
+}
+
+// Close stops the server, if it was started.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client != nil {
+		m.client.Close()
+		m.client = nil
+	}
+}
+
+// Tools returns the lsp_diagnostics, lsp_references and lsp_rename tools.
+func (m *Manager) Tools() []tools.ExternalTool {
+	path := map[string]interface{}{
+		"type":        "string",
+		"description": "File path",
+	}
+	line := map[string]interface{}{
+		"type":        "integer",
+		"description": "Line of the symbol, starting at 1",
+	}
+	symbol := map[string]interface{}{
+		"type":        "string",
+		"description": "The identifier on that line, e.g. 'ParseConfig'",
+	}
+	return []tools.ExternalTool{
+		{
+			Name: DiagnosticsTool,
+			Description: "Get the compiler and linter errors and warnings of a file from the language server. " +
+				"Use it after editing a file to check it still compiles.",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"path": path},
+				"required":   []string{"path"},
+			},
+			Call: m.diagnostics,
+		},
+		{
+			Name: ReferencesTool,
+			Description: "Find every reference to a symbol (function, type, variable, field) across the project, " +
+				"including its declaration. More precise than searching for its name.",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"path": path, "line": line, "symbol": symbol},
+				"required":   []string{"path", "line", "symbol"},
+			},
+			Call: m.references,
+		},
+		{
+			Name: RenameTool,
+			Description: "Rename a symbol everywhere it is used across the project, as an IDE rename does, " +
+				"and write the changed files.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path":   path,
+					"line":   line,
+					"symbol": symbol,
+					"new_name": map[string]interface{}{
+						"type":        "string",
+						"description": "The new name",
+					},
+				},
+				"required": []string{"path", "line", "symbol", "new_name"},
+			},
+			Edit: m.rename,
+		},
+	}
+}
+
+func (m *Manager) diagnostics(args map[string]interface{}) (string, error) {
+	path, err := m.path(args)
+	if err != nil {
+		return "", err
+	}
+	c, err := m.Client()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	diagnostics, err := c.Diagnostics(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if len(diagnostics) == 0 {
+		return fmt.Sprintf("No problems in %s", m.display(path)), nil
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Severity != diagnostics[j].Severity {
+			return diagnostics[i].Severity < diagnostics[j].Severity
+		}
+		return diagnostics[i].Range.Start.Line < diagnostics[j].Range.Start.Line
+	})
+	var b strings.Builder
+	for _, d := range diagnostics {
+		fmt.Fprintf(&b, "%s:%d:%d: %s: %s", m.display(path), d.Range.Start.Line+1, d.Range.Start.Character+1,
+			d.SeverityName(), strings.TrimSpace(d.Message))
+		if d.Source != "" {
+			fmt.Fprintf(&b, " (%s)", d.Source)
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+func (m *Manager) references(args map[string]interface{}) (string, error) {
+	path, pos, err := m.symbol(args)
+	if err != nil {
+		return "", err
+	}
+	c, err := m.Client()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	locations, err := c.References(ctx, path, pos)
+	if err != nil {
+		return "", err
+	}
+	if len(locations) == 0 {
+		return "No references found", nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d references:\n", len(locations))
+	lines := map[string][]string{}
+	for i, loc := range locations {
+		if i == maxReferences {
+			fmt.Fprintf(&b, "... and %d more\n", len(locations)-maxReferences)
+			break
+		}
+		file := URIPath(loc.URI)
+		if _, ok := lines[file]; !ok {
+			data, _ := os.ReadFile(file)
+			lines[file] = strings.Split(string(data), "\n")
+		}
+		fmt.Fprintf(&b, "%s:%d", m.display(file), loc.Range.Start.Line+1)
+		if n := loc.Range.Start.Line; n < len(lines[file]) {
+			fmt.Fprintf(&b, ": %s", strings.TrimSpace(lines[file][n]))
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+func (m *Manager) rename(args map[string]interface{}) (string, []string, error) {
+	newName, _ := args["new_name"].(string)
+	if strings.TrimSpace(newName) == "" {
+		return "", nil, fmt.Errorf("new_name parameter required")
+	}
+	path, pos, err := m.symbol(args)
+	if err != nil {
+		return "", nil, err
+	}
+	c, err := m.Client()
+	if err != nil {
+		return "", nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	edit, err := c.Rename(ctx, path, pos, newName)
+	if err != nil {
+		return "", nil, err
+	}
+	fileEdits, kind := edit.FileEdits()
+	if kind != "" {
+		return "", nil, fmt.Errorf("the rename needs a file %s, which lsp_rename does not do", kind)
+	}
+	if len(fileEdits) == 0 {
+		return "", nil, fmt.Errorf("the language server found nothing to rename")
+	}
+
+	// Every file is checked before any is written
+	type change struct {
+		path string
+		text string
+		mode os.FileMode
+	}
+	var changes []change
+	edits := 0
+	for uri, fe := range fileEdits {
+		file := URIPath(uri)
+		if file == "" || !within(file, m.root) {
+			return "", nil, fmt.Errorf("the rename would change %s, outside the project", uri)
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return "", nil, err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", nil, err
+		}
+		changes = append(changes, change{file, ApplyEdits(string(data), fe), info.Mode().Perm()})
+		edits += len(fe)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+
+	j := tools.ActiveJournal()
+	var modified []string
+	for _, ch := range changes {
+		if j != nil {
+			if err := j.Snapshot(ch.path); err != nil {
+				return "", modified, fmt.Errorf("could not snapshot %s for undo: %w", m.display(ch.path), err)
+			}
+		}
+		if err := os.WriteFile(ch.path, []byte(ch.text), ch.mode); err != nil {
+			return "", modified, err
+		}
+		if j != nil {
+			j.Written(ch.path)
+		}
+		modified = append(modified, m.display(ch.path))
+	}
+	return fmt.Sprintf("Renamed %s to %s: %d edits in %d files:\n%s", args["symbol"], newName, edits,
+		len(modified), strings.Join(modified, "\n")), modified, nil
+}
+
+// path resolves the path argument against the working directory
+func (m *Manager) path(args map[string]interface{}) (string, error) {
+	path, _ := args["path"].(string)
+	if strings.TrimSpace(path) == "" {
+		return "", fmt.Errorf("path parameter required")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(m.workdir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// symbol resolves the path, line and symbol arguments to a position
+func (m *Manager) symbol(args map[string]interface{}) (string, Position, error) {
+	path, err := m.path(args)
+	if err != nil {
+		return "", Position{}, err
+	}
+	line, _ := args["line"].(float64)
+	symbol, _ := args["symbol"].(string)
+	if line < 1 || symbol == "" {
+		return "", Position{}, fmt.Errorf("line and symbol parameters required")
+	}
+	// Config.Load names Load
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		symbol = symbol[i+1:]
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", Position{}, err
+	}
+	pos, err := FindSymbol(string(data), int(line), symbol)
+	if err != nil {
+		return "", Position{}, fmt.Errorf("%s: %w", m.display(path), err)
+	}
+	return path, pos, nil
+}
+
+// FindSymbol returns the position of the first whole-word occurrence of
+// symbol on line (counted from 1) of text.
+func FindSymbol(text string, line int, symbol string) (Position, error) {
+	lines := strings.Split(text, "\n")
+	if line > len(lines) {
+		return Position{}, fmt.Errorf("line %d is past the end of the file (%d lines)", line, len(lines))
+	}
+	content := lines[line-1]
+	for from := 0; ; {
+		i := strings.Index(content[from:], symbol)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(symbol)
+		if (start == 0 || !isIdentByte(content[start-1])) && (end == len(content) || !isIdentByte(content[end])) {
+			return Position{Line: line - 1, Character: Character(content, start)}, nil
+		}
+		from = end
+	}
+	return Position{}, fmt.Errorf("%q is not on line %d: %s", symbol, line, strings.TrimSpace(content))
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b >= 0x80
+}
+
+// display is path relative to the working directory when it is under it
+func (m *Manager) display(path string) string {
+	if rel, err := filepath.Rel(m.workdir, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
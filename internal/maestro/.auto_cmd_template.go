@@ -44,7 +44,7 @@ Example:
 		}
 
 		// Setup
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			return fmt.Errorf("failed to load setup: %w", err)
 		}
@@ -54,12 +54,7 @@ Example:
 
 		cwd, _ := os.Getwd()
 
-		var provider llm.Provider
-		if backendCfg.Type == "ollama" {
-			provider = llm.NewOllama(backendCfg.BaseURL)
-		} else {
-			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-		}
+		provider := llm.NewForBackend(backendName, backendCfg)
 
 		model := backendCfg.GetModelForAgent("editor")
 
@@ -0,0 +1,72 @@
+package maestro
+
+import (
+	"fmt"
+
+	"gptcode/internal/agents"
+	"gptcode/internal/config"
+	"gptcode/internal/observability"
+)
+
+// taskBudget returns the run's remaining budget with the prices of the
+// models the task's planner, editor and reviewer run on
+func (c *Conductor) taskBudget(complexity, planBackend, planModel string) agents.Budget {
+	models := []agents.ModelPrice{{Agent: "planner", Backend: planBackend, Model: planModel}}
+	for _, role := range []struct {
+		agent  string
+		action config.ActionType
+	}{
+		{"editor", config.ActionEdit},
+		{"reviewer", config.ActionReview},
+	} {
+		if backend, model, err := c.selector.SelectModel(role.action, c.language, complexity); err == nil {
+			models = append(models, agents.ModelPrice{Agent: role.agent, Backend: backend, Model: model})
+		}
+	}
+	return agents.NewBudget(observability.ActiveCostMeter(), models...)
+}
+
+// recordBudget notes in the trace the budget and prices the planner was
+// shown, so a plan can later be read against what it could afford.
+func (c *Conductor) recordBudget(b agents.Budget) {
+	if c.Tracer == nil {
+		return
+	}
+	chosen := "unlimited"
+	attribution := map[string]float64{"spent": b.Spent}
+	if left, limited := b.Remaining(); limited {
+		chosen = "within_budget"
+		if b.Tight() {
+			chosen = "tight"
+		}
+		attribution["limit"] = b.Limit
+		attribution["remaining"] = left
+	}
+	var prices []string
+	for _, m := range b.Models {
+		price := "unpriced"
+		if m.Priced {
+			price = fmt.Sprintf("$%.2f/$%.2f per 1M", m.PromptPerM, m.CompletionPerM)
+		}
+		prices = append(prices, fmt.Sprintf("%s %s/%s %s", m.Agent, m.Backend, m.Model, price))
+	}
+	_ = c.Tracer.RecordDecision("planner", observability.Decision{
+		Type:         "budget",
+		Chosen:       chosen,
+		Alternatives: prices,
+		Attribution:  attribution,
+		Reasoning:    "remaining budget and model prices shown to the planner",
+	})
+}
+
+// recordBudgetDecision notes a choice made to fit the budget
+func (c *Conductor) recordBudgetDecision(agent, chosen, reasoning string) {
+	if c.Tracer == nil {
+		return
+	}
+	_ = c.Tracer.RecordDecision(agent, observability.Decision{
+		Type:      "budget_strategy",
+		Chosen:    chosen,
+		Reasoning: reasoning,
+	})
+}
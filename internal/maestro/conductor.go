@@ -2,8 +2,11 @@ package maestro
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,8 +15,14 @@ import (
 	"gptcode/internal/agents"
 	"gptcode/internal/config"
 	"gptcode/internal/feedback"
+	"gptcode/internal/intelligence"
 	"gptcode/internal/llm"
 	"gptcode/internal/observability"
+	"gptcode/internal/output"
+	"gptcode/internal/prompt"
+	"gptcode/internal/summarize"
+	"gptcode/internal/tools"
+	"gptcode/internal/validation"
 )
 
 // Conductor is the central coordinator (Maestro) that orchestrates all agents
@@ -26,6 +35,9 @@ type Conductor struct {
 	Tracer       observability.Tracer
 	Observer     *observability.AgentObserver // For tracking and summary
 	loopDetector *llm.LoopDetector            // Centralized Claude Code-style loop detection
+	// testBase is the working tree when the task started, what checkTests
+	// compares with
+	testBase string
 }
 
 // NewConductor creates a new Maestro conductor
@@ -44,7 +56,7 @@ func NewConductor(
 	observer := observability.NewObserver()
 	observer.SetVerbose(os.Getenv("GPTCODE_DEBUG") == "1")
 
-	return &Conductor{
+	c := &Conductor{
 		selector: selector,
 		setup:    setup,
 		cwd:      cwd,
@@ -53,63 +65,97 @@ func NewConductor(
 		Tracer:   tracer,
 		Observer: observer,
 	}
+	if selector != nil {
+		selector.OnDemotion(c.recordDemotion)
+	}
+	return c
 }
 
-// ExecuteTask orchestrates the execution of a task
-func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity string) error {
-	if os.Getenv("GPTCODE_DEBUG") == "1" {
-		fmt.Fprintf(os.Stderr, "[MAESTRO] ExecuteTask called: task=%s complexity=%s lang=%s\n", task, complexity, c.language)
+// recordDemotion notes in the trace a model the selector passed over
+// because of repeated bad feedback, and the one it promoted instead.
+func (c *Conductor) recordDemotion(d config.Demotion) {
+	if c.Tracer == nil {
+		return
 	}
+	_ = c.Tracer.RecordDecision("ModelSelector", observability.Decision{
+		Type:         "model_demotion",
+		Chosen:       fmt.Sprintf("%s/%s", d.PromotedBackend, d.PromotedModel),
+		Alternatives: []string{fmt.Sprintf("%s/%s", d.Backend, d.Model)},
+		Reasoning:    d.String(),
+	})
+}
 
-	// Begin tracing session
-	sessionID := uuid.New().String()
-	if c.Tracer != nil {
-		_ = c.Tracer.Begin(sessionID, task)
-		defer func() { _ = c.Tracer.End(true) }() // End with success status (will be updated on error)
+// recordOverrides notes command-line backend/profile/model overrides in the
+// trace so a run can be attributed to the configuration it actually used.
+func recordOverrides(tracer observability.Tracer) {
+	o := config.CurrentOverrides()
+	if o.IsZero() {
+		return
 	}
+	_ = tracer.RecordDecision("config", observability.Decision{
+		Type:      "config_override",
+		Chosen:    o.String(),
+		Reasoning: "set by command-line flags",
+	})
+}
 
-	// Select model for planning
-	planBackend, planModel, err := c.selector.SelectModel(config.ActionPlan, c.language, complexity)
-	if err != nil {
-		return fmt.Errorf("failed to select planner model: %w", err)
+// recordPromptVariants notes the prompt experiment variants the session's
+// agents were assigned.
+func recordPromptVariants(tracer observability.Tracer) {
+	variants := prompt.AssignedVariants()
+	agents := make([]string, 0, len(variants))
+	for agent := range variants {
+		agents = append(agents, agent)
 	}
+	sort.Strings(agents)
+	for _, agent := range agents {
+		_ = tracer.RecordDecision(agent, observability.Decision{
+			Type:      "prompt_variant",
+			Chosen:    variants[agent],
+			Reasoning: "prompt experiment assignment",
+		})
+	}
+}
+
+// ExecuteTask orchestrates the execution of a task. Each agent run is
+// limited by its timeout and retried when it runs over; once ctx is done,
+// by the task's deadline or Ctrl+C, the task stops and its trace is saved.
+func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity string) error {
+	return c.execute(ctx, task, "", complexity)
+}
+
+// ExecutePlan is ExecuteTask with a plan already agreed on, such as one
+// reviewed with --discuss: the planner is skipped and the editor, validation
+// and test guard work from plan.
+func (c *Conductor) ExecutePlan(ctx context.Context, task string, plan string, complexity string) error {
+	return c.execute(ctx, task, plan, complexity)
+}
 
+func (c *Conductor) execute(ctx context.Context, task string, plan string, complexity string) (err error) {
 	if os.Getenv("GPTCODE_DEBUG") == "1" {
-		fmt.Fprintf(os.Stderr, "[MAESTRO] Planner: %s/%s\n", planBackend, planModel)
+		fmt.Fprintf(os.Stderr, "[MAESTRO] ExecuteTask called: task=%s complexity=%s lang=%s\n", task, complexity, c.language)
 	}
 
-	// Record model selection decision
+	c.testBase = validation.SnapshotTree(c.cwd)
+
+	// Begin tracing session
+	sessionID := uuid.New().String()
 	if c.Tracer != nil {
-		decision := observability.Decision{
-			Type:         "model_selection",
-			Chosen:       fmt.Sprintf("%s/%s", planBackend, planModel),
-			Alternatives: []string{},                          // Would populate with alternatives in real implementation
-			Attribution:  map[string]float64{"language": 1.0}, // Simplified attribution
-			Reasoning:    "Selected based on language and complexity",
-		}
-		_ = c.Tracer.RecordDecision("ModelSelector", decision)
+		_ = c.Tracer.Begin(sessionID, task)
+		defer func() {
+			recordPromptVariants(c.Tracer)
+			_ = c.Tracer.End(err == nil)
+		}()
+		recordOverrides(c.Tracer)
 	}
 
-	// Create planner with selected model
-	planProvider := c.createProvider(planBackend)
-	planner := agents.NewPlanner(planProvider, planModel)
-
-	fmt.Println("Creating plan...")
-	start := time.Now()
-	plan, err := planner.CreatePlan(ctx, task, "", nil)
-	elapsed := time.Since(start)
-	c.selector.RecordUsage(planBackend, planModel, err == nil, errorMsg(err))
-	if err != nil {
-		return fmt.Errorf("planning failed: %w", err)
-	}
+	// Past corrections on similar tasks, so the same mistakes are not repeated
+	corrections := c.pastCorrections(task)
 
-	// Record planning metrics
-	if c.Tracer != nil {
-		metrics := observability.Metrics{
-			DurationMs:   elapsed.Milliseconds(),
-			ErrorMessage: "",
+	if plan == "" {
+		if plan, err = c.createPlan(ctx, task, complexity, corrections); err != nil {
+			return err
 		}
-		_ = c.Tracer.RecordMetrics("PlannerAgent", metrics)
 	}
 
 	// Build conversation history
@@ -125,11 +171,18 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 	}
 	c.loopDetector = llm.NewLoopDetector(intent)
 
+	// Shared across retries so a re-created editor still detects files that
+	// another movement changed after this task read them.
+	fileVersions := tools.NewFileVersions()
+
 	if os.Getenv("GPTCODE_DEBUG") == "1" {
 		fmt.Fprintf(os.Stderr, "[MAESTRO] LoopDetector initialized with intent=%s\n", intent)
 	}
 
 	for {
+		if ctx.Err() != nil {
+			return taskStopped(ctx)
+		}
 		// Check if we should continue (intent-aware limits + loop detection)
 		shouldContinue, stopReason := c.loopDetector.ShouldContinue()
 		if !shouldContinue {
@@ -163,12 +216,23 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 		// Create editor with selected model and observer
 		editProvider := c.createProvider(editBackend)
 		editor := agents.NewEditorWithObserver(editProvider, c.cwd, editModel, c.Observer)
+		editor.SetFileVersions(fileVersions)
+		editor.SetTimeout(c.setup.AgentTimeout("editor"))
+		editor.SetCorrections(corrections)
 
 		// Execute with editor
-		fmt.Println("Executing changes...")
-		start = time.Now()
+		editProgress := output.StartStatus(os.Stdout, "Executing changes")
+		start := time.Now()
 		result, modifiedFiles, err := editor.Execute(ctx, history, nil)
-		elapsed = time.Since(start)
+		editProgress.Stop()
+		elapsed := time.Since(start)
+		var clarification *tools.ClarificationError
+		if errors.As(err, &clarification) || errors.Is(err, observability.ErrBudgetExceeded) || tools.IsDiffBudgetError(err) {
+			return err
+		}
+		if ctx.Err() != nil {
+			return taskStopped(ctx)
+		}
 		c.selector.RecordUsage(editBackend, editModel, err == nil, errorMsg(err))
 		if err != nil {
 			// LoopDetector will handle max iterations check on next iteration
@@ -200,7 +264,7 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 					Attribution:  map[string]float64{"attempt": float64(attempt), "error_type": 1.0},
 					Reasoning:    fmt.Sprintf("Retrying attempt %d after error", attempt),
 				}
-				_ = c.Tracer.RecordDecision("RecoverySystem", decision)
+				c.recordRecovery(decision, modifiedFiles)
 			}
 
 			history = append(history, llm.ChatMessage{
@@ -240,6 +304,51 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 			return nil
 		}
 
+		// Reject fixes that pass by deleting, skipping or gutting tests
+		if weakened := c.checkTests(task); weakened != "" {
+			fmt.Printf("[WARNING] %s\n", weakened)
+
+			if c.Tracer != nil {
+				decision := observability.Decision{
+					Type:         "recovery_strategy",
+					Chosen:       "retry_with_tests_restored",
+					Alternatives: []string{"skip", "abort"},
+					Attribution:  map[string]float64{"attempt": float64(attempt)},
+					Reasoning:    fmt.Sprintf("Retrying attempt %d after tests were weakened", attempt),
+				}
+				c.recordRecovery(decision, modifiedFiles)
+			}
+
+			history = append(history, llm.ChatMessage{
+				Role: "user",
+				Content: fmt.Sprintf("Your changes made the tests weaker, which was not requested:\n%s\n\n"+
+					"Restore the deleted tests, assertions and skipped tests, and fix the code instead.", weakened),
+			})
+			continue
+		}
+
+		// Run the configured validation commands before the review
+		if failed := c.runValidation(ctx); failed != "" {
+			fmt.Printf("[WARNING] Validation command failed:\n%s\n", failed)
+
+			if c.Tracer != nil {
+				decision := observability.Decision{
+					Type:         "recovery_strategy",
+					Chosen:       "retry_with_validation_fix",
+					Alternatives: []string{"skip", "abort"},
+					Attribution:  map[string]float64{"attempt": float64(attempt)},
+					Reasoning:    fmt.Sprintf("Retrying attempt %d after a validation command failed", attempt),
+				}
+				c.recordRecovery(decision, modifiedFiles)
+			}
+
+			history = append(history, llm.ChatMessage{
+				Role:    "user",
+				Content: fmt.Sprintf("A validation command failed after your changes:\n%s\n\nFix the code so it passes.", failed),
+			})
+			continue
+		}
+
 		// Select model for review
 		reviewBackend, reviewModel, err := c.selector.SelectModel(config.ActionReview, c.language, complexity)
 		if err != nil {
@@ -255,9 +364,18 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 		reviewer := agents.NewReviewer(reviewProvider, c.cwd, reviewModel)
 
 		// Validate
-		fmt.Println("Validating...")
+		reviewProgress := output.StartStatus(os.Stdout, "Validating")
 		start = time.Now()
-		review, err := reviewer.Review(ctx, plan, modifiedFiles, nil)
+		reviewCtx, cancelReview := agents.WithTimeout(ctx, "reviewer", c.setup.AgentTimeout("reviewer"))
+		review, err := reviewer.Review(reviewCtx, plan, modifiedFiles, nil)
+		if reviewCtx.Err() != nil && err != nil {
+			err = context.Cause(reviewCtx)
+		}
+		cancelReview()
+		reviewProgress.Stop()
+		if ctx.Err() != nil {
+			return taskStopped(ctx)
+		}
 		elapsed = time.Since(start)
 		c.selector.RecordUsage(reviewBackend, reviewModel, err == nil, errorMsg(err))
 		if err != nil {
@@ -290,7 +408,7 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 					Attribution:  map[string]float64{"attempt": float64(attempt), "error_type": 1.0},
 					Reasoning:    fmt.Sprintf("Retrying attempt %d after validation error", attempt),
 				}
-				_ = c.Tracer.RecordDecision("RecoverySystem", decision)
+				c.recordRecovery(decision, modifiedFiles)
 			}
 
 			history = append(history, llm.ChatMessage{
@@ -331,7 +449,7 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 					Attribution:  map[string]float64{"attempt": float64(attempt), "error_count": float64(len(review.Issues))},
 					Reasoning:    fmt.Sprintf("Retrying attempt %d after %d validation issues", attempt, len(review.Issues)),
 				}
-				_ = c.Tracer.RecordDecision("RecoverySystem", decision)
+				c.recordRecovery(decision, modifiedFiles)
 			}
 
 			history = append(history, llm.ChatMessage{
@@ -390,6 +508,216 @@ func (c *Conductor) ExecuteTask(ctx context.Context, task string, complexity str
 	return fmt.Errorf("task stopped by loop detector")
 }
 
+// taskStopped is the error of a task whose context ended, by its deadline
+// or a cancellation such as Ctrl+C
+func taskStopped(ctx context.Context) error {
+	return fmt.Errorf("task stopped: %w", context.Cause(ctx))
+}
+
+// packageDigestThreshold is the source size above which a directory named in
+// the task is summarized for the planner instead of being read file by file.
+const packageDigestThreshold = 64 * 1024
+
+// maxPackageDigests caps how many directories one task summarizes
+const maxPackageDigests = 2
+
+// createPlan has the planner, on the model selected for complexity, draft
+// the plan for task within the task's budget.
+func (c *Conductor) createPlan(ctx context.Context, task string, complexity string, corrections string) (plan string, err error) {
+	// Select model for planning
+	planBackend, planModel, err := c.selector.SelectModel(config.ActionPlan, c.language, complexity)
+	if err != nil {
+		return "", fmt.Errorf("failed to select planner model: %w", err)
+	}
+
+	if os.Getenv("GPTCODE_DEBUG") == "1" {
+		fmt.Fprintf(os.Stderr, "[MAESTRO] Planner: %s/%s\n", planBackend, planModel)
+	}
+
+	// Record model selection decision
+	if c.Tracer != nil {
+		decision := observability.Decision{
+			Type:         "model_selection",
+			Chosen:       fmt.Sprintf("%s/%s", planBackend, planModel),
+			Alternatives: []string{},                          // Would populate with alternatives in real implementation
+			Attribution:  map[string]float64{"language": 1.0}, // Simplified attribution
+			Reasoning:    "Selected based on language and complexity",
+		}
+		_ = c.Tracer.RecordDecision("ModelSelector", decision)
+	}
+
+	// Create planner with selected model
+	planProvider := c.createProvider(planBackend)
+	planner := agents.NewPlanner(planProvider, planModel)
+
+	// Show the planner what is left to spend and what each model costs
+	budget := c.taskBudget(complexity, planBackend, planModel)
+	planner.SetBudget(budget)
+	c.recordBudget(budget)
+
+	// Past corrections on similar tasks, so the same mistakes are not repeated
+	planner.SetCorrections(corrections)
+
+	analysis := c.digestLargePackages(ctx, task, budget)
+
+	planProgress := output.StartStatus(os.Stdout, "Creating plan")
+	start := time.Now()
+	planCtx, cancelPlan := agents.WithTimeout(ctx, "planner", c.setup.AgentTimeout("planner"))
+	plan, err = planner.CreatePlan(planCtx, task, analysis, nil)
+	if planCtx.Err() != nil && err != nil {
+		err = context.Cause(planCtx)
+	}
+	cancelPlan()
+	planProgress.Stop()
+	elapsed := time.Since(start)
+	c.selector.RecordUsage(planBackend, planModel, err == nil, errorMsg(err))
+	if err != nil {
+		return "", fmt.Errorf("planning failed: %w", err)
+	}
+	if strategy := agents.BudgetStrategy(plan); strategy != "" {
+		c.recordBudgetDecision("planner", strategy, "planner's strategy for the budget")
+	}
+
+	// Record planning metrics
+	if c.Tracer != nil {
+		metrics := observability.Metrics{
+			DurationMs:   elapsed.Milliseconds(),
+			ErrorMessage: "",
+		}
+		_ = c.Tracer.RecordMetrics("PlannerAgent", metrics)
+	}
+	return plan, nil
+}
+
+// digestLargePackages returns map-reduce digests of the directories named in
+// task that are too large to read into context, at most maxPackageDigests of
+// them and summarize.DefaultMaxFiles files each. Summaries use a cheap model,
+// are charged to the task's cost meter and are cached by file hash, so
+// unchanged files cost nothing on later runs. Once the budget is tight they
+// are skipped and the planner targets files itself.
+func (c *Conductor) digestLargePackages(ctx context.Context, task string, budget agents.Budget) string {
+	var dirs []string
+	seen := map[string]bool{}
+	for _, field := range strings.Fields(task) {
+		field = strings.Trim(field, "`'\",.:;()")
+		if field == "" || seen[field] {
+			continue
+		}
+		seen[field] = true
+		path := field
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.cwd, path)
+		}
+		if info, err := os.Stat(path); err == nil && info.IsDir() && summarize.SourceSize(path) > packageDigestThreshold {
+			dirs = append(dirs, path)
+		}
+		if len(dirs) == maxPackageDigests {
+			break
+		}
+	}
+	if len(dirs) == 0 {
+		return ""
+	}
+	if budget.Tight() {
+		left, _ := budget.Remaining()
+		c.recordBudgetDecision("conductor", "skip_package_digests",
+			fmt.Sprintf("$%.4f of $%.4f left, too little to summarize %d large package(s)", left, budget.Limit, len(dirs)))
+		return ""
+	}
+
+	backend, model, err := c.selector.SelectModel(config.ActionResearch, c.language, "simple")
+	if err != nil {
+		return ""
+	}
+	summarizer := summarize.New(c.createProvider(backend), model)
+
+	var digests []string
+	for i, dir := range dirs {
+		if i > 0 && agents.NewBudget(observability.ActiveCostMeter()).Tight() {
+			c.recordBudgetDecision("conductor", "skip_package_digests",
+				fmt.Sprintf("budget got tight after %d package digest(s)", i))
+			break
+		}
+		progress := output.StartStatus(os.Stdout, "Summarizing "+filepath.Base(dir))
+		digest, err := summarizer.Digest(ctx, dir)
+		progress.Stop()
+		if err != nil {
+			if os.Getenv("GPTCODE_DEBUG") == "1" {
+				fmt.Fprintf(os.Stderr, "[MAESTRO] Failed to summarize %s: %v\n", dir, err)
+			}
+			continue
+		}
+		rel, _ := filepath.Rel(c.cwd, dir)
+		digests = append(digests, fmt.Sprintf("Digest of %s (summarized; read specific files for detail):\n%s", rel, digest))
+	}
+	return strings.Join(digests, "\n\n")
+}
+
+// recordRecovery traces a retry together with the files it touched, so
+// insights can tell which files keep causing retries.
+func (c *Conductor) recordRecovery(decision observability.Decision, files []string) {
+	step := observability.StepTrace{
+		Node:      "RecoverySystem",
+		Timestamp: time.Now(),
+		Decision:  &decision,
+	}
+	if len(files) > 0 {
+		step.Inputs = map[string]interface{}{"files": files}
+	}
+	_ = c.Tracer.RecordStep(step)
+}
+
+// checkTests compares the tests with the tree the task started from and
+// returns the findings when the change weakened them, so the user's own
+// uncommitted test edits are not blamed on it. In warn mode the findings are
+// only printed.
+func (c *Conductor) checkTests(task string) string {
+	mode := ""
+	if c.setup != nil {
+		mode = c.setup.Defaults.TestGuard
+	}
+	if mode == "off" || validation.AllowsTestChanges(task) {
+		return ""
+	}
+	guard := validation.NewTestGuard(c.cwd)
+	if c.testBase != "" {
+		guard.Base = c.testBase
+	}
+	res, err := guard.Check()
+	if err != nil || !res.Weakened {
+		return ""
+	}
+	if mode == "warn" {
+		fmt.Printf("[WARNING] %s\n", res.Summary())
+		return ""
+	}
+	return res.Summary()
+}
+
+// runValidation runs the build, test and lint commands the project
+// configures in its validation section and returns the first failing
+// command with its output, or "" when all pass.
+func (c *Conductor) runValidation(ctx context.Context) string {
+	res, err := validation.RunConfigured(ctx, c.cwd)
+	if err != nil {
+		return fmt.Sprintf("$ %s\n%v", res.Command, err)
+	}
+	if res != nil {
+		return fmt.Sprintf("$ %s\n%s\n%s failed: %s", res.Command, tailLines(res.Output, 60), res.Kind, res.Reason)
+	}
+	return ""
+}
+
+// tailLines keeps the last n lines of s, where failures are usually
+// reported
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = append([]string{"..."}, lines[len(lines)-n:]...)
+	}
+	return strings.Join(lines, "\n")
+}
+
 func errorMsg(err error) string {
 	if err == nil {
 		return ""
@@ -397,6 +725,22 @@ func errorMsg(err error) string {
 	return err.Error()
 }
 
+// pastCorrections renders the recorded feedback corrections relevant to
+// task as a prompt section, or "" when there are none
+func (c *Conductor) pastCorrections(task string) string {
+	events, err := feedback.QueryRelevant(task)
+	if err != nil {
+		if os.Getenv("GPTCODE_DEBUG") == "1" {
+			fmt.Fprintf(os.Stderr, "[WARN] Failed to query feedback: %v\n", err)
+		}
+		return ""
+	}
+	if len(events) > 0 && os.Getenv("GPTCODE_DEBUG") == "1" {
+		fmt.Fprintf(os.Stderr, "[MAESTRO] %d past corrections apply to this task\n", len(events))
+	}
+	return feedback.FormatCorrections(events)
+}
+
 func (c *Conductor) recordFeedback(backend, model, agent, task string, success bool, failureReason string) {
 	sentiment := feedback.SentimentBad
 	if success {
@@ -412,11 +756,16 @@ func (c *Conductor) recordFeedback(backend, model, agent, task string, success b
 		Context:   fmt.Sprintf("language=%s", c.language),
 	}
 
+	// Record prompt variants so experiments can be compared by outcome
+	event.Metadata = prompt.VariantMetadata()
+	event.Metadata["project"] = intelligence.ProjectKey(c.cwd)
+
 	// Add failure reason to metadata so we can learn from specific failure types
 	if !success && failureReason != "" {
-		event.Metadata = map[string]string{
-			"failure_reason": failureReason,
-		}
+		event.Metadata["failure_reason"] = failureReason
+	}
+	if len(event.Metadata) == 0 {
+		event.Metadata = nil
 	}
 
 	if err := feedback.Record(event); err != nil {
@@ -435,10 +784,7 @@ func (c *Conductor) createProvider(backendName string) llm.Provider {
 		backendCfg = c.setup.Backend[backendName]
 	}
 
-	if backendCfg.Type == "ollama" {
-		return llm.NewOllama(backendCfg.BaseURL)
-	}
-	return llm.NewChatCompletion(backendCfg.BaseURL, backendName)
+	return llm.NewForBackend(backendName, backendCfg)
 }
 
 // formatExecutionError creates clear feedback for execution errors
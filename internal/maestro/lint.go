@@ -4,6 +4,8 @@ import (
 	"context"
 	"os/exec"
 	"path/filepath"
+
+	"gptcode/internal/tools"
 )
 
 type LintVerifier struct {
@@ -17,43 +19,42 @@ func NewLintVerifier(dir string) *LintVerifier {
 }
 
 func (v *LintVerifier) Verify(ctx context.Context) (*VerificationResult, error) {
-	var cmd *exec.Cmd
+	var command string
 
 	switch v.Language {
 	case "go":
 		if commandExists("golangci-lint") {
-			cmd = exec.CommandContext(ctx, "golangci-lint", "run", "./...")
+			command = "golangci-lint run ./..."
 		} else {
-			cmd = exec.CommandContext(ctx, "go", "vet", "./...")
+			command = "go vet ./..."
 		}
 	case "javascript", "typescript":
 		if fileExists(filepath.Join(v.Dir, ".eslintrc.json")) || fileExists(filepath.Join(v.Dir, ".eslintrc.js")) {
 			if commandExists("eslint") {
-				cmd = exec.CommandContext(ctx, "npm", "run", "lint")
+				command = "npm run lint"
 			}
 		}
 	case "python":
 		if commandExists("ruff") {
-			cmd = exec.CommandContext(ctx, "ruff", "check", ".")
+			command = "ruff check ."
 		} else if commandExists("flake8") {
-			cmd = exec.CommandContext(ctx, "flake8", ".")
+			command = "flake8 ."
 		}
 	case "elixir":
-		cmd = exec.CommandContext(ctx, "mix", "format", "--check-formatted")
+		command = "mix format --check-formatted"
 	case "ruby":
 		if commandExists("rubocop") {
-			cmd = exec.CommandContext(ctx, "rubocop")
+			command = "rubocop"
 		}
 	default:
 		return &VerificationResult{Success: true}, nil
 	}
 
-	if cmd == nil {
+	if command == "" {
 		return &VerificationResult{Success: true}, nil
 	}
 
-	cmd.Dir = v.Dir
-	output, err := cmd.CombinedOutput()
+	output, err := tools.ActiveExecutor().Run(ctx, command, v.Dir)
 	outStr := string(output)
 
 	if err != nil {
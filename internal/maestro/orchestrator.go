@@ -16,6 +16,7 @@ import (
 	"gptcode/internal/llm"
 	"gptcode/internal/observability"
 	"gptcode/internal/telemetry"
+	"gptcode/internal/validation"
 )
 
 // Maestro orchestrates autonomous execution with verification and recovery
@@ -64,7 +65,7 @@ func (m *Maestro) ExecutePlan(ctx context.Context, planContent string) error {
 	_ = m.Events.Status("\u001b[36mStarting autonomous execution...\u001b[0m")
 
 	// Load setup to access budget settings
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -85,7 +86,11 @@ func (m *Maestro) ExecutePlan(ctx context.Context, planContent string) error {
 	sessionID := uuid.New().String()
 	if m.Tracer != nil {
 		_ = m.Tracer.Begin(sessionID, planContent)
-		defer func() { _ = m.Tracer.End(true) }() // End with success status (will be updated on error)
+		defer func() {
+			recordPromptVariants(m.Tracer)
+			_ = m.Tracer.End(true) // End with success status (will be updated on error)
+		}()
+		recordOverrides(m.Tracer)
 	}
 
 	// Parse plan into steps (simple version: split by phases)
@@ -360,14 +365,24 @@ func (m *Maestro) selectVerifiers() []Verifier {
 	// Only add verifiers if code files were modified
 	var verifiers []Verifier
 	if hasCodeFiles {
-		verifiers = append(verifiers, NewBuildVerifier(m.CWD))
-		verifiers = append(verifiers, NewTestVerifier(m.CWD))
+		// Commands configured for the project replace the detected ones
+		configured := validation.Configured(m.CWD)
+		if configured.Build != nil {
+			verifiers = append(verifiers, NewCommandVerifier(m.CWD, validation.KindBuild, configured.Build))
+		} else {
+			verifiers = append(verifiers, NewBuildVerifier(m.CWD))
+		}
+		if configured.Test != nil {
+			verifiers = append(verifiers, NewCommandVerifier(m.CWD, validation.KindTest, configured.Test))
+		} else {
+			verifiers = append(verifiers, NewTestVerifier(m.CWD))
+		}
 	}
 
-	// Add lint verifier if it was specifically requested
+	// Add lint verifier and commands if they were specifically requested
 	for _, originalVerifier := range m.Verifiers {
-		// Check if it's a LintVerifier by type assertion
-		if _, ok := originalVerifier.(*LintVerifier); ok {
+		switch originalVerifier.(type) {
+		case *LintVerifier, *CommandVerifier:
 			verifiers = append(verifiers, originalVerifier)
 		}
 	}
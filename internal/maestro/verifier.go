@@ -8,7 +8,10 @@ import (
 	"path/filepath"
 	"strings"
 
+	"gptcode/internal/config"
 	"gptcode/internal/langdetect"
+	"gptcode/internal/tools"
+	"gptcode/internal/validation"
 )
 
 type VerificationResult struct {
@@ -84,37 +87,36 @@ func (v *TestVerifier) Verify(ctx context.Context) (*VerificationResult, error)
 
 // runAllTests runs tests on the entire project
 func (v *TestVerifier) runAllTests(ctx context.Context) (*VerificationResult, error) {
-	var cmd *exec.Cmd
+	var command string
 
 	switch v.Language {
 	case "go":
-		cmd = exec.CommandContext(ctx, "go", "test", "./...")
+		command = "go test ./..."
 	case "javascript", "typescript":
 		if fileExists(filepath.Join(v.Dir, "package.json")) {
-			cmd = exec.CommandContext(ctx, "npm", "test")
+			command = "npm test"
 		} else {
 			return &VerificationResult{Success: true}, nil
 		}
 	case "python":
 		if fileExists(filepath.Join(v.Dir, "pytest.ini")) || fileExists(filepath.Join(v.Dir, "setup.py")) {
-			cmd = exec.CommandContext(ctx, "pytest")
+			command = "pytest"
 		} else {
 			return &VerificationResult{Success: true}, nil
 		}
 	case "elixir":
-		cmd = exec.CommandContext(ctx, "mix", "test")
+		command = "mix test"
 	case "ruby":
 		if fileExists(filepath.Join(v.Dir, "Gemfile")) {
-			cmd = exec.CommandContext(ctx, "bundle", "exec", "rspec")
+			command = "bundle exec rspec"
 		} else {
-			cmd = exec.CommandContext(ctx, "rspec")
+			command = "rspec"
 		}
 	default:
 		return &VerificationResult{Success: true}, nil
 	}
 
-	cmd.Dir = v.Dir
-	output, err := cmd.CombinedOutput()
+	output, err := tools.ActiveExecutor().Run(ctx, command, v.Dir)
 	outStr := string(output)
 
 	if err != nil {
@@ -148,9 +150,7 @@ func (v *TestVerifier) runGoTestsForModifiedFiles(ctx context.Context, modifiedF
 
 	// Run tests for each package that has modified files
 	for pkgDir := range packageDirs {
-		cmd := exec.CommandContext(ctx, "go", "test", "./...")
-		cmd.Dir = pkgDir
-		output, err := cmd.CombinedOutput()
+		output, err := tools.ActiveExecutor().Run(ctx, "go test ./...", pkgDir)
 		outStr := string(output)
 
 		if err != nil {
@@ -240,7 +240,7 @@ func (v *BuildVerifier) Verify(ctx context.Context) (*VerificationResult, error)
 		return &VerificationResult{Success: true, Output: "No code files modified, skipping build"}, nil
 	}
 
-	var cmd *exec.Cmd
+	var command string
 
 	switch v.Language {
 	case "go":
@@ -248,22 +248,21 @@ func (v *BuildVerifier) Verify(ctx context.Context) (*VerificationResult, error)
 		return v.runGoBuildForModifiedFiles(ctx, modifiedFiles)
 	case "javascript", "typescript":
 		if fileExists(filepath.Join(v.Dir, "package.json")) {
-			cmd = exec.CommandContext(ctx, "npm", "run", "build")
+			command = "npm run build"
 		} else {
 			return &VerificationResult{Success: true}, nil
 		}
 	case "python":
-		cmd = exec.CommandContext(ctx, "python", "-m", "py_compile")
+		command = "python -m py_compile"
 	case "elixir":
-		cmd = exec.CommandContext(ctx, "mix", "compile")
+		command = "mix compile"
 	case "ruby":
 		return &VerificationResult{Success: true}, nil
 	default:
 		return &VerificationResult{Success: true}, nil
 	}
 
-	cmd.Dir = v.Dir
-	output, err := cmd.CombinedOutput()
+	output, err := tools.ActiveExecutor().Run(ctx, command, v.Dir)
 	outStr := string(output)
 
 	if err != nil {
@@ -302,9 +301,7 @@ func (v *BuildVerifier) runGoBuildForModifiedFiles(ctx context.Context, modified
 	for pkgDir := range packageDirs {
 		// Check if the package directory has a go.mod file or is part of a go project
 		if fileExists(filepath.Join(pkgDir, "go.mod")) {
-			cmd := exec.CommandContext(ctx, "go", "build", "./...")
-			cmd.Dir = pkgDir
-			output, err := cmd.CombinedOutput()
+			output, err := tools.ActiveExecutor().Run(ctx, "go build ./...", pkgDir)
 			outStr := string(output)
 
 			if err != nil {
@@ -317,9 +314,7 @@ func (v *BuildVerifier) runGoBuildForModifiedFiles(ctx context.Context, modified
 		} else {
 			// If no go.mod in this directory, try to find the closest parent with go.mod
 			// For now, build the entire project to avoid issues with imports
-			cmd := exec.CommandContext(ctx, "go", "build", "./...")
-			cmd.Dir = v.Dir
-			output, err := cmd.CombinedOutput()
+			output, err := tools.ActiveExecutor().Run(ctx, "go build ./...", v.Dir)
 			outStr := string(output)
 
 			if err != nil {
@@ -340,6 +335,33 @@ func (v *BuildVerifier) runGoBuildForModifiedFiles(ctx context.Context, modified
 
 }
 
+// CommandVerifier runs a validation command configured in the project's
+// .gptcode/config.yml
+type CommandVerifier struct {
+	Dir     string
+	Kind    string
+	Command *config.ValidationCommand
+}
+
+func NewCommandVerifier(dir, kind string, command *config.ValidationCommand) *CommandVerifier {
+	return &CommandVerifier{Dir: dir, Kind: kind, Command: command}
+}
+
+func (v *CommandVerifier) Verify(ctx context.Context) (*VerificationResult, error) {
+	res, err := validation.RunCommand(ctx, v.Dir, v.Kind, v.Command)
+	if err != nil {
+		return nil, err
+	}
+	if !res.Success {
+		return &VerificationResult{
+			Success: false,
+			Output:  res.Output,
+			Error:   fmt.Errorf("%s command %q failed: %s", v.Kind, v.Command.Command, res.Reason),
+		}, nil
+	}
+	return &VerificationResult{Success: true, Output: res.Output}, nil
+}
+
 func detectLanguage(dir string) string {
 	lang := langdetect.DetectLanguage(dir)
 	switch lang {
@@ -353,6 +375,8 @@ func detectLanguage(dir string) string {
 		return "elixir"
 	case langdetect.Ruby:
 		return "ruby"
+	case langdetect.Rust:
+		return "rust"
 	default:
 		return "unknown"
 	}
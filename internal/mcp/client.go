@@ -0,0 +1,251 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ProtocolVersion is the MCP revision the client speaks
+const ProtocolVersion = "2024-11-05"
+
+// transport carries JSON-RPC messages to and from one server.
+type transport interface {
+	Send(msg []byte) error
+	// Messages delivers the server's messages and is closed when the
+	// connection ends
+	Messages() <-chan []byte
+	Close() error
+}
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// ErrClosed is returned for calls on a connection that has ended.
+var ErrClosed = errors.New("mcp connection closed")
+
+// ServerInfo identifies a connected server
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Tool is a tool a server offers
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+}
+
+// Content is one part of a tool result
+type Content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Resource *struct {
+		URI  string `json:"uri"`
+		Text string `json:"text,omitempty"`
+	} `json:"resource,omitempty"`
+}
+
+// CallResult is the result of a tool call
+type CallResult struct {
+	Content []Content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+}
+
+// Text renders the result for the LLM. Binary content is replaced by a
+// placeholder naming its type.
+func (r *CallResult) Text() string {
+	var parts []string
+	for _, c := range r.Content {
+		switch {
+		case c.Type == "text":
+			parts = append(parts, c.Text)
+		case c.Type == "resource" && c.Resource != nil && c.Resource.Text != "":
+			parts = append(parts, fmt.Sprintf("%s:\n%s", c.Resource.URI, c.Resource.Text))
+		case c.Type == "resource" && c.Resource != nil:
+			parts = append(parts, fmt.Sprintf("[resource %s]", c.Resource.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s %s]", c.Type, c.MimeType))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Client is a connection to one MCP server
+type Client struct {
+	Server ServerInfo
+
+	t       transport
+	mu      sync.Mutex
+	nextID  int64
+	pending map[string]chan rpcMessage
+	done    chan struct{}
+}
+
+func newClient(t transport) *Client {
+	c := &Client{t: t, pending: map[string]chan rpcMessage{}, done: make(chan struct{})}
+	go c.readLoop()
+	return c
+}
+
+func (c *Client) readLoop() {
+	defer close(c.done)
+	for data := range c.t.Messages() {
+		var msg rpcMessage
+		if json.Unmarshal(data, &msg) != nil {
+			continue
+		}
+		switch {
+		case msg.Method != "" && len(msg.ID) > 0:
+			c.answer(msg)
+		case msg.Method != "":
+			// Notifications (logging, list changes) need no reply
+		default:
+			c.mu.Lock()
+			ch := c.pending[string(msg.ID)]
+			delete(c.pending, string(msg.ID))
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+	}
+}
+
+// answer replies to a request from the server. Only ping is supported; the
+// client declares no capabilities such as sampling or roots.
+func (c *Client) answer(req rpcMessage) {
+	reply := rpcMessage{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		reply.Result = json.RawMessage("{}")
+	} else {
+		reply.Error = &rpcError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	if data, err := json.Marshal(reply); err == nil {
+		_ = c.t.Send(data)
+	}
+}
+
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	c.mu.Lock()
+	c.nextID++
+	id := json.RawMessage(strconv.FormatInt(c.nextID, 10))
+	ch := make(chan rpcMessage, 1)
+	c.pending[string(id)] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+
+	data, err := json.Marshal(rpcMessage{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if err := c.t.Send(data); err != nil {
+		return err
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(msg.Result, result)
+	case <-c.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(method string, params interface{}) error {
+	data, err := json.Marshal(rpcMessage{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return c.t.Send(data)
+}
+
+func (c *Client) initialize(ctx context.Context, version string) error {
+	var result struct {
+		ProtocolVersion string     `json:"protocolVersion"`
+		ServerInfo      ServerInfo `json:"serverInfo"`
+	}
+	err := c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      ServerInfo{Name: "gptcode", Version: version},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	c.Server = result.ServerInfo
+	return c.notify("notifications/initialized", nil)
+}
+
+// ListTools returns every tool the server offers.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var tools []Tool
+	cursor := ""
+	for {
+		var params interface{}
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		var page struct {
+			Tools      []Tool `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, fmt.Errorf("tools/list: %w", err)
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallTool runs a tool on the server.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallResult, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	result := &CallResult{}
+	if err := c.call(ctx, "tools/call", map[string]interface{}{"name": name, "arguments": args}, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Close ends the connection and stops a stdio server.
+func (c *Client) Close() error {
+	return c.t.Close()
+}
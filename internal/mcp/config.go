@@ -0,0 +1,109 @@
+// Package mcp is a Model Context Protocol client. It connects to the MCP
+// servers declared in ~/.gptcode/mcp.yaml over stdio or SSE, discovers their
+// tools and registers them with the agents' tools next to the builtin ones.
+package mcp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the contents of mcp.yaml:
+//
+//	servers:
+//	  filesystem:
+//	    command: npx
+//	    args: ["-y", "@modelcontextprotocol/server-filesystem", "."]
+//	  browser:
+//	    url: http://localhost:8931/sse
+//	    headers:
+//	      Authorization: Bearer ${BROWSER_TOKEN}
+type Config struct {
+	Servers map[string]ServerConfig `yaml:"servers"`
+}
+
+// ServerConfig declares one MCP server. Servers with a command are started
+// and spoken to over stdio; servers with a url are reached over SSE.
+// Environment variables in args, env values, url and headers are expanded.
+type ServerConfig struct {
+	Command   string            `yaml:"command,omitempty"`
+	Args      []string          `yaml:"args,omitempty"`
+	Env       map[string]string `yaml:"env,omitempty"`
+	Dir       string            `yaml:"dir,omitempty"`
+	URL       string            `yaml:"url,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	Transport string            `yaml:"transport,omitempty"`
+	Disabled  bool              `yaml:"disabled,omitempty"`
+	// Timeout is the limit for one tool call in seconds (default 60)
+	Timeout int `yaml:"timeout,omitempty"`
+	// Tools, when set, limits the server's tools offered to the agents
+	Tools []string `yaml:"tools,omitempty"`
+}
+
+// ConfigPath is ~/.gptcode/mcp.yaml
+func ConfigPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "mcp.yaml")
+}
+
+// LoadConfig reads the MCP configuration at path. A missing file is an empty
+// configuration.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	for name, server := range cfg.Servers {
+		if _, err := server.transportType(); err != nil {
+			return nil, fmt.Errorf("mcp server %s: %w", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// Names returns the enabled servers, sorted.
+func (c *Config) Names() []string {
+	var names []string
+	for name, server := range c.Servers {
+		if !server.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s ServerConfig) transportType() (string, error) {
+	switch s.Transport {
+	case "":
+		if s.Command != "" {
+			return "stdio", nil
+		}
+		if s.URL != "" {
+			return "sse", nil
+		}
+		return "", fmt.Errorf("needs a command (stdio) or a url (sse)")
+	case "stdio":
+		if s.Command == "" {
+			return "", fmt.Errorf("the stdio transport needs a command")
+		}
+	case "sse":
+		if s.URL == "" {
+			return "", fmt.Errorf("the sse transport needs a url")
+		}
+	default:
+		return "", fmt.Errorf("unknown transport %q (use stdio or sse)", s.Transport)
+	}
+	return s.Transport, nil
+}
@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"gptcode/internal/tools"
+)
+
+// defaultCallTimeout limits a tool call when the server sets no timeout
+const defaultCallTimeout = 60 * time.Second
+
+// Server is a connected server and the tools it offers the agents
+type Server struct {
+	Name   string
+	Client *Client
+	Tools  []Tool
+	config ServerConfig
+}
+
+// Connect starts or dials a server, initializes the session and lists its
+// tools, keeping only those allowed by cfg.Tools.
+func Connect(ctx context.Context, name string, cfg ServerConfig, version string) (*Server, error) {
+	kind, err := cfg.transportType()
+	if err != nil {
+		return nil, err
+	}
+
+	var t transport
+	var stderr *tailBuffer
+	switch kind {
+	case "stdio":
+		st, err := startStdio(cfg)
+		if err != nil {
+			return nil, err
+		}
+		t, stderr = st, st.stderr
+	case "sse":
+		if t, err = dialSSE(ctx, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	c := newClient(t)
+	fail := func(err error) (*Server, error) {
+		c.Close()
+		if stderr != nil && stderr.String() != "" {
+			return nil, fmt.Errorf("%w\n%s", err, stderr.String())
+		}
+		return nil, err
+	}
+	if err := c.initialize(ctx, version); err != nil {
+		return fail(err)
+	}
+	list, err := c.ListTools(ctx)
+	if err != nil {
+		return fail(err)
+	}
+
+	s := &Server{Name: name, Client: c, config: cfg}
+	allowed := map[string]bool{}
+	for _, t := range cfg.Tools {
+		allowed[t] = true
+	}
+	for _, tool := range list {
+		if len(allowed) == 0 || allowed[tool.Name] {
+			s.Tools = append(s.Tools, tool)
+		}
+	}
+	return s, nil
+}
+
+// ToolName is the name a server's tool is offered to the agents under,
+// e.g. mcp_filesystem_read_file.
+func ToolName(server, tool string) string {
+	name := "mcp_" + invalidName.ReplaceAllString(server, "_") + "_" + invalidName.ReplaceAllString(tool, "_")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+var invalidName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Manager holds the connections to every configured server
+type Manager struct {
+	Servers []*Server
+}
+
+// ConnectAll connects to the enabled servers of cfg in parallel. Servers
+// that fail are left out and reported in the returned error.
+func ConnectAll(ctx context.Context, cfg *Config, version string) (*Manager, error) {
+	names := cfg.Names()
+	servers := make([]*Server, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			s, err := Connect(ctx, name, cfg.Servers[name], version)
+			if err != nil {
+				errs[i] = fmt.Errorf("mcp server %s: %w", name, err)
+				return
+			}
+			servers[i] = s
+		}(i, name)
+	}
+	wg.Wait()
+
+	m := &Manager{}
+	for _, s := range servers {
+		if s != nil {
+			m.Servers = append(m.Servers, s)
+		}
+	}
+	return m, errors.Join(errs...)
+}
+
+// Register offers every server's tools to the agents and returns how many
+// were registered.
+func (m *Manager) Register() int {
+	n := 0
+	for _, s := range m.Servers {
+		timeout := defaultCallTimeout
+		if s.config.Timeout > 0 {
+			timeout = time.Duration(s.config.Timeout) * time.Second
+		}
+		for _, tool := range s.Tools {
+			client, toolName := s.Client, tool.Name
+			tools.RegisterExternalTool(tools.ExternalTool{
+				Name:        ToolName(s.Name, tool.Name),
+				Description: fmt.Sprintf("[MCP %s] %s", s.Name, tool.Description),
+				Parameters:  tool.InputSchema,
+				Call: func(args map[string]interface{}) (string, error) {
+					ctx, cancel := context.WithTimeout(context.Background(), timeout)
+					defer cancel()
+					res, err := client.CallTool(ctx, toolName, args)
+					if err != nil {
+						return "", err
+					}
+					if res.IsError {
+						return "", errors.New(res.Text())
+					}
+					return res.Text(), nil
+				},
+			})
+			n++
+		}
+	}
+	return n
+}
+
+// ToolNames returns the registered names of every server's tools, sorted.
+func (m *Manager) ToolNames() []string {
+	var names []string
+	for _, s := range m.Servers {
+		for _, t := range s.Tools {
+			names = append(names, ToolName(s.Name, t.Name))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close disconnects every server.
+func (m *Manager) Close() {
+	for _, s := range m.Servers {
+		_ = s.Client.Close()
+	}
+}
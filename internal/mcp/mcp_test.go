@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/tools"
+)
+
+// TestMain turns the test binary into a fake stdio MCP server when asked to.
+func TestMain(m *testing.M) {
+	if os.Getenv("GPTCODE_FAKE_MCP") == "1" {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if reply := fakeServer(scanner.Bytes()); reply != nil {
+				fmt.Println(string(reply))
+			}
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakeServer answers one JSON-RPC message with an echo tool.
+func fakeServer(data []byte) []byte {
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		} `json:"params"`
+	}
+	if json.Unmarshal(data, &req) != nil || len(req.ID) == 0 {
+		return nil
+	}
+	var result interface{}
+	switch req.Method {
+	case "initialize":
+		result = map[string]interface{}{"protocolVersion": ProtocolVersion, "serverInfo": ServerInfo{Name: "fake", Version: "1.0"}}
+	case "tools/list":
+		result = map[string]interface{}{"tools": []Tool{
+			{Name: "echo", Description: "Echo text", InputSchema: map[string]interface{}{"type": "object"}},
+			{Name: "fail", Description: "Always fails"},
+		}}
+	case "tools/call":
+		if req.Params.Name == "fail" {
+			result = CallResult{IsError: true, Content: []Content{{Type: "text", Text: "it failed"}}}
+		} else {
+			result = CallResult{Content: []Content{{Type: "text", Text: fmt.Sprint(req.Params.Arguments["text"])}}}
+		}
+	}
+	reply, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	return reply
+}
+
+func TestStdioServerToolsReachTheAgents(t *testing.T) {
+	t.Setenv("GPTCODE_FAKE_MCP", "1")
+	cfg := &Config{Servers: map[string]ServerConfig{
+		"fake":   {Command: os.Args[0], Args: []string{"-test.run=^$"}},
+		"broken": {Command: filepath.Join(t.TempDir(), "missing")},
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	m, err := ConnectAll(ctx, cfg, "test")
+	defer m.Close()
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the broken server to be reported, got %v", err)
+	}
+	if len(m.Servers) != 1 || m.Servers[0].Client.Server.Name != "fake" {
+		t.Fatalf("unexpected servers %+v", m.Servers)
+	}
+
+	defer tools.UnregisterExternalTools()
+	if n := m.Register(); n != 2 {
+		t.Fatalf("registered %d tools, want 2", n)
+	}
+	found := false
+	for _, def := range tools.GetAvailableTools() {
+		found = found || def["function"].(map[string]interface{})["name"] == "mcp_fake_echo"
+	}
+	if !found {
+		t.Error("mcp_fake_echo missing from the available tools")
+	}
+
+	res := tools.ExecuteTool(tools.ToolCall{Name: "mcp_fake_echo", Arguments: map[string]interface{}{"text": "hi"}}, t.TempDir())
+	if res.Error != "" || res.Result != "hi" {
+		t.Errorf("echo returned %+v", res)
+	}
+	res = tools.ExecuteTool(tools.ToolCall{Name: "mcp_fake_fail"}, t.TempDir())
+	if res.Error != "it failed" {
+		t.Errorf("fail returned %+v", res)
+	}
+}
+
+func TestSSEServer(t *testing.T) {
+	events := make(chan []byte, 8)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: endpoint\ndata: /messages?session=1\n\n")
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case msg := <-events:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/messages", func(w http.ResponseWriter, r *http.Request) {
+		var body json.RawMessage
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if reply := fakeServer(body); reply != nil {
+			events <- reply
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	s, err := Connect(ctx, "web", ServerConfig{URL: srv.URL + "/sse", Tools: []string{"echo"}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Client.Close()
+	if len(s.Tools) != 1 || s.Tools[0].Name != "echo" {
+		t.Fatalf("tools should be limited to the allowlist, got %+v", s.Tools)
+	}
+	res, err := s.Client.CallTool(ctx, "echo", map[string]interface{}{"text": "over sse"})
+	if err != nil || res.Text() != "over sse" {
+		t.Errorf("CallTool = %+v, %v", res, err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.yaml")
+	_ = os.WriteFile(path, []byte("servers:\n  fs:\n    command: npx\n  off:\n    url: http://x\n    disabled: true\n"), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := cfg.Names(); len(names) != 1 || names[0] != "fs" {
+		t.Errorf("Names() = %v", names)
+	}
+
+	_ = os.WriteFile(path, []byte("servers:\n  bad:\n    transport: stdio\n"), 0644)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected an error for a stdio server without a command")
+	}
+	if cfg, err := LoadConfig(filepath.Join(t.TempDir(), "none.yaml")); err != nil || len(cfg.Servers) != 0 {
+		t.Errorf("missing config should be empty, got %v %v", cfg, err)
+	}
+}
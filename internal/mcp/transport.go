@@ -0,0 +1,244 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stdioTransport runs a server as a subprocess exchanging newline-delimited
+// JSON-RPC messages on its stdin and stdout.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	msgs   chan []byte
+	stderr *tailBuffer
+	mu     sync.Mutex
+	exited chan struct{}
+}
+
+func startStdio(cfg ServerConfig) (*stdioTransport, error) {
+	args := make([]string, len(cfg.Args))
+	for i, a := range cfg.Args {
+		args[i] = os.ExpandEnv(a)
+	}
+	cmd := exec.Command(os.ExpandEnv(cfg.Command), args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+os.ExpandEnv(v))
+	}
+	t := &stdioTransport{cmd: cmd, msgs: make(chan []byte, 16), stderr: &tailBuffer{max: 4096}, exited: make(chan struct{})}
+	cmd.Stderr = t.stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	t.stdin = stdin
+
+	go func() {
+		defer close(t.msgs)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) > 0 {
+				t.msgs <- append([]byte(nil), line...)
+			}
+		}
+	}()
+	go func() {
+		_ = cmd.Wait()
+		close(t.exited)
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) Send(msg []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := t.stdin.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *stdioTransport) Messages() <-chan []byte { return t.msgs }
+
+// Close closes the server's stdin, which tells it to exit, and kills it if
+// it has not within a few seconds.
+func (t *stdioTransport) Close() error {
+	_ = t.stdin.Close()
+	select {
+	case <-t.exited:
+	case <-time.After(3 * time.Second):
+		_ = t.cmd.Process.Kill()
+		<-t.exited
+	}
+	return nil
+}
+
+// tailBuffer keeps the last max bytes written to it, for reporting why a
+// server failed.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.buf))
+}
+
+// sseTransport is the HTTP+SSE transport: the server streams its messages as
+// server-sent events and names, in an initial endpoint event, the URL the
+// client posts its messages to.
+type sseTransport struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+	msgs     chan []byte
+	cancel   context.CancelFunc
+}
+
+func dialSSE(ctx context.Context, cfg ServerConfig) (*sseTransport, error) {
+	base, err := url.Parse(os.ExpandEnv(cfg.URL))
+	if err != nil {
+		return nil, err
+	}
+	headers := map[string]string{}
+	for k, v := range cfg.Headers {
+		headers[k] = os.ExpandEnv(v)
+	}
+
+	streamCtx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, base.String(), nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("GET %s: %s", base, resp.Status)
+	}
+
+	t := &sseTransport{headers: headers, client: &http.Client{Timeout: 30 * time.Second}, msgs: make(chan []byte, 16), cancel: cancel}
+	endpoint := make(chan string, 1)
+	go func() {
+		defer close(t.msgs)
+		defer resp.Body.Close()
+		readEvents(resp.Body, func(event, data string) {
+			switch event {
+			case "endpoint":
+				select {
+				case endpoint <- data:
+				default:
+				}
+			case "message", "":
+				t.msgs <- []byte(data)
+			}
+		})
+	}()
+
+	select {
+	case data := <-endpoint:
+		ref, err := base.Parse(data)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid endpoint %q: %w", data, err)
+		}
+		t.endpoint = ref.String()
+		return t, nil
+	case <-ctx.Done():
+		cancel()
+		return nil, fmt.Errorf("no endpoint event from %s: %w", base, ctx.Err())
+	}
+}
+
+// readEvents parses a server-sent event stream, calling fn for each event.
+func readEvents(r io.Reader, fn func(event, data string)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var event string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 {
+				fn(event, strings.Join(data, "\n"))
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+}
+
+func (t *sseTransport) Send(msg []byte) error {
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST %s: %s", t.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (t *sseTransport) Messages() <-chan []byte { return t.msgs }
+
+func (t *sseTransport) Close() error {
+	t.cancel()
+	return nil
+}
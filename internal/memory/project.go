@@ -0,0 +1,184 @@
+package memory
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gptcode/internal/config"
+)
+
+// ProjectMemoryFile is where a project's facts are kept, relative to the
+// project root
+const ProjectMemoryFile = ".gptcode/memory.jsonl"
+
+// Kinds of project facts
+const (
+	KindDecision   = "decision"
+	KindConvention = "convention"
+	KindGotcha     = "gotcha"
+	KindNote       = "note"
+)
+
+// Fact is something durable learned about a project: an architecture
+// decision, a convention or a gotcha worth knowing in later sessions
+type Fact struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Kind      string    `json:"kind"`
+	Text      string    `json:"text"`
+	// Source is where the fact came from: chat, do or user
+	Source string `json:"source,omitempty"`
+}
+
+// ProjectStore is the memory of one project, kept in its .gptcode directory
+type ProjectStore struct {
+	Path string
+	// MaxFacts caps how many facts are kept; the oldest are dropped first
+	MaxFacts int
+}
+
+// NewProjectStore returns the memory of the project containing dir
+func NewProjectStore(dir string) *ProjectStore {
+	return &ProjectStore{
+		Path:     filepath.Join(config.ProjectRoot(dir), ProjectMemoryFile),
+		MaxFacts: 200,
+	}
+}
+
+// List returns the project's facts, oldest first
+func (s *ProjectStore) List() ([]Fact, error) {
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var facts []Fact
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var fact Fact
+		if json.Unmarshal(sc.Bytes(), &fact) == nil && fact.Text != "" {
+			facts = append(facts, fact)
+		}
+	}
+	return facts, sc.Err()
+}
+
+// Add appends the facts not already known, returning those added
+func (s *ProjectStore) Add(facts ...Fact) ([]Fact, error) {
+	existing, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, f := range existing {
+		seen[normalize(f.Text)] = true
+	}
+
+	var added []Fact
+	for _, f := range facts {
+		f.Text = strings.TrimSpace(f.Text)
+		key := normalize(f.Text)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if f.Kind == "" {
+			f.Kind = KindNote
+		}
+		if f.Timestamp.IsZero() {
+			f.Timestamp = time.Now().UTC()
+		}
+		f.ID = factID(f.Text)
+		added = append(added, f)
+	}
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	all := append(existing, added...)
+	if s.MaxFacts > 0 && len(all) > s.MaxFacts {
+		all = all[len(all)-s.MaxFacts:]
+	}
+	return added, s.write(all)
+}
+
+// Forget removes the facts whose ID starts with id, which must name exactly
+// one fact
+func (s *ProjectStore) Forget(id string) (Fact, error) {
+	facts, err := s.List()
+	if err != nil {
+		return Fact{}, err
+	}
+	match := -1
+	for i, f := range facts {
+		if id != "" && strings.HasPrefix(f.ID, id) {
+			if match >= 0 {
+				return Fact{}, fmt.Errorf("%q matches more than one fact", id)
+			}
+			match = i
+		}
+	}
+	if match < 0 {
+		return Fact{}, fmt.Errorf("no fact with id %q", id)
+	}
+	forgotten := facts[match]
+	facts = append(facts[:match], facts[match+1:]...)
+	return forgotten, s.write(facts)
+}
+
+// Format lists the facts for a prompt, newest last, in at most maxLen bytes
+func (s *ProjectStore) Format(maxLen int) string {
+	facts, _ := s.List()
+	var lines []string
+	size := 0
+	for i := len(facts) - 1; i >= 0; i-- {
+		line := fmt.Sprintf("- (%s) %s", facts[i].Kind, facts[i].Text)
+		if maxLen > 0 && size+len(line) > maxLen {
+			break
+		}
+		size += len(line) + 1
+		lines = append([]string{line}, lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *ProjectStore) write(facts []Fact) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0755); err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, f := range facts {
+		line, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.TrimRight(text, ". "))), " ")
+}
+
+func factID(text string) string {
+	sum := sha1.Sum([]byte(normalize(text)))
+	return hex.EncodeToString(sum[:])[:8]
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+type factProvider struct {
+	reply  string
+	prompt string
+}
+
+func (p *factProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.prompt = req.UserPrompt
+	return &llm.ChatResponse{Text: p.reply}, nil
+}
+
+func TestProjectStore(t *testing.T) {
+	store := &ProjectStore{Path: filepath.Join(t.TempDir(), ".gptcode", "memory.jsonl"), MaxFacts: 3}
+
+	added, err := store.Add(
+		Fact{Kind: KindConvention, Text: "Handlers live in internal/api."},
+		Fact{Kind: KindGotcha, Text: "handlers live in  internal/api"},
+		Fact{Text: "Use make test, not go test"},
+	)
+	if err != nil || len(added) != 2 {
+		t.Fatalf("Add = %+v, %v; want 2 facts, the duplicate dropped", added, err)
+	}
+	if added[1].Kind != KindNote || added[1].ID == "" {
+		t.Errorf("a fact without kind is a note with an id: %+v", added[1])
+	}
+
+	_, _ = store.Add(Fact{Text: "a"}, Fact{Text: "b"})
+	facts, _ := store.List()
+	if len(facts) != 3 || facts[0].Text != "Use make test, not go test" {
+		t.Errorf("the oldest facts are dropped past MaxFacts: %+v", facts)
+	}
+
+	if _, err := store.Forget(facts[0].ID[:4]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Forget("nope"); err == nil {
+		t.Error("forgetting an unknown id should fail")
+	}
+	if got := store.Format(0); got != "- (note) a\n- (note) b" {
+		t.Errorf("Format = %q", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	store := &ProjectStore{Path: filepath.Join(t.TempDir(), "memory.jsonl")}
+	_, _ = store.Add(Fact{Kind: KindDecision, Text: "Config is YAML, not TOML"})
+
+	p := &factProvider{reply: "```json\n[" +
+		`{"kind":"convention","text":"Errors are wrapped with %w"},` +
+		`{"kind":"decision","text":"Config is YAML, not TOML."},` +
+		`{"kind":"progress","text":"Half of the task is done"}` +
+		"]\n```"}
+	added, err := Summarize(context.Background(), p, "m", store, "chat", "User: how do we wrap errors?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || added[0].Text != "Errors are wrapped with %w" || added[0].Source != "chat" {
+		t.Errorf("only the new convention should be added: %+v", added)
+	}
+	if !strings.Contains(p.prompt, "Known facts:\n- (decision) Config is YAML") {
+		t.Errorf("the known facts are not sent: %q", p.prompt)
+	}
+
+	p.reply = "[]"
+	long := "Task: add retries\n" + strings.Repeat("x", maxTranscript)
+	if _, err := Summarize(context.Background(), p, "m", store, "do", long); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(p.prompt, "Session:\nTask: add retries") {
+		t.Error("a long session should be summarized from its start")
+	}
+
+	p.reply = "Nothing durable here."
+	if _, err := Summarize(context.Background(), p, "m", store, "chat", "User: hi"); err == nil {
+		t.Error("a reply without an array should fail")
+	}
+}
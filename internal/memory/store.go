@@ -19,6 +19,9 @@ type JSONLMemStore struct {
 	MaxEntries   int
 	GlobalMaxLen int
 	FallbackLang string
+	// Project holds the facts learned about the current project, listed
+	// before the snippets
+	Project *ProjectStore
 }
 
 func NewJSONLMemStore() *JSONLMemStore {
@@ -41,10 +44,24 @@ type entry struct {
 }
 
 func LoadStore() (Store, error) {
-	return NewJSONLMemStore(), nil
+	s := NewJSONLMemStore()
+	if cwd, err := os.Getwd(); err == nil {
+		s.Project = NewProjectStore(cwd)
+	}
+	return s, nil
 }
 
 func (s *JSONLMemStore) LastRelevant(lang string) string {
+	var facts string
+	if s.Project != nil {
+		if known := s.Project.Format(s.GlobalMaxLen / 2); known != "" {
+			facts = "Project facts:\n" + known + "\n\n"
+		}
+	}
+	return facts + s.lastSnippets(lang)
+}
+
+func (s *JSONLMemStore) lastSnippets(lang string) string {
 	f, err := os.Open(s.Path)
 	if err != nil {
 		return ""
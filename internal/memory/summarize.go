@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gptcode/internal/llm"
+)
+
+const summarizePrompt = `You maintain the long-term memory of a software project. From a coding session, extract the facts about the project worth knowing in future sessions:
+- decision: an architecture or design decision and its reason
+- convention: how code in this project is written, named, tested or laid out
+- gotcha: a pitfall, surprising behavior or workaround
+
+Rules:
+- Only durable facts about the project, not about this session's task, its progress or the user's mood.
+- Each fact is one self-contained sentence naming the files, packages or commands involved.
+- Skip facts already in the known facts.
+- Most sessions teach nothing durable; then reply with [].
+
+Reply with a JSON array only, e.g. [{"kind":"convention","text":"Errors are wrapped with fmt.Errorf and %w."}]`
+
+// maxTranscript caps how much of a session is sent to be summarized,
+// keeping its start: the task and the first exchanges, which the rest
+// builds on
+const maxTranscript = 24000
+
+// Summarize asks the model for the durable facts a session taught about the
+// project and appends the new ones to the store, returning them.
+func Summarize(ctx context.Context, provider llm.Provider, model string, store *ProjectStore, source, transcript string) ([]Fact, error) {
+	transcript = strings.TrimSpace(transcript)
+	if transcript == "" {
+		return nil, nil
+	}
+	if len(transcript) > maxTranscript {
+		transcript = transcript[:maxTranscript]
+	}
+
+	userPrompt := "Session:\n" + transcript
+	if known := store.Format(4000); known != "" {
+		userPrompt = "Known facts:\n" + known + "\n\n" + userPrompt
+	}
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: summarizePrompt,
+		UserPrompt:   userPrompt,
+		Model:        model,
+	})
+	if err != nil {
+		return nil, err
+	}
+	facts, err := parseFacts(resp.Text)
+	if err != nil {
+		return nil, err
+	}
+	for i := range facts {
+		facts[i].Source = source
+	}
+	return store.Add(facts...)
+}
+
+// parseFacts reads the model's JSON array, tolerating code fences and text
+// around it, and drops facts of unknown kinds
+func parseFacts(text string) ([]Fact, error) {
+	start := strings.Index(text, "[")
+	end := strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in the model's reply: %q", text)
+	}
+	var raw []struct {
+		Kind string `json:"kind"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(text[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse facts: %w", err)
+	}
+	var facts []Fact
+	for _, r := range raw {
+		switch r.Kind {
+		case KindDecision, KindConvention, KindGotcha:
+			facts = append(facts, Fact{Kind: r.Kind, Text: r.Text})
+		}
+	}
+	return facts, nil
+}
@@ -0,0 +1,270 @@
+package merge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gptcode/internal/llm"
+)
+
+// Hunk is one conflict of a file: the lines between <<<<<<< and >>>>>>>
+type Hunk struct {
+	// Line is the 1-based line of the <<<<<<< marker
+	Line        int
+	OursLabel   string
+	BaseLabel   string
+	TheirsLabel string
+	Ours        string
+	Theirs      string
+	// Base is the common ancestor, present with merge.conflictStyle=diff3
+	Base    string
+	HasBase bool
+
+	// Resolution replaces the hunk once Accepted; Confidence (0-1) and
+	// Reason come from whoever proposed it
+	Resolution string
+	Confidence float64
+	Reason     string
+	Accepted   bool
+}
+
+// Document is a conflicted file split into plain text and hunks
+type Document struct {
+	parts []part
+}
+
+type part struct {
+	text string
+	hunk *Hunk
+}
+
+// ParseHunks splits content at its conflict markers. A marker left open is
+// an error, since writing the file back would lose the conflict.
+func ParseHunks(content string) (*Document, error) {
+	doc := &Document{}
+	lines := strings.SplitAfter(content, "\n")
+	var text strings.Builder
+	var hunk *Hunk
+	var section *strings.Builder
+	var ours, base, theirs strings.Builder
+
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case hunk == nil && strings.HasPrefix(trimmed, "<<<<<<<"):
+			if text.Len() > 0 {
+				doc.parts = append(doc.parts, part{text: text.String()})
+				text.Reset()
+			}
+			hunk = &Hunk{Line: i + 1, OursLabel: markerLabel(trimmed)}
+			ours.Reset()
+			base.Reset()
+			theirs.Reset()
+			section = &ours
+		case hunk != nil && strings.HasPrefix(trimmed, "|||||||"):
+			hunk.HasBase = true
+			hunk.BaseLabel = markerLabel(trimmed)
+			section = &base
+		case hunk != nil && trimmed == "=======":
+			section = &theirs
+		case hunk != nil && strings.HasPrefix(trimmed, ">>>>>>>") && section == &theirs:
+			hunk.TheirsLabel = markerLabel(trimmed)
+			hunk.Ours, hunk.Base, hunk.Theirs = ours.String(), base.String(), theirs.String()
+			doc.parts = append(doc.parts, part{hunk: hunk})
+			hunk = nil
+		case hunk != nil:
+			section.WriteString(line)
+		default:
+			text.WriteString(line)
+		}
+	}
+	if hunk != nil {
+		return nil, fmt.Errorf("conflict at line %d has no closing >>>>>>> marker", hunk.Line)
+	}
+	if text.Len() > 0 {
+		doc.parts = append(doc.parts, part{text: text.String()})
+	}
+	return doc, nil
+}
+
+func markerLabel(line string) string {
+	return strings.TrimSpace(line[7:])
+}
+
+// Hunks returns the conflicts in file order
+func (d *Document) Hunks() []*Hunk {
+	var hunks []*Hunk
+	for _, p := range d.parts {
+		if p.hunk != nil {
+			hunks = append(hunks, p.hunk)
+		}
+	}
+	return hunks
+}
+
+// Unresolved counts the hunks not accepted yet
+func (d *Document) Unresolved() int {
+	n := 0
+	for _, h := range d.Hunks() {
+		if !h.Accepted {
+			n++
+		}
+	}
+	return n
+}
+
+// Render writes the file back: accepted hunks become their resolution, the
+// others keep their markers so git still sees them as conflicts
+func (d *Document) Render() string {
+	var b strings.Builder
+	for _, p := range d.parts {
+		switch {
+		case p.hunk == nil:
+			b.WriteString(p.text)
+		case p.hunk.Accepted:
+			b.WriteString(p.hunk.Resolution)
+		default:
+			h := p.hunk
+			b.WriteString(withLabel("<<<<<<<", h.OursLabel) + "\n" + h.Ours)
+			if h.HasBase {
+				b.WriteString(withLabel("|||||||", h.BaseLabel) + "\n" + h.Base)
+			}
+			b.WriteString("=======\n" + h.Theirs + withLabel(">>>>>>>", h.TheirsLabel) + "\n")
+		}
+	}
+	return b.String()
+}
+
+func withLabel(marker, label string) string {
+	if label == "" {
+		return marker
+	}
+	return marker + " " + label
+}
+
+// context returns up to n lines of text on each side of h
+func (d *Document) context(h *Hunk, n int) (before, after string) {
+	for i, p := range d.parts {
+		if p.hunk != h {
+			continue
+		}
+		if i > 0 && d.parts[i-1].hunk == nil {
+			lines := strings.SplitAfter(d.parts[i-1].text, "\n")
+			before = strings.Join(lines[max(0, len(lines)-n-1):], "")
+		}
+		if i+1 < len(d.parts) && d.parts[i+1].hunk == nil {
+			lines := strings.SplitAfter(d.parts[i+1].text, "\n")
+			after = strings.Join(lines[:min(n, len(lines))], "")
+		}
+	}
+	return before, after
+}
+
+// TrivialResolution settles hunks that need no judgement: both sides made
+// the same change, or only one side changed the base. ok is false otherwise.
+func TrivialResolution(h *Hunk) (resolution, reason string, ok bool) {
+	switch {
+	case h.Ours == h.Theirs:
+		return h.Ours, "both sides made the same change", true
+	case h.HasBase && h.Ours == h.Base:
+		return h.Theirs, "only " + sideName(h.TheirsLabel, "theirs") + " changed this", true
+	case h.HasBase && h.Theirs == h.Base:
+		return h.Ours, "only " + sideName(h.OursLabel, "ours") + " changed this", true
+	}
+	return "", "", false
+}
+
+func sideName(label, fallback string) string {
+	if label == "" {
+		return fallback
+	}
+	return label
+}
+
+// contextLines is how much of the file around a hunk the model sees
+const contextLines = 30
+
+// ResolveHunk proposes a resolution for h with the model's confidence in it.
+// Trivial hunks are settled without asking it, at full confidence.
+func (r *Resolver) ResolveHunk(ctx context.Context, path string, doc *Document, h *Hunk) error {
+	if resolution, reason, ok := TrivialResolution(h); ok {
+		h.Resolution, h.Reason, h.Confidence = resolution, reason, 1
+		return nil
+	}
+
+	before, after := doc.context(h, contextLines)
+	base := "(not recorded; set merge.conflictStyle=diff3 to include it)"
+	if h.HasBase {
+		base = h.Base
+	}
+	prompt := fmt.Sprintf(`Resolve one conflict of %s.
+
+Code before the conflict:
+%s
+Ours (%s):
+%s
+Common ancestor:
+%s
+Theirs (%s):
+%s
+Code after the conflict:
+%s
+Reply with JSON only:
+{"resolution": "<the lines that replace the whole conflict, markers removed>", "confidence": <0 to 1>, "reason": "<one sentence>"}
+
+Keep the intent of both sides when they are compatible. confidence is how sure you are the
+resolution compiles and keeps both intents; below 0.5 when one side's change has to be dropped
+or you are guessing.`, path, before, sideName(h.OursLabel, "ours"), h.Ours, base,
+		sideName(h.TheirsLabel, "theirs"), h.Theirs, after)
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	resp, err := r.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You resolve Git merge conflicts one hunk at a time. Return only valid JSON.",
+		UserPrompt:   prompt,
+		Model:        r.model,
+	})
+	if err != nil {
+		return fmt.Errorf("LLM resolution failed: %w", err)
+	}
+	return parseHunkResolution(resp.Text, h)
+}
+
+func parseHunkResolution(text string, h *Hunk) error {
+	text = strings.TrimSpace(text)
+	if start, end := strings.Index(text, "{"), strings.LastIndex(text, "}"); start >= 0 && end > start {
+		text = text[start : end+1]
+	}
+	var answer struct {
+		Resolution string  `json:"resolution"`
+		Confidence float64 `json:"confidence"`
+		Reason     string  `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(text), &answer); err != nil {
+		return fmt.Errorf("failed to parse resolution: %w", err)
+	}
+
+	h.Resolution = answer.Resolution
+	if h.Resolution != "" && !strings.HasSuffix(h.Resolution, "\n") {
+		h.Resolution += "\n"
+	}
+	h.Confidence = min(max(answer.Confidence, 0), 1)
+	h.Reason = answer.Reason
+	if strings.Contains(h.Resolution, "<<<<<<<") || strings.Contains(h.Resolution, ">>>>>>>") {
+		h.Confidence = 0
+		h.Reason = "the proposal still has conflict markers"
+	}
+	return nil
+}
+
+// MergetoolConfig is the ~/.gitconfig snippet that registers binary as
+// git mergetool --tool=gptcode
+func MergetoolConfig(binary string) string {
+	return fmt.Sprintf(`[mergetool "gptcode"]
+	cmd = %s git resolve --mergetool \"$MERGED\"
+	trustExitCode = true
+`, binary)
+}
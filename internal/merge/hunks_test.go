@@ -0,0 +1,83 @@
+package merge
+
+import (
+	"strings"
+	"testing"
+)
+
+const conflicted = `package main
+
+<<<<<<< HEAD
+const timeout = 30
+=======
+const timeout = 60
+>>>>>>> feature
+func main() {
+<<<<<<< HEAD
+	run()
+||||||| base
+	run()
+=======
+	runWithRetry()
+>>>>>>> feature
+}
+`
+
+func TestParseHunks(t *testing.T) {
+	doc, err := ParseHunks(conflicted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hunks := doc.Hunks()
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks, want 2", len(hunks))
+	}
+	first := hunks[0]
+	if first.Line != 3 || first.OursLabel != "HEAD" || first.TheirsLabel != "feature" ||
+		first.Ours != "const timeout = 30\n" || first.Theirs != "const timeout = 60\n" || first.HasBase {
+		t.Errorf("first hunk = %+v", first)
+	}
+	if !hunks[1].HasBase || hunks[1].Base != "\trun()\n" {
+		t.Errorf("second hunk should carry its diff3 base: %+v", hunks[1])
+	}
+	if got := doc.Render(); got != conflicted {
+		t.Errorf("rendering without resolutions should round-trip:\n%s", got)
+	}
+
+	first.Resolution, first.Accepted = "const timeout = 60\n", true
+	if doc.Unresolved() != 1 {
+		t.Errorf("Unresolved = %d, want 1", doc.Unresolved())
+	}
+	got := doc.Render()
+	if !strings.Contains(got, "package main\n\nconst timeout = 60\nfunc main() {\n<<<<<<< HEAD\n") {
+		t.Errorf("accepted hunk not applied:\n%s", got)
+	}
+
+	if _, err := ParseHunks("a\n<<<<<<< HEAD\nb\n=======\n"); err == nil {
+		t.Error("an unterminated conflict should fail")
+	}
+}
+
+func TestTrivialResolution(t *testing.T) {
+	doc, _ := ParseHunks(conflicted)
+	hunks := doc.Hunks()
+	if _, _, ok := TrivialResolution(hunks[0]); ok {
+		t.Error("both sides changed the first hunk differently")
+	}
+	if res, _, ok := TrivialResolution(hunks[1]); !ok || res != "\trunWithRetry()\n" {
+		t.Errorf("only theirs changed the second hunk: %q %v", res, ok)
+	}
+}
+
+func TestParseHunkResolution(t *testing.T) {
+	h := &Hunk{}
+	if err := parseHunkResolution("```json\n{\"resolution\": \"x := 1\", \"confidence\": 1.4, \"reason\": \"r\"}\n```", h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Resolution != "x := 1\n" || h.Confidence != 1 || h.Reason != "r" {
+		t.Errorf("parsed %+v", h)
+	}
+	if err := parseHunkResolution(`{"resolution": "<<<<<<< HEAD\n", "confidence": 0.9}`, h); err != nil || h.Confidence != 0 {
+		t.Errorf("a proposal with markers should have no confidence: %+v %v", h, err)
+	}
+}
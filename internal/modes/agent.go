@@ -0,0 +1,118 @@
+package modes
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"gptcode/internal/agents"
+	"gptcode/internal/config"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/llm"
+	"gptcode/internal/output"
+)
+
+// RunAgent runs the custom agent name, defined in ~/.gptcode/agents, on
+// task in the working directory.
+func RunAgent(name, task string) error {
+	setup, err := config.LoadEffectiveSetup()
+	if err != nil {
+		return fmt.Errorf("failed to load setup: %w", err)
+	}
+	specs, err := agents.LoadCustomAgents(agents.CustomAgentsDir())
+	if err != nil {
+		return err
+	}
+	var spec *agents.CustomAgentSpec
+	var names []string
+	for i := range specs {
+		names = append(names, specs[i].Name)
+		if specs[i].Name == name {
+			spec = &specs[i]
+		}
+	}
+	if spec == nil {
+		if len(names) == 0 {
+			return fmt.Errorf("no agent %s: define it in %s/%s.yaml", name, agents.CustomAgentsDir(), name)
+		}
+		return fmt.Errorf("no agent %s (defined: %s)", name, strings.Join(names, ", "))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	provider, model := customAgentModel(setup, spec.Model, string(langdetect.DetectLanguage(cwd)))
+	agent := agents.NewCustomAgent(*spec, provider, cwd, model)
+
+	ctx, cancel := agents.WithTimeout(context.Background(), spec.Name, setup.AgentTimeout(spec.Name))
+	defer cancel()
+
+	progress := output.StartStatus(os.Stderr, fmt.Sprintf("Running %s (%s)", spec.Name, model))
+	result, err := agent.Execute(ctx, []llm.ChatMessage{{Role: "user", Content: task}}, func(status string) {
+		progress.Update(status)
+	})
+	progress.Stop()
+	if err != nil {
+		return fmt.Errorf("agent %s failed: %w", spec.Name, err)
+	}
+	fmt.Println(result)
+	return nil
+}
+
+// registerCustomAgents adds the agents defined in ~/.gptcode/agents to the
+// chat coordinator. Definitions that fail to load are reported and skipped.
+func registerCustomAgents(coordinator *agents.Coordinator, setup *config.Setup, cwd string) {
+	specs, err := agents.LoadCustomAgents(agents.CustomAgentsDir())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, output.Warnf("custom agents: %v", err))
+		return
+	}
+	if len(specs) == 0 {
+		return
+	}
+	language := string(langdetect.DetectLanguage(cwd))
+	for _, spec := range specs {
+		provider, model := customAgentModel(setup, spec.Model, language)
+		coordinator.Register(agents.NewCustomAgent(spec, provider, cwd, model))
+	}
+}
+
+// customAgentModel picks a custom agent's backend and model from its hints:
+// an explicit model or alias, the model configured for a builtin agent, or
+// the model selector's pick for an action. Without hints the agent uses
+// the editor's model.
+func customAgentModel(setup *config.Setup, hints agents.ModelHints, language string) (llm.Provider, string) {
+	backendName := setup.Defaults.Backend
+	if hints.Backend != "" {
+		backendName = hints.Backend
+	}
+	backendCfg := setup.Backend[backendName]
+
+	var model string
+	switch {
+	case hints.ID != "":
+		model = hints.ID
+		if alias, ok := backendCfg.Models[hints.ID]; ok {
+			model = alias
+		}
+	case hints.Agent != "":
+		model = backendCfg.GetModelForAgent(hints.Agent)
+	case hints.Action != "":
+		if selector, err := config.NewModelSelector(setup); err == nil {
+			complexity := hints.Complexity
+			if complexity == "" {
+				complexity = "simple"
+			}
+			if backend, selected, err := selector.SelectModel(config.ActionType(hints.Action), language, complexity); err == nil {
+				backendName, model = backend, selected
+				backendCfg = setup.Backend[backendName]
+			}
+		}
+	}
+	if model == "" {
+		model = backendCfg.GetModelForAgent("editor")
+	}
+	return llm.NewForBackend(backendName, backendCfg), model
+}
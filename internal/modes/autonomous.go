@@ -10,15 +10,18 @@ import (
 	"gptcode/internal/events"
 	"gptcode/internal/llm"
 	"gptcode/internal/maestro"
+	"gptcode/internal/observability"
 )
 
 // AutonomousExecutor wraps autonomous execution for use across modes
 type AutonomousExecutor struct {
-	events   *events.Emitter
-	provider llm.Provider
-	cwd      string
-	model    string
-	executor *autonomous.Executor
+	events    *events.Emitter
+	provider  llm.Provider
+	cwd       string
+	model     string
+	executor  *autonomous.Executor
+	conductor *maestro.Conductor
+	observer  *observability.AgentObserver
 }
 
 // NewAutonomousExecutor creates a new autonomous executor
@@ -29,7 +32,7 @@ func NewAutonomousExecutor(provider llm.Provider, cwd string, model string, lang
 // NewAutonomousExecutorWithBackend creates executor with specific backend override
 func NewAutonomousExecutorWithBackend(provider llm.Provider, cwd string, model string, language string, backendName string) *AutonomousExecutor {
 	// Load setup
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		fmt.Printf("[WARN] Failed to load setup: %v, using defaults\n", err)
 		// Create minimal setup
@@ -58,14 +61,29 @@ func NewAutonomousExecutorWithBackend(provider llm.Provider, cwd string, model s
 	analyzer := autonomous.NewTaskAnalyzer(classifier, provider, cwd, model)
 
 	executor := autonomous.NewExecutor(analyzer, conductor, cwd)
-
-	return &AutonomousExecutor{
-		events:   events.NewEmitter(nil),
-		provider: provider,
-		cwd:      cwd,
-		model:    model,
-		executor: executor,
+	a := &AutonomousExecutor{
+		events:    events.NewEmitter(nil),
+		provider:  provider,
+		cwd:       cwd,
+		model:     model,
+		executor:  executor,
+		conductor: conductor,
 	}
+	executor.SetWorktreeConductors(func(dir string) *maestro.Conductor {
+		c := maestro.NewConductor(selector, setup, dir, language)
+		if a.observer != nil {
+			c.Observer = a.observer
+		}
+		return c
+	})
+	return a
+}
+
+// SetObserver reports the agents' activity, in this tree and in the
+// worktrees of parallel movements, to observer
+func (a *AutonomousExecutor) SetObserver(observer *observability.AgentObserver) {
+	a.observer = observer
+	a.conductor.Observer = observer
 }
 
 // Execute runs autonomous execution with Symphony pattern if task is complex
@@ -74,6 +92,12 @@ func (a *AutonomousExecutor) Execute(ctx context.Context, task string) error {
 	return a.executor.Execute(ctx, task)
 }
 
+// ExecutePlan implements a plan already agreed on for task, with the same
+// validation and test guard as Execute
+func (a *AutonomousExecutor) ExecutePlan(ctx context.Context, task string, plan string) error {
+	return a.executor.ExecutePlan(ctx, task, plan)
+}
+
 // ShouldUseAutonomous determines if a task should use autonomous mode
 // This is a lightweight heuristic check before full analysis.
 // The real complexity scoring happens in TaskAnalyzer.estimateComplexity()
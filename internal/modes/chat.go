@@ -15,9 +15,11 @@ import (
 	"gptcode/internal/agents"
 	"gptcode/internal/config"
 	"gptcode/internal/graph"
+	"gptcode/internal/index"
 	"gptcode/internal/llm"
 	"gptcode/internal/output"
 	"gptcode/internal/prompt"
+	"gptcode/internal/tools"
 )
 
 type ChatHistory struct {
@@ -43,6 +45,12 @@ func isOpsQuery(s string) bool {
 		"xcode",
 		"docker",
 		"high usage",
+		"kubernetes",
+		"k8s",
+		"kubectl",
+		"crashloop",
+		"imagepullbackoff",
+		"oomkilled",
 		"dados do sistema",
 		"armazenamento",
 		"disco",
@@ -64,7 +72,7 @@ func Chat(input string, args []string) {
 		fmt.Fprintf(os.Stderr, "[CHAT] Input: %s\n", input[:min(100, len(input))])
 	}
 
-	setup, _ := config.LoadSetup()
+	setup, _ := config.LoadEffectiveSetup()
 
 	var history ChatHistory
 	if input != "" {
@@ -94,12 +102,7 @@ func Chat(input string, args []string) {
 
 	cwd, _ := os.Getwd()
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	researchModel := backendCfg.GetModelForAgent("research")
 	orchestrator := llm.NewOrchestrator(backendCfg.BaseURL, backendName, provider, researchModel)
@@ -158,10 +161,9 @@ func Chat(input string, args []string) {
 		return
 	}
 
-	var stopSpinner chan bool
+	var progress *output.Progress
 	if os.Getenv("GPTCODE_DEBUG") != "1" {
-		stopSpinner = make(chan bool, 1)
-		go showSpinner(stopSpinner)
+		progress = output.StartSpinner(os.Stderr, "Thinking...")
 	}
 
 	routerModel := backendCfg.GetModelForAgent("router")
@@ -184,47 +186,24 @@ func Chat(input string, args []string) {
 			}
 			g.PageRank(0.85, 20)
 
-			// Optimize context
-			optimizer := graph.NewOptimizer(g)
+			// Select context
 			maxFiles := setup.Defaults.GraphMaxFiles
 			if maxFiles == 0 {
 				maxFiles = 5 // default
 			}
-			relevantFiles := optimizer.OptimizeContext(lastUserMessage, maxFiles)
+			relevantFiles := selectContext(g, cwd, setup, lastUserMessage, maxFiles)
 
 			if len(relevantFiles) > 0 {
-				if os.Getenv("GPTCODE_DEBUG") == "1" {
-					fmt.Fprintf(os.Stderr, "[GRAPH] Selected %d files:\n", len(relevantFiles))
-					for i, f := range relevantFiles {
-						fmt.Fprintf(os.Stderr, "[GRAPH]   %d. %s (score: %.3f)\n", i+1, f, g.Nodes[g.Paths[f]].Score)
-					}
-				}
-
-				// Read file contents
+				// Read file contents, each within its share of the context window
 				var contextBuilder strings.Builder
 				contextBuilder.WriteString("\n\n[Context from Dependency Graph]\n")
+				snippetTokens := min(llm.SnippetTokens(backendName, editorModel, len(relevantFiles)), llm.SnippetTokens(backendName, queryModel, len(relevantFiles)))
 
 				for _, file := range relevantFiles {
 					content, err := os.ReadFile(filepath.Join(cwd, file))
 					if err == nil {
-						text := string(content)
-
-						// Smart truncation: keep ~3000 chars (rough ~750 tokens)
-						// For large files, try to keep relevant parts (imports + key functions)
-						if len(text) > 3000 {
-							lines := strings.Split(text, "\n")
-
-							// Keep first 30 lines (imports, package decl)
-							head := strings.Join(lines[:min(30, len(lines))], "\n")
-
-							// Keep last 20 lines (often important functions)
-							tailStart := max(30, len(lines)-20)
-							tail := strings.Join(lines[tailStart:], "\n")
-
-							text = fmt.Sprintf("%s\n\n... (%d lines omitted) ...\n\n%s", head, len(lines)-50, tail)
-						}
-
-						contextBuilder.WriteString(fmt.Sprintf("File: %s\n```\n%s\n```\n", file, text))
+						text := llm.TruncateToTokens(string(content), snippetTokens, llm.TokenizerFor(editorModel))
+						contextBuilder.WriteString(tools.WrapUntrusted(file, text, tools.ScanInjection(text)) + "\n")
 					}
 				}
 
@@ -240,40 +219,65 @@ func Chat(input string, args []string) {
 	}
 
 	coordinator := agents.NewCoordinator(provider, orchestrator, cwd, routerModel, editorModel, queryModel, researchModel)
+	coordinator.SetTimeouts(setup.AgentTimeout)
+	registerCustomAgents(coordinator, setup, cwd)
+
+	isTerminal := isInteractiveTerminal()
+
+	// Stream the answer as it is generated; the spinner gives way to the
+	// first token and status updates stop so they do not interleave.
+	streamed := false
+	if isTerminal && setup.Output.StreamEnabled() {
+		coordinator.SetStream(func(chunk string) {
+			if !streamed {
+				streamed = true
+				if progress != nil {
+					progress.Stop()
+				}
+				fmt.Println(output.Separator())
+			}
+			fmt.Print(chunk)
+		})
+	}
 
 	statusCallback := func(status string) {
 		if os.Getenv("GPTCODE_DEBUG") == "1" {
 			fmt.Fprintf(os.Stderr, "[STATUS] %s\n", status)
-		} else {
-			fmt.Fprintf(os.Stderr, "\r[STATUS] %s", status)
+		} else if !streamed {
+			progress.Update(status)
 		}
 	}
 
 	result, err := coordinator.Execute(context.Background(), history.Messages, statusCallback)
 
-	if os.Getenv("GPTCODE_DEBUG") != "1" {
-		stopSpinner <- true
-		time.Sleep(100 * time.Millisecond)
-		fmt.Fprint(os.Stderr, "\r\033[K")
+	if progress != nil {
+		progress.Stop()
 	}
 
 	if err != nil {
+		if streamed {
+			fmt.Println()
+		}
 		fmt.Println("Erro:", err)
 		return
 	}
 
-	isTerminal := isInteractiveTerminal()
-
 	if isTerminal {
 		parsed := output.ParseMarkdown(result)
 
-		rendered, err := output.RenderMarkdown(parsed.RenderedText)
-		if err != nil {
-			rendered = result
-		}
+		if streamed {
+			if !strings.HasSuffix(result, "\n") {
+				fmt.Println()
+			}
+		} else {
+			rendered, err := output.RenderMarkdown(parsed.RenderedText)
+			if err != nil {
+				rendered = result
+			}
 
-		fmt.Println(output.Separator())
-		fmt.Print(rendered)
+			fmt.Println(output.Separator())
+			fmt.Print(rendered)
+		}
 		fmt.Println(output.Separator())
 
 		if len(parsed.CodeBlocks) > 0 {
@@ -295,22 +299,6 @@ func isInteractiveTerminal() bool {
 	return term.IsTerminal(int(os.Stdout.Fd()))
 }
 
-func showSpinner(done chan bool) {
-	spinner := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
-	i := 0
-	for {
-		select {
-		case <-done:
-			return
-		default:
-			fmt.Fprintf(os.Stderr, "\r%s Thinking...", spinner[i%len(spinner)])
-			os.Stderr.Sync()
-			i++
-			time.Sleep(80 * time.Millisecond)
-		}
-	}
-}
-
 func RunChat(builder *prompt.Builder, provider llm.Provider, model string, cliArgs []string) error {
 	input, _ := io.ReadAll(os.Stdin)
 	Chat(string(input), cliArgs)
@@ -320,13 +308,19 @@ func RunChat(builder *prompt.Builder, provider llm.Provider, model string, cliAr
 // ChatWithResponse executes chat and returns the response instead of printing it
 // This is used by the REPL to capture responses for conversation history
 func ChatWithResponse(input string, args []string) (string, error) {
+	return ChatWithStream(input, args, nil)
+}
+
+// ChatWithStream is ChatWithResponse that also passes the response to
+// onChunk as it is generated. A nil onChunk disables streaming.
+func ChatWithStream(input string, args []string, onChunk agents.StreamCallback) (string, error) {
 	os.Stdout.Sync()
 
 	if os.Getenv("GPTCODE_DEBUG") == "1" {
 		fmt.Fprintf(os.Stderr, "[CHAT] ChatWithResponse: input len=%d\n", len(input))
 	}
 
-	setup, _ := config.LoadSetup()
+	setup, _ := config.LoadEffectiveSetup()
 
 	var history ChatHistory
 	if input != "" {
@@ -348,12 +342,7 @@ func ChatWithResponse(input string, args []string) (string, error) {
 	backendCfg := setup.Backend[backendName]
 	cwd, _ := os.Getwd()
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	researchModel := backendCfg.GetModelForAgent("research")
 	orchestrator := llm.NewOrchestrator(backendCfg.BaseURL, backendName, provider, researchModel)
@@ -387,29 +376,22 @@ func ChatWithResponse(input string, args []string) (string, error) {
 		builder := graph.NewBuilder(cwd)
 		if g, err := builder.Build(); err == nil {
 			g.PageRank(0.85, 20)
-			optimizer := graph.NewOptimizer(g)
 			maxFiles := setup.Defaults.GraphMaxFiles
 			if maxFiles == 0 {
 				maxFiles = 5
 			}
-			relevantFiles := optimizer.OptimizeContext(lastUserMessage, maxFiles)
+			relevantFiles := selectContext(g, cwd, setup, lastUserMessage, maxFiles)
 
 			if len(relevantFiles) > 0 {
 				var contextBuilder strings.Builder
 				contextBuilder.WriteString("\n\n[Context from Dependency Graph]\n")
+				snippetTokens := min(llm.SnippetTokens(backendName, editorModel, len(relevantFiles)), llm.SnippetTokens(backendName, queryModel, len(relevantFiles)))
 
 				for _, file := range relevantFiles {
 					content, err := os.ReadFile(filepath.Join(cwd, file))
 					if err == nil {
-						text := string(content)
-						if len(text) > 3000 {
-							lines := strings.Split(text, "\n")
-							head := strings.Join(lines[:min(30, len(lines))], "\n")
-							tailStart := max(30, len(lines)-20)
-							tail := strings.Join(lines[tailStart:], "\n")
-							text = fmt.Sprintf("%s\n\n... (%d lines omitted) ...\n\n%s", head, len(lines)-50, tail)
-						}
-						contextBuilder.WriteString(fmt.Sprintf("File: %s\n```\n%s\n```\n", file, text))
+						text := llm.TruncateToTokens(string(content), snippetTokens, llm.TokenizerFor(editorModel))
+						contextBuilder.WriteString(tools.WrapUntrusted(file, text, tools.ScanInjection(text)) + "\n")
 					}
 				}
 				history.Messages[len(history.Messages)-1].Content += contextBuilder.String()
@@ -418,6 +400,11 @@ func ChatWithResponse(input string, args []string) (string, error) {
 	}
 
 	coordinator := agents.NewCoordinator(provider, orchestrator, cwd, routerModel, editorModel, queryModel, researchModel)
+	coordinator.SetTimeouts(setup.AgentTimeout)
+	registerCustomAgents(coordinator, setup, cwd)
+	if onChunk != nil {
+		coordinator.SetStream(onChunk)
+	}
 
 	statusCallback := func(status string) {
 		if os.Getenv("GPTCODE_DEBUG") == "1" {
@@ -443,6 +430,27 @@ func truncateHistory(messages []llm.ChatMessage, maxMessages int) []llm.ChatMess
 	return messages[len(messages)-maxMessages:]
 }
 
+// selectContext ranks the project's files for query with the weights from
+// setup and returns the best maxFiles. Each selection is logged so it can be
+// explained afterwards; GPTCODE_DEBUG=1 prints the ranking.
+func selectContext(g *graph.Graph, cwd string, setup *config.Setup, query string, maxFiles int) []string {
+	ranker := graph.NewRanker(g, cwd)
+	if weights, err := ranker.Weights.With(setup.Context.Weights); err == nil {
+		ranker.Weights = weights
+	} else {
+		fmt.Fprintf(os.Stderr, "Warning: %v; using the default context weights\n", err)
+	}
+	if ix, embedder, err := index.Open(cwd, setup); err == nil {
+		ranker.Similarity = ix.Similarity(embedder)
+	}
+	sel := ranker.Select(query, maxFiles)
+	_ = graph.LogSelection(sel)
+	if os.Getenv("GPTCODE_DEBUG") == "1" {
+		fmt.Fprintf(os.Stderr, "[GRAPH] Selected %d files:\n%s", len(sel.Paths()), sel.Explain(maxFiles+5))
+	}
+	return sel.Paths()
+}
+
 func countEdges(g *graph.Graph) int {
 	count := 0
 	for _, edges := range g.OutEdges {
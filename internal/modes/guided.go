@@ -2,6 +2,7 @@ package modes
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -86,6 +87,79 @@ func (g *GuidedMode) ExecuteAndReturnPlan(ctx context.Context, userMessage strin
 	return fullPlan, nil
 }
 
+// ErrPlanRejected is returned by DiscussPlan when the user abandons the
+// discussion instead of agreeing on a plan.
+var ErrPlanRejected = errors.New("plan rejected")
+
+// DiscussPlan drafts a plan for task and revises it with the user's comments
+// until they agree to it. comment is shown each version of the plan and
+// returns the user's comments, "" to accept it, or ErrPlanRejected. Every
+// revision sees the whole discussion so far. The agreed plan is saved like
+// any other plan and returned.
+func (g *GuidedMode) DiscussPlan(ctx context.Context, task string, comment func(plan string) (string, error)) (string, error) {
+	_ = g.events.Status("Analyzing task...")
+	draft, err := g.createDraftPlan(ctx, task)
+	if err != nil {
+		return "", fmt.Errorf("failed to create draft: %w", err)
+	}
+	_ = g.events.Status("Creating detailed plan...")
+	plan, err := g.createDetailedPlan(ctx, task, draft)
+	if err != nil {
+		return "", fmt.Errorf("failed to create plan: %w", err)
+	}
+
+	var discussion []llm.ChatMessage
+	for round := 1; ; round++ {
+		comments, err := comment(plan)
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(comments) == "" {
+			break
+		}
+		discussion = append(discussion,
+			llm.ChatMessage{Role: "assistant", Content: plan},
+			llm.ChatMessage{Role: "user", Content: comments},
+		)
+		_ = g.events.Status(fmt.Sprintf("Revising plan (round %d)...", round))
+		if plan, err = g.revisePlan(ctx, task, discussion); err != nil {
+			return "", fmt.Errorf("failed to revise plan: %w", err)
+		}
+	}
+
+	path, err := g.savePlan(plan)
+	if err != nil {
+		return "", fmt.Errorf("failed to save plan: %w", err)
+	}
+	_ = g.events.OpenPlan(path)
+	_ = g.events.Message("Agreed plan saved to " + path)
+	return plan, nil
+}
+
+func (g *GuidedMode) revisePlan(ctx context.Context, task string, discussion []llm.ChatMessage) (string, error) {
+	resp, err := g.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: fmt.Sprintf(`You create MINIMAL, DIRECT plans and revise them with the user before any code is written.
+
+Task: %s
+
+Rewrite the plan to address the user's latest comments while keeping what they already agreed to. Reply with the complete revised plan only, in the same format:
+# Plan
+
+## What to do
+
+## Files to modify
+
+## Changes`, task),
+		Messages: discussion,
+		Model:    g.model,
+		Intent:   "plan",
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Text, nil
+}
+
 func (g *GuidedMode) createDraftPlan(ctx context.Context, task string) (string, error) {
 	prompt := fmt.Sprintf(`You are creating a DRAFT implementation plan.
 
@@ -314,7 +388,7 @@ func IsComplexTask(message string) bool {
 	}
 	if label == "complex" {
 		threshold := 0.55
-		if setup, err2 := config.LoadSetup(); err2 == nil {
+		if setup, err2 := config.LoadEffectiveSetup(); err2 == nil {
 			if setup.Defaults.MLComplexThreshold > 0 {
 				threshold = setup.Defaults.MLComplexThreshold
 			}
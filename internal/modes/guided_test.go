@@ -0,0 +1,65 @@
+package modes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+// planProvider answers every request with a numbered plan and records the
+// requests it saw.
+type planProvider struct {
+	requests []llm.ChatRequest
+}
+
+func (p *planProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.requests = append(p.requests, req)
+	return &llm.ChatResponse{Text: fmt.Sprintf("# Plan %d", len(p.requests))}, nil
+}
+
+func TestDiscussPlanRevisesUntilAccepted(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	provider := &planProvider{}
+	g := NewGuidedMode(provider, t.TempDir(), "model")
+
+	comments := []string{"use the existing logger", "also update the README", ""}
+	var shown []string
+	plan, err := g.DiscussPlan(context.Background(), "add logging", func(plan string) (string, error) {
+		shown = append(shown, plan)
+		c := comments[0]
+		comments = comments[1:]
+		return c, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Draft and detailed plan, then one revision per comment
+	if plan != "# Plan 4" || len(shown) != 3 {
+		t.Fatalf("plan = %q after %d rounds", plan, len(shown))
+	}
+	last := provider.requests[3]
+	if len(last.Messages) != 4 || last.Messages[3].Content != "also update the README" {
+		t.Errorf("revision should see the whole discussion, got %+v", last.Messages)
+	}
+	if data, err := os.ReadFile(filepath.Join(home, ".gptcode", "current_plan.txt")); err != nil || string(data) != plan {
+		t.Errorf("agreed plan not saved: %q %v", data, err)
+	}
+}
+
+func TestDiscussPlanRejected(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	g := NewGuidedMode(&planProvider{}, t.TempDir(), "model")
+	_, err := g.DiscussPlan(context.Background(), "add logging", func(string) (string, error) {
+		return "", ErrPlanRejected
+	})
+	if !errors.Is(err, ErrPlanRejected) {
+		t.Fatalf("expected ErrPlanRejected, got %v", err)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"gptcode/internal/agents"
 	"gptcode/internal/config"
+	"gptcode/internal/github"
 	"gptcode/internal/llm"
 	"gptcode/internal/output"
 
@@ -19,19 +23,14 @@ func RunImplement(planPath string) error {
 		return fmt.Errorf("could not read plan file: %w", err)
 	}
 
-	setup, _ := config.LoadSetup()
+	setup, _ := config.LoadEffectiveSetup()
 	backendName := setup.Defaults.Backend
 	backendCfg := setup.Backend[backendName]
 	cwd, _ := os.Getwd()
 
 	fmt.Fprintf(os.Stderr, "⠋ Implementing plan from: %s\n\n", planPath)
 
-	var customExec llm.Provider
-	if backendCfg.Type == "ollama" {
-		customExec = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		customExec = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	customExec := llm.NewForBackend(backendName, backendCfg)
 
 	implementPrompt := fmt.Sprintf(`Implement this approved technical plan:
 
@@ -83,6 +82,50 @@ Focus on making the actual code changes described in the plan.`, string(planCont
 	return nil
 }
 
+// CommitPhase commits everything in dir's working tree, the plan file
+// included, as the work of phase i. A phase that changed nothing makes no
+// commit.
+func CommitPhase(dir string, plan *Plan, i int) error {
+	client := github.NewClient("")
+	client.SetWorkDir(dir)
+	if err := client.CommitChanges(github.CommitOptions{Message: plan.CommitMessage(i), AllFiles: true}); err != nil {
+		return fmt.Errorf("could not commit phase %d (%s): %w", i+1, plan.Phases[i].Name, err)
+	}
+	return nil
+}
+
+// UncommittedChanges lists the files with changes in dir's working tree,
+// untracked ones included, leaving out except.
+func UncommittedChanges(dir, except string) ([]string, error) {
+	args := []string{"status", "--porcelain", "-z", "--untracked-files=all", "--", ":/"}
+	if except != "" {
+		abs, err := filepath.Abs(except)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, ":(exclude)"+abs)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status: %w", err)
+	}
+	var files []string
+	entries := strings.Split(string(out), "\x00")
+	for i := 0; i < len(entries); i++ {
+		if len(entries[i]) < 4 {
+			continue
+		}
+		files = append(files, entries[i][3:])
+		// A rename is followed by the path it was renamed from
+		if entries[i][0] == 'R' || entries[i][0] == 'C' {
+			i++
+		}
+	}
+	return files, nil
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -10,6 +10,7 @@ import (
 	"gptcode/internal/events"
 	"gptcode/internal/intelligence"
 	"gptcode/internal/llm"
+	"gptcode/internal/observability"
 )
 
 type OrchestratedMode struct {
@@ -23,7 +24,7 @@ type OrchestratedMode struct {
 }
 
 func NewOrchestratedMode(provider llm.Provider, baseProvider llm.Provider, cwd string, model string, editorModel string) *OrchestratedMode {
-	setup, _ := config.LoadSetup()
+	setup, _ := config.LoadEffectiveSetup()
 	return &OrchestratedMode{
 		events:       events.NewEmitter(os.Stderr),
 		provider:     provider,
@@ -53,6 +54,12 @@ func (o *OrchestratedMode) Execute(ctx context.Context, userMessage string) erro
 	}
 
 	plannerAgent := agents.NewPlanner(o.provider, o.model)
+	if o.setup != nil {
+		backend := o.setup.Defaults.Backend
+		plannerAgent.SetBudget(agents.NewBudget(observability.ActiveCostMeter(),
+			agents.ModelPrice{Agent: "planner", Backend: backend, Model: o.model},
+			agents.ModelPrice{Agent: "editor", Backend: backend, Model: o.editorModel}))
+	}
 	plan, err := plannerAgent.CreatePlan(ctx, userMessage, analysis, statusCallback)
 	if err != nil {
 		return fmt.Errorf("planning failed: %w", err)
@@ -37,7 +37,7 @@ func RunPlan(args []string) error {
 		return fmt.Errorf("no task provided")
 	}
 
-	setup, _ := config.LoadSetup()
+	setup, _ := config.LoadEffectiveSetup()
 	backendName := setup.Defaults.Backend
 	backendCfg := setup.Backend[backendName]
 	cwd, _ := os.Getwd()
@@ -48,14 +48,7 @@ func RunPlan(args []string) error {
 	if len(urls) > 0 {
 		fmt.Fprintf(os.Stderr, "⠋ Fetching external documentation...\n")
 
-		var orchestrator *llm.OrchestratorProvider
-		if backendCfg.Type == "ollama" {
-			customExec := llm.NewOllama(backendCfg.BaseURL)
-			orchestrator = llm.NewOrchestrator(backendCfg.BaseURL, backendName, customExec, backendCfg.DefaultModel)
-		} else {
-			customExec := llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-			orchestrator = llm.NewOrchestrator(backendCfg.BaseURL, backendName, customExec, backendCfg.DefaultModel)
-		}
+		orchestrator := llm.NewOrchestrator(backendCfg.BaseURL, backendName, llm.NewForBackend(backendName, backendCfg), backendCfg.DefaultModel)
 
 		researchAgent := agents.NewResearch(orchestrator)
 		for _, url := range urls {
@@ -69,12 +62,7 @@ func RunPlan(args []string) error {
 
 	fmt.Fprintf(os.Stderr, "⠋ Analyzing codebase...\n")
 
-	var customExec llm.Provider
-	if backendCfg.Type == "ollama" {
-		customExec = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		customExec = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	customExec := llm.NewForBackend(backendName, backendCfg)
 
 	queryModel := backendCfg.GetModelForAgent("query")
 	queryAgent := agents.NewQuery(customExec, cwd, queryModel)
@@ -142,9 +130,9 @@ Create a structured plan with:
 ### Success Criteria
 
 #### Automated Verification:
-- [ ] Tests pass: make test
-- [ ] Linting passes: make lint
-- [ ] Build succeeds: make build
+- [ ] Tests pass: `+"`make test`"+`
+- [ ] Linting passes: `+"`make lint`"+`
+- [ ] Build succeeds: `+"`make build`"+`
 
 #### Manual Verification:
 - [ ] Feature works as expected when tested
@@ -190,11 +178,18 @@ Create a structured plan with:
 	filename := fmt.Sprintf("%s_%s.md", timestamp, sanitizedTask)
 	planPath := filepath.Join(plansDir, filename)
 
-	err = os.WriteFile(planPath, []byte(planResult), 0644)
+	plan := &Plan{
+		Version: PlanVersion,
+		Task:    task,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Phases:  derivePhases(planResult),
+		Body:    planResult,
+	}
+	err = plan.Save(planPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\nWarning: Could not save plan to %s: %v\n", planPath, err)
 	} else {
-		fmt.Fprintf(os.Stderr, "\n✓ Plan saved to: %s\n", planPath)
+		fmt.Fprintf(os.Stderr, "\n✓ Plan saved to: %s (%d phases)\n", planPath, len(plan.Phases))
 		fmt.Fprintf(os.Stderr, "\nTo implement this plan, run:\n  chu implement %s\n", planPath)
 	}
 
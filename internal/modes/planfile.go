@@ -0,0 +1,377 @@
+package modes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PlanVersion is the version of the plan file format this gptcode writes.
+// Plans without front matter are version 0 and are upgraded when saved.
+const PlanVersion = 1
+
+// PhaseStatus is how far implement got with a phase
+type PhaseStatus string
+
+const (
+	PhasePending    PhaseStatus = "pending"
+	PhaseInProgress PhaseStatus = "in_progress"
+	PhaseDone       PhaseStatus = "done"
+	PhaseSkipped    PhaseStatus = "skipped"
+	PhaseFailed     PhaseStatus = "failed"
+)
+
+// PhaseStatuses are the valid phase statuses, in the order a phase goes
+// through them
+var PhaseStatuses = []PhaseStatus{PhasePending, PhaseInProgress, PhaseDone, PhaseFailed, PhaseSkipped}
+
+// Finished reports whether implement moves past a phase with this status
+func (s PhaseStatus) Finished() bool {
+	return s == PhaseDone || s == PhaseSkipped
+}
+
+// Valid reports whether s is one of PhaseStatuses
+func (s PhaseStatus) Valid() bool {
+	return slices.Contains(PhaseStatuses, s)
+}
+
+// PhaseStatusNames lists the valid phase statuses
+func PhaseStatusNames() string {
+	names := make([]string, len(PhaseStatuses))
+	for i, s := range PhaseStatuses {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
+
+// PlanPhase is one phase of a plan, as listed in its front matter
+type PlanPhase struct {
+	Name string `yaml:"name"`
+	// Files are the files the phase is expected to change
+	Files []string `yaml:"files,omitempty"`
+	// Verify are shell commands that must succeed once the phase is done
+	Verify []string    `yaml:"verify,omitempty"`
+	Status PhaseStatus `yaml:"status"`
+}
+
+// Plan is a plan file: YAML front matter listing the phases, followed by
+// the markdown plan itself.
+//
+//	---
+//	version: 1
+//	task: Add user authentication
+//	phases:
+//	  - name: Session store
+//	    files: [internal/auth/session.go]
+//	    verify: [go test ./internal/auth/...]
+//	    status: done
+//	---
+//	# Add user authentication Implementation Plan
+//	...
+type Plan struct {
+	Version int         `yaml:"version"`
+	Task    string      `yaml:"task,omitempty"`
+	Created time.Time   `yaml:"created,omitempty"`
+	Phases  []PlanPhase `yaml:"phases"`
+	// Body is the markdown after the front matter
+	Body string `yaml:"-"`
+}
+
+// ParsePlanFile parses a plan file. A plan without front matter, as older
+// versions wrote, gets its phases from its "## Phase N: Name" sections.
+func ParsePlanFile(data string) (*Plan, error) {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	front, body, ok := splitFrontMatter(data)
+	if !ok {
+		return &Plan{Version: PlanVersion, Phases: derivePhases(data), Body: data}, nil
+	}
+
+	p := &Plan{}
+	dec := yaml.NewDecoder(strings.NewReader(front))
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid plan front matter: %w", err)
+	}
+	p.Body = body
+	if p.Version == 0 {
+		return nil, fmt.Errorf("invalid plan front matter: version is missing")
+	}
+	if p.Version > PlanVersion {
+		return nil, fmt.Errorf("plan file version %d is newer than this gptcode supports (%d); upgrade gptcode", p.Version, PlanVersion)
+	}
+	for i := range p.Phases {
+		phase := &p.Phases[i]
+		if strings.TrimSpace(phase.Name) == "" {
+			return nil, fmt.Errorf("invalid plan front matter: phase %d has no name", i+1)
+		}
+		if phase.Status == "" {
+			phase.Status = PhasePending
+		}
+		if !phase.Status.Valid() {
+			return nil, fmt.Errorf("invalid plan front matter: phase %d (%s) has status %q, expected one of %s",
+				i+1, phase.Name, phase.Status, PhaseStatusNames())
+		}
+	}
+	return p, nil
+}
+
+// splitFrontMatter splits data into the YAML between its leading "---"
+// lines and the rest
+func splitFrontMatter(data string) (front, body string, ok bool) {
+	if !strings.HasPrefix(data, "---\n") {
+		return "", data, false
+	}
+	rest := data[len("---\n"):]
+	if strings.HasPrefix(rest, "---\n") {
+		return "", rest[len("---\n"):], true
+	}
+	end := strings.Index(rest, "\n---\n")
+	if end < 0 {
+		if !strings.HasSuffix(rest, "\n---") {
+			return "", data, false
+		}
+		return rest[:len(rest)-len("\n---")], "", true
+	}
+	return rest[:end+1], rest[end+len("\n---\n"):], true
+}
+
+var (
+	phaseHeading = regexp.MustCompile(`(?i)^##\s+phase\s+(\d+)\s*[:.)-]?\s*(.*)$`)
+	fileLine     = regexp.MustCompile(`^\*\*Files?\*\*:?\s*(.+)$`)
+	checkItem    = regexp.MustCompile(`^\s*[-*]\s+(?:\[[ xX]\]\s+)?(.+)$`)
+	inlineCode   = regexp.MustCompile("`([^`]+)`")
+)
+
+// derivePhases reads the phases of a markdown plan from its "## Phase N"
+// sections: the files from their **File**: lines, and the verification
+// commands from their "Automated Verification" checklists.
+func derivePhases(body string) []PlanPhase {
+	var phases []PlanPhase
+	automated := false
+	for _, line := range strings.Split(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if m := phaseHeading.FindStringSubmatch(trimmed); m != nil {
+			name := strings.TrimSpace(m[2])
+			if name == "" {
+				name = "Phase " + m[1]
+			}
+			phases = append(phases, PlanPhase{Name: name, Status: PhasePending})
+			automated = false
+			continue
+		}
+		if strings.HasPrefix(trimmed, "## ") {
+			// A section after the phases, e.g. "## Testing Strategy"
+			if len(phases) > 0 {
+				break
+			}
+			continue
+		}
+		if len(phases) == 0 {
+			continue
+		}
+		phase := &phases[len(phases)-1]
+		if strings.HasPrefix(trimmed, "#") {
+			automated = strings.Contains(strings.ToLower(trimmed), "automated verification")
+			continue
+		}
+		if m := fileLine.FindStringSubmatch(trimmed); m != nil {
+			for _, f := range strings.Split(m[1], ",") {
+				if f = strings.Trim(strings.TrimSpace(f), "`"); f != "" && !slices.Contains(phase.Files, f) {
+					phase.Files = append(phase.Files, f)
+				}
+			}
+			continue
+		}
+		if automated {
+			if m := checkItem.FindStringSubmatch(trimmed); m != nil {
+				if command := verifyCommand(m[1]); command != "" {
+					phase.Verify = append(phase.Verify, command)
+				}
+			}
+		}
+	}
+	return phases
+}
+
+// verifyCommand is the command of a verification checklist item, as in
+// "Tests pass: `make test`" or "Tests pass: make test"
+func verifyCommand(item string) string {
+	if m := inlineCode.FindStringSubmatch(item); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	if i := strings.LastIndex(item, ": "); i >= 0 {
+		return strings.TrimSpace(item[i+2:])
+	}
+	return ""
+}
+
+// LoadPlan reads and parses the plan file at path.
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read plan file: %w", err)
+	}
+	p, err := ParsePlanFile(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Marshal returns the plan file: the front matter and the body.
+func (p *Plan) Marshal() ([]byte, error) {
+	var front bytes.Buffer
+	enc := yaml.NewEncoder(&front)
+	enc.SetIndent(2)
+	if err := enc.Encode(p); err != nil {
+		return nil, err
+	}
+	_ = enc.Close()
+
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(front.Bytes())
+	b.WriteString("---\n")
+	b.WriteString(p.Body)
+	return b.Bytes(), nil
+}
+
+// Save writes the plan to path, through a temporary file so an interrupted
+// write does not lose it.
+func (p *Plan) Save(path string) error {
+	data, err := p.Marshal()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".plan-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Next returns the index of the first phase not done or skipped, or -1 when
+// the plan is complete.
+func (p *Plan) Next() int {
+	for i, phase := range p.Phases {
+		if !phase.Status.Finished() {
+			return i
+		}
+	}
+	return -1
+}
+
+// Find returns the index of the phase ref names, by number from 1 or by
+// name.
+func (p *Plan) Find(ref string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(p.Phases) {
+			return 0, fmt.Errorf("no phase %d: the plan has %d phases", n, len(p.Phases))
+		}
+		return n - 1, nil
+	}
+	for i, phase := range p.Phases {
+		if strings.EqualFold(phase.Name, ref) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no phase named %q", ref)
+}
+
+// PhaseTask returns what implement asks for phase i: the phase's section
+// of the plan, with its files and verification commands.
+func (p *Plan) PhaseTask(i int) string {
+	phase := p.Phases[i]
+	section := p.section(i)
+	if section == "" {
+		section = "## " + phase.Name + "\n"
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(section, "\n"))
+	b.WriteString("\n")
+	if len(phase.Files) > 0 {
+		fmt.Fprintf(&b, "\nFiles to change: %s\n", strings.Join(phase.Files, ", "))
+	}
+	if len(phase.Verify) > 0 {
+		fmt.Fprintf(&b, "\nThe phase is verified with: %s\n", strings.Join(phase.Verify, "; "))
+	}
+	return b.String()
+}
+
+// CommitMessage returns the message of the commit implement makes once
+// phase i is done: the phase as the subject, with the plan's task and the
+// verify commands that passed in the body.
+func (p *Plan) CommitMessage(i int) string {
+	phase := p.Phases[i]
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\nPhase %d of %d", phase.Name, i+1, len(p.Phases))
+	if p.Task != "" {
+		fmt.Fprintf(&b, " of %s", p.Task)
+	}
+	b.WriteString("\n")
+	if len(phase.Verify) > 0 {
+		b.WriteString("\nVerified with:\n")
+		for _, command := range phase.Verify {
+			fmt.Fprintf(&b, "- %s\n", command)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// section returns the "## " section of the body for phase i: the one
+// named after it, or else the (i+1)th "## Phase" section
+func (p *Plan) section(i int) string {
+	type section struct {
+		heading string
+		text    string
+	}
+	var sections []section
+	for _, line := range strings.Split(p.Body, "\n") {
+		if strings.HasPrefix(line, "## ") {
+			sections = append(sections, section{heading: strings.TrimSpace(line)})
+		}
+		if len(sections) > 0 {
+			sections[len(sections)-1].text += line + "\n"
+		}
+	}
+
+	name := p.Phases[i].Name
+	n := 0
+	var numbered string
+	for _, s := range sections {
+		m := phaseHeading.FindStringSubmatch(s.heading)
+		title := strings.TrimPrefix(s.heading, "## ")
+		if m != nil {
+			title = m[2]
+		}
+		if strings.EqualFold(strings.TrimSpace(title), name) {
+			return s.text
+		}
+		if m != nil {
+			if n == i {
+				numbered = s.text
+			}
+			n++
+		}
+	}
+	return numbered
+}
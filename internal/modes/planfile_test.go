@@ -0,0 +1,207 @@
+package modes
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const markdownPlan = `# Auth Implementation Plan
+
+## Overview
+Add login.
+
+## Phase 1: Session store
+
+### Changes Required
+
+**File**: ` + "`internal/auth/session.go`" + `
+**Changes**: Add the store
+
+### Success Criteria
+
+#### Automated Verification:
+- [ ] Tests pass: ` + "`go test ./internal/auth/...`" + `
+- [ ] Build succeeds: make build
+
+#### Manual Verification:
+- [ ] Sessions expire: after an hour
+
+---
+
+## Phase 2: Login handler
+
+**Files**: internal/auth/login.go, internal/auth/routes.go
+
+## Testing Strategy
+- [ ] Not a phase: ignored
+`
+
+func TestParsePlanFileWithoutFrontMatter(t *testing.T) {
+	p, err := ParsePlanFile(markdownPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PlanPhase{
+		{
+			Name:   "Session store",
+			Files:  []string{"internal/auth/session.go"},
+			Verify: []string{"go test ./internal/auth/...", "make build"},
+			Status: PhasePending,
+		},
+		{
+			Name:   "Login handler",
+			Files:  []string{"internal/auth/login.go", "internal/auth/routes.go"},
+			Status: PhasePending,
+		},
+	}
+	if !reflect.DeepEqual(p.Phases, want) {
+		t.Errorf("unexpected phases:\n%+v", p.Phases)
+	}
+	if p.Version != PlanVersion || p.Body != markdownPlan {
+		t.Errorf("unexpected version %d or body", p.Version)
+	}
+
+	task := p.PhaseTask(1)
+	if !strings.HasPrefix(task, "## Phase 2: Login handler\n") || strings.Contains(task, "Testing Strategy") ||
+		!strings.Contains(task, "Files to change: internal/auth/login.go, internal/auth/routes.go") {
+		t.Errorf("unexpected phase task:\n%s", task)
+	}
+}
+
+func TestPlanRoundTrip(t *testing.T) {
+	p, err := ParsePlanFile(markdownPlan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Task = "Add login"
+	p.Phases[0].Status = PhaseDone
+	path := filepath.Join(t.TempDir(), "plan.md")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "---\nversion: 1\ntask: Add login\n") || !strings.HasSuffix(string(data), "---\n"+markdownPlan) {
+		t.Errorf("unexpected plan file:\n%s", data)
+	}
+
+	loaded, err := LoadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, p) {
+		t.Errorf("the plan changed in the round trip:\n%+v\n%+v", loaded, p)
+	}
+	if loaded.Next() != 1 {
+		t.Errorf("expected phase 2 next, got %d", loaded.Next()+1)
+	}
+	if i, err := loaded.Find("login HANDLER"); err != nil || i != 1 {
+		t.Errorf("Find by name: %d %v", i, err)
+	}
+	if _, err := loaded.Find("3"); err == nil {
+		t.Error("Find should reject a phase past the end")
+	}
+
+	// A renamed phase falls back to its numbered section
+	loaded.Phases[0].Name = "Sessions"
+	if task := loaded.PhaseTask(0); !strings.HasPrefix(task, "## Phase 1: Session store\n") {
+		t.Errorf("unexpected phase task:\n%s", task)
+	}
+}
+
+func TestParsePlanFileErrors(t *testing.T) {
+	for front, want := range map[string]string{
+		"phases: []\n": "version is missing",
+		"version: 9\n": "newer than this gptcode supports",
+		"version: 1\nphases:\n  - status: done\n":                  "phase 1 has no name",
+		"version: 1\nphases:\n  - name: a\n    status: finished\n": `status "finished"`,
+		"version: 1\nphase: []\n":                                  "field phase not found",
+	} {
+		_, err := ParsePlanFile("---\n" + front + "---\n# Plan\n")
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected %q, got %v", front, want, err)
+		}
+	}
+}
+
+func TestPlanCommitMessage(t *testing.T) {
+	p := &Plan{
+		Task: "Add user authentication",
+		Phases: []PlanPhase{
+			{Name: "Session store", Verify: []string{"go test ./internal/auth/...", "make build"}},
+			{Name: "Login handler"},
+		},
+	}
+	want := "Session store\n\nPhase 1 of 2 of Add user authentication\n\nVerified with:\n- go test ./internal/auth/...\n- make build"
+	if got := p.CommitMessage(0); got != want {
+		t.Errorf("unexpected message:\n%s", got)
+	}
+	if got := p.CommitMessage(1); got != "Login handler\n\nPhase 2 of 2 of Add user authentication" {
+		t.Errorf("unexpected message:\n%s", got)
+	}
+}
+
+func TestCommitPhase(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	git("init")
+	git("config", "user.name", "test")
+	git("config", "user.email", "test@example.com")
+
+	p := &Plan{Version: PlanVersion, Phases: []PlanPhase{{Name: "Session store", Status: PhaseDone}}, Body: "# Plan\n"}
+	if err := p.Save(filepath.Join(dir, "plan.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "session.go"), []byte("package auth\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CommitPhase(dir, p, 0); err != nil {
+		t.Fatal(err)
+	}
+	if subject := strings.TrimSpace(git("log", "-1", "--format=%s")); subject != "Session store" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	if files := git("show", "--name-only", "--format="); !strings.Contains(files, "plan.md") || !strings.Contains(files, "session.go") {
+		t.Errorf("expected the plan and the phase's changes in the commit, got:\n%s", files)
+	}
+
+	// Nothing left to commit is not an error
+	if err := CommitPhase(dir, p, 0); err != nil {
+		t.Errorf("committing an unchanged tree: %v", err)
+	}
+}
+
+func TestUncommittedChanges(t *testing.T) {
+	dir := t.TempDir()
+	cmd := exec.Command("git", "init", "-q")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	for _, name := range []string{"plan.md", "notes.txt", "sub/wip.go"} {
+		path := filepath.Join(dir, name)
+		_ = os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed, err := UncommittedChanges(filepath.Join(dir, "sub"), filepath.Join(dir, "plan.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{"notes.txt", "sub/wip.go"}) {
+		t.Errorf("UncommittedChanges = %v, want everything but the plan", changed)
+	}
+}
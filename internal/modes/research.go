@@ -38,7 +38,7 @@ func RunResearch(args []string) error {
 		return fmt.Errorf("no research question provided")
 	}
 
-	setup, _ := config.LoadSetup()
+	setup, _ := config.LoadEffectiveSetup()
 	backendName := setup.Defaults.Backend
 	backendCfg := setup.Backend[backendName]
 	cwd, _ := os.Getwd()
@@ -49,14 +49,7 @@ func RunResearch(args []string) error {
 	if len(urls) > 0 {
 		fmt.Fprintf(os.Stderr, "⠋ Fetching external documentation...\n")
 
-		var orchestrator *llm.OrchestratorProvider
-		if backendCfg.Type == "ollama" {
-			customExec := llm.NewOllama(backendCfg.BaseURL)
-			orchestrator = llm.NewOrchestrator(backendCfg.BaseURL, backendName, customExec, backendCfg.DefaultModel)
-		} else {
-			customExec := llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-			orchestrator = llm.NewOrchestrator(backendCfg.BaseURL, backendName, customExec, backendCfg.DefaultModel)
-		}
+		orchestrator := llm.NewOrchestrator(backendCfg.BaseURL, backendName, llm.NewForBackend(backendName, backendCfg), backendCfg.DefaultModel)
 
 		researchAgent := agents.NewResearch(orchestrator)
 		for _, url := range urls {
@@ -70,12 +63,7 @@ func RunResearch(args []string) error {
 
 	fmt.Fprintf(os.Stderr, "⠋ Analyzing codebase...\n")
 
-	var customExec llm.Provider
-	if backendCfg.Type == "ollama" {
-		customExec = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		customExec = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	customExec := llm.NewForBackend(backendName, backendCfg)
 
 	queryModel := backendCfg.GetModelForAgent("query")
 	queryAgent := agents.NewQuery(customExec, cwd, queryModel)
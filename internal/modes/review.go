@@ -2,23 +2,70 @@ package modes
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"gptcode/internal/agents"
 	"gptcode/internal/config"
+	"gptcode/internal/forge"
 	"gptcode/internal/llm"
+	"gptcode/internal/review"
 )
 
 type ReviewOptions struct {
 	Target string
 	Focus  string
+
+	// PR reviews a pull request's diff on Host instead of Target; with
+	// Post, the findings are submitted as an inline review.
+	PR   int
+	Post bool
+	Host forge.Forge
+
+	// Format prints the findings as text (the default), json or sarif
+	Format string
+	// FailOn fails the review when a finding is at this level or above:
+	// error, warn or info
+	FailOn string
+	// Version is the tool version reported in SARIF output
+	Version string
 }
 
+// Review output formats
+const (
+	ReviewFormatText  = "text"
+	ReviewFormatJSON  = "json"
+	ReviewFormatSARIF = "sarif"
+)
+
+// maxReviewDiff caps how much of a PR's diff goes into the review prompt
+const maxReviewDiff = 30000
+
 func RunReview(opts ReviewOptions) error {
-	setup, err := config.LoadSetup()
+	format := opts.Format
+	if format == "" {
+		format = ReviewFormatText
+	}
+	if format != ReviewFormatText && format != ReviewFormatJSON && format != ReviewFormatSARIF {
+		return fmt.Errorf("unknown format %q (want text, json or sarif)", format)
+	}
+	failOn := ""
+	if opts.FailOn != "" {
+		level, err := review.ParseLevel(opts.FailOn)
+		if err != nil {
+			return err
+		}
+		failOn = level
+	}
+	if opts.PR > 0 && opts.Host == nil {
+		return fmt.Errorf("reviewing a PR needs its repository")
+	}
+
+	setup, err := config.LoadEffectiveSetup()
 	if err != nil {
 		return fmt.Errorf("failed to load setup: %w", err)
 	}
@@ -34,12 +81,7 @@ func RunReview(opts ReviewOptions) error {
 		model = modelAlias
 	}
 
-	var provider llm.Provider
-	if backendCfg.Type == "ollama" {
-		provider = llm.NewOllama(backendCfg.BaseURL)
-	} else {
-		provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-	}
+	provider := llm.NewForBackend(backendName, backendCfg)
 
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -47,29 +89,50 @@ func RunReview(opts ReviewOptions) error {
 	}
 
 	reviewAgent := agents.NewReview(provider, cwd, model)
+	reviewAgent.SetOutputFormat(review.FindingsFormat)
 
-	target := opts.Target
-	if target == "" {
-		target = "."
+	// Machine-readable output owns stdout; progress goes to stderr
+	var log io.Writer = os.Stdout
+	if format != ReviewFormatText {
+		log = os.Stderr
 	}
 
-	targetPath := target
-	if !filepath.IsAbs(target) {
-		targetPath = filepath.Join(cwd, target)
-	}
-
-	info, err := os.Stat(targetPath)
-	if err != nil {
-		return fmt.Errorf("target not found: %w", err)
+	var reviewPrompt, title, diff string
+	if opts.PR > 0 {
+		diff, err = opts.Host.FetchPRDiff(opts.PR)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(diff) == "" {
+			return fmt.Errorf("PR #%d has no changes to review", opts.PR)
+		}
+		reviewPrompt = buildPRReviewPrompt(opts.PR, diff, opts.Focus)
+		title = fmt.Sprintf("PR #%d", opts.PR)
+		fmt.Fprintf(log, "Reviewing: %s (%s)\n", title, opts.Host.PRURL(opts.PR))
+	} else {
+		target := opts.Target
+		if target == "" {
+			target = "."
+		}
+
+		targetPath := target
+		if !filepath.IsAbs(target) {
+			targetPath = filepath.Join(cwd, target)
+		}
+
+		info, err := os.Stat(targetPath)
+		if err != nil {
+			return fmt.Errorf("target not found: %w", err)
+		}
+
+		reviewPrompt = buildReviewPrompt(targetPath, info.IsDir(), opts.Focus)
+		title = target
+		fmt.Fprintf(log, "Reviewing: %s\n", target)
 	}
-
-	reviewPrompt := buildReviewPrompt(targetPath, info.IsDir(), opts.Focus)
-
-	fmt.Printf("Reviewing: %s\n", target)
 	if opts.Focus != "" {
-		fmt.Printf("Focus: %s\n", opts.Focus)
+		fmt.Fprintf(log, "Focus: %s\n", opts.Focus)
 	}
-	fmt.Println()
+	fmt.Fprintln(log)
 
 	statusCallback := func(status string) {
 		fmt.Fprintf(os.Stderr, "[STATUS] %s\n", status)
@@ -87,13 +150,68 @@ func RunReview(opts ReviewOptions) error {
 	if err != nil {
 		return fmt.Errorf("review failed: %w", err)
 	}
+	findings, err := review.ParseFindings(result)
+	if err != nil {
+		return fmt.Errorf("%w\n\nReview:\n%s", err, result)
+	}
+	for i := range findings {
+		if filepath.IsAbs(findings[i].File) {
+			if rel, err := filepath.Rel(cwd, findings[i].File); err == nil {
+				findings[i].File = rel
+			}
+		}
+	}
 
-	fmt.Println("\n" + strings.Repeat("=", 80))
-	fmt.Println("CODE REVIEW")
-	fmt.Println(strings.Repeat("=", 80) + "\n")
-	fmt.Println(result)
-	fmt.Println()
+	if err := printFindings(format, title, findings, opts.Version); err != nil {
+		return err
+	}
+
+	if opts.Post {
+		submission := review.BuildReview(findings, diff)
+		if err := opts.Host.PostReview(opts.PR, submission); err != nil {
+			return err
+		}
+		fmt.Fprintf(log, "Posted review on PR #%d: %s, %d inline comment(s)\n", opts.PR, submission.Event, len(submission.Comments))
+	}
+
+	if failOn != "" {
+		if failing := review.AtLeast(findings, failOn); len(failing) > 0 {
+			return fmt.Errorf("review found %d finding(s) at %s level or above", len(failing), failOn)
+		}
+	}
+	return nil
+}
 
+func printFindings(format, title string, findings []review.Finding, version string) error {
+	switch format {
+	case ReviewFormatJSON:
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case ReviewFormatSARIF:
+		data, err := review.SARIF(findings, version)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		fmt.Println("\n" + strings.Repeat("=", 80))
+		fmt.Printf("CODE REVIEW: %s, %s\n", title, review.Summary(findings))
+		fmt.Println(strings.Repeat("=", 80) + "\n")
+		for _, f := range findings {
+			location := f.File
+			if f.Line > 0 {
+				location = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			fmt.Printf("[%s] %s %s (%s)\n", f.Level(), location, f.Title, f.Severity)
+			if f.Body != "" {
+				fmt.Printf("    %s\n", strings.ReplaceAll(f.Body, "\n", "\n    "))
+			}
+		}
+		fmt.Println()
+	}
 	return nil
 }
 
@@ -112,11 +230,21 @@ func buildReviewPrompt(targetPath string, isDir bool, focus string) string {
 		prompt.WriteString(fmt.Sprintf("\nSpecial focus: %s\n", focus))
 	}
 
-	prompt.WriteString("\nProvide a structured review covering:\n")
-	prompt.WriteString("1. Summary: Overall assessment\n")
-	prompt.WriteString("2. Critical Issues: Bugs, security risks, or breaking problems\n")
-	prompt.WriteString("3. Suggestions: Quality, performance, or maintainability improvements\n")
-	prompt.WriteString("4. Nitpicks: Style, naming, or minor preferences\n")
+	prompt.WriteString("\nReport bugs, security risks, and quality, performance or style problems as findings.\n")
 
 	return prompt.String()
 }
+
+func buildPRReviewPrompt(pr int, diff, focus string) string {
+	var prompt strings.Builder
+	prompt.WriteString(fmt.Sprintf("Review the changes of pull request #%d.\n\n", pr))
+	prompt.WriteString("The working directory may not have the PR checked out; judge the change from the diff, and read files only for surrounding context.\n")
+	if focus != "" {
+		prompt.WriteString(fmt.Sprintf("\nSpecial focus: %s\n", focus))
+	}
+	if len(diff) > maxReviewDiff {
+		diff = diff[:maxReviewDiff] + "\n... (diff truncated)"
+	}
+	prompt.WriteString("\nDiff:\n```diff\n" + diff + "\n```\n")
+	return prompt.String()
+}
@@ -8,25 +8,42 @@ import (
 	"os"
 	"strings"
 
+	"gptcode/internal/config"
 	"gptcode/internal/llm"
 	"gptcode/internal/prompt"
 	"gptcode/internal/tools"
 )
 
+// RunOptions controls how run mode executes the commands the model proposes.
+type RunOptions struct {
+	// AutoApprove runs every proposed command without asking (--yes).
+	AutoApprove bool
+	// Target runs the commands on a remote machine over SSH (--target);
+	// only run_command is offered then, the local files are not the
+	// machine's
+	Target *tools.SSHExecutor
+}
+
 func RunExecute(builder *prompt.Builder, provider llm.Provider, model string, args []string) error {
+	return RunExecuteWithOptions(builder, provider, model, args, RunOptions{})
+}
+
+// RunExecuteWithOptions is RunExecute with explicit options. Unless
+// AutoApprove is set, proposed shell commands are listed with their
+// explanations and the user picks which ones to run.
+func RunExecuteWithOptions(builder *prompt.Builder, provider llm.Provider, model string, args []string, opts RunOptions) error {
+	stdin := bufio.NewReader(os.Stdin)
 	task := ""
 	if len(args) > 0 {
 		task = strings.Join(args, " ")
 	}
 
 	if task == "" {
-		scanner := bufio.NewScanner(os.Stdin)
 		fmt.Fprintln(os.Stderr, "Run mode - Execute any task")
 		fmt.Fprintln(os.Stderr, "\nWhat task do you want to execute?")
 		fmt.Fprint(os.Stderr, "> ")
-		if scanner.Scan() {
-			task = scanner.Text()
-		}
+		line, _ := stdin.ReadString('\n')
+		task = strings.TrimSpace(line)
 	}
 
 	if task == "" {
@@ -67,10 +84,25 @@ func RunExecute(builder *prompt.Builder, provider llm.Provider, model string, ar
 		"**CRITICAL**:\n" +
 		"- NEVER execute sudo commands (they block)\n" +
 		"- Present sudo commands as suggestions with explanation\n" +
+		"- Give every run_command call a one-line explanation; the user may approve only some commands\n" +
 		"- Be AUTONOMOUS with available tools\n"
 
 	cwd, _ := os.Getwd()
 	toolsRaw := tools.GetAvailableTools()
+	if opts.Target != nil {
+		sys += "\n### REMOTE TARGET\n" +
+			fmt.Sprintf("Every run_command runs on %s over SSH, not on the user's machine. ", opts.Target.Target.Host) +
+			"Inspect that machine with commands; local files are not available.\n"
+		toolsRaw = remoteTools(toolsRaw)
+		prev := tools.ActiveExecutor()
+		tools.SetExecutor(opts.Target)
+		defer tools.SetExecutor(prev)
+	} else if tools.RegisterKubeTools(cwd) {
+		toolsRaw = tools.GetAvailableTools()
+		if isKubeQuery(task) {
+			sys += kubePlaybook
+		}
+	}
 	var availableTools []interface{}
 	for _, t := range toolsRaw {
 		availableTools = append(availableTools, t)
@@ -80,6 +112,10 @@ func RunExecute(builder *prompt.Builder, provider llm.Provider, model string, ar
 		{Role: "user", Content: task},
 	}
 
+	// Stream responses on a terminal; piped output stays one block per turn
+	setup, _ := config.LoadEffectiveSetup()
+	stream := isInteractiveTerminal() && setup.Output.StreamEnabled()
+
 	maxIterations := 15
 
 	for i := 0; i < maxIterations; i++ {
@@ -90,12 +126,23 @@ func RunExecute(builder *prompt.Builder, provider llm.Provider, model string, ar
 			Messages:     messages,
 		}
 
-		resp, err := provider.Chat(context.Background(), req)
+		var onChunk func(string)
+		if stream {
+			onChunk = func(chunk string) { fmt.Print(chunk) }
+		}
+		resp, err := llm.StreamChat(context.Background(), provider, req, onChunk)
 		if err != nil {
+			if stream {
+				fmt.Println()
+			}
 			return fmt.Errorf("LLM error: %w", err)
 		}
 
-		if resp.Text != "" {
+		if stream {
+			if resp.Text != "" && !strings.HasSuffix(resp.Text, "\n") {
+				fmt.Println()
+			}
+		} else if resp.Text != "" {
 			fmt.Println(strings.TrimSpace(resp.Text))
 		}
 
@@ -109,7 +156,28 @@ func RunExecute(builder *prompt.Builder, provider llm.Provider, model string, ar
 			ToolCalls: resp.ToolCalls,
 		})
 
+		approved := map[string]bool{}
+		if cmds := collectCommands(resp.ToolCalls); len(cmds) > 0 {
+			if opts.AutoApprove {
+				for _, c := range cmds {
+					approved[c.ID] = true
+				}
+			} else {
+				approved = confirmCommands(cmds, stdin, os.Stderr)
+			}
+		}
+
 		for _, tc := range resp.ToolCalls {
+			if tc.Name == "run_command" && !approved[tc.ID] {
+				messages = append(messages, llm.ChatMessage{
+					Role:       "tool",
+					Content:    "Skipped: the user chose not to run this command.",
+					Name:       tc.Name,
+					ToolCallID: tc.ID,
+				})
+				continue
+			}
+
 			var args map[string]interface{}
 			_ = json.Unmarshal([]byte(tc.Arguments), &args)
 
@@ -140,3 +208,14 @@ func RunExecute(builder *prompt.Builder, provider llm.Provider, model string, ar
 
 	return nil
 }
+
+// remoteTools keeps run_command, the only tool that reaches a remote target
+func remoteTools(all []map[string]interface{}) []map[string]interface{} {
+	var kept []map[string]interface{}
+	for _, t := range all {
+		if fn, ok := t["function"].(map[string]interface{}); ok && fn["name"] == "run_command" {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,32 @@
+package modes
+
+import "strings"
+
+// kubeQueryKeys mark tasks about a Kubernetes cluster
+var kubeQueryKeys = []string{
+	"kubernetes", "k8s", "kubectl", "crashloop", "imagepullbackoff",
+	"errimagepull", "oomkilled", "pod ", "pods", "deployment", "statefulset",
+	"daemonset", "namespace", "ingress", "helm",
+}
+
+func isKubeQuery(s string) bool {
+	q := strings.ToLower(s) + " "
+	for _, k := range kubeQueryKeys {
+		if strings.Contains(q, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// kubePlaybook is added to run mode's prompt for Kubernetes tasks when the
+// k8s_* tools are registered
+const kubePlaybook = "\n### KUBERNETES PLAYBOOK\n" +
+	"Use the k8s_get, k8s_describe, k8s_logs and k8s_events tools instead of run_command with kubectl. They only read.\n" +
+	"For a failing workload (CrashLoopBackOff, Error, Pending, ImagePullBackOff, OOMKilled), chain them:\n" +
+	"1. k8s_get the deployment, then k8s_get pods with its selector (e.g. app=<name>) to find the failing pods, their status and restarts\n" +
+	"2. k8s_describe one failing pod: read Last State, exit code, reason (OOMKilled, Error), probes and its events\n" +
+	"3. k8s_logs that pod with previous=true for the crashed container's last lines; without previous if it never started\n" +
+	"4. k8s_events for the pod, and for the namespace when the pod has none (scheduling, quota, image pulls)\n" +
+	"5. When the evidence points there, k8s_get the configmaps, services or nodes involved\n" +
+	"Then give the root cause with the evidence (quote the log line or event) and the fix as commands for the user to run, such as kubectl rollout undo or kubectl set resources. Never apply changes yourself.\n"
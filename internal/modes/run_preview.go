@@ -0,0 +1,116 @@
+package modes
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gptcode/internal/llm"
+)
+
+// commandPreview is a shell command proposed by the model, shown to the user
+// before anything runs.
+type commandPreview struct {
+	ID          string
+	Command     string
+	Explanation string
+}
+
+// collectCommands returns the run_command calls of a response, in order.
+func collectCommands(calls []llm.ChatToolCall) []commandPreview {
+	var cmds []commandPreview
+	for _, tc := range calls {
+		if tc.Name != "run_command" {
+			continue
+		}
+		var args struct {
+			Command     string `json:"command"`
+			Explanation string `json:"explanation"`
+		}
+		_ = json.Unmarshal([]byte(tc.Arguments), &args)
+		cmds = append(cmds, commandPreview{ID: tc.ID, Command: args.Command, Explanation: args.Explanation})
+	}
+	return cmds
+}
+
+// confirmCommands prints the proposed commands and asks which ones to run.
+// It returns the tool call IDs the user approved.
+func confirmCommands(cmds []commandPreview, in *bufio.Reader, out io.Writer) map[string]bool {
+	fmt.Fprintf(out, "\nProposed commands:\n")
+	for i, c := range cmds {
+		fmt.Fprintf(out, "  [%d] %s\n", i+1, c.Command)
+		if c.Explanation != "" {
+			fmt.Fprintf(out, "      %s\n", c.Explanation)
+		}
+	}
+
+	approved := make(map[string]bool)
+	for {
+		fmt.Fprintf(out, "Run which? [a]ll, [n]one, or numbers/ranges (e.g. 1,3-4): ")
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(out, "\nNo selection; skipping commands (use --yes to run without confirmation).")
+			return approved
+		}
+
+		selected, perr := parseSelection(line, len(cmds))
+		if perr != nil {
+			fmt.Fprintf(out, "  %v\n", perr)
+			continue
+		}
+		for _, idx := range selected {
+			approved[cmds[idx].ID] = true
+		}
+		return approved
+	}
+}
+
+// parseSelection parses "a", "n", "2", "1,3-5" into zero-based indices for a
+// list of n items.
+func parseSelection(input string, n int) ([]int, error) {
+	input = strings.ToLower(strings.TrimSpace(input))
+	switch input {
+	case "a", "all", "y", "yes":
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all, nil
+	case "", "n", "none", "no":
+		return nil, nil
+	}
+
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i > 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		start, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		end, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		if start < 1 || end > n || start > end {
+			return nil, fmt.Errorf("selection %q out of range 1-%d", part, n)
+		}
+		for i := start; i <= end; i++ {
+			seen[i-1] = true
+		}
+	}
+
+	indices := make([]int, 0, len(seen))
+	for i := range seen {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices, nil
+}
@@ -0,0 +1,61 @@
+package modes
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{"a", []int{0, 1, 2, 3}, false},
+		{"", nil, false},
+		{"n", nil, false},
+		{"2", []int{1}, false},
+		{"1,3-4", []int{0, 2, 3}, false},
+		{"3-4, 1, 4", []int{0, 2, 3}, false},
+		{"5", nil, true},
+		{"x", nil, true},
+		{"3-1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.input, 4)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSelection(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && len(got)+len(tt.want) > 0 && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelection(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestConfirmCommands(t *testing.T) {
+	cmds := collectCommands([]llm.ChatToolCall{
+		{ID: "1", Name: "run_command", Arguments: `{"command":"df -h","explanation":"disk usage"}`},
+		{ID: "2", Name: "read_file", Arguments: `{"path":"x"}`},
+		{ID: "3", Name: "run_command", Arguments: `{"command":"rm -rf tmp"}`},
+	})
+	if len(cmds) != 2 {
+		t.Fatalf("collected %d commands, want 2", len(cmds))
+	}
+
+	in := bufio.NewReader(strings.NewReader("9\n1\n"))
+	approved := confirmCommands(cmds, in, io.Discard)
+	if !approved["1"] || approved["3"] {
+		t.Errorf("approved = %v, want only 1", approved)
+	}
+
+	approved = confirmCommands(cmds, bufio.NewReader(strings.NewReader("")), io.Discard)
+	if len(approved) != 0 {
+		t.Errorf("EOF should approve nothing, got %v", approved)
+	}
+}
@@ -0,0 +1,233 @@
+package observability
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gptcode/internal/catalog"
+)
+
+// ErrBudgetExceeded stops a run that spent its --max-cost budget
+var ErrBudgetExceeded = errors.New("cost budget exceeded")
+
+// PriceFunc returns a model's prompt and completion prices in USD per
+// million tokens.
+type PriceFunc func(backend, model string) (prompt, completion float64, ok bool)
+
+// CostFor prices a call from per-million-token prices.
+func CostFor(promptPerM, completionPerM float64, tokensIn, tokensOut int) float64 {
+	return (float64(tokensIn)*promptPerM + float64(tokensOut)*completionPerM) / 1e6
+}
+
+// UsageRecord is one backend/model's spend in one session, as persisted in
+// ~/.gptcode/usage.jsonl
+type UsageRecord struct {
+	Time      time.Time `json:"time"`
+	Session   string    `json:"session"`
+	Command   string    `json:"command"`
+	Backend   string    `json:"backend"`
+	Model     string    `json:"model"`
+	Calls     int       `json:"calls"`
+	TokensIn  int       `json:"tokens_in"`
+	TokensOut int       `json:"tokens_out"`
+	Cost      float64   `json:"cost"`
+	// Unpriced is set when the model had no catalog price and Cost is 0
+	Unpriced bool `json:"unpriced,omitempty"`
+}
+
+// CostMeter prices every LLM call of a run from the model catalog, enforces
+// an optional spending limit and persists the session's spend.
+type CostMeter struct {
+	Session string
+	Command string
+	Price   PriceFunc
+	// OnExceeded is asked whether to continue once the limit is reached.
+	// Returning true extends the limit by its original amount; when nil the
+	// run stops.
+	OnExceeded func(spent, limit float64) bool
+
+	mu      sync.Mutex
+	limit   float64
+	initial float64
+	spent   float64
+	usage   map[string]*UsageRecord
+}
+
+// NewCostMeter returns a meter for command with a limit in USD; 0 means no
+// limit.
+func NewCostMeter(session, command string, limit float64) *CostMeter {
+	return &CostMeter{
+		Session: session,
+		Command: command,
+		Price:   catalog.Price,
+		limit:   limit,
+		initial: limit,
+		usage:   map[string]*UsageRecord{},
+	}
+}
+
+var (
+	activeMu    sync.Mutex
+	activeMeter *CostMeter
+)
+
+// SetCostMeter installs the meter LLM providers report to; nil removes it.
+func SetCostMeter(m *CostMeter) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	activeMeter = m
+}
+
+// ActiveCostMeter returns the installed meter, or nil.
+func ActiveCostMeter() *CostMeter {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	return activeMeter
+}
+
+// Check returns ErrBudgetExceeded when the limit is spent and OnExceeded
+// does not allow more. Providers call it before each request.
+func (m *CostMeter) Check() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.limit <= 0 || m.spent < m.limit {
+		return nil
+	}
+	if m.OnExceeded != nil && m.OnExceeded(m.spent, m.limit) {
+		m.limit += m.initial
+		return nil
+	}
+	return fmt.Errorf("%w: spent $%.4f of $%.4f", ErrBudgetExceeded, m.spent, m.limit)
+}
+
+// Record adds a call's token usage and returns its cost.
+func (m *CostMeter) Record(backend, model string, tokensIn, tokensOut int) float64 {
+	promptPerM, completionPerM, priced := 0.0, 0.0, false
+	if m.Price != nil {
+		promptPerM, completionPerM, priced = m.Price(backend, model)
+	}
+	cost := CostFor(promptPerM, completionPerM, tokensIn, tokensOut)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := backend + "/" + model
+	rec, ok := m.usage[key]
+	if !ok {
+		rec = &UsageRecord{Session: m.Session, Command: m.Command, Backend: backend, Model: model}
+		m.usage[key] = rec
+	}
+	rec.Calls++
+	rec.TokensIn += tokensIn
+	rec.TokensOut += tokensOut
+	rec.Cost += cost
+	rec.Unpriced = rec.Unpriced || !priced
+	m.spent += cost
+	return cost
+}
+
+// Spent returns the run's cost so far in USD.
+func (m *CostMeter) Spent() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.spent
+}
+
+// Limit returns the run's spending limit in USD, 0 when there is none. It
+// grows when OnExceeded allows an overrun.
+func (m *CostMeter) Limit() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.limit
+}
+
+// Remaining returns what the run may still spend in USD, and false when
+// there is no limit.
+func (m *CostMeter) Remaining() (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.limit <= 0 {
+		return 0, false
+	}
+	return max(m.limit-m.spent, 0), true
+}
+
+// Records returns the session's usage per backend/model.
+func (m *CostMeter) Records() []UsageRecord {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]UsageRecord, 0, len(m.usage))
+	for _, rec := range m.usage {
+		out = append(out, *rec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Backend+out[i].Model < out[j].Backend+out[j].Model
+	})
+	return out
+}
+
+// Save appends the session's usage to the usage log.
+func (m *CostMeter) Save() error {
+	records := m.Records()
+	if len(records) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range records {
+		records[i].Time = now
+	}
+	return AppendUsage(UsagePath(), records)
+}
+
+// UsagePath is the usage log, ~/.gptcode/usage.jsonl
+func UsagePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "usage.jsonl")
+}
+
+// AppendUsage appends records to the log at path.
+func AppendUsage(path string, records []UsageRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadUsage reads the usage log at path, skipping malformed lines.
+func LoadUsage(path string) ([]UsageRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err == nil {
+			records = append(records, rec)
+		}
+	}
+	return records, scanner.Err()
+}
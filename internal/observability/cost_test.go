@@ -0,0 +1,81 @@
+package observability
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func fixedPrice(backend, model string) (float64, float64, bool) {
+	if model == "free" {
+		return 0, 0, false
+	}
+	return 1, 2, true
+}
+
+func TestCostMeterRecordAndCheck(t *testing.T) {
+	m := NewCostMeter("s1", "gptcode do", 0.003)
+	m.Price = fixedPrice
+
+	if cost := m.Record("openai", "gpt", 1000, 500); cost != 0.002 {
+		t.Errorf("cost = %v, want 0.002", cost)
+	}
+	m.Record("ollama", "free", 1000, 1000)
+	if err := m.Check(); err != nil {
+		t.Fatalf("under budget: %v", err)
+	}
+
+	if left, limited := m.Remaining(); !limited || left < 0.00099 || left > 0.00101 {
+		t.Errorf("Remaining = %v, %v; want 0.001, true", left, limited)
+	}
+
+	m.Record("openai", "gpt", 1000, 0)
+	if left, _ := m.Remaining(); left != 0 {
+		t.Errorf("an overrun leaves nothing, got %v", left)
+	}
+	if err := m.Check(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+
+	asked := 0
+	m.OnExceeded = func(spent, limit float64) bool { asked++; return true }
+	if err := m.Check(); err != nil || asked != 1 {
+		t.Fatalf("approved overrun should continue: err=%v asked=%d", err, asked)
+	}
+	if err := m.Check(); err != nil || asked != 1 {
+		t.Errorf("extended limit should not ask again yet: err=%v asked=%d", err, asked)
+	}
+
+	records := m.Records()
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %+v", records)
+	}
+	if r := records[0]; r.Backend != "ollama" || !r.Unpriced || r.Cost != 0 {
+		t.Errorf("unexpected ollama record: %+v", r)
+	}
+	if r := records[1]; r.Calls != 2 || r.TokensIn != 2000 || r.TokensOut != 500 || r.Session != "s1" {
+		t.Errorf("unexpected openai record: %+v", r)
+	}
+}
+
+func TestUsageLogRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+	if records, err := LoadUsage(path); err != nil || records != nil {
+		t.Fatalf("missing log should be empty: %v %v", records, err)
+	}
+
+	in := []UsageRecord{{Session: "a", Backend: "groq", Model: "m", Calls: 1, Cost: 0.5}}
+	if err := AppendUsage(path, in); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendUsage(path, in); err != nil {
+		t.Fatal(err)
+	}
+	out, err := LoadUsage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[1].Backend != "groq" || out[1].Cost != 0.5 {
+		t.Errorf("unexpected records: %+v", out)
+	}
+}
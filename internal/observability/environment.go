@@ -0,0 +1,128 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment is a snapshot of what a session ran with, to tell why a task
+// behaves differently between runs
+type Environment struct {
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// Tools maps the toolchains found on PATH to their versions
+	Tools     map[string]string `json:"tools,omitempty"`
+	GitCommit string            `json:"git_commit,omitempty"`
+	GitBranch string            `json:"git_branch,omitempty"`
+	// Dirty lists the files with uncommitted changes when the session began
+	Dirty []string `json:"dirty,omitempty"`
+	// Models are the backend/model pairs the session called
+	Models []string `json:"models,omitempty"`
+}
+
+// toolVersions are the commands printing the version of each toolchain
+var toolVersions = map[string][]string{
+	"go":      {"go", "version"},
+	"node":    {"node", "--version"},
+	"mix":     {"mix", "--version"},
+	"python3": {"python3", "--version"},
+	"ruby":    {"ruby", "--version"},
+	"cargo":   {"cargo", "--version"},
+}
+
+const toolTimeout = 5 * time.Second
+
+// CaptureEnvironment snapshots the OS, toolchain versions and git state of
+// dir. Missing tools and git are left out.
+func CaptureEnvironment(dir string) *Environment {
+	env := &Environment{OS: runtime.GOOS, Arch: runtime.GOARCH, Tools: map[string]string{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, args := range toolVersions {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := commandOutput(dir, args...)
+			if err != nil {
+				return
+			}
+			// mix prints the Erlang banner before its own version
+			lines := strings.Split(out, "\n")
+			version := lines[len(lines)-1]
+			mu.Lock()
+			env.Tools[name] = version
+			mu.Unlock()
+		}()
+	}
+
+	if commit, err := commandOutput(dir, "git", "rev-parse", "HEAD"); err == nil {
+		env.GitCommit = commit
+		env.GitBranch, _ = commandOutput(dir, "git", "rev-parse", "--abbrev-ref", "HEAD")
+		if status, err := commandOutput(dir, "git", "status", "--porcelain"); err == nil && status != "" {
+			for _, line := range strings.Split(status, "\n") {
+				if len(line) > 3 {
+					env.Dirty = append(env.Dirty, line[3:])
+				}
+			}
+		}
+	}
+	wg.Wait()
+	return env
+}
+
+func commandOutput(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), toolTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// Diff lists what changed from e to other, one line per difference
+func (e *Environment) Diff(other *Environment) []string {
+	var changes []string
+	change := func(what, from, to string) {
+		switch {
+		case from == to:
+		case from == "":
+			changes = append(changes, fmt.Sprintf("+ %s: %s", what, to))
+		case to == "":
+			changes = append(changes, fmt.Sprintf("- %s: %s", what, from))
+		default:
+			changes = append(changes, fmt.Sprintf("~ %s: %s -> %s", what, from, to))
+		}
+	}
+
+	change("os", e.OS+"/"+e.Arch, other.OS+"/"+other.Arch)
+	tools := map[string]bool{}
+	for name := range e.Tools {
+		tools[name] = true
+	}
+	for name := range other.Tools {
+		tools[name] = true
+	}
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		change(name, e.Tools[name], other.Tools[name])
+	}
+	change("git commit", e.GitCommit, other.GitCommit)
+	change("git branch", e.GitBranch, other.GitBranch)
+	change("dirty files", strings.Join(e.Dirty, ", "), strings.Join(other.Dirty, ", "))
+	change("models", strings.Join(e.Models, ", "), strings.Join(other.Models, ", "))
+	return changes
+}
@@ -0,0 +1,51 @@
+package observability
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCaptureEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.email=t@example.com", "-c", "user.name=t", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Skipf("git unavailable: %v %s", err, out)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := CaptureEnvironment(dir)
+	if len(env.GitCommit) != 40 || !reflect.DeepEqual(env.Dirty, []string{"main.go"}) {
+		t.Errorf("unexpected git state: %s %v", env.GitCommit, env.Dirty)
+	}
+	if !strings.HasPrefix(env.Tools["go"], "go version go") {
+		t.Errorf("unexpected go version %q", env.Tools["go"])
+	}
+}
+
+func TestEnvironmentDiff(t *testing.T) {
+	before := &Environment{OS: "linux", Arch: "amd64", Tools: map[string]string{"go": "go1.23", "node": "v20"}, GitCommit: "a1", Models: []string{"groq/llama"}}
+	after := &Environment{OS: "linux", Arch: "amd64", Tools: map[string]string{"go": "go1.24", "mix": "Mix 1.17"}, GitCommit: "a1", Dirty: []string{"x.go"}, Models: []string{"groq/llama"}}
+
+	want := []string{
+		"~ go: go1.23 -> go1.24",
+		"+ mix: Mix 1.17",
+		"- node: v20",
+		"+ dirty files: x.go",
+	}
+	if got := before.Diff(after); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+	if got := after.Diff(after); len(got) != 0 {
+		t.Errorf("expected no changes, got %q", got)
+	}
+}
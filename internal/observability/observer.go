@@ -49,6 +49,7 @@ type LLMRequestEvent struct {
 	Backend   string        `json:"backend"`
 	TokensIn  int           `json:"tokens_in"`
 	TokensOut int           `json:"tokens_out"`
+	Cost      float64       `json:"cost,omitempty"`
 	Duration  time.Duration `json:"duration_ms"`
 	Error     string        `json:"error,omitempty"`
 }
@@ -96,6 +97,7 @@ type ExecutionSummary struct {
 	LLMCalls      int               `json:"llm_calls"`
 	TokensIn      int               `json:"tokens_in"`
 	TokensOut     int               `json:"tokens_out"`
+	Cost          float64           `json:"cost"`
 	Errors        []string          `json:"errors"`
 	Success       bool              `json:"success"`
 }
@@ -134,6 +136,7 @@ type AgentObserver struct {
 	llmCalls      int
 	tokensIn      int
 	tokensOut     int
+	cost          float64
 	errors        []string
 	success       bool
 }
@@ -180,6 +183,7 @@ func (o *AgentObserver) Emit(event Event) {
 		o.llmCalls++
 		o.tokensIn += e.TokensIn
 		o.tokensOut += e.TokensOut
+		o.cost += e.Cost
 		if e.Error != "" {
 			o.errors = append(o.errors, e.Error)
 		}
@@ -268,6 +272,11 @@ func (o *AgentObserver) Summary() *ExecutionSummary {
 		modified = append(modified, path)
 	}
 
+	cost := o.cost
+	if m := ActiveCostMeter(); m != nil {
+		cost = m.Spent()
+	}
+
 	return &ExecutionSummary{
 		Duration:      time.Since(o.startTime),
 		FilesCreated:  created,
@@ -277,6 +286,7 @@ func (o *AgentObserver) Summary() *ExecutionSummary {
 		LLMCalls:      o.llmCalls,
 		TokensIn:      o.tokensIn,
 		TokensOut:     o.tokensOut,
+		Cost:          cost,
 		Errors:        o.errors,
 		Success:       o.success && len(o.errors) == 0,
 	}
@@ -452,6 +462,7 @@ func (o *AgentObserver) PrintSummary() {
 		fmt.Printf("  Tokens In:          %s\n", formatNumber(summary.TokensIn))
 		fmt.Printf("  Tokens Out:         %s\n", formatNumber(summary.TokensOut))
 		fmt.Printf("  Total Tokens:       %s\n", formatNumber(summary.TokensIn+summary.TokensOut))
+		fmt.Printf("  Cost:               $%.4f\n", summary.Cost)
 	} else {
 		fmt.Println("  No LLM calls recorded")
 	}
@@ -45,6 +45,10 @@ type SessionTrace struct {
 	TotalCost   float64     `json:"total_cost"`
 	TotalTimeMs int64       `json:"total_time_ms"`
 	Success     bool        `json:"success"`
+	// Transcript is the file holding the session's LLM exchanges
+	Transcript string `json:"transcript,omitempty"`
+	// Environment is what the session ran with
+	Environment *Environment `json:"environment,omitempty"`
 }
 
 // Tracer is the interface for recording execution traces
@@ -19,6 +19,9 @@ type TracerImpl struct {
 	path      []string
 	totalCost float64
 	mutex     sync.Mutex
+	// capture receives the environment snapshot taken as the session begins
+	capture     chan *Environment
+	environment *Environment
 }
 
 // NewTracer creates a new tracer instance
@@ -38,6 +41,14 @@ func (t *TracerImpl) Begin(sessionID, command string) error {
 	t.command = command
 	t.startTime = time.Now()
 
+	// Running the toolchains for their versions takes a moment, so the
+	// snapshot is taken alongside the session
+	t.capture = make(chan *Environment, 1)
+	go func() {
+		dir, _ := os.Getwd()
+		t.capture <- CaptureEnvironment(dir)
+	}()
+
 	return nil
 }
 
@@ -103,6 +114,11 @@ func (t *TracerImpl) End(success bool) error {
 	endTime := time.Now()
 	totalTimeMs := endTime.Sub(t.startTime).Milliseconds()
 
+	// Steps rarely carry their own cost; the run's meter has the real spend
+	if m := ActiveCostMeter(); m != nil && t.totalCost == 0 {
+		t.totalCost = m.Spent()
+	}
+
 	sessionTrace := SessionTrace{
 		SessionID:   t.sessionID,
 		Command:     t.command,
@@ -114,6 +130,21 @@ func (t *TracerImpl) End(success bool) error {
 		TotalTimeMs: totalTimeMs,
 		Success:     success,
 	}
+	if tr := ActiveTranscript(); tr != nil {
+		sessionTrace.Transcript = tr.Path()
+	}
+	if t.capture != nil {
+		t.environment, t.capture = <-t.capture, nil
+	}
+	if t.environment != nil {
+		env := *t.environment
+		if m := ActiveCostMeter(); m != nil {
+			for _, rec := range m.Records() {
+				env.Models = append(env.Models, rec.Backend+"/"+rec.Model)
+			}
+		}
+		sessionTrace.Environment = &env
+	}
 
 	// Write to file for now (can be extended to other storage)
 	if err := t.writeToFile(sessionTrace); err != nil {
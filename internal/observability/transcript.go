@@ -0,0 +1,138 @@
+package observability
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keepTranscripts is how many session transcripts are kept on disk
+const keepTranscripts = 20
+
+// Exchange is one LLM call: the prompt sent and the response received
+type Exchange struct {
+	Time      time.Time       `json:"time"`
+	Backend   string          `json:"backend"`
+	Model     string          `json:"model"`
+	System    string          `json:"system,omitempty"`
+	Messages  json.RawMessage `json:"messages,omitempty"`
+	Response  string          `json:"response,omitempty"`
+	ToolCalls json.RawMessage `json:"tool_calls,omitempty"`
+}
+
+// Transcript appends a session's LLM exchanges to a JSONL file so failed
+// runs can be inspected and bundled with `gptcode bugreport`.
+type Transcript struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewTranscript returns the transcript for session under
+// ~/.gptcode/transcripts, removing all but the most recent transcripts.
+func NewTranscript(session string) *Transcript {
+	dir := TranscriptsDir()
+	pruneTranscripts(dir, keepTranscripts-1)
+	return &Transcript{path: filepath.Join(dir, session+".jsonl")}
+}
+
+// Path is the transcript file
+func (t *Transcript) Path() string {
+	return t.path
+}
+
+// Record appends an exchange.
+func (t *Transcript) Record(ex Exchange) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(ex)
+}
+
+var (
+	transcriptMu     sync.Mutex
+	activeTranscript *Transcript
+)
+
+// SetTranscript installs the transcript LLM providers record to; nil
+// removes it.
+func SetTranscript(t *Transcript) {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+	activeTranscript = t
+}
+
+// ActiveTranscript returns the installed transcript, or nil.
+func ActiveTranscript() *Transcript {
+	transcriptMu.Lock()
+	defer transcriptMu.Unlock()
+	return activeTranscript
+}
+
+// TranscriptsDir is ~/.gptcode/transcripts
+func TranscriptsDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "transcripts")
+}
+
+// LoadTranscript reads the exchanges in a transcript file, skipping
+// malformed lines.
+func LoadTranscript(path string) ([]Exchange, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var exchanges []Exchange
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var ex Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err == nil {
+			exchanges = append(exchanges, ex)
+		}
+	}
+	return exchanges, scanner.Err()
+}
+
+func pruneTranscripts(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	type file struct {
+		path string
+		mod  time.Time
+	}
+	var files []file
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			files = append(files, file{filepath.Join(dir, e.Name()), info.ModTime()})
+		}
+	}
+	if len(files) <= keep {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.After(files[j].mod) })
+	for _, f := range files[keep:] {
+		_ = os.Remove(f.path)
+	}
+}
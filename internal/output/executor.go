@@ -12,25 +12,25 @@ func HandleCodeBlock(action Action, code string) error {
 	switch action {
 	case ActionCopy:
 		if err := clipboard.WriteAll(code); err != nil {
-			fmt.Fprintf(os.Stderr, "✗ Failed to copy: %v\n", err)
+			fmt.Fprintln(os.Stderr, Failf("Failed to copy: %v", err))
 			return err
 		}
-		fmt.Fprintln(os.Stderr, "✓ Copied to clipboard")
+		fmt.Fprintln(os.Stderr, OKf("Copied to clipboard"))
 
 	case ActionRun:
-		fmt.Fprintln(os.Stderr, "\n✓ Running...")
+		fmt.Fprintln(os.Stderr, "\n"+OKf("Running..."))
 		cmd := exec.Command("sh", "-c", code)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		if err := cmd.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "\n✗ Command failed: %v\n", err)
+			fmt.Fprintln(os.Stderr, "\n"+Failf("Command failed: %v", err))
 			return err
 		}
 
 	case ActionEdit:
 		edited, err := openInEditor(code)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "✗ Failed to open editor: %v\n", err)
+			fmt.Fprintln(os.Stderr, Failf("Failed to open editor: %v", err))
 			return err
 		}
 
@@ -42,7 +42,7 @@ func HandleCodeBlock(action Action, code string) error {
 			cmd.Stdout = os.Stdout
 			cmd.Stderr = os.Stderr
 			if err := cmd.Run(); err != nil {
-				fmt.Fprintf(os.Stderr, "\n✗ Command failed: %v\n", err)
+				fmt.Fprintln(os.Stderr, "\n"+Failf("Command failed: %v", err))
 				return err
 			}
 		}
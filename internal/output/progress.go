@@ -0,0 +1,133 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// AccessibleInterval is how often accessible progress prints a
+// "still working" line while the status does not change.
+var AccessibleInterval = 10 * time.Second
+
+var spinnerFrames = map[Profile][]string{
+	ProfileEmoji: {"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"},
+	ProfileASCII: {"|", "/", "-", "\\"},
+	ProfilePlain: {"|", "/", "-", "\\"},
+}
+
+// terminal is held while a prompt waits for its answer and while a
+// progress line is written, so work running in parallel, such as the
+// movements of a symphony wave, neither interleaves with a prompt nor
+// prompts twice at once.
+var terminal sync.Mutex
+
+// LockTerminal holds the terminal until the returned function is called.
+// Prompts hold it from the question to the answer.
+func LockTerminal() (unlock func()) {
+	terminal.Lock()
+	return terminal.Unlock
+}
+
+// Progress reports the status of a long-running step. It either animates a
+// single redrawn line (spinner), prints one line per step (status), or, in
+// accessible mode, prints a plain line for every status change plus a
+// periodic heartbeat, never using control characters.
+type Progress struct {
+	w       io.Writer
+	animate bool
+
+	mu      sync.Mutex
+	label   string
+	started time.Time
+	changed time.Time
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// StartSpinner shows an animated spinner with label on w until Stop.
+func StartSpinner(w io.Writer, label string) *Progress {
+	return startProgress(w, label, !current.Accessible)
+}
+
+// StartStatus prints label as a line on w. In accessible mode it also
+// prints a heartbeat line while the step runs.
+func StartStatus(w io.Writer, label string) *Progress {
+	unlock := LockTerminal()
+	fmt.Fprintf(w, "%s...\n", label)
+	unlock()
+	return startProgress(w, label, false)
+}
+
+func startProgress(w io.Writer, label string, animate bool) *Progress {
+	now := time.Now()
+	p := &Progress{w: w, animate: animate, label: label, started: now, changed: now, done: make(chan struct{})}
+	if animate || current.Accessible {
+		p.wg.Add(1)
+		go p.loop()
+	}
+	return p
+}
+
+func (p *Progress) loop() {
+	defer p.wg.Done()
+	interval := AccessibleInterval
+	if p.animate {
+		interval = 80 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	frames := spinnerFrames[current.Profile]
+	for i := 0; ; i++ {
+		p.mu.Lock()
+		unlock := LockTerminal()
+		if p.animate {
+			fmt.Fprintf(p.w, "\r\033[K%s %s", frames[i%len(frames)], p.label)
+		} else if i > 0 && time.Since(p.changed) >= interval {
+			fmt.Fprintf(p.w, "%s: still working (%s elapsed)\n", p.label, time.Since(p.started).Round(time.Second))
+			p.changed = time.Now()
+		}
+		unlock()
+		p.mu.Unlock()
+
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update replaces the status text.
+func (p *Progress) Update(status string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if status == p.label {
+		return
+	}
+	p.label = status
+	p.changed = time.Now()
+	if !p.animate {
+		unlock := LockTerminal()
+		fmt.Fprintln(p.w, status)
+		unlock()
+	}
+}
+
+// Stop ends the progress display and clears the spinner line.
+func (p *Progress) Stop() {
+	select {
+	case <-p.done:
+		return
+	default:
+		close(p.done)
+	}
+	p.wg.Wait()
+	if p.animate {
+		unlock := LockTerminal()
+		fmt.Fprint(p.w, "\r\033[K")
+		unlock()
+	}
+}
@@ -0,0 +1,62 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAccessibleProgressHasNoControlCharacters(t *testing.T) {
+	SetAccessible(true)
+	interval := AccessibleInterval
+	AccessibleInterval = 20 * time.Millisecond
+	t.Cleanup(func() {
+		SetAccessible(false)
+		AccessibleInterval = interval
+	})
+
+	var out syncBuffer
+	p := StartSpinner(&out, "Thinking...")
+	p.Update("Reading files")
+	time.Sleep(70 * time.Millisecond)
+	p.Stop()
+
+	got := out.String()
+	if strings.ContainsAny(got, "\r\033") {
+		t.Errorf("accessible output contains control characters: %q", got)
+	}
+	if !strings.Contains(got, "Reading files\n") {
+		t.Errorf("status change not printed as a line: %q", got)
+	}
+	if !strings.Contains(got, "Reading files: still working") {
+		t.Errorf("expected a heartbeat line: %q", got)
+	}
+}
+
+func TestStatusPrintsLabelOnce(t *testing.T) {
+	var out syncBuffer
+	p := StartStatus(&out, "Validating")
+	p.Stop()
+	if got := out.String(); got != "Validating...\n" {
+		t.Errorf("got %q", got)
+	}
+}
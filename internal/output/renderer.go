@@ -5,8 +5,15 @@ import (
 )
 
 func RenderMarkdown(md string) (string, error) {
+	style := glamour.WithAutoStyle()
+	switch {
+	case current.Profile != ProfileEmoji:
+		style = glamour.WithStandardStyle("ascii")
+	case !current.Color:
+		style = glamour.WithStandardStyle("notty")
+	}
 	r, err := glamour.NewTermRenderer(
-		glamour.WithAutoStyle(),
+		style,
 		glamour.WithWordWrap(100),
 	)
 	if err != nil {
@@ -11,24 +11,42 @@ import (
 )
 
 var (
+	HeaderStyle    lipgloss.Style
+	SeparatorStyle lipgloss.Style
+	SuccessStyle   lipgloss.Style
+	BoxStyle       lipgloss.Style
+)
+
+func init() {
+	rebuildStyles()
+}
+
+// rebuildStyles derives the shared styles from the active output style
+func rebuildStyles() {
+	t := current.Theme
 	HeaderStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("#7aa2f7")). // Tokyo Night Blue
-			MarginTop(1).
-			MarginBottom(1)
+		Bold(true).
+		Foreground(t.Header).
+		MarginTop(1).
+		MarginBottom(1)
 
 	SeparatorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#3b4261")) // Tokyo Night Gutter (Dark Grey)
+		Foreground(t.Separator)
 
 	SuccessStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#9ece6a")). // Tokyo Night Green
-			Bold(true)
+		Foreground(t.Success).
+		Bold(true)
 
 	BoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("#7dcfff")). // Tokyo Night Cyan
-			Padding(0, 1)
-)
+		BorderForeground(t.Accent).
+		Padding(0, 1)
+	switch current.Profile {
+	case ProfileEmoji:
+		BoxStyle = BoxStyle.Border(lipgloss.RoundedBorder())
+	case ProfileASCII:
+		BoxStyle = BoxStyle.Border(asciiBorder)
+	}
+}
 
 func GetTerminalWidth() int {
 	width, _, err := term.GetSize(int(os.Stdout.Fd()))
@@ -38,9 +56,14 @@ func GetTerminalWidth() int {
 	return width
 }
 
+// Separator returns a full-width rule, or "" in accessible mode where a
+// screen reader would read out every character.
 func Separator() string {
+	if current.Accessible {
+		return ""
+	}
 	width := GetTerminalWidth()
-	return SeparatorStyle.Render(strings.Repeat("━", width))
+	return SeparatorStyle.Render(strings.Repeat(separatorGlyph(), width))
 }
 
 func Header(text string) string {
@@ -48,17 +71,17 @@ func Header(text string) string {
 }
 
 func Success(text string) string {
-	return SuccessStyle.Render(fmt.Sprintf("✓ %s", text))
+	return SuccessStyle.Render(OKf("%s", text))
 }
 
 func CodeBlockBox(title, code string) string {
 	styledCode := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#c0caf5")). // Tokyo Night FG
+		Foreground(current.Theme.Text).
 		Render(code)
 
 	titleStyle := lipgloss.NewStyle().
 		Bold(true).
-		Foreground(lipgloss.Color("#e0af68")) // Tokyo Night Yellow
+		Foreground(current.Theme.Title)
 
 	content := fmt.Sprintf("%s\n\n%s", titleStyle.Render(title), styledCode)
 	return BoxStyle.Render(content)
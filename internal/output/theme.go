@@ -0,0 +1,210 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Profile controls which glyphs are used for status markers, separators and
+// boxes.
+type Profile string
+
+const (
+	ProfileEmoji Profile = "emoji" // emoji markers and box drawing
+	ProfileASCII Profile = "ascii" // [OK]/[FAIL] tags and ASCII lines
+	ProfilePlain Profile = "plain" // no markers, no boxes
+)
+
+// Theme is a color palette
+type Theme struct {
+	Name      string
+	Header    lipgloss.Color
+	Separator lipgloss.Color
+	Success   lipgloss.Color
+	Error     lipgloss.Color
+	Warning   lipgloss.Color
+	Accent    lipgloss.Color
+	Title     lipgloss.Color
+	Text      lipgloss.Color
+}
+
+// Themes are the built-in color palettes
+var Themes = map[string]Theme{
+	"tokyonight": {
+		Name:      "tokyonight",
+		Header:    "#7aa2f7",
+		Separator: "#3b4261",
+		Success:   "#9ece6a",
+		Error:     "#f7768e",
+		Warning:   "#e0af68",
+		Accent:    "#7dcfff",
+		Title:     "#e0af68",
+		Text:      "#c0caf5",
+	},
+	"solarized": {
+		Name:      "solarized",
+		Header:    "#268bd2",
+		Separator: "#586e75",
+		Success:   "#859900",
+		Error:     "#dc322f",
+		Warning:   "#b58900",
+		Accent:    "#2aa198",
+		Title:     "#cb4b16",
+		Text:      "#839496",
+	},
+	"gruvbox": {
+		Name:      "gruvbox",
+		Header:    "#83a598",
+		Separator: "#504945",
+		Success:   "#b8bb26",
+		Error:     "#fb4934",
+		Warning:   "#fabd2f",
+		Accent:    "#8ec07c",
+		Title:     "#fe8019",
+		Text:      "#ebdbb2",
+	},
+}
+
+// DefaultTheme is used when no theme or an unknown theme is configured
+const DefaultTheme = "tokyonight"
+
+// Style is the active output style
+type Style struct {
+	Profile Profile
+	Color   bool
+	Theme   Theme
+	// Accessible replaces spinners and redrawn status lines with periodic
+	// plain progress lines, for screen readers and dumb terminals
+	Accessible bool
+}
+
+var current = Style{Profile: ProfileEmoji, Color: true, Theme: Themes[DefaultTheme]}
+
+// Current returns the active output style.
+func Current() Style {
+	return current
+}
+
+// Configure selects the output style. profile and theme come from the
+// --style flag or setup.yaml and may be empty. GPTCODE_OUTPUT_STYLE
+// overrides a configured profile, NO_COLOR and TERM=dumb disable colors, and
+// TERM=dumb or CI default to the ascii profile. TERM=dumb and
+// GPTCODE_ACCESSIBLE=1 also turn on accessible progress output.
+func Configure(profile, theme string) error {
+	style := Style{Profile: ProfileEmoji, Color: true, Theme: Themes[DefaultTheme]}
+
+	if env := os.Getenv("GPTCODE_OUTPUT_STYLE"); env != "" && profile == "" {
+		profile = env
+	}
+	if profile == "" && (os.Getenv("TERM") == "dumb" || os.Getenv("CI") != "") {
+		profile = string(ProfileASCII)
+	}
+
+	switch p := Profile(strings.ToLower(profile)); p {
+	case "":
+	case ProfileEmoji, ProfileASCII, ProfilePlain:
+		style.Profile = p
+	default:
+		return fmt.Errorf("unknown output style %q (use emoji, ascii or plain)", profile)
+	}
+
+	if theme != "" {
+		t, ok := Themes[strings.ToLower(theme)]
+		if !ok {
+			return fmt.Errorf("unknown theme %q", theme)
+		}
+		style.Theme = t
+	}
+
+	if _, ok := os.LookupEnv("NO_COLOR"); ok || os.Getenv("TERM") == "dumb" || style.Profile == ProfilePlain {
+		style.Color = false
+	}
+	style.Accessible = os.Getenv("TERM") == "dumb" || os.Getenv("GPTCODE_ACCESSIBLE") == "1"
+
+	apply(style)
+	return nil
+}
+
+// SetAccessible turns accessible progress output on or off.
+func SetAccessible(on bool) {
+	current.Accessible = on
+}
+
+// Accessible reports whether accessible progress output is on.
+func Accessible() bool {
+	return current.Accessible
+}
+
+func apply(style Style) {
+	current = style
+	if style.Color {
+		lipgloss.SetColorProfile(termenv.EnvColorProfile())
+	} else {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	rebuildStyles()
+}
+
+// Marker kinds for Icon
+const (
+	MarkOK   = "ok"
+	MarkFail = "fail"
+	MarkWarn = "warn"
+	MarkInfo = "info"
+)
+
+var markers = map[Profile]map[string]string{
+	ProfileEmoji: {MarkOK: "✓", MarkFail: "✗", MarkWarn: "⚠️", MarkInfo: "ℹ️"},
+	ProfileASCII: {MarkOK: "[OK]", MarkFail: "[FAIL]", MarkWarn: "[WARN]", MarkInfo: "[INFO]"},
+	ProfilePlain: {},
+}
+
+// Icon returns the status marker for kind in the active profile, or "" for
+// the plain profile.
+func Icon(kind string) string {
+	return markers[current.Profile][kind]
+}
+
+func mark(kind, text string) string {
+	if icon := Icon(kind); icon != "" {
+		return icon + " " + text
+	}
+	return text
+}
+
+// OKf formats a success message with the active profile's marker.
+func OKf(format string, args ...any) string {
+	return mark(MarkOK, fmt.Sprintf(format, args...))
+}
+
+// Failf formats a failure message with the active profile's marker.
+func Failf(format string, args ...any) string {
+	return mark(MarkFail, fmt.Sprintf(format, args...))
+}
+
+// Warnf formats a warning with the active profile's marker.
+func Warnf(format string, args ...any) string {
+	return mark(MarkWarn, fmt.Sprintf(format, args...))
+}
+
+// Infof formats an informational message with the active profile's marker.
+func Infof(format string, args ...any) string {
+	return mark(MarkInfo, fmt.Sprintf(format, args...))
+}
+
+// asciiBorder is used for boxes in the ascii profile
+var asciiBorder = lipgloss.Border{
+	Top: "-", Bottom: "-", Left: "|", Right: "|",
+	TopLeft: "+", TopRight: "+", BottomLeft: "+", BottomRight: "+",
+}
+
+func separatorGlyph() string {
+	if current.Profile == ProfileEmoji {
+		return "━"
+	}
+	return "-"
+}
@@ -0,0 +1,66 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigureProfiles(t *testing.T) {
+	t.Setenv("GPTCODE_OUTPUT_STYLE", "")
+	t.Setenv("CI", "")
+	t.Setenv("TERM", "xterm-256color")
+	t.Cleanup(func() { apply(Style{Profile: ProfileEmoji, Color: true, Theme: Themes[DefaultTheme]}) })
+
+	tests := []struct {
+		profile string
+		want    string
+	}{
+		{"emoji", "✓ done"},
+		{"ascii", "[OK] done"},
+		{"plain", "done"},
+	}
+	for _, tt := range tests {
+		if err := Configure(tt.profile, ""); err != nil {
+			t.Fatalf("Configure(%q): %v", tt.profile, err)
+		}
+		if got := OKf("done"); got != tt.want {
+			t.Errorf("%s: OKf = %q, want %q", tt.profile, got, tt.want)
+		}
+	}
+
+	if err := Configure("ascii", ""); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(Separator(), "━") {
+		t.Error("ascii separator should not use box drawing characters")
+	}
+}
+
+func TestConfigureEnvironment(t *testing.T) {
+	t.Setenv("GPTCODE_OUTPUT_STYLE", "")
+	t.Setenv("TERM", "xterm-256color")
+	t.Setenv("CI", "true")
+	t.Setenv("NO_COLOR", "1")
+	t.Cleanup(func() { apply(Style{Profile: ProfileEmoji, Color: true, Theme: Themes[DefaultTheme]}) })
+
+	if err := Configure("", "gruvbox"); err != nil {
+		t.Fatal(err)
+	}
+	style := Current()
+	if style.Profile != ProfileASCII {
+		t.Errorf("CI should default to ascii, got %s", style.Profile)
+	}
+	if style.Color {
+		t.Error("NO_COLOR should disable colors")
+	}
+	if style.Theme.Name != "gruvbox" {
+		t.Errorf("theme = %s, want gruvbox", style.Theme.Name)
+	}
+
+	if err := Configure("fancy", ""); err == nil {
+		t.Error("expected error for unknown style")
+	}
+	if err := Configure("", "neon"); err == nil {
+		t.Error("expected error for unknown theme")
+	}
+}
@@ -0,0 +1,77 @@
+// Package perf runs Go benchmarks, keeps their results per commit in
+// .gptcode/bench/ and compares them with a baseline to catch regressions.
+package perf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"gptcode/internal/httpclient"
+)
+
+// Samples are the values a benchmark measured for each unit, one per run,
+// e.g. "ns/op": [1052, 1040, 1061]
+type Samples map[string][]float64
+
+// ParseBench reads the results of go test -bench output. Each benchmark is
+// named after its package, as in "gptcode/internal/graph.BenchmarkBuild-8",
+// and gets one sample per unit for each line -count printed.
+func ParseBench(output string) map[string]Samples {
+	results := map[string]Samples{}
+	pkg := ""
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if p, ok := strings.CutPrefix(line, "pkg: "); ok {
+			pkg = strings.TrimSpace(p)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := fields[0]
+		if pkg != "" {
+			name = pkg + "." + name
+		}
+		// Then value/unit pairs: 1052 ns/op 256 B/op 4 allocs/op
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				break
+			}
+			if results[name] == nil {
+				results[name] = Samples{}
+			}
+			results[name][fields[i+1]] = append(results[name][fields[i+1]], v)
+		}
+	}
+	return results
+}
+
+// RunBench runs the benchmarks of pkg in dir matching pattern, count times
+// each, with memory statistics, and returns their output
+func RunBench(dir, pkg, pattern string, count int) (string, error) {
+	if pattern == "" {
+		pattern = "."
+	}
+	args := []string{"test", "-run", "^$", "-bench", pattern, "-benchmem", "-count", strconv.Itoa(count), pkg}
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = httpclient.CommandEnv()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return out.String(), fmt.Errorf("benchmarks failed: %w", err)
+	}
+	return out.String(), nil
+}
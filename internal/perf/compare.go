@@ -0,0 +1,232 @@
+package perf
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Alpha is the significance level below which a change is taken as real
+// rather than noise, as benchstat uses
+const Alpha = 0.05
+
+// Delta is how one unit of one benchmark changed from the baseline
+type Delta struct {
+	Benchmark string
+	Unit      string
+	// Base and Head are the medians of the samples
+	Base, Head float64
+	// Percent is the change of the median, positive when it grew
+	Percent float64
+	// P is the p-value of the Mann-Whitney U test between the samples
+	P float64
+	// Significant is set when P is below Alpha
+	Significant bool
+	// Regression is set when the change is significant and worse than the
+	// threshold. Every unit go test reports is better lower.
+	Regression bool
+}
+
+// Comparison is how a run compares with its baseline
+type Comparison struct {
+	Base, Head string
+	Threshold  float64
+	Deltas     []Delta
+}
+
+// Compare compares head's benchmarks with base's, unit by unit, flagging
+// significant changes that grew by more than threshold percent. Benchmarks
+// only one of the runs has are left out.
+func Compare(base, head *Run, threshold float64) *Comparison {
+	c := &Comparison{Base: base.Commit, Head: head.Commit, Threshold: threshold}
+	for name, samples := range head.Benchmarks {
+		baseSamples, ok := base.Benchmarks[name]
+		if !ok {
+			continue
+		}
+		for unit, values := range samples {
+			old := baseSamples[unit]
+			if len(old) == 0 || len(values) == 0 {
+				continue
+			}
+			d := Delta{Benchmark: name, Unit: unit, Base: median(old), Head: median(values)}
+			if d.Base != 0 {
+				d.Percent = 100 * (d.Head - d.Base) / d.Base
+			} else if d.Head != 0 {
+				d.Percent = math.Inf(1)
+			}
+			d.P = MannWhitneyU(old, values)
+			d.Significant = d.P < Alpha
+			d.Regression = d.Significant && d.Percent > threshold
+			c.Deltas = append(c.Deltas, d)
+		}
+	}
+	sort.Slice(c.Deltas, func(i, j int) bool {
+		if c.Deltas[i].Benchmark != c.Deltas[j].Benchmark {
+			return c.Deltas[i].Benchmark < c.Deltas[j].Benchmark
+		}
+		return c.Deltas[i].Unit < c.Deltas[j].Unit
+	})
+	return c
+}
+
+// Regressions returns the deltas flagged as regressions
+func (c *Comparison) Regressions() []Delta {
+	var regressions []Delta
+	for _, d := range c.Deltas {
+		if d.Regression {
+			regressions = append(regressions, d)
+		}
+	}
+	return regressions
+}
+
+// Report renders the comparison as a table in the style of benchstat:
+// "~" marks changes that are not significant.
+func (c *Comparison) Report() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Baseline %s vs %s (regression threshold %.1f%%, alpha %.2f)\n\n", short(c.Base), short(c.Head), c.Threshold, Alpha)
+	if len(c.Deltas) == 0 {
+		b.WriteString("No benchmarks in common with the baseline.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%-50s %-10s %14s %14s %10s %8s\n", "benchmark", "unit", "base", "head", "delta", "p")
+	for _, d := range c.Deltas {
+		delta := "~"
+		if d.Significant {
+			delta = fmt.Sprintf("%+.2f%%", d.Percent)
+		}
+		mark := ""
+		if d.Regression {
+			mark = "  REGRESSION"
+		}
+		fmt.Fprintf(&b, "%-50s %-10s %14s %14s %10s %8.3f%s\n",
+			d.Benchmark, d.Unit, formatValue(d.Base), formatValue(d.Head), delta, d.P, mark)
+	}
+	return b.String()
+}
+
+func formatValue(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.4g", v)
+}
+
+func short(commit string) string {
+	return commit[:min(12, len(commit))]
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// MannWhitneyU returns the two-sided p-value of the Mann-Whitney U test of
+// whether x and y come from the same distribution. Small samples without
+// ties get the exact p-value; others the normal approximation with a tie
+// correction.
+func MannWhitneyU(x, y []float64) float64 {
+	n1, n2 := len(x), len(y)
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type sample struct {
+		v     float64
+		first bool
+	}
+	all := make([]sample, 0, n1+n2)
+	for _, v := range x {
+		all = append(all, sample{v, true})
+	}
+	for _, v := range y {
+		all = append(all, sample{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Rank with ties sharing their average rank
+	r1 := 0.0
+	tieTerm := 0.0
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].first {
+				r1 += rank
+			}
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieTerm += t*t*t - t
+		}
+		i = j
+	}
+	u1 := r1 - float64(n1*(n1+1))/2
+	u := math.Min(u1, float64(n1*n2)-u1)
+
+	if !ties && n1 <= 50 && n2 <= 50 {
+		return math.Min(1, 2*exactUCDF(n1, n2, int(u)))
+	}
+
+	n := float64(n1 + n2)
+	mean := float64(n1*n2) / 2
+	variance := float64(n1*n2) / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	// Continuity correction
+	z := (math.Abs(u1-mean) - 0.5) / math.Sqrt(variance)
+	if z < 0 {
+		z = 0
+	}
+	return math.Min(1, math.Erfc(z/math.Sqrt2))
+}
+
+// exactUCDF is P(U <= u) for samples of n1 and n2 values without ties,
+// counting the orderings that give each U
+func exactUCDF(n1, n2, u int) float64 {
+	// counts[j][k] is the number of orderings of j values of the first
+	// sample and the current number of the second giving U = k
+	maxU := n1 * n2
+	prev := make([][]float64, n1+1)
+	for j := range prev {
+		prev[j] = make([]float64, maxU+1)
+		prev[j][0] = 1
+	}
+	for m := 1; m <= n2; m++ {
+		cur := make([][]float64, n1+1)
+		cur[0] = make([]float64, maxU+1)
+		cur[0][0] = 1
+		for j := 1; j <= n1; j++ {
+			cur[j] = make([]float64, maxU+1)
+			for k := 0; k <= j*m; k++ {
+				// The largest value is from the second sample, adding
+				// nothing to U, or from the first, beating all m
+				cur[j][k] = prev[j][k]
+				if k >= m {
+					cur[j][k] += cur[j-1][k-m]
+				}
+			}
+		}
+		prev = cur
+	}
+	total, below := 0.0, 0.0
+	for k, c := range prev[n1] {
+		total += c
+		if k <= u {
+			below += c
+		}
+	}
+	return below / total
+}
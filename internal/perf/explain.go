@@ -0,0 +1,61 @@
+package perf
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gptcode/internal/llm"
+)
+
+// maxDiff caps the diff sent along with the regressions
+const maxDiff = 30000
+
+// Diff returns the changes from base to the working tree of dir, leaving
+// out benchmark results, cut to a size a model can read
+func Diff(dir, base string) (string, error) {
+	diff, err := git(dir, "diff", base, "--", ".", ":(exclude).gptcode/bench")
+	if err != nil {
+		return "", err
+	}
+	if len(diff) > maxDiff {
+		diff = diff[:maxDiff] + "\n... (diff truncated)"
+	}
+	return diff, nil
+}
+
+// Explain asks the model which changes of diff most likely caused the
+// comparison's regressions, and how to fix them
+func Explain(ctx context.Context, provider llm.Provider, model string, c *Comparison, diff string) (string, error) {
+	var regressions []string
+	for _, d := range c.Regressions() {
+		regressions = append(regressions, fmt.Sprintf("- %s %s: %s -> %s (%+.1f%%, p=%.3f)",
+			d.Benchmark, d.Unit, formatValue(d.Base), formatValue(d.Head), d.Percent, d.P))
+	}
+	if len(regressions) == 0 {
+		return "", nil
+	}
+
+	prompt := fmt.Sprintf(`These Go benchmarks regressed between commit %s and the current code:
+
+%s
+
+The changes since %s:
+
+%s
+
+Explain which of these changes most likely caused each regression, and
+why (extra allocations, copies, lock contention, algorithmic cost...).
+Suggest a fix for each. Be brief and refer to files and functions.`,
+		short(c.Base), strings.Join(regressions, "\n"), short(c.Base), diff)
+
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are a Go performance engineer who explains benchmark regressions from code diffs.",
+		UserPrompt:   prompt,
+		Model:        model,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
@@ -0,0 +1,149 @@
+package perf
+
+import (
+	"errors"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const benchOutput = `goos: linux
+goarch: amd64
+pkg: example.com/demo/graph
+cpu: AMD EPYC
+BenchmarkBuild-8   	    1000	   1052 ns/op	     256 B/op	       4 allocs/op
+BenchmarkBuild-8   	    1000	   1040 ns/op	     256 B/op	       4 allocs/op
+BenchmarkRank/small-8	  500000	     2.5 ns/op
+PASS
+ok  	example.com/demo/graph	2.1s
+pkg: example.com/demo/index
+BenchmarkSearch-8  	     200	  600000 ns/op
+--- FAIL: BenchmarkBroken
+PASS
+`
+
+func TestParseBench(t *testing.T) {
+	got := ParseBench(benchOutput)
+	want := map[string]Samples{
+		"example.com/demo/graph.BenchmarkBuild-8": {
+			"ns/op":     {1052, 1040},
+			"B/op":      {256, 256},
+			"allocs/op": {4, 4},
+		},
+		"example.com/demo/graph.BenchmarkRank/small-8": {"ns/op": {2.5}},
+		"example.com/demo/index.BenchmarkSearch-8":     {"ns/op": {600000}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected results:\n%+v", got)
+	}
+}
+
+func TestMannWhitneyU(t *testing.T) {
+	// Fully separated samples of 5: 2 of the 252 orderings are as extreme
+	if p := MannWhitneyU([]float64{1, 2, 3, 4, 5}, []float64{6, 7, 8, 9, 10}); math.Abs(p-2.0/252) > 1e-9 {
+		t.Errorf("expected the exact p-value 2/252, got %v", p)
+	}
+	if p := MannWhitneyU([]float64{1, 3, 5, 7, 9}, []float64{2, 4, 6, 8, 10}); p < 0.5 {
+		t.Errorf("interleaved samples should not differ, got p=%v", p)
+	}
+	// Ties use the normal approximation
+	if p := MannWhitneyU([]float64{5, 5, 5, 5, 5}, []float64{5, 5, 5, 5, 5}); p != 1 {
+		t.Errorf("identical samples should give p=1, got %v", p)
+	}
+	if p := MannWhitneyU([]float64{1, 1, 2, 2, 2, 1, 1, 2}, []float64{9, 9, 8, 8, 9, 8, 9, 8}); p >= Alpha {
+		t.Errorf("separated samples with ties should differ, got p=%v", p)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := &Run{Commit: "aaaa", Benchmarks: map[string]Samples{
+		"p.BenchmarkSlow":  {"ns/op": {100, 101, 99, 100, 102}, "allocs/op": {4, 4, 4, 4, 4}},
+		"p.BenchmarkNoise": {"ns/op": {100, 110, 90, 105, 95}},
+		"p.BenchmarkGone":  {"ns/op": {1}},
+	}}
+	head := &Run{Commit: "bbbb", Benchmarks: map[string]Samples{
+		"p.BenchmarkSlow":  {"ns/op": {120, 121, 119, 122, 118}, "allocs/op": {4, 4, 4, 4, 4}},
+		"p.BenchmarkNoise": {"ns/op": {101, 111, 91, 104, 96}},
+		"p.BenchmarkNew":   {"ns/op": {1}},
+	}}
+	c := Compare(base, head, 5)
+	if len(c.Deltas) != 3 {
+		t.Fatalf("expected the benchmarks in common only, got %+v", c.Deltas)
+	}
+	regressions := c.Regressions()
+	if len(regressions) != 1 || regressions[0].Benchmark != "p.BenchmarkSlow" || regressions[0].Unit != "ns/op" {
+		t.Fatalf("expected BenchmarkSlow ns/op to regress, got %+v", regressions)
+	}
+	if d := regressions[0]; d.Base != 100 || d.Head != 120 || d.Percent != 20 {
+		t.Errorf("unexpected delta %+v", d)
+	}
+	if c := Compare(base, head, 25); len(c.Regressions()) != 0 {
+		t.Error("a change within the threshold should not regress")
+	}
+
+	report := c.Report()
+	if !strings.Contains(report, "+20.00%") || !strings.Contains(report, "REGRESSION") || strings.Count(report, " ~ ") != 2 {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestStoreAndBaseline(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	commit := func(msg string) string {
+		git("commit", "--allow-empty", "-qm", msg)
+		head, _, err := Head(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return head
+	}
+	git("init", "-q")
+	git("config", "user.name", "test")
+	git("config", "user.email", "test@example.com")
+
+	first := commit("first")
+	if _, err := Baseline(dir); !errors.Is(err, ErrNoBaseline) {
+		t.Fatalf("expected no baseline, got %v", err)
+	}
+	run := &Run{Commit: first, Benchmarks: map[string]Samples{"p.BenchmarkA": {"ns/op": {1}}}}
+	if err := run.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, dirty, _ := Head(dir); dirty {
+		t.Error("saved results should not make the tree dirty")
+	}
+	commit("second")
+	head := commit("third")
+	if err := (&Run{Commit: head}).Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	base, err := Baseline(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Commit != first || base.Benchmarks["p.BenchmarkA"]["ns/op"][0] != 1 {
+		t.Errorf("expected the first commit's results, got %+v", base)
+	}
+	if _, err := Load(dir, "HEAD~1"); err == nil || !strings.Contains(err.Error(), "no benchmark results") {
+		t.Errorf("expected no results for the second commit, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, dirty, _ := Head(dir); !dirty {
+		t.Error("expected a dirty tree")
+	}
+}
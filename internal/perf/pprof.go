@@ -0,0 +1,178 @@
+package perf
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gptcode/internal/graph"
+)
+
+// Frame is one source line of a pprof profile, as pprof -top -lines lists
+// them
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	// Flat and Cum are the line's own cost and its cost with its callees,
+	// as pprof prints them (e.g. "40ms", "1.2MB")
+	Flat, Cum               string
+	FlatPercent, CumPercent float64
+}
+
+// ParseTop parses the output of go tool pprof -top -lines
+func ParseTop(output string) []Frame {
+	var frames []Frame
+	header := false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if !header {
+			header = len(fields) >= 5 && fields[0] == "flat" && fields[1] == "flat%"
+			continue
+		}
+		if len(fields) < 7 {
+			continue
+		}
+		flatPct, err1 := strconv.ParseFloat(strings.TrimSuffix(fields[1], "%"), 64)
+		cumPct, err2 := strconv.ParseFloat(strings.TrimSuffix(fields[4], "%"), 64)
+		colon := strings.LastIndex(fields[6], ":")
+		if err1 != nil || err2 != nil || colon < 0 {
+			continue
+		}
+		lineNum, err := strconv.Atoi(fields[6][colon+1:])
+		if err != nil {
+			continue
+		}
+		frames = append(frames, Frame{
+			Function:    fields[5],
+			File:        fields[6][:colon],
+			Line:        lineNum,
+			Flat:        fields[0],
+			Cum:         fields[3],
+			FlatPercent: flatPct,
+			CumPercent:  cumPct,
+		})
+	}
+	return frames
+}
+
+// Top returns the frames of the profile at path, symbolized with binary
+// when it is not empty. sampleIndex picks what a heap profile measures,
+// e.g. alloc_space; it is ignored when empty.
+func Top(path, binary, sampleIndex string) ([]Frame, error) {
+	args := []string{"tool", "pprof", "-top", "-lines", "-nodecount=1000"}
+	if sampleIndex != "" {
+		args = append(args, "-sample_index="+sampleIndex)
+	}
+	if binary != "" {
+		args = append(args, binary)
+	}
+	args = append(args, path)
+	cmd := exec.Command("go", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go tool pprof %s: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return ParseTop(string(out)), nil
+}
+
+// Hotspot is a function of the project a profile spends much of its time
+// or memory in
+type Hotspot struct {
+	Function string
+	// File is relative to the project root
+	File string
+	// Lines are the function's lines in the profile, the costliest first
+	Lines []int
+	// FlatPercent and CumPercent add up the function's lines, CumPercent
+	// up to 100
+	FlatPercent, CumPercent float64
+	// Related are the files next to File in the dependency graph, the
+	// most important first
+	Related []string
+}
+
+// Hotspots groups the frames of the project at root by function, leaving
+// out the standard library, dependencies and tests, and returns the limit
+// costliest by cumulative cost. With g, each hotspot lists up to three
+// files it imports or is imported by, by PageRank.
+func Hotspots(frames []Frame, root string, g *graph.Graph, limit int) []Hotspot {
+	byFunc := map[string]*Hotspot{}
+	var order []string
+	for _, f := range frames {
+		rel, err := filepath.Rel(root, f.File)
+		if err != nil || strings.HasPrefix(rel, "..") || filepath.IsAbs(rel) || strings.HasSuffix(rel, "_test.go") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(rel, "vendor/") {
+			continue
+		}
+		h, ok := byFunc[f.Function]
+		if !ok {
+			h = &Hotspot{Function: f.Function, File: rel}
+			byFunc[f.Function] = h
+			order = append(order, f.Function)
+		}
+		// Frames come costliest first
+		h.Lines = append(h.Lines, f.Line)
+		h.FlatPercent += f.FlatPercent
+		// A line's cost with its callees may include another line's
+		h.CumPercent = min(100, h.CumPercent+f.CumPercent)
+	}
+
+	hotspots := make([]Hotspot, 0, len(order))
+	for _, name := range order {
+		hotspots = append(hotspots, *byFunc[name])
+	}
+	sort.SliceStable(hotspots, func(i, j int) bool {
+		if hotspots[i].CumPercent != hotspots[j].CumPercent {
+			return hotspots[i].CumPercent > hotspots[j].CumPercent
+		}
+		return hotspots[i].FlatPercent > hotspots[j].FlatPercent
+	})
+	if len(hotspots) > limit {
+		hotspots = hotspots[:limit]
+	}
+	if g != nil {
+		for i := range hotspots {
+			hotspots[i].Related = related(g, hotspots[i].File, 3)
+		}
+	}
+	return hotspots
+}
+
+// related returns up to n files file imports or is imported by, by score
+func related(g *graph.Graph, file string, n int) []string {
+	id, ok := g.Paths[file]
+	if !ok {
+		return nil
+	}
+	seen := map[string]bool{file: true}
+	var files []*graph.Node
+	for _, other := range append(append([]int64(nil), g.OutEdges[id]...), g.InEdges[id]...) {
+		node := g.Nodes[other]
+		if node.Type == "symbol" || seen[node.Path] {
+			continue
+		}
+		seen[node.Path] = true
+		files = append(files, node)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].Score != files[j].Score {
+			return files[i].Score > files[j].Score
+		}
+		return files[i].Path < files[j].Path
+	})
+	var paths []string
+	for _, node := range files[:min(n, len(files))] {
+		paths = append(paths, node.Path)
+	}
+	return paths
+}
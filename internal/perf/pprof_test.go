@@ -0,0 +1,102 @@
+package perf
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gptcode/internal/graph"
+	"gptcode/internal/llm"
+)
+
+const topOutput = `File: pp.test
+Type: alloc_space
+Showing nodes accounting for 1865.73MB, 99.91% of 1867.45MB total
+      flat  flat%   sum%        cum   cum%
+ 1812.23MB 97.04% 97.04%  1865.73MB 99.91%  example.com/pp.Join /src/pp/a.go:5
+   53.50MB  2.86% 99.91%    53.50MB  2.86%  internal/bytealg.MakeNoZero /usr/local/go/src/runtime/slice.go:437
+      40ms  2.29% 12.00%       40ms  2.29%  example.com/pp.Join /src/pp/a.go:4 (inline)
+      10ms  0.57% 12.57%      900ms 51.43%  example.com/pp.Run /src/pp/run.go:9
+       5ms  0.29% 12.86%        5ms  0.29%  example.com/pp.BenchmarkJoin /src/pp/a_test.go:7
+`
+
+func TestParseTop(t *testing.T) {
+	frames := ParseTop(topOutput)
+	if len(frames) != 5 {
+		t.Fatalf("expected 5 frames, got %+v", frames)
+	}
+	want := Frame{Function: "example.com/pp.Join", File: "/src/pp/a.go", Line: 5, Flat: "1812.23MB", Cum: "1865.73MB", FlatPercent: 97.04, CumPercent: 99.91}
+	if frames[0] != want {
+		t.Errorf("unexpected frame %+v", frames[0])
+	}
+	if frames[2].Line != 4 || frames[2].Function != "example.com/pp.Join" {
+		t.Errorf("expected the inline frame parsed, got %+v", frames[2])
+	}
+}
+
+func TestHotspots(t *testing.T) {
+	frames := ParseTop(topOutput)
+	g := graph.NewGraph()
+	g.AddEdge("run.go", "a.go")
+	g.AddEdge("main.go", "run.go")
+	g.AddEdge("util.go", "run.go")
+	g.AddNode("run.go#Run", "symbol")
+	g.AddEdge("run.go#Run", "run.go")
+	g.PageRank(0.85, 20)
+
+	hotspots := Hotspots(frames, "/src/pp", g, 5)
+	if len(hotspots) != 2 {
+		t.Fatalf("expected Join and Run, without the runtime and tests, got %+v", hotspots)
+	}
+	join, run := hotspots[0], hotspots[1]
+	if join.Function != "example.com/pp.Join" || join.File != "a.go" || !reflect.DeepEqual(join.Lines, []int{5, 4}) {
+		t.Errorf("unexpected hotspot %+v", join)
+	}
+	if math.Abs(join.FlatPercent-(97.04+2.29)) > 1e-9 {
+		t.Errorf("expected the lines' costs added, got %v", join.FlatPercent)
+	}
+	if !reflect.DeepEqual(join.Related, []string{"run.go"}) {
+		t.Errorf("expected run.go related to a.go, got %v", join.Related)
+	}
+	if len(run.Related) != 3 || run.Related[0] != "a.go" {
+		t.Errorf("expected the files around run.go by PageRank, got %v", run.Related)
+	}
+	if len(Hotspots(frames, "/src/pp", nil, 1)) != 1 {
+		t.Error("expected the hotspots cut to the limit")
+	}
+}
+
+type patchProvider struct {
+	prompt string
+}
+
+func (p *patchProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.prompt = req.UserPrompt
+	return &llm.ChatResponse{Text: "Preallocate the builder.\n\n```diff\n--- a/a.go\n+++ b/a.go\n@@ -3,3 +3,3 @@\n func Join(n int) string {\n-\ts := \"\"\n+\tvar s strings.Builder\n \treturn s\n```\n\nAnd a broken one:\n```diff\nnot a diff\n```\n"}, nil
+}
+
+func TestSuggest(t *testing.T) {
+	root := t.TempDir()
+	src := "package pp\n\nfunc Join(n int) string {\n\ts := \"\"\n\treturn s\n}\n"
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	provider := &patchProvider{}
+	s, err := Suggest(context.Background(), provider, "m", root, "heap allocations",
+		[]Hotspot{{Function: "example.com/pp.Join", File: "a.go", Lines: []int{4}, FlatPercent: 97, CumPercent: 99, Related: []string{"run.go"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"heap allocations", "Related files: run.go", "   3  func Join(n int) string {", "   6  }"} {
+		if !strings.Contains(provider.prompt, want) {
+			t.Errorf("expected %q in the prompt:\n%s", want, provider.prompt)
+		}
+	}
+	if !strings.HasPrefix(s.Diff, "--- a/a.go\n") || strings.Contains(s.Diff, "not a diff") {
+		t.Errorf("expected only the valid diff, got:\n%s", s.Diff)
+	}
+}
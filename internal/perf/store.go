@@ -0,0 +1,119 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Run is the benchmark results of one commit
+type Run struct {
+	Commit string `json:"commit"`
+	// Dirty is set when the working tree had changes besides the results
+	Dirty      bool               `json:"dirty,omitempty"`
+	Time       time.Time          `json:"time"`
+	Benchmarks map[string]Samples `json:"benchmarks"`
+}
+
+// ErrNoBaseline is returned by Baseline when no earlier commit has results
+var ErrNoBaseline = errors.New("no benchmark results for an earlier commit; run gptcode perf bench on the baseline first")
+
+// Dir is where dir's benchmark results are kept, one file per commit
+func Dir(dir string) string {
+	return filepath.Join(dir, ".gptcode", "bench")
+}
+
+// Save writes the run to dir's results as <commit>.json, replacing the
+// commit's earlier results
+func (r *Run) Save(dir string) error {
+	if err := os.MkdirAll(Dir(dir), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(Dir(dir), r.Commit+".json"), append(data, '\n'), 0644)
+}
+
+// Load reads the results of the commit ref names
+func Load(dir, ref string) (*Run, error) {
+	commit, err := git(dir, "rev-parse", "--verify", ref+"^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(Dir(dir), commit+".json")
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no benchmark results for %s (%s); check it out and run gptcode perf bench", ref, commit[:min(12, len(commit))])
+	}
+	if err != nil {
+		return nil, err
+	}
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+// Baseline returns the results of the nearest ancestor of HEAD that has
+// some, HEAD itself excluded
+func Baseline(dir string) (*Run, error) {
+	entries, err := os.ReadDir(Dir(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoBaseline
+	}
+	if err != nil {
+		return nil, err
+	}
+	stored := map[string]bool{}
+	for _, e := range entries {
+		if commit, ok := strings.CutSuffix(e.Name(), ".json"); ok {
+			stored[commit] = true
+		}
+	}
+	out, err := git(dir, "rev-list", "HEAD~1")
+	if err != nil {
+		// A repository with a single commit has no ancestors
+		return nil, ErrNoBaseline
+	}
+	for _, commit := range strings.Fields(out) {
+		if stored[commit] {
+			return Load(dir, commit)
+		}
+	}
+	return nil, ErrNoBaseline
+}
+
+// Head returns the commit checked out in dir, and whether the working tree
+// has changes other than benchmark results
+func Head(dir string) (commit string, dirty bool, err error) {
+	commit, err = git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return "", false, err
+	}
+	status, err := git(dir, "status", "--porcelain", "--", ".", ":(exclude).gptcode/bench")
+	if err != nil {
+		return "", false, err
+	}
+	return commit, status != "", nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,119 @@
+package perf
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/tools"
+)
+
+// Suggestion is the model's advice on a profile's hotspots: its reasoning,
+// and the patches it proposes as a unified diff
+type Suggestion struct {
+	Text string
+	Diff string
+}
+
+var diffBlock = regexp.MustCompile("(?s)```(?:diff|patch)?\\s*\\n(.*?)```")
+
+// Suggest shows the model the source of each hotspot and asks for concrete
+// optimizations as unified diffs. kind says what the profile measured,
+// e.g. "CPU" or "heap allocations". Diffs that do not parse are dropped.
+func Suggest(ctx context.Context, provider llm.Provider, model, root, kind string, hotspots []Hotspot) (*Suggestion, error) {
+	if len(hotspots) == 0 {
+		return &Suggestion{}, nil
+	}
+	var b strings.Builder
+	for i, h := range hotspots {
+		fmt.Fprintf(&b, "### %d. %s (%s:%d) — %.1f%% flat, %.1f%% cum\n", i+1, h.Function, h.File, h.Lines[0], h.FlatPercent, h.CumPercent)
+		fmt.Fprintf(&b, "Hot lines: %s\n", joinInts(h.Lines, 5))
+		if len(h.Related) > 0 {
+			fmt.Fprintf(&b, "Related files: %s\n", strings.Join(h.Related, ", "))
+		}
+		if src := functionSource(filepath.Join(root, h.File), h.Lines[0]); src != "" {
+			fmt.Fprintf(&b, "```go\n%s\n```\n", src)
+		}
+		b.WriteString("\n")
+	}
+
+	prompt := fmt.Sprintf(`A %s profile of this Go project spends most of its cost in these
+functions, with line numbers from the file:
+
+%s
+For the hotspots worth changing, propose concrete optimizations: fewer
+allocations, preallocated slices and maps, strings.Builder, avoiding
+repeated work, better algorithms. Keep behavior the same.
+
+For each, explain the change in one or two sentences, then give it as a
+unified diff against the file (--- a/path, +++ b/path, @@ hunks with three
+lines of context) in a single `+"```diff"+` block for all files.`, kind, b.String())
+
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You are a Go performance engineer who turns profiles into small, safe patches.",
+		UserPrompt:   prompt,
+		Model:        model,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Suggestion{Text: strings.TrimSpace(resp.Text)}
+	var diffs []string
+	for _, m := range diffBlock.FindAllStringSubmatch(resp.Text, -1) {
+		if strings.Contains(m[1], "@@") {
+			if _, err := tools.ParseUnifiedDiff(m[1]); err == nil {
+				diffs = append(diffs, strings.TrimRight(m[1], "\n")+"\n")
+			}
+		}
+	}
+	s.Diff = strings.Join(diffs, "")
+	return s, nil
+}
+
+// functionSource returns the function of path containing line, with line
+// numbers, or the lines around it when the file does not parse
+func functionSource(path string, line int) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(data), "\n")
+	start, end := max(1, line-15), min(len(lines), line+15)
+
+	fset := token.NewFileSet()
+	if f, err := parser.ParseFile(fset, path, data, 0); err == nil {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			from, to := fset.Position(fn.Pos()).Line, fset.Position(fn.End()).Line
+			if from <= line && line <= to {
+				start, end = from, min(to, from+120)
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "%4d  %s\n", i, lines[i-1])
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func joinInts(values []int, limit int) string {
+	var parts []string
+	for _, v := range values[:min(limit, len(values))] {
+		parts = append(parts, fmt.Sprint(v))
+	}
+	return strings.Join(parts, ", ")
+}
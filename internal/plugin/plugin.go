@@ -0,0 +1,131 @@
+// Package plugin discovers and runs external subcommands. Any executable
+// named gptcode-<name> on PATH is exposed as `gptcode <name>`, the same way
+// git handles git-<name>.
+package plugin
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Prefix is the executable name prefix that marks a plugin
+const Prefix = "gptcode-"
+
+// Plugin is an external subcommand found on PATH
+type Plugin struct {
+	Name string // subcommand name, without the prefix
+	Path string // absolute path of the executable
+}
+
+// Context is passed to plugins as JSON in GPTCODE_CONTEXT. The most used
+// fields are also exported as individual GPTCODE_* variables.
+type Context struct {
+	Name      string   `json:"name"`
+	Args      []string `json:"args"`
+	WorkDir   string   `json:"workdir"`
+	ConfigDir string   `json:"config_dir"`
+	Backend   string   `json:"backend,omitempty"`
+	Profile   string   `json:"profile,omitempty"`
+	Model     string   `json:"model,omitempty"`
+	Language  string   `json:"language,omitempty"`
+}
+
+// Discover returns the plugins on PATH, sorted by name. When two
+// directories provide the same plugin, the first one on PATH wins.
+func Discover() []Plugin {
+	return discoverIn(filepath.SplitList(os.Getenv("PATH")))
+}
+
+func discoverIn(dirs []string) []Plugin {
+	seen := make(map[string]bool)
+	var plugins []Plugin
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || seen[name] || entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, Plugin{Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// Find returns the plugin providing the named subcommand.
+func Find(name string) (Plugin, bool) {
+	for _, p := range Discover() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Plugin{}, false
+}
+
+func pluginName(file string) (string, bool) {
+	if !strings.HasPrefix(file, Prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, Prefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name, name != ""
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(path))
+		return ext == ".exe" || ext == ".bat" || ext == ".cmd"
+	}
+	return info.Mode()&0o111 != 0
+}
+
+// Env returns the environment variables describing ctx.
+func (ctx Context) Env() []string {
+	data, _ := json.Marshal(ctx)
+	return []string{
+		"GPTCODE_PLUGIN=" + ctx.Name,
+		"GPTCODE_CONTEXT=" + string(data),
+		"GPTCODE_WORKDIR=" + ctx.WorkDir,
+		"GPTCODE_CONFIG_DIR=" + ctx.ConfigDir,
+		"GPTCODE_BACKEND=" + ctx.Backend,
+		"GPTCODE_PROFILE=" + ctx.Profile,
+		"GPTCODE_MODEL=" + ctx.Model,
+		"GPTCODE_LANGUAGE=" + ctx.Language,
+	}
+}
+
+// Run executes the plugin with args, wiring it to the given streams. The
+// plugin's exit status is returned as an *exec.ExitError.
+func (p Plugin) Run(ctx Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd := exec.Command(p.Path, args...)
+	cmd.Dir = ctx.WorkDir
+	cmd.Env = append(os.Environ(), ctx.Env()...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
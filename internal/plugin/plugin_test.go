@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeExecutable(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverIn(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	first, second := t.TempDir(), t.TempDir()
+	writeExecutable(t, first, "gptcode-jira", "#!/bin/sh\n")
+	writeExecutable(t, second, "gptcode-jira", "#!/bin/sh\n")
+	writeExecutable(t, second, "gptcode-deploy", "#!/bin/sh\n")
+	writeExecutable(t, second, "other-tool", "#!/bin/sh\n")
+	if err := os.WriteFile(filepath.Join(second, "gptcode-notes"), []byte("text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plugins := discoverIn([]string{first, "", second, "/does/not/exist"})
+	if len(plugins) != 2 {
+		t.Fatalf("expected 2 plugins, got %+v", plugins)
+	}
+	if plugins[0].Name != "deploy" || plugins[1].Name != "jira" {
+		t.Errorf("unexpected order: %+v", plugins)
+	}
+	if plugins[1].Path != filepath.Join(first, "gptcode-jira") {
+		t.Errorf("first directory on PATH should win, got %s", plugins[1].Path)
+	}
+}
+
+func TestRunPassesContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir := t.TempDir()
+	writeExecutable(t, dir, "gptcode-echo", "#!/bin/sh\necho \"$GPTCODE_PLUGIN $GPTCODE_BACKEND $*\"\necho \"$GPTCODE_CONTEXT\"\n")
+
+	p := Plugin{Name: "echo", Path: filepath.Join(dir, "gptcode-echo")}
+	ctx := Context{Name: "echo", Args: []string{"a", "b"}, WorkDir: dir, Backend: "groq"}
+	var out bytes.Buffer
+	if err := p.Run(ctx, ctx.Args, nil, &out, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.SplitN(strings.TrimSpace(out.String()), "\n", 2)
+	if lines[0] != "echo groq a b" {
+		t.Errorf("unexpected output %q", lines[0])
+	}
+	var got Context
+	if err := json.Unmarshal([]byte(lines[1]), &got); err != nil {
+		t.Fatalf("GPTCODE_CONTEXT is not JSON: %v", err)
+	}
+	if got.WorkDir != dir || len(got.Args) != 2 {
+		t.Errorf("unexpected context %+v", got)
+	}
+}
@@ -0,0 +1,212 @@
+package prompt
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"gptcode/internal/config"
+	"gptcode/internal/feedback"
+)
+
+// ControlVariant is the built-in prompt in a prompt experiment
+const ControlVariant = "control"
+
+// VariantMetadataPrefix prefixes the feedback metadata keys that record
+// which prompt variant each agent used ("prompt_variant.editor").
+const VariantMetadataPrefix = "prompt_variant."
+
+var (
+	variantMu   sync.Mutex
+	experiments map[string]config.PromptExperiment
+	assigned    = map[string]string{}
+	loaded      bool
+)
+
+// SetExperiments replaces the experiments read from setup.yaml and clears
+// the session's assignments.
+func SetExperiments(e map[string]config.PromptExperiment) {
+	variantMu.Lock()
+	defer variantMu.Unlock()
+	experiments = e
+	assigned = map[string]string{}
+	loaded = true
+}
+
+func loadExperimentsLocked() {
+	if loaded {
+		return
+	}
+	loaded = true
+	if setup, err := config.LoadEffectiveSetup(); err == nil {
+		experiments = setup.Prompts
+	}
+}
+
+// AgentPrompt returns the system prompt agent should use in this session,
+// assigning a variant the first time the agent asks. base is the built-in
+// prompt. Agents without an experiment always get base.
+//
+// GPTCODE_PROMPT_VARIANTS ("editor=concise,planner=control") pins variants
+// explicitly; otherwise the experiment's assign setting decides.
+func AgentPrompt(agent, base string) string {
+	variantMu.Lock()
+	defer variantMu.Unlock()
+	loadExperimentsLocked()
+
+	exp, ok := experiments[agent]
+	if !ok || len(exp.Variants) == 0 {
+		return base
+	}
+
+	name, ok := assigned[agent]
+	if !ok {
+		name = chooseVariant(agent, exp)
+		assigned[agent] = name
+		if os.Getenv("GPTCODE_DEBUG") == "1" {
+			fmt.Fprintf(os.Stderr, "[PROMPT] %s uses variant %q\n", agent, name)
+		}
+	}
+
+	variant, ok := exp.Variants[name]
+	if !ok {
+		return base
+	}
+	return renderVariant(variant, base)
+}
+
+// AssignedVariants returns the variants assigned so far in this session,
+// keyed by agent.
+func AssignedVariants() map[string]string {
+	variantMu.Lock()
+	defer variantMu.Unlock()
+	out := make(map[string]string, len(assigned))
+	for agent, name := range assigned {
+		out[agent] = name
+	}
+	return out
+}
+
+// VariantMetadata returns AssignedVariants as feedback metadata.
+func VariantMetadata() map[string]string {
+	meta := map[string]string{}
+	for agent, name := range AssignedVariants() {
+		meta[VariantMetadataPrefix+agent] = name
+	}
+	return meta
+}
+
+func chooseVariant(agent string, exp config.PromptExperiment) string {
+	for _, pin := range strings.Split(os.Getenv("GPTCODE_PROMPT_VARIANTS"), ",") {
+		if a, name, ok := strings.Cut(strings.TrimSpace(pin), "="); ok && a == agent {
+			return name
+		}
+	}
+	if exp.Assign != "" && exp.Assign != "random" {
+		return exp.Assign
+	}
+
+	names := []string{}
+	if _, ok := exp.Variants[ControlVariant]; !ok {
+		names = append(names, ControlVariant)
+	}
+	for name := range exp.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	weight := func(name string) float64 {
+		if v, ok := exp.Variants[name]; ok && v.Weight > 0 {
+			return v.Weight
+		}
+		return 1
+	}
+	var total float64
+	for _, name := range names {
+		total += weight(name)
+	}
+	r := rand.Float64() * total
+	for _, name := range names {
+		r -= weight(name)
+		if r < 0 {
+			return name
+		}
+	}
+	return names[len(names)-1]
+}
+
+func renderVariant(v config.PromptVariant, base string) string {
+	prompt := base
+	switch {
+	case v.Prompt != "":
+		prompt = v.Prompt
+	case v.File != "":
+		path := v.File
+		if !filepath.IsAbs(path) {
+			home, _ := os.UserHomeDir()
+			path = filepath.Join(home, ".gptcode", path)
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			prompt = string(data)
+		} else {
+			fmt.Fprintf(os.Stderr, "[WARN] prompt variant file %s: %v; using built-in prompt\n", v.File, err)
+		}
+	}
+	if v.Append != "" {
+		prompt += "\n\n" + v.Append
+	}
+	return prompt
+}
+
+// VariantStats is the task outcome tally for one prompt variant
+type VariantStats struct {
+	Agent     string  `json:"agent"`
+	Variant   string  `json:"variant"`
+	Sessions  int     `json:"sessions"`
+	Successes int     `json:"successes"`
+	Rate      float64 `json:"success_rate"`
+}
+
+// CompareVariants tallies task outcomes per agent and variant from feedback
+// events. Each task records one "editor" event carrying the variants of
+// every agent in the session, so only those events are counted.
+func CompareVariants(events []feedback.Event) []VariantStats {
+	byKey := map[string]*VariantStats{}
+	for _, e := range events {
+		if e.Agent != "editor" {
+			continue
+		}
+		for key, variant := range e.Metadata {
+			agent, ok := strings.CutPrefix(key, VariantMetadataPrefix)
+			if !ok {
+				continue
+			}
+			s, ok := byKey[agent+"/"+variant]
+			if !ok {
+				s = &VariantStats{Agent: agent, Variant: variant}
+				byKey[agent+"/"+variant] = s
+			}
+			s.Sessions++
+			if e.Sentiment == feedback.SentimentGood {
+				s.Successes++
+			}
+		}
+	}
+
+	out := make([]VariantStats, 0, len(byKey))
+	for _, s := range byKey {
+		s.Rate = float64(s.Successes) / float64(s.Sessions)
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Agent != out[j].Agent {
+			return out[i].Agent < out[j].Agent
+		}
+		return out[i].Variant < out[j].Variant
+	})
+	return out
+}
@@ -0,0 +1,87 @@
+package prompt
+
+import (
+	"testing"
+
+	"gptcode/internal/config"
+	"gptcode/internal/feedback"
+)
+
+func TestAgentPromptVariants(t *testing.T) {
+	defer SetExperiments(nil)
+	SetExperiments(map[string]config.PromptExperiment{
+		"editor": {
+			Assign: "concise",
+			Variants: map[string]config.PromptVariant{
+				"concise": {Append: "Be brief."},
+				"rewrite": {Prompt: "You are a different editor."},
+			},
+		},
+	})
+
+	if got := AgentPrompt("planner", "base planner"); got != "base planner" {
+		t.Errorf("agent without experiment got %q", got)
+	}
+	if got := AgentPrompt("editor", "base editor"); got != "base editor\n\nBe brief." {
+		t.Errorf("assigned variant got %q", got)
+	}
+	if got := AssignedVariants()["editor"]; got != "concise" {
+		t.Errorf("assigned editor variant = %q, want concise", got)
+	}
+	if got := VariantMetadata()["prompt_variant.editor"]; got != "concise" {
+		t.Errorf("variant metadata = %q, want concise", got)
+	}
+}
+
+func TestAgentPromptPinnedByEnv(t *testing.T) {
+	defer SetExperiments(nil)
+	t.Setenv("GPTCODE_PROMPT_VARIANTS", "planner=x, editor=rewrite")
+	SetExperiments(map[string]config.PromptExperiment{
+		"editor": {
+			Variants: map[string]config.PromptVariant{
+				"rewrite": {Prompt: "You are a different editor."},
+			},
+		},
+	})
+
+	if got := AgentPrompt("editor", "base editor"); got != "You are a different editor." {
+		t.Errorf("pinned variant got %q", got)
+	}
+}
+
+func TestAgentPromptControl(t *testing.T) {
+	defer SetExperiments(nil)
+	SetExperiments(map[string]config.PromptExperiment{
+		"reviewer": {
+			Variants: map[string]config.PromptVariant{
+				"strict": {Weight: 0.000001, Append: "Be strict."},
+			},
+		},
+	})
+	t.Setenv("GPTCODE_PROMPT_VARIANTS", "reviewer=control")
+
+	if got := AgentPrompt("reviewer", "base reviewer"); got != "base reviewer" {
+		t.Errorf("control variant got %q", got)
+	}
+}
+
+func TestCompareVariants(t *testing.T) {
+	events := []feedback.Event{
+		{Agent: "editor", Sentiment: feedback.SentimentGood, Metadata: map[string]string{"prompt_variant.editor": "concise"}},
+		{Agent: "editor", Sentiment: feedback.SentimentBad, Metadata: map[string]string{"prompt_variant.editor": "concise", "failure_reason": "x"}},
+		{Agent: "editor", Sentiment: feedback.SentimentGood, Metadata: map[string]string{"prompt_variant.editor": "control"}},
+		{Agent: "reviewer", Sentiment: feedback.SentimentGood, Metadata: map[string]string{"prompt_variant.editor": "control"}},
+		{Agent: "editor", Sentiment: feedback.SentimentGood},
+	}
+
+	stats := CompareVariants(events)
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 variants, got %+v", stats)
+	}
+	if stats[0].Variant != "concise" || stats[0].Sessions != 2 || stats[0].Successes != 1 || stats[0].Rate != 0.5 {
+		t.Errorf("concise stats = %+v", stats[0])
+	}
+	if stats[1].Variant != "control" || stats[1].Sessions != 1 || stats[1].Rate != 1 {
+		t.Errorf("control stats = %+v", stats[1])
+	}
+}
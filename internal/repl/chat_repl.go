@@ -1,15 +1,19 @@
 package repl
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/chzyer/readline"
+	"gptcode/internal/agents"
 	"gptcode/internal/config"
 	"gptcode/internal/llm"
+	"gptcode/internal/memory"
 	"gptcode/internal/modes"
 	"gptcode/internal/prompt"
 )
@@ -20,6 +24,7 @@ type ChatREPL struct {
 	ctxMgr  *ContextManager
 	builder *prompt.Builder
 	model   string
+	runTask TaskRunner
 }
 
 // NewChatREPL creates a new chat REPL instance
@@ -48,7 +53,7 @@ func NewChatREPL(maxTokens, maxMessages int) (*ChatREPL, error) {
 	}
 
 	// Load GPTCode configuration
-	setup, err := config.LoadSetup()
+	setup, err := config.LoadEffectiveSetup()
 	var model string
 	if err != nil {
 		// Use default values if we can't load configuration
@@ -80,6 +85,12 @@ func NewChatREPL(maxTokens, maxMessages int) (*ChatREPL, error) {
 	}, nil
 }
 
+// SetTaskRunner enables /do, which runs the task the conversation agreed on
+// with runTask.
+func (r *ChatREPL) SetTaskRunner(runTask TaskRunner) {
+	r.runTask = runTask
+}
+
 // filterInput prevents REPL from treating certain runes as special
 func filterInput(r rune) (rune, bool) {
 	switch r {
@@ -158,9 +169,44 @@ func (r *ChatREPL) Run() error {
 		}
 	}
 
+	r.remember()
 	return nil
 }
 
+// remember adds what the conversation taught about the project to its
+// memory when defaults.learn_memory is on. Conversations of a single
+// question are not worth summarizing.
+func (r *ChatREPL) remember() {
+	if setup, _ := config.LoadEffectiveSetup(); setup == nil || !setup.Defaults.LearnMemory {
+		return
+	}
+	questions := 0
+	for _, msg := range r.ctxMgr.messages {
+		if msg.Role == "user" {
+			questions++
+		}
+	}
+	if questions < 2 {
+		return
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	provider, model := llm.NewQueryProvider(nil, "")
+	facts, err := memory.Summarize(ctx, provider, model, memory.NewProjectStore(cwd), "chat", r.ctxMgr.GetContext())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to update project memory: %v\n", err)
+		return
+	}
+	if len(facts) > 0 {
+		fmt.Printf("Remembered %d new facts about this project (gptcode memory list)\n", len(facts))
+	}
+}
+
 // handleCommand processes REPL commands
 // Returns (shouldContinue, shouldExit)
 func (r *ChatREPL) handleCommand(cmd string) (bool, bool) {
@@ -225,6 +271,10 @@ func (r *ChatREPL) handleCommand(cmd string) (bool, bool) {
 		r.showHistory()
 		return true, false
 
+	case "/do":
+		r.promote(strings.TrimSpace(strings.TrimPrefix(cmd, "/do")))
+		return true, false
+
 	default:
 		fmt.Printf("Unknown command: %s (type /help for available commands)\n", parts[0])
 		return true, false
@@ -254,14 +304,30 @@ func (r *ChatREPL) processMessage(input string) error {
 		}
 	}
 
-	// Call ChatWithResponse to capture the response
-	response, err := modes.ChatWithResponse(fullPrompt, []string{})
+	// Stream the response as it is generated when enabled, capturing it
+	// for the conversation history either way
+	var onChunk agents.StreamCallback
+	streamed := false
+	if setup, _ := config.LoadEffectiveSetup(); setup.Output.StreamEnabled() {
+		onChunk = func(chunk string) {
+			streamed = true
+			fmt.Print(chunk)
+		}
+	}
+	response, err := modes.ChatWithStream(fullPrompt, []string{}, onChunk)
 	if err != nil {
+		if streamed {
+			fmt.Println()
+		}
 		return fmt.Errorf("chat error: %w", err)
 	}
 
 	// Print the response to user
-	fmt.Println(response)
+	if streamed {
+		fmt.Println()
+	} else {
+		fmt.Println(response)
+	}
 	fmt.Println()
 
 	// Add assistant response to context
@@ -281,6 +347,7 @@ func (r *ChatREPL) showHelp() {
 	fmt.Println("  /context       - Show context statistics")
 	fmt.Println("  /files         - List files in context")
 	fmt.Println("  /history       - Show conversation history")
+	fmt.Println("  /do [note]     - Turn the conversation into a task and run it")
 	fmt.Println("  /help          - Show this help")
 	fmt.Println("")
 	fmt.Println("All other input will be processed as a chat message.")
@@ -337,6 +404,12 @@ func isOpsQuery(s string) bool {
 		"xcode",
 		"docker",
 		"high usage",
+		"kubernetes",
+		"k8s",
+		"kubectl",
+		"crashloop",
+		"imagepullbackoff",
+		"oomkilled",
 		"dados do sistema",
 		"armazenamento",
 		"disco",
@@ -358,19 +431,7 @@ func RunSingleShot(input string, args []string) error {
 		if os.Getenv("GPTCODE_DEBUG") == "1" {
 			fmt.Fprintf(os.Stderr, "[RunSingleShot] Routing to run mode\n")
 		}
-		setup, _ := config.LoadSetup()
-		backendName := setup.Defaults.Backend
-		backendCfg := setup.Backend[backendName]
-
-		// Use query agent model from profile
-		queryModel := backendCfg.GetModelForAgent("query")
-
-		var provider llm.Provider
-		if backendCfg.Type == "ollama" {
-			provider = llm.NewOllama(backendCfg.BaseURL)
-		} else {
-			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
-		}
+		provider, queryModel := llm.NewQueryProvider(nil, "")
 		builder := prompt.NewDefaultBuilder(nil)
 		return modes.RunExecute(builder, provider, queryModel, []string{input})
 	}
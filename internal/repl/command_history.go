@@ -81,6 +81,17 @@ func (h *CommandHistory) GetLastCommand() *CommandEntry {
 	return &h.Commands[len(h.Commands)-1]
 }
 
+// FindPrevious returns the most recent run of command in dir, or nil
+func (h *CommandHistory) FindPrevious(command, dir string) *CommandEntry {
+	for i := len(h.Commands) - 1; i >= 0; i-- {
+		if h.Commands[i].Command == command && h.Commands[i].Dir == dir {
+			entry := h.Commands[i]
+			return &entry
+		}
+	}
+	return nil
+}
+
 // SetDirectory updates the current working directory
 func (h *CommandHistory) SetDirectory(dir string) {
 	h.CurrentDir = dir
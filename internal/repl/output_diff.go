@@ -0,0 +1,155 @@
+package repl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// maxDiffLines caps how many changed lines are printed after a repeated command
+const maxDiffLines = 40
+
+// OutputDelta describes how the output of a repeated command changed
+type OutputDelta struct {
+	Command    string
+	PreviousID string
+	Elapsed    time.Duration
+	Previous   string
+	Current    string
+	Changes    []string
+}
+
+// Unchanged reports whether the output is identical to the previous run
+func (d *OutputDelta) Unchanged() bool {
+	return len(d.Changes) == 0
+}
+
+// newOutputDelta compares the previous run of a command with its new output
+func newOutputDelta(prev *CommandEntry, output string, now time.Time) *OutputDelta {
+	return &OutputDelta{
+		Command:    prev.Command,
+		PreviousID: prev.ID,
+		Elapsed:    now.Sub(prev.Time).Round(time.Second),
+		Previous:   prev.Output,
+		Current:    output,
+		Changes:    diffLines(prev.Output, output),
+	}
+}
+
+// String renders the delta for display, truncated to maxDiffLines
+func (d *OutputDelta) String() string {
+	if d.Unchanged() {
+		return fmt.Sprintf("(output unchanged since [%s], %s ago)\n", d.PreviousID, d.Elapsed)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- changes since [%s] (%s ago) ---\n", d.PreviousID, d.Elapsed))
+	for i, line := range d.Changes {
+		if i == maxDiffLines {
+			sb.WriteString(fmt.Sprintf("... %d more changed lines (/diff to see all)\n", len(d.Changes)-maxDiffLines))
+			break
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+// Prompt builds the question sent to the model for /delta
+func (d *OutputDelta) Prompt(question string) string {
+	if question == "" {
+		question = "What changed since the last check, and does it matter?"
+	}
+	return fmt.Sprintf("I ran `%s` twice, %s apart.\n\nChanged lines (- before, + now):\n```\n%s\n```\n\nCurrent output:\n```\n%s\n```\n\n%s",
+		d.Command, d.Elapsed, strings.Join(d.Changes, "\n"), strings.TrimRight(d.Current, "\n"), question)
+}
+
+// maxDiffEdits bounds the search for the shortest diff; outputs further
+// apart are shown as entirely replaced
+const maxDiffEdits = 1000
+
+// diffLines returns a line diff of a and b containing only changed lines,
+// prefixed with "- " (removed) or "+ " (added). Lines shared at the start
+// and end are skipped, and the rest compared with Myers' algorithm, which
+// takes time and memory in proportion to the number of changes rather than
+// to the product of the outputs' lengths.
+func diffLines(a, b string) []string {
+	if a == b {
+		return nil
+	}
+	x := strings.Split(strings.TrimRight(a, "\n"), "\n")
+	y := strings.Split(strings.TrimRight(b, "\n"), "\n")
+
+	for len(x) > 0 && len(y) > 0 && x[0] == y[0] {
+		x, y = x[1:], y[1:]
+	}
+	for len(x) > 0 && len(y) > 0 && x[len(x)-1] == y[len(y)-1] {
+		x, y = x[:len(x)-1], y[:len(y)-1]
+	}
+	return myersDiff(x, y)
+}
+
+// myersDiff finds the shortest edit script turning x into y, keeping the
+// furthest point reached on each diagonal after every edit so the path can
+// be walked back from the end
+func myersDiff(x, y []string) []string {
+	n, m := len(x), len(y)
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1]
+			} else {
+				i = v[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				return myersEdits(trace, x, y)
+			}
+		}
+	}
+
+	out := make([]string, 0, n+m)
+	for _, line := range x {
+		out = append(out, "- "+line)
+	}
+	for _, line := range y {
+		out = append(out, "+ "+line)
+	}
+	return out
+}
+
+// myersEdits walks the path myersDiff found back from the end of x and y;
+// trace[d] holds the furthest points of diagonals -d..d before edit d
+func myersEdits(trace [][]int, x, y []string) []string {
+	var out []string
+	i, j := len(x), len(y)
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := i - j
+		prevK := k - 1
+		if k == -d || (k != d && v[k-1+d] < v[k+1+d]) {
+			prevK = k + 1
+		}
+		prevI := v[prevK+d]
+		prevJ := prevI - prevK
+		if prevK == k+1 {
+			out = append(out, "+ "+y[prevJ])
+		} else {
+			out = append(out, "- "+x[prevI])
+		}
+		i, j = prevI, prevJ
+	}
+	slices.Reverse(out)
+	return out
+}
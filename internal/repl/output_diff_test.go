@@ -0,0 +1,131 @@
+package repl
+
+import (
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffLines(t *testing.T) {
+	before := "NAME READY\napi 1/1\nworker 0/1\n"
+	after := "NAME READY\napi 1/1\nworker 1/1\ncron 1/1\n"
+
+	got := diffLines(before, after)
+	want := []string{"- worker 0/1", "+ worker 1/1", "+ cron 1/1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines() = %v, want %v", got, want)
+	}
+
+	if d := diffLines(before, before); d != nil {
+		t.Errorf("identical output should have no diff, got %v", d)
+	}
+}
+
+func TestDiffLinesIsShortest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		out := make([]string, 1+rng.Intn(12))
+		for i := range out {
+			out[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return out
+	}
+	for n := 0; n < 500; n++ {
+		x, y := lines(), lines()
+		a, b := strings.Join(x, "\n"), strings.Join(y, "\n")
+		if a == b {
+			continue
+		}
+
+		// The shortest diff keeps a longest common subsequence
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		got := diffLines(a, b)
+		var removed, added []string
+		for _, line := range got {
+			if strings.HasPrefix(line, "- ") {
+				removed = append(removed, line[2:])
+			} else {
+				added = append(added, line[2:])
+			}
+		}
+		if want := len(x) + len(y) - 2*lcs[0][0]; len(got) != want {
+			t.Fatalf("diffLines(%q, %q) = %q: %d changes, want %d", x, y, got, len(got), want)
+		}
+		if !isSubsequence(removed, x) || !isSubsequence(added, y) {
+			t.Fatalf("diffLines(%q, %q) = %q: changes out of order", x, y, got)
+		}
+	}
+}
+
+// isSubsequence reports whether sub appears in s in order
+func isSubsequence(sub, s []string) bool {
+	for _, line := range s {
+		if len(sub) > 0 && sub[0] == line {
+			sub = sub[1:]
+		}
+	}
+	return len(sub) == 0
+}
+
+func TestDiffLinesLargeOutput(t *testing.T) {
+	// Long logs repeat lines; a quadratic table of them would not fit in memory
+	var before, after strings.Builder
+	for i := 0; i < 50000; i++ {
+		line := "INFO request served\n"
+		if i%1000 == 0 {
+			line = "INFO checkpoint " + strconv.Itoa(i) + "\n"
+		}
+		before.WriteString(line)
+		if i == 25000 {
+			line = "ERROR request failed\n"
+		}
+		after.WriteString(line)
+	}
+
+	got := diffLines(before.String(), after.String())
+	want := []string{"- INFO checkpoint 25000", "+ ERROR request failed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffLines() = %v, want %v", got, want)
+	}
+}
+
+func TestOutputDeltaFromHistory(t *testing.T) {
+	h := NewCommandHistory(10)
+	h.AddCommand("kubectl get pods", "api Running\n", "", 0)
+	h.AddCommand("ls", "a\n", "", 0)
+
+	prev := h.FindPrevious("kubectl get pods", "")
+	if prev == nil || prev.ID != "1" {
+		t.Fatalf("FindPrevious() = %v, want entry 1", prev)
+	}
+
+	delta := newOutputDelta(prev, "api CrashLoopBackOff\n", prev.Time.Add(90*time.Second))
+	if delta.Unchanged() {
+		t.Fatal("expected changes")
+	}
+	if !strings.Contains(delta.String(), "changes since [1] (1m30s ago)") {
+		t.Errorf("unexpected summary: %q", delta.String())
+	}
+	if !strings.Contains(delta.Prompt(""), "+ api CrashLoopBackOff") {
+		t.Errorf("prompt missing delta: %q", delta.Prompt(""))
+	}
+	if h.FindPrevious("docker ps", "") != nil {
+		t.Error("unexpected match for unknown command")
+	}
+}
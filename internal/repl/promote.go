@@ -0,0 +1,102 @@
+package repl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gptcode/internal/llm"
+)
+
+// TaskRunner runs a task with the autonomous executor, as gptcode do does
+type TaskRunner func(task string) error
+
+const promotePrompt = `You turn a conversation between a developer and an assistant into a task for an autonomous coding agent that has not seen the conversation.
+
+Write the task in markdown with these sections:
+## Goal
+One or two sentences on the change to make.
+## Requirements
+Every requirement, decision and detail the conversation settled on, as bullets. Keep names, paths, signatures and values exactly as discussed.
+## Constraints
+What must not change or be done, as bullets; omit the section if nothing was said.
+## Files
+Files and packages the conversation points at, as bullets; omit the section if none.
+## Acceptance criteria
+How to tell the change is done, as bullets.
+
+Rules:
+- Include only what the conversation supports. Where the developer overruled the assistant, follow the developer.
+- Leave out exploratory ideas that were dropped.
+- Reply with the task only, without code fences.`
+
+// TaskFromConversation condenses a chat conversation, with an optional note
+// from the user, into a structured task the autonomous executor can run
+// without restating the requirements.
+func TaskFromConversation(ctx context.Context, provider llm.Provider, model, conversation, note string) (string, error) {
+	if strings.TrimSpace(conversation) == "" {
+		return "", fmt.Errorf("the conversation is empty")
+	}
+	userPrompt := "Conversation:\n" + conversation
+	if note = strings.TrimSpace(note); note != "" {
+		userPrompt += "\n\nThe developer adds: " + note
+	}
+
+	resp, err := provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: promotePrompt,
+		UserPrompt:   userPrompt,
+		Model:        model,
+	})
+	if err != nil {
+		return "", err
+	}
+	task := strings.TrimSpace(resp.Text)
+	task = strings.TrimPrefix(strings.TrimPrefix(task, "```markdown"), "```")
+	task = strings.TrimSpace(strings.TrimSuffix(task, "```"))
+	if !strings.Contains(strings.ToLower(task), "## goal") {
+		return "", fmt.Errorf("the model did not write a task: %q", task)
+	}
+	return task, nil
+}
+
+// promote turns the conversation into a task, asks the user to confirm it
+// and runs it, recording the outcome in the conversation.
+func (r *ChatREPL) promote(note string) {
+	if r.runTask == nil {
+		fmt.Println("/do is not available in this session")
+		return
+	}
+	conversation := r.ctxMgr.GetContext()
+	if conversation == "" {
+		fmt.Println("Nothing to turn into a task yet: describe the change first")
+		return
+	}
+
+	fmt.Println("Writing the task from the conversation...")
+	provider, model := llm.NewQueryProvider(nil, "")
+	task, err := TaskFromConversation(context.Background(), provider, model, conversation, note)
+	if err != nil {
+		fmt.Printf("Failed to write the task: %v\n", err)
+		return
+	}
+	fmt.Printf("\n%s\n\n", task)
+
+	r.rl.SetPrompt("Run this task? [Y/n] ")
+	answer, err := r.rl.Readline()
+	r.rl.SetPrompt("> ")
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if err != nil || (answer != "" && answer != "y" && answer != "yes") {
+		fmt.Println("Task not run; keep chatting or /do again")
+		return
+	}
+
+	outcome := "The task was run and completed:\n" + task
+	if err := r.runTask(task); err != nil {
+		fmt.Printf("Task failed: %v\n", err)
+		outcome = fmt.Sprintf("The task was run and failed (%v):\n%s", err, task)
+	} else {
+		fmt.Println("Task completed")
+	}
+	fmt.Println()
+	r.ctxMgr.AddMessage("task", outcome, estimateTokens(outcome))
+}
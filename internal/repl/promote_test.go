@@ -0,0 +1,43 @@
+package repl
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+type taskProvider struct {
+	reply  string
+	prompt string
+}
+
+func (p *taskProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.prompt = req.UserPrompt
+	return &llm.ChatResponse{Text: p.reply}, nil
+}
+
+func TestTaskFromConversation(t *testing.T) {
+	cm := NewContextManager(8000, 50)
+	cm.AddMessage("user", "The retry loop in client.go should back off", 10)
+	cm.AddMessage("assistant", "Use exponential backoff capped at 30s?", 10)
+	cm.AddMessage("user", "Yes, but cap it at 10s", 10)
+
+	p := &taskProvider{reply: "```markdown\n## Goal\nAdd exponential backoff to the retry loop.\n\n## Requirements\n- Cap the delay at 10s\n```"}
+	task, err := TaskFromConversation(context.Background(), p, "m", cm.GetContext(), "keep the public API")
+	if err != nil || !strings.HasPrefix(task, "## Goal") || strings.Contains(task, "```") {
+		t.Errorf("unexpected task %q, %v", task, err)
+	}
+	if !strings.Contains(p.prompt, "User: Yes, but cap it at 10s") || !strings.Contains(p.prompt, "The developer adds: keep the public API") {
+		t.Errorf("prompt lacks the conversation or the note: %q", p.prompt)
+	}
+
+	p.reply = "Sure, what should I do?"
+	if _, err := TaskFromConversation(context.Background(), p, "m", cm.GetContext(), ""); err == nil {
+		t.Error("a reply without a goal is not a task")
+	}
+	if _, err := TaskFromConversation(context.Background(), p, "m", "", ""); err == nil {
+		t.Error("an empty conversation has no task")
+	}
+}
@@ -2,17 +2,27 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path"
+	"sort"
 	"strings"
+	"time"
+
+	"gptcode/internal/llm"
+	"gptcode/internal/tools"
 )
 
 // RunREPL implements a REPL for command execution with follow-up support
 type RunREPL struct {
-	history *CommandHistory
-	prompt  string
+	history   *CommandHistory
+	prompt    string
+	lastDelta *OutputDelta
+	// remote runs the commands over SSH instead of on this machine
+	remote *tools.SSHExecutor
 }
 
 // NewRunREPL creates a new run REPL instance
@@ -23,13 +33,24 @@ func NewRunREPL(maxCommands int) *RunREPL {
 	}
 }
 
+// SetTarget runs the REPL's commands on a remote machine; /cd and /env
+// then apply to the remote shell
+func (r *RunREPL) SetTarget(target *tools.SSHExecutor) {
+	r.remote = target
+}
+
 // Run starts the REPL loop
 func (r *RunREPL) Run() error {
 	fmt.Println("GPTCode Run REPL - Type /help for commands")
+	if r.remote != nil {
+		fmt.Printf("Commands run on %s\n", r.remote.Target)
+	}
 	fmt.Println("")
 
 	// Initialize current directory
-	if wd, err := os.Getwd(); err == nil {
+	if r.remote != nil {
+		r.history.SetDirectory(r.remote.Target.Dir)
+	} else if wd, err := os.Getwd(); err == nil {
 		r.history.SetDirectory(wd)
 	}
 
@@ -37,7 +58,9 @@ func (r *RunREPL) Run() error {
 
 	for {
 		// Show prompt with current directory
-		if r.history.CurrentDir != "" {
+		if r.remote != nil {
+			fmt.Printf("%s:%s$ ", r.remote.Target.Host, r.history.CurrentDir)
+		} else if r.history.CurrentDir != "" {
 			fmt.Printf("%s:%s$ ", r.history.CurrentDir, strings.TrimSuffix(os.Args[0], "/chu"))
 		} else {
 			fmt.Print(r.prompt)
@@ -121,12 +144,36 @@ func (r *RunREPL) handleCommand(cmd string) (bool, bool) {
 		}
 		return true, false
 
+	case "/diff":
+		if r.lastDelta == nil {
+			fmt.Println("No repeated command yet. Re-run a command to compare its output.")
+		} else if r.lastDelta.Unchanged() {
+			fmt.Print(r.lastDelta.String())
+		} else {
+			fmt.Printf("Changes in `%s` since [%s]:\n", r.lastDelta.Command, r.lastDelta.PreviousID)
+			fmt.Println(strings.Join(r.lastDelta.Changes, "\n"))
+		}
+		return true, false
+
+	case "/delta":
+		if r.lastDelta == nil {
+			fmt.Println("No repeated command yet. Re-run a command to compare its output.")
+			return true, false
+		}
+		question := strings.TrimSpace(strings.TrimPrefix(cmd, parts[0]))
+		if err := r.explainDelta(question); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+		return true, false
+
 	case "/cd":
 		if len(parts) < 2 {
 			fmt.Println("Usage: /cd <directory>")
 			if r.history.CurrentDir != "" {
 				fmt.Printf("Current directory: %s\n", r.history.CurrentDir)
 			}
+		} else if r.remote != nil {
+			r.remoteChdir(parts[1])
 		} else {
 			if err := os.Chdir(parts[1]); err != nil {
 				fmt.Printf("Failed to change directory: %v\n", err)
@@ -179,6 +226,11 @@ func (r *RunREPL) executeCommand(cmdStr string) {
 	// Expand $last and $N references
 	cmdStr = r.expandReferences(cmdStr)
 
+	if r.remote != nil {
+		r.executeRemote(cmdStr)
+		return
+	}
+
 	// Create command with current directory
 	cmd := exec.Command("sh", "-c", cmdStr)
 
@@ -207,6 +259,13 @@ func (r *RunREPL) executeCommand(cmdStr string) {
 		}
 	}
 
+	// Compare with the previous run of the same command
+	var delta *OutputDelta
+	if prev := r.history.FindPrevious(cmdStr, r.history.CurrentDir); prev != nil {
+		delta = newOutputDelta(prev, output, time.Now())
+		r.lastDelta = delta
+	}
+
 	// Add to history
 	r.history.AddCommand(cmdStr, output, "", exitCode)
 
@@ -219,6 +278,100 @@ func (r *RunREPL) executeCommand(cmdStr string) {
 	if exitCode != 0 {
 		fmt.Printf("(exit code: %d)\n", exitCode)
 	}
+
+	if delta != nil {
+		fmt.Print(delta.String())
+		if !delta.Unchanged() {
+			fmt.Println("(ask /delta [question] to have the AI explain what changed)")
+		}
+	}
+}
+
+// remoteSession returns the target with the REPL's directory and
+// environment
+func (r *RunREPL) remoteSession() *tools.SSHExecutor {
+	session := *r.remote
+	session.Target.Dir = r.history.CurrentDir
+	keys := make([]string, 0, len(r.history.Environment))
+	for k := range r.history.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	session.Env = nil
+	for _, k := range keys {
+		session.Env = append(session.Env, k+"="+r.history.Environment[k])
+	}
+	return &session
+}
+
+// remoteChdir changes the remote directory, checking it exists there
+func (r *RunREPL) remoteChdir(dir string) {
+	if !path.IsAbs(dir) && r.history.CurrentDir != "" {
+		dir = path.Join(r.history.CurrentDir, dir)
+	}
+	session := r.remoteSession()
+	session.Stream = nil
+	out, err := session.Run(context.Background(), "cd "+shellQuote(dir)+" && pwd", "")
+	if err != nil {
+		fmt.Printf("Failed to change directory: %s\n", strings.TrimSpace(string(out)))
+		return
+	}
+	wd := strings.TrimSpace(string(out))
+	r.history.SetDirectory(wd)
+	fmt.Printf("Changed to: %s\n", wd)
+}
+
+// executeRemote runs a command on the target, streaming its output
+func (r *RunREPL) executeRemote(cmdStr string) {
+	session := r.remoteSession()
+	session.Stream = os.Stdout
+	outputBytes, err := session.Run(context.Background(), cmdStr, "")
+	output := string(outputBytes)
+	exitCode := 0
+	if err != nil {
+		exitError, ok := err.(*exec.ExitError)
+		if !ok {
+			fmt.Printf("Error executing command: %v\n", err)
+			return
+		}
+		exitCode = exitError.ExitCode()
+	}
+
+	var delta *OutputDelta
+	if prev := r.history.FindPrevious(cmdStr, r.history.CurrentDir); prev != nil {
+		delta = newOutputDelta(prev, output, time.Now())
+		r.lastDelta = delta
+	}
+	r.history.AddCommand(cmdStr, output, "", exitCode)
+
+	if exitCode != 0 {
+		fmt.Printf("(exit code: %d)\n", exitCode)
+	}
+	if delta != nil {
+		fmt.Print(delta.String())
+		if !delta.Unchanged() {
+			fmt.Println("(ask /delta [question] to have the AI explain what changed)")
+		}
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// explainDelta asks the model to reason over the last output delta
+func (r *RunREPL) explainDelta(question string) error {
+	provider, model := llm.NewQueryProvider(nil, "")
+	resp, err := provider.Chat(context.Background(), llm.ChatRequest{
+		SystemPrompt: "You are an operations assistant. The user re-ran a command while troubleshooting. Explain concisely what changed between the two runs and whether it indicates progress, a regression or noise.",
+		UserPrompt:   r.lastDelta.Prompt(question),
+		Model:        model,
+	})
+	if err != nil {
+		return fmt.Errorf("LLM error: %w", err)
+	}
+	fmt.Println(strings.TrimSpace(resp.Text))
+	return nil
 }
 
 // expandReferences replaces $last and $N with previous command outputs
@@ -252,6 +405,8 @@ func (r *RunREPL) showHelp() {
 	fmt.Println("  /env           - Show/set environment variables")
 	fmt.Println("  /env <key>     - Show specific environment variable")
 	fmt.Println("  /env <k>=<v>   - Set environment variable")
+	fmt.Println("  /diff          - Show the full output diff of the last repeated command")
+	fmt.Println("  /delta [q]     - Ask the AI what changed since the previous run")
 	fmt.Println("")
 	fmt.Println("Commands can reference previous outputs:")
 	fmt.Println("  $last          - Reference the last command")
@@ -278,10 +433,15 @@ func (r *RunREPL) showRecentCommands() {
 	}
 }
 
-// RunSingleShotCommand executes a single command without REPL mode
-func RunSingleShotCommand(command string) error {
+// RunSingleShotCommand executes a single command without REPL mode, on
+// target when it is not nil
+func RunSingleShotCommand(command string, target *tools.SSHExecutor) error {
 	// Create a temporary REPL and execute one command
 	repl := NewRunREPL(10)
+	if target != nil {
+		repl.SetTarget(target)
+		repl.history.SetDirectory(target.Target.Dir)
+	}
 	repl.executeCommand(command)
 	return nil
 }
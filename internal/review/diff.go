@@ -0,0 +1,116 @@
+package review
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gptcode/internal/github"
+)
+
+// maxLineDrift is how far a finding may sit from a changed line and still be
+// commented inline, on the nearest changed line
+const maxLineDrift = 5
+
+// CommentableLines returns, per file, the lines of the new version a review
+// comment can be placed on: those inside the diff's hunks
+func CommentableLines(diff string) map[string]map[int]bool {
+	lines := map[string]map[int]bool{}
+	var file string
+	line := 0
+	inHunk := false
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			file, inHunk = "", false
+		case strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			if file == "/dev/null" {
+				file = ""
+			} else if lines[file] == nil {
+				lines[file] = map[int]bool{}
+			}
+		case strings.HasPrefix(text, "@@"):
+			line, inHunk = hunkStart(text), file != ""
+		case !inHunk:
+		case strings.HasPrefix(text, "+"), strings.HasPrefix(text, " "):
+			lines[file][line] = true
+			line++
+		case strings.HasPrefix(text, "-"), strings.HasPrefix(text, `\`):
+		default:
+			inHunk = false
+		}
+	}
+	return lines
+}
+
+// hunkStart reads the new side's first line from "@@ -a,b +c,d @@"
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0
+	}
+	start, _, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	n, _ := strconv.Atoi(start)
+	return n
+}
+
+// BuildReview places findings on the diff's changed lines, snapping each to
+// the nearest commentable line within a few lines; findings that cannot be
+// placed go into the review's body. The verdict follows the findings'
+// severity.
+func BuildReview(findings []Finding, diff string) github.ReviewSubmission {
+	commentable := CommentableLines(diff)
+	review := github.ReviewSubmission{Event: Verdict(findings)}
+	var unplaced []Finding
+	for _, f := range findings {
+		line := nearestLine(commentable[f.File], f.Line)
+		if line == 0 {
+			unplaced = append(unplaced, f)
+			continue
+		}
+		review.Comments = append(review.Comments, github.InlineComment{Path: f.File, Line: line, Body: f.Comment()})
+	}
+
+	var b strings.Builder
+	b.WriteString("## Automated review\n\n")
+	if len(findings) == 0 {
+		b.WriteString("No problems found.\n")
+	} else {
+		fmt.Fprintf(&b, "Found %s.\n", Summary(findings))
+	}
+	if len(unplaced) > 0 {
+		b.WriteString("\n### Outside the diff\n\n")
+		for _, f := range unplaced {
+			location := f.File
+			if location != "" && f.Line > 0 {
+				location = fmt.Sprintf("%s:%d", f.File, f.Line)
+			}
+			if location != "" {
+				location = fmt.Sprintf(" (`%s`)", location)
+			}
+			fmt.Fprintf(&b, "- **%s**%s %s", f.Severity, location, f.Title)
+			if f.Body != "" {
+				fmt.Fprintf(&b, ": %s", strings.ReplaceAll(f.Body, "\n", " "))
+			}
+			b.WriteString("\n")
+		}
+	}
+	review.Body = b.String()
+	return review
+}
+
+func nearestLine(lines map[int]bool, line int) int {
+	if len(lines) == 0 || line <= 0 {
+		return 0
+	}
+	for d := 0; d <= maxLineDrift; d++ {
+		if lines[line+d] {
+			return line + d
+		}
+		if d > 0 && lines[line-d] {
+			return line - d
+		}
+	}
+	return 0
+}
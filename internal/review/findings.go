@@ -0,0 +1,182 @@
+// Package review turns a code review into findings tied to files and lines,
+// and places them on a pull request's diff so they can be posted as inline
+// review comments.
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gptcode/internal/github"
+)
+
+// Severities, most severe first
+const (
+	SeverityCritical = "critical"
+	SeverityMajor    = "major"
+	SeverityMinor    = "minor"
+	SeverityNit      = "nit"
+)
+
+var severityRank = map[string]int{
+	SeverityCritical: 0,
+	SeverityMajor:    1,
+	SeverityMinor:    2,
+	SeverityNit:      3,
+}
+
+// severityAliases maps the names models use to the four severities
+var severityAliases = map[string]string{
+	"blocker": SeverityCritical,
+	"error":   SeverityCritical,
+	"high":    SeverityMajor,
+	"warning": SeverityMajor,
+	"medium":  SeverityMinor,
+	"low":     SeverityMinor,
+	"info":    SeverityNit,
+	"nitpick": SeverityNit,
+	"style":   SeverityNit,
+}
+
+// Levels group the severities for gating and reporting: critical and major
+// findings are errors, minor ones warnings and nits info
+const (
+	LevelError = "error"
+	LevelWarn  = "warn"
+	LevelInfo  = "info"
+)
+
+var levelRank = map[string]int{LevelError: 0, LevelWarn: 1, LevelInfo: 2}
+
+// ParseLevel reads a level name as given to --fail-on
+func ParseLevel(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error":
+		return LevelError, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "info", "note":
+		return LevelInfo, nil
+	}
+	return "", fmt.Errorf("unknown level %q (want error, warn or info)", s)
+}
+
+// Finding is one problem the review found
+type Finding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+}
+
+// Blocking reports whether the finding should stop the change from merging
+func (f Finding) Blocking() bool {
+	return f.Severity == SeverityCritical || f.Severity == SeverityMajor
+}
+
+// Level is the finding's severity as error, warn or info
+func (f Finding) Level() string {
+	switch f.Severity {
+	case SeverityCritical, SeverityMajor:
+		return LevelError
+	case SeverityMinor:
+		return LevelWarn
+	}
+	return LevelInfo
+}
+
+// AtLeast returns the findings at level or above, e.g. errors and warnings
+// for LevelWarn
+func AtLeast(findings []Finding, level string) []Finding {
+	var out []Finding
+	for _, f := range findings {
+		if levelRank[f.Level()] <= levelRank[level] {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Comment renders the finding as a review comment
+func (f Finding) Comment() string {
+	text := fmt.Sprintf("**%s** %s", strings.ToUpper(f.Severity[:1])+f.Severity[1:], f.Title)
+	if f.Body != "" {
+		text += "\n\n" + f.Body
+	}
+	return text
+}
+
+// FindingsFormat is the output format the reviewer is asked for, so its
+// findings can be printed, posted or gated on
+const FindingsFormat = `Output Format:
+Reply with a JSON array of findings and nothing else, [] when there are none:
+[{"file": "path/relative/to/repo.go", "line": 42, "severity": "critical|major|minor|nit", "title": "one line", "body": "why it matters and how to fix it"}]
+
+- file is relative to the working directory; line is the line the finding is about, in the new version of the file when reviewing a diff.
+- critical: bugs, data loss, security holes. major: wrong behavior in edge cases, missing error handling, missing tests for new behavior. minor: maintainability and performance. nit: style and naming.
+- Report each problem once, at its most relevant line.`
+
+// ParseFindings reads the reviewer's JSON findings, tolerating code fences
+// and text around the array. Severities are normalized to the four levels,
+// unknown ones becoming minor, and findings are sorted most severe first.
+func ParseFindings(text string) ([]Finding, error) {
+	start, end := strings.Index(text, "["), strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array of findings in the review")
+	}
+	var findings []Finding
+	if err := json.Unmarshal([]byte(text[start:end+1]), &findings); err != nil {
+		return nil, fmt.Errorf("failed to parse findings: %w", err)
+	}
+	for i := range findings {
+		findings[i].Severity = normalizeSeverity(findings[i].Severity)
+		findings[i].File = strings.TrimPrefix(findings[i].File, "./")
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank[findings[i].Severity] < severityRank[findings[j].Severity]
+	})
+	return findings, nil
+}
+
+func normalizeSeverity(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if _, ok := severityRank[s]; ok {
+		return s
+	}
+	if alias, ok := severityAliases[s]; ok {
+		return alias
+	}
+	return SeverityMinor
+}
+
+// Verdict is the review event findings call for: request changes when any
+// is blocking, approve otherwise
+func Verdict(findings []Finding) string {
+	for _, f := range findings {
+		if f.Blocking() {
+			return github.ReviewRequestChanges
+		}
+	}
+	return github.ReviewApprove
+}
+
+// Summary counts the findings by severity, e.g. "1 critical, 2 minor"
+func Summary(findings []Finding) string {
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[f.Severity]++
+	}
+	var parts []string
+	for _, s := range []string{SeverityCritical, SeverityMajor, SeverityMinor, SeverityNit} {
+		if counts[s] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[s], s))
+		}
+	}
+	if len(parts) == 0 {
+		return "no findings"
+	}
+	return strings.Join(parts, ", ")
+}
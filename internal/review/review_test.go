@@ -0,0 +1,156 @@
+package review
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gptcode/internal/github"
+)
+
+const testDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -10,4 +10,5 @@ func main() {
+ 	a := 1
+-	b := 2
++	b := 3
++	c := 4
+ 	fmt.Println(a, b)
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1,2 +0,0 @@
+-package main
+-
+`
+
+func TestParseFindings(t *testing.T) {
+	text := "Here you go:\n```json\n[" +
+		`{"file": "./main.go", "line": 11, "severity": "Low", "title": "naming"},` +
+		`{"file": "main.go", "line": 12, "severity": "critical", "title": "overflow"}` +
+		"]\n```"
+	findings, err := ParseFindings(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 2 || findings[0].Severity != SeverityCritical {
+		t.Fatalf("findings should be sorted most severe first: %+v", findings)
+	}
+	if findings[1].Severity != SeverityMinor || findings[1].File != "main.go" {
+		t.Errorf("second finding = %+v", findings[1])
+	}
+	if _, err := ParseFindings("looks good"); err == nil {
+		t.Error("expected an error without a JSON array")
+	}
+}
+
+func TestCommentableLines(t *testing.T) {
+	lines := CommentableLines(testDiff)
+	for _, n := range []int{10, 11, 12, 13} {
+		if !lines["main.go"][n] {
+			t.Errorf("main.go:%d should be commentable", n)
+		}
+	}
+	if lines["main.go"][14] || lines["main.go"][9] {
+		t.Error("lines outside the hunk should not be commentable")
+	}
+	if _, ok := lines["old.go"]; ok {
+		t.Error("deleted files have no new side to comment on")
+	}
+}
+
+func TestBuildReview(t *testing.T) {
+	findings := []Finding{
+		{File: "main.go", Line: 11, Severity: SeverityMajor, Title: "wrong value"},
+		{File: "main.go", Line: 16, Severity: SeverityNit, Title: "near the hunk"},
+		{File: "util.go", Line: 3, Severity: SeverityMinor, Title: "not in the diff"},
+	}
+	review := BuildReview(findings, testDiff)
+	if review.Event != github.ReviewRequestChanges {
+		t.Errorf("a major finding should request changes, got %s", review.Event)
+	}
+	if len(review.Comments) != 2 || review.Comments[1].Line != 13 {
+		t.Fatalf("comments = %+v", review.Comments)
+	}
+	if !strings.Contains(review.Body, "`util.go:3`") {
+		t.Errorf("unplaced finding missing from the body:\n%s", review.Body)
+	}
+
+	if got := BuildReview(findings[1:], testDiff).Event; got != github.ReviewApprove {
+		t.Errorf("minor findings should approve, got %s", got)
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	findings := []Finding{
+		{Severity: SeverityCritical}, {Severity: SeverityMinor}, {Severity: SeverityNit},
+	}
+	for level, want := range map[string]int{LevelError: 1, LevelWarn: 2, LevelInfo: 3} {
+		if got := len(AtLeast(findings, level)); got != want {
+			t.Errorf("AtLeast(%s) = %d findings, want %d", level, got, want)
+		}
+	}
+	if level, err := ParseLevel("Warning"); err != nil || level != LevelWarn {
+		t.Errorf("ParseLevel(Warning) = %q, %v", level, err)
+	}
+	if _, err := ParseLevel("fatal"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	data, err := SARIF([]Finding{
+		{File: "main.go", Line: 12, Severity: SeverityMajor, Title: "overflow", Body: "use int64"},
+		{File: "README.md", Severity: SeverityNit, Title: "typo"},
+	}, "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Version string `json:"version"`
+					Rules   []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region *struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Version != "1.2.3" || len(log.Runs[0].Tool.Driver.Rules) != 4 {
+		t.Fatalf("unexpected log:\n%s", data)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 2 || results[0].Level != "error" || results[0].RuleID != "review/major" || results[1].Level != "note" {
+		t.Fatalf("unexpected results:\n%s", data)
+	}
+	loc := results[0].Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "main.go" || loc.Region == nil || loc.Region.StartLine != 12 {
+		t.Errorf("unexpected location %+v", loc)
+	}
+	if results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Error("a finding without a line should have no region")
+	}
+}
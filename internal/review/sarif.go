@@ -0,0 +1,114 @@
+package review
+
+import (
+	"encoding/json"
+	"path/filepath"
+)
+
+// SARIF 2.1.0, the subset GitHub code scanning reads
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version,omitempty"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	DefaultConfig    sarifConfig  `json:"defaultConfiguration"`
+}
+
+type sarifConfig struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region           *sarifRegion  `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifLevels maps finding levels to SARIF's
+var sarifLevels = map[string]string{LevelError: "error", LevelWarn: "warning", LevelInfo: "note"}
+
+// SARIF renders findings as a SARIF log for code scanning upload, with one
+// rule per severity. Paths should be relative to the repository root.
+func SARIF(findings []Finding, version string) ([]byte, error) {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "gptcode", Version: version}},
+		Results: []sarifResult{},
+	}
+	for _, s := range []string{SeverityCritical, SeverityMajor, SeverityMinor, SeverityNit} {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               ruleID(s),
+			ShortDescription: sarifMessage{Text: "Code review finding (" + s + ")"},
+			DefaultConfig:    sarifConfig{Level: sarifLevels[Finding{Severity: s}.Level()]},
+		})
+	}
+	for _, f := range findings {
+		text := f.Title
+		if f.Body != "" {
+			text += "\n\n" + f.Body
+		}
+		result := sarifResult{
+			RuleID:  ruleID(f.Severity),
+			Level:   sarifLevels[f.Level()],
+			Message: sarifMessage{Text: text},
+		}
+		if f.File != "" {
+			loc := sarifPhysicalLocation{ArtifactLocation: sarifArtifact{URI: filepath.ToSlash(f.File)}}
+			if f.Line > 0 {
+				loc.Region = &sarifRegion{StartLine: f.Line}
+			}
+			result.Locations = []sarifLocation{{PhysicalLocation: loc}}
+		}
+		run.Results = append(run.Results, result)
+	}
+	return json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
+
+func ruleID(severity string) string {
+	return "review/" + severity
+}
@@ -0,0 +1,40 @@
+// Package secrets describes what credentials look like, for the scanner
+// that keeps them out of commits and the redactor that keeps them out of
+// bug reports.
+package secrets
+
+import "regexp"
+
+// Pattern is one kind of credential
+type Pattern struct {
+	// Name names what was found, e.g. aws-access-key or private-key
+	Name   string
+	Regexp *regexp.Regexp
+	// Entropy is the Shannon entropy, in bits per character, a match must
+	// reach to be reported; 0 accepts any match. Redaction ignores it,
+	// since hiding a false positive costs nothing.
+	Entropy float64
+}
+
+// Patterns go from the most specific to the most generic. None has a
+// capturing group, so a whole match is the secret. A private key matches
+// from its BEGIN line to its END line, or the BEGIN line alone when the
+// text is read line by line.
+var Patterns = []Pattern{
+	{Name: "private-key", Regexp: regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----(?:[\s\S]*?-----END (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----)?`)},
+	{Name: "aws-access-key", Regexp: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{Name: "github-token", Regexp: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{60,})`)},
+	{Name: "gitlab-token", Regexp: regexp.MustCompile(`\bglpat-[A-Za-z0-9_\-]{20,}`)},
+	{Name: "slack-token", Regexp: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{Name: "stripe-key", Regexp: regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{20,}`)},
+	{Name: "google-api-key", Regexp: regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}`)},
+	{Name: "anthropic-key", Regexp: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_\-]{20,}`)},
+	// OpenAI's keys, and the OpenAI-style keys of OpenRouter and others
+	{Name: "openai-key", Regexp: regexp.MustCompile(`\bsk-[A-Za-z0-9_\-]{16,}`), Entropy: 3.5},
+	{Name: "jwt", Regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	{Name: "bearer-token", Regexp: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._\-]{16,}`), Entropy: 3.5},
+}
+
+// KeyWords is a regexp alternation of the words naming a secret in a
+// setting or assignment, such as API_KEY=... or "password": "..."
+const KeyWords = `api[_-]?key|secret|token|passw(?:or)?d|credential`
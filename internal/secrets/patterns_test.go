@@ -0,0 +1,20 @@
+package secrets
+
+import "testing"
+
+func TestPatterns(t *testing.T) {
+	for _, p := range Patterns {
+		if p.Regexp.NumSubexp() != 0 {
+			t.Errorf("%s: a capturing group would hide part of the secret", p.Name)
+		}
+	}
+
+	key := "-----BEGIN " + "OPENSSH PRIVATE KEY-----\nb3BlbnNzaC1rZXktdjEAAAAA\n-----END " + "OPENSSH PRIVATE KEY-----"
+	privateKey := Patterns[0]
+	if got := privateKey.Regexp.FindString("before\n" + key + "\nafter"); got != key {
+		t.Errorf("expected the whole key block, got %q", got)
+	}
+	if got := privateKey.Regexp.FindString("-----BEGIN " + "RSA PRIVATE KEY-----"); got == "" {
+		t.Error("expected the BEGIN line alone to match")
+	}
+}
@@ -0,0 +1,557 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of findings: a vulnerable dependency is fixed by upgrading it, a
+// code finding by editing the file at its line
+const (
+	KindDependency = "dependency"
+	KindCode       = "code"
+)
+
+// Analyzer is one external security tool: when it applies to a project,
+// the command that runs it, and how to read its JSON output
+type Analyzer struct {
+	Name string
+	// Applies reports whether the project in dir has something the tool
+	// scans
+	Applies func(dir string) bool
+	// Command returns the command line to run in dir, the executable first
+	Command func(dir string) []string
+	// Parse turns the tool's output into findings, with files relative to
+	// dir
+	Parse func(output []byte, dir string) ([]Vulnerability, error)
+}
+
+// Analyzers are the tools security scan knows, run in this order
+var Analyzers = []Analyzer{
+	{
+		Name:    "govulncheck",
+		Applies: hasAny("go.mod"),
+		Command: static("govulncheck", "-json", "./..."),
+		Parse:   parseGovulncheck,
+	},
+	{
+		Name:    "gosec",
+		Applies: hasAny("go.mod"),
+		Command: static("gosec", "-fmt=json", "-quiet", "-exclude-dir=vendor", "./..."),
+		Parse:   parseGosec,
+	},
+	{
+		Name:    "npm-audit",
+		Applies: hasAny("package-lock.json", "npm-shrinkwrap.json"),
+		Command: static("npm", "audit", "--json"),
+		Parse:   parseNpmAudit,
+	},
+	{
+		Name:    "bandit",
+		Applies: hasAny("requirements.txt", "pyproject.toml", "setup.py", "Pipfile"),
+		Command: static("bandit", "-r", ".", "-f", "json", "-q", "-x", "./.venv,./venv,./node_modules,./tests,./test"),
+		Parse:   parseBandit,
+	},
+	{
+		Name:    "pip-audit",
+		Applies: hasAny("requirements.txt", "pyproject.toml"),
+		Command: func(dir string) []string {
+			args := []string{"pip-audit", "-f", "json", "--progress-spinner", "off"}
+			if fileExists(filepath.Join(dir, "requirements.txt")) {
+				return append(args, "-r", "requirements.txt")
+			}
+			return append(args, ".")
+		},
+		Parse: parsePipAudit,
+	},
+	{
+		Name:    "bundle-audit",
+		Applies: hasAny("Gemfile.lock"),
+		Command: static("bundle", "audit", "check"),
+		Parse:   parseBundleAudit,
+	},
+	{
+		Name:    "trivy",
+		Applies: hasAny("Dockerfile", "Containerfile", "docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"),
+		Command: static("trivy", "fs", "--format", "json", "--quiet", "--scanners", "vuln,misconfig", "."),
+		Parse:   parseTrivy,
+	},
+}
+
+// TrivyImage is the analyzer that scans a built container image
+func TrivyImage(image string) Analyzer {
+	return Analyzer{
+		Name:    "trivy-image",
+		Applies: func(string) bool { return true },
+		Command: static("trivy", "image", "--format", "json", "--quiet", image),
+		Parse:   parseTrivy,
+	}
+}
+
+func static(args ...string) func(string) []string {
+	return func(string) []string { return args }
+}
+
+func hasAny(names ...string) func(string) bool {
+	return func(dir string) bool {
+		for _, name := range names {
+			if fileExists(filepath.Join(dir, name)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// relPath makes a path a tool reports relative to dir; it returns "" for
+// paths that are not files of the project
+func relPath(dir, path string) string {
+	if path == "" {
+		return ""
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") || !fileExists(path) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// normalizeSeverity maps the tools' severities to Critical, High, Medium,
+// Low or Unknown
+func normalizeSeverity(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "critical":
+		return "Critical"
+	case "high", "error":
+		return "High"
+	case "medium", "moderate", "warning":
+		return "Medium"
+	case "low", "info", "negligible":
+		return "Low"
+	default:
+		return "Unknown"
+	}
+}
+
+func severityRank(s string) int {
+	switch s {
+	case "Critical":
+		return 0
+	case "High":
+		return 1
+	case "Medium":
+		return 2
+	case "Low":
+		return 3
+	default:
+		return 4
+	}
+}
+
+func firstCVE(aliases []string) string {
+	for _, a := range aliases {
+		if strings.HasPrefix(a, "CVE-") {
+			return a
+		}
+	}
+	return ""
+}
+
+// parseGovulncheck reads the stream of JSON messages of govulncheck -json.
+// Only vulnerabilities the code calls are reported, at the project's call
+// site.
+func parseGovulncheck(output []byte, dir string) ([]Vulnerability, error) {
+	type frame struct {
+		Module   string `json:"module"`
+		Version  string `json:"version"`
+		Package  string `json:"package"`
+		Function string `json:"function"`
+		Position *struct {
+			Filename string `json:"filename"`
+			Line     int    `json:"line"`
+		} `json:"position"`
+	}
+	type message struct {
+		OSV *struct {
+			ID      string   `json:"id"`
+			Summary string   `json:"summary"`
+			Details string   `json:"details"`
+			Aliases []string `json:"aliases"`
+		} `json:"osv"`
+		Finding *struct {
+			OSV          string  `json:"osv"`
+			FixedVersion string  `json:"fixed_version"`
+			Trace        []frame `json:"trace"`
+		} `json:"finding"`
+	}
+
+	type advisory struct{ summary, cve string }
+	advisories := map[string]advisory{}
+	seen := map[string]bool{}
+	var vulns []Vulnerability
+
+	dec := json.NewDecoder(bytes.NewReader(output))
+	for {
+		var m message
+		if err := dec.Decode(&m); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("govulncheck output: %w", err)
+		}
+		if m.OSV != nil {
+			summary := m.OSV.Summary
+			if summary == "" {
+				summary = m.OSV.Details
+			}
+			advisories[m.OSV.ID] = advisory{summary: summary, cve: firstCVE(m.OSV.Aliases)}
+		}
+		f := m.Finding
+		if f == nil || len(f.Trace) == 0 || f.Trace[0].Function == "" || seen[f.OSV] {
+			continue
+		}
+		seen[f.OSV] = true
+		v := Vulnerability{
+			ID:       f.OSV,
+			Kind:     KindDependency,
+			Severity: "Unknown",
+			Package:  f.Trace[0].Module,
+			Version:  f.Trace[0].Version,
+			Fix:      f.FixedVersion,
+		}
+		// The trace runs from the vulnerable symbol to the project's call
+		if last := f.Trace[len(f.Trace)-1]; last.Position != nil {
+			v.File = relPath(dir, last.Position.Filename)
+			if v.File != "" {
+				v.Line = last.Position.Line
+			}
+		}
+		vulns = append(vulns, v)
+	}
+	// The advisories may come after the findings
+	for i := range vulns {
+		a := advisories[vulns[i].ID]
+		vulns[i].Description = a.summary
+		vulns[i].CVE = a.cve
+	}
+	return vulns, nil
+}
+
+func parseGosec(output []byte, dir string) ([]Vulnerability, error) {
+	if len(bytes.TrimSpace(output)) == 0 {
+		return nil, nil
+	}
+	var report struct {
+		Issues []struct {
+			Severity string `json:"severity"`
+			RuleID   string `json:"rule_id"`
+			Details  string `json:"details"`
+			File     string `json:"file"`
+			Line     string `json:"line"`
+			CWE      struct {
+				ID string `json:"id"`
+			} `json:"cwe"`
+		} `json:"Issues"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("gosec output: %w", err)
+	}
+	var vulns []Vulnerability
+	for _, issue := range report.Issues {
+		// Multi-line issues report a range such as "12-14"
+		line, _ := strconv.Atoi(strings.SplitN(issue.Line, "-", 2)[0])
+		desc := issue.Details
+		if issue.CWE.ID != "" {
+			desc = fmt.Sprintf("%s (CWE-%s)", desc, issue.CWE.ID)
+		}
+		vulns = append(vulns, Vulnerability{
+			ID:          issue.RuleID,
+			Kind:        KindCode,
+			Severity:    normalizeSeverity(issue.Severity),
+			File:        relPath(dir, issue.File),
+			Line:        line,
+			Description: desc,
+		})
+	}
+	return vulns, nil
+}
+
+// parseNpmAudit reads the npm 7+ report. Packages only vulnerable through
+// another vulnerable dependency are left out; fixing that one fixes them.
+func parseNpmAudit(output []byte, dir string) ([]Vulnerability, error) {
+	var report struct {
+		Error *struct {
+			Code    string `json:"code"`
+			Summary string `json:"summary"`
+		} `json:"error"`
+		Vulnerabilities map[string]struct {
+			Name         string            `json:"name"`
+			Severity     string            `json:"severity"`
+			Range        string            `json:"range"`
+			Via          []json.RawMessage `json:"via"`
+			FixAvailable json.RawMessage   `json:"fixAvailable"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("npm audit output: %w", err)
+	}
+	if report.Error != nil {
+		return nil, fmt.Errorf("npm audit: %s: %s", report.Error.Code, report.Error.Summary)
+	}
+
+	names := make([]string, 0, len(report.Vulnerabilities))
+	for name := range report.Vulnerabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var vulns []Vulnerability
+	for _, name := range names {
+		pkg := report.Vulnerabilities[name]
+		var ids, titles []string
+		for _, raw := range pkg.Via {
+			var advisory struct {
+				Title string `json:"title"`
+				URL   string `json:"url"`
+			}
+			// Entries that are plain strings name another package
+			if json.Unmarshal(raw, &advisory) != nil {
+				continue
+			}
+			ids = append(ids, filepath.Base(advisory.URL))
+			titles = append(titles, advisory.Title)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		v := Vulnerability{
+			ID:          strings.Join(ids, ", "),
+			Kind:        KindDependency,
+			Severity:    normalizeSeverity(pkg.Severity),
+			Package:     name,
+			Version:     pkg.Range,
+			Description: strings.Join(titles, "; "),
+		}
+		var fix struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(pkg.FixAvailable, &fix) == nil && fix.Name != "" {
+			v.Fix = fix.Name + "@" + fix.Version
+		} else if string(pkg.FixAvailable) == "true" {
+			v.Fix = "npm audit fix"
+		}
+		vulns = append(vulns, v)
+	}
+	return vulns, nil
+}
+
+func parseBandit(output []byte, dir string) ([]Vulnerability, error) {
+	var report struct {
+		Results []struct {
+			Filename string `json:"filename"`
+			Line     int    `json:"line_number"`
+			Severity string `json:"issue_severity"`
+			Text     string `json:"issue_text"`
+			TestID   string `json:"test_id"`
+			IssueCWE struct {
+				ID int `json:"id"`
+			} `json:"issue_cwe"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("bandit output: %w", err)
+	}
+	var vulns []Vulnerability
+	for _, r := range report.Results {
+		desc := r.Text
+		if r.IssueCWE.ID != 0 {
+			desc = fmt.Sprintf("%s (CWE-%d)", desc, r.IssueCWE.ID)
+		}
+		vulns = append(vulns, Vulnerability{
+			ID:          r.TestID,
+			Kind:        KindCode,
+			Severity:    normalizeSeverity(r.Severity),
+			File:        relPath(dir, r.Filename),
+			Line:        r.Line,
+			Description: desc,
+		})
+	}
+	return vulns, nil
+}
+
+// parsePipAudit reads pip-audit's JSON, either the current object or the
+// bare list of older versions
+func parsePipAudit(output []byte, dir string) ([]Vulnerability, error) {
+	type dependency struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Vulns   []struct {
+			ID          string   `json:"id"`
+			FixVersions []string `json:"fix_versions"`
+			Aliases     []string `json:"aliases"`
+			Description string   `json:"description"`
+		} `json:"vulns"`
+	}
+	var report struct {
+		Dependencies []dependency `json:"dependencies"`
+	}
+	trimmed := bytes.TrimSpace(output)
+	var err error
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		err = json.Unmarshal(trimmed, &report.Dependencies)
+	} else {
+		err = json.Unmarshal(trimmed, &report)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("pip-audit output: %w", err)
+	}
+
+	var vulns []Vulnerability
+	for _, dep := range report.Dependencies {
+		for _, pv := range dep.Vulns {
+			v := Vulnerability{
+				ID:          pv.ID,
+				Kind:        KindDependency,
+				Severity:    "Unknown",
+				Package:     dep.Name,
+				Version:     dep.Version,
+				Description: pv.Description,
+				CVE:         firstCVE(pv.Aliases),
+			}
+			if len(pv.FixVersions) > 0 {
+				v.Fix = pv.FixVersions[0]
+			}
+			vulns = append(vulns, v)
+		}
+	}
+	return vulns, nil
+}
+
+// parseBundleAudit reads the blocks of "Key: value" lines bundle audit
+// prints for each advisory
+func parseBundleAudit(output []byte, dir string) ([]Vulnerability, error) {
+	var vulns []Vulnerability
+	var v *Vulnerability
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Name":
+			vulns = append(vulns, Vulnerability{Package: value, Kind: KindDependency, Severity: "Unknown", File: relPath(dir, "Gemfile.lock")})
+			v = &vulns[len(vulns)-1]
+		case "Version":
+			if v != nil {
+				v.Version = value
+			}
+		case "CVE", "Advisory":
+			if v != nil && v.ID == "" {
+				v.ID = value
+				if strings.HasPrefix(value, "CVE-") {
+					v.CVE = value
+				}
+			}
+		case "Criticality":
+			if v != nil {
+				v.Severity = normalizeSeverity(value)
+			}
+		case "Title":
+			if v != nil {
+				v.Description = value
+			}
+		case "Solution":
+			if v != nil {
+				v.Fix = value
+			}
+		}
+	}
+	return vulns, nil
+}
+
+func parseTrivy(output []byte, dir string) ([]Vulnerability, error) {
+	var report struct {
+		Results []struct {
+			Target          string `json:"Target"`
+			Vulnerabilities []struct {
+				VulnerabilityID  string `json:"VulnerabilityID"`
+				PkgName          string `json:"PkgName"`
+				InstalledVersion string `json:"InstalledVersion"`
+				FixedVersion     string `json:"FixedVersion"`
+				Severity         string `json:"Severity"`
+				Title            string `json:"Title"`
+			} `json:"Vulnerabilities"`
+			Misconfigurations []struct {
+				ID            string `json:"ID"`
+				Title         string `json:"Title"`
+				Message       string `json:"Message"`
+				Resolution    string `json:"Resolution"`
+				Severity      string `json:"Severity"`
+				CauseMetadata struct {
+					StartLine int `json:"StartLine"`
+				} `json:"CauseMetadata"`
+			} `json:"Misconfigurations"`
+		} `json:"Results"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("trivy output: %w", err)
+	}
+
+	var vulns []Vulnerability
+	for _, r := range report.Results {
+		// Image targets name a layer or the OS, not a file of the project
+		file := relPath(dir, r.Target)
+		for _, tv := range r.Vulnerabilities {
+			v := Vulnerability{
+				ID:          tv.VulnerabilityID,
+				Kind:        KindDependency,
+				Severity:    normalizeSeverity(tv.Severity),
+				Package:     tv.PkgName,
+				Version:     tv.InstalledVersion,
+				File:        file,
+				Description: tv.Title,
+				Fix:         tv.FixedVersion,
+			}
+			if strings.HasPrefix(tv.VulnerabilityID, "CVE-") {
+				v.CVE = tv.VulnerabilityID
+			}
+			vulns = append(vulns, v)
+		}
+		for _, m := range r.Misconfigurations {
+			desc := m.Title
+			if m.Message != "" {
+				desc += ": " + m.Message
+			}
+			vulns = append(vulns, Vulnerability{
+				ID:          m.ID,
+				Kind:        KindCode,
+				Severity:    normalizeSeverity(m.Severity),
+				File:        file,
+				Line:        m.CauseMetadata.StartLine,
+				Description: desc,
+				Fix:         m.Resolution,
+			})
+		}
+	}
+	return vulns, nil
+}
@@ -0,0 +1,216 @@
+package security
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func project(t *testing.T, files ...string) string {
+	dir := t.TempDir()
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestParseGovulncheck(t *testing.T) {
+	dir := project(t, "main.go")
+	output := `{"config":{"scanner_name":"govulncheck"}}
+{"finding":{"osv":"GO-2023-2102","fixed_version":"v0.17.0","trace":[{"module":"golang.org/x/net","version":"v0.10.0","package":"golang.org/x/net/http2","function":"ServeConn"},{"module":"example.com/app","package":"example.com/app","function":"main","position":{"filename":"main.go","line":12}}]}}
+{"finding":{"osv":"GO-2023-2102","fixed_version":"v0.17.0","trace":[{"module":"golang.org/x/net","version":"v0.10.0","package":"golang.org/x/net/http2","function":"ServeConn"}]}}
+{"finding":{"osv":"GO-2024-0001","trace":[{"module":"golang.org/x/text","version":"v0.3.0"}]}}
+{"osv":{"id":"GO-2023-2102","summary":"HTTP/2 rapid reset can cause excessive work","aliases":["CVE-2023-39325","GHSA-4374-p667-p6c8"]}}
+`
+	vulns, err := parseGovulncheck([]byte(output), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vulns) != 1 {
+		t.Fatalf("expected only the called vulnerability, once, got %+v", vulns)
+	}
+	v := vulns[0]
+	if v.Package != "golang.org/x/net" || v.Version != "v0.10.0" || v.Fix != "v0.17.0" || v.Kind != KindDependency {
+		t.Errorf("unexpected finding %+v", v)
+	}
+	if v.Location() != "main.go:12" || v.CVE != "CVE-2023-39325" || !strings.Contains(v.Description, "rapid reset") {
+		t.Errorf("expected the call site and the advisory, got %+v", v)
+	}
+}
+
+func TestParseGosec(t *testing.T) {
+	dir := project(t, "cmd/serve.go")
+	output := `{"Issues":[{"severity":"MEDIUM","confidence":"HIGH","cwe":{"id":"22"},"rule_id":"G304","details":"Potential file inclusion via variable","file":"` + filepath.Join(dir, "cmd/serve.go") + `","line":"41-43"}],"Stats":{}}`
+	vulns, err := parseGosec([]byte(output), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Vulnerability{ID: "G304", Kind: KindCode, Severity: "Medium", File: "cmd/serve.go", Line: 41, Description: "Potential file inclusion via variable (CWE-22)"}
+	if len(vulns) != 1 || vulns[0] != want {
+		t.Errorf("unexpected findings %+v", vulns)
+	}
+}
+
+func TestParseNpmAudit(t *testing.T) {
+	output := `{"auditReportVersion":2,"vulnerabilities":{
+"lodash":{"name":"lodash","severity":"critical","via":[{"source":1094,"name":"lodash","title":"Prototype Pollution in lodash","url":"https://github.com/advisories/GHSA-jf85-cpcp-j695","severity":"critical"}],"range":"<4.17.12","fixAvailable":true},
+"grunt":{"name":"grunt","severity":"moderate","via":["lodash"],"range":"*","fixAvailable":{"name":"grunt","version":"1.6.1","isSemVerMajor":true}},
+"minimist":{"name":"minimist","severity":"moderate","via":[{"title":"Prototype Pollution in minimist","url":"https://github.com/advisories/GHSA-vh95-rmgr-6w4m"}],"range":"<0.2.1","fixAvailable":{"name":"mkdirp","version":"1.0.4"}}}}`
+	vulns, err := parseNpmAudit([]byte(output), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vulns) != 2 {
+		t.Fatalf("expected the packages with advisories, got %+v", vulns)
+	}
+	if v := vulns[0]; v.Package != "lodash" || v.ID != "GHSA-jf85-cpcp-j695" || v.Severity != "Critical" || v.Fix != "npm audit fix" {
+		t.Errorf("unexpected finding %+v", v)
+	}
+	if v := vulns[1]; v.Package != "minimist" || v.Severity != "Medium" || v.Fix != "mkdirp@1.0.4" {
+		t.Errorf("unexpected finding %+v", v)
+	}
+
+	if _, err := parseNpmAudit([]byte(`{"error":{"code":"ENOLOCK","summary":"This command requires an existing lockfile."}}`), ""); err == nil || !strings.Contains(err.Error(), "ENOLOCK") {
+		t.Errorf("expected npm's error, got %v", err)
+	}
+}
+
+func TestParseBandit(t *testing.T) {
+	dir := project(t, "app/run.py")
+	output := `{"errors":[],"results":[{"filename":"./app/run.py","line_number":7,"issue_severity":"HIGH","issue_confidence":"HIGH","issue_text":"subprocess call with shell=True identified, security issue.","test_id":"B602","issue_cwe":{"id":78}}]}`
+	vulns, err := parseBandit([]byte(output), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vulns) != 1 || vulns[0].Location() != "app/run.py:7" || vulns[0].Severity != "High" || !strings.HasSuffix(vulns[0].Description, "(CWE-78)") {
+		t.Errorf("unexpected findings %+v", vulns)
+	}
+}
+
+func TestParsePipAudit(t *testing.T) {
+	for _, output := range []string{
+		`{"dependencies":[{"name":"flask","version":"0.5","vulns":[{"id":"PYSEC-2019-179","fix_versions":["1.0"],"aliases":["CVE-2019-1010083"],"description":"Denial of service"}]},{"name":"click","version":"8.1.7","vulns":[]}],"fixes":[]}`,
+		`[{"name":"flask","version":"0.5","vulns":[{"id":"PYSEC-2019-179","fix_versions":["1.0"],"aliases":["CVE-2019-1010083"],"description":"Denial of service"}]}]`,
+	} {
+		vulns, err := parsePipAudit([]byte(output), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Vulnerability{ID: "PYSEC-2019-179", Kind: KindDependency, Severity: "Unknown", Package: "flask", Version: "0.5", Description: "Denial of service", Fix: "1.0", CVE: "CVE-2019-1010083"}
+		if len(vulns) != 1 || vulns[0] != want {
+			t.Errorf("unexpected findings %+v", vulns)
+		}
+	}
+}
+
+func TestParseBundleAudit(t *testing.T) {
+	output := `Name: actionpack
+Version: 3.2.10
+CVE: CVE-2013-0156
+Criticality: High
+URL: https://groups.google.com/forum/#!topic/rubyonrails-security/61bkgvnSGTQ
+Title: Ruby on Rails params_parser.rb Vulnerability
+Solution: upgrade to ~> 2.3.15, >= 3.2.11
+
+Name: rack
+Version: 1.4.1
+Advisory: OSVDB-89939
+Criticality: Medium
+Title: Rack Cookie Session Timing Attack
+
+Vulnerabilities found!
+`
+	vulns, err := parseBundleAudit([]byte(output), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vulns) != 2 {
+		t.Fatalf("expected two advisories, got %+v", vulns)
+	}
+	if v := vulns[0]; v.Package != "actionpack" || v.CVE != "CVE-2013-0156" || v.Severity != "High" || v.Fix != "upgrade to ~> 2.3.15, >= 3.2.11" {
+		t.Errorf("unexpected finding %+v", v)
+	}
+	if v := vulns[1]; v.ID != "OSVDB-89939" || v.CVE != "" || v.Severity != "Medium" {
+		t.Errorf("unexpected finding %+v", v)
+	}
+}
+
+func TestParseTrivy(t *testing.T) {
+	dir := project(t, "Dockerfile", "go.mod")
+	output := `{"Results":[
+{"Target":"go.mod","Class":"lang-pkgs","Vulnerabilities":[{"VulnerabilityID":"CVE-2023-39325","PkgName":"golang.org/x/net","InstalledVersion":"v0.10.0","FixedVersion":"0.17.0","Severity":"HIGH","Title":"rapid stream resets"}]},
+{"Target":"Dockerfile","Class":"config","Misconfigurations":[{"ID":"DS002","Title":"Image user should not be 'root'","Message":"Specify at least 1 USER command","Resolution":"Add 'USER <non root user name>' line","Severity":"HIGH","CauseMetadata":{"StartLine":3}}]},
+{"Target":"alpine:3.18 (alpine 3.18.0)","Class":"os-pkgs","Vulnerabilities":[{"VulnerabilityID":"CVE-2023-5363","PkgName":"libcrypto3","InstalledVersion":"3.1.0-r4","Severity":"LOW"}]}]}`
+	vulns, err := parseTrivy([]byte(output), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vulns) != 3 {
+		t.Fatalf("unexpected findings %+v", vulns)
+	}
+	if v := vulns[0]; v.File != "go.mod" || v.CVE != "CVE-2023-39325" || v.Kind != KindDependency || v.Fix != "0.17.0" {
+		t.Errorf("unexpected finding %+v", v)
+	}
+	if v := vulns[1]; v.Location() != "Dockerfile:3" || v.Kind != KindCode || !strings.Contains(v.Description, "USER") || v.Severity != "High" {
+		t.Errorf("unexpected finding %+v", v)
+	}
+	if v := vulns[2]; v.File != "" || v.Severity != "Low" {
+		t.Errorf("expected the image's packages without a file, got %+v", v)
+	}
+}
+
+func TestScanAndFix(t *testing.T) {
+	dir := project(t, "go.mod")
+	script := func(output string) func(string) []string {
+		return static("sh", "-c", "printf '%s' '"+output+"'; exit 1")
+	}
+	s := NewScanner(nil, "", dir)
+	s.analyzers = []Analyzer{
+		{Name: "low", Applies: hasAny("go.mod"), Command: script(`{"Issues":[{"severity":"LOW","rule_id":"G104","file":"a.go","line":"1"}]}`), Parse: parseGosec},
+		{Name: "high", Applies: hasAny("go.mod"), Command: script(`{"Results":[{"Target":"go.mod","Vulnerabilities":[{"VulnerabilityID":"CVE-1","PkgName":"x","Severity":"HIGH"}]}]}`), Parse: parseTrivy},
+		{Name: "again", Applies: hasAny("go.mod"), Command: script(`{"Results":[{"Target":"go.mod","Vulnerabilities":[{"VulnerabilityID":"CVE-1","PkgName":"x","Severity":"HIGH"}]}]}`), Parse: parseTrivy},
+		{Name: "broken", Applies: hasAny("go.mod"), Command: script(`not json`), Parse: parseBandit},
+		{Name: "missing", Applies: hasAny("go.mod"), Command: static("gptcode-no-such-scanner"), Parse: parseBandit},
+		{Name: "other", Applies: hasAny("package-lock.json"), Command: static("sh"), Parse: parseBandit},
+	}
+
+	report, err := s.ScanAndFix(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(report.Tools, ",") != "low,high,again,broken" || strings.Join(report.Skipped, ",") != "missing" {
+		t.Errorf("unexpected tools %v, skipped %v", report.Tools, report.Skipped)
+	}
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0].Error(), "broken") {
+		t.Errorf("expected the broken tool's error, got %v", report.Errors)
+	}
+	if len(report.Vulnerabilities) != 2 {
+		t.Fatalf("expected the duplicate dropped, got %+v", report.Vulnerabilities)
+	}
+	if v := report.Vulnerabilities[0]; v.Tool != "high" || v.Severity != "High" {
+		t.Errorf("expected the most severe first, got %+v", v)
+	}
+}
+
+func TestFixPrompt(t *testing.T) {
+	dir := t.TempDir()
+	src := "package main\n\nimport \"os\"\n\nfunc read(p string) ([]byte, error) {\n\treturn os.ReadFile(p)\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "read.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := NewScanner(nil, "", dir)
+	prompt := s.fixPrompt(Vulnerability{ID: "G304", Tool: "gosec", Kind: KindCode, Severity: "Medium", File: "read.go", Line: 6, Description: "Potential file inclusion via variable"})
+	for _, want := range []string{"reported by gosec in read.go:6", "ID: G304", ">   6  \treturn os.ReadFile(p)", "    5  func read", "edit only that file"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected %q in the prompt:\n%s", want, prompt)
+		}
+	}
+}
@@ -1,423 +1,329 @@
 package security
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
+	"gptcode/internal/agents"
 	"gptcode/internal/langdetect"
 	"gptcode/internal/llm"
 )
 
 type Vulnerability struct {
-	ID          string
-	Severity    string
-	Package     string
-	Version     string
+	ID string
+	// Tool is the analyzer that reported the finding
+	Tool string
+	// Kind is KindDependency or KindCode
+	Kind     string
+	Severity string
+	Package  string
+	Version  string
+	// File and Line locate the finding in the project, relative to its
+	// root: the code at fault, or the call of a vulnerable dependency
 	File        string
 	Line        int
 	Description string
-	Fix         string
-	CVE         string
+	// Fix is the version that fixes a dependency, or the tool's advice
+	Fix string
+	CVE string
+}
+
+// Location is the finding's file:line, or its file
+func (v Vulnerability) Location() string {
+	if v.File == "" || v.Line == 0 {
+		return v.File
+	}
+	return fmt.Sprintf("%s:%d", v.File, v.Line)
 }
 
 type SecurityReport struct {
 	Language        string
 	Vulnerabilities []Vulnerability
-	FixedCount      int
-	UpdatedFiles    []string
-	Errors          []error
+	// Tools are the analyzers that ran, Skipped those that apply to the
+	// project but are not installed
+	Tools        []string
+	Skipped      []string
+	FixedCount   int
+	UpdatedFiles []string
+	Errors       []error
 }
 
 type Scanner struct {
-	provider llm.Provider
-	model    string
-	workDir  string
+	provider  llm.Provider
+	model     string
+	workDir   string
+	analyzers []Analyzer
 }
 
 func NewScanner(provider llm.Provider, model, workDir string) *Scanner {
 	return &Scanner{
-		provider: provider,
-		model:    model,
-		workDir:  workDir,
+		provider:  provider,
+		model:     model,
+		workDir:   workDir,
+		analyzers: Analyzers,
 	}
 }
 
-func (s *Scanner) ScanAndFix(ctx context.Context, autofix bool) (*SecurityReport, error) {
-	lang := langdetect.DetectLanguage(s.workDir)
-
-	vulns, err := s.scanVulnerabilities(lang)
-	if err != nil {
-		return nil, fmt.Errorf("scan failed: %w", err)
-	}
+// AddImage also scans a container image with trivy
+func (s *Scanner) AddImage(image string) {
+	s.analyzers = append(s.analyzers, TrivyImage(image))
+}
 
+// ScanAndFix runs every analyzer that applies to the project and is
+// installed, and merges their findings, the most severe first. With
+// autofix, vulnerable dependencies are upgraded with the package manager
+// and code findings are handed to the editor agent at their file and line.
+// A failing analyzer is recorded in the report's errors.
+func (s *Scanner) ScanAndFix(ctx context.Context, autofix bool) (*SecurityReport, error) {
 	report := &SecurityReport{
-		Language:        string(lang),
-		Vulnerabilities: vulns,
+		Language: string(langdetect.DetectLanguage(s.workDir)),
 	}
 
-	if !autofix || len(vulns) == 0 {
-		return report, nil
-	}
-
-	for _, vuln := range vulns {
-		if err := s.fixVulnerability(ctx, vuln, lang); err != nil {
-			report.Errors = append(report.Errors, fmt.Errorf("failed to fix %s: %w", vuln.ID, err))
-		} else {
-			report.FixedCount++
-			if vuln.File != "" && !contains(report.UpdatedFiles, vuln.File) {
-				report.UpdatedFiles = append(report.UpdatedFiles, vuln.File)
-			}
+	for _, a := range s.analyzers {
+		if !a.Applies(s.workDir) {
+			continue
 		}
-	}
-
-	return report, nil
-}
-
-func (s *Scanner) scanVulnerabilities(lang langdetect.Language) ([]Vulnerability, error) {
-	switch lang {
-	case langdetect.Go:
-		return s.scanGo()
-	case langdetect.TypeScript:
-		return s.scanNode()
-	case langdetect.Python:
-		return s.scanPython()
-	case langdetect.Ruby:
-		return s.scanRuby()
-	default:
-		return nil, fmt.Errorf("unsupported language: %s", lang)
-	}
-}
-
-func (s *Scanner) scanGo() ([]Vulnerability, error) {
-	cmd := exec.Command("govulncheck", "-json", "./...")
-	cmd.Dir = s.workDir
-	output, err := cmd.Output()
-
-	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok && len(exitErr.Stderr) > 0 {
-			output = exitErr.Stderr
+		argv := a.Command(s.workDir)
+		if _, err := exec.LookPath(argv[0]); err != nil {
+			report.Skipped = append(report.Skipped, a.Name)
+			continue
+		}
+		report.Tools = append(report.Tools, a.Name)
+		vulns, err := s.run(ctx, a, argv)
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+			continue
 		}
+		report.Vulnerabilities = append(report.Vulnerabilities, vulns...)
 	}
+	report.Vulnerabilities = dedupe(report.Vulnerabilities)
 
-	return s.parseGovulncheck(string(output))
-}
-
-func (s *Scanner) parseGovulncheck(output string) ([]Vulnerability, error) {
-	var vulns []Vulnerability
+	if !autofix || len(report.Vulnerabilities) == 0 {
+		return report, nil
+	}
 
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if line == "" || !strings.Contains(line, "vulnerability") {
+	fixed := map[string]bool{}
+	for _, vuln := range report.Vulnerabilities {
+		files, err := s.fixVulnerability(ctx, vuln, fixed)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Errorf("failed to fix %s: %w", vuln.ID, err))
 			continue
 		}
-
-		if strings.Contains(line, "GO-") {
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				vuln := Vulnerability{
-					ID:          extractID(line, "GO-"),
-					Severity:    extractSeverity(line),
-					Package:     extractPackage(line),
-					Description: line,
-				}
-				vulns = append(vulns, vuln)
+		report.FixedCount++
+		for _, file := range files {
+			if !contains(report.UpdatedFiles, file) {
+				report.UpdatedFiles = append(report.UpdatedFiles, file)
 			}
 		}
 	}
 
-	return vulns, nil
+	return report, nil
 }
 
-func (s *Scanner) scanNode() ([]Vulnerability, error) {
-	cmd := exec.Command("npm", "audit", "--json")
+// run runs an analyzer. The tools exit non-zero when they find something,
+// so their output is read whenever there is some.
+func (s *Scanner) run(ctx context.Context, a Analyzer, argv []string) ([]Vulnerability, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
 	cmd.Dir = s.workDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
 	output, err := cmd.Output()
-
-	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok {
-			output = exitErr.Stderr
+	if len(bytes.TrimSpace(output)) == 0 {
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w: %s", a.Name, err, strings.TrimSpace(stderr.String()))
 		}
+		return nil, nil
 	}
 
-	return s.parseNpmAudit(string(output))
-}
-
-func (s *Scanner) parseNpmAudit(output string) ([]Vulnerability, error) {
-	var vulns []Vulnerability
-
-	if !strings.Contains(output, "vulnerabilities") {
-		return vulns, nil
+	vulns, perr := a.Parse(output, s.workDir)
+	if perr != nil {
+		return nil, fmt.Errorf("%s: %w", a.Name, perr)
 	}
-
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "severity") {
-			vuln := Vulnerability{
-				Severity:    extractSeverity(line),
-				Description: line,
-			}
-			vulns = append(vulns, vuln)
-		}
+	for i := range vulns {
+		vulns[i].Tool = a.Name
 	}
-
 	return vulns, nil
 }
 
-func (s *Scanner) scanPython() ([]Vulnerability, error) {
-	cmd := exec.Command("safety", "check", "--json")
-	cmd.Dir = s.workDir
-	output, err := cmd.Output()
-
-	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok {
-			output = exitErr.Stderr
+// dedupe drops findings reported by several tools, such as a Go module
+// both govulncheck and trivy flag, and sorts the rest by severity
+func dedupe(vulns []Vulnerability) []Vulnerability {
+	seen := map[string]bool{}
+	var out []Vulnerability
+	for _, v := range vulns {
+		key := v.ID
+		if v.CVE != "" {
+			key = v.CVE
 		}
-	}
-
-	return s.parseSafety(string(output))
-}
-
-func (s *Scanner) parseSafety(output string) ([]Vulnerability, error) {
-	var vulns []Vulnerability
-
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "vulnerability") || strings.Contains(line, "CVE") {
-			vuln := Vulnerability{
-				CVE:         extractCVE(line),
-				Description: line,
-			}
-			vulns = append(vulns, vuln)
+		key += "|" + v.Package
+		if v.Kind == KindCode {
+			key += "|" + v.Location()
 		}
-	}
-
-	return vulns, nil
-}
-
-func (s *Scanner) scanRuby() ([]Vulnerability, error) {
-	cmd := exec.Command("bundle", "audit", "check")
-	cmd.Dir = s.workDir
-	output, err := cmd.Output()
-
-	if err != nil {
-		exitErr, ok := err.(*exec.ExitError)
-		if ok {
-			output = exitErr.Stderr
+		if seen[key] {
+			continue
 		}
+		seen[key] = true
+		out = append(out, v)
 	}
-
-	return s.parseBundleAudit(string(output))
+	sort.SliceStable(out, func(i, j int) bool {
+		return severityRank(out[i].Severity) < severityRank(out[j].Severity)
+	})
+	return out
 }
 
-func (s *Scanner) parseBundleAudit(output string) ([]Vulnerability, error) {
-	var vulns []Vulnerability
+// fixVulnerability fixes one finding and returns the files it changed.
+// fixed remembers project-wide fixes, such as npm audit fix, already run.
+func (s *Scanner) fixVulnerability(ctx context.Context, vuln Vulnerability, fixed map[string]bool) ([]string, error) {
+	if vuln.Kind == KindCode {
+		return s.fixWithEditor(ctx, vuln)
+	}
 
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if strings.Contains(line, "CVE") || strings.Contains(line, "vulnerability") {
-			vuln := Vulnerability{
-				CVE:         extractCVE(line),
-				Description: line,
+	switch vuln.Tool {
+	case "govulncheck":
+		return s.fixGoVulnerability(ctx, vuln)
+	case "npm-audit":
+		if !fixed["npm-audit"] {
+			if err := s.command(ctx, "npm", "audit", "fix"); err != nil {
+				return nil, err
 			}
-			vulns = append(vulns, vuln)
+			fixed["npm-audit"] = true
+		}
+		return []string{"package.json", "package-lock.json"}, nil
+	case "pip-audit":
+		return s.fixPythonVulnerability(ctx, vuln)
+	case "bundle-audit":
+		if err := s.command(ctx, "bundle", "update", vuln.Package); err != nil {
+			return nil, err
 		}
+		return []string{"Gemfile.lock"}, nil
 	}
 
-	return vulns, nil
-}
-
-func (s *Scanner) fixVulnerability(ctx context.Context, vuln Vulnerability, lang langdetect.Language) error {
-	switch lang {
-	case langdetect.Go:
-		return s.fixGoVulnerability(ctx, vuln)
-	case langdetect.TypeScript:
-		return s.fixNodeVulnerability(vuln)
-	case langdetect.Python:
-		return s.fixPythonVulnerability(vuln)
-	case langdetect.Ruby:
-		return s.fixRubyVulnerability(vuln)
-	default:
-		return fmt.Errorf("unsupported language: %s", lang)
+	// Dependencies found elsewhere, e.g. by trivy, are bumped in their
+	// manifest
+	if vuln.File == "" || vuln.Fix == "" {
+		return nil, fmt.Errorf("no automatic fix for %s", vuln.Package)
 	}
+	return s.fixWithEditor(ctx, vuln)
 }
 
-func (s *Scanner) fixGoVulnerability(ctx context.Context, vuln Vulnerability) error {
-	if vuln.Package == "" {
-		return fmt.Errorf("no package specified")
+func (s *Scanner) fixGoVulnerability(ctx context.Context, vuln Vulnerability) ([]string, error) {
+	if vuln.Package == "" || vuln.Package == "stdlib" || vuln.Package == "toolchain" {
+		return nil, fmt.Errorf("%s is fixed by upgrading Go to %s", vuln.Package, vuln.Fix)
 	}
 
-	cmd := exec.Command("go", "get", "-u", vuln.Package)
-	cmd.Dir = s.workDir
-	output, err := cmd.CombinedOutput()
-
-	if err != nil {
-		return s.fixWithLLM(ctx, vuln, string(output))
+	version := vuln.Fix
+	if version == "" {
+		version = "latest"
 	}
-
-	cmd = exec.Command("go", "mod", "tidy")
-	cmd.Dir = s.workDir
-	return cmd.Run()
-}
-
-func (s *Scanner) fixNodeVulnerability(vuln Vulnerability) error {
-	cmd := exec.Command("npm", "audit", "fix")
-	cmd.Dir = s.workDir
-	return cmd.Run()
+	if err := s.command(ctx, "go", "get", vuln.Package+"@"+version); err != nil {
+		return nil, err
+	}
+	if err := s.command(ctx, "go", "mod", "tidy"); err != nil {
+		return nil, err
+	}
+	return []string{"go.mod", "go.sum"}, nil
 }
 
-func (s *Scanner) fixPythonVulnerability(vuln Vulnerability) error {
+func (s *Scanner) fixPythonVulnerability(ctx context.Context, vuln Vulnerability) ([]string, error) {
 	if vuln.Package == "" {
-		return fmt.Errorf("no package specified")
+		return nil, fmt.Errorf("no package specified")
 	}
 
-	cmd := exec.Command("pip", "install", "--upgrade", vuln.Package)
-	cmd.Dir = s.workDir
-	return cmd.Run()
-}
-
-func (s *Scanner) fixRubyVulnerability(vuln Vulnerability) error {
-	cmd := exec.Command("bundle", "update")
-	cmd.Dir = s.workDir
-	return cmd.Run()
-}
-
-func (s *Scanner) fixWithLLM(ctx context.Context, vuln Vulnerability, errorOutput string) error {
-	files, err := s.findVulnerableFiles(vuln)
-	if err != nil || len(files) == 0 {
-		return fmt.Errorf("no vulnerable files found")
+	// A pinned requirement is raised in requirements.txt, which the
+	// editor does with the fix version; otherwise the environment is
+	// upgraded
+	if fileExists(filepath.Join(s.workDir, "requirements.txt")) && vuln.Fix != "" {
+		vuln.File = "requirements.txt"
+		return s.fixWithEditor(ctx, vuln)
 	}
-
-	for _, file := range files {
-		content, err := os.ReadFile(file)
-		if err != nil {
-			continue
-		}
-
-		prompt := fmt.Sprintf(`Fix security vulnerability in code:
-
-Vulnerability: %s
-ID: %s
-Severity: %s
-Package: %s
-Description: %s
-
-Error when trying to update: %s
-
-File: %s
-Content:
-%s
-
-Provide a secure fix. Return ONLY the complete updated file content.`,
-			vuln.ID, vuln.ID, vuln.Severity, vuln.Package, vuln.Description,
-			errorOutput, file, string(content))
-
-		resp, err := s.provider.Chat(ctx, llm.ChatRequest{
-			SystemPrompt: "You are a security expert that fixes vulnerabilities safely and correctly.",
-			UserPrompt:   prompt,
-			Model:        s.model,
-		})
-
-		if err != nil {
-			return err
-		}
-
-		updated := s.extractCode(resp.Text)
-		if err := os.WriteFile(file, []byte(updated), 0644); err != nil {
-			return err
-		}
-
-		vuln.File = file
+	spec := vuln.Package
+	if vuln.Fix != "" {
+		spec += ">=" + vuln.Fix
 	}
-
-	return nil
+	return nil, s.command(ctx, "pip", "install", "--upgrade", spec)
 }
 
-func (s *Scanner) findVulnerableFiles(vuln Vulnerability) ([]string, error) {
-	if vuln.Package == "" {
-		return nil, nil
+// fixWithEditor asks the editor agent to fix the finding in its file, and
+// only there
+func (s *Scanner) fixWithEditor(ctx context.Context, vuln Vulnerability) ([]string, error) {
+	if vuln.File == "" {
+		return nil, fmt.Errorf("no file to fix")
+	}
+	if s.provider == nil {
+		return nil, fmt.Errorf("no model configured")
 	}
 
-	pkgName := filepath.Base(vuln.Package)
-
-	cmd := exec.Command("grep", "-rl", "--include=*.go", pkgName, s.workDir)
-	output, err := cmd.Output()
+	editor := agents.NewEditorWithFileValidation(s.provider, s.workDir, s.model, []string{vuln.File})
+	_, modified, err := editor.Execute(ctx, []llm.ChatMessage{{Role: "user", Content: s.fixPrompt(vuln)}}, nil)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
-
-	files := strings.Split(strings.TrimSpace(string(output)), "\n")
-	var result []string
-	for _, f := range files {
-		if f != "" && !strings.Contains(f, "vendor/") {
-			result = append(result, f)
-		}
+	if len(modified) == 0 {
+		return nil, fmt.Errorf("editor made no changes to %s", vuln.File)
 	}
-
-	return result, nil
+	return []string{vuln.File}, nil
 }
 
-func (s *Scanner) extractCode(text string) string {
-	text = strings.TrimSpace(text)
-
-	if strings.HasPrefix(text, "```go") {
-		text = strings.TrimPrefix(text, "```go\n")
-		text = strings.TrimSuffix(text, "```")
-	} else if strings.HasPrefix(text, "```") {
-		text = strings.TrimPrefix(text, "```\n")
-		text = strings.TrimSuffix(text, "```")
+func (s *Scanner) fixPrompt(vuln Vulnerability) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Fix this security finding reported by %s in %s:\n\n", vuln.Tool, vuln.Location())
+	fmt.Fprintf(&b, "ID: %s\nSeverity: %s\n", vuln.ID, vuln.Severity)
+	if vuln.CVE != "" && vuln.CVE != vuln.ID {
+		fmt.Fprintf(&b, "CVE: %s\n", vuln.CVE)
 	}
-
-	return strings.TrimSpace(text)
-}
-
-func extractID(line, prefix string) string {
-	if idx := strings.Index(line, prefix); idx != -1 {
-		end := idx + len(prefix)
-		for end < len(line) && (line[end] == '-' || (line[end] >= '0' && line[end] <= '9')) {
-			end++
-		}
-		return line[idx:end]
+	if vuln.Package != "" {
+		fmt.Fprintf(&b, "Package: %s %s\n", vuln.Package, vuln.Version)
 	}
-	return ""
-}
-
-func extractSeverity(line string) string {
-	lower := strings.ToLower(line)
-	severities := []string{"critical", "high", "medium", "moderate", "low"}
-	for _, sev := range severities {
-		if strings.Contains(lower, sev) {
-			return strings.ToUpper(sev[:1]) + sev[1:]
-		}
+	if vuln.Description != "" {
+		fmt.Fprintf(&b, "Description: %s\n", vuln.Description)
+	}
+	if vuln.Fix != "" {
+		fmt.Fprintf(&b, "Suggested fix: %s\n", vuln.Fix)
 	}
-	return "Unknown"
+	if snippet := sourceAround(filepath.Join(s.workDir, vuln.File), vuln.Line, 10); snippet != "" {
+		fmt.Fprintf(&b, "\nCode around line %d:\n```\n%s\n```\n", vuln.Line, snippet)
+	}
+	fmt.Fprintf(&b, "\nRead %s, make the smallest change that removes the issue without changing behavior, and edit only that file. Do not silence the finding with a suppression comment.", vuln.File)
+	return b.String()
 }
 
-func extractPackage(line string) string {
-	parts := strings.Fields(line)
-	for i, part := range parts {
-		if strings.Contains(part, "/") && i+1 < len(parts) {
-			return parts[i+1]
+// sourceAround returns the lines of path around line, numbered
+func sourceAround(path string, line, context int) string {
+	if line <= 0 {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(data), "\n")
+	if line > len(lines) {
+		return ""
+	}
+	var b strings.Builder
+	for i := max(1, line-context); i <= min(len(lines), line+context); i++ {
+		marker := " "
+		if i == line {
+			marker = ">"
 		}
+		fmt.Fprintf(&b, "%s%4d  %s\n", marker, i, lines[i-1])
 	}
-	return ""
+	return strings.TrimRight(b.String(), "\n")
 }
 
-func extractCVE(line string) string {
-	if idx := strings.Index(line, "CVE-"); idx != -1 {
-		end := idx + 4
-		for end < len(line) && (line[end] == '-' || (line[end] >= '0' && line[end] <= '9')) {
-			end++
-		}
-		return line[idx:end]
+func (s *Scanner) command(ctx context.Context, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = s.workDir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
 	}
-	return ""
+	return nil
 }
 
 func contains(slice []string, item string) bool {
@@ -0,0 +1,84 @@
+package selfupdate
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CheckInterval is how often the background check contacts GitHub
+const CheckInterval = 24 * time.Hour
+
+// checkState is cached in ~/.gptcode/update_check.json so the notice costs
+// no network round trip on most runs.
+type checkState struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Channel   string    `json:"channel"`
+	Latest    string    `json:"latest"`
+}
+
+func statePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gptcode", "update_check.json")
+}
+
+func loadState() checkState {
+	var s checkState
+	if path := statePath(); path != "" {
+		if data, err := os.ReadFile(path); err == nil {
+			_ = json.Unmarshal(data, &s)
+		}
+	}
+	return s
+}
+
+func saveState(s checkState) {
+	path := statePath()
+	if path == "" {
+		return
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+	_ = os.MkdirAll(filepath.Dir(path), 0o755)
+	_ = os.WriteFile(path, data, 0o644)
+}
+
+// Refresh updates the cached latest version when it is older than
+// CheckInterval or was recorded for another channel. Errors are ignored;
+// the notice is best effort.
+func (u *Updater) Refresh(ctx context.Context, channel string) {
+	state := loadState()
+	if state.Channel == channel && time.Since(state.CheckedAt) < CheckInterval {
+		return
+	}
+	releases, err := u.Releases(ctx)
+	if err != nil {
+		return
+	}
+	state = checkState{CheckedAt: time.Now(), Channel: channel}
+	if latest, ok := Latest(releases, channel); ok {
+		state.Latest = latest.Tag
+	}
+	saveState(state)
+}
+
+// Notice returns a one-line "new version available" message based on the
+// cached check, or "" when current is up to date or unknown.
+func Notice(current, channel string) string {
+	if current == "" || current == "dev" {
+		return ""
+	}
+	state := loadState()
+	if state.Latest == "" || state.Channel != channel || CompareVersions(state.Latest, current) <= 0 {
+		return ""
+	}
+	return "A new gptcode version is available: " + current + " → " + state.Latest +
+		". Run `gptcode self-update` to upgrade (disable with update.check: false)."
+}
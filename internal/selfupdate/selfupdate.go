@@ -0,0 +1,379 @@
+// Package selfupdate finds, verifies and installs gptcode releases published
+// on GitHub.
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReleasesRepo is the public repository releases are published to
+const ReleasesRepo = "gptcode-cloud/cli-releases"
+
+// PublicKey is the base64 ed25519 key that signs checksums.txt. Release
+// builds set it from RELEASE_PUBLIC_KEY (see .goreleaser.yml); when empty,
+// downloads are verified by checksum only.
+var PublicKey = ""
+
+// Channels a user can follow
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+)
+
+// Asset is a downloadable file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Release is a GitHub release
+type Release struct {
+	Tag         string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []Asset   `json:"assets"`
+}
+
+// Asset returns the release asset with the given name.
+func (r *Release) Asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Asset{}, false
+}
+
+// Updater talks to the GitHub releases API
+type Updater struct {
+	Repo    string
+	APIBase string
+	Client  *http.Client
+}
+
+// New returns an Updater for the public releases repository.
+func New(client *http.Client) *Updater {
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+	}
+	return &Updater{Repo: ReleasesRepo, APIBase: "https://api.github.com", Client: client}
+}
+
+// Releases lists published releases, newest first.
+func (u *Updater) Releases(ctx context.Context) ([]Release, error) {
+	url := fmt.Sprintf("%s/repos/%s/releases?per_page=50", u.APIBase, u.Repo)
+	body, err := u.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	if err := json.Unmarshal(body, &releases); err != nil {
+		return nil, fmt.Errorf("failed to parse releases: %w", err)
+	}
+	var published []Release
+	for _, r := range releases {
+		if !r.Draft {
+			published = append(published, r)
+		}
+	}
+	sort.SliceStable(published, func(i, j int) bool {
+		return CompareVersions(published[i].Tag, published[j].Tag) > 0
+	})
+	return published, nil
+}
+
+// Latest returns the newest release on channel. The beta channel includes
+// prereleases; stable does not.
+func Latest(releases []Release, channel string) (*Release, bool) {
+	for i := range releases {
+		if releases[i].Prerelease && channel != ChannelBeta {
+			continue
+		}
+		return &releases[i], true
+	}
+	return nil, false
+}
+
+// Between returns the releases newer than current up to and including
+// target, newest first, for showing what changed.
+func Between(releases []Release, current, target string) []Release {
+	var out []Release
+	for _, r := range releases {
+		if CompareVersions(r.Tag, current) > 0 && CompareVersions(r.Tag, target) <= 0 {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// ArchiveName is the release archive for a platform, matching the names
+// produced by the release workflow.
+func ArchiveName(tag, goos, goarch string) string {
+	ext := "tar.gz"
+	if goos == "windows" {
+		ext = "zip"
+	}
+	return fmt.Sprintf("gptcode_%s_%s_%s.%s", strings.TrimPrefix(tag, "v"), goos, goarch, ext)
+}
+
+// Download fetches the archive for this platform and verifies it against
+// the release's checksums.txt (and its signature when PublicKey is set). It
+// returns the extracted gptcode binary.
+func (u *Updater) Download(ctx context.Context, r *Release) ([]byte, error) {
+	name := ArchiveName(r.Tag, runtime.GOOS, runtime.GOARCH)
+	archive, ok := r.Asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s", r.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+	sums, ok := r.Asset("checksums.txt")
+	if !ok {
+		return nil, fmt.Errorf("release %s has no checksums.txt; refusing to install an unverified binary", r.Tag)
+	}
+
+	sumsData, err := u.get(ctx, sums.URL)
+	if err != nil {
+		return nil, err
+	}
+	if PublicKey != "" {
+		sig, ok := r.Asset("checksums.txt.sig")
+		if !ok {
+			return nil, fmt.Errorf("release %s is not signed", r.Tag)
+		}
+		sigData, err := u.get(ctx, sig.URL)
+		if err != nil {
+			return nil, err
+		}
+		if err := VerifySignature(sumsData, sigData, PublicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := u.get(ctx, archive.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyChecksum(data, name, sumsData); err != nil {
+		return nil, err
+	}
+	return ExtractBinary(data, name)
+}
+
+// VerifyChecksum checks data against the sha256sum-format entry for name.
+func VerifyChecksum(data []byte, name string, checksums []byte) error {
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != name {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != strings.ToLower(fields[0]) {
+			return fmt.Errorf("checksum mismatch for %s", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("no checksum listed for %s", name)
+}
+
+// VerifySignature checks a base64 ed25519 signature of data.
+func VerifySignature(data, signature []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("signature verification failed for checksums.txt")
+	}
+	return nil
+}
+
+// ExtractBinary returns the gptcode executable inside a release archive.
+func ExtractBinary(archive []byte, name string) ([]byte, error) {
+	isBinary := func(path string) bool {
+		base := filepath.Base(path)
+		return base == "gptcode" || base == "gptcode.exe"
+	}
+
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if !isBinary(f.Name) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(rc)
+		}
+		return nil, fmt.Errorf("gptcode binary not found in %s", name)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("gptcode binary not found in %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && isBinary(hdr.Name) {
+			return io.ReadAll(tr)
+		}
+	}
+}
+
+// Install atomically replaces the executable at path with binary: the new
+// file is written next to it and renamed over it, so an interrupted update
+// never leaves a partial binary behind.
+func Install(path string, binary []byte) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		path = resolved
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gptcode-update-*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %s: %w", filepath.Dir(path), err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()|0o111); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		// A running executable cannot be replaced, but it can be renamed
+		old := path + ".old"
+		_ = os.Remove(old)
+		if err := os.Rename(path, old); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (u *Updater) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(url, u.APIBase) {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	resp, err := u.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// CompareVersions compares two semantic versions ("v1.2.3", "1.2.3-beta.1")
+// and returns -1, 0 or 1. A prerelease sorts before its release.
+func CompareVersions(a, b string) int {
+	coreA, preA, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	coreB, preB, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	partsA, partsB := strings.Split(coreA, "."), strings.Split(coreB, ".")
+	for i := 0; i < 3; i++ {
+		if c := compareInts(part(partsA, i), part(partsB, i)); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	idsA, idsB := strings.Split(preA, "."), strings.Split(preB, ".")
+	for i := 0; i < len(idsA) && i < len(idsB); i++ {
+		na, errA := strconv.Atoi(idsA[i])
+		nb, errB := strconv.Atoi(idsB[i])
+		var c int
+		if errA == nil && errB == nil {
+			c = compareInts(na, nb)
+		} else {
+			c = strings.Compare(idsA[i], idsB[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(idsA), len(idsB))
+}
+
+func part(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	n, _ := strconv.Atoi(parts[i])
+	return n
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
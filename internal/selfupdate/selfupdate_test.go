@@ -0,0 +1,153 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.2.10", "v1.2.9", 1},
+		{"v1.3.0", "v1.10.0", -1},
+		{"v2.0.0-beta.1", "v2.0.0", -1},
+		{"v2.0.0-beta.2", "v2.0.0-beta.10", -1},
+		{"v2.0.0-rc.1", "v2.0.0-beta.5", 1},
+		{"v1.0", "v1.0.0", 0},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLatestByChannel(t *testing.T) {
+	releases := []Release{
+		{Tag: "v1.3.0-beta.1", Prerelease: true},
+		{Tag: "v1.2.0"},
+		{Tag: "v1.1.0"},
+	}
+	if r, _ := Latest(releases, ChannelStable); r.Tag != "v1.2.0" {
+		t.Errorf("stable = %s", r.Tag)
+	}
+	if r, _ := Latest(releases, ChannelBeta); r.Tag != "v1.3.0-beta.1" {
+		t.Errorf("beta = %s", r.Tag)
+	}
+	if got := Between(releases, "v1.1.0", "v1.2.0"); len(got) != 1 || got[0].Tag != "v1.2.0" {
+		t.Errorf("Between = %+v", got)
+	}
+}
+
+func tarGz(t *testing.T, name string, content []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestDownloadVerifiesChecksumAndSignature(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses tar.gz archives")
+	}
+	tag := "v1.2.0"
+	name := ArchiveName(tag, runtime.GOOS, runtime.GOARCH)
+	archive := tarGz(t, "./gptcode", []byte("new binary"))
+	sum := sha256.Sum256(archive)
+	checksums := []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), name))
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, checksums))
+
+	files := map[string][]byte{"/" + name: archive, "/checksums.txt": checksums, "/checksums.txt.sig": []byte(sig)}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/test/releases/releases" {
+			var assets []Asset
+			for path := range files {
+				assets = append(assets, Asset{Name: path[1:], URL: srv.URL + path})
+			}
+			json.NewEncoder(w).Encode([]Release{{Tag: tag, Assets: assets}, {Tag: "v9.0.0", Draft: true}})
+			return
+		}
+		if data, ok := files[r.URL.Path]; ok {
+			w.Write(data)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	u := &Updater{Repo: "test/releases", APIBase: srv.URL, Client: srv.Client()}
+	releases, err := u.Releases(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(releases) != 1 {
+		t.Fatalf("drafts should be skipped, got %d releases", len(releases))
+	}
+
+	PublicKey = base64.StdEncoding.EncodeToString(pub)
+	defer func() { PublicKey = "" }()
+
+	binary, err := u.Download(context.Background(), &releases[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(binary) != "new binary" {
+		t.Errorf("binary = %q", binary)
+	}
+
+	files["/"+name] = tarGz(t, "gptcode", []byte("tampered"))
+	if _, err := u.Download(context.Background(), &releases[0]); err == nil {
+		t.Error("expected checksum mismatch")
+	}
+
+	files["/"+name] = archive
+	files["/checksums.txt.sig"] = []byte(base64.StdEncoding.EncodeToString(make([]byte, ed25519.SignatureSize)))
+	if _, err := u.Download(context.Background(), &releases[0]); err == nil {
+		t.Error("expected signature failure")
+	}
+}
+
+func TestInstallReplacesBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gptcode")
+	if err := os.WriteFile(path, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Install(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "new" {
+		t.Errorf("binary = %q", data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if runtime.GOOS != "windows" && len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
@@ -0,0 +1,271 @@
+// Package stack tracks stacked pull requests: a large change split into
+// phases, each on a branch made from the one before it and reviewed as its
+// own PR. The stack is kept in .gptcode/stack.json so its branches can be
+// rebased when a phase below them changes.
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gptcode/internal/httpclient"
+)
+
+// Phase is one branch and PR of a stack
+type Phase struct {
+	Title string `json:"title"`
+	// Task is what the phase implements, from its section of the plan
+	Task   string `json:"task"`
+	Branch string `json:"branch"`
+	// Parent is the branch the phase was made from: the previous phase's,
+	// or the stack's base for the first one and once the phases below it
+	// have merged
+	Parent string `json:"parent"`
+	// ParentSHA is the parent commit the phase's own commits sit on; a
+	// parent that moved past it is what Sync rebases onto
+	ParentSHA string `json:"parent_sha,omitempty"`
+	PR        int    `json:"pr,omitempty"`
+	PRURL     string `json:"pr_url,omitempty"`
+	Merged    bool   `json:"merged,omitempty"`
+}
+
+// Stack is the phases of one issue's fix, bottom first
+type Stack struct {
+	Issue   int       `json:"issue"`
+	Title   string    `json:"title"`
+	Base    string    `json:"base"`
+	Created time.Time `json:"created"`
+	Phases  []Phase   `json:"phases"`
+}
+
+// ErrNoStack is returned by Load when the repository has no stack
+var ErrNoStack = errors.New("no stack in this repository; create one with gptcode issue stack create")
+
+// Path is where dir's stack is kept
+func Path(dir string) string {
+	return filepath.Join(dir, ".gptcode", "stack.json")
+}
+
+// Load reads dir's stack
+func Load(dir string) (*Stack, error) {
+	data, err := os.ReadFile(Path(dir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoStack
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Stack
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", Path(dir), err)
+	}
+	return &s, nil
+}
+
+// Save writes the stack to dir, keeping the file out of git so the phase
+// commits don't pick it up
+func (s *Stack) Save(dir string) error {
+	if err := os.MkdirAll(filepath.Dir(Path(dir)), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(Path(dir), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return excludeFromGit(dir)
+}
+
+// excludeFromGit adds the stack file to the repository's info/exclude
+func excludeFromGit(dir string) error {
+	out, err := git(dir, "rev-parse", "--git-path", "info/exclude")
+	if err != nil {
+		return nil
+	}
+	exclude := strings.TrimSpace(out)
+	if !filepath.IsAbs(exclude) {
+		exclude = filepath.Join(dir, exclude)
+	}
+	const pattern = "/.gptcode/stack.json"
+	data, _ := os.ReadFile(exclude)
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		data = append(data, '\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(exclude), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(exclude, append(data, pattern+"\n"...), 0644)
+}
+
+// PhaseSpec is a phase of a plan before it has a branch
+type PhaseSpec struct {
+	Title string
+	Task  string
+}
+
+// ParsePhases splits a Markdown plan at its "## " headings, one phase per
+// heading; "###" subsections stay in their phase, since a phase is
+// reviewed as a whole.
+func ParsePhases(plan string) []PhaseSpec {
+	var phases []PhaseSpec
+	for _, line := range strings.Split(plan, "\n") {
+		if title, ok := strings.CutPrefix(line, "## "); ok {
+			phases = append(phases, PhaseSpec{Title: strings.TrimSpace(title)})
+			continue
+		}
+		if len(phases) > 0 {
+			phases[len(phases)-1].Task += line + "\n"
+		}
+	}
+	for i := range phases {
+		phases[i].Task = strings.TrimSpace(phases[i].Task)
+	}
+	return phases
+}
+
+// BranchName is the branch of phase n (1-based) of a stack whose branches
+// start with prefix, e.g. issue-123-fix-login-part-2
+func BranchName(prefix string, n int) string {
+	return fmt.Sprintf("%s-part-%d", prefix, n)
+}
+
+// Open returns the phases whose PRs have not merged
+func (s *Stack) Open() []*Phase {
+	var open []*Phase
+	for i := range s.Phases {
+		if !s.Phases[i].Merged {
+			open = append(open, &s.Phases[i])
+		}
+	}
+	return open
+}
+
+// Rebase is what Sync did to one phase
+type Rebase struct {
+	Branch string
+	// From and Onto are the parent commits before and after; equal when
+	// the phase was already on its parent
+	From, Onto string
+	// Parent changed when the phase moved to a new parent branch
+	Parent string
+}
+
+// Moved reports whether the phase's branch was rewritten
+func (r Rebase) Moved() bool { return r.From != r.Onto }
+
+// Sync rebases every open phase whose parent moved, bottom up, so each
+// branch sits on the current tip of the one below it. A phase whose parent
+// merged moves onto the stack's base, baseRef, which is normally the
+// freshly fetched remote branch. On a conflict the rebase is aborted and
+// Sync stops with the phases below it done.
+func (s *Stack) Sync(dir, baseRef string) ([]Rebase, error) {
+	var rebases []Rebase
+	parent, parentRef := s.Base, baseRef
+	for i := range s.Phases {
+		p := &s.Phases[i]
+		if p.Merged {
+			continue
+		}
+		onto, err := revParse(dir, parentRef)
+		if err != nil {
+			return rebases, err
+		}
+		r := Rebase{Branch: p.Branch, From: p.ParentSHA, Onto: onto}
+		if p.Parent != parent {
+			r.Parent = parent
+		}
+		if r.Moved() {
+			args := []string{"rebase", "--onto", onto, p.ParentSHA, p.Branch}
+			if p.ParentSHA == "" {
+				args = []string{"rebase", onto, p.Branch}
+			}
+			if _, err := git(dir, args...); err != nil {
+				_, _ = git(dir, "rebase", "--abort")
+				return rebases, fmt.Errorf("rebasing %s onto %s: %w", p.Branch, parent, err)
+			}
+		}
+		p.Parent, p.ParentSHA = parent, onto
+		rebases = append(rebases, r)
+		parent, parentRef = p.Branch, p.Branch
+	}
+	return rebases, nil
+}
+
+// Pull fast-forwards the open phases' branches to remote's copies when
+// those are ahead, e.g. after a reviewer pushed a fix to a phase's PR.
+// Branches that diverged from their remote copy are left alone and
+// returned as diverged, so they are not pushed over. It returns the
+// branches it moved; remote must have been fetched.
+func (s *Stack) Pull(dir, remote string) (pulled, diverged []string, err error) {
+	current := CurrentBranch(dir)
+	for _, p := range s.Open() {
+		upstream := remote + "/" + p.Branch
+		remoteSHA, err := revParse(dir, upstream)
+		if err != nil {
+			continue
+		}
+		localSHA, err := revParse(dir, p.Branch)
+		if err != nil || localSHA == remoteSHA {
+			continue
+		}
+		if _, err := git(dir, "merge-base", "--is-ancestor", localSHA, remoteSHA); err != nil {
+			if _, err := git(dir, "merge-base", "--is-ancestor", remoteSHA, localSHA); err != nil {
+				diverged = append(diverged, p.Branch)
+			}
+			continue
+		}
+		if p.Branch == current {
+			_, err = git(dir, "merge", "--ff-only", "-q", upstream)
+		} else {
+			_, err = git(dir, "update-ref", "refs/heads/"+p.Branch, remoteSHA, localSHA)
+		}
+		if err != nil {
+			return pulled, diverged, err
+		}
+		pulled = append(pulled, p.Branch)
+	}
+	return pulled, diverged, nil
+}
+
+// Head is the commit dir's ref points at
+func Head(dir, ref string) (string, error) {
+	return revParse(dir, ref)
+}
+
+// CurrentBranch is the branch checked out in dir
+func CurrentBranch(dir string) string {
+	out, _ := git(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	return strings.TrimSpace(out)
+}
+
+func revParse(dir, ref string) (string, error) {
+	out, err := git(dir, "rev-parse", "--verify", ref+"^{commit}")
+	return strings.TrimSpace(out), err
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = httpclient.CommandEnv()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
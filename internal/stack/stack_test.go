@@ -0,0 +1,163 @@
+package stack
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := git(dir, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	run(t, dir, "add", name)
+	run(t, dir, "commit", "-q", "-m", name)
+}
+
+func TestParsePhases(t *testing.T) {
+	plan := "# Plan\n\nintro\n\n## Add the store\nCreate it.\n### Tests\nCover it.\n\n## Use the store\nWire it in.\n"
+	phases := ParsePhases(plan)
+	if len(phases) != 2 {
+		t.Fatalf("got %d phases, want 2: %+v", len(phases), phases)
+	}
+	if phases[0].Title != "Add the store" || phases[0].Task != "Create it.\n### Tests\nCover it." {
+		t.Errorf("first phase = %+v", phases[0])
+	}
+	if phases[1].Task != "Wire it in." {
+		t.Errorf("second phase = %+v", phases[1])
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Load(dir); err != ErrNoStack {
+		t.Fatalf("Load without a stack = %v, want ErrNoStack", err)
+	}
+	s := &Stack{Issue: 7, Base: "main", Phases: []Phase{{Title: "one", Branch: BranchName("issue-7-x", 1), PR: 3}}}
+	if err := s.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	back, err := Load(dir)
+	if err != nil || back.Phases[0].Branch != "issue-7-x-part-1" || back.Phases[0].PR != 3 {
+		t.Errorf("Load = %+v, %v", back, err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	run(t, dir, "init", "-q", "-b", "main")
+	run(t, dir, "config", "user.name", "t")
+	run(t, dir, "config", "user.email", "t@t")
+	commitFile(t, dir, "base.txt", "base\n")
+
+	s := &Stack{Base: "main"}
+	if err := s.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	if out := run(t, dir, "status", "--porcelain"); out != "" {
+		t.Errorf("the stack file should be ignored by git, status: %q", out)
+	}
+	parent := "main"
+	for i, name := range []string{"one.txt", "two.txt"} {
+		branch := BranchName("issue-1", i+1)
+		sha, _ := Head(dir, parent)
+		run(t, dir, "checkout", "-q", "-b", branch, parent)
+		commitFile(t, dir, name, name+"\n")
+		s.Phases = append(s.Phases, Phase{Branch: branch, Parent: parent, ParentSHA: sha})
+		parent = branch
+	}
+
+	rebases, err := s.Sync(dir, "main")
+	if err != nil || len(rebases) != 2 || rebases[0].Moved() || rebases[1].Moved() {
+		t.Fatalf("an untouched stack should not move: %+v %v", rebases, err)
+	}
+
+	// Review feedback lands on the first phase
+	run(t, dir, "checkout", "-q", "issue-1-part-1")
+	commitFile(t, dir, "one.txt", "one, reviewed\n")
+	if rebases, err = s.Sync(dir, "main"); err != nil || rebases[0].Moved() || !rebases[1].Moved() {
+		t.Fatalf("only the second phase should move: %+v %v", rebases, err)
+	}
+	tip, _ := Head(dir, "issue-1-part-1")
+	if s.Phases[1].ParentSHA != tip {
+		t.Error("the second phase should record the first phase's new tip")
+	}
+	run(t, dir, "checkout", "-q", "issue-1-part-2")
+	if data, _ := os.ReadFile(filepath.Join(dir, "one.txt")); string(data) != "one, reviewed\n" {
+		t.Errorf("the second phase lacks the first phase's update: %q", data)
+	}
+
+	// The first phase is squash-merged into main
+	run(t, dir, "checkout", "-q", "main")
+	run(t, dir, "merge", "-q", "--squash", "issue-1-part-1")
+	run(t, dir, "commit", "-q", "-m", "part 1")
+	s.Phases[0].Merged = true
+	if rebases, err = s.Sync(dir, "main"); err != nil || len(rebases) != 1 || rebases[0].Parent != "main" {
+		t.Fatalf("the second phase should move onto main: %+v %v", rebases, err)
+	}
+	if out := run(t, dir, "log", "--format=%s", "main..issue-1-part-2"); out != "two.txt\n" {
+		t.Errorf("the second phase should keep only its own commit, got %q", out)
+	}
+}
+
+func TestPull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	upstream := t.TempDir()
+	run(t, upstream, "init", "-q", "-b", "main")
+	run(t, upstream, "config", "user.name", "t")
+	run(t, upstream, "config", "user.email", "t@t")
+	commitFile(t, upstream, "base.txt", "base\n")
+	run(t, upstream, "branch", "issue-1-part-1")
+
+	dir := t.TempDir()
+	run(t, dir, "clone", "-q", upstream, ".")
+	run(t, dir, "branch", "issue-1-part-1", "origin/issue-1-part-1")
+
+	// A reviewer pushes a fix to the phase's PR
+	run(t, upstream, "checkout", "-q", "issue-1-part-1")
+	commitFile(t, upstream, "fix.txt", "fix\n")
+	run(t, dir, "fetch", "-q", "origin")
+
+	s := &Stack{Base: "main", Phases: []Phase{{Branch: "issue-1-part-1", Parent: "main"}}}
+	pulled, diverged, err := s.Pull(dir, "origin")
+	if err != nil || len(pulled) != 1 || len(diverged) != 0 {
+		t.Fatalf("Pull = %v, %v, %v", pulled, diverged, err)
+	}
+	local, _ := Head(dir, "issue-1-part-1")
+	remote, _ := Head(dir, "origin/issue-1-part-1")
+	if local != remote {
+		t.Error("the local branch should be fast-forwarded")
+	}
+
+	// Both sides commit: the branch is reported, not moved
+	commitFile(t, upstream, "review.txt", "review\n")
+	run(t, dir, "config", "user.name", "t")
+	run(t, dir, "config", "user.email", "t@t")
+	run(t, dir, "checkout", "-q", "issue-1-part-1")
+	commitFile(t, dir, "local.txt", "local\n")
+	run(t, dir, "fetch", "-q", "origin")
+	before, _ := Head(dir, "issue-1-part-1")
+	pulled, diverged, err = s.Pull(dir, "origin")
+	if err != nil || len(pulled) != 0 || len(diverged) != 1 {
+		t.Fatalf("Pull = %v, %v, %v", pulled, diverged, err)
+	}
+	if after, _ := Head(dir, "issue-1-part-1"); after != before {
+		t.Error("a diverged branch should be left alone")
+	}
+}
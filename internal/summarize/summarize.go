@@ -0,0 +1,242 @@
+// Package summarize compresses directories too large to fit in a prompt into
+// a digest: each file is summarized by a cheap model (map), and summaries are
+// combined directory by directory until the digest fits a budget (reduce).
+package summarize
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gptcode/internal/llm"
+)
+
+// DefaultBudget is the digest size, in characters, the reducer aims for
+const DefaultBudget = 12000
+
+// DefaultMaxFiles caps how many files one digest summarizes, each costing a
+// model call the first time
+const DefaultMaxFiles = 60
+
+// maxFileChars caps how much of one file is sent to the map step
+const maxFileChars = 24000
+
+var sourceExts = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".ts": true, ".jsx": true, ".tsx": true,
+	".rb": true, ".ex": true, ".exs": true, ".rs": true, ".java": true, ".kt": true,
+	".c": true, ".h": true, ".cpp": true, ".cs": true, ".swift": true, ".php": true,
+}
+
+const fileSummaryPrompt = `Summarize this source file for an engineer planning a change.
+List its purpose, exported types/functions with one-line descriptions,
+and notable dependencies or side effects. Be terse: at most 12 lines.
+
+File: %s
+---
+%s
+---`
+
+const combinePrompt = `Combine these summaries of %s into one terse overview
+(at most 25 lines). Keep names of key types and functions and how the parts
+relate; drop detail that does not help plan a change.
+
+%s`
+
+// Summarizer builds digests with a (preferably cheap) model
+type Summarizer struct {
+	provider llm.Provider
+	model    string
+	cacheDir string
+	Budget   int
+	// MaxFiles caps the files summarized, the shallowest first; 0 means
+	// no cap
+	MaxFiles int
+}
+
+// New returns a Summarizer that caches file summaries under
+// ~/.gptcode/cache/summaries.
+func New(provider llm.Provider, model string) *Summarizer {
+	home, _ := os.UserHomeDir()
+	return &Summarizer{
+		provider: provider,
+		model:    model,
+		cacheDir: filepath.Join(home, ".gptcode", "cache", "summaries"),
+		Budget:   DefaultBudget,
+		MaxFiles: DefaultMaxFiles,
+	}
+}
+
+// SourceSize returns the total size in bytes of the source files under dir.
+func SourceSize(dir string) int64 {
+	var total int64
+	_ = walkSources(dir, func(path string, info os.FileInfo) {
+		total += info.Size()
+	})
+	return total
+}
+
+// Digest summarizes the source files under dir, up to MaxFiles, and
+// combines the summaries into an overview no longer than the budget.
+func (s *Summarizer) Digest(ctx context.Context, dir string) (string, error) {
+	var files []string
+	if err := walkSources(dir, func(path string, _ os.FileInfo) {
+		files = append(files, path)
+	}); err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no source files under %s", dir)
+	}
+	sort.Strings(files)
+	skipped := 0
+	if s.MaxFiles > 0 && len(files) > s.MaxFiles {
+		// Files near the top usually hold the package's entry points
+		sort.SliceStable(files, func(i, j int) bool {
+			return strings.Count(files[i], string(filepath.Separator)) < strings.Count(files[j], string(filepath.Separator))
+		})
+		skipped = len(files) - s.MaxFiles
+		files = files[:s.MaxFiles]
+		sort.Strings(files)
+	}
+
+	byDir := map[string][]string{}
+	for _, path := range files {
+		summary, err := s.summarizeFile(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		byDir[filepath.Dir(rel)] = append(byDir[filepath.Dir(rel)], fmt.Sprintf("### %s\n%s", rel, summary))
+	}
+
+	dirs := make([]string, 0, len(byDir))
+	for d := range byDir {
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+
+	// Reduce each directory that is over its share of the budget, then the
+	// whole digest if it is still too large.
+	share := s.Budget / len(dirs)
+	sections := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		section, err := s.reduce(ctx, filepath.Join(filepath.Base(dir), d), byDir[d], share)
+		if err != nil {
+			return "", err
+		}
+		if len(dirs) > 1 {
+			section = fmt.Sprintf("## %s\n%s", d, section)
+		}
+		sections = append(sections, section)
+	}
+	digest, err := s.reduce(ctx, filepath.Base(dir), sections, s.Budget)
+	if err != nil {
+		return "", err
+	}
+	if skipped > 0 {
+		digest += fmt.Sprintf("\n\n(%d deeper files were not summarized)", skipped)
+	}
+	return digest, nil
+}
+
+// reduce combines parts until they fit in budget characters, summarizing
+// groups of parts that fit a single request at a time.
+func (s *Summarizer) reduce(ctx context.Context, name string, parts []string, budget int) (string, error) {
+	for {
+		joined := strings.Join(parts, "\n\n")
+		if len(joined) <= budget {
+			return joined, nil
+		}
+
+		var groups [][]string
+		var current []string
+		size := 0
+		for _, p := range parts {
+			if size+len(p) > maxFileChars && len(current) > 0 {
+				groups = append(groups, current)
+				current, size = nil, 0
+			}
+			current = append(current, p)
+			size += len(p)
+		}
+		groups = append(groups, current)
+
+		combined := make([]string, 0, len(groups))
+		for _, g := range groups {
+			text, err := s.complete(ctx, fmt.Sprintf(combinePrompt, name, truncate(strings.Join(g, "\n\n"), maxFileChars)))
+			if err != nil {
+				return "", err
+			}
+			combined = append(combined, text)
+		}
+		if len(combined) == 1 {
+			return combined[0], nil
+		}
+		parts = combined
+	}
+}
+
+// summarizeFile returns the cached summary for path's current content or
+// asks the model for one.
+func (s *Summarizer) summarizeFile(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%x", sha256.Sum256(append([]byte(s.model+"\x00"), data...)))
+	cachePath := filepath.Join(s.cacheDir, key+".txt")
+	if cached, err := os.ReadFile(cachePath); err == nil {
+		return string(cached), nil
+	}
+
+	summary, err := s.complete(ctx, fmt.Sprintf(fileSummaryPrompt, filepath.Base(path), truncate(string(data), maxFileChars)))
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize %s: %w", path, err)
+	}
+	if err := os.MkdirAll(s.cacheDir, 0755); err == nil {
+		_ = os.WriteFile(cachePath, []byte(summary), 0644)
+	}
+	return summary, nil
+}
+
+func (s *Summarizer) complete(ctx context.Context, userPrompt string) (string, error) {
+	resp, err := s.provider.Chat(ctx, llm.ChatRequest{
+		SystemPrompt: "You write terse, accurate code summaries.",
+		UserPrompt:   userPrompt,
+		Model:        s.model,
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
+
+func walkSources(dir string, fn func(path string, info os.FileInfo)) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			name := info.Name()
+			if path != dir && (strings.HasPrefix(name, ".") || name == "vendor" || name == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if sourceExts[filepath.Ext(path)] {
+			fn(path, info)
+		}
+		return nil
+	})
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "\n... (truncated)"
+}
@@ -0,0 +1,128 @@
+package summarize
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gptcode/internal/llm"
+)
+
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	p.calls++
+	if strings.HasPrefix(req.UserPrompt, "Combine") {
+		return &llm.ChatResponse{Text: "combined overview"}, nil
+	}
+	return &llm.ChatResponse{Text: strings.Repeat("summary ", 20)}, nil
+}
+
+func newTestSummarizer(t *testing.T, provider llm.Provider) *Summarizer {
+	s := New(provider, "cheap")
+	s.cacheDir = t.TempDir()
+	return s
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDigestCachesFileSummaries(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.go"), "package a")
+	writeFile(t, filepath.Join(dir, "sub", "b.go"), "package sub")
+	writeFile(t, filepath.Join(dir, "README.md"), "not source")
+	writeFile(t, filepath.Join(dir, "vendor", "c.go"), "package c")
+
+	provider := &countingProvider{}
+	s := newTestSummarizer(t, provider)
+
+	digest, err := s.Digest(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("expected 2 file summaries, got %d calls", provider.calls)
+	}
+	if !strings.Contains(digest, "### a.go") || !strings.Contains(digest, "### sub/b.go") {
+		t.Errorf("digest missing file sections:\n%s", digest)
+	}
+
+	provider.calls = 0
+	if _, err := s.Digest(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 0 {
+		t.Errorf("expected cached summaries, got %d calls", provider.calls)
+	}
+
+	writeFile(t, filepath.Join(dir, "a.go"), "package a // changed")
+	if _, err := s.Digest(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("expected only the changed file to be resummarized, got %d calls", provider.calls)
+	}
+}
+
+func TestDigestReducesToBudget(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go", "d.go"} {
+		writeFile(t, filepath.Join(dir, name), "package x // "+name)
+	}
+
+	s := newTestSummarizer(t, &countingProvider{})
+	s.Budget = 200
+
+	digest, err := s.Digest(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "combined overview" {
+		t.Errorf("expected reduced digest, got:\n%s", digest)
+	}
+}
+
+func TestDigestCapsFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.go"), "package a")
+	writeFile(t, filepath.Join(dir, "deep", "er", "b.go"), "package er")
+	writeFile(t, filepath.Join(dir, "sub", "c.go"), "package sub")
+
+	provider := &countingProvider{}
+	s := newTestSummarizer(t, provider)
+	s.MaxFiles = 2
+
+	digest, err := s.Digest(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if provider.calls != 2 || strings.Contains(digest, "b.go") {
+		t.Errorf("expected the 2 shallowest files summarized, got %d calls:\n%s", provider.calls, digest)
+	}
+	if !strings.Contains(digest, "1 deeper files were not summarized") {
+		t.Errorf("the digest should say files were left out:\n%s", digest)
+	}
+}
+
+func TestSourceSize(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.go"), "12345")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "1234567890")
+	writeFile(t, filepath.Join(dir, ".git", "x.go"), "1234567890")
+
+	if got := SourceSize(dir); got != 5 {
+		t.Errorf("SourceSize = %d, want 5", got)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"gptcode/internal/output"
 )
 
 type ProgressTracker struct {
@@ -27,6 +29,7 @@ type ProgressTracker struct {
 	testPattern    *regexp.Regexp
 	statusPattern  *regexp.Regexp
 	summaryPattern *regexp.Regexp
+	lastLine       time.Time
 }
 
 func NewProgressTracker(category string, timeout int, notify bool) *ProgressTracker {
@@ -82,6 +85,17 @@ func (pt *ProgressTracker) printProgress() {
 		test = test[:47] + "..."
 	}
 
+	if output.Accessible() {
+		// One plain line per interval instead of a redrawn status line
+		if time.Since(pt.lastLine) < output.AccessibleInterval {
+			return
+		}
+		pt.lastLine = time.Now()
+		fmt.Printf("Progress: %s elapsed, %d passed, %d failed, %s remaining, running %s\n",
+			formatDuration(elapsed), pt.passedTests, pt.failedTests, formatDuration(remaining), test)
+		return
+	}
+
 	fmt.Printf("\r %s | [OK] %d passed | [ERROR] %d failed |  %s remaining | [RETRY] %s",
 		formatDuration(elapsed),
 		pt.passedTests,
@@ -120,11 +134,15 @@ func (pt *ProgressTracker) Finish(success bool) {
 
 	elapsed := time.Since(pt.startTime)
 
-	fmt.Print("\r")
-	fmt.Print(strings.Repeat(" ", 120))
-	fmt.Print("\r")
-	fmt.Println()
-	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	if output.Accessible() {
+		fmt.Println()
+	} else {
+		fmt.Print("\r")
+		fmt.Print(strings.Repeat(" ", 120))
+		fmt.Print("\r")
+		fmt.Println()
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	}
 
 	if pt.totalTests == 0 {
 		if success {
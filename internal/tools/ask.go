@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gptcode/internal/output"
+)
+
+// DefaultAmbiguityThreshold is the ambiguity score at or above which an
+// unanswerable question stops the task instead of letting the agent guess.
+const DefaultAmbiguityThreshold = 0.6
+
+// Question is a clarification an agent asked through ask_user
+type Question struct {
+	Text    string   `json:"question"`
+	Options []string `json:"options,omitempty"`
+	// Ambiguity is the agent's estimate (0-1) of how much the answer
+	// changes the outcome.
+	Ambiguity float64 `json:"ambiguity"`
+}
+
+// UserPrompter presents a question to the user and returns the answer
+type UserPrompter func(q Question) (string, error)
+
+var (
+	askMu              sync.Mutex
+	userPrompter       UserPrompter
+	ambiguityThreshold = DefaultAmbiguityThreshold
+	unanswered         []Question
+)
+
+// SetUserPrompter installs the prompter ask_user uses. Supervised and
+// interactive modes install one; with none, as in autonomous runs, questions
+// are either deferred to the agent's judgement or stop the task.
+func SetUserPrompter(p UserPrompter) {
+	askMu.Lock()
+	defer askMu.Unlock()
+	userPrompter = p
+}
+
+// SetAmbiguityThreshold sets the score at which unanswerable questions stop
+// the task. Values outside (0, 1] restore the default.
+func SetAmbiguityThreshold(t float64) {
+	askMu.Lock()
+	defer askMu.Unlock()
+	if t <= 0 || t > 1 {
+		t = DefaultAmbiguityThreshold
+	}
+	ambiguityThreshold = t
+}
+
+// ClarificationError stops a task whose open questions were too ambiguous
+// to guess at. It lists the questions so the user can rerun with answers.
+type ClarificationError struct {
+	Questions []Question
+}
+
+func (e *ClarificationError) Error() string {
+	var b strings.Builder
+	b.WriteString("task needs clarification before it can proceed:")
+	for i, q := range e.Questions {
+		fmt.Fprintf(&b, "\n  %d. %s", i+1, q.Text)
+		if len(q.Options) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(q.Options, " / "))
+		}
+	}
+	b.WriteString("\nRerun with the answers in the task description.")
+	return b.String()
+}
+
+// PendingClarification returns a ClarificationError for the questions that
+// stopped the task since the last call, or nil, and clears them.
+func PendingClarification() error {
+	askMu.Lock()
+	defer askMu.Unlock()
+	if len(unanswered) == 0 {
+		return nil
+	}
+	err := &ClarificationError{Questions: unanswered}
+	unanswered = nil
+	return err
+}
+
+// AskUserTool is the ask_user tool definition.
+func AskUserTool() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name":        "ask_user",
+			"description": "Ask the user a clarifying question when the task is ambiguous and guessing could produce the wrong change. Do not ask about things you can find out by reading the code.",
+			"parameters": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"question": map[string]interface{}{
+						"type":        "string",
+						"description": "The question to ask",
+					},
+					"options": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Suggested answers, if the choice is between a few alternatives",
+					},
+					"ambiguity": map[string]interface{}{
+						"type":        "number",
+						"description": "How much the answer changes the result, from 0 (cosmetic) to 1 (completely different change)",
+					},
+				},
+				"required": []string{"question"},
+			},
+		},
+	}
+}
+
+func askUser(call ToolCall) ToolResult {
+	q := Question{}
+	q.Text, _ = call.Arguments["question"].(string)
+	if strings.TrimSpace(q.Text) == "" {
+		return ToolResult{Tool: "ask_user", Error: "question is required"}
+	}
+	if opts, ok := call.Arguments["options"].([]interface{}); ok {
+		for _, o := range opts {
+			if s, ok := o.(string); ok && s != "" {
+				q.Options = append(q.Options, s)
+			}
+		}
+	}
+	q.Ambiguity, _ = call.Arguments["ambiguity"].(float64)
+
+	askMu.Lock()
+	prompter, threshold := userPrompter, ambiguityThreshold
+	askMu.Unlock()
+
+	if prompter != nil {
+		answer, err := prompter(q)
+		if err != nil {
+			return ToolResult{Tool: "ask_user", Error: fmt.Sprintf("failed to get an answer: %v", err)}
+		}
+		return ToolResult{Tool: "ask_user", Result: "User answered: " + answer}
+	}
+
+	if q.Ambiguity >= threshold {
+		askMu.Lock()
+		unanswered = append(unanswered, q)
+		askMu.Unlock()
+		return ToolResult{Tool: "ask_user", Error: "no user is available; the task will stop so the user can clarify"}
+	}
+	return ToolResult{
+		Tool:   "ask_user",
+		Result: "No user is available. Proceed with the most reasonable interpretation and state the assumption in your final message.",
+	}
+}
+
+// TerminalPrompter asks questions on out and reads answers from in. Options
+// are numbered; the user can pick one by number or type a free answer.
+func TerminalPrompter(in io.Reader, out io.Writer) UserPrompter {
+	reader := bufio.NewReader(in)
+	return func(q Question) (string, error) {
+		defer output.LockTerminal()()
+		fmt.Fprintf(out, "\n? %s\n", q.Text)
+		for i, o := range q.Options {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, o)
+		}
+		fmt.Fprint(out, "> ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		answer := strings.TrimSpace(line)
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(q.Options) {
+			answer = q.Options[n-1]
+		}
+		if answer == "" {
+			answer = "(no answer; use your best judgement)"
+		}
+		return answer, nil
+	}
+}
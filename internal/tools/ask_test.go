@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func askCall(question string, ambiguity float64, options ...interface{}) ToolCall {
+	return ToolCall{Name: "ask_user", Arguments: map[string]interface{}{
+		"question":  question,
+		"ambiguity": ambiguity,
+		"options":   options,
+	}}
+}
+
+func TestAskUserWithPrompter(t *testing.T) {
+	defer SetUserPrompter(nil)
+	var asked Question
+	SetUserPrompter(func(q Question) (string, error) {
+		asked = q
+		return "use JSON", nil
+	})
+
+	result := ExecuteTool(askCall("Which format?", 0.9, "JSON", "YAML"), t.TempDir())
+	if result.Error != "" || result.Result != "User answered: use JSON" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+	if asked.Text != "Which format?" || len(asked.Options) != 2 {
+		t.Errorf("prompter got %+v", asked)
+	}
+	if err := PendingClarification(); err != nil {
+		t.Errorf("answered question should not be pending: %v", err)
+	}
+}
+
+func TestAskUserWithoutPrompter(t *testing.T) {
+	SetAmbiguityThreshold(0.5)
+	defer SetAmbiguityThreshold(0)
+
+	result := ExecuteTool(askCall("Rename the field?", 0.2), t.TempDir())
+	if result.Error != "" || !strings.Contains(result.Result, "most reasonable interpretation") {
+		t.Errorf("low ambiguity should defer to the agent, got %+v", result)
+	}
+	if err := PendingClarification(); err != nil {
+		t.Errorf("low ambiguity question should not stop the task: %v", err)
+	}
+
+	result = ExecuteTool(askCall("Which database?", 0.8, "postgres", "sqlite"), t.TempDir())
+	if result.Error == "" {
+		t.Error("high ambiguity question should report that the task stops")
+	}
+	err := PendingClarification()
+	var clarification *ClarificationError
+	if !errors.As(err, &clarification) || len(clarification.Questions) != 1 {
+		t.Fatalf("expected a clarification error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Which database? (postgres / sqlite)") {
+		t.Errorf("error should list the question, got %q", err.Error())
+	}
+	if PendingClarification() != nil {
+		t.Error("pending questions should be cleared")
+	}
+}
+
+func TestTerminalPrompter(t *testing.T) {
+	var out bytes.Buffer
+	prompter := TerminalPrompter(strings.NewReader("2\nsomething else\n"), &out)
+	q := Question{Text: "Pick one", Options: []string{"a", "b"}}
+
+	if answer, _ := prompter(q); answer != "b" {
+		t.Errorf("numbered answer = %q, want b", answer)
+	}
+	if answer, _ := prompter(q); answer != "something else" {
+		t.Errorf("free answer = %q", answer)
+	}
+	if !strings.Contains(out.String(), "2) b") {
+		t.Errorf("options not listed: %q", out.String())
+	}
+}
@@ -0,0 +1,430 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// devNull is the path a unified diff gives the missing side of a created
+// or deleted file
+const devNull = "/dev/null"
+
+// FileDiff is the part of a unified diff that changes one file
+type FileDiff struct {
+	// OldPath and NewPath are devNull for a created and a deleted file
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// Path is the file the diff changes
+func (d FileDiff) Path() string {
+	if d.NewPath == devNull {
+		return d.OldPath
+	}
+	return d.NewPath
+}
+
+// Hunk is one @@ block of a file diff. Lines keep their ' ', '-' or '+'
+// prefix.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []string
+	// OldNoNewline and NewNoNewline are set when a side ends without a
+	// newline ("\ No newline at end of file")
+	OldNoNewline, NewNoNewline bool
+}
+
+func (h Hunk) header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// sides returns the hunk's lines before and after the change
+func (h Hunk) sides() (old, new []string) {
+	for _, l := range h.Lines {
+		switch l[0] {
+		case ' ':
+			old = append(old, l[1:])
+			new = append(new, l[1:])
+		case '-':
+			old = append(old, l[1:])
+		case '+':
+			new = append(new, l[1:])
+		}
+	}
+	return old, new
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseUnifiedDiff parses a unified diff of one or more files, as git diff
+// and diff -u produce. Line counts in hunk headers are not trusted, since
+// models often get them wrong: a hunk runs until the next hunk or file.
+func ParseUnifiedDiff(text string) ([]FileDiff, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	var files []FileDiff
+	var file *FileDiff
+	var hunk *Hunk
+	endHunk := func() {
+		if hunk == nil {
+			return
+		}
+		// A blank line ending the hunk is the diff's own trailing newline
+		for len(hunk.Lines) > 0 && hunk.Lines[len(hunk.Lines)-1] == " " {
+			hunk.Lines = hunk.Lines[:len(hunk.Lines)-1]
+		}
+		file.Hunks = append(file.Hunks, *hunk)
+		hunk = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			endHunk()
+			files = append(files, FileDiff{OldPath: diffPath(line[4:]), NewPath: diffPath(lines[i+1][4:])})
+			file = &files[len(files)-1]
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if file == nil {
+				return nil, fmt.Errorf("line %d: hunk before any --- / +++ file header", i+1)
+			}
+			endHunk()
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: invalid hunk header %q (expected @@ -start,count +start,count @@)", i+1, line)
+			}
+			hunk = &Hunk{OldStart: atoi(m[1]), OldLines: count(m[2]), NewStart: atoi(m[3]), NewLines: count(m[4])}
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			if n := len(hunk.Lines); n > 0 {
+				switch hunk.Lines[n-1][0] {
+				case '-':
+					hunk.OldNoNewline = true
+				case '+':
+					hunk.NewNoNewline = true
+				default:
+					hunk.OldNoNewline, hunk.NewNoNewline = true, true
+				}
+			}
+		case hunk != nil && line == "":
+			// Editors and models drop the space of blank context lines
+			hunk.Lines = append(hunk.Lines, " ")
+		case hunk != nil && strings.ContainsAny(line[:1], " -+"):
+			hunk.Lines = append(hunk.Lines, line)
+		default:
+			// git's diff, index and mode lines, and text around the diff
+			endHunk()
+		}
+	}
+	endHunk()
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file diffs found: expected --- a/path and +++ b/path headers followed by @@ hunks")
+	}
+	for _, f := range files {
+		if f.OldPath == devNull && f.NewPath == devNull {
+			return nil, fmt.Errorf("a file diff has /dev/null on both sides")
+		}
+		if f.OldPath != devNull && f.NewPath != devNull && f.OldPath != f.NewPath {
+			return nil, fmt.Errorf("%s -> %s: renames are not supported; create the new file and delete the old one", f.OldPath, f.NewPath)
+		}
+		if len(f.Hunks) == 0 && f.NewPath != devNull {
+			return nil, fmt.Errorf("%s: no hunks", f.Path())
+		}
+	}
+	return files, nil
+}
+
+// diffPath is the path of a --- or +++ header, without git's a/ and b/
+// prefixes or a trailing timestamp
+func diffPath(header string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == devNull {
+		return path
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return filepath.Clean(path)
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// count is a hunk header line count, which defaults to 1 when left out
+func count(s string) int {
+	if s == "" {
+		return 1
+	}
+	return atoi(s)
+}
+
+// diffPaths returns the files a diff changes, or nil when it does not parse
+func diffPaths(diff string) []string {
+	files, err := ParseUnifiedDiff(diff)
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.Path())
+	}
+	return paths
+}
+
+// WritePaths returns the paths a call of a write tool writes to, or the
+// path a read_file call reads
+func WritePaths(name string, args map[string]interface{}) []string {
+	if name == "apply_diff" {
+		diff, _ := args["diff"].(string)
+		return diffPaths(diff)
+	}
+	if path, _ := args["path"].(string); path != "" {
+		return []string{path}
+	}
+	return nil
+}
+
+// applyHunks applies hunks to content in order. Each hunk is looked for
+// nearest to the line its header gives, exactly and then ignoring
+// indentation, after the previous hunk.
+func applyHunks(path, content string, hunks []Hunk) (string, error) {
+	var lines []string
+	trailing := true
+	if content != "" {
+		trailing = strings.HasSuffix(content, "\n")
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+
+	from, delta := 0, 0
+	for n, h := range hunks {
+		old, new := h.sides()
+		expected := max(h.OldStart-1+delta, from)
+		// A hunk only adding lines gives the line they follow
+		at := max(h.OldStart+delta, from)
+		if len(old) > 0 {
+			at = findLines(lines, old, from, expected, false)
+			if at < 0 {
+				at = findLines(lines, old, from, expected, true)
+			}
+			if at < 0 {
+				return "", hunkMismatch(path, content, n+1, h, old, expected)
+			}
+		}
+		at = min(at, len(lines))
+
+		rest := append([]string(nil), lines[at+len(old):]...)
+		lines = append(append(lines[:at], new...), rest...)
+		from = at + len(new)
+		delta += len(new) - len(old)
+		if from == len(lines) {
+			// The hunk reaches the end of the file
+			switch {
+			case h.NewNoNewline:
+				trailing = false
+			case h.OldNoNewline:
+				trailing = true
+			}
+		}
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	text := strings.Join(lines, "\n")
+	if trailing {
+		text += "\n"
+	}
+	return text, nil
+}
+
+// findLines returns the index of the occurrence of want in lines at or
+// after from that is nearest to expected, or -1
+func findLines(lines, want []string, from, expected int, loose bool) int {
+	best := -1
+	for i := from; i+len(want) <= len(lines); i++ {
+		match := true
+		for j, w := range want {
+			got := lines[i+j]
+			if loose {
+				got, w = strings.TrimSpace(got), strings.TrimSpace(w)
+			}
+			if got != w {
+				match = false
+				break
+			}
+		}
+		if match && (best < 0 || abs(i-expected) < abs(best-expected)) {
+			best = i
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func hunkMismatch(path, content string, n int, h Hunk, old []string, expected int) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "hunk %d of %s (%s) does not match the file: these lines were not found:\n", n, path, h.header())
+	for i, l := range old {
+		if i == 10 {
+			fmt.Fprintf(&b, "  ... (%d more lines)\n", len(old)-10)
+			break
+		}
+		fmt.Fprintf(&b, "  %s\n", l)
+	}
+	if snippet := sourceSnippet(content, expected+1, 3); snippet != "" {
+		fmt.Fprintf(&b, "Around line %d the file has:\n%s\n", expected+1, snippet)
+	}
+	b.WriteString("Read the file and make the diff from its current content.")
+	return fmt.Errorf("%s", b.String())
+}
+
+// ApplyDiffTool is the apply_diff tool definition.
+func ApplyDiffTool() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "function",
+		"function": map[string]interface{}{
+			"name": "apply_diff",
+			"description": "Apply a unified diff (--- a/path, +++ b/path, @@ hunks) to one or more files. " +
+				"All files change or none does. Use /dev/null as the old path to create a file and as the new path to delete one.",
+			"parameters": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"diff": map[string]interface{}{
+						"type":        "string",
+						"description": "The unified diff, with 3 lines of context around each change",
+					},
+				},
+				"required": []string{"diff"},
+			},
+		},
+	}
+}
+
+// fileChange is the new content of one file of a diff
+type fileChange struct {
+	path     string
+	full     string
+	content  string
+	deleted  bool
+	existed  bool
+	original []byte
+	mode     os.FileMode
+	hunks    int
+}
+
+// ApplyDiff applies a unified diff of one or more files. Every file is
+// patched in memory first, and Go files must parse, so either all files
+// change or none does.
+func ApplyDiff(call ToolCall, workdir string) ToolResult {
+	diff, _ := call.Arguments["diff"].(string)
+	if strings.TrimSpace(diff) == "" {
+		return ToolResult{Tool: "apply_diff", Error: "diff parameter required"}
+	}
+	files, err := ParseUnifiedDiff(diff)
+	if err != nil {
+		return ToolResult{Tool: "apply_diff", Error: "invalid diff: " + err.Error()}
+	}
+
+	var changes []fileChange
+	seen := map[string]bool{}
+	for _, f := range files {
+		path := f.Path()
+		if seen[path] {
+			return ToolResult{Tool: "apply_diff", Error: fmt.Sprintf("%s appears twice in the diff; put all its hunks under one header", path)}
+		}
+		seen[path] = true
+		c := fileChange{path: path, full: filepath.Join(workdir, path), mode: 0644, hunks: len(f.Hunks)}
+		if info, err := os.Stat(c.full); err == nil {
+			c.existed, c.mode = true, info.Mode().Perm()
+			if c.original, err = os.ReadFile(c.full); err != nil {
+				return ToolResult{Tool: "apply_diff", Error: err.Error()}
+			}
+		}
+
+		switch {
+		case f.OldPath == devNull && c.existed && len(c.original) > 0:
+			return ToolResult{Tool: "apply_diff", Error: fmt.Sprintf("%s already exists; diff against its current content instead of /dev/null", path)}
+		case f.OldPath != devNull && !c.existed:
+			return withPathHints(ToolCall{Arguments: map[string]interface{}{"path": path}}, workdir,
+				ToolResult{Tool: "apply_diff", Error: fmt.Sprintf("%s does not exist", path)})
+		case f.NewPath == devNull:
+			c.deleted = true
+		default:
+			content := strings.ReplaceAll(string(c.original), "\r\n", "\n")
+			if c.content, err = applyHunks(path, content, f.Hunks); err != nil {
+				return ToolResult{Tool: "apply_diff", Error: err.Error()}
+			}
+			if strings.HasSuffix(path, ".go") {
+				formatted, err := formatGoSource(path, c.content)
+				if err != nil {
+					return ToolResult{Tool: "apply_diff", Error: err.Error()}
+				}
+				c.content = formatted
+			}
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+
+	for i, c := range changes {
+		if err := writeChange(c); err != nil {
+			for _, done := range changes[:i] {
+				restoreChange(done)
+			}
+			return ToolResult{Tool: "apply_diff", Error: fmt.Sprintf("%s: %v (no file was changed)", c.path, err)}
+		}
+	}
+
+	var summary, modified []string
+	for _, c := range changes {
+		modified = append(modified, c.path)
+		switch {
+		case c.deleted:
+			summary = append(summary, c.path+": deleted")
+		case !c.existed:
+			summary = append(summary, c.path+": created")
+		default:
+			summary = append(summary, fmt.Sprintf("%s: %d hunks", c.path, c.hunks))
+		}
+	}
+	return ToolResult{
+		Tool:          "apply_diff",
+		Result:        fmt.Sprintf("Diff applied to %d files:\n%s", len(changes), strings.Join(summary, "\n")),
+		ModifiedFiles: modified,
+	}
+}
+
+func writeChange(c fileChange) error {
+	if c.deleted {
+		return os.Remove(c.full)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.full), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.full, []byte(c.content), c.mode)
+}
+
+// restoreChange undoes a written change
+func restoreChange(c fileChange) {
+	if !c.existed {
+		_ = os.Remove(c.full)
+		return
+	}
+	_ = os.WriteFile(c.full, c.original, c.mode)
+}
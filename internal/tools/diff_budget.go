@@ -0,0 +1,282 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DiffUsage is how much a task has changed so far
+type DiffUsage struct {
+	Files int
+	Lines int
+}
+
+// DiffBudget limits how many files and lines an autonomous task may change.
+// Every write is measured against the files' content before the task first
+// touched them; a write that takes the task over budget is undone and, unless
+// OnExceeded raises the limits, the task stops.
+type DiffBudget struct {
+	MaxLines int
+	MaxFiles int
+	// OnExceeded is asked whether to continue once a write exceeds the
+	// budget. Returning true raises each limit by its original amount; when
+	// nil the task stops.
+	OnExceeded func(usage DiffUsage, maxLines, maxFiles int) bool
+
+	mu           sync.Mutex
+	initialLines int
+	initialFiles int
+	originals    map[string]*string // nil when the file did not exist
+	changed      map[string]int
+	pending      map[string]*string
+	exceeded     *DiffBudgetError
+}
+
+// NewDiffBudget returns a budget of maxLines changed lines and maxFiles
+// changed files; 0 leaves that dimension unlimited.
+func NewDiffBudget(maxLines, maxFiles int) *DiffBudget {
+	return &DiffBudget{
+		MaxLines:     maxLines,
+		MaxFiles:     maxFiles,
+		initialLines: maxLines,
+		initialFiles: maxFiles,
+		originals:    map[string]*string{},
+		changed:      map[string]int{},
+		pending:      map[string]*string{},
+	}
+}
+
+// DiffBudgetError stops a task that changed more than its budget allows
+type DiffBudgetError struct {
+	Usage    DiffUsage
+	MaxLines int
+	MaxFiles int
+	Files    []string
+	// Rejected is the write that was undone
+	Rejected string
+}
+
+func (e *DiffBudgetError) Error() string {
+	var limits []string
+	if e.MaxLines > 0 {
+		limits = append(limits, fmt.Sprintf("%d lines", e.MaxLines))
+	}
+	if e.MaxFiles > 0 {
+		limits = append(limits, fmt.Sprintf("%d files", e.MaxFiles))
+	}
+	return fmt.Sprintf("diff budget exceeded: writing %s would change %d lines in %d files (budget %s). "+
+		"The write was undone; changes so far: %s. Narrow the task or raise --max-diff-lines/--max-diff-files.",
+		e.Rejected, e.Usage.Lines, e.Usage.Files, strings.Join(limits, ", "), strings.Join(e.Files, ", "))
+}
+
+var (
+	diffBudgetMu sync.Mutex
+	diffBudget   *DiffBudget
+)
+
+// SetDiffBudget installs the budget editors enforce; nil removes it.
+func SetDiffBudget(b *DiffBudget) {
+	diffBudgetMu.Lock()
+	defer diffBudgetMu.Unlock()
+	diffBudget = b
+}
+
+// ActiveDiffBudget returns the installed budget, or nil.
+func ActiveDiffBudget() *DiffBudget {
+	diffBudgetMu.Lock()
+	defer diffBudgetMu.Unlock()
+	return diffBudget
+}
+
+// Before snapshots the files a write is about to change.
+func (b *DiffBudget) Before(call LLMToolCall, workdir string) {
+	if !isWriteTool(call.Name) {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, path := range toolCallPaths(call) {
+		abs := absPath(workdir, path)
+		content := readOptional(abs)
+		b.pending[abs] = content
+		if _, ok := b.originals[abs]; !ok {
+			b.originals[abs] = content
+		}
+	}
+}
+
+// After measures a write. When it takes the task over budget and the limits
+// are not raised, its files are restored to their content before the write
+// and a *DiffBudgetError is returned.
+func (b *DiffBudget) After(call LLMToolCall, result ToolResult, workdir string) error {
+	if !isWriteTool(call.Name) {
+		return nil
+	}
+	paths := toolCallPaths(call)
+	if len(paths) == 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	type write struct {
+		abs         string
+		before      *string
+		previous    int
+		hadPrevious bool
+	}
+	var writes []write
+	for _, path := range paths {
+		abs := absPath(workdir, path)
+		before, ok := b.pending[abs]
+		delete(b.pending, abs)
+		if !ok || result.Error != "" {
+			continue
+		}
+		w := write{abs: abs, before: before}
+		w.previous, w.hadPrevious = b.changed[abs]
+		b.changed[abs] = changedLines(b.originals[abs], readOptional(abs))
+		if b.changed[abs] == 0 {
+			delete(b.changed, abs)
+		}
+		writes = append(writes, w)
+	}
+	if len(writes) == 0 {
+		return nil
+	}
+
+	usage := b.usage()
+	if !b.over(usage) {
+		return nil
+	}
+	if b.OnExceeded != nil && b.OnExceeded(usage, b.MaxLines, b.MaxFiles) {
+		b.MaxLines += b.initialLines
+		b.MaxFiles += b.initialFiles
+		return nil
+	}
+
+	for _, w := range writes {
+		restore(w.abs, w.before)
+		if w.hadPrevious {
+			b.changed[w.abs] = w.previous
+		} else {
+			delete(b.changed, w.abs)
+		}
+	}
+	b.exceeded = &DiffBudgetError{
+		Usage:    usage,
+		MaxLines: b.MaxLines,
+		MaxFiles: b.MaxFiles,
+		Files:    b.files(workdir),
+		Rejected: strings.Join(paths, ", "),
+	}
+	return b.exceeded
+}
+
+// Exceeded returns the error that stopped the task, or nil.
+func (b *DiffBudget) Exceeded() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.exceeded == nil {
+		return nil
+	}
+	return b.exceeded
+}
+
+// Usage returns how much the task has changed so far.
+func (b *DiffBudget) Usage() DiffUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.usage()
+}
+
+func (b *DiffBudget) usage() DiffUsage {
+	u := DiffUsage{Files: len(b.changed)}
+	for _, n := range b.changed {
+		u.Lines += n
+	}
+	return u
+}
+
+func (b *DiffBudget) over(u DiffUsage) bool {
+	return (b.MaxLines > 0 && u.Lines > b.MaxLines) || (b.MaxFiles > 0 && u.Files > b.MaxFiles)
+}
+
+func (b *DiffBudget) files(workdir string) []string {
+	out := make([]string, 0, len(b.changed))
+	for abs, n := range b.changed {
+		name := abs
+		if rel, err := filepath.Rel(workdir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		out = append(out, fmt.Sprintf("%s (%d lines)", name, n))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// IsDiffBudgetError reports whether err stopped a task over its diff budget.
+func IsDiffBudgetError(err error) bool {
+	var budget *DiffBudgetError
+	return errors.As(err, &budget)
+}
+
+// changedLines counts the lines added plus the lines removed between two
+// versions of a file, ignoring the order they appear in.
+func changedLines(before, after *string) int {
+	counts := map[string]int{}
+	if before != nil {
+		for _, line := range splitLines(*before) {
+			counts[line]++
+		}
+	}
+	if after != nil {
+		for _, line := range splitLines(*after) {
+			counts[line]--
+		}
+	}
+	n := 0
+	for _, c := range counts {
+		if c < 0 {
+			c = -c
+		}
+		n += c
+	}
+	return n
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func readOptional(path string) *string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	s := string(data)
+	return &s
+}
+
+func restore(path string, content *string) {
+	if content == nil {
+		_ = os.Remove(path)
+		return
+	}
+	_ = os.WriteFile(path, []byte(*content), 0644)
+}
+
+func absPath(workdir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(workdir, path)
+}
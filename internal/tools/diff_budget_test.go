@@ -0,0 +1,75 @@
+package tools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCall(path, content string) LLMToolCall {
+	args, _ := json.Marshal(map[string]string{"path": path, "content": content})
+	return LLMToolCall{Name: "write_file", Arguments: string(args)}
+}
+
+func budgetedWrite(b *DiffBudget, call LLMToolCall, dir string) error {
+	b.Before(call, dir)
+	return b.After(call, ExecuteToolFromLLM(call, dir), dir)
+}
+
+func TestDiffBudgetStopsAndUndoesWrite(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b := NewDiffBudget(3, 0)
+
+	if err := budgetedWrite(b, writeCall("a.txt", "one\nTWO\n"), dir); err != nil {
+		t.Fatalf("2 changed lines should fit a budget of 3: %v", err)
+	}
+	if got := b.Usage(); got != (DiffUsage{Files: 1, Lines: 2}) {
+		t.Errorf("usage = %+v", got)
+	}
+
+	err := budgetedWrite(b, writeCall("b.txt", "x\ny\n"), dir)
+	if !IsDiffBudgetError(err) {
+		t.Fatalf("expected a diff budget error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "a.txt (2 lines)") {
+		t.Errorf("error should list the changes so far: %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dir, "b.txt")); !os.IsNotExist(statErr) {
+		t.Error("the write over budget should be undone")
+	}
+	if b.Exceeded() == nil {
+		t.Error("the budget should report that the task stopped")
+	}
+}
+
+func TestDiffBudgetRaisedByPrompt(t *testing.T) {
+	dir := t.TempDir()
+	b := NewDiffBudget(0, 1)
+	asked := 0
+	b.OnExceeded = func(usage DiffUsage, maxLines, maxFiles int) bool {
+		asked++
+		return true
+	}
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := budgetedWrite(b, writeCall(name, "x\n"), dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if asked != 1 || b.MaxFiles != 2 {
+		t.Errorf("asked %d times, max files %d", asked, b.MaxFiles)
+	}
+
+	// Reverting a file's changes frees its budget
+	if err := budgetedWrite(b, writeCall("a.txt", ""), dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Usage(); got != (DiffUsage{Files: 1, Lines: 1}) {
+		t.Errorf("usage = %+v", got)
+	}
+}
@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyDiff(t *testing.T) {
+	write := func(t *testing.T, dir, name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(t *testing.T, dir, name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	apply := func(dir, diff string) ToolResult {
+		return ApplyDiff(ToolCall{Name: "apply_diff", Arguments: map[string]interface{}{"diff": diff}}, dir)
+	}
+
+	t.Run("several hunks and files", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, dir, "a.txt", "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n")
+		write(t, dir, "docs/b.txt", "alpha\nbeta\n")
+		write(t, dir, "old.txt", "gone\n")
+
+		result := apply(dir, `diff --git a/a.txt b/a.txt
+index 1234567..89abcde 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+@@ -6,3 +6,4 @@
+ six
+ seven
++seven and a half
+ eight
+--- a/docs/b.txt
++++ b/docs/b.txt
+@@ -1,2 +1,2 @@
+ alpha
+-beta
++gamma
+--- /dev/null
++++ b/new/c.txt
+@@ -0,0 +1,2 @@
++created
++here
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+`)
+		if result.Error != "" {
+			t.Fatalf("ApplyDiff failed: %s", result.Error)
+		}
+		if got := read(t, dir, "a.txt"); got != "one\nTWO\nthree\nfour\nfive\nsix\nseven\nseven and a half\neight\n" {
+			t.Errorf("a.txt not patched: %q", got)
+		}
+		if got := read(t, dir, "docs/b.txt"); got != "alpha\ngamma\n" {
+			t.Errorf("docs/b.txt not patched: %q", got)
+		}
+		if got := read(t, dir, "new/c.txt"); got != "created\nhere\n" {
+			t.Errorf("new/c.txt not created: %q", got)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "old.txt")); !os.IsNotExist(err) {
+			t.Errorf("old.txt not deleted: %v", err)
+		}
+		if len(result.ModifiedFiles) != 4 || !strings.Contains(result.Result, "Diff applied to 4 files") {
+			t.Errorf("unexpected result %q %v", result.Result, result.ModifiedFiles)
+		}
+	})
+
+	t.Run("line numbers drift", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, dir, "a.txt", "header\nheader\none\ntwo\n\nthree\n")
+
+		// The header says line 1 but the lines are at 3, and the blank
+		// context line lost its space
+		result := apply(dir, "--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n\n three\n")
+		if result.Error != "" {
+			t.Fatalf("ApplyDiff failed: %s", result.Error)
+		}
+		if got := read(t, dir, "a.txt"); got != "header\nheader\none\n2\n\nthree\n" {
+			t.Errorf("a.txt not patched: %q", got)
+		}
+	})
+
+	t.Run("a mismatch changes nothing", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, dir, "a.txt", "one\ntwo\n")
+		write(t, dir, "b.txt", "three\nfour\n")
+
+		result := apply(dir, "--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n"+
+			"--- a/b.txt\n+++ b/b.txt\n@@ -1,2 +1,2 @@\n three\n-five\n+5\n")
+		if !strings.Contains(result.Error, "hunk 1 of b.txt") || !strings.Contains(result.Error, "  five") {
+			t.Errorf("unexpected error %q", result.Error)
+		}
+		if got := read(t, dir, "a.txt"); got != "one\ntwo\n" {
+			t.Errorf("a.txt changed although b.txt did not match: %q", got)
+		}
+	})
+
+	t.Run("go files are formatted and must parse", func(t *testing.T) {
+		t.Setenv("PATH", "")
+		dir := t.TempDir()
+		write(t, dir, "main.go", "package main\n\nfunc main() {\n}\n")
+		write(t, dir, "other.txt", "keep\n")
+
+		result := apply(dir, "--- a/other.txt\n+++ b/other.txt\n@@ -1 +1 @@\n-keep\n+changed\n"+
+			"--- a/main.go\n+++ b/main.go\n@@ -3,2 +3,2 @@\n-func main() {\n+func main( {\n }\n")
+		if !strings.Contains(result.Error, "main.go:3:") || !strings.Contains(result.Error, "no file was changed") {
+			t.Errorf("expected a located parse error, got %q", result.Error)
+		}
+		if got := read(t, dir, "other.txt"); got != "keep\n" {
+			t.Errorf("other.txt changed although main.go did not parse: %q", got)
+		}
+
+		result = apply(dir, "--- a/main.go\n+++ b/main.go\n@@ -3,2 +3,3 @@\n func main() {\n+x:=1;_=x\n }\n")
+		if result.Error != "" {
+			t.Fatalf("ApplyDiff failed: %s", result.Error)
+		}
+		if got := read(t, dir, "main.go"); got != "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n" {
+			t.Errorf("main.go not formatted: %q", got)
+		}
+	})
+
+	t.Run("invalid diffs", func(t *testing.T) {
+		dir := t.TempDir()
+		write(t, dir, "a.txt", "one\n")
+		for diff, want := range map[string]string{
+			"just some text": "no file diffs found",
+			"--- a/a.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-one\n":                   "renames are not supported",
+			"--- a/missing.txt\n+++ b/missing.txt\n@@ -1 +1 @@\n-one\n+two\n": "missing.txt does not exist",
+			"--- /dev/null\n+++ b/a.txt\n@@ -0,0 +1 @@\n+one\n":               "already exists",
+		} {
+			if result := apply(dir, diff); !strings.Contains(result.Error, want) {
+				t.Errorf("%q: expected %q, got %q", diff, want, result.Error)
+			}
+		}
+	})
+}
+
+func TestWritePaths(t *testing.T) {
+	diff := "--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\n--- /dev/null\n+++ b/dir/c.go\n@@ -0,0 +1 @@\n+c\n"
+	got := WritePaths("apply_diff", map[string]interface{}{"diff": diff})
+	if strings.Join(got, ",") != "a.go,dir/c.go" {
+		t.Errorf("unexpected paths %v", got)
+	}
+	if got := WritePaths("write_file", map[string]interface{}{"path": "x.go"}); len(got) != 1 || got[0] != "x.go" {
+		t.Errorf("unexpected paths %v", got)
+	}
+}
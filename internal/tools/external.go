@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"sort"
+	"sync"
+)
+
+// ExternalTool is a tool implemented outside this package, such as one
+// served by an MCP server. Registered tools are offered to the agents next
+// to the builtin ones and run through ExecuteTool.
+type ExternalTool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments
+	Parameters map[string]interface{}
+	Call       func(args map[string]interface{}) (string, error)
+	// Edit is set instead of Call by tools that change files; it also
+	// returns the paths it changed, relative to the working directory
+	Edit func(args map[string]interface{}) (string, []string, error)
+}
+
+var (
+	externalMu    sync.RWMutex
+	externalTools = map[string]ExternalTool{}
+)
+
+// RegisterExternalTool makes t available to the agents, replacing any
+// external tool with the same name. Builtin tools cannot be overridden.
+func RegisterExternalTool(t ExternalTool) {
+	externalMu.Lock()
+	defer externalMu.Unlock()
+	externalTools[t.Name] = t
+}
+
+// UnregisterExternalTools removes every external tool.
+func UnregisterExternalTools() {
+	externalMu.Lock()
+	defer externalMu.Unlock()
+	externalTools = map[string]ExternalTool{}
+}
+
+// ExternalToolDefinitions returns the registered external tools in the
+// function-calling format the agents pass to the LLM, sorted by name.
+func ExternalToolDefinitions() []map[string]interface{} {
+	externalMu.RLock()
+	defer externalMu.RUnlock()
+	names := make([]string, 0, len(externalTools))
+	for name := range externalTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	defs := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		t := externalTools[name]
+		params := t.Parameters
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		defs = append(defs, map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        t.Name,
+				"description": t.Description,
+				"parameters":  params,
+			},
+		})
+	}
+	return defs
+}
+
+// ExternalToolDefinition returns the definition of one registered external
+// tool, for agents that offer a fixed set of tools.
+func ExternalToolDefinition(name string) (map[string]interface{}, bool) {
+	for _, def := range ExternalToolDefinitions() {
+		if def["function"].(map[string]interface{})["name"] == name {
+			return def, true
+		}
+	}
+	return nil, false
+}
+
+func executeExternal(call ToolCall) (ToolResult, bool) {
+	externalMu.RLock()
+	t, ok := externalTools[call.Name]
+	externalMu.RUnlock()
+	if !ok {
+		return ToolResult{}, false
+	}
+	if t.Edit != nil {
+		out, files, err := t.Edit(call.Arguments)
+		result := ToolResult{Tool: call.Name, Result: out, ModifiedFiles: files}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result, true
+	}
+	out, err := t.Call(call.Arguments)
+	if err != nil {
+		return ToolResult{Tool: call.Name, Result: out, Error: err.Error()}, true
+	}
+	return ToolResult{Tool: call.Name, Result: out}, true
+}
@@ -0,0 +1,163 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// goimportsTimeout bounds one goimports run
+const goimportsTimeout = 10 * time.Second
+
+// GoPatchError rejects a patch that leaves a Go file unparseable. Its
+// message, which goes back to the model, locates the first syntax error
+// and shows the code around it.
+type GoPatchError struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+	// More counts the syntax errors after the first
+	More int
+	// Snippet is the numbered code around the error
+	Snippet string
+}
+
+func (e *GoPatchError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Patch rejected: %s does not parse after applying it.\n", e.Path)
+	fmt.Fprintf(&b, "error: %s", e.Path)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", e.Line, e.Column)
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+	if e.More > 0 {
+		fmt.Fprintf(&b, " (and %d more errors)", e.More)
+	}
+	if e.Snippet != "" {
+		b.WriteString("\n\nOffending code:\n")
+		b.WriteString(e.Snippet)
+	}
+	b.WriteString("\n\nFix the patch and try again; no file was changed.")
+	return b.String()
+}
+
+// verifyGoSource parses src as a Go file, returning a *GoPatchError when it
+// does not parse.
+func verifyGoSource(path, src string) error {
+	fset := token.NewFileSet()
+	_, err := parser.ParseFile(fset, path, src, parser.AllErrors)
+	if err == nil {
+		return nil
+	}
+
+	perr := &GoPatchError{Path: path, Message: err.Error()}
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		perr.Line, perr.Column = list[0].Pos.Line, list[0].Pos.Column
+		perr.Message = list[0].Msg
+		perr.More = len(list) - 1
+	}
+	perr.Snippet = sourceSnippet(src, perr.Line, 3)
+	return perr
+}
+
+// formatGoSource checks that src, the new content of the Go file at path,
+// parses and formats it with gofmt. Imports are left alone: one a patch
+// adds may only be used by the next, so FixGoImports runs once the edit is
+// done.
+func formatGoSource(path, src string) (string, error) {
+	if err := verifyGoSource(path, src); err != nil {
+		return "", err
+	}
+	formatted, err := format.Source([]byte(src))
+	if err != nil {
+		return src, nil
+	}
+	return string(formatted), nil
+}
+
+// FixGoImports runs goimports on the Go files among files, relative to
+// workdir, adding the imports they miss and dropping unused ones. Editors
+// call it when they finish; without goimports installed it does nothing.
+func FixGoImports(workdir string, files []string) {
+	seen := map[string]bool{}
+	for _, file := range files {
+		if !strings.HasSuffix(file, ".go") || seen[file] {
+			continue
+		}
+		seen[file] = true
+		fullPath := file
+		if !filepath.IsAbs(file) {
+			fullPath = filepath.Join(workdir, file)
+		}
+		src, err := os.ReadFile(fullPath)
+		if err != nil {
+			continue
+		}
+		if fixed, ok := goimports(fullPath, string(src)); ok && fixed != string(src) {
+			_ = os.WriteFile(fullPath, []byte(fixed), 0644)
+		}
+	}
+}
+
+// goimports runs goimports on src as the content of fullPath, so imports
+// resolve from its module
+func goimports(fullPath, src string) (string, bool) {
+	bin, err := exec.LookPath("goimports")
+	if err != nil {
+		return "", false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), goimportsTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, "-srcdir", filepath.Dir(fullPath))
+	cmd.Dir = filepath.Dir(fullPath)
+	cmd.Stdin = strings.NewReader(src)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil || out.Len() == 0 {
+		return "", false
+	}
+	return out.String(), true
+}
+
+// sourceSnippet returns the lines around line (1-based), numbered, with the
+// target line marked.
+func sourceSnippet(src string, line, context int) string {
+	if line <= 0 {
+		return ""
+	}
+	lines := strings.Split(src, "\n")
+	if line > len(lines) {
+		line = len(lines)
+	}
+
+	start := line - context
+	if start < 1 {
+		start = 1
+	}
+	end := line + context
+	if end > len(lines) {
+		end = len(lines)
+	}
+
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		marker := "  "
+		if i == line {
+			marker = "> "
+		}
+		b.WriteString(fmt.Sprintf("%s%4d | %s\n", marker, i, lines[i-1]))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,357 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keepJournals is how many session journals are kept on disk
+const keepJournals = 20
+
+// JournalEntry is the state of one file before a session first changed it
+type JournalEntry struct {
+	Path    string      `json:"path"`
+	Existed bool        `json:"existed"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	Blob    string      `json:"blob,omitempty"`
+	// Written holds the hashes of every version the session wrote, so undo
+	// can tell the session's changes from later edits by someone else
+	Written []string `json:"written,omitempty"`
+}
+
+// Journal snapshots every file before a session modifies it so the session
+// can be undone without git. Journals live in ~/.gptcode/journal/<session>
+// and are only created once a file is changed.
+type Journal struct {
+	Session string          `json:"session"`
+	Command string          `json:"command"`
+	Workdir string          `json:"workdir"`
+	Started time.Time       `json:"started"`
+	Undone  *time.Time      `json:"undone,omitempty"`
+	Entries []*JournalEntry `json:"entries"`
+	// Blobs counts the snapshots taken, naming the next one; entries can be
+	// forgotten, so their number cannot
+	Blobs int `json:"blobs,omitempty"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// NewJournal returns the journal for session, started in workdir.
+func NewJournal(session, command, workdir string) *Journal {
+	return &Journal{
+		Session: session,
+		Command: command,
+		Workdir: workdir,
+		Started: time.Now(),
+		dir:     filepath.Join(JournalsDir(), session),
+	}
+}
+
+// JournalsDir is ~/.gptcode/journal
+func JournalsDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".gptcode", "journal")
+}
+
+var (
+	journalMu     sync.Mutex
+	activeJournal *Journal
+)
+
+// SetJournal installs the journal write tools record to; nil removes it.
+func SetJournal(j *Journal) {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	activeJournal = j
+}
+
+// ActiveJournal returns the installed journal, or nil.
+func ActiveJournal() *Journal {
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	return activeJournal
+}
+
+// Snapshot saves path's current content the first time the session is
+// about to change it.
+func (j *Journal) Snapshot(path string) error {
+	path = filepath.Clean(path)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.entry(path) != nil {
+		return nil
+	}
+
+	if len(j.Entries) == 0 {
+		pruneJournals(JournalsDir(), keepJournals-1)
+	}
+	if err := os.MkdirAll(filepath.Join(j.dir, "blobs"), 0700); err != nil {
+		return err
+	}
+
+	e := &JournalEntry{Path: path}
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		e.Existed = true
+		e.Mode = info.Mode().Perm()
+		e.Blob = fmt.Sprintf("%d", j.Blobs)
+		j.Blobs++
+		if err := os.WriteFile(filepath.Join(j.dir, "blobs", e.Blob), data, 0600); err != nil {
+			return err
+		}
+	}
+	j.Entries = append(j.Entries, e)
+	return j.save()
+}
+
+// Written records the version of path the session just wrote.
+func (j *Journal) Written(path string) {
+	path = filepath.Clean(path)
+	hash, err := hashFile(path)
+	if err != nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if e := j.entry(path); e != nil {
+		e.Written = append(e.Written, hash)
+		_ = j.save()
+	}
+}
+
+// Forget drops the entries under dir, such as a temporary worktree that no
+// longer exists.
+func (j *Journal) Forget(dir string) {
+	dir = filepath.Clean(dir) + string(filepath.Separator)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	kept := j.Entries[:0]
+	for _, e := range j.Entries {
+		if !strings.HasPrefix(e.Path, dir) {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) != len(j.Entries) {
+		j.Entries = kept
+		_ = j.save()
+	}
+}
+
+func (j *Journal) entry(path string) *JournalEntry {
+	for _, e := range j.Entries {
+		if e.Path == path {
+			return e
+		}
+	}
+	return nil
+}
+
+func (j *Journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(j.dir, "journal.json"), data, 0600)
+}
+
+// UndoResult lists what Undo did to each file
+type UndoResult struct {
+	Restored []string
+	Removed  []string
+	// Conflicts were changed after the session and left alone
+	Conflicts []string
+	Unchanged []string
+}
+
+// Undo restores every journaled file to its state before the session.
+// Files edited since by someone else are reported as conflicts and left
+// alone unless force is set.
+func (j *Journal) Undo(force bool) (*UndoResult, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.Undone != nil {
+		return nil, fmt.Errorf("session %s was already undone on %s", j.Session, j.Undone.Format("2006-01-02 15:04"))
+	}
+
+	res := &UndoResult{}
+	for _, e := range j.Entries {
+		original := ""
+		if e.Existed {
+			data, err := os.ReadFile(filepath.Join(j.dir, "blobs", e.Blob))
+			if err != nil {
+				return res, fmt.Errorf("journal snapshot of %s is missing: %w", e.Path, err)
+			}
+			sum := sha256.Sum256(data)
+			original = hex.EncodeToString(sum[:])
+		}
+
+		current, err := hashFile(e.Path)
+		exists := err == nil
+		switch {
+		case !exists && !e.Existed, exists && current == original:
+			res.Unchanged = append(res.Unchanged, e.Path)
+			continue
+		case !force && !wroteVersion(e, current, exists):
+			res.Conflicts = append(res.Conflicts, e.Path)
+			continue
+		}
+
+		if !e.Existed {
+			if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+				return res, err
+			}
+			res.Removed = append(res.Removed, e.Path)
+			continue
+		}
+		data, _ := os.ReadFile(filepath.Join(j.dir, "blobs", e.Blob))
+		if err := os.MkdirAll(filepath.Dir(e.Path), 0755); err != nil {
+			return res, err
+		}
+		if err := os.WriteFile(e.Path, data, e.Mode); err != nil {
+			return res, err
+		}
+		res.Restored = append(res.Restored, e.Path)
+	}
+
+	if len(res.Conflicts) == 0 {
+		now := time.Now()
+		j.Undone = &now
+		if err := j.save(); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Original returns the content e's file had before the session changed it,
+// or nil if the session created the file.
+func (j *Journal) Original(e *JournalEntry) ([]byte, error) {
+	if !e.Existed {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(j.dir, "blobs", e.Blob))
+	if err != nil {
+		return nil, fmt.Errorf("journal snapshot of %s is missing: %w", e.Path, err)
+	}
+	return data, nil
+}
+
+// wroteVersion reports whether the file's current state is one the session
+// produced. A deleted file counts as the session's when the session wrote
+// nothing newer, e.g. after a run_command removed it.
+func wroteVersion(e *JournalEntry, current string, exists bool) bool {
+	if !exists {
+		return len(e.Written) == 0
+	}
+	for _, h := range e.Written {
+		if h == current {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadJournal reads the journal of session; a prefix of the ID is enough.
+func LoadJournal(session string) (*Journal, error) {
+	journals, err := ListJournals()
+	if err != nil {
+		return nil, err
+	}
+	var match *Journal
+	for _, j := range journals {
+		if j.Session == session {
+			return j, nil
+		}
+		if len(session) >= 4 && len(j.Session) > len(session) && j.Session[:len(session)] == session {
+			if match != nil {
+				return nil, fmt.Errorf("session %s is ambiguous; use more of the ID", session)
+			}
+			match = j
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no journal for session %s", session)
+	}
+	return match, nil
+}
+
+// ListJournals returns the recorded journals, newest first.
+func ListJournals() ([]*Journal, error) {
+	entries, err := os.ReadDir(JournalsDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var journals []*Journal
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(JournalsDir(), e.Name())
+		data, err := os.ReadFile(filepath.Join(dir, "journal.json"))
+		if err != nil {
+			continue
+		}
+		j := &Journal{}
+		if json.Unmarshal(data, j) != nil {
+			continue
+		}
+		j.dir = dir
+		journals = append(journals, j)
+	}
+	sort.Slice(journals, func(a, b int) bool { return journals[a].Started.After(journals[b].Started) })
+	return journals, nil
+}
+
+func pruneJournals(dir string, keep int) {
+	journals, err := ListJournals()
+	if err != nil || len(journals) <= keep {
+		return
+	}
+	for _, j := range journals[keep:] {
+		if filepath.Dir(j.dir) == dir {
+			_ = os.RemoveAll(j.dir)
+		}
+	}
+}
+
+// journalWrite snapshots the files a write tool is about to change, runs
+// the write and records the versions it produced.
+func journalWrite(call ToolCall, workdir string, write func() ToolResult) ToolResult {
+	j := ActiveJournal()
+	paths := WritePaths(call.Name, call.Arguments)
+	if j == nil || len(paths) == 0 {
+		return write()
+	}
+	for _, path := range paths {
+		if err := j.Snapshot(absPath(workdir, path)); err != nil {
+			return ToolResult{Tool: call.Name, Error: fmt.Sprintf("could not snapshot %s for undo: %v", path, err)}
+		}
+	}
+	result := write()
+	if result.Error == "" {
+		for _, path := range paths {
+			j.Written(absPath(workdir, path))
+		}
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalUndo(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "main.go")
+	created := filepath.Join(dir, "new.go")
+	if err := os.WriteFile(edited, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	j := NewJournal("3f2a9c1e-session", "gptcode do", dir)
+	SetJournal(j)
+	defer SetJournal(nil)
+
+	for _, call := range []ToolCall{
+		{Name: "write_file", Arguments: map[string]interface{}{"path": "main.go", "content": "package main\n\nfunc main() {}\n"}},
+		{Name: "write_file", Arguments: map[string]interface{}{"path": "new.go", "content": "package main\n"}},
+		{Name: "write_file", Arguments: map[string]interface{}{"path": "main.go", "content": "package main\n\nfunc main() { run() }\n"}},
+	} {
+		if res := ExecuteTool(call, dir); res.Error != "" {
+			t.Fatal(res.Error)
+		}
+	}
+	if len(j.Entries) != 2 {
+		t.Fatalf("expected 2 journal entries, got %d", len(j.Entries))
+	}
+
+	loaded, err := LoadJournal("3f2a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := loaded.Undo(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Restored) != 1 || len(res.Removed) != 1 || len(res.Conflicts) != 0 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if data, _ := os.ReadFile(edited); string(data) != "package main\n" {
+		t.Errorf("main.go not restored: %q", data)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("new.go should have been removed")
+	}
+	if _, err := loaded.Undo(false); err == nil {
+		t.Error("expected an error undoing twice")
+	}
+}
+
+func TestJournalUndoConflict(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("v1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	j := NewJournal("session", "gptcode do", dir)
+	if err := j.Snapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(path, []byte("v2\n"), 0644)
+	j.Written(path)
+	// Edited by the user after the session
+	_ = os.WriteFile(path, []byte("v3\n"), 0644)
+
+	res, err := j.Undo(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Conflicts) != 1 || j.Undone != nil {
+		t.Fatalf("expected a conflict, got %+v", res)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "v3\n" {
+		t.Errorf("conflicting file was changed: %q", data)
+	}
+
+	if res, err = j.Undo(true); err != nil || len(res.Restored) != 1 {
+		t.Fatalf("forced undo: %+v, %v", res, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "v1\n" {
+		t.Errorf("forced undo did not restore: %q", data)
+	}
+}
+
+func TestJournalSnapshotAfterForget(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	worktree := filepath.Join(dir, "worktree")
+	if err := os.MkdirAll(worktree, 0755); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(dir, "kept.go")
+	temp := filepath.Join(worktree, "temp.go")
+	later := filepath.Join(dir, "later.go")
+	for path, content := range map[string]string{kept: "kept\n", temp: "temp\n", later: "later\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	j := NewJournal("session", "gptcode do", dir)
+	for _, path := range []string{temp, kept} {
+		if err := j.Snapshot(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j.Forget(worktree)
+	if err := j.Snapshot(later); err != nil {
+		t.Fatal(err)
+	}
+
+	// The snapshot taken after Forget must not overwrite kept.go's
+	for _, e := range j.Entries {
+		want := map[string]string{kept: "kept\n", later: "later\n"}[e.Path]
+		if data, err := j.Original(e); err != nil || string(data) != want {
+			t.Errorf("original of %s = %q (%v), want %q", e.Path, data, err, want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The kubectl tools only read: they run get, describe, logs and events,
+// never apply, delete, exec or port-forward, and do not print secrets'
+// data.
+
+const (
+	kubeTimeout     = time.Minute
+	kubeMaxOutput   = 16000
+	kubeDefaultTail = 200
+	kubeMaxTail     = 2000
+)
+
+var (
+	kubeResourcePattern  = regexp.MustCompile(`^[a-z][a-z0-9.-]*$`)
+	kubeNamePattern      = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._/-]*$`)
+	kubeNamespacePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	kubeSincePattern     = regexp.MustCompile(`^[0-9]+[smh]$`)
+)
+
+// KubeconfigPath returns the kubeconfig kubectl would use, "" when there
+// is none
+func KubeconfigPath() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		for _, p := range filepath.SplitList(env) {
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				return p
+			}
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	p := filepath.Join(home, ".kube", "config")
+	if info, err := os.Stat(p); err != nil || info.IsDir() {
+		return ""
+	}
+	return p
+}
+
+// RegisterKubeTools registers the k8s_* tools when kubectl and a
+// kubeconfig are available, and reports whether it did
+func RegisterKubeTools(workdir string) bool {
+	if KubeconfigPath() == "" {
+		return false
+	}
+	if _, err := exec.LookPath("kubectl"); err != nil {
+		return false
+	}
+	for _, t := range KubeTools(workdir) {
+		RegisterExternalTool(t)
+	}
+	return true
+}
+
+// KubeTools returns the read-only kubectl tools, running kubectl in
+// workdir. Like shell tools they run on the host, where the kubeconfig is.
+func KubeTools(workdir string) []ExternalTool {
+	str := func(desc string) map[string]interface{} {
+		return map[string]interface{}{"type": "string", "description": desc}
+	}
+	namespace := str("Namespace (default: the context's namespace)")
+	run := func(args []string, keepTail bool) (string, error) {
+		return runKubectl(args, workdir, keepTail)
+	}
+
+	return []ExternalTool{
+		{
+			Name:        "k8s_get",
+			Description: "List Kubernetes resources (kubectl get -o wide), e.g. pods, deployments, nodes, services, ingresses.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"resource":       str("Resource type, e.g. pods, deployments, nodes"),
+					"name":           str("Optional resource name"),
+					"namespace":      namespace,
+					"selector":       str("Optional label selector, e.g. app=api"),
+					"all_namespaces": map[string]interface{}{"type": "boolean", "description": "List across all namespaces"},
+					"output":         str("wide (default) or yaml; yaml is refused for secrets"),
+				},
+				"required": []string{"resource"},
+			},
+			Call: func(args map[string]interface{}) (string, error) {
+				kargs, err := kubeGetArgs(args)
+				if err != nil {
+					return "", err
+				}
+				return run(kargs, false)
+			},
+		},
+		{
+			Name:        "k8s_describe",
+			Description: "Describe a Kubernetes resource (kubectl describe): status, conditions, container states, restarts and recent events.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"resource":  str("Resource type, e.g. pod, deployment, node"),
+					"name":      str("Resource name"),
+					"namespace": namespace,
+				},
+				"required": []string{"resource", "name"},
+			},
+			Call: func(args map[string]interface{}) (string, error) {
+				kargs, err := kubeDescribeArgs(args)
+				if err != nil {
+					return "", err
+				}
+				return run(kargs, false)
+			},
+		},
+		{
+			Name:        "k8s_logs",
+			Description: "Read a pod's logs (kubectl logs). Set previous to read the last crashed container's logs, e.g. for CrashLoopBackOff.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"pod":       str("Pod name, or deployment/<name> for one of its pods"),
+					"container": str("Container name, for pods with several"),
+					"namespace": namespace,
+					"previous":  map[string]interface{}{"type": "boolean", "description": "Logs of the previous, terminated container"},
+					"tail":      map[string]interface{}{"type": "integer", "description": fmt.Sprintf("Lines from the end (default %d, at most %d)", kubeDefaultTail, kubeMaxTail)},
+					"since":     str("Only logs newer than this, e.g. 10m or 1h"),
+				},
+				"required": []string{"pod"},
+			},
+			Call: func(args map[string]interface{}) (string, error) {
+				kargs, err := kubeLogsArgs(args)
+				if err != nil {
+					return "", err
+				}
+				return run(kargs, true)
+			},
+		},
+		{
+			Name:        "k8s_events",
+			Description: "List Kubernetes events, oldest first (kubectl get events): scheduling failures, OOM kills, image pull errors, failed probes.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"namespace":      namespace,
+					"object":         str("Optional name of the object the events are about, e.g. a pod"),
+					"all_namespaces": map[string]interface{}{"type": "boolean", "description": "List across all namespaces"},
+				},
+			},
+			Call: func(args map[string]interface{}) (string, error) {
+				kargs, err := kubeEventsArgs(args)
+				if err != nil {
+					return "", err
+				}
+				return run(kargs, true)
+			},
+		},
+	}
+}
+
+func kubeString(args map[string]interface{}, key string, pattern *regexp.Regexp) (string, error) {
+	v, _ := args[key].(string)
+	v = strings.TrimSpace(v)
+	if v != "" && pattern != nil && !pattern.MatchString(v) {
+		return "", fmt.Errorf("invalid %s %q", key, v)
+	}
+	return v, nil
+}
+
+// kubeScope returns the namespace flags of a call
+func kubeScope(args map[string]interface{}) ([]string, error) {
+	if all, _ := args["all_namespaces"].(bool); all {
+		return []string{"--all-namespaces"}, nil
+	}
+	ns, err := kubeString(args, "namespace", kubeNamespacePattern)
+	if err != nil || ns == "" {
+		return nil, err
+	}
+	return []string{"--namespace", ns}, nil
+}
+
+func kubeGetArgs(args map[string]interface{}) ([]string, error) {
+	resource, err := kubeString(args, "resource", kubeResourcePattern)
+	if err != nil {
+		return nil, err
+	}
+	if resource == "" {
+		return nil, fmt.Errorf("resource is required")
+	}
+	name, err := kubeString(args, "name", kubeNamePattern)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := kubeScope(args)
+	if err != nil {
+		return nil, err
+	}
+	output, _ := kubeString(args, "output", nil)
+	switch output {
+	case "", "wide":
+		output = "wide"
+	case "yaml":
+		if isKubeSecret(resource) {
+			return nil, fmt.Errorf("secrets' data is not shown; list or describe them instead")
+		}
+	default:
+		return nil, fmt.Errorf("output must be wide or yaml")
+	}
+
+	kargs := []string{"get", resource}
+	if name != "" {
+		kargs = append(kargs, name)
+	}
+	kargs = append(kargs, scope...)
+	if selector, _ := kubeString(args, "selector", nil); selector != "" {
+		kargs = append(kargs, "--selector", selector)
+	}
+	return append(kargs, "--output", output), nil
+}
+
+func kubeDescribeArgs(args map[string]interface{}) ([]string, error) {
+	resource, err := kubeString(args, "resource", kubeResourcePattern)
+	if err != nil {
+		return nil, err
+	}
+	name, err := kubeString(args, "name", kubeNamePattern)
+	if err != nil {
+		return nil, err
+	}
+	if resource == "" || name == "" {
+		return nil, fmt.Errorf("resource and name are required")
+	}
+	scope, err := kubeScope(args)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"describe", resource, name}, scope...), nil
+}
+
+func kubeLogsArgs(args map[string]interface{}) ([]string, error) {
+	pod, err := kubeString(args, "pod", kubeNamePattern)
+	if err != nil {
+		return nil, err
+	}
+	if pod == "" {
+		return nil, fmt.Errorf("pod is required")
+	}
+	kargs := []string{"logs", pod}
+	scope, err := kubeScope(args)
+	if err != nil {
+		return nil, err
+	}
+	kargs = append(kargs, scope...)
+	container, err := kubeString(args, "container", kubeNamePattern)
+	if err != nil {
+		return nil, err
+	}
+	if container != "" {
+		kargs = append(kargs, "--container", container)
+	}
+	if previous, _ := args["previous"].(bool); previous {
+		kargs = append(kargs, "--previous")
+	}
+	tail := kubeDefaultTail
+	if n, ok := args["tail"].(float64); ok && n > 0 {
+		tail = int(n)
+	}
+	if tail > kubeMaxTail {
+		tail = kubeMaxTail
+	}
+	kargs = append(kargs, "--tail", fmt.Sprint(tail))
+	since, err := kubeString(args, "since", kubeSincePattern)
+	if err != nil {
+		return nil, err
+	}
+	if since != "" {
+		kargs = append(kargs, "--since", since)
+	}
+	return kargs, nil
+}
+
+func kubeEventsArgs(args map[string]interface{}) ([]string, error) {
+	scope, err := kubeScope(args)
+	if err != nil {
+		return nil, err
+	}
+	kargs := append([]string{"get", "events"}, scope...)
+	object, err := kubeString(args, "object", kubeNamePattern)
+	if err != nil {
+		return nil, err
+	}
+	if object != "" {
+		// kind/name is accepted, the field selector only takes the name
+		if i := strings.LastIndex(object, "/"); i >= 0 {
+			object = object[i+1:]
+		}
+		kargs = append(kargs, "--field-selector", "involvedObject.name="+object)
+	}
+	return append(kargs, "--sort-by", ".lastTimestamp"), nil
+}
+
+func isKubeSecret(resource string) bool {
+	r := strings.SplitN(resource, ".", 2)[0]
+	return r == "secret" || r == "secrets"
+}
+
+// runKubectl runs kubectl with args and trims long output, keeping its end
+// for logs and events, where the latest lines matter most
+func runKubectl(args []string, workdir string, keepTail bool) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kubeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "kubectl", append([]string{"--request-timeout=30s"}, args...)...)
+	cmd.Dir = workdir
+	out, err := cmd.CombinedOutput()
+	s := string(out)
+	if len(s) > kubeMaxOutput {
+		if keepTail {
+			s = "[... earlier output trimmed]\n" + s[len(s)-kubeMaxOutput:]
+		} else {
+			s = s[:kubeMaxOutput] + "\n[... output trimmed]"
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return s, fmt.Errorf("kubectl timed out after %s", kubeTimeout)
+	}
+	if err != nil {
+		return s, fmt.Errorf("kubectl %s: %w", args[0], err)
+	}
+	return s, nil
+}
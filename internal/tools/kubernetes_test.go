@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestKubeArgs(t *testing.T) {
+	cases := []struct {
+		build func(map[string]interface{}) ([]string, error)
+		args  map[string]interface{}
+		want  string
+	}{
+		{kubeGetArgs, map[string]interface{}{"resource": "pods", "namespace": "shop", "selector": "app=api"},
+			"get pods --namespace shop --selector app=api --output wide"},
+		{kubeGetArgs, map[string]interface{}{"resource": "deployments", "name": "api", "output": "yaml"},
+			"get deployments api --output yaml"},
+		{kubeDescribeArgs, map[string]interface{}{"resource": "pod", "name": "api-7d9f", "all_namespaces": true},
+			"describe pod api-7d9f --all-namespaces"},
+		{kubeLogsArgs, map[string]interface{}{"pod": "deployment/api", "previous": true, "tail": float64(50000), "since": "10m"},
+			"logs deployment/api --previous --tail 2000 --since 10m"},
+		{kubeEventsArgs, map[string]interface{}{"namespace": "shop", "object": "pod/api-7d9f"},
+			"get events --namespace shop --field-selector involvedObject.name=api-7d9f --sort-by .lastTimestamp"},
+	}
+	for _, c := range cases {
+		got, err := c.build(c.args)
+		if err != nil || strings.Join(got, " ") != c.want {
+			t.Errorf("%v: expected %q, got %q (%v)", c.args, c.want, strings.Join(got, " "), err)
+		}
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"resource": "secrets", "output": "yaml"},
+		{"resource": "pods", "output": "jsonpath={.items}"},
+		{"resource": "pods", "namespace": "--kubeconfig=/tmp/x"},
+		{"resource": "pods", "name": "-o=yaml"},
+		{},
+	} {
+		if _, err := kubeGetArgs(bad); err == nil {
+			t.Errorf("%v: expected an error", bad)
+		}
+	}
+}
+
+func TestRegisterKubeTools(t *testing.T) {
+	defer UnregisterExternalTools()
+	dir := t.TempDir()
+	t.Setenv("KUBECONFIG", filepath.Join(dir, "missing"))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if RegisterKubeTools(dir) {
+		t.Fatal("expected no tools without a kubeconfig")
+	}
+
+	kubeconfig := filepath.Join(dir, "config")
+	if err := os.WriteFile(kubeconfig, []byte("apiVersion: v1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", filepath.Join(dir, "missing")+string(os.PathListSeparator)+kubeconfig)
+	if KubeconfigPath() != kubeconfig {
+		t.Fatalf("expected the first existing kubeconfig, got %q", KubeconfigPath())
+	}
+	// Prints its arguments
+	if err := os.WriteFile(filepath.Join(dir, "kubectl"), []byte("#!/bin/sh\necho \"kubectl $*\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if !RegisterKubeTools(dir) {
+		t.Fatal("expected the tools registered")
+	}
+
+	result := ExecuteTool(ToolCall{Name: "k8s_logs", Arguments: map[string]interface{}{"pod": "api-7d9f", "namespace": "shop"}}, dir)
+	if result.Error != "" || strings.TrimSpace(result.Result) != "kubectl --request-timeout=30s logs api-7d9f --namespace shop --tail 200" {
+		t.Errorf("unexpected k8s_logs result %+v", result)
+	}
+	result = ExecuteTool(ToolCall{Name: "k8s_get", Arguments: map[string]interface{}{"resource": "secrets", "output": "yaml"}}, dir)
+	if !strings.Contains(result.Error, "secrets") {
+		t.Errorf("expected secrets' data refused, got %+v", result)
+	}
+}
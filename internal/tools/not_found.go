@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// maxPathHints is how many similar paths a file-not-found error suggests
+	maxPathHints = 5
+	// maxHintScan bounds the files looked at for hints in large trees
+	maxHintScan = 20000
+)
+
+// withPathHints adds, to the error of a tool that failed because its path
+// does not exist, the project files with the nearest names or, without any,
+// a map of the closest existing directory. Models guessing a wrong path
+// then correct it on the next call instead of searching for it.
+func withPathHints(call ToolCall, workdir string, result ToolResult) ToolResult {
+	path, _ := call.Arguments["path"].(string)
+	if result.Error == "" || path == "" {
+		return result
+	}
+	if _, err := os.Stat(filepath.Join(workdir, path)); !os.IsNotExist(err) {
+		return result
+	}
+
+	if hints := similarPaths(workdir, path, maxPathHints); len(hints) > 0 {
+		result.Error = fmt.Sprintf("%s\nFile %s does not exist. Files with similar names:\n  %s",
+			result.Error, path, strings.Join(hints, "\n  "))
+		return result
+	}
+	dir := nearestDir(workdir, filepath.Dir(path))
+	tree := ProjectMap(ToolCall{Arguments: map[string]interface{}{"max_depth": float64(2)}}, filepath.Join(workdir, dir))
+	if tree.Error == "" {
+		result.Error = fmt.Sprintf("%s\nFile %s does not exist and no file has a similar name. Contents of %s:\n%s",
+			result.Error, path, dir, tree.Result)
+	}
+	return result
+}
+
+// similarPaths returns the project files whose name or location is closest
+// to path, best first
+func similarPaths(workdir, path string, limit int) []string {
+	type hint struct {
+		path  string
+		score int
+	}
+	want := strings.ToLower(filepath.ToSlash(filepath.Clean(path)))
+	wantBase := filepath.Base(want)
+	wantStem := strings.TrimSuffix(wantBase, filepath.Ext(wantBase))
+	wantDirs := map[string]bool{}
+	for _, d := range strings.Split(filepath.Dir(want), "/") {
+		if d != "." && d != "" {
+			wantDirs[d] = true
+		}
+	}
+
+	var hints []hint
+	scanned := 0
+	_ = filepath.Walk(workdir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		name := info.Name()
+		if info.IsDir() {
+			if p != workdir && (defaultIgnoreDirs[name] || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if scanned++; scanned > maxHintScan {
+			return filepath.SkipAll
+		}
+
+		rel, _ := filepath.Rel(workdir, p)
+		rel = filepath.ToSlash(rel)
+		lower := strings.ToLower(rel)
+		base := strings.ToLower(name)
+		stem := strings.TrimSuffix(base, filepath.Ext(base))
+
+		score := 0
+		switch {
+		case strings.HasSuffix(lower, "/"+want):
+			// The path was given relative to the wrong directory
+			score = 200
+		case base == wantBase:
+			score = 100
+		case stem == wantStem:
+			score = 70
+		default:
+			if similarity(base, wantBase) < 0.6 {
+				return nil
+			}
+			score = 50
+		}
+		for _, d := range strings.Split(filepath.Dir(lower), "/") {
+			if wantDirs[d] {
+				score += 10
+			}
+		}
+		hints = append(hints, hint{rel, score})
+		return nil
+	})
+
+	sort.SliceStable(hints, func(i, j int) bool {
+		if hints[i].score != hints[j].score {
+			return hints[i].score > hints[j].score
+		}
+		return len(hints[i].path) < len(hints[j].path)
+	})
+	var paths []string
+	for i := 0; i < len(hints) && i < limit; i++ {
+		paths = append(paths, hints[i].path)
+	}
+	return paths
+}
+
+// nearestDir returns dir or its closest existing parent, relative to workdir
+func nearestDir(workdir, dir string) string {
+	for d := filepath.Clean(dir); d != "." && d != "/" && !strings.HasPrefix(d, ".."); d = filepath.Dir(d) {
+		if info, err := os.Stat(filepath.Join(workdir, d)); err == nil && info.IsDir() {
+			return d
+		}
+	}
+	return "."
+}
+
+// similarity is 1 minus the edit distance of a and b over the longer length
+func similarity(a, b string) float64 {
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(b)])/float64(longest)
+}
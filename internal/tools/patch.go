@@ -39,32 +39,44 @@ func ApplyPatch(call ToolCall, workdir string) ToolResult {
 
 	if strings.Contains(normalizedContent, normalizedSearch) {
 		newContent := strings.Replace(normalizedContent, normalizedSearch, replaceBlock, 1)
-		if err := os.WriteFile(fullPath, []byte(newContent), 0644); err != nil {
-			return ToolResult{Tool: "apply_patch", Error: err.Error()}
-		}
-		return ToolResult{
-			Tool:          "apply_patch",
-			Result:        "Patch applied successfully",
-			ModifiedFiles: []string{path},
-		}
+		return writePatchedFile(path, fullPath, newContent, "Patch applied successfully")
 	}
 
 	fuzzyMatch := findFuzzyMatch(normalizedContent, normalizedSearch)
 	if fuzzyMatch != "" {
 		newContent := strings.Replace(normalizedContent, fuzzyMatch, replaceBlock, 1)
-		if err := os.WriteFile(fullPath, []byte(newContent), 0644); err != nil {
+		return writePatchedFile(path, fullPath, newContent, "Patch applied with fuzzy matching")
+	}
+
+	return ToolResult{
+		Tool:  "apply_patch",
+		Error: fmt.Sprintf("Could not find search block in %s. Ensure it matches file content.", path),
+	}
+}
+
+// writePatchedFile writes the patched content. A Go file must parse after
+// the patch, and is written gofmt'd; one that does not parse is left
+// unchanged and the model gets the syntax error immediately instead of
+// discovering it in the build step.
+func writePatchedFile(path, fullPath, newContent, message string) ToolResult {
+	if strings.HasSuffix(path, ".go") {
+		formatted, err := formatGoSource(path, newContent)
+		if err != nil {
 			return ToolResult{Tool: "apply_patch", Error: err.Error()}
 		}
-		return ToolResult{
-			Tool:          "apply_patch",
-			Result:        "Patch applied with fuzzy matching",
-			ModifiedFiles: []string{path},
+		if formatted != newContent {
+			message += " (formatted with gofmt; read the file before patching the same lines again)"
 		}
+		newContent = formatted
 	}
 
+	if err := os.WriteFile(fullPath, []byte(newContent), 0644); err != nil {
+		return ToolResult{Tool: "apply_patch", Error: err.Error()}
+	}
 	return ToolResult{
-		Tool:  "apply_patch",
-		Error: fmt.Sprintf("Could not find search block in %s. Ensure it matches file content.", path),
+		Tool:          "apply_patch",
+		Result:        message,
+		ModifiedFiles: []string{path},
 	}
 }
 
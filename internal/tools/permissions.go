@@ -0,0 +1,252 @@
+package tools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gptcode/internal/output"
+)
+
+// PermissionKind is what a permission covers
+type PermissionKind string
+
+const (
+	// PermissionCommand covers the command lines run_command runs
+	PermissionCommand PermissionKind = "commands"
+	// PermissionPath covers writes to a directory outside the project
+	PermissionPath PermissionKind = "paths"
+)
+
+// PermissionRequest is an operation waiting for the user's approval
+type PermissionRequest struct {
+	Tool string
+	Kind PermissionKind
+	// Subject is the command line, or the directory outside the project
+	// being written to
+	Subject string
+	// Reason says why the command policy wants it confirmed, if it does
+	Reason string
+}
+
+// Decision is the user's answer to a permission prompt
+type Decision int
+
+const (
+	Deny Decision = iota
+	AllowOnce
+	// AllowSession allows the same operation until the command exits
+	AllowSession
+	// AllowProject allows it from now on, recorded in the project policy
+	AllowProject
+)
+
+// PermissionPrompter asks the user whether to allow an operation
+type PermissionPrompter func(r PermissionRequest) (Decision, error)
+
+// Permissions makes supervised runs ask before running a command or writing
+// outside the project, unless the operation was allowed before: by the
+// project policy, or earlier in the session.
+type Permissions struct {
+	// Root resolves relative allowed paths
+	Root   string
+	Prompt PermissionPrompter
+	// Remember records an "always for this project" decision; when nil
+	// such decisions only last the session.
+	Remember func(kind PermissionKind, pattern string) error
+
+	mu      sync.Mutex
+	allowed map[PermissionKind][]string
+}
+
+// NewPermissions returns permissions that allow the commands and paths of
+// the project policy and ask prompt about anything else.
+func NewPermissions(root string, commands, paths []string, prompt PermissionPrompter) *Permissions {
+	return &Permissions{
+		Root:   root,
+		Prompt: prompt,
+		allowed: map[PermissionKind][]string{
+			PermissionCommand: append([]string(nil), commands...),
+			PermissionPath:    append([]string(nil), paths...),
+		},
+	}
+}
+
+var (
+	permissionsMu sync.Mutex
+	permissions   *Permissions
+)
+
+// SetPermissions installs the permissions tools check; nil removes them,
+// letting every operation through.
+func SetPermissions(p *Permissions) {
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+	permissions = p
+}
+
+// ActivePermissions returns the installed permissions, or nil.
+func ActivePermissions() *Permissions {
+	permissionsMu.Lock()
+	defer permissionsMu.Unlock()
+	return permissions
+}
+
+// Allowed reports whether the request is allowed without asking.
+func (p *Permissions) Allowed(r PermissionRequest) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.allowedLocked(r)
+}
+
+func (p *Permissions) allowedLocked(r PermissionRequest) bool {
+	for _, pattern := range p.allowed[r.Kind] {
+		if r.Kind == PermissionCommand && matchCommand(pattern, r.Subject) {
+			return true
+		}
+		if r.Kind == PermissionPath && withinDir(r.Subject, p.resolve(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check allows the request, asking the user when it was not allowed
+// before. An error explains a refusal to the agent.
+func (p *Permissions) Check(r PermissionRequest) error {
+	// One prompt at a time, so an answer covers the calls waiting on it
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.allowedLocked(r) || p.Prompt == nil {
+		return nil
+	}
+
+	decision, err := p.Prompt(r)
+	if err != nil {
+		return fmt.Errorf("could not ask the user for permission: %w", err)
+	}
+	switch decision {
+	case AllowOnce:
+		return nil
+	case AllowSession, AllowProject:
+		pattern := r.Subject
+		if r.Kind == PermissionPath {
+			pattern = displayPath(r.Subject)
+		}
+		p.allowed[r.Kind] = append(p.allowed[r.Kind], pattern)
+		if decision == AllowProject && p.Remember != nil {
+			if err := p.Remember(r.Kind, pattern); err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  Could not save the decision to the project policy: %v\n", err)
+			}
+		}
+		return nil
+	}
+	if r.Kind == PermissionCommand {
+		return fmt.Errorf("the user did not allow running %q; do without it or ask the user", r.Subject)
+	}
+	return fmt.Errorf("the user did not allow writing to %s, outside the project", displayPath(r.Subject))
+}
+
+func (p *Permissions) resolve(pattern string) string {
+	if home, err := os.UserHomeDir(); err == nil && (pattern == "~" || strings.HasPrefix(pattern, "~/")) {
+		return filepath.Join(home, pattern[1:])
+	}
+	if !filepath.IsAbs(pattern) {
+		return filepath.Join(p.Root, pattern)
+	}
+	return filepath.Clean(pattern)
+}
+
+// checkPermission asks the installed permissions about the commands and
+// outside writes a tool call makes.
+func checkPermission(call ToolCall, workdir string) error {
+	p := ActivePermissions()
+	if p == nil {
+		return nil
+	}
+	switch call.Name {
+	case "run_command":
+		command, _ := call.Arguments["command"].(string)
+		if strings.TrimSpace(command) == "" {
+			return nil
+		}
+		return p.Check(PermissionRequest{Tool: call.Name, Kind: PermissionCommand, Subject: strings.TrimSpace(command)})
+	case "write_file", "apply_patch", "apply_diff":
+		for _, path := range WritePaths(call.Name, call.Arguments) {
+			// As the write tools resolve it, so "../" is how a write escapes
+			abs := filepath.Join(workdir, path)
+			if withinDir(abs, workdir) {
+				continue
+			}
+			if err := p.Check(PermissionRequest{Tool: call.Name, Kind: PermissionPath, Subject: filepath.Dir(abs)}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// matchCommand matches a command line against an exact command or a prefix
+// ending in "*". A prefix does not extend to chained commands, so
+// "go test *" does not allow "go test ./... && curl ...".
+func matchCommand(pattern, command string) bool {
+	prefix, ok := strings.CutSuffix(pattern, "*")
+	if !ok {
+		return pattern == command
+	}
+	if command == strings.TrimSpace(prefix) {
+		return true
+	}
+	rest, ok := strings.CutPrefix(command, prefix)
+	return ok && !strings.ContainsAny(rest, ";&|`$<>\n")
+}
+
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// displayPath shortens paths under the home directory to ~/...
+func displayPath(path string) string {
+	if home, err := os.UserHomeDir(); err == nil && withinDir(path, home) {
+		rel, _ := filepath.Rel(home, path)
+		return filepath.Join("~", rel)
+	}
+	return path
+}
+
+// TerminalPermissionPrompter asks for permissions on out and reads the
+// answers from in.
+func TerminalPermissionPrompter(in io.Reader, out io.Writer) PermissionPrompter {
+	reader := bufio.NewReader(in)
+	return func(r PermissionRequest) (Decision, error) {
+		defer output.LockTerminal()()
+		if r.Kind == PermissionCommand {
+			fmt.Fprintf(out, "\n? Allow %s to run: %s\n", r.Tool, r.Subject)
+			if r.Reason != "" {
+				fmt.Fprintf(out, "  (%s)\n", r.Reason)
+			}
+		} else {
+			fmt.Fprintf(out, "\n? Allow %s to write to %s, outside the project?\n", r.Tool, displayPath(r.Subject))
+		}
+		fmt.Fprint(out, "  [y] yes  [n] no  [s] always this session  [p] always for this project\n> ")
+
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return Deny, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return AllowOnce, nil
+		case "s", "session":
+			return AllowSession, nil
+		case "p", "project":
+			return AllowProject, nil
+		}
+		return Deny, nil
+	}
+}
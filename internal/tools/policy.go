@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// CommandVerdict is what the command policy decides about a command line
+type CommandVerdict int
+
+const (
+	CommandAllowed CommandVerdict = iota
+	// CommandConfirm commands run only once the user confirms them
+	CommandConfirm
+	// CommandDenied commands never run
+	CommandDenied
+)
+
+// policyRule matches command lines the policy denies or confirms
+type policyRule struct {
+	re     *regexp.Regexp
+	reason string
+}
+
+// defaultDenyRules block commands that destroy data beyond the task or run
+// code from the network. Recursive forced deletes and writes outside the
+// project are checked by commandEffects.
+var defaultDenyRules = []policyRule{
+	{regexp.MustCompile(`\b(curl|wget|fetch)\b[^;&|]*\|\s*(sudo\s+)?(ba|z|da|k|fi)?sh\b`), "runs a downloaded script (curl | sh)"},
+	{regexp.MustCompile(`\b(ba|z|da|k)?sh\s+(-c\s+)?["']?(\$\(|<\()\s*(curl|wget)\b`), "runs a downloaded script"},
+	{regexp.MustCompile(`\bmkfs(\.\w+)?\b|\bdd\b[^;&|]*\bof=/dev/|>\s*/dev/(sd|hd|nvme|disk|mmcblk)`), "writes to a disk device"},
+	{regexp.MustCompile(`:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`), "fork bomb"},
+	{regexp.MustCompile(`\bchmod\s+(-\w+\s+)*[0-7]*777\s+/(\s|$)|\bchown\s+-R\s+\S+\s+/(\s|$)`), "changes permissions of the whole system"},
+}
+
+// defaultConfirmRules are commands with effects outside the working tree
+// that are sometimes what the task asks for
+var defaultConfirmRules = []policyRule{
+	{regexp.MustCompile(`\bgit\s+push\b`), "publishes commits"},
+	{regexp.MustCompile(`\bgit\s+(reset\s+--hard|clean\s+-\w*f|checkout\s+\.(\s|$)|stash\s+(drop|clear))`), "discards local changes"},
+	{regexp.MustCompile(`\b(npm|pnpm|yarn)\s+publish\b|\bcargo\s+publish\b|\bgem\s+push\b|\btwine\s+upload\b|\bmix\s+hex\.publish\b`), "publishes a package"},
+	{regexp.MustCompile(`\b(kubectl|helm)\s+(apply|delete|install|upgrade|uninstall|rollback)\b|\bterraform\s+(apply|destroy)\b`), "changes infrastructure"},
+	{regexp.MustCompile(`\b(brew|apt|apt-get|yum|dnf|pacman)\s+(install|remove|upgrade|-S)\b|\b(npm|pnpm|yarn)\s+(install|i|add)\s+(-g|--global)\b|\bpip3?\s+install\b[^;&|]*--user\b`), "installs system-wide packages"},
+	{regexp.MustCompile(`\bdocker\s+(system\s+prune|volume\s+rm|rmi)\b`), "deletes docker data"},
+}
+
+// CommandPolicy decides which command lines run_command may run: denied
+// commands are refused, commands in the confirm category run once the user
+// confirms them and anything else runs. It is checked on every run, unlike
+// the permissions of supervised runs.
+type CommandPolicy struct {
+	// Confirm asks the user about confirm-category commands and skips those
+	// the project policy allows; when nil they are refused.
+	Confirm *Permissions
+
+	deny    []policyRule
+	confirm []policyRule
+}
+
+// NewCommandPolicy returns the default policy extended with the project's
+// deny and confirm patterns, regular expressions matched anywhere in the
+// command line. A pattern that does not compile is matched as plain text.
+func NewCommandPolicy(deny, confirm []string) *CommandPolicy {
+	return &CommandPolicy{
+		deny:    append(append([]policyRule(nil), defaultDenyRules...), projectRules(deny, "denied by the project policy")...),
+		confirm: append(append([]policyRule(nil), defaultConfirmRules...), projectRules(confirm, "the project policy asks to confirm it")...),
+	}
+}
+
+func projectRules(patterns []string, reason string) []policyRule {
+	var rules []policyRule
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			re = regexp.MustCompile(regexp.QuoteMeta(pattern))
+		}
+		rules = append(rules, policyRule{re, fmt.Sprintf("%s (%s)", reason, pattern)})
+	}
+	return rules
+}
+
+var (
+	commandPolicyMu sync.Mutex
+	commandPolicy   *CommandPolicy
+)
+
+// SetCommandPolicy installs the policy run_command checks; nil, as with
+// --yolo, removes it.
+func SetCommandPolicy(p *CommandPolicy) {
+	commandPolicyMu.Lock()
+	defer commandPolicyMu.Unlock()
+	commandPolicy = p
+}
+
+// ActiveCommandPolicy returns the installed policy, or nil.
+func ActiveCommandPolicy() *CommandPolicy {
+	commandPolicyMu.Lock()
+	defer commandPolicyMu.Unlock()
+	return commandPolicy
+}
+
+// Classify returns the verdict for command run in workdir and its reason
+func (p *CommandPolicy) Classify(command, workdir string) (CommandVerdict, string) {
+	for _, rule := range p.deny {
+		if rule.re.MatchString(command) {
+			return CommandDenied, rule.reason
+		}
+	}
+	verdict, reason := commandEffects(command, workdir)
+	if verdict != CommandAllowed {
+		return verdict, reason
+	}
+	for _, rule := range p.confirm {
+		if rule.re.MatchString(command) {
+			return CommandConfirm, rule.reason
+		}
+	}
+	return CommandAllowed, ""
+}
+
+// checkCommandPolicy refuses run_command calls the installed policy denies
+// and asks about those it wants confirmed. Supervised runs ask about every
+// command already, so confirmation is left to their permissions.
+func checkCommandPolicy(call ToolCall, workdir string) error {
+	p := ActiveCommandPolicy()
+	if p == nil || call.Name != "run_command" {
+		return nil
+	}
+	command, _ := call.Arguments["command"].(string)
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return nil
+	}
+
+	verdict, reason := p.Classify(command, workdir)
+	switch verdict {
+	case CommandDenied:
+		return fmt.Errorf("the command policy does not allow %q: %s. Find another way, or ask the user to run it", command, reason)
+	case CommandConfirm:
+		if ActivePermissions() != nil {
+			return nil
+		}
+		if p.Confirm == nil {
+			return fmt.Errorf("%q needs the user's confirmation (%s), which cannot be asked for here; do without it or ask the user to run it", command, reason)
+		}
+		return p.Confirm.Check(PermissionRequest{Tool: call.Name, Kind: PermissionCommand, Subject: command, Reason: reason})
+	}
+	return nil
+}
+
+// commandSeparators split a command line into the commands it chains
+var commandSeparators = regexp.MustCompile(`&&|\|\||[;&|\n]`)
+
+// commandEffects looks at each command of a chain for recursive deletes and
+// for writes, by redirection, tee, cp or mv, outside workdir
+func commandEffects(command, workdir string) (CommandVerdict, string) {
+	verdict, reason := CommandAllowed, ""
+	for _, segment := range commandSeparators.Split(command, -1) {
+		fields := strings.Fields(segment)
+		args := fields
+		// Skip what runs the command: sudo, env and variable assignments
+		for len(args) > 0 && (args[0] == "sudo" || args[0] == "env" || args[0] == "command" || strings.Contains(args[0], "=")) {
+			args = args[1:]
+		}
+		if len(args) > 0 && args[0] == "rm" {
+			recursive, force := rmFlags(args[1:])
+			if recursive && force {
+				return CommandDenied, "recursive forced delete (rm -rf)"
+			}
+			if recursive {
+				verdict, reason = CommandConfirm, "recursive delete"
+			}
+		}
+		for _, target := range writeTargets(fields, args) {
+			if outside(target, workdir) {
+				return CommandDenied, fmt.Sprintf("writes to %s, outside the project", target)
+			}
+		}
+	}
+	return verdict, reason
+}
+
+func rmFlags(args []string) (recursive, force bool) {
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			return
+		case arg == "--recursive":
+			recursive = true
+		case arg == "--force":
+			force = true
+		case strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--"):
+			recursive = recursive || strings.ContainsAny(arg, "rR")
+			force = force || strings.Contains(arg, "f")
+		}
+	}
+	return
+}
+
+// writeTargets returns the files a command writes to by redirection, tee,
+// or as the destination of cp and mv. args are the fields from the command
+// name on.
+func writeTargets(fields, args []string) []string {
+	var targets []string
+	for i, f := range fields {
+		if rest, ok := strings.CutPrefix(strings.TrimLeft(f, "0123456789&"), ">"); ok {
+			rest = strings.TrimPrefix(rest, ">")
+			if rest == "" && i+1 < len(fields) {
+				rest = fields[i+1]
+			}
+			if rest != "" && !strings.HasPrefix(rest, "&") {
+				targets = append(targets, rest)
+			}
+		}
+	}
+	if len(args) == 0 {
+		return targets
+	}
+	var operands []string
+	for _, f := range args[1:] {
+		if !strings.HasPrefix(f, "-") && !strings.ContainsAny(f, "<>") {
+			operands = append(operands, f)
+		}
+	}
+	switch args[0] {
+	case "tee":
+		targets = append(targets, operands...)
+	case "cp", "mv", "install", "ln":
+		if len(operands) >= 2 {
+			targets = append(targets, operands[len(operands)-1])
+		}
+	}
+	return targets
+}
+
+// outside reports whether target, resolved from workdir, is outside it.
+// Devices such as /dev/null and the temporary directory are not.
+func outside(target, workdir string) bool {
+	target = strings.Trim(target, `"'`)
+	if strings.HasPrefix(target, "$") || target == "" {
+		// A variable could be anywhere; run_command has no way to tell
+		return false
+	}
+	if home, err := os.UserHomeDir(); err == nil && (target == "~" || strings.HasPrefix(target, "~/")) {
+		target = filepath.Join(home, target[1:])
+	}
+	workdir, _ = filepath.Abs(workdir)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(workdir, target)
+	}
+	target = filepath.Clean(target)
+	for _, dir := range []string{workdir, "/dev", os.TempDir(), "/tmp"} {
+		if withinDir(target, dir) {
+			return false
+		}
+	}
+	return true
+}
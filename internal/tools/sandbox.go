@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gptcode/internal/langdetect"
+)
+
+// Executor runs the command lines of run_command
+type Executor interface {
+	// Run runs command with sh in workdir and returns its combined output
+	Run(ctx context.Context, command, workdir string) ([]byte, error)
+}
+
+// HostExecutor runs commands on this machine, the default
+type HostExecutor struct{}
+
+func (HostExecutor) Run(ctx context.Context, command, workdir string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = workdir
+	// Children of sh can keep the output open after it is killed
+	cmd.WaitDelay = time.Second
+	return cmd.CombinedOutput()
+}
+
+// DefaultSandboxImages are the images DockerExecutor runs each language's
+// projects in
+var DefaultSandboxImages = map[string]string{
+	string(langdetect.Go):         "golang:1.24",
+	string(langdetect.TypeScript): "node:22",
+	string(langdetect.Python):     "python:3.12",
+	string(langdetect.Ruby):       "ruby:3.3",
+	string(langdetect.Elixir):     "elixir:1.17",
+	string(langdetect.Rust):       "rust:1",
+	"default":                     "debian:bookworm-slim",
+}
+
+// DockerExecutor runs each command in a disposable container. The
+// repository is mounted read-write at its own path, so paths in the output
+// match the host's, and the command runs as the current user so the files
+// it writes stay the user's. Its .git is read-only, since hooks or config
+// written there would run on the host at the next git command. Nothing else
+// of the host is visible.
+type DockerExecutor struct {
+	// Images override DefaultSandboxImages by language
+	Images map[string]string
+	// Network is the container's network, e.g. none; empty uses Docker's
+	// default
+	Network string
+	// Memory and CPUs limit the container, e.g. 2g and 2
+	Memory string
+	CPUs   string
+	// Env are extra KEY=value variables set in the container
+	Env []string
+	// Docker is the docker binary (default "docker")
+	Docker string
+}
+
+var sandboxCount atomic.Int64
+
+// Image returns the image commands in workdir run in
+func (d *DockerExecutor) Image(workdir string) string {
+	lang := string(langdetect.DetectLanguage(workdir))
+	for _, image := range []string{d.Images[lang], DefaultSandboxImages[lang], d.Images["default"]} {
+		if image != "" {
+			return image
+		}
+	}
+	return DefaultSandboxImages["default"]
+}
+
+// Args returns the docker run arguments that run command in workdir, in
+// a container called name
+func (d *DockerExecutor) Args(name, command, workdir string) []string {
+	abs, err := filepath.Abs(workdir)
+	if err != nil {
+		abs = workdir
+	}
+	mount := abs
+	if root := langdetect.RepoRoot(abs); root != "" {
+		mount = root
+	}
+
+	args := []string{"run", "--rm", "--name", name, "--label", "gptcode.sandbox=1",
+		"-v", mount + ":" + mount}
+	if gitDir := filepath.Join(mount, ".git"); statOK(gitDir) {
+		args = append(args, "-v", gitDir+":"+gitDir+":ro")
+	}
+	args = append(args, "-w", abs, "-e", "HOME=/tmp", "-e", "CI=1")
+	if runtime.GOOS == "linux" {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	if d.Network != "" {
+		args = append(args, "--network", d.Network)
+	}
+	if d.Memory != "" {
+		args = append(args, "--memory", d.Memory)
+	}
+	if d.CPUs != "" {
+		args = append(args, "--cpus", d.CPUs)
+	}
+	for _, env := range d.Env {
+		args = append(args, "-e", env)
+	}
+	return append(args, d.Image(abs), "sh", "-c", command)
+}
+
+func statOK(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (d *DockerExecutor) Run(ctx context.Context, command, workdir string) ([]byte, error) {
+	docker := d.Docker
+	if docker == "" {
+		docker = "docker"
+	}
+	name := fmt.Sprintf("gptcode-sandbox-%d-%d", os.Getpid(), sandboxCount.Add(1))
+	cmd := exec.CommandContext(ctx, docker, d.Args(name, command, workdir)...)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		// Killing the client leaves the container running
+		_ = exec.Command(docker, "rm", "-f", name).Run()
+	}
+	// docker run exits with 125 when the container could not start, e.g.
+	// a missing image or a stopped daemon
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 125 {
+		return out, fmt.Errorf("docker sandbox: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return out, err
+}
+
+var (
+	executorMu sync.Mutex
+	executor   Executor = HostExecutor{}
+)
+
+// SetExecutor selects where run_command runs; nil runs commands on the
+// host.
+func SetExecutor(e Executor) {
+	executorMu.Lock()
+	defer executorMu.Unlock()
+	if e == nil {
+		e = HostExecutor{}
+	}
+	executor = e
+}
+
+// ActiveExecutor returns the executor run_command uses
+func ActiveExecutor() Executor {
+	executorMu.Lock()
+	defer executorMu.Unlock()
+	return executor
+}
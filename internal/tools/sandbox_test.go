@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerExecutorArgs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := &DockerExecutor{Network: "none", Memory: "2g", Env: []string{"GOFLAGS=-mod=mod"}}
+	args := d.Args("box", "go test ./...", dir)
+	line := strings.Join(args, " ")
+	for _, want := range []string{
+		"run --rm --name box",
+		"-v " + dir + ":" + dir,
+		"-w " + dir,
+		"--network none",
+		"--memory 2g",
+		"-e GOFLAGS=-mod=mod",
+	} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %s", want, line)
+		}
+	}
+	if tail := args[len(args)-4:]; strings.Join(tail, "|") != "golang:1.24|sh|-c|go test ./..." {
+		t.Errorf("expected the Go image running the command, got %v", tail)
+	}
+
+	if strings.Contains(line, ":ro") {
+		t.Errorf("nothing is read-only without a .git, got %s", line)
+	}
+	// Hooks and config written to .git would run on the host
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitDir := filepath.Join(dir, ".git")
+	if line := strings.Join(d.Args("box", "true", dir), " "); !strings.Contains(line, "-v "+dir+":"+dir+" -v "+gitDir+":"+gitDir+":ro") {
+		t.Errorf("expected .git mounted read-only over the repository, got %s", line)
+	}
+
+	d.Images = map[string]string{"go": "golang:1.22-alpine"}
+	if image := d.Image(dir); image != "golang:1.22-alpine" {
+		t.Errorf("expected the configured image, got %s", image)
+	}
+	d.Images = map[string]string{"default": "alpine"}
+	if image := d.Image(dir); image != "golang:1.24" {
+		t.Errorf("expected the language's image before the configured default, got %s", image)
+	}
+	if image := d.Image(t.TempDir()); image != "alpine" {
+		t.Errorf("expected the configured default for other projects, got %s", image)
+	}
+}
+
+func TestDockerExecutorRun(t *testing.T) {
+	dir := t.TempDir()
+	docker := filepath.Join(dir, "docker")
+	// Echoes the command it was asked to run, and fails like docker does
+	// when it cannot start the container
+	script := "#!/bin/sh\nfor last; do :; done\nif [ \"$last\" = fail ]; then echo 'Unable to find image'; exit 125; fi\necho \"ran: $last\"\n"
+	if err := os.WriteFile(docker, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	d := &DockerExecutor{Docker: docker}
+
+	out, err := d.Run(context.Background(), "make test", dir)
+	if err != nil || strings.TrimSpace(string(out)) != "ran: make test" {
+		t.Fatalf("unexpected run: %q, %v", out, err)
+	}
+	if _, err := d.Run(context.Background(), "fail", dir); err == nil || !strings.Contains(err.Error(), "docker sandbox") || !strings.Contains(err.Error(), "Unable to find image") {
+		t.Errorf("expected the sandbox error, got %v", err)
+	}
+}
+
+type recordingExecutor struct {
+	command string
+}
+
+func (r *recordingExecutor) Run(ctx context.Context, command, workdir string) ([]byte, error) {
+	r.command = command
+	return []byte("sandboxed\n"), nil
+}
+
+func TestRunCommandUsesExecutor(t *testing.T) {
+	rec := &recordingExecutor{}
+	SetExecutor(rec)
+	defer SetExecutor(nil)
+
+	result := ExecuteTool(ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": "ls"}}, t.TempDir())
+	if rec.command != "ls" || result.Result != "sandboxed\n" || result.Error != "" {
+		t.Errorf("expected run_command to go through the executor, got %+v", result)
+	}
+
+	SetExecutor(nil)
+	if _, ok := ActiveExecutor().(HostExecutor); !ok {
+		t.Error("expected nil to restore the host executor")
+	}
+}
@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ShellTool is a tool declared in configuration that runs a command line,
+// such as a migrate_db tool wrapping a project script. Command is a
+// text/template whose fields are the call's arguments, shell-quoted:
+//
+//	./scripts/migrate.sh {{.direction}}{{if .steps}} --steps {{.steps}}{{end}}
+type ShellTool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments (default: none)
+	Parameters map[string]interface{}
+	Command    string
+	// Timeout limits one run (default DefaultShellToolTimeout)
+	Timeout time.Duration
+}
+
+// DefaultShellToolTimeout is how long a shell tool may run by default
+const DefaultShellToolTimeout = 5 * time.Minute
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_-]{0,63}$`)
+
+// builtinToolNames are the tools ExecuteTool implements itself
+func builtinToolNames() map[string]bool {
+	names := map[string]bool{"ask_user": true}
+	external := map[string]bool{}
+	for _, def := range ExternalToolDefinitions() {
+		external[def["function"].(map[string]interface{})["name"].(string)] = true
+	}
+	for _, def := range GetAvailableTools() {
+		name := def["function"].(map[string]interface{})["name"].(string)
+		if !external[name] {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// Validate checks the tool's name, schema and command template
+func (t ShellTool) Validate() error {
+	if !toolNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid tool name %q", t.Name)
+	}
+	if builtinToolNames()[t.Name] {
+		return fmt.Errorf("tool %s: the name is taken by a builtin tool", t.Name)
+	}
+	if strings.TrimSpace(t.Command) == "" {
+		return fmt.Errorf("tool %s has no command", t.Name)
+	}
+	if t.Parameters != nil {
+		if typ, _ := t.Parameters["type"].(string); typ != "object" {
+			return fmt.Errorf("tool %s: parameters must be a JSON schema of type object", t.Name)
+		}
+	}
+	if _, err := template.New(t.Name).Parse(t.Command); err != nil {
+		return fmt.Errorf("tool %s: invalid command template: %w", t.Name, err)
+	}
+	return nil
+}
+
+// CommandLine renders the command with args, each shell-quoted. Arguments
+// not given render empty.
+func (t ShellTool) CommandLine(args map[string]interface{}) (string, error) {
+	tmpl, err := template.New(t.Name).Option("missingkey=zero").Parse(t.Command)
+	if err != nil {
+		return "", err
+	}
+	data := map[string]string{}
+	if props, ok := t.Parameters["properties"].(map[string]interface{}); ok {
+		for name := range props {
+			data[name] = ""
+		}
+	}
+	for name, v := range args {
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case float64, bool, int:
+			s = fmt.Sprint(v)
+		default:
+			b, _ := json.Marshal(v)
+			s = string(b)
+		}
+		data[name] = shellQuote(s)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// shellQuote quotes s for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// RegisterShellTools validates tools and registers them as external tools
+// running in workdir. Their command lines go through the command policy and
+// the supervised permissions, as run_command's do.
+func RegisterShellTools(shellTools []ShellTool, workdir string) error {
+	for _, t := range shellTools {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, t := range shellTools {
+		RegisterExternalTool(ExternalTool{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.Parameters,
+			Call: func(args map[string]interface{}) (string, error) {
+				return t.run(args, workdir)
+			},
+		})
+	}
+	return nil
+}
+
+func (t ShellTool) run(args map[string]interface{}, workdir string) (string, error) {
+	command, err := t.CommandLine(args)
+	if err != nil {
+		return "", err
+	}
+	if err := checkCommandPolicy(ToolCall{Name: "run_command", Arguments: map[string]interface{}{"command": command}}, workdir); err != nil {
+		return "", err
+	}
+	// Supervised runs ask about the command line, as they do for run_command
+	if p := ActivePermissions(); p != nil {
+		var reason string
+		if policy := ActiveCommandPolicy(); policy != nil {
+			_, reason = policy.Classify(command, workdir)
+		}
+		if err := p.Check(PermissionRequest{Tool: t.Name, Kind: PermissionCommand, Subject: command, Reason: reason}); err != nil {
+			return "", err
+		}
+	}
+
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = DefaultShellToolTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	out, err := ActiveExecutor().Run(ctx, command, workdir)
+	if ctx.Err() == context.DeadlineExceeded {
+		return string(out), fmt.Errorf("%s timed out after %s", t.Name, timeout)
+	}
+	return string(out), err
+}
@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellToolCommandLine(t *testing.T) {
+	tool := ShellTool{
+		Name: "migrate_db",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"direction": map[string]interface{}{"type": "string"},
+				"steps":     map[string]interface{}{"type": "integer"},
+			},
+		},
+		Command: "./migrate.sh {{.direction}}{{if .steps}} --steps {{.steps}}{{end}}",
+	}
+	got, err := tool.CommandLine(map[string]interface{}{"direction": "up; rm -rf /"})
+	if err != nil || got != `./migrate.sh 'up; rm -rf /'` {
+		t.Errorf("CommandLine = %q, %v", got, err)
+	}
+	got, _ = tool.CommandLine(map[string]interface{}{"direction": "it's", "steps": float64(2)})
+	if got != `./migrate.sh 'it'\''s' --steps '2'` {
+		t.Errorf("CommandLine = %q", got)
+	}
+}
+
+func TestShellToolValidate(t *testing.T) {
+	cases := []struct {
+		tool ShellTool
+		want string
+	}{
+		{ShellTool{Name: "read_file", Command: "cat"}, "builtin"},
+		{ShellTool{Name: "bad name", Command: "true"}, "invalid tool name"},
+		{ShellTool{Name: "empty"}, "no command"},
+		{ShellTool{Name: "tmpl", Command: "echo {{.x"}, "invalid command template"},
+		{ShellTool{Name: "schema", Command: "true", Parameters: map[string]interface{}{"type": "string"}}, "type object"},
+	}
+	for _, c := range cases {
+		if err := c.tool.Validate(); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got %v, want an error about %s", c.tool.Name, err, c.want)
+		}
+	}
+}
+
+func TestRegisterShellTools(t *testing.T) {
+	defer UnregisterExternalTools()
+	dir := t.TempDir()
+	err := RegisterShellTools([]ShellTool{{
+		Name:        "touch_file",
+		Description: "Create a file",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"name": map[string]interface{}{"type": "string"}},
+		},
+		Command: "touch {{.name}} && echo created {{.name}}",
+	}}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ExternalToolDefinition("touch_file"); !ok {
+		t.Fatal("the shell tool should be offered to the agents")
+	}
+
+	result := ExecuteTool(ToolCall{Name: "touch_file", Arguments: map[string]interface{}{"name": "a b.txt"}}, dir)
+	if result.Error != "" || strings.TrimSpace(result.Result) != "created a b.txt" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a b.txt")); err != nil {
+		t.Error("the command should run in the registered directory")
+	}
+
+	if err := RegisterShellTools([]ShellTool{{Name: "write_file", Command: "true"}}, dir); err == nil {
+		t.Error("a shell tool must not replace a builtin one")
+	}
+}
+
+func TestShellToolAsksPermission(t *testing.T) {
+	defer UnregisterExternalTools()
+	defer SetPermissions(nil)
+	defer SetCommandPolicy(nil)
+	dir := t.TempDir()
+	if err := RegisterShellTools([]ShellTool{{Name: "clean", Command: "rm -r build"}}, dir); err != nil {
+		t.Fatal(err)
+	}
+	SetCommandPolicy(NewCommandPolicy(nil, nil))
+	var asked []PermissionRequest
+	SetPermissions(NewPermissions(dir, nil, nil, func(r PermissionRequest) (Decision, error) {
+		asked = append(asked, r)
+		return Deny, nil
+	}))
+
+	result := ExecuteTool(ToolCall{Name: "clean", Arguments: map[string]interface{}{}}, dir)
+	if !strings.Contains(result.Error, "did not allow") {
+		t.Fatalf("denied command should not run: %+v", result)
+	}
+	if len(asked) != 1 || asked[0].Tool != "clean" || asked[0].Subject != "rm -r build" || asked[0].Reason != "recursive delete" {
+		t.Errorf("unexpected prompts %+v", asked)
+	}
+}
@@ -0,0 +1,127 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SSHTarget is a remote machine commands run on, written
+// ssh://[user@]host[:port][/dir]
+type SSHTarget struct {
+	User string
+	Host string
+	Port int
+	// Dir is where commands run; the login directory when empty
+	Dir string
+}
+
+// ParseSSHTarget parses an ssh:// URL; a bare [user@]host is accepted too
+func ParseSSHTarget(target string) (SSHTarget, error) {
+	if !strings.Contains(target, "://") {
+		target = "ssh://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return SSHTarget{}, fmt.Errorf("invalid target %q: %w", target, err)
+	}
+	if u.Scheme != "ssh" {
+		return SSHTarget{}, fmt.Errorf("unsupported target %q: only ssh:// is supported", target)
+	}
+	if u.Hostname() == "" {
+		return SSHTarget{}, fmt.Errorf("invalid target %q: no host", target)
+	}
+	t := SSHTarget{User: u.User.Username(), Host: u.Hostname(), Dir: u.Path}
+	if p := u.Port(); p != "" {
+		if t.Port, err = strconv.Atoi(p); err != nil {
+			return SSHTarget{}, fmt.Errorf("invalid port in %q", target)
+		}
+	}
+	return t, nil
+}
+
+func (t SSHTarget) String() string {
+	s := "ssh://"
+	if t.User != "" {
+		s += t.User + "@"
+	}
+	s += t.Host
+	if t.Port != 0 {
+		s += ":" + strconv.Itoa(t.Port)
+	}
+	return s + t.Dir
+}
+
+// Allowed reports whether the host matches one of patterns, shell globs
+// such as *.staging.example.com. No patterns allow no host.
+func (t SSHTarget) Allowed(patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(strings.TrimSpace(p)), strings.ToLower(t.Host)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SSHExecutor runs commands on a remote machine with the ssh client, so the
+// user's keys, agent and ~/.ssh/config apply. It never prompts: hosts that
+// need a password or an unknown host key fail.
+type SSHExecutor struct {
+	Target SSHTarget
+	// Stream also copies the output there as it arrives
+	Stream io.Writer
+	// Env are KEY=value variables exported before the command
+	Env []string
+	// SSH is the ssh binary (default "ssh")
+	SSH string
+}
+
+// Args returns the ssh arguments that run command on the target
+func (s *SSHExecutor) Args(command string) []string {
+	args := []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=15"}
+	if s.Target.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(s.Target.Port))
+	}
+	dest := s.Target.Host
+	if s.Target.User != "" {
+		dest = s.Target.User + "@" + dest
+	}
+	if s.Target.Dir != "" {
+		command = "cd " + shellQuote(s.Target.Dir) + " && " + command
+	}
+	for i := len(s.Env) - 1; i >= 0; i-- {
+		if k, v, ok := strings.Cut(s.Env[i], "="); ok {
+			command = "export " + k + "=" + shellQuote(v) + "; " + command
+		}
+	}
+	return append(args, dest, "--", command)
+}
+
+// Run runs command in the target's directory; the local workdir means
+// nothing on the remote machine and is ignored.
+func (s *SSHExecutor) Run(ctx context.Context, command, workdir string) ([]byte, error) {
+	bin := s.SSH
+	if bin == "" {
+		bin = "ssh"
+	}
+	cmd := exec.CommandContext(ctx, bin, s.Args(command)...)
+	var out bytes.Buffer
+	w := io.Writer(&out)
+	if s.Stream != nil {
+		w = io.MultiWriter(&out, s.Stream)
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	err := cmd.Run()
+	// ssh exits with 255 for its own errors: refused connections, host
+	// keys, authentication
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 255 {
+		return out.Bytes(), fmt.Errorf("ssh %s: %w: %s", s.Target.Host, err, strings.TrimSpace(out.String()))
+	}
+	return out.Bytes(), err
+}
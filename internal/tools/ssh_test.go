@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSSHTarget(t *testing.T) {
+	cases := []struct {
+		in   string
+		want SSHTarget
+	}{
+		{"ssh://web-1", SSHTarget{Host: "web-1"}},
+		{"ssh://deploy@web-1.example.com:2222/srv/app", SSHTarget{User: "deploy", Host: "web-1.example.com", Port: 2222, Dir: "/srv/app"}},
+		{"deploy@10.0.0.5", SSHTarget{User: "deploy", Host: "10.0.0.5"}},
+	}
+	for _, c := range cases {
+		got, err := ParseSSHTarget(c.in)
+		if err != nil || got != c.want {
+			t.Errorf("%s: expected %+v, got %+v (%v)", c.in, c.want, got, err)
+		}
+	}
+	for _, bad := range []string{"http://web-1", "ssh://", "ssh://web-1:port"} {
+		if _, err := ParseSSHTarget(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+	if s := cases[1].want.String(); s != "ssh://deploy@web-1.example.com:2222/srv/app" {
+		t.Errorf("unexpected String: %s", s)
+	}
+}
+
+func TestSSHTargetAllowed(t *testing.T) {
+	target := SSHTarget{Host: "API-1.Staging.example.com"}
+	if target.Allowed(nil) {
+		t.Error("expected no host allowed without patterns")
+	}
+	if !target.Allowed([]string{"web-1", "*.staging.example.com"}) {
+		t.Error("expected the glob to allow the host")
+	}
+	if target.Allowed([]string{"*.prod.example.com", "api-1"}) {
+		t.Error("expected other hosts to be refused")
+	}
+}
+
+func TestSSHExecutorArgs(t *testing.T) {
+	s := &SSHExecutor{
+		Target: SSHTarget{User: "deploy", Host: "web-1", Port: 2222, Dir: "/srv/it's"},
+		Env:    []string{"A=1", "B=x y"},
+	}
+	args := s.Args("systemctl status nginx")
+	line := strings.Join(args, " ")
+	if !strings.Contains(line, "BatchMode=yes") || !strings.Contains(line, "-p 2222") {
+		t.Errorf("expected non-interactive ssh on the port, got %v", args)
+	}
+	want := `export A='1'; export B='x y'; cd '/srv/it'\''s' && systemctl status nginx`
+	if tail := args[len(args)-3:]; tail[0] != "deploy@web-1" || tail[1] != "--" || tail[2] != want {
+		t.Errorf("unexpected remote command %q", tail)
+	}
+}
+
+func TestSSHExecutorRun(t *testing.T) {
+	dir := t.TempDir()
+	ssh := filepath.Join(dir, "ssh")
+	// Echoes the remote command, and fails like ssh does when it cannot
+	// connect
+	script := "#!/bin/sh\nfor last; do :; done\nif [ \"$last\" = fail ]; then echo 'Permission denied (publickey).'; exit 255; fi\necho \"ran: $last\"\nexit 3\n"
+	if err := os.WriteFile(ssh, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var streamed bytes.Buffer
+	s := &SSHExecutor{Target: SSHTarget{Host: "web-1"}, SSH: ssh, Stream: &streamed}
+
+	out, err := s.Run(context.Background(), "uptime", "/local/dir")
+	if strings.TrimSpace(string(out)) != "ran: uptime" || streamed.String() != string(out) {
+		t.Fatalf("expected the output captured and streamed, got %q and %q", out, streamed.String())
+	}
+	if err == nil || strings.Contains(err.Error(), "ssh web-1") {
+		t.Errorf("expected the remote exit status as is, got %v", err)
+	}
+	if _, err := s.Run(context.Background(), "fail", ""); err == nil || !strings.Contains(err.Error(), "Permission denied") {
+		t.Errorf("expected the ssh error, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -31,7 +32,7 @@ type ToolResult struct {
 }
 
 func GetAvailableTools() []map[string]interface{} {
-	return []map[string]interface{}{
+	builtin := []map[string]interface{}{
 		{
 			"type": "function",
 			"function": map[string]interface{}{
@@ -81,6 +82,10 @@ func GetAvailableTools() []map[string]interface{} {
 							"type":        "string",
 							"description": "Shell command to execute",
 						},
+						"explanation": map[string]interface{}{
+							"type":        "string",
+							"description": "One-line explanation of what the command does, shown to the user before it runs",
+						},
 					},
 					"required": []string{"command"},
 				},
@@ -187,6 +192,7 @@ func GetAvailableTools() []map[string]interface{} {
 				},
 			},
 		},
+		ApplyDiffTool(),
 		{
 			"type": "function",
 			"function": map[string]interface{}{
@@ -213,12 +219,23 @@ func GetAvailableTools() []map[string]interface{} {
 			},
 		},
 	}
+	return append(builtin, ExternalToolDefinitions()...)
 }
 
 func ExecuteTool(call ToolCall, workdir string) ToolResult {
+	if err := checkCommandPolicy(call, workdir); err != nil {
+		return ToolResult{Tool: call.Name, Error: err.Error()}
+	}
+	if err := checkPermission(call, workdir); err != nil {
+		return ToolResult{Tool: call.Name, Error: err.Error()}
+	}
+	if c, ok := steeredCall(call, workdir); ok {
+		return ToolResult{Tool: call.Name, Error: fmt.Sprintf("Blocked %s: the file contains instructions aimed at the assistant, which are not part of the task", c)}
+	}
+
 	switch call.Name {
 	case "read_file":
-		return readFile(call, workdir)
+		return withPathHints(call, workdir, readFile(call, workdir))
 	case "list_files":
 		return listFiles(call, workdir)
 	case "run_command":
@@ -228,14 +245,21 @@ func ExecuteTool(call ToolCall, workdir string) ToolResult {
 	case "read_guideline":
 		return readGuideline(call)
 	case "write_file":
-		return writeFile(call, workdir)
+		return journalWrite(call, workdir, func() ToolResult { return writeFile(call, workdir) })
 	case "project_map":
 		return ProjectMap(call, workdir)
 	case "apply_patch":
-		return ApplyPatch(call, workdir)
+		return withPathHints(call, workdir, journalWrite(call, workdir, func() ToolResult { return ApplyPatch(call, workdir) }))
+	case "apply_diff":
+		return journalWrite(call, workdir, func() ToolResult { return ApplyDiff(call, workdir) })
 	case "find_relevant_files":
 		return FindRelevantFiles(call, workdir)
+	case "ask_user":
+		return askUser(call)
 	default:
+		if result, ok := executeExternal(call); ok {
+			return result
+		}
 		return ToolResult{
 			Tool:  call.Name,
 			Error: "Unknown tool",
@@ -285,9 +309,13 @@ func readFile(call ToolCall, workdir string) ToolResult {
 		result = truncated + fmt.Sprintf("\n... (truncated, %d total lines)", len(lines))
 	}
 
+	flagged := ScanInjection(result)
+	if len(flagged) > 0 {
+		markTainted(path)
+	}
 	return ToolResult{
 		Tool:   "read_file",
-		Result: result,
+		Result: WrapUntrusted(path, result, flagged),
 	}
 }
 
@@ -349,9 +377,7 @@ func runCommand(call ToolCall, workdir string) ToolResult {
 		}
 	}
 
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Dir = workdir
-	output, err := cmd.CombinedOutput()
+	output, err := ActiveExecutor().Run(context.Background(), command, workdir)
 
 	result := ToolResult{
 		Tool:   "run_command",
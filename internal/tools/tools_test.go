@@ -1,8 +1,10 @@
 package tools
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -205,4 +207,148 @@ func TestApplyPatch(t *testing.T) {
 			t.Error("Expected error for missing parameters")
 		}
 	})
+
+	t.Run("go syntax error is reverted", func(t *testing.T) {
+		goPath := filepath.Join(tmpDir, "main.go")
+		original := "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"
+		os.WriteFile(goPath, []byte(original), 0644)
+
+		call := ToolCall{
+			Name: "apply_patch",
+			Arguments: map[string]interface{}{
+				"path":    "main.go",
+				"search":  "\tprintln(\"hi\")\n",
+				"replace": "\tprintln(\"hi\"\n",
+			},
+		}
+
+		result := ApplyPatch(call, tmpDir)
+		if result.Error == "" {
+			t.Fatal("Expected parse error for broken Go patch")
+		}
+		if !strings.Contains(result.Error, "Offending code") {
+			t.Errorf("Expected snippet in error, got: %s", result.Error)
+		}
+
+		newContent, _ := os.ReadFile(goPath)
+		if string(newContent) != original {
+			t.Errorf("File should be reverted. Got: %s", string(newContent))
+		}
+	})
+
+	t.Run("valid go patch", func(t *testing.T) {
+		goPath := filepath.Join(tmpDir, "ok.go")
+		os.WriteFile(goPath, []byte("package main\n\nvar x = 1\n"), 0644)
+
+		call := ToolCall{
+			Name: "apply_patch",
+			Arguments: map[string]interface{}{
+				"path":    "ok.go",
+				"search":  "var x = 1",
+				"replace": "var x = 2",
+			},
+		}
+
+		result := ApplyPatch(call, tmpDir)
+		if result.Error != "" {
+			t.Fatalf("ApplyPatch failed: %s", result.Error)
+		}
+	})
+
+	t.Run("go patch is formatted", func(t *testing.T) {
+		t.Setenv("PATH", t.TempDir())
+		goPath := filepath.Join(tmpDir, "fmt.go")
+		os.WriteFile(goPath, []byte("package main\n\nfunc main() {\n}\n"), 0644)
+
+		call := ToolCall{
+			Name: "apply_patch",
+			Arguments: map[string]interface{}{
+				"path":    "fmt.go",
+				"search":  "func main() {\n}",
+				"replace": "func main() {\nx :=   1\n  _ = x\n}",
+			},
+		}
+
+		result := ApplyPatch(call, tmpDir)
+		if result.Error != "" {
+			t.Fatalf("ApplyPatch failed: %s", result.Error)
+		}
+		if !strings.Contains(result.Result, "formatted with gofmt") {
+			t.Errorf("expected the formatting to be reported, got %q", result.Result)
+		}
+		newContent, _ := os.ReadFile(goPath)
+		if string(newContent) != "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n" {
+			t.Errorf("the file should be gofmt'd, got:\n%s", newContent)
+		}
+	})
+
+	t.Run("goimports runs once the edit is done", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("fake goimports is a shell script")
+		}
+		bin := t.TempDir()
+		os.WriteFile(filepath.Join(bin, "goimports"), []byte("#!/bin/sh\ncat\necho '// imports fixed'\n"), 0755)
+		t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+		goPath := filepath.Join(tmpDir, "imports.go")
+		os.WriteFile(goPath, []byte("package main\n\nvar y = 1\n"), 0644)
+
+		result := ApplyPatch(ToolCall{Name: "apply_patch", Arguments: map[string]interface{}{
+			"path": "imports.go", "search": "var y = 1", "replace": "var y = 2",
+		}}, tmpDir)
+		newContent, _ := os.ReadFile(goPath)
+		if result.Error != "" || string(newContent) != "package main\n\nvar y = 2\n" {
+			t.Errorf("a patch should not run goimports, got %q %s", newContent, result.Error)
+		}
+
+		FixGoImports(tmpDir, result.ModifiedFiles)
+		newContent, _ = os.ReadFile(goPath)
+		if !strings.HasSuffix(string(newContent), "var y = 2\n// imports fixed\n") {
+			t.Errorf("expected goimports' output, got %q", newContent)
+		}
+	})
+}
+
+func TestVerifyGoSource(t *testing.T) {
+	err := verifyGoSource("main.go", "package main\n\nfunc main() {\n\tprintln(\"hi\"\n}\n")
+	var perr *GoPatchError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a *GoPatchError, got %v", err)
+	}
+	if perr.Line != 4 || perr.Message == "" || !strings.Contains(perr.Snippet, ">    4 | \tprintln(\"hi\"") {
+		t.Errorf("unexpected error %+v", perr)
+	}
+	if !strings.Contains(err.Error(), "error: main.go:4:") {
+		t.Errorf("the message should locate the error: %s", err)
+	}
+	if err := verifyGoSource("ok.go", "package ok\n"); err != nil {
+		t.Errorf("valid source should pass: %v", err)
+	}
+}
+
+func TestPathHints(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"internal/auth/handler.go", "internal/auth/session.go", "cmd/app/main.go", "node_modules/handler.go"} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		os.WriteFile(filepath.Join(dir, name), []byte("package x\n"), 0644)
+	}
+
+	result := ExecuteTool(ToolCall{Name: "read_file", Arguments: map[string]interface{}{"path": "auth/handlers.go"}}, dir)
+	if !strings.Contains(result.Error, "Files with similar names:\n  internal/auth/handler.go") || strings.Contains(result.Error, "node_modules") {
+		t.Errorf("unexpected hints: %s", result.Error)
+	}
+
+	result = ExecuteTool(ToolCall{Name: "read_file", Arguments: map[string]interface{}{"path": "app/main.go"}}, dir)
+	if !strings.Contains(result.Error, "  cmd/app/main.go") {
+		t.Errorf("a path relative to the wrong directory should be found: %s", result.Error)
+	}
+
+	result = ExecuteTool(ToolCall{Name: "apply_patch", Arguments: map[string]interface{}{"path": "internal/auth/zzz.py", "search": "a", "replace": "b"}}, dir)
+	if !strings.Contains(result.Error, "Contents of internal/auth:") || !strings.Contains(result.Error, "session.go") {
+		t.Errorf("without similar names the nearest directory should be mapped: %s", result.Error)
+	}
+
+	result = ExecuteTool(ToolCall{Name: "read_file", Arguments: map[string]interface{}{"path": "cmd/app/main.go"}}, dir)
+	if result.Error != "" {
+		t.Errorf("existing file: %s", result.Error)
+	}
 }
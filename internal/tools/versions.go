@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileVersions remembers the content hash of every file an agent has read or
+// written. Writes are rejected when the file on disk no longer matches the
+// version the agent last saw, which catches another movement (or the user)
+// editing the same file in the meantime.
+type FileVersions struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+func NewFileVersions() *FileVersions {
+	return &FileVersions{hashes: make(map[string]string)}
+}
+
+// FileChangedError is returned when a write targets a file that changed after
+// the agent read it.
+type FileChangedError struct {
+	Path string
+}
+
+func (e *FileChangedError) Error() string {
+	return fmt.Sprintf("file changed since read: %s was modified after you last read it (possibly by another agent). Call read_file on it again before modifying it.", e.Path)
+}
+
+// Check validates a pending write against the recorded version. It returns
+// nil for reads, for files that were never read, and for files that are
+// unchanged.
+func (v *FileVersions) Check(call LLMToolCall, workdir string) error {
+	if !isWriteTool(call.Name) {
+		return nil
+	}
+	for _, path := range toolCallPaths(call) {
+		v.mu.Lock()
+		seen, ok := v.hashes[filepath.Clean(path)]
+		v.mu.Unlock()
+		if !ok {
+			continue
+		}
+
+		current, err := hashFile(filepath.Join(workdir, path))
+		if os.IsNotExist(err) {
+			return &FileChangedError{Path: path}
+		}
+		if err == nil && current != seen {
+			return &FileChangedError{Path: path}
+		}
+	}
+	return nil
+}
+
+// Record stores the on-disk hash after a successful read or write so later
+// writes can be validated.
+func (v *FileVersions) Record(call LLMToolCall, result ToolResult, workdir string) {
+	if result.Error != "" {
+		return
+	}
+	if call.Name != "read_file" && !isWriteTool(call.Name) {
+		return
+	}
+	for _, path := range toolCallPaths(call) {
+		hash, err := hashFile(filepath.Join(workdir, path))
+		if err != nil {
+			continue
+		}
+		v.mu.Lock()
+		v.hashes[filepath.Clean(path)] = hash
+		v.mu.Unlock()
+	}
+}
+
+func isWriteTool(name string) bool {
+	return name == "write_file" || name == "apply_patch" || name == "apply_diff"
+}
+
+func toolCallPaths(call LLMToolCall) []string {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+		return nil
+	}
+	return WritePaths(call.Name, args)
+}
+
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
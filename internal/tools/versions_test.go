@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileVersions(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "shared.go")
+	os.WriteFile(path, []byte("package shared\n"), 0644)
+
+	read := LLMToolCall{Name: "read_file", Arguments: `{"path":"shared.go"}`}
+	write := LLMToolCall{Name: "write_file", Arguments: `{"path":"shared.go","content":"package shared\n\nvar x = 1\n"}`}
+
+	t.Run("unread file can be written", func(t *testing.T) {
+		v := NewFileVersions()
+		if err := v.Check(write, tmpDir); err != nil {
+			t.Fatalf("unexpected conflict: %v", err)
+		}
+	})
+
+	t.Run("unchanged file passes", func(t *testing.T) {
+		v := NewFileVersions()
+		v.Record(read, ToolResult{Tool: "read_file"}, tmpDir)
+		if err := v.Check(write, tmpDir); err != nil {
+			t.Fatalf("unexpected conflict: %v", err)
+		}
+	})
+
+	t.Run("concurrent change is rejected", func(t *testing.T) {
+		v := NewFileVersions()
+		v.Record(read, ToolResult{Tool: "read_file"}, tmpDir)
+
+		os.WriteFile(path, []byte("package shared\n\nvar y = 2\n"), 0644)
+
+		err := v.Check(write, tmpDir)
+		if _, ok := err.(*FileChangedError); !ok {
+			t.Fatalf("expected FileChangedError, got %v", err)
+		}
+
+		v.Record(read, ToolResult{Tool: "read_file"}, tmpDir)
+		if err := v.Check(write, tmpDir); err != nil {
+			t.Fatalf("re-read should clear conflict: %v", err)
+		}
+	})
+
+	t.Run("own writes update the version", func(t *testing.T) {
+		v := NewFileVersions()
+		v.Record(read, ToolResult{Tool: "read_file"}, tmpDir)
+
+		result := ExecuteToolFromLLM(write, tmpDir)
+		v.Record(write, result, tmpDir)
+
+		if err := v.Check(write, tmpDir); err != nil {
+			t.Fatalf("own write should not conflict: %v", err)
+		}
+	})
+}
@@ -0,0 +1,192 @@
+// Package tutorial runs the interactive `gptcode learn` walkthrough: a list
+// of steps that explain a feature and then exercise it for real inside a
+// throwaway sandbox repository.
+package tutorial
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrQuit is returned when the user leaves the tutorial early
+var ErrQuit = errors.New("tutorial stopped")
+
+// Step is one lesson of the tutorial
+type Step struct {
+	Title string
+	// Explain is shown before the step runs
+	Explain string
+	// Run exercises the feature; nil steps only explain
+	Run func(s *Session) error
+}
+
+// Session is the state shared by the steps of a tutorial run
+type Session struct {
+	In      *bufio.Reader
+	Out     io.Writer
+	Sandbox string
+	// Values lets steps pass results to later steps
+	Values map[string]string
+}
+
+func NewSession(in io.Reader, out io.Writer, sandbox string) *Session {
+	return &Session{In: bufio.NewReader(in), Out: out, Sandbox: sandbox, Values: map[string]string{}}
+}
+
+// Ask prints question and returns the trimmed answer, or def when empty.
+func (s *Session) Ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(s.Out, "%s [%s] ", question, def)
+	} else {
+		fmt.Fprintf(s.Out, "%s ", question)
+	}
+	line, _ := s.In.ReadString('\n')
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// Confirm asks a yes/no question; def is the answer on Enter.
+func (s *Session) Confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer := strings.ToLower(s.Ask(fmt.Sprintf("%s (%s)", question, hint), ""))
+	if answer == "" {
+		return def
+	}
+	return answer == "y" || answer == "yes"
+}
+
+// Run walks through steps starting at index from (0-based). Before each step
+// the user can continue, skip it or quit. A failing step is reported and the
+// user decides whether to go on.
+func (s *Session) Run(steps []Step, from int) error {
+	for i := from; i < len(steps); i++ {
+		step := steps[i]
+		fmt.Fprintf(s.Out, "\n━━ Step %d/%d: %s ━━\n\n%s\n\n", i+1, len(steps), step.Title, strings.TrimSpace(step.Explain))
+		if step.Run == nil {
+			if s.pause() == "q" {
+				return ErrQuit
+			}
+			continue
+		}
+
+		switch s.prompt() {
+		case "q":
+			fmt.Fprintf(s.Out, "Resume later with: gptcode learn --step %d\n", i+1)
+			return ErrQuit
+		case "s":
+			continue
+		}
+		if err := step.Run(s); err != nil {
+			fmt.Fprintf(s.Out, "\nThis step did not complete: %v\n", err)
+			if !s.Confirm("Continue with the next step?", true) {
+				fmt.Fprintf(s.Out, "Resume later with: gptcode learn --step %d\n", i+1)
+				return ErrQuit
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Session) prompt() string {
+	answer := strings.ToLower(s.Ask("[Enter] run this step, [s] skip, [q] quit:", ""))
+	return firstLetter(answer)
+}
+
+func (s *Session) pause() string {
+	answer := strings.ToLower(s.Ask("[Enter] continue, [q] quit:", ""))
+	return firstLetter(answer)
+}
+
+func firstLetter(s string) string {
+	if s == "" {
+		return ""
+	}
+	return s[:1]
+}
+
+// sandboxFiles is the sample project: a tiny Go package with one bug that
+// its test catches.
+var sandboxFiles = map[string]string{
+	"go.mod": "module sandbox\n\ngo 1.21\n",
+	"calc/calc.go": `package calc
+
+// Sum returns the sum of the numbers.
+func Sum(numbers []int) int {
+	total := 0
+	for i := 1; i < len(numbers); i++ {
+		total += numbers[i]
+	}
+	return total
+}
+
+// Average returns the mean of the numbers, or 0 for none.
+func Average(numbers []int) float64 {
+	if len(numbers) == 0 {
+		return 0
+	}
+	return float64(Sum(numbers)) / float64(len(numbers))
+}
+`,
+	"calc/calc_test.go": `package calc
+
+import "testing"
+
+func TestSum(t *testing.T) {
+	if got := Sum([]int{1, 2, 3}); got != 6 {
+		t.Errorf("Sum([1 2 3]) = %d, want 6", got)
+	}
+}
+
+func TestAverage(t *testing.T) {
+	if got := Average([]int{2, 4}); got != 3 {
+		t.Errorf("Average([2 4]) = %v, want 3", got)
+	}
+}
+`,
+	"README.md": "# sandbox\n\nA tiny project created by `gptcode learn`. Anything here can be changed or deleted.\n",
+}
+
+// CreateSandbox writes the sample project into dir and commits it, so the
+// tutorial's tasks show up as a clean diff.
+func CreateSandbox(dir string) error {
+	for name, content := range sandboxFiles {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=gptcode", "-c", "user.email=learn@gptcode.local", "commit", "-q", "-m", "Sample project"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// Diff returns the sandbox's uncommitted changes.
+func (s *Session) Diff() string {
+	cmd := exec.Command("git", "diff", "--stat", "-p")
+	cmd.Dir = s.Sandbox
+	out, _ := cmd.Output()
+	return string(out)
+}
@@ -0,0 +1,54 @@
+package tutorial
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunSkipsAndQuits(t *testing.T) {
+	var ran []string
+	step := func(name string) Step {
+		return Step{Title: name, Explain: "about " + name, Run: func(s *Session) error {
+			ran = append(ran, name)
+			return errors.New("failed")
+		}}
+	}
+	steps := []Step{{Title: "intro", Explain: "hello"}, step("a"), step("b"), step("c")}
+
+	// Enter past the intro, run a, continue after its failure, skip b, quit at c
+	var out bytes.Buffer
+	s := NewSession(strings.NewReader("\n\n\ns\nq\n"), &out, t.TempDir())
+	if err := s.Run(steps, 0); !errors.Is(err, ErrQuit) {
+		t.Fatalf("expected ErrQuit, got %v", err)
+	}
+	if len(ran) != 1 || ran[0] != "a" {
+		t.Errorf("ran %v, want [a]", ran)
+	}
+	for _, want := range []string{"Step 1/4: intro", "This step did not complete: failed", "gptcode learn --step 4"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCreateSandbox(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	if err := CreateSandbox(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "calc", "calc_test.go")); err != nil {
+		t.Fatal(err)
+	}
+	s := NewSession(strings.NewReader(""), &bytes.Buffer{}, dir)
+	if diff := s.Diff(); diff != "" {
+		t.Errorf("sandbox should start committed, got diff:\n%s", diff)
+	}
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"bytes"
+	"context"
 	"os/exec"
 	"path/filepath"
 
@@ -23,6 +24,10 @@ func NewBuildExecutor(workDir string) *BuildExecutor {
 }
 
 func (be *BuildExecutor) RunBuild() (*BuildResult, error) {
+	if c := configuredCommand(be.workDir, KindBuild); c != nil {
+		res, err := RunCommand(context.Background(), be.workDir, KindBuild, c)
+		return &BuildResult{Success: res.Success, Output: res.Output, ErrorMessage: res.Reason}, err
+	}
 	lang := langdetect.DetectLanguage(be.workDir)
 	switch lang {
 	case langdetect.Go:
@@ -0,0 +1,162 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+
+	"gptcode/internal/config"
+	"gptcode/internal/langdetect"
+	"gptcode/internal/tools"
+)
+
+// Kinds of validation commands
+const (
+	KindBuild    = "build"
+	KindTest     = "test"
+	KindLint     = "lint"
+	KindCoverage = "coverage"
+)
+
+// CommandResult is the outcome of a configured validation command
+type CommandResult struct {
+	Kind     string
+	Command  string
+	Success  bool
+	Output   string
+	ExitCode int
+	// Reason explains a failure: the exit code or the pattern that decided
+	// it
+	Reason string
+}
+
+// Configured returns the validation commands the project configures for
+// workDir's language in .gptcode/config.yml.
+func Configured(workDir string) config.ValidationCommands {
+	pc, err := config.LoadProjectConfig(workDir)
+	if err != nil {
+		return config.ValidationCommands{}
+	}
+	return pc.Validation.For(string(langdetect.DetectLanguage(workDir)))
+}
+
+// configuredCommand returns the configured command of a kind, or nil
+func configuredCommand(workDir, kind string) *config.ValidationCommand {
+	cmds := Configured(workDir)
+	switch kind {
+	case KindBuild:
+		return cmds.Build
+	case KindTest:
+		return cmds.Test
+	case KindLint:
+		return cmds.Lint
+	case KindCoverage:
+		return cmds.Coverage
+	}
+	return nil
+}
+
+// RunCommand runs a validation command with sh -c in workDir, in the
+// sandbox when one is active, and judges its success by its exit code and
+// output patterns. The command is killed when ctx is done. The error is
+// only set when the command could not be run or a pattern is invalid.
+func RunCommand(ctx context.Context, workDir, kind string, c *config.ValidationCommand) (*CommandResult, error) {
+	res := &CommandResult{Kind: kind, Command: c.Command}
+	out, err := tools.ActiveExecutor().Run(ctx, c.Command, workDir)
+	res.Output = string(out)
+	if ctx.Err() != nil {
+		res.Reason = ctx.Err().Error()
+		return res, ctx.Err()
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = exitErr.ExitCode()
+	} else if err != nil {
+		res.Reason = err.Error()
+		return res, err
+	}
+
+	if !successCode(c.ExitCodes, res.ExitCode) {
+		res.Reason = fmt.Sprintf("exit code %d", res.ExitCode)
+		return res, nil
+	}
+	if c.FailurePattern != "" {
+		re, err := regexp.Compile(c.FailurePattern)
+		if err != nil {
+			return res, fmt.Errorf("invalid failure_pattern of the %s command: %w", kind, err)
+		}
+		if re.MatchString(res.Output) {
+			res.Reason = fmt.Sprintf("output matches %q", c.FailurePattern)
+			return res, nil
+		}
+	}
+	if c.SuccessPattern != "" {
+		re, err := regexp.Compile(c.SuccessPattern)
+		if err != nil {
+			return res, fmt.Errorf("invalid success_pattern of the %s command: %w", kind, err)
+		}
+		if !re.MatchString(res.Output) {
+			res.Reason = fmt.Sprintf("output does not match %q", c.SuccessPattern)
+			return res, nil
+		}
+	}
+	res.Success = true
+	return res, nil
+}
+
+// RunConfigured runs the build, test and lint commands configured for
+// workDir in order, stopping at the first failure, which it returns. It
+// returns nil when they all pass or none is configured.
+func RunConfigured(ctx context.Context, workDir string) (*CommandResult, error) {
+	cmds := Configured(workDir)
+	for _, c := range []struct {
+		kind string
+		cmd  *config.ValidationCommand
+	}{{KindBuild, cmds.Build}, {KindTest, cmds.Test}, {KindLint, cmds.Lint}} {
+		if c.cmd == nil {
+			continue
+		}
+		res, err := RunCommand(ctx, workDir, c.kind, c.cmd)
+		if err != nil {
+			return res, err
+		}
+		if !res.Success {
+			return res, nil
+		}
+	}
+	return nil, nil
+}
+
+func successCode(codes []int, code int) bool {
+	if len(codes) == 0 {
+		return code == 0
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+var percentRegex = regexp.MustCompile(`([0-9]+(?:\.[0-9]+)?)\s*%`)
+
+// parseCoverage finds the coverage percentage in a command's output
+func parseCoverage(c *config.ValidationCommand, output string) (float64, error) {
+	re := percentRegex
+	if c.CoveragePattern != "" {
+		var err error
+		if re, err = regexp.Compile(c.CoveragePattern); err != nil {
+			return 0, fmt.Errorf("invalid coverage_pattern: %w", err)
+		}
+	}
+	matches := re.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 || len(matches[len(matches)-1]) < 2 {
+		return 0, errors.New("coverage not found in the output")
+	}
+	return strconv.ParseFloat(matches[len(matches)-1][1], 64)
+}
@@ -0,0 +1,109 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/config"
+)
+
+func TestConfiguredCommands(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		".git/HEAD": "ref: refs/heads/main\n",
+		"go.mod":    "module example.com/app\n",
+		".gptcode/config.yml": `validation:
+  build: echo built
+  test:
+    command: echo "3 passed, 1 flaky"; exit 3
+    exit_codes: [0, 3]
+    failure_pattern: "[1-9][0-9]* failed"
+  lint:
+    command: 'echo "src/a.go:1: error: unused"; exit 1'
+  coverage:
+    command: echo "pkg a 50%"; echo "total 82.5%"
+  languages:
+    typescript:
+      build: "false"
+`,
+		"web/package.json": "{}",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	build, err := NewBuildExecutor(root).RunBuild()
+	if err != nil || !build.Success || strings.TrimSpace(build.Output) != "built" {
+		t.Errorf("build = %+v (%v), want the configured command to pass", build, err)
+	}
+	// The typescript subproject overrides the build
+	if build, _ := NewBuildExecutor(filepath.Join(root, "web")).RunBuild(); build.Success {
+		t.Error("the typescript build command should fail")
+	}
+
+	tests, err := NewTestExecutor(root).RunTests()
+	if err != nil || !tests.Success {
+		t.Errorf("tests = %+v (%v), want exit code 3 to pass", tests, err)
+	}
+
+	lint, err := NewLinterExecutor(root).RunLinters()
+	if err != nil || len(lint) != 1 || lint[0].Success || lint[0].Errors != 1 || lint[0].ErrorMessage != "exit code 1" {
+		t.Errorf("lint = %+v (%v), want one failure", lint, err)
+	}
+
+	cov, err := NewCoverageExecutor(root).RunCoverage(80)
+	if err != nil || cov.Coverage != 82.5 {
+		t.Errorf("coverage = %+v (%v), want 82.5", cov, err)
+	}
+
+	res, err := RunConfigured(context.Background(), root)
+	if err != nil || res == nil || res.Kind != KindLint {
+		t.Errorf("RunConfigured = %+v (%v), want the lint failure", res, err)
+	}
+}
+
+func TestRunCommandPatterns(t *testing.T) {
+	cases := []struct {
+		cmd     config.ValidationCommand
+		success bool
+	}{
+		{config.ValidationCommand{Command: "echo ok"}, true},
+		{config.ValidationCommand{Command: "exit 2"}, false},
+		{config.ValidationCommand{Command: "exit 2", ExitCodes: []int{2}}, true},
+		{config.ValidationCommand{Command: "echo '1 failed'", FailurePattern: "[1-9] failed"}, false},
+		{config.ValidationCommand{Command: "echo done", SuccessPattern: "BUILD SUCCESSFUL"}, false},
+		{config.ValidationCommand{Command: "echo BUILD SUCCESSFUL", SuccessPattern: "BUILD SUCCESSFUL"}, true},
+	}
+	for _, c := range cases {
+		res, err := RunCommand(context.Background(), t.TempDir(), KindBuild, &c.cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Success != c.success {
+			t.Errorf("%+v: success = %v, want %v (%s)", c.cmd, res.Success, c.success, res.Reason)
+		}
+	}
+}
+
+func TestRunCommandCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	res, err := RunCommand(ctx, t.TempDir(), KindTest, &config.ValidationCommand{Command: "sleep 10"})
+	if !errors.Is(err, context.DeadlineExceeded) || res.Success {
+		t.Errorf("expected the deadline to stop the command, got %+v, %v", res, err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("the command should be killed when ctx is done, took %v", time.Since(start))
+	}
+}
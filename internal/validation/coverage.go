@@ -3,12 +3,15 @@ package validation
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
+
+	"gptcode/internal/config"
 )
 
 type CoverageResult struct {
@@ -27,6 +30,10 @@ func NewCoverageExecutor(workDir string) *CoverageExecutor {
 }
 
 func (ce *CoverageExecutor) RunCoverage(min float64) (*CoverageResult, error) {
+	if c := configuredCommand(ce.workDir, KindCoverage); c != nil {
+		return ce.runConfigured(c, min)
+	}
+
 	res := &CoverageResult{}
 	profile := filepath.Join(ce.workDir, "coverage.out")
 	_ = os.Remove(profile)
@@ -78,3 +85,26 @@ func (ce *CoverageExecutor) RunCoverage(min float64) (*CoverageResult, error) {
 	_ = os.Remove(profile)
 	return res, nil
 }
+
+func (ce *CoverageExecutor) runConfigured(c *config.ValidationCommand, min float64) (*CoverageResult, error) {
+	cmdRes, err := RunCommand(context.Background(), ce.workDir, KindCoverage, c)
+	res := &CoverageResult{Output: cmdRes.Output}
+	if err != nil {
+		res.ErrorMessage = err.Error()
+		return res, err
+	}
+	if !cmdRes.Success {
+		res.ErrorMessage = cmdRes.Reason
+		return res, errors.New(cmdRes.Reason)
+	}
+	if res.Coverage, err = parseCoverage(c, cmdRes.Output); err != nil {
+		res.ErrorMessage = err.Error()
+		return res, err
+	}
+	if min > 0 && res.Coverage < min {
+		res.ErrorMessage = "coverage below threshold"
+		return res, errors.New("coverage below threshold")
+	}
+	res.Success = true
+	return res, nil
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"gptcode/internal/langdetect"
 	"os/exec"
@@ -28,6 +29,20 @@ func NewLinterExecutor(workDir string) *LinterExecutor {
 }
 
 func (le *LinterExecutor) RunLinters() ([]*LintResult, error) {
+	if c := configuredCommand(le.workDir, KindLint); c != nil {
+		res, err := RunCommand(context.Background(), le.workDir, KindLint, c)
+		if err != nil {
+			return nil, err
+		}
+		result := &LintResult{Success: res.Success, Output: res.Output, Tool: c.Command, ErrorMessage: res.Reason}
+		if !res.Success {
+			result.parseIssues(res.Output)
+			if result.Errors == 0 {
+				result.Errors, result.Issues = 1, result.Issues+1
+			}
+		}
+		return []*LintResult{result}, nil
+	}
 	lang := langdetect.DetectLanguage(le.workDir)
 
 	switch lang {
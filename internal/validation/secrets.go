@@ -0,0 +1,238 @@
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gptcode/internal/secrets"
+)
+
+// AllowSecretMarker on a line keeps the secret scanner from reporting it,
+// e.g. for a documented test fixture.
+const AllowSecretMarker = "gptcode:allow-secret"
+
+// SecretFinding is a likely credential added by a change.
+type SecretFinding struct {
+	File string
+	Line int
+	// Rule is the name of the pattern found, e.g. aws-access-key or
+	// generic-secret
+	Rule   string
+	Secret string
+}
+
+// Redacted shows the secret's first characters only, for reports.
+func (f SecretFinding) Redacted() string {
+	if len(f.Secret) <= 8 {
+		return strings.Repeat("*", len(f.Secret))
+	}
+	return f.Secret[:4] + strings.Repeat("*", 8)
+}
+
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", f.File, f.Line, f.Rule, f.Redacted())
+}
+
+// genericSecret is the value of a key, secret, token or password
+// assignment, checked after secrets.Patterns
+var genericSecret = secrets.Pattern{
+	Name:    "generic-secret",
+	Regexp:  regexp.MustCompile(`(?i)(?:` + secrets.KeyWords + `)[a-z0-9_-]*["']?\s*(?::=|=>|[:=])\s*["']([^"'\s]{16,})["']`),
+	Entropy: 3.5,
+}
+
+// secretRules go from the most specific to the generic one; a line is
+// reported once, for the first rule it matches.
+var secretRules = append(append([]secrets.Pattern{}, secrets.Patterns...), genericSecret)
+
+var placeholder = regexp.MustCompile(`(?i)example|dummy|placeholder|changeme|redacted|your[_-]|xxxx|\*\*\*\*|\$\{|\{\{|<[a-z_-]+>`)
+
+// skippedSecretFiles hold checksums and hashes that look random but are
+// not secrets.
+var skippedSecretFiles = map[string]bool{
+	"go.sum":            true,
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"pnpm-lock.yaml":    true,
+	"Cargo.lock":        true,
+	"Gemfile.lock":      true,
+	"poetry.lock":       true,
+	"mix.lock":          true,
+}
+
+// FindSecret returns the likely secret on one line of file, if any.
+func FindSecret(file string, lineNum int, line string) (SecretFinding, bool) {
+	if strings.Contains(line, AllowSecretMarker) {
+		return SecretFinding{}, false
+	}
+	for _, rule := range secretRules {
+		for _, m := range rule.Regexp.FindAllStringSubmatch(line, -1) {
+			secret := m[len(m)-1]
+			if placeholder.MatchString(secret) || (rule.Entropy > 0 && Entropy(secret) < rule.Entropy) {
+				continue
+			}
+			return SecretFinding{File: file, Line: lineNum, Rule: rule.Name, Secret: secret}, true
+		}
+	}
+	return SecretFinding{}, false
+}
+
+// Entropy is the Shannon entropy of s in bits per character; random
+// tokens score high, words and repeated characters low.
+func Entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// ScanDiffForSecrets looks for secrets in the lines a unified diff adds.
+func ScanDiffForSecrets(diff string) []SecretFinding {
+	var findings []SecretFinding
+	file, prev := "", ""
+	line := 0
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		header := strings.HasPrefix(prev, "--- ")
+		prev = text
+		switch {
+		// An added line may start with "++ " too; a file header follows
+		// the "--- " line
+		case header && strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			if file == "/dev/null" || skippedSecretFiles[filepath.Base(file)] {
+				file = ""
+			}
+		case strings.HasPrefix(text, "@@"):
+			line = hunkStart(text)
+		case strings.HasPrefix(text, "+"):
+			if file != "" {
+				if f, ok := FindSecret(file, line, text[1:]); ok {
+					findings = append(findings, f)
+				}
+			}
+			line++
+		case strings.HasPrefix(text, " "):
+			line++
+		}
+	}
+	return findings
+}
+
+// hunkStart returns the first new-file line of a "@@ -a,b +c,d @@" header
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+		return 0
+	}
+	start, _, _ := strings.Cut(fields[2][1:], ",")
+	n, _ := strconv.Atoi(start)
+	return n
+}
+
+// SecretScanner checks what a commit or a push would publish for secrets.
+type SecretScanner struct {
+	workDir string
+}
+
+func NewSecretScanner(workDir string) *SecretScanner {
+	return &SecretScanner{workDir: workDir}
+}
+
+// Staged scans the changes in the index, what git commit commits.
+func (ss *SecretScanner) Staged() ([]SecretFinding, error) {
+	diff, err := ss.git("diff", "--cached", "--no-color", "--no-ext-diff", "-U0")
+	if err != nil {
+		return nil, err
+	}
+	return ScanDiffForSecrets(string(diff)), nil
+}
+
+// WorkingTree scans the changes and the untracked files, what committing
+// everything (git add -A) commits.
+func (ss *SecretScanner) WorkingTree() ([]SecretFinding, error) {
+	diff, err := ss.git("diff", "HEAD", "--no-color", "--no-ext-diff", "-U0")
+	if err != nil {
+		return nil, err
+	}
+	findings := ScanDiffForSecrets(string(diff))
+
+	untracked, err := ss.git("ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range strings.Split(string(untracked), "\x00") {
+		if file == "" || skippedSecretFiles[filepath.Base(file)] {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(ss.workDir, file))
+		// Binary and very large files are not source code
+		if err != nil || len(data) > 1<<20 || bytes.IndexByte(data, 0) >= 0 {
+			continue
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if f, ok := FindSecret(filepath.ToSlash(file), i+1, line); ok {
+				findings = append(findings, f)
+			}
+		}
+	}
+	return findings, nil
+}
+
+// Since scans the commits from base to HEAD, what pushing the branch
+// publishes. base falls back to origin/base when it does not exist
+// locally.
+func (ss *SecretScanner) Since(base string) ([]SecretFinding, error) {
+	var lastErr error
+	for _, ref := range []string{base, "origin/" + base} {
+		diff, err := ss.git("diff", "--no-color", "--no-ext-diff", "-U0", ref+"...HEAD")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return ScanDiffForSecrets(string(diff)), nil
+	}
+	return nil, lastErr
+}
+
+func (ss *SecretScanner) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = ss.workDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// SecretsSummary lists the findings, one per line.
+func SecretsSummary(findings []SecretFinding) string {
+	lines := make([]string, len(findings))
+	for i, f := range findings {
+		lines[i] = "- " + f.String()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,127 @@
+package validation
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// The fixtures are put together at run time so this file holds no token a
+// scanner would flag
+var (
+	fakeGitHubToken = "ghp_" + strings.Repeat("a1B2c3D4", 5)
+	fakeAWSKey      = "AKIA" + "Q3XZ7RT2WB9KM4PL"
+	fakeSecret      = "q8Rz" + "T2vLk9Xw4Pm7Bn3Y"
+)
+
+func TestFindSecret(t *testing.T) {
+	cases := []struct {
+		line string
+		rule string
+	}{
+		{`token := "` + fakeGitHubToken + `"`, "github-token"},
+		{"aws_access_key_id = " + fakeAWSKey, "aws-access-key"},
+		{"-----BEGIN " + "RSA PRIVATE KEY-----", "private-key"},
+		{`const apiKey = "` + fakeSecret + `"`, "generic-secret"},
+		{`password: '` + fakeSecret + `'`, "generic-secret"},
+		// Low entropy, placeholders and marked lines are not secrets
+		{`password: "aaaaaaaaaaaaaaaaaaaa"`, ""},
+		{`api_key = "your-api-key-goes-here"`, ""},
+		{"aws_access_key_id = AKIA" + "IOSFODNN7EXAMPLE", ""},
+		{`token := "` + fakeGitHubToken + `" // ` + AllowSecretMarker, ""},
+		{`secret := os.Getenv("STRIPE_SECRET_KEY")`, ""},
+	}
+	for _, c := range cases {
+		f, ok := FindSecret("a.go", 1, c.line)
+		if c.rule == "" {
+			if ok {
+				t.Errorf("%q: unexpected finding %v", c.line, f)
+			}
+			continue
+		}
+		if !ok || f.Rule != c.rule {
+			t.Errorf("%q: expected %s, got %v (found %v)", c.line, c.rule, f, ok)
+		}
+	}
+
+	f, _ := FindSecret("a.go", 3, `const apiKey = "`+fakeSecret+`"`)
+	if f.Secret != fakeSecret || strings.Contains(f.String(), fakeSecret) || !strings.HasPrefix(f.Redacted(), "q8Rz") {
+		t.Errorf("expected the secret redacted in reports, got %s", f)
+	}
+}
+
+func TestScanDiffForSecrets(t *testing.T) {
+	diff := `diff --git a/config.go b/config.go
+--- a/config.go
++++ b/config.go
+@@ -10,0 +11,2 @@ func load() {
++	name := "service"
++	token := "` + fakeGitHubToken + `"
+@@ -20 +22 @@
+-	old := "` + fakeSecret + `"
++	key := "` + fakeAWSKey + `"
+diff --git a/go.sum b/go.sum
+--- a/go.sum
++++ b/go.sum
+@@ -1,0 +2 @@
++example.com/m v1.0.0 h1:` + fakeSecret + `=
+diff --git a/gone.go b/gone.go
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-	token := "` + fakeGitHubToken + `"
+`
+	findings := ScanDiffForSecrets(diff)
+	if len(findings) != 2 {
+		t.Fatalf("expected the two added secrets, got %v", findings)
+	}
+	if f := findings[0]; f.File != "config.go" || f.Line != 12 || f.Rule != "github-token" {
+		t.Errorf("unexpected finding %v", f)
+	}
+	if f := findings[1]; f.Line != 22 || f.Rule != "aws-access-key" {
+		t.Errorf("unexpected finding %v", f)
+	}
+}
+
+func TestSecretScanner(t *testing.T) {
+	dir := guardRepo(t)
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("branch", "-M", "main")
+	scanner := NewSecretScanner(dir)
+
+	writeFile(t, dir, "staged.go", "package calc\n\nvar token = \""+fakeGitHubToken+"\"\n")
+	writeFile(t, dir, "untracked.env", "AWS_ACCESS_KEY_ID="+fakeAWSKey+"\n")
+	git("add", "staged.go")
+
+	staged, err := scanner.Staged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(staged) != 1 || staged[0].File != "staged.go" || staged[0].Line != 3 {
+		t.Errorf("expected the staged secret only, got %v", staged)
+	}
+
+	tree, err := scanner.WorkingTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree) != 2 || tree[1].File != "untracked.env" || tree[1].Line != 1 {
+		t.Errorf("expected the untracked file scanned too, got %v", tree)
+	}
+
+	git("checkout", "-q", "-b", "feature")
+	git("-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "-m", "add token")
+	pushed, err := scanner.Since("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pushed) != 1 || pushed[0].File != "staged.go" {
+		t.Errorf("expected the branch's secret, got %v", pushed)
+	}
+}
@@ -2,6 +2,7 @@ package validation
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"gptcode/internal/langdetect"
 	"os/exec"
@@ -28,6 +29,10 @@ func NewTestExecutor(workDir string) *TestExecutor {
 }
 
 func (te *TestExecutor) RunTests() (*TestResult, error) {
+	if c := configuredCommand(te.workDir, KindTest); c != nil {
+		res, err := RunCommand(context.Background(), te.workDir, KindTest, c)
+		return &TestResult{Success: res.Success, Output: res.Output, ErrorMessage: res.Reason}, err
+	}
 	lang := langdetect.DetectLanguage(te.workDir)
 
 	switch lang {
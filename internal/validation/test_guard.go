@@ -0,0 +1,296 @@
+package validation
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TestStats counts what a test file checks.
+type TestStats struct {
+	Tests      int
+	Assertions int
+	Skips      int
+}
+
+// TestFileChange compares one test file before and after a change.
+type TestFileChange struct {
+	Path    string
+	Deleted bool
+	Before  TestStats
+	After   TestStats
+}
+
+// TestGuardResult reports whether a change weakened the test suite.
+type TestGuardResult struct {
+	Weakened bool
+	Findings []string
+	Changes  []TestFileChange
+	// CoverageBefore and CoverageAfter are set when coverage was compared
+	CoverageBefore float64
+	CoverageAfter  float64
+}
+
+// Summary describes the findings in one message.
+func (r *TestGuardResult) Summary() string {
+	if !r.Weakened {
+		return "no tests were weakened"
+	}
+	return "tests were weakened:\n- " + strings.Join(r.Findings, "\n- ")
+}
+
+// TestGuard compares the test files of the working tree against a base
+// revision to catch fixes that pass by deleting tests, removing assertions
+// or skipping tests.
+type TestGuard struct {
+	workDir string
+	// Base is the revision the working tree is compared to; HEAD by default
+	Base string
+	// Coverage also compares Go coverage at Base and in the working tree
+	Coverage bool
+	// MaxCoverageDrop is the coverage loss, in percentage points, tolerated
+	// before it counts as weakening
+	MaxCoverageDrop float64
+}
+
+func NewTestGuard(workDir string) *TestGuard {
+	return &TestGuard{workDir: workDir, Base: "HEAD", MaxCoverageDrop: 1.0}
+}
+
+// SnapshotTree records dir's working tree, uncommitted changes included,
+// as a commit to use as TestGuard.Base, so a later check does not blame a
+// change for edits that were there before it. It is HEAD when the tree is
+// clean, and "" when dir has no commits.
+func SnapshotTree(dir string) string {
+	tg := &TestGuard{workDir: dir}
+	// The commit is never referenced, so whose name it carries is moot
+	if out, err := tg.git("-c", "user.name=gptcode", "-c", "user.email=gptcode@localhost", "stash", "create"); err == nil && len(bytes.TrimSpace(out)) > 0 {
+		return string(bytes.TrimSpace(out))
+	}
+	out, err := tg.git("rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return string(bytes.TrimSpace(out))
+}
+
+// Check compares every modified, renamed or deleted test file with its
+// version at Base. New test files never count as weakening.
+func (tg *TestGuard) Check() (*TestGuardResult, error) {
+	out, err := tg.git("diff", "--name-status", "-M", tg.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &TestGuardResult{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		status, oldPath, newPath := fields[0], fields[1], fields[len(fields)-1]
+		if !IsTestFile(oldPath) || status[0] == 'A' {
+			continue
+		}
+
+		before, err := tg.git("show", tg.Base+":"+oldPath)
+		if err != nil {
+			continue
+		}
+		change := TestFileChange{Path: oldPath, Before: AnalyzeTestSource(oldPath, string(before))}
+		if status[0] == 'D' || !IsTestFile(newPath) {
+			change.Deleted = true
+		} else {
+			after, err := os.ReadFile(filepath.Join(tg.root(), newPath))
+			if err != nil {
+				change.Deleted = true
+			} else {
+				change.Path = newPath
+				change.After = AnalyzeTestSource(newPath, string(after))
+			}
+		}
+		res.Changes = append(res.Changes, change)
+		res.Findings = append(res.Findings, change.findings()...)
+	}
+
+	if tg.Coverage {
+		if err := tg.compareCoverage(res); err != nil {
+			return nil, err
+		}
+	}
+
+	res.Weakened = len(res.Findings) > 0
+	return res, nil
+}
+
+func (c TestFileChange) findings() []string {
+	if c.Deleted {
+		if c.Before.Tests == 0 {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s was deleted (%d tests, %d assertions)", c.Path, c.Before.Tests, c.Before.Assertions)}
+	}
+
+	var out []string
+	if c.After.Tests < c.Before.Tests {
+		out = append(out, fmt.Sprintf("%s: tests went from %d to %d", c.Path, c.Before.Tests, c.After.Tests))
+	}
+	if c.After.Assertions < c.Before.Assertions {
+		out = append(out, fmt.Sprintf("%s: assertions went from %d to %d", c.Path, c.Before.Assertions, c.After.Assertions))
+	}
+	if c.After.Skips > c.Before.Skips {
+		out = append(out, fmt.Sprintf("%s: %d new skip markers", c.Path, c.After.Skips-c.Before.Skips))
+	}
+	return out
+}
+
+// compareCoverage measures Go coverage at Base, in a temporary worktree, and
+// in the working tree.
+func (tg *TestGuard) compareCoverage(res *TestGuardResult) error {
+	if !fileExists(filepath.Join(tg.workDir, "go.mod")) {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "gptcode-testguard-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if _, err := tg.git("worktree", "add", "--detach", dir, tg.Base); err != nil {
+		return err
+	}
+	defer func() { _, _ = tg.git("worktree", "remove", "--force", dir) }()
+
+	rel, err := filepath.Rel(tg.root(), tg.workDir)
+	if err != nil {
+		return err
+	}
+	before, err := NewCoverageExecutor(filepath.Join(dir, rel)).RunCoverage(0)
+	if err != nil || before == nil {
+		// Base itself does not pass; there is no coverage to protect
+		return nil
+	}
+	after, err := NewCoverageExecutor(tg.workDir).RunCoverage(0)
+	if err != nil || after == nil {
+		return nil
+	}
+
+	res.CoverageBefore, res.CoverageAfter = before.Coverage, after.Coverage
+	if before.Coverage-after.Coverage > tg.MaxCoverageDrop {
+		res.Findings = append(res.Findings, fmt.Sprintf("coverage dropped from %.1f%% to %.1f%%", before.Coverage, after.Coverage))
+	}
+	return nil
+}
+
+func (tg *TestGuard) root() string {
+	out, err := tg.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return tg.workDir
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (tg *TestGuard) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = tg.workDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// IsTestFile reports whether path looks like a test file in one of the
+// supported languages.
+func IsTestFile(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	return strings.HasSuffix(base, "_test.go") ||
+		strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		(strings.HasPrefix(base, "test_") && strings.HasSuffix(base, ".py")) ||
+		strings.HasSuffix(base, "_test.py") ||
+		strings.HasSuffix(base, "_spec.rb") || strings.HasSuffix(base, "_test.rb") ||
+		strings.HasSuffix(base, "_test.exs")
+}
+
+type testPatterns struct {
+	comment    string
+	tests      *regexp.Regexp
+	assertions *regexp.Regexp
+	skips      *regexp.Regexp
+}
+
+var testPatternsByExt = map[string]testPatterns{
+	".go": {
+		comment:    "//",
+		tests:      regexp.MustCompile(`(?m)^func (Test|Fuzz)\w*\(`),
+		assertions: regexp.MustCompile(`\bt\.(Error|Errorf|Fatal|Fatalf|Fail|FailNow)\(|\b(assert|require)\.\w+\(`),
+		skips:      regexp.MustCompile(`\bt\.(Skip|Skipf|SkipNow)\(`),
+	},
+	".js": {
+		comment:    "//",
+		tests:      regexp.MustCompile(`\b(it|test)\(`),
+		assertions: regexp.MustCompile(`\bexpect\(|\bassert[.(]`),
+		skips:      regexp.MustCompile(`\b(it|test|describe)\.(skip|todo)\(|\bx(it|test|describe)\(`),
+	},
+	".py": {
+		comment:    "#",
+		tests:      regexp.MustCompile(`(?m)^\s*(async\s+)?def test_?\w*\(`),
+		assertions: regexp.MustCompile(`\bassert\b|self\.assert\w+\(|pytest\.raises\(`),
+		skips:      regexp.MustCompile(`@pytest\.mark\.(skip|xfail)|@unittest\.skip|pytest\.skip\(|self\.skipTest\(`),
+	},
+	".rb": {
+		comment:    "#",
+		tests:      regexp.MustCompile(`(?m)^\s*(it|test|specify)\b`),
+		assertions: regexp.MustCompile(`\bexpect[({]|\bassert\w*\b|\.should\b`),
+		skips:      regexp.MustCompile(`(?m)^\s*(xit|skip|pending)\b`),
+	},
+	".exs": {
+		comment:    "#",
+		tests:      regexp.MustCompile(`(?m)^\s*test\s+"`),
+		assertions: regexp.MustCompile(`\b(assert|refute)\w*\b`),
+		skips:      regexp.MustCompile(`@tag\s+:skip|@moduletag\s+:skip`),
+	},
+}
+
+// AnalyzeTestSource counts the tests, assertions and skip markers in a test
+// file. Commented-out lines are ignored, so commenting out an assertion
+// counts as removing it.
+func AnalyzeTestSource(path, src string) TestStats {
+	ext := filepath.Ext(path)
+	switch ext {
+	case ".ts", ".tsx", ".jsx", ".mjs", ".cjs":
+		ext = ".js"
+	}
+	p, ok := testPatternsByExt[ext]
+	if !ok {
+		return TestStats{}
+	}
+
+	var code strings.Builder
+	for _, line := range strings.Split(src, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), p.comment) {
+			continue
+		}
+		code.WriteString(line)
+		code.WriteByte('\n')
+	}
+	s := code.String()
+	return TestStats{
+		Tests:      len(p.tests.FindAllStringIndex(s, -1)),
+		Assertions: len(p.assertions.FindAllStringIndex(s, -1)),
+		Skips:      len(p.skips.FindAllStringIndex(s, -1)),
+	}
+}
+
+var testChangeInstruction = regexp.MustCompile(`(?i)\b(remove|delete|drop|skip|disable|rewrite|replace|relax|loosen)\b[^.\n]{0,40}\btests?\b|\btests?\b[^.\n]{0,40}\b(obsolete|outdated|wrong|flaky|no longer)\b`)
+
+// AllowsTestChanges reports whether task explicitly asks to change, remove or
+// skip tests, in which case weakening them is intended.
+func AllowsTestChanges(task string) bool {
+	return testChangeInstruction.MatchString(task)
+}
@@ -0,0 +1,142 @@
+package validation
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const guardedTest = `package calc
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Errorf("Add(1, 2) = %d", Add(1, 2))
+	}
+	if Add(0, 0) != 0 {
+		t.Fatal("Add(0, 0) != 0")
+	}
+}
+
+func TestSub(t *testing.T) {
+	if Sub(3, 1) != 2 {
+		t.Error("Sub(3, 1) != 2")
+	}
+}
+`
+
+func guardRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	dir := t.TempDir()
+	writeFile(t, dir, "calc_test.go", guardedTest)
+	writeFile(t, dir, "old_test.go", "package calc\n\nimport \"testing\"\n\nfunc TestOld(t *testing.T) { t.Fatal(\"x\") }\n")
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTestGuardDetectsWeakenedTests(t *testing.T) {
+	dir := guardRepo(t)
+
+	weakened := strings.Replace(guardedTest, "\t\tt.Fatal(\"Add(0, 0) != 0\")", "\t\t// t.Fatal(\"Add(0, 0) != 0\")", 1)
+	weakened = strings.Replace(weakened, "func TestSub(t *testing.T) {\n", "func TestSub(t *testing.T) {\n\tt.Skip(\"flaky\")\n", 1)
+	writeFile(t, dir, "calc_test.go", weakened)
+	if err := os.Remove(filepath.Join(dir, "old_test.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := NewTestGuard(dir).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Weakened || len(res.Findings) != 3 {
+		t.Fatalf("expected deletion, assertion and skip findings, got %v", res.Findings)
+	}
+	summary := res.Summary()
+	for _, want := range []string{"old_test.go was deleted", "assertions went from 3 to 2", "1 new skip markers"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q:\n%s", want, summary)
+		}
+	}
+}
+
+func TestTestGuardAllowsStrongerTests(t *testing.T) {
+	dir := guardRepo(t)
+	writeFile(t, dir, "calc_test.go", guardedTest+"\nfunc TestMul(t *testing.T) {\n\tif Mul(2, 3) != 6 {\n\t\tt.Error(\"Mul\")\n\t}\n}\n")
+	writeFile(t, dir, "new_test.go", "package calc\n")
+
+	res, err := NewTestGuard(dir).Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Weakened {
+		t.Errorf("adding tests should not count as weakening: %v", res.Findings)
+	}
+}
+
+func TestTestGuardIgnoresEditsBeforeSnapshot(t *testing.T) {
+	dir := guardRepo(t)
+	if err := os.Remove(filepath.Join(dir, "old_test.go")); err != nil {
+		t.Fatal(err)
+	}
+	guard := NewTestGuard(dir)
+	guard.Base = SnapshotTree(dir)
+
+	res, err := guard.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Weakened {
+		t.Errorf("a deletion made before the snapshot should not count: %v", res.Findings)
+	}
+
+	writeFile(t, dir, "calc_test.go", strings.Replace(guardedTest, "func TestSub", "func testSub", 1))
+	if res, err = guard.Check(); err != nil || !res.Weakened {
+		t.Errorf("a change after the snapshot should still count: %+v, %v", res, err)
+	}
+}
+
+func TestAnalyzeTestSourcePython(t *testing.T) {
+	src := "import pytest\n\ndef test_a():\n    assert 1 == 1\n\n@pytest.mark.skip\ndef test_b():\n    # assert False\n    pass\n"
+	got := AnalyzeTestSource("test_a.py", src)
+	if got != (TestStats{Tests: 2, Assertions: 1, Skips: 1}) {
+		t.Errorf("AnalyzeTestSource = %+v", got)
+	}
+}
+
+func TestAllowsTestChanges(t *testing.T) {
+	for task, want := range map[string]bool{
+		"remove the obsolete login tests":    true,
+		"skip the flaky integration test":    true,
+		"fix the nil pointer in the parser":  false,
+		"make the failing tests pass":        false,
+		"fix the failing tests":              false,
+		"the legacy export test is obsolete": true,
+	} {
+		if got := AllowsTestChanges(task); got != want {
+			t.Errorf("AllowsTestChanges(%q) = %v, want %v", task, got, want)
+		}
+	}
+}
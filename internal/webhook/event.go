@@ -0,0 +1,220 @@
+// Package webhook turns GitHub webhook deliveries into issue automation
+// jobs (issue fix, issue review, issue ci) and runs them from a queue.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gptcode/internal/github"
+)
+
+// Job kinds, named after the issue subcommand each runs
+const (
+	KindFix    = "fix"
+	KindReview = "review"
+	KindCI     = "ci"
+)
+
+// DefaultLabel is the issue label that asks gptcode for a fix
+const DefaultLabel = "gptcode-fix"
+
+// Request is the work one delivery asks for.
+type Request struct {
+	Kind   string `json:"kind"`
+	Repo   string `json:"repo"`
+	Number int    `json:"number"`
+	// Ref is the PR's head branch for review and ci jobs
+	Ref string `json:"ref,omitempty"`
+	// Event is the delivery's event type and action, e.g. "issues.labeled"
+	Event string `json:"event"`
+}
+
+// Key identifies the work, so a burst of deliveries for the same PR
+// queues it once.
+func (r Request) Key() string {
+	return fmt.Sprintf("%s %s#%d", r.Kind, r.Repo, r.Number)
+}
+
+// Filter selects the deliveries that become jobs.
+type Filter struct {
+	// Repo is the only repository whose events are handled (owner/repo)
+	Repo string
+	// Label on an issue triggers a fix (default DefaultLabel)
+	Label string
+	// IgnoreUsers are senders whose events are ignored, such as the
+	// account gptcode pushes as, so its own activity starts no jobs
+	IgnoreUsers []string
+	// BranchTemplate is the project's git.branch_template; only PRs from
+	// the branches gptcode names are worked on, never people's own
+	BranchTemplate string
+	// AllowUsers may review the agent's PRs besides the repository's
+	// owners, members and collaborators
+	AllowUsers []string
+}
+
+// trustedAssociations are the author associations whose reviews the agent
+// acts on
+var trustedAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR"}
+
+// trusts reports whether a review by login, associated to the repository
+// as association, may drive the agent
+func (f Filter) trusts(login, association string) bool {
+	for _, a := range trustedAssociations {
+		if strings.EqualFold(association, a) {
+			return true
+		}
+	}
+	for _, u := range f.AllowUsers {
+		if u != "" && strings.EqualFold(login, u) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header of a delivery
+// against the webhook secret.
+func VerifySignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// payload holds the fields of the events handled, which share their
+// repository, sender and action.
+type payload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+	Label struct {
+		Name string `json:"name"`
+	} `json:"label"`
+	Issue struct {
+		Number      int       `json:"number"`
+		PullRequest *struct{} `json:"pull_request"`
+	} `json:"issue"`
+	PullRequest *pullRequest `json:"pull_request"`
+	Review      author       `json:"review"`
+	Comment     author       `json:"comment"`
+	CheckSuite  *checkRun    `json:"check_suite"`
+	WorkflowRun *checkRun    `json:"workflow_run"`
+}
+
+// author is who wrote a review or review comment
+type author struct {
+	State             string `json:"state"`
+	AuthorAssociation string `json:"author_association"`
+	User              struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+type pullRequest struct {
+	Number int `json:"number"`
+	Head   struct {
+		Ref  string `json:"ref"`
+		Repo struct {
+			FullName string `json:"full_name"`
+		} `json:"repo"`
+	} `json:"head"`
+}
+
+type checkRun struct {
+	Conclusion   string        `json:"conclusion"`
+	HeadBranch   string        `json:"head_branch"`
+	PullRequests []pullRequest `json:"pull_requests"`
+}
+
+// Parse returns the jobs a delivery of eventType asks for, which is none
+// for most deliveries:
+//
+//   - issues labeled with the fix label: issue fix
+//   - a PR review with changes requested or comments, or a PR review
+//     comment: issue review
+//   - a failed check suite or workflow run on a PR: issue ci
+//
+// PRs from forks are skipped, since their branches cannot be pushed to, as
+// are PRs from branches gptcode did not create and reviews by people who
+// are neither the repository's owners, members or collaborators nor in
+// AllowUsers.
+func Parse(eventType string, body []byte, f Filter) ([]Request, error) {
+	var p payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %w", eventType, err)
+	}
+	if f.Repo != "" && !strings.EqualFold(p.Repository.FullName, f.Repo) {
+		return nil, nil
+	}
+	for _, u := range f.IgnoreUsers {
+		if u != "" && strings.EqualFold(p.Sender.Login, u) {
+			return nil, nil
+		}
+	}
+	label := f.Label
+	if label == "" {
+		label = DefaultLabel
+	}
+
+	repo := p.Repository.FullName
+	event := eventType + "." + p.Action
+	forPR := func(kind string, pr pullRequest, ref string) []Request {
+		if pr.Head.Repo.FullName != "" && !strings.EqualFold(pr.Head.Repo.FullName, repo) {
+			return nil
+		}
+		if ref == "" {
+			ref = pr.Head.Ref
+		}
+		if !github.IsAgentBranch(ref, f.BranchTemplate) {
+			return nil
+		}
+		return []Request{{Kind: kind, Repo: repo, Number: pr.Number, Ref: ref, Event: event}}
+	}
+
+	switch eventType {
+	case "issues":
+		if p.Action == "labeled" && strings.EqualFold(p.Label.Name, label) && p.Issue.PullRequest == nil {
+			return []Request{{Kind: KindFix, Repo: repo, Number: p.Issue.Number, Event: event}}, nil
+		}
+	case "pull_request_review":
+		state := strings.ToLower(p.Review.State)
+		if p.Action == "submitted" && p.PullRequest != nil && (state == "changes_requested" || state == "commented") &&
+			f.trusts(p.Review.User.Login, p.Review.AuthorAssociation) {
+			return forPR(KindReview, *p.PullRequest, ""), nil
+		}
+	case "pull_request_review_comment":
+		if p.Action == "created" && p.PullRequest != nil && f.trusts(p.Comment.User.Login, p.Comment.AuthorAssociation) {
+			return forPR(KindReview, *p.PullRequest, ""), nil
+		}
+	case "check_suite", "workflow_run":
+		run := p.CheckSuite
+		if eventType == "workflow_run" {
+			run = p.WorkflowRun
+		}
+		if p.Action != "completed" || run == nil || run.Conclusion != "failure" {
+			return nil, nil
+		}
+		var reqs []Request
+		for _, pr := range run.PullRequests {
+			reqs = append(reqs, forPR(KindCI, pr, run.HeadBranch)...)
+		}
+		return reqs, nil
+	}
+	return nil, nil
+}
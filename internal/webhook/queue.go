@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+// Job is a queued request and its progress.
+type Job struct {
+	ID string `json:"id"`
+	Request
+	Status   string    `json:"status"`
+	Created  time.Time `json:"created"`
+	Started  time.Time `json:"started,omitzero"`
+	Finished time.Time `json:"finished,omitzero"`
+	Error    string    `json:"error,omitempty"`
+	// Output is the tail of the job's output
+	Output string `json:"output,omitempty"`
+}
+
+// Runner does the work of a job, returning its output.
+type Runner func(ctx context.Context, req Request) (string, error)
+
+// Queue runs jobs in the background with a fixed number of workers.
+// Requests for work already queued are merged into the queued job, and
+// each PR or issue gets at most MaxAttempts jobs, so a fix that keeps
+// failing CI does not loop forever.
+type Queue struct {
+	MaxAttempts int
+	// Keep is how many finished jobs are remembered (default 200)
+	Keep int
+
+	run     Runner
+	pending chan *Job
+	now     func() time.Time
+
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	order    []string
+	attempts map[string]int
+	nextID   int
+}
+
+// NewQueue starts workers goroutines running jobs with run until ctx is
+// cancelled.
+func NewQueue(ctx context.Context, run Runner, workers int) *Queue {
+	if workers < 1 {
+		workers = 1
+	}
+	q := &Queue{
+		MaxAttempts: 3,
+		Keep:        200,
+		run:         run,
+		pending:     make(chan *Job, 1000),
+		now:         time.Now,
+		jobs:        map[string]*Job{},
+		attempts:    map[string]int{},
+	}
+	for i := 0; i < workers; i++ {
+		go q.work(ctx)
+	}
+	return q
+}
+
+// Add queues req, returning its job. A request for work already queued
+// returns the queued job; one past MaxAttempts is recorded as skipped.
+func (q *Queue) Add(req Request) *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, id := range q.order {
+		if j := q.jobs[id]; j.Status == StatusQueued && j.Key() == req.Key() {
+			c := *j
+			return &c
+		}
+	}
+
+	q.nextID++
+	j := &Job{ID: fmt.Sprintf("%d", q.nextID), Request: req, Status: StatusQueued, Created: q.now()}
+	q.jobs[j.ID] = j
+	q.order = append(q.order, j.ID)
+	q.trim()
+
+	if q.MaxAttempts > 0 && q.attempts[req.Key()] >= q.MaxAttempts {
+		j.Status = StatusSkipped
+		j.Error = fmt.Sprintf("already ran %d times", q.attempts[req.Key()])
+		j.Finished = j.Created
+	} else {
+		q.attempts[req.Key()]++
+		select {
+		case q.pending <- j:
+		default:
+			j.Status, j.Error, j.Finished = StatusSkipped, "queue full", j.Created
+		}
+	}
+	c := *j
+	return &c
+}
+
+// trim forgets the oldest finished jobs beyond Keep
+func (q *Queue) trim() {
+	for len(q.order) > q.Keep {
+		removed := false
+		for i, id := range q.order {
+			if s := q.jobs[id].Status; s != StatusQueued && s != StatusRunning {
+				delete(q.jobs, id)
+				q.order = append(q.order[:i], q.order[i+1:]...)
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			return
+		}
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-q.pending:
+			q.update(j, func(j *Job) { j.Status, j.Started = StatusRunning, q.now() })
+			output, err := q.run(ctx, j.Request)
+			q.update(j, func(j *Job) {
+				j.Finished, j.Output = q.now(), tail(output, 4000)
+				j.Status = StatusSucceeded
+				if err != nil {
+					j.Status, j.Error = StatusFailed, err.Error()
+				}
+			})
+		}
+	}
+}
+
+func (q *Queue) update(j *Job, f func(*Job)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	f(j)
+}
+
+// Get returns a copy of the job with id, or nil.
+func (q *Queue) Get(id string) *Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return nil
+	}
+	c := *j
+	return &c
+}
+
+// List returns copies of the remembered jobs, newest first.
+func (q *Queue) List() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]Job, 0, len(q.order))
+	for i := len(q.order) - 1; i >= 0; i-- {
+		jobs = append(jobs, *q.jobs[q.order[i]])
+	}
+	return jobs
+}
+
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return "…" + s[len(s)-n:]
+}
@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxPayload bounds a delivery; GitHub caps payloads at 25 MB but the
+// events handled are far smaller
+const maxPayload = 5 << 20
+
+// Server receives GitHub webhook deliveries and reports job status. Job
+// status includes command output, so it is only shown to requests bearing
+// the webhook secret; a proxy in front would make every request look local.
+type Server struct {
+	secret string
+	filter Filter
+	queue  *Queue
+	mux    *http.ServeMux
+}
+
+// NewServer returns a server that checks deliveries against secret and
+// queues the jobs they ask for on queue.
+func NewServer(secret string, filter Filter, queue *Queue) *Server {
+	s := &Server{secret: secret, filter: filter, queue: queue, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /webhook", s.handleWebhook)
+	s.mux.HandleFunc("GET /jobs", s.handleJobs)
+	s.mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPayload))
+	if err != nil {
+		http.Error(w, "failed to read the payload", http.StatusBadRequest)
+		return
+	}
+	if !VerifySignature(s.secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	if event == "ping" {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "pong"})
+		return
+	}
+	reqs, err := Parse(event, body, s.filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(reqs) == 0 {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+		return
+	}
+	jobs := make([]*Job, 0, len(reqs))
+	for _, req := range reqs {
+		jobs = append(jobs, s.queue.Add(req))
+	}
+	writeJSON(w, http.StatusAccepted, map[string]any{"status": "queued", "jobs": jobs})
+}
+
+// authorized reports whether r may read job status
+func (s *Server) authorized(r *http.Request) bool {
+	token := r.Header.Get("Authorization")
+	return subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+s.secret)) == 1
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.queue.List())
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	job := s.queue.Get(r.PathValue("id"))
+	if job == nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
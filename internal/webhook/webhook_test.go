@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParse(t *testing.T) {
+	f := Filter{Repo: "acme/app", IgnoreUsers: []string{"gptcode-bot"}, AllowUsers: []string{"reviewer-bot"}}
+	repo := `"repository":{"full_name":"acme/app"},"sender":{"login":"ana"}`
+	pr := `"pull_request":{"number":9,"head":{"ref":"issue-4-fix","repo":{"full_name":"acme/app"}}}`
+	cases := []struct {
+		event, body string
+		want        string
+	}{
+		{"issues", `{"action":"labeled","label":{"name":"gptcode-fix"},"issue":{"number":4},` + repo + `}`, "fix acme/app#4"},
+		{"issues", `{"action":"labeled","label":{"name":"bug"},"issue":{"number":4},` + repo + `}`, ""},
+		{"pull_request_review", `{"action":"submitted","review":{"state":"changes_requested","author_association":"MEMBER"},` + pr + `,` + repo + `}`, "review acme/app#9"},
+		{"pull_request_review", `{"action":"submitted","review":{"state":"approved","author_association":"OWNER"},` + pr + `,` + repo + `}`, ""},
+		{"pull_request_review_comment", `{"action":"created","comment":{"author_association":"COLLABORATOR"},` + pr + `,` + repo + `}`, "review acme/app#9"},
+		{"pull_request_review_comment", `{"action":"created","comment":{"author_association":"NONE","user":{"login":"reviewer-bot"}},` + pr + `,` + repo + `}`, "review acme/app#9"},
+		// Reviews by outsiders and PRs from people's own branches are ignored
+		{"pull_request_review", `{"action":"submitted","review":{"state":"commented","author_association":"CONTRIBUTOR","user":{"login":"mallory"}},` + pr + `,` + repo + `}`, ""},
+		{"pull_request_review_comment", `{"action":"created","comment":{"author_association":"NONE"},` + pr + `,` + repo + `}`, ""},
+		{"pull_request_review", `{"action":"submitted","review":{"state":"changes_requested","author_association":"OWNER"},"pull_request":{"number":9,"head":{"ref":"release-2024","repo":{"full_name":"acme/app"}}},` + repo + `}`, ""},
+		{"check_suite", `{"action":"completed","check_suite":{"conclusion":"failure","head_branch":"ana/wip","pull_requests":[{"number":9}]},` + repo + `}`, ""},
+		{"check_suite", `{"action":"completed","check_suite":{"conclusion":"failure","head_branch":"issue-4-fix","pull_requests":[{"number":9}]},` + repo + `}`, "ci acme/app#9"},
+		{"workflow_run", `{"action":"completed","workflow_run":{"conclusion":"success","pull_requests":[{"number":9}]},` + repo + `}`, ""},
+		// Other repositories, our own events and forks are ignored
+		{"issues", `{"action":"labeled","label":{"name":"gptcode-fix"},"issue":{"number":4},"repository":{"full_name":"acme/other"}}`, ""},
+		{"pull_request_review_comment", `{"action":"created","comment":{"author_association":"OWNER"},` + pr + `,"repository":{"full_name":"acme/app"},"sender":{"login":"gptcode-bot"}}`, ""},
+		{"pull_request_review_comment", `{"action":"created","comment":{"author_association":"OWNER"},"pull_request":{"number":9,"head":{"ref":"x","repo":{"full_name":"fork/app"}}},` + repo + `}`, ""},
+	}
+	for _, c := range cases {
+		reqs, err := Parse(c.event, []byte(c.body), f)
+		if err != nil {
+			t.Fatalf("%s: %v", c.event, err)
+		}
+		got := ""
+		if len(reqs) == 1 {
+			got = reqs[0].Key()
+			if reqs[0].Kind != KindFix && reqs[0].Ref != "issue-4-fix" {
+				t.Errorf("%s: ref %q", c.event, reqs[0].Ref)
+			}
+		}
+		if got != c.want {
+			t.Errorf("%s %s: got %q, want %q", c.event, c.body, got, c.want)
+		}
+	}
+}
+
+func TestQueue(t *testing.T) {
+	release := make(chan struct{})
+	ran := make(chan Request, 10)
+	run := func(ctx context.Context, req Request) (string, error) {
+		<-release
+		ran <- req
+		return "done", nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewQueue(ctx, run, 1)
+	q.MaxAttempts = 2
+
+	req := Request{Kind: KindReview, Repo: "acme/app", Number: 9}
+	first := q.Add(req)
+	// Wait for the worker to pick up the first job, so the next is queued
+	for q.Get(first.ID).Status != StatusRunning {
+		time.Sleep(time.Millisecond)
+	}
+	second := q.Add(req)
+	if merged := q.Add(req); merged.ID != second.ID {
+		t.Errorf("a request for queued work should be merged, got job %s and %s", second.ID, merged.ID)
+	}
+	if skipped := q.Add(req); skipped.Status != StatusQueued || skipped.ID != second.ID {
+		t.Errorf("still merged while queued, got %+v", skipped)
+	}
+
+	close(release)
+	<-ran
+	<-ran
+	for q.Get(second.ID).Status != StatusSucceeded {
+		time.Sleep(time.Millisecond)
+	}
+	if third := q.Add(req); third.Status != StatusSkipped {
+		t.Errorf("a third attempt should be skipped, got %+v", third)
+	}
+	if jobs := q.List(); len(jobs) != 3 || jobs[0].Status != StatusSkipped || jobs[2].Output != "done" {
+		t.Errorf("unexpected jobs %+v", jobs)
+	}
+}
+
+func TestServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q := NewQueue(ctx, func(ctx context.Context, req Request) (string, error) { return "", nil }, 1)
+	srv := httptest.NewServer(NewServer("s3cret", Filter{Repo: "acme/app"}, q))
+	defer srv.Close()
+
+	body := `{"action":"labeled","label":{"name":"gptcode-fix"},"issue":{"number":4},"repository":{"full_name":"acme/app"}}`
+	post := func(signature string) *http.Response {
+		req, _ := http.NewRequest("POST", srv.URL+"/webhook", strings.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "issues")
+		req.Header.Set("X-Hub-Signature-256", signature)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := post(sign("wrong", body)); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("a bad signature should be rejected, got %d", resp.StatusCode)
+	}
+	resp := post(sign("s3cret", body))
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", resp.StatusCode)
+	}
+	var accepted struct{ Jobs []Job }
+	_ = json.NewDecoder(resp.Body).Decode(&accepted)
+	if len(accepted.Jobs) != 1 || accepted.Jobs[0].Key() != "fix acme/app#4" {
+		t.Fatalf("unexpected jobs %+v", accepted.Jobs)
+	}
+
+	if resp, _ := http.Get(srv.URL + "/jobs"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("job status needs the secret, got %d", resp.StatusCode)
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/jobs/"+accepted.Jobs[0].ID, nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected the job, got %v %v", resp, err)
+	}
+}
@@ -0,0 +1,139 @@
+// Read-only dashboard of past gptcode runs. Every view is rendered from the
+// JSON API with plain DOM calls; all recorded text is inserted as text, never
+// as HTML.
+"use strict";
+
+const view = document.getElementById("view");
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === "onclick") node.addEventListener("click", value);
+    else node.setAttribute(key, value);
+  }
+  for (const child of children.flat()) {
+    if (child == null) continue;
+    node.append(child instanceof Node ? child : String(child));
+  }
+  return node;
+}
+
+async function api(path) {
+  const res = await fetch("/api/" + path);
+  if (!res.ok) throw new Error((await res.text()).trim() || res.statusText);
+  return (await res.json()) || [];
+}
+
+const money = (n) => "$" + (n || 0).toFixed(4);
+const when = (t) => (t ? new Date(t).toLocaleString() : "");
+const short = (id) => (id || "").slice(0, 8);
+
+function table(headers, rows) {
+  return el("table", {},
+    el("thead", {}, el("tr", {}, headers.map((h) =>
+      el("th", { class: h.num ? "num" : "" }, h.label || h)))),
+    el("tbody", {}, rows.map((cells) => el("tr", {}, cells.map((c, i) =>
+      el("td", { class: headers[i].num ? "num" : "" }, c))))));
+}
+
+function diffBlock(text) {
+  return el("pre", {}, text.split("\n").map((line) => {
+    if (line.startsWith("+") && !line.startsWith("+++")) return el("span", { class: "add" }, line);
+    if (line.startsWith("-") && !line.startsWith("---")) return el("span", { class: "del" }, line);
+    return line + "\n";
+  }));
+}
+
+const views = {
+  async sessions() {
+    const sessions = await api("sessions");
+    if (!sessions.length) return el("p", { class: "muted" }, "No runs recorded yet.");
+    return table(
+      ["Session", "Command", "Started", { label: "Files", num: true }, { label: "Calls", num: true },
+        { label: "Tokens in/out", num: true }, { label: "Cost", num: true }],
+      sessions.map((s) => [
+        el("a", { href: "#session/" + encodeURIComponent(s.id) }, short(s.id)),
+        [s.command, s.undone ? el("span", { class: "muted" }, " (undone)") : null],
+        when(s.started), s.files, s.calls, `${s.tokens_in} / ${s.tokens_out}`, money(s.cost),
+      ]));
+  },
+
+  async session(id) {
+    const s = await api("sessions/" + encodeURIComponent(id));
+    const files = s.files || [];
+    const exchanges = s.exchanges || [];
+    return el("div", {},
+      el("h2", {}, s.command || short(s.id)),
+      el("p", { class: "muted" }, `${s.id} · ${when(s.started)} · ${s.workdir || ""} · ${money(s.cost)}`),
+      el("section", {}, el("h3", {}, `Files (${files.length})`),
+        files.length ? files.map((f) => el("details", {},
+          el("summary", {}, `${f.path} `, el("span", { class: "muted" }, f.status)),
+          el("div", {}, f.diff ? diffBlock(f.diff) : el("p", { class: "muted" }, "No diff")))) :
+          el("p", { class: "muted" }, "No files changed.")),
+      el("section", {}, el("h3", {}, `LLM exchanges (${exchanges.length})`),
+        exchanges.length ? exchanges.map((ex, i) => el("details", {},
+          el("summary", {}, `#${i + 1} ${ex.backend}/${ex.model} `, el("span", { class: "muted" }, when(ex.time))),
+          el("div", {},
+            ex.system ? [el("h4", {}, "System"), el("pre", {}, ex.system)] : null,
+            ex.messages ? [el("h4", {}, "Messages"), el("pre", {}, JSON.stringify(ex.messages, null, 2))] : null,
+            el("h4", {}, "Response"), el("pre", {}, ex.response || ""),
+            ex.tool_calls ? [el("h4", {}, "Tool calls"), el("pre", {}, JSON.stringify(ex.tool_calls, null, 2))] : null))) :
+          el("p", { class: "muted" }, "No transcript for this session.")));
+  },
+
+  async traces() {
+    const traces = await api("traces");
+    if (!traces.length) return el("p", { class: "muted" }, "No traces recorded for this project.");
+    return table(
+      ["Session", "Command", "Started", "Path", { label: "Steps", num: true },
+        { label: "Time", num: true }, { label: "Cost", num: true }, "Result"],
+      traces.map((t) => [
+        short(t.session_id), t.command, when(t.start_time), (t.path || []).join(" → "),
+        (t.steps || []).length, ((t.total_time_ms || 0) / 1000).toFixed(1) + "s", money(t.total_cost),
+        el("span", { class: t.success ? "good" : "bad" }, t.success ? "success" : "failed"),
+      ]));
+  },
+
+  async costs() {
+    const rows = await api("costs");
+    if (!rows.length) return el("p", { class: "muted" }, "No usage recorded yet.");
+    const total = rows.reduce((sum, r) => sum + r.cost, 0);
+    return el("div", {},
+      el("p", {}, "Total: ", el("strong", {}, money(total))),
+      table(
+        ["Day", "Backend", "Model", { label: "Calls", num: true },
+          { label: "Tokens in/out", num: true }, { label: "Cost", num: true }],
+        rows.map((r) => [r.day, r.backend, r.model, r.calls, `${r.tokens_in} / ${r.tokens_out}`, money(r.cost)])));
+  },
+
+  async feedback() {
+    const events = await api("feedback");
+    if (!events.length) return el("p", { class: "muted" }, "No feedback recorded yet.");
+    return table(
+      ["When", "Sentiment", "Agent", "Model", "Task"],
+      events.map((e) => [
+        when(e.timestamp),
+        el("span", { class: e.sentiment === "good" ? "good" : "bad" }, e.sentiment),
+        e.agent, `${e.backend}/${e.model}`, e.task || "",
+      ]));
+  },
+};
+
+async function render() {
+  const [name, ...rest] = (location.hash.slice(1) || "sessions").split("/");
+  const arg = decodeURIComponent(rest.join("/"));
+  for (const link of document.querySelectorAll("nav a")) {
+    const target = link.getAttribute("href").slice(1);
+    link.classList.toggle("active", target === name || (name === "session" && target === "sessions"));
+  }
+  const show = views[name] || views.sessions;
+  view.replaceChildren(el("p", { class: "muted" }, "Loading…"));
+  try {
+    view.replaceChildren(await show(arg));
+  } catch (err) {
+    view.replaceChildren(el("p", { class: "bad" }, "Failed to load: " + err.message));
+  }
+}
+
+window.addEventListener("hashchange", render);
+render();
@@ -0,0 +1,265 @@
+package webui
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"gptcode/internal/feedback"
+	"gptcode/internal/intelligence"
+	"gptcode/internal/observability"
+	"gptcode/internal/tools"
+)
+
+// Sources reads the stores the dashboard shows. Default reads the files
+// under ~/.gptcode; tests substitute fixtures.
+type Sources struct {
+	Journals   func() ([]*tools.Journal, error)
+	Transcript func(session string) ([]observability.Exchange, error)
+	Traces     func() ([]observability.SessionTrace, error)
+	Usage      func() ([]observability.UsageRecord, error)
+	Feedback   func() ([]feedback.Event, error)
+}
+
+// DefaultSources reads journals, transcripts, usage and feedback from
+// ~/.gptcode and the traces of the project in workdir.
+func DefaultSources(workdir string) Sources {
+	return Sources{
+		Journals: tools.ListJournals,
+		Transcript: func(session string) ([]observability.Exchange, error) {
+			return observability.LoadTranscript(filepath.Join(observability.TranscriptsDir(), session+".jsonl"))
+		},
+		Traces: func() ([]observability.SessionTrace, error) {
+			return intelligence.LoadProjectTraces(intelligence.ProjectKey(workdir))
+		},
+		Usage: func() ([]observability.UsageRecord, error) {
+			return observability.LoadUsage(observability.UsagePath())
+		},
+		Feedback: feedback.LoadAll,
+	}
+}
+
+// Session summarizes one run: what it was, what it cost and how many files
+// it changed
+type Session struct {
+	ID        string     `json:"id"`
+	Command   string     `json:"command"`
+	Workdir   string     `json:"workdir,omitempty"`
+	Started   time.Time  `json:"started"`
+	Undone    *time.Time `json:"undone,omitempty"`
+	Files     int        `json:"files"`
+	Calls     int        `json:"calls"`
+	TokensIn  int        `json:"tokens_in"`
+	TokensOut int        `json:"tokens_out"`
+	Cost      float64    `json:"cost"`
+}
+
+// FileDiff is a file a session changed, diffed against its current content
+type FileDiff struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	Diff   string `json:"diff,omitempty"`
+}
+
+// SessionDetail is everything recorded about one session
+type SessionDetail struct {
+	Session
+	Usage     []observability.UsageRecord `json:"usage"`
+	Exchanges []observability.Exchange    `json:"exchanges"`
+	Files     []FileDiff                  `json:"files"`
+}
+
+// CostRow is one day's spend on a backend and model
+type CostRow struct {
+	Day       string  `json:"day"`
+	Backend   string  `json:"backend"`
+	Model     string  `json:"model"`
+	Calls     int     `json:"calls"`
+	TokensIn  int     `json:"tokens_in"`
+	TokensOut int     `json:"tokens_out"`
+	Cost      float64 `json:"cost"`
+}
+
+// sessions merges the journals and usage records into one entry per
+// session, newest first. A session that changed no files only has usage.
+func (s Sources) sessions() ([]Session, map[string]*tools.Journal, error) {
+	journals, err := s.Journals()
+	if err != nil {
+		return nil, nil, err
+	}
+	records, err := s.Usage()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byID := map[string]*Session{}
+	byJournal := map[string]*tools.Journal{}
+	for _, j := range journals {
+		byJournal[j.Session] = j
+		byID[j.Session] = &Session{
+			ID:      j.Session,
+			Command: j.Command,
+			Workdir: j.Workdir,
+			Started: j.Started,
+			Undone:  j.Undone,
+			Files:   len(j.Entries),
+		}
+	}
+	for _, r := range records {
+		if r.Session == "" {
+			continue
+		}
+		sess, ok := byID[r.Session]
+		if !ok {
+			sess = &Session{ID: r.Session, Command: r.Command, Started: r.Time}
+			byID[r.Session] = sess
+		}
+		sess.Calls += r.Calls
+		sess.TokensIn += r.TokensIn
+		sess.TokensOut += r.TokensOut
+		sess.Cost += r.Cost
+	}
+
+	out := make([]Session, 0, len(byID))
+	for _, sess := range byID {
+		out = append(out, *sess)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Started.After(out[j].Started) })
+	return out, byJournal, nil
+}
+
+// session returns the detail of the session with id.
+func (s Sources) session(id string) (*SessionDetail, bool, error) {
+	sessions, journals, err := s.sessions()
+	if err != nil {
+		return nil, false, err
+	}
+	var detail *SessionDetail
+	for _, sess := range sessions {
+		if sess.ID == id {
+			detail = &SessionDetail{Session: sess}
+			break
+		}
+	}
+	if detail == nil {
+		return nil, false, nil
+	}
+
+	records, err := s.Usage()
+	if err != nil {
+		return nil, false, err
+	}
+	for _, r := range records {
+		if r.Session == id {
+			detail.Usage = append(detail.Usage, r)
+		}
+	}
+	if detail.Exchanges, err = s.Transcript(id); err != nil {
+		return nil, false, err
+	}
+	if j := journals[id]; j != nil {
+		for _, e := range j.Entries {
+			detail.Files = append(detail.Files, diffEntry(j, e))
+		}
+	}
+	return detail, true, nil
+}
+
+// diffEntry diffs the file as it was before the session against its
+// current content, so later edits by someone else show up too.
+func diffEntry(j *tools.Journal, e *tools.JournalEntry) FileDiff {
+	name := e.Path
+	if rel, err := filepath.Rel(j.Workdir, e.Path); err == nil && !strings.HasPrefix(rel, "..") {
+		name = rel
+	}
+	fd := FileDiff{Path: name}
+
+	before, err := j.Original(e)
+	if err != nil {
+		fd.Status = "missing snapshot"
+		return fd
+	}
+	after, err := os.ReadFile(e.Path)
+	exists := err == nil
+	switch {
+	case !e.Existed && !exists:
+		fd.Status = "created, since removed"
+		return fd
+	case !e.Existed:
+		fd.Status = "created"
+	case !exists:
+		fd.Status = "deleted"
+	default:
+		fd.Status = "modified"
+	}
+
+	fd.Diff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(before)),
+		B:        difflib.SplitLines(string(after)),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	})
+	if fd.Diff == "" && fd.Status == "modified" {
+		fd.Status = "unchanged"
+	}
+	return fd
+}
+
+// costs sums usage per day, backend and model, newest day first.
+func (s Sources) costs() ([]CostRow, error) {
+	records, err := s.Usage()
+	if err != nil {
+		return nil, err
+	}
+	type key struct{ day, backend, model string }
+	rows := map[key]*CostRow{}
+	for _, r := range records {
+		k := key{r.Time.Local().Format("2006-01-02"), r.Backend, r.Model}
+		row, ok := rows[k]
+		if !ok {
+			row = &CostRow{Day: k.day, Backend: k.backend, Model: k.model}
+			rows[k] = row
+		}
+		row.Calls += r.Calls
+		row.TokensIn += r.TokensIn
+		row.TokensOut += r.TokensOut
+		row.Cost += r.Cost
+	}
+
+	out := make([]CostRow, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Day != out[j].Day {
+			return out[i].Day > out[j].Day
+		}
+		return out[i].Cost > out[j].Cost
+	})
+	return out, nil
+}
+
+// traces returns the project's traces, newest first.
+func (s Sources) traces() ([]observability.SessionTrace, error) {
+	traces, err := s.Traces()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(traces, func(i, j int) bool { return traces[i].StartTime.After(traces[j].StartTime) })
+	return traces, nil
+}
+
+// feedback returns the recorded feedback events, newest first.
+func (s Sources) feedback() ([]feedback.Event, error) {
+	events, err := s.Feedback()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Timestamp.After(events[j].Timestamp) })
+	return events, nil
+}
@@ -0,0 +1,162 @@
+// Package webui serves a read-only local dashboard of past runs: sessions
+// with their transcripts and diffs, traces, costs and feedback.
+package webui
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// Default request rate limit. The dashboard reloads a handful of endpoints
+// per view, so this only stops a runaway script or page from hammering the
+// stores on disk.
+const (
+	DefaultRate  = 10
+	DefaultBurst = 30
+)
+
+// Server serves the dashboard's assets and JSON API.
+type Server struct {
+	sources Sources
+	limiter *limiter
+	mux     *http.ServeMux
+}
+
+// NewServer returns a dashboard over sources allowing rate requests per
+// second with bursts of burst. Zero values use the defaults.
+func NewServer(sources Sources, rate float64, burst int) *Server {
+	if rate <= 0 {
+		rate = DefaultRate
+	}
+	if burst <= 0 {
+		burst = DefaultBurst
+	}
+	s := &Server{sources: sources, limiter: newLimiter(rate, burst), mux: http.NewServeMux()}
+
+	static, _ := fs.Sub(assets, "assets")
+	s.mux.Handle("GET /", http.FileServer(http.FS(static)))
+	s.mux.HandleFunc("GET /api/sessions", s.handleSessions)
+	s.mux.HandleFunc("GET /api/sessions/{id}", s.handleSession)
+	s.mux.HandleFunc("GET /api/traces", s.handleTraces)
+	s.mux.HandleFunc("GET /api/costs", s.handleCosts)
+	s.mux.HandleFunc("GET /api/feedback", s.handleFeedback)
+	return s
+}
+
+// ServeHTTP only answers reads from localhost within the rate limit.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "the dashboard is read-only", http.StatusMethodNotAllowed)
+		return
+	}
+	// A page on another site could otherwise read transcripts through a
+	// DNS name rebound to 127.0.0.1
+	if !isLocalHost(r.Host) {
+		http.Error(w, "forbidden host", http.StatusForbidden)
+		return
+	}
+	if !s.limiter.allow() {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func isLocalHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = hostport
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ListenAndServe serves the dashboard on 127.0.0.1:port until it fails.
+func (s *Server) ListenAndServe(port int) error {
+	srv := &http.Server{
+		Addr:              net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return srv.ListenAndServe()
+}
+
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, _, err := s.sources.sessions()
+	writeJSON(w, sessions, err)
+}
+
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	detail, ok, err := s.sources.session(r.PathValue("id"))
+	if err == nil && !ok {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, detail, err)
+}
+
+func (s *Server) handleTraces(w http.ResponseWriter, r *http.Request) {
+	traces, err := s.sources.traces()
+	writeJSON(w, traces, err)
+}
+
+func (s *Server) handleCosts(w http.ResponseWriter, r *http.Request) {
+	costs, err := s.sources.costs()
+	writeJSON(w, costs, err)
+}
+
+func (s *Server) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	events, err := s.sources.feedback()
+	writeJSON(w, events, err)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// limiter is a token bucket refilled at rate tokens per second up to burst.
+type limiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+	mu     sync.Mutex
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now(), now: time.Now}
+}
+
+func (l *limiter) allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
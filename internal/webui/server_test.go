@@ -0,0 +1,132 @@
+package webui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gptcode/internal/feedback"
+	"gptcode/internal/observability"
+	"gptcode/internal/tools"
+)
+
+func testSources(t *testing.T) Sources {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	j := tools.NewJournal("3f2a9c1e", "gptcode do", dir)
+	if err := j.Snapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	return Sources{
+		Journals: tools.ListJournals,
+		Transcript: func(session string) ([]observability.Exchange, error) {
+			return []observability.Exchange{{Backend: "openai", Model: "gpt-4o", Response: "done"}}, nil
+		},
+		Traces: func() ([]observability.SessionTrace, error) { return nil, nil },
+		Usage: func() ([]observability.UsageRecord, error) {
+			return []observability.UsageRecord{
+				{Time: now, Session: "3f2a9c1e", Backend: "openai", Model: "gpt-4o", Calls: 2, Cost: 0.01},
+				{Time: now, Session: "b7c1", Command: "gptcode research", Backend: "openai", Model: "gpt-4o", Calls: 1, Cost: 0.02},
+			}, nil
+		},
+		Feedback: func() ([]feedback.Event, error) {
+			return []feedback.Event{{Sentiment: feedback.SentimentGood, Agent: "editor"}}, nil
+		},
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://127.0.0.1:7420"+path, nil))
+	if v != nil && rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+	}
+	return rec
+}
+
+func TestServerAPI(t *testing.T) {
+	s := NewServer(testSources(t), 1000, 1000)
+
+	var sessions []Session
+	get(t, s, "/api/sessions", &sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("expected a session from the journal and one from usage only, got %+v", sessions)
+	}
+
+	var detail SessionDetail
+	get(t, s, "/api/sessions/3f2a9c1e", &detail)
+	if detail.Calls != 2 || detail.Command != "gptcode do" || len(detail.Exchanges) != 1 {
+		t.Errorf("unexpected session detail %+v", detail)
+	}
+	if len(detail.Files) != 1 || detail.Files[0].Path != "main.go" || detail.Files[0].Status != "modified" ||
+		!strings.Contains(detail.Files[0].Diff, "+func main() {}") {
+		t.Errorf("expected the diff of main.go, got %+v", detail.Files)
+	}
+
+	if rec := get(t, s, "/api/sessions/unknown", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown session returned %d", rec.Code)
+	}
+
+	var costs []CostRow
+	get(t, s, "/api/costs", &costs)
+	if len(costs) != 1 || costs[0].Calls != 3 {
+		t.Errorf("usage should be summed per day and model, got %+v", costs)
+	}
+
+	if rec := get(t, s, "/", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "app.js") {
+		t.Errorf("index not served: %d", rec.Code)
+	}
+}
+
+func TestServerIsReadOnlyAndLocal(t *testing.T) {
+	s := NewServer(testSources(t), 1000, 1000)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "http://127.0.0.1:7420/api/sessions", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST returned %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://evil.example:7420/api/sessions", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("request for a foreign host returned %d", rec.Code)
+	}
+}
+
+func TestServerRateLimits(t *testing.T) {
+	s := NewServer(testSources(t), 1, 3)
+	now := time.Now()
+	s.limiter.now = func() time.Time { return now }
+	s.limiter.last = now
+
+	for i := 0; i < 3; i++ {
+		if rec := get(t, s, "/api/feedback", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst returned %d", i, rec.Code)
+		}
+	}
+	if rec := get(t, s, "/api/feedback", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("request past the burst returned %d", rec.Code)
+	}
+	now = now.Add(time.Second)
+	if rec := get(t, s, "/api/feedback", nil); rec.Code != http.StatusOK {
+		t.Errorf("a token should be refilled after a second, got %d", rec.Code)
+	}
+}
@@ -225,6 +225,12 @@ function M.setup(opts)
     desc = "GPTCode: search models",
   })
   
+  vim.api.nvim_create_autocmd({ "BufEnter", "BufDelete" }, {
+    callback = function()
+      vim.schedule(M.record_open_files)
+    end,
+  })
+
   vim.api.nvim_create_autocmd("VimLeave", {
     callback = function()
       chat_state.conversation = {}
@@ -806,7 +812,7 @@ function M.show_model_picker(callback, agent, backend)
     
     query = query or ""
     
-    local cmd = {"gptcode", "models", "search"}
+    local cmd = {"gptcode", "models", "search", "--json"}
     if query ~= "" then
       for term in query:gmatch("%S+") do
         table.insert(cmd, term)
@@ -975,7 +981,7 @@ function M.search_models()
       table.insert(terms, term)
     end
     
-    local cmd = {"gptcode", "models", "search"}
+    local cmd = {"gptcode", "models", "search", "--json"}
     for _, term in ipairs(terms) do
       table.insert(cmd, term)
     end
@@ -1690,6 +1696,40 @@ function M.create_code_tabs(blocks)
 end
 
 
+-- Records the listed file buffers, most recently used first, in
+-- ~/.gptcode/open_files.json under the current directory so context
+-- selection can favor the files being worked on.
+function M.record_open_files()
+  local root = vim.fn.getcwd()
+  local bufs = vim.fn.getbufinfo({ buflisted = 1 })
+  table.sort(bufs, function(a, b) return a.lastused > b.lastused end)
+  local files = {}
+  for _, info in ipairs(bufs) do
+    if info.name ~= "" and vim.fn.filereadable(info.name) == 1 and #files < 20 then
+      table.insert(files, info.name)
+    end
+  end
+
+  local path = vim.fn.expand("~/.gptcode/open_files.json")
+  local projects = {}
+  local fh = io.open(path, "r")
+  if fh then
+    local ok, decoded = pcall(vim.json.decode, fh:read("*a"))
+    fh:close()
+    if ok and type(decoded) == "table" then
+      projects = decoded
+    end
+  end
+  projects[root] = files
+
+  vim.fn.mkdir(vim.fn.fnamemodify(path, ":h"), "p")
+  fh = io.open(path, "w")
+  if fh then
+    fh:write(vim.json.encode(projects))
+    fh:close()
+  end
+end
+
 local function ensure_memory_dir()
   local mem_path = config.memory_file
   local dir = vim.fn.fnamemodify(mem_path, ":h")
@@ -0,0 +1,52 @@
+// Command sign-checksums signs a release's checksums.txt with the ed25519
+// key in RELEASE_SIGNING_KEY, writing the base64 signature gptcode update
+// verifies to checksums.txt.sig. With -keygen it prints a new key pair: the
+// private key goes in the RELEASE_SIGNING_KEY secret, the public one in the
+// RELEASE_PUBLIC_KEY variable the release build embeds.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	keygen := flag.Bool("keygen", false, "print a new key pair and exit")
+	flag.Parse()
+
+	if *keygen {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Println("RELEASE_SIGNING_KEY=" + base64.StdEncoding.EncodeToString(priv))
+		fmt.Println("RELEASE_PUBLIC_KEY=" + base64.StdEncoding.EncodeToString(pub))
+		return
+	}
+	if flag.NArg() != 1 {
+		fail(fmt.Errorf("usage: sign-checksums <checksums.txt>"))
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(os.Getenv("RELEASE_SIGNING_KEY")))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		fail(fmt.Errorf("RELEASE_SIGNING_KEY must be a base64 ed25519 private key"))
+	}
+	path := flag.Arg(0)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fail(err)
+	}
+	sig := ed25519.Sign(ed25519.PrivateKey(key), data)
+	if err := os.WriteFile(path+".sig", []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0644); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
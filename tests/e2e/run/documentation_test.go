@@ -64,7 +64,7 @@ func TestDocumentationUpdates(t *testing.T) {
 		}
 
 		// Load config and create generator
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			t.Fatalf("Failed to load config: %v", err)
 		}
@@ -77,7 +77,7 @@ func TestDocumentationUpdates(t *testing.T) {
 
 		var provider llm.Provider
 		if backendCfg.Type == "ollama" {
-			provider = llm.NewOllama(backendCfg.BaseURL)
+			provider = llm.NewOllama(backendCfg.BaseURL, backendName)
 		} else {
 			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
 		}
@@ -67,7 +67,7 @@ go 1.21
 		}
 
 		// Load config and create generator
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			t.Fatalf("Failed to load config: %v", err)
 		}
@@ -80,7 +80,7 @@ go 1.21
 
 		var provider llm.Provider
 		if backendCfg.Type == "ollama" {
-			provider = llm.NewOllama(backendCfg.BaseURL)
+			provider = llm.NewOllama(backendCfg.BaseURL, backendName)
 		} else {
 			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
 		}
@@ -225,7 +225,7 @@ go 1.21
 		}
 
 		// Load config and create generator
-		setup, err := config.LoadSetup()
+		setup, err := config.LoadEffectiveSetup()
 		if err != nil {
 			t.Fatalf("Failed to load config: %v", err)
 		}
@@ -238,7 +238,7 @@ go 1.21
 
 		var provider llm.Provider
 		if backendCfg.Type == "ollama" {
-			provider = llm.NewOllama(backendCfg.BaseURL)
+			provider = llm.NewOllama(backendCfg.BaseURL, backendName)
 		} else {
 			provider = llm.NewChatCompletion(backendCfg.BaseURL, backendName)
 		}